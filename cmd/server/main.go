@@ -14,8 +14,11 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
+	// Export time zones resolve on hosts without a zoneinfo database.
+	_ "time/tzdata"
 
 	"github.com/google/uuid"
 	lumberjack "gopkg.in/natefinch/lumberjack.v2"
@@ -52,12 +55,22 @@ var version = "dev"
 func main() {
 	var (
 		showVersion bool
+		validate    bool
+		migratePlan bool
+		migrateOnly bool
+		seedWipe    bool
 		dev         bool
 		addr        string
 		dbPath      string
 		configPath  string
+		seedPath    string
 	)
 	flag.BoolVar(&showVersion, "version", false, "print version and exit")
+	flag.BoolVar(&validate, "validate", false, "check the config, database, storage and listen address, then exit; changes nothing")
+	flag.BoolVar(&migratePlan, "migrate-plan", false, "print the schema changes the next start would apply and any schema drift, then exit; changes nothing")
+	flag.BoolVar(&migrateOnly, "migrate-only", false, "apply database migrations and exit")
+	flag.StringVar(&seedPath, "seed", "", "create or update the users, folders, credentials, connections and shares in this YAML/JSON fixture, then exit")
+	flag.BoolVar(&seedWipe, "seed-wipe", false, "delete everything earlier -seed runs created before seeding, then exit; requires "+seedWipeEnv+"=true")
 	flag.BoolVar(&dev, "dev", false, "dev mode: serve the API only; Vite serves the UI")
 	flag.StringVar(&configPath, "config", "", "extra directory to search for config.yaml (besides . and ./config)")
 	flag.StringVar(&addr, "addr", "", "address to listen on (overrides config)")
@@ -81,6 +94,43 @@ func main() {
 	if dbPath != "" {
 		cfg.Database.DSN = dbPath
 	}
+	if validate {
+		ctx, cancel := context.WithTimeout(context.Background(), validateTimeout)
+		failed := printValidation(os.Stdout, validateConfig(ctx, cfg))
+		cancel()
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+	if migratePlan {
+		ctx, cancel := context.WithTimeout(context.Background(), validateTimeout)
+		err := planMigrations(ctx, os.Stdout, cfg)
+		cancel()
+		if err != nil {
+			slog.Default().Error("plan migrations", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if migrateOnly {
+		st, err := store.Open(store.Config{Driver: store.Driver(cfg.Database.Driver), DSN: cfg.Database.DSN})
+		if err != nil {
+			slog.Default().Error("migrate", "err", err)
+			os.Exit(1)
+		}
+		_ = st.Close()
+		slog.Default().Info("database migrated", "driver", displayDriver(store.Driver(cfg.Database.Driver)))
+		return
+	}
+
+	if seedPath != "" || seedWipe {
+		if err := runSeed(context.Background(), os.Stdout, cfg, seedPath, seedWipe); err != nil {
+			slog.Default().Error("seed", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	// Logs go to stdout, or to a size-rotated file when configured.
 	logOut := io.Writer(os.Stdout)
@@ -106,6 +156,7 @@ func run(logger *slog.Logger, cfg *config.Config, dev bool) error {
 	// Secrets and store.
 	// Master key: required in prod; generated (ephemeral) with a loud warning in dev.
 	masterKey, err := secrets.ResolveMasterKey(cfg.Secrets.MasterKey, cfg.Secrets.MasterKeyFile)
+	ephemeralKey := err != nil
 	if err != nil {
 		if !dev {
 			return fmt.Errorf("load master key: %w", err)
@@ -119,15 +170,47 @@ func run(logger *slog.Logger, cfg *config.Config, dev bool) error {
 		return err
 	}
 
-	st, err := store.Open(store.Config{Driver: store.Driver(cfg.Database.Driver), DSN: cfg.Database.DSN})
+	metrics := telemetry.NewMetrics()
+	resilience := &store.Resilience{
+		BreakerThreshold: cfg.Database.BreakerThreshold,
+		BreakerCooldown:  cfg.Database.BreakerCooldownDuration(),
+		OnBreakerChange: func(open bool) {
+			metrics.SetDBCircuitOpen(open)
+			if open {
+				logger.Error("database circuit breaker opened", "module", "database")
+			} else {
+				logger.Info("database circuit breaker closed", "module", "database")
+			}
+		},
+	}
+	storeCfg := store.Config{Driver: store.Driver(cfg.Database.Driver), DSN: cfg.Database.DSN, Resilience: resilience}
+	if cfg.Database.QueryMetrics {
+		storeCfg.Instrumentation = &store.QueryInstrumentation{
+			Observe:       metrics.ObserveDBQuery,
+			SlowThreshold: cfg.Database.SlowQueryDuration(),
+			Logger:        logger.With("module", "database"),
+		}
+	}
+	st, err := store.Open(storeCfg)
 	if err != nil {
 		return fmt.Errorf("open store: %w", err)
 	}
 	defer func() { _ = st.Close() }()
 
+	vaultReport, err := vaultSelfTest(context.Background(), logger, st, vault, ephemeralKey, cfg.Secrets)
+	if err != nil {
+		return err
+	}
 	if err := bootstrapAdmin(context.Background(), logger, st, cfg.Bootstrap); err != nil {
 		return err
 	}
+	repairs, err := service.RepairConnectionFolders(context.Background(), st.ConnectionFolders)
+	for _, r := range repairs {
+		logger.Warn("moved connection folder to its owner's root", "folder", r.FolderID, "user", r.UserID, "parent", r.ParentID, "reason", r.Reason)
+	}
+	if err != nil {
+		return fmt.Errorf("repair connection folders: %w", err)
+	}
 
 	// Core registries and policy.
 	reg := pluginregistry.New()
@@ -141,16 +224,70 @@ func run(logger *slog.Logger, cfg *config.Config, dev bool) error {
 		return fmt.Errorf("load policies: %w", err)
 	}
 
+	// System settings read through one cache; services that keep a setting in
+	// memory reload it when the admin settings API changes it.
+	settings := service.NewSettingsService(st.SystemSettings)
+	settings.Register(service.BuiltinSettings()...)
+
 	// Live-state leasing and transports.
 	internalURLs := livelease.DiscoverInternalURLs(livelease.PortFromListenAddress(cfg.Server.Addr), false)
 	instance := livelease.NewLocalInstanceRef(internalURLs...)
 	leases := livelease.NewStoreLeaseRegistry(st.LiveStateLeases)
 	leaseTTL := cfg.LiveState.LeaseTTLDuration()
 	renewInterval := cfg.LiveState.RenewIntervalDuration()
-	sessions := session.New(session.Options{LeaseRegistry: leases, Instance: instance, LeaseTTL: leaseTTL, RenewInterval: renewInterval})
+	webhooks := service.NewWebhookService(st.Webhooks, st.WebhookDeliveries, vault,
+		service.WithWebhookLogger(logger.With("module", "webhooks")))
+	defer webhooks.Close()
+	shares := service.NewSessionShareService(st.SessionShareLinks, st.SessionParticipants, st.SessionWriteRequests, logger.With("module", "session_shares"),
+		service.WithWriteRequestTTL(cfg.LiveState.WriteRequestTimeoutDuration()))
+	presence := session.NewPresenceHub(0)
+	sessionMetrics := service.NewSessionMetrics(metrics, func(protocol string) bool {
+		_, ok := reg.Manifest(protocol)
+		return ok
+	})
+	history := service.NewSessionHistory(st.ConnectionSessions,
+		service.WithSessionHistoryLogger(logger.With("module", "session_history")),
+		service.WithSessionHistoryObservations(st.SessionObservations))
+	// Runs after sessions.Shutdown so the final counts of every session closed
+	// there are kept.
+	defer history.Flush(context.Background(), nil)
+	sessionCaps := service.NewSessionCaps(settings, st.Users, logger.With("module", "session_caps"))
+	launches := service.NewSessionLaunches()
+	scrollbackBytes := cfg.LiveState.ScrollbackBytes
+	if scrollbackBytes <= 0 {
+		scrollbackBytes = -1 // session.Options takes 0 as the default size
+	}
+	sessions := session.New(session.Options{
+		LeaseRegistry: leases, Instance: instance, LeaseTTL: leaseTTL, RenewInterval: renewInterval,
+		UserLimit:       sessionCaps.UserLimit,
+		ReconnectGrace:  cfg.LiveState.ReconnectGraceDuration(),
+		ScrollbackBytes: scrollbackBytes,
+		CloseTimeout:    cfg.LiveState.SessionCloseTimeoutDuration(),
+		MaxLifetime:     cfg.LiveState.SessionMaxLifetimeDuration(),
+		LaunchTimeout:   cfg.LiveState.SessionLaunchTimeoutDuration(),
+		ClipboardBytes:  cfg.LiveState.ClipboardMaxBytes,
+		OnLaunch:        launches.Launched,
+		OnCloseStuck: func(snap session.Snapshot) {
+			logger.Warn("upstream session close is stuck; leaving it to finish in the background",
+				"session", snap.ID, "connection", snap.Key.ConnectionID, "user", snap.UserID)
+		},
+		OnOpen: func(snap session.Snapshot) {
+			webhooks.SessionStarted(snap)
+			sessionMetrics.SessionStarted(snap)
+			history.SessionStarted(snap)
+		},
+		OnResize:       webhooks.SessionResized,
+		OnReconnecting: webhooks.SessionReconnecting,
+		OnClose: func(snap session.Snapshot) {
+			webhooks.SessionClosed(snap)
+			shares.SessionClosed(snap)
+			presence.SessionClosed(snap)
+			sessionMetrics.SessionClosed(snap)
+			history.SessionClosed(snap)
+		},
+	})
 	defer sessions.Shutdown()
 
-	metrics := telemetry.NewMetrics()
 	tunnels := transport.NewRegistry(
 		transport.WithLeaseRegistry(leases, instance),
 		transport.WithLeaseTTL(leaseTTL),
@@ -158,21 +295,57 @@ func run(logger *slog.Logger, cfg *config.Config, dev bool) error {
 	)
 
 	// Connection services.
-	creds := service.NewCredentialService(st.Credentials, st.CredentialGrants, vault, service.WithCredentialKindCatalog(reg))
+	credReads := service.NewCredentialReadGuard(settings)
+	if err := credReads.Load(context.Background()); err != nil {
+		return fmt.Errorf("load credential read quota: %w", err)
+	}
+	approvals := service.NewCredentialApprovals(st.CredentialApprovals, st.Credentials, st.CredentialGrants, st.Users, settings)
+	if err := approvals.Load(context.Background()); err != nil {
+		return fmt.Errorf("load credential approval settings: %w", err)
+	}
+	creds := service.NewCredentialService(st.Credentials, st.CredentialGrants, vault,
+		service.WithCredentialKindCatalog(reg), service.WithCredentialAccessLog(st.CredentialAccess),
+		service.WithCredentialVersions(st.CredentialVersions), service.WithCredentialReadGuard(credReads),
+		service.WithCredentialApprovals(approvals), service.WithCredentialUsage(st.CredentialUsage),
+		service.WithCredentialLogger(logger.With("module", "credentials")))
+	defer creds.Close()
 	creds.SetSecretAccessHook(metrics.IncSecretAccess)
 
 	connector := service.NewConnector(reg, creds, vault, tunnels)
 	connector.SetSecretAccessHook(metrics.IncSecretAccess)
+	driverSettings := service.NewDriverSettings(reg, settings)
+	connector.SetDriverSettings(driverSettings)
 
-	connections := service.NewConnectionService(st.Connections, reg, creds, vault)
+	connections := service.NewConnectionService(st.Connections, reg, creds, vault,
+		service.WithConnectionPlacements(st.ConnectionPlacements), service.WithConnectionNames(st.ConnectionNames))
+	renames, err := connections.DedupeNames(context.Background())
+	if err != nil {
+		return fmt.Errorf("dedupe connection names: %w", err)
+	}
+	for _, rn := range renames {
+		logger.Warn("renamed duplicate connection", "connection", rn.ConnectionID, "owner", rn.OwnerID,
+			"folder", rn.FolderID, "from", rn.From, "to", rn.To)
+	}
 	enrollments := service.NewEnrollmentService(st.Enrollments, st.Connections, reg)
-	protocols := service.NewProtocolService(st.ProtocolSettings)
+	protocols := service.NewProtocolService(st.ProtocolSettings,
+		service.WithProtocolCacheTTL(cfg.Connections.ProtocolCacheTTLDuration()),
+		service.WithProtocolCacheObserver(func(hit bool) { metrics.ObserveCacheLookup("protocols", hit) }))
 
-	var auditWriter audit.Sink = audit.NewWriter(st.Audit)
+	auditTail := audit.NewTail()
+	auditChain := audit.NewWriter(st.Audit, audit.WithTail(auditTail))
+	var auditWriter audit.Sink = auditChain
 	if !cfg.Audit.Enabled {
-		auditWriter = audit.Noop{}
+		auditWriter, auditTail = audit.Noop{}, nil
 		logger.Warn("audit is disabled by configuration")
 	}
+	// The anchor key is sealed under the master key, so a throwaway dev key
+	// would leave it undecryptable after a restart.
+	var auditSigner *audit.Signer
+	if !ephemeralKey {
+		if auditSigner, err = audit.LoadSigner(context.Background(), st.SystemSettings, vault); err != nil {
+			logger.Warn("audit anchor key unavailable; anchors will not be signed", "err", err)
+		}
+	}
 
 	// Out-of-tree plugins: register subprocesses from plugins.dir into the same
 	// registry as the built-ins, forwarding their audit to the core writer.
@@ -209,11 +382,91 @@ func run(logger *slog.Logger, cfg *config.Config, dev bool) error {
 	recEngine := recording.NewEngine(recording.Options{
 		Store: st.Recordings, Blobs: recBlobs, Audit: auditWriter,
 		Metrics: metrics, DefaultRetentionDays: cfg.Recordings.RetentionDays,
+		CaptureInput:   !cfg.Recordings.RedactInput,
+		OnFinalize:     webhooks.RecordingCompleted,
+		OnWriteFailure: webhooks.RecordingWriteFailed,
 	})
 	recEngine.Register(plugin.FormatAsciicastV2, recording.NewAsciicastRecorder)
 
-	recordings := service.NewRecordingService(st.Recordings, recBlobs)
-	users := service.NewUserService(st.Users)
+	// Read-only maintenance mode: recordings hold their metadata in memory
+	// while it is on and flush it when it turns off.
+	maintenance := service.NewMaintenanceService(st.SystemSettings)
+	maintenance.OnChange(func(readOnly bool) {
+		if err := recEngine.SetReadOnly(context.Background(), readOnly); err != nil {
+			logger.Warn("flush deferred recording metadata", "err", err)
+		}
+		logger.Warn("read-only maintenance mode changed", "readOnly", readOnly)
+	})
+	if err := maintenance.Load(context.Background()); err != nil {
+		return fmt.Errorf("load maintenance mode: %w", err)
+	}
+	hygiene := service.NewHygieneService(st.Orphans)
+	grantExpiry := service.NewGrantExpiry(st.Grants, st.Connections, settings)
+	idempotency := service.NewIdempotencyService(st.IdempotencyKeys, service.DefaultIdempotencyTTL)
+	jobs := service.NewJobService(st.Jobs, recBlobs, service.WithJobLogger(logger.With("module", "jobs")))
+	jobs.Register(service.JobAuditExport, service.AuditExportJob(st.Audit))
+	if err := jobs.Start(context.Background()); err != nil {
+		return fmt.Errorf("start jobs: %w", err)
+	}
+	defer jobs.Close()
+	collation := service.NewCollationService(settings, st.SortKeys)
+	backfilled, err := collation.Load(context.Background())
+	if err != nil {
+		return fmt.Errorf("load name collation: %w", err)
+	}
+	if backfilled > 0 {
+		logger.Info("backfilled name sort keys", "locale", collation.Locale(), "rows", backfilled)
+	}
+	settings.OnChange(service.SettingApprovalExemptRoot, func(ctx context.Context, _ string) {
+		if err := approvals.Load(ctx); err != nil {
+			logger.Warn("reload credential approval settings", "err", err)
+		}
+	})
+	settings.OnChange(service.SettingCredentialReadQuota, func(ctx context.Context, _ string) {
+		if err := credReads.Load(ctx); err != nil {
+			logger.Warn("reload credential read quota", "err", err)
+		}
+	})
+	settings.OnChange(service.SettingCollationLocale, func(ctx context.Context, locale string) {
+		if locale == collation.Locale() {
+			return
+		}
+		if _, err := collation.Load(ctx); err != nil {
+			logger.Warn("reload name collation", "err", err)
+		}
+	})
+
+	recordingOpts := []service.RecordingServiceOption{
+		service.WithTranscriptMaxBytes(cfg.Recordings.TranscriptMaxBytes), service.WithChapterGap(cfg.Recordings.ChapterGap()),
+	}
+	if cfg.Recordings.ArchiveEnabled() {
+		archiveBlobs, err := recording.NewLocalBlobStore(cfg.Recordings.ArchiveDir)
+		if err != nil {
+			return fmt.Errorf("recording archive storage: %w", err)
+		}
+		recordingOpts = append(recordingOpts, service.WithArchive(archiveBlobs, time.Duration(cfg.Recordings.ArchiveAfterDays)*24*time.Hour))
+	}
+	recordings := service.NewRecordingService(st.Recordings, recBlobs, recordingOpts...)
+	var policyOpts []service.PasswordPolicyOption
+	if path := cfg.Auth.BreachedPasswordsFile; path != "" {
+		breached, err := auth.LoadBloomFilter(path)
+		if err != nil {
+			return fmt.Errorf("load breached passwords: %w", err)
+		}
+		policyOpts = append(policyOpts, service.WithBreachedPasswords(breached))
+	}
+	passwordPolicy := service.NewPasswordPolicyService(settings, st.Users, policyOpts...)
+	if err := passwordPolicy.Load(context.Background()); err != nil {
+		return fmt.Errorf("load password policy: %w", err)
+	}
+	settings.OnChange(service.SettingPasswordPolicy, func(ctx context.Context, _ string) {
+		if err := passwordPolicy.Load(ctx); err != nil {
+			logger.Warn("reload password policy", "err", err)
+		}
+	})
+	users := service.NewUserService(st.Users, service.WithUserGrants(st.Grants, st.CredentialGrants), service.WithUserCredentials(st.Credentials),
+		service.WithUserConnections(connections), service.WithPasswordPolicy(passwordPolicy),
+		service.WithUserAnonymizer(st.Anonymize))
 	twoFactor := service.NewTwoFactorService(st.Users, vault, app.DisplayName)
 
 	mailer := email.New(email.SMTP{
@@ -225,7 +478,36 @@ func run(logger *slog.Logger, cfg *config.Config, dev bool) error {
 		Password: cfg.Email.Password,
 		UseTLS:   cfg.Email.UseTLS,
 	})
-	invitations := service.NewInvitationService(st.Invitations, users, mailer)
+	invitations := service.NewInvitationService(st.Invitations, users, mailer, service.WithInvitationSettings(settings))
+	credReads.OnAlert(func(a service.CredentialReadAlert) {
+		result := models.AuditAllowed
+		if !a.BlockedUntil.IsZero() {
+			result = models.AuditDenied
+		}
+		auditWriter.Record(context.Background(), audit.Event{
+			User: models.User{ID: a.UserID}, Event: "credential.read_alert", RouteID: "credential.read_alert",
+			Risk: string(plugin.RiskPrivileged), Result: result,
+			Params: map[string]string{
+				"scope": a.Scope, "credentialId": a.CredentialID,
+				"count": strconv.Itoa(a.Count), "limit": strconv.Itoa(a.Limit),
+			},
+		})
+		metrics.IncCredentialReadAlert(a.Scope)
+		webhooks.CredentialReadAlert(a)
+		go mailRootAdmins(logger, st.Users, mailer, "ShellCN credential read alert", credentialReadAlertText(a))
+	})
+	approvals.OnEvent(func(e service.CredentialApprovalEvent) {
+		if e.Event == service.ApprovalEventApprove {
+			return // the approve route audits with the request context
+		}
+		auditWriter.Record(context.Background(), audit.Event{
+			User: models.User{ID: e.ActorID}, Event: e.Event, RouteID: e.Event,
+			Risk: string(plugin.RiskPrivileged), Result: models.AuditAllowed,
+			Params: map[string]string{
+				"approvalId": e.Approval.ID, "credentialId": e.Approval.CredentialID, "connectionId": e.Approval.ConnectionID,
+			},
+		})
+	})
 
 	modelRegistry := modelreg.New(modelreg.WithLogger(logger))
 	aiConfig := aiconfig.New(st.AIProviders, vault, cfg.AI).WithModels(modelRegistry)
@@ -236,80 +518,92 @@ func run(logger *slog.Logger, cfg *config.Config, dev bool) error {
 		_, err := st.Users.Count(ctx)
 		return err
 	})
+	health.Register("database_breaker", resilience.Check)
+	health.RegisterReport("vault", vaultReport)
+	health.SetMode(func() string {
+		if maintenance.ReadOnly() {
+			return "read_only"
+		}
+		return "read_write"
+	})
+
+	// Background jobs stop when run returns. Those that write skip their ticks
+	// while in read-only mode and pick up again on the next tick once it is
+	// turned off.
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	readOnly := maintenance.ReadOnly
 
-	stopLeaseCleanup := startLiveStateLeaseCleanup(logger, st.LiveStateLeases, liveStateLeaseCleanupEvery(leaseTTL))
-	defer stopLeaseCleanup()
+	runNowAndEvery(background, liveStateLeaseCleanupEvery(leaseTTL), readOnly, purgeExpired(logger, "live-state lease cleanup", st.LiveStateLeases.DeleteExpired))
 
 	// Background maintenance: always reap abandoned chunked (browser-capture)
 	// recordings so partial blobs from vanished sessions don't leak; additionally
 	// sweep expired recordings when an admin has opted into retention.
-	stopCleanup := make(chan struct{})
-	defer close(stopCleanup)
+	// The storage gauges are refreshed even in read-only mode so capacity
+	// alerts keep working.
+	refreshRecordingStorage := func(ctx context.Context) {
+		u, err := recordings.StorageReport(ctx, time.Now(), 1)
+		if err != nil {
+			logger.Warn("recording storage report failed", "err", err)
+			return
+		}
+		metrics.SetRecordingStorage(u.Total.Bytes, u.Total.Count, u.Total.ReclaimableBytes)
+	}
+	maintainRecordings := func(ctx context.Context) {
+		// 24h is a safe backstop: it frees genuinely abandoned captures
+		// without cutting off legitimately long-running sessions.
+		recEngine.ReapStaleChunked(ctx, 24*time.Hour)
+		if cfg.Recordings.RetentionEnabled() {
+			n, err := recordings.Cleanup(ctx, time.Now())
+			logSweep(logger, "recording cleanup", n, err)
+		}
+		n, err := recordings.Archive(ctx, time.Now())
+		logSweep(logger, "recording archive", n, err)
+		if cfg.Recordings.Transcripts {
+			n, err := recordings.ExtractTranscripts(ctx)
+			logSweep(logger, "recording transcript extraction", n, err)
+		}
+		n, err = recordings.BuildAllChapters(ctx)
+		logSweep(logger, "recording chaptering", n, err)
+	}
+	runNowAndEvery(background, cfg.Recordings.CleanupEvery(), nil, refreshRecordingStorage)
+	runEvery(background, cfg.Recordings.CleanupEvery(), readOnly, maintainRecordings)
+
 	go func() {
-		t := time.NewTicker(cfg.Recordings.CleanupEvery())
-		defer t.Stop()
-		for {
-			select {
-			case <-stopCleanup:
-				return
-			case <-t.C:
-				// 24h is a safe backstop: it frees genuinely abandoned captures
-				// without cutting off legitimately long-running sessions.
-				recEngine.ReapStaleChunked(context.Background(), 24*time.Hour)
-				if cfg.Recordings.RetentionEnabled() {
-					if n, err := recordings.Cleanup(context.Background(), time.Now()); err != nil {
-						logger.Warn("recording cleanup failed", "err", err)
-					} else if n > 0 {
-						logger.Info("recording cleanup removed expired recordings", "count", n)
-					}
-				}
-			}
+		if maintenance.ReadOnly() {
+			return
+		}
+		if n, err := audit.BackfillResources(context.Background(), st.Audit); err != nil {
+			logger.Warn("audit resource backfill failed", "err", err)
+		} else if n > 0 {
+			logger.Info("backfilled audit resources", "rows", n)
 		}
 	}()
 
 	if cfg.Audit.Enabled && cfg.Audit.RetentionEnabled() {
-		stopAuditCleanup := make(chan struct{})
-		defer close(stopAuditCleanup)
-
-		go func() {
-			t := time.NewTicker(cfg.Audit.CleanupEvery())
-			defer t.Stop()
-
-			for {
-				select {
-				case <-stopAuditCleanup:
-					return
-
-				case <-t.C:
-					before := time.Now().AddDate(0, 0, -cfg.Audit.RetentionDays)
-
-					if n, err := st.Audit.DeleteBefore(context.Background(), before); err != nil {
-						logger.Warn("audit cleanup failed", "err", err)
-					} else if n > 0 {
-						logger.Info("audit cleanup removed expired entries", "count", n)
-					}
-				}
+		runEvery(background, cfg.Audit.CleanupEvery(), readOnly, purgeOlderThan(logger, "audit cleanup", cfg.Audit.RetentionDays, st.Audit.DeleteBefore))
+	}
+	if cfg.Audit.Enabled && auditSigner != nil && cfg.Audit.AnchorEvery() > 0 {
+		runEvery(background, cfg.Audit.AnchorEvery(), readOnly, func(ctx context.Context) {
+			if _, err := auditChain.Anchor(ctx, auditSigner); err != nil {
+				logger.Warn("audit anchor failed", "err", err)
 			}
-		}()
+		})
+	}
+	if cfg.Audit.CredentialAccessRetentionEnabled() {
+		runEvery(background, cfg.Audit.CleanupEvery(), readOnly, purgeOlderThan(logger, "credential access log cleanup", cfg.Audit.CredentialAccessRetentionDays, st.CredentialAccess.DeleteBefore))
 	}
 
 	// Reflect live session/channel counts into the gauges.
-	stopMetrics := make(chan struct{})
-	defer close(stopMetrics)
-	go func() {
-		t := time.NewTicker(10 * time.Second)
-		defer t.Stop()
-		for {
-			select {
-			case <-stopMetrics:
-				return
-			case <-t.C:
-				s := sessions.Stats()
-				metrics.SetSessions(s.Sessions)
-				metrics.SetChannels(s.Channels)
-			}
-		}
-	}()
+	runEvery(background, 10*time.Second, nil, func(ctx context.Context) {
+		s := sessions.Stats()
+		metrics.SetSessions(s.Sessions)
+		metrics.SetChannels(s.Channels)
+		metrics.SetStuckCloses(s.StuckCloses)
+		active := sessions.Active()
+		sessionMetrics.Sample(active)
+		history.Flush(ctx, active)
+	})
 
 	var staticFS fs.FS
 	if !dev {
@@ -321,6 +615,18 @@ func run(logger *slog.Logger, cfg *config.Config, dev bool) error {
 
 	// HTTP server.
 	authKey := cfg.Auth.JWTSigningKey(masterKey)
+	var timeouts service.Timeouts
+	timeouts.Read, timeouts.Write, timeouts.Report = cfg.Database.OperationTimeouts()
+	trustedProxies, err := cfg.Server.TrustedProxyPrefixes()
+	if err != nil {
+		return err
+	}
+	jobTickets := auth.NewTicketStore(auth.TicketStoreOptions{
+		TTL:        service.DefaultJobLinkTTL,
+		SigningKey: authKey,
+		Leases:     leases,
+		Instance:   instance,
+	})
 	srv := server.New(server.Deps{
 		Plugins:    reg,
 		Store:      st,
@@ -338,34 +644,101 @@ func run(logger *slog.Logger, cfg *config.Config, dev bool) error {
 			Leases:     leases,
 			Instance:   instance,
 		}),
-		Policy:            pol,
-		Connector:         connector,
-		Connections:       connections,
-		Credentials:       creds,
-		Enrollments:       enrollments,
-		Protocols:         protocols,
-		ExtPlugins:        extPlugins,
-		Market:            market,
-		PluginsDir:        cfg.Plugins.Dir,
-		Users:             users,
-		TwoFactor:         twoFactor,
-		Invitations:       invitations,
-		Tunnels:           tunnels,
-		Leases:            leases,
-		Instance:          instance,
-		Recording:         recEngine,
-		Recordings:        recordings,
-		RecordingMaxChunk: cfg.Recordings.MaxChunkBytes,
-		AI:                aiConfig,
-		AIGlobal:          cfg.AI,
-		ModelRegistry:     modelRegistry,
-		Audit:             auditWriter,
-		Metrics:           metrics,
-		Health:            health,
-		Logger:            logger,
-		StaticFS:          staticFS,
-		Dev:               dev,
-		AccessLog:         cfg.Server.AccessLog,
+		Policy:                   pol,
+		Connector:                connector,
+		Connections:              connections,
+		Credentials:              creds,
+		Enrollments:              enrollments,
+		Protocols:                protocols,
+		Maintenance:              maintenance,
+		Hygiene:                  hygiene,
+		Activity:                 service.NewActivityService(st.Activity),
+		SessionCaps:              sessionCaps,
+		GrantExpiry:              grantExpiry,
+		Launches:                 launches,
+		DriverSettings:           driverSettings,
+		Usage:                    service.NewConnectionUsageService(st.ConnectionUsage, st.Users, st.ConnectionFolders, st.ConnectionPlacements),
+		ExtPlugins:               extPlugins,
+		Market:                   market,
+		PluginsDir:               cfg.Plugins.Dir,
+		Users:                    users,
+		PasswordPolicy:           passwordPolicy,
+		TwoFactor:                twoFactor,
+		Invitations:              invitations,
+		Webhooks:                 webhooks,
+		Jobs:                     jobs,
+		JobTickets:               jobTickets,
+		SessionShares:            shares,
+		Idempotency:              idempotency,
+		Presence:                 presence,
+		Observations:             service.NewSessionObservationService(st.SessionObservations, settings),
+		CredentialReads:          credReads,
+		Collation:                collation,
+		Settings:                 settings,
+		Approvals:                approvals,
+		Tunnels:                  tunnels,
+		Leases:                   leases,
+		Instance:                 instance,
+		Recording:                recEngine,
+		Recordings:               recordings,
+		RecordingMaxChunk:        cfg.Recordings.MaxChunkBytes,
+		ScrollbackSkipUnrecorded: cfg.LiveState.ScrollbackSkipUnrecorded,
+		AI:                       aiConfig,
+		AIGlobal:                 cfg.AI,
+		ModelRegistry:            modelRegistry,
+		Audit:                    auditWriter,
+		AuditTail:                auditTail,
+		AuditSigner:              auditSigner,
+		Metrics:                  metrics,
+		Health:                   health,
+		Logger:                   logger,
+		StaticFS:                 staticFS,
+		Dev:                      dev,
+		AccessLog:                cfg.Server.AccessLog,
+		Version:                  version,
+		Timeouts:                 &timeouts,
+		TrustedProxies:           trustedProxies,
+	})
+
+	if cfg.Connections.TrashPurgeEnabled() {
+		runEvery(background, cfg.Connections.CleanupEvery(), readOnly, purgeOlderThan(logger, "connection trash purge", cfg.Connections.TrashRetentionDays, srv.PurgeConnectionTrash))
+	}
+
+	// Tell grantees when a temporary connection share is about to lapse and
+	// when it has.
+	runNowAndEvery(background, time.Minute, nil, func(ctx context.Context) {
+		if err := grantExpiry.Notify(ctx); err != nil {
+			logger.Warn("share expiry notices failed", "err", err)
+		}
+	})
+
+	// Referential hygiene: deletions remove their grants, and this sweep
+	// catches what a failed cleanup or an older release left behind. It also
+	// drops idempotency keys past their TTL, finished jobs past retention, and
+	// the token hashes of lapsed invitations, and fails session history rows
+	// a crashed server left active.
+	runNowAndEvery(background, cfg.Connections.CleanupEvery(), readOnly, func(ctx context.Context) {
+		if rep, err := hygiene.Run(ctx, time.Now()); err != nil {
+			logger.Warn("orphaned grant sweep failed", "err", err)
+		} else if n := rep.Total(); n > 0 {
+			logger.Info("orphaned grant sweep removed rows", "count", n, "removed", rep.Removed)
+		}
+		purgeExpired(logger, "idempotency key cleanup", idempotency.Purge)(ctx)
+		n, err := jobs.Purge(ctx, time.Now())
+		logSweep(logger, "job cleanup", n, err)
+		n, err = invitations.ExpireLapsed(ctx)
+		logSweep(logger, "invitation expiry sweep", n, err)
+		if n, err := history.Reconcile(ctx); err != nil {
+			logger.Warn("session reconciliation failed", "err", err)
+		} else if n > 0 {
+			logger.Info("session reconciliation failed orphaned sessions", "count", n)
+			metrics.AddSessionsReconciled(n)
+			auditWriter.Record(ctx, audit.Event{
+				Event: "session.reconcile", RouteID: "session.reconcile",
+				Risk: string(plugin.RiskPrivileged), Result: models.AuditAllowed,
+				Params: map[string]string{"count": strconv.Itoa(n), "reason": service.SessionReasonServerRestart},
+			})
+		}
 	})
 
 	httpServer := &http.Server{
@@ -402,32 +775,75 @@ func liveStateLeaseCleanupEvery(leaseTTL time.Duration) time.Duration {
 	return leaseTTL
 }
 
-func startLiveStateLeaseCleanup(logger *slog.Logger, leases store.LiveStateLeaseStore, every time.Duration) func() {
-	stop := make(chan struct{})
-	cleanup := func() {
-		if n, err := leases.DeleteExpired(context.Background(), time.Now().UTC()); err != nil {
-			logger.Warn("live-state lease cleanup failed", "err", err)
-		} else if n > 0 {
-			logger.Info("live-state lease cleanup removed expired leases", "count", n)
+// bootstrapAdmin creates a default admin on first run and logs generated credentials.
+// mailRootAdmins emails the active root admins with an address, when email is
+// set up.
+func mailRootAdmins(logger *slog.Logger, users store.UserStore, mailer *email.Mailer, subject, body string) {
+	if !mailer.Enabled() {
+		return
+	}
+	list, err := users.List(context.Background())
+	if err != nil {
+		logger.Warn("list root admins to notify", "err", err)
+		return
+	}
+	for _, u := range list {
+		if !u.Protected || u.Disabled || u.Email == "" {
+			continue
+		}
+		if err := mailer.Send(u.Email, subject, body); err != nil {
+			logger.Warn("notify admin", "user", u.ID, "err", err)
 		}
 	}
-	cleanup()
-	go func() {
-		t := time.NewTicker(every)
-		defer t.Stop()
-		for {
-			select {
-			case <-stop:
-				return
-			case <-t.C:
-				cleanup()
-			}
+}
+
+func credentialReadAlertText(a service.CredentialReadAlert) string {
+	subject := "User " + a.UserID
+	if a.Scope == service.CredentialReadScopeCredential {
+		subject = "Credential " + a.CredentialID
+	}
+	text := fmt.Sprintf("%s had %d credential reads in %s, over the limit of %d.\n", subject, a.Count, a.Window, a.Limit)
+	if !a.BlockedUntil.IsZero() {
+		text += fmt.Sprintf("User %s is blocked from credential reads until %s.\n", a.UserID, a.BlockedUntil.UTC().Format(time.RFC3339))
+	}
+	return text
+}
+
+// vaultSelfTest checks the master key against the self-test value stored on
+// first boot, storing one when there is none, and returns the vault's health
+// report. A throwaway dev key is reported as missing and never stored.
+func vaultSelfTest(ctx context.Context, logger *slog.Logger, st *store.Store, vault *secrets.Vault, ephemeral bool, cfg config.SecretsConfig) (telemetry.Report, error) {
+	var stored string
+	setting, err := st.SystemSettings.Get(ctx, secrets.SelfTestSetting)
+	switch {
+	case err == nil:
+		stored = setting.Value
+	case !errors.Is(err, store.ErrNotFound):
+		return nil, fmt.Errorf("read vault self-test value: %w", err)
+	case !ephemeral:
+		if stored, err = secrets.NewSelfTest(ctx, vault); err != nil {
+			return nil, fmt.Errorf("create vault self-test value: %w", err)
 		}
-	}()
-	return func() { close(stop) }
+		if err := st.SystemSettings.Set(ctx, &models.SystemSetting{Key: secrets.SelfTestSetting, Value: stored}); err != nil {
+			return nil, fmt.Errorf("store vault self-test value: %w", err)
+		}
+	}
+	report := func(ctx context.Context) (string, error) {
+		if ephemeral {
+			return string(secrets.VaultKeyMissing), errors.New("no master key configured; secrets do not survive a restart")
+		}
+		status, err := secrets.CheckVault(ctx, vault, stored)
+		return string(status), err
+	}
+	if status, err := report(ctx); err != nil && !ephemeral {
+		if cfg.RequireSelfTest {
+			return nil, fmt.Errorf("vault self-test (%s): %w; set secrets.require_self_test to false to start anyway", status, err)
+		}
+		logger.Error("vault self-test failed; existing secrets will not decrypt", "status", status, "err", err)
+	}
+	return report, nil
 }
 
-// bootstrapAdmin creates a default admin on first run and logs generated credentials.
 func bootstrapAdmin(ctx context.Context, logger *slog.Logger, st *store.Store, cfg config.BootstrapConfig) error {
 	n, err := st.Users.Count(ctx)
 	if err != nil {
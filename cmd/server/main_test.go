@@ -2,13 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
 	"io"
 	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/charlesng35/shellcn/internal/auth"
 	"github.com/charlesng35/shellcn/internal/config"
 	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/secrets"
 	"github.com/charlesng35/shellcn/internal/store"
 )
 
@@ -44,3 +49,127 @@ func TestBootstrapAdminRejectsWeakConfiguredPassword(t *testing.T) {
 		t.Fatal("weak configured bootstrap password should fail")
 	}
 }
+
+func TestValidateConfigChangesNothing(t *testing.T) {
+	dir := t.TempDir()
+	key, _ := secrets.GenerateMasterKey()
+	cfg := &config.Config{}
+	cfg.Secrets.MasterKey = secrets.EncodeMasterKey(key)
+	cfg.Database.Driver = "sqlite"
+	cfg.Database.DSN = filepath.Join(dir, "shellcn.db")
+	cfg.Recordings.Dir = dir
+	cfg.Server.Addr = "127.0.0.1:0"
+
+	results := validateConfig(context.Background(), cfg)
+	if printValidation(io.Discard, results) {
+		t.Fatalf("unexpected failure: %+v", results)
+	}
+	if _, err := os.Stat(cfg.Database.DSN); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("validation created the database: %v", err)
+	}
+
+	st, err := store.Open(store.Config{Driver: store.DriverSQLite, DSN: cfg.Database.DSN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, _ := secrets.GenerateMasterKey()
+	other, _ := secrets.NewVault(otherKey)
+	value, _ := secrets.NewSelfTest(context.Background(), other)
+	_ = st.SystemSettings.Set(context.Background(), &models.SystemSetting{Key: secrets.SelfTestSetting, Value: value})
+	_ = st.Close()
+	results = validateConfig(context.Background(), cfg)
+	if !printValidation(io.Discard, results) {
+		t.Fatalf("a self-test value from another key should fail: %+v", results)
+	}
+
+	cfg.Secrets.MasterKey = ""
+	cfg.Recordings.Dir = filepath.Join(dir, "missing")
+	results = validateConfig(context.Background(), cfg)
+	if !printValidation(io.Discard, results) || results[0].Name != "master_key" || results[0].Status != checkFail {
+		t.Fatalf("missing master key should fail: %+v", results)
+	}
+}
+
+func TestPlanMigrationsChangesNothing(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Database.Driver = "sqlite"
+	cfg.Database.DSN = filepath.Join(t.TempDir(), "shellcn.db")
+	var out strings.Builder
+	if err := planMigrations(context.Background(), &out, cfg); err != nil || !strings.Contains(out.String(), "does not exist yet") {
+		t.Fatalf("missing database: %q err=%v", out.String(), err)
+	}
+	if _, err := os.Stat(cfg.Database.DSN); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("planning must not create the database: %v", err)
+	}
+
+	st, err := store.Open(store.Config{Driver: store.DriverSQLite, DSN: cfg.Database.DSN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = st.Close()
+	out.Reset()
+	if err := planMigrations(context.Background(), &out, cfg); err != nil || !strings.Contains(out.String(), "sqlite schema is up to date") {
+		t.Fatalf("migrated database: %q err=%v", out.String(), err)
+	}
+}
+
+func TestSeedWipeRequiresConfirmation(t *testing.T) {
+	t.Setenv(seedWipeEnv, "")
+	cfg := &config.Config{}
+	cfg.Database.DSN = filepath.Join(t.TempDir(), "seed.db")
+	err := runSeed(context.Background(), io.Discard, cfg, "", true)
+	if err == nil || !strings.Contains(err.Error(), seedWipeEnv) {
+		t.Fatalf("wipe without confirmation: %v", err)
+	}
+	if _, statErr := os.Stat(cfg.Database.DSN); !errors.Is(statErr, os.ErrNotExist) {
+		t.Errorf("wipe without confirmation opened the database")
+	}
+}
+
+func TestVaultSelfTestStoresAndChecksKey(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	logger := slog.New(slog.DiscardHandler)
+	vault := func() *secrets.Vault {
+		key, _ := secrets.GenerateMasterKey()
+		v, _ := secrets.NewVault(key)
+		return v
+	}
+	require := config.SecretsConfig{RequireSelfTest: true}
+
+	// A throwaway key is reported missing and leaves nothing behind.
+	report, err := vaultSelfTest(ctx, logger, st, vault(), true, require)
+	if err != nil {
+		t.Fatalf("ephemeral key: %v", err)
+	}
+	if status, err := report(ctx); status != string(secrets.VaultKeyMissing) || err == nil {
+		t.Fatalf("ephemeral key reported %s %v", status, err)
+	}
+	if _, err := st.SystemSettings.Get(ctx, secrets.SelfTestSetting); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("ephemeral key stored a self-test value: %v", err)
+	}
+
+	first := vault()
+	report, err = vaultSelfTest(ctx, logger, st, first, false, require)
+	if err != nil {
+		t.Fatalf("first boot: %v", err)
+	}
+	if status, err := report(ctx); status != string(secrets.VaultCryptoOK) || err != nil {
+		t.Fatalf("first boot reported %s %v", status, err)
+	}
+	if _, err := vaultSelfTest(ctx, logger, st, first, false, require); err != nil {
+		t.Fatalf("same key: %v", err)
+	}
+
+	other := vault()
+	if _, err := vaultSelfTest(ctx, logger, st, other, false, require); err == nil {
+		t.Fatal("a changed key should refuse to start")
+	}
+	report, err = vaultSelfTest(ctx, logger, st, other, false, config.SecretsConfig{})
+	if err != nil {
+		t.Fatalf("changed key with the check optional: %v", err)
+	}
+	if status, err := report(ctx); status != string(secrets.VaultKeyMismatch) || err == nil {
+		t.Fatalf("changed key reported %s %v", status, err)
+	}
+}
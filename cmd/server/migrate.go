@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/charlesng35/shellcn/internal/config"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+// planMigrations compares the models with the live schema and prints what
+// the next start would change. It never writes to the database.
+func planMigrations(ctx context.Context, w io.Writer, cfg *config.Config) error {
+	driver := store.Driver(cfg.Database.Driver)
+	if driver == store.DriverSQLite || driver == "" {
+		path := sqlitePath(cfg.Database.DSN)
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			_, _ = fmt.Fprintf(w, "sqlite database %s does not exist yet; every table is created on first start\n", path)
+			return nil
+		}
+	}
+	st, err := store.Probe(ctx, store.Config{Driver: driver, DSN: cfg.Database.DSN})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = st.Close() }()
+	rep, err := st.Schema.Plan(ctx)
+	if err != nil {
+		return err
+	}
+	printSchemaPlan(w, rep)
+	return nil
+}
+
+func printSchemaPlan(w io.Writer, rep store.SchemaReport) {
+	for _, c := range rep.Pending {
+		_, _ = fmt.Fprintf(w, "APPLY  %-14s %s\n", c.Kind, describeSchemaChange(c))
+	}
+	for _, c := range rep.Drift {
+		_, _ = fmt.Fprintf(w, "WARN   %-14s %s (not in the models; left as is)\n", c.Kind, describeSchemaChange(c))
+	}
+	if rep.UpToDate() {
+		_, _ = fmt.Fprintf(w, "%s schema is up to date; %d drift warnings\n", rep.Driver, len(rep.Drift))
+		return
+	}
+	_, _ = fmt.Fprintf(w, "%s schema: %d pending changes, %d drift warnings\n", rep.Driver, len(rep.Pending), len(rep.Drift))
+}
+
+func describeSchemaChange(c store.SchemaChange) string {
+	out := c.Table
+	switch {
+	case c.Column != "":
+		out += "." + c.Column
+	case c.Index != "":
+		out += " index " + c.Index
+	}
+	if c.Detail != "" {
+		out += ": " + c.Detail
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// runEvery calls fn on every tick of interval until ctx is done. Ticks that
+// land while paused reports true are skipped; a nil paused never skips.
+func runEvery(ctx context.Context, interval time.Duration, paused func() bool, fn func(context.Context)) {
+	go tickEvery(ctx, interval, paused, fn)
+}
+
+// runNowAndEvery is runEvery with a first call straight away.
+func runNowAndEvery(ctx context.Context, interval time.Duration, paused func() bool, fn func(context.Context)) {
+	go func() {
+		runJob(ctx, paused, fn)
+		tickEvery(ctx, interval, paused, fn)
+	}()
+}
+
+func tickEvery(ctx context.Context, interval time.Duration, paused func() bool, fn func(context.Context)) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			runJob(ctx, paused, fn)
+		}
+	}
+}
+
+func runJob(ctx context.Context, paused func() bool, fn func(context.Context)) {
+	if paused != nil && paused() {
+		return
+	}
+	fn(ctx)
+}
+
+// purgeOlderThan returns a job that removes what purge finds older than days.
+func purgeOlderThan[N int | int64](logger *slog.Logger, what string, days int, purge func(context.Context, time.Time) (N, error)) func(context.Context) {
+	return func(ctx context.Context) {
+		n, err := purge(ctx, time.Now().AddDate(0, 0, -days))
+		logSweep(logger, what, n, err)
+	}
+}
+
+// logSweep logs a failed cleanup step, or how many rows it handled.
+func logSweep[N int | int64](logger *slog.Logger, what string, n N, err error) {
+	if err != nil {
+		logger.Warn(what+" failed", "err", err)
+	} else if n > 0 {
+		logger.Info(what, "count", n)
+	}
+}
+
+// purgeExpired returns a job that removes what purge finds expired by now.
+func purgeExpired[N int | int64](logger *slog.Logger, what string, purge func(context.Context, time.Time) (N, error)) func(context.Context) {
+	return func(ctx context.Context) {
+		n, err := purge(ctx, time.Now().UTC())
+		logSweep(logger, what, n, err)
+	}
+}
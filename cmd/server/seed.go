@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/charlesng35/shellcn/internal/audit"
+	"github.com/charlesng35/shellcn/internal/config"
+	"github.com/charlesng35/shellcn/internal/pluginregistry"
+	"github.com/charlesng35/shellcn/internal/secrets"
+	"github.com/charlesng35/shellcn/internal/seed"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/plugins"
+)
+
+// seedWipeEnv must be "true" for -seed-wipe to delete anything. The flag
+// alone is too easy to leave in a script pointed at production.
+const seedWipeEnv = "SHELLCN_SEED_ALLOW_WIPE"
+
+// runSeed applies the fixture at path, after wiping earlier seeded rows when
+// wipe is set. Either may be empty/false, but not both.
+func runSeed(ctx context.Context, w io.Writer, cfg *config.Config, path string, wipe bool) error {
+	if wipe && os.Getenv(seedWipeEnv) != "true" {
+		return fmt.Errorf("refusing to wipe seeded data: set %s=true to confirm", seedWipeEnv)
+	}
+	var fixture seed.Fixture
+	if path != "" {
+		var err error
+		if fixture, err = seed.Load(path); err != nil {
+			return err
+		}
+	}
+	// Seeded secrets must decrypt on the next start, so there is no
+	// ephemeral-key fallback here.
+	masterKey, err := secrets.ResolveMasterKey(cfg.Secrets.MasterKey, cfg.Secrets.MasterKeyFile)
+	if err != nil {
+		return fmt.Errorf("load master key: %w", err)
+	}
+	vault, err := secrets.NewVault(masterKey)
+	if err != nil {
+		return err
+	}
+	st, err := store.Open(store.Config{Driver: store.Driver(cfg.Database.Driver), DSN: cfg.Database.DSN})
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+	defer func() { _ = st.Close() }()
+
+	s, err := newSeeder(ctx, cfg, st, vault)
+	if err != nil {
+		return err
+	}
+	if wipe {
+		rep, err := s.Wipe(ctx)
+		printSeedCounts(w, "deleted", rep.Deleted)
+		if err != nil {
+			return err
+		}
+	}
+	if path == "" {
+		return nil
+	}
+	rep, err := s.Apply(ctx, fixture)
+	printSeedCounts(w, "created", rep.Created)
+	printSeedCounts(w, "updated", rep.Updated)
+	return err
+}
+
+// newSeeder builds the services the seeder writes through, configured as
+// run configures them for the API.
+func newSeeder(ctx context.Context, cfg *config.Config, st *store.Store, vault *secrets.Vault) (*seed.Seeder, error) {
+	reg := pluginregistry.New()
+	plugins.Register(reg)
+
+	settings := service.NewSettingsService(st.SystemSettings)
+	settings.Register(service.BuiltinSettings()...)
+	passwordPolicy := service.NewPasswordPolicyService(settings, st.Users)
+	if err := passwordPolicy.Load(ctx); err != nil {
+		return nil, fmt.Errorf("load password policy: %w", err)
+	}
+
+	creds := service.NewCredentialService(st.Credentials, st.CredentialGrants, vault,
+		service.WithCredentialKindCatalog(reg), service.WithCredentialVersions(st.CredentialVersions),
+		service.WithCredentialUsage(st.CredentialUsage))
+	var sink audit.Sink = audit.NewWriter(st.Audit)
+	if !cfg.Audit.Enabled {
+		sink = audit.Noop{}
+	}
+	return &seed.Seeder{
+		Store: st,
+		Users: service.NewUserService(st.Users, service.WithUserGrants(st.Grants, st.CredentialGrants),
+			service.WithUserCredentials(st.Credentials), service.WithPasswordPolicy(passwordPolicy)),
+		Credentials: creds,
+		Connections: service.NewConnectionService(st.Connections, reg, creds, vault,
+			service.WithConnectionPlacements(st.ConnectionPlacements), service.WithConnectionNames(st.ConnectionNames)),
+		Audit: sink,
+	}, nil
+}
+
+func printSeedCounts(w io.Writer, verb string, counts map[string]int) {
+	for _, kind := range slices.Sorted(maps.Keys(counts)) {
+		_, _ = fmt.Fprintf(w, "%-8s %-18s %d\n", verb, kind, counts[kind])
+	}
+}
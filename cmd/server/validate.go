@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/app"
+	"github.com/charlesng35/shellcn/internal/auth"
+	"github.com/charlesng35/shellcn/internal/config"
+	"github.com/charlesng35/shellcn/internal/secrets"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+// validateTimeout bounds the whole -validate run; the database gets most of it.
+const (
+	validateTimeout = 10 * time.Second
+	databaseTimeout = 5 * time.Second
+)
+
+type checkStatus string
+
+const (
+	checkPass checkStatus = "PASS"
+	checkWarn checkStatus = "WARN"
+	checkFail checkStatus = "FAIL"
+)
+
+type checkResult struct {
+	Name   string
+	Status checkStatus
+	Detail string
+}
+
+// validateConfig checks that cfg can start a server without changing anything:
+// no key generation, no migrations, no bootstrap.
+func validateConfig(ctx context.Context, cfg *config.Config) []checkResult {
+	var out []checkResult
+	add := func(name string, status checkStatus, format string, args ...any) {
+		out = append(out, checkResult{Name: name, Status: status, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	var vault *secrets.Vault
+	masterKey, err := secrets.ResolveMasterKey(cfg.Secrets.MasterKey, cfg.Secrets.MasterKeyFile)
+	if err == nil {
+		vault, err = secrets.NewVault(masterKey)
+	}
+	if err != nil {
+		add("master_key", checkFail, "%v", err)
+	} else {
+		add("master_key", checkPass, "master key loaded")
+	}
+
+	if pw := cfg.Bootstrap.AdminPassword; pw != "" {
+		if err := service.ValidatePassword(pw); err != nil {
+			add("bootstrap_admin", checkFail, "%v", err)
+		} else {
+			add("bootstrap_admin", checkPass, "configured admin password is acceptable")
+		}
+	}
+
+	if path := cfg.Auth.BreachedPasswordsFile; path != "" {
+		if _, err := auth.LoadBloomFilter(path); err != nil {
+			add("breached_passwords", checkFail, "%v", err)
+		} else {
+			add("breached_passwords", checkPass, "breached-password filter %s loaded", path)
+		}
+	}
+
+	st := checkDatabase(ctx, cfg, add)
+	if st != nil {
+		defer func() { _ = st.Close() }()
+		checkVaultSelfTest(ctx, st, vault, add)
+	}
+	checkRecordingsDir(cfg.Recordings.Dir, add)
+	checkListenAddr(cfg.Server.Addr, add)
+	return out
+}
+
+func checkDatabase(ctx context.Context, cfg *config.Config, add func(string, checkStatus, string, ...any)) *store.Store {
+	driver := store.Driver(cfg.Database.Driver)
+	if driver == store.DriverSQLite || driver == "" {
+		// Opening a missing SQLite file would create it.
+		path := sqlitePath(cfg.Database.DSN)
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			add("database", checkWarn, "sqlite database %s does not exist yet; it is created on first start", path)
+			return nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, databaseTimeout)
+	defer cancel()
+	type probed struct {
+		st  *store.Store
+		err error
+	}
+	done := make(chan probed, 1)
+	go func() {
+		st, err := store.Probe(ctx, store.Config{Driver: driver, DSN: cfg.Database.DSN})
+		done <- probed{st, err}
+	}()
+	select {
+	case p := <-done:
+		if p.err != nil {
+			add("database", checkFail, "%v", p.err)
+			return nil
+		}
+		add("database", checkPass, "%s database reachable", displayDriver(driver))
+		return p.st
+	case <-ctx.Done():
+		// A driver that ignores the context keeps its goroutine until it gives up.
+		add("database", checkFail, "no answer from the %s database within %s", displayDriver(driver), databaseTimeout)
+		return nil
+	}
+}
+
+func checkVaultSelfTest(ctx context.Context, st *store.Store, vault *secrets.Vault, add func(string, checkStatus, string, ...any)) {
+	setting, err := st.SystemSettings.Get(ctx, secrets.SelfTestSetting)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		add("vault_self_test", checkWarn, "no self-test value stored; master key not checked against existing data")
+	case err != nil:
+		add("vault_self_test", checkWarn, "read self-test value: %v", err)
+	case vault == nil:
+		add("vault_self_test", checkFail, "no usable master key to decrypt the self-test value")
+	default:
+		if err := secrets.VerifySelfTest(ctx, vault, setting.Value); err != nil {
+			add("vault_self_test", checkFail, "master key does not decrypt the stored self-test value: %v", err)
+			return
+		}
+		add("vault_self_test", checkPass, "master key decrypts the stored self-test value")
+	}
+}
+
+func checkRecordingsDir(dir string, add func(string, checkStatus, string, ...any)) {
+	info, err := os.Stat(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		add("recordings", checkWarn, "recording directory %s does not exist yet; it is created on first start", dir)
+		return
+	}
+	if err != nil {
+		add("recordings", checkFail, "%v", err)
+		return
+	}
+	if !info.IsDir() {
+		add("recordings", checkFail, "recording path %s is not a directory", dir)
+		return
+	}
+	f, err := os.CreateTemp(dir, ".validate-*")
+	if err != nil {
+		add("recordings", checkFail, "recording directory %s is not writable: %v", dir, err)
+		return
+	}
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+	add("recordings", checkPass, "recording directory %s is writable", dir)
+}
+
+func checkListenAddr(addr string, add func(string, checkStatus, string, ...any)) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		add("listen", checkFail, "cannot listen on %s: %v", addr, err)
+		return
+	}
+	_ = ln.Close()
+	add("listen", checkPass, "%s is free", addr)
+}
+
+func sqlitePath(dsn string) string {
+	if dsn == "" {
+		dsn = app.DefaultDatabaseDSN
+	}
+	dsn = strings.TrimPrefix(dsn, "file:")
+	if i := strings.IndexByte(dsn, '?'); i >= 0 {
+		dsn = dsn[:i]
+	}
+	return filepath.Clean(dsn)
+}
+
+func displayDriver(d store.Driver) store.Driver {
+	if d == "" {
+		return store.DriverSQLite
+	}
+	return d
+}
+
+// printValidation writes one line per check and a summary, and reports
+// whether any check failed.
+func printValidation(w io.Writer, results []checkResult) bool {
+	failed, warned := 0, 0
+	for _, r := range results {
+		_, _ = fmt.Fprintf(w, "%-4s  %-16s %s\n", r.Status, r.Name, r.Detail)
+		switch r.Status {
+		case checkFail:
+			failed++
+		case checkWarn:
+			warned++
+		}
+	}
+	_, _ = fmt.Fprintf(w, "%d checks: %d passed, %d warnings, %d failed\n",
+		len(results), len(results)-failed-warned, warned, failed)
+	return failed > 0
+}
@@ -1,16 +1,19 @@
 // Package audit records an append-only log of every authorized (and denied)
 // operation. Params arrive already redacted; the writer never mutates audit
-// rows after insert.
+// rows after insert, and chains each row to the one before it by hash so
+// later edits can be found (see Verify).
 package audit
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/charlesng35/shellcn/internal/models"
 	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/internal/telemetry"
 )
 
 // Operation sources recorded on every audit event.
@@ -25,6 +28,10 @@ type Event struct {
 	User         models.User
 	Event        string // route AuditEvent
 	ConnectionID string
+	// ResourceType and ResourceID name what the operation acted on. When both
+	// are empty the writer derives them with ResourceOf.
+	ResourceType string
+	ResourceID   string
 	RouteID      string
 	Risk         string
 	Result       models.AuditResult
@@ -48,6 +55,8 @@ type ctxKey int
 const (
 	remoteAddrKey ctxKey = iota
 	sourceKey
+	clientKey
+	batchKey
 )
 
 // WithRemoteAddr stashes the request's client address on the context so every
@@ -62,6 +71,18 @@ func remoteAddrFrom(ctx context.Context) string {
 	return addr
 }
 
+// WithClient stashes the request's client description so sessions and
+// recordings started during the request can record where they came from.
+func WithClient(ctx context.Context, c models.ClientInfo) context.Context {
+	return context.WithValue(ctx, clientKey, c)
+}
+
+// ClientFrom returns the client stashed by WithClient, or the zero value.
+func ClientFrom(ctx context.Context) models.ClientInfo {
+	c, _ := ctx.Value(clientKey).(models.ClientInfo)
+	return c
+}
+
 type origin struct {
 	source string
 	turnID string
@@ -82,15 +103,39 @@ func sourceFrom(ctx context.Context) (string, string) {
 type Writer struct {
 	store store.AuditStore
 	now   func() time.Time
+	tail  *Tail
+	// chainMu serializes reading the chain head and inserting after it.
+	chainMu sync.Mutex
 }
 
-func NewWriter(s store.AuditStore) *Writer {
-	return &Writer{store: s, now: time.Now}
+func NewWriter(s store.AuditStore, opts ...WriterOption) *Writer {
+	w := &Writer{store: s, now: time.Now}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
 }
 
-// Record appends one audit entry. Append failures are intentionally swallowed
-// here (audit must never break the request path); the store logs its own errors.
+// Record appends one audit entry, or buffers it when ctx carries an open
+// Batch. Append failures are intentionally swallowed here (audit must never
+// break the request path); the store logs its own errors.
 func (w *Writer) Record(ctx context.Context, ev Event) {
+	entry := w.entry(ctx, ev)
+	if b := batchFrom(ctx); b != nil && b.add(w, entry) {
+		return
+	}
+	if ok, _ := w.append(ctx, []*models.AuditEntry{entry}, false, nil); ok {
+		w.publish(entry)
+	}
+}
+
+func (w *Writer) publish(entries ...*models.AuditEntry) {
+	if w.tail != nil {
+		w.tail.publish(entries...)
+	}
+}
+
+func (w *Writer) entry(ctx context.Context, ev Event) *models.AuditEntry {
 	addr := ev.RemoteAddr
 	if addr == "" {
 		addr = remoteAddrFrom(ctx)
@@ -99,6 +144,10 @@ func (w *Writer) Record(ctx context.Context, ev Event) {
 	if source == "" {
 		source, turnID = sourceFrom(ctx)
 	}
+	resType, resID := ev.ResourceType, ev.ResourceID
+	if resType == "" && resID == "" {
+		resType, resID = ResourceOf(ev.ConnectionID, ev.Params)
+	}
 	entry := &models.AuditEntry{
 		ID:           uuid.NewString(),
 		Time:         w.now(),
@@ -106,6 +155,8 @@ func (w *Writer) Record(ctx context.Context, ev Event) {
 		Username:     ev.User.Username,
 		Event:        ev.Event,
 		ConnectionID: ev.ConnectionID,
+		ResourceType: resType,
+		ResourceID:   resID,
 		RouteID:      ev.RouteID,
 		Risk:         ev.Risk,
 		Result:       ev.Result,
@@ -113,11 +164,12 @@ func (w *Writer) Record(ctx context.Context, ev Event) {
 		RemoteAddr:   addr,
 		Source:       source,
 		TurnID:       turnID,
+		RequestID:    telemetry.RequestID(ctx),
 	}
 	if ev.Err != nil {
 		entry.Error = ev.Err.Error()
 	}
-	_ = w.store.Append(ctx, entry)
+	return entry
 }
 
 // Noop discards events — used by the route wrapper until the real writer is wired.
@@ -3,10 +3,14 @@ package audit_test
 import (
 	"context"
 	"errors"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/charlesng35/shellcn/internal/audit"
 	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/secrets"
 	"github.com/charlesng35/shellcn/internal/store"
 )
 
@@ -59,3 +63,307 @@ func TestNoopSink(_ *testing.T) {
 	// Must not panic and must not require a store.
 	audit.Noop{}.Record(context.Background(), audit.Event{Event: "x"})
 }
+
+func TestWriterDerivesResource(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	w := audit.NewWriter(st.Audit)
+	w.Record(ctx, audit.Event{Event: "conn.open", ConnectionID: "c1", Result: models.AuditAllowed})
+	w.Record(ctx, audit.Event{Event: "recording.delete", ConnectionID: "c1", Result: models.AuditAllowed,
+		Params: map[string]string{"recording": "r1"}})
+	w.Record(ctx, audit.Event{Event: "folder.update", Result: models.AuditAllowed,
+		Params: map[string]string{"folderId": "f1"}})
+	w.Record(ctx, audit.Event{Event: "custom", ConnectionID: "c1", ResourceType: "job", ResourceID: "j1", Result: models.AuditAllowed})
+
+	for _, c := range []struct{ kind, id, event string }{
+		{audit.ResourceConnection, "c1", "conn.open"},
+		{audit.ResourceRecording, "r1", "recording.delete"},
+		{audit.ResourceFolder, "f1", "folder.update"},
+		{audit.ResourceJob, "j1", "custom"},
+	} {
+		rows, _ := st.Audit.List(ctx, store.AuditFilter{ResourceType: c.kind, ResourceID: c.id})
+		if len(rows) != 1 || rows[0].Event != c.event {
+			t.Errorf("%s/%s: %+v", c.kind, c.id, rows)
+		}
+	}
+}
+
+func TestResourceOfUnknownParams(t *testing.T) {
+	if kind, id := audit.ResourceOf("", map[string]string{"vmid": "101"}); kind != "" || id != "" {
+		t.Errorf("got %q %q, want empty", kind, id)
+	}
+}
+
+// countingAudit counts the store calls a writer makes.
+type countingAudit struct {
+	store.AuditStore
+	appends, batches int
+}
+
+func (c *countingAudit) Append(ctx context.Context, e *models.AuditEntry) error {
+	c.appends++
+	return c.AuditStore.Append(ctx, e)
+}
+
+func (c *countingAudit) AppendBatch(ctx context.Context, entries []*models.AuditEntry) error {
+	c.batches++
+	return c.AuditStore.AppendBatch(ctx, entries)
+}
+
+func TestBatchWritesOnCommit(t *testing.T) {
+	st := store.NewMemory()
+	rows := &countingAudit{AuditStore: st.Audit}
+	w := audit.NewWriter(rows)
+
+	ctx, batch := audit.BeginBatch(context.Background())
+	for range 1200 {
+		w.Record(ctx, audit.Event{User: models.User{ID: "u1"}, Event: "user.disable", Result: models.AuditAllowed})
+	}
+	if n, _ := st.Audit.Count(ctx, store.AuditFilter{}); n != 0 || batch.Len() != 1200 {
+		t.Fatalf("before commit: %d stored, %d buffered", n, batch.Len())
+	}
+	if err := batch.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := st.Audit.Count(ctx, store.AuditFilter{}); n != 1200 || rows.appends != 0 || rows.batches != 1 {
+		t.Fatalf("after commit: %d stored, %d appends, %d batches", n, rows.appends, rows.batches)
+	}
+
+	// After the batch ends, events under its context are written directly.
+	w.Record(ctx, audit.Event{Event: "after"})
+	if rows.appends != 1 {
+		t.Fatalf("appends after commit = %d, want 1", rows.appends)
+	}
+}
+
+func TestBatchDiscardAndFinish(t *testing.T) {
+	st := store.NewMemory()
+	w := audit.NewWriter(st.Audit)
+
+	ctx, batch := audit.BeginBatch(context.Background())
+	w.Record(ctx, audit.Event{Event: "item"})
+	batch.Discard()
+	w.Record(ctx, audit.Event{Event: "bulk.failed", Result: models.AuditError})
+	batch.Finish(ctx)
+	got, _ := st.Audit.List(ctx, store.AuditFilter{})
+	if len(got) != 1 || got[0].Event != "bulk.failed" {
+		t.Fatalf("discarded batch wrote %+v", got)
+	}
+
+	// Finish writes what a panicking or timed-out operation buffered.
+	cctx, cancel := context.WithCancel(context.Background())
+	func() {
+		defer func() { _ = recover() }()
+		ctx, batch := audit.BeginBatch(cctx)
+		defer batch.Finish(ctx)
+		w.Record(ctx, audit.Event{Event: "item"})
+		cancel()
+		panic("boom")
+	}()
+	if n, _ := st.Audit.Count(context.Background(), store.AuditFilter{}); n != 2 {
+		t.Fatalf("entries after panic = %d, want 2", n)
+	}
+}
+
+func TestTailPublishesStoredEntries(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	tail := audit.NewTail()
+	w := audit.NewWriter(st.Audit, audit.WithTail(tail))
+	live, unsubscribe := tail.Subscribe()
+	defer unsubscribe()
+
+	w.Record(ctx, audit.Event{Event: "user.disable", Result: models.AuditAllowed})
+	bctx, batch := audit.BeginBatch(ctx)
+	w.Record(bctx, audit.Event{Event: "item.1"})
+	w.Record(bctx, audit.Event{Event: "item.2"})
+	if len(live) != 1 {
+		t.Fatalf("published %d entries before commit, want 1", len(live))
+	}
+	batch.Finish(bctx)
+	var got []string
+	for range 3 {
+		e := <-live
+		if e.ID == "" {
+			t.Fatalf("published entry without id: %+v", e)
+		}
+		got = append(got, e.Event)
+	}
+	if strings.Join(got, ",") != "user.disable,item.1,item.2" {
+		t.Fatalf("published %v", got)
+	}
+
+	// A subscriber that stops reading is dropped rather than blocking writes.
+	slow, _ := tail.Subscribe()
+	for range 300 {
+		w.Record(ctx, audit.Event{Event: "flood"})
+	}
+	n := 0
+	for range slow {
+		n++
+	}
+	if n == 0 || n >= 300 {
+		t.Fatalf("lagging subscriber received %d entries before being dropped", n)
+	}
+}
+
+func newSigner(t *testing.T, st *store.Store) *audit.Signer {
+	t.Helper()
+	key, _ := secrets.GenerateMasterKey()
+	vault, err := secrets.NewVault(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := audit.LoadSigner(context.Background(), st.SystemSettings, vault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := audit.LoadSigner(context.Background(), st.SystemSettings, vault)
+	if err != nil || again.PublicKey() != s.PublicKey() {
+		t.Fatalf("reloaded key differs: %v", err)
+	}
+	return s
+}
+
+// editedAudit rewrites entries as the chain is read back, standing in for
+// rows changed in the database.
+type editedAudit struct {
+	store.AuditStore
+	edit func(e *models.AuditEntry) bool
+}
+
+func (s editedAudit) ListChain(ctx context.Context, since time.Time, afterSeq int64, limit int) ([]models.AuditEntry, error) {
+	list, err := s.AuditStore.ListChain(ctx, since, afterSeq, limit)
+	kept := list[:0]
+	for _, e := range list {
+		if s.edit(&e) {
+			kept = append(kept, e)
+		}
+	}
+	return kept, err
+}
+
+func TestChainVerifies(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	signer := newSigner(t, st)
+	w := audit.NewWriter(st.Audit)
+
+	if ok, err := w.Anchor(ctx, signer); ok || err != nil {
+		t.Fatalf("anchored an empty chain: %v, %v", ok, err)
+	}
+	for _, ev := range []string{"login", "vm.start", "vm.stop"} {
+		w.Record(ctx, audit.Event{User: models.User{ID: "u1", Username: "alice"}, Event: ev, Result: models.AuditAllowed,
+			Params: map[string]string{"vmid": "101"}, RemoteAddr: "10.0.0.1"})
+	}
+	bctx, batch := audit.BeginBatch(ctx)
+	w.Record(bctx, audit.Event{Event: "item.1"})
+	w.Record(bctx, audit.Event{Event: "item.2"})
+	batch.Finish(bctx)
+	if ok, err := w.Anchor(ctx, signer); !ok || err != nil {
+		t.Fatalf("anchor: %v, %v", ok, err)
+	}
+	if ok, _ := w.Anchor(ctx, signer); ok {
+		t.Fatal("anchored a chain that already ends in an anchor")
+	}
+
+	report, err := audit.Verify(ctx, st.Audit, signer, time.Time{}, time.Time{})
+	if err != nil || report.Broken != nil || report.Checked != 6 || report.FirstSeq != 1 || report.LastSeq != 6 ||
+		report.Anchors != 1 || report.Signed != 1 {
+		t.Fatalf("report = %+v, %v", report, err)
+	}
+
+	// Anonymizing rewrites columns the hash leaves out.
+	if _, err := st.Anonymize.Scrub(ctx, store.AnonymizeAudit, "u1", "deleted-u1", 100); err != nil {
+		t.Fatal(err)
+	}
+	if report, _ := audit.Verify(ctx, st.Audit, signer, time.Time{}, time.Time{}); report.Broken != nil {
+		t.Fatalf("anonymized chain broken: %+v", report.Broken)
+	}
+
+	for name, c := range map[string]struct {
+		edit   func(e *models.AuditEntry) bool
+		seq    int64
+		reason string
+	}{
+		"edited params": {func(e *models.AuditEntry) bool {
+			if *e.Seq == 2 {
+				e.Params = map[string]string{"vmid": "102"}
+			}
+			return true
+		}, 2, "contents"},
+		"rehashed entry": {func(e *models.AuditEntry) bool {
+			if *e.Seq == 2 {
+				e.Event = "vm.reboot"
+				e.Hash = audit.ChainHash(e.PrevHash, e)
+			}
+			return true
+		}, 3, "previous hash"},
+		"deleted entry": {func(e *models.AuditEntry) bool { return *e.Seq != 3 }, 4, "missing"},
+	} {
+		report, err := audit.Verify(ctx, editedAudit{st.Audit, c.edit}, signer, time.Time{}, time.Time{})
+		if err != nil || report.Broken == nil || report.Broken.Seq != c.seq || !strings.Contains(report.Broken.Reason, c.reason) {
+			t.Errorf("%s: report = %+v, %v", name, report.Broken, err)
+		}
+	}
+
+	// Another server's key does not check the anchor.
+	other := newSigner(t, store.NewMemory())
+	if report, _ := audit.Verify(ctx, st.Audit, other, time.Time{}, time.Time{}); report.Broken == nil || report.Broken.Seq != 6 {
+		t.Fatalf("foreign key: %+v", report.Broken)
+	}
+}
+
+// racingAudit lets another instance write once between this writer reading
+// the chain head and inserting on it.
+type racingAudit struct {
+	store.AuditStore
+	other func()
+}
+
+func (s *racingAudit) ChainHead(ctx context.Context) (models.AuditEntry, error) {
+	head, err := s.AuditStore.ChainHead(ctx)
+	if f := s.other; f != nil {
+		s.other = nil
+		f()
+	}
+	return head, err
+}
+
+func TestChainRetriesAfterLosingTheHead(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	other := audit.NewWriter(st.Audit)
+	racing := &racingAudit{AuditStore: st.Audit}
+	racing.other = func() { other.Record(ctx, audit.Event{Event: "other"}) }
+	audit.NewWriter(racing).Record(ctx, audit.Event{Event: "mine"})
+
+	if n, _ := st.Audit.Count(ctx, store.AuditFilter{}); n != 2 {
+		t.Fatalf("stored %d entries, want 2", n)
+	}
+	report, err := audit.Verify(ctx, st.Audit, nil, time.Time{}, time.Time{})
+	if err != nil || report.Broken != nil || report.Checked != 2 {
+		t.Fatalf("report = %+v, %v", report, err)
+	}
+}
+
+func TestChainSurvivesSQLite(t *testing.T) {
+	ctx := context.Background()
+	st, err := store.Open(store.Config{Driver: store.DriverSQLite, DSN: filepath.Join(t.TempDir(), "audit.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = st.Close() }()
+	w := audit.NewWriter(st.Audit)
+	w.Record(ctx, audit.Event{User: models.User{ID: "u1"}, Event: "vm.start", Params: map[string]string{"vmid": "101"}})
+	w.Record(ctx, audit.Event{Event: "login", Params: map[string]string{}, Err: errors.New("denied")})
+	bctx, batch := audit.BeginBatch(ctx)
+	w.Record(bctx, audit.Event{Event: "item.1"})
+	w.Record(bctx, audit.Event{Event: "item.2"})
+	batch.Finish(bctx)
+
+	report, err := audit.Verify(ctx, st.Audit, nil, time.Time{}, time.Time{})
+	if err != nil || report.Broken != nil || report.Checked != 4 {
+		t.Fatalf("report = %+v, %v", report, err)
+	}
+}
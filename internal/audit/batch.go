@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/charlesng35/shellcn/internal/models"
+)
+
+// Batch buffers the entries a Writer records under one context, so a bulk
+// operation writes its per-item audit rows in a few multi-row inserts
+// instead of one insert per item. Entries keep the time they were recorded
+// and are chained in that order.
+//
+// The usual shape is:
+//
+//	ctx, batch := audit.BeginBatch(ctx)
+//	defer batch.Finish(ctx)
+//	... on failure: batch.Discard()
+//
+// Once committed or discarded, later events under ctx are appended directly
+// again, so the failure's own audit entry is still written.
+type Batch struct {
+	mu      sync.Mutex
+	w       *Writer
+	entries []*models.AuditEntry
+	done    bool
+}
+
+// BeginBatch returns a context whose audit events are buffered in the
+// returned Batch until it is committed.
+func BeginBatch(ctx context.Context) (context.Context, *Batch) {
+	b := &Batch{}
+	return context.WithValue(ctx, batchKey, b), b
+}
+
+func batchFrom(ctx context.Context) *Batch {
+	b, _ := ctx.Value(batchKey).(*Batch)
+	return b
+}
+
+// add buffers e unless the batch is finished or belongs to another writer.
+func (b *Batch) add(w *Writer, e *models.AuditEntry) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.done || b.w != nil && b.w != w {
+		return false
+	}
+	b.w = w
+	b.entries = append(b.entries, e)
+	return true
+}
+
+// Len is the number of entries waiting to be written.
+func (b *Batch) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.entries)
+}
+
+// Commit writes the buffered entries and ends the batch. It runs even when
+// ctx is already cancelled, so a request that timed out after doing the work
+// still records it.
+func (b *Batch) Commit(ctx context.Context) error {
+	b.mu.Lock()
+	w, entries := b.w, b.entries
+	b.entries, b.done = nil, true
+	b.mu.Unlock()
+	if len(entries) == 0 {
+		return nil
+	}
+	// Concurrent items may record out of order; the chain follows their
+	// times, ties in the order they were recorded.
+	slices.SortStableFunc(entries, func(a, b *models.AuditEntry) int { return a.Time.Compare(b.Time) })
+	if _, err := w.append(context.WithoutCancel(ctx), entries, true, nil); err != nil {
+		return err
+	}
+	w.publish(entries...)
+	return nil
+}
+
+// Discard drops the buffered entries and ends the batch, for an operation
+// that failed and whose per-item rows must not be written.
+func (b *Batch) Discard() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries, b.done = nil, true
+}
+
+// Finish commits whatever is still buffered, ignoring the error like Record
+// does. Deferred right after BeginBatch, it also writes the entries of an
+// operation cut short by a panic.
+func (b *Batch) Finish(ctx context.Context) {
+	_ = b.Commit(ctx)
+}
@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+// chainRetries bounds how often a write re-reads the chain head after
+// another instance inserted on the same one first.
+const chainRetries = 3
+
+// chainRecord is the canonical form of an entry that ChainHash covers. The
+// username, remote address and resource columns are left out: anonymization
+// and the resource backfill rewrite them after insert, and the user id and
+// params they derive from are covered.
+type chainRecord struct {
+	ID           string             `json:"id"`
+	Seq          int64              `json:"seq"`
+	Time         string             `json:"time"`
+	UserID       string             `json:"userId"`
+	Event        string             `json:"event"`
+	ConnectionID string             `json:"connectionId"`
+	RouteID      string             `json:"routeId"`
+	Risk         string             `json:"risk"`
+	Result       models.AuditResult `json:"result"`
+	Params       map[string]string  `json:"params,omitempty"`
+	Error        string             `json:"error"`
+	Source       string             `json:"source"`
+	TurnID       string             `json:"turnId"`
+	RequestID    string             `json:"requestId"`
+}
+
+// ChainHash returns the hex SHA-256 of prev followed by the canonical JSON of
+// e: its id, seq, time (RFC 3339 in UTC), user id, event, connection id,
+// route id, risk, result, params (omitted when empty), error, source, turn
+// id and request id, in that order.
+func ChainHash(prev string, e *models.AuditEntry) string {
+	var seq int64
+	if e.Seq != nil {
+		seq = *e.Seq
+	}
+	// Marshal cannot fail on strings and a string map.
+	b, _ := json.Marshal(chainRecord{
+		ID: e.ID, Seq: seq, Time: e.Time.UTC().Format(time.RFC3339Nano), UserID: e.UserID,
+		Event: e.Event, ConnectionID: e.ConnectionID, RouteID: e.RouteID, Risk: e.Risk,
+		Result: e.Result, Params: e.Params, Error: e.Error, Source: e.Source, TurnID: e.TurnID,
+		RequestID: e.RequestID,
+	})
+	h := sha256.New()
+	h.Write([]byte(prev))
+	h.Write(b)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// link numbers entries after head in slice order and chains their hashes.
+// Times are cut to milliseconds first, which every database keeps, so the
+// hash still matches once the entry is read back.
+func link(head models.AuditEntry, entries []*models.AuditEntry) {
+	prev, seq := head.Hash, int64(0)
+	if head.Seq != nil {
+		seq = *head.Seq
+	}
+	for _, e := range entries {
+		seq++
+		n := seq
+		e.Seq, e.PrevHash = &n, prev
+		e.Time = e.Time.Truncate(time.Millisecond)
+		e.Hash = ChainHash(prev, e)
+		prev = e.Hash
+	}
+}
+
+// append chains entries onto the current head and inserts them, one read of
+// the head per insert. The mutex keeps this instance's writes in order; the
+// unique Seq catches another instance taking the same head, and the entries
+// are then chained onto the new head and tried again. fill, when set, sees
+// the head first and may skip the write by returning false.
+func (w *Writer) append(ctx context.Context, entries []*models.AuditEntry, batch bool, fill func(head models.AuditEntry) bool) (bool, error) {
+	w.chainMu.Lock()
+	defer w.chainMu.Unlock()
+	var (
+		err   error
+		tried *int64
+	)
+	for i := range chainRetries {
+		head, herr := w.store.ChainHead(ctx)
+		if herr != nil && !errors.Is(herr, store.ErrNotFound) {
+			return false, herr
+		}
+		if i > 0 && sameSeq(head.Seq, tried) {
+			// The head did not move, so the insert failed for another reason.
+			return false, err
+		}
+		tried = head.Seq
+		if fill != nil && !fill(head) {
+			return false, nil
+		}
+		link(head, entries)
+		if batch {
+			err = w.store.AppendBatch(ctx, entries)
+		} else {
+			err = w.store.Append(ctx, entries[0])
+		}
+		if err == nil {
+			return true, nil
+		}
+	}
+	return false, err
+}
+
+func sameSeq(a, b *int64) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"context"
+	"errors"
+
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+// Resource types recorded on audit entries.
+const (
+	ResourceConnection = "connection"
+	ResourceCredential = "credential"
+	ResourceRecording  = "recording"
+	ResourceFolder     = "connection_folder"
+	ResourceJob        = "job"
+	ResourceUser       = "user"
+)
+
+// resourceParams maps the params call sites record to the resource they name,
+// most specific first. Params ranked below connectionId are only consulted
+// when the event names no connection.
+var resourceParams = []struct{ param, kind string }{
+	{"recording", ResourceRecording},
+	{"recordingId", ResourceRecording},
+	{"credentialId", ResourceCredential},
+	{"connectionId", ResourceConnection},
+	{"folderId", ResourceFolder},
+	{"job", ResourceJob},
+	{"userId", ResourceUser},
+	{"user", ResourceUser},
+}
+
+// ResourceOf derives what an operation acted on from the connection id and
+// params its call site recorded. Both results are empty when nothing matches.
+func ResourceOf(connectionID string, params map[string]string) (kind, id string) {
+	for _, p := range resourceParams {
+		if p.kind == ResourceConnection && connectionID != "" {
+			return ResourceConnection, connectionID
+		}
+		if v := params[p.param]; v != "" {
+			return p.kind, v
+		}
+	}
+	return "", ""
+}
+
+const backfillBatch = 500
+
+// BackfillResources fills the resource columns of entries written before they
+// existed and returns how many it updated.
+func BackfillResources(ctx context.Context, s store.AuditStore) (int, error) {
+	var n int
+	for {
+		page, err := s.ListUnresourced(ctx, backfillBatch)
+		if err != nil || len(page) == 0 {
+			return n, err
+		}
+		for _, e := range page {
+			kind, id := ResourceOf(e.ConnectionID, e.Params)
+			// Retention may prune an entry between the list and the update.
+			if err := s.SetResource(ctx, e.ID, kind, id); err != nil && !errors.Is(err, store.ErrNotFound) {
+				return n, err
+			}
+			n++
+		}
+	}
+}
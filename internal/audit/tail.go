@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"sync"
+
+	"github.com/charlesng35/shellcn/internal/models"
+)
+
+// tailBuffer is how many entries a subscriber may fall behind before it is
+// dropped.
+const tailBuffer = 256
+
+// Tail fans entries out to live subscribers once the Writer has stored them.
+// Publishing never waits on a subscriber: one whose buffer is full has its
+// channel closed instead, and is expected to resume from the store.
+type Tail struct {
+	mu   sync.Mutex
+	subs map[chan models.AuditEntry]struct{}
+}
+
+func NewTail() *Tail {
+	return &Tail{subs: map[chan models.AuditEntry]struct{}{}}
+}
+
+// Subscribe returns a channel of entries stored from now on and a func that
+// unsubscribes. The channel is closed on unsubscribe or when the subscriber
+// falls behind.
+func (t *Tail) Subscribe() (<-chan models.AuditEntry, func()) {
+	ch := make(chan models.AuditEntry, tailBuffer)
+	t.mu.Lock()
+	t.subs[ch] = struct{}{}
+	t.mu.Unlock()
+	return ch, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.dropLocked(ch)
+	}
+}
+
+func (t *Tail) publish(entries ...*models.AuditEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for ch := range t.subs {
+		for _, e := range entries {
+			select {
+			case ch <- *e:
+				continue
+			default:
+			}
+			t.dropLocked(ch)
+			break
+		}
+	}
+}
+
+func (t *Tail) dropLocked(ch chan models.AuditEntry) {
+	if _, ok := t.subs[ch]; ok {
+		delete(t.subs, ch)
+		close(ch)
+	}
+}
+
+// WriterOption configures a Writer.
+type WriterOption func(*Writer)
+
+// WithTail publishes every entry the Writer stores to t.
+func WithTail(t *Tail) WriterOption {
+	return func(w *Writer) { w.tail = t }
+}
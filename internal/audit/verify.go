@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+const verifyBatch = 1000
+
+// VerifyReport is the outcome of replaying the hash chain.
+type VerifyReport struct {
+	// Checked counts the entries replayed, FirstSeq and LastSeq bound them.
+	Checked  int64 `json:"checked"`
+	FirstSeq int64 `json:"firstSeq,omitempty"`
+	LastSeq  int64 `json:"lastSeq,omitempty"`
+	// Anchors counts anchor records met; Signed those whose signature was
+	// checked, which needs the server key.
+	Anchors int `json:"anchors"`
+	Signed  int `json:"signed"`
+	// Broken is the first link that does not hold, nil when all do.
+	Broken *BrokenLink `json:"broken,omitempty"`
+}
+
+// BrokenLink names the first entry whose chain check failed.
+type BrokenLink struct {
+	ID     string    `json:"id"`
+	Seq    int64     `json:"seq"`
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason"`
+}
+
+// Verify replays the chain from the first chained entry at or after since up
+// to the first one after until, stopping at the first broken link. Entries
+// are walked in Seq order, so a batch entry recorded before since but
+// chained among later ones is still checked. The entry before the first one
+// may have been removed by retention, so the first link is taken on trust.
+// A nil signer still checks that each anchor matches the entry before it.
+func Verify(ctx context.Context, st store.AuditStore, signer *Signer, since, until time.Time) (VerifyReport, error) {
+	var r VerifyReport
+	first, err := st.ListChain(ctx, since, 0, 1)
+	if err != nil || len(first) == 0 {
+		return r, err
+	}
+	var prev *models.AuditEntry
+	after := *first[0].Seq - 1
+	for {
+		page, err := st.ListChain(ctx, time.Time{}, after, verifyBatch)
+		if err != nil {
+			return r, err
+		}
+		for i := range page {
+			e := &page[i]
+			if !until.IsZero() && e.Time.After(until) {
+				return r, nil
+			}
+			if reason := checkLink(prev, e, signer, &r); reason != "" {
+				r.Broken = &BrokenLink{ID: e.ID, Seq: *e.Seq, Time: e.Time, Reason: reason}
+				return r, nil
+			}
+			if r.Checked == 0 {
+				r.FirstSeq = *e.Seq
+			}
+			r.Checked++
+			r.LastSeq = *e.Seq
+			prev = e
+		}
+		if len(page) < verifyBatch {
+			return r, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return r, err
+		}
+		after = r.LastSeq
+	}
+}
+
+// checkLink returns why e does not follow prev, or "" when it does.
+func checkLink(prev, e *models.AuditEntry, signer *Signer, r *VerifyReport) string {
+	seq := *e.Seq
+	switch {
+	case prev == nil && seq == 1 && e.PrevHash != "":
+		return "the first entry links to a previous hash"
+	case prev != nil && seq != *prev.Seq+1:
+		return fmt.Sprintf("entries %d to %d are missing", *prev.Seq+1, seq-1)
+	case prev != nil && e.PrevHash != prev.Hash:
+		return fmt.Sprintf("previous hash does not match entry %d", *prev.Seq)
+	case ChainHash(e.PrevHash, e) != e.Hash:
+		return "hash does not match the entry's contents"
+	}
+	if e.Event != AnchorEvent {
+		return ""
+	}
+	r.Anchors++
+	anchored, err := strconv.ParseInt(e.Params["seq"], 10, 64)
+	if err != nil || anchored != seq-1 || e.Params["hash"] != e.PrevHash {
+		return "anchor does not match the entry before it"
+	}
+	if signer != nil {
+		if !signer.Check(anchored, e.Params["hash"], e.Params["signature"]) {
+			return "anchor signature is not the server's"
+		}
+		r.Signed++
+	}
+	return ""
+}
@@ -1,10 +1,12 @@
 package auth_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -270,3 +272,22 @@ func TestSessionCookieAttributes(t *testing.T) {
 		t.Errorf("cookie attributes wrong: %+v", c)
 	}
 }
+
+func TestBloomFilterRoundTrip(t *testing.T) {
+	f := auth.NewBloomFilter(100, 0.01)
+	f.Add("hunter2")
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := auth.ReadBloomFilter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Contains("hunter2") || got.Contains("correct horse battery staple") {
+		t.Fatal("filter membership did not survive a round trip")
+	}
+	if _, err := auth.ReadBloomFilter(strings.NewReader("not a filter")); !errors.Is(err, auth.ErrInvalidBloomFilter) {
+		t.Fatalf("garbage: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// ErrInvalidBloomFilter is returned for a breached-password file that is not
+// in BloomFilter's format.
+var ErrInvalidBloomFilter = errors.New("auth: invalid bloom filter file")
+
+const (
+	bloomMagic = "SHCNBLM1"
+	// bloomMaxBits caps a loaded filter at 1 GiB of memory.
+	bloomMaxBits = uint64(1) << 33
+)
+
+// BloomFilter is a compact set of known-breached passwords. Contains can
+// report a false positive, never a false negative; a false positive only
+// asks the user for a different password.
+//
+// The file form is the 8-byte magic "SHCNBLM1", the hash count k (uint32)
+// and the bit count m (uint64), both big-endian, then ceil(m/64) big-endian
+// uint64 words of bits.
+type BloomFilter struct {
+	k    uint32
+	m    uint64
+	bits []uint64
+}
+
+// NewBloomFilter sizes an empty filter for n entries at false-positive rate p.
+func NewBloomFilter(n int, p float64) *BloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	if p <= 0 || p >= 1 {
+		p = 0.001
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k := uint32(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &BloomFilter{k: k, m: m, bits: make([]uint64, (m+63)/64)}
+}
+
+func (f *BloomFilter) Add(password string) {
+	h1, h2 := bloomHashes(password)
+	for i := uint64(0); i < uint64(f.k); i++ {
+		bit := (h1 + i*h2) % f.m
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (f *BloomFilter) Contains(password string) bool {
+	h1, h2 := bloomHashes(password)
+	for i := uint64(0); i < uint64(f.k); i++ {
+		bit := (h1 + i*h2) % f.m
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomHashes derives the two hashes of Kirsch-Mitzenmacher double hashing
+// from one SHA-256; h2 is odd so the probe sequence never stalls.
+func bloomHashes(password string) (uint64, uint64) {
+	sum := sha256.Sum256([]byte(password))
+	return binary.BigEndian.Uint64(sum[0:8]), binary.BigEndian.Uint64(sum[8:16]) | 1
+}
+
+func (f *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var hdr [20]byte
+	copy(hdr[:8], bloomMagic)
+	binary.BigEndian.PutUint32(hdr[8:12], f.k)
+	binary.BigEndian.PutUint64(hdr[12:20], f.m)
+	if _, err := bw.Write(hdr[:]); err != nil {
+		return 0, err
+	}
+	var word [8]byte
+	for _, v := range f.bits {
+		binary.BigEndian.PutUint64(word[:], v)
+		if _, err := bw.Write(word[:]); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(hdr) + 8*len(f.bits)), bw.Flush()
+}
+
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	br := bufio.NewReader(r)
+	var hdr [20]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil || string(hdr[:8]) != bloomMagic {
+		return nil, ErrInvalidBloomFilter
+	}
+	k, m := binary.BigEndian.Uint32(hdr[8:12]), binary.BigEndian.Uint64(hdr[12:20])
+	if k == 0 || k > 64 || m == 0 || m > bloomMaxBits {
+		return nil, ErrInvalidBloomFilter
+	}
+	f := &BloomFilter{k: k, m: m, bits: make([]uint64, (m+63)/64)}
+	var word [8]byte
+	for i := range f.bits {
+		if _, err := io.ReadFull(br, word[:]); err != nil {
+			return nil, ErrInvalidBloomFilter
+		}
+		f.bits[i] = binary.BigEndian.Uint64(word[:])
+	}
+	return f, nil
+}
+
+// LoadBloomFilter reads a breached-password filter file.
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	f, err := ReadBloomFilter(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
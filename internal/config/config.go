@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/netip"
 	"strings"
 	"time"
 
@@ -20,17 +21,18 @@ import (
 )
 
 type Config struct {
-	Server     ServerConfig     `mapstructure:"server"`
-	Auth       AuthConfig       `mapstructure:"auth"`
-	Bootstrap  BootstrapConfig  `mapstructure:"bootstrap"`
-	Database   DatabaseConfig   `mapstructure:"database"`
-	Secrets    SecretsConfig    `mapstructure:"secrets"`
-	Email      EmailConfig      `mapstructure:"email"`
-	Audit      AuditConfig      `mapstructure:"audit"`
-	LiveState  LiveStateConfig  `mapstructure:"live_state"`
-	Recordings RecordingsConfig `mapstructure:"recordings"`
-	Plugins    PluginsConfig    `mapstructure:"plugins"`
-	AI         AIConfig         `mapstructure:"ai"`
+	Server      ServerConfig      `mapstructure:"server"`
+	Auth        AuthConfig        `mapstructure:"auth"`
+	Bootstrap   BootstrapConfig   `mapstructure:"bootstrap"`
+	Database    DatabaseConfig    `mapstructure:"database"`
+	Secrets     SecretsConfig     `mapstructure:"secrets"`
+	Email       EmailConfig       `mapstructure:"email"`
+	Audit       AuditConfig       `mapstructure:"audit"`
+	Connections ConnectionsConfig `mapstructure:"connections"`
+	LiveState   LiveStateConfig   `mapstructure:"live_state"`
+	Recordings  RecordingsConfig  `mapstructure:"recordings"`
+	Plugins     PluginsConfig     `mapstructure:"plugins"`
+	AI          AIConfig          `mapstructure:"ai"`
 }
 
 type ServerConfig struct {
@@ -41,11 +43,40 @@ type ServerConfig struct {
 	LogFile string `mapstructure:"log_file"`
 	// AccessLog logs one line per API request. On by default.
 	AccessLog bool `mapstructure:"access_log"`
+	// TrustedProxies are the CIDRs (or single addresses) of reverse proxies
+	// whose X-Forwarded-For and X-Real-IP headers name the real client.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+}
+
+// TrustedProxyPrefixes parses TrustedProxies; a bare address is a single-host
+// prefix. Entries may hold several values separated by commas or spaces, as
+// the environment variable does.
+func (c ServerConfig) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	var values []string
+	for _, v := range c.TrustedProxies {
+		values = append(values, strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })...)
+	}
+	for _, v := range values {
+		if p, err := netip.ParsePrefix(v); err == nil {
+			out = append(out, p.Masked())
+			continue
+		}
+		a, err := netip.ParseAddr(v)
+		if err != nil {
+			return nil, fmt.Errorf("config: trusted proxy %q is not an address or CIDR", v)
+		}
+		out = append(out, netip.PrefixFrom(a.Unmap(), a.Unmap().BitLen()))
+	}
+	return out, nil
 }
 
 type AuthConfig struct {
 	SessionTTL string `mapstructure:"session_ttl"`
 	JWTSecret  string `mapstructure:"jwt_secret"`
+	// BreachedPasswordsFile is an optional bloom filter of breached passwords
+	// used by the password policy's checkBreached rule.
+	BreachedPasswordsFile string `mapstructure:"breached_passwords_file"`
 }
 
 type BootstrapConfig struct {
@@ -75,11 +106,56 @@ func (c AuthConfig) JWTSigningKey(masterKey []byte) []byte {
 type DatabaseConfig struct {
 	Driver string `mapstructure:"driver"` // sqlite | postgres | mysql
 	DSN    string `mapstructure:"dsn"`    // sqlite: file path; others: connection string
+	// QueryMetrics times every statement into a histogram and logs those slower
+	// than SlowQueryThreshold. Off by default.
+	QueryMetrics       bool   `mapstructure:"query_metrics"`
+	SlowQueryThreshold string `mapstructure:"slow_query_threshold"`
+	// BreakerThreshold consecutive transient failures stop database calls for
+	// BreakerCooldown before a probe is let through.
+	BreakerThreshold int    `mapstructure:"breaker_threshold"`
+	BreakerCooldown  string `mapstructure:"breaker_cooldown"`
+	// ReadTimeout, WriteTimeout and ReportTimeout bound the store calls of one
+	// API operation of that class; "0" turns the bound off.
+	ReadTimeout   string `mapstructure:"read_timeout"`
+	WriteTimeout  string `mapstructure:"write_timeout"`
+	ReportTimeout string `mapstructure:"report_timeout"`
+}
+
+// SlowQueryDuration parses SlowQueryThreshold, falling back to 200ms.
+func (c DatabaseConfig) SlowQueryDuration() time.Duration {
+	if d, err := time.ParseDuration(c.SlowQueryThreshold); err == nil && d > 0 {
+		return d
+	}
+	return 200 * time.Millisecond
+}
+
+// OperationTimeouts parses the per-class timeouts, falling back to 5s reads,
+// 10s writes and 30s reports.
+func (c DatabaseConfig) OperationTimeouts() (read, write, report time.Duration) {
+	parse := func(v string, fallback time.Duration) time.Duration {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
+		}
+		return fallback
+	}
+	return parse(c.ReadTimeout, 5*time.Second), parse(c.WriteTimeout, 10*time.Second), parse(c.ReportTimeout, 30*time.Second)
+}
+
+// BreakerCooldownDuration parses BreakerCooldown, falling back to 10s.
+func (c DatabaseConfig) BreakerCooldownDuration() time.Duration {
+	if d, err := time.ParseDuration(c.BreakerCooldown); err == nil && d > 0 {
+		return d
+	}
+	return 10 * time.Second
 }
 
 type SecretsConfig struct {
 	MasterKey     string `mapstructure:"master_key"`
 	MasterKeyFile string `mapstructure:"master_key_file"`
+	// RequireSelfTest refuses to start when the master key cannot decrypt the
+	// stored self-test value, so new secrets are not written under a key that
+	// differs from the one existing secrets use.
+	RequireSelfTest bool `mapstructure:"require_self_test"`
 }
 
 // EmailConfig is the outbound SMTP configuration used for account invitations.
@@ -101,11 +177,30 @@ type AuditConfig struct {
 	Enabled         bool   `mapstructure:"enabled"`
 	RetentionDays   int    `mapstructure:"retention_days"`   // 0 = disabled (keep forever)
 	CleanupInterval string `mapstructure:"cleanup_interval"` // how often to sweep expired audit rows
+	// CredentialAccessRetentionDays expires the per-credential access log
+	// independently of the audit trail. 0 keeps it forever.
+	CredentialAccessRetentionDays int `mapstructure:"credential_access_retention_days"`
+	// AnchorInterval is how often the head of the audit hash chain is signed
+	// with the server key. 0 disables anchoring.
+	AnchorInterval string `mapstructure:"anchor_interval"`
 }
 
 // RetentionEnabled reports whether audit expiry/cleanup is active.
 func (c AuditConfig) RetentionEnabled() bool { return c.RetentionDays > 0 }
 
+// CredentialAccessRetentionEnabled reports whether access-log cleanup is active.
+func (c AuditConfig) CredentialAccessRetentionEnabled() bool {
+	return c.CredentialAccessRetentionDays > 0
+}
+
+// AnchorEvery parses AnchorInterval; 0 means anchoring is off.
+func (c AuditConfig) AnchorEvery() time.Duration {
+	if d, err := time.ParseDuration(c.AnchorInterval); err == nil && d > 0 {
+		return d
+	}
+	return 0
+}
+
 // CleanupEvery parses CleanupInterval, falling back to a sane default.
 func (c AuditConfig) CleanupEvery() time.Duration {
 	if d, err := time.ParseDuration(c.CleanupInterval); err == nil && d > 0 {
@@ -114,9 +209,62 @@ func (c AuditConfig) CleanupEvery() time.Duration {
 	return time.Hour
 }
 
+// ConnectionsConfig controls the connection trash. Deleted connections stay
+// restorable for TrashRetentionDays, then a sweep purges them; 0 keeps trashed
+// connections until an owner restores them.
+type ConnectionsConfig struct {
+	TrashRetentionDays int    `mapstructure:"trash_retention_days"`
+	CleanupInterval    string `mapstructure:"cleanup_interval"` // how often to sweep expired trash
+	// ProtocolCacheTTL bounds how long a replica serves protocol availability
+	// from memory before rereading it, so another replica's change shows up.
+	ProtocolCacheTTL string `mapstructure:"protocol_cache_ttl"`
+}
+
+// TrashPurgeEnabled reports whether the trash purge job is active.
+func (c ConnectionsConfig) TrashPurgeEnabled() bool { return c.TrashRetentionDays > 0 }
+
+// CleanupEvery parses CleanupInterval, falling back to a sane default.
+func (c ConnectionsConfig) CleanupEvery() time.Duration {
+	if d, err := time.ParseDuration(c.CleanupInterval); err == nil && d > 0 {
+		return d
+	}
+	return time.Hour
+}
+
+// ProtocolCacheTTLDuration parses ProtocolCacheTTL, falling back to 30s.
+func (c ConnectionsConfig) ProtocolCacheTTLDuration() time.Duration {
+	if d, err := time.ParseDuration(c.ProtocolCacheTTL); err == nil && d > 0 {
+		return d
+	}
+	return 30 * time.Second
+}
+
 type LiveStateConfig struct {
 	LeaseTTL      string `mapstructure:"lease_ttl"`
 	RenewInterval string `mapstructure:"renew_interval"`
+	// ReconnectGrace is how long a session whose transport dropped is kept
+	// while a plugin that supports it reconnects.
+	ReconnectGrace string `mapstructure:"reconnect_grace"`
+	// WriteRequestTimeout is how long a shared-session participant's request
+	// for write access waits for the owner before it expires.
+	WriteRequestTimeout string `mapstructure:"write_request_timeout"`
+	// ScrollbackBytes caps the terminal output each live session keeps for
+	// scrollback search; 0 keeps none.
+	ScrollbackBytes int `mapstructure:"scrollback_bytes"`
+	// ScrollbackSkipUnrecorded keeps sessions whose recording policy is
+	// disabled out of scrollback search.
+	ScrollbackSkipUnrecorded bool `mapstructure:"scrollback_skip_unrecorded"`
+	// SessionCloseTimeout bounds how long closing an upstream session may
+	// block before the close is left running and reported as stuck.
+	SessionCloseTimeout string `mapstructure:"session_close_timeout"`
+	// SessionMaxLifetime closes upstream sessions open longer than it;
+	// empty means no limit.
+	SessionMaxLifetime string `mapstructure:"session_max_lifetime"`
+	// SessionLaunchTimeout fails a session still connecting in the
+	// background after it.
+	SessionLaunchTimeout string `mapstructure:"session_launch_timeout"`
+	// ClipboardMaxBytes caps clipboard text relayed to or from a session.
+	ClipboardMaxBytes int `mapstructure:"clipboard_max_bytes"`
 }
 
 func (c LiveStateConfig) LeaseTTLDuration() time.Duration {
@@ -126,6 +274,47 @@ func (c LiveStateConfig) LeaseTTLDuration() time.Duration {
 	return 15 * time.Second
 }
 
+// ReconnectGraceDuration parses ReconnectGrace, falling back to 60s.
+func (c LiveStateConfig) ReconnectGraceDuration() time.Duration {
+	if d, err := time.ParseDuration(c.ReconnectGrace); err == nil && d > 0 {
+		return d
+	}
+	return time.Minute
+}
+
+// SessionCloseTimeoutDuration parses SessionCloseTimeout, falling back to 10s.
+func (c LiveStateConfig) SessionCloseTimeoutDuration() time.Duration {
+	if d, err := time.ParseDuration(c.SessionCloseTimeout); err == nil && d > 0 {
+		return d
+	}
+	return 10 * time.Second
+}
+
+// SessionMaxLifetimeDuration parses SessionMaxLifetime; 0 means no limit.
+func (c LiveStateConfig) SessionMaxLifetimeDuration() time.Duration {
+	if d, err := time.ParseDuration(c.SessionMaxLifetime); err == nil && d > 0 {
+		return d
+	}
+	return 0
+}
+
+// SessionLaunchTimeoutDuration parses SessionLaunchTimeout, falling back to
+// 2m.
+func (c LiveStateConfig) SessionLaunchTimeoutDuration() time.Duration {
+	if d, err := time.ParseDuration(c.SessionLaunchTimeout); err == nil && d > 0 {
+		return d
+	}
+	return 2 * time.Minute
+}
+
+// WriteRequestTimeoutDuration parses WriteRequestTimeout, falling back to 2m.
+func (c LiveStateConfig) WriteRequestTimeoutDuration() time.Duration {
+	if d, err := time.ParseDuration(c.WriteRequestTimeout); err == nil && d > 0 {
+		return d
+	}
+	return 2 * time.Minute
+}
+
 func (c LiveStateConfig) RenewIntervalDuration() time.Duration {
 	ttl := c.LeaseTTLDuration()
 	if d, err := time.ParseDuration(c.RenewInterval); err == nil && d > 0 && d < ttl {
@@ -143,11 +332,26 @@ type RecordingsConfig struct {
 	RetentionDays   int    `mapstructure:"retention_days"`   // 0 = disabled (keep forever)
 	CleanupInterval string `mapstructure:"cleanup_interval"` // how often to sweep expired recordings
 	MaxChunkBytes   int64  `mapstructure:"max_chunk_bytes"`  // per-chunk cap for desktop uploads
+	RedactInput     bool   `mapstructure:"redact_input"`     // drop keystrokes from terminal recordings
+	Transcripts     bool   `mapstructure:"transcripts"`      // extract searchable text from terminal recordings
+	// TranscriptMaxBytes skips transcript extraction for larger recordings.
+	TranscriptMaxBytes int64 `mapstructure:"transcript_max_bytes"`
+	// ChapterIdleGap is the pause that ends one chapter of terminal activity
+	// and starts the next.
+	ChapterIdleGap string `mapstructure:"chapter_idle_gap"`
+	// ArchiveDir is a second blob root, typically on cheaper storage, that
+	// finalized recordings move to after ArchiveAfterDays. Empty or a
+	// non-positive age disables archiving.
+	ArchiveDir       string `mapstructure:"archive_dir"`
+	ArchiveAfterDays int    `mapstructure:"archive_after_days"`
 }
 
 // RetentionEnabled reports whether expiry/cleanup is active.
 func (c RecordingsConfig) RetentionEnabled() bool { return c.RetentionDays > 0 }
 
+// ArchiveEnabled reports whether old recordings move to ArchiveDir.
+func (c RecordingsConfig) ArchiveEnabled() bool { return c.ArchiveDir != "" && c.ArchiveAfterDays > 0 }
+
 // CleanupEvery parses CleanupInterval, falling back to a sane default.
 func (c RecordingsConfig) CleanupEvery() time.Duration {
 	if d, err := time.ParseDuration(c.CleanupInterval); err == nil && d > 0 {
@@ -156,6 +360,14 @@ func (c RecordingsConfig) CleanupEvery() time.Duration {
 	return time.Hour
 }
 
+// ChapterGap parses ChapterIdleGap, falling back to a minute.
+func (c RecordingsConfig) ChapterGap() time.Duration {
+	if d, err := time.ParseDuration(c.ChapterIdleGap); err == nil && d > 0 {
+		return d
+	}
+	return time.Minute
+}
+
 // PluginsConfig points at the directory scanned for out-of-tree plugin binaries.
 // Empty disables external-plugin loading; a missing directory is not an error.
 type PluginsConfig struct {
@@ -218,22 +430,50 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("server.access_log", false)
 	v.SetDefault("auth.session_ttl", "24h")
 	v.SetDefault("auth.jwt_secret", "")
+	v.SetDefault("auth.breached_passwords_file", "")
 	v.SetDefault("bootstrap.admin_username", "admin")
 	v.SetDefault("bootstrap.admin_password", "")
 	v.SetDefault("database.driver", "sqlite")
 	v.SetDefault("database.dsn", app.DefaultDatabaseDSN)
+	v.SetDefault("database.query_metrics", false)
+	v.SetDefault("database.slow_query_threshold", "200ms")
+	v.SetDefault("database.breaker_threshold", 5)
+	v.SetDefault("database.breaker_cooldown", "10s")
+	v.SetDefault("database.read_timeout", "5s")
+	v.SetDefault("database.write_timeout", "10s")
+	v.SetDefault("database.report_timeout", "30s")
+	v.SetDefault("secrets.require_self_test", true)
 	v.SetDefault("email.enabled", false)
 	v.SetDefault("email.port", 587)
 	v.SetDefault("email.use_tls", false)
 	v.SetDefault("audit.enabled", true)
 	v.SetDefault("audit.retention_days", 0) // disabled: keep audit entries forever
 	v.SetDefault("audit.cleanup_interval", "1h")
+	v.SetDefault("audit.credential_access_retention_days", 0) // disabled: keep access logs forever
+	v.SetDefault("audit.anchor_interval", "1h")
+	v.SetDefault("connections.trash_retention_days", 30)
+	v.SetDefault("connections.cleanup_interval", "1h")
+	v.SetDefault("connections.protocol_cache_ttl", "30s")
 	v.SetDefault("live_state.lease_ttl", "15s")
 	v.SetDefault("live_state.renew_interval", "5s")
+	v.SetDefault("live_state.reconnect_grace", "60s")
+	v.SetDefault("live_state.write_request_timeout", "2m")
+	v.SetDefault("live_state.scrollback_bytes", 2<<20)
+	v.SetDefault("live_state.scrollback_skip_unrecorded", false)
+	v.SetDefault("live_state.session_close_timeout", "10s")
+	v.SetDefault("live_state.session_max_lifetime", "")
+	v.SetDefault("live_state.session_launch_timeout", "2m")
+	v.SetDefault("live_state.clipboard_max_bytes", 256<<10)
 	v.SetDefault("recordings.dir", "recordings")
 	v.SetDefault("recordings.retention_days", 0) // disabled: keep recordings forever
 	v.SetDefault("recordings.cleanup_interval", "1h")
 	v.SetDefault("recordings.max_chunk_bytes", 8<<20)
+	v.SetDefault("recordings.redact_input", true)
+	v.SetDefault("recordings.transcripts", false)
+	v.SetDefault("recordings.transcript_max_bytes", 64<<20)
+	v.SetDefault("recordings.chapter_idle_gap", "60s")
+	v.SetDefault("recordings.archive_dir", "")
+	v.SetDefault("recordings.archive_after_days", 0)
 	v.SetDefault("plugins.dir", "plugins.d")
 	v.SetDefault("plugins.market.enabled", true)
 	v.SetDefault("plugins.market.indexes", []string{
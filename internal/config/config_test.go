@@ -23,6 +23,12 @@ func TestLoadDefaults(t *testing.T) {
 	if cfg.Database.Driver != "sqlite" || cfg.Database.DSN != app.DefaultDatabaseDSN {
 		t.Errorf("database defaults: got %+v", cfg.Database)
 	}
+	if cfg.Database.QueryMetrics || cfg.Database.SlowQueryDuration().String() != "200ms" {
+		t.Errorf("query metrics defaults: got %+v", cfg.Database)
+	}
+	if cfg.Database.BreakerThreshold != 5 || cfg.Database.BreakerCooldownDuration().String() != "10s" {
+		t.Errorf("breaker defaults: got %+v", cfg.Database)
+	}
 	if cfg.Auth.SessionTTLDuration().String() != "24h0m0s" {
 		t.Errorf("auth session TTL default: got %s", cfg.Auth.SessionTTLDuration())
 	}
@@ -35,6 +41,12 @@ func TestLoadDefaults(t *testing.T) {
 	if cfg.Audit.CleanupEvery().String() != "1h0m0s" {
 		t.Errorf("audit cleanup interval default: got %s", cfg.Audit.CleanupEvery())
 	}
+	if !cfg.Connections.TrashPurgeEnabled() || cfg.Connections.TrashRetentionDays != 30 {
+		t.Errorf("connections trash default: got %+v", cfg.Connections)
+	}
+	if !cfg.Recordings.RedactInput {
+		t.Errorf("recordings should redact input by default")
+	}
 	if cfg.LiveState.LeaseTTLDuration().String() != "15s" || cfg.LiveState.RenewIntervalDuration().String() != "5s" {
 		t.Errorf("live_state defaults: ttl=%s renew=%s", cfg.LiveState.LeaseTTLDuration(), cfg.LiveState.RenewIntervalDuration())
 	}
@@ -53,8 +65,11 @@ func TestEnvOverride(t *testing.T) {
 	t.Setenv("SHELLCN_AUDIT_ENABLED", "false")
 	t.Setenv("SHELLCN_AUDIT_RETENTION_DAYS", "30")
 	t.Setenv("SHELLCN_AUDIT_CLEANUP_INTERVAL", "2h")
+	t.Setenv("SHELLCN_AUDIT_CREDENTIAL_ACCESS_RETENTION_DAYS", "90")
+	t.Setenv("SHELLCN_CONNECTIONS_TRASH_RETENTION_DAYS", "0")
 	t.Setenv("SHELLCN_LIVE_STATE_LEASE_TTL", "20s")
 	t.Setenv("SHELLCN_LIVE_STATE_RENEW_INTERVAL", "4s")
+	t.Setenv("SHELLCN_SERVER_TRUSTED_PROXIES", "10.0.0.0/8 192.168.1.7")
 
 	cfg, err := config.Load(t.TempDir())
 	if err != nil {
@@ -78,12 +93,25 @@ func TestEnvOverride(t *testing.T) {
 	if !cfg.AI.Configured() || cfg.AI.Model != "openai/gpt-4o" {
 		t.Errorf("ai env override: got %+v", cfg.AI)
 	}
-	if cfg.Audit.Enabled || cfg.Audit.RetentionDays != 30 || cfg.Audit.CleanupEvery().String() != "2h0m0s" {
+	if cfg.Audit.Enabled || cfg.Audit.RetentionDays != 30 || cfg.Audit.CleanupEvery().String() != "2h0m0s" ||
+		cfg.Audit.CredentialAccessRetentionDays != 90 {
 		t.Errorf("audit env override: got %+v", cfg.Audit)
 	}
+	if cfg.Connections.TrashPurgeEnabled() {
+		t.Errorf("connections env override: got %+v", cfg.Connections)
+	}
 	if cfg.LiveState.LeaseTTLDuration().String() != "20s" || cfg.LiveState.RenewIntervalDuration().String() != "4s" {
 		t.Errorf("live_state env override: ttl=%s renew=%s", cfg.LiveState.LeaseTTLDuration(), cfg.LiveState.RenewIntervalDuration())
 	}
+	if proxies, err := cfg.Server.TrustedProxyPrefixes(); err != nil || len(proxies) != 2 || proxies[1].String() != "192.168.1.7/32" {
+		t.Errorf("trusted proxies env override: got %v err=%v", proxies, err)
+	}
+}
+
+func TestTrustedProxyPrefixesRejectsGarbage(t *testing.T) {
+	if _, err := (config.ServerConfig{TrustedProxies: []string{"10.0.0.0/8", "proxy.local"}}).TrustedProxyPrefixes(); err == nil {
+		t.Fatal("a hostname should be rejected")
+	}
 }
 
 func TestFileLoadWithEnvPrecedence(t *testing.T) {
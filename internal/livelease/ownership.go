@@ -115,6 +115,12 @@ type LeaseRegistry interface {
 	PreferInternalURL(ctx context.Context, ref LeaseRef, internalURL string) error
 }
 
+// SessionCounter is implemented by registries that can count the session
+// leases an actor holds across every instance.
+type SessionCounter interface {
+	CountSessions(ctx context.Context, actorScope string) (int, error)
+}
+
 func AgentLeaseKey(connectionID string) string {
 	return "agent:" + connectionID
 }
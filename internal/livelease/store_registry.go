@@ -75,6 +75,11 @@ func (r *StoreLeaseRegistry) PreferInternalURL(ctx context.Context, ref LeaseRef
 	return err
 }
 
+func (r *StoreLeaseRegistry) CountSessions(ctx context.Context, actorScope string) (int, error) {
+	n, err := r.store.CountLive(ctx, "session:", ":"+actorScope, r.now().UTC())
+	return int(n), err
+}
+
 type storeLease struct {
 	registry *StoreLeaseRegistry
 	ref      LeaseRef
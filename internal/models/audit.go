@@ -19,6 +19,11 @@ type AuditEntry struct {
 	Username     string
 	Event        string `gorm:"index"` // route AuditEvent, e.g. "vm.snapshot.list"
 	ConnectionID string `gorm:"index"`
+	// ResourceType and ResourceID name what the operation acted on, e.g.
+	// "connection" and its id; both are empty when it acted on nothing in
+	// particular.
+	ResourceType string `gorm:"index:idx_audit_resource,priority:1"`
+	ResourceID   string `gorm:"index:idx_audit_resource,priority:2"`
 	RouteID      string
 	Risk         string
 	Result       AuditResult
@@ -30,6 +35,15 @@ type AuditEntry struct {
 	// to their conversation/turn.
 	Source string `gorm:"index"`
 	TurnID string
+	// RequestID is the correlation id of the HTTP request that recorded the
+	// entry, matching the request_id in the server log.
+	RequestID string `gorm:"index"`
+	// Seq orders the hash chain; Hash covers PrevHash, the hash of the entry
+	// at Seq-1, and the entry's own fields (see audit.ChainHash). Entries from
+	// before the chain existed leave all three empty.
+	Seq      *int64 `gorm:"uniqueIndex"`
+	PrevHash string
+	Hash     string
 }
 
 func (AuditEntry) TableName() string { return "audit_entries" }
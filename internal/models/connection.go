@@ -25,6 +25,7 @@ func CredentialGrantAccesses() []Access {
 type Connection struct {
 	ID       string `gorm:"primaryKey"`
 	Name     string
+	NameSort string `gorm:"index"` // Name's collation key; listings order by it
 	Protocol string `gorm:"index"`
 	OwnerID  string `gorm:"index"`
 	// Transport is "direct" or "agent".
@@ -33,6 +34,9 @@ type Connection struct {
 
 	// Config holds non-secret connection fields (host, port, …).
 	Config map[string]any `gorm:"serializer:json"`
+	// ConfigVersion is the plugin version Config and Secrets were saved under;
+	// empty for connections saved before it was tracked.
+	ConfigVersion string
 	// Secrets holds ciphertext for inline Secret==true fields, keyed by field key.
 	// The store only ever sees ciphertext; encryption happens in the service layer.
 	Secrets map[string][]byte `gorm:"serializer:json"`
@@ -42,13 +46,26 @@ type Connection struct {
 	Recording map[string]string `gorm:"serializer:json"`
 	// RetentionDays caps how long this connection's recordings are kept; 0 = keep.
 	RetentionDays int
+	// RecordingFailClosed ends an auto-recorded stream whose recording can no
+	// longer be written, instead of letting it continue unrecorded.
+	RecordingFailClosed bool
 
 	AIMode             AIMode
 	AIAllowDestructive bool
 	AIAutoApprove      bool
 
+	// FileTransferDisabled and ClipboardDisabled switch off features the
+	// protocol would otherwise offer. Stored negated so existing rows allow both.
+	FileTransferDisabled bool
+	ClipboardDisabled    bool
+
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	// LastUsedAt is when a session was last opened on the connection.
+	LastUsedAt *time.Time
+	// DeletedAt marks a connection moved to the trash; it is hidden from normal
+	// reads until restored or purged.
+	DeletedAt *time.Time `gorm:"index"`
 }
 
 type AIMode string
@@ -68,6 +85,7 @@ type ConnectionFolder struct {
 	UserID    string `gorm:"index"`
 	ParentID  string `gorm:"index"`
 	Name      string
+	NameSort  string
 	Color     string
 	SortOrder int
 	CreatedAt time.Time
@@ -88,13 +106,47 @@ type ConnectionPlacement struct {
 
 func (ConnectionPlacement) TableName() string { return "connection_placements" }
 
+// ConnectionName is the name a live connection holds in its owner's folder.
+// The unique key makes the store, not one replica's lock, decide which of two
+// writers racing for a name wins. NameHash is the SHA-256 of the trimmed,
+// lowercased name, which keeps the key short and free of the database's
+// collation rules.
+type ConnectionName struct {
+	ConnectionID string `gorm:"primaryKey"`
+	OwnerID      string `gorm:"uniqueIndex:idx_connection_name_claim"`
+	FolderID     string `gorm:"uniqueIndex:idx_connection_name_claim"`
+	NameHash     string `gorm:"uniqueIndex:idx_connection_name_claim"`
+}
+
+func (ConnectionName) TableName() string { return "connection_names" }
+
+// ConnectionFavorite pins a connection to a user's quick-access list. Position
+// orders the list; rows for connections the user can no longer reach are
+// skipped on read and pruned when the connection is purged.
+type ConnectionFavorite struct {
+	UserID       string `gorm:"primaryKey"`
+	ConnectionID string `gorm:"primaryKey;index"`
+	Position     int
+	CreatedAt    time.Time
+}
+
+func (ConnectionFavorite) TableName() string { return "user_connection_favorites" }
+
 // Grant is an explicit per-connection sharing grant to a subject (user).
 type Grant struct {
 	ID           string `gorm:"primaryKey"`
 	ConnectionID string `gorm:"index;uniqueIndex:idx_grant_conn_subject"`
 	SubjectID    string `gorm:"index;uniqueIndex:idx_grant_conn_subject"`
 	Access       Access
-	CreatedAt    time.Time
+	// ExpiresAt ends the grant's access; nil never expires. A lapsed grant is
+	// kept so its owner can see and extend it.
+	ExpiresAt *time.Time `gorm:"index"`
+	CreatedAt time.Time
+}
+
+// Active reports whether the grant still confers access at now.
+func (g Grant) Active(now time.Time) bool {
+	return g.ExpiresAt == nil || g.ExpiresAt.After(now)
 }
 
 func (Grant) TableName() string { return "grants" }
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// ConnectionSessionStatus is the lifecycle state of a connection session row.
+type ConnectionSessionStatus string
+
+const (
+	ConnectionSessionActive ConnectionSessionStatus = "active"
+	ConnectionSessionClosed ConnectionSessionStatus = "closed"
+	ConnectionSessionFailed ConnectionSessionStatus = "failed"
+)
+
+// ConnectionSession is one upstream session's lifetime on a connection. The
+// byte counts are flushed periodically while it is live, so they may trail
+// the in-memory session by up to the flush interval.
+type ConnectionSession struct {
+	ID           string `gorm:"primaryKey"`
+	ConnectionID string `gorm:"index"`
+	UserID       string `gorm:"index"`
+	Protocol     string
+	Status       ConnectionSessionStatus `gorm:"index"`
+	Reason       string
+	StartedAt    time.Time `gorm:"index"`
+	EndedAt      *time.Time
+	// BytesIn is what the browser sent toward the upstream; BytesOut is what
+	// the upstream sent back to the browser.
+	BytesIn   int64
+	BytesOut  int64
+	UpdatedAt time.Time
+}
+
+func (ConnectionSession) TableName() string { return "connection_sessions" }
@@ -7,10 +7,14 @@ import "time"
 type Credential struct {
 	ID        string            `gorm:"primaryKey"`
 	Name      string            `gorm:"not null"`
+	NameSort  string            `gorm:"index"`
 	Kind      string            `gorm:"index;not null"`
 	OwnerID   string            `gorm:"index;not null"`
 	Values    map[string]string `gorm:"serializer:json"`
 	Protocols []string          `gorm:"serializer:json"`
+	// RequiresApproval makes every secret resolution wait for a second
+	// person's approval.
+	RequiresApproval bool
 	// EncryptedValues is encrypted JSON for secret credential fields.
 	EncryptedValues []byte
 	CreatedAt       time.Time
@@ -19,6 +23,32 @@ type Credential struct {
 
 func (Credential) TableName() string { return "credentials" }
 
+// CredentialVersion is a snapshot of a credential's values, written on create
+// and on every update. Secret values stay encrypted as in Credential.
+type CredentialVersion struct {
+	ID              string            `gorm:"primaryKey"`
+	CredentialID    string            `gorm:"not null;uniqueIndex:idx_credversion_cred_version"`
+	Version         int               `gorm:"not null;uniqueIndex:idx_credversion_cred_version"`
+	Values          map[string]string `gorm:"serializer:json"`
+	EncryptedValues []byte
+	CreatedBy       string
+	CreatedAt       time.Time
+}
+
+func (CredentialVersion) TableName() string { return "credential_versions" }
+
+// CredentialUsage is one user's own history with a credential: whether they
+// pinned it in the picker and when they last resolved it. Rows are pruned
+// when the credential is deleted.
+type CredentialUsage struct {
+	UserID       string `gorm:"primaryKey"`
+	CredentialID string `gorm:"primaryKey;index"`
+	Favorite     bool
+	LastUsedAt   *time.Time
+}
+
+func (CredentialUsage) TableName() string { return "user_credential_usage" }
+
 // CredentialSummary is the non-secret view returned to clients for selection.
 // It never carries secret material, encrypted blobs, or storage keys.
 type CredentialSummary struct {
@@ -30,6 +60,14 @@ type CredentialSummary struct {
 	Values    map[string]string `json:"values,omitempty"`
 	Protocols []string          `json:"protocols,omitempty"`
 	UpdatedAt time.Time         `json:"updatedAt,omitzero"`
+	// KindLabel is the kind's display name, e.g. "SSH private key".
+	KindLabel string `json:"kindLabel,omitempty"`
+	// RequiresApproval is set on break-glass credentials.
+	RequiresApproval bool `json:"requiresApproval,omitempty"`
+	// IsFavorite and LastUsedByMeAt describe the requesting user's own
+	// CredentialUsage.
+	IsFavorite     bool       `json:"isFavorite,omitempty"`
+	LastUsedByMeAt *time.Time `json:"lastUsedByMeAt,omitempty"`
 }
 
 // Summary projects a Credential to its non-secret summary.
@@ -39,13 +77,14 @@ func (c Credential) Summary() CredentialSummary {
 		values[k] = v
 	}
 	return CredentialSummary{
-		ID:        c.ID,
-		Name:      c.Name,
-		Kind:      c.Kind,
-		OwnerID:   c.OwnerID,
-		Values:    values,
-		Protocols: c.Protocols,
-		UpdatedAt: c.UpdatedAt,
+		ID:               c.ID,
+		Name:             c.Name,
+		Kind:             c.Kind,
+		OwnerID:          c.OwnerID,
+		Values:           values,
+		Protocols:        c.Protocols,
+		UpdatedAt:        c.UpdatedAt,
+		RequiresApproval: c.RequiresApproval,
 	}
 }
 
@@ -59,3 +98,45 @@ type CredentialGrant struct {
 }
 
 func (CredentialGrant) TableName() string { return "credential_grants" }
+
+// CredentialAccessLog records one use of a credential's secret material,
+// separate from the global audit trail so a credential can be reviewed alone.
+type CredentialAccessLog struct {
+	ID           string    `gorm:"primaryKey"`
+	Time         time.Time `gorm:"index"`
+	CredentialID string    `gorm:"index;not null"`
+	UserID       string    `gorm:"index"`
+	ConnectionID string
+	Purpose      string
+	Result       AuditResult
+}
+
+func (CredentialAccessLog) TableName() string { return "credential_access_logs" }
+
+// CredentialApprovalStatus is where a two-person approval request stands.
+type CredentialApprovalStatus string
+
+const (
+	ApprovalPending  CredentialApprovalStatus = "pending"
+	ApprovalApproved CredentialApprovalStatus = "approved"
+	ApprovalUsed     CredentialApprovalStatus = "used"
+)
+
+// CredentialApproval is one user's request to resolve a credential that
+// requires approval. Someone else approves it before ExpiresAt; the requester
+// may then spend it once before UseBy.
+type CredentialApproval struct {
+	ID           string                   `gorm:"primaryKey" json:"id"`
+	CredentialID string                   `gorm:"index;not null" json:"credentialId"`
+	RequesterID  string                   `gorm:"index;not null" json:"requesterId"`
+	ConnectionID string                   `json:"connectionId,omitempty"`
+	Status       CredentialApprovalStatus `gorm:"index" json:"status"`
+	ApproverID   string                   `json:"approverId,omitempty"`
+	CreatedAt    time.Time                `json:"createdAt"`
+	ExpiresAt    time.Time                `json:"expiresAt"`
+	ApprovedAt   time.Time                `json:"approvedAt,omitzero"`
+	UseBy        time.Time                `json:"useBy,omitzero"`
+	UsedAt       time.Time                `json:"usedAt,omitzero"`
+}
+
+func (CredentialApproval) TableName() string { return "credential_approvals" }
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// IdempotencyKey remembers the response to a request sent with an
+// Idempotency-Key header, so a retry replays it instead of repeating the
+// operation. ID hashes the user, path and client key; RequestHash covers the
+// method, path and body so a reused key with a different request is caught.
+type IdempotencyKey struct {
+	ID          string `gorm:"primaryKey"`
+	UserID      string `gorm:"index"`
+	RequestHash string
+	Status      int
+	ContentType string
+	Body        []byte
+	ExpiresAt   time.Time `gorm:"index"`
+	CreatedAt   time.Time
+}
+
+func (IdempotencyKey) TableName() string { return "idempotency_keys" }
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// JobStatus is the lifecycle state of a background job.
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCanceled  JobStatus = "canceled"
+)
+
+// Done reports whether the job has finished, successfully or not.
+func (s JobStatus) Done() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCanceled
+}
+
+// Job is one long-running task requested over the API and run by a worker.
+// A small result is kept inline in Result; a larger one lives in blob storage
+// under ResultKey.
+type Job struct {
+	ID          string    `gorm:"primaryKey"`
+	Type        string    `gorm:"index"`
+	Status      JobStatus `gorm:"index"`
+	Progress    int
+	RequestedBy string            `gorm:"index"`
+	Params      map[string]string `gorm:"serializer:json"`
+	Error       string
+	ContentType string
+	FileName    string
+	Result      []byte
+	ResultKey   string
+	ResultSize  int64
+	StartedAt   *time.Time
+	FinishedAt  *time.Time
+	// ExpiresAt is when a finished job and its result are purged.
+	ExpiresAt *time.Time `gorm:"index"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (Job) TableName() string { return "jobs" }
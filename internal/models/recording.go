@@ -28,6 +28,7 @@ type Recording struct {
 	Class          string `gorm:"index"` // terminal | desktop
 	Format         string // asciicast_v2 | webm_canvas | ...
 	Authoritative  bool
+	InputCaptured  bool            // keystrokes were recorded; false means input was redacted
 	Status         RecordingStatus `gorm:"index"`
 	Title          string
 	StartedAt      time.Time
@@ -36,10 +37,72 @@ type Recording struct {
 	Size           int64
 	Checksum       string // sha256 hex of the finalized blob
 	StorageKey     string
+	TranscriptKey  string // extracted output text; empty until extraction runs
 	Error          string
-	ExpiresAt      *time.Time `gorm:"index"` // nil = retained indefinitely
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
+	Partial        bool                  // capture stopped early; the blob holds what was written before the failure
+	ExpiresAt      *time.Time            `gorm:"index"` // nil = retained indefinitely
+	Annotations    []RecordingAnnotation `gorm:"serializer:json"`
+	// Chapters are the recording's activity bursts; ChapterGapMS is the idle
+	// gap that separated them, zero until chaptering has run.
+	Chapters     []RecordingChapter `gorm:"serializer:json"`
+	ChapterGapMS int64
+	// Protected recordings are on legal hold: retention cleanup skips them and
+	// deletion is refused until an admin lifts the hold.
+	Protected   bool `gorm:"index;not null;default:false"`
+	ProtectedBy string
+	ProtectedAt *time.Time
+	// Client is where the recorded session was opened from.
+	Client ClientInfo `gorm:"embedded;embeddedPrefix:client_"`
+	// ArchivedAt is when the blob at StorageKey moved to the archive store;
+	// nil while it is in primary storage. The transcript stays in primary.
+	ArchivedAt *time.Time `gorm:"index"`
+	// ConnectionSnapshot is the connection's settings as the session used
+	// them; nil for recordings made before snapshots existed.
+	ConnectionSnapshot *ConnectionSnapshot `gorm:"serializer:json"`
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+// ClientInfo describes the browser that opened a session: its address as
+// resolved through any trusted proxies, its user agent and, when a GeoIP
+// lookup is configured, its location.
+type ClientInfo struct {
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"userAgent,omitempty"`
+	Country   string `json:"country,omitempty"`
+	City      string `json:"city,omitempty"`
+}
+
+// ConnectionSnapshot is the non-secret view of a connection's settings taken
+// when a session opened, so later edits do not rewrite what it ran with.
+type ConnectionSnapshot struct {
+	Protocol  string         `json:"protocol"`
+	Transport string         `json:"transport"`
+	Config    map[string]any `json:"config"`
+	// Version is the connection's UpdatedAt at launch.
+	Version time.Time `json:"version"`
+	// Truncated is set when fields were dropped to bound the snapshot's size.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// RecordingAnnotation is a user note pinned to an offset in a recording. Live
+// annotations are also written into the stream as asciicast markers; post-hoc
+// ones exist only here.
+type RecordingAnnotation struct {
+	Offset float64   `json:"offset"` // seconds from recording start
+	Text   string    `json:"text"`
+	UserID string    `json:"userId"`
+	Live   bool      `json:"live"`
+	At     time.Time `json:"at"`
+}
+
+// RecordingChapter is a contiguous stretch of terminal activity, bounded by
+// idle gaps longer than the chaptering threshold.
+type RecordingChapter struct {
+	StartSeconds float64 `json:"startSeconds"`
+	EndSeconds   float64 `json:"endSeconds"`
+	EventCount   int     `json:"eventCount"`
+	Bytes        int64   `json:"bytes"`
 }
 
 func (Recording) TableName() string { return "recordings" }
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// SeedRecord maps a fixture's stable id to the row the seed command created
+// for it, so running the same fixture again updates that row instead of
+// adding another.
+type SeedRecord struct {
+	Kind       string `gorm:"primaryKey"`
+	ExternalID string `gorm:"primaryKey"`
+	ResourceID string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (SeedRecord) TableName() string { return "seed_records" }
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// SessionObservation is one read-only attachment to another user's live
+// session. Observers see the session's output only and are not participants.
+type SessionObservation struct {
+	ID           string `gorm:"primaryKey"`
+	SessionID    string `gorm:"index"`
+	ConnectionID string `gorm:"index"`
+	ObserverID   string `gorm:"index"`
+	OwnerID      string
+	// Disclosed records whether the owner was told about the observation.
+	Disclosed bool
+	StartedAt time.Time `gorm:"index"`
+	EndedAt   *time.Time
+}
+
+func (SessionObservation) TableName() string { return "session_observations" }
@@ -0,0 +1,128 @@
+package models
+
+import "time"
+
+// ShareMode is the access a session share link hands out.
+type ShareMode string
+
+const (
+	// ShareRead lets a joiner watch the session.
+	ShareRead ShareMode = "read"
+	// ShareWrite also makes the joiner the session's write holder.
+	ShareWrite ShareMode = "write"
+)
+
+// Valid reports whether m is a known share mode.
+func (m ShareMode) Valid() bool {
+	return m == ShareRead || m == ShareWrite
+}
+
+// SessionShareLink invites other users into OwnerID's live session on a
+// connection. Joining makes the user a participant of that one session, not
+// of the connection; the participants go, with the link, when the session
+// closes. Only the token hash is stored. CreatedBy is the owner or the
+// session's write holder; OwnerID is empty on links from before a write
+// holder could share, when the creator was always the owner.
+type SessionShareLink struct {
+	ID           string `gorm:"primaryKey"`
+	ConnectionID string `gorm:"index"`
+	OwnerID      string `gorm:"index"`
+	CreatedBy    string `gorm:"index"`
+	TokenHash    string `gorm:"uniqueIndex"`
+	Mode         ShareMode
+	MaxUses      int
+	Uses         int
+	ExpiresAt    time.Time
+	RevokedAt    *time.Time
+	CreatedAt    time.Time
+}
+
+func (SessionShareLink) TableName() string { return "session_share_links" }
+
+// SessionShareLinkSummary is the client view of a link (no token).
+type SessionShareLinkSummary struct {
+	ID           string    `json:"id"`
+	ConnectionID string    `json:"connectionId"`
+	OwnerID      string    `json:"ownerId"`
+	CreatedBy    string    `json:"createdBy"`
+	Mode         ShareMode `json:"mode"`
+	MaxUses      int       `json:"maxUses"`
+	Uses         int       `json:"uses"`
+	Status       string    `json:"status"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// SessionOwner returns the user whose session the link joins.
+func (l SessionShareLink) SessionOwner() string {
+	if l.OwnerID != "" {
+		return l.OwnerID
+	}
+	return l.CreatedBy
+}
+
+// Active reports whether the link can still be redeemed at now.
+func (l SessionShareLink) Active(now time.Time) bool {
+	return l.RevokedAt == nil && now.Before(l.ExpiresAt) && l.Uses < l.MaxUses
+}
+
+func (l SessionShareLink) Summary() SessionShareLinkSummary {
+	status := "active"
+	switch now := time.Now(); {
+	case l.RevokedAt != nil:
+		status = "revoked"
+	case l.Uses >= l.MaxUses:
+		status = "used"
+	case !now.Before(l.ExpiresAt):
+		status = "expired"
+	}
+	return SessionShareLinkSummary{
+		ID: l.ID, ConnectionID: l.ConnectionID, OwnerID: l.SessionOwner(), CreatedBy: l.CreatedBy, Mode: l.Mode,
+		MaxUses: l.MaxUses, Uses: l.Uses, Status: status, ExpiresAt: l.ExpiresAt, CreatedAt: l.CreatedAt,
+	}
+}
+
+// SessionParticipant is a user who joined the live session OwnerID holds on
+// ConnectionID through a share link. Participants watch the owner's stream;
+// only the write holder's input reaches it. At most one participant of a
+// session holds write, and with none the owner alone types.
+type SessionParticipant struct {
+	ID           string `gorm:"primaryKey"`
+	ConnectionID string `gorm:"index;uniqueIndex:idx_participant_session_user"`
+	OwnerID      string `gorm:"uniqueIndex:idx_participant_session_user"`
+	UserID       string `gorm:"uniqueIndex:idx_participant_session_user"`
+	ShareLinkID  string `gorm:"index"`
+	Mode         ShareMode
+	WriteHolder  bool
+	JoinedAt     time.Time
+}
+
+func (SessionParticipant) TableName() string { return "session_participants" }
+
+// WriteRequestStatus is where a request to hold write stands.
+type WriteRequestStatus string
+
+const (
+	WriteRequestPending  WriteRequestStatus = "pending"
+	WriteRequestApproved WriteRequestStatus = "approved"
+	WriteRequestDenied   WriteRequestStatus = "denied"
+	WriteRequestExpired  WriteRequestStatus = "expired"
+)
+
+// SessionWriteRequest is a participant of OwnerID's session on ConnectionID
+// asking to hold write. It stays pending until answered, and counts as
+// expired once ExpiresAt passes unanswered.
+type SessionWriteRequest struct {
+	ID           string             `gorm:"primaryKey" json:"id"`
+	ConnectionID string             `gorm:"index" json:"connectionId"`
+	UserID       string             `json:"userId"`
+	OwnerID      string             `json:"ownerId"`
+	Status       WriteRequestStatus `gorm:"index" json:"status"`
+	DecidedBy    string             `json:"decidedBy,omitempty"`
+	Reason       string             `json:"reason,omitempty"`
+	CreatedAt    time.Time          `json:"createdAt"`
+	ExpiresAt    time.Time          `json:"expiresAt"`
+	DecidedAt    time.Time          `json:"decidedAt,omitzero"`
+}
+
+func (SessionWriteRequest) TableName() string { return "session_write_requests" }
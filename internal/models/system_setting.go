@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// SystemSetting is one admin-managed, instance-wide key/value setting.
+type SystemSetting struct {
+	Key       string `gorm:"primaryKey"`
+	Value     string
+	UpdatedBy string
+	UpdatedAt time.Time
+}
+
+func (SystemSetting) TableName() string { return "system_settings" }
@@ -30,6 +30,11 @@ type User struct {
 	Disabled       bool
 	// Protected marks the root admin, which can never be deleted.
 	Protected bool
+	// PasswordChangedAt is when the local password was last set; nil for
+	// accounts that predate tracking it.
+	PasswordChangedAt *time.Time
+	// MustChangePassword limits the account to changing its password.
+	MustChangePassword bool
 
 	// Two-factor authentication (TOTP). TOTPSecret holds the encrypted shared
 	// secret and never serializes to clients. A non-empty secret with
@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// Webhook is an admin-managed endpoint notified of lifecycle events. The
+// signing secret is stored only as ciphertext and never serializes to clients.
+type Webhook struct {
+	ID               string `gorm:"primaryKey"`
+	Name             string
+	URL              string
+	Events           []string `gorm:"serializer:json"`
+	Enabled          bool
+	SecretCiphertext []byte `json:"-"`
+	CreatedBy        string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+func (Webhook) TableName() string { return "webhooks" }
+
+// Subscribed reports whether the webhook wants event; an empty filter matches all.
+func (w Webhook) Subscribed(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookSummary is the non-secret projection returned to clients.
+type WebhookSummary struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Enabled   bool      `json:"enabled"`
+	HasSecret bool      `json:"hasSecret"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Summary projects a webhook without its secret.
+func (w Webhook) Summary() WebhookSummary {
+	events := w.Events
+	if events == nil {
+		events = []string{}
+	}
+	return WebhookSummary{
+		ID: w.ID, Name: w.Name, URL: w.URL, Events: events, Enabled: w.Enabled,
+		HasSecret: len(w.SecretCiphertext) > 0, CreatedBy: w.CreatedBy,
+		CreatedAt: w.CreatedAt, UpdatedAt: w.UpdatedAt,
+	}
+}
+
+// WebhookDelivery is one attempt to deliver an event to a webhook.
+type WebhookDelivery struct {
+	ID         string    `gorm:"primaryKey" json:"id"`
+	WebhookID  string    `gorm:"index" json:"webhookId"`
+	DeliveryID string    `json:"deliveryId"`
+	Event      string    `json:"event"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"durationMs"`
+	Test       bool      `json:"test,omitempty"`
+	CreatedAt  time.Time `gorm:"index" json:"createdAt"`
+}
+
+func (WebhookDelivery) TableName() string { return "webhook_deliveries" }
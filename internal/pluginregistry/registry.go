@@ -16,6 +16,8 @@ type entry struct {
 	plugin   plugin.Plugin
 	manifest plugin.Manifest
 	routes   map[string]plugin.Route
+	template plugin.ConfigTemplate
+	settings *plugin.Schema
 }
 
 type Registry struct {
@@ -48,8 +50,11 @@ func (r *Registry) Register(p plugin.Plugin) error {
 	if err := validateUX(m, routes); err != nil {
 		return fmt.Errorf("plugin %q: %w", m.Name, err)
 	}
+	if err := validateSettings(p); err != nil {
+		return fmt.Errorf("plugin %q: %w", m.Name, err)
+	}
 
-	r.byName[m.Name] = &entry{plugin: p, manifest: m, routes: routeMap(routes)}
+	r.byName[m.Name] = newEntry(p, m, routes)
 	return nil
 }
 
@@ -74,8 +79,11 @@ func (r *Registry) Replace(p plugin.Plugin) error {
 	if err := validateUX(m, routes); err != nil {
 		return fmt.Errorf("plugin %q: %w", m.Name, err)
 	}
+	if err := validateSettings(p); err != nil {
+		return fmt.Errorf("plugin %q: %w", m.Name, err)
+	}
 
-	r.byName[m.Name] = &entry{plugin: p, manifest: m, routes: routeMap(routes)}
+	r.byName[m.Name] = newEntry(p, m, routes)
 	return nil
 }
 
@@ -107,6 +115,38 @@ func validateUX(m plugin.Manifest, routes []plugin.Route) error {
 	return fmt.Errorf("UX contract: %s", strings.Join(messages, "; "))
 }
 
+// validateSettings keeps secrets out of driver settings, which are stored in
+// plain system settings.
+func validateSettings(p plugin.Plugin) error {
+	c, ok := p.(plugin.Configurable)
+	if !ok {
+		return nil
+	}
+	for _, g := range c.Settings().Groups {
+		if key, ok := secretField(g.Fields); ok {
+			return fmt.Errorf("driver setting %q cannot hold a secret or file", key)
+		}
+	}
+	return nil
+}
+
+func secretField(fields []plugin.Field) (string, bool) {
+	for _, f := range fields {
+		switch {
+		case f.Secret, f.Type == plugin.FieldPassword, f.Type == plugin.FieldCredentialRef, f.Type == plugin.FieldFile:
+			return f.Key, true
+		case f.Item != nil:
+			if _, ok := secretField([]plugin.Field{*f.Item}); ok {
+				return f.Key, true
+			}
+		}
+		if _, ok := secretField(f.Fields); ok {
+			return f.Key, true
+		}
+	}
+	return "", false
+}
+
 func (r *Registry) Get(name string) (plugin.Plugin, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -127,6 +167,18 @@ func (r *Registry) Manifest(name string) (plugin.Manifest, bool) {
 	return e.manifest, true
 }
 
+// Settings returns the driver settings schema of a plugin that implements
+// plugin.Configurable.
+func (r *Registry) Settings(name string) (plugin.Schema, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.byName[name]
+	if !ok || e.settings == nil {
+		return plugin.Schema{}, false
+	}
+	return *e.settings, true
+}
+
 func (r *Registry) Route(pluginName, routeID string) (plugin.Route, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -187,6 +239,18 @@ func (r *Registry) Projection(name string) (plugin.Projection, bool) {
 	return plugin.BuildProjection(e.manifest, e.routes), true
 }
 
+// Template returns the plugin's connection form, resolved once at
+// registration.
+func (r *Registry) Template(name string) (plugin.ConfigTemplate, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.byName[name]
+	if !ok {
+		return plugin.ConfigTemplate{}, false
+	}
+	return e.template, true
+}
+
 func (r *Registry) CredentialKinds() []plugin.CredentialKindInfo {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -243,6 +307,15 @@ func (r *Registry) credentialCatalogWithSupportsLocked() (*plugin.CredentialKind
 	return catalog, nil
 }
 
+func newEntry(p plugin.Plugin, m plugin.Manifest, routes []plugin.Route) *entry {
+	e := &entry{plugin: p, manifest: m, routes: routeMap(routes), template: plugin.BuildConfigTemplate(m)}
+	if c, ok := p.(plugin.Configurable); ok {
+		schema := c.Settings()
+		e.settings = &schema
+	}
+	return e
+}
+
 func routeMap(routes []plugin.Route) map[string]plugin.Route {
 	out := make(map[string]plugin.Route, len(routes))
 	for _, rt := range routes {
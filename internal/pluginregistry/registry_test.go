@@ -372,3 +372,36 @@ func protocolCredentialManifest(name string, kinds []plugin.CredentialKind) (plu
 func contains(s, sub string) bool {
 	return strings.Contains(s, sub)
 }
+
+type settingsPlugin struct {
+	stubPlugin
+	settings plugin.Schema
+}
+
+func (s *settingsPlugin) Settings() plugin.Schema { return s.settings }
+
+func TestSettingsComeFromConfigurablePlugins(t *testing.T) {
+	m, routes := sampleManifest()
+	reg := New()
+	secret := &settingsPlugin{stubPlugin: stubPlugin{manifest: m, routes: routes}, settings: plugin.Schema{Groups: []plugin.Group{{Name: "Gateway", Fields: []plugin.Field{
+		{Key: "gateway", Label: "Gateway", Type: plugin.FieldObject, Fields: []plugin.Field{{Key: "token", Label: "Token", Type: plugin.FieldText, Secret: true}}},
+	}}}}}
+	if err := reg.Register(secret); err == nil || !strings.Contains(err.Error(), `"gateway"`) {
+		t.Fatalf("secret setting: %v", err)
+	}
+	plain := &settingsPlugin{stubPlugin: stubPlugin{manifest: m, routes: routes}, settings: plugin.Schema{Groups: []plugin.Group{{Name: "Gateway", Fields: []plugin.Field{
+		{Key: "host", Label: "Host", Type: plugin.FieldText},
+	}}}}}
+	if err := reg.Register(plain); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if s, ok := reg.Settings("sample"); !ok || s.Groups[0].Fields[0].Key != "host" {
+		t.Fatalf("Settings(sample) = %+v, %v", s, ok)
+	}
+	if err := reg.Replace(&stubPlugin{manifest: m, routes: routes}); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if _, ok := reg.Settings("sample"); ok {
+		t.Fatal("settings survived a replace with a plugin that declares none")
+	}
+}
@@ -103,16 +103,22 @@ func (en *Enforcer) LoadStorePolicies(ctx context.Context, policies store.Policy
 
 // roleAllows reports whether any of the user's roles permits the permission/risk.
 func (en *Enforcer) roleAllows(roles []models.Role, permission string, risk plugin.RiskLevel) bool {
+	_, ok := en.allowingRole(roles, permission, risk)
+	return ok
+}
+
+// allowingRole returns the first of roles that permits the permission/risk.
+func (en *Enforcer) allowingRole(roles []models.Role, permission string, risk plugin.RiskLevel) (models.Role, bool) {
 	if permission == "" {
 		permission = "*"
 	}
 	for _, role := range roles {
 		ok, err := en.e.Enforce(string(role), permission, string(risk))
 		if err == nil && ok {
-			return true
+			return role, true
 		}
 	}
-	return false
+	return "", false
 }
 
 // AccessInput is everything an authorization decision needs. The caller (the
@@ -137,30 +143,66 @@ type AccessInput struct {
 // Admin is a user-management role, not a super-user: it grants no implicit access
 // to other users' connections.
 func (en *Enforcer) Authorize(in AccessInput) error {
+	return en.evaluate(in, nil)
+}
+
+// TraceStep is one rule evaluated while reaching a decision.
+type TraceStep struct {
+	Rule    string `json:"rule"`
+	Matched bool   `json:"matched"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// Explain reaches the same decision as Authorize and also returns every rule
+// it evaluated, in order, so an operator can see why access was granted or
+// refused.
+func (en *Enforcer) Explain(in AccessInput) ([]TraceStep, error) {
+	var trace []TraceStep
+	err := en.evaluate(in, &trace)
+	return trace, err
+}
+
+func (en *Enforcer) evaluate(in AccessInput, trace *[]TraceStep) error {
+	note := func(rule string, matched bool, detail string) {
+		if trace != nil {
+			*trace = append(*trace, TraceStep{Rule: rule, Matched: matched, Detail: detail})
+		}
+	}
+	roleGate := func() error {
+		role, ok := en.allowingRole(in.User.Roles, in.Permission, in.Risk)
+		if !ok {
+			note("role_policy", false, fmt.Sprintf("no role permits %q/%q", in.Permission, in.Risk))
+			return fmt.Errorf("%w: role may not perform %q/%q actions", ErrForbidden, in.Permission, in.Risk)
+		}
+		note("role_policy", true, fmt.Sprintf("role %q permits %q/%q", role, in.Permission, in.Risk))
+		return nil
+	}
+
+	note("account_active", !in.User.Disabled, "")
 	if in.User.Disabled {
 		return fmt.Errorf("%w: account disabled", ErrForbidden)
 	}
+	note("has_role", hasAnyRole(in.User.Roles), fmt.Sprint(in.User.Roles))
 	if !hasAnyRole(in.User.Roles) {
 		return fmt.Errorf("%w: role may not perform %q/%q actions", ErrForbidden, in.Permission, in.Risk)
 	}
 	if in.ConnectionID == "" {
-		if !en.roleAllows(in.User.Roles, in.Permission, in.Risk) {
-			return fmt.Errorf("%w: role may not perform %q/%q actions", ErrForbidden, in.Permission, in.Risk)
-		}
-		return nil
+		return roleGate()
 	}
-	if in.OwnerID != "" && in.OwnerID == in.User.ID {
-		if !en.roleAllows(in.User.Roles, in.Permission, in.Risk) {
-			return fmt.Errorf("%w: role may not perform %q/%q actions", ErrForbidden, in.Permission, in.Risk)
-		}
-		return nil
+	owner := in.OwnerID != "" && in.OwnerID == in.User.ID
+	note("owner", owner, "owner "+in.OwnerID)
+	if owner {
+		return roleGate()
 	}
+	note("grant", in.HasGrant, string(in.GrantAccess))
 	if !in.HasGrant {
 		return fmt.Errorf("%w: no access to connection %q", ErrForbidden, in.ConnectionID)
 	}
 	if !grantAllows(in.GrantAccess, in.Risk) {
+		note("grant_tier", false, fmt.Sprintf("grant %q does not cover %q", in.GrantAccess, in.Risk))
 		return fmt.Errorf("%w: grant %q may not perform %q/%q actions", ErrForbidden, in.GrantAccess, in.Permission, in.Risk)
 	}
+	note("grant_tier", true, fmt.Sprintf("grant %q covers %q", in.GrantAccess, in.Risk))
 	return nil
 }
 
@@ -212,3 +212,22 @@ func TestLoadStorePolicies(t *testing.T) {
 		t.Fatalf("stored policy should authorize matching route: %v", err)
 	}
 }
+
+func TestExplainMatchesAuthorize(t *testing.T) {
+	en := newEnforcer(t)
+	inputs := []policy.AccessInput{
+		{User: user("owner", models.RoleOperator), Risk: plugin.RiskWrite, ConnectionID: "c1", OwnerID: "owner"},
+		{User: user("other", models.RoleOperator), Risk: plugin.RiskSafe, ConnectionID: "c1", OwnerID: "owner"},
+		{User: user("other", models.RoleOperator), Risk: plugin.RiskDestructive, ConnectionID: "c1", OwnerID: "owner", HasGrant: true, GrantAccess: models.AccessView},
+		{User: models.User{ID: "gone", Roles: []models.Role{models.RoleAdmin}, Disabled: true}, Risk: plugin.RiskSafe},
+	}
+	for i, in := range inputs {
+		trace, err := en.Explain(in)
+		if (err == nil) != (en.Authorize(in) == nil) {
+			t.Fatalf("input %d: Explain and Authorize disagree (%v)", i, err)
+		}
+		if len(trace) == 0 || trace[len(trace)-1].Matched != (err == nil) {
+			t.Fatalf("input %d: last step should carry the decision: %+v", i, trace)
+		}
+	}
+}
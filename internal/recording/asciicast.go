@@ -17,7 +17,8 @@ const asciicastIdleTimeLimit = 2.0
 // asciicastRecorder encodes terminal events as asciicast v2: a JSON header line
 // followed by newline-delimited `[time, code, data]` event arrays. Writes are
 // incremental and flushed per line, so a recording stays valid if the session
-// ends abruptly. Output is `o`, input `i`, resize `r` ("{cols}x{rows}").
+// ends abruptly. Output is `o`, input `i`, resize `r` ("{cols}x{rows}"),
+// marker `m` (annotation label).
 type asciicastRecorder struct {
 	mu  sync.Mutex
 	w   io.Writer
@@ -107,4 +108,8 @@ func (r *asciicastRecorder) Resize(ts time.Duration, cols, rows int) error {
 	return r.event(ts, "r", fmt.Sprintf("%dx%d", cols, rows))
 }
 
+func (r *asciicastRecorder) Marker(ts time.Duration, label string) error {
+	return r.event(ts, "m", label)
+}
+
 func (r *asciicastRecorder) Close() error { return nil }
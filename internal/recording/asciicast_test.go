@@ -72,6 +72,16 @@ func TestAsciicastEncodesEventsAndResize(t *testing.T) {
 	}
 }
 
+func TestAsciicastEncodesMarker(t *testing.T) {
+	var buf bytes.Buffer
+	r, _ := NewAsciicastRecorder(&buf, StartInfo{})
+	_ = r.Marker(2500*time.Millisecond, "deploy starts")
+	_, events := parseLines(t, buf.Bytes())
+	if len(events) != 1 || events[0][0] != 2.5 || events[0][1] != "m" || events[0][2] != "deploy starts" {
+		t.Fatalf("marker event: %+v", events)
+	}
+}
+
 func TestAsciicastOmitsInputUnlessWritten(t *testing.T) {
 	var buf bytes.Buffer
 	r, _ := NewAsciicastRecorder(&buf, StartInfo{})
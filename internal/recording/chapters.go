@@ -0,0 +1,87 @@
+package recording
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+)
+
+// SkipIdlePause is how long an idle gap lasts when replay skips idle time.
+const SkipIdlePause = 2.0
+
+// ExtractChapters splits an asciicast v2 stream into activity bursts: runs of
+// output and input events with no gap longer than gap between them. Event
+// times that run backwards are held at the latest time seen, so a clock
+// anomaly never yields a negative or overlapping chapter. A cast without
+// events has no chapters.
+func ExtractChapters(r io.Reader, gap time.Duration) ([]models.RecordingChapter, error) {
+	cast, err := NewCastReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = cast.Close() }()
+
+	limit := gap.Seconds()
+	chapters := []models.RecordingChapter{}
+	var cur *models.RecordingChapter
+	last := 0.0
+	for {
+		ev, err := cast.Next()
+		if errors.Is(err, io.EOF) {
+			return chapters, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ev.Code != "o" && ev.Code != "i" {
+			continue
+		}
+		t := max(ev.Time, last)
+		if cur == nil || t-cur.EndSeconds > limit {
+			chapters = append(chapters, models.RecordingChapter{StartSeconds: t, EndSeconds: t})
+			cur = &chapters[len(chapters)-1]
+		}
+		cur.EndSeconds = t
+		cur.EventCount++
+		cur.Bytes += int64(len(ev.Data))
+		last = t
+	}
+}
+
+// IdleSkipper maps recording time onto a replay timeline where every gap
+// between events longer than Gap seconds plays as SkipIdlePause. Times must
+// be fed in stream order; a time earlier than the last one maps to the same
+// point.
+type IdleSkipper struct {
+	Gap   float64
+	last  float64
+	shift float64
+}
+
+// Map returns the replay time for an event at t seconds into the recording.
+func (s *IdleSkipper) Map(t float64) float64 {
+	t = max(t, s.last)
+	if d := t - s.last; d > s.Gap {
+		s.shift += d - SkipIdlePause
+	}
+	s.last = t
+	return t - s.shift
+}
+
+// SkippedDuration is how long a recording of duration seconds plays with idle
+// gaps longer than gap compressed, given its chapters.
+func SkippedDuration(chapters []models.RecordingChapter, duration, gap float64) float64 {
+	out, last := duration, 0.0
+	for _, c := range chapters {
+		if d := c.StartSeconds - last; d > gap {
+			out -= d - SkipIdlePause
+		}
+		last = c.EndSeconds
+	}
+	if d := duration - last; d > gap {
+		out -= d - SkipIdlePause
+	}
+	return out
+}
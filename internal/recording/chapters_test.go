@@ -0,0 +1,69 @@
+package recording
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+)
+
+func TestExtractChaptersSplitsOnIdleGaps(t *testing.T) {
+	cast := strings.Join([]string{
+		`{"version":2,"width":80,"height":24}`,
+		`[1.0,"o","$ "]`,
+		`[1.5,"i","ls\r"]`,
+		`[2.0,"o","a b\r\n"]`,
+		`[30.0,"r","100x30"]`, // resizes and markers are not activity
+		`[100.0,"o","later"]`,
+		`[99.0,"o","skew"]`, // a clock step backwards stays in the chapter
+		`[101.0,"o","!"]`,
+	}, "\n")
+	got, err := ExtractChapters(strings.NewReader(cast), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.RecordingChapter{
+		{StartSeconds: 1, EndSeconds: 2, EventCount: 3, Bytes: 2 + 3 + 5},
+		{StartSeconds: 100, EndSeconds: 101, EventCount: 3, Bytes: 5 + 4 + 1},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("chapters = %+v, want %+v", got, want)
+	}
+}
+
+func TestExtractChaptersWithoutEvents(t *testing.T) {
+	got, err := ExtractChapters(strings.NewReader(`{"version":2,"width":80,"height":24}`+"\n"), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || len(got) != 0 {
+		t.Fatalf("chapters = %#v, want an empty list", got)
+	}
+	if _, err := ExtractChapters(strings.NewReader("not a cast\n"), time.Minute); err == nil {
+		t.Fatal("non-asciicast input should fail")
+	}
+}
+
+func TestIdleSkipperCompressesLongGaps(t *testing.T) {
+	s := IdleSkipper{Gap: 60}
+	for _, tc := range []struct{ in, want float64 }{
+		{1, 1},
+		{30, 30},  // under the gap: played as recorded
+		{130, 32}, // 100s idle plays as SkipIdlePause
+		{120, 32}, // backwards: held at the latest point
+		{131, 33},
+	} {
+		if got := s.Map(tc.in); got != tc.want {
+			t.Fatalf("Map(%v) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+
+	chapters := []models.RecordingChapter{{StartSeconds: 1, EndSeconds: 30}, {StartSeconds: 130, EndSeconds: 131}}
+	if got := SkippedDuration(chapters, 300, 60); got != 33+SkipIdlePause {
+		t.Fatalf("skipped duration = %v", got)
+	}
+	if got := SkippedDuration(nil, 300, 60); got != SkipIdlePause {
+		t.Fatalf("idle recording skipped duration = %v", got)
+	}
+}
@@ -58,6 +58,7 @@ func (e *Engine) BeginChunked(ctx context.Context, info StreamInfo, format plugi
 		Status:        models.RecordingActive, Title: info.Title, StartedAt: start,
 		StorageKey: StorageKey(info.Connection.ID, id, format),
 		ExpiresAt:  ExpiryFor(start, info.Connection.RetentionDays, e.retention),
+		Client:     info.Client, ConnectionSnapshot: info.ConnectionSnapshot,
 	}
 	if err := e.store.Create(ctx, &row); err != nil {
 		return models.Recording{}, err
@@ -153,6 +154,7 @@ func (e *Engine) FinalizeChunked(ctx context.Context, recordingID, userID string
 		}
 		e.metrics.RecordingFinished()
 		e.auditChunked(ctx, cr.info, EventFinalize, models.AuditAllowed)
+		e.finalized(cr.rec)
 	}
 	e.mu.Lock()
 	delete(e.chunked, recordingID)
@@ -25,6 +25,13 @@ const (
 	EventDelete   = "recording.delete"
 )
 
+// EventWriteFailed marks the moment a live recording stopped capturing.
+const EventWriteFailed = "recording.write_failed"
+
+// maxDeferredWrites caps the recording metadata held in memory while the
+// database is in read-only maintenance mode.
+const maxDeferredWrites = 1024
+
 // defaultBufferEvents bounds the per-recording event queue. A full queue marks
 // the recording failed rather than blocking the live stream.
 const defaultBufferEvents = 1024
@@ -37,7 +44,16 @@ type Options struct {
 	Metrics              Metrics
 	DefaultRetentionDays int
 	BufferEvents         int
-	Now                  func() time.Time
+	// CaptureInput records keystrokes into terminal recordings. Off, input is
+	// dropped before it is queued and only output is kept.
+	CaptureInput bool
+	// OnFinalize observes every recording once its final metadata is saved.
+	// It must not block.
+	OnFinalize func(models.Recording)
+	Now        func() time.Time
+	// OnWriteFailure observes a live recording whose blob writes failed for
+	// good; capture stops there while the stream goes on. It must not block.
+	OnWriteFailure func(models.Recording, error)
 }
 
 // Engine decides whether a stream is recorded and owns recording lifecycle.
@@ -49,11 +65,25 @@ type Engine struct {
 	now       func() time.Time
 	bufEvents int
 	retention int
+	capInput  bool
+	onFinal   func(models.Recording)
+	onFail    func(models.Recording, error)
+	sleep     func(time.Duration)
 	factories map[plugin.RecordingFormat]RecorderFactory
 
 	mu      sync.Mutex
 	active  map[string]*recSession // streamed (tap) recordings, keyed by StreamKey
 	chunked map[string]*chunkedRec // client-uploaded recordings, keyed by recording id
+
+	deferMu  sync.Mutex
+	readOnly bool
+	deferred []deferredWrite
+}
+
+// deferredWrite is recording metadata held back while the database is read-only.
+type deferredWrite struct {
+	rec    models.Recording
+	create bool
 }
 
 // NewEngine builds an Engine. Register a RecorderFactory per format before use.
@@ -66,6 +96,10 @@ func NewEngine(opts Options) *Engine {
 		now:       opts.Now,
 		bufEvents: opts.BufferEvents,
 		retention: opts.DefaultRetentionDays,
+		capInput:  opts.CaptureInput,
+		onFinal:   opts.OnFinalize,
+		onFail:    opts.OnWriteFailure,
+		sleep:     time.Sleep,
 		factories: map[plugin.RecordingFormat]RecorderFactory{},
 		active:    map[string]*recSession{},
 		chunked:   map[string]*chunkedRec{},
@@ -102,6 +136,11 @@ type StreamInfo struct {
 	Rows       int
 	Title      string
 	RemoteAddr string
+	// Client is where the session was opened from; it is stored on the row.
+	Client models.ClientInfo
+	// ConnectionSnapshot is the connection's settings as the session used
+	// them; it is stored on the row.
+	ConnectionSnapshot *models.ConnectionSnapshot
 }
 
 // StreamKey locates a live stream's recording tap.
@@ -153,6 +192,7 @@ func (e *Engine) Prepare(ctx context.Context, info StreamInfo) (*Pending, error)
 		info:       info,
 		capability: capability,
 		forced:     policy == plugin.PolicyAuto,
+		failClosed: policy == plugin.PolicyAuto && info.Connection.RecordingFailClosed,
 	}
 	if sess.forced && capability.Class != plugin.RecordingTerminal && capability.DefaultFormat() != plugin.FormatWebMCanvas {
 		if err := e.startSession(ctx, sess); err != nil {
@@ -165,6 +205,46 @@ func (e *Engine) Prepare(ctx context.Context, info StreamInfo) (*Pending, error)
 // Recording reports whether this Pending will (or already does) record.
 func (p *Pending) Recording() bool { return p != nil && p.sess != nil }
 
+// Begin starts a required terminal recording right away instead of on the
+// first keystroke, for streams that carry no user input.
+func (p *Pending) Begin(ctx context.Context) error {
+	if p == nil || p.sess == nil {
+		return nil
+	}
+	if err := p.sess.startOnInteraction(ctx); err != nil {
+		return fmt.Errorf("%w: required recording could not start: %v", plugin.ErrUnavailable, err)
+	}
+	return nil
+}
+
+// RecordingID returns the ID of the recording once it has started.
+func (p *Pending) RecordingID() string {
+	if p == nil || p.sess == nil {
+		return ""
+	}
+	p.sess.mu.Lock()
+	defer p.sess.mu.Unlock()
+	if p.sess.rec == nil {
+		return ""
+	}
+	return p.sess.rec.ID
+}
+
+// SetConnectionSnapshot records the settings of the session the stream
+// opened on, for a recording prepared before the session was. A recording
+// already running stores it when it is finalized.
+func (p *Pending) SetConnectionSnapshot(snap *models.ConnectionSnapshot) {
+	if p == nil || p.sess == nil || snap == nil {
+		return
+	}
+	p.sess.mu.Lock()
+	defer p.sess.mu.Unlock()
+	p.sess.info.ConnectionSnapshot = snap
+	if p.sess.rec != nil {
+		p.sess.rec.ConnectionSnapshot = snap
+	}
+}
+
 // Attach wraps the live client stream with the recording tap.
 func (p *Pending) Attach(client plugin.ClientStream) plugin.ClientStream {
 	if p == nil || p.sess == nil {
@@ -266,7 +346,7 @@ func (e *Engine) startSessionLocked(ctx context.Context, sess *recSession) error
 	if err != nil {
 		return err
 	}
-	counter := newCountingWriter(w)
+	counter := newCountingWriter(&retryWriter{w: w, sleep: e.sleep})
 	rec, err := factory(counter, StartInfo{
 		Title: sess.info.Title, Cols: sess.info.Cols, Rows: sess.info.Rows,
 		Start: start, Format: format,
@@ -284,8 +364,11 @@ func (e *Engine) startSessionLocked(ctx context.Context, sess *recSession) error
 		Class: string(sess.capability.Class), Format: string(format), Authoritative: sess.capability.Authoritative,
 		Status: models.RecordingActive, Title: sess.info.Title, StartedAt: start,
 		StorageKey: storageKey, ExpiresAt: ExpiryFor(start, sess.info.Connection.RetentionDays, e.retention),
+		Client:             sess.info.Client,
+		ConnectionSnapshot: sess.info.ConnectionSnapshot,
+		InputCaptured:      e.capInput,
 	}
-	if err := e.store.Create(ctx, row); err != nil {
+	if err := e.persist(ctx, row, true); err != nil {
 		_ = rec.Close()
 		_ = w.Close()
 		_ = e.blobs.Delete(ctx, storageKey)
@@ -295,7 +378,7 @@ func (e *Engine) startSessionLocked(ctx context.Context, sess *recSession) error
 	lr := &liveRecording{
 		start: start, now: e.now, events: make(chan recEvent, e.bufEvents),
 		stop:         make(chan struct{}),
-		captureInput: false, // input (`i`) capture is sensitive and off by default
+		captureInput: e.capInput,
 	}
 	sess.ctx = context.WithoutCancel(ctx)
 	sess.rec = row
@@ -317,6 +400,74 @@ func (e *Engine) startSessionLocked(ctx context.Context, sess *recSession) error
 	return nil
 }
 
+// SetReadOnly pauses (on) or resumes metadata writes. While paused, recording
+// rows are held in memory up to maxDeferredWrites and the blobs keep streaming;
+// resuming flushes the held rows in order before writes go direct again.
+func (e *Engine) SetReadOnly(ctx context.Context, on bool) error {
+	if on {
+		e.deferMu.Lock()
+		e.readOnly = true
+		e.deferMu.Unlock()
+		return nil
+	}
+	for {
+		e.deferMu.Lock()
+		pending := e.deferred
+		e.deferred = nil
+		if len(pending) == 0 {
+			e.readOnly = false
+			e.deferMu.Unlock()
+			return nil
+		}
+		e.deferMu.Unlock()
+		for i := range pending {
+			if err := e.write(ctx, &pending[i].rec, pending[i].create); err != nil {
+				e.deferMu.Lock()
+				e.deferred = append(pending[i:], e.deferred...)
+				e.deferMu.Unlock()
+				return err
+			}
+		}
+	}
+}
+
+func (e *Engine) persist(ctx context.Context, r *models.Recording, create bool) error {
+	e.deferMu.Lock()
+	if e.readOnly {
+		defer e.deferMu.Unlock()
+		return e.deferLocked(*r, create)
+	}
+	e.deferMu.Unlock()
+	return e.write(ctx, r, create)
+}
+
+func (e *Engine) deferLocked(r models.Recording, create bool) error {
+	for i := range e.deferred {
+		if e.deferred[i].rec.ID == r.ID {
+			e.deferred[i].rec = r
+			return nil
+		}
+	}
+	if len(e.deferred) >= maxDeferredWrites {
+		return fmt.Errorf("%w: read-only mode: recording buffer is full", plugin.ErrUnavailable)
+	}
+	e.deferred = append(e.deferred, deferredWrite{rec: r, create: create})
+	return nil
+}
+
+func (e *Engine) finalized(r models.Recording) {
+	if e.onFinal != nil {
+		e.onFinal(r)
+	}
+}
+
+func (e *Engine) write(ctx context.Context, r *models.Recording, create bool) error {
+	if create {
+		return e.store.Create(ctx, r)
+	}
+	return e.store.Update(ctx, r)
+}
+
 func (e *Engine) auditRecording(ctx context.Context, sess *recSession, event string, result models.AuditResult, err error) {
 	e.audit.Record(ctx, audit.Event{
 		User: sess.info.User, Event: event, ConnectionID: sess.info.Connection.ID,
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"io"
+	"strings"
 	"sync"
+	"syscall"
 	"testing"
 	"time"
 
@@ -53,6 +55,7 @@ type fakeRecorder struct {
 	out   [][]byte
 	in    [][]byte
 	sizes []int
+	marks []string
 	ts    []time.Duration
 }
 
@@ -83,6 +86,13 @@ func (r *fakeRecorder) Resize(_ time.Duration, cols, rows int) error {
 	return nil
 }
 
+func (r *fakeRecorder) Marker(_ time.Duration, label string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.marks = append(r.marks, label)
+	return nil
+}
+
 func (r *fakeRecorder) Close() error { return nil }
 
 // failBlobs makes Create fail, to exercise forced-recording denial.
@@ -92,6 +102,41 @@ func (failBlobs) Create(context.Context, string) (io.WriteCloser, error) {
 	return nil, errors.New("disk full")
 }
 
+// flakyBlobs hands out writers that fail every write after the header with err;
+// a transient err clears after one failed attempt.
+type flakyBlobs struct {
+	BlobStore
+	err       error
+	transient bool
+}
+
+func (b flakyBlobs) Create(ctx context.Context, key string) (io.WriteCloser, error) {
+	w, err := b.BlobStore.Create(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &flakyWriter{WriteCloser: w, err: b.err, transient: b.transient}, nil
+}
+
+type flakyWriter struct {
+	io.WriteCloser
+	err       error
+	transient bool
+	writes    int
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes > 1 && w.err != nil {
+		err := w.err
+		if w.transient {
+			w.err = nil
+		}
+		return 0, err
+	}
+	return w.WriteCloser.Write(p)
+}
+
 // --- helpers ----------------------------------------------------------------
 
 type clock struct {
@@ -171,7 +216,10 @@ func TestEngineAutoRecordsOutputWithMonotonicTimestamps(t *testing.T) {
 	ctx := context.Background()
 	client := newFakeClient()
 
-	wrapped, finalize, err := e.Wrap(ctx, client, streamInfo("auto"))
+	info := streamInfo("auto")
+	info.Client = models.ClientInfo{IP: "203.0.113.5", UserAgent: "test"}
+	info.ConnectionSnapshot = &models.ConnectionSnapshot{Protocol: "p", Config: map[string]any{"host": "h"}}
+	wrapped, finalize, err := e.Wrap(ctx, client, info)
 	if err != nil {
 		t.Fatalf("wrap forced: %v", err)
 	}
@@ -212,6 +260,12 @@ func TestEngineAutoRecordsOutputWithMonotonicTimestamps(t *testing.T) {
 	if r.Class != "terminal" || r.Format != "asciicast_v2" {
 		t.Errorf("class/format: %s/%s", r.Class, r.Format)
 	}
+	if r.Client != info.Client {
+		t.Errorf("client: %+v", r.Client)
+	}
+	if r.ConnectionSnapshot == nil || r.ConnectionSnapshot.Config["host"] != "h" {
+		t.Errorf("connection snapshot: %+v", r.ConnectionSnapshot)
+	}
 }
 
 func TestEngineAutoTerminalSkipsIdleOpenAndResize(t *testing.T) {
@@ -495,3 +549,252 @@ func TestEngineRecordsResizeControlFrames(t *testing.T) {
 		}
 	}
 }
+
+func TestEngineLiveAnnotationMarksStreamAndPersists(t *testing.T) {
+	rec := &fakeRecorder{}
+	e, st := newEngine(t, nil, rec)
+	ctx := context.Background()
+	info := streamInfo("manual")
+	key := StreamKey(info.User.ID, info.Connection.ID, info.Route.ID, info.Params)
+	_, finalize, err := e.Wrap(ctx, newFakeClient(), info)
+	if err != nil {
+		t.Fatalf("wrap: %v", err)
+	}
+	row, err := e.Start(ctx, key)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	if _, err := e.Annotate(row.ID, "someone-else", "hi"); !errors.Is(err, plugin.ErrForbidden) {
+		t.Fatalf("non-participant: want ErrForbidden, got %v", err)
+	}
+	note, err := e.Annotate(row.ID, info.User.ID, "deploy starts")
+	if err != nil || !note.Live || note.Offset <= 0 {
+		t.Fatalf("annotate: %+v err=%v", note, err)
+	}
+	if live, ok := e.LiveAnnotations(row.ID); !ok || len(live) != 1 {
+		t.Fatalf("live annotations: %+v ok=%v", live, ok)
+	}
+	finalize()
+
+	if len(rec.marks) != 1 || rec.marks[0] != "deploy starts" {
+		t.Fatalf("marker not written to stream: %v", rec.marks)
+	}
+	got, _ := st.Recordings.Get(ctx, row.ID)
+	if len(got.Annotations) != 1 || got.Annotations[0].Text != "deploy starts" {
+		t.Fatalf("annotation not persisted on finalize: %+v", got.Annotations)
+	}
+	if _, err := e.Annotate(row.ID, info.User.ID, "late"); !errors.Is(err, plugin.ErrNotFound) {
+		t.Fatalf("finished recording: want ErrNotFound, got %v", err)
+	}
+}
+
+func TestEnginePauseSkipsContentBetweenMarkers(t *testing.T) {
+	rec := &fakeRecorder{}
+	e, _ := newEngine(t, nil, rec)
+	ctx := context.Background()
+	info := streamInfo("manual")
+	key := StreamKey(info.User.ID, info.Connection.ID, info.Route.ID, info.Params)
+	wrapped, finalize, err := e.Wrap(ctx, newFakeClient(), info)
+	if err != nil {
+		t.Fatalf("wrap: %v", err)
+	}
+	row, err := e.Start(ctx, key)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	if err := e.SetPaused(row.ID, "someone-else", true); !errors.Is(err, plugin.ErrForbidden) {
+		t.Fatalf("non-owner pause: want ErrForbidden, got %v", err)
+	}
+	_, _ = wrapped.Write([]byte("before"))
+	if err := e.SetPaused(row.ID, info.User.ID, true); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if err := e.SetPaused(row.ID, info.User.ID, true); err != nil {
+		t.Fatalf("repeat pause: %v", err)
+	}
+	_, _ = wrapped.Write([]byte("secret"))
+	if err := e.SetPaused(row.ID, info.User.ID, false); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	_, _ = wrapped.Write([]byte("after"))
+	finalize()
+
+	if len(rec.out) != 2 || string(rec.out[0]) != "before" || string(rec.out[1]) != "after" {
+		t.Fatalf("paused output leaked or lost: %q", rec.out)
+	}
+	if len(rec.marks) != 2 || rec.marks[0] != MarkerCapturePaused || rec.marks[1] != MarkerCaptureResumed {
+		t.Fatalf("boundary markers: %v", rec.marks)
+	}
+	if err := e.SetPaused(row.ID, info.User.ID, true); !errors.Is(err, plugin.ErrNotFound) {
+		t.Fatalf("finished recording: want ErrNotFound, got %v", err)
+	}
+}
+
+func TestEngineForcedRecordingCannotPause(t *testing.T) {
+	rec := &fakeRecorder{}
+	e, _ := newEngine(t, nil, rec)
+	ctx := context.Background()
+	client := newFakeClient()
+	info := streamInfo("auto")
+	wrapped, finalize, err := e.Wrap(ctx, client, info)
+	if err != nil {
+		t.Fatalf("wrap: %v", err)
+	}
+	defer finalize()
+	client.reads <- []byte("ls\r")
+	if _, err := wrapped.Read(make([]byte, 32)); err != nil {
+		t.Fatalf("arming input: %v", err)
+	}
+	key := StreamKey(info.User.ID, info.Connection.ID, info.Route.ID, info.Params)
+	row, err := e.Start(ctx, key)
+	if err != nil {
+		t.Fatalf("live row: %v", err)
+	}
+	if err := e.SetPaused(row.ID, info.User.ID, true); !errors.Is(err, plugin.ErrForbidden) {
+		t.Fatalf("forced pause: want ErrForbidden, got %v", err)
+	}
+}
+
+func TestEngineInputRedaction(t *testing.T) {
+	for _, capture := range []bool{false, true} {
+		rec := &fakeRecorder{}
+		e, st := newEngine(t, nil, rec)
+		e.capInput = capture
+		ctx := context.Background()
+		client := newFakeClient()
+		wrapped, finalize, err := e.Wrap(ctx, client, streamInfo("auto"))
+		if err != nil {
+			t.Fatalf("wrap: %v", err)
+		}
+		client.reads <- []byte("hunter2\r")
+		if _, err := wrapped.Read(make([]byte, 32)); err != nil {
+			t.Fatalf("input: %v", err)
+		}
+		finalize()
+
+		if got := len(rec.in) > 0; got != capture {
+			t.Fatalf("capture=%v: input recorded=%v (%q)", capture, got, rec.in)
+		}
+		rows, _ := st.Recordings.List(ctx, store.RecordingFilter{})
+		if len(rows) != 1 || rows[0].InputCaptured != capture {
+			t.Fatalf("capture=%v: persisted flag: %+v", capture, rows)
+		}
+	}
+}
+
+func TestEngineReadOnlyDefersMetadataUntilResume(t *testing.T) {
+	rec := &fakeRecorder{}
+	e, st := newEngine(t, nil, rec)
+	ctx := context.Background()
+	if err := e.SetReadOnly(ctx, true); err != nil {
+		t.Fatalf("read-only: %v", err)
+	}
+	client := newFakeClient()
+	wrapped, finalize, err := e.Wrap(ctx, client, streamInfo("auto"))
+	if err != nil {
+		t.Fatalf("wrap: %v", err)
+	}
+	client.reads <- []byte("ls\r")
+	if _, err := wrapped.Read(make([]byte, 32)); err != nil {
+		t.Fatalf("recording should start in read-only mode: %v", err)
+	}
+	_, _ = wrapped.Write([]byte("out\n"))
+	finalize()
+
+	if recs, _ := st.Recordings.List(ctx, store.RecordingFilter{}); len(recs) != 0 {
+		t.Fatalf("read-only mode wrote %d recording rows", len(recs))
+	}
+	if err := e.SetReadOnly(ctx, false); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	recs, _ := st.Recordings.List(ctx, store.RecordingFilter{})
+	if len(recs) != 1 || recs[0].Status != models.RecordingFinalized || recs[0].Size == 0 {
+		t.Fatalf("deferred recording not flushed as finalized: %+v", recs)
+	}
+}
+
+func TestEngineWriteFailureKeepsPartialRecording(t *testing.T) {
+	for _, failClosed := range []bool{false, true} {
+		local, _ := NewLocalBlobStore(t.TempDir())
+		e, st := newEngine(t, flakyBlobs{BlobStore: local, err: syscall.ENOSPC}, &fakeRecorder{})
+		e.Register(plugin.FormatAsciicastV2, NewAsciicastRecorder)
+		failed := make(chan error, 1)
+		e.onFail = func(_ models.Recording, err error) { failed <- err }
+		ctx := context.Background()
+		info := streamInfo("auto")
+		info.Connection.RecordingFailClosed = failClosed
+		client := newFakeClient()
+		wrapped, finalize, err := e.Wrap(ctx, client, info)
+		if err != nil {
+			t.Fatalf("wrap: %v", err)
+		}
+		client.reads <- []byte("ls\r")
+		if _, err := wrapped.Read(make([]byte, 32)); err != nil {
+			t.Fatalf("first input: %v", err)
+		}
+		if _, err := wrapped.Write([]byte("out\n")); err != nil {
+			t.Fatalf("write before failure: %v", err)
+		}
+		select {
+		case err := <-failed:
+			if !errors.Is(err, syscall.ENOSPC) {
+				t.Fatalf("failure hook error: %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("write failure was not reported")
+		}
+
+		_, err = wrapped.Write([]byte("more\n"))
+		if failClosed {
+			if !errors.Is(err, plugin.ErrUnavailable) || client.ctx.Err() == nil {
+				t.Fatalf("fail-closed stream should end: err=%v", err)
+			}
+		} else if err != nil {
+			t.Fatalf("stream should outlive its recording: %v", err)
+		}
+		finalize()
+
+		recs, _ := st.Recordings.List(ctx, store.RecordingFilter{})
+		if len(recs) != 1 || recs[0].Status != models.RecordingFinalized || !recs[0].Partial || !strings.Contains(recs[0].Error, "no space") {
+			t.Fatalf("failClosed=%v: want a partial finalized row, got %+v", failClosed, recs)
+		}
+	}
+}
+
+func TestEngineRetriesTransientWriteErrors(t *testing.T) {
+	local, _ := NewLocalBlobStore(t.TempDir())
+	e, st := newEngine(t, flakyBlobs{BlobStore: local, err: syscall.EAGAIN, transient: true}, &fakeRecorder{})
+	e.Register(plugin.FormatAsciicastV2, NewAsciicastRecorder)
+	var slept []time.Duration
+	e.sleep = func(d time.Duration) { slept = append(slept, d) }
+	ctx := context.Background()
+	client := newFakeClient()
+	wrapped, finalize, err := e.Wrap(ctx, client, streamInfo("auto"))
+	if err != nil {
+		t.Fatalf("wrap: %v", err)
+	}
+	client.reads <- []byte("ls\r")
+	if _, err := wrapped.Read(make([]byte, 32)); err != nil {
+		t.Fatalf("first input: %v", err)
+	}
+	_, _ = wrapped.Write([]byte("out\n"))
+	finalize()
+
+	recs, _ := st.Recordings.List(ctx, store.RecordingFilter{})
+	if len(recs) != 1 || recs[0].Status != models.RecordingFinalized || recs[0].Partial {
+		t.Fatalf("retried recording should finalize whole: %+v", recs)
+	}
+	if len(slept) != 1 || slept[0] != writeRetryBackoff {
+		t.Fatalf("want one backoff of %s, got %v", writeRetryBackoff, slept)
+	}
+	rc, err := local.Open(ctx, recs[0].StorageKey)
+	if err != nil {
+		t.Fatalf("open blob: %v", err)
+	}
+	defer func() { _ = rc.Close() }()
+	if data, _ := io.ReadAll(rc); !strings.Contains(string(data), `"out\n"`) {
+		t.Fatalf("retried output missing from blob: %s", data)
+	}
+}
@@ -7,6 +7,7 @@ type Metrics interface {
 	RecordingFinished()
 	AddRecordingBytes(n int)
 	RecordingFailed()
+	RecordingWriteFailed()
 }
 
 type noopMetrics struct{}
@@ -15,3 +16,4 @@ func (noopMetrics) RecordingStarted()     {}
 func (noopMetrics) RecordingFinished()    {}
 func (noopMetrics) AddRecordingBytes(int) {}
 func (noopMetrics) RecordingFailed()      {}
+func (noopMetrics) RecordingWriteFailed() {}
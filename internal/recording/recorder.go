@@ -3,8 +3,11 @@ package recording
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"hash"
 	"io"
+	"net"
+	"syscall"
 	"time"
 
 	"github.com/charlesng35/shellcn/sdk/plugin"
@@ -27,6 +30,7 @@ type Recorder interface {
 	WriteOutput(ts time.Duration, p []byte) error
 	WriteInput(ts time.Duration, p []byte) error
 	Resize(ts time.Duration, cols, rows int) error
+	Marker(ts time.Duration, label string) error
 	Close() error
 }
 
@@ -54,3 +58,39 @@ func (c *countingWriter) Write(p []byte) (int, error) {
 }
 
 func (c *countingWriter) checksum() string { return hex.EncodeToString(c.h.Sum(nil)) }
+
+// Blob write retry bounds: a transient error is retried writeRetries times,
+// doubling the pause from writeRetryBackoff.
+const (
+	writeRetries      = 3
+	writeRetryBackoff = 50 * time.Millisecond
+)
+
+// retryWriter retries transient blob write errors with backoff, resuming after
+// the bytes that did land. It sits under the recorder, whose own errors stick.
+type retryWriter struct {
+	w     io.Writer
+	sleep func(time.Duration)
+}
+
+func (r *retryWriter) Write(p []byte) (int, error) {
+	written := 0
+	for attempt := 0; ; attempt++ {
+		n, err := r.w.Write(p[written:])
+		written += n
+		if err == nil || attempt == writeRetries || !transientWriteError(err) {
+			return written, err
+		}
+		r.sleep(writeRetryBackoff << attempt)
+	}
+}
+
+// transientWriteError reports errors worth retrying: a busy file descriptor or
+// a network timeout from a remote blob store.
+func transientWriteError(err error) bool {
+	if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
@@ -0,0 +1,97 @@
+package recording
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// CastHeader is the part of an asciicast v2 header a player needs.
+type CastHeader struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Title  string `json:"title,omitempty"`
+	// Timestamp is the Unix time capture started, when the writer recorded it.
+	Timestamp int64 `json:"timestamp,omitempty"`
+}
+
+// CastEvent is one asciicast v2 event: Time seconds into the recording, Code
+// "o", "i", "r" or "m" and its Data.
+type CastEvent struct {
+	Time float64 `json:"t"`
+	Code string  `json:"code"`
+	Data string  `json:"data"`
+}
+
+// CastReader streams the events of an asciicast v2 recording one line at a
+// time, so replaying a multi-hour session holds only the current event in
+// memory. A gzip-compressed cast is decompressed as it is read.
+type CastReader struct {
+	Header CastHeader
+	sc     *bufio.Scanner
+	zr     *gzip.Reader
+}
+
+// NewCastReader reads and validates the header of r.
+func NewCastReader(r io.Reader) (*CastReader, error) {
+	br := bufio.NewReader(r)
+	c := &CastReader{}
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		c.zr = zr
+		r = zr
+	} else {
+		r = br
+	}
+	c.sc = bufio.NewScanner(r)
+	c.sc.Buffer(make([]byte, 64*1024), 16<<20)
+	if !c.sc.Scan() {
+		if err := c.sc.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("recording: empty asciicast")
+	}
+	var header struct {
+		Version int `json:"version"`
+		CastHeader
+	}
+	if err := json.Unmarshal(c.sc.Bytes(), &header); err != nil || header.Version != 2 {
+		return nil, fmt.Errorf("recording: not an asciicast v2 stream")
+	}
+	c.Header = header.CastHeader
+	return c, nil
+}
+
+// Next returns the next event, or io.EOF after the last one.
+func (c *CastReader) Next() (CastEvent, error) {
+	for c.sc.Scan() {
+		var ev [3]any
+		if err := json.Unmarshal(c.sc.Bytes(), &ev); err != nil {
+			continue // a torn final line from an abrupt stop
+		}
+		ts, ok := ev[0].(float64)
+		code, _ := ev[1].(string)
+		data, _ := ev[2].(string)
+		if !ok || code == "" {
+			continue
+		}
+		return CastEvent{Time: ts, Code: code, Data: data}, nil
+	}
+	if err := c.sc.Err(); err != nil {
+		return CastEvent{}, err
+	}
+	return CastEvent{}, io.EOF
+}
+
+// Close releases the decompressor; the underlying reader is the caller's.
+func (c *CastReader) Close() error {
+	if c.zr != nil {
+		return c.zr.Close()
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package recording
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCastReaderStreamsPlainAndGzipCasts(t *testing.T) {
+	cast := strings.Join([]string{
+		`{"version":2,"width":120,"height":40,"title":"deploy"}`,
+		`[0.1,"o","$ ls\r\n"]`,
+		`[0.4,"r","100x30"]`,
+		`[1.5,"o","done"]`,
+		`[1.6,"o",`, // torn final line
+	}, "\n")
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write([]byte(cast))
+	_ = zw.Close()
+
+	for name, r := range map[string]io.Reader{"plain": strings.NewReader(cast), "gzip": &gz} {
+		c, err := NewCastReader(r)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if c.Header != (CastHeader{Width: 120, Height: 40, Title: "deploy"}) {
+			t.Fatalf("%s header = %+v", name, c.Header)
+		}
+		var got []CastEvent
+		for {
+			ev, err := c.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			got = append(got, ev)
+		}
+		if len(got) != 3 || got[1] != (CastEvent{Time: 0.4, Code: "r", Data: "100x30"}) || got[2].Time != 1.5 {
+			t.Fatalf("%s events = %+v", name, got)
+		}
+		_ = c.Close()
+	}
+
+	if _, err := NewCastReader(strings.NewReader("not a cast\n")); err == nil {
+		t.Fatal("non-asciicast input should fail")
+	}
+}
@@ -2,6 +2,7 @@ package recording
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"sync"
 	"sync/atomic"
@@ -21,6 +22,7 @@ type recSession struct {
 	info       StreamInfo
 	capability plugin.RecordingCapability
 	forced     bool
+	failClosed bool // end the stream once the recording can no longer be written
 	tap        *tap
 
 	ctx       context.Context
@@ -30,6 +32,7 @@ type recSession struct {
 	counter   *countingWriter
 	lr        *liveRecording
 	drainDone chan struct{}
+	notes     []models.RecordingAnnotation
 
 	preStartOutput      [][]byte
 	preStartOutputBytes int
@@ -50,6 +53,9 @@ func (s *recSession) drain(metrics Metrics) {
 		}
 	}
 	write := func(ev recEvent) {
+		if s.lr.writeFailure() != nil {
+			return
+		}
 		var err error
 		switch ev.kind {
 		case 'o':
@@ -58,9 +64,11 @@ func (s *recSession) drain(metrics Metrics) {
 			err = s.recorder.WriteInput(ev.ts, ev.data)
 		case 'r':
 			err = s.recorder.Resize(ev.ts, ev.cols, ev.rows)
+		case 'm':
+			err = s.recorder.Marker(ev.ts, string(ev.data))
 		}
-		if err != nil {
-			s.lr.failed.Store(true)
+		if err != nil && s.lr.stopWriting(err) {
+			s.writeFailed(err)
 		}
 		report()
 	}
@@ -82,8 +90,21 @@ func (s *recSession) drain(metrics Metrics) {
 	}
 }
 
+// writeFailed reports a recording that stopped capturing mid-stream. It runs on
+// the drain goroutine, which owns s.rec until finish.
+func (s *recSession) writeFailed(err error) {
+	e := s.engine
+	e.metrics.RecordingWriteFailed()
+	e.auditRecording(s.ctx, s, EventWriteFailed, models.AuditError, err)
+	if e.onFail != nil {
+		e.onFail(*s.rec, err)
+	}
+}
+
 // finish stops draining, closes the recorder + blob, and persists the final
-// metadata exactly once. A failed capture is recorded as RecordingFailed.
+// metadata exactly once. A capture cut short by a write error keeps what was
+// written and is marked Partial; any other failure is recorded as
+// RecordingFailed.
 func (s *recSession) finish(status models.RecordingStatus) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -113,25 +134,34 @@ func (s *recSession) finishLocked(status models.RecordingStatus) {
 	s.rec.DurationMS = end.Sub(s.rec.StartedAt).Milliseconds()
 	s.rec.Size = s.counter.n
 	s.rec.Checksum = s.counter.checksum()
+	s.rec.Annotations = append(s.rec.Annotations, s.notes...)
 	event := EventFinalize
-	if s.lr.failed.Load() {
+	if werr := s.lr.writeFailure(); werr != nil {
+		s.rec.Status = status
+		s.rec.Partial = true
+		s.rec.Error = "recording stopped early: " + werr.Error()
+		event = EventFailed
+	} else if s.lr.failed.Load() {
 		s.rec.Status = models.RecordingFailed
 		s.rec.Error = "capture incomplete (recorder error or buffer overflow)"
 		event = EventFailed
 	} else {
 		s.rec.Status = status
 	}
-	updateErr := s.engine.store.Update(s.ctx, s.rec)
+	updateErr := s.engine.persist(s.ctx, s.rec, false)
 	s.engine.metrics.RecordingFinished()
 	if updateErr != nil {
 		s.engine.metrics.RecordingFailed()
 		s.engine.auditRecording(s.ctx, s, EventFailed, models.AuditError, updateErr)
-	} else if s.rec.Status == models.RecordingFailed {
+	} else if s.rec.Status == models.RecordingFailed || s.rec.Partial {
 		s.engine.metrics.RecordingFailed()
 		s.engine.auditRecording(s.ctx, s, event, models.AuditError, nil)
 	} else {
 		s.engine.auditRecording(s.ctx, s, event, models.AuditAllowed, nil)
 	}
+	if updateErr == nil {
+		s.engine.finalized(*s.rec)
+	}
 }
 
 func (s *recSession) shouldStartOnInteraction() bool {
@@ -211,3 +241,100 @@ func (e *Engine) Resize(key string, cols, rows int) {
 	}
 	sess.lr.resize(cols, rows)
 }
+
+// Annotate pins a note to the current offset of a live recording, writing it
+// into the stream as a marker and keeping it for the finalized metadata. Only
+// the user driving the stream may annotate live.
+func (e *Engine) Annotate(recordingID, userID, text string) (models.RecordingAnnotation, error) {
+	sess := e.liveByRecording(recordingID)
+	if sess == nil {
+		return models.RecordingAnnotation{}, plugin.ErrNotFound
+	}
+	if sess.info.User.ID != userID {
+		return models.RecordingAnnotation{}, plugin.ErrForbidden
+	}
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if !sess.live.Load() || sess.rec.ID != recordingID {
+		return models.RecordingAnnotation{}, plugin.ErrNotFound
+	}
+	ts := sess.lr.marker(text)
+	note := models.RecordingAnnotation{Offset: ts.Seconds(), Text: text, UserID: userID, Live: true, At: e.now()}
+	sess.notes = append(sess.notes, note)
+	return note, nil
+}
+
+// Capture markers written at pause boundaries so a replay shows the gap.
+const (
+	MarkerCapturePaused  = "capture paused"
+	MarkerCaptureResumed = "capture resumed"
+)
+
+// SetPaused pauses or resumes capture of a live recording's stream content,
+// writing a marker at the boundary. Only the user driving the stream may do
+// this, and forced recordings cannot be paused. Changing to the current state
+// is a no-op.
+func (e *Engine) SetPaused(recordingID, userID string, paused bool) error {
+	sess := e.liveByRecording(recordingID)
+	if sess == nil {
+		return plugin.ErrNotFound
+	}
+	if sess.info.User.ID != userID {
+		return plugin.ErrForbidden
+	}
+	if sess.forced {
+		return fmt.Errorf("%w: forced recording cannot be paused", plugin.ErrForbidden)
+	}
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if !sess.live.Load() || sess.rec.ID != recordingID {
+		return plugin.ErrNotFound
+	}
+	if sess.lr.paused.Load() == paused {
+		return nil
+	}
+	if paused {
+		sess.lr.marker(MarkerCapturePaused)
+		sess.lr.paused.Store(true)
+		return nil
+	}
+	sess.lr.paused.Store(false)
+	sess.lr.marker(MarkerCaptureResumed)
+	return nil
+}
+
+// LiveAnnotations returns the annotations a live recording has collected so far
+// (not yet persisted); ok is false when the recording is not live.
+func (e *Engine) LiveAnnotations(recordingID string) ([]models.RecordingAnnotation, bool) {
+	sess := e.liveByRecording(recordingID)
+	if sess == nil {
+		return nil, false
+	}
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if !sess.live.Load() || sess.rec.ID != recordingID {
+		return nil, false
+	}
+	return append([]models.RecordingAnnotation(nil), sess.notes...), true
+}
+
+func (e *Engine) liveByRecording(recordingID string) *recSession {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	sessions := make([]*recSession, 0, len(e.active))
+	for _, sess := range e.active {
+		sessions = append(sessions, sess)
+	}
+	e.mu.Unlock()
+	for _, sess := range sessions {
+		sess.mu.Lock()
+		match := sess.live.Load() && sess.rec.ID == recordingID
+		sess.mu.Unlock()
+		if match {
+			return sess
+		}
+	}
+	return nil
+}
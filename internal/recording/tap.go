@@ -11,7 +11,7 @@ import (
 
 // recEvent is one timestamped stream event queued for the drain loop.
 type recEvent struct {
-	kind       byte // 'o' output, 'i' input, 'r' resize
+	kind       byte // 'o' output, 'i' input, 'r' resize, 'm' marker
 	ts         time.Duration
 	data       []byte
 	cols, rows int
@@ -27,12 +27,35 @@ type liveRecording struct {
 	events       chan recEvent
 	stop         chan struct{} // closed by finish; never close events (avoids send-on-closed)
 	captureInput bool
+	paused       atomic.Bool // owner-paused: stream content is skipped, resizes still land
 	failed       atomic.Bool
 	dropped      atomic.Int64
+	writeErr     atomic.Pointer[error] // the write error that stopped capture
+}
+
+// stopWriting records the error that ended capture and reports whether it was
+// the first; events after it are dropped.
+func (lr *liveRecording) stopWriting(err error) bool {
+	return lr.writeErr.CompareAndSwap(nil, &err)
+}
+
+func (lr *liveRecording) writeFailure() error {
+	if p := lr.writeErr.Load(); p != nil {
+		return *p
+	}
+	return nil
 }
 
 func (lr *liveRecording) enqueue(ev recEvent) {
-	ev.ts = max(lr.now().Sub(lr.start), 0)
+	ev.ts = lr.elapsed()
+	lr.push(ev)
+}
+
+func (lr *liveRecording) elapsed() time.Duration {
+	return max(lr.now().Sub(lr.start), 0)
+}
+
+func (lr *liveRecording) push(ev recEvent) {
 	select {
 	case <-lr.stop:
 		return
@@ -48,10 +71,16 @@ func (lr *liveRecording) enqueue(ev recEvent) {
 }
 
 func (lr *liveRecording) output(p []byte) {
+	if lr.paused.Load() {
+		return
+	}
 	lr.enqueue(recEvent{kind: 'o', data: append([]byte(nil), p...)})
 }
 
 func (lr *liveRecording) input(p []byte) {
+	if lr.paused.Load() {
+		return
+	}
 	lr.enqueue(recEvent{kind: 'i', data: append([]byte(nil), p...)})
 }
 
@@ -59,6 +88,13 @@ func (lr *liveRecording) resize(cols, rows int) {
 	lr.enqueue(recEvent{kind: 'r', cols: cols, rows: rows})
 }
 
+// marker queues an annotation label and returns the offset it was stamped at.
+func (lr *liveRecording) marker(label string) time.Duration {
+	ts := lr.elapsed()
+	lr.push(recEvent{kind: 'm', ts: ts, data: []byte(label)})
+	return ts
+}
+
 // tap wraps a ClientStream and mirrors its traffic to the active recording (if
 // any). client.Write carries upstream→browser output; client.Read carries
 // browser→upstream input. With no active recording the tap is a passthrough.
@@ -70,6 +106,9 @@ type tap struct {
 
 func (t *tap) Read(p []byte) (int, error) {
 	n, err := t.inner.Read(p)
+	if lost := t.recordingLost(); lost != nil {
+		return 0, lost
+	}
 	if n > 0 {
 		frame := p[:n]
 		if terminalUserInput(frame) {
@@ -101,6 +140,9 @@ func resizeControl(frame []byte) (cols, rows int, ok bool) {
 
 func (t *tap) Write(p []byte) (int, error) {
 	if lr := t.live.Load(); lr != nil {
+		if lost := t.recordingLost(); lost != nil {
+			return 0, lost
+		}
 		lr.output(p)
 		return t.inner.Write(p)
 	}
@@ -115,6 +157,21 @@ func (t *tap) Context() context.Context { return t.inner.Context() }
 
 func (t *tap) Close() error { return t.inner.Close() }
 
+// recordingLost closes a fail-closed stream once its recording has stopped
+// writing, and returns the error that ends it.
+func (t *tap) recordingLost() error {
+	lr := t.live.Load()
+	if lr == nil || t.sess == nil || !t.sess.failClosed {
+		return nil
+	}
+	werr := lr.writeFailure()
+	if werr == nil {
+		return nil
+	}
+	_ = t.inner.Close()
+	return fmt.Errorf("%w: session recording failed: %v", plugin.ErrUnavailable, werr)
+}
+
 func (t *tap) startFromInteraction() error {
 	if t.sess == nil || !t.sess.shouldStartOnInteraction() {
 		return nil
@@ -0,0 +1,241 @@
+package recording
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// TranscriptLine is one line of terminal output with the offset, in seconds,
+// of the output that started it.
+type TranscriptLine struct {
+	Offset float64 `json:"offset"`
+	Text   string  `json:"text"`
+}
+
+// Transcript is the plain text of a terminal recording's output.
+type Transcript struct {
+	Lines []TranscriptLine `json:"lines"`
+}
+
+// TranscriptKey is the side blob a recording's transcript is stored under.
+func TranscriptKey(storageKey string) string {
+	return storageKey + ".transcript.json.gz"
+}
+
+// ExtractTranscript rebuilds plain text lines from an asciicast v2 stream.
+// Escape sequences are dropped, a carriage return before more text restarts
+// the line and backspaces erase; input, resize and marker events are ignored.
+func ExtractTranscript(r io.Reader) (Transcript, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return Transcript{}, err
+		}
+		return Transcript{}, fmt.Errorf("recording: empty asciicast")
+	}
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(sc.Bytes(), &header); err != nil || header.Version != 2 {
+		return Transcript{}, fmt.Errorf("recording: not an asciicast v2 stream")
+	}
+
+	b := transcriptBuilder{}
+	for sc.Scan() {
+		var ev [3]any
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			continue // a torn final line from an abrupt stop
+		}
+		ts, _ := ev[0].(float64)
+		code, _ := ev[1].(string)
+		data, _ := ev[2].(string)
+		if code == "o" {
+			b.write(ts, data)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return Transcript{}, err
+	}
+	b.flush()
+	return Transcript{Lines: b.lines}, nil
+}
+
+type escState int
+
+const (
+	escNone escState = iota
+	escStart
+	escCSI
+	escOSC
+	escOSCEsc
+)
+
+type transcriptBuilder struct {
+	lines   []TranscriptLine
+	cur     []rune
+	started float64
+	open    bool
+	cr      bool // a carriage return not yet followed by a newline
+	esc     escState
+}
+
+func (b *transcriptBuilder) write(ts float64, data string) {
+	for _, r := range data {
+		switch b.esc {
+		case escStart:
+			switch r {
+			case '[':
+				b.esc = escCSI
+			case ']':
+				b.esc = escOSC
+			default:
+				b.esc = escNone
+			}
+			continue
+		case escCSI:
+			if r >= 0x40 && r <= 0x7e {
+				b.esc = escNone
+			}
+			continue
+		case escOSC:
+			switch r {
+			case 0x07:
+				b.esc = escNone
+			case 0x1b:
+				b.esc = escOSCEsc
+			}
+			continue
+		case escOSCEsc:
+			b.esc = escNone
+			continue
+		}
+		switch {
+		case r == 0x1b:
+			b.esc = escStart
+		case r == '\n':
+			b.flush()
+		case r == '\r':
+			b.cr = true
+		case r == '\b':
+			if len(b.cur) > 0 {
+				b.cur = b.cur[:len(b.cur)-1]
+			}
+		case r == '\t':
+			b.add(ts, ' ')
+		case r < 0x20 || r == 0x7f:
+		default:
+			b.add(ts, r)
+		}
+	}
+}
+
+func (b *transcriptBuilder) add(ts float64, r rune) {
+	if b.cr {
+		b.cur, b.cr = b.cur[:0], false
+	}
+	if !b.open {
+		b.open, b.started = true, ts
+	}
+	b.cur = append(b.cur, r)
+}
+
+func (b *transcriptBuilder) flush() {
+	if b.open {
+		if text := strings.TrimRight(string(b.cur), " "); text != "" {
+			b.lines = append(b.lines, TranscriptLine{Offset: b.started, Text: text})
+		}
+	}
+	b.cur, b.open, b.cr = b.cur[:0], false, false
+}
+
+// WriteTranscript stores t gzip-compressed under key.
+func WriteTranscript(ctx context.Context, blobs BlobStore, key string, t Transcript) error {
+	w, err := blobs.Create(ctx, key)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(t); err != nil {
+		_ = w.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// ReadTranscript loads a transcript stored by WriteTranscript.
+func ReadTranscript(ctx context.Context, blobs BlobStore, key string) (Transcript, error) {
+	rc, err := blobs.Open(ctx, key)
+	if err != nil {
+		return Transcript{}, err
+	}
+	defer func() { _ = rc.Close() }()
+	zr, err := gzip.NewReader(rc)
+	if err != nil {
+		return Transcript{}, err
+	}
+	var t Transcript
+	if err := json.NewDecoder(zr).Decode(&t); err != nil {
+		return Transcript{}, err
+	}
+	return t, nil
+}
+
+// TranscriptMatch is one transcript line containing a search term.
+type TranscriptMatch struct {
+	Offset  float64 `json:"offset"`
+	Snippet string  `json:"snippet"`
+}
+
+const snippetRadius = 60
+
+// Search returns up to limit lines containing q, ignoring case, each cut to a
+// snippet around the first hit.
+func (t Transcript) Search(q string, limit int) []TranscriptMatch {
+	needle := strings.ToLower(q)
+	if needle == "" {
+		return nil
+	}
+	var out []TranscriptMatch
+	for _, l := range t.Lines {
+		i := strings.Index(strings.ToLower(l.Text), needle)
+		if i < 0 {
+			continue
+		}
+		out = append(out, TranscriptMatch{Offset: l.Offset, Snippet: snippet(l.Text, min(i, len(l.Text)), len(needle))})
+		if len(out) == limit {
+			break
+		}
+	}
+	return out
+}
+
+// snippet cuts text to snippetRadius bytes either side of [i, i+n), on rune
+// boundaries, marking cuts with an ellipsis.
+func snippet(text string, i, n int) string {
+	start, end := max(i-snippetRadius, 0), min(i+n+snippetRadius, len(text))
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+	s := text[start:end]
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(text) {
+		s += "…"
+	}
+	return s
+}
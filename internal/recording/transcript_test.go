@@ -0,0 +1,67 @@
+package recording
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExtractTranscript(t *testing.T) {
+	cast := strings.Join([]string{
+		`{"version":2,"width":80,"height":24}`,
+		`[0.1,"o","\u001b[1;32m$\u001b[0m rm -rf /tmp/x\r\n"]`,
+		`[0.2,"i","secret typed input"]`,
+		`[0.5,"o","\u001b]0;title\u0007progress 10%\rprogress 100%\r\n"]`,
+		`[0.7,"o","typo\b\bpe\r\n$ "]`,
+		`[0.9,"m","marker"]`,
+		`[1.0,"o","exit"]`,
+		`[1.1,"o",`, // torn final line
+	}, "\n")
+	tr, err := ExtractTranscript(strings.NewReader(cast))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TranscriptLine{{0.1, "$ rm -rf /tmp/x"}, {0.5, "progress 100%"}, {0.7, "type"}, {0.7, "$ exit"}}
+	if len(tr.Lines) != len(want) {
+		t.Fatalf("lines = %+v", tr.Lines)
+	}
+	for i := range want {
+		if tr.Lines[i] != want[i] {
+			t.Fatalf("line %d = %+v, want %+v", i, tr.Lines[i], want[i])
+		}
+	}
+
+	matches := tr.Search("RM -RF", 5)
+	if len(matches) != 1 || matches[0].Offset != 0.1 || matches[0].Snippet != "$ rm -rf /tmp/x" {
+		t.Fatalf("matches = %+v", matches)
+	}
+	if _, err := ExtractTranscript(strings.NewReader("not a cast\n")); err == nil {
+		t.Fatal("non-asciicast input should fail")
+	}
+}
+
+func TestTranscriptRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	blobs, err := NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open("testdata/sample.cast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	tr, err := ExtractTranscript(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := TranscriptKey("c1/r1.cast")
+	if err := WriteTranscript(ctx, blobs, key, tr); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadTranscript(ctx, blobs, key)
+	if err != nil || len(got.Lines) != len(tr.Lines) || got.Lines[0].Text != "$ echo hi" {
+		t.Fatalf("round trip: %+v %v", got, err)
+	}
+}
@@ -0,0 +1,72 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+)
+
+// SelfTestSetting is the system setting holding an encrypted known value, so a
+// master key can be checked against the data it must decrypt.
+const SelfTestSetting = "secrets.self_test"
+
+const selfTestPlaintext = "shellcn-vault-self-test"
+
+// NewSelfTest encrypts the known value for storage under SelfTestSetting.
+func NewSelfTest(ctx context.Context, store SecretStore) (string, error) {
+	blob, err := store.Encrypt(ctx, []byte(selfTestPlaintext))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(blob), nil
+}
+
+// VerifySelfTest reports whether store decrypts a value from NewSelfTest.
+func VerifySelfTest(ctx context.Context, store SecretStore, value string) error {
+	blob, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return fmt.Errorf("%w: self-test value is not base64", ErrCiphertext)
+	}
+	plain, err := store.Decrypt(ctx, blob)
+	if err != nil {
+		return err
+	}
+	if string(plain) != selfTestPlaintext {
+		return fmt.Errorf("%w: self-test value does not match", ErrCiphertext)
+	}
+	return nil
+}
+
+// VaultStatus is the outcome of CheckVault.
+type VaultStatus string
+
+const (
+	VaultCryptoOK VaultStatus = "crypto_ok"
+	// VaultKeyMissing means no master key was configured, so secrets are
+	// sealed under a throwaway key.
+	VaultKeyMissing VaultStatus = "key_missing"
+	// VaultKeyMismatch means the key cannot decrypt the stored self-test
+	// value: it is not the key existing secrets were written under.
+	VaultKeyMismatch VaultStatus = "key_mismatch"
+	// VaultCryptoError means a fresh value did not survive a round trip.
+	VaultCryptoError VaultStatus = "crypto_error"
+)
+
+// CheckVault round-trips a fresh value through store, then decrypts the
+// stored self-test value when there is one.
+func CheckVault(ctx context.Context, store SecretStore, stored string) (VaultStatus, error) {
+	fresh, err := NewSelfTest(ctx, store)
+	if err == nil {
+		err = VerifySelfTest(ctx, store, fresh)
+	}
+	if err != nil {
+		return VaultCryptoError, err
+	}
+	if stored == "" {
+		return VaultCryptoOK, nil
+	}
+	if err := VerifySelfTest(ctx, store, stored); err != nil {
+		return VaultKeyMismatch, err
+	}
+	return VaultCryptoOK, nil
+}
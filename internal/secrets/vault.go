@@ -91,23 +91,9 @@ func (v *Vault) Encrypt(_ context.Context, plaintext []byte) ([]byte, error) {
 
 // Decrypt opens an envelope blob produced by Encrypt.
 func (v *Vault) Decrypt(_ context.Context, blob []byte) ([]byte, error) {
-	kekNonceSize := v.kek.NonceSize()
-	wrappedDEKSize := dekSize + v.kek.Overhead()
-	// version + kekNonce + wrappedDEK + at least a dekNonce.
-	minLen := 1 + kekNonceSize + wrappedDEKSize + 12
-	if len(blob) < minLen || blob[0] != vaultVersion {
-		return nil, ErrCiphertext
-	}
-
-	off := 1
-	kekNonce := blob[off : off+kekNonceSize]
-	off += kekNonceSize
-	wrappedDEK := blob[off : off+wrappedDEKSize]
-	off += wrappedDEKSize
-
-	dek, err := v.kek.Open(nil, kekNonce, wrappedDEK, nil)
+	dek, off, err := v.unwrapDEK(blob)
 	if err != nil {
-		return nil, fmt.Errorf("%w: unwrap data key: %v", ErrCiphertext, err)
+		return nil, err
 	}
 	dekAEAD, err := newGCM(dek)
 	if err != nil {
@@ -128,3 +114,30 @@ func (v *Vault) Decrypt(_ context.Context, blob []byte) ([]byte, error) {
 	}
 	return plaintext, nil
 }
+
+// unwrapDEK validates the envelope header and opens the wrapped data key,
+// returning it with the offset of the payload section (dekNonce | ciphertext).
+func (v *Vault) unwrapDEK(blob []byte) ([]byte, int, error) {
+	kekNonceSize := v.kek.NonceSize()
+	wrappedDEKSize := dekSize + v.kek.Overhead()
+	// version + kekNonce + wrappedDEK + at least a dekNonce.
+	minLen := 1 + kekNonceSize + wrappedDEKSize + 12
+	if len(blob) < minLen {
+		return nil, 0, ErrCiphertext
+	}
+	if blob[0] != vaultVersion {
+		return nil, 0, fmt.Errorf("%w: unsupported format version %d", ErrCiphertext, blob[0])
+	}
+
+	off := 1
+	kekNonce := blob[off : off+kekNonceSize]
+	off += kekNonceSize
+	wrappedDEK := blob[off : off+wrappedDEKSize]
+	off += wrappedDEKSize
+
+	dek, err := v.kek.Open(nil, kekNonce, wrappedDEK, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: unwrap data key: %v", ErrCiphertext, err)
+	}
+	return dek, off, nil
+}
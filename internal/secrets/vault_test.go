@@ -74,6 +74,31 @@ func TestVaultWrongKeyCannotDecrypt(t *testing.T) {
 	}
 }
 
+func TestVaultRejectsMalformedBlobs(t *testing.T) {
+	ctx := context.Background()
+	v := newVault(t)
+	blob, _ := v.Encrypt(ctx, []byte("secret"))
+	if blob[0] != 1 {
+		t.Fatalf("format version: want 1, got %d", blob[0])
+	}
+
+	unknownVersion := bytes.Clone(blob)
+	unknownVersion[0] = 2
+	wrappedDEK := bytes.Clone(blob)
+	wrappedDEK[20] ^= 0xFF // inside the wrapped data key
+	cases := map[string][]byte{
+		"empty":           nil,
+		"truncated":       blob[:40],
+		"unknown version": unknownVersion,
+		"wrapped dek":     wrappedDEK,
+	}
+	for name, b := range cases {
+		if _, err := v.Decrypt(ctx, b); !errors.Is(err, secrets.ErrCiphertext) {
+			t.Errorf("%s: want ErrCiphertext, got %v", name, err)
+		}
+	}
+}
+
 func TestNewVaultRejectsBadKey(t *testing.T) {
 	if _, err := secrets.NewVault([]byte("too short")); !errors.Is(err, secrets.ErrMasterKey) {
 		t.Errorf("short key: want ErrMasterKey, got %v", err)
@@ -142,3 +167,33 @@ func TestEncryptDecryptMap(t *testing.T) {
 		t.Errorf("map round-trip mismatch: %+v", dec)
 	}
 }
+
+func TestSelfTest(t *testing.T) {
+	ctx := context.Background()
+	v := newVault(t)
+	value, err := secrets.NewSelfTest(ctx, v)
+	if err != nil {
+		t.Fatalf("new self-test: %v", err)
+	}
+	if err := secrets.VerifySelfTest(ctx, v, value); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if err := secrets.VerifySelfTest(ctx, newVault(t), value); !errors.Is(err, secrets.ErrCiphertext) {
+		t.Fatalf("another key should fail the self-test: %v", err)
+	}
+}
+
+func TestCheckVault(t *testing.T) {
+	ctx := context.Background()
+	v := newVault(t)
+	if status, err := secrets.CheckVault(ctx, v, ""); status != secrets.VaultCryptoOK || err != nil {
+		t.Fatalf("no stored value: %s %v", status, err)
+	}
+	value, _ := secrets.NewSelfTest(ctx, v)
+	if status, err := secrets.CheckVault(ctx, v, value); status != secrets.VaultCryptoOK || err != nil {
+		t.Fatalf("same key: %s %v", status, err)
+	}
+	if status, err := secrets.CheckVault(ctx, newVault(t), value); status != secrets.VaultKeyMismatch || !errors.Is(err, secrets.ErrCiphertext) {
+		t.Fatalf("another key: %s %v", status, err)
+	}
+}
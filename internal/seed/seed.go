@@ -0,0 +1,637 @@
+// Package seed loads a fixture of users, folders, credentials, connections
+// and shares through the service layer, for demo and test environments.
+//
+// Every fixture entry has a stable id. The seeder records which row it made
+// for each id, so running the same fixture again updates those rows instead
+// of adding new ones.
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"sigs.k8s.io/yaml"
+
+	"github.com/charlesng35/shellcn/internal/audit"
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// Kinds of fixture entry, as recorded in seed_records.
+const (
+	KindUser            = "user"
+	KindFolder          = "folder"
+	KindCredential      = "credential"
+	KindConnection      = "connection"
+	KindConnectionShare = "connection_share"
+	KindCredentialShare = "credential_share"
+)
+
+// Fixture is the document a seed file holds, in YAML or JSON. Entries refer
+// to each other by fixture id, never by database id.
+type Fixture struct {
+	Users       []User       `json:"users"`
+	Folders     []Folder     `json:"folders"`
+	Credentials []Credential `json:"credentials"`
+	Connections []Connection `json:"connections"`
+	Shares      []Share      `json:"shares"`
+}
+
+// User is an account. Password is only used when the account is created; a
+// re-run leaves the current password alone. A username that already exists
+// outside the fixture is adopted rather than duplicated.
+type User struct {
+	ID          string        `json:"id"`
+	Username    string        `json:"username"`
+	Email       string        `json:"email"`
+	DisplayName string        `json:"displayName"`
+	Roles       []models.Role `json:"roles"`
+	Password    string        `json:"password"`
+}
+
+// Folder is a sidebar folder in Owner's connection list.
+type Folder struct {
+	ID     string `json:"id"`
+	Owner  string `json:"owner"`
+	Name   string `json:"name"`
+	Color  string `json:"color"`
+	Parent string `json:"parent"`
+}
+
+// Credential is a reusable credential. Values hold its secret material in
+// plain text; the credential service encrypts them as on any other write.
+type Credential struct {
+	ID               string            `json:"id"`
+	Owner            string            `json:"owner"`
+	Name             string            `json:"name"`
+	Kind             string            `json:"kind"`
+	Values           map[string]string `json:"values"`
+	RequiresApproval bool              `json:"requiresApproval"`
+}
+
+// Connection is a saved connection. Credentials maps a credential_ref config
+// field to the fixture id of the credential it uses.
+type Connection struct {
+	ID          string            `json:"id"`
+	Owner       string            `json:"owner"`
+	Name        string            `json:"name"`
+	Protocol    string            `json:"protocol"`
+	Transport   string            `json:"transport"`
+	Folder      string            `json:"folder"`
+	Config      map[string]any    `json:"config"`
+	Credentials map[string]string `json:"credentials"`
+	Recording   map[string]string `json:"recording"`
+}
+
+// Share grants User access to exactly one of Connection or Credential.
+// Access defaults to view.
+type Share struct {
+	ID         string        `json:"id"`
+	Connection string        `json:"connection"`
+	Credential string        `json:"credential"`
+	User       string        `json:"user"`
+	Access     models.Access `json:"access"`
+}
+
+// Load reads a fixture file.
+func Load(path string) (Fixture, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Fixture{}, err
+	}
+	var f Fixture
+	if err := yaml.UnmarshalStrict(b, &f); err != nil {
+		return Fixture{}, fmt.Errorf("seed: parse %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Seeder applies fixtures. It writes through the same services the API uses,
+// so validation and secret encryption match production, and audits each
+// change as the "seed" actor.
+type Seeder struct {
+	Store       *store.Store
+	Users       *service.UserService
+	Credentials *service.CredentialService
+	Connections *service.ConnectionService
+	Audit       audit.Sink
+}
+
+// Report counts what a run created and updated, by kind.
+type Report struct {
+	Created map[string]int
+	Updated map[string]int
+	Deleted map[string]int
+}
+
+func newReport() Report {
+	return Report{Created: map[string]int{}, Updated: map[string]int{}, Deleted: map[string]int{}}
+}
+
+// seedActor is who seed changes are audited as.
+var seedActor = models.User{Username: "seed"}
+
+// Apply creates or updates everything in f. It stops at the first invalid
+// entry; what was applied before it stays, and a fixed re-run picks up from
+// there.
+func (s *Seeder) Apply(ctx context.Context, f Fixture) (Report, error) {
+	if err := f.validate(); err != nil {
+		return Report{}, err
+	}
+	rep := newReport()
+	steps := []func(context.Context, Fixture, *Report) error{
+		s.applyUsers, s.applyFolders, s.applyCredentials, s.applyConnections, s.applyShares,
+	}
+	for _, step := range steps {
+		if err := step(ctx, f, &rep); err != nil {
+			return rep, err
+		}
+	}
+	return rep, nil
+}
+
+// validate checks ids are present and unique per kind, and that references
+// point at entries in the same fixture.
+func (f Fixture) validate() error {
+	ids := map[string]map[string]bool{}
+	add := func(kind, id string) error {
+		if id == "" {
+			return fmt.Errorf("seed: a %s has no id", kind)
+		}
+		if ids[kind] == nil {
+			ids[kind] = map[string]bool{}
+		}
+		if ids[kind][id] {
+			return fmt.Errorf("seed: duplicate %s id %q", kind, id)
+		}
+		ids[kind][id] = true
+		return nil
+	}
+	ref := func(kind, id, from string) error {
+		if !ids[kind][id] {
+			return fmt.Errorf("seed: %s refers to unknown %s %q", from, kind, id)
+		}
+		return nil
+	}
+	for _, u := range f.Users {
+		if err := add(KindUser, u.ID); err != nil {
+			return err
+		}
+	}
+	for _, fo := range f.Folders {
+		if err := add(KindFolder, fo.ID); err != nil {
+			return err
+		}
+		if err := ref(KindUser, fo.Owner, "folder "+fo.ID); err != nil {
+			return err
+		}
+	}
+	for _, fo := range f.Folders {
+		if fo.Parent != "" {
+			if err := ref(KindFolder, fo.Parent, "folder "+fo.ID); err != nil {
+				return err
+			}
+		}
+	}
+	for _, c := range f.Credentials {
+		if err := add(KindCredential, c.ID); err != nil {
+			return err
+		}
+		if err := ref(KindUser, c.Owner, "credential "+c.ID); err != nil {
+			return err
+		}
+	}
+	for _, c := range f.Connections {
+		if err := add(KindConnection, c.ID); err != nil {
+			return err
+		}
+		if err := ref(KindUser, c.Owner, "connection "+c.ID); err != nil {
+			return err
+		}
+		if c.Folder != "" {
+			if err := ref(KindFolder, c.Folder, "connection "+c.ID); err != nil {
+				return err
+			}
+		}
+		for _, credID := range c.Credentials {
+			if err := ref(KindCredential, credID, "connection "+c.ID); err != nil {
+				return err
+			}
+		}
+	}
+	for _, sh := range f.Shares {
+		if err := add("share", sh.ID); err != nil {
+			return err
+		}
+		if err := ref(KindUser, sh.User, "share "+sh.ID); err != nil {
+			return err
+		}
+		switch {
+		case (sh.Connection == "") == (sh.Credential == ""):
+			return fmt.Errorf("seed: share %s must name exactly one connection or credential", sh.ID)
+		case sh.Connection != "":
+			if err := ref(KindConnection, sh.Connection, "share "+sh.ID); err != nil {
+				return err
+			}
+		default:
+			if err := ref(KindCredential, sh.Credential, "share "+sh.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve returns the database id recorded for a fixture id, or "" when the
+// fixture entry has no row yet.
+func (s *Seeder) resolve(ctx context.Context, kind, externalID string) (string, error) {
+	r, err := s.Store.SeedRecords.Get(ctx, kind, externalID)
+	if errors.Is(err, store.ErrNotFound) {
+		return "", nil
+	}
+	return r.ResourceID, err
+}
+
+// mustResolve is resolve for a reference an earlier step has applied.
+func (s *Seeder) mustResolve(ctx context.Context, kind, externalID string) (string, error) {
+	id, err := s.resolve(ctx, kind, externalID)
+	if err == nil && id == "" {
+		err = fmt.Errorf("seed: %s %q was not applied", kind, externalID)
+	}
+	return id, err
+}
+
+func (s *Seeder) remember(ctx context.Context, kind, externalID, resourceID string) error {
+	return s.Store.SeedRecords.Put(ctx, &models.SeedRecord{Kind: kind, ExternalID: externalID, ResourceID: resourceID})
+}
+
+// record audits one seed change and counts it.
+func (s *Seeder) record(ctx context.Context, rep *Report, kind, externalID, resourceID string, created bool) {
+	verb, counts := "update", rep.Updated
+	if created {
+		verb, counts = "create", rep.Created
+	}
+	counts[kind]++
+	if s.Audit == nil {
+		return
+	}
+	s.Audit.Record(ctx, audit.Event{
+		User: seedActor, Event: "seed." + kind + "." + verb, RouteID: "seed",
+		ResourceType: kind, ResourceID: resourceID,
+		Result: models.AuditAllowed, Params: map[string]string{"seedId": externalID},
+	})
+}
+
+func (s *Seeder) applyUsers(ctx context.Context, f Fixture, rep *Report) error {
+	for _, u := range f.Users {
+		id, err := s.resolve(ctx, KindUser, u.ID)
+		if err != nil {
+			return err
+		}
+		var existing models.User
+		if id != "" {
+			existing, err = s.Users.Get(ctx, id)
+		} else {
+			existing, err = s.Store.Users.GetByUsername(ctx, u.Username)
+		}
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			created, err := s.Users.Create(ctx, service.NewUserInput{
+				Username: u.Username, Email: u.Email, DisplayName: u.DisplayName, Roles: u.Roles, Password: u.Password,
+			})
+			if err != nil {
+				return fmt.Errorf("seed: user %s: %w", u.ID, err)
+			}
+			if err := s.remember(ctx, KindUser, u.ID, created.ID); err != nil {
+				return err
+			}
+			s.record(ctx, rep, KindUser, u.ID, created.ID, true)
+		case err != nil:
+			return err
+		default:
+			if err := s.remember(ctx, KindUser, u.ID, existing.ID); err != nil {
+				return err
+			}
+			// The root admin's roles are not the fixture's to change.
+			if existing.Protected {
+				continue
+			}
+			if _, err := s.Users.Update(ctx, existing.ID, service.UpdateUserInput{
+				Email: u.Email, DisplayName: u.DisplayName, Roles: u.Roles, Disabled: existing.Disabled,
+			}); err != nil {
+				return fmt.Errorf("seed: user %s: %w", u.ID, err)
+			}
+			s.record(ctx, rep, KindUser, u.ID, existing.ID, false)
+		}
+	}
+	return nil
+}
+
+// applyFolders runs parents before children whatever order the fixture
+// lists them in.
+func (s *Seeder) applyFolders(ctx context.Context, f Fixture, rep *Report) error {
+	done := map[string]bool{}
+	pending := slices.Clone(f.Folders)
+	for len(pending) > 0 {
+		var next []Folder
+		for _, fo := range pending {
+			if fo.Parent != "" && !done[fo.Parent] {
+				next = append(next, fo)
+				continue
+			}
+			if err := s.applyFolder(ctx, fo, rep); err != nil {
+				return err
+			}
+			done[fo.ID] = true
+		}
+		if len(next) == len(pending) {
+			return fmt.Errorf("seed: folder %s is part of a parent cycle", next[0].ID)
+		}
+		pending = next
+	}
+	return nil
+}
+
+func (s *Seeder) applyFolder(ctx context.Context, fo Folder, rep *Report) error {
+	ownerID, err := s.mustResolve(ctx, KindUser, fo.Owner)
+	if err != nil {
+		return err
+	}
+	parentID := ""
+	if fo.Parent != "" {
+		if parentID, err = s.mustResolve(ctx, KindFolder, fo.Parent); err != nil {
+			return err
+		}
+	}
+	in := service.ConnectionFolderInput{Name: fo.Name, Color: fo.Color, ParentID: parentID}
+	id, err := s.resolve(ctx, KindFolder, fo.ID)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		existing, err := s.Store.ConnectionFolders.Get(ctx, id)
+		if err == nil && existing.UserID == ownerID {
+			if _, err := s.Connections.UpdateFolder(ctx, s.Store.ConnectionFolders, existing, in); err != nil {
+				return fmt.Errorf("seed: folder %s: %w", fo.ID, err)
+			}
+			s.record(ctx, rep, KindFolder, fo.ID, id, false)
+			return nil
+		}
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+	}
+	created, err := s.Connections.CreateFolder(ctx, s.Store.ConnectionFolders, ownerID, in)
+	if err != nil {
+		return fmt.Errorf("seed: folder %s: %w", fo.ID, err)
+	}
+	if err := s.remember(ctx, KindFolder, fo.ID, created.ID); err != nil {
+		return err
+	}
+	s.record(ctx, rep, KindFolder, fo.ID, created.ID, true)
+	return nil
+}
+
+func (s *Seeder) applyCredentials(ctx context.Context, f Fixture, rep *Report) error {
+	for _, c := range f.Credentials {
+		ownerID, err := s.mustResolve(ctx, KindUser, c.Owner)
+		if err != nil {
+			return err
+		}
+		id, err := s.resolve(ctx, KindCredential, c.ID)
+		if err != nil {
+			return err
+		}
+		if id != "" {
+			existing, err := s.Store.Credentials.Get(ctx, id)
+			if err == nil && existing.OwnerID == ownerID {
+				if _, err := s.Credentials.Update(ctx, id, service.UpdateCredentialInput{
+					Name: c.Name, Kind: c.Kind, Values: c.Values, ActorID: ownerID, RequiresApproval: &c.RequiresApproval,
+				}); err != nil {
+					return fmt.Errorf("seed: credential %s: %w", c.ID, err)
+				}
+				s.record(ctx, rep, KindCredential, c.ID, id, false)
+				continue
+			}
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				return err
+			}
+		}
+		created, err := s.Credentials.Create(ctx, service.NewCredentialInput{
+			OwnerID: ownerID, Name: c.Name, Kind: c.Kind, Values: c.Values, RequiresApproval: c.RequiresApproval,
+		})
+		if err != nil {
+			return fmt.Errorf("seed: credential %s: %w", c.ID, err)
+		}
+		if err := s.remember(ctx, KindCredential, c.ID, created.ID); err != nil {
+			return err
+		}
+		s.record(ctx, rep, KindCredential, c.ID, created.ID, true)
+	}
+	return nil
+}
+
+func (s *Seeder) applyConnections(ctx context.Context, f Fixture, rep *Report) error {
+	for _, c := range f.Connections {
+		ownerID, err := s.mustResolve(ctx, KindUser, c.Owner)
+		if err != nil {
+			return err
+		}
+		in := service.ConnectionInput{
+			Name: c.Name, Protocol: c.Protocol, Transport: c.Transport, ActorID: ownerID,
+			Config: map[string]any{}, Recording: c.Recording,
+		}
+		for k, v := range c.Config {
+			in.Config[k] = v
+		}
+		for field, credID := range c.Credentials {
+			if in.Config[field], err = s.mustResolve(ctx, KindCredential, credID); err != nil {
+				return err
+			}
+		}
+		if c.Folder != "" {
+			if in.FolderID, err = s.mustResolve(ctx, KindFolder, c.Folder); err != nil {
+				return err
+			}
+		}
+		id, err := s.resolve(ctx, KindConnection, c.ID)
+		if err != nil {
+			return err
+		}
+		if id != "" {
+			existing, err := s.Store.Connections.Get(ctx, id)
+			if err == nil && existing.OwnerID == ownerID && existing.Protocol == c.Protocol {
+				if _, err := s.Connections.Update(ctx, existing, in); err != nil {
+					return fmt.Errorf("seed: connection %s: %w", c.ID, err)
+				}
+				s.record(ctx, rep, KindConnection, c.ID, id, false)
+				continue
+			}
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				return err
+			}
+		}
+		created, err := s.Connections.Create(ctx, ownerID, in)
+		if err != nil {
+			return fmt.Errorf("seed: connection %s: %w", c.ID, err)
+		}
+		if err := s.remember(ctx, KindConnection, c.ID, created.ID); err != nil {
+			return err
+		}
+		s.record(ctx, rep, KindConnection, c.ID, created.ID, true)
+	}
+	return nil
+}
+
+func (s *Seeder) applyShares(ctx context.Context, f Fixture, rep *Report) error {
+	for _, sh := range f.Shares {
+		subjectID, err := s.mustResolve(ctx, KindUser, sh.User)
+		if err != nil {
+			return err
+		}
+		access := sh.Access
+		if access == "" {
+			access = models.AccessView
+		}
+		if sh.Connection != "" {
+			err = s.applyConnectionShare(ctx, sh, subjectID, access, rep)
+		} else {
+			err = s.applyCredentialShare(ctx, sh, subjectID, access, rep)
+		}
+		if err != nil {
+			return fmt.Errorf("seed: share %s: %w", sh.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *Seeder) applyConnectionShare(ctx context.Context, sh Share, subjectID string, access models.Access, rep *Report) error {
+	if !slices.Contains(models.ConnectionGrantAccesses(), access) {
+		return fmt.Errorf("%w: unknown access %q", plugin.ErrInvalidInput, access)
+	}
+	connID, err := s.mustResolve(ctx, KindConnection, sh.Connection)
+	if err != nil {
+		return err
+	}
+	g, err := s.Store.Grants.Get(ctx, connID, subjectID)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		g = models.Grant{ID: uuid.NewString(), ConnectionID: connID, SubjectID: subjectID, Access: access, CreatedAt: time.Now()}
+		if err := s.Store.Grants.Create(ctx, &g); err != nil {
+			return err
+		}
+		if err := s.remember(ctx, KindConnectionShare, sh.ID, g.ID); err != nil {
+			return err
+		}
+		s.record(ctx, rep, KindConnectionShare, sh.ID, g.ID, true)
+	case err != nil:
+		return err
+	default:
+		if g.Access != access {
+			if err := s.Store.Grants.SetAccess(ctx, g.ID, access); err != nil {
+				return err
+			}
+		}
+		if err := s.remember(ctx, KindConnectionShare, sh.ID, g.ID); err != nil {
+			return err
+		}
+		s.record(ctx, rep, KindConnectionShare, sh.ID, g.ID, false)
+	}
+	return nil
+}
+
+func (s *Seeder) applyCredentialShare(ctx context.Context, sh Share, subjectID string, access models.Access, rep *Report) error {
+	if !slices.Contains(models.CredentialGrantAccesses(), access) {
+		return fmt.Errorf("%w: credentials can only be shared with view access", plugin.ErrInvalidInput)
+	}
+	credID, err := s.mustResolve(ctx, KindCredential, sh.Credential)
+	if err != nil {
+		return err
+	}
+	grants, err := s.Store.CredentialGrants.ListByCredential(ctx, credID)
+	if err != nil {
+		return err
+	}
+	for _, g := range grants {
+		if g.SubjectID == subjectID {
+			return s.remember(ctx, KindCredentialShare, sh.ID, g.ID)
+		}
+	}
+	g := models.CredentialGrant{ID: uuid.NewString(), CredentialID: credID, SubjectID: subjectID, Access: access, CreatedAt: time.Now()}
+	if err := s.Store.CredentialGrants.Create(ctx, &g); err != nil {
+		return err
+	}
+	if err := s.remember(ctx, KindCredentialShare, sh.ID, g.ID); err != nil {
+		return err
+	}
+	s.record(ctx, rep, KindCredentialShare, sh.ID, g.ID, true)
+	return nil
+}
+
+// wipeOrder deletes dependents before what they depend on.
+var wipeOrder = []string{KindCredentialShare, KindConnectionShare, KindConnection, KindCredential, KindFolder, KindUser}
+
+// Wipe permanently deletes every row earlier runs created, and forgets their
+// mappings. Accounts the seeder adopted by username are deleted too, except
+// the protected root admin. Rows made by other means are untouched.
+func (s *Seeder) Wipe(ctx context.Context) (Report, error) {
+	rep := newReport()
+	records, err := s.Store.SeedRecords.List(ctx)
+	if err != nil {
+		return rep, err
+	}
+	for _, kind := range wipeOrder {
+		for _, r := range records {
+			if r.Kind != kind {
+				continue
+			}
+			if err := s.wipeOne(ctx, r); err != nil && !errors.Is(err, store.ErrNotFound) {
+				return rep, fmt.Errorf("seed: wipe %s %s: %w", r.Kind, r.ExternalID, err)
+			}
+			if err := s.Store.SeedRecords.Delete(ctx, r.Kind, r.ExternalID); err != nil {
+				return rep, err
+			}
+			rep.Deleted[kind]++
+			if s.Audit != nil {
+				s.Audit.Record(ctx, audit.Event{
+					User: seedActor, Event: "seed." + kind + ".delete", RouteID: "seed",
+					ResourceType: kind, ResourceID: r.ResourceID,
+					Result: models.AuditAllowed, Params: map[string]string{"seedId": r.ExternalID},
+				})
+			}
+		}
+	}
+	return rep, nil
+}
+
+func (s *Seeder) wipeOne(ctx context.Context, r models.SeedRecord) error {
+	switch r.Kind {
+	case KindCredentialShare:
+		return s.Store.CredentialGrants.Delete(ctx, r.ResourceID)
+	case KindConnectionShare:
+		return s.Store.Grants.Delete(ctx, r.ResourceID)
+	case KindConnection:
+		return s.Store.Connections.Delete(ctx, r.ResourceID)
+	case KindCredential:
+		return s.Credentials.Delete(ctx, r.ResourceID)
+	case KindFolder:
+		return s.Store.ConnectionFolders.Delete(ctx, r.ResourceID)
+	case KindUser:
+		u, err := s.Users.Get(ctx, r.ResourceID)
+		if err != nil {
+			return err
+		}
+		if u.Protected {
+			return nil
+		}
+		return s.Users.Delete(ctx, u.ID, service.UserDeleteOptions{OrphanCredentials: true, OrphanConnections: true})
+	}
+	return nil
+}
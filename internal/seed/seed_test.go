@@ -0,0 +1,191 @@
+package seed_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/pluginregistry"
+	"github.com/charlesng35/shellcn/internal/secrets"
+	"github.com/charlesng35/shellcn/internal/seed"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+	shellssh "github.com/charlesng35/shellcn/plugins/ssh"
+)
+
+const fixtureYAML = `
+users:
+  - id: alice
+    username: alice
+    email: alice@example.com
+    displayName: Alice
+    roles: [operator]
+    password: Correct-Horse-42
+  - id: bob
+    username: bob
+    roles: [viewer]
+    password: Battery-Staple-42
+folders:
+  - id: prod
+    owner: alice
+    name: Production
+  - id: web
+    owner: alice
+    name: Web
+    parent: prod
+credentials:
+  - id: ops-password
+    owner: alice
+    name: ops
+    kind: ssh_password
+    values: {username: ops, password: hunter2}
+connections:
+  - id: web-1
+    owner: alice
+    name: web-1
+    protocol: ssh
+    folder: web
+    config: {host: 10.0.0.1, auth: stored_password, host_key_verification: insecure}
+    credentials: {credential_password_id: ops-password}
+shares:
+  - id: web-1-bob
+    connection: web-1
+    user: bob
+    access: manage
+  - id: ops-bob
+    credential: ops-password
+    user: bob
+`
+
+func newSeeder(t *testing.T) (*seed.Seeder, *store.Store) {
+	t.Helper()
+	st := store.NewMemory()
+	key, _ := secrets.GenerateMasterKey()
+	vault, _ := secrets.NewVault(key)
+	reg := pluginregistry.New()
+	reg.MustRegister(shellssh.New())
+	settings := service.NewSettingsService(st.SystemSettings)
+	settings.Register(service.BuiltinSettings()...)
+	creds := service.NewCredentialService(st.Credentials, st.CredentialGrants, vault,
+		service.WithCredentialKindCatalog(reg), service.WithCredentialVersions(st.CredentialVersions))
+	return &seed.Seeder{
+		Store: st,
+		Users: service.NewUserService(st.Users, service.WithUserGrants(st.Grants, st.CredentialGrants),
+			service.WithUserCredentials(st.Credentials),
+			service.WithPasswordPolicy(service.NewPasswordPolicyService(settings, st.Users))),
+		Credentials: creds,
+		Connections: service.NewConnectionService(st.Connections, reg, creds, vault,
+			service.WithConnectionPlacements(st.ConnectionPlacements)),
+	}, st
+}
+
+func loadFixture(t *testing.T, doc string) seed.Fixture {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fixture.yaml")
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := seed.Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	return f
+}
+
+func TestApplyIsIdempotentAndUpdates(t *testing.T) {
+	ctx := context.Background()
+	s, st := newSeeder(t)
+	f := loadFixture(t, fixtureYAML)
+
+	rep, err := s.Apply(ctx, f)
+	if err != nil {
+		t.Fatalf("first apply: %v", err)
+	}
+	if rep.Created[seed.KindUser] != 2 || rep.Created[seed.KindConnection] != 1 || rep.Created[seed.KindConnectionShare] != 1 {
+		t.Fatalf("first apply created %+v", rep.Created)
+	}
+
+	f.Connections[0].Name = "web-01"
+	f.Users[1].Roles = []models.Role{models.RoleOperator}
+	rep, err = s.Apply(ctx, f)
+	if err != nil {
+		t.Fatalf("second apply: %v", err)
+	}
+	if len(rep.Created) != 0 {
+		t.Fatalf("second apply created %+v, want only updates", rep.Created)
+	}
+
+	users, _ := st.Users.List(ctx)
+	if len(users) != 2 {
+		t.Fatalf("users = %d, want 2", len(users))
+	}
+	conns, _ := st.Connections.ListByOwner(ctx, users[0].ID)
+	conns2, _ := st.Connections.ListByOwner(ctx, users[1].ID)
+	conns = append(conns, conns2...)
+	if len(conns) != 1 || conns[0].Name != "web-01" {
+		t.Fatalf("connections = %+v, want one renamed web-01", conns)
+	}
+	bob, _ := st.Users.GetByUsername(ctx, "bob")
+	if len(bob.Roles) != 1 || bob.Roles[0] != models.RoleOperator {
+		t.Errorf("bob roles = %v, want [operator]", bob.Roles)
+	}
+	grant, err := st.Grants.Get(ctx, conns[0].ID, bob.ID)
+	if err != nil || grant.Access != models.AccessManage {
+		t.Errorf("bob grant = %+v, %v; want manage", grant, err)
+	}
+}
+
+func TestApplyEncryptsCredentialValues(t *testing.T) {
+	ctx := context.Background()
+	s, st := newSeeder(t)
+	if _, err := s.Apply(ctx, loadFixture(t, fixtureYAML)); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	rec, err := st.SeedRecords.Get(ctx, seed.KindCredential, "ops-password")
+	if err != nil {
+		t.Fatalf("seed record: %v", err)
+	}
+	cred, _ := st.Credentials.Get(ctx, rec.ResourceID)
+	if len(cred.EncryptedValues) == 0 || bytes.Contains(cred.EncryptedValues, []byte("hunter2")) {
+		t.Fatal("credential values are not encrypted at rest")
+	}
+}
+
+func TestApplyRejectsUnknownReference(t *testing.T) {
+	s, _ := newSeeder(t)
+	f := loadFixture(t, fixtureYAML)
+	f.Connections[0].Folder = "missing"
+	if _, err := s.Apply(context.Background(), f); err == nil {
+		t.Fatal("apply accepted a connection in an unknown folder")
+	}
+}
+
+func TestWipeRemovesOnlySeededRows(t *testing.T) {
+	ctx := context.Background()
+	s, st := newSeeder(t)
+	if _, err := s.Apply(ctx, loadFixture(t, fixtureYAML)); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	other := models.User{ID: "other", Username: "carol"}
+	if err := st.Users.Create(ctx, &other, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := s.Wipe(ctx)
+	if err != nil {
+		t.Fatalf("wipe: %v", err)
+	}
+	if rep.Deleted[seed.KindUser] != 2 {
+		t.Errorf("deleted %+v", rep.Deleted)
+	}
+	users, _ := st.Users.List(ctx)
+	if len(users) != 1 || users[0].Username != "carol" {
+		t.Fatalf("users after wipe = %+v, want only carol", users)
+	}
+	if records, _ := st.SeedRecords.List(ctx); len(records) != 0 {
+		t.Errorf("seed records after wipe = %d", len(records))
+	}
+}
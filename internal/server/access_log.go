@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"strings"
@@ -9,6 +10,16 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 )
 
+// accessUser is filled in by authenticate so the access log, which runs before
+// the auth middleware, can name the user behind a request.
+type accessUser struct{ id string }
+
+func noteAccessUser(ctx context.Context, userID string) {
+	if u, ok := ctx.Value(ctxAccessUser).(*accessUser); ok {
+		u.id = userID
+	}
+}
+
 // accessLog logs one line per API request. chi's wrapper preserves Hijacker and
 // Flusher, so WebSocket upgrades and streaming responses still work.
 func (s *Server) accessLog(next http.Handler) http.Handler {
@@ -19,7 +30,8 @@ func (s *Server) accessLog(next http.Handler) http.Handler {
 		}
 		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 		start := time.Now()
-		next.ServeHTTP(ww, r)
+		user := &accessUser{}
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), ctxAccessUser, user)))
 
 		status := ww.Status()
 		if status == 0 {
@@ -39,6 +51,7 @@ func (s *Server) accessLog(next http.Handler) http.Handler {
 			slog.Int("bytes", ww.BytesWritten()),
 			slog.Duration("duration", time.Since(start)),
 			slog.String("remote", r.RemoteAddr),
+			slog.String("user_id", user.id),
 		)
 	})
 }
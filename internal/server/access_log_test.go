@@ -12,7 +12,8 @@ import (
 func TestAccessLog(t *testing.T) {
 	var buf bytes.Buffer
 	s := &Server{deps: Deps{Logger: slog.New(slog.NewJSONHandler(&buf, nil)), AccessLog: true}}
-	h := s.accessLog(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+	h := s.accessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		noteAccessUser(r.Context(), "u-1")
 		w.WriteHeader(http.StatusNoContent)
 	}))
 
@@ -22,10 +23,10 @@ func TestAccessLog(t *testing.T) {
 		t.Fatalf("non-API request should not be logged: %s", buf.String())
 	}
 
-	// API requests are logged with method, path, and status.
+	// API requests are logged with method, path, status, and the user.
 	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/connections", nil))
 	out := buf.String()
-	for _, want := range []string{`"msg":"request"`, `"method":"POST"`, `"path":"/api/connections"`, `"status":204`} {
+	for _, want := range []string{`"msg":"request"`, `"method":"POST"`, `"path":"/api/connections"`, `"status":204`, `"user_id":"u-1"`} {
 		if !strings.Contains(out, want) {
 			t.Errorf("access log missing %s in: %s", want, out)
 		}
@@ -1,9 +1,38 @@
 package server
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/audit"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
 
 // handleMyAudit serves the signed-in user's own audit trail ("My activity").
 func (s *Server) handleMyAudit(w http.ResponseWriter, r *http.Request) {
 	user, _ := userFrom(r.Context())
-	s.writeAuditPage(w, r, user.ID)
+	s.writeAuditPage(w, r, store.AuditFilter{UserID: user.ID})
+}
+
+// handleConnectionAudit serves the audit trail of one connection. The owner
+// sees every entry; a grantee sees only their own.
+func (s *Server) handleConnectionAudit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	conn, err := s.deps.Store.Connections.Get(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	if !s.canAccessConnection(ctx, user, conn) {
+		writeError(w, s.deps.Logger, plugin.ErrNotFound)
+		return
+	}
+	f := store.AuditFilter{ResourceType: audit.ResourceConnection, ResourceID: conn.ID}
+	if !s.canAdminConnection(user, conn) {
+		f.UserID = user.ID
+	}
+	s.writeAuditPage(w, r, f)
 }
@@ -22,6 +22,7 @@ const (
 	userDeactivateEvent = "user.deactivate"
 	inviteCreateEvent   = "invitation.create"
 	inviteRevokeEvent   = "invitation.revoke"
+	inviteResendEvent   = "invitation.resend"
 	twoFactorResetEvent = "user.2fa.reset"
 )
 
@@ -379,6 +380,24 @@ func (s *Server) handleAdminRevokeInvitation(w http.ResponseWriter, r *http.Requ
 	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
 }
 
+func (s *Server) handleAdminResendInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actor, _ := userFrom(ctx)
+	id := chi.URLParam(r, "id")
+	inv, token, emailSent, err := s.deps.Invitations.Resend(ctx, id, s.inviteAcceptURL(r))
+	if err != nil {
+		s.auditAdminEvent(ctx, actor, inviteResendEvent, models.AuditError, map[string]string{"id": id}, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditAdminEvent(ctx, actor, inviteResendEvent, models.AuditAllowed, map[string]string{"id": id, "email": inv.Email}, nil)
+	writeJSON(w, http.StatusOK, inviteResponse{
+		Invitation: inv.Summary(),
+		Link:       s.inviteAcceptURL(r) + token,
+		EmailSent:  emailSent,
+	})
+}
+
 func (s *Server) handleAdminEmailStatus(w http.ResponseWriter, _ *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]bool{"enabled": s.deps.Invitations.EmailEnabled()})
 }
@@ -388,7 +407,7 @@ func (s *Server) handleAdminEmailStatus(w http.ResponseWriter, _ *http.Request)
 func (s *Server) handleInvitationLookup(w http.ResponseWriter, r *http.Request) {
 	inv, err := s.deps.Invitations.Lookup(r.Context(), chi.URLParam(r, "token"))
 	if err != nil {
-		writeError(w, s.deps.Logger, plugin.ErrNotFound)
+		writeError(w, s.deps.Logger, invitationError(err))
 		return
 	}
 	writeJSON(w, http.StatusOK, map[string]string{"email": inv.Email, "role": string(inv.Role)})
@@ -412,16 +431,21 @@ func (s *Server) handleAcceptInvitation(w http.ResponseWriter, r *http.Request)
 	}
 	user, err := s.deps.Invitations.Accept(ctx, chi.URLParam(r, "token"), req.Username, req.Password)
 	if err != nil {
-		if errors.Is(err, service.ErrInvitationInvalid) {
-			writeError(w, s.deps.Logger, plugin.ErrNotFound)
-			return
-		}
-		writeError(w, s.deps.Logger, err) // models.ErrConflict → 409 on a taken username
+		writeError(w, s.deps.Logger, invitationError(err)) // models.ErrConflict → 409 on a taken username
 		return
 	}
 	writeJSON(w, http.StatusCreated, map[string]string{"username": user.Username})
 }
 
+// invitationError hides why a token failed: an expired link says so (410),
+// anything else is a plain 404 that reveals nothing about the invited email.
+func invitationError(err error) error {
+	if errors.Is(err, service.ErrInvitationInvalid) {
+		return plugin.ErrNotFound
+	}
+	return err
+}
+
 func (s *Server) inviteAcceptURL(r *http.Request) string {
 	scheme := "http"
 	if isTLS(r) {
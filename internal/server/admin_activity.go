@@ -0,0 +1,88 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	defaultActivityDays = 30
+	maxActivityDays     = 365
+)
+
+var sessionOpenRoute = plugin.Route{
+	ID: "connection.session.open", Permission: "connection.use", Risk: plugin.RiskSafe, AuditEvent: service.SessionOpenEvent,
+}
+
+type activityDTO struct {
+	Range                string                  `json:"range"`
+	Since                time.Time               `json:"since"`
+	GeneratedAt          time.Time               `json:"generatedAt"`
+	SessionsPerDay       []activityDayDTO        `json:"sessionsPerDay"`
+	TopConnections       []activityConnectionDTO `json:"topConnections"`
+	ActiveUsers          int64                   `json:"activeUsers"`
+	RecordingBytes       int64                   `json:"recordingBytes"`
+	CredentialRetrievals int64                   `json:"credentialRetrievals"`
+}
+
+type activityDayDTO struct {
+	Day      string `json:"day"`
+	Sessions int64  `json:"sessions"`
+}
+
+type activityConnectionDTO struct {
+	ConnectionID string `json:"connectionId"`
+	Name         string `json:"name,omitempty"`
+	Sessions     int64  `json:"sessions"`
+}
+
+// handleAdminActivity reports usage over a trailing window of days for the
+// admin dashboard. Figures are cached for a few minutes.
+func (s *Server) handleAdminActivity(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	days, err := parseActivityRange(r.URL.Query().Get("range"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	window := time.Duration(days) * 24 * time.Hour
+	sum, at, err := s.deps.Activity.Summary(ctx, window)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	out := activityDTO{
+		Range: strconv.Itoa(days) + "d", Since: at.Add(-window), GeneratedAt: at,
+		SessionsPerDay: []activityDayDTO{}, TopConnections: []activityConnectionDTO{},
+		ActiveUsers: sum.ActiveUsers, RecordingBytes: sum.RecordingBytes, CredentialRetrievals: sum.CredentialRetrievals,
+	}
+	for _, d := range sum.SessionsPerDay {
+		out.SessionsPerDay = append(out.SessionsPerDay, activityDayDTO{Day: d.Day, Sessions: d.Count})
+	}
+	for _, c := range sum.TopConnections {
+		item := activityConnectionDTO{ConnectionID: c.ConnectionID, Sessions: c.Count}
+		if conn, err := s.deps.Store.Connections.Get(ctx, c.ConnectionID); err == nil {
+			item.Name = conn.Name
+		}
+		out.TopConnections = append(out.TopConnections, item)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// parseActivityRange accepts "<n>d"; empty means the default window.
+func parseActivityRange(v string) (int, error) {
+	if v == "" {
+		return defaultActivityDays, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(v, "d"))
+	if err != nil || !strings.HasSuffix(v, "d") || n < 1 || n > maxActivityDays {
+		return 0, fmt.Errorf("%w: range must be 1d-%dd", plugin.ErrInvalidInput, maxActivityDays)
+	}
+	return n, nil
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/audit"
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const auditVerifyEvent = "audit.verify"
+
+type auditVerifyRequest struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+}
+
+type auditVerifyDTO struct {
+	audit.VerifyReport
+	OK bool `json:"ok"`
+	// PublicKey checks anchor signatures in an export; empty when the server
+	// has no anchor key.
+	PublicKey string `json:"publicKey,omitempty"`
+}
+
+// handleAdminAuditVerify replays the audit hash chain over a time range and
+// reports the first broken link. Either bound may be left out.
+func (s *Server) handleAdminAuditVerify(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	var req auditVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	if !req.Since.IsZero() && !req.Until.IsZero() && req.Since.After(req.Until) {
+		writeError(w, s.deps.Logger, fmt.Errorf("%w: since must not be after until", plugin.ErrInvalidInput))
+		return
+	}
+	report, err := audit.Verify(ctx, s.deps.Store.Audit, s.deps.AuditSigner, req.Since, req.Until)
+	params := map[string]string{"checked": strconv.FormatInt(report.Checked, 10)}
+	if !req.Since.IsZero() {
+		params["since"] = req.Since.UTC().Format(time.RFC3339Nano)
+	}
+	if !req.Until.IsZero() {
+		params["until"] = req.Until.UTC().Format(time.RFC3339Nano)
+	}
+	if report.Broken != nil {
+		params["brokenSeq"] = strconv.FormatInt(report.Broken.Seq, 10)
+	}
+	result := models.AuditAllowed
+	if err != nil {
+		result = models.AuditError
+	}
+	s.deps.Audit.Record(ctx, audit.Event{
+		User: user, Event: auditVerifyEvent, RouteID: auditVerifyEvent,
+		Risk: string(plugin.RiskSafe), Result: result, Params: params, Err: err,
+	})
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	dto := auditVerifyDTO{VerifyReport: report, OK: report.Broken == nil}
+	if s.deps.AuditSigner != nil {
+		dto.PublicKey = s.deps.AuditSigner.PublicKey()
+	}
+	writeJSON(w, http.StatusOK, dto)
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// handleAdminCredentialBindings reports connections whose credential
+// references would fail at launch (?status=&owner=&protocol=&limit=&offset=).
+func (s *Server) handleAdminCredentialBindings(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	status := service.CredentialBindingStatus(q.Get("status"))
+	switch status {
+	case "", service.BindingOK, service.BindingOwnerLostAccess, service.BindingCredentialDeleted, service.BindingScopeMismatch:
+	default:
+		writeError(w, s.deps.Logger, fmt.Errorf("%w: unknown binding status %q", plugin.ErrInvalidInput, status))
+		return
+	}
+	limit, offset := auditPageParams(r)
+	report, err := s.deps.Connections.ValidateCredentialBindings(r.Context(), service.CredentialBindingFilter{
+		Status: status, OwnerID: q.Get("owner"), Protocol: q.Get("protocol"), Limit: limit, Offset: offset,
+	})
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,16 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/charlesng35/shellcn/internal/service"
+)
+
+type hygieneDTO struct {
+	// Last is the most recent orphaned-grant sweep; absent before the first.
+	Last *service.HygieneReport `json:"last,omitempty"`
+}
+
+func (s *Server) handleAdminHygiene(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, hygieneDTO{Last: s.deps.Hygiene.Last()})
+}
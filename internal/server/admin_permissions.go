@@ -0,0 +1,111 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/charlesng35/shellcn/internal/policy"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+type permissionExplainDTO struct {
+	UserID       string             `json:"userId"`
+	ResourceType string             `json:"resourceType"`
+	ResourceID   string             `json:"resourceId"`
+	Permission   string             `json:"permission"`
+	Risk         string             `json:"risk"`
+	Allowed      bool               `json:"allowed"`
+	Reason       string             `json:"reason,omitempty"`
+	Trace        []policy.TraceStep `json:"trace"`
+}
+
+// handleAdminExplainPermission replays an access decision for another user
+// and returns each rule evaluated. It reads only: nothing is audited against
+// the target user and no access or usage record is written.
+func (s *Server) handleAdminExplainPermission(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actor, _ := userFrom(ctx)
+	if !actor.Protected {
+		writeError(w, s.deps.Logger, plugin.ErrForbidden)
+		return
+	}
+	q := r.URL.Query()
+	out := permissionExplainDTO{
+		UserID: q.Get("user_id"), ResourceType: q.Get("resource_type"), ResourceID: q.Get("resource_id"),
+		Permission: q.Get("permission"), Risk: q.Get("risk"),
+	}
+	if out.UserID == "" || out.ResourceID == "" {
+		writeError(w, s.deps.Logger, fmt.Errorf("%w: user_id and resource_id are required", plugin.ErrInvalidInput))
+		return
+	}
+	if out.Risk == "" {
+		out.Risk = string(plugin.RiskSafe)
+	}
+	if !validRisk(plugin.RiskLevel(out.Risk)) {
+		writeError(w, s.deps.Logger, fmt.Errorf("%w: unknown risk %q", plugin.ErrInvalidInput, out.Risk))
+		return
+	}
+	target, err := s.deps.Users.Get(ctx, out.UserID)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+
+	var decision error
+	switch out.ResourceType {
+	case "", "connection":
+		out.ResourceType = "connection"
+		conn, err := s.deps.Store.Connections.Get(ctx, out.ResourceID)
+		if err != nil {
+			writeError(w, s.deps.Logger, err)
+			return
+		}
+		in := policy.AccessInput{
+			User: target, Permission: out.Permission, Risk: plugin.RiskLevel(out.Risk),
+			ConnectionID: conn.ID, OwnerID: conn.OwnerID,
+		}
+		if conn.OwnerID != target.ID {
+			if g, err := s.deps.Store.Grants.Get(ctx, conn.ID, target.ID); err == nil {
+				in.HasGrant = true
+				in.GrantAccess = g.Access
+			}
+		}
+		out.Trace, decision = s.deps.Policy.Explain(in)
+	case "credential":
+		cred, err := s.deps.Store.Credentials.Get(ctx, out.ResourceID)
+		if err != nil {
+			writeError(w, s.deps.Logger, err)
+			return
+		}
+		// Mirrors the credential service's use check: owner or a view-grant.
+		owner := cred.OwnerID == target.ID
+		out.Trace = append(out.Trace, policy.TraceStep{Rule: "owner", Matched: owner, Detail: "owner " + cred.OwnerID})
+		if !owner {
+			has, err := s.deps.Store.CredentialGrants.Has(ctx, cred.ID, target.ID)
+			if err != nil {
+				writeError(w, s.deps.Logger, err)
+				return
+			}
+			out.Trace = append(out.Trace, policy.TraceStep{Rule: "credential_grant", Matched: has})
+			if !has {
+				decision = fmt.Errorf("%w: no access to credential %q", policy.ErrForbidden, cred.ID)
+			}
+		}
+	default:
+		writeError(w, s.deps.Logger, fmt.Errorf("%w: resource_type must be connection or credential", plugin.ErrInvalidInput))
+		return
+	}
+	out.Allowed = decision == nil
+	if decision != nil {
+		out.Reason = decision.Error()
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func validRisk(risk plugin.RiskLevel) bool {
+	switch risk {
+	case plugin.RiskSafe, plugin.RiskWrite, plugin.RiskDestructive, plugin.RiskPrivileged:
+		return true
+	}
+	return false
+}
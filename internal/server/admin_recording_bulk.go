@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/audit"
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const recBulkDeleteEvent = "recording.bulk_delete"
+
+type recordingBulkDeleteRequest struct {
+	User       string    `json:"user"`
+	Connection string    `json:"connection"`
+	Protocol   string    `json:"protocol"`
+	Class      string    `json:"class"`
+	Status     string    `json:"status"`
+	Since      time.Time `json:"since"`
+	Until      time.Time `json:"until"`
+	DryRun     bool      `json:"dryRun"`
+}
+
+// handleAdminBulkDeleteRecordings deletes every recording matching a filter,
+// or with dryRun reports what that would remove. One audit row records the
+// filter and outcome.
+func (s *Server) handleAdminBulkDeleteRecordings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	var req recordingBulkDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	f := store.RecordingFilter{
+		UserID: req.User, ConnectionID: req.Connection, Protocol: req.Protocol,
+		Class: req.Class, Status: req.Status, Since: req.Since, Until: req.Until,
+	}
+	res, err := s.deps.Recordings.BulkDelete(ctx, f, req.DryRun)
+	params := map[string]string{
+		"user": req.User, "connection": req.Connection, "protocol": req.Protocol,
+		"class": req.Class, "status": req.Status, "dryRun": strconv.FormatBool(req.DryRun),
+	}
+	if !req.Since.IsZero() {
+		params["since"] = req.Since.UTC().Format(time.RFC3339)
+	}
+	if !req.Until.IsZero() {
+		params["until"] = req.Until.UTC().Format(time.RFC3339)
+	}
+	for k, v := range params {
+		if v == "" {
+			delete(params, k)
+		}
+	}
+	result := models.AuditAllowed
+	if err != nil {
+		result = models.AuditError
+	} else {
+		params["matched"] = strconv.Itoa(res.Matched)
+		params["deleted"] = strconv.Itoa(res.Deleted)
+		params["bytes"] = strconv.FormatInt(res.Bytes, 10)
+		params["protected"] = strconv.Itoa(len(res.Protected))
+		params["failed"] = strconv.Itoa(len(res.Failed))
+	}
+	s.deps.Audit.Record(ctx, audit.Event{
+		User: user, Event: recBulkDeleteEvent, RouteID: recBulkDeleteEvent,
+		Risk: string(plugin.RiskDestructive), Result: result, Params: params, Err: err,
+	})
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	defaultStorageTop = 10
+	maxStorageTop     = 100
+)
+
+type recordingStorageDTO struct {
+	GeneratedAt  time.Time           `json:"generatedAt"`
+	Total        recordingUsageDTO   `json:"total"`
+	ByUser       []recordingUsageDTO `json:"byUser"`
+	ByConnection []recordingUsageDTO `json:"byConnection"`
+}
+
+type recordingUsageDTO struct {
+	ID               string     `json:"id,omitempty"`
+	Name             string     `json:"name,omitempty"`
+	Bytes            int64      `json:"bytes"`
+	Count            int64      `json:"count"`
+	Oldest           *time.Time `json:"oldest,omitempty"`
+	Newest           *time.Time `json:"newest,omitempty"`
+	ReclaimableBytes int64      `json:"reclaimableBytes"`
+	ReclaimableCount int64      `json:"reclaimableCount"`
+}
+
+func toRecordingUsageDTO(g store.RecordingUsageGroup) recordingUsageDTO {
+	out := recordingUsageDTO{
+		ID: g.ID, Name: g.Name, Bytes: g.Bytes, Count: g.Count,
+		ReclaimableBytes: g.ReclaimableBytes, ReclaimableCount: g.ReclaimableCount,
+	}
+	if !g.Oldest.IsZero() {
+		out.Oldest, out.Newest = &g.Oldest, &g.Newest
+	}
+	return out
+}
+
+// handleAdminRecordingStorage reports where recording storage goes: the total
+// and the top users and connections by bytes, with what retention would free.
+func (s *Server) handleAdminRecordingStorage(w http.ResponseWriter, r *http.Request) {
+	top := defaultStorageTop
+	if v := r.URL.Query().Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxStorageTop {
+			writeError(w, s.deps.Logger, fmt.Errorf("%w: top must be 1-%d", plugin.ErrInvalidInput, maxStorageTop))
+			return
+		}
+		top = n
+	}
+	now := time.Now().UTC()
+	u, err := s.deps.Recordings.StorageReport(r.Context(), now, top)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	out := recordingStorageDTO{
+		GeneratedAt: now, Total: toRecordingUsageDTO(u.Total),
+		ByUser: []recordingUsageDTO{}, ByConnection: []recordingUsageDTO{},
+	}
+	for _, g := range u.ByUser {
+		out.ByUser = append(out.ByUser, toRecordingUsageDTO(g))
+	}
+	for _, g := range u.ByConnection {
+		out.ByConnection = append(out.ByConnection, toRecordingUsageDTO(g))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
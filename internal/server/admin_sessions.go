@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+
+	"github.com/charlesng35/shellcn/internal/service"
+)
+
+type activeSessionDTO struct {
+	ID           string    `json:"id"`
+	ConnectionID string    `json:"connectionId"`
+	UserID       string    `json:"userId"`
+	Protocol     string    `json:"protocol,omitempty"`
+	State        string    `json:"state"`
+	Channels     int       `json:"channels"`
+	Streams      int       `json:"streams"`
+	StartedAt    time.Time `json:"startedAt"`
+	LastUsed     time.Time `json:"lastUsed"`
+	BytesIn      int64     `json:"bytesIn"`
+	BytesOut     int64     `json:"bytesOut"`
+}
+
+// handleAdminActiveSessions lists the upstream sessions open on this instance,
+// oldest first, with the bytes each has relayed. ?format=csv or Accept:
+// text/csv downloads the same list, with ?columns= picking a subset and times
+// in the caller's time zone or ?tz.
+func (s *Server) handleAdminActiveSessions(w http.ResponseWriter, r *http.Request) {
+	active := s.deps.Sessions.Active()
+	sort.Slice(active, func(i, j int) bool { return active[i].CreatedAt.Before(active[j].CreatedAt) })
+	out := make([]activeSessionDTO, 0, len(active))
+	for _, snap := range active {
+		out = append(out, activeSessionDTO{
+			ID: snap.ID, ConnectionID: snap.Key.ConnectionID, UserID: snap.UserID,
+			Protocol: service.SnapshotProtocol(snap), State: string(snap.State),
+			Channels: snap.Channels, Streams: snap.Streams,
+			StartedAt: snap.CreatedAt, LastUsed: snap.LastUsed,
+			BytesIn: snap.BytesIn, BytesOut: snap.BytesOut,
+		})
+	}
+	if wantsCSV(r) {
+		s.writeActiveSessionsCSV(w, r, out)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// activeSessionRow is an active session with the names a report shows.
+type activeSessionRow struct {
+	activeSessionDTO
+	connectionName, username string
+	now                      time.Time
+}
+
+// activeSessionColumns writes timestamps in loc.
+func activeSessionColumns(loc *time.Location) []csvColumn[activeSessionRow] {
+	return []csvColumn[activeSessionRow]{
+		{"id", func(r activeSessionRow) string { return r.ID }},
+		{"connection_id", func(r activeSessionRow) string { return r.ConnectionID }},
+		{"connection_name", func(r activeSessionRow) string { return csvCell(r.connectionName) }},
+		{"protocol", func(r activeSessionRow) string { return r.Protocol }},
+		{"user_id", func(r activeSessionRow) string { return r.UserID }},
+		{"username", func(r activeSessionRow) string { return csvCell(r.username) }},
+		{"state", func(r activeSessionRow) string { return r.State }},
+		{"started_at", func(r activeSessionRow) string { return csvTime(r.StartedAt, loc) }},
+		{"duration_seconds", func(r activeSessionRow) string { return csvSeconds(r.now.Sub(r.StartedAt)) }},
+		{"bytes_in", func(r activeSessionRow) string { return strconv.FormatInt(r.BytesIn, 10) }},
+		{"bytes_out", func(r activeSessionRow) string { return strconv.FormatInt(r.BytesOut, 10) }},
+	}
+}
+
+func (s *Server) writeActiveSessionsCSV(w http.ResponseWriter, r *http.Request, list []activeSessionDTO) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	loc, err := s.exportLocation(r, user)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	cols, err := selectCSVColumns(activeSessionColumns(loc), r.URL.Query().Get("columns"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	connNames, usernames := map[string]string{}, map[string]string{}
+	now := time.Now()
+	export := newCSVExport(w, "active-sessions.csv", cols)
+	for _, dto := range list {
+		name, ok := connNames[dto.ConnectionID]
+		if !ok {
+			if c, err := s.deps.Store.Connections.Get(ctx, dto.ConnectionID); err == nil {
+				name = c.Name
+			}
+			connNames[dto.ConnectionID] = name
+		}
+		username, ok := usernames[dto.UserID]
+		if !ok {
+			if u, err := s.deps.Store.Users.GetByID(ctx, dto.UserID); err == nil {
+				username = u.Username
+			}
+			usernames[dto.UserID] = username
+		}
+		if err := export.row(activeSessionRow{activeSessionDTO: dto, connectionName: name, username: username, now: now}); err != nil {
+			break
+		}
+	}
+	_ = export.flush()
+}
+
+type userSessionCountDTO struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username,omitempty"`
+	Sessions int    `json:"sessions"`
+	// Limit is the user's session cap; 0 means none.
+	Limit int `json:"limit"`
+}
+
+// handleAdminSessionsByUser counts the sessions each user has open on this
+// instance, busiest first, next to the cap that applies to them.
+func (s *Server) handleAdminSessionsByUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	counts := map[string]int{}
+	for _, snap := range s.deps.Sessions.Active() {
+		counts[snap.UserID]++
+	}
+	out := make([]userSessionCountDTO, 0, len(counts))
+	for id, n := range counts {
+		item := userSessionCountDTO{UserID: id, Sessions: n, Limit: service.DefaultMaxSessionsPerUser}
+		if u, err := s.deps.Store.Users.GetByID(ctx, id); err == nil {
+			item.Username = u.Username
+			item.Limit = s.sessionLimit(ctx, u)
+		}
+		out = append(out, item)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Sessions != out[j].Sessions {
+			return out[i].Sessions > out[j].Sessions
+		}
+		return out[i].UserID < out[j].UserID
+	})
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) sessionLimit(ctx context.Context, u models.User) int {
+	if s.deps.SessionCaps == nil {
+		return service.DefaultMaxSessionsPerUser
+	}
+	limit, err := s.deps.SessionCaps.Limit(ctx, u)
+	if err != nil {
+		return service.DefaultMaxSessionsPerUser
+	}
+	return limit
+}
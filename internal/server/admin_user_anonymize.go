@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const userAnonymizeEvent = "user.anonymize"
+
+const (
+	defaultAnonymizeBatch = 1000
+	maxAnonymizeBatch     = 10000
+)
+
+type anonymizeUserRequest struct {
+	// Confirm must repeat the target's current username; after the first
+	// pass that is the tombstone the report returned.
+	Confirm string `json:"confirm"`
+	Batch   int    `json:"batch"`
+}
+
+// handleAdminAnonymizeUser scrubs one batch of a user's personal data. Only
+// the root admin may run it, and it cannot be undone. A report with done=false
+// asks the caller to repeat the request until the history is clean.
+func (s *Server) handleAdminAnonymizeUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actor, _ := userFrom(ctx)
+	if !actor.Protected {
+		s.auditAdminEvent(ctx, actor, userAnonymizeEvent, models.AuditDenied, nil, plugin.ErrForbidden)
+		writeError(w, s.deps.Logger, errForbidden("only the root admin may anonymize a user"))
+		return
+	}
+	var req anonymizeUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	if req.Batch == 0 {
+		req.Batch = defaultAnonymizeBatch
+	}
+	if req.Batch < 0 || req.Batch > maxAnonymizeBatch {
+		writeError(w, s.deps.Logger, fmt.Errorf("%w: batch must be between 1 and %d", plugin.ErrInvalidInput, maxAnonymizeBatch))
+		return
+	}
+	target, err := s.deps.Users.Get(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	if req.Confirm == "" || req.Confirm != target.Username {
+		writeError(w, s.deps.Logger, fmt.Errorf("%w: confirm must repeat the user's username", plugin.ErrInvalidInput))
+		return
+	}
+
+	report, err := s.deps.Users.Anonymize(ctx, target.ID, req.Batch)
+	// The audit row names the user by id only: their old username is the
+	// data being removed.
+	params := map[string]string{"userId": target.ID, "batch": strconv.Itoa(req.Batch)}
+	for table, n := range report.Rows {
+		params["rows."+table] = strconv.FormatInt(n, 10)
+	}
+	if err != nil {
+		result := models.AuditError
+		if errors.Is(err, plugin.ErrForbidden) {
+			result = models.AuditDenied
+		}
+		s.auditAdminEvent(ctx, actor, userAnonymizeEvent, result, params, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	params["done"] = strconv.FormatBool(report.Done)
+	s.auditAdminEvent(ctx, actor, userAnonymizeEvent, models.AuditAllowed, params, nil)
+	writeJSON(w, http.StatusOK, report)
+}
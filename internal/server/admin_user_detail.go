@@ -1,6 +1,7 @@
 package server
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -24,9 +25,12 @@ type auditEntryDTO struct {
 	Risk         string            `json:"risk,omitempty"`
 	Result       string            `json:"result"`
 	ConnectionID string            `json:"connectionId,omitempty"`
+	ResourceType string            `json:"resourceType,omitempty"`
+	ResourceID   string            `json:"resourceId,omitempty"`
 	Params       map[string]string `json:"params,omitempty"`
 	Error        string            `json:"error,omitempty"`
 	RemoteAddr   string            `json:"remoteAddr,omitempty"`
+	RequestID    string            `json:"requestId,omitempty"`
 }
 
 type auditPage struct {
@@ -37,28 +41,43 @@ type auditPage struct {
 func toAuditEntryDTO(e models.AuditEntry) auditEntryDTO {
 	return auditEntryDTO{
 		ID: e.ID, Time: e.Time, Event: e.Event, Risk: e.Risk,
-		Result: string(e.Result), ConnectionID: e.ConnectionID,
-		Params: e.Params, Error: e.Error, RemoteAddr: e.RemoteAddr,
+		Result: string(e.Result), ConnectionID: e.ConnectionID, ResourceType: e.ResourceType, ResourceID: e.ResourceID,
+		Params: e.Params, Error: e.Error, RemoteAddr: e.RemoteAddr, RequestID: e.RequestID,
 	}
 }
 
-// writeAuditPage serves a paginated audit slice for one user.
-func (s *Server) writeAuditPage(w http.ResponseWriter, r *http.Request, userID string) {
-	limit := defaultAuditPageSize
+// auditPageParams reads the limit/offset query parameters shared by the
+// paginated audit-style endpoints.
+func auditPageParams(r *http.Request) (limit, offset int) {
+	limit = defaultAuditPageSize
 	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= maxAuditPageSize {
 		limit = v
 	}
-	offset := 0
 	if v, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && v > 0 {
 		offset = v
 	}
+	return limit, offset
+}
+
+// writeAuditPage serves a paginated audit slice matching base. Unless base
+// already names a resource, ?resource_type= and ?resource_id= narrow it.
+func (s *Server) writeAuditPage(w http.ResponseWriter, r *http.Request, base store.AuditFilter) {
+	if base.ResourceType == "" {
+		base.ResourceType, base.ResourceID = r.URL.Query().Get("resource_type"), r.URL.Query().Get("resource_id")
+	}
+	if base.ResourceID != "" && base.ResourceType == "" {
+		writeError(w, s.deps.Logger, fmt.Errorf("%w: resource_id needs resource_type", plugin.ErrInvalidInput))
+		return
+	}
+	f := base
+	f.Limit, f.Offset = auditPageParams(r)
 
-	entries, err := s.deps.Store.Audit.List(r.Context(), store.AuditFilter{UserID: userID, Limit: limit, Offset: offset})
+	entries, err := s.deps.Store.Audit.List(r.Context(), f)
 	if err != nil {
 		writeError(w, s.deps.Logger, err)
 		return
 	}
-	total, err := s.deps.Store.Audit.Count(r.Context(), store.AuditFilter{UserID: userID})
+	total, err := s.deps.Store.Audit.Count(r.Context(), base)
 	if err != nil {
 		writeError(w, s.deps.Logger, err)
 		return
@@ -76,7 +95,7 @@ func (s *Server) handleAdminUserAudit(w http.ResponseWriter, r *http.Request) {
 		writeError(w, s.deps.Logger, err)
 		return
 	}
-	s.writeAuditPage(w, r, user.ID)
+	s.writeAuditPage(w, r, store.AuditFilter{UserID: user.ID})
 }
 
 type userConnectionDTO struct {
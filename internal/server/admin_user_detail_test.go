@@ -1,9 +1,13 @@
 package server_test
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"strings"
 	"testing"
+
+	"github.com/charlesng35/shellcn/internal/store"
 )
 
 func TestAdminUserDetailEndpoints(t *testing.T) {
@@ -46,3 +50,63 @@ func TestAdminUserDetailEndpoints(t *testing.T) {
 		}
 	}
 }
+
+func TestConnectionAuditTab(t *testing.T) {
+	h := newHarness(t)
+	h.do(t, http.MethodGet, "/api/connections/c-op/x/tester.list", "op", nil)
+	h.do(t, http.MethodGet, "/api/connections/c-boom/x/boom.list", "op", nil)
+	if resp := h.do(t, http.MethodPost, "/api/connections/c-op/grants", "op", strings.NewReader(`{"subjectId":"viewer","access":"view"}`)); resp.Status != http.StatusCreated {
+		t.Fatalf("grant: %d (%s)", resp.Status, resp.Body)
+	}
+	h.do(t, http.MethodGet, "/api/connections/c-op/x/tester.list", "viewer", nil)
+
+	page := func(path, user string) auditPageBody {
+		t.Helper()
+		resp := h.do(t, http.MethodGet, path, user, nil)
+		if resp.Status != http.StatusOK {
+			t.Fatalf("%s as %s: status=%d body=%s", path, user, resp.Status, resp.Body)
+		}
+		var p auditPageBody
+		if err := json.Unmarshal(resp.Body, &p); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return p
+	}
+
+	owner := page("/api/connections/c-op/audit", "op")
+	for _, e := range owner.Items {
+		if e.ResourceType != "connection" || e.ResourceID != "c-op" {
+			t.Errorf("owner tab has foreign entry: %+v", e)
+		}
+	}
+	if owner.Total < 3 || int(owner.Total) != len(owner.Items) {
+		t.Fatalf("owner tab: total=%d items=%d", owner.Total, len(owner.Items))
+	}
+
+	grantee := page("/api/connections/c-op/audit", "viewer")
+	own, _ := h.store.Audit.Count(context.Background(), store.AuditFilter{UserID: "viewer", ResourceType: "connection", ResourceID: "c-op"})
+	if own == 0 || grantee.Total != own || grantee.Total >= owner.Total {
+		t.Fatalf("grantee tab: want only their own %d entries, got %+v", own, grantee)
+	}
+
+	if resp := h.do(t, http.MethodGet, "/api/connections/c-op/audit", "op2", nil); resp.Status != http.StatusNotFound {
+		t.Fatalf("stranger: want 404, got %d", resp.Status)
+	}
+
+	mine := page("/api/audit/me?resource_type=connection&resource_id=c-boom", "op")
+	// The route and its failed session open.
+	if mine.Total != 2 || mine.Items[0].ResourceID != "c-boom" || mine.Items[1].ResourceID != "c-boom" {
+		t.Fatalf("filtered own audit: %+v", mine)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/audit/me?resource_id=c-boom", "op", nil); resp.Status != http.StatusBadRequest {
+		t.Fatalf("resource_id without type: want 400, got %d", resp.Status)
+	}
+}
+
+type auditPageBody struct {
+	Items []struct {
+		ResourceType string `json:"resourceType"`
+		ResourceID   string `json:"resourceId"`
+	} `json:"items"`
+	Total int64 `json:"total"`
+}
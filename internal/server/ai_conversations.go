@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/charlesng35/shellcn/internal/ai/memory"
 	"github.com/charlesng35/shellcn/internal/models"
 	"github.com/charlesng35/shellcn/sdk/plugin"
 )
@@ -43,15 +44,26 @@ func (s *Server) handleListConversations(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, http.StatusOK, list)
 }
 
+type createConversationRequest struct {
+	ProviderID string `json:"providerId"`
+}
+
+type renameConversationRequest struct {
+	Title string `json:"title"`
+}
+
+type conversationDTO struct {
+	Conversation models.AIConversation `json:"conversation"`
+	Page         memory.MessagePage    `json:"page"`
+}
+
 func (s *Server) handleCreateConversation(w http.ResponseWriter, r *http.Request) {
 	conn, ok := s.aiConn(w, r)
 	if !ok {
 		return
 	}
 	user, _ := userFrom(r.Context())
-	var req struct {
-		ProviderID string `json:"providerId"`
-	}
+	var req createConversationRequest
 	_ = json.NewDecoder(r.Body).Decode(&req)
 	model, err := s.aiConversationModel(r.Context(), user.ID, req.ProviderID)
 	if err != nil {
@@ -87,7 +99,7 @@ func (s *Server) handleGetConversation(w http.ResponseWriter, r *http.Request) {
 		writeError(w, s.deps.Logger, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]any{"conversation": conv, "page": page})
+	writeJSON(w, http.StatusOK, conversationDTO{Conversation: conv, Page: page})
 }
 
 func (s *Server) handleConversationMessages(w http.ResponseWriter, r *http.Request) {
@@ -125,9 +137,7 @@ func (s *Server) handleRenameConversation(w http.ResponseWriter, r *http.Request
 	if !s.aiConversationBelongsToConnection(r.Context(), user.ID, chi.URLParam(r, "cid"), conn.ID, w) {
 		return
 	}
-	var req struct {
-		Title string `json:"title"`
-	}
+	var req renameConversationRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
 		return
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/policy"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	auditViewPermission = "audit.view"
+	auditStreamEvent    = "audit.stream"
+	// auditStreamHeartbeat keeps idle proxies from closing the stream.
+	auditStreamHeartbeat = 15 * time.Second
+	// maxAuditReplay bounds the entries a resumed stream sends from the
+	// store; a longer gap starts with a "truncated" event instead.
+	maxAuditReplay = 1000
+)
+
+// auditStreamFilter narrows the live tail before entries are sent.
+type auditStreamFilter struct {
+	eventPrefix  string
+	userID       string
+	resourceType string
+}
+
+func (f auditStreamFilter) matches(e models.AuditEntry) bool {
+	return strings.HasPrefix(e.Event, f.eventPrefix) &&
+		(f.userID == "" || e.UserID == f.userID) &&
+		(f.resourceType == "" || e.ResourceType == f.resourceType)
+}
+
+// handleAuditStream sends audit entries as server-sent events as they are
+// written, narrowed by ?action (event prefix), ?user_id and ?resource_type.
+// Each event's id resumes the stream: a client reconnecting with
+// Last-Event-ID first gets the matching entries it missed, read from the
+// store. A client that falls too far behind is disconnected and resumes the
+// same way.
+func (s *Server) handleAuditStream(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	if err := s.deps.Policy.Authorize(policy.AccessInput{User: user, Permission: auditViewPermission, Risk: plugin.RiskPrivileged}); err != nil {
+		s.auditAdminEvent(ctx, user, auditStreamEvent, models.AuditDenied, nil, err)
+		s.incAuthzFailure(err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	q := r.URL.Query()
+	filter := auditStreamFilter{eventPrefix: q.Get("action"), userID: q.Get("user_id"), resourceType: q.Get("resource_type")}
+	s.auditAdminEvent(ctx, user, auditStreamEvent, models.AuditAllowed,
+		map[string]string{"action": filter.eventPrefix, "userId": filter.userID, "resourceType": filter.resourceType}, nil)
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = q.Get("lastEventId")
+	}
+	var since time.Time
+	var sinceID string
+	if lastID != "" {
+		var err error
+		if since, sinceID, err = parseAuditEventID(lastID); err != nil {
+			writeError(w, s.deps.Logger, err)
+			return
+		}
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, s.deps.Logger, errors.New("streaming response unsupported"))
+		return
+	}
+
+	// Subscribe before reading the store so nothing written in between is
+	// missed; replayed ids are skipped when they come through live as well.
+	live, unsubscribe := s.deps.AuditTail.Subscribe()
+	defer unsubscribe()
+	var replay []models.AuditEntry
+	truncated := false
+	if lastID != "" {
+		var err error
+		if replay, truncated, err = s.auditReplay(r, filter, since, sinceID); err != nil {
+			writeError(w, s.deps.Logger, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if truncated {
+		_, _ = fmt.Fprint(w, "event: truncated\ndata: {}\n\n")
+	}
+	sent := make(map[string]bool, len(replay))
+	for _, e := range replay {
+		sent[e.ID] = true
+		if writeAuditSSE(w, e) != nil {
+			return
+		}
+	}
+	_, _ = fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(auditStreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-live:
+			if !ok {
+				return
+			}
+			if sent[e.ID] || !filter.matches(e) {
+				continue
+			}
+			if writeAuditSSE(w, e) != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// auditReplay reads the matching entries written after the one a resuming
+// client saw last, oldest first. The store may keep times at a coarser
+// precision than the event id, so it reads a little earlier and drops
+// everything up to the last seen entry.
+func (s *Server) auditReplay(r *http.Request, filter auditStreamFilter, since time.Time, sinceID string) ([]models.AuditEntry, bool, error) {
+	list, err := s.deps.Store.Audit.List(r.Context(), store.AuditFilter{
+		UserID: filter.userID, ResourceType: filter.resourceType,
+		Since: since.Add(-time.Second), Limit: maxAuditReplay + 1,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	truncated := len(list) > maxAuditReplay
+	if truncated {
+		list = list[:maxAuditReplay]
+	}
+	slices.Reverse(list)
+	if i := slices.IndexFunc(list, func(e models.AuditEntry) bool { return e.ID == sinceID }); i >= 0 {
+		list = list[i+1:]
+	} else {
+		list = slices.DeleteFunc(list, func(e models.AuditEntry) bool { return !e.Time.After(since) })
+	}
+	return slices.DeleteFunc(list, func(e models.AuditEntry) bool { return !filter.matches(e) }), truncated, nil
+}
+
+func writeAuditSSE(w http.ResponseWriter, e models.AuditEntry) error {
+	data, err := json.Marshal(toAuditEntryDTO(e))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: audit\ndata: %s\n\n", auditEventID(e), data)
+	return err
+}
+
+// auditEventID carries the entry's time so a resume can find its place in
+// the store without looking the entry up.
+func auditEventID(e models.AuditEntry) string {
+	return strconv.FormatInt(e.Time.UnixNano(), 10) + "." + e.ID
+}
+
+func parseAuditEventID(v string) (time.Time, string, error) {
+	ns, id, ok := strings.Cut(v, ".")
+	n, err := strconv.ParseInt(ns, 10, 64)
+	if !ok || err != nil || id == "" {
+		return time.Time{}, "", fmt.Errorf("%w: malformed Last-Event-ID", plugin.ErrInvalidInput)
+	}
+	return time.Unix(0, n), id, nil
+}
@@ -39,6 +39,9 @@ type userDTO struct {
 	Roles            []models.Role `json:"roles"`
 	Protected        bool          `json:"protected"`
 	TwoFactorEnabled bool          `json:"twoFactorEnabled"`
+	// MustChangePassword means every other API call fails until the user
+	// changes their password.
+	MustChangePassword bool `json:"mustChangePassword,omitempty"`
 }
 
 type sessionDTO struct {
@@ -64,6 +67,7 @@ func toUserDTO(u models.User) userDTO {
 	return userDTO{
 		ID: u.ID, Username: u.Username, DisplayName: u.DisplayName, Email: u.Email,
 		Roles: roles, Protected: u.Protected, TwoFactorEnabled: u.TOTPEnabled,
+		MustChangePassword: u.MustChangePassword,
 	}
 }
 
@@ -1,14 +1,19 @@
 package server
 
 import (
+	"cmp"
 	"context"
+	"fmt"
 	"net/http"
-	"sort"
+	"net/url"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
 	"github.com/charlesng35/shellcn/sdk/plugin"
 )
 
@@ -48,15 +53,49 @@ func (s *Server) handleGetPlugin(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, proj)
 }
 
+// handleGetPluginTemplate serves the protocol's connection form so clients
+// build it from what the plugin declares. The schema hash doubles as an ETag.
+func (s *Server) handleGetPluginTemplate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	name := chi.URLParam(r, "name")
+	tmpl, ok := s.deps.Plugins.Template(name)
+	if !ok {
+		writeError(w, s.deps.Logger, plugin.ErrNotFound)
+		return
+	}
+	if s.deps.Protocols != nil {
+		user, _ := userFrom(ctx)
+		states, err := s.deps.Protocols.States(ctx)
+		if err != nil {
+			writeError(w, s.deps.Logger, err)
+			return
+		}
+		if !states[name].Allows(user.HasRole(models.RoleAdmin)) {
+			writeError(w, s.deps.Logger, plugin.ErrNotFound)
+			return
+		}
+	}
+	etag := `"` + tmpl.Hash + `"`
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, http.StatusOK, tmpl)
+}
+
 type connectionDTO struct {
-	ID                 string            `json:"id"`
-	Name               string            `json:"name"`
-	Protocol           string            `json:"protocol"`
-	Icon               *plugin.Icon      `json:"icon,omitempty"`
-	Transport          string            `json:"transport"`
-	Config             map[string]any    `json:"config,omitempty"`
-	Online             bool              `json:"online"`
-	Status             string            `json:"status,omitempty"`
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Protocol  string         `json:"protocol"`
+	Icon      *plugin.Icon   `json:"icon,omitempty"`
+	Transport string         `json:"transport"`
+	Config    map[string]any `json:"config,omitempty"`
+	Online    bool           `json:"online"`
+	Status    string         `json:"status,omitempty"`
+	// ProtocolBlocked marks a connection whose protocol an admin has since made
+	// unavailable to this user; it stays listed but cannot be launched.
+	ProtocolBlocked    bool              `json:"protocolBlocked,omitempty"`
 	CanManage          bool              `json:"canManage"`
 	CanShare           bool              `json:"canShare"`
 	Access             string            `json:"access"`
@@ -68,8 +107,15 @@ type connectionDTO struct {
 	AIMode             models.AIMode     `json:"aiMode,omitempty"`
 	AIAllowDestructive bool              `json:"aiAllowDestructive,omitempty"`
 	AIAutoApprove      bool              `json:"aiAutoApprove,omitempty"`
+	AllowFileTransfer  bool              `json:"allowFileTransfer"`
+	AllowClipboard     bool              `json:"allowClipboard"`
 	FolderID           string            `json:"folderId,omitempty"`
 	SortOrder          int               `json:"sortOrder"`
+	IsFavorite         bool              `json:"isFavorite"`
+	FavoritePosition   *int              `json:"favoritePosition,omitempty"`
+	LastUsedAt         *time.Time        `json:"lastUsedAt,omitempty"`
+	// ShareExpiresInSeconds counts down a temporary share to the caller.
+	ShareExpiresInSeconds int64 `json:"shareExpiresInSeconds,omitempty"`
 }
 
 func (s *Server) handleListConnections(w http.ResponseWriter, r *http.Request) {
@@ -92,10 +138,47 @@ func (s *Server) handleListConnections(w http.ResponseWriter, r *http.Request) {
 	for _, p := range placements {
 		placementByConnection[p.ConnectionID] = p
 	}
+	// Favorites are keyed off the accessible list, so a pin on a connection
+	// the user has since lost is simply not shown.
+	favorites, err := s.deps.Store.ConnectionFavorites.ListByUser(ctx, user.ID)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	favoritePosition := map[string]int{}
+	for i, f := range favorites {
+		favoritePosition[f.ConnectionID] = i
+	}
+	favoritesOnly := r.URL.Query().Get("favorites_only") == "true"
+	inFolders, err := s.connectionFolderScope(ctx, user, r.URL.Query())
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	var states map[string]models.ProtocolAvailability
+	if s.deps.Protocols != nil {
+		if states, err = s.deps.Protocols.States(ctx); err != nil {
+			writeError(w, s.deps.Logger, err)
+			return
+		}
+	}
+	isAdmin := user.HasRole(models.RoleAdmin)
 	names := map[string]string{}
 	for _, c := range conns {
+		pos, favorite := favoritePosition[c.ID]
+		if favoritesOnly && !favorite {
+			continue
+		}
+		if inFolders != nil && !inFolders[placementByConnection[c.ID].FolderID] {
+			continue
+		}
 		dto := s.toConnectionDTO(c)
+		if favorite {
+			dto.IsFavorite = true
+			dto.FavoritePosition = &pos
+		}
 		s.decorateConnectionAccess(ctx, user, c, &dto, names)
+		dto.ProtocolBlocked = !states[c.Protocol].Allows(isAdmin)
 		if p, ok := placementByConnection[c.ID]; ok {
 			dto.FolderID = p.FolderID
 			dto.SortOrder = p.SortOrder
@@ -105,6 +188,27 @@ func (s *Server) handleListConnections(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, out)
 }
 
+// connectionFolderScope resolves ?folder= to the folders a listed connection
+// must be placed in, adding nested folders with ?recursive=true. Nil means the
+// list is not filtered by folder.
+func (s *Server) connectionFolderScope(ctx context.Context, user models.User, q url.Values) (map[string]bool, error) {
+	id := q.Get("folder")
+	if id == "" {
+		return nil, nil
+	}
+	folders, err := s.deps.Store.ConnectionFolders.ListByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(folders, func(f models.ConnectionFolder) bool { return f.ID == id }) {
+		return nil, fmt.Errorf("%w: unknown folder %q", plugin.ErrInvalidInput, id)
+	}
+	if q.Get("recursive") != "true" {
+		return map[string]bool{id: true}, nil
+	}
+	return service.FolderSubtree(folders, id), nil
+}
+
 func (s *Server) decorateConnectionAccess(ctx context.Context, user models.User, c models.Connection, dto *connectionDTO, names map[string]string) {
 	dto.Owned = c.OwnerID == user.ID
 	dto.Access = string(models.AccessView)
@@ -113,6 +217,7 @@ func (s *Server) decorateConnectionAccess(ctx context.Context, user models.User,
 	} else if g, err := s.deps.Store.Grants.Get(ctx, c.ID, user.ID); err == nil {
 		dto.Access = string(g.Access)
 		dto.SharedWithMe = true
+		dto.ShareExpiresInSeconds = expiresInSeconds(g.ExpiresAt)
 		dto.OwnerName = s.displayName(ctx, c.OwnerID, names)
 	}
 	dto.CanManage = s.canAdminConnection(user, c)
@@ -170,7 +275,9 @@ func (s *Server) accessibleConnections(ctx context.Context, user models.User) ([
 		seen[c.ID] = true
 		out = append(out, c)
 	}
-	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	slices.SortFunc(out, func(a, b models.Connection) int {
+		return cmp.Or(strings.Compare(a.NameSort, b.NameSort), strings.Compare(a.Name, b.Name))
+	})
 	return out, nil
 }
 
@@ -190,6 +297,10 @@ func (s *Server) handleListCredentials(w http.ResponseWriter, r *http.Request) {
 	if summaries == nil {
 		summaries = []models.CredentialSummary{}
 	}
+	if err := s.deps.Credentials.Personalize(r.Context(), user.ID, summaries, r.URL.Query().Get("sort")); err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
 	names := map[string]string{}
 	for i := range summaries {
 		if summaries[i].OwnerID != "" && summaries[i].OwnerID != user.ID {
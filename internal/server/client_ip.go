@@ -0,0 +1,83 @@
+package server
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/charlesng35/shellcn/internal/models"
+)
+
+const maxUserAgent = 256
+
+// GeoLocator maps a client address to a location. Lookups run on every
+// request, so implementations should answer from memory; empty strings mean
+// unknown.
+type GeoLocator interface {
+	Locate(ip netip.Addr) (country, city string)
+}
+
+// clientInfo describes the caller of r: its resolved address, user agent and,
+// when Geo is set, location.
+func (s *Server) clientInfo(r *http.Request) models.ClientInfo {
+	c := models.ClientInfo{IP: s.resolveClientIP(r), UserAgent: r.UserAgent()}
+	if len(c.UserAgent) > maxUserAgent {
+		c.UserAgent = strings.ToValidUTF8(c.UserAgent[:maxUserAgent], "")
+	}
+	if s.deps.Geo != nil {
+		if addr, err := netip.ParseAddr(c.IP); err == nil {
+			c.Country, c.City = s.deps.Geo.Locate(addr)
+		}
+	}
+	return c
+}
+
+// resolveClientIP returns the direct peer unless it is a trusted proxy, in
+// which case X-Forwarded-For is walked right to left past further trusted
+// hops; the first untrusted address is the client, and a malformed hop stops
+// the walk at the last good one. X-Real-IP is the fallback
+// when the proxy sends no X-Forwarded-For.
+func (s *Server) resolveClientIP(r *http.Request) string {
+	peer := peerIP(r)
+	if !s.trustedProxy(peer) {
+		return peer
+	}
+	var hops []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(h, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			return client
+		}
+		client = addr.Unmap().String()
+		if !s.trustedAddr(addr) {
+			return client
+		}
+	}
+	if len(hops) == 0 {
+		if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+			return addr.Unmap().String()
+		}
+	}
+	return client
+}
+
+func (s *Server) trustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	return err == nil && s.trustedAddr(addr)
+}
+
+func (s *Server) trustedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range s.deps.TrustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/netip"
+	"testing"
+
+	"github.com/charlesng35/shellcn/internal/server"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+type stubGeo struct{}
+
+func (stubGeo) Locate(ip netip.Addr) (string, string) {
+	if ip.String() == "203.0.113.5" {
+		return "NZ", "Wellington"
+	}
+	return "", ""
+}
+
+func auditAddr(t *testing.T, h *harness, event string) string {
+	t.Helper()
+	rows, _ := h.store.Audit.List(context.Background(), store.AuditFilter{})
+	for _, r := range rows {
+		if r.Event == event {
+			return r.RemoteAddr
+		}
+	}
+	t.Fatalf("no %s audit row", event)
+	return ""
+}
+
+func listWithForwardedFor(t *testing.T, h *harness, xff string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, h.ts.URL+"/api/connections/c-op/x/tester.list", nil)
+	req.Header.Set("X-Forwarded-For", xff)
+	req.Header.Set("User-Agent", "client-ip-test")
+	if resp := h.doReq(t, req, "op"); resp.Status != http.StatusOK {
+		t.Fatalf("list: got %d (%s)", resp.Status, resp.Body)
+	}
+}
+
+func TestForwardedForIgnoredFromUntrustedPeer(t *testing.T) {
+	h := newHarness(t)
+	listWithForwardedFor(t, h, "203.0.113.5")
+	if got := auditAddr(t, h, "tester.list"); got != "127.0.0.1" {
+		t.Fatalf("untrusted peer: audit remote addr = %q, want the peer", got)
+	}
+}
+
+func TestForwardedForResolvedThroughTrustedProxies(t *testing.T) {
+	h := newHarness(t, func(d *server.Deps) {
+		d.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("10.0.0.0/8")}
+		d.Geo = stubGeo{}
+	})
+	// The leftmost hop is client-supplied and must not win over the first
+	// untrusted one.
+	listWithForwardedFor(t, h, "198.51.100.1, 203.0.113.5, 10.1.2.3")
+	if got := auditAddr(t, h, "tester.list"); got != "203.0.113.5" {
+		t.Fatalf("audit remote addr = %q, want 203.0.113.5", got)
+	}
+
+	resp := h.do(t, http.MethodGet, "/api/connections/c-op/session", "op", nil)
+	var out struct {
+		State  string `json:"state"`
+		Client struct {
+			IP        string `json:"ip"`
+			UserAgent string `json:"userAgent"`
+			Country   string `json:"country"`
+			City      string `json:"city"`
+		} `json:"client"`
+	}
+	if err := json.Unmarshal(resp.Body, &out); err != nil {
+		t.Fatalf("decode: %v (%s)", err, resp.Body)
+	}
+	if out.Client.IP != "203.0.113.5" || out.Client.UserAgent != "client-ip-test" || out.Client.Country != "NZ" || out.Client.City != "Wellington" {
+		t.Fatalf("session client = %+v (%s)", out.Client, resp.Body)
+	}
+}
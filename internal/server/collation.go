@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	collationEvent         = "system.collation"
+	collationBackfillEvent = "system.collation_backfill"
+)
+
+type collationDTO struct {
+	Locale string `json:"locale"`
+	// Updated is how many sort keys the change or backfill rewrote.
+	Updated *int `json:"updated,omitempty"`
+}
+
+type collationRequest struct {
+	Locale string `json:"locale"`
+}
+
+func (s *Server) handleGetCollation(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, collationDTO{Locale: s.deps.Collation.Locale()})
+}
+
+func (s *Server) handleSetCollation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actor, _ := userFrom(ctx)
+	var req collationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	params := map[string]string{"locale": req.Locale}
+	n, err := s.deps.Collation.SetLocale(ctx, actor, req.Locale)
+	if err != nil {
+		s.auditAdminEvent(ctx, actor, collationEvent, models.AuditError, params, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	params["updated"] = strconv.Itoa(n)
+	s.auditAdminEvent(ctx, actor, collationEvent, models.AuditAllowed, params, nil)
+	writeJSON(w, http.StatusOK, collationDTO{Locale: s.deps.Collation.Locale(), Updated: &n})
+}
+
+func (s *Server) handleBackfillCollation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actor, _ := userFrom(ctx)
+	n, err := s.deps.Collation.Backfill(ctx)
+	if err != nil {
+		s.auditAdminEvent(ctx, actor, collationBackfillEvent, models.AuditError, nil, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditAdminEvent(ctx, actor, collationBackfillEvent, models.AuditAllowed, map[string]string{"updated": strconv.Itoa(n)}, nil)
+	writeJSON(w, http.StatusOK, collationDTO{Locale: s.deps.Collation.Locale(), Updated: &n})
+}
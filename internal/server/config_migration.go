@@ -0,0 +1,26 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/charlesng35/shellcn/internal/models"
+)
+
+const connConfigMigrateEvent = "connection.config_migrate"
+
+// handleAdminMigrateConnectionConfigs rewrites stored connections saved under
+// an older config version of their protocol and reports the ones it could not.
+func (s *Server) handleAdminMigrateConnectionConfigs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actor, _ := userFrom(ctx)
+	rep, err := s.deps.Connections.MigrateConfigs(ctx)
+	params := map[string]string{"updated": strconv.Itoa(rep.Updated), "unmigrated": strconv.Itoa(len(rep.Unmigrated))}
+	if err != nil {
+		s.auditAdminEvent(ctx, actor, connConfigMigrateEvent, models.AuditError, params, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditAdminEvent(ctx, actor, connConfigMigrateEvent, models.AuditAllowed, params, nil)
+	writeJSON(w, http.StatusOK, rep)
+}
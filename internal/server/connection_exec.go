@@ -0,0 +1,212 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/charlesng35/shellcn/internal/audit"
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/recording"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	execOutputLimit    = 1 << 20
+	defaultExecTimeout = time.Minute
+	maxExecTimeout     = 10 * time.Minute
+	maxExecCommand     = 64 << 10
+)
+
+var execRoute = plugin.Route{
+	ID: "connection.exec", Permission: "connection.exec", Risk: plugin.RiskPrivileged, AuditEvent: "connection.exec",
+}
+
+type execRequest struct {
+	Command        string            `json:"command"`
+	TimeoutSeconds int               `json:"timeoutSeconds"`
+	Env            map[string]string `json:"env"`
+}
+
+type execResultDTO struct {
+	ExitCode    int    `json:"exitCode"`
+	Stdout      string `json:"stdout"`
+	Stderr      string `json:"stderr"`
+	Truncated   bool   `json:"truncated"`
+	TimedOut    bool   `json:"timedOut"`
+	DurationMS  int64  `json:"durationMs"`
+	RecordingID string `json:"recordingId,omitempty"`
+}
+
+// handleExecConnection runs one command on the connection without a terminal
+// and returns its exit status and captured output. It borrows the caller's
+// session like any launch, so session and channel limits, credential use and
+// recording policy all apply.
+func (s *Server) handleExecConnection(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	conn, err := s.deps.Store.Connections.Get(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	res := resolved{user: user, conn: conn, route: execRoute}
+	if err := s.authorize(ctx, user, conn, res.route); err != nil {
+		s.auditEvent(ctx, res, models.AuditDenied, err)
+		s.incAuthzFailure(err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	var req execRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	timeout, err := req.validate()
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	res.params = map[string]string{"command": req.Command, "env": strings.Join(slices.Sorted(maps.Keys(req.Env)), ",")}
+	manifest, ok := s.deps.Plugins.Manifest(conn.Protocol)
+	if !ok || !manifest.HasCapability(plugin.CapabilityExec) {
+		err := fmt.Errorf("%w: %s connections cannot run commands", plugin.ErrNotSupported, conn.Protocol)
+		s.auditEvent(ctx, res, models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	if s.proxyIfRemoteLeaseHolder(w, r, conn, user.ID) {
+		return
+	}
+	handle, err := s.acquireSession(ctx, res)
+	if err != nil {
+		s.auditEvent(ctx, res, models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+
+	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	pending, err := s.deps.Recording.Prepare(execCtx, recording.StreamInfo{
+		User: user, Connection: conn, Manifest: manifest, Route: execRoute,
+		StreamID: execRecordingStream(manifest), Params: map[string]string{"exec": uuid.NewString()},
+		Cols: 80, Rows: 24, Title: req.Command, RemoteAddr: clientIP(r), Client: audit.ClientFrom(r.Context()),
+		ConnectionSnapshot: s.connectionSnapshot(user.ID, conn.ID),
+	})
+	if err != nil {
+		s.auditEvent(ctx, res, models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	defer pending.Finish()
+	recorded := pending.Attach(execStream{ctx: execCtx})
+	if err := pending.Begin(execCtx); err != nil {
+		s.auditEvent(ctx, res, models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+
+	var recMu sync.Mutex
+	stdout, stderr := &cappedBuffer{limit: execOutputLimit}, &cappedBuffer{limit: execOutputLimit}
+	started := time.Now()
+	code, err := handle.Exec(execCtx, plugin.ExecRequest{
+		Command: req.Command, Env: req.Env,
+		Stdout: io.MultiWriter(stdout, &lockedWriter{mu: &recMu, w: recorded}),
+		Stderr: io.MultiWriter(stderr, &lockedWriter{mu: &recMu, w: recorded}),
+	})
+	pending.Finish()
+	timedOut := errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil
+	if err != nil && !timedOut {
+		s.auditEvent(ctx, res, models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	out := execResultDTO{
+		ExitCode: code, Stdout: stdout.String(), Stderr: stderr.String(),
+		Truncated: stdout.truncated || stderr.truncated, TimedOut: timedOut,
+		DurationMS: time.Since(started).Milliseconds(), RecordingID: pending.RecordingID(),
+	}
+	if timedOut {
+		out.ExitCode = -1
+		err = fmt.Errorf("command timed out after %s", timeout)
+	}
+	res.params["exitCode"] = strconv.Itoa(out.ExitCode)
+	s.auditEvent(ctx, res, auditResult(err), err)
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (req execRequest) validate() (time.Duration, error) {
+	if strings.TrimSpace(req.Command) == "" {
+		return 0, fmt.Errorf("%w: command is required", plugin.ErrInvalidInput)
+	}
+	if len(req.Command) > maxExecCommand {
+		return 0, fmt.Errorf("%w: command is longer than %d bytes", plugin.ErrInvalidInput, maxExecCommand)
+	}
+	if req.TimeoutSeconds < 0 || time.Duration(req.TimeoutSeconds)*time.Second > maxExecTimeout {
+		return 0, fmt.Errorf("%w: timeoutSeconds must be between 1 and %d", plugin.ErrInvalidInput, int(maxExecTimeout.Seconds()))
+	}
+	if req.TimeoutSeconds == 0 {
+		return defaultExecTimeout, nil
+	}
+	return time.Duration(req.TimeoutSeconds) * time.Second, nil
+}
+
+// execRecordingStream picks the terminal stream whose recording policy
+// covers command output.
+func execRecordingStream(m plugin.Manifest) string {
+	for _, st := range m.Streams {
+		if c, ok := m.RecordingClassFor(st.ID); ok && c.Class == plugin.RecordingTerminal {
+			return st.ID
+		}
+	}
+	return ""
+}
+
+// execStream is the recording tap's client side for a command that has no
+// browser attached.
+type execStream struct{ ctx context.Context }
+
+func (execStream) Read([]byte) (int, error)    { return 0, io.EOF }
+func (execStream) Write(p []byte) (int, error) { return len(p), nil }
+func (execStream) Close() error                { return nil }
+func (s execStream) Context() context.Context  { return s.ctx }
+
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// cappedBuffer keeps the first limit bytes written and drops the rest.
+type cappedBuffer struct {
+	strings.Builder
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); len(p) > room {
+		b.truncated = true
+		b.Builder.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	b.Builder.Write(p)
+	return len(p), nil
+}
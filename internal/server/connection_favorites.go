@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	connFavoriteAddEvent    = "connection_favorite.add"
+	connFavoriteRemoveEvent = "connection_favorite.remove"
+	connFavoriteOrderEvent  = "connection_favorite.order"
+)
+
+type favoriteOrderRequest struct {
+	ConnectionIDs []string `json:"connectionIds"`
+}
+
+// handleAddConnectionFavorite pins a connection the caller can reach.
+func (s *Server) handleAddConnectionFavorite(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	conn, err := s.deps.Store.Connections.Get(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	if conn.OwnerID != user.ID {
+		if _, err := s.deps.Store.Grants.Get(ctx, conn.ID, user.ID); err != nil {
+			s.auditConnEvent(ctx, user, conn.ID, connFavoriteAddEvent, plugin.RiskWrite, models.AuditDenied, plugin.ErrForbidden)
+			writeError(w, s.deps.Logger, plugin.ErrForbidden)
+			return
+		}
+	}
+	if err := service.AddConnectionFavorite(ctx, s.deps.Store.ConnectionFavorites, user.ID, conn.ID); err != nil {
+		s.auditConnEvent(ctx, user, conn.ID, connFavoriteAddEvent, plugin.RiskWrite, models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditConnEvent(ctx, user, conn.ID, connFavoriteAddEvent, plugin.RiskWrite, models.AuditAllowed, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRemoveConnectionFavorite unpins a connection. It needs no access to
+// the connection, so a user can always clear a favorite they lost access to.
+func (s *Server) handleRemoveConnectionFavorite(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	connID := chi.URLParam(r, "id")
+	if err := s.deps.Store.ConnectionFavorites.Delete(ctx, user.ID, connID); err != nil {
+		s.auditConnEvent(ctx, user, connID, connFavoriteRemoveEvent, plugin.RiskWrite, models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditConnEvent(ctx, user, connID, connFavoriteRemoveEvent, plugin.RiskWrite, models.AuditAllowed, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleReorderConnectionFavorites(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	var req favoriteOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	if err := service.ReorderConnectionFavorites(ctx, s.deps.Store.ConnectionFavorites, user.ID, req.ConnectionIDs); err != nil {
+		s.auditConnEvent(ctx, user, "", connFavoriteOrderEvent, plugin.RiskWrite, models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditConnEvent(ctx, user, "", connFavoriteOrderEvent, plugin.RiskWrite, models.AuditAllowed, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
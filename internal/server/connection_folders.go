@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/charlesng35/shellcn/internal/audit"
 	"github.com/charlesng35/shellcn/internal/models"
 	"github.com/charlesng35/shellcn/internal/service"
 	"github.com/charlesng35/shellcn/sdk/plugin"
@@ -112,6 +113,11 @@ func (s *Server) handleDeleteConnectionFolder(w http.ResponseWriter, r *http.Req
 		writeError(w, s.deps.Logger, err)
 		return
 	}
+	if err := s.deps.Connections.ClaimFolderMove(ctx, folder.UserID, folder.ID, folder.ParentID); err != nil {
+		s.auditConnEvent(ctx, user, "", connFolderDeleteEvent, plugin.RiskDestructive, models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
 	now := time.Now()
 	for _, child := range folders {
 		if child.ParentID != folder.ID {
@@ -165,7 +171,9 @@ func (s *Server) handleSaveConnectionLayout(w http.ResponseWriter, r *http.Reque
 	for _, f := range folders {
 		folderIDs[f.ID] = true
 	}
-	if err := service.SaveConnectionLayout(ctx, s.deps.Store.ConnectionPlacements, user.ID, accessible, folderIDs, req.Items); err != nil {
+	err = service.SaveConnectionLayout(ctx, s.deps.Store.ConnectionPlacements, user.ID, accessible, folderIDs, req.Items,
+		func(moves map[string]string) error { return s.deps.Connections.ClaimMoves(ctx, user.ID, moves) })
+	if err != nil {
 		result := models.AuditError
 		if errors.Is(err, plugin.ErrForbidden) {
 			result = models.AuditDenied
@@ -182,3 +190,72 @@ func (s *Server) handleSaveConnectionLayout(w http.ResponseWriter, r *http.Reque
 	s.auditConnEvent(ctx, user, "", connLayoutUpdateEvent, plugin.RiskWrite, models.AuditAllowed, nil)
 	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
 }
+
+type connectionImportRequest struct {
+	ParentID   string                   `json:"parentId"`
+	OnConflict string                   `json:"onConflict"`
+	Bundle     service.ConnectionBundle `json:"bundle"`
+}
+
+const maxImportBody = 8 << 20
+
+func (s *Server) handleExportConnectionFolder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	folder, err := s.deps.Store.ConnectionFolders.Get(ctx, chi.URLParam(r, "folderId"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	if folder.UserID != user.ID {
+		s.auditConnEvent(ctx, user, "", connFolderExportEvent, plugin.RiskSafe, models.AuditDenied, plugin.ErrForbidden)
+		writeError(w, s.deps.Logger, plugin.ErrForbidden)
+		return
+	}
+	bundle, err := s.deps.Connections.ExportFolder(ctx, s.deps.Store.ConnectionFolders, user.ID, folder.ID)
+	if err != nil {
+		s.auditConnEvent(ctx, user, "", connFolderExportEvent, plugin.RiskSafe, models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditConnEvent(ctx, user, "", connFolderExportEvent, plugin.RiskSafe, models.AuditAllowed, nil)
+	writeJSON(w, http.StatusOK, bundle)
+}
+
+func (s *Server) handleImportConnectionFolder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	if !canCreate(user) {
+		s.auditConnEvent(ctx, user, "", connFolderImportEvent, plugin.RiskWrite, models.AuditDenied, plugin.ErrForbidden)
+		writeError(w, s.deps.Logger, plugin.ErrForbidden)
+		return
+	}
+	var req connectionImportRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxImportBody)).Decode(&req); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	if req.OnConflict == "" {
+		req.OnConflict = service.ImportConflictRename
+	}
+	report, err := s.deps.Connections.ImportFolder(ctx, s.deps.Store.ConnectionFolders, user.ID, service.ConnectionImportInput{
+		ParentID: req.ParentID, OnConflict: req.OnConflict, Bundle: req.Bundle,
+		Allowed: func(protocol string) error { return s.checkProtocolAvailable(ctx, user, protocol) },
+	})
+	if err != nil {
+		s.auditConnEvent(ctx, user, "", connFolderImportEvent, plugin.RiskWrite, models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	// A large bundle creates hundreds of connections; write their audit rows
+	// together.
+	batchCtx, batch := audit.BeginBatch(ctx)
+	defer batch.Finish(batchCtx)
+	for _, item := range report.Connections {
+		if item.Status == service.ImportCreated {
+			s.auditConnEvent(batchCtx, user, item.ID, connCreateEvent, plugin.RiskWrite, models.AuditAllowed, nil)
+		}
+	}
+	s.auditConnEvent(batchCtx, user, "", connFolderImportEvent, plugin.RiskWrite, models.AuditAllowed, nil)
+	writeJSON(w, http.StatusOK, report)
+}
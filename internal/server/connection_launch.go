@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// maxLaunchConcurrency caps how many sessions a folder launch opens at once.
+const maxLaunchConcurrency = 10
+
+var sessionLaunchRoute = plugin.Route{
+	ID: "connection.session.launch", Permission: "connection.use", Risk: plugin.RiskSafe, AuditEvent: "connection.session.launch",
+}
+
+type folderLaunchRequest struct {
+	// Concurrency is how many sessions open in parallel; 1 launches
+	// sequentially. Zero or above the cap means the cap.
+	Concurrency int `json:"concurrency"`
+}
+
+type folderLaunchDTO struct {
+	BatchID string               `json:"batchId"`
+	Results []folderLaunchResult `json:"results"`
+}
+
+type folderLaunchResult struct {
+	ConnectionID string                `json:"connectionId"`
+	Name         string                `json:"name"`
+	Session      *connectionSessionDTO `json:"session,omitempty"`
+	Status       int                   `json:"status"`
+	Error        string                `json:"error,omitempty"`
+}
+
+// handleLaunchConnectionFolder opens a session for every connection the caller
+// placed in one of their folders. Each target goes through the same authorize
+// and acquire path as a single launch; one failure never fails the batch.
+func (s *Server) handleLaunchConnectionFolder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	folder, err := s.deps.Store.ConnectionFolders.Get(ctx, chi.URLParam(r, "folderId"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	if folder.UserID != user.ID {
+		writeError(w, s.deps.Logger, plugin.ErrForbidden)
+		return
+	}
+	var req folderLaunchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	concurrency := req.Concurrency
+	if concurrency <= 0 || concurrency > maxLaunchConcurrency {
+		concurrency = maxLaunchConcurrency
+	}
+
+	conns, err := s.folderConnections(ctx, user, folder.ID)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	batchID := uuid.NewString()
+	results := make([]folderLaunchResult, len(conns))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, conn := range conns {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			results[i] = s.launchForBatch(ctx, user, conn, batchID)
+		}()
+	}
+	wg.Wait()
+	writeJSON(w, http.StatusOK, folderLaunchDTO{BatchID: batchID, Results: results})
+}
+
+// folderConnections lists the accessible connections placed in a folder, in
+// sidebar order.
+func (s *Server) folderConnections(ctx context.Context, user models.User, folderID string) ([]models.Connection, error) {
+	placements, err := s.deps.Store.ConnectionPlacements.ListByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	conns, err := s.accessibleConnections(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]models.Connection, len(conns))
+	for _, c := range conns {
+		byID[c.ID] = c
+	}
+	sort.SliceStable(placements, func(i, j int) bool { return placements[i].SortOrder < placements[j].SortOrder })
+	var out []models.Connection
+	for _, p := range placements {
+		if c, ok := byID[p.ConnectionID]; ok && p.FolderID == folderID {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (s *Server) launchForBatch(ctx context.Context, user models.User, conn models.Connection, batchID string) folderLaunchResult {
+	out := folderLaunchResult{ConnectionID: conn.ID, Name: conn.Name}
+	res := resolved{user: user, conn: conn, route: sessionLaunchRoute, params: map[string]string{"batch": batchID}}
+	fail := func(result models.AuditResult, err error) folderLaunchResult {
+		s.auditEvent(ctx, res, result, err)
+		err = cleanAgentError(err)
+		out.Status = statusFor(err)
+		out.Error = err.Error()
+		if out.Status >= 500 && out.Status != http.StatusServiceUnavailable && out.Status != http.StatusNotImplemented {
+			out.Error = http.StatusText(out.Status)
+		}
+		return out
+	}
+	if err := s.authorize(ctx, user, conn, res.route); err != nil {
+		s.incAuthzFailure(err)
+		return fail(models.AuditDenied, err)
+	}
+	// A session held by another instance can only be reached through a
+	// per-connection request, which the lease proxy forwards.
+	if _, remote, err := s.remoteLeaseHolder(ctx, conn, user.ID); err != nil {
+		return fail(models.AuditError, err)
+	} else if remote {
+		return fail(models.AuditError, fmt.Errorf("%w: session is held by another instance", plugin.ErrUnavailable))
+	}
+	handle, err := s.acquireSession(ctx, res)
+	if err != nil {
+		return fail(models.AuditError, err)
+	}
+	s.auditEvent(ctx, res, models.AuditAllowed, nil)
+	dto := s.connectionSessionDTO(conn, handle.Snapshot())
+	out.Session = &dto
+	out.Status = http.StatusOK
+	return out
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/session"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	fileTransferDisabledCode = "file_transfer_disabled"
+	clipboardDisabledCode    = "clipboard_disabled"
+	nameTakenCode            = "name_taken"
+	// credentialReadsBlockedCode lets the UI explain a read-quota lockout.
+	credentialReadsBlockedCode = "credential_reads_blocked"
+	// credentialApprovalCode marks a launch waiting on a second person.
+	credentialApprovalCode = "credential_approval_required"
+	recordingProtectedCode = "recording_protected"
+	// ownedCredentialsCode asks for a transfer target before a user delete.
+	ownedCredentialsCode = "owned_credentials"
+	// ownedConnectionsCode asks for a reassignment target before a user delete.
+	ownedConnectionsCode = "owned_connections"
+	sessionLimitCode     = "session_limit"
+	// invitationExpiredCode lets the accept page offer "ask for a new link".
+	invitationExpiredCode = "invitation_expired"
+	// launchCodePrefix precedes a connect failure category, e.g.
+	// "connect_auth_failed".
+	launchCodePrefix = "connect_"
+)
+
+var (
+	errFileTransferDisabled = fmt.Errorf("%w: file transfer is disabled for this connection", plugin.ErrForbidden)
+	errClipboardDisabled    = fmt.Errorf("%w: clipboard is disabled for this connection", plugin.ErrForbidden)
+)
+
+// isFileTransferRoute reports whether a route moves file content: every file
+// browser route is gated by a <protocol>.files.read or .files.write permission.
+func isFileTransferRoute(route plugin.Route) bool {
+	return strings.HasSuffix(route.Permission, ".files.read") || strings.HasSuffix(route.Permission, ".files.write")
+}
+
+// checkConnectionFeatures applies the connection's own feature policy, which
+// holds even for users whose role and grant would allow the route.
+func checkConnectionFeatures(conn models.Connection, route plugin.Route) error {
+	if conn.FileTransferDisabled && isFileTransferRoute(route) {
+		return errFileTransferDisabled
+	}
+	return nil
+}
+
+// sessionCapabilities tells the client which optional features to offer.
+func sessionCapabilities(conn models.Connection) map[string]bool {
+	return map[string]bool{"fileTransfer": !conn.FileTransferDisabled, "clipboard": !conn.ClipboardDisabled}
+}
+
+func errorCode(err error) string {
+	var nameErr *service.NameConflictError
+	var protectedErr *service.RecordingProtectedError
+	var ownedErr *service.OwnedCredentialsError
+	var ownedConnsErr *service.OwnedConnectionsError
+	var limitErr *session.LimitError
+	var launchErr *service.LaunchError
+	switch {
+	case errors.Is(err, errFileTransferDisabled):
+		return fileTransferDisabledCode
+	case errors.Is(err, errClipboardDisabled):
+		return clipboardDisabledCode
+	case errors.As(err, &nameErr):
+		return nameTakenCode
+	case errors.Is(err, service.ErrCredentialReadsBlocked):
+		return credentialReadsBlockedCode
+	case errors.Is(err, service.ErrApprovalRequired):
+		return credentialApprovalCode
+	case errors.As(err, &protectedErr):
+		return recordingProtectedCode
+	case errors.As(err, &ownedErr):
+		return ownedCredentialsCode
+	case errors.As(err, &ownedConnsErr):
+		return ownedConnectionsCode
+	case errors.Is(err, errPasswordChangeRequired):
+		return passwordChangeRequiredCode
+	case errors.As(err, &limitErr):
+		return sessionLimitCode
+	case errors.Is(err, service.ErrInvitationExpired):
+		return invitationExpiredCode
+	case errors.As(err, &launchErr):
+		return launchCodePrefix + string(launchErr.Category)
+	}
+	return ""
+}
+
+// featurePolicyOnlyChange reports whether an update touched nothing but the
+// feature policy. Such an update leaves live sessions up: the policy is read
+// on every request and the session status carries the new capabilities.
+func featurePolicyOnlyChange(before, after models.Connection) bool {
+	before.FileTransferDisabled, before.ClipboardDisabled = after.FileTransferDisabled, after.ClipboardDisabled
+	before.UpdatedAt = after.UpdatedAt
+	a, errA := json.Marshal(before)
+	b, errB := json.Marshal(after)
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/audit"
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	connTransferEvent = "connection.transfer"
+	connReassignEvent = "connection.reassign"
+)
+
+type connectionTransferRequest struct {
+	NewOwnerID string `json:"newOwnerId"`
+}
+
+type connectionReassignRequest struct {
+	FromUserID string `json:"fromUserId"`
+	ToUserID   string `json:"toUserId"`
+	// IncludeCredentials moves the old owner's credentials first, so the
+	// connections using them can move too.
+	IncludeCredentials bool `json:"includeCredentials"`
+}
+
+type connectionReassignDTO struct {
+	Moved                  []string                    `json:"moved"`
+	Renamed                []connectionRenameDTO       `json:"renamed"`
+	Skipped                []connectionTransferSkipDTO `json:"skipped"`
+	CredentialsTransferred int                         `json:"credentialsTransferred"`
+}
+
+type connectionRenameDTO struct {
+	ConnectionID string `json:"connectionId"`
+	From         string `json:"from"`
+	To           string `json:"to"`
+}
+
+type connectionTransferSkipDTO struct {
+	ConnectionID string `json:"connectionId"`
+	Name         string `json:"name"`
+	Reason       string `json:"reason"`
+}
+
+// handleTransferConnection hands one connection to another user. The owner
+// or the root admin may do this; grants and folder placements are kept.
+func (s *Server) handleTransferConnection(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	id := chi.URLParam(r, "id")
+	var req connectionTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	params := map[string]string{"newOwner": req.NewOwnerID}
+	record := func(result models.AuditResult, err error) {
+		s.deps.Audit.Record(ctx, audit.Event{
+			User: user, Event: connTransferEvent, ConnectionID: id, RouteID: connTransferEvent,
+			Risk: string(plugin.RiskPrivileged), Result: result, Params: params, Err: err,
+		})
+	}
+	if err := s.activeTransferTarget(ctx, req.NewOwnerID); err != nil {
+		record(models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	prev, _ := s.deps.Store.Connections.Get(ctx, id)
+	conn, err := s.deps.Connections.TransferOwnership(ctx, user, id, req.NewOwnerID)
+	switch {
+	case errors.Is(err, plugin.ErrForbidden):
+		record(models.AuditDenied, err)
+	case err != nil:
+		record(models.AuditError, err)
+	default:
+		params["previousOwner"] = prev.OwnerID
+		record(models.AuditAllowed, nil)
+	}
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	if prev.OwnerID != conn.OwnerID {
+		// Open sessions authenticated with the old owner's credentials.
+		s.deps.Sessions.CloseConnection(conn.ID)
+	}
+	writeJSON(w, http.StatusOK, s.deps.Connections.Detail(ctx, user.ID, conn))
+}
+
+// handleAdminReassignConnections moves every connection one user owns to
+// another, e.g. when the first user leaves. Each moved connection gets its
+// own audit entry naming both owners.
+func (s *Server) handleAdminReassignConnections(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actor, _ := userFrom(ctx)
+	var req connectionReassignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	params := map[string]string{"from": req.FromUserID, "to": req.ToUserID}
+	out := connectionReassignDTO{Moved: []string{}, Renamed: []connectionRenameDTO{}, Skipped: []connectionTransferSkipDTO{}}
+	err := s.activeTransferTarget(ctx, req.ToUserID)
+	if err == nil && req.IncludeCredentials {
+		out.CredentialsTransferred, err = s.deps.Credentials.TransferAllOwnership(ctx, req.FromUserID, req.ToUserID)
+		params["credentialsTransferred"] = strconv.Itoa(out.CredentialsTransferred)
+	}
+	if err == nil {
+		moved, moveErr := s.deps.Connections.TransferAllOwnership(ctx, req.FromUserID, req.ToUserID)
+		err = moveErr
+		batchCtx, batch := audit.BeginBatch(ctx)
+		for _, id := range moved.Moved {
+			s.deps.Sessions.CloseConnection(id)
+			s.deps.Audit.Record(batchCtx, audit.Event{
+				User: actor, Event: connTransferEvent, ConnectionID: id, RouteID: connReassignEvent,
+				Risk: string(plugin.RiskPrivileged), Result: models.AuditAllowed,
+				Params: map[string]string{"previousOwner": req.FromUserID, "newOwner": req.ToUserID},
+			})
+		}
+		batch.Finish(batchCtx)
+		out.Moved = moved.Moved
+		for _, rn := range moved.Renamed {
+			out.Renamed = append(out.Renamed, connectionRenameDTO{ConnectionID: rn.ConnectionID, From: rn.From, To: rn.To})
+		}
+		for _, sk := range moved.Skipped {
+			out.Skipped = append(out.Skipped, connectionTransferSkipDTO{ConnectionID: sk.ConnectionID, Name: sk.Name, Reason: sk.Reason})
+		}
+	}
+	params["moved"] = strconv.Itoa(len(out.Moved))
+	params["skipped"] = strconv.Itoa(len(out.Skipped))
+	s.deps.Audit.Record(ctx, audit.Event{
+		User: actor, Event: connReassignEvent, RouteID: connReassignEvent,
+		Risk: string(plugin.RiskPrivileged), Result: auditResult(err), Params: params, Err: err,
+	})
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+type trashedConnectionDTO struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Protocol  string    `json:"protocol"`
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// handleListConnectionTrash lists the caller's own trashed connections. Restore
+// mirrors delete, so only the owner ever sees or acts on a trashed record.
+func (s *Server) handleListConnectionTrash(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	trashed, err := s.deps.Connections.Trash(ctx, user.ID)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	out := make([]trashedConnectionDTO, 0, len(trashed))
+	for _, c := range trashed {
+		out = append(out, trashedConnectionDTO{ID: c.ID, Name: c.Name, Protocol: c.Protocol, DeletedAt: *c.DeletedAt})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleRestoreConnection(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	conn, err := s.deps.Store.Connections.GetTrashed(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	if !s.canAdminConnection(user, conn) {
+		s.auditConnEvent(ctx, user, conn.ID, connRestoreEvent, plugin.RiskWrite, models.AuditDenied, plugin.ErrForbidden)
+		writeError(w, s.deps.Logger, plugin.ErrForbidden)
+		return
+	}
+	restored, err := s.deps.Connections.Restore(ctx, conn)
+	if err != nil {
+		s.auditConnEvent(ctx, user, conn.ID, connRestoreEvent, plugin.RiskWrite, models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditConnEvent(ctx, user, conn.ID, connRestoreEvent, plugin.RiskWrite, models.AuditAllowed, nil)
+	writeJSON(w, http.StatusOK, s.deps.Connections.Detail(ctx, user.ID, restored))
+}
+
+// PurgeConnectionTrash permanently deletes connections trashed before the
+// cutoff along with the grants and placements kept for a possible restore.
+func (s *Server) PurgeConnectionTrash(ctx context.Context, before time.Time) (int, error) {
+	ids, err := s.deps.Connections.PurgeTrash(ctx, before)
+	for _, id := range ids {
+		s.cleanupConnectionDependents(ctx, id)
+	}
+	return len(ids), err
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	defaultUnusedDays = 90
+	maxUnusedDays     = 3650
+)
+
+type staleConnectionsDTO struct {
+	UnusedFor   string                         `json:"unusedFor"`
+	Since       time.Time                      `json:"since"`
+	Connections []service.StaleConnectionEntry `json:"connections"`
+}
+
+// handleConnectionStats reports session counts for a connection the caller
+// owns or was granted.
+func (s *Server) handleConnectionStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	conn, err := s.deps.Store.Connections.Get(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	if !s.canAccessConnection(ctx, user, conn) {
+		writeError(w, s.deps.Logger, plugin.ErrNotFound)
+		return
+	}
+	stats, err := s.deps.Usage.Stats(ctx, conn)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleAdminStaleConnections lists connections with no session in the
+// ?unused_for window. Root sees every connection; other admins see the ones
+// they own or were granted. ?format=csv downloads the list, with times in the
+// caller's time zone or ?tz.
+func (s *Server) handleAdminStaleConnections(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	days, err := parseDays("unused_for", r.URL.Query().Get("unused_for"), defaultUnusedDays, maxUnusedDays)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	window := time.Duration(days) * 24 * time.Hour
+	list, err := s.deps.Usage.Stale(ctx, user, window)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		writeJSON(w, http.StatusOK, staleConnectionsDTO{
+			UnusedFor: strconv.Itoa(days) + "d", Since: time.Now().Add(-window), Connections: list,
+		})
+	case "csv":
+		loc, err := s.exportLocation(r, user)
+		if err != nil {
+			writeError(w, s.deps.Logger, err)
+			return
+		}
+		writeStaleConnectionsCSV(w, list, loc)
+	default:
+		writeError(w, s.deps.Logger, fmt.Errorf("%w: format must be json or csv", plugin.ErrInvalidInput))
+	}
+}
+
+func writeStaleConnectionsCSV(w http.ResponseWriter, list []service.StaleConnectionEntry, loc *time.Location) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="stale-connections.csv"`)
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"id", "name", "protocol", "owner_id", "owner", "folder", "created_at", "last_used_at"})
+	for _, c := range list {
+		lastUsed := ""
+		if c.LastUsedAt != nil {
+			lastUsed = csvTime(*c.LastUsedAt, loc)
+		}
+		_ = cw.Write([]string{
+			c.ID, csvCell(c.Name), c.Protocol, c.OwnerID, csvCell(c.OwnerUsername), csvCell(c.FolderPath),
+			csvTime(c.CreatedAt, loc), lastUsed,
+		})
+	}
+	cw.Flush()
+}
+
+// csvCell keeps spreadsheets from evaluating user-chosen names as formulas.
+func csvCell(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// parseDays reads a window parameter written "<n>d"; empty means def.
+func parseDays(name, v string, def, maxDays int) (int, error) {
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(v, "d"))
+	if err != nil || !strings.HasSuffix(v, "d") || n < 1 || n > maxDays {
+		return 0, fmt.Errorf("%w: %s must be 1d-%dd", plugin.ErrInvalidInput, name, maxDays)
+	}
+	return n, nil
+}
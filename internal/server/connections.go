@@ -3,7 +3,10 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -20,10 +23,13 @@ const (
 	connCreateEvent            = "connection.create"
 	connUpdateEvent            = "connection.update"
 	connDeleteEvent            = "connection.delete"
+	connRestoreEvent           = "connection.restore"
 	connSessionDisconnectEvent = "connection.session.disconnect"
 	connFolderCreateEvent      = "connection_folder.create"
 	connFolderUpdateEvent      = "connection_folder.update"
 	connFolderDeleteEvent      = "connection_folder.delete"
+	connFolderExportEvent      = "connection_folder.export"
+	connFolderImportEvent      = "connection_folder.import"
 	connLayoutUpdateEvent      = "connection_layout.update"
 )
 
@@ -41,16 +47,42 @@ type connectionWriteRequest struct {
 	AIMode              models.AIMode     `json:"aiMode"`
 	AIAllowDestructive  bool              `json:"aiAllowDestructive"`
 	AIAutoApprove       bool              `json:"aiAutoApprove"`
+	AllowFileTransfer   *bool             `json:"allowFileTransfer"`
+	AllowClipboard      *bool             `json:"allowClipboard"`
+	RecordingFailClosed *bool             `json:"recordingFailClosed"`
 }
 
 type connectionSessionDTO struct {
+	ID              string `json:"id,omitempty"`
 	State           string `json:"state"`
 	Reason          string `json:"reason,omitempty"`
+	Failure         string `json:"failure,omitempty"` // category of a failed connect
+	Stage           string `json:"stage,omitempty"`   // last step of a pending launch
 	Channels        int    `json:"channels"`
 	Streams         int    `json:"streams"`
 	LastSeen        string `json:"lastSeen,omitempty"`
 	LastHealthCheck string `json:"lastHealthCheck,omitempty"`
 	IdleExpiresIn   int64  `json:"idleExpiresIn,omitempty"`
+	Cols            int    `json:"cols,omitempty"`
+	Rows            int    `json:"rows,omitempty"`
+	// BytesIn and BytesOut are the session's traffic so far; in is what
+	// the browser sent.
+	BytesIn  int64 `json:"bytesIn"`
+	BytesOut int64 `json:"bytesOut"`
+	// Capabilities follow the connection's feature policy, so a client polling
+	// a live session picks up policy changes without reconnecting.
+	Capabilities map[string]bool `json:"capabilities,omitempty"`
+	// Presence is the connection's participants' current state, so a late
+	// joiner renders it before the first presence event.
+	Presence []session.Presence `json:"presence,omitempty"`
+	// WriteRequests are the pending requests for write access the caller may
+	// answer, or their own.
+	WriteRequests []models.SessionWriteRequest `json:"writeRequests,omitempty"`
+	// Client is where the session was opened from.
+	Client *models.ClientInfo `json:"client,omitempty"`
+	// ConnectionSnapshot is the connection's settings as the session opened
+	// them, which later edits do not change.
+	ConnectionSnapshot *models.ConnectionSnapshot `json:"connectionSnapshot,omitempty"`
 }
 
 // toConnectionDTO projects a stored connection for the client.
@@ -59,7 +91,9 @@ func (s *Server) toConnectionDTO(c models.Connection) connectionDTO {
 		ID: c.ID, Name: c.Name, Protocol: c.Protocol,
 		Transport: c.Transport, Recording: c.Recording,
 		AIMode: c.AIMode, AIAllowDestructive: c.AIAllowDestructive,
-		AIAutoApprove: c.AIAutoApprove,
+		AIAutoApprove:     c.AIAutoApprove,
+		AllowFileTransfer: !c.FileTransferDisabled, AllowClipboard: !c.ClipboardDisabled,
+		LastUsedAt: c.LastUsedAt,
 	}
 	// A direct transport is always dialable on demand; an agent transport is
 	// reachable only while its tunnel is registered. `online` gates the enroll
@@ -136,7 +170,8 @@ func (s *Server) handleCreateConnection(w http.ResponseWriter, r *http.Request)
 		Name: req.Name, Protocol: req.Protocol, Transport: req.Transport,
 		Config: req.Config, ActorID: user.ID, Recording: req.Recording,
 		AIMode: req.AIMode, AIAllowDestructive: req.AIAllowDestructive,
-		AIAutoApprove: req.AIAutoApprove,
+		AIAutoApprove: req.AIAutoApprove, AllowFileTransfer: req.AllowFileTransfer, AllowClipboard: req.AllowClipboard,
+		RecordingFailClosed: req.RecordingFailClosed,
 	})
 	if err != nil {
 		s.auditConnEvent(ctx, user, "", connCreateEvent, plugin.RiskWrite, models.AuditError, err)
@@ -188,14 +223,17 @@ func (s *Server) handleUpdateConnection(w http.ResponseWriter, r *http.Request)
 		ActorID: user.ID, PreserveCredentials: req.PreserveCredentials,
 		Recording: req.Recording,
 		AIMode:    req.AIMode, AIAllowDestructive: req.AIAllowDestructive,
-		AIAutoApprove: req.AIAutoApprove,
+		AIAutoApprove: req.AIAutoApprove, AllowFileTransfer: req.AllowFileTransfer, AllowClipboard: req.AllowClipboard,
+		RecordingFailClosed: req.RecordingFailClosed,
 	})
 	if err != nil {
 		s.auditConnEvent(ctx, user, conn.ID, connUpdateEvent, plugin.RiskWrite, models.AuditError, err)
 		writeError(w, s.deps.Logger, err)
 		return
 	}
-	s.deps.Sessions.CloseConnection(conn.ID)
+	if !featurePolicyOnlyChange(conn, updated) {
+		s.deps.Sessions.CloseConnection(conn.ID)
+	}
 	s.auditConnEvent(ctx, user, conn.ID, connUpdateEvent, plugin.RiskWrite, models.AuditAllowed, nil)
 	writeJSON(w, http.StatusOK, s.deps.Connections.Detail(ctx, user.ID, updated))
 }
@@ -218,10 +256,7 @@ func (s *Server) handleDeleteConnection(w http.ResponseWriter, r *http.Request)
 		writeError(w, s.deps.Logger, err)
 		return
 	}
-	if err := s.deps.Store.ConnectionPlacements.DeleteByConnection(ctx, conn.ID); err != nil {
-		s.deps.Logger.Warn("cleanup connection placements failed", "connection", conn.ID, "err", err)
-	}
-	s.cleanupConnectionDependents(ctx, conn.ID)
+	s.detachConnection(ctx, conn.ID)
 	s.auditConnEvent(ctx, user, conn.ID, connDeleteEvent, plugin.RiskDestructive, models.AuditAllowed, nil)
 	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
 }
@@ -234,7 +269,8 @@ func (s *Server) handleConnectionSessionStatus(w http.ResponseWriter, r *http.Re
 		writeError(w, s.deps.Logger, err)
 		return
 	}
-	if !s.canAccessConnection(ctx, user, conn) {
+	// Participants of a shared session see its presence and write requests.
+	if !s.canAccessConnection(ctx, user, conn) && !s.isParticipant(ctx, conn.ID, user.ID) {
 		writeError(w, s.deps.Logger, plugin.ErrForbidden)
 		return
 	}
@@ -242,14 +278,25 @@ func (s *Server) handleConnectionSessionStatus(w http.ResponseWriter, r *http.Re
 		return
 	}
 	key := session.Key{ConnectionID: conn.ID, ActorScope: user.ID}
-	snap, ok := s.deps.Sessions.Status(key)
-	if !ok {
-		writeJSON(w, http.StatusOK, connectionSessionDTO{State: "idle"})
-		return
+	dto := connectionSessionDTO{State: "idle", Capabilities: sessionCapabilities(conn), Presence: s.presence(conn.ID)}
+	if snap, ok := s.deps.Sessions.Status(key); ok {
+		dto = s.connectionSessionDTO(conn, snap)
+	}
+	if s.deps.SessionShares != nil {
+		dto.WriteRequests = s.deps.SessionShares.PendingWrites(ctx, conn.ID, user.ID)
 	}
-	writeJSON(w, http.StatusOK, s.connectionSessionDTO(snap))
+	writeJSON(w, http.StatusOK, dto)
 }
 
+// launchWaitTimeout bounds how long a ?wait=true launch holds the request;
+// the launch itself carries on past it.
+const launchWaitTimeout = 30 * time.Second
+
+// handleKeepaliveConnectionSession opens the caller's session on the
+// connection, or keeps a live one from idling out. A new session connects in
+// the background: the response is 202 with the pending session, and its
+// progress arrives on the caller's event stream. With ?wait=true the response
+// waits, up to launchWaitTimeout, for the launch to finish.
 func (s *Server) handleKeepaliveConnectionSession(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	user, _ := userFrom(ctx)
@@ -270,28 +317,100 @@ func (s *Server) handleKeepaliveConnectionSession(w http.ResponseWriter, r *http
 	if s.proxyIfRemoteLeaseHolder(w, r, conn, user.ID) {
 		return
 	}
-	handle, err := s.acquireSession(ctx, res)
+	wait, _ := strconv.ParseBool(r.URL.Query().Get("wait"))
+	key := session.Key{ConnectionID: conn.ID, ActorScope: user.ID}
+	snap, err := s.launchSession(ctx, res)
 	if err != nil {
-		if snap, ok := s.deps.Sessions.Status(session.Key{ConnectionID: conn.ID, ActorScope: user.ID}); ok {
-			writeJSON(w, http.StatusOK, s.connectionSessionDTO(snap))
-			return
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	if wait && snap.State == session.StatePending {
+		waitCtx, cancel := context.WithTimeout(ctx, launchWaitTimeout)
+		defer cancel()
+		if latest, ok := s.deps.Sessions.Await(waitCtx, key); ok {
+			snap = latest
 		}
+	}
+	status := http.StatusOK
+	if snap.State == session.StatePending {
+		status = http.StatusAccepted
+	}
+	writeJSON(w, status, s.connectionSessionDTO(conn, snap))
+}
+
+const maxTerminalDimension = 1000
+
+type sessionResizeRequest struct {
+	Cols int `json:"cols"`
+	Rows int `json:"rows"`
+}
+
+// handleResizeConnectionSession sets the terminal size of the caller's live
+// session on every open terminal channel and records it on the session.
+func (s *Server) handleResizeConnectionSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	conn, err := s.deps.Store.Connections.Get(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	res := resolved{user: user, conn: conn, route: plugin.Route{
+		ID: "connection.session.resize", Permission: "connection.use", Risk: plugin.RiskSafe, AuditEvent: "connection.session.resize",
+	}}
+	if err := s.authorize(ctx, user, conn, res.route); err != nil {
+		s.auditEvent(ctx, res, models.AuditDenied, err)
+		s.incAuthzFailure(err)
 		writeError(w, s.deps.Logger, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, s.connectionSessionDTO(handle.Snapshot()))
+	if s.proxyIfRemoteLeaseHolder(w, r, conn, user.ID) {
+		return
+	}
+	var req sessionResizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	if req.Cols < 1 || req.Rows < 1 || req.Cols > maxTerminalDimension || req.Rows > maxTerminalDimension {
+		writeError(w, s.deps.Logger, fmt.Errorf("%w: cols and rows must be between 1 and %d", plugin.ErrInvalidInput, maxTerminalDimension))
+		return
+	}
+	snap, err := s.deps.Sessions.Resize(session.Key{ConnectionID: conn.ID, ActorScope: user.ID}, req.Cols, req.Rows)
+	if errors.Is(err, session.ErrSessionClosed) {
+		err = fmt.Errorf("%w: no live session to resize", plugin.ErrConflict)
+	}
+	if err != nil {
+		s.auditEvent(ctx, res, models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditEvent(ctx, res, models.AuditAllowed, nil)
+	writeJSON(w, http.StatusOK, s.connectionSessionDTO(conn, snap))
 }
 
-func (s *Server) connectionSessionDTO(snap session.Snapshot) connectionSessionDTO {
+func (s *Server) connectionSessionDTO(conn models.Connection, snap session.Snapshot) connectionSessionDTO {
 	dto := connectionSessionDTO{
-		State: string(snap.State), Reason: snap.Reason,
+		ID: snap.ID, State: string(snap.State), Reason: snap.Reason, Stage: string(snap.Stage),
 		Channels: snap.Channels, Streams: snap.Streams,
+		BytesIn: snap.BytesIn, BytesOut: snap.BytesOut,
 		LastSeen: snap.LastUsed.UTC().Format(time.RFC3339),
+		Cols:     snap.Cols, Rows: snap.Rows,
+		Capabilities: sessionCapabilities(conn),
+		Presence:     s.presence(conn.ID),
 	}
 	if !snap.LastHealthCheck.IsZero() {
 		dto.LastHealthCheck = snap.LastHealthCheck.UTC().Format(time.RFC3339)
 	}
-	if snap.State != session.StateError && snap.Channels == 0 && snap.Streams == 0 {
+	if snap.Client != (models.ClientInfo{}) {
+		dto.Client = &snap.Client
+	}
+	if cs, ok := snap.Metadata[service.MetadataConnectionSnapshot].(models.ConnectionSnapshot); ok {
+		dto.ConnectionSnapshot = &cs
+	}
+	dto.Failure = service.LaunchFailure(snap)
+	if snap.State != session.StateError && snap.State != session.StateReconnecting && snap.State != session.StatePending &&
+		snap.Channels == 0 && snap.Streams == 0 {
 		expires := time.Until(snap.LastUsed.Add(s.deps.Sessions.IdleTimeout()))
 		if expires > 0 {
 			dto.IdleExpiresIn = int64(expires.Seconds())
@@ -321,22 +440,16 @@ func (s *Server) handleDisconnectConnectionSession(w http.ResponseWriter, r *htt
 	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
 }
 
-// cleanupConnectionDependents removes the access-control state tied to a deleted
-// connection so it can never be inherited by a future record: it drops any live
-// agent tunnel, deletes sharing grants, and revokes outstanding enrollments.
-// Best-effort — the connection is already gone, so failures are logged not fatal.
-func (s *Server) cleanupConnectionDependents(ctx context.Context, connID string) {
+// detachConnection tears down the live state of a trashed connection: it closes
+// pooled sessions, drops any agent tunnel, and revokes outstanding enrollments so
+// a restore never revives an agent token. Grants and sidebar placements are kept
+// so a restore brings the connection back as it was. Best-effort — the
+// connection is already trashed, so failures are logged not fatal.
+func (s *Server) detachConnection(ctx context.Context, connID string) {
 	s.deps.Sessions.CloseConnection(connID)
 	if s.deps.Tunnels != nil {
 		s.deps.Tunnels.Remove(connID)
 	}
-	if grants, err := s.deps.Store.Grants.ListByConnection(ctx, connID); err == nil {
-		for _, g := range grants {
-			if err := s.deps.Store.Grants.Delete(ctx, g.ID); err != nil {
-				s.deps.Logger.Warn("cleanup grant failed", "connection", connID, "grant", g.ID, "err", err)
-			}
-		}
-	}
 	if enrs, err := s.deps.Store.Enrollments.ListByConnection(ctx, connID); err == nil {
 		for _, e := range enrs {
 			if e.Status == models.EnrollmentPending || e.Status == models.EnrollmentOnline {
@@ -347,3 +460,22 @@ func (s *Server) cleanupConnectionDependents(ctx context.Context, connID string)
 		}
 	}
 }
+
+// cleanupConnectionDependents removes the access-control state tied to a purged
+// connection so it can never be inherited by a future record: sharing grants and
+// every user's sidebar placement and favorite.
+func (s *Server) cleanupConnectionDependents(ctx context.Context, connID string) {
+	if grants, err := s.deps.Store.Grants.ListByConnection(ctx, connID); err == nil {
+		for _, g := range grants {
+			if err := s.deps.Store.Grants.Delete(ctx, g.ID); err != nil {
+				s.deps.Logger.Warn("cleanup grant failed", "connection", connID, "grant", g.ID, "err", err)
+			}
+		}
+	}
+	if err := s.deps.Store.ConnectionPlacements.DeleteByConnection(ctx, connID); err != nil {
+		s.deps.Logger.Warn("cleanup connection placements failed", "connection", connID, "err", err)
+	}
+	if err := s.deps.Store.ConnectionFavorites.DeleteByConnection(ctx, connID); err != nil {
+		s.deps.Logger.Warn("cleanup connection favorites failed", "connection", connID, "err", err)
+	}
+}
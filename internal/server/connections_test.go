@@ -6,10 +6,15 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/coder/websocket/wsjson"
 
 	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
 	"github.com/charlesng35/shellcn/internal/store"
 )
 
@@ -56,6 +61,9 @@ func TestConnectionCRUDRoundTrip(t *testing.T) {
 	if len(before) == 0 {
 		t.Fatal("secret was not stored as ciphertext")
 	}
+	if _, ok := conn.Config["password"]; ok {
+		t.Fatalf("secret persisted in plaintext config: %v", conn.Config)
+	}
 
 	// Update omitting the secret keeps the stored ciphertext untouched.
 	upd := `{"name":"db1-renamed","transport":"direct","config":{"host":"db.local"}}`
@@ -84,6 +92,9 @@ func TestConnectionCRUDRoundTrip(t *testing.T) {
 	if bytes.Equal(conn.Secrets["password"], before) {
 		t.Fatal("replaced secret should change the ciphertext")
 	}
+	if _, ok := conn.Config["password"]; ok {
+		t.Fatalf("rotated secret persisted in plaintext config: %v", conn.Config)
+	}
 
 	// Delete removes it.
 	resp = h.do(t, http.MethodDelete, "/api/connections/"+id, "op", nil)
@@ -95,6 +106,125 @@ func TestConnectionCRUDRoundTrip(t *testing.T) {
 	}
 }
 
+func TestConnectionTrashRestoreAndPurge(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+
+	if resp := h.do(t, http.MethodPost, "/api/connections/c-op/grants", "op",
+		strings.NewReader(`{"subjectId":"viewer","access":"view"}`)); resp.Status != http.StatusCreated {
+		t.Fatalf("grant: want 201, got %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodDelete, "/api/connections/c-op", "op", nil); resp.Status != http.StatusOK {
+		t.Fatalf("delete: want 200, got %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/connections/c-op", "op", nil); resp.Status != http.StatusNotFound {
+		t.Fatalf("trashed connection should be hidden: got %d", resp.Status)
+	}
+	resp := h.do(t, http.MethodGet, "/api/connections/trash", "op", nil)
+	if resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `"id":"c-op"`) {
+		t.Fatalf("trash list: status=%d body=%s", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/connections/trash", "op2", nil); strings.Contains(string(resp.Body), "c-op") {
+		t.Fatalf("trash must only list the caller's connections: %s", resp.Body)
+	}
+	if resp := h.do(t, http.MethodPost, "/api/connections/c-op/restore", "op2", nil); resp.Status != http.StatusForbidden {
+		t.Fatalf("non-owner restore: want 403, got %d", resp.Status)
+	}
+
+	// A live connection now holds the name, so the restore is suffixed.
+	conn, _ := h.store.Connections.GetTrashed(ctx, "c-op")
+	if resp := h.do(t, http.MethodPost, "/api/connections", "op",
+		strings.NewReader(`{"name":"`+conn.Name+`","protocol":"tester","config":{"host":"h"}}`)); resp.Status != http.StatusCreated {
+		t.Fatalf("create clash: want 201, got %d (%s)", resp.Status, resp.Body)
+	}
+	resp = h.do(t, http.MethodPost, "/api/connections/c-op/restore", "op", nil)
+	if resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `"name":"`+conn.Name+` (restored)"`) {
+		t.Fatalf("restore: status=%d body=%s", resp.Status, resp.Body)
+	}
+	if grants, _ := h.store.Grants.ListByConnection(ctx, "c-op"); len(grants) != 1 {
+		t.Fatalf("restore should keep grants: %+v", grants)
+	}
+
+	if resp := h.do(t, http.MethodDelete, "/api/connections/c-op", "op", nil); resp.Status != http.StatusOK {
+		t.Fatalf("delete again: want 200, got %d", resp.Status)
+	}
+	if n, err := h.srv.PurgeConnectionTrash(ctx, time.Now().Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("purge: n=%d err=%v", n, err)
+	}
+	if _, err := h.store.Connections.GetTrashed(ctx, "c-op"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("purged connection should be gone, got err=%v", err)
+	}
+	if grants, _ := h.store.Grants.ListByConnection(ctx, "c-op"); len(grants) != 0 {
+		t.Fatalf("purge should drop grants: %+v", grants)
+	}
+}
+
+func TestConnectionNamesUniquePerFolder(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+	create := func(user, name string) apiResp {
+		return h.do(t, http.MethodPost, "/api/connections", user,
+			strings.NewReader(`{"name":"`+name+`","protocol":"tester","config":{"host":"h"}}`))
+	}
+	resp := create("op", "Prod-DB")
+	if resp.Status != http.StatusCreated {
+		t.Fatalf("create: got %d (%s)", resp.Status, resp.Body)
+	}
+	var first struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(resp.Body, &first)
+
+	resp = create("op", "prod-db")
+	var conflict struct {
+		Code       string `json:"code"`
+		Suggestion string `json:"suggestion"`
+	}
+	_ = json.Unmarshal(resp.Body, &conflict)
+	if resp.Status != http.StatusConflict || conflict.Code != "name_taken" || conflict.Suggestion != "prod-db (2)" {
+		t.Fatalf("duplicate create: got %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := create("op2", "prod-db"); resp.Status != http.StatusCreated {
+		t.Fatalf("another owner may reuse the name: got %d (%s)", resp.Status, resp.Body)
+	}
+
+	// Moving the first one into a folder frees the name at the root.
+	resp = h.do(t, http.MethodPost, "/api/connection-folders", "op", strings.NewReader(`{"name":"Databases"}`))
+	var folder struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(resp.Body, &folder)
+	move := func(connID string) apiResp {
+		return h.do(t, http.MethodPut, "/api/connections/layout", "op",
+			strings.NewReader(`{"items":[{"connectionId":"`+connID+`","folderId":"`+folder.ID+`","sortOrder":0}]}`))
+	}
+	if resp := move(first.ID); resp.Status != http.StatusOK {
+		t.Fatalf("move into folder: got %d (%s)", resp.Status, resp.Body)
+	}
+	resp = create("op", "prod-db")
+	if resp.Status != http.StatusCreated {
+		t.Fatalf("create at root after move: got %d (%s)", resp.Status, resp.Body)
+	}
+	second := createConnID(t, resp)
+	if resp := move(second); resp.Status != http.StatusConflict || !strings.Contains(string(resp.Body), "name_taken") {
+		t.Fatalf("move onto a taken name: got %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodDelete, "/api/connection-folders/"+folder.ID, "op", nil); resp.Status != http.StatusConflict {
+		t.Fatalf("deleting the folder would clash at the root: got %d (%s)", resp.Status, resp.Body)
+	}
+	if p, _ := h.store.ConnectionPlacements.ListByUser(ctx, "op"); len(p) != 1 || p[0].FolderID != folder.ID {
+		t.Fatalf("refused moves must leave placements alone: %+v", p)
+	}
+	if resp := h.do(t, http.MethodPut, "/api/connections/c-op", "op",
+		strings.NewReader(`{"name":"PROD-db","config":{"host":"h"}}`)); resp.Status != http.StatusConflict {
+		t.Fatalf("rename into a taken name: got %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodPut, "/api/connections/"+first.ID, "op",
+		strings.NewReader(`{"name":"prod-db","config":{"host":"h"}}`)); resp.Status != http.StatusOK {
+		t.Fatalf("case-only rename of the same connection: got %d (%s)", resp.Status, resp.Body)
+	}
+}
+
 func TestConnectionConfigVisibilityFollowsTransport(t *testing.T) {
 	h := newHarness(t)
 	ctx := context.Background()
@@ -227,6 +357,60 @@ func TestConnectionRecordingPolicy(t *testing.T) {
 	}
 }
 
+func TestConnectionFeaturePolicy(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+
+	if resp := h.do(t, http.MethodGet, "/api/connections/c-op/x/tester.files", "op", nil); resp.Status != http.StatusOK {
+		t.Fatalf("file route allowed by default: got %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodPut, "/api/connections/c-op", "op",
+		strings.NewReader(`{"name":"op","config":{"host":"h"}}`)); resp.Status != http.StatusOK {
+		t.Fatalf("update: got %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodPost, "/api/connections/c-op/session?wait=true", "op", nil); resp.Status != http.StatusOK ||
+		!strings.Contains(string(resp.Body), `"capabilities":{"clipboard":true,"fileTransfer":true}`) {
+		t.Fatalf("keepalive capabilities: got %d (%s)", resp.Status, resp.Body)
+	}
+
+	resp := h.do(t, http.MethodPut, "/api/connections/c-op", "op",
+		strings.NewReader(`{"name":"op","config":{"host":"h"},"allowFileTransfer":false}`))
+	if resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `"allowFileTransfer":false`) ||
+		!strings.Contains(string(resp.Body), `"allowClipboard":true`) {
+		t.Fatalf("disable file transfer: got %d (%s)", resp.Status, resp.Body)
+	}
+	if got := h.pluginSessions.Stats().Sessions; got != 1 {
+		t.Fatalf("a policy-only update must keep the live session, sessions = %d", got)
+	}
+	resp = h.do(t, http.MethodGet, "/api/connections/c-op/session", "op", nil)
+	if !strings.Contains(string(resp.Body), `"state":"connected"`) || !strings.Contains(string(resp.Body), `"fileTransfer":false`) {
+		t.Fatalf("session status after policy change: %s", resp.Body)
+	}
+
+	resp = h.do(t, http.MethodGet, "/api/connections/c-op/x/tester.files", "op", nil)
+	var env struct{ Code string }
+	_ = json.Unmarshal(resp.Body, &env)
+	if resp.Status != http.StatusForbidden || env.Code != "file_transfer_disabled" {
+		t.Fatalf("file route with transfer disabled: got %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/connections/c-op/x/tester.list", "op", nil); resp.Status != http.StatusOK {
+		t.Fatalf("other routes stay available: got %d", resp.Status)
+	}
+
+	// Omitting the fields keeps the stored policy; a config change still
+	// restarts sessions.
+	if resp := h.do(t, http.MethodPut, "/api/connections/c-op", "op",
+		strings.NewReader(`{"name":"op","config":{"host":"h2"}}`)); resp.Status != http.StatusOK {
+		t.Fatalf("update config: got %d (%s)", resp.Status, resp.Body)
+	}
+	if conn, _ := h.store.Connections.Get(ctx, "c-op"); !conn.FileTransferDisabled {
+		t.Fatal("omitting allowFileTransfer must preserve the policy")
+	}
+	if got := h.pluginSessions.Stats().Sessions; got != 0 {
+		t.Fatalf("a config change should close sessions, sessions = %d", got)
+	}
+}
+
 func TestConnectionCreateValidation(t *testing.T) {
 	h := newHarness(t)
 
@@ -319,7 +503,7 @@ func TestConnectionSessionKeepaliveConnectsAndReportsState(t *testing.T) {
 		t.Fatalf("initial session status: status=%d body=%s", resp.Status, resp.Body)
 	}
 
-	resp = h.do(t, http.MethodPost, "/api/connections/c-op/session", "op", nil)
+	resp = h.do(t, http.MethodPost, "/api/connections/c-op/session?wait=true", "op", nil)
 	if resp.Status != http.StatusOK {
 		t.Fatalf("keepalive/connect: want 200, got %d (%s)", resp.Status, resp.Body)
 	}
@@ -342,7 +526,7 @@ func TestConnectionSessionKeepaliveConnectsAndReportsState(t *testing.T) {
 func TestConnectionSessionKeepaliveReportsConnectFailureState(t *testing.T) {
 	h := newHarness(t)
 
-	resp := h.do(t, http.MethodPost, "/api/connections/c-boom/session", "op", nil)
+	resp := h.do(t, http.MethodPost, "/api/connections/c-boom/session?wait=true", "op", nil)
 	if resp.Status != http.StatusOK {
 		t.Fatalf("connect failure should be returned as session state: status=%d body=%s", resp.Status, resp.Body)
 	}
@@ -357,10 +541,56 @@ func TestConnectionSessionKeepaliveReportsConnectFailureState(t *testing.T) {
 	}
 }
 
+func TestConnectionSessionLaunchesInBackground(t *testing.T) {
+	h := newHarness(t)
+	c, err := h.dialWS(t, "op", "/api/me/events")
+	if err != nil {
+		t.Fatalf("dial events: %v", err)
+	}
+	defer c.CloseNow()
+
+	resp := h.do(t, http.MethodPost, "/api/connections/c-op/session", "op", nil)
+	var started struct {
+		ID    string `json:"id"`
+		State string `json:"state"`
+	}
+	_ = json.Unmarshal(resp.Body, &started)
+	if resp.Status != http.StatusAccepted || started.State != "pending" || started.ID == "" {
+		t.Fatalf("launch: %d %s", resp.Status, resp.Body)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var stages []string
+	for {
+		var ev struct {
+			Type   string              `json:"type"`
+			Launch service.LaunchEvent `json:"sessionLaunch"`
+		}
+		if err := wsjson.Read(ctx, c, &ev); err != nil {
+			t.Fatalf("read launch events after %v: %v", stages, err)
+		}
+		if ev.Type != "sessionLaunch" || ev.Launch.SessionID != started.ID {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+		if ev.Launch.State == "connected" {
+			break
+		}
+		stages = append(stages, ev.Launch.Stage)
+	}
+	if !slices.Equal(stages, []string{"resolving", "connecting"}) {
+		t.Fatalf("stages = %v", stages)
+	}
+	resp = h.do(t, http.MethodGet, "/api/connections/c-op/session", "op", nil)
+	if resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `"state":"connected"`) {
+		t.Fatalf("status after launch: %d %s", resp.Status, resp.Body)
+	}
+}
+
 func TestConnectionSessionKeepaliveHonorsAccess(t *testing.T) {
 	h := newHarness(t)
 
-	if resp := h.do(t, http.MethodPost, "/api/connections/c-op/session", "viewer", nil); resp.Status != http.StatusForbidden {
+	if resp := h.do(t, http.MethodPost, "/api/connections/c-op/session?wait=true", "viewer", nil); resp.Status != http.StatusForbidden {
 		t.Fatalf("viewer without grant: want 403, got %d (%s)", resp.Status, resp.Body)
 	}
 	if err := h.store.Grants.Create(context.Background(), &models.Grant{
@@ -368,7 +598,7 @@ func TestConnectionSessionKeepaliveHonorsAccess(t *testing.T) {
 	}); err != nil {
 		t.Fatalf("create grant: %v", err)
 	}
-	if resp := h.do(t, http.MethodPost, "/api/connections/c-op/session", "viewer", nil); resp.Status != http.StatusOK {
+	if resp := h.do(t, http.MethodPost, "/api/connections/c-op/session?wait=true", "viewer", nil); resp.Status != http.StatusOK {
 		t.Fatalf("viewer with grant: want 200, got %d (%s)", resp.Status, resp.Body)
 	}
 
@@ -381,7 +611,7 @@ func TestConnectionSessionKeepaliveHonorsAccess(t *testing.T) {
 	}); err != nil {
 		t.Fatalf("create no-role grant: %v", err)
 	}
-	if resp := h.do(t, http.MethodPost, "/api/connections/c-op/session", "norole", nil); resp.Status != http.StatusForbidden {
+	if resp := h.do(t, http.MethodPost, "/api/connections/c-op/session?wait=true", "norole", nil); resp.Status != http.StatusForbidden {
 		t.Fatalf("no-role grantee: want 403, got %d (%s)", resp.Status, resp.Body)
 	}
 }
@@ -535,6 +765,28 @@ func TestConnectionFoldersAndLayout(t *testing.T) {
 	if !strings.Contains(body, `"folderId":"`+child.ID+`"`) || !strings.Contains(body, `"sortOrder":1`) {
 		t.Fatalf("connection list missing placement data: %s", resp.Body)
 	}
+	for query, want := range map[string]string{
+		"folder=" + folder.ID:                     "c-op",
+		"folder=" + folder.ID + "&recursive=true": "c-boom,c-op",
+		"folder=" + child.ID + "&recursive=true":  "c-boom",
+	} {
+		var list []struct {
+			ID string `json:"id"`
+		}
+		resp = h.do(t, http.MethodGet, "/api/connections?"+query, "op", nil)
+		_ = json.Unmarshal(resp.Body, &list)
+		var ids []string
+		for _, c := range list {
+			ids = append(ids, c.ID)
+		}
+		slices.Sort(ids)
+		if got := strings.Join(ids, ","); resp.Status != http.StatusOK || got != want {
+			t.Fatalf("list ?%s: want %s, got %d %q", query, want, resp.Status, got)
+		}
+	}
+	if resp := h.do(t, http.MethodGet, "/api/connections?folder=nope", "op", nil); resp.Status != http.StatusBadRequest {
+		t.Fatalf("unknown folder filter: want 400, got %d", resp.Status)
+	}
 
 	resp = h.do(t, http.MethodGet, "/api/connection-folders", "op", nil)
 	if resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), "Production") || !strings.Contains(string(resp.Body), `"parentId":"`+folder.ID+`"`) || !strings.Contains(string(resp.Body), `"sortOrder":4`) {
@@ -588,3 +840,440 @@ func TestConnectionFolderValidation(t *testing.T) {
 		t.Fatalf("bad parent folder: want 400, got %d (%s)", resp.Status, resp.Body)
 	}
 }
+
+func TestLaunchConnectionFolderReportsPerConnection(t *testing.T) {
+	h := newHarness(t)
+	resp := h.do(t, http.MethodPost, "/api/connection-folders", "op", strings.NewReader(`{"name":"Web"}`))
+	if resp.Status != http.StatusCreated {
+		t.Fatalf("create folder: %d (%s)", resp.Status, resp.Body)
+	}
+	var folder struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(resp.Body, &folder)
+	resp = h.do(t, http.MethodPut, "/api/connections/layout", "op",
+		strings.NewReader(`{"items":[{"connectionId":"c-op","folderId":"`+folder.ID+`","sortOrder":0},{"connectionId":"c-boom","folderId":"`+folder.ID+`","sortOrder":1},{"connectionId":"c-internal","sortOrder":0}]}`))
+	if resp.Status != http.StatusOK {
+		t.Fatalf("save layout: %d (%s)", resp.Status, resp.Body)
+	}
+
+	if resp := h.do(t, http.MethodPost, "/api/connection-folders/"+folder.ID+"/launch", "op2", nil); resp.Status != http.StatusForbidden {
+		t.Fatalf("another user's folder: want 403, got %d", resp.Status)
+	}
+	resp = h.do(t, http.MethodPost, "/api/connection-folders/"+folder.ID+"/launch", "op", strings.NewReader(`{"concurrency":1}`))
+	if resp.Status != http.StatusOK {
+		t.Fatalf("launch: %d (%s)", resp.Status, resp.Body)
+	}
+	var out struct {
+		BatchID string `json:"batchId"`
+		Results []struct {
+			ConnectionID string `json:"connectionId"`
+			Status       int    `json:"status"`
+			Error        string `json:"error"`
+			Session      *struct {
+				State string `json:"state"`
+			} `json:"session"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(resp.Body, &out); err != nil || out.BatchID == "" {
+		t.Fatalf("decode: %v (%s)", err, resp.Body)
+	}
+	if len(out.Results) != 2 || out.Results[0].ConnectionID != "c-op" || out.Results[1].ConnectionID != "c-boom" {
+		t.Fatalf("results should follow folder order without c-internal: %s", resp.Body)
+	}
+	if out.Results[0].Status != http.StatusOK || out.Results[0].Session == nil {
+		t.Fatalf("c-op should launch: %s", resp.Body)
+	}
+	if out.Results[1].Status != http.StatusServiceUnavailable || out.Results[1].Error == "" {
+		t.Fatalf("c-boom failure should be reported per item: %s", resp.Body)
+	}
+
+	rows, _ := h.store.Audit.List(context.Background(), store.AuditFilter{})
+	var batched int
+	for _, r := range rows {
+		if r.Event == "connection.session.launch" && r.Params["batch"] == out.BatchID {
+			batched++
+		}
+	}
+	if batched != 2 {
+		t.Fatalf("want 2 launch audit rows tagged with the batch, got %d", batched)
+	}
+}
+
+func TestConnectionFavorites(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+
+	for _, id := range []string{"c-op", "c-boom", "c-internal"} {
+		if resp := h.do(t, http.MethodPut, "/api/connections/"+id+"/favorite", "op", nil); resp.Status != http.StatusNoContent {
+			t.Fatalf("favorite %s: %d (%s)", id, resp.Status, resp.Body)
+		}
+	}
+	if resp := h.do(t, http.MethodPut, "/api/connections/c-view/favorite", "op", nil); resp.Status != http.StatusForbidden {
+		t.Fatalf("favorite inaccessible: want 403, got %d", resp.Status)
+	}
+	resp := h.do(t, http.MethodPatch, "/api/me/favorites/order", "op", strings.NewReader(`{"connectionIds":["c-internal","c-op"]}`))
+	if resp.Status != http.StatusNoContent {
+		t.Fatalf("reorder: %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodPatch, "/api/me/favorites/order", "op", strings.NewReader(`{"connectionIds":["c-view"]}`)); resp.Status != http.StatusBadRequest {
+		t.Fatalf("reorder non-favorite: want 400, got %d", resp.Status)
+	}
+
+	var list []struct {
+		ID               string `json:"id"`
+		IsFavorite       bool   `json:"isFavorite"`
+		FavoritePosition *int   `json:"favoritePosition"`
+	}
+	resp = h.do(t, http.MethodGet, "/api/connections?favorites_only=true", "op", nil)
+	_ = json.Unmarshal(resp.Body, &list)
+	positions := map[string]int{}
+	for _, c := range list {
+		if !c.IsFavorite || c.FavoritePosition == nil {
+			t.Fatalf("favorites_only returned a non-favorite: %s", resp.Body)
+		}
+		positions[c.ID] = *c.FavoritePosition
+	}
+	if len(positions) != 3 || positions["c-internal"] != 0 || positions["c-op"] != 1 || positions["c-boom"] != 2 {
+		t.Fatalf("favorite order: %v", positions)
+	}
+
+	// A favorite on a connection the user loses access to drops out quietly.
+	if resp := h.do(t, http.MethodPost, "/api/connections/c-op/grants", "op", strings.NewReader(`{"subjectId":"viewer","access":"view"}`)); resp.Status != http.StatusCreated {
+		t.Fatalf("grant: %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodPut, "/api/connections/c-op/favorite", "viewer", nil); resp.Status != http.StatusNoContent {
+		t.Fatalf("grantee favorite: %d (%s)", resp.Status, resp.Body)
+	}
+	grants, _ := h.store.Grants.ListByConnection(ctx, "c-op")
+	_ = h.store.Grants.Delete(ctx, grants[0].ID)
+	resp = h.do(t, http.MethodGet, "/api/connections?favorites_only=true", "viewer", nil)
+	if resp.Status != http.StatusOK || strings.Contains(string(resp.Body), "c-op") {
+		t.Fatalf("lost favorite should be omitted: %d %s", resp.Status, resp.Body)
+	}
+
+	if resp := h.do(t, http.MethodDelete, "/api/connections/c-op", "op", nil); resp.Status != http.StatusOK {
+		t.Fatalf("delete: %d", resp.Status)
+	}
+	if _, err := h.srv.PurgeConnectionTrash(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	for _, user := range []string{"op", "viewer"} {
+		favs, _ := h.store.ConnectionFavorites.ListByUser(ctx, user)
+		for _, f := range favs {
+			if f.ConnectionID == "c-op" {
+				t.Fatalf("purge should prune %s's favorite", user)
+			}
+		}
+	}
+}
+
+func TestExecConnection(t *testing.T) {
+	h := newHarness(t)
+	type result struct {
+		ExitCode    int    `json:"exitCode"`
+		Stdout      string `json:"stdout"`
+		Stderr      string `json:"stderr"`
+		TimedOut    bool   `json:"timedOut"`
+		RecordingID string `json:"recordingId"`
+	}
+	exec := func(conn, user, body string) (apiResp, result) {
+		t.Helper()
+		resp := h.do(t, http.MethodPost, "/api/connections/"+conn+"/exec", user, strings.NewReader(body))
+		var out result
+		if resp.Status == http.StatusOK {
+			if err := json.Unmarshal(resp.Body, &out); err != nil {
+				t.Fatalf("decode: %v (%s)", err, resp.Body)
+			}
+		}
+		return resp, out
+	}
+
+	resp, out := exec("c-op", "op", `{"command":"false","env":{"CI":"1"}}`)
+	if resp.Status != http.StatusOK || out.ExitCode != 1 || out.Stdout != "ran: false\n" || out.Stderr != "warning\n" {
+		t.Fatalf("exec: %d %+v (%s)", resp.Status, out, resp.Body)
+	}
+	if out.RecordingID != "" {
+		t.Fatalf("connection without a recording policy should not record: %+v", out)
+	}
+	rows, _ := h.store.Audit.List(context.Background(), store.AuditFilter{})
+	if !slices.ContainsFunc(rows, func(r models.AuditEntry) bool {
+		return r.Event == "connection.exec" && r.Result == models.AuditAllowed &&
+			r.Params["command"] == "false" && r.Params["env"] == "CI" && r.Params["exitCode"] == "1"
+	}) {
+		t.Fatalf("missing exec audit row: %+v", rows)
+	}
+
+	if resp, _ := exec("c-view", "viewer", `{"command":"id"}`); resp.Status != http.StatusForbidden {
+		t.Fatalf("viewer exec should be forbidden: %d", resp.Status)
+	}
+	if resp, _ := exec("c-internal", "op", `{"command":"id"}`); resp.Status != http.StatusNotImplemented {
+		t.Fatalf("protocol without exec should be 501: %d (%s)", resp.Status, resp.Body)
+	}
+	for _, body := range []string{`{"command":"  "}`, `{"command":"id","timeoutSeconds":100000}`} {
+		if resp, _ := exec("c-op", "op", body); resp.Status != http.StatusBadRequest {
+			t.Fatalf("%s: want 400, got %d", body, resp.Status)
+		}
+	}
+	resp, out = exec("c-op", "op", `{"command":"sleep","timeoutSeconds":1}`)
+	if resp.Status != http.StatusOK || !out.TimedOut || out.ExitCode != -1 {
+		t.Fatalf("timeout: %d %+v", resp.Status, out)
+	}
+
+	resp = h.do(t, http.MethodPost, "/api/connections", "op",
+		strings.NewReader(`{"name":"rec","protocol":"tester","config":{"host":"h"},"recording":{"terminal":"auto"}}`))
+	connID := createConnID(t, resp)
+	resp, out = exec(connID, "op", `{"command":"uptime"}`)
+	if resp.Status != http.StatusOK || out.RecordingID == "" {
+		t.Fatalf("recorded exec: %d %+v", resp.Status, out)
+	}
+	rec, err := h.store.Recordings.Get(context.Background(), out.RecordingID)
+	if err != nil || rec.Status != models.RecordingFinalized || rec.RouteID != "connection.exec" {
+		t.Fatalf("exec recording: %+v, %v", rec, err)
+	}
+}
+
+func TestSessionStatusCarriesConnectionSnapshot(t *testing.T) {
+	h := newHarness(t)
+	body := `{"name":"db1","protocol":"tester","transport":"direct","config":{"host":"db.local","password":"s3cret-value"}}`
+	id := createConnID(t, h.do(t, http.MethodPost, "/api/connections", "op", strings.NewReader(body)))
+	if resp := h.do(t, http.MethodPost, "/api/connections/"+id+"/session?wait=true", "op", nil); resp.Status != http.StatusOK {
+		t.Fatalf("open session: got %d (%s)", resp.Status, resp.Body)
+	}
+
+	resp := h.do(t, http.MethodGet, "/api/connections/"+id+"/session", "op", nil)
+	if strings.Contains(string(resp.Body), "s3cret-value") || strings.Contains(string(resp.Body), `"password"`) {
+		t.Fatalf("session snapshot leaked a secret: %s", resp.Body)
+	}
+	var out struct {
+		ConnectionSnapshot *models.ConnectionSnapshot `json:"connectionSnapshot"`
+	}
+	if err := json.Unmarshal(resp.Body, &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if cs := out.ConnectionSnapshot; cs == nil || cs.Protocol != "tester" || cs.Config["host"] != "db.local" || cs.Version.IsZero() {
+		t.Fatalf("connection snapshot = %+v (%s)", cs, resp.Body)
+	}
+}
+
+func TestConnectionFolderBundleRoundTrip(t *testing.T) {
+	h := newHarness(t)
+	mkFolder := func(body string) string {
+		resp := h.do(t, http.MethodPost, "/api/connection-folders", "op", strings.NewReader(body))
+		if resp.Status != http.StatusCreated {
+			t.Fatalf("create folder: %d (%s)", resp.Status, resp.Body)
+		}
+		var f struct {
+			ID string `json:"id"`
+		}
+		_ = json.Unmarshal(resp.Body, &f)
+		return f.ID
+	}
+	_ = h.store.Credentials.Create(context.Background(), &models.Credential{ID: "cred-op", Name: "op", Kind: "db_password", OwnerID: "op"})
+	_ = h.store.Grants.Create(context.Background(), &models.Grant{ID: "g-op-view", ConnectionID: "c-view", SubjectID: "op", Access: models.AccessView})
+	root := mkFolder(`{"name":"Prod","color":"blue"}`)
+	sub := mkFolder(`{"name":"DB","parentId":"` + root + `"}`)
+	resp := h.do(t, http.MethodPost, "/api/connections", "op",
+		strings.NewReader(`{"name":"pg","protocol":"tester","config":{"host":"db.local","password":"hunter2","credential_id":"cred-op"}}`))
+	if resp.Status != http.StatusCreated {
+		t.Fatalf("create connection: %d (%s)", resp.Status, resp.Body)
+	}
+	pg := createConnID(t, resp)
+	resp = h.do(t, http.MethodPut, "/api/connections/layout", "op",
+		strings.NewReader(`{"items":[{"connectionId":"`+pg+`","folderId":"`+sub+`","sortOrder":0},{"connectionId":"c-view","folderId":"`+root+`","sortOrder":0}]}`))
+	if resp.Status != http.StatusOK {
+		t.Fatalf("save layout: %d (%s)", resp.Status, resp.Body)
+	}
+
+	if resp := h.do(t, http.MethodGet, "/api/connection-folders/"+root+"/export", "op2", nil); resp.Status != http.StatusForbidden {
+		t.Fatalf("export another user's folder: want 403, got %d", resp.Status)
+	}
+	resp = h.do(t, http.MethodGet, "/api/connection-folders/"+root+"/export", "op", nil)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("export: %d (%s)", resp.Status, resp.Body)
+	}
+	for _, leak := range []string{"hunter2", "cred-op", "password", "credential_id"} {
+		if strings.Contains(string(resp.Body), leak) {
+			t.Fatalf("bundle leaks %q: %s", leak, resp.Body)
+		}
+	}
+	var bundle struct {
+		Folders []struct {
+			Name string `json:"name"`
+		} `json:"folders"`
+		Connections []struct {
+			Name   string         `json:"name"`
+			Config map[string]any `json:"config"`
+		} `json:"connections"`
+		Omitted int `json:"omitted"`
+	}
+	if err := json.Unmarshal(resp.Body, &bundle); err != nil {
+		t.Fatalf("decode bundle: %v", err)
+	}
+	if len(bundle.Folders) != 2 || len(bundle.Connections) != 1 || bundle.Omitted != 1 || bundle.Connections[0].Config["host"] != "db.local" {
+		t.Fatalf("bundle contents: %s", resp.Body)
+	}
+
+	type report struct {
+		Folders []struct {
+			ID        string `json:"id"`
+			Status    string `json:"status"`
+			RenamedTo string `json:"renamedTo"`
+		} `json:"folders"`
+		Connections []struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		} `json:"connections"`
+	}
+	importAs := func(user, onConflict string) (apiResp, report) {
+		resp := h.do(t, http.MethodPost, "/api/connection-folders/import", user,
+			strings.NewReader(`{"onConflict":"`+onConflict+`","bundle":`+string(resp.Body)+`}`))
+		var rep report
+		_ = json.Unmarshal(resp.Body, &rep)
+		return resp, rep
+	}
+
+	if resp, _ := importAs("viewer", ""); resp.Status != http.StatusForbidden {
+		t.Fatalf("viewer import: want 403, got %d", resp.Status)
+	}
+	if resp, _ := importAs("op", "merge"); resp.Status != http.StatusBadRequest {
+		t.Fatalf("bad strategy: want 400, got %d", resp.Status)
+	}
+	r, rep := importAs("op", "skip")
+	if r.Status != http.StatusOK || len(rep.Folders) != 2 || rep.Folders[0].Status != "skipped" || rep.Connections[0].Status != "skipped" {
+		t.Fatalf("skip import: %d (%s)", r.Status, r.Body)
+	}
+	r, rep = importAs("op", "")
+	if r.Status != http.StatusOK || rep.Folders[0].RenamedTo != "Prod (2)" || rep.Connections[0].Status != "created" {
+		t.Fatalf("rename import: %d (%s)", r.Status, r.Body)
+	}
+	conn, err := h.store.Connections.Get(context.Background(), rep.Connections[0].ID)
+	if err != nil || conn.OwnerID != "op" || len(conn.Secrets) != 0 {
+		t.Fatalf("imported connection: %+v %v", conn, err)
+	}
+	r, rep = importAs("op2", "fail")
+	if r.Status != http.StatusOK || rep.Folders[0].Status != "created" || rep.Connections[0].Status != "created" {
+		t.Fatalf("import into another account: %d (%s)", r.Status, r.Body)
+	}
+}
+
+func TestConnectionTransferAndReassign(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+	transfer := func(user, connID, to string) apiResp {
+		return h.do(t, http.MethodPost, "/api/connections/"+connID+"/transfer-ownership", user, strings.NewReader(`{"newOwnerId":"`+to+`"}`))
+	}
+	_ = h.store.Grants.Create(ctx, &models.Grant{ID: "g-view", ConnectionID: "c-op", SubjectID: "viewer", Access: models.AccessView})
+
+	if resp := transfer("viewer", "c-op", "op2"); resp.Status != http.StatusForbidden {
+		t.Fatalf("grantee transfer = %d, want 403", resp.Status)
+	}
+	if resp := transfer("op", "c-op", "ghost"); resp.Status != http.StatusBadRequest {
+		t.Fatalf("transfer to unknown user = %d, want 400", resp.Status)
+	}
+	if resp := transfer("op", "c-op", "op2"); resp.Status != http.StatusOK {
+		t.Fatalf("owner transfer = %d %s", resp.Status, resp.Body)
+	}
+	if conn, _ := h.store.Connections.Get(ctx, "c-op"); conn.OwnerID != "op2" {
+		t.Fatalf("owner after transfer = %q, want op2", conn.OwnerID)
+	}
+	if g, err := h.store.Grants.Get(ctx, "c-op", "viewer"); err != nil || g.ID != "g-view" {
+		t.Fatalf("grant after transfer = %+v, %v; want kept", g, err)
+	}
+	waitForAudit(t, h, func(e models.AuditEntry) bool {
+		return e.Event == "connection.transfer" && e.Result == models.AuditAllowed && e.Params["previousOwner"] == "op" && e.Params["newOwner"] == "op2"
+	})
+
+	// A credential only op can use holds its connection back unless the
+	// credentials move along.
+	_ = h.store.Credentials.Create(ctx, &models.Credential{ID: "cred-op", Name: "mine", Kind: "db_password", OwnerID: "op"})
+	_ = h.store.Connections.Create(ctx, &models.Connection{ID: "c-ref", Name: "ref", Protocol: "tester", OwnerID: "op", Transport: "direct",
+		Config: map[string]any{"host": "h", "credential_id": "cred-op"}})
+	if resp := transfer("op", "c-ref", "op2"); resp.Status != http.StatusForbidden {
+		t.Fatalf("transfer with an unusable credential = %d %s, want 403", resp.Status, resp.Body)
+	}
+
+	resp := h.do(t, http.MethodDelete, "/api/admin/users/op?orphanCredentials=true", "admin", nil)
+	var env struct {
+		Code             string `json:"code"`
+		OwnedConnections int    `json:"ownedConnections"`
+	}
+	_ = json.Unmarshal(resp.Body, &env)
+	if resp.Status != http.StatusConflict || env.Code != "owned_connections" || env.OwnedConnections != 3 {
+		t.Fatalf("delete owner = %d %s", resp.Status, resp.Body)
+	}
+	// c-ref could not follow its credential to another user, so nothing moves.
+	if resp := h.do(t, http.MethodDelete, "/api/admin/users/op?transferTo=op2&transferConnectionsTo=viewer", "admin", nil); resp.Status != http.StatusConflict {
+		t.Fatalf("delete with a stranded connection = %d %s, want 409", resp.Status, resp.Body)
+	}
+	if cred, _ := h.store.Credentials.Get(ctx, "cred-op"); cred.OwnerID != "op" {
+		t.Fatalf("credential owner after refused delete = %q, want op", cred.OwnerID)
+	}
+
+	_ = h.store.Connections.Create(ctx, &models.Connection{ID: "c-clash", Name: "Boom", Protocol: "tester", OwnerID: "op2", Transport: "direct"})
+	reassign := func(user, body string) apiResp {
+		return h.do(t, http.MethodPost, "/api/admin/connections/reassign", user, strings.NewReader(body))
+	}
+	if resp := reassign("op", `{"fromUserId":"op","toUserId":"op2"}`); resp.Status != http.StatusForbidden {
+		t.Fatalf("non-admin reassign = %d, want 403", resp.Status)
+	}
+	var out struct {
+		Moved   []string `json:"moved"`
+		Renamed []struct {
+			ConnectionID string `json:"connectionId"`
+			To           string `json:"to"`
+		} `json:"renamed"`
+		Skipped []struct {
+			ConnectionID string `json:"connectionId"`
+		} `json:"skipped"`
+	}
+	resp = reassign("admin", `{"fromUserId":"op","toUserId":"op2"}`)
+	if err := json.Unmarshal(resp.Body, &out); err != nil || resp.Status != http.StatusOK {
+		t.Fatalf("reassign = %d %s", resp.Status, resp.Body)
+	}
+	if len(out.Moved) != 2 || len(out.Skipped) != 1 || out.Skipped[0].ConnectionID != "c-ref" {
+		t.Fatalf("reassign = %+v", out)
+	}
+	if len(out.Renamed) != 1 || out.Renamed[0].ConnectionID != "c-boom" || out.Renamed[0].To != "boom (2)" {
+		t.Fatalf("renamed = %+v", out.Renamed)
+	}
+	resp = reassign("admin", `{"fromUserId":"op","toUserId":"op2","includeCredentials":true}`)
+	if resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `"credentialsTransferred":1`) || !strings.Contains(string(resp.Body), `"moved":["c-ref"]`) {
+		t.Fatalf("reassign with credentials = %d %s", resp.Status, resp.Body)
+	}
+	if list, _ := h.store.Connections.ListByOwner(ctx, "op"); len(list) != 0 {
+		t.Fatalf("op still owns %+v", list)
+	}
+	waitForAudit(t, h, func(e models.AuditEntry) bool {
+		return e.Event == "connection.transfer" && e.RouteID == "connection.reassign" && e.ConnectionID == "c-ref" && e.Params["previousOwner"] == "op"
+	})
+	if resp := h.do(t, http.MethodDelete, "/api/admin/users/op", "admin", nil); resp.Status != http.StatusOK {
+		t.Fatalf("delete after reassign = %d %s", resp.Status, resp.Body)
+	}
+}
+
+func TestConnectionRejectsUnknownSettings(t *testing.T) {
+	h := newHarness(t)
+	body := `{"name":"typo","protocol":"tester","config":{"host":"h","pass_word":"x","zzz":1}}`
+	resp := h.do(t, http.MethodPost, "/api/connections", "op", strings.NewReader(body))
+	var env struct {
+		Fields []struct {
+			Field      string `json:"field"`
+			Suggestion string `json:"suggestion"`
+		} `json:"fields"`
+	}
+	if resp.Status != http.StatusBadRequest || json.Unmarshal(resp.Body, &env) != nil || len(env.Fields) != 2 {
+		t.Fatalf("create: %d %s", resp.Status, resp.Body)
+	}
+	if f := env.Fields[0]; f.Field != "pass_word" || f.Suggestion != "password" {
+		t.Fatalf("near miss = %+v", f)
+	}
+	if f := env.Fields[1]; f.Field != "zzz" || f.Suggestion != "" {
+		t.Fatalf("unrelated key = %+v", f)
+	}
+
+	resp = h.do(t, http.MethodPut, "/api/connections/c-op", "op", strings.NewReader(`{"name":"op","config":{"host":"h","Host":"x"}}`))
+	if resp.Status != http.StatusBadRequest || !strings.Contains(string(resp.Body), `did you mean \"host\"?`) {
+		t.Fatalf("update: %d %s", resp.Status, resp.Body)
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+type credentialAccessDTO struct {
+	ID           string    `json:"id"`
+	Time         time.Time `json:"time"`
+	UserID       string    `json:"userId"`
+	Username     string    `json:"username,omitempty"`
+	ConnectionID string    `json:"connectionId,omitempty"`
+	Purpose      string    `json:"purpose"`
+	Result       string    `json:"result"`
+}
+
+type credentialAccessPage struct {
+	Items []credentialAccessDTO `json:"items"`
+	Total int64                 `json:"total"`
+}
+
+// handleCredentialAccessLog pages through one credential's access log. Only the
+// owner and the protected root admin may read it; grantees never see who else
+// used a credential shared with them.
+func (s *Server) handleCredentialAccessLog(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	cred, err := s.deps.Store.Credentials.Get(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	if !isOwner(user, cred.OwnerID) && !user.Protected {
+		writeError(w, s.deps.Logger, plugin.ErrForbidden)
+		return
+	}
+
+	limit, offset := auditPageParams(r)
+	entries, err := s.deps.Store.CredentialAccess.List(ctx, store.CredentialAccessFilter{CredentialID: cred.ID, Limit: limit, Offset: offset})
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	total, err := s.deps.Store.CredentialAccess.Count(ctx, store.CredentialAccessFilter{CredentialID: cred.ID})
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	items := make([]credentialAccessDTO, 0, len(entries))
+	for _, e := range entries {
+		username, _ := s.subjectLabel(ctx, e.UserID)
+		items = append(items, credentialAccessDTO{
+			ID: e.ID, Time: e.Time, UserID: e.UserID, Username: username,
+			ConnectionID: e.ConnectionID, Purpose: e.Purpose, Result: string(e.Result),
+		})
+	}
+	writeJSON(w, http.StatusOK, credentialAccessPage{Items: items, Total: total})
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/audit"
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	credApproveEvent = "credential.approval.approve"
+	// Only the root admin changes the exemption, since it covers root itself.
+	approvalExemptRootEvent = "system.approval_exempt_root"
+)
+
+type approvalExemptRootDTO struct {
+	ExemptRoot bool `json:"exemptRoot"`
+}
+
+func (s *Server) handleListCredentialApprovals(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	list, err := s.deps.Approvals.List(ctx, user)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) handleApproveCredentialApproval(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	approval, err := s.deps.Approvals.Approve(ctx, user, chi.URLParam(r, "id"))
+	params := map[string]string{"approvalId": chi.URLParam(r, "id"), "requesterId": approval.RequesterID}
+	if err != nil {
+		result := models.AuditError
+		if statusFor(err) == http.StatusForbidden {
+			result = models.AuditDenied
+		}
+		s.auditCredApprovalEvent(r, user, approval.CredentialID, params, result, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditCredApprovalEvent(r, user, approval.CredentialID, params, models.AuditAllowed, nil)
+	writeJSON(w, http.StatusOK, approval)
+}
+
+func (s *Server) auditCredApprovalEvent(r *http.Request, user models.User, credID string, params map[string]string, result models.AuditResult, err error) {
+	params["credentialId"] = credID
+	s.deps.Audit.Record(r.Context(), audit.Event{
+		User: user, Event: credApproveEvent, RouteID: credApproveEvent, Risk: string(plugin.RiskPrivileged),
+		Result: result, Params: params, Err: err,
+	})
+}
+
+func (s *Server) handleGetApprovalExemptRoot(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, approvalExemptRootDTO{ExemptRoot: s.deps.Approvals.ExemptRoot()})
+}
+
+func (s *Server) handleSetApprovalExemptRoot(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actor, _ := userFrom(ctx)
+	if !actor.Protected {
+		writeError(w, s.deps.Logger, errForbidden("only the root admin may change the approval exemption"))
+		return
+	}
+	var req approvalExemptRootDTO
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	params := map[string]string{"exemptRoot": strconv.FormatBool(req.ExemptRoot)}
+	if err := s.deps.Approvals.SetExemptRoot(ctx, actor, req.ExemptRoot); err != nil {
+		s.auditAdminEvent(ctx, actor, approvalExemptRootEvent, models.AuditError, params, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditAdminEvent(ctx, actor, approvalExemptRootEvent, models.AuditAllowed, params, nil)
+	writeJSON(w, http.StatusOK, req)
+}
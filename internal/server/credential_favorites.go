@@ -0,0 +1,43 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	credFavoriteAddEvent    = "credential_favorite.add"
+	credFavoriteRemoveEvent = "credential_favorite.remove"
+)
+
+// handleAddCredentialFavorite pins a credential the caller can use.
+func (s *Server) handleAddCredentialFavorite(w http.ResponseWriter, r *http.Request) {
+	s.setCredentialFavorite(w, r, true, credFavoriteAddEvent)
+}
+
+// handleRemoveCredentialFavorite unpins a credential, with or without access.
+func (s *Server) handleRemoveCredentialFavorite(w http.ResponseWriter, r *http.Request) {
+	s.setCredentialFavorite(w, r, false, credFavoriteRemoveEvent)
+}
+
+func (s *Server) setCredentialFavorite(w http.ResponseWriter, r *http.Request, favorite bool, event string) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	credID := chi.URLParam(r, "id")
+	if err := s.deps.Credentials.SetFavorite(ctx, user.ID, credID, favorite); err != nil {
+		result := models.AuditError
+		if errors.Is(err, models.ErrForbidden) {
+			result = models.AuditDenied
+		}
+		s.auditCredEvent(ctx, user, credID, event, plugin.RiskWrite, result, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditCredEvent(ctx, user, credID, event, plugin.RiskWrite, models.AuditAllowed, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
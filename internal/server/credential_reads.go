@@ -0,0 +1,44 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// Only the root admin changes the quota, since it can lock users out of their
+// credentials.
+const credentialReadQuotaEvent = "system.credential_read_quota"
+
+func (s *Server) handleGetCredentialReadQuota(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.deps.CredentialReads.Quota())
+}
+
+func (s *Server) handleSetCredentialReadQuota(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actor, _ := userFrom(ctx)
+	if !actor.Protected {
+		writeError(w, s.deps.Logger, errForbidden("only the root admin may change the credential read quota"))
+		return
+	}
+	var req service.CredentialReadQuota
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	params := map[string]string{
+		"userLimit": strconv.Itoa(req.UserLimit), "credentialLimit": strconv.Itoa(req.CredentialLimit),
+		"windowSeconds": strconv.Itoa(req.WindowSeconds), "blockSeconds": strconv.Itoa(req.BlockSeconds),
+	}
+	if err := s.deps.CredentialReads.SetQuota(ctx, actor, req); err != nil {
+		s.auditAdminEvent(ctx, actor, credentialReadQuotaEvent, models.AuditError, params, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditAdminEvent(ctx, actor, credentialReadQuotaEvent, models.AuditAllowed, params, nil)
+	writeJSON(w, http.StatusOK, s.deps.CredentialReads.Quota())
+}
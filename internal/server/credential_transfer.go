@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/audit"
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	credTransferEvent    = "credential.transfer"
+	credTransferAllEvent = "credential.transfer_all"
+	userDeleteEvent      = "user.delete"
+)
+
+type credentialTransferRequest struct {
+	NewOwnerID string `json:"newOwnerId"`
+}
+
+type credentialTransferAllRequest struct {
+	FromUserID string `json:"fromUserId"`
+	ToUserID   string `json:"toUserId"`
+}
+
+type credentialTransferAllDTO struct {
+	Transferred int `json:"transferred"`
+}
+
+// activeTransferTarget checks that a new credential owner is a live account.
+func (s *Server) activeTransferTarget(ctx context.Context, id string) error {
+	to, err := s.deps.Users.Get(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("%w: new owner does not exist", plugin.ErrInvalidInput)
+	}
+	if err != nil {
+		return err
+	}
+	if to.Disabled {
+		return fmt.Errorf("%w: new owner is deactivated", plugin.ErrInvalidInput)
+	}
+	return nil
+}
+
+// handleTransferCredential hands one credential to another user. The owner or
+// the root admin may do this; grants already issued are kept.
+func (s *Server) handleTransferCredential(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	id := chi.URLParam(r, "id")
+	var req credentialTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	params := map[string]string{"credentialId": id, "newOwner": req.NewOwnerID}
+	record := func(result models.AuditResult, err error) {
+		s.deps.Audit.Record(ctx, audit.Event{
+			User: user, Event: credTransferEvent, RouteID: credTransferEvent,
+			Risk: string(plugin.RiskPrivileged), Result: result, Params: params, Err: err,
+		})
+	}
+	if err := s.activeTransferTarget(ctx, req.NewOwnerID); err != nil {
+		record(models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	prev, _ := s.deps.Store.Credentials.Get(ctx, id)
+	cred, err := s.deps.Credentials.TransferOwnership(ctx, user, id, req.NewOwnerID)
+	switch {
+	case errors.Is(err, plugin.ErrForbidden):
+		record(models.AuditDenied, err)
+	case err != nil:
+		record(models.AuditError, err)
+	default:
+		params["previousOwner"] = prev.OwnerID
+		record(models.AuditAllowed, nil)
+	}
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, cred.Summary())
+}
+
+// handleAdminTransferCredentials moves every credential one user owns to
+// another, e.g. before the first user leaves.
+func (s *Server) handleAdminTransferCredentials(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actor, _ := userFrom(ctx)
+	var req credentialTransferAllRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	params := map[string]string{"from": req.FromUserID, "to": req.ToUserID}
+	err := s.activeTransferTarget(ctx, req.ToUserID)
+	n := 0
+	if err == nil {
+		n, err = s.deps.Credentials.TransferAllOwnership(ctx, req.FromUserID, req.ToUserID)
+	}
+	params["transferred"] = strconv.Itoa(n)
+	result := models.AuditAllowed
+	if err != nil {
+		result = models.AuditError
+	}
+	s.deps.Audit.Record(ctx, audit.Event{
+		User: actor, Event: credTransferAllEvent, RouteID: credTransferAllEvent,
+		Risk: string(plugin.RiskPrivileged), Result: result, Params: params, Err: err,
+	})
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, credentialTransferAllDTO{Transferred: n})
+}
+
+// handleAdminDeleteUser deletes an account. If the user owns credentials the
+// request must name ?transferTo=<userId> or confirm ?orphanCredentials=true,
+// and likewise ?transferConnectionsTo or ?orphanConnections=true for owned
+// connections; otherwise it fails with the number at stake.
+func (s *Server) handleAdminDeleteUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actor, _ := userFrom(ctx)
+	target, err := s.deps.Users.Get(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	q := r.URL.Query()
+	opts := service.UserDeleteOptions{
+		TransferCredentialsTo: q.Get("transferTo"), OrphanCredentials: q.Get("orphanCredentials") == "true",
+		TransferConnectionsTo: q.Get("transferConnectionsTo"), OrphanConnections: q.Get("orphanConnections") == "true",
+	}
+	params := map[string]string{"username": target.Username}
+	if opts.TransferCredentialsTo != "" {
+		params["transferTo"] = opts.TransferCredentialsTo
+	}
+	if opts.OrphanCredentials {
+		params["orphanCredentials"] = "true"
+	}
+	if opts.TransferConnectionsTo != "" {
+		params["transferConnectionsTo"] = opts.TransferConnectionsTo
+	}
+	if opts.OrphanConnections {
+		params["orphanConnections"] = "true"
+	}
+	deny := func(msg string) {
+		s.auditAdminEvent(ctx, actor, userDeleteEvent, models.AuditDenied, params, plugin.ErrForbidden)
+		writeError(w, s.deps.Logger, errForbidden(msg))
+	}
+	switch {
+	case target.ID == actor.ID:
+		deny("you cannot delete your own account")
+		return
+	case target.Protected:
+		deny("the root admin cannot be deleted")
+		return
+	case target.HasRole(models.RoleAdmin) && !actor.Protected:
+		deny("only the root admin may manage another admin")
+		return
+	}
+	if err := s.deps.Users.Delete(ctx, target.ID, opts); err != nil {
+		s.auditAdminEvent(ctx, actor, userDeleteEvent, models.AuditError, params, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditAdminEvent(ctx, actor, userDeleteEvent, models.AuditAllowed, params, nil)
+	writeJSON(w, http.StatusOK, okDTO{"ok": true})
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const credDiffEvent = "credential.versions.diff"
+
+// handleCredentialVersions lists a credential's versions. Only the owner, who
+// may edit the credential, sees its history.
+func (s *Server) handleCredentialVersions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	cred, err := s.deps.Store.Credentials.Get(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	if !canManageCredential(user, cred) {
+		writeError(w, s.deps.Logger, plugin.ErrForbidden)
+		return
+	}
+	versions, err := s.deps.Credentials.Versions(ctx, cred.ID)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, versions)
+}
+
+// handleCredentialVersionDiff reports which fields changed between two
+// versions without returning any value.
+func (s *Server) handleCredentialVersionDiff(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	cred, err := s.deps.Store.Credentials.Get(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	if !canManageCredential(user, cred) {
+		s.auditCredEvent(ctx, user, cred.ID, credDiffEvent, plugin.RiskSafe, models.AuditDenied, plugin.ErrForbidden)
+		writeError(w, s.deps.Logger, plugin.ErrForbidden)
+		return
+	}
+	from, errFrom := strconv.Atoi(r.URL.Query().Get("from"))
+	to, errTo := strconv.Atoi(r.URL.Query().Get("to"))
+	if errFrom != nil || errTo != nil || from < 1 || to < 1 {
+		writeError(w, s.deps.Logger, fmt.Errorf("%w: from and to must be version numbers", plugin.ErrInvalidInput))
+		return
+	}
+	diff, err := s.deps.Credentials.DiffVersions(ctx, cred.ID, from, to)
+	if err != nil {
+		s.auditCredEvent(ctx, user, cred.ID, credDiffEvent, plugin.RiskSafe, models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditCredEvent(ctx, user, cred.ID, credDiffEvent, plugin.RiskSafe, models.AuditAllowed, nil)
+	writeJSON(w, http.StatusOK, diff)
+}
@@ -23,6 +23,14 @@ type credentialWriteRequest struct {
 	Name   string            `json:"name"`
 	Kind   string            `json:"kind"`
 	Values map[string]string `json:"values"`
+	// RequiresApproval turns two-person approval on or off; omitted keeps it.
+	RequiresApproval *bool `json:"requiresApproval,omitempty"`
+}
+
+// credentialWriteResponse is the saved credential plus non-fatal warnings.
+type credentialWriteResponse struct {
+	models.CredentialSummary
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 func canManageCredential(user models.User, cred models.Credential) bool {
@@ -52,6 +60,7 @@ func (s *Server) handleCreateCredential(w http.ResponseWriter, r *http.Request)
 	}
 	cred, err := s.deps.Credentials.Create(ctx, service.NewCredentialInput{
 		OwnerID: user.ID, Name: req.Name, Kind: req.Kind, Values: req.Values,
+		RequiresApproval: req.RequiresApproval != nil && *req.RequiresApproval,
 	})
 	if err != nil {
 		s.auditCredEvent(ctx, user, "", credCreateEvent, plugin.RiskWrite, models.AuditError, err)
@@ -59,7 +68,7 @@ func (s *Server) handleCreateCredential(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	s.auditCredEvent(ctx, user, cred.ID, credCreateEvent, plugin.RiskWrite, models.AuditAllowed, nil)
-	writeJSON(w, http.StatusCreated, cred.Summary())
+	writeJSON(w, http.StatusCreated, credentialWriteResponse{CredentialSummary: cred.Summary(), Warnings: s.deps.Credentials.KindWarnings(cred.Kind)})
 }
 
 func (s *Server) handleUpdateCredential(w http.ResponseWriter, r *http.Request) {
@@ -82,7 +91,8 @@ func (s *Server) handleUpdateCredential(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	updated, err := s.deps.Credentials.Update(ctx, cred.ID, service.UpdateCredentialInput{
-		Name: req.Name, Kind: req.Kind, Values: req.Values,
+		Name: req.Name, Kind: req.Kind, Values: req.Values, ActorID: user.ID,
+		RequiresApproval: req.RequiresApproval,
 	})
 	if err != nil {
 		s.auditCredEvent(ctx, user, cred.ID, credUpdateEvent, plugin.RiskWrite, models.AuditError, err)
@@ -90,7 +100,7 @@ func (s *Server) handleUpdateCredential(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	s.auditCredEvent(ctx, user, cred.ID, credUpdateEvent, plugin.RiskWrite, models.AuditAllowed, nil)
-	writeJSON(w, http.StatusOK, updated.Summary())
+	writeJSON(w, http.StatusOK, credentialWriteResponse{CredentialSummary: updated.Summary(), Warnings: s.deps.Credentials.KindWarnings(updated.Kind)})
 }
 
 func (s *Server) handleDeleteCredential(w http.ResponseWriter, r *http.Request) {
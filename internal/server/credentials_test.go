@@ -6,7 +6,10 @@ import (
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
 	"github.com/charlesng35/shellcn/sdk/plugin"
 )
 
@@ -58,6 +61,50 @@ func TestCredentialCreateRotateAuthz(t *testing.T) {
 	}
 }
 
+func TestCredentialVersionDiff(t *testing.T) {
+	h := newHarness(t)
+	id := createCredID(t, h, "op",
+		`{"name":"db pw","kind":"db_password","values":{"username":"app","password":"secret-value-123"}}`)
+	if resp := h.do(t, http.MethodPut, "/api/credentials/"+id, "op",
+		strings.NewReader(`{"name":"db pw","kind":"db_password","values":{"username":"app","password":"rotated-456"}}`)); resp.Status != http.StatusOK {
+		t.Fatalf("rotate: got %d (%s)", resp.Status, resp.Body)
+	}
+
+	resp := h.do(t, http.MethodGet, "/api/credentials/"+id+"/versions/diff?from=1&to=2", "op", nil)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("diff: got %d (%s)", resp.Status, resp.Body)
+	}
+	for _, v := range []string{"secret-value-123", "rotated-456", `"app"`} {
+		if strings.Contains(string(resp.Body), v) {
+			t.Fatalf("diff leaked %s: %s", v, resp.Body)
+		}
+	}
+	var diff struct {
+		Fields []struct {
+			Key     string `json:"key"`
+			Changed bool   `json:"changed"`
+		} `json:"fields"`
+	}
+	_ = json.Unmarshal(resp.Body, &diff)
+	changed := map[string]bool{}
+	for _, f := range diff.Fields {
+		changed[f.Key] = f.Changed
+	}
+	if !changed["password"] || changed["username"] {
+		t.Fatalf("diff fields = %+v", diff.Fields)
+	}
+
+	if resp := h.do(t, http.MethodGet, "/api/credentials/"+id+"/versions/diff?from=1&to=2", "viewer", nil); resp.Status != http.StatusForbidden {
+		t.Errorf("non-owner diff: want 403, got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/credentials/"+id+"/versions/diff?from=x", "op", nil); resp.Status != http.StatusBadRequest {
+		t.Errorf("bad version: want 400, got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/credentials/"+id+"/versions/diff?from=1&to=5", "op", nil); resp.Status != http.StatusNotFound {
+		t.Errorf("unknown version: want 404, got %d", resp.Status)
+	}
+}
+
 func TestCredentialKindsEndpoint(t *testing.T) {
 	h := newHarness(t)
 
@@ -111,6 +158,18 @@ func TestCredentialCreateRejectsUnknownAndDerivesProtocols(t *testing.T) {
 	if resp.Status != http.StatusBadRequest {
 		t.Fatalf("unknown kind: want 400, got %d (%s)", resp.Status, resp.Body)
 	}
+
+	resp = h.do(t, http.MethodPost, "/api/credentials", "op",
+		strings.NewReader(`{"name":"bad","kind":"ssh_password","values":{"shell":"zsh"}}`))
+	var envelope struct {
+		Fields []struct {
+			Field string `json:"field"`
+		} `json:"fields"`
+	}
+	_ = json.Unmarshal(resp.Body, &envelope)
+	if resp.Status != http.StatusBadRequest || len(envelope.Fields) != 3 {
+		t.Fatalf("every bad field should be reported: got %d (%s)", resp.Status, resp.Body)
+	}
 }
 
 func TestCredentialDeleteBlockedWhileReferenced(t *testing.T) {
@@ -157,3 +216,324 @@ func TestCredentialDeleteBlockedForAnyCredentialRefField(t *testing.T) {
 		t.Fatalf("delete while referenced through alternate field: want 409, got %d (%s)", resp.Status, resp.Body)
 	}
 }
+
+func TestCredentialAccessLogRecordsSessionLaunch(t *testing.T) {
+	h := newHarness(t)
+	id := createCredID(t, h, "op",
+		`{"name":"db pw","kind":"db_password","values":{"username":"app","password":"secret-value-123"}}`)
+
+	ctx := context.Background()
+	conn, _ := h.store.Connections.Get(ctx, "c-op")
+	conn.Config = map[string]any{"host": "db", "credential_id": id}
+	if err := h.store.Connections.Update(ctx, &conn); err != nil {
+		t.Fatalf("update connection: %v", err)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/connections/c-op/x/tester.list", "op", nil); resp.Status != http.StatusOK {
+		t.Fatalf("launch: want 200, got %d (%s)", resp.Status, resp.Body)
+	}
+
+	// The row is written in the background.
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if n, _ := h.store.CredentialAccess.Count(ctx, store.CredentialAccessFilter{CredentialID: id}); n > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	resp := h.do(t, http.MethodGet, "/api/credentials/"+id+"/access-log", "op", nil)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("access log: want 200, got %d (%s)", resp.Status, resp.Body)
+	}
+	var page struct {
+		Items []struct {
+			UserID       string `json:"userId"`
+			ConnectionID string `json:"connectionId"`
+			Purpose      string `json:"purpose"`
+			Result       string `json:"result"`
+		} `json:"items"`
+		Total int64 `json:"total"`
+	}
+	if err := json.Unmarshal(resp.Body, &page); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if page.Total != 1 || len(page.Items) != 1 {
+		t.Fatalf("access log entries: %+v", page)
+	}
+	got := page.Items[0]
+	if got.UserID != "op" || got.ConnectionID != "c-op" || got.Purpose != "session.launch" || got.Result != "allowed" {
+		t.Errorf("access log entry: %+v", got)
+	}
+	if strings.Contains(string(resp.Body), "secret-value-123") {
+		t.Fatalf("access log leaked secret: %s", resp.Body)
+	}
+
+	// Neither other users nor a non-root admin may read it.
+	for _, u := range []string{"viewer", "admin"} {
+		if resp := h.do(t, http.MethodGet, "/api/credentials/"+id+"/access-log", u, nil); resp.Status != http.StatusForbidden {
+			t.Errorf("%s access log: want 403, got %d", u, resp.Status)
+		}
+	}
+}
+
+func TestCredentialReadQuotaBlocksLaunch(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+	_ = h.store.Users.Create(ctx, &models.User{ID: "root", Username: "root", Roles: []models.Role{models.RoleAdmin}, Protected: true}, "")
+	h.sessions["root"] = h.sessionMgr.Create("root")
+	id := createCredID(t, h, "op",
+		`{"name":"db pw","kind":"db_password","values":{"username":"app","password":"secret-value-123"}}`)
+	conn, _ := h.store.Connections.Get(ctx, "c-op")
+	conn.Config = map[string]any{"host": "db", "credential_id": id}
+	if err := h.store.Connections.Update(ctx, &conn); err != nil {
+		t.Fatalf("update connection: %v", err)
+	}
+
+	quota := `{"userLimit":1,"windowSeconds":300,"blockSeconds":600}`
+	if resp := h.do(t, http.MethodPut, "/api/admin/credential-read-quota", "admin", strings.NewReader(quota)); resp.Status != http.StatusForbidden {
+		t.Fatalf("non-root quota change: want 403, got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodPut, "/api/admin/credential-read-quota", "root", strings.NewReader(`{"userLimit":1}`)); resp.Status != http.StatusBadRequest {
+		t.Fatalf("quota without a window: want 400, got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodPut, "/api/admin/credential-read-quota", "root", strings.NewReader(quota)); resp.Status != http.StatusOK {
+		t.Fatalf("set quota: got %d (%s)", resp.Status, resp.Body)
+	}
+
+	// Each launch reads the credential: the second read trips the quota and
+	// the third is refused.
+	for range 2 {
+		if resp := h.do(t, http.MethodGet, "/api/connections/c-op/x/tester.list", "op", nil); resp.Status != http.StatusOK {
+			t.Fatalf("launch: want 200, got %d (%s)", resp.Status, resp.Body)
+		}
+		h.pluginSessions.CloseConnection("c-op")
+	}
+	resp := h.do(t, http.MethodGet, "/api/connections/c-op/x/tester.list", "op", nil)
+	if resp.Status != http.StatusTooManyRequests || !strings.Contains(string(resp.Body), `"code":"credential_reads_blocked"`) {
+		t.Fatalf("blocked launch: got %d (%s)", resp.Status, resp.Body)
+	}
+	if strings.Contains(string(resp.Body), "secret-value-123") {
+		t.Fatalf("lockout leaked the secret: %s", resp.Body)
+	}
+}
+
+func TestAdminCredentialBindingReport(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+	_ = h.store.Credentials.Create(ctx, &models.Credential{ID: "cred-op", Name: "mine", Kind: "db_password", OwnerID: "op"})
+	_ = h.store.Credentials.Create(ctx, &models.Credential{ID: "cred-admin", Name: "adm", Kind: "db_password", OwnerID: "admin"})
+	_ = h.store.Credentials.Create(ctx, &models.Credential{ID: "cred-ssh", Name: "ssh", Kind: "ssh_password", OwnerID: "op"})
+	for id, cred := range map[string]string{"b-ok": "cred-op", "b-lost": "cred-admin", "b-gone": "cred-deleted", "b-scope": "cred-ssh"} {
+		_ = h.store.Connections.Create(ctx, &models.Connection{
+			ID: id, Name: id, Protocol: "tester", Transport: "direct", OwnerID: "op",
+			Config: map[string]any{"host": "h", "credential_id": cred},
+		})
+	}
+
+	resp := h.do(t, http.MethodGet, "/api/admin/credential-bindings", "admin", nil)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("report: want 200, got %d (%s)", resp.Status, resp.Body)
+	}
+	var report struct {
+		Items []struct {
+			ConnectionID string `json:"connectionId"`
+			Status       string `json:"status"`
+		} `json:"items"`
+		Total  int            `json:"total"`
+		Counts map[string]int `json:"counts"`
+	}
+	_ = json.Unmarshal(resp.Body, &report)
+	want := map[string]int{"ok": 1, "owner_lost_access": 1, "credential_deleted": 1, "scope_mismatch": 1}
+	if report.Total != 4 || len(report.Counts) != len(want) {
+		t.Fatalf("report = %+v", report)
+	}
+	for status, n := range want {
+		if report.Counts[status] != n {
+			t.Fatalf("counts = %v, want %v", report.Counts, want)
+		}
+	}
+
+	resp = h.do(t, http.MethodGet, "/api/admin/credential-bindings?status=owner_lost_access", "admin", nil)
+	_ = json.Unmarshal(resp.Body, &report)
+	if report.Total != 1 || len(report.Items) != 1 || report.Items[0].ConnectionID != "b-lost" {
+		t.Fatalf("filtered report = %+v", report)
+	}
+	resp = h.do(t, http.MethodGet, "/api/admin/credential-bindings?limit=2&offset=3", "admin", nil)
+	_ = json.Unmarshal(resp.Body, &report)
+	if report.Total != 4 || len(report.Items) != 1 || report.Items[0].ConnectionID != "b-scope" {
+		t.Fatalf("paged report = %+v", report)
+	}
+
+	if resp := h.do(t, http.MethodGet, "/api/admin/credential-bindings?status=bogus", "admin", nil); resp.Status != http.StatusBadRequest {
+		t.Fatalf("unknown status: want 400, got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/admin/credential-bindings", "op", nil); resp.Status != http.StatusForbidden {
+		t.Fatalf("non-admin: want 403, got %d", resp.Status)
+	}
+}
+
+func TestCredentialApprovalGatesLaunch(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+	id := createCredID(t, h, "op2",
+		`{"name":"break glass","kind":"db_password","requiresApproval":true,"values":{"username":"sa","password":"secret-value-123"}}`)
+	_ = h.store.CredentialGrants.Create(ctx, &models.CredentialGrant{ID: "cg-op", CredentialID: id, SubjectID: "op", Access: models.AccessView})
+	conn, _ := h.store.Connections.Get(ctx, "c-op")
+	conn.Config = map[string]any{"host": "db", "credential_id": id}
+	if err := h.store.Connections.Update(ctx, &conn); err != nil {
+		t.Fatalf("update connection: %v", err)
+	}
+
+	resp := h.do(t, http.MethodGet, "/api/connections/c-op/x/tester.list", "op", nil)
+	if resp.Status != http.StatusForbidden || !strings.Contains(string(resp.Body), `"code":"credential_approval_required"`) {
+		t.Fatalf("unapproved launch: got %d (%s)", resp.Status, resp.Body)
+	}
+	var pending []models.CredentialApproval
+	_ = json.Unmarshal(h.do(t, http.MethodGet, "/api/credential-approvals", "op", nil).Body, &pending)
+	if len(pending) != 1 || pending[0].Status != models.ApprovalPending || pending[0].ConnectionID != "c-op" {
+		t.Fatalf("requester's approvals = %+v", pending)
+	}
+	approve := "/api/credential-approvals/" + pending[0].ID + "/approve"
+	if resp := h.do(t, http.MethodPost, approve, "op", nil); resp.Status != http.StatusForbidden {
+		t.Fatalf("self-approval: want 403, got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodPost, approve, "admin", nil); resp.Status != http.StatusForbidden {
+		t.Fatalf("admin who cannot edit the credential: want 403, got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodPost, approve, "op2", nil); resp.Status != http.StatusOK {
+		t.Fatalf("owner approval: got %d (%s)", resp.Status, resp.Body)
+	}
+
+	if resp := h.do(t, http.MethodGet, "/api/connections/c-op/x/tester.list", "op", nil); resp.Status != http.StatusOK {
+		t.Fatalf("approved launch: got %d (%s)", resp.Status, resp.Body)
+	}
+	h.pluginSessions.CloseConnection("c-op")
+	if resp := h.do(t, http.MethodGet, "/api/connections/c-op/x/tester.list", "op", nil); resp.Status != http.StatusForbidden {
+		t.Fatalf("a spent approval must not launch again: got %d", resp.Status)
+	}
+
+	if resp := h.do(t, http.MethodPut, "/api/admin/approval-exempt-root", "admin", strings.NewReader(`{"exemptRoot":true}`)); resp.Status != http.StatusForbidden {
+		t.Fatalf("only root may exempt root: got %d", resp.Status)
+	}
+
+	rows, _ := h.store.Audit.List(ctx, store.AuditFilter{})
+	var denied, allowed bool
+	for _, r := range rows {
+		if r.Event == "credential.approval.approve" {
+			denied = denied || r.Result == models.AuditDenied
+			allowed = allowed || r.Result == models.AuditAllowed
+		}
+	}
+	if !denied || !allowed {
+		t.Fatal("expected denied and allowed credential.approval.approve audit rows")
+	}
+}
+
+func TestCredentialOwnershipTransfer(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+	_ = h.store.Users.Create(ctx, &models.User{ID: "root", Username: "root", Roles: []models.Role{models.RoleAdmin}, Protected: true}, "")
+	h.sessions["root"] = h.sessionMgr.Create("root")
+
+	id := createCredID(t, h, "op", `{"name":"db pw","kind":"db_password","values":{"username":"app","password":"pw-1"}}`)
+	second := createCredID(t, h, "op", `{"name":"db pw 2","kind":"db_password","values":{"username":"app","password":"pw-2"}}`)
+	if resp := h.do(t, http.MethodPost, "/api/credentials/"+id+"/grants", "op", strings.NewReader(`{"subjectId":"viewer","access":"view"}`)); resp.Status != http.StatusCreated {
+		t.Fatalf("share: %d %s", resp.Status, resp.Body)
+	}
+
+	transfer := func(user, credID, to string) apiResp {
+		return h.do(t, http.MethodPost, "/api/credentials/"+credID+"/transfer-ownership", user, strings.NewReader(`{"newOwnerId":"`+to+`"}`))
+	}
+	if resp := transfer("admin", id, "op2"); resp.Status != http.StatusForbidden {
+		t.Fatalf("non-root admin transfer = %d, want 403", resp.Status)
+	}
+	if resp := transfer("op", id, "ghost"); resp.Status != http.StatusBadRequest {
+		t.Fatalf("transfer to unknown user = %d, want 400", resp.Status)
+	}
+	if resp := transfer("op", id, "op2"); resp.Status != http.StatusOK {
+		t.Fatalf("owner transfer = %d %s", resp.Status, resp.Body)
+	}
+	if resp := transfer("op", id, "op"); resp.Status != http.StatusForbidden {
+		t.Fatalf("former owner transfer = %d, want 403", resp.Status)
+	}
+	if resp := transfer("root", id, "op"); resp.Status != http.StatusOK {
+		t.Fatalf("root transfer = %d %s", resp.Status, resp.Body)
+	}
+	if g, err := h.store.CredentialGrants.ListByCredential(ctx, id); err != nil || len(g) != 1 {
+		t.Fatalf("grants after transfer = %+v, %v; want kept", g, err)
+	}
+
+	resp := h.do(t, http.MethodDelete, "/api/admin/users/op", "admin", nil)
+	var env struct {
+		Code             string `json:"code"`
+		OwnedCredentials int    `json:"ownedCredentials"`
+	}
+	_ = json.Unmarshal(resp.Body, &env)
+	if resp.Status != http.StatusConflict || env.Code != "owned_credentials" || env.OwnedCredentials != 2 {
+		t.Fatalf("delete owner = %d %s", resp.Status, resp.Body)
+	}
+
+	if resp := h.do(t, http.MethodPost, "/api/admin/credentials/transfer-ownership", "op", strings.NewReader(`{"fromUserId":"op","toUserId":"op2"}`)); resp.Status != http.StatusForbidden {
+		t.Fatalf("non-admin bulk transfer = %d, want 403", resp.Status)
+	}
+	resp = h.do(t, http.MethodPost, "/api/admin/credentials/transfer-ownership", "admin", strings.NewReader(`{"fromUserId":"op","toUserId":"op2"}`))
+	if resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `"transferred":2`) {
+		t.Fatalf("bulk transfer = %d %s", resp.Status, resp.Body)
+	}
+	if cred, _ := h.store.Credentials.Get(ctx, second); cred.OwnerID != "op2" {
+		t.Fatalf("second credential owner = %q, want op2", cred.OwnerID)
+	}
+
+	if resp := h.do(t, http.MethodDelete, "/api/admin/users/op2?transferTo=op2", "admin", nil); resp.Status != http.StatusBadRequest {
+		t.Fatalf("transfer to self = %d, want 400", resp.Status)
+	}
+	if resp := h.do(t, http.MethodDelete, "/api/admin/users/op2?transferTo=viewer", "admin", nil); resp.Status != http.StatusOK {
+		t.Fatalf("delete with transfer = %d %s", resp.Status, resp.Body)
+	}
+	if list, _ := h.store.Credentials.ListByOwner(ctx, "viewer"); len(list) != 2 {
+		t.Fatalf("viewer owns %d credentials, want 2", len(list))
+	}
+	entries, _ := h.store.Audit.List(ctx, store.AuditFilter{UserID: "admin"})
+	var deleted bool
+	for _, e := range entries {
+		deleted = deleted || (e.Event == "user.delete" && e.Result == models.AuditAllowed && e.Params["transferTo"] == "viewer")
+	}
+	if !deleted {
+		t.Fatalf("user delete not audited")
+	}
+}
+
+func TestCredentialFavoritesInPicker(t *testing.T) {
+	h := newHarness(t)
+	first := createCredID(t, h, "op", `{"name":"a pw","kind":"db_password","values":{"username":"app","password":"pw-1"}}`)
+	second := createCredID(t, h, "op", `{"name":"b pw","kind":"db_password","values":{"username":"app","password":"pw-2"}}`)
+
+	if resp := h.do(t, http.MethodPut, "/api/credentials/"+second+"/favorite", "op", nil); resp.Status != http.StatusNoContent {
+		t.Fatalf("pin: %d %s", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodPut, "/api/credentials/"+second+"/favorite", "viewer", nil); resp.Status != http.StatusForbidden {
+		t.Fatalf("pin without access = %d, want 403", resp.Status)
+	}
+	if err := h.store.CredentialUsage.Touch(context.Background(), "op", first, time.Now()); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+
+	var list []models.CredentialSummary
+	resp := h.do(t, http.MethodGet, "/api/credentials?sort=recent", "op", nil)
+	if err := json.Unmarshal(resp.Body, &list); err != nil || resp.Status != http.StatusOK {
+		t.Fatalf("list: %d %s", resp.Status, resp.Body)
+	}
+	if len(list) != 2 || list[0].ID != first || list[0].LastUsedByMeAt == nil || !list[1].IsFavorite || list[0].IsFavorite {
+		t.Fatalf("recent picker: %+v", list)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/credentials?sort=popular", "op", nil); resp.Status != http.StatusBadRequest {
+		t.Fatalf("unknown sort = %d, want 400", resp.Status)
+	}
+
+	if resp := h.do(t, http.MethodDelete, "/api/credentials/"+second+"/favorite", "op", nil); resp.Status != http.StatusNoContent {
+		t.Fatalf("unpin: %d %s", resp.Status, resp.Body)
+	}
+	resp = h.do(t, http.MethodGet, "/api/credentials", "op", nil)
+	if strings.Contains(string(resp.Body), `"isFavorite"`) {
+		t.Fatalf("unpinned credential still favorite: %s", resp.Body)
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// csvColumn is one column of a CSV export: its header and how a row fills it.
+type csvColumn[T any] struct {
+	name string
+	cell func(T) string
+}
+
+// wantsCSV reports whether a list request asked for CSV, with ?format=csv or
+// an Accept header naming text/csv.
+func wantsCSV(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "csv" {
+		return true
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mt, _, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil && mt == "text/csv" {
+			return true
+		}
+	}
+	return false
+}
+
+// selectCSVColumns narrows all to the comma-separated ?columns names. The
+// export keeps all's order whatever order they are asked in, so a saved
+// report lines up month after month.
+func selectCSVColumns[T any](all []csvColumn[T], param string) ([]csvColumn[T], error) {
+	if strings.TrimSpace(param) == "" {
+		return all, nil
+	}
+	want := map[string]bool{}
+	for _, name := range strings.Split(param, ",") {
+		name = strings.TrimSpace(name)
+		if !slices.ContainsFunc(all, func(c csvColumn[T]) bool { return c.name == name }) {
+			return nil, fmt.Errorf("%w: unknown column %q", plugin.ErrInvalidInput, name)
+		}
+		want[name] = true
+	}
+	var out []csvColumn[T]
+	for _, c := range all {
+		if want[c.name] {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// csvExport writes rows straight to the response as they are produced.
+type csvExport[T any] struct {
+	cw   *csv.Writer
+	cols []csvColumn[T]
+}
+
+func newCSVExport[T any](w http.ResponseWriter, filename string, cols []csvColumn[T]) *csvExport[T] {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	e := &csvExport[T]{cw: csv.NewWriter(w), cols: cols}
+	header := make([]string, len(cols))
+	for i, c := range cols {
+		header[i] = c.name
+	}
+	_ = e.cw.Write(header)
+	return e
+}
+
+func (e *csvExport[T]) row(v T) error {
+	rec := make([]string, len(e.cols))
+	for i, c := range e.cols {
+		rec[i] = c.cell(v)
+	}
+	return e.cw.Write(rec)
+}
+
+func (e *csvExport[T]) flush() error {
+	e.cw.Flush()
+	return e.cw.Error()
+}
+
+// exportLocation is the zone an export writes timestamps in: ?tz when given,
+// else the caller's timezone preference, else UTC.
+func (s *Server) exportLocation(r *http.Request, user models.User) (*time.Location, error) {
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		return service.LoadTimeZone(tz)
+	}
+	if s.deps.Preferences == nil {
+		return time.UTC, nil
+	}
+	return s.deps.Preferences.TimeZone(r.Context(), user.ID)
+}
+
+// csvTime writes t as RFC 3339 in loc. Outside UTC the zone abbreviation
+// follows, since an offset alone does not tell a reader which zone it is.
+func csvTime(t time.Time, loc *time.Location) string {
+	if t.IsZero() {
+		return ""
+	}
+	if loc == nil || loc == time.UTC {
+		return t.UTC().Format(time.RFC3339)
+	}
+	return t.In(loc).Format(time.RFC3339 + " MST")
+}
+
+func csvSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCSVTimeAcrossDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no tz database: %v", err)
+	}
+	cases := []struct {
+		utc  string
+		loc  *time.Location
+		want string
+	}{
+		{"2026-03-08T06:59:59Z", ny, "2026-03-08T01:59:59-05:00 EST"},
+		// Clocks jump from 02:00 to 03:00.
+		{"2026-03-08T07:00:00Z", ny, "2026-03-08T03:00:00-04:00 EDT"},
+		// 01:30 happens twice when clocks fall back; the zone tells them apart.
+		{"2026-11-01T05:30:00Z", ny, "2026-11-01T01:30:00-04:00 EDT"},
+		{"2026-11-01T06:30:00Z", ny, "2026-11-01T01:30:00-05:00 EST"},
+		{"2026-11-01T06:30:00Z", time.UTC, "2026-11-01T06:30:00Z"},
+		{"2026-11-01T06:30:00Z", nil, "2026-11-01T06:30:00Z"},
+	}
+	for _, tc := range cases {
+		at, _ := time.Parse(time.RFC3339, tc.utc)
+		if got := csvTime(at, tc.loc); got != tc.want {
+			t.Errorf("csvTime(%s, %v) = %q, want %q", tc.utc, tc.loc, got, tc.want)
+		}
+	}
+	if got := csvTime(time.Time{}, ny); got != "" {
+		t.Errorf("zero time = %q", got)
+	}
+}
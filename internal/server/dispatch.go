@@ -26,6 +26,7 @@ import (
 	"github.com/charlesng35/shellcn/internal/models"
 	"github.com/charlesng35/shellcn/internal/policy"
 	"github.com/charlesng35/shellcn/internal/recording"
+	"github.com/charlesng35/shellcn/internal/service"
 	"github.com/charlesng35/shellcn/internal/session"
 	"github.com/charlesng35/shellcn/internal/transport"
 	"github.com/charlesng35/shellcn/sdk/plugin"
@@ -89,6 +90,9 @@ func (s *Server) resolveRoute(ctx context.Context, user models.User, connID, rou
 	if err := s.authorize(ctx, user, conn, route); err != nil {
 		return res, err
 	}
+	if err := checkConnectionFeatures(conn, route); err != nil {
+		return res, err
+	}
 	return res, nil
 }
 
@@ -215,15 +219,71 @@ func (s *Server) acquireSession(ctx context.Context, res resolved) (*session.Han
 		return nil, err
 	}
 	key := session.Key{ConnectionID: res.conn.ID, ActorScope: res.user.ID}
-	return s.deps.Sessions.Acquire(ctx, key, res.user.ID, func(ctx context.Context) (plugin.Session, error) {
+	return s.deps.Sessions.Acquire(ctx, key, res.user.ID, s.connectSession(res))
+}
+
+// launchSession is acquireSession for a launch that connects in the
+// background; it returns the session's snapshot at once.
+func (s *Server) launchSession(ctx context.Context, res resolved) (session.Snapshot, error) {
+	if err := s.checkProtocolAvailable(ctx, res.user, res.conn.Protocol); err != nil {
+		return session.Snapshot{}, err
+	}
+	key := session.Key{ConnectionID: res.conn.ID, ActorScope: res.user.ID}
+	return s.deps.Sessions.Start(ctx, key, res.user.ID, s.connectSession(res))
+}
+
+func (s *Server) connectSession(res resolved) session.ConnectFunc {
+	return func(ctx context.Context) (plugin.Session, error) {
+		plugin.ReportStage(ctx, plugin.StageResolving)
 		cfg, plg, err := s.deps.Connector.Build(ctx, res.user, res.conn)
 		if err != nil {
 			return nil, err
 		}
-		cfg.ActorScope = key.ActorScope
+		session.SetMetadata(ctx, service.MetadataConnectionSnapshot, s.deps.Connector.Snapshot(res.conn, cfg))
+		cfg.ActorScope = res.user.ID
 		cfg.Storage = s.pluginStorage(res)
-		return plg.Connect(ctx, cfg)
-	})
+		plugin.ReportStage(ctx, plugin.StageConnecting)
+		sess, err := plg.Connect(ctx, cfg)
+		if err != nil {
+			return nil, s.launchFailed(ctx, res, err)
+		}
+		s.auditEvent(ctx, resolved{user: res.user, conn: res.conn, route: sessionOpenRoute}, models.AuditAllowed, nil)
+		if err := s.deps.Store.Connections.MarkUsed(ctx, res.conn.ID, time.Now()); err != nil {
+			s.deps.Logger.Warn("mark connection used", "connection", res.conn.ID, "err", err)
+		}
+		return sess, nil
+	}
+}
+
+// launchFailed classifies a plugin's Connect error, keeps the category on the
+// failed session and in the audit trail, and counts it.
+func (s *Server) launchFailed(ctx context.Context, res resolved, err error) error {
+	err = service.ClassifyLaunchError(err)
+	category := "unknown"
+	var launchErr *service.LaunchError
+	if errors.As(err, &launchErr) {
+		category = string(launchErr.Category)
+		session.SetMetadata(ctx, service.MetadataLaunchFailure, category)
+	}
+	s.auditEventParams(ctx, resolved{user: res.user, conn: res.conn, route: sessionOpenRoute}, models.AuditError,
+		map[string]string{"failure": category}, err)
+	if s.deps.Metrics != nil {
+		s.deps.Metrics.IncLaunchFailure(res.conn.Protocol, category)
+	}
+	return err
+}
+
+// connectionSnapshot returns the settings the user's live session on conn
+// was opened with, or nil when there is none.
+func (s *Server) connectionSnapshot(userID, connID string) *models.ConnectionSnapshot {
+	snap, ok := s.deps.Sessions.Status(session.Key{ConnectionID: connID, ActorScope: userID})
+	if !ok {
+		return nil
+	}
+	if cs, ok := snap.Metadata[service.MetadataConnectionSnapshot].(models.ConnectionSnapshot); ok {
+		return &cs
+	}
+	return nil
 }
 
 func (s *Server) auditEvent(ctx context.Context, res resolved, result models.AuditResult, err error) {
@@ -649,6 +709,7 @@ func (s *Server) serveStream(w http.ResponseWriter, r *http.Request, res resolve
 	}
 	releaseStream := handle.TrackStream()
 	defer releaseStream()
+	pending.SetConnectionSnapshot(s.connectionSnapshot(res.user.ID, res.conn.ID))
 	s.auditEvent(ctx, res, models.AuditAllowed, nil)
 
 	streamCtx, cancel := context.WithCancel(ctx)
@@ -671,7 +732,12 @@ func (s *Server) serveStream(w http.ResponseWriter, r *http.Request, res resolve
 			}
 		}()
 	}
-	client := pending.Attach(&wsClientStream{Conn: conn, ctx: streamCtx})
+	// The recording tap sees exactly the metered bytes.
+	client := handle.MeterStream(&wsClientStream{Conn: conn, ctx: streamCtx})
+	if s.capturesScrollback(res, pending) {
+		client = handle.CaptureScrollback(client)
+	}
+	client = pending.Attach(client)
 
 	rc := plugin.NewRequestContext(streamCtx, toPluginUser(res.user), handle, res.params, r.URL.Query(), nil).
 		WithAuditHook(func(ctx context.Context, result plugin.AuditResult, params map[string]string, err error) {
@@ -685,6 +751,21 @@ func (s *Server) serveStream(w http.ResponseWriter, r *http.Request, res resolve
 	_ = c.Close(websocket.StatusNormalClosure, "")
 }
 
+// capturesScrollback reports whether a stream's output belongs in the
+// session's searchable scrollback: terminal streams only, and not ones kept
+// out of recordings when ScrollbackSkipUnrecorded is set.
+func (s *Server) capturesScrollback(res resolved, pending *recording.Pending) bool {
+	if s.deps.ScrollbackSkipUnrecorded && !pending.Recording() {
+		return false
+	}
+	m, ok := s.deps.Plugins.Manifest(res.conn.Protocol)
+	if !ok {
+		return false
+	}
+	stream, ok := m.StreamByRoute(res.route.ID)
+	return ok && stream.Kind == plugin.StreamTerminal
+}
+
 type streamKeepAlivePolicy struct {
 	enabled       bool
 	controlReader bool
@@ -762,7 +843,7 @@ func (s *Server) prepareRecording(ctx context.Context, r *http.Request, res reso
 	stream, _ := manifest.StreamByRoute(res.route.ID)
 	return s.deps.Recording.Prepare(ctx, recording.StreamInfo{
 		User: res.user, Connection: res.conn, Manifest: manifest, Route: res.route,
-		StreamID: stream.ID, Params: res.params, RemoteAddr: r.RemoteAddr,
+		StreamID: stream.ID, Params: res.params, RemoteAddr: clientIP(r), Client: audit.ClientFrom(r.Context()),
 	})
 }
 
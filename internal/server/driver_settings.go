@@ -0,0 +1,51 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const driverSettingsUpdateEvent = "driver.settings.update"
+
+func (s *Server) handleGetDriverSettings(w http.ResponseWriter, r *http.Request) {
+	view, err := s.deps.DriverSettings.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, view)
+}
+
+// handleUpdateDriverSettings replaces a driver's settings with the body's
+// {key: value} map. Only the keys are audited.
+func (s *Server) handleUpdateDriverSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actor, _ := userFrom(ctx)
+	driver := chi.URLParam(r, "id")
+	var req map[string]any
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSettingsBody)).Decode(&req); err != nil || req == nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	keys := make([]string, 0, len(req))
+	for k := range req {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	params := map[string]string{"driver": driver, "keys": strings.Join(keys, ",")}
+	view, err := s.deps.DriverSettings.Update(ctx, actor, driver, req)
+	if err != nil {
+		s.auditAdminEvent(ctx, actor, driverSettingsUpdateEvent, models.AuditError, params, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditAdminEvent(ctx, actor, driverSettingsUpdateEvent, models.AuditAllowed, params, nil)
+	writeJSON(w, http.StatusOK, view)
+}
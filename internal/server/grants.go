@@ -3,9 +3,12 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -27,6 +30,10 @@ type grantRequest struct {
 	SubjectID string `json:"subjectId"`
 	Email     string `json:"email"`
 	Access    string `json:"access"`
+	// ExpiresAt makes a connection share temporary; credential shares do not
+	// expire. ExpiresIn ("1h", "7d") is the same as a relative duration.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	ExpiresIn string     `json:"expiresIn,omitempty"`
 }
 
 // resolveGrantSubject maps a grant request to a target user id: a picked id, or
@@ -50,11 +57,23 @@ func (s *Server) resolveGrantSubject(ctx context.Context, req grantRequest) (str
 }
 
 type grantDTO struct {
-	ID          string `json:"id"`
-	SubjectID   string `json:"subjectId"`
-	Username    string `json:"username,omitempty"`
-	DisplayName string `json:"displayName,omitempty"`
-	Access      string `json:"access"`
+	ID               string     `json:"id"`
+	SubjectID        string     `json:"subjectId"`
+	Username         string     `json:"username,omitempty"`
+	DisplayName      string     `json:"displayName,omitempty"`
+	Access           string     `json:"access"`
+	ExpiresAt        *time.Time `json:"expiresAt,omitempty"`
+	ExpiresInSeconds int64      `json:"expiresInSeconds,omitempty"`
+}
+
+// expiresInSeconds is the whole seconds left before a share lapses, rounded
+// up so a share that has not lapsed never reads 0; nil when it does not
+// expire.
+func expiresInSeconds(at *time.Time) int64 {
+	if at == nil {
+		return 0
+	}
+	return max(int64(math.Ceil(time.Until(*at).Seconds())), 0)
 }
 
 // isOwner gates sharing (grant create/list/revoke): only the resource owner may
@@ -91,12 +110,20 @@ func (s *Server) handleListConnectionGrants(w http.ResponseWriter, r *http.Reque
 		writeError(w, s.deps.Logger, err)
 		return
 	}
+	writeJSON(w, http.StatusOK, s.connectionGrantDTOs(ctx, grants))
+}
+
+// connectionGrantDTOs labels each connection grant with its subject.
+func (s *Server) connectionGrantDTOs(ctx context.Context, grants []models.Grant) []grantDTO {
 	out := make([]grantDTO, 0, len(grants))
 	for _, g := range grants {
 		username, display := s.subjectLabel(ctx, g.SubjectID)
-		out = append(out, grantDTO{ID: g.ID, SubjectID: g.SubjectID, Username: username, DisplayName: display, Access: string(g.Access)})
+		out = append(out, grantDTO{
+			ID: g.ID, SubjectID: g.SubjectID, Username: username, DisplayName: display, Access: string(g.Access),
+			ExpiresAt: g.ExpiresAt, ExpiresInSeconds: expiresInSeconds(g.ExpiresAt),
+		})
 	}
-	writeJSON(w, http.StatusOK, out)
+	return out
 }
 
 func (s *Server) handleCreateConnectionGrant(w http.ResponseWriter, r *http.Request) {
@@ -121,15 +148,31 @@ func (s *Server) handleCreateConnectionGrant(w http.ResponseWriter, r *http.Requ
 		writeError(w, s.deps.Logger, err)
 		return
 	}
-	g := models.Grant{ID: uuid.NewString(), ConnectionID: conn.ID, SubjectID: subjectID, Access: access}
+	if req.ExpiresAt != nil || req.ExpiresIn != "" {
+		if s.deps.GrantExpiry == nil || (req.ExpiresAt != nil && req.ExpiresIn != "") {
+			writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+			return
+		}
+		if req.ExpiresIn != "" {
+			at, err := s.deps.GrantExpiry.ExpiresIn(ctx, req.ExpiresIn)
+			if err != nil {
+				writeError(w, s.deps.Logger, err)
+				return
+			}
+			req.ExpiresAt = &at
+		} else if err := s.deps.GrantExpiry.Validate(ctx, *req.ExpiresAt); err != nil {
+			writeError(w, s.deps.Logger, err)
+			return
+		}
+	}
+	g := models.Grant{ID: uuid.NewString(), ConnectionID: conn.ID, SubjectID: subjectID, Access: access, ExpiresAt: req.ExpiresAt}
 	if err := s.deps.Store.Grants.Create(ctx, &g); err != nil {
 		s.auditConnEvent(ctx, user, conn.ID, connGrantCreateEvent, plugin.RiskWrite, models.AuditError, err)
 		writeError(w, s.deps.Logger, err)
 		return
 	}
 	s.auditConnEvent(ctx, user, conn.ID, connGrantCreateEvent, plugin.RiskWrite, models.AuditAllowed, nil)
-	username, display := s.subjectLabel(ctx, g.SubjectID)
-	writeJSON(w, http.StatusCreated, grantDTO{ID: g.ID, SubjectID: g.SubjectID, Username: username, DisplayName: display, Access: string(g.Access)})
+	writeJSON(w, http.StatusCreated, s.connectionGrantDTOs(ctx, []models.Grant{g})[0])
 }
 
 func (s *Server) handleDeleteConnectionGrant(w http.ResponseWriter, r *http.Request) {
@@ -203,6 +246,10 @@ func (s *Server) handleCreateCredentialGrant(w http.ResponseWriter, r *http.Requ
 	if !ok {
 		return
 	}
+	if req.ExpiresAt != nil || req.ExpiresIn != "" {
+		writeError(w, s.deps.Logger, fmt.Errorf("%w: credential shares do not expire", plugin.ErrInvalidInput))
+		return
+	}
 	subjectID, err := s.resolveGrantSubject(ctx, req)
 	if err != nil {
 		writeError(w, s.deps.Logger, err)
@@ -2,11 +2,15 @@ package server_test
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
 )
 
 func TestConnectionGrantTiers(t *testing.T) {
@@ -196,3 +200,126 @@ func TestShareByEmail(t *testing.T) {
 		t.Errorf("share to unknown email: want 404, got %d", resp.Status)
 	}
 }
+
+func TestShareExpiryReportAndExtend(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+	share := func(expires time.Time) apiResp {
+		body := fmt.Sprintf(`{"subjectId":"viewer","access":"view","expiresAt":%q}`, expires.Format(time.RFC3339))
+		return h.do(t, http.MethodPost, "/api/connections/c-op/grants", "op", strings.NewReader(body))
+	}
+	if resp := share(time.Now().Add(-time.Hour)); resp.Status != http.StatusBadRequest {
+		t.Fatalf("past expiry = %d, want 400", resp.Status)
+	}
+	if resp := share(time.Now().AddDate(2, 0, 0)); resp.Status != http.StatusBadRequest {
+		t.Fatalf("expiry beyond max lifetime = %d, want 400", resp.Status)
+	}
+	resp := share(time.Now().Add(48 * time.Hour))
+	var created struct {
+		ID        string     `json:"id"`
+		ExpiresAt *time.Time `json:"expiresAt"`
+	}
+	if err := json.Unmarshal(resp.Body, &created); err != nil || resp.Status != http.StatusCreated || created.ExpiresAt == nil {
+		t.Fatalf("temporary share: %d %s", resp.Status, resp.Body)
+	}
+
+	expiring := func(user, within string) []map[string]any {
+		t.Helper()
+		resp := h.do(t, http.MethodGet, "/api/connections/shares/expiring?within="+within, user, nil)
+		var out []map[string]any
+		if err := json.Unmarshal(resp.Body, &out); err != nil || resp.Status != http.StatusOK {
+			t.Fatalf("expiring as %s: %d %s", user, resp.Status, resp.Body)
+		}
+		return out
+	}
+	if got := expiring("op", "7d"); len(got) != 1 || got[0]["connectionId"] != "c-op" || len(got[0]["shares"].([]any)) != 1 {
+		t.Fatalf("expiring within 7d: %+v", got)
+	}
+	if got := expiring("op", "1d"); len(got) != 0 {
+		t.Fatalf("expiring within 1d: %+v", got)
+	}
+	if got := expiring("viewer", "7d"); len(got) != 0 {
+		t.Fatalf("grantee sees no shares to manage: %+v", got)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/connections/shares/expiring?within=week", "op", nil); resp.Status != http.StatusBadRequest {
+		t.Fatalf("bad window = %d, want 400", resp.Status)
+	}
+
+	extend := func(user string, expires time.Time) apiResp {
+		body := fmt.Sprintf(`{"grantIds":[%q],"expiresAt":%q}`, created.ID, expires.Format(time.RFC3339))
+		return h.do(t, http.MethodPost, "/api/connections/shares/extend", user, strings.NewReader(body))
+	}
+	if resp := extend("op2", time.Now().AddDate(0, 0, 30)); resp.Status != http.StatusForbidden {
+		t.Fatalf("extend someone else's share = %d, want 403", resp.Status)
+	}
+	if resp := extend("op", time.Now().Add(-time.Minute)); resp.Status != http.StatusBadRequest {
+		t.Fatalf("extend into the past = %d, want 400", resp.Status)
+	}
+	if resp := extend("op", time.Now().AddDate(0, 0, 30)); resp.Status != http.StatusOK {
+		t.Fatalf("extend: %d %s", resp.Status, resp.Body)
+	}
+	if got := expiring("op", "7d"); len(got) != 0 {
+		t.Fatalf("extended share still expiring: %+v", got)
+	}
+	rows, _ := h.store.Audit.List(ctx, store.AuditFilter{ConnectionID: "c-op"})
+	extended := 0
+	for _, r := range rows {
+		if r.Event == "connection.grant.extend" && r.Result == models.AuditAllowed {
+			extended++
+		}
+	}
+	if extended != 1 {
+		t.Fatalf("want one extend audit row for c-op, got %d", extended)
+	}
+
+	// A lapsed share stops conferring access.
+	past := time.Now().Add(-time.Minute)
+	if err := h.store.Grants.SetExpiry(ctx, []string{created.ID}, &past); err != nil {
+		t.Fatalf("lapse: %v", err)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/connections/c-op/stats", "viewer", nil); resp.Status != http.StatusNotFound {
+		t.Fatalf("lapsed grantee = %d, want 404", resp.Status)
+	}
+}
+
+func TestShareExpiresInShortcut(t *testing.T) {
+	h := newHarness(t)
+	share := func(body string) apiResp {
+		return h.do(t, http.MethodPost, "/api/connections/c-op/grants", "op", strings.NewReader(body))
+	}
+	if resp := share(`{"subjectId":"viewer","access":"view","expiresIn":"1w"}`); resp.Status != http.StatusBadRequest {
+		t.Fatalf("unknown unit = %d, want 400", resp.Status)
+	}
+	both := fmt.Sprintf(`{"subjectId":"viewer","access":"view","expiresIn":"1d","expiresAt":%q}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+	if resp := share(both); resp.Status != http.StatusBadRequest {
+		t.Fatalf("expiresIn with expiresAt = %d, want 400", resp.Status)
+	}
+	resp := share(`{"subjectId":"viewer","access":"view","expiresIn":"1d"}`)
+	var created struct {
+		ExpiresAt        *time.Time `json:"expiresAt"`
+		ExpiresInSeconds int64      `json:"expiresInSeconds"`
+	}
+	if err := json.Unmarshal(resp.Body, &created); err != nil || resp.Status != http.StatusCreated || created.ExpiresAt == nil {
+		t.Fatalf("share for 1d: %d %s", resp.Status, resp.Body)
+	}
+	if d := time.Until(*created.ExpiresAt); d > 24*time.Hour || d < 23*time.Hour {
+		t.Fatalf("expires in %v, want about a day", d)
+	}
+	if s := created.ExpiresInSeconds; s > 86400 || s < 86400-60 {
+		t.Fatalf("expiresInSeconds = %d", s)
+	}
+
+	resp = h.do(t, http.MethodGet, "/api/connections", "viewer", nil)
+	var conns []struct {
+		ID                    string `json:"id"`
+		ShareExpiresInSeconds int64  `json:"shareExpiresInSeconds"`
+	}
+	if err := json.Unmarshal(resp.Body, &conns); err != nil {
+		t.Fatalf("list: %d %s", resp.Status, resp.Body)
+	}
+	for _, c := range conns {
+		if c.ID == "c-op" && (c.ShareExpiresInSeconds <= 0 || c.ShareExpiresInSeconds > 86400) {
+			t.Fatalf("grantee countdown = %d", c.ShareExpiresInSeconds)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotency-Replayed"
+
+	maxIdempotencyKey = 255
+	maxIdempotentBody = 1 << 20
+
+	idempotencyKeyReusedCode = "idempotency_key_reused"
+)
+
+// idempotent lets a client retry next safely: a request carrying an
+// Idempotency-Key replays the response its first attempt produced instead of
+// running again. Concurrent requests with the same key wait for the first.
+// Reusing a key for a different request is a 422. Server errors are not
+// remembered, so they can be retried.
+func (s *Server) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" || s.deps.Idempotency == nil {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			writeError(w, s.deps.Logger, fmt.Errorf("%w: %s must be at most %d characters", plugin.ErrInvalidInput, idempotencyKeyHeader, maxIdempotencyKey))
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBody+1))
+		if err != nil || len(body) > maxIdempotentBody {
+			writeError(w, s.deps.Logger, fmt.Errorf("%w: request body is too large to make idempotent", plugin.ErrInvalidInput))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		user, _ := userFrom(r.Context())
+		id := hashParts(user.ID, r.URL.Path, key)
+		reqHash := hashParts(r.Method, r.URL.Path, string(body))
+
+		release := s.deps.Idempotency.Lock(id)
+		defer release()
+		ctx := r.Context()
+		prev, found, err := s.deps.Idempotency.Lookup(ctx, id, time.Now().UTC())
+		if err != nil {
+			writeError(w, s.deps.Logger, err)
+			return
+		}
+		if found {
+			if prev.RequestHash != reqHash {
+				writeJSON(w, http.StatusUnprocessableEntity, errorEnvelope{
+					Error: idempotencyKeyHeader + " was already used for a different request", Code: idempotencyKeyReusedCode,
+				})
+				return
+			}
+			if prev.ContentType != "" {
+				w.Header().Set("Content-Type", prev.ContentType)
+			}
+			w.Header().Set(idempotencyReplayedHeader, "true")
+			w.WriteHeader(prev.Status)
+			_, _ = w.Write(prev.Body)
+			return
+		}
+
+		var out bytes.Buffer
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(&out)
+		next(ww, r)
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if status >= 500 {
+			return
+		}
+		err = s.deps.Idempotency.Remember(ctx, models.IdempotencyKey{
+			ID: id, UserID: user.ID, RequestHash: reqHash, Status: status,
+			ContentType: ww.Header().Get("Content-Type"), Body: out.Bytes(),
+		}, time.Now().UTC())
+		if err != nil {
+			s.deps.Logger.Warn("remember idempotent response", "path", r.URL.Path, "err", err)
+		}
+	}
+}
+
+func hashParts(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,99 @@
+package server_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/charlesng35/shellcn/internal/auth"
+	"github.com/charlesng35/shellcn/internal/server"
+	"github.com/charlesng35/shellcn/internal/service"
+)
+
+func withIdempotency(d *server.Deps) {
+	d.Idempotency = service.NewIdempotencyService(d.Store.IdempotencyKeys, 0)
+}
+
+func createWithKey(h *harness, key, body string) (int, string, http.Header, error) {
+	req, err := http.NewRequest(http.MethodPost, h.ts.URL+"/api/connections", strings.NewReader(body))
+	if err != nil {
+		return 0, "", nil, err
+	}
+	sess := h.sessions["op"]
+	req.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: sess.ID})
+	req.Header.Set(auth.CSRFHeader, sess.CSRFToken)
+	req.Header.Set("Idempotency-Key", key)
+	resp, err := h.ts.Client().Do(req)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	b, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(b), resp.Header, err
+}
+
+func countConnections(t *testing.T, h *harness, name string) int {
+	t.Helper()
+	list, err := h.store.Connections.List(context.Background())
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	n := 0
+	for _, c := range list {
+		if c.Name == name {
+			n++
+		}
+	}
+	return n
+}
+
+func TestIdempotentCreateReplaysFirstResponse(t *testing.T) {
+	h := newHarness(t, withIdempotency)
+	body := `{"name":"retried","protocol":"tester","transport":"direct","config":{"host":"h"}}`
+
+	status, first, hdr, err := createWithKey(h, "key-1", body)
+	if err != nil || status != http.StatusCreated || hdr.Get("Idempotency-Replayed") != "" {
+		t.Fatalf("first create: %d %s %v", status, first, err)
+	}
+	status, again, hdr, err := createWithKey(h, "key-1", body)
+	if err != nil || status != http.StatusCreated || again != first || hdr.Get("Idempotency-Replayed") != "true" {
+		t.Fatalf("retry: %d %s (first %s) %v", status, again, first, err)
+	}
+	if n := countConnections(t, h, "retried"); n != 1 {
+		t.Fatalf("retry created a duplicate: %d connections", n)
+	}
+
+	status, resp, _, _ := createWithKey(h, "key-1", `{"name":"other","protocol":"tester","transport":"direct","config":{"host":"h"}}`)
+	if status != http.StatusUnprocessableEntity || !strings.Contains(resp, "idempotency_key_reused") {
+		t.Fatalf("reused key with another body: %d %s", status, resp)
+	}
+}
+
+func TestIdempotentCreateSerializesConcurrentRetries(t *testing.T) {
+	h := newHarness(t, withIdempotency)
+	body := `{"name":"racing","protocol":"tester","transport":"direct","config":{"host":"h"}}`
+
+	const attempts = 5
+	var wg sync.WaitGroup
+	bodies := make([]string, attempts)
+	statuses := make([]int, attempts)
+	for i := range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i], bodies[i], _, _ = createWithKey(h, "key-race", body)
+		}()
+	}
+	wg.Wait()
+	for i := range attempts {
+		if statuses[i] != http.StatusCreated || bodies[i] != bodies[0] {
+			t.Fatalf("attempt %d: %d %s (first %s)", i, statuses[i], bodies[i], bodies[0])
+		}
+	}
+	if n := countConnections(t, h, "racing"); n != 1 {
+		t.Fatalf("concurrent retries created %d connections", n)
+	}
+}
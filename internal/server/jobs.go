@@ -0,0 +1,172 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/audit"
+	"github.com/charlesng35/shellcn/internal/auth"
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	auditExportEvent = "audit.export"
+	jobCancelEvent   = "job.cancel"
+	jobResultEvent   = "job.result.download"
+
+	// jobResultTicketRoute is the synthetic ticket scope for job result
+	// downloads.
+	jobResultTicketRoute = "job.result"
+)
+
+type jobDTO struct {
+	ID                string     `json:"id"`
+	Type              string     `json:"type"`
+	Status            string     `json:"status"`
+	Progress          int        `json:"progress"`
+	Error             string     `json:"error,omitempty"`
+	ResultSize        int64      `json:"resultSize,omitempty"`
+	DownloadURL       string     `json:"downloadUrl,omitempty"`
+	DownloadExpiresAt *time.Time `json:"downloadExpiresAt,omitempty"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	StartedAt         *time.Time `json:"startedAt,omitempty"`
+	FinishedAt        *time.Time `json:"finishedAt,omitempty"`
+}
+
+// toJobDTO renders a job; a succeeded job gets a fresh single-use download
+// link when result tickets are configured.
+func (s *Server) toJobDTO(j models.Job) jobDTO {
+	d := jobDTO{
+		ID: j.ID, Type: j.Type, Status: string(j.Status), Progress: j.Progress, Error: j.Error,
+		CreatedAt: j.CreatedAt, StartedAt: j.StartedAt, FinishedAt: j.FinishedAt,
+	}
+	if j.Status != models.JobSucceeded {
+		return d
+	}
+	d.ResultSize = j.ResultSize
+	if s.deps.JobTickets != nil {
+		ticket, exp := s.deps.JobTickets.Mint(jobResultScope(j.ID))
+		d.DownloadURL = "/api/jobs/" + url.PathEscape(j.ID) + "/result?" + url.Values{"ticket": {ticket}}.Encode()
+		d.DownloadExpiresAt = &exp
+	}
+	return d
+}
+
+func jobResultScope(id string) auth.TicketScope {
+	return auth.TicketScope{ConnectionID: id, RouteID: jobResultTicketRoute}
+}
+
+type auditExportRequest struct {
+	User         string    `json:"user"`
+	Connection   string    `json:"connection"`
+	ResourceType string    `json:"resourceType"`
+	ResourceID   string    `json:"resourceId"`
+	Since        time.Time `json:"since"`
+	Until        time.Time `json:"until"`
+}
+
+// handleAdminAuditExport queues an NDJSON export of the audit log and returns
+// the job to poll.
+func (s *Server) handleAdminAuditExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	var req auditExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	if req.ResourceID != "" && req.ResourceType == "" {
+		writeError(w, s.deps.Logger, fmt.Errorf("%w: resourceId needs resourceType", plugin.ErrInvalidInput))
+		return
+	}
+	params := service.AuditExportParams(store.AuditFilter{
+		UserID: req.User, ConnectionID: req.Connection, ResourceType: req.ResourceType, ResourceID: req.ResourceID,
+		Since: req.Since, Until: req.Until,
+	})
+	job, err := s.deps.Jobs.Submit(ctx, user.ID, service.JobAuditExport, params)
+	result, auditParams := models.AuditAllowed, map[string]string{}
+	for k, v := range params {
+		auditParams[k] = v
+	}
+	if err != nil {
+		result = models.AuditError
+	} else {
+		auditParams["job"] = job.ID
+	}
+	s.deps.Audit.Record(ctx, audit.Event{
+		User: user, Event: auditExportEvent, RouteID: auditExportEvent,
+		Risk: string(plugin.RiskSafe), Result: result, Params: auditParams, Err: err,
+	})
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, s.toJobDTO(job))
+}
+
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	user, _ := userFrom(r.Context())
+	job, err := s.deps.Jobs.Get(r.Context(), user.ID, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.toJobDTO(job))
+}
+
+func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	id := chi.URLParam(r, "id")
+	job, err := s.deps.Jobs.Cancel(ctx, user.ID, id)
+	result := models.AuditAllowed
+	if err != nil {
+		result = models.AuditError
+	}
+	s.deps.Audit.Record(ctx, audit.Event{
+		User: user, Event: jobCancelEvent, RouteID: jobCancelEvent,
+		Risk: string(plugin.RiskSafe), Result: result, Params: map[string]string{"job": id}, Err: err,
+	})
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, s.toJobDTO(job))
+}
+
+// handleJobResult serves a job's output to the bearer of a single-use signed
+// ticket from the job's downloadUrl.
+func (s *Server) handleJobResult(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+	if err := s.deps.JobTickets.Redeem(r.URL.Query().Get("ticket"), jobResultScope(id)); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrUnauthorized)
+		return
+	}
+	job, rc, err := s.deps.Jobs.OpenResult(ctx, id)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	defer rc.Close()
+	s.deps.Audit.Record(ctx, audit.Event{
+		User: models.User{ID: job.RequestedBy}, Event: jobResultEvent, RouteID: jobResultEvent,
+		Risk: string(plugin.RiskSafe), Result: models.AuditAllowed, Params: map[string]string{"job": id, "type": job.Type},
+	})
+	w.Header().Set("Content-Type", job.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(job.ResultSize, 10))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+job.FileName+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = io.Copy(w, rc)
+}
@@ -0,0 +1,106 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/auth"
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/server"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+type jobResp struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	Progress    int    `json:"progress"`
+	DownloadURL string `json:"downloadUrl"`
+}
+
+func TestAuditExportJob(t *testing.T) {
+	h := newHarness(t, func(d *server.Deps) {
+		jobs := service.NewJobService(d.Store.Jobs, nil)
+		jobs.Register(service.JobAuditExport, service.AuditExportJob(d.Store.Audit))
+		if err := jobs.Start(context.Background()); err != nil {
+			t.Fatalf("start jobs: %v", err)
+		}
+		t.Cleanup(jobs.Close)
+		d.Jobs = jobs
+		d.JobTickets = auth.NewTicketStore(auth.TicketStoreOptions{
+			SigningKey: []byte("0123456789abcdef0123456789abcdef"),
+			Leases:     d.Leases,
+			Instance:   d.Instance,
+		})
+	})
+	ctx := context.Background()
+	for _, id := range []string{"e1", "e2"} {
+		_ = h.store.Audit.Append(ctx, &models.AuditEntry{ID: id, Time: time.Now().Add(-time.Minute), UserID: "op", Event: "x", Result: models.AuditAllowed})
+	}
+
+	if resp := h.do(t, http.MethodPost, "/api/admin/audit/export", "op", strings.NewReader(`{}`)); resp.Status != http.StatusForbidden {
+		t.Fatalf("operator export = %d, want 403", resp.Status)
+	}
+	resp := h.do(t, http.MethodPost, "/api/admin/audit/export", "admin", strings.NewReader(`{"user":"op"}`))
+	if resp.Status != http.StatusAccepted {
+		t.Fatalf("export = %d %s", resp.Status, resp.Body)
+	}
+	var job jobResp
+	_ = json.Unmarshal(resp.Body, &job)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status != string(models.JobSucceeded) {
+		if time.Now().After(deadline) {
+			t.Fatalf("job never finished: %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+		resp = h.do(t, http.MethodGet, "/api/jobs/"+job.ID, "admin", nil)
+		if resp.Status != http.StatusOK {
+			t.Fatalf("poll = %d %s", resp.Status, resp.Body)
+		}
+		_ = json.Unmarshal(resp.Body, &job)
+	}
+	if job.Progress != 100 || job.DownloadURL == "" {
+		t.Fatalf("finished job = %+v", job)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/jobs/"+job.ID, "op", nil); resp.Status != http.StatusNotFound {
+		t.Fatalf("non-owner poll = %d, want 404", resp.Status)
+	}
+
+	get := func(url string) (int, string) {
+		r, err := http.Get(h.ts.URL + url)
+		if err != nil {
+			t.Fatalf("download: %v", err)
+		}
+		defer r.Body.Close()
+		b, _ := io.ReadAll(r.Body)
+		return r.StatusCode, string(b)
+	}
+	status, body := get(job.DownloadURL)
+	if status != http.StatusOK || strings.Count(body, "\n") != 2 || !strings.Contains(body, `"id":"e1"`) {
+		t.Fatalf("download = %d %q", status, body)
+	}
+	if status, _ := get(job.DownloadURL); status != http.StatusUnauthorized {
+		t.Fatalf("reused link = %d, want 401", status)
+	}
+	if status, _ := get("/api/jobs/" + job.ID + "/result"); status != http.StatusUnauthorized {
+		t.Fatalf("unsigned download = %d, want 401", status)
+	}
+
+	if resp := h.do(t, http.MethodPost, "/api/jobs/"+job.ID+"/cancel", "admin", nil); resp.Status != http.StatusConflict {
+		t.Fatalf("cancel finished job = %d, want 409", resp.Status)
+	}
+	entries, _ := h.store.Audit.List(ctx, store.AuditFilter{UserID: "admin"})
+	var exported bool
+	for _, e := range entries {
+		exported = exported || (e.Event == "audit.export" && e.Params["job"] == job.ID)
+	}
+	if !exported {
+		t.Fatalf("audit export not audited")
+	}
+}
@@ -0,0 +1,122 @@
+package server_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/server"
+	"github.com/charlesng35/shellcn/internal/service"
+)
+
+func TestReadOnlyModeRefusesMutations(t *testing.T) {
+	h := newHarness(t)
+	if resp := h.do(t, http.MethodPost, "/api/admin/read-only", "op", strings.NewReader(`{"readOnly":true}`)); resp.Status != http.StatusForbidden {
+		t.Fatalf("non-admin toggle: want 403, got %d", resp.Status)
+	}
+	resp := h.do(t, http.MethodPost, "/api/admin/read-only", "admin", strings.NewReader(`{"readOnly":true}`))
+	if resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `"readOnly":true`) {
+		t.Fatalf("enable: %d (%s)", resp.Status, resp.Body)
+	}
+	if setting, err := h.store.SystemSettings.Get(t.Context(), "system.read_only"); err != nil || setting.Value != "true" {
+		t.Fatalf("flag not persisted: %+v err=%v", setting, err)
+	}
+
+	resp = h.do(t, http.MethodPost, "/api/connections", "op",
+		strings.NewReader(`{"name":"x","protocol":"tester","config":{"host":"h"}}`))
+	if resp.Status != http.StatusServiceUnavailable || !strings.Contains(string(resp.Body), `"code":"read_only_mode"`) {
+		t.Fatalf("mutation in read-only: want 503 read_only_mode, got %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodDelete, "/api/connections/c-op", "op", nil); resp.Status != http.StatusServiceUnavailable {
+		t.Fatalf("delete in read-only: want 503, got %d", resp.Status)
+	}
+	// Reads and the exempt auth endpoints keep working.
+	if resp := h.do(t, http.MethodGet, "/api/connections", "op", nil); resp.Status != http.StatusOK {
+		t.Fatalf("read in read-only: want 200, got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodPost, "/api/auth/logout", "viewer", nil); resp.Status == http.StatusServiceUnavailable {
+		t.Fatal("logout must stay available in read-only mode")
+	}
+
+	if resp := h.do(t, http.MethodPost, "/api/admin/read-only", "admin", strings.NewReader(`{"readOnly":false}`)); resp.Status != http.StatusOK {
+		t.Fatalf("disable: %d (%s)", resp.Status, resp.Body)
+	}
+	resp = h.do(t, http.MethodPost, "/api/connections", "op",
+		strings.NewReader(`{"name":"x","protocol":"tester","config":{"host":"h"}}`))
+	if resp.Status != http.StatusCreated {
+		t.Fatalf("mutation after resume: want 201, got %d (%s)", resp.Status, resp.Body)
+	}
+}
+
+func TestCollationLocaleChangeRewritesSortKeys(t *testing.T) {
+	h := newHarness(t)
+	for _, name := range []string{"Zebra", "Ähre"} {
+		if resp := h.do(t, http.MethodPost, "/api/connections", "op",
+			strings.NewReader(`{"name":"`+name+`","protocol":"tester","config":{"host":"h"}}`)); resp.Status != http.StatusCreated {
+			t.Fatalf("create %s: %d (%s)", name, resp.Status, resp.Body)
+		}
+	}
+	order := func() string {
+		conns, _ := h.store.Connections.ListByOwner(t.Context(), "op")
+		var names []string
+		for _, c := range conns {
+			if c.Name == "Zebra" || c.Name == "Ähre" {
+				names = append(names, c.Name)
+			}
+		}
+		return strings.Join(names, ",")
+	}
+	if got := order(); got != "Ähre,Zebra" {
+		t.Fatalf("root collation order = %s", got)
+	}
+
+	if resp := h.do(t, http.MethodPut, "/api/admin/collation", "op", strings.NewReader(`{"locale":"sv"}`)); resp.Status != http.StatusForbidden {
+		t.Fatalf("non-admin: want 403, got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodPut, "/api/admin/collation", "admin", strings.NewReader(`{"locale":"??"}`)); resp.Status != http.StatusBadRequest {
+		t.Fatalf("invalid locale: want 400, got %d", resp.Status)
+	}
+	resp := h.do(t, http.MethodPut, "/api/admin/collation", "admin", strings.NewReader(`{"locale":"sv"}`))
+	if resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `"locale":"sv"`) {
+		t.Fatalf("set locale: %d (%s)", resp.Status, resp.Body)
+	}
+	if setting, err := h.store.SystemSettings.Get(t.Context(), "system.collation_locale"); err != nil || setting.Value != "sv" {
+		t.Fatalf("locale not persisted: %+v err=%v", setting, err)
+	}
+	if got := order(); got != "Zebra,Ähre" {
+		t.Fatalf("swedish collation order = %s", got)
+	}
+	resp = h.do(t, http.MethodPost, "/api/admin/collation/backfill", "admin", nil)
+	if resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `"updated":0`) {
+		t.Fatalf("backfill: %d (%s)", resp.Status, resp.Body)
+	}
+}
+
+func TestAdminHygieneReportsLastSweep(t *testing.T) {
+	var hygiene *service.HygieneService
+	h := newHarness(t, func(d *server.Deps) {
+		hygiene = service.NewHygieneService(d.Store.Orphans)
+		d.Hygiene = hygiene
+	})
+	if resp := h.do(t, http.MethodGet, "/api/admin/hygiene", "op", nil); resp.Status != http.StatusForbidden {
+		t.Fatalf("non-admin: want 403, got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/admin/hygiene", "admin", nil); resp.Status != http.StatusOK || strings.Contains(string(resp.Body), "last") {
+		t.Fatalf("report before the first sweep: %d (%s)", resp.Status, resp.Body)
+	}
+	if err := h.store.Grants.Create(t.Context(), &models.Grant{ID: "orphan", ConnectionID: "purged", SubjectID: "op"}); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	if _, err := hygiene.Run(t.Context(), time.Now()); err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	resp := h.do(t, http.MethodGet, "/api/admin/hygiene", "admin", nil)
+	if resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `"grant_connection":1`) {
+		t.Fatalf("report: %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/admin/read-only", "admin", nil); strings.Contains(string(resp.Body), "hygiene") || strings.Contains(string(resp.Body), "grant_connection") {
+		t.Fatalf("maintenance status should not carry the sweep: %s", resp.Body)
+	}
+}
@@ -96,6 +96,10 @@ func platformKeys(v pluginmarket.Version) []string {
 	return keys
 }
 
+type marketInstallRequest struct {
+	Version string `json:"version"`
+}
+
 func (s *Server) handleAdminMarketInstall(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	actor, _ := userFrom(ctx)
@@ -105,9 +109,7 @@ func (s *Server) handleAdminMarketInstall(w http.ResponseWriter, r *http.Request
 	}
 	name := chi.URLParam(r, "name")
 
-	var req struct {
-		Version string `json:"version"`
-	}
+	var req marketInstallRequest
 	if body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20)); err == nil && len(body) > 0 {
 		if err := json.Unmarshal(body, &req); err != nil {
 			writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
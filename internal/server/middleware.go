@@ -9,6 +9,7 @@ import (
 	"github.com/charlesng35/shellcn/internal/audit"
 	"github.com/charlesng35/shellcn/internal/auth"
 	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
 	"github.com/charlesng35/shellcn/sdk/plugin"
 )
 
@@ -17,6 +18,7 @@ type ctxKey int
 const (
 	ctxUser ctxKey = iota
 	ctxSession
+	ctxAccessUser
 )
 
 func userFrom(ctx context.Context) (models.User, bool) {
@@ -54,6 +56,11 @@ func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) (context.C
 		writeAuthRequired(w, s.deps.Logger, plugin.ErrUnauthorized)
 		return nil, false
 	}
+	if user.MustChangePassword && !passwordChangeExempt(r) {
+		writeError(w, s.deps.Logger, errPasswordChangeRequired)
+		return nil, false
+	}
+	noteAccessUser(r.Context(), user.ID)
 	ctx := context.WithValue(r.Context(), ctxUser, user)
 	ctx = context.WithValue(ctx, ctxSession, sess)
 	return ctx, true
@@ -157,19 +164,42 @@ func forwardedValue(header, key string) string {
 	return ""
 }
 
-// withRemoteAddr stashes the direct peer address on the request context so every
-// audit event recorded during the request carries the caller's source IP. The
-// non-spoofable RemoteAddr is used (not X-Forwarded-For) so the audit trail
-// cannot be forged by a client header.
+// withRemoteAddr stashes the client address on the request context so every
+// audit event recorded during the request carries the caller's source IP, and
+// sessions and recordings started by it know where they came from. Forwarding
+// headers are only believed from a trusted proxy, so a client cannot forge
+// the audit trail.
 func (s *Server) withRemoteAddr(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx := audit.WithRemoteAddr(r.Context(), clientIP(r))
+		c := s.clientInfo(r)
+		ctx := audit.WithRemoteAddr(r.Context(), c.IP)
+		ctx = audit.WithClient(ctx, c)
 		ctx = audit.WithSource(ctx, audit.SourceHTTP, "")
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// withOperationTimeouts applies the configured service timeouts to the request.
+func (s *Server) withOperationTimeouts(next http.Handler) http.Handler {
+	if s.deps.Timeouts == nil {
+		return next
+	}
+	t := *s.deps.Timeouts
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(service.ContextWithTimeouts(r.Context(), t)))
+	})
+}
+
+// clientIP is the resolved client address, or the direct peer outside the
+// withRemoteAddr middleware.
 func clientIP(r *http.Request) string {
+	if ip := audit.ClientFrom(r.Context()).IP; ip != "" {
+		return ip
+	}
+	return peerIP(r)
+}
+
+func peerIP(r *http.Request) string {
 	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
 		return host
 	}
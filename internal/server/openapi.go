@@ -0,0 +1,340 @@
+package server
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/app"
+	"github.com/charlesng35/shellcn/internal/auth"
+)
+
+// apiOperation documents one route. Request and Response hold zero values
+// whose types are reflected into schemas; nil means no JSON body.
+type apiOperation struct {
+	Summary  string
+	Request  any
+	Response any
+	// Status is the success status; zero means 200.
+	Status int
+	// ContentType names a non-JSON success body such as a stream.
+	ContentType string
+	Public      bool
+}
+
+// undocumentedMethods never appear in the document. A route registered for
+// every method is documented under the "*" key for the common verbs only.
+var undocumentedMethods = map[string]bool{
+	http.MethodConnect: true, http.MethodOptions: true, http.MethodTrace: true,
+}
+
+var anyMethodVerbs = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// lookupAPIOperation finds the documentation for a registered method and
+// pattern, falling back to the "*" entry for any-method routes.
+func lookupAPIOperation(method, pattern string) (apiOperation, bool) {
+	if op, ok := apiOperations[method+" "+pattern]; ok {
+		return op, true
+	}
+	op, ok := apiOperations["* "+pattern]
+	return op, ok
+}
+
+// apiRoutes lists the documentable "METHOD pattern" pairs registered on the
+// router, sorted.
+func apiRoutes(router chi.Routes) []string {
+	methods := map[string][]string{}
+	_ = chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		methods[route] = append(methods[route], method)
+		return nil
+	})
+	var out []string
+	for route, registered := range methods {
+		// chi registers HandleFunc routes under every method, CONNECT included.
+		if slices.Contains(registered, http.MethodConnect) {
+			registered = anyMethodVerbs
+		}
+		for _, method := range registered {
+			if !undocumentedMethods[method] {
+				out = append(out, method+" "+route)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// handleOpenAPI serves an OpenAPI 3 document built from the routes actually
+// registered on this server, so disabled features are absent from it.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.openAPIDocument())
+}
+
+func (s *Server) openAPIDocument() map[string]any {
+	schemas := newSchemaRegistry()
+	errorRef := schemas.ref(reflect.TypeOf(errorEnvelope{}))
+	paths := map[string]map[string]any{}
+	for _, key := range apiRoutes(s.router) {
+		method, pattern, _ := strings.Cut(key, " ")
+		op, ok := lookupAPIOperation(method, pattern)
+		if !ok {
+			op = apiOperation{Summary: "Undocumented"}
+		}
+		path := strings.TrimSuffix(pattern, "/*")
+		if path != pattern {
+			path += "/{path}"
+		}
+		item := map[string]any{
+			"summary":     op.Summary,
+			"operationId": operationID(method, path),
+			"tags":        []string{operationTag(path)},
+			"responses":   operationResponses(op, method, schemas, errorRef),
+		}
+		if params := pathParameters(path); len(params) > 0 {
+			item["parameters"] = params
+		}
+		if op.Public {
+			item["security"] = []any{}
+		}
+		if op.Request != nil {
+			item["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": schemas.schema(reflect.TypeOf(op.Request))}},
+			}
+		}
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(method)] = item
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   app.DisplayName + " API",
+			"version": s.deps.Version,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]any{
+				"session": map[string]any{"type": "apiKey", "in": "cookie", "name": auth.SessionCookieName},
+				"csrf": map[string]any{
+					"type": "apiKey", "in": "header", "name": auth.CSRFHeader,
+					"description": "Required on state-changing requests; the value is returned by /api/auth/me.",
+				},
+			},
+		},
+		"security": []any{map[string]any{"session": []string{}, "csrf": []string{}}},
+	}
+}
+
+func operationResponses(op apiOperation, method string, schemas *schemaRegistry, errorRef map[string]any) map[string]any {
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]any{"description": http.StatusText(status)}
+	switch {
+	case method == http.MethodHead:
+	case op.ContentType != "":
+		success["content"] = map[string]any{op.ContentType: map[string]any{}}
+	case op.Response != nil:
+		success["content"] = map[string]any{"application/json": map[string]any{"schema": schemas.schema(reflect.TypeOf(op.Response))}}
+	}
+	return map[string]any{
+		strconv.Itoa(status): success,
+		"default": map[string]any{
+			"description": "Error",
+			"content":     map[string]any{"application/json": map[string]any{"schema": errorRef}},
+		},
+	}
+}
+
+func pathParameters(path string) []any {
+	var out []any
+	for _, seg := range strings.Split(path, "/") {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			out = append(out, map[string]any{
+				"name": seg[1 : len(seg)-1], "in": "path", "required": true,
+				"schema": map[string]any{"type": "string"},
+			})
+		}
+	}
+	return out
+}
+
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	upper := true
+	for _, r := range strings.TrimPrefix(path, "/api") {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if upper {
+				r = unicode.ToUpper(r)
+			}
+			b.WriteRune(r)
+			upper = false
+		default:
+			upper = true
+		}
+	}
+	return b.String()
+}
+
+func operationTag(path string) string {
+	segs := strings.Split(strings.TrimPrefix(path, "/api/"), "/")
+	if segs[0] == "admin" || segs[0] == "me" {
+		if len(segs) > 1 {
+			return segs[0] + "/" + segs[1]
+		}
+	}
+	return segs[0]
+}
+
+// schemaRegistry reflects Go types into OpenAPI schemas, placing named structs
+// under components so shared DTOs are described once.
+type schemaRegistry struct {
+	components map[string]any
+	names      map[reflect.Type]string
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{components: map[string]any{}, names: map[reflect.Type]string{}}
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage(nil))
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (r *schemaRegistry) ref(t reflect.Type) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + r.register(t)}
+}
+
+func (r *schemaRegistry) register(t reflect.Type) string {
+	if name, ok := r.names[t]; ok {
+		return name
+	}
+	name := exportedName(t.Name())
+	if _, taken := r.components[name]; taken {
+		pkg := t.PkgPath()
+		name = exportedName(pkg[strings.LastIndex(pkg, "/")+1:]) + name
+	}
+	r.names[t] = name
+	// Reserve the slot before descending so recursive types terminate.
+	r.components[name] = map[string]any{}
+	r.components[name] = r.structSchema(t)
+	return name
+}
+
+func (r *schemaRegistry) schema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	case t.Kind() != reflect.Pointer && t.Implements(jsonMarshalerType):
+		return map[string]any{}
+	case t.Kind() != reflect.Pointer && t.Implements(textMarshalerType):
+		return map[string]any{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := r.schema(t.Elem())
+		if _, isRef := s["$ref"]; isRef {
+			return map[string]any{"allOf": []any{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		return r.ref(t)
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": r.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": r.schema(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{}
+	}
+}
+
+func (r *schemaRegistry) structSchema(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	r.collectFields(t, props, &required)
+	out := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		sort.Strings(required)
+		out["required"] = required
+	}
+	return out
+}
+
+// collectFields follows encoding/json's field rules: untagged embedded structs
+// are flattened, "-" is skipped, and omitempty fields are optional.
+func (r *schemaRegistry) collectFields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				r.collectFields(ft, props, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = r.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func exportedName(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
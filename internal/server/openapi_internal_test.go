@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	aiconfig "github.com/charlesng35/shellcn/internal/ai/config"
+	"github.com/charlesng35/shellcn/internal/audit"
+	"github.com/charlesng35/shellcn/internal/auth"
+	"github.com/charlesng35/shellcn/internal/recording"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/session"
+	"github.com/charlesng35/shellcn/internal/telemetry"
+	"github.com/charlesng35/shellcn/internal/transport"
+)
+
+// fullyWiredServer registers every optional route group. Handlers are never
+// invoked, so zero-value dependencies are enough.
+func fullyWiredServer() *Server {
+	s := &Server{deps: Deps{
+		Metrics: telemetry.NewMetrics(), Enrollments: &service.EnrollmentService{}, Tunnels: &transport.Registry{},
+		ArtifactTickets: &auth.TicketStore{}, Invitations: &service.InvitationService{}, TwoFactor: &service.TwoFactorService{},
+		Connections: &service.ConnectionService{}, Credentials: &service.CredentialService{}, AI: &aiconfig.Service{},
+		Recordings: &service.RecordingService{}, Recording: &recording.Engine{}, Users: &service.UserService{},
+		Maintenance: &service.MaintenanceService{}, Protocols: &service.ProtocolService{}, Activity: &service.ActivityService{}, Usage: &service.ConnectionUsageService{}, GrantExpiry: &service.GrantExpiry{}, DriverSettings: &service.DriverSettings{}, Hygiene: &service.HygieneService{},
+		Webhooks:        &service.WebhookService{},
+		Jobs:            &service.JobService{},
+		Preferences:     &service.PreferenceService{},
+		JobTickets:      &auth.TicketStore{},
+		SessionShares:   &service.SessionShareService{},
+		CredentialReads: &service.CredentialReadGuard{},
+		Collation:       &service.CollationService{},
+		Settings:        &service.SettingsService{},
+		PasswordPolicy:  &service.PasswordPolicyService{},
+		Approvals:       &service.CredentialApprovals{},
+		Presence:        &session.PresenceHub{},
+		Observations:    &service.SessionObservationService{},
+		AuditTail:       &audit.Tail{},
+	}}
+	s.router = s.routes()
+	return s
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	s := fullyWiredServer()
+	registered := map[string]bool{}
+	for _, key := range apiRoutes(s.router) {
+		method, pattern, _ := strings.Cut(key, " ")
+		if _, ok := lookupAPIOperation(method, pattern); !ok {
+			t.Errorf("route %s is missing from apiOperations", key)
+		}
+		registered[key] = true
+		registered["* "+pattern] = true
+	}
+	for key := range apiOperations {
+		if !registered[key] {
+			t.Errorf("apiOperations documents %s, which is not registered", key)
+		}
+	}
+}
+
+func TestOpenAPIDocumentShape(t *testing.T) {
+	s := fullyWiredServer()
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	var doc struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+				Required   []string       `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Fatalf("openapi version %q", doc.OpenAPI)
+	}
+	op := doc.Paths["/api/connections/{id}/proxy/{path}"]["get"]
+	if op == nil || len(op["parameters"].([]any)) != 2 {
+		t.Fatalf("proxy wildcard should become a path parameter: %v", doc.Paths["/api/connections/{id}/proxy/{path}"])
+	}
+	if _, ok := doc.Paths["/api/connections/{id}/x/{routeID}"]["connect"]; ok {
+		t.Fatal("CONNECT should not be documented")
+	}
+	folder, ok := doc.Components.Schemas["FolderLaunchResult"]
+	if !ok {
+		t.Fatal("nested DTOs should be registered as components")
+	}
+	if _, ok := folder.Properties["session"]; !ok || strings.Contains(strings.Join(folder.Required, ","), "session") {
+		t.Fatalf("omitempty fields are optional: %+v", folder)
+	}
+	if _, ok := doc.Components.Schemas["ErrorEnvelope"]; !ok {
+		t.Fatal("error envelope schema missing")
+	}
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"net/http"
+
+	aiconfig "github.com/charlesng35/shellcn/internal/ai/config"
+	"github.com/charlesng35/shellcn/internal/ai/memory"
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/recording"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/session"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+type okDTO map[string]bool
+
+// apiOperations documents every core route by "METHOD pattern" as registered
+// on the router; "*" covers a route that accepts any method. TestOpenAPI
+// fails when a registered route has no entry here.
+var apiOperations = map[string]apiOperation{
+	"GET /healthz": {Summary: "Liveness and readiness", ContentType: "application/json", Public: true},
+	"GET /metrics": {Summary: "Prometheus metrics", ContentType: "text/plain", Public: true},
+
+	"GET /api/openapi.json":                         {Summary: "This document", ContentType: "application/json", Public: true},
+	"POST /api/auth/login":                          {Summary: "Log in with a password", Request: loginRequest{}, Response: loginResponse{}, Public: true},
+	"POST /api/auth/login/mfa":                      {Summary: "Complete a two-factor login", Request: mfaRequest{}, Response: loginResponse{}, Public: true},
+	"GET /api/auth/password-policy":                 {Summary: "Requirements new local passwords must meet", Response: passwordPolicyDTO{}, Public: true},
+	"POST /api/auth/logout":                         {Summary: "Log out", Response: okDTO{}},
+	"GET /api/auth/me":                              {Summary: "Current session", Response: sessionDTO{}},
+	"PUT /api/auth/me":                              {Summary: "Update own profile", Request: updateProfileRequest{}, Response: userDTO{}},
+	"POST /api/auth/me/password":                    {Summary: "Change own password", Request: changePasswordRequest{}, Response: sessionDTO{}},
+	"POST /api/auth/totp/setup":                     {Summary: "Begin two-factor enrollment", Response: totpSetupResponse{}},
+	"POST /api/auth/totp/enable":                    {Summary: "Confirm two-factor enrollment", Request: totpCodeRequest{}, Response: recoveryCodesResponse{}},
+	"POST /api/auth/totp/disable":                   {Summary: "Disable two-factor", Request: totpCodeRequest{}, Response: okDTO{}},
+	"POST /api/auth/totp/recovery-codes":            {Summary: "Regenerate recovery codes", Request: totpCodeRequest{}, Response: recoveryCodesResponse{}},
+	"POST /api/auth/totp/remind":                    {Summary: "Postpone the two-factor reminder", Response: okDTO{}},
+	"GET /api/agent/connect":                        {Summary: "Agent tunnel (WebSocket upgrade, enrollment token auth)", Status: http.StatusSwitchingProtocols, Public: true},
+	"GET /api/invitations/{token}":                  {Summary: "Look up an invitation", Response: map[string]string{}, Public: true},
+	"POST /api/invitations/{token}/accept":          {Summary: "Accept an invitation", Request: acceptInviteRequest{}, Response: map[string]string{}, Status: http.StatusCreated, Public: true},
+	"GET /api/plugins":                              {Summary: "List available protocols", Response: []plugin.Summary{}},
+	"GET /api/plugins/{name}":                       {Summary: "Protocol projection", Response: plugin.Projection{}},
+	"GET /api/plugins/{name}/template":              {Summary: "Protocol connection form", Response: plugin.ConfigTemplate{}},
+	"GET /api/credential-kinds":                     {Summary: "List credential kinds", Response: []plugin.CredentialKindInfo{}},
+	"GET /api/audit/me":                             {Summary: "Own audit trail", Response: auditPage{}},
+	"GET /api/credentials":                          {Summary: "List usable credentials (?sort=recent orders by your own last use)", Response: []models.CredentialSummary{}},
+	"POST /api/credentials":                         {Summary: "Create a credential", Request: credentialWriteRequest{}, Response: credentialWriteResponse{}, Status: http.StatusCreated},
+	"PUT /api/credentials/{id}":                     {Summary: "Update a credential", Request: credentialWriteRequest{}, Response: credentialWriteResponse{}},
+	"DELETE /api/credentials/{id}":                  {Summary: "Delete a credential", Response: okDTO{}},
+	"PUT /api/credentials/{id}/favorite":            {Summary: "Pin a credential in the picker", Status: http.StatusNoContent},
+	"DELETE /api/credentials/{id}/favorite":         {Summary: "Unpin a credential", Status: http.StatusNoContent},
+	"GET /api/credentials/{id}/grants":              {Summary: "List credential shares", Response: []grantDTO{}},
+	"POST /api/credentials/{id}/grants":             {Summary: "Share a credential", Request: grantRequest{}, Response: grantDTO{}, Status: http.StatusCreated},
+	"DELETE /api/credentials/{id}/grants/{grantId}": {Summary: "Revoke a credential share", Response: okDTO{}},
+	"GET /api/credentials/{id}/access-log":          {Summary: "Credential access log", Response: credentialAccessPage{}},
+	"GET /api/credentials/{id}/versions":            {Summary: "List credential versions", Response: []service.CredentialVersionInfo{}},
+	"GET /api/credentials/{id}/versions/diff":       {Summary: "Compare two credential versions without values", Response: service.CredentialDiff{}},
+	"GET /api/credential-approvals":                 {Summary: "Own approval requests and pending ones you may approve", Response: []models.CredentialApproval{}},
+	"POST /api/credential-approvals/{id}/approve":   {Summary: "Approve another user's credential request", Response: models.CredentialApproval{}},
+
+	"GET /api/connections":                                                        {Summary: "List accessible connections (?favorites_only=true, ?folder=&recursive=true)", Response: []connectionDTO{}},
+	"POST /api/connections":                                                       {Summary: "Create a connection", Request: connectionWriteRequest{}, Response: connectionDTO{}, Status: http.StatusCreated},
+	"PUT /api/connections/layout":                                                 {Summary: "Save sidebar layout", Request: connectionLayoutRequest{}, Response: okDTO{}},
+	"GET /api/connections/trash":                                                  {Summary: "List trashed connections", Response: []trashedConnectionDTO{}},
+	"GET /api/connections/{id}":                                                   {Summary: "Connection detail", Response: service.ConnectionDetail{}},
+	"PUT /api/connections/{id}":                                                   {Summary: "Update a connection", Request: connectionWriteRequest{}, Response: service.ConnectionDetail{}},
+	"DELETE /api/connections/{id}":                                                {Summary: "Move a connection to the trash", Response: okDTO{}},
+	"POST /api/connections/{id}/restore":                                          {Summary: "Restore a trashed connection", Response: service.ConnectionDetail{}},
+	"GET /api/connections/{id}/session":                                           {Summary: "Session status", Response: connectionSessionDTO{}},
+	"POST /api/connections/{id}/session":                                          {Summary: "Open or keep alive a session; a new one connects in the background (202 pending) unless ?wait=true", Response: connectionSessionDTO{}},
+	"DELETE /api/connections/{id}/session":                                        {Summary: "Disconnect a session", Response: okDTO{}},
+	"POST /api/connections/{id}/session/resize":                                   {Summary: "Resize a live session's terminal", Request: sessionResizeRequest{}, Response: connectionSessionDTO{}},
+	"GET /api/connections/{id}/session/presence":                                  {Summary: "Participant presence events (WebSocket upgrade)", Status: http.StatusSwitchingProtocols},
+	"POST /api/connections/{id}/session/presence":                                 {Summary: "Publish own typing, cursor and scroll presence", Request: session.PresenceState{}, Response: presenceUpdateDTO{}},
+	"POST /api/connections/{id}/exec":                                             {Summary: "Run a command on a connection without a terminal", Request: execRequest{}, Response: execResultDTO{}},
+	"GET /api/connections/{id}/stats":                                             {Summary: "Session counts and last use of a connection", Response: service.ConnectionStats{}},
+	"GET /api/connections/{id}/audit":                                             {Summary: "Audit trail of a connection", Response: auditPage{}},
+	"PUT /api/connections/{id}/favorite":                                          {Summary: "Pin a connection", Status: http.StatusNoContent},
+	"DELETE /api/connections/{id}/favorite":                                       {Summary: "Unpin a connection", Status: http.StatusNoContent},
+	"PATCH /api/me/favorites/order":                                               {Summary: "Reorder pinned connections", Request: favoriteOrderRequest{}, Status: http.StatusNoContent},
+	"GET /api/connections/shares/expiring":                                        {Summary: "Own connection shares lapsing within ?within=<n>d", Response: []expiringSharesDTO{}},
+	"POST /api/connections/shares/extend":                                         {Summary: "Move a batch of own connection shares to a new expiry", Request: extendSharesRequest{}, Response: []grantDTO{}},
+	"GET /api/connections/{id}/grants":                                            {Summary: "List connection shares", Response: []grantDTO{}},
+	"POST /api/connections/{id}/grants":                                           {Summary: "Share a connection", Request: grantRequest{}, Response: grantDTO{}, Status: http.StatusCreated},
+	"DELETE /api/connections/{id}/grants/{grantId}":                               {Summary: "Revoke a connection share", Response: okDTO{}},
+	"GET /api/connections/{id}/session/share-links":                               {Summary: "List live-session share links", Response: []models.SessionShareLinkSummary{}},
+	"POST /api/connections/{id}/session/share-links":                              {Summary: "Create a live-session share link", Request: service.SessionShareInput{}, Response: shareLinkCreateResponse{}, Status: http.StatusCreated},
+	"DELETE /api/connections/{id}/session/share-links/{linkId}":                   {Summary: "Revoke a live-session share link", Response: okDTO{}},
+	"POST /api/sessions/join/{token}":                                             {Summary: "Join a live session by share link", Response: sessionJoinResponse{}},
+	"GET /api/connections/{id}/session/shared/{ownerId}/stream":                   {Summary: "Watch a joined live session; input is typed while holding write (WebSocket upgrade)", Status: http.StatusSwitchingProtocols},
+	"POST /api/connections/{id}/session/write-requests":                           {Summary: "Request write access to a shared session", Response: models.SessionWriteRequest{}, Status: http.StatusAccepted},
+	"POST /api/connections/{id}/session/write-requests/{requestId}/approve":       {Summary: "Approve a write access request", Request: writeRequestDecision{}, Response: models.SessionWriteRequest{}},
+	"POST /api/connections/{id}/session/write-requests/{requestId}/deny":          {Summary: "Deny a write access request", Request: writeRequestDecision{}, Response: models.SessionWriteRequest{}},
+	"POST /api/connections/{id}/tickets":                                          {Summary: "Mint a stream ticket", Request: ticketRequest{}, Response: ticketResponse{}, Status: http.StatusCreated},
+	"* /api/connections/{id}/x/{routeID}":                                         {Summary: "Invoke a plugin route; body and result follow the route's schema", Response: map[string]any{}},
+	"* /api/connections/{id}/proxy/*":                                             {Summary: "Reverse-proxy through a connection", ContentType: "*/*"},
+	"POST /api/connections/{id}/agent/enrollments":                                {Summary: "Create an agent enrollment", Response: service.Enrollment{}, Status: http.StatusCreated},
+	"GET /api/connections/{id}/agent/state":                                       {Summary: "Agent state", Response: service.AgentState{}},
+	"GET /api/connections/{id}/agent/enrollments/{enrollmentId}/artifacts/{kind}": {Summary: "Fetch an install artifact (signed ticket auth)", ContentType: "text/plain", Public: true},
+	"GET /api/jobs/{id}/result":                                                   {Summary: "Download a job result (signed ticket auth)", ContentType: "application/octet-stream", Public: true},
+	"GET /api/audit/stream":                                                       {Summary: "Live audit entries as server-sent events (audit.view); ?action, ?user_id, ?resource_type; resumes from Last-Event-ID", ContentType: "text/event-stream"},
+	"GET /api/me/events":                                                          {Summary: "Own job progress and preference change events (WebSocket upgrade)", Status: http.StatusSwitchingProtocols},
+	"GET /api/sessions/{id}/observe":                                              {Summary: "Read-only live session output for auditors (WebSocket upgrade)", Status: http.StatusSwitchingProtocols},
+	"GET /api/sessions/{id}/scrollback":                                           {Summary: "Page through a live terminal session's recent output (owner or session.observe)", Response: scrollbackPageDTO{}},
+	"GET /api/sessions/{id}/scrollback/search":                                    {Summary: "Search a live terminal session's recent output; ?q, ?regex, ?context", Response: scrollbackSearchDTO{}},
+	"GET /api/sessions/{id}/clipboard":                                            {Summary: "Text the caller's live RDP/VNC session last reported on its remote clipboard", Response: clipboardDTO{}},
+	"PUT /api/sessions/{id}/clipboard":                                            {Summary: "Replace the remote clipboard of the caller's live session; 413 over live_state.clipboard_max_bytes", Request: clipboardBody{}, Status: http.StatusNoContent},
+	"GET /api/me/preferences":                                                     {Summary: "Own synced UI preferences (ETag header)", Response: service.UserPreferences{}},
+	"PUT /api/me/preferences":                                                     {Summary: "Merge UI preferences; null deletes a key (If-Match for concurrency)", Request: map[string]any{}, Response: service.UserPreferences{}},
+	"GET /api/jobs/{id}":                                                          {Summary: "Poll a background job", Response: jobDTO{}},
+	"POST /api/jobs/{id}/cancel":                                                  {Summary: "Cancel a queued or running job", Status: http.StatusAccepted, Response: jobDTO{}},
+	"POST /api/admin/audit/export":                                                {Summary: "Queue an NDJSON audit log export", Request: auditExportRequest{}, Status: http.StatusAccepted, Response: jobDTO{}},
+	"POST /api/admin/audit/verify":                                                {Summary: "Replay the audit hash chain over a time range and report the first broken link", Request: auditVerifyRequest{}, Response: auditVerifyDTO{}},
+	"GET /api/connection-folders":                                                 {Summary: "List folders", Response: []service.ConnectionFolderDTO{}},
+	"POST /api/connection-folders":                                                {Summary: "Create a folder", Request: connectionFolderRequest{}, Response: service.ConnectionFolderDTO{}, Status: http.StatusCreated},
+	"PUT /api/connection-folders/{folderId}":                                      {Summary: "Update a folder", Request: connectionFolderRequest{}, Response: service.ConnectionFolderDTO{}},
+	"DELETE /api/connection-folders/{folderId}":                                   {Summary: "Delete a folder", Response: okDTO{}},
+	"POST /api/connection-folders/{folderId}/launch":                              {Summary: "Open every connection in a folder", Request: folderLaunchRequest{}, Response: folderLaunchDTO{}},
+	"GET /api/connection-folders/{folderId}/export":                               {Summary: "Export a folder tree as a bundle", Response: service.ConnectionBundle{}},
+	"POST /api/connection-folders/import":                                         {Summary: "Import a folder bundle", Request: connectionImportRequest{}, Response: service.ConnectionImportReport{}},
+
+	"GET /api/recordings":                           {Summary: "List recordings (?q= also searches transcripts; ?format=csv exports, ?columns= picks fields)", Response: []recordingDTO{}},
+	"GET /api/recordings/{id}":                      {Summary: "Recording detail", Response: recordingDTO{}},
+	"GET /api/recordings/{id}/content":              {Summary: "Recording content", ContentType: "application/octet-stream"},
+	"HEAD /api/recordings/{id}/content":             {Summary: "Recording content headers"},
+	"GET /api/recordings/{id}/transcript":           {Summary: "Recording output transcript", Response: recording.Transcript{}},
+	"GET /api/recordings/{id}/replay":               {Summary: "Paced terminal replay (WebSocket upgrade; speed, pause and seek controls; ?skip_idle=true shortens idle gaps)", Status: http.StatusSwitchingProtocols},
+	"GET /api/recordings/{id}/events.json":          {Summary: "Terminal recording as NDJSON events (?include_payload=true adds text)", ContentType: "application/x-ndjson"},
+	"DELETE /api/recordings/{id}":                   {Summary: "Delete a recording", Response: okDTO{}},
+	"GET /api/recordings/{id}/annotations":          {Summary: "List recording annotations", Response: []models.RecordingAnnotation{}},
+	"POST /api/recordings/{id}/annotations":         {Summary: "Annotate a recording", Request: annotationRequest{}, Response: models.RecordingAnnotation{}, Status: http.StatusCreated},
+	"POST /api/recordings/{id}/pause":               {Summary: "Pause capture of a live recording", Response: map[string]bool{}},
+	"POST /api/recordings/{id}/resume":              {Summary: "Resume capture of a live recording", Response: map[string]bool{}},
+	"POST /api/recordings/{id}/chunks":              {Summary: "Upload a recording chunk (raw body, ?index=)", Response: map[string]any{}},
+	"POST /api/recordings/{id}/finalize":            {Summary: "Finalize a chunked recording", Response: recordingDTO{}},
+	"POST /api/recordings/{id}/abort":               {Summary: "Abort a chunked recording", Response: okDTO{}},
+	"GET /api/connections/{id}/recordings":          {Summary: "List a connection's recordings", Response: []recordingDTO{}},
+	"POST /api/connections/{id}/recordings/control": {Summary: "Start or stop a manual recording", Request: recordingControlRequest{}, Response: recordingDTO{}},
+	"GET /api/admin/recordings/storage-report":      {Summary: "Recording storage by user and connection (?top=10)", Response: recordingStorageDTO{}},
+	"POST /api/admin/recordings/bulk-delete":        {Summary: "Delete recordings matching a filter (dryRun reports counts only)", Request: recordingBulkDeleteRequest{}, Response: service.RecordingBulkResult{}},
+	"POST /api/admin/recordings/protect":            {Summary: "Place a legal hold on recordings matching a filter", Request: recordingProtectMatchingRequest{}, Response: recordingProtectMatchingDTO{}},
+	"POST /api/recordings/{id}/protect":             {Summary: "Place a legal hold on a recording", Request: recordingProtectRequest{}, Response: recordingDTO{}},
+	"POST /api/recordings/{id}/unprotect":           {Summary: "Lift a recording's legal hold (reason required)", Request: recordingProtectRequest{}, Response: recordingDTO{}},
+	"POST /api/connections/{id}/recordings/desktop": {Summary: "Begin a chunked desktop recording", Request: recordingControlRequest{}, Response: recordingDTO{}, Status: http.StatusCreated},
+
+	"POST /api/credentials/{id}/transfer-ownership":  {Summary: "Hand a credential to another user (owner or root admin)", Request: credentialTransferRequest{}, Response: models.CredentialSummary{}},
+	"POST /api/admin/credentials/transfer-ownership": {Summary: "Move all of one user's credentials to another", Request: credentialTransferAllRequest{}, Response: credentialTransferAllDTO{}},
+	"POST /api/connections/{id}/transfer-ownership":  {Summary: "Hand a connection to another user (owner or root admin)", Request: connectionTransferRequest{}, Response: service.ConnectionDetail{}},
+	"POST /api/admin/connections/reassign":           {Summary: "Move all of one user's connections to another", Request: connectionReassignRequest{}, Response: connectionReassignDTO{}},
+	"POST /api/admin/connections/migrate-config":     {Summary: "Rewrite connections saved under an older config version; reports those needing manual edits", Response: service.ConfigMigrationReport{}},
+
+	"GET /api/ai/global":                                        {Summary: "Shared AI provider status", Response: aiconfig.GlobalStatus{}},
+	"GET /api/me/ai/config":                                     {Summary: "List own AI providers", Response: []models.AIProviderSummary{}},
+	"POST /api/me/ai/config":                                    {Summary: "Add an AI provider", Request: aiProviderRequest{}, Response: models.AIProviderSummary{}, Status: http.StatusCreated},
+	"PUT /api/me/ai/config/{id}":                                {Summary: "Update an AI provider", Request: aiProviderRequest{}, Response: models.AIProviderSummary{}},
+	"DELETE /api/me/ai/config/{id}":                             {Summary: "Delete an AI provider", Status: http.StatusNoContent},
+	"GET /api/me/ai/config/{id}/models":                         {Summary: "List a provider's models", Response: map[string]any{}},
+	"POST /api/me/ai/config/{id}/test":                          {Summary: "Test a saved provider", Response: map[string]any{}},
+	"POST /api/me/ai/models":                                    {Summary: "List models for a draft provider", Request: aiProviderRequest{}, Response: map[string]any{}},
+	"POST /api/me/ai/test":                                      {Summary: "Test a draft provider", Request: aiProviderRequest{}, Response: map[string]any{}},
+	"POST /api/connections/{id}/ai/turns":                       {Summary: "Run an AI turn (NDJSON event stream)", Request: aiTurnRequest{}, ContentType: "application/x-ndjson"},
+	"POST /api/connections/{id}/ai/turns/{turnID}/control":      {Summary: "Approve or cancel an AI turn step", Request: aiTurnControlRequest{}, Status: http.StatusNoContent},
+	"GET /api/connections/{id}/ai/conversations":                {Summary: "List AI conversations", Response: []models.AIConversation{}},
+	"POST /api/connections/{id}/ai/conversations":               {Summary: "Start an AI conversation", Request: createConversationRequest{}, Response: models.AIConversation{}, Status: http.StatusCreated},
+	"GET /api/connections/{id}/ai/conversations/{cid}":          {Summary: "AI conversation with its latest messages", Response: conversationDTO{}},
+	"GET /api/connections/{id}/ai/conversations/{cid}/messages": {Summary: "Page AI conversation messages", Response: memory.MessagePage{}},
+	"PUT /api/connections/{id}/ai/conversations/{cid}":          {Summary: "Rename an AI conversation", Request: renameConversationRequest{}, Response: models.AIConversation{}},
+	"DELETE /api/connections/{id}/ai/conversations/{cid}":       {Summary: "Delete an AI conversation", Status: http.StatusNoContent},
+
+	"GET /api/admin/users":                    {Summary: "List users", Response: []adminUserDTO{}},
+	"GET /api/admin/users/search":             {Summary: "Search users (?query=)", Response: []userSummary{}},
+	"POST /api/admin/users":                   {Summary: "Create a user", Request: createUserRequest{}, Response: adminUserDTO{}, Status: http.StatusCreated},
+	"GET /api/admin/users/{id}":               {Summary: "User detail", Response: adminUserDTO{}},
+	"PUT /api/admin/users/{id}":               {Summary: "Update a user", Request: updateUserRequest{}, Response: adminUserDTO{}},
+	"POST /api/admin/users/{id}/activate":     {Summary: "Activate a user", Response: adminUserDTO{}},
+	"POST /api/admin/users/{id}/deactivate":   {Summary: "Deactivate a user", Response: adminUserDTO{}},
+	"DELETE /api/admin/users/{id}":            {Summary: "Delete a user (?transferTo= or ?orphanCredentials=true for owned credentials, ?transferConnectionsTo= or ?orphanConnections=true for owned connections)", Response: okDTO{}},
+	"POST /api/admin/users/{id}/reset-2fa":    {Summary: "Reset a user's two-factor", Response: adminUserDTO{}},
+	"POST /api/admin/users/{id}/anonymize":    {Summary: "Irreversibly scrub one batch of a user's personal data (root only; repeat until done)", Request: anonymizeUserRequest{}, Response: service.AnonymizeReport{}},
+	"GET /api/admin/users/{id}/audit":         {Summary: "A user's audit trail", Response: auditPage{}},
+	"GET /api/admin/users/{id}/connections":   {Summary: "Connections a user owns", Response: []userConnectionDTO{}},
+	"GET /api/admin/permissions/explain":      {Summary: "Explain an access decision (root only)", Response: permissionExplainDTO{}},
+	"GET /api/admin/activity":                 {Summary: "Usage activity over a trailing window (?range=30d)", Response: activityDTO{}},
+	"GET /api/admin/connections/stale":        {Summary: "Connections with no session in a window (?unused_for=90d&format=csv)", Response: staleConnectionsDTO{}},
+	"GET /api/admin/sessions":                 {Summary: "Upstream sessions open on this instance with their traffic (?format=csv exports, ?columns= picks fields)", Response: []activeSessionDTO{}},
+	"GET /api/admin/sessions/by-user":         {Summary: "Sessions open on this instance per user, busiest first, with each user's cap", Response: []userSessionCountDTO{}},
+	"GET /api/admin/schema/status":            {Summary: "Pending schema migrations and drift from the models; changes nothing", Response: store.SchemaReport{}},
+	"GET /api/admin/credential-bindings":      {Summary: "Connections whose credential references would fail at launch (?status=&owner=&protocol=)", Response: service.CredentialBindingReport{}},
+	"GET /api/admin/settings":                 {Summary: "Admin-editable system settings with defaults; secret values are omitted", Response: settingsDTO{}},
+	"PUT /api/admin/settings":                 {Summary: "Validate and write a map of system settings", Request: map[string]any{}, Response: settingsDTO{}},
+	"GET /api/admin/drivers/{id}/settings":    {Summary: "A protocol driver's settings form and current values", Response: service.DriverSettingsView{}},
+	"PUT /api/admin/drivers/{id}/settings":    {Summary: "Validate and replace a protocol driver's settings; running sessions keep theirs", Request: map[string]any{}, Response: service.DriverSettingsView{}},
+	"GET /api/admin/collation":                {Summary: "Name collation locale", Response: collationDTO{}},
+	"PUT /api/admin/collation":                {Summary: "Change the name collation locale and rewrite sort keys", Request: collationRequest{}, Response: collationDTO{}},
+	"POST /api/admin/collation/backfill":      {Summary: "Rewrite stale name sort keys", Response: collationDTO{}},
+	"GET /api/admin/approval-exempt-root":     {Summary: "Whether root skips credential approval", Response: approvalExemptRootDTO{}},
+	"PUT /api/admin/approval-exempt-root":     {Summary: "Exempt root from credential approval (root only)", Request: approvalExemptRootDTO{}, Response: approvalExemptRootDTO{}},
+	"GET /api/admin/credential-read-quota":    {Summary: "Credential read quota", Response: service.CredentialReadQuota{}},
+	"PUT /api/admin/credential-read-quota":    {Summary: "Update the credential read quota", Request: service.CredentialReadQuota{}, Response: service.CredentialReadQuota{}},
+	"GET /api/admin/read-only":                {Summary: "Read-only maintenance mode", Response: readOnlyDTO{}},
+	"POST /api/admin/read-only":               {Summary: "Switch read-only maintenance mode", Request: readOnlyRequest{}, Response: readOnlyDTO{}},
+	"GET /api/admin/hygiene":                  {Summary: "Last orphaned-grant sweep", Response: hygieneDTO{}},
+	"GET /api/admin/email":                    {Summary: "Email delivery status", Response: okDTO{}},
+	"GET /api/admin/invitations":              {Summary: "List invitations", Response: []models.InvitationSummary{}},
+	"POST /api/admin/invitations":             {Summary: "Invite a user", Request: createInviteRequest{}, Response: inviteResponse{}, Status: http.StatusCreated},
+	"DELETE /api/admin/invitations/{id}":      {Summary: "Revoke an invitation", Response: okDTO{}},
+	"POST /api/admin/invitations/{id}/revoke": {Summary: "Revoke an invitation", Response: okDTO{}},
+	"POST /api/admin/invitations/{id}/resend": {Summary: "Resend an invitation with a new link", Response: inviteResponse{}},
+	"GET /api/admin/webhooks":                 {Summary: "List webhooks", Response: []models.WebhookSummary{}},
+	"POST /api/admin/webhooks":                {Summary: "Create a webhook", Request: service.WebhookInput{}, Response: webhookCreateResponse{}, Status: http.StatusCreated},
+	"PUT /api/admin/webhooks/{id}":            {Summary: "Update a webhook", Request: service.WebhookInput{}, Response: models.WebhookSummary{}},
+	"DELETE /api/admin/webhooks/{id}":         {Summary: "Delete a webhook", Response: okDTO{}},
+	"GET /api/admin/webhooks/{id}/deliveries": {Summary: "Recent delivery attempts, newest first", Response: []models.WebhookDelivery{}},
+	"POST /api/admin/webhooks/{id}/test":      {Summary: "Send a test delivery", Response: models.WebhookDelivery{}},
+	"GET /api/admin/protocols":                {Summary: "List protocols with availability", Response: protocolListDTO{}},
+	"PUT /api/admin/protocols/{name}":         {Summary: "Set protocol availability", Request: protocolAvailabilityRequest{}, Status: http.StatusNoContent},
+	"GET /api/admin/market":                   {Summary: "List marketplace plugins", Response: marketListDTO{}},
+	"POST /api/admin/market/{name}/install":   {Summary: "Install or upgrade a plugin", Request: marketInstallRequest{}, Response: map[string]any{}},
+	"DELETE /api/admin/market/{name}":         {Summary: "Uninstall a plugin", Response: map[string]any{}},
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// passwordChangeRequiredCode tells the client to show only the password form.
+const passwordChangeRequiredCode = "password_change_required"
+
+var errPasswordChangeRequired = fmt.Errorf("%w: change your password to continue", plugin.ErrForbidden)
+
+type passwordPolicyDTO struct {
+	MinLength        int  `json:"minLength"`
+	RequireUpper     bool `json:"requireUpper"`
+	RequireLower     bool `json:"requireLower"`
+	RequireDigit     bool `json:"requireDigit"`
+	RequireSymbol    bool `json:"requireSymbol"`
+	DisallowIdentity bool `json:"disallowIdentity"`
+	CheckBreached    bool `json:"checkBreached"`
+}
+
+// handleGetPasswordPolicy is public so sign-up forms for invitations can show
+// the requirements before the invitee has a session.
+func (s *Server) handleGetPasswordPolicy(w http.ResponseWriter, _ *http.Request) {
+	p := s.deps.PasswordPolicy.Policy()
+	writeJSON(w, http.StatusOK, passwordPolicyDTO{
+		MinLength: p.MinLength, RequireUpper: p.RequireUpper, RequireLower: p.RequireLower,
+		RequireDigit: p.RequireDigit, RequireSymbol: p.RequireSymbol, DisallowIdentity: p.DisallowIdentity,
+		CheckBreached: p.CheckBreached && s.deps.PasswordPolicy.BreachedCheckAvailable(),
+	})
+}
+
+// passwordChangeExempt lists what an account flagged for a forced password
+// change may still call.
+func passwordChangeExempt(r *http.Request) bool {
+	switch r.Method + " " + r.URL.Path {
+	case "GET /api/auth/me", "POST /api/auth/me/password", "POST /api/auth/logout":
+		return true
+	}
+	return false
+}
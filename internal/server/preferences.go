@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const preferencesUpdateEvent = "account.preferences.update"
+
+func (s *Server) handleGetPreferences(w http.ResponseWriter, r *http.Request) {
+	user, _ := userFrom(r.Context())
+	prefs, err := s.deps.Preferences.Get(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	w.Header().Set("ETag", prefs.ETag)
+	writeJSON(w, http.StatusOK, prefs)
+}
+
+// handleUpdatePreferences merges the body into the caller's preferences. An
+// If-Match header makes the write conditional on the version the client last
+// saw, so two tabs cannot silently overwrite each other.
+func (s *Server) handleUpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	var patch map[string]json.RawMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, service.MaxPreferencesBytes)).Decode(&patch); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	prefs, err := s.deps.Preferences.Update(ctx, user.ID, patch, r.Header.Get("If-Match"))
+	if err != nil {
+		s.auditAccountEvent(ctx, user, preferencesUpdateEvent, models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditAccountEvent(ctx, user, preferencesUpdateEvent, models.AuditAllowed, nil)
+	w.Header().Set("ETag", prefs.ETag)
+	writeJSON(w, http.StatusOK, prefs)
+}
@@ -0,0 +1,85 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket/wsjson"
+)
+
+type preferencesResp struct {
+	Values map[string]any `json:"values"`
+	ETag   string         `json:"etag"`
+}
+
+func TestUserPreferences(t *testing.T) {
+	h := newHarness(t)
+
+	resp := h.do(t, http.MethodGet, "/api/me/preferences", "op", nil)
+	if resp.Status != http.StatusOK || resp.Header.Get("ETag") == "" {
+		t.Fatalf("get = %d etag=%q", resp.Status, resp.Header.Get("ETag"))
+	}
+	emptyTag := resp.Header.Get("ETag")
+
+	c, err := h.dialWS(t, "op", "/api/me/events")
+	if err != nil {
+		t.Fatalf("dial events: %v", err)
+	}
+	defer c.CloseNow()
+
+	put := func(body, ifMatch string) apiResp {
+		req, _ := http.NewRequest(http.MethodPut, h.ts.URL+"/api/me/preferences", strings.NewReader(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		return h.doReq(t, req, "op")
+	}
+	resp = put(`{"theme":"dark","terminalFontSize":14,"futureKey":{"a":1}}`, emptyTag)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("put = %d %s", resp.Status, resp.Body)
+	}
+	var prefs preferencesResp
+	_ = json.Unmarshal(resp.Body, &prefs)
+	if prefs.Values["theme"] != "dark" || prefs.Values["futureKey"] == nil || prefs.ETag == emptyTag || resp.Header.Get("ETag") != prefs.ETag {
+		t.Fatalf("put result = %+v", prefs)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var ev struct {
+		Type        string          `json:"type"`
+		Preferences preferencesResp `json:"preferences"`
+	}
+	if err := wsjson.Read(ctx, c, &ev); err != nil || ev.Type != "preferences" || ev.Preferences.ETag != prefs.ETag {
+		t.Fatalf("event = %+v, %v", ev, err)
+	}
+
+	if resp := put(`{"theme":"light"}`, emptyTag); resp.Status != http.StatusPreconditionFailed {
+		t.Fatalf("stale put = %d, want 412", resp.Status)
+	}
+	resp = put(`{"theme":null,"sftpViewMode":"grid"}`, prefs.ETag)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("merge put = %d %s", resp.Status, resp.Body)
+	}
+	prefs = preferencesResp{}
+	_ = json.Unmarshal(resp.Body, &prefs)
+	if _, ok := prefs.Values["theme"]; ok || prefs.Values["sftpViewMode"] != "grid" || prefs.Values["terminalFontSize"] != float64(14) {
+		t.Fatalf("merged = %+v", prefs.Values)
+	}
+
+	if resp := put(`{"theme":"purple","terminalFontSize":400}`, ""); resp.Status != http.StatusBadRequest || !strings.Contains(string(resp.Body), "terminalFontSize") {
+		t.Fatalf("invalid put = %d %s", resp.Status, resp.Body)
+	}
+	if resp := put(`{"blob":"`+strings.Repeat("x", 40<<10)+`"}`, ""); resp.Status != http.StatusBadRequest {
+		t.Fatalf("oversized put = %d, want 400", resp.Status)
+	}
+
+	resp = h.do(t, http.MethodGet, "/api/me/preferences", "op2", nil)
+	if resp.Status != http.StatusOK || resp.Header.Get("ETag") != emptyTag {
+		t.Fatalf("other user's preferences = %d %s", resp.Status, resp.Body)
+	}
+}
@@ -99,6 +99,10 @@ func (s *Server) handleAdminListProtocols(w http.ResponseWriter, r *http.Request
 	writeJSON(w, http.StatusOK, protocolListDTO{Dir: s.deps.PluginsDir, Protocols: out})
 }
 
+type protocolAvailabilityRequest struct {
+	Availability models.ProtocolAvailability `json:"availability"`
+}
+
 func (s *Server) handleAdminSetProtocolAvailability(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	actor, _ := userFrom(ctx)
@@ -108,9 +112,7 @@ func (s *Server) handleAdminSetProtocolAvailability(w http.ResponseWriter, r *ht
 		return
 	}
 
-	var req struct {
-		Availability models.ProtocolAvailability `json:"availability"`
-	}
+	var req protocolAvailabilityRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
 		return
@@ -79,8 +79,14 @@ func TestProtocolDisabledHiddenAndBlocked(t *testing.T) {
 		}
 	}
 
+	// Existing connections stay listed but are flagged so the UI can explain why.
+	resp := h.do(t, http.MethodGet, "/api/connections", "op", nil)
+	if !strings.Contains(string(resp.Body), `"id":"c-op"`) || !strings.Contains(string(resp.Body), `"protocolBlocked":true`) {
+		t.Fatalf("disabled-protocol connection should be listed and flagged: %s", resp.Body)
+	}
+
 	// Connecting an existing connection of the disabled protocol fails clearly.
-	resp := h.do(t, http.MethodGet, "/api/connections/c-op/x/tester.list", "op", nil)
+	resp = h.do(t, http.MethodGet, "/api/connections/c-op/x/tester.list", "op", nil)
 	if resp.Status != http.StatusForbidden {
 		t.Fatalf("connect disabled: status %d (%s)", resp.Status, resp.Body)
 	}
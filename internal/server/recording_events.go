@@ -0,0 +1,127 @@
+package server
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/recording"
+)
+
+const recExportEvent = "recording.export"
+
+// recordingEventLine is one NDJSON line of a recording export. Every line
+// carries the recording's identifiers so a SIEM can ingest lines on their own.
+type recordingEventLine struct {
+	Time           string  `json:"time"`
+	Offset         float64 `json:"offset"`
+	Type           string  `json:"type"`
+	Bytes          int     `json:"bytes"`
+	Payload        *string `json:"payload,omitempty"`
+	RecordingID    string  `json:"recordingId"`
+	ConnectionID   string  `json:"connectionId"`
+	ConnectionName string  `json:"connectionName,omitempty"`
+	Protocol       string  `json:"protocol"`
+	UserID         string  `json:"userId"`
+	Username       string  `json:"username,omitempty"`
+}
+
+var castEventTypes = map[string]string{"o": "output", "i": "input", "r": "resize", "m": "marker"}
+
+// handleRecordingEvents converts a terminal recording to NDJSON, one event per
+// line with an absolute timestamp. Payload text is left out unless
+// ?include_payload=true. The cast is decoded as it is written, so memory use
+// does not grow with the recording.
+func (s *Server) handleRecordingEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	id := chi.URLParam(r, "id")
+	rc, rec, err := s.openReplay(ctx, user, id)
+	if err != nil {
+		result := models.AuditError
+		if statusFor(err) == http.StatusForbidden {
+			result = models.AuditDenied
+		}
+		if rec.ID == "" {
+			rec.ID = id
+		}
+		s.auditRecordingEvent(ctx, user, rec, recExportEvent, result, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	defer func() { _ = rc.Close() }()
+	cast, err := recording.NewCastReader(rc)
+	if err != nil {
+		s.auditRecordingEvent(ctx, user, rec, recExportEvent, models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	defer func() { _ = cast.Close() }()
+	s.auditRecordingEvent(ctx, user, rec, recExportEvent, models.AuditAllowed, nil)
+
+	start := rec.StartedAt
+	if cast.Header.Timestamp > 0 {
+		start = time.Unix(cast.Header.Timestamp, 0)
+	}
+	withPayload := r.URL.Query().Get("include_payload") == "true"
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+rec.ID+`.events.ndjson"`)
+	w.Header().Set("Vary", "Accept-Encoding")
+	var out io.Writer = w
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		defer func() { _ = zw.Close() }()
+		out = zw
+	}
+	enc := json.NewEncoder(out)
+	for {
+		ev, err := cast.Next()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			s.deps.Logger.Warn("recording export", "recording", rec.ID, "err", err)
+			return
+		}
+		line := recordingEventLine{
+			Time:        start.Add(time.Duration(ev.Time * float64(time.Second))).UTC().Format(time.RFC3339Nano),
+			Offset:      ev.Time,
+			Type:        castEventTypes[ev.Code],
+			Bytes:       len(ev.Data),
+			RecordingID: rec.ID, ConnectionID: rec.ConnectionID, ConnectionName: rec.ConnectionName,
+			Protocol: rec.Protocol, UserID: rec.UserID, Username: rec.Username,
+		}
+		if line.Type == "" {
+			line.Type = ev.Code
+		}
+		if withPayload {
+			line.Payload = &ev.Data
+		}
+		if err := enc.Encode(line); err != nil {
+			return
+		}
+	}
+}
+
+// acceptsGzip reports whether the client listed gzip in Accept-Encoding
+// without refusing it via q=0.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
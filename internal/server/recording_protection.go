@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/audit"
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	recProtectEvent   = "recording.protect"
+	recUnprotectEvent = "recording.unprotect"
+
+	maxProtectionReasonLen = 500
+)
+
+type recordingProtectionDTO struct {
+	ProtectedBy string     `json:"protectedBy"`
+	ProtectedAt *time.Time `json:"protectedAt,omitempty"`
+}
+
+type recordingProtectRequest struct {
+	Reason string `json:"reason"`
+}
+
+type recordingProtectMatchingRequest struct {
+	User       string    `json:"user"`
+	Connection string    `json:"connection"`
+	Protocol   string    `json:"protocol"`
+	Since      time.Time `json:"since"`
+	Until      time.Time `json:"until"`
+	Reason     string    `json:"reason"`
+}
+
+type recordingProtectMatchingDTO struct {
+	Protected int `json:"protected"`
+}
+
+// protectionReason reads the request's reason; lifting a hold must say why.
+func protectionReason(r *http.Request, required bool) (string, error) {
+	var req recordingProtectRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return "", plugin.ErrInvalidInput
+		}
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if len(reason) > maxProtectionReasonLen || (required && reason == "") {
+		return "", fmt.Errorf("%w: reason must be 1-%d characters", plugin.ErrInvalidInput, maxProtectionReasonLen)
+	}
+	return reason, nil
+}
+
+// handleSetRecordingProtection places a legal hold on a recording or lifts it.
+func (s *Server) handleSetRecordingProtection(protect bool) http.HandlerFunc {
+	event := recUnprotectEvent
+	if protect {
+		event = recProtectEvent
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user, _ := userFrom(ctx)
+		id := chi.URLParam(r, "id")
+		reason, err := protectionReason(r, !protect)
+		if err != nil {
+			writeError(w, s.deps.Logger, err)
+			return
+		}
+		rec, err := s.deps.Recordings.SetProtected(ctx, user.ID, id, protect)
+		if rec.ID == "" {
+			rec.ID = id
+		}
+		result := models.AuditAllowed
+		if err != nil {
+			result = models.AuditError
+		}
+		s.deps.Audit.Record(ctx, audit.Event{
+			User: user, Event: event, ConnectionID: rec.ConnectionID, RouteID: event,
+			Risk: string(plugin.RiskWrite), Result: result, Err: err,
+			Params: map[string]string{"recording": rec.ID, "reason": reason},
+		})
+		if err != nil {
+			writeError(w, s.deps.Logger, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, toRecordingDTO(rec))
+	}
+}
+
+// handleAdminProtectRecordings places a legal hold on every finished
+// recording matching a filter.
+func (s *Server) handleAdminProtectRecordings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	var req recordingProtectMatchingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	n, err := s.deps.Recordings.ProtectMatching(ctx, user.ID, store.RecordingFilter{
+		UserID: req.User, ConnectionID: req.Connection, Protocol: req.Protocol, Since: req.Since, Until: req.Until,
+	})
+	params := map[string]string{"reason": strings.TrimSpace(req.Reason), "protected": strconv.Itoa(n)}
+	for k, v := range map[string]string{"user": req.User, "connection": req.Connection, "protocol": req.Protocol} {
+		if v != "" {
+			params[k] = v
+		}
+	}
+	if !req.Since.IsZero() {
+		params["since"] = req.Since.UTC().Format(time.RFC3339)
+	}
+	if !req.Until.IsZero() {
+		params["until"] = req.Until.UTC().Format(time.RFC3339)
+	}
+	result := models.AuditAllowed
+	if err != nil {
+		result = models.AuditError
+	}
+	s.deps.Audit.Record(ctx, audit.Event{
+		User: user, Event: recProtectEvent, ConnectionID: req.Connection, RouteID: recProtectEvent,
+		Risk: string(plugin.RiskWrite), Result: result, Params: params, Err: err,
+	})
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, recordingProtectMatchingDTO{Protected: n})
+}
@@ -0,0 +1,364 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/recording"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	recReplayEvent = "recording.replay"
+
+	maxReplaysPerUser = 3
+	minReplaySpeed    = 0.5
+	maxReplaySpeed    = 8
+)
+
+// replayControl is a client message on a replay socket: "speed" (Speed),
+// "pause", "resume" or "seek" (Offset, seconds into the recording).
+type replayControl struct {
+	Type   string  `json:"type"`
+	Speed  float64 `json:"speed,omitempty"`
+	Offset float64 `json:"offset,omitempty"`
+}
+
+// replayMessage is a server message on a replay socket: the "header", each
+// "event", a "seek" (with Reset when playback restarts and the client should
+// clear its terminal), the "end" of the recording and "error" for a rejected
+// control.
+type replayMessage struct {
+	Type       string               `json:"type"`
+	Width      int                  `json:"width,omitempty"`
+	Height     int                  `json:"height,omitempty"`
+	DurationMS int64                `json:"durationMs,omitempty"`
+	Event      *recording.CastEvent `json:"event,omitempty"`
+	Offset     float64              `json:"offset,omitempty"`
+	Reset      bool                 `json:"reset,omitempty"`
+	Error      string               `json:"error,omitempty"`
+}
+
+// replaySlots counts each user's running replays.
+type replaySlots struct {
+	mu     sync.Mutex
+	byUser map[string]int
+}
+
+func newReplaySlots() *replaySlots {
+	return &replaySlots{byUser: map[string]int{}}
+}
+
+func (r *replaySlots) acquire(userID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.byUser[userID] >= maxReplaysPerUser {
+		return false
+	}
+	r.byUser[userID]++
+	return true
+}
+
+func (r *replaySlots) release(userID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.byUser[userID]--; r.byUser[userID] <= 0 {
+		delete(r.byUser, userID)
+	}
+}
+
+// handleRecordingReplay streams a terminal recording over a WebSocket, pacing
+// events by their timestamps so the client never holds the whole cast. With
+// ?skip_idle=true, idle gaps longer than the chapter threshold play as
+// recording.SkipIdlePause and all offsets are on that shortened timeline.
+func (s *Server) handleRecordingReplay(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	id := chi.URLParam(r, "id")
+	rc, rec, err := s.openReplay(ctx, user, id)
+	if err != nil {
+		result := models.AuditError
+		if statusFor(err) == http.StatusForbidden {
+			result = models.AuditDenied
+		}
+		if rec.ID == "" {
+			rec.ID = id
+		}
+		s.auditRecordingEvent(ctx, user, rec, recReplayEvent, result, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	if !s.replays.acquire(user.ID) {
+		_ = rc.Close()
+		writeError(w, s.deps.Logger, fmt.Errorf("%w: at most %d recording replays may run at once", plugin.ErrUnavailable, maxReplaysPerUser))
+		return
+	}
+	defer s.replays.release(user.ID)
+
+	c, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		_ = rc.Close()
+		return // Accept already wrote the response
+	}
+	s.auditRecordingEvent(ctx, user, rec, recReplayEvent, models.AuditAllowed, nil)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	controls := make(chan replayControl)
+	go readReplayControls(ctx, c, controls, cancel)
+
+	p := &replayer{
+		conn: c, rec: rec, first: rc, controls: controls, speed: 1,
+		skipGap: s.skipIdleGap(r, rec),
+		reopen: func() (io.ReadCloser, error) {
+			rc, _, err := s.openReplay(ctx, user, id)
+			return rc, err
+		},
+	}
+	if err := p.run(ctx); err != nil && ctx.Err() == nil {
+		_ = c.Close(websocket.StatusInternalError, streamCloseReason(err))
+		return
+	}
+	_ = c.Close(websocket.StatusNormalClosure, "")
+}
+
+// openReplay applies the content access checks to a replay and rejects
+// recordings that have no terminal events.
+func (s *Server) openReplay(ctx context.Context, user models.User, id string) (io.ReadCloser, models.Recording, error) {
+	rc, rec, err := s.deps.Recordings.Content(ctx, user, id)
+	if err != nil {
+		return nil, rec, err
+	}
+	if rec.Format != string(plugin.FormatAsciicastV2) {
+		_ = rc.Close()
+		return nil, rec, fmt.Errorf("%w: only terminal recordings can be replayed", plugin.ErrInvalidInput)
+	}
+	return rc, rec, nil
+}
+
+// skipIdleGap is the idle gap replay compresses, or zero when the client did
+// not ask to skip idle time.
+func (s *Server) skipIdleGap(r *http.Request, rec models.Recording) float64 {
+	if r.URL.Query().Get("skip_idle") != "true" {
+		return 0
+	}
+	return s.deps.Recordings.ChapterGap(rec)
+}
+
+// readReplayControls forwards valid client controls and cancels the replay
+// when the client goes away.
+func readReplayControls(ctx context.Context, c *websocket.Conn, out chan<- replayControl, cancel context.CancelFunc) {
+	defer cancel()
+	for {
+		var ctl replayControl
+		if err := wsjson.Read(ctx, c, &ctl); err != nil {
+			return
+		}
+		if err := ctl.validate(); err != nil {
+			_ = wsjson.Write(ctx, c, replayMessage{Type: "error", Error: err.Error()})
+			continue
+		}
+		select {
+		case out <- ctl:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c replayControl) validate() error {
+	switch c.Type {
+	case "pause", "resume":
+		return nil
+	case "speed":
+		if c.Speed < minReplaySpeed || c.Speed > maxReplaySpeed {
+			return fmt.Errorf("speed must be between %gx and %gx", float64(minReplaySpeed), float64(maxReplaySpeed))
+		}
+		return nil
+	case "seek":
+		if c.Offset < 0 || math.IsNaN(c.Offset) {
+			return errors.New("seek offset must not be negative")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown control %q", c.Type)
+	}
+}
+
+// replayer paces one cast onto a socket. clock is the playback position in
+// recording seconds; events before target (a seek) are sent without delay.
+type replayer struct {
+	conn     *websocket.Conn
+	rec      models.Recording
+	first    io.ReadCloser
+	reopen   func() (io.ReadCloser, error)
+	controls <-chan replayControl
+	rc       io.ReadCloser
+	cast     *recording.CastReader
+	skipGap  float64
+	skipper  *recording.IdleSkipper
+	speed    float64
+	paused   bool
+	clock    float64
+	target   float64
+}
+
+func (p *replayer) run(ctx context.Context) error {
+	defer p.close()
+	if err := p.open(p.first); err != nil {
+		return err
+	}
+	if err := p.send(ctx, replayMessage{Type: "header", Width: p.cast.Header.Width, Height: p.cast.Header.Height, DurationMS: p.duration()}); err != nil {
+		return err
+	}
+
+	for {
+		ev, err := p.cast.Next()
+		if errors.Is(err, io.EOF) {
+			if err := p.send(ctx, replayMessage{Type: "end", Offset: p.clock}); err != nil {
+				return err
+			}
+			// Stay open after the end so the client can seek back.
+			ev = recording.CastEvent{Time: math.Inf(1)}
+		} else if err != nil {
+			return err
+		} else if p.skipper != nil {
+			ev.Time = p.skipper.Map(ev.Time)
+		}
+		for {
+			seek, ok, err := p.wait(ctx, ev.Time)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				break
+			}
+			p.target = seek
+			if seek >= p.clock && !math.IsInf(ev.Time, 1) {
+				// Forward: fast-forward from the pending event.
+				if err := p.send(ctx, replayMessage{Type: "seek", Offset: seek}); err != nil {
+					return err
+				}
+				p.clock = seek
+				continue
+			}
+			// Backward, or after the end: start the cast over on a reset terminal.
+			if err := p.send(ctx, replayMessage{Type: "seek", Offset: seek, Reset: true}); err != nil {
+				return err
+			}
+			p.close()
+			rc, err := p.reopen()
+			if err != nil {
+				return err
+			}
+			if err := p.open(rc); err != nil {
+				return err
+			}
+			p.clock = 0
+			ev = recording.CastEvent{}
+			break
+		}
+		if ev.Code == "" {
+			continue
+		}
+		p.clock = math.Max(p.clock, ev.Time)
+		if err := p.send(ctx, replayMessage{Type: "event", Event: &ev}); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *replayer) open(rc io.ReadCloser) error {
+	p.rc = rc
+	cast, err := recording.NewCastReader(rc)
+	if err != nil {
+		return err
+	}
+	p.cast = cast
+	if p.skipGap > 0 {
+		p.skipper = &recording.IdleSkipper{Gap: p.skipGap}
+	}
+	return nil
+}
+
+// duration is the playback length in milliseconds. Skipped idle time is
+// only known once the recording has been chaptered.
+func (p *replayer) duration() int64 {
+	if p.skipGap <= 0 || p.rec.ChapterGapMS <= 0 {
+		return p.rec.DurationMS
+	}
+	secs := recording.SkippedDuration(p.rec.Chapters, float64(p.rec.DurationMS)/1000, p.skipGap)
+	return int64(math.Round(secs * 1000))
+}
+
+func (p *replayer) close() {
+	if p.cast != nil {
+		_ = p.cast.Close()
+		p.cast = nil
+	}
+	if p.rc != nil {
+		_ = p.rc.Close()
+		p.rc = nil
+	}
+}
+
+// wait blocks until the event at due should play, applying speed and pause
+// controls meanwhile. It returns early with ok set when the client seeks.
+func (p *replayer) wait(ctx context.Context, due float64) (seek float64, ok bool, err error) {
+	for {
+		if due <= p.target || due <= p.clock {
+			return 0, false, nil
+		}
+		var fire <-chan time.Time
+		var timer *time.Timer
+		if !p.paused && !math.IsInf(due, 1) {
+			timer = time.NewTimer(time.Duration((due - p.clock) / p.speed * float64(time.Second)))
+			fire = timer.C
+		}
+		start := time.Now()
+		select {
+		case <-ctx.Done():
+			stopTimer(timer)
+			return 0, false, ctx.Err()
+		case <-fire:
+			p.clock = due
+			return 0, false, nil
+		case ctl := <-p.controls:
+			stopTimer(timer)
+			if !p.paused && timer != nil {
+				p.clock = math.Min(due, p.clock+time.Since(start).Seconds()*p.speed)
+			}
+			switch ctl.Type {
+			case "speed":
+				p.speed = ctl.Speed
+			case "pause":
+				p.paused = true
+			case "resume":
+				p.paused = false
+			case "seek":
+				return ctl.Offset, true, nil
+			}
+		}
+	}
+}
+
+func stopTimer(t *time.Timer) {
+	if t != nil {
+		t.Stop()
+	}
+}
+
+func (p *replayer) send(ctx context.Context, msg replayMessage) error {
+	return wsjson.Write(ctx, p.conn, msg)
+}
@@ -3,10 +3,14 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-chi/chi/v5"
 
@@ -20,8 +24,14 @@ import (
 const (
 	defaultChunkLimit = 8 << 20
 
-	recReadEvent   = "recording.read"
-	recDeleteEvent = "recording.delete"
+	recReadEvent       = "recording.read"
+	recTranscriptEvent = "recording.transcript.read"
+	recDeleteEvent     = "recording.delete"
+	recAnnotateEvent   = "recording.annotate"
+	recPauseEvent      = "recording.pause"
+	recResumeEvent     = "recording.resume"
+
+	maxAnnotationLen = 500
 )
 
 type recordingDTO struct {
@@ -34,30 +44,64 @@ type recordingDTO struct {
 	Class          string     `json:"class"`
 	Format         string     `json:"format"`
 	Authoritative  bool       `json:"authoritative"`
+	InputCaptured  bool       `json:"inputCaptured"`
 	Status         string     `json:"status"`
+	Partial        bool       `json:"partial,omitempty"`
+	Error          string     `json:"error,omitempty"`
 	Title          string     `json:"title,omitempty"`
 	StartedAt      time.Time  `json:"startedAt"`
 	EndedAt        *time.Time `json:"endedAt,omitempty"`
 	DurationMS     int64      `json:"durationMs"`
 	Size           int64      `json:"size"`
+	HasTranscript  bool       `json:"hasTranscript"`
+	// Matches are the transcript lines that matched a ?q= search.
+	Matches []recording.TranscriptMatch `json:"matches,omitempty"`
+	// Client is where the session was opened from; absent for older rows.
+	Client *models.ClientInfo `json:"client,omitempty"`
+	// Protected marks a legal hold; ProtectedBy is the admin who set it.
+	Protected   bool       `json:"protected"`
+	ProtectedBy string     `json:"protectedBy,omitempty"`
+	ProtectedAt *time.Time `json:"protectedAt,omitempty"`
+	// ArchivedAt is when the recording moved to archive storage.
+	ArchivedAt *time.Time `json:"archivedAt,omitempty"`
+	// ConnectionSnapshot and Chapters are only included in the
+	// single-recording response; Chapters is absent until chaptering runs.
+	ConnectionSnapshot *models.ConnectionSnapshot `json:"connectionSnapshot,omitempty"`
+	Chapters           []models.RecordingChapter  `json:"chapters,omitempty"`
 }
 
 func toRecordingDTO(r models.Recording) recordingDTO {
-	return recordingDTO{
+	out := recordingDTO{
 		ID: r.ID, UserID: r.UserID, Username: r.Username,
 		ConnectionID: r.ConnectionID, ConnectionName: r.ConnectionName, Protocol: r.Protocol,
-		Class: r.Class, Format: r.Format, Authoritative: r.Authoritative, Status: string(r.Status),
+		Class: r.Class, Format: r.Format, Authoritative: r.Authoritative, InputCaptured: r.InputCaptured, Status: string(r.Status),
 		Title: r.Title, StartedAt: r.StartedAt, EndedAt: r.EndedAt, DurationMS: r.DurationMS, Size: r.Size,
+		Partial: r.Partial, Error: r.Error, HasTranscript: r.TranscriptKey != "",
+		Protected: r.Protected, ProtectedBy: r.ProtectedBy, ProtectedAt: r.ProtectedAt, ArchivedAt: r.ArchivedAt,
+	}
+	if r.Client != (models.ClientInfo{}) {
+		out.Client = &r.Client
 	}
+	return out
 }
 
 // recordingFilter builds a store filter from query params (admin-only fields are
 // applied here; the service re-scopes non-admins to what they may see).
-func recordingFilter(r *http.Request) store.RecordingFilter {
+func recordingFilter(r *http.Request) (store.RecordingFilter, error) {
 	q := r.URL.Query()
 	f := store.RecordingFilter{
 		UserID: q.Get("user"), ConnectionID: q.Get("connection"), Protocol: q.Get("protocol"),
 		Class: q.Get("class"), Format: q.Get("format"), Status: q.Get("status"),
+		Search: strings.TrimSpace(q.Get("q")), Sort: q.Get("sort"),
+	}
+	// ?format=csv asks for a CSV export; no recording is stored as CSV.
+	if f.Format == "csv" {
+		f.Format = ""
+	}
+	switch f.Sort {
+	case "", store.RecordingSortDuration, store.RecordingSortConnection:
+	default:
+		return store.RecordingFilter{}, fmt.Errorf("%w: unknown sort %q", plugin.ErrInvalidInput, f.Sort)
 	}
 	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 {
 		f.Limit = v
@@ -68,7 +112,7 @@ func recordingFilter(r *http.Request) store.RecordingFilter {
 	if t, err := time.Parse(time.RFC3339, q.Get("until")); err == nil {
 		f.Until = t
 	}
-	return f
+	return f, nil
 }
 
 func (s *Server) auditRecordingEvent(ctx context.Context, user models.User, rec models.Recording, event string, result models.AuditResult, err error) {
@@ -79,19 +123,48 @@ func (s *Server) auditRecordingEvent(ctx context.Context, user models.User, rec
 }
 
 func (s *Server) handleListRecordings(w http.ResponseWriter, r *http.Request) {
-	user, _ := userFrom(r.Context())
-	recs, err := s.deps.Recordings.List(r.Context(), user, recordingFilter(r))
+	f, err := recordingFilter(r)
 	if err != nil {
 		writeError(w, s.deps.Logger, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, recordingDTOs(recs))
+	s.listRecordings(w, r, f)
 }
 
 func (s *Server) handleListConnectionRecordings(w http.ResponseWriter, r *http.Request) {
-	user, _ := userFrom(r.Context())
-	f := recordingFilter(r)
+	f, err := recordingFilter(r)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
 	f.ConnectionID = chi.URLParam(r, "id")
+	s.listRecordings(w, r, f)
+}
+
+// listRecordings serves a recording list; a ?q= search also matches stored
+// transcripts and returns the matching lines. ?format=csv or Accept: text/csv
+// downloads the same list, with times in the caller's time zone or ?tz.
+func (s *Server) listRecordings(w http.ResponseWriter, r *http.Request, f store.RecordingFilter) {
+	user, _ := userFrom(r.Context())
+	if wantsCSV(r) {
+		s.writeRecordingsCSV(w, r, user, f)
+		return
+	}
+	if f.Search != "" {
+		results, err := s.deps.Recordings.Search(r.Context(), user, f)
+		if err != nil {
+			writeError(w, s.deps.Logger, err)
+			return
+		}
+		out := make([]recordingDTO, 0, len(results))
+		for _, res := range results {
+			dto := toRecordingDTO(res.Recording)
+			dto.Matches = res.Matches
+			out = append(out, dto)
+		}
+		writeJSON(w, http.StatusOK, out)
+		return
+	}
 	recs, err := s.deps.Recordings.List(r.Context(), user, f)
 	if err != nil {
 		writeError(w, s.deps.Logger, err)
@@ -100,6 +173,82 @@ func (s *Server) handleListConnectionRecordings(w http.ResponseWriter, r *http.R
 	writeJSON(w, http.StatusOK, recordingDTOs(recs))
 }
 
+// recordingColumns writes timestamps in loc.
+func recordingColumns(loc *time.Location) []csvColumn[models.Recording] {
+	return []csvColumn[models.Recording]{
+		{"id", func(r models.Recording) string { return r.ID }},
+		{"connection_id", func(r models.Recording) string { return r.ConnectionID }},
+		{"connection_name", func(r models.Recording) string { return csvCell(r.ConnectionName) }},
+		{"protocol", func(r models.Recording) string { return r.Protocol }},
+		{"user_id", func(r models.Recording) string { return r.UserID }},
+		{"username", func(r models.Recording) string { return csvCell(r.Username) }},
+		{"status", func(r models.Recording) string { return string(r.Status) }},
+		{"format", func(r models.Recording) string { return r.Format }},
+		{"started_at", func(r models.Recording) string { return csvTime(r.StartedAt, loc) }},
+		{"ended_at", func(r models.Recording) string {
+			if r.EndedAt == nil {
+				return ""
+			}
+			return csvTime(*r.EndedAt, loc)
+		}},
+		{"duration_seconds", func(r models.Recording) string { return csvSeconds(time.Duration(r.DurationMS) * time.Millisecond) }},
+		{"bytes", func(r models.Recording) string { return strconv.FormatInt(r.Size, 10) }},
+		{"protected", func(r models.Recording) string { return strconv.FormatBool(r.Protected) }},
+	}
+}
+
+// writeRecordingsCSV streams the recordings listRecordings would return. A
+// plain listing is read a page at a time; a ?q= search is already bounded by
+// the transcript scan, so its results are written as they are.
+func (s *Server) writeRecordingsCSV(w http.ResponseWriter, r *http.Request, user models.User, f store.RecordingFilter) {
+	loc, err := s.exportLocation(r, user)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	cols, err := selectCSVColumns(recordingColumns(loc), r.URL.Query().Get("columns"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	ctx := r.Context()
+	if f.Search != "" {
+		results, err := s.deps.Recordings.Search(ctx, user, f)
+		if err != nil {
+			writeError(w, s.deps.Logger, err)
+			return
+		}
+		export := newCSVExport(w, "recordings.csv", cols)
+		for _, res := range results {
+			if err := export.row(res.Recording); err != nil {
+				break
+			}
+		}
+		_ = export.flush()
+		return
+	}
+	// The header goes out with the first page, so a failing first query
+	// still gets a JSON error.
+	var export *csvExport[models.Recording]
+	err = s.deps.Recordings.Each(ctx, user, f, func(rec models.Recording) error {
+		if export == nil {
+			export = newCSVExport(w, "recordings.csv", cols)
+		}
+		return export.row(rec)
+	})
+	switch {
+	case export != nil:
+		if err != nil {
+			s.deps.Logger.Warn("recordings csv export", "err", err)
+		}
+		_ = export.flush()
+	case err != nil:
+		writeError(w, s.deps.Logger, err)
+	default:
+		_ = newCSVExport(w, "recordings.csv", cols).flush()
+	}
+}
+
 func recordingDTOs(recs []models.Recording) []recordingDTO {
 	out := make([]recordingDTO, 0, len(recs))
 	for _, r := range recs {
@@ -115,7 +264,129 @@ func (s *Server) handleGetRecording(w http.ResponseWriter, r *http.Request) {
 		writeError(w, s.deps.Logger, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, toRecordingDTO(rec))
+	dto := toRecordingDTO(rec)
+	dto.ConnectionSnapshot = rec.ConnectionSnapshot
+	if rec.ChapterGapMS > 0 {
+		dto.Chapters = rec.Chapters
+	}
+	writeJSON(w, http.StatusOK, dto)
+}
+
+func (s *Server) handleRecordingTranscript(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	id := chi.URLParam(r, "id")
+	t, err := s.deps.Recordings.Transcript(ctx, user, id)
+	rec := models.Recording{ID: id}
+	if stored, getErr := s.deps.Store.Recordings.Get(ctx, id); getErr == nil {
+		rec = stored
+	}
+	if err != nil {
+		result := models.AuditError
+		if statusFor(err) == http.StatusForbidden {
+			result = models.AuditDenied
+		}
+		s.auditRecordingEvent(ctx, user, rec, recTranscriptEvent, result, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditRecordingEvent(ctx, user, rec, recTranscriptEvent, models.AuditAllowed, nil)
+	writeJSON(w, http.StatusOK, t)
+}
+
+type annotationRequest struct {
+	Text   string   `json:"text"`
+	Offset *float64 `json:"offset"` // post-hoc only; live annotations use the current offset
+}
+
+func (s *Server) handleListRecordingAnnotations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	rec, err := s.deps.Recordings.Get(ctx, user, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	notes := rec.Annotations
+	if live, ok := s.deps.Recording.LiveAnnotations(rec.ID); ok {
+		notes = append(notes, live...)
+	}
+	if notes == nil {
+		notes = []models.RecordingAnnotation{}
+	}
+	writeJSON(w, http.StatusOK, notes)
+}
+
+// handleAnnotateRecording pins a note to a recording. While the recording is
+// live only the user driving the stream may annotate, and the note is also
+// written into the stream; afterwards anyone who may view it can add notes to
+// the metadata.
+func (s *Server) handleAnnotateRecording(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	id := chi.URLParam(r, "id")
+	var req annotationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	req.Text = strings.TrimSpace(req.Text)
+	if req.Text == "" || utf8.RuneCountInString(req.Text) > maxAnnotationLen {
+		writeError(w, s.deps.Logger, fmt.Errorf("%w: annotation text must be 1-%d characters", plugin.ErrInvalidInput, maxAnnotationLen))
+		return
+	}
+	rec := models.Recording{ID: id}
+	if stored, err := s.deps.Store.Recordings.Get(ctx, id); err == nil {
+		rec = stored
+	}
+	note, err := s.deps.Recording.Annotate(id, user.ID, req.Text)
+	if errors.Is(err, plugin.ErrNotFound) {
+		offset := 0.0
+		if req.Offset != nil {
+			offset = *req.Offset
+		}
+		note, err = s.deps.Recordings.Annotate(ctx, user, id, offset, req.Text)
+	}
+	if err != nil {
+		result := models.AuditError
+		if statusFor(err) == http.StatusForbidden {
+			result = models.AuditDenied
+		}
+		s.auditRecordingEvent(ctx, user, rec, recAnnotateEvent, result, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditRecordingEvent(ctx, user, rec, recAnnotateEvent, models.AuditAllowed, nil)
+	writeJSON(w, http.StatusCreated, note)
+}
+
+// handleRecordingCapture pauses or resumes capture of the caller's own live
+// recording. The stream itself is unaffected; markers bound the gap.
+func (s *Server) handleRecordingCapture(paused bool) http.HandlerFunc {
+	event := recResumeEvent
+	if paused {
+		event = recPauseEvent
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user, _ := userFrom(ctx)
+		id := chi.URLParam(r, "id")
+		rec := models.Recording{ID: id}
+		if stored, err := s.deps.Store.Recordings.Get(ctx, id); err == nil {
+			rec = stored
+		}
+		if err := s.deps.Recording.SetPaused(id, user.ID, paused); err != nil {
+			result := models.AuditError
+			if statusFor(err) == http.StatusForbidden {
+				result = models.AuditDenied
+			}
+			s.auditRecordingEvent(ctx, user, rec, event, result, err)
+			writeError(w, s.deps.Logger, err)
+			return
+		}
+		s.auditRecordingEvent(ctx, user, rec, event, models.AuditAllowed, nil)
+		writeJSON(w, http.StatusOK, map[string]bool{"paused": paused})
+	}
 }
 
 func (s *Server) handleRecordingContent(w http.ResponseWriter, r *http.Request) {
@@ -183,7 +454,11 @@ func (s *Server) handleDeleteRecording(w http.ResponseWriter, r *http.Request) {
 	user, _ := userFrom(ctx)
 	rec, err := s.deps.Recordings.Delete(ctx, user, chi.URLParam(r, "id"))
 	if err != nil {
-		s.auditRecordingEvent(ctx, user, models.Recording{ID: chi.URLParam(r, "id")}, recDeleteEvent, models.AuditDenied, err)
+		result := models.AuditDenied
+		if statusFor(err) == http.StatusConflict {
+			result = models.AuditError
+		}
+		s.auditRecordingEvent(ctx, user, models.Recording{ID: chi.URLParam(r, "id")}, recDeleteEvent, result, err)
 		writeError(w, s.deps.Logger, err)
 		return
 	}
@@ -213,7 +488,8 @@ func (s *Server) streamInfoFor(r *http.Request, user models.User, conn models.Co
 	stream, _ := manifest.StreamByRoute(routeID)
 	return recording.StreamInfo{
 		User: user, Connection: conn, Manifest: manifest, Route: route,
-		StreamID: stream.ID, Params: params, RemoteAddr: r.RemoteAddr,
+		StreamID: stream.ID, Params: params, RemoteAddr: clientIP(r), Client: audit.ClientFrom(r.Context()),
+		ConnectionSnapshot: s.connectionSnapshot(user.ID, conn.ID),
 	}, route, true
 }
 
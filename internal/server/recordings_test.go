@@ -1,14 +1,22 @@
 package server_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
 
 	"github.com/charlesng35/shellcn/internal/models"
 	"github.com/charlesng35/shellcn/internal/store"
@@ -63,6 +71,21 @@ func recordingIDs(t *testing.T, body []byte) []string {
 	return out
 }
 
+func TestRecordingListSearchAndSort(t *testing.T) {
+	h := newHarness(t)
+	_, recID := recordTerminalSession(t, h, "op")
+
+	if ids := recordingIDs(t, h.do(t, http.MethodGet, "/api/recordings?q=OP&sort=duration", "op", nil).Body); len(ids) != 1 || ids[0] != recID {
+		t.Fatalf("search by name: want [%s], got %v", recID, ids)
+	}
+	if ids := recordingIDs(t, h.do(t, http.MethodGet, "/api/recordings?q=billing", "op", nil).Body); len(ids) != 0 {
+		t.Fatalf("non-matching search: want none, got %v", ids)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/recordings?sort=size", "op", nil); resp.Status != http.StatusBadRequest {
+		t.Fatalf("unknown sort: want 400, got %d", resp.Status)
+	}
+}
+
 func TestRecordingListScopeAndContent(t *testing.T) {
 	h := newHarness(t)
 	ctx := context.Background()
@@ -120,6 +143,62 @@ func TestRecordingListScopeAndContent(t *testing.T) {
 	}
 }
 
+func TestRecordingPostHocAnnotations(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+	_, recID := recordTerminalSession(t, h, "op")
+	rec, _ := h.store.Recordings.Get(ctx, recID)
+	rec.DurationMS = 10_000
+	if err := h.store.Recordings.Update(ctx, &rec); err != nil {
+		t.Fatalf("seed duration: %v", err)
+	}
+	path := "/api/recordings/" + recID + "/annotations"
+
+	if resp := h.do(t, http.MethodPost, path, "viewer", strings.NewReader(`{"text":"x","offset":1}`)); resp.Status != http.StatusForbidden {
+		t.Fatalf("stranger annotate: want 403, got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodPost, path, "op", strings.NewReader(`{"text":"x","offset":99}`)); resp.Status != http.StatusBadRequest {
+		t.Fatalf("offset past end: want 400, got %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodPost, path, "op", strings.NewReader(`{"text":"  "}`)); resp.Status != http.StatusBadRequest {
+		t.Fatalf("blank text: want 400, got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodPost, path, "op", strings.NewReader(`{"text":"rollback here","offset":4.2}`)); resp.Status != http.StatusCreated {
+		t.Fatalf("annotate: want 201, got %d (%s)", resp.Status, resp.Body)
+	}
+
+	resp := h.do(t, http.MethodGet, path, "op", nil)
+	var notes []models.RecordingAnnotation
+	if err := json.Unmarshal(resp.Body, &notes); err != nil {
+		t.Fatalf("decode: %v (%s)", err, resp.Body)
+	}
+	if len(notes) != 1 || notes[0].Text != "rollback here" || notes[0].Offset != 4.2 || notes[0].Live || notes[0].UserID != "op" {
+		t.Fatalf("annotations: %+v", notes)
+	}
+	if resp := h.do(t, http.MethodGet, path, "viewer", nil); resp.Status != http.StatusForbidden {
+		t.Fatalf("stranger list: want 403, got %d", resp.Status)
+	}
+}
+
+func TestRecordingPauseNeedsLiveRecording(t *testing.T) {
+	h := newHarness(t)
+	_, recID := recordTerminalSession(t, h, "op")
+
+	resp := h.do(t, http.MethodPost, "/api/recordings/"+recID+"/pause", "op", nil)
+	if resp.Status != http.StatusNotFound {
+		t.Fatalf("pause finalized recording: want 404, got %d (%s)", resp.Status, resp.Body)
+	}
+	var rec struct {
+		InputCaptured bool `json:"inputCaptured"`
+	}
+	if err := json.Unmarshal(h.do(t, http.MethodGet, "/api/recordings/"+recID, "op", nil).Body, &rec); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if rec.InputCaptured {
+		t.Fatalf("input should be redacted by default: %+v", rec)
+	}
+}
+
 func TestDesktopChunkFlow(t *testing.T) {
 	h := newHarness(t)
 	ctx := context.Background()
@@ -170,3 +249,461 @@ func TestDesktopChunkFlow(t *testing.T) {
 }
 
 func itoa(i int) string { return string(rune('0' + i)) }
+
+func TestRecordingTranscriptSearch(t *testing.T) {
+	h := newHarness(t)
+	_, recID := recordTerminalSession(t, h, "op")
+
+	if resp := h.do(t, http.MethodGet, "/api/recordings/"+recID+"/transcript", "op", nil); resp.Status != http.StatusNotFound {
+		t.Fatalf("transcript before extraction: want 404, got %d", resp.Status)
+	}
+	if _, err := h.recordings.ExtractTranscript(context.Background(), recID); err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+
+	resp := h.do(t, http.MethodGet, "/api/recordings/"+recID+"/transcript", "op", nil)
+	if resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `"ping"`) {
+		t.Fatalf("transcript: status=%d body=%s", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/recordings/"+recID+"/transcript", "viewer", nil); resp.Status != http.StatusForbidden {
+		t.Fatalf("stranger transcript: want 403, got %d", resp.Status)
+	}
+
+	var list []struct {
+		ID            string `json:"id"`
+		HasTranscript bool   `json:"hasTranscript"`
+		Matches       []struct {
+			Snippet string `json:"snippet"`
+		} `json:"matches"`
+	}
+	body := h.do(t, http.MethodGet, "/api/recordings?q=PIN", "op", nil).Body
+	if err := json.Unmarshal(body, &list); err != nil {
+		t.Fatalf("decode: %v (%s)", err, body)
+	}
+	if len(list) != 1 || list[0].ID != recID || !list[0].HasTranscript || len(list[0].Matches) != 1 || list[0].Matches[0].Snippet != "ping" {
+		t.Fatalf("transcript search: %s", body)
+	}
+	if ids := recordingIDs(t, h.do(t, http.MethodGet, "/api/recordings?q=PIN", "viewer", nil).Body); len(ids) != 0 {
+		t.Fatalf("stranger search: want none, got %v", ids)
+	}
+}
+
+func readReplay(t *testing.T, c *websocket.Conn, want string) map[string]any {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	for {
+		var msg map[string]any
+		if err := wsjson.Read(ctx, c, &msg); err != nil {
+			t.Fatalf("waiting for %q: %v", want, err)
+		}
+		if msg["type"] == want {
+			return msg
+		}
+	}
+}
+
+func TestRecordingReplayStreamsAndCapsPerUser(t *testing.T) {
+	h := newHarness(t)
+	_, recID := recordTerminalSession(t, h, "op")
+	path := "/api/recordings/" + recID + "/replay"
+
+	if _, err := h.dialWS(t, "viewer", path); err == nil {
+		t.Fatal("stranger replay should be refused")
+	}
+
+	c, err := h.dialWS(t, "op", path)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	ctx := context.Background()
+	_ = wsjson.Write(ctx, c, map[string]any{"type": "speed", "speed": 20})
+	_ = wsjson.Write(ctx, c, map[string]any{"type": "speed", "speed": 8})
+	seen := map[string]map[string]any{}
+	for seen["end"] == nil || seen["error"] == nil {
+		var msg map[string]any
+		rctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		err := wsjson.Read(rctx, c, &msg)
+		cancel()
+		if err != nil {
+			t.Fatalf("replay: %v (seen %v)", err, seen)
+		}
+		seen[msg["type"].(string)] = msg
+	}
+	if seen["header"]["width"] == nil || seen["event"] == nil {
+		t.Fatalf("header and events expected before the end: %v", seen)
+	}
+	if !strings.Contains(seen["error"]["error"].(string), "speed") {
+		t.Fatalf("out-of-range speed: %v", seen["error"])
+	}
+	_ = wsjson.Write(ctx, c, map[string]any{"type": "seek", "offset": 0})
+	if msg := readReplay(t, c, "seek"); msg["reset"] != true {
+		t.Fatalf("seek after the end should restart: %v", msg)
+	}
+	readReplay(t, c, "end")
+
+	others := []*websocket.Conn{}
+	for range 2 {
+		o, err := h.dialWS(t, "op", path)
+		if err != nil {
+			t.Fatalf("replay within cap: %v", err)
+		}
+		others = append(others, o)
+	}
+	if _, err := h.dialWS(t, "op", path); err == nil {
+		t.Fatal("a fourth concurrent replay should be refused")
+	}
+	_ = c.CloseNow()
+	for i := 0; ; i++ {
+		o, err := h.dialWS(t, "op", path)
+		if err == nil {
+			_ = o.CloseNow()
+			break
+		}
+		if i == 50 {
+			t.Fatal("closing a replay did not free its slot")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	for _, o := range others {
+		_ = o.CloseNow()
+	}
+}
+
+func TestRecordingChaptersAndSkipIdleReplay(t *testing.T) {
+	h := newHarness(t)
+	_, recID := recordTerminalSession(t, h, "op")
+
+	var detail struct {
+		Chapters []models.RecordingChapter `json:"chapters"`
+	}
+	_ = json.Unmarshal(h.do(t, http.MethodGet, "/api/recordings/"+recID, "op", nil).Body, &detail)
+	if detail.Chapters != nil {
+		t.Fatalf("chapters before chaptering: %+v", detail.Chapters)
+	}
+	if n, err := h.recordings.BuildAllChapters(context.Background()); err != nil || n != 1 {
+		t.Fatalf("build chapters: n=%d err=%v", n, err)
+	}
+	if n, _ := h.recordings.BuildAllChapters(context.Background()); n != 0 {
+		t.Fatalf("second pass should skip chaptered recordings, did %d", n)
+	}
+	resp := h.do(t, http.MethodGet, "/api/recordings/"+recID, "op", nil)
+	_ = json.Unmarshal(resp.Body, &detail)
+	if len(detail.Chapters) != 1 || detail.Chapters[0].EventCount == 0 || detail.Chapters[0].Bytes == 0 {
+		t.Fatalf("chapters: %s", resp.Body)
+	}
+
+	c, err := h.dialWS(t, "op", "/api/recordings/"+recID+"/replay?skip_idle=true")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = c.CloseNow() }()
+	if msg := readReplay(t, c, "header"); msg["width"] == nil {
+		t.Fatalf("header: %v", msg)
+	}
+	readReplay(t, c, "event")
+	readReplay(t, c, "end")
+}
+
+func TestRecordingEventsExport(t *testing.T) {
+	h := newHarness(t)
+	_, recID := recordTerminalSession(t, h, "op")
+	path := "/api/recordings/" + recID + "/events.json"
+
+	if resp := h.do(t, http.MethodGet, path, "op2", nil); resp.Status != http.StatusForbidden {
+		t.Fatalf("other user: want 403, got %d", resp.Status)
+	}
+	decode := func(body io.Reader) []map[string]any {
+		t.Helper()
+		var lines []map[string]any
+		dec := json.NewDecoder(body)
+		for dec.More() {
+			var line map[string]any
+			if err := dec.Decode(&line); err != nil {
+				t.Fatalf("decode line: %v", err)
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) == 0 {
+			t.Fatal("no events exported")
+		}
+		return lines
+	}
+
+	resp := h.do(t, http.MethodGet, path, "op", nil)
+	if resp.Status != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("export: %d %q", resp.Status, resp.Header.Get("Content-Type"))
+	}
+	for _, line := range decode(bytes.NewReader(resp.Body)) {
+		if _, err := time.Parse(time.RFC3339Nano, line["time"].(string)); err != nil {
+			t.Fatalf("time: %v", err)
+		}
+		if line["recordingId"] != recID || line["userId"] != "op" || line["protocol"] != "tester" || line["type"] == "" {
+			t.Fatalf("line is not self-describing: %v", line)
+		}
+		if _, ok := line["payload"]; ok {
+			t.Fatalf("payload without include_payload: %v", line)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, h.ts.URL+path+"?include_payload=true", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp = h.doReq(t, req, "op")
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("want a gzip response, got %q", resp.Header.Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(resp.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := false
+	for _, line := range decode(zr) {
+		if p, ok := line["payload"].(string); ok && p != "" {
+			payload = true
+			if int(line["bytes"].(float64)) != len(p) {
+				t.Fatalf("bytes mismatch: %v", line)
+			}
+		}
+	}
+	if !payload {
+		t.Fatal("include_payload should add event text")
+	}
+	waitForAudit(t, h, func(e models.AuditEntry) bool {
+		return e.Event == "recording.export" && e.Result == models.AuditAllowed
+	})
+}
+
+func TestAdminRecordingStorageReport(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	for _, r := range []models.Recording{
+		{ID: "r1", UserID: "op", Username: "op", ConnectionID: "c-op", Status: models.RecordingFinalized, StartedAt: past, Size: 300, ExpiresAt: &past},
+		{ID: "r2", UserID: "op", Username: "op", ConnectionID: "c-view", Status: models.RecordingFinalized, StartedAt: past, Size: 200},
+		{ID: "r3", UserID: "viewer", Username: "viewer", ConnectionID: "c-view", Status: models.RecordingFinalized, StartedAt: past, Size: 100},
+	} {
+		if err := h.store.Recordings.Create(ctx, &r); err != nil {
+			t.Fatalf("seed %s: %v", r.ID, err)
+		}
+	}
+
+	if resp := h.do(t, http.MethodGet, "/api/admin/recordings/storage-report", "op", nil); resp.Status != http.StatusForbidden {
+		t.Fatalf("non-admin: want 403, got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/admin/recordings/storage-report?top=0", "admin", nil); resp.Status != http.StatusBadRequest {
+		t.Fatalf("bad top: want 400, got %d", resp.Status)
+	}
+	resp := h.do(t, http.MethodGet, "/api/admin/recordings/storage-report?top=1", "admin", nil)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("report: %d (%s)", resp.Status, resp.Body)
+	}
+	type group struct {
+		ID               string `json:"id"`
+		Bytes            int64  `json:"bytes"`
+		Count            int64  `json:"count"`
+		ReclaimableBytes int64  `json:"reclaimableBytes"`
+	}
+	var got struct {
+		Total        group   `json:"total"`
+		ByUser       []group `json:"byUser"`
+		ByConnection []group `json:"byConnection"`
+	}
+	if err := json.Unmarshal(resp.Body, &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Total.Bytes != 600 || got.Total.Count != 3 || got.Total.ReclaimableBytes != 300 {
+		t.Fatalf("total: %+v", got.Total)
+	}
+	if len(got.ByUser) != 1 || got.ByUser[0].ID != "op" || got.ByUser[0].Bytes != 500 {
+		t.Fatalf("by user: %+v", got.ByUser)
+	}
+	if len(got.ByConnection) != 1 || got.ByConnection[0].ID != "c-op" || got.ByConnection[0].ReclaimableBytes != 300 {
+		t.Fatalf("by connection: %+v", got.ByConnection)
+	}
+}
+
+func TestAdminBulkDeleteRecordings(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	for _, r := range []models.Recording{
+		{ID: "r1", UserID: "op", ConnectionID: "c-op", Status: models.RecordingFinalized, StartedAt: past, Size: 300, StorageKey: "gone.cast"},
+		{ID: "r2", UserID: "op", ConnectionID: "c-op", Status: models.RecordingFinalized, StartedAt: past, Size: 200, Protected: true},
+		{ID: "r3", UserID: "op", ConnectionID: "c-op", Status: models.RecordingActive, StartedAt: past},
+		{ID: "r4", UserID: "op", ConnectionID: "c-view", Status: models.RecordingFinalized, StartedAt: past, Size: 100},
+	} {
+		if err := h.store.Recordings.Create(ctx, &r); err != nil {
+			t.Fatalf("seed %s: %v", r.ID, err)
+		}
+	}
+	type result struct {
+		Matched   int      `json:"matched"`
+		Deleted   int      `json:"deleted"`
+		Bytes     int64    `json:"bytes"`
+		Protected []string `json:"protected"`
+		Active    []string `json:"active"`
+		Failed    []struct {
+			ID string `json:"id"`
+		} `json:"failed"`
+	}
+	run := func(user, body string) (apiResp, result) {
+		resp := h.do(t, http.MethodPost, "/api/admin/recordings/bulk-delete", user, strings.NewReader(body))
+		var res result
+		_ = json.Unmarshal(resp.Body, &res)
+		return resp, res
+	}
+
+	if resp, _ := run("op", `{"connection":"c-op"}`); resp.Status != http.StatusForbidden {
+		t.Fatalf("non-admin: want 403, got %d", resp.Status)
+	}
+	if resp, _ := run("admin", `{}`); resp.Status != http.StatusBadRequest {
+		t.Fatalf("unfiltered: want 400, got %d", resp.Status)
+	}
+	resp, res := run("admin", `{"connection":"c-op","dryRun":true}`)
+	if resp.Status != http.StatusOK || res.Matched != 3 || res.Deleted != 1 || res.Bytes != 300 ||
+		!slices.Equal(res.Protected, []string{"r2"}) || !slices.Equal(res.Active, []string{"r3"}) {
+		t.Fatalf("dry run: %d (%s)", resp.Status, resp.Body)
+	}
+	if _, err := h.store.Recordings.Get(ctx, "r1"); err != nil {
+		t.Fatalf("dry run deleted r1: %v", err)
+	}
+
+	resp, res = run("admin", `{"connection":"c-op"}`)
+	if resp.Status != http.StatusOK || res.Deleted != 1 || len(res.Failed) != 0 {
+		t.Fatalf("delete: %d (%s)", resp.Status, resp.Body)
+	}
+	if _, err := h.store.Recordings.Get(ctx, "r1"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("r1 should be gone: %v", err)
+	}
+	for _, id := range []string{"r2", "r3", "r4"} {
+		if _, err := h.store.Recordings.Get(ctx, id); err != nil {
+			t.Fatalf("%s should remain: %v", id, err)
+		}
+	}
+
+	rows, _ := h.store.Audit.List(ctx, store.AuditFilter{})
+	var audited int
+	for _, r := range rows {
+		if r.Event == "recording.bulk_delete" && r.Params["connection"] == "c-op" {
+			audited++
+			if r.Params["dryRun"] == "false" && (r.Params["deleted"] != "1" || r.Params["protected"] != "1") {
+				t.Fatalf("audit params: %v", r.Params)
+			}
+		}
+	}
+	if audited != 2 {
+		t.Fatalf("want one audit row per call, got %d", audited)
+	}
+}
+
+func TestRecordingLegalHold(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	for _, r := range []models.Recording{
+		{ID: "r1", UserID: "op", ConnectionID: "c-op", Status: models.RecordingFinalized, StartedAt: past},
+		{ID: "r2", UserID: "op", ConnectionID: "c-op", Status: models.RecordingFinalized, StartedAt: past},
+		{ID: "r3", UserID: "op", ConnectionID: "c-op", Status: models.RecordingActive, StartedAt: past},
+	} {
+		if err := h.store.Recordings.Create(ctx, &r); err != nil {
+			t.Fatalf("seed %s: %v", r.ID, err)
+		}
+	}
+
+	if resp := h.do(t, http.MethodPost, "/api/recordings/r1/protect", "op", nil); resp.Status != http.StatusForbidden {
+		t.Fatalf("non-admin protect: want 403, got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodPost, "/api/recordings/r3/protect", "admin", nil); resp.Status != http.StatusConflict {
+		t.Fatalf("protect active: want 409, got %d (%s)", resp.Status, resp.Body)
+	}
+	resp := h.do(t, http.MethodPost, "/api/recordings/r1/protect", "admin", strings.NewReader(`{"reason":"case 42"}`))
+	if resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `"protected":true`) || !strings.Contains(string(resp.Body), `"protectedBy":"admin"`) {
+		t.Fatalf("protect: %d (%s)", resp.Status, resp.Body)
+	}
+
+	resp = h.do(t, http.MethodDelete, "/api/recordings/r1", "op", nil)
+	var env struct {
+		Code       string `json:"code"`
+		Protection struct {
+			ProtectedBy string `json:"protectedBy"`
+		} `json:"protection"`
+	}
+	_ = json.Unmarshal(resp.Body, &env)
+	if resp.Status != http.StatusConflict || env.Code != "recording_protected" || env.Protection.ProtectedBy != "admin" {
+		t.Fatalf("delete protected: %d (%s)", resp.Status, resp.Body)
+	}
+
+	if resp := h.do(t, http.MethodPost, "/api/recordings/r1/unprotect", "admin", nil); resp.Status != http.StatusBadRequest {
+		t.Fatalf("unprotect without reason: want 400, got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodPost, "/api/recordings/r1/unprotect", "admin", strings.NewReader(`{"reason":"case closed"}`)); resp.Status != http.StatusOK {
+		t.Fatalf("unprotect: %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodDelete, "/api/recordings/r1", "op", nil); resp.Status != http.StatusOK {
+		t.Fatalf("delete after unprotect: %d (%s)", resp.Status, resp.Body)
+	}
+
+	resp = h.do(t, http.MethodPost, "/api/admin/recordings/protect", "admin", strings.NewReader(`{"connection":"c-op","reason":"hold"}`))
+	if resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `"protected":1`) {
+		t.Fatalf("protect by filter: %d (%s)", resp.Status, resp.Body)
+	}
+	if r2, _ := h.store.Recordings.Get(ctx, "r2"); !r2.Protected {
+		t.Fatal("r2 should be protected by the filter")
+	}
+
+	rows, _ := h.store.Audit.List(ctx, store.AuditFilter{})
+	var unprotects int
+	for _, r := range rows {
+		if r.Event == "recording.unprotect" && r.Result == models.AuditAllowed && r.Params["reason"] == "case closed" {
+			unprotects++
+		}
+	}
+	if unprotects != 1 {
+		t.Fatalf("want one audited unprotect with its reason, got %d", unprotects)
+	}
+}
+
+func TestRecordingListCSVExport(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+	start := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	for i, name := range []string{"plain", "web, \"prod\"\nrack 2", "=cmd"} {
+		if err := h.store.Recordings.Create(ctx, &models.Recording{
+			ID: "r" + strconv.Itoa(i), UserID: "op", Username: "op", ConnectionID: "c-op", ConnectionName: name,
+			Protocol: "ssh", Format: "asciicast_v2", Status: models.RecordingFinalized,
+			StartedAt: start.Add(time.Duration(i) * time.Minute), DurationMS: 1500, Size: 42,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = h.store.Recordings.Create(ctx, &models.Recording{ID: "other", UserID: "viewer", StartedAt: start})
+
+	req, _ := http.NewRequest(http.MethodGet, h.ts.URL+"/api/recordings?columns=connection_name,id,duration_seconds", nil)
+	req.Header.Set("Accept", "text/csv")
+	resp := h.doReq(t, req, "op")
+	if resp.Status != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/csv") {
+		t.Fatalf("csv: %d %q (%s)", resp.Status, resp.Header.Get("Content-Type"), resp.Body)
+	}
+	rows, err := csv.NewReader(bytes.NewReader(resp.Body)).ReadAll()
+	if err != nil {
+		t.Fatalf("parse csv: %v (%s)", err, resp.Body)
+	}
+	want := [][]string{
+		{"id", "connection_name", "duration_seconds"},
+		{"r2", "'=cmd", "1.500"},
+		{"r1", "web, \"prod\"\nrack 2", "1.500"},
+		{"r0", "plain", "1.500"},
+	}
+	if !slices.EqualFunc(rows, want, slices.Equal) {
+		t.Fatalf("csv rows = %q, want %q", rows, want)
+	}
+
+	// Filters apply as for JSON; format=csv is not a recording format filter.
+	resp = h.do(t, http.MethodGet, "/api/recordings?format=csv&q=plain&columns=id", "op", nil)
+	if rows, _ := csv.NewReader(bytes.NewReader(resp.Body)).ReadAll(); len(rows) != 2 || rows[1][0] != "r0" {
+		t.Fatalf("search csv rows = %q", rows)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/recordings?format=csv&columns=team", "op", nil); resp.Status != http.StatusBadRequest {
+		t.Fatalf("unknown column: want 400, got %d", resp.Status)
+	}
+}
@@ -10,14 +10,34 @@ import (
 	"github.com/charlesng35/shellcn/internal/auth"
 	"github.com/charlesng35/shellcn/internal/models"
 	"github.com/charlesng35/shellcn/internal/policy"
+	"github.com/charlesng35/shellcn/internal/service"
 	"github.com/charlesng35/shellcn/internal/session"
 	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/internal/telemetry"
 	"github.com/charlesng35/shellcn/internal/transport"
 	"github.com/charlesng35/shellcn/sdk/plugin"
 )
 
 type errorEnvelope struct {
 	Error string `json:"error"`
+	// Code is a stable machine-readable reason, set only where clients branch on it.
+	Code string `json:"code,omitempty"`
+	// Suggestion is a replacement value the client can offer, e.g. a free name.
+	Suggestion string `json:"suggestion,omitempty"`
+	// Fields lists every rejected input field when validation found several.
+	Fields []service.FieldError `json:"fields,omitempty"`
+	// Protection says who placed the legal hold that blocked a recording delete.
+	Protection *recordingProtectionDTO `json:"protection,omitempty"`
+	// OwnedCredentials is how many credentials block deleting a user.
+	OwnedCredentials int `json:"ownedCredentials,omitempty"`
+	// OwnedConnections is how many connections block deleting a user.
+	OwnedConnections int `json:"ownedConnections,omitempty"`
+	// PasswordRules lists the password policy rules a new password failed.
+	PasswordRules []service.PasswordRule `json:"passwordRules,omitempty"`
+	// SessionLimit is the cap a refused session ran into.
+	SessionLimit *sessionLimitDTO `json:"sessionLimit,omitempty"`
+	// RequestID is the correlation id users can quote when reporting a failure.
+	RequestID string `json:"requestId,omitempty"`
 }
 
 func writeJSON(w http.ResponseWriter, status int, v any) {
@@ -30,28 +50,59 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 
 // statusFor maps a sentinel error to an HTTP status (the boundary normalization).
 func statusFor(err error) int {
+	var launchErr *service.LaunchError
+	if errors.As(err, &launchErr) {
+		return launchStatus(launchErr.Category)
+	}
 	switch {
 	case errors.Is(err, plugin.ErrInvalidInput), errors.Is(err, models.ErrInvalidInput):
 		return http.StatusBadRequest
 	case errors.Is(err, plugin.ErrUnauthorized), errors.Is(err, auth.ErrInvalidCredentials):
 		return http.StatusUnauthorized
+	case errors.Is(err, service.ErrCredentialReadsBlocked):
+		return http.StatusTooManyRequests
 	case errors.Is(err, plugin.ErrForbidden), errors.Is(err, policy.ErrForbidden),
-		errors.Is(err, models.ErrForbidden), errors.Is(err, auth.ErrAccountDisabled):
+		errors.Is(err, models.ErrForbidden), errors.Is(err, auth.ErrAccountDisabled), errors.Is(err, service.ErrApprovalRequired):
 		return http.StatusForbidden
 	case errors.Is(err, plugin.ErrNotFound), errors.Is(err, store.ErrNotFound):
 		return http.StatusNotFound
 	case errors.Is(err, plugin.ErrConflict), errors.Is(err, models.ErrConflict), errors.Is(err, plugin.ErrAlreadyExists):
 		return http.StatusConflict
-	case errors.Is(err, plugin.ErrUnavailable), errors.Is(err, session.ErrSessionLimit),
-		errors.Is(err, session.ErrChannelLimit), errors.Is(err, transport.ErrAgentUnavailable):
+	case errors.Is(err, plugin.ErrUnavailable), errors.Is(err, store.ErrUnavailable), errors.Is(err, session.ErrSessionLimit),
+		errors.Is(err, session.ErrChannelLimit), errors.Is(err, session.ErrSessionReconnecting), errors.Is(err, transport.ErrAgentUnavailable):
 		return http.StatusServiceUnavailable
-	case errors.Is(err, plugin.ErrNotSupported):
+	case errors.Is(err, plugin.ErrNotSupported), errors.Is(err, session.ErrNoClipboard):
 		return http.StatusNotImplemented
+	case errors.Is(err, session.ErrClipboardTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, service.ErrInvitationExpired):
+		return http.StatusGone
+	case errors.Is(err, service.ErrPreferencesChanged):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, service.ErrTimeout):
+		return http.StatusGatewayTimeout
 	default:
 		return http.StatusInternalServerError
 	}
 }
 
+// launchStatus gives each connect failure category its own status, so clients
+// and proxies can tell a refused login from a host that is down.
+func launchStatus(c plugin.FailureCategory) int {
+	switch c {
+	case plugin.FailureAuth:
+		return http.StatusUnauthorized
+	case plugin.FailureHostKey:
+		return http.StatusConflict
+	case plugin.FailureQuota:
+		return http.StatusTooManyRequests
+	case plugin.FailureTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
+
 // cleanAgentError replaces a noisy tunnel dial failure (internal URL +
 // "session shutdown") with a clear, client-safe message.
 func cleanAgentError(err error) error {
@@ -69,13 +120,56 @@ func writeError(w http.ResponseWriter, log *slog.Logger, err error) {
 	err = cleanAgentError(err)
 	status := statusFor(err)
 	msg := err.Error()
-	if status >= 500 && status != http.StatusServiceUnavailable && status != http.StatusNotImplemented {
+	requestID := w.Header().Get(telemetry.RequestIDHeader)
+	var launchErr *service.LaunchError
+	if errors.As(err, &launchErr) {
+		// The driver's text stays in the log for support; the user gets what
+		// to check instead.
+		if log != nil {
+			log.Warn("session launch failed", "category", launchErr.Category, "err", err, "request_id", requestID)
+		}
+		msg = launchErr.Message()
+	} else if status >= 500 && status != http.StatusServiceUnavailable && status != http.StatusNotImplemented {
 		if log != nil {
-			log.Error("request failed", "err", err)
+			log.Error("request failed", "err", err, "request_id", requestID)
 		}
 		msg = http.StatusText(status)
 	}
-	writeJSON(w, status, errorEnvelope{Error: msg})
+	env := errorEnvelope{Error: msg, Code: errorCode(err), RequestID: requestID}
+	var nameErr *service.NameConflictError
+	if errors.As(err, &nameErr) {
+		env.Suggestion = nameErr.Suggestion
+	}
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErr) {
+		env.Fields = validationErr.Fields
+	}
+	var protectedErr *service.RecordingProtectedError
+	if errors.As(err, &protectedErr) {
+		env.Protection = &recordingProtectionDTO{ProtectedBy: protectedErr.ProtectedBy, ProtectedAt: protectedErr.ProtectedAt}
+	}
+	var ownedErr *service.OwnedCredentialsError
+	if errors.As(err, &ownedErr) {
+		env.OwnedCredentials = ownedErr.Count
+	}
+	var ownedConnsErr *service.OwnedConnectionsError
+	if errors.As(err, &ownedConnsErr) {
+		env.OwnedConnections = ownedConnsErr.Count
+	}
+	var policyErr *service.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		env.PasswordRules = policyErr.Rules
+	}
+	var limitErr *session.LimitError
+	if errors.As(err, &limitErr) {
+		env.SessionLimit = &sessionLimitDTO{Limit: limitErr.Limit, Active: limitErr.Active}
+	}
+	writeJSON(w, status, env)
+}
+
+type sessionLimitDTO struct {
+	Limit  int `json:"limit"`
+	Active int `json:"active"`
 }
 
 func writeAuthRequired(w http.ResponseWriter, log *slog.Logger, err error) {
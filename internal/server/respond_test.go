@@ -7,6 +7,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/charlesng35/shellcn/internal/service"
 	"github.com/charlesng35/shellcn/internal/transport"
 	"github.com/charlesng35/shellcn/sdk/plugin"
 )
@@ -57,3 +58,46 @@ func TestWriteErrorServerFaultHidesDetail(t *testing.T) {
 		t.Errorf("internal detail leaked: %s", body)
 	}
 }
+
+func TestWriteErrorOperationTimeout(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeError(rec, nil, fmt.Errorf("%w: select from recordings: interrupted", service.ErrTimeout))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status: want 504, got %d", rec.Code)
+	}
+	if body := rec.Body.String(); strings.Contains(body, "recordings") {
+		t.Errorf("query detail leaked: %s", body)
+	}
+}
+
+func TestWriteErrorLaunchFailure(t *testing.T) {
+	for _, tc := range []struct {
+		driverErr string
+		status    int
+		code      string
+	}{
+		{"ssh: handshake failed: ssh: unable to authenticate, attempted methods [none password], no supported methods remain", http.StatusUnauthorized, "connect_auth_failed"},
+		{"dial tcp 10.0.0.9:22: connect: connection refused", http.StatusBadGateway, "connect_host_unreachable"},
+		{"dial tcp 10.0.0.9:22: i/o timeout", http.StatusGatewayTimeout, "connect_timeout"},
+		{"ssh: handshake failed: ssh: host key mismatch", http.StatusConflict, "connect_host_key_mismatch"},
+		{"tls: first record does not look like a TLS handshake", http.StatusBadGateway, "connect_protocol_error"},
+		{"FATAL: sorry, too many clients already (SQLSTATE 53300)", http.StatusTooManyRequests, "connect_quota"},
+	} {
+		rec := httptest.NewRecorder()
+		writeError(rec, nil, service.ClassifyLaunchError(fmt.Errorf("%s", tc.driverErr)))
+		body := rec.Body.String()
+		if rec.Code != tc.status || !strings.Contains(body, `"code":"`+tc.code+`"`) {
+			t.Errorf("%q: status=%d body=%s, want %d %s", tc.driverErr, rec.Code, body, tc.status, tc.code)
+		}
+		if strings.Contains(body, "10.0.0.9") || strings.Contains(body, "handshake") {
+			t.Errorf("%q: driver detail should stay in the log: %s", tc.driverErr, body)
+		}
+	}
+
+	// An error no category fits keeps the current handling.
+	rec := httptest.NewRecorder()
+	writeError(rec, nil, service.ClassifyLaunchError(fmt.Errorf("%w: upstream busy", plugin.ErrUnavailable)))
+	if rec.Code != http.StatusServiceUnavailable || strings.Contains(rec.Body.String(), `"code"`) {
+		t.Fatalf("unclassified: status=%d body=%s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,14 @@
+package server
+
+import "net/http"
+
+// handleAdminSchemaStatus reports schema changes the next start would apply
+// and any drift from the models. It never alters the schema.
+func (s *Server) handleAdminSchemaStatus(w http.ResponseWriter, r *http.Request) {
+	rep, err := s.deps.Store.Schema.Plan(r.Context())
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rep)
+}
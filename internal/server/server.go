@@ -5,6 +5,7 @@ import (
 	"io/fs"
 	"log/slog"
 	"net/http"
+	"net/netip"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -47,23 +48,76 @@ type Deps struct {
 	Credentials     *service.CredentialService
 	Enrollments     *service.EnrollmentService
 	Protocols       *service.ProtocolService
+	// Activity serves the admin usage dashboard; nil disables it.
+	Activity *service.ActivityService
+	// Usage serves connection usage stats and the stale-connection report;
+	// nil disables both.
+	Usage *service.ConnectionUsageService
+	// SessionCaps reports each user's session cap in the admin sessions view;
+	// nil shows the default cap.
+	SessionCaps *service.SessionCaps
+	// GrantExpiry validates temporary connection shares and serves the
+	// expiring-shares report; nil allows only permanent shares.
+	GrantExpiry *service.GrantExpiry
+	// Launches relays background session launches to the caller's event
+	// stream; nil leaves clients polling the session status.
+	Launches *service.SessionLaunches
+	// Maintenance is the read-only mode switch; nil disables the guard.
+	Maintenance *service.MaintenanceService
+	// Hygiene is the orphaned-grant sweep whose last report admins can read;
+	// nil disables the route.
+	Hygiene *service.HygieneService
 	// ExtPlugins is the out-of-tree plugin manager; nil when none are configured.
 	ExtPlugins *extplugin.Manager
 	// Market is the plugin registry client; nil when the marketplace is disabled.
 	Market *pluginmarket.Service
 	// PluginsDir is the configured external-plugin directory, surfaced read-only
 	// to the admin UI; empty when external loading is disabled.
-	PluginsDir        string
-	Users             *service.UserService
-	TwoFactor         *service.TwoFactorService
-	Invitations       *service.InvitationService
+	PluginsDir  string
+	Users       *service.UserService
+	TwoFactor   *service.TwoFactorService
+	Invitations *service.InvitationService
+	// Webhooks manages lifecycle event webhooks; nil disables their admin API.
+	Webhooks *service.WebhookService
+	// Preferences syncs the caller's UI settings; nil disables its API.
+	Preferences *service.PreferenceService
+	// Jobs runs background exports; nil disables the jobs API.
+	Jobs *service.JobService
+	// JobTickets signs job result download links; nil omits the links.
+	JobTickets *auth.TicketStore
+	// Idempotency replays retried creates and launches; nil ignores the
+	// Idempotency-Key header.
+	Idempotency *service.IdempotencyService
+	// SessionShares issues join links for live sessions; nil disables them.
+	SessionShares *service.SessionShareService
+	// Presence carries participants' typing and cursor state; nil disables it.
+	Presence *session.PresenceHub
+	// Observations logs read-only session observers; nil disables observing.
+	Observations *service.SessionObservationService
+	// PasswordPolicy describes the local password rules; nil disables its API.
+	PasswordPolicy *service.PasswordPolicyService
+	// Collation orders listed names; nil disables its admin API.
+	Collation *service.CollationService
+	// Settings is the registry of admin-editable system settings; nil
+	// disables its admin API.
+	Settings *service.SettingsService
+	// DriverSettings holds per-protocol settings of Configurable plugins;
+	// nil disables its admin API.
+	DriverSettings *service.DriverSettings
+	// Approvals gates break-glass credentials; nil disables its API.
+	Approvals *service.CredentialApprovals
+	// CredentialReads is the secret-read quota; nil disables its admin API.
+	CredentialReads   *service.CredentialReadGuard
 	Tunnels           *transport.Registry
 	Leases            livelease.LeaseRegistry
 	Instance          livelease.InstanceRef
 	Recordings        *service.RecordingService
 	Recording         *recording.Engine
 	RecordingMaxChunk int64
-	AI                *aiconfig.Service
+	// ScrollbackSkipUnrecorded keeps terminal output of sessions whose
+	// recording policy is disabled out of scrollback search.
+	ScrollbackSkipUnrecorded bool
+	AI                       *aiconfig.Service
 	// AIGlobal is the env/config shared-AI provider.
 	AIGlobal config.AIConfig
 	// ModelRegistry resolves model context windows and live model lists.
@@ -72,6 +126,11 @@ type Deps struct {
 	Metrics       *telemetry.Metrics
 	Health        *telemetry.Health
 	Logger        *slog.Logger
+	// AuditTail feeds the live audit stream; nil disables it.
+	AuditTail *audit.Tail
+	// AuditSigner checks anchor signatures when the audit chain is verified;
+	// nil checks the hashes only.
+	AuditSigner *audit.Signer
 
 	// StaticFS is the embedded web/dist; nil in dev mode.
 	StaticFS fs.FS
@@ -80,6 +139,16 @@ type Deps struct {
 	AccessLog bool
 	// AllowedOrigins are extra WS origins beyond same-site (usually empty).
 	AllowedOrigins []string
+	// Version is the build version reported in the API document.
+	Version string
+	// Timeouts bound service operations run for API requests; nil keeps
+	// service.DefaultTimeouts.
+	Timeouts *service.Timeouts
+	// TrustedProxies are the peers whose X-Forwarded-For and X-Real-IP
+	// headers are believed; empty trusts none.
+	TrustedProxies []netip.Prefix
+	// Geo enriches the client address with a location; nil skips the lookup.
+	Geo GeoLocator
 }
 
 // Server wires the dependencies into a chi router.
@@ -89,6 +158,7 @@ type Server struct {
 	loginLimiter *rateLimiter
 	chat         *ai.Service
 	aiTurns      *aiTurnRegistry
+	replays      *replaySlots
 }
 
 // New builds the server and its routes.
@@ -101,7 +171,7 @@ func New(d Deps) *Server {
 	}
 	// ~5 login attempts/min per IP with a small burst — generous for humans,
 	// punishing for online password guessing.
-	s := &Server{deps: d, loginLimiter: newRateLimiter(rate.Every(12*time.Second), 5), aiTurns: newAITurnRegistry()}
+	s := &Server{deps: d, loginLimiter: newRateLimiter(rate.Every(12*time.Second), 5), aiTurns: newAITurnRegistry(), replays: newReplaySlots()}
 
 	// Build chat here because it calls back into the server route invoker.
 	if d.AI != nil {
@@ -126,6 +196,7 @@ func (s *Server) routes() chi.Router {
 	r.Use(middleware.Recoverer)
 	r.Use(telemetry.RequestIDMiddleware)
 	r.Use(s.withRemoteAddr)
+	r.Use(s.withOperationTimeouts)
 	if s.deps.AccessLog {
 		r.Use(s.accessLog)
 	}
@@ -137,13 +208,18 @@ func (s *Server) routes() chi.Router {
 		r.Get("/healthz", func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write([]byte("ok")) })
 	}
 	if s.deps.Metrics != nil {
-		r.Handle("/metrics", s.deps.Metrics.Handler())
+		r.Method(http.MethodGet, "/metrics", s.deps.Metrics.Handler())
 	}
 
 	r.Route("/api", func(api chi.Router) {
+		api.Use(s.readOnlyGuard)
 		// Login is public and rate-limited per IP.
+		api.Get("/openapi.json", s.handleOpenAPI)
 		api.With(s.loginRateLimit).Post("/auth/login", s.handleLogin)
 		api.With(s.loginRateLimit).Post("/auth/login/mfa", s.handleLoginMFA)
+		if s.deps.PasswordPolicy != nil {
+			api.Get("/auth/password-policy", s.handleGetPasswordPolicy)
+		}
 
 		// Agent connect authenticates with its enrollment token.
 		if s.deps.Enrollments != nil && s.deps.Tunnels != nil {
@@ -154,6 +230,11 @@ func (s *Server) routes() chi.Router {
 			api.Get("/connections/{id}/agent/enrollments/{enrollmentId}/artifacts/{kind}", s.handleFetchArtifact)
 		}
 
+		// Job result download uses only its single-use signed ticket.
+		if s.deps.Jobs != nil && s.deps.JobTickets != nil {
+			api.Get("/jobs/{id}/result", s.handleJobResult)
+		}
+
 		// Invitation acceptance is public (the invitee has no session yet).
 		if s.deps.Invitations != nil {
 			api.Get("/invitations/{token}", s.handleInvitationLookup)
@@ -179,6 +260,7 @@ func (s *Server) routes() chi.Router {
 
 			pr.Get("/plugins", s.handleListPlugins)
 			pr.Get("/plugins/{name}", s.handleGetPlugin)
+			pr.Get("/plugins/{name}/template", s.handleGetPluginTemplate)
 
 			pr.Get("/connections", s.handleListConnections)
 			pr.Get("/connection-folders", s.handleListConnectionFolders)
@@ -186,25 +268,72 @@ func (s *Server) routes() chi.Router {
 			pr.Get("/credential-kinds", s.handleListCredentialKinds)
 
 			if s.deps.Connections != nil {
-				pr.Post("/connections", s.handleCreateConnection)
+				pr.Post("/connections", s.idempotent(s.handleCreateConnection))
 				pr.Put("/connections/layout", s.handleSaveConnectionLayout)
+				pr.Get("/connections/trash", s.handleListConnectionTrash)
+				pr.Post("/connections/{id}/restore", s.handleRestoreConnection)
 				pr.Get("/connections/{id}", s.handleConnectionDetail)
 				pr.Put("/connections/{id}", s.handleUpdateConnection)
 				pr.Delete("/connections/{id}", s.handleDeleteConnection)
 				pr.Get("/connections/{id}/session", s.handleConnectionSessionStatus)
-				pr.Post("/connections/{id}/session", s.handleKeepaliveConnectionSession)
+				pr.Post("/connections/{id}/session", s.idempotent(s.handleKeepaliveConnectionSession))
 				pr.Delete("/connections/{id}/session", s.handleDisconnectConnectionSession)
+				pr.Post("/connections/{id}/session/resize", s.handleResizeConnectionSession)
+				if s.deps.Presence != nil {
+					pr.Get("/connections/{id}/session/presence", s.handleSessionPresenceStream)
+					pr.Post("/connections/{id}/session/presence", s.handleUpdateSessionPresence)
+				}
+				pr.Post("/connections/{id}/exec", s.handleExecConnection)
+				if s.deps.Users != nil {
+					pr.Post("/connections/{id}/transfer-ownership", s.handleTransferConnection)
+				}
 				pr.Post("/connection-folders", s.handleCreateConnectionFolder)
+				pr.Post("/connection-folders/import", s.handleImportConnectionFolder)
+				pr.Get("/connection-folders/{folderId}/export", s.handleExportConnectionFolder)
 				pr.Put("/connection-folders/{folderId}", s.handleUpdateConnectionFolder)
 				pr.Delete("/connection-folders/{folderId}", s.handleDeleteConnectionFolder)
+				pr.Post("/connection-folders/{folderId}/launch", s.idempotent(s.handleLaunchConnectionFolder))
+				if s.deps.Usage != nil {
+					pr.Get("/connections/{id}/stats", s.handleConnectionStats)
+				}
+				pr.Get("/connections/{id}/audit", s.handleConnectionAudit)
+				pr.Put("/connections/{id}/favorite", s.handleAddConnectionFavorite)
+				pr.Delete("/connections/{id}/favorite", s.handleRemoveConnectionFavorite)
+				pr.Patch("/me/favorites/order", s.handleReorderConnectionFavorites)
 			}
 			if s.deps.Credentials != nil {
 				pr.Post("/credentials", s.handleCreateCredential)
 				pr.Put("/credentials/{id}", s.handleUpdateCredential)
 				pr.Delete("/credentials/{id}", s.handleDeleteCredential)
+				pr.Put("/credentials/{id}/favorite", s.handleAddCredentialFavorite)
+				pr.Delete("/credentials/{id}/favorite", s.handleRemoveCredentialFavorite)
+				if s.deps.Users != nil {
+					pr.Post("/credentials/{id}/transfer-ownership", s.handleTransferCredential)
+				}
 			}
 
 			pr.Get("/audit/me", s.handleMyAudit)
+			if s.deps.AuditTail != nil {
+				pr.Get("/audit/stream", s.handleAuditStream)
+			}
+			if s.deps.Jobs != nil || s.deps.Preferences != nil || s.deps.Observations != nil || s.deps.Launches != nil {
+				pr.Get("/me/events", s.handleUserEvents)
+			}
+			if s.deps.Observations != nil {
+				pr.Get("/sessions/{id}/observe", s.handleObserveSession)
+			}
+			pr.Get("/sessions/{id}/scrollback", s.handleScrollback)
+			pr.Get("/sessions/{id}/scrollback/search", s.handleSearchScrollback)
+			pr.Get("/sessions/{id}/clipboard", s.handleGetSessionClipboard)
+			pr.Put("/sessions/{id}/clipboard", s.handlePutSessionClipboard)
+			if s.deps.Preferences != nil {
+				pr.Get("/me/preferences", s.handleGetPreferences)
+				pr.Put("/me/preferences", s.handleUpdatePreferences)
+			}
+			if s.deps.Jobs != nil {
+				pr.Get("/jobs/{id}", s.handleGetJob)
+				pr.Post("/jobs/{id}/cancel", s.handleCancelJob)
+			}
 
 			// AI exposes shared status plus owner-scoped provider CRUD.
 			if s.deps.AI != nil {
@@ -229,14 +358,35 @@ func (s *Server) routes() chi.Router {
 				}
 			}
 			if s.deps.Connections != nil {
+				if s.deps.GrantExpiry != nil {
+					pr.Get("/connections/shares/expiring", s.handleExpiringShares)
+					pr.Post("/connections/shares/extend", s.handleExtendShares)
+				}
 				pr.Get("/connections/{id}/grants", s.handleListConnectionGrants)
 				pr.Post("/connections/{id}/grants", s.handleCreateConnectionGrant)
 				pr.Delete("/connections/{id}/grants/{grantId}", s.handleDeleteConnectionGrant)
+				if s.deps.SessionShares != nil {
+					pr.Get("/connections/{id}/session/share-links", s.handleListShareLinks)
+					pr.Post("/connections/{id}/session/share-links", s.handleCreateShareLink)
+					pr.Delete("/connections/{id}/session/share-links/{linkId}", s.handleRevokeShareLink)
+					pr.Post("/sessions/join/{token}", s.handleJoinSession)
+					pr.Get("/connections/{id}/session/shared/{ownerId}/stream", s.handleSharedSessionStream)
+					pr.Post("/connections/{id}/session/write-requests", s.handleRequestWriteAccess)
+					pr.Post("/connections/{id}/session/write-requests/{requestId}/approve", s.handleApproveWriteRequest)
+					pr.Post("/connections/{id}/session/write-requests/{requestId}/deny", s.handleDenyWriteRequest)
+				}
 			}
 			if s.deps.Credentials != nil {
 				pr.Get("/credentials/{id}/grants", s.handleListCredentialGrants)
+				pr.Get("/credentials/{id}/access-log", s.handleCredentialAccessLog)
+				pr.Get("/credentials/{id}/versions", s.handleCredentialVersions)
+				pr.Get("/credentials/{id}/versions/diff", s.handleCredentialVersionDiff)
 				pr.Post("/credentials/{id}/grants", s.handleCreateCredentialGrant)
 				pr.Delete("/credentials/{id}/grants/{grantId}", s.handleDeleteCredentialGrant)
+				if s.deps.Approvals != nil {
+					pr.Get("/credential-approvals", s.handleListCredentialApprovals)
+					pr.Post("/credential-approvals/{id}/approve", s.handleApproveCredentialApproval)
+				}
 			}
 
 			if s.deps.Recordings != nil {
@@ -244,6 +394,13 @@ func (s *Server) routes() chi.Router {
 				pr.Get("/recordings/{id}", s.handleGetRecording)
 				pr.Get("/recordings/{id}/content", s.handleRecordingContent)
 				pr.Head("/recordings/{id}/content", s.handleRecordingContent)
+				pr.Get("/recordings/{id}/transcript", s.handleRecordingTranscript)
+				pr.Get("/recordings/{id}/replay", s.handleRecordingReplay)
+				pr.Get("/recordings/{id}/events.json", s.handleRecordingEvents)
+				pr.Get("/recordings/{id}/annotations", s.handleListRecordingAnnotations)
+				pr.Post("/recordings/{id}/annotations", s.handleAnnotateRecording)
+				pr.Post("/recordings/{id}/pause", s.handleRecordingCapture(true))
+				pr.Post("/recordings/{id}/resume", s.handleRecordingCapture(false))
 				pr.Delete("/recordings/{id}", s.handleDeleteRecording)
 				if s.deps.Connections != nil {
 					pr.Get("/connections/{id}/recordings", s.handleListConnectionRecordings)
@@ -274,14 +431,85 @@ func (s *Server) routes() chi.Router {
 					ar.Put("/admin/users/{id}", s.handleAdminUpdateUser)
 					ar.Post("/admin/users/{id}/activate", s.handleAdminActivateUser)
 					ar.Post("/admin/users/{id}/deactivate", s.handleAdminDeactivateUser)
+					ar.Delete("/admin/users/{id}", s.handleAdminDeleteUser)
 					ar.Post("/admin/users/{id}/reset-2fa", s.handleAdminResetTwoFactor)
+					ar.Post("/admin/users/{id}/anonymize", s.handleAdminAnonymizeUser)
 					ar.Get("/admin/users/{id}/audit", s.handleAdminUserAudit)
 					ar.Get("/admin/users/{id}/connections", s.handleAdminUserConnections)
+					ar.Get("/admin/permissions/explain", s.handleAdminExplainPermission)
+					ar.Get("/admin/sessions", s.handleAdminActiveSessions)
+					ar.Get("/admin/sessions/by-user", s.handleAdminSessionsByUser)
+					ar.Get("/admin/schema/status", s.handleAdminSchemaStatus)
+					if s.deps.Connections != nil {
+						ar.Get("/admin/credential-bindings", s.handleAdminCredentialBindings)
+						ar.Post("/admin/connections/migrate-config", s.handleAdminMigrateConnectionConfigs)
+						if s.deps.Credentials != nil {
+							ar.Post("/admin/connections/reassign", s.handleAdminReassignConnections)
+						}
+					}
+					if s.deps.Credentials != nil {
+						ar.Post("/admin/credentials/transfer-ownership", s.handleAdminTransferCredentials)
+					}
+					if s.deps.Activity != nil {
+						ar.Get("/admin/activity", s.handleAdminActivity)
+					}
+					if s.deps.Usage != nil {
+						ar.Get("/admin/connections/stale", s.handleAdminStaleConnections)
+					}
+					if s.deps.Jobs != nil {
+						ar.Post("/admin/audit/export", s.handleAdminAuditExport)
+					}
+					ar.Post("/admin/audit/verify", s.handleAdminAuditVerify)
+					if s.deps.Recordings != nil {
+						ar.Get("/admin/recordings/storage-report", s.handleAdminRecordingStorage)
+						ar.Post("/admin/recordings/bulk-delete", s.handleAdminBulkDeleteRecordings)
+						ar.Post("/admin/recordings/protect", s.handleAdminProtectRecordings)
+						ar.Post("/recordings/{id}/protect", s.handleSetRecordingProtection(true))
+						ar.Post("/recordings/{id}/unprotect", s.handleSetRecordingProtection(false))
+					}
+					if s.deps.Maintenance != nil {
+						ar.Get("/admin/read-only", s.handleGetReadOnly)
+						ar.Post("/admin/read-only", s.handleSetReadOnly)
+					}
+					if s.deps.Hygiene != nil {
+						ar.Get("/admin/hygiene", s.handleAdminHygiene)
+					}
+					if s.deps.Settings != nil {
+						ar.Get("/admin/settings", s.handleListSettings)
+						ar.Put("/admin/settings", s.handleUpdateSettings)
+					}
+					if s.deps.DriverSettings != nil {
+						ar.Get("/admin/drivers/{id}/settings", s.handleGetDriverSettings)
+						ar.Put("/admin/drivers/{id}/settings", s.handleUpdateDriverSettings)
+					}
+					if s.deps.Collation != nil {
+						ar.Get("/admin/collation", s.handleGetCollation)
+						ar.Put("/admin/collation", s.handleSetCollation)
+						ar.Post("/admin/collation/backfill", s.handleBackfillCollation)
+					}
+					if s.deps.Approvals != nil {
+						ar.Get("/admin/approval-exempt-root", s.handleGetApprovalExemptRoot)
+						ar.Put("/admin/approval-exempt-root", s.handleSetApprovalExemptRoot)
+					}
+					if s.deps.CredentialReads != nil {
+						ar.Get("/admin/credential-read-quota", s.handleGetCredentialReadQuota)
+						ar.Put("/admin/credential-read-quota", s.handleSetCredentialReadQuota)
+					}
 					if s.deps.Invitations != nil {
 						ar.Get("/admin/email", s.handleAdminEmailStatus)
 						ar.Get("/admin/invitations", s.handleAdminListInvitations)
 						ar.Post("/admin/invitations", s.handleAdminCreateInvitation)
 						ar.Delete("/admin/invitations/{id}", s.handleAdminRevokeInvitation)
+						ar.Post("/admin/invitations/{id}/revoke", s.handleAdminRevokeInvitation)
+						ar.Post("/admin/invitations/{id}/resend", s.handleAdminResendInvitation)
+					}
+					if s.deps.Webhooks != nil {
+						ar.Get("/admin/webhooks", s.handleAdminListWebhooks)
+						ar.Post("/admin/webhooks", s.handleAdminCreateWebhook)
+						ar.Put("/admin/webhooks/{id}", s.handleAdminUpdateWebhook)
+						ar.Delete("/admin/webhooks/{id}", s.handleAdminDeleteWebhook)
+						ar.Get("/admin/webhooks/{id}/deliveries", s.handleAdminWebhookDeliveries)
+						ar.Post("/admin/webhooks/{id}/test", s.handleAdminTestWebhook)
 					}
 					if s.deps.Protocols != nil {
 						ar.Get("/admin/protocols", s.handleAdminListProtocols)
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
@@ -35,6 +36,7 @@ import (
 	"github.com/charlesng35/shellcn/internal/service"
 	"github.com/charlesng35/shellcn/internal/session"
 	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/internal/telemetry"
 	"github.com/charlesng35/shellcn/internal/transport"
 	shellssh "github.com/charlesng35/shellcn/plugins/ssh"
 	"github.com/charlesng35/shellcn/sdk/plugin"
@@ -49,6 +51,18 @@ func (fakeSess) OpenChannel(context.Context, plugin.ChannelRequest) (plugin.Chan
 	return nil, plugin.ErrNotSupported
 }
 func (fakeSess) Close() error { return nil }
+func (fakeSess) Exec(ctx context.Context, req plugin.ExecRequest) (int, error) {
+	if req.Command == "sleep" {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	_, _ = io.WriteString(req.Stdout, "ran: "+req.Command+"\n")
+	_, _ = io.WriteString(req.Stderr, "warning\n")
+	if req.Command == "false" {
+		return 1, nil
+	}
+	return 0, nil
+}
 func (fakeSess) ServeHTTPProxy(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte("proxied:" + r.URL.Path))
 }
@@ -63,6 +77,7 @@ func (testPlugin) Manifest() plugin.Manifest {
 		APIVersion: plugin.CurrentAPIVersion, Name: "tester", Version: "0", Title: "Tester", Category: plugin.CategoryOther,
 		Layout:              plugin.LayoutTabs,
 		SupportedTransports: []plugin.Transport{plugin.TransportDirect, plugin.TransportAgent},
+		Capabilities:        []plugin.Capability{plugin.CapabilityExec},
 		Config: plugin.Schema{Groups: []plugin.Group{{Name: "Basic", Fields: []plugin.Field{
 			{Key: "host", Label: "Host", Type: plugin.FieldText, Required: true, VisibleWhen: &directOnly},
 			{Key: "read_only", Label: "Read-only", Type: plugin.FieldToggle, Default: true},
@@ -102,6 +117,10 @@ func (testPlugin) Routes() []plugin.Route {
 			ID: "tester.list", Method: plugin.MethodGet, Permission: "tester.read", Risk: plugin.RiskSafe, AuditEvent: "tester.list",
 			Handle: func(*plugin.RequestContext) (any, error) { return plugin.Page[string]{Items: []string{"a", "b"}}, nil },
 		},
+		{
+			ID: "tester.files", Method: plugin.MethodGet, Permission: "tester.files.read", Risk: plugin.RiskSafe, AuditEvent: "tester.files",
+			Handle: func(*plugin.RequestContext) (any, error) { return []string{"readme.txt"}, nil },
+		},
 		{
 			ID: "tester.unauth", Method: plugin.MethodGet, Permission: "tester.read", Risk: plugin.RiskSafe, AuditEvent: "tester.unauth",
 			Handle: func(*plugin.RequestContext) (any, error) { return nil, plugin.ErrUnauthorized },
@@ -300,6 +319,7 @@ type harness struct {
 	pluginSessions *session.Manager
 	sessionMgr     *auth.SessionManager
 	sessions       map[string]auth.Session // userID → platform session
+	recordings     *service.RecordingService
 }
 
 func newHarness(t *testing.T, opts ...func(*server.Deps)) *harness {
@@ -314,7 +334,16 @@ func newHarness(t *testing.T, opts ...func(*server.Deps)) *harness {
 	reg.MustRegister(internalPlugin{})
 	reg.MustRegister(agentOnlyPlugin{})
 	reg.MustRegister(shellssh.New())
-	creds := service.NewCredentialService(st.Credentials, st.CredentialGrants, vault, service.WithCredentialKindCatalog(reg))
+	settings := service.NewSettingsService(st.SystemSettings)
+	settings.Register(service.BuiltinSettings()...)
+	credReads := service.NewCredentialReadGuard(settings)
+	approvals := service.NewCredentialApprovals(st.CredentialApprovals, st.Credentials, st.CredentialGrants, st.Users, settings)
+	settings.OnChange(service.SettingApprovalExemptRoot, func(ctx context.Context, _ string) { _ = approvals.Load(ctx) })
+	creds := service.NewCredentialService(st.Credentials, st.CredentialGrants, vault,
+		service.WithCredentialKindCatalog(reg), service.WithCredentialAccessLog(st.CredentialAccess),
+		service.WithCredentialVersions(st.CredentialVersions), service.WithCredentialReadGuard(credReads),
+		service.WithCredentialApprovals(approvals), service.WithCredentialUsage(st.CredentialUsage))
+	t.Cleanup(creds.Close)
 
 	pol, err := policy.New()
 	if err != nil {
@@ -322,24 +351,50 @@ func newHarness(t *testing.T, opts ...func(*server.Deps)) *harness {
 	}
 	instance := livelease.NewInstanceRef("test-instance", "http://test-instance")
 	leases := livelease.NewStoreLeaseRegistry(st.LiveStateLeases)
-	sessMgr := session.New(session.Options{LeaseRegistry: leases, Instance: instance})
+	webhooks := service.NewWebhookService(st.Webhooks, st.WebhookDeliveries, vault,
+		service.WithWebhookRetry(1, 10*time.Millisecond))
+	t.Cleanup(webhooks.Close)
+	shares := service.NewSessionShareService(st.SessionShareLinks, st.SessionParticipants, st.SessionWriteRequests, nil)
+	presence := session.NewPresenceHub(0)
+	observations := service.NewSessionObservationService(st.SessionObservations, settings)
+	sessionCaps := service.NewSessionCaps(settings, st.Users, slog.Default())
+	launches := service.NewSessionLaunches()
+	sessMgr := session.New(session.Options{
+		LeaseRegistry: leases, Instance: instance, UserLimit: sessionCaps.UserLimit,
+		OnOpen:   webhooks.SessionStarted,
+		OnResize: webhooks.SessionResized,
+		OnLaunch: launches.Launched,
+		OnClose: func(snap session.Snapshot) {
+			webhooks.SessionClosed(snap)
+			shares.SessionClosed(snap)
+			presence.SessionClosed(snap)
+		},
+	})
 	t.Cleanup(sessMgr.Shutdown)
 	tunnels := transport.NewRegistry(transport.WithLeaseRegistry(leases, instance))
 	connector := service.NewConnector(reg, creds, vault, tunnels)
-	connections := service.NewConnectionService(st.Connections, reg, creds, vault)
+	driverSettings := service.NewDriverSettings(reg, settings)
+	connector.SetDriverSettings(driverSettings)
+	connections := service.NewConnectionService(st.Connections, reg, creds, vault,
+		service.WithConnectionPlacements(st.ConnectionPlacements), service.WithConnectionNames(st.ConnectionNames))
 	recBlobs, err := recording.NewLocalBlobStore(t.TempDir())
 	if err != nil {
 		t.Fatalf("blob store: %v", err)
 	}
-	recEngine := recording.NewEngine(recording.Options{Store: st.Recordings, Blobs: recBlobs})
+	recEngine := recording.NewEngine(recording.Options{Store: st.Recordings, Blobs: recBlobs, OnFinalize: webhooks.RecordingCompleted})
 	recEngine.Register(plugin.FormatAsciicastV2, recording.NewAsciicastRecorder)
 	recordings := service.NewRecordingService(st.Recordings, recBlobs)
 	authMgr := auth.NewSessionManager(time.Hour)
 	ticketKey := []byte("0123456789abcdef0123456789abcdef")
 	enrollments := service.NewEnrollmentService(st.Enrollments, st.Connections, reg)
-	users := service.NewUserService(st.Users)
+	passwordPolicy := service.NewPasswordPolicyService(settings, st.Users)
+	settings.OnChange(service.SettingPasswordPolicy, func(ctx context.Context, _ string) { _ = passwordPolicy.Load(ctx) })
+	users := service.NewUserService(st.Users, service.WithUserCredentials(st.Credentials),
+		service.WithUserConnections(connections), service.WithPasswordPolicy(passwordPolicy),
+		service.WithUserAnonymizer(st.Anonymize))
 	twoFactor := service.NewTwoFactorService(st.Users, vault, "ShellCN")
 	invitations := service.NewInvitationService(st.Invitations, users, email.New(email.SMTP{}))
+	auditTail := audit.NewTail()
 
 	deps := server.Deps{
 		Plugins: reg, Store: st, Sessions: sessMgr,
@@ -351,14 +406,22 @@ func newHarness(t *testing.T, opts ...func(*server.Deps)) *harness {
 			Instance:   instance,
 		}),
 		Policy:    pol,
-		Connector: connector, Connections: connections, Credentials: creds, Audit: audit.NewWriter(st.Audit),
+		Connector: connector, Connections: connections, Credentials: creds, Audit: audit.NewWriter(st.Audit, audit.WithTail(auditTail)),
 		Enrollments: enrollments, Tunnels: tunnels, Leases: leases, Instance: instance, Protocols: service.NewProtocolService(st.ProtocolSettings),
-		Users: users, TwoFactor: twoFactor, Invitations: invitations,
-		Recording: recEngine, Recordings: recordings,
+		Maintenance: service.NewMaintenanceService(st.SystemSettings), CredentialReads: credReads, Collation: service.NewCollationService(settings, st.SortKeys), Approvals: approvals, Settings: settings,
+		Activity:       service.NewActivityService(st.Activity),
+		SessionCaps:    sessionCaps,
+		GrantExpiry:    service.NewGrantExpiry(st.Grants, st.Connections, settings),
+		Launches:       launches,
+		DriverSettings: driverSettings,
+		Usage:          service.NewConnectionUsageService(st.ConnectionUsage, st.Users, st.ConnectionFolders, st.ConnectionPlacements),
+		Users:          users, PasswordPolicy: passwordPolicy, TwoFactor: twoFactor, Invitations: invitations, Webhooks: webhooks, SessionShares: shares, Presence: presence, Observations: observations,
+		Recording: recEngine, Recordings: recordings, Preferences: service.NewPreferenceService(st.Preferences),
 		AI: aiconfig.New(st.AIProviders, vault, config.AIConfig{
 			Kind: "openai", Name: "Shared", APIKey: "sk-global-secret", Model: "gpt-4o",
 		}),
 		ModelRegistry: modelreg.New(modelreg.WithoutRegistryFetch()),
+		AuditTail:     auditTail,
 	}
 	for _, o := range opts {
 		o(&deps)
@@ -368,7 +431,7 @@ func newHarness(t *testing.T, opts ...func(*server.Deps)) *harness {
 	ts := httptest.NewServer(srv.Handler())
 	t.Cleanup(ts.Close)
 
-	h := &harness{ts: ts, srv: srv, store: st, tunnels: tunnels, pluginSessions: sessMgr, sessionMgr: authMgr, sessions: map[string]auth.Session{}}
+	h := &harness{ts: ts, srv: srv, store: st, tunnels: tunnels, pluginSessions: sessMgr, sessionMgr: authMgr, sessions: map[string]auth.Session{}, recordings: recordings}
 
 	ctx := context.Background()
 	for _, u := range []struct {
@@ -981,6 +1044,36 @@ func TestProjectionEndpoints(t *testing.T) {
 	}
 }
 
+func TestPluginTemplateEndpoint(t *testing.T) {
+	h := newHarness(t)
+	resp := h.do(t, http.MethodGet, "/api/plugins/tester/template", "op", nil)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("get template: want 200, got %d", resp.Status)
+	}
+	var tmpl plugin.ConfigTemplate
+	if err := json.Unmarshal(resp.Body, &tmpl); err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Protocol != "tester" || tmpl.Hash == "" || tmpl.Defaults["read_only"] != true {
+		t.Errorf("template = %+v", tmpl)
+	}
+	want := map[string]plugin.FieldBinding{"host": plugin.BindingConfig, "password": plugin.BindingSecret, "credential_id": plugin.BindingCredential}
+	for key, binding := range want {
+		if tmpl.Bindings[key] != binding {
+			t.Errorf("binding[%s] = %q, want %q", key, tmpl.Bindings[key], binding)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, h.ts.URL+"/api/plugins/tester/template", nil)
+	req.Header.Set("If-None-Match", `"`+tmpl.Hash+`"`)
+	if resp := h.doReq(t, req, "op"); resp.Status != http.StatusNotModified {
+		t.Errorf("matching If-None-Match: want 304, got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/plugins/nope/template", "op", nil); resp.Status != http.StatusNotFound {
+		t.Errorf("unknown plugin: want 404, got %d", resp.Status)
+	}
+}
+
 func (h *harness) wsURL(path string) string {
 	return "ws" + strings.TrimPrefix(h.ts.URL, "http") + path
 }
@@ -1522,3 +1615,29 @@ func TestWSTicketSingleUse(t *testing.T) {
 		t.Error("ticket replay must be rejected")
 	}
 }
+
+func TestRequestIDReachesAuditAndErrorResponses(t *testing.T) {
+	h := newHarness(t)
+	req, _ := http.NewRequest(http.MethodGet, h.ts.URL+"/api/connections/c-op/x/tester.list", nil)
+	req.Header.Set(telemetry.RequestIDHeader, "bug-report-42")
+	resp := h.doReq(t, req, "viewer")
+	if resp.Status != http.StatusForbidden || resp.Header.Get(telemetry.RequestIDHeader) != "bug-report-42" {
+		t.Fatalf("got %d with request id %q", resp.Status, resp.Header.Get(telemetry.RequestIDHeader))
+	}
+	var env struct {
+		RequestID string `json:"requestId"`
+	}
+	if err := json.Unmarshal(resp.Body, &env); err != nil || env.RequestID != "bug-report-42" {
+		t.Fatalf("error body should carry the request id: %s", resp.Body)
+	}
+	rows, _ := h.store.Audit.List(context.Background(), store.AuditFilter{ConnectionID: "c-op"})
+	for _, r := range rows {
+		if r.RouteID == "tester.list" && r.Result == models.AuditDenied {
+			if r.RequestID != "bug-report-42" {
+				t.Fatalf("audit request id = %q", r.RequestID)
+			}
+			return
+		}
+	}
+	t.Fatal("missing denied audit row")
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/session"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	clipboardPushEvent = "session.clipboard.push"
+	clipboardPullEvent = "session.clipboard.pull"
+)
+
+type clipboardBody struct {
+	Text string `json:"text"`
+}
+
+type clipboardDTO struct {
+	Session   string    `json:"session"`
+	Text      string    `json:"text"`
+	UpdatedAt time.Time `json:"updatedAt,omitzero"`
+}
+
+// handlePutSessionClipboard replaces the remote clipboard of the caller's
+// live session. Pushing needs write access to the connection, so a
+// participant holding a view grant cannot type through the clipboard.
+func (s *Server) handlePutSessionClipboard(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	res, ok := s.clipboardSession(w, r, id, clipboardPushEvent, plugin.RiskWrite)
+	if !ok {
+		return
+	}
+	limit := s.deps.Sessions.ClipboardLimit()
+	var body clipboardBody
+	// JSON escaping can take six bytes per byte of text; the text itself is
+	// held to the limit below.
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, int64(6*limit+64))).Decode(&body)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		err = session.ErrClipboardTooLarge
+	case err != nil:
+		err = plugin.ErrInvalidInput
+	case len(body.Text) > limit:
+		err = session.ErrClipboardTooLarge
+	}
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	_, err = s.deps.Sessions.SetClipboard(r.Context(), id, body.Text)
+	s.finishClipboard(r, res, "push", len(body.Text), err)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetSessionClipboard returns the text the caller's live session last
+// reported on its remote clipboard.
+func (s *Server) handleGetSessionClipboard(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	res, ok := s.clipboardSession(w, r, id, clipboardPullEvent, plugin.RiskSafe)
+	if !ok {
+		return
+	}
+	_, clip, err := s.deps.Sessions.Clipboard(id)
+	s.finishClipboard(r, res, "pull", len(clip.Text), err)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, clipboardDTO{Session: id, Text: clip.Text, UpdatedAt: clip.UpdatedAt})
+}
+
+// clipboardSession resolves a clipboard request to the caller's own session
+// and its connection, then applies the connection's clipboard policy and
+// authorizes connection.use at risk, writing the error response when it
+// reports false. Another user's session is reported as not found, so ids
+// cannot be probed.
+func (s *Server) clipboardSession(w http.ResponseWriter, r *http.Request, id, event string, risk plugin.RiskLevel) (resolved, bool) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	res := resolved{user: user, params: map[string]string{"session": id}}
+	// A session without clipboard support still resolves, and is reported
+	// as such once the caller has passed the policy checks. Resolving does
+	// not count as use, so a refused caller cannot keep it alive.
+	snap, err := s.deps.Sessions.ClipboardSession(id)
+	if err != nil || snap.UserID != user.ID {
+		writeError(w, s.deps.Logger, plugin.ErrNotFound)
+		return res, false
+	}
+	conn, err := s.deps.Store.Connections.Get(ctx, snap.Key.ConnectionID)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return res, false
+	}
+	res.conn = conn
+	res.route = plugin.Route{ID: event, Permission: "connection.use", Risk: risk, AuditEvent: event}
+	err = s.authorize(ctx, user, conn, res.route)
+	if err == nil && conn.ClipboardDisabled {
+		err = errClipboardDisabled
+	}
+	if err != nil {
+		s.auditEvent(ctx, res, models.AuditDenied, err)
+		s.incAuthzFailure(err)
+		writeError(w, s.deps.Logger, err)
+		return res, false
+	}
+	return res, true
+}
+
+// finishClipboard audits and counts a transfer by its direction and size;
+// the text itself is never logged.
+func (s *Server) finishClipboard(r *http.Request, res resolved, direction string, n int, err error) {
+	res.params["direction"] = direction
+	res.params["bytes"] = strconv.Itoa(n)
+	s.auditEvent(r.Context(), res, auditResult(err), err)
+	if err == nil && s.deps.Metrics != nil {
+		s.deps.Metrics.ObserveClipboard(res.conn.Protocol, direction, n)
+	}
+}
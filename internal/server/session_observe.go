@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/coder/websocket"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/audit"
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/policy"
+	"github.com/charlesng35/shellcn/internal/session"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const sessionObserveEvent = "session.observe"
+
+// handleObserveSession relays a live session's output to the caller over a
+// WebSocket. Observers cannot type, chat or appear in presence, and they are
+// detached when the session closes. Any frame sent by the observer closes
+// the socket.
+func (s *Server) handleObserveSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	id := chi.URLParam(r, "id")
+	params := map[string]string{"session": id}
+	if err := s.deps.Policy.Authorize(policy.AccessInput{User: user, Permission: sessionObserveEvent, Risk: plugin.RiskPrivileged}); err != nil {
+		s.auditObserveEvent(ctx, user, "", models.AuditDenied, params, err)
+		s.incAuthzFailure(err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	snap, output, detach, err := s.deps.Sessions.Observe(id)
+	if errors.Is(err, session.ErrSessionNotFound) {
+		err = plugin.ErrNotFound
+	}
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	defer detach()
+	params["owner"] = snap.UserID
+
+	c, err := websocket.Accept(w, r, &websocket.AcceptOptions{Subprotocols: []string{"binary"}})
+	if err != nil {
+		return // Accept already wrote the response
+	}
+	obs, err := s.deps.Observations.Start(ctx, user.ID, snap)
+	if err != nil {
+		s.auditObserveEvent(ctx, user, snap.Key.ConnectionID, models.AuditError, params, err)
+		_ = c.Close(websocket.StatusInternalError, streamCloseReason(err))
+		return
+	}
+	defer func() {
+		if err := s.deps.Observations.End(context.WithoutCancel(ctx), obs); err != nil {
+			s.deps.Logger.Warn("end session observation", "observation", obs.ID, "err", err)
+		}
+	}()
+	s.auditObserveEvent(ctx, user, snap.Key.ConnectionID, models.AuditAllowed, params, nil)
+
+	msgType := websocket.MessageText
+	if c.Subprotocol() == "binary" {
+		msgType = websocket.MessageBinary
+	}
+	ctx = c.CloseRead(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			_ = c.Close(websocket.StatusNormalClosure, "")
+			return
+		case p, ok := <-output:
+			if !ok {
+				_ = c.Close(websocket.StatusNormalClosure, "session closed")
+				return
+			}
+			if err := c.Write(ctx, msgType, p); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) auditObserveEvent(ctx context.Context, user models.User, connID string, result models.AuditResult, params map[string]string, err error) {
+	s.deps.Audit.Record(ctx, audit.Event{
+		User: user, Event: sessionObserveEvent, ConnectionID: connID, RouteID: sessionObserveEvent,
+		Risk: string(plugin.RiskPrivileged), Result: result, Params: params, Err: err,
+	})
+}
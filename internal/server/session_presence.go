@@ -0,0 +1,132 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/session"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	sessionPresenceEvent       = "connection.session.presence"
+	sessionPresenceStreamEvent = "connection.session.presence.stream"
+
+	maxPresenceScroll = 1 << 20
+)
+
+// presenceRoute authorizes a presence call on the {id} connection. Presence
+// is a safe operation, so anyone who joined a shared session on it may use
+// it too.
+func (s *Server) presenceRoute(w http.ResponseWriter, r *http.Request, id, event string) (resolved, bool) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	conn, err := s.deps.Store.Connections.Get(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return resolved{}, false
+	}
+	res := resolved{user: user, conn: conn, route: plugin.Route{
+		ID: id, Permission: "connection.use", Risk: plugin.RiskSafe, AuditEvent: event,
+	}}
+	if err := s.authorize(ctx, user, conn, res.route); err != nil && !s.isParticipant(ctx, conn.ID, user.ID) {
+		s.auditEvent(ctx, res, models.AuditDenied, err)
+		s.incAuthzFailure(err)
+		writeError(w, s.deps.Logger, err)
+		return resolved{}, false
+	}
+	return res, true
+}
+
+// handleUpdateSessionPresence publishes the caller's typing, cursor and scroll
+// state to the connection's other participants. Allowed updates arrive
+// several times a second and are not audited individually; opening the
+// presence stream is.
+func (s *Server) handleUpdateSessionPresence(w http.ResponseWriter, r *http.Request) {
+	res, ok := s.presenceRoute(w, r, "connection.session.presence", sessionPresenceEvent)
+	if !ok {
+		return
+	}
+	var st session.PresenceState
+	if err := json.NewDecoder(r.Body).Decode(&st); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	if err := validatePresence(st); err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	sent := s.deps.Presence.Update(res.conn.ID, res.user.ID, st)
+	writeJSON(w, http.StatusOK, presenceUpdateDTO{Sent: sent})
+}
+
+type presenceUpdateDTO struct {
+	// Sent is false when the update was throttled; it is delivered when the
+	// caller's interval ends unless a newer one replaces it.
+	Sent bool `json:"sent"`
+}
+
+func validatePresence(st session.PresenceState) error {
+	if c := st.Cursor; c != nil && (c.Row < 0 || c.Col < 0 || c.Row > maxTerminalDimension || c.Col > maxTerminalDimension) {
+		return fmt.Errorf("%w: cursor must be within %d rows and columns", plugin.ErrInvalidInput, maxTerminalDimension)
+	}
+	if st.Scroll != nil && (*st.Scroll < 0 || *st.Scroll > maxPresenceScroll) {
+		return fmt.Errorf("%w: scroll must be between 0 and %d", plugin.ErrInvalidInput, maxPresenceScroll)
+	}
+	return nil
+}
+
+// handleSessionPresenceStream streams the connection's session.presence events
+// over a WebSocket, starting with one event per current participant. Closing
+// the socket makes the caller leave.
+func (s *Server) handleSessionPresenceStream(w http.ResponseWriter, r *http.Request) {
+	res, ok := s.presenceRoute(w, r, "connection.session.presence.stream", sessionPresenceStreamEvent)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+	c, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return // Accept already wrote the response
+	}
+	s.auditEvent(ctx, res, models.AuditAllowed, nil)
+	events, cancel := s.deps.Presence.Subscribe(res.conn.ID, res.user.ID)
+	defer cancel()
+	ctx = c.CloseRead(ctx)
+
+	for _, p := range s.deps.Presence.Snapshot(res.conn.ID) {
+		ev := session.PresenceEvent{
+			Type: session.PresenceEventType, ConnectionID: res.conn.ID, UserID: p.UserID, State: p.PresenceState, At: p.UpdatedAt,
+		}
+		if err := wsjson.Write(ctx, c, ev); err != nil {
+			return
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			_ = c.Close(websocket.StatusNormalClosure, "")
+			return
+		case ev := <-events:
+			if err := wsjson.Write(ctx, c, ev); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) presence(connID string) []session.Presence {
+	if s.deps.Presence == nil {
+		return nil
+	}
+	if list := s.deps.Presence.Snapshot(connID); len(list) > 0 {
+		return list
+	}
+	return nil
+}
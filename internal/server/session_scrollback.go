@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/audit"
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/policy"
+	"github.com/charlesng35/shellcn/internal/session"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	sessionScrollbackEvent   = "session.scrollback"
+	defaultScrollbackLines   = 200
+	defaultScrollbackContext = 2
+)
+
+type scrollbackPageDTO struct {
+	Session string `json:"session"`
+	session.ScrollbackPage
+}
+
+type scrollbackSearchDTO struct {
+	Session string                    `json:"session"`
+	Matches []session.ScrollbackMatch `json:"matches"`
+}
+
+// handleScrollback pages through a live terminal session's retained output.
+// ?from is a line number as returned by earlier pages or searches; omitted,
+// the page ends at the newest line.
+func (s *Server) handleScrollback(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	q := r.URL.Query()
+	n, err := scrollbackIntParam(q.Get("lines"), defaultScrollbackLines)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	n = min(max(n, 1), session.MaxScrollbackLines)
+	from, err := scrollbackIntParam(q.Get("from"), -1)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	if from < 0 {
+		// Read the bounds first so the default page is the newest lines.
+		_, page, err := s.deps.Sessions.Scrollback(id, 0, 1)
+		if err == nil {
+			from = max(page.Next-n, page.First)
+		}
+	}
+	snap, page, err := s.deps.Sessions.Scrollback(id, from, n)
+	if !s.authorizeScrollback(w, r, snap, err, map[string]string{"session": id, "from": strconv.Itoa(from)}) {
+		return
+	}
+	writeJSON(w, http.StatusOK, scrollbackPageDTO{Session: id, ScrollbackPage: page})
+}
+
+// handleSearchScrollback finds lines of a live terminal session's retained
+// output matching ?q, as a case-insensitive substring or, with ?regex=true,
+// a regular expression. Matching ignores escape sequences; lines come back
+// raw for display.
+func (s *Server) handleSearchScrollback(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	q := r.URL.Query()
+	radius, err := scrollbackIntParam(q.Get("context"), defaultScrollbackContext)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	query := session.ScrollbackQuery{Query: q.Get("q"), Regex: q.Get("regex") == "true", Context: radius}
+	snap, matches, err := s.deps.Sessions.SearchScrollback(id, query)
+	if !s.authorizeScrollback(w, r, snap, err, map[string]string{"session": id, "q": query.Query}) {
+		return
+	}
+	writeJSON(w, http.StatusOK, scrollbackSearchDTO{Session: id, Matches: matches})
+}
+
+// authorizeScrollback lets the session's owner through and otherwise
+// requires the observe permission, writing the error response when it
+// reports false. Callers without the permission get 403 for every session
+// but their own, so ids cannot be probed. Only non-owner reads are audited:
+// owners paging their own output would flood the log.
+func (s *Server) authorizeScrollback(w http.ResponseWriter, r *http.Request, snap session.Snapshot, err error, params map[string]string) bool {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	if errors.Is(err, session.ErrSessionNotFound) || errors.Is(err, session.ErrNoScrollback) {
+		err = plugin.ErrNotFound
+	}
+	if snap.UserID == "" || snap.UserID != user.ID {
+		params["owner"] = snap.UserID
+		if authErr := s.deps.Policy.Authorize(policy.AccessInput{User: user, Permission: sessionObserveEvent, Risk: plugin.RiskPrivileged}); authErr != nil {
+			s.auditScrollbackEvent(ctx, user, snap.Key.ConnectionID, models.AuditDenied, params, authErr)
+			s.incAuthzFailure(authErr)
+			writeError(w, s.deps.Logger, authErr)
+			return false
+		}
+		s.auditScrollbackEvent(ctx, user, snap.Key.ConnectionID, auditResult(err), params, err)
+	}
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return false
+	}
+	return true
+}
+
+func (s *Server) auditScrollbackEvent(ctx context.Context, user models.User, connID string, result models.AuditResult, params map[string]string, err error) {
+	s.deps.Audit.Record(ctx, audit.Event{
+		User: user, Event: sessionScrollbackEvent, ConnectionID: connID, RouteID: sessionScrollbackEvent,
+		Risk: string(plugin.RiskPrivileged), Result: result, Params: params, Err: err,
+	})
+}
+
+func scrollbackIntParam(v string, def int) (int, error) {
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: %q is not a non-negative integer", plugin.ErrInvalidInput, v)
+	}
+	return n, nil
+}
@@ -0,0 +1,281 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/session"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	shareLinkCreateEvent = "session.share_link.create"
+	shareLinkRevokeEvent = "session.share_link.revoke"
+	sessionJoinEvent     = "session.join"
+	sharedStreamEvent    = "session.shared_stream"
+
+	holderRecheck = time.Second
+	// participantRecheck bounds how long a shared stream outlives its
+	// participant when the removal was made on another server.
+	participantRecheck = 15 * time.Second
+)
+
+type shareLinkCreateResponse struct {
+	Link models.SessionShareLinkSummary `json:"link"`
+	// Token is the join token; it is shown only once.
+	Token string `json:"token"`
+}
+
+type sessionJoinResponse struct {
+	ConnectionID string           `json:"connectionId"`
+	OwnerID      string           `json:"ownerId"`
+	Mode         models.ShareMode `json:"mode"`
+	// WriteHolder is set when the joiner's input reaches the session.
+	WriteHolder bool `json:"writeHolder"`
+	// Cols and Rows are the shared session's current terminal size, when known.
+	Cols int `json:"cols,omitempty"`
+	Rows int `json:"rows,omitempty"`
+}
+
+// shareableConnection loads the {id} connection for a share-link route and
+// returns whose session the caller may share: their own as its owner, or the
+// session they hold write on.
+func (s *Server) shareableConnection(w http.ResponseWriter, r *http.Request, event string) (models.Connection, string, bool) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	conn, err := s.deps.Store.Connections.Get(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return models.Connection{}, "", false
+	}
+	if isOwner(user, conn.OwnerID) {
+		return conn, user.ID, true
+	}
+	if p, err := s.deps.SessionShares.Participation(ctx, conn.ID, user.ID); err == nil && p.WriteHolder {
+		return conn, p.OwnerID, true
+	}
+	if event != "" {
+		s.auditConnEvent(ctx, user, conn.ID, event, plugin.RiskWrite, models.AuditDenied, plugin.ErrForbidden)
+	}
+	writeError(w, s.deps.Logger, plugin.ErrForbidden)
+	return models.Connection{}, "", false
+}
+
+func (s *Server) handleListShareLinks(w http.ResponseWriter, r *http.Request) {
+	conn, _, ok := s.shareableConnection(w, r, "")
+	if !ok {
+		return
+	}
+	list, err := s.deps.SessionShares.List(r.Context(), conn.ID)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleCreateShareLink issues a join link for the live session the caller
+// may share on the connection; without one there is nothing to join.
+func (s *Server) handleCreateShareLink(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	conn, ownerID, ok := s.shareableConnection(w, r, shareLinkCreateEvent)
+	if !ok {
+		return
+	}
+	if s.proxyIfRemoteLeaseHolder(w, r, conn, ownerID) {
+		return
+	}
+	if snap, live := s.deps.Sessions.Status(session.Key{ConnectionID: conn.ID, ActorScope: ownerID}); !live || snap.State != session.StateConnected {
+		err := fmt.Errorf("%w: no live session to share", plugin.ErrConflict)
+		s.auditConnEvent(ctx, user, conn.ID, shareLinkCreateEvent, plugin.RiskWrite, models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	var req service.SessionShareInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	link, token, err := s.deps.SessionShares.Create(ctx, conn.ID, ownerID, user.ID, req)
+	if err != nil {
+		s.auditConnEvent(ctx, user, conn.ID, shareLinkCreateEvent, plugin.RiskWrite, models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditConnEvent(ctx, user, conn.ID, shareLinkCreateEvent, plugin.RiskWrite, models.AuditAllowed, nil)
+	writeJSON(w, http.StatusCreated, shareLinkCreateResponse{Link: link.Summary(), Token: token})
+}
+
+func (s *Server) handleRevokeShareLink(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	conn, _, ok := s.shareableConnection(w, r, shareLinkRevokeEvent)
+	if !ok {
+		return
+	}
+	if err := s.deps.SessionShares.Revoke(ctx, conn.ID, chi.URLParam(r, "linkId")); err != nil {
+		s.auditConnEvent(ctx, user, conn.ID, shareLinkRevokeEvent, plugin.RiskWrite, models.AuditError, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditConnEvent(ctx, user, conn.ID, shareLinkRevokeEvent, plugin.RiskWrite, models.AuditAllowed, nil)
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func (s *Server) handleJoinSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	link, p, err := s.deps.SessionShares.Join(ctx, chi.URLParam(r, "token"), user.ID)
+	if err != nil {
+		// A token that matches no link names no connection to audit.
+		if link.ConnectionID != "" {
+			s.auditConnEvent(ctx, user, link.ConnectionID, sessionJoinEvent, plugin.RiskWrite, models.AuditDenied, err)
+		} else {
+			s.deps.Logger.Info("session join failed", "user", user.ID, "err", err)
+		}
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditConnEvent(ctx, user, link.ConnectionID, sessionJoinEvent, plugin.RiskWrite, models.AuditAllowed, nil)
+	out := sessionJoinResponse{ConnectionID: link.ConnectionID, OwnerID: link.SessionOwner(), Mode: link.Mode, WriteHolder: p.WriteHolder}
+	if snap, ok := s.deps.Sessions.Status(session.Key{ConnectionID: link.ConnectionID, ActorScope: link.SessionOwner()}); ok {
+		out.Cols, out.Rows = snap.Cols, snap.Rows
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleSharedSessionStream relays the {ownerId} user's live session on the
+// connection to one of its participants over a WebSocket. Frames from the
+// participant are typed into the session while they hold write and dropped
+// otherwise. The socket closes with the session or when the participant is
+// removed from it.
+func (s *Server) handleSharedSessionStream(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	connID, ownerID := chi.URLParam(r, "id"), chi.URLParam(r, "ownerId")
+	// Subscribed before the check, so a removal in between is not missed.
+	removed, unsubscribe := s.deps.SessionShares.Removals(user.ID)
+	defer unsubscribe()
+	if _, err := s.deps.SessionShares.Participant(ctx, connID, ownerID, user.ID); err != nil {
+		if errors.Is(err, plugin.ErrNotFound) {
+			s.auditConnEvent(ctx, user, connID, sharedStreamEvent, plugin.RiskSafe, models.AuditDenied, err)
+		}
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	conn, err := s.deps.Store.Connections.Get(ctx, connID)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	if s.proxyIfRemoteLeaseHolder(w, r, conn, ownerID) {
+		return
+	}
+	key := session.Key{ConnectionID: connID, ActorScope: ownerID}
+	snap, ok := s.deps.Sessions.Status(key)
+	if !ok || snap.State != session.StateConnected {
+		writeError(w, s.deps.Logger, fmt.Errorf("%w: the shared session has ended", plugin.ErrNotFound))
+		return
+	}
+	_, output, detach, err := s.deps.Sessions.Observe(snap.ID)
+	if errors.Is(err, session.ErrSessionNotFound) {
+		err = fmt.Errorf("%w: the shared session has ended", plugin.ErrNotFound)
+	}
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	defer detach()
+
+	c, err := websocket.Accept(w, r, &websocket.AcceptOptions{Subprotocols: []string{"binary"}})
+	if err != nil {
+		return // Accept already wrote the response
+	}
+	s.auditConnEvent(ctx, user, connID, sharedStreamEvent, plugin.RiskSafe, models.AuditAllowed, nil)
+	msgType := websocket.MessageText
+	if c.Subprotocol() == "binary" {
+		msgType = websocket.MessageBinary
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		defer cancel()
+		s.relayParticipantInput(ctx, c, key, user.ID)
+	}()
+	recheck := time.NewTicker(participantRecheck)
+	defer recheck.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			_ = c.Close(websocket.StatusNormalClosure, "")
+			return
+		case p := <-removed.C:
+			if p.ConnectionID == connID && p.OwnerID == ownerID {
+				_ = c.Close(websocket.StatusPolicyViolation, "removed from the session")
+				return
+			}
+		case <-recheck.C:
+			if _, err := s.deps.SessionShares.Participant(ctx, connID, ownerID, user.ID); errors.Is(err, plugin.ErrNotFound) {
+				_ = c.Close(websocket.StatusPolicyViolation, "removed from the session")
+				return
+			}
+		case p, ok := <-output:
+			if !ok {
+				_ = c.Close(websocket.StatusNormalClosure, "session closed")
+				return
+			}
+			if err := c.Write(ctx, msgType, p); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// relayParticipantInput types what a participant sends into the shared
+// session while they hold write. Whether they do is read from the store at
+// most once per holderRecheck, so write passing to someone else takes effect
+// within that time.
+func (s *Server) relayParticipantInput(ctx context.Context, c *websocket.Conn, key session.Key, userID string) {
+	var (
+		holder  bool
+		checked time.Time
+	)
+	for {
+		_, p, err := c.Read(ctx)
+		if err != nil {
+			return
+		}
+		if time.Since(checked) >= holderRecheck {
+			part, err := s.deps.SessionShares.Participant(ctx, key.ConnectionID, key.ActorScope, userID)
+			if errors.Is(err, plugin.ErrNotFound) {
+				return // removed from the session
+			}
+			holder, checked = err == nil && part.WriteHolder, time.Now()
+		}
+		if !holder {
+			continue
+		}
+		if err := s.deps.Sessions.Input(key, p); err != nil && !errors.Is(err, session.ErrNoTerminal) {
+			return
+		}
+	}
+}
+
+// isParticipant reports whether userID joined a shared session on connID.
+func (s *Server) isParticipant(ctx context.Context, connID, userID string) bool {
+	if s.deps.SessionShares == nil {
+		return false
+	}
+	_, err := s.deps.SessionShares.Participation(ctx, connID, userID)
+	return err == nil
+}
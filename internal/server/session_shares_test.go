@@ -0,0 +1,596 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/session"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+func TestSessionShareLinks(t *testing.T) {
+	h := newHarness(t)
+	const links = "/api/connections/c-op/session/share-links"
+	create := func(body string) (int, string, string) {
+		t.Helper()
+		resp := h.do(t, http.MethodPost, links, "op", strings.NewReader(body))
+		var out struct {
+			Link struct {
+				ID string `json:"id"`
+			} `json:"link"`
+			Token string `json:"token"`
+		}
+		_ = json.Unmarshal(resp.Body, &out)
+		return resp.Status, out.Link.ID, out.Token
+	}
+
+	if resp := h.do(t, http.MethodPost, links, "op2", strings.NewReader(`{"mode":"read"}`)); resp.Status != http.StatusForbidden {
+		t.Fatalf("non-owner create: want 403, got %d", resp.Status)
+	}
+	if status, _, _ := create(`{"mode":"read"}`); status != http.StatusConflict {
+		t.Fatalf("create without a live session: want 409, got %d", status)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/connections/c-op/x/tester.list", "op", nil); resp.Status != http.StatusOK {
+		t.Fatalf("open session: got %d (%s)", resp.Status, resp.Body)
+	}
+	if status, _, _ := create(`{"mode":"admin"}`); status != http.StatusBadRequest {
+		t.Fatalf("bad mode: want 400, got %d", status)
+	}
+	if resp := h.do(t, http.MethodPost, "/api/connections/c-op/session/resize", "op", strings.NewReader(`{"cols":0,"rows":24}`)); resp.Status != http.StatusBadRequest {
+		t.Fatalf("bad size: want 400, got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodPost, "/api/connections/c-op/session/resize", "op", strings.NewReader(`{"cols":132,"rows":43}`)); resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `"cols":132`) {
+		t.Fatalf("resize: got %d (%s)", resp.Status, resp.Body)
+	}
+	status, linkID, token := create(`{"mode":"read","expiresInSeconds":600}`)
+	if status != http.StatusCreated || token == "" {
+		t.Fatalf("create: got %d", status)
+	}
+
+	if resp := h.do(t, http.MethodPost, "/api/sessions/join/"+token, "op", nil); resp.Status != http.StatusConflict {
+		t.Fatalf("owner join: want 409, got %d", resp.Status)
+	}
+	resp := h.do(t, http.MethodPost, "/api/sessions/join/"+token, "op2", nil)
+	if resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `"ownerId":"op","mode":"read","writeHolder":false`) || !strings.Contains(string(resp.Body), `"rows":43`) {
+		t.Fatalf("join: got %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodPost, "/api/connections/c-op/session/resize", "op2", strings.NewReader(`{"cols":80,"rows":24}`)); resp.Status != http.StatusForbidden {
+		t.Fatalf("joiner resizing the owner's session: want 403, got %d", resp.Status)
+	}
+	// Joining reaches the owner's live session only, never the connection.
+	if resp := h.do(t, http.MethodGet, "/api/connections/c-op/x/tester.list", "op2", nil); resp.Status != http.StatusForbidden {
+		t.Fatalf("joined user using the connection: want 403, got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodPost, "/api/sessions/join/"+token, "viewer", nil); resp.Status != http.StatusNotFound {
+		t.Fatalf("single-use link reused: want 404, got %d", resp.Status)
+	}
+	if grants, _ := h.store.Grants.ListByConnection(context.Background(), "c-op"); len(grants) != 0 {
+		t.Fatalf("joining must not grant the connection: %+v", grants)
+	}
+	// A refused join is audited on the link's connection; a token that
+	// matches no link has none and is not audited.
+	if resp := h.do(t, http.MethodPost, "/api/sessions/join/not-a-token", "viewer", nil); resp.Status != http.StatusNotFound {
+		t.Fatalf("unknown token: want 404, got %d", resp.Status)
+	}
+	waitForAudit(t, h, func(e models.AuditEntry) bool {
+		return e.Event == "session.join" && e.Result == models.AuditDenied && e.UserID == "viewer" && e.ConnectionID == "c-op"
+	})
+	if rows, _ := h.store.Audit.List(context.Background(), store.AuditFilter{}); slices.ContainsFunc(rows, func(e models.AuditEntry) bool {
+		return e.Event == "session.join" && e.ConnectionID == ""
+	}) {
+		t.Fatalf("join audited without a connection: %+v", rows)
+	}
+	if p, err := h.store.SessionParticipants.Get(context.Background(), "c-op", "op", "op2"); err != nil || p.ShareLinkID != linkID {
+		t.Fatalf("participant should record the link: %+v %v", p, err)
+	}
+
+	_, spareID, spare := create(`{"mode":"write","maxUses":3}`)
+	if resp := h.do(t, http.MethodDelete, links+"/"+spareID, "op", nil); resp.Status != http.StatusOK {
+		t.Fatalf("revoke: got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodPost, "/api/sessions/join/"+spare, "viewer", nil); resp.Status != http.StatusNotFound {
+		t.Fatalf("revoked link: want 404, got %d", resp.Status)
+	}
+
+	// Closing the owner's session withdraws the links and its participants.
+	_, _, pending := create(`{"mode":"read","maxUses":5}`)
+	h.pluginSessions.CloseConnection("c-op")
+	if resp := h.do(t, http.MethodPost, "/api/sessions/join/"+pending, "viewer", nil); resp.Status != http.StatusNotFound {
+		t.Fatalf("link after session close: want 404, got %d", resp.Status)
+	}
+	if _, err := h.dialWS(t, "op2", "/api/connections/c-op/session/shared/op/stream"); err == nil {
+		t.Fatal("joined user streaming after close must fail")
+	}
+	resp = h.do(t, http.MethodGet, links, "op", nil)
+	var list []struct {
+		Status string `json:"status"`
+	}
+	_ = json.Unmarshal(resp.Body, &list)
+	if len(list) != 3 {
+		t.Fatalf("list: %s", resp.Body)
+	}
+	for _, l := range list {
+		if l.Status == "active" {
+			t.Fatalf("no link should stay active: %s", resp.Body)
+		}
+	}
+}
+
+func TestWriteHolderCanShareSession(t *testing.T) {
+	h := newHarness(t)
+	const links = "/api/connections/c-op/session/share-links"
+	create := func(user, body string) (int, string) {
+		t.Helper()
+		resp := h.do(t, http.MethodPost, links, user, strings.NewReader(body))
+		var out struct {
+			Token string `json:"token"`
+		}
+		_ = json.Unmarshal(resp.Body, &out)
+		return resp.Status, out.Token
+	}
+	if resp := h.do(t, http.MethodGet, "/api/connections/c-op/x/tester.list", "op", nil); resp.Status != http.StatusOK {
+		t.Fatalf("open session: got %d (%s)", resp.Status, resp.Body)
+	}
+	_, write := create("op", `{"mode":"write"}`)
+	if resp := h.do(t, http.MethodPost, "/api/sessions/join/"+write, "op2", nil); resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `"writeHolder":true`) {
+		t.Fatalf("join for write: got %d (%s)", resp.Status, resp.Body)
+	}
+
+	status, read := create("op2", `{"mode":"read"}`)
+	if status != http.StatusCreated {
+		t.Fatalf("write holder create: want 201, got %d", status)
+	}
+	resp := h.do(t, http.MethodPost, "/api/sessions/join/"+read, "viewer", nil)
+	if resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `"ownerId":"op"`) {
+		t.Fatalf("join through the holder's link: got %d (%s)", resp.Status, resp.Body)
+	}
+	if p, err := h.store.SessionParticipants.Get(context.Background(), "c-op", "op", "viewer"); err != nil || p.WriteHolder {
+		t.Fatalf("participant of the owner's session = %+v, %v", p, err)
+	}
+	if status, _ := create("viewer", `{"mode":"read"}`); status != http.StatusForbidden {
+		t.Fatalf("read-only participant create: want 403, got %d", status)
+	}
+}
+
+func TestSessionPresenceReachesJoinedViewers(t *testing.T) {
+	h := newHarness(t)
+	if resp := h.do(t, http.MethodGet, "/api/connections/c-op/x/tester.list", "op", nil); resp.Status != http.StatusOK {
+		t.Fatalf("open session: got %d (%s)", resp.Status, resp.Body)
+	}
+	const presence = "/api/connections/c-op/session/presence"
+	if resp := h.do(t, http.MethodPost, presence, "op2", strings.NewReader(`{"typing":true}`)); resp.Status != http.StatusForbidden {
+		t.Fatalf("presence without access: want 403, got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodPost, presence, "op", strings.NewReader(`{"typing":true,"cursor":{"row":3,"col":7}}`)); resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `"sent":true`) {
+		t.Fatalf("publish presence: got %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodPost, presence, "op", strings.NewReader(`{"cursor":{"row":-1,"col":0}}`)); resp.Status != http.StatusBadRequest {
+		t.Fatalf("bad cursor: want 400, got %d", resp.Status)
+	}
+
+	resp := h.do(t, http.MethodPost, "/api/connections/c-op/session/share-links", "op", strings.NewReader(`{"mode":"read"}`))
+	var link struct {
+		Token string `json:"token"`
+	}
+	_ = json.Unmarshal(resp.Body, &link)
+	if resp := h.do(t, http.MethodPost, "/api/sessions/join/"+link.Token, "op2", nil); resp.Status != http.StatusOK {
+		t.Fatalf("join: got %d (%s)", resp.Status, resp.Body)
+	}
+
+	// A late joiner sees current presence in the session status and as the
+	// first events of the stream.
+	if resp := h.do(t, http.MethodGet, "/api/connections/c-op/session", "op2", nil); !strings.Contains(string(resp.Body), `"presence":[{"userId":"op","typing":true,"cursor":{"row":3,"col":7}`) {
+		t.Fatalf("session status should carry presence: %s", resp.Body)
+	}
+	c, err := h.dialWS(t, "op2", presence)
+	if err != nil {
+		t.Fatalf("dial presence: %v", err)
+	}
+	defer func() { _ = c.CloseNow() }()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var ev session.PresenceEvent
+	if err := wsjson.Read(ctx, c, &ev); err != nil || ev.Type != "session.presence" || ev.UserID != "op" || !ev.State.Typing {
+		t.Fatalf("initial presence = %+v, %v", ev, err)
+	}
+
+	// The owner leaving clears their presence for everyone.
+	h.pluginSessions.CloseConnection("c-op")
+	if err := wsjson.Read(ctx, c, &ev); err != nil || ev.UserID != "op" || !ev.Left {
+		t.Fatalf("leave event = %+v, %v", ev, err)
+	}
+}
+
+// termSess is a fakeSess whose terminal channel records what is typed.
+type termSess struct {
+	fakeSess
+	term *captureTerm
+}
+
+func (s *termSess) OpenChannel(context.Context, plugin.ChannelRequest) (plugin.Channel, error) {
+	return s.term, nil
+}
+
+type captureTerm struct {
+	mu    sync.Mutex
+	typed strings.Builder
+	done  chan struct{}
+}
+
+func (c *captureTerm) Read([]byte) (int, error) { <-c.done; return 0, io.EOF }
+func (c *captureTerm) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.typed.Write(p)
+}
+func (c *captureTerm) Close() error            { return nil }
+func (c *captureTerm) Kind() plugin.StreamKind { return plugin.StreamTerminal }
+func (c *captureTerm) Resize(int, int) error   { return nil }
+func (c *captureTerm) Typed() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.typed.String()
+}
+
+func TestSessionWriteRequestFlow(t *testing.T) {
+	h := newHarness(t)
+	term := &captureTerm{done: make(chan struct{})}
+	defer close(term.done)
+	handle, err := h.pluginSessions.Acquire(context.Background(), session.Key{ConnectionID: "c-op", ActorScope: "op"}, "op",
+		func(context.Context) (plugin.Session, error) { return &termSess{term: term}, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := handle.OpenChannel(context.Background(), plugin.ChannelRequest{Kind: plugin.StreamTerminal}); err != nil {
+		t.Fatal(err)
+	}
+	resp := h.do(t, http.MethodPost, "/api/connections/c-op/session/share-links", "op", strings.NewReader(`{"mode":"read"}`))
+	var link struct {
+		Token string `json:"token"`
+	}
+	_ = json.Unmarshal(resp.Body, &link)
+	if resp := h.do(t, http.MethodPost, "/api/sessions/join/"+link.Token, "op2", nil); resp.Status != http.StatusOK {
+		t.Fatalf("join: got %d", resp.Status)
+	}
+	events, err := h.dialWS(t, "op", "/api/me/events")
+	if err != nil {
+		t.Fatalf("dial events: %v", err)
+	}
+	defer events.CloseNow()
+	shared, err := h.dialWSWithSubprotocol(t, "op2", "/api/connections/c-op/session/shared/op/stream", "binary")
+	if err != nil {
+		t.Fatalf("dial shared stream: %v", err)
+	}
+	defer shared.CloseNow()
+	if _, err := h.dialWS(t, "viewer", "/api/connections/c-op/session/shared/op/stream"); err == nil {
+		t.Fatal("non-participant must not stream the session")
+	}
+
+	const requests = "/api/connections/c-op/session/write-requests"
+	if resp := h.do(t, http.MethodPost, requests, "viewer", nil); resp.Status != http.StatusForbidden {
+		t.Fatalf("non-participant request: want 403, got %d", resp.Status)
+	}
+	resp = h.do(t, http.MethodPost, requests, "op2", nil)
+	var req struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	_ = json.Unmarshal(resp.Body, &req)
+	if resp.Status != http.StatusAccepted || req.Status != "pending" {
+		t.Fatalf("request: got %d (%s)", resp.Status, resp.Body)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var ev struct {
+		Type         string `json:"type"`
+		WriteRequest struct {
+			ID     string `json:"id"`
+			UserID string `json:"userId"`
+		} `json:"writeRequest"`
+	}
+	if err := wsjson.Read(ctx, events, &ev); err != nil || ev.Type != "writeRequest" || ev.WriteRequest.ID != req.ID || ev.WriteRequest.UserID != "op2" {
+		t.Fatalf("owner event = %+v, %v", ev, err)
+	}
+	resp = h.do(t, http.MethodGet, "/api/connections/c-op/session", "op", nil)
+	if !strings.Contains(string(resp.Body), `"writeRequests":[{"id":"`+req.ID+`"`) {
+		t.Fatalf("session status should list the pending request: %s", resp.Body)
+	}
+
+	if err := shared.Write(ctx, websocket.MessageBinary, []byte("early")); err != nil {
+		t.Fatal(err)
+	}
+	if resp := h.do(t, http.MethodPost, requests+"/"+req.ID+"/approve", "op2", nil); resp.Status != http.StatusForbidden {
+		t.Fatalf("requester approving: want 403, got %d", resp.Status)
+	}
+	resp = h.do(t, http.MethodPost, requests+"/"+req.ID+"/approve", "op", strings.NewReader(`{}`))
+	if resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `"status":"approved"`) {
+		t.Fatalf("approve: got %d (%s)", resp.Status, resp.Body)
+	}
+	if grants, _ := h.store.Grants.ListByConnection(ctx, "c-op"); len(grants) != 0 {
+		t.Fatalf("approval must not grant the connection: %+v", grants)
+	}
+	// Holding write is picked up by the open stream within a recheck.
+	for deadline := time.Now().Add(3 * time.Second); !strings.Contains(term.Typed(), "ls\r"); time.Sleep(50 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("write after approval never reached the terminal: %q", term.Typed())
+		}
+		_ = shared.Write(ctx, websocket.MessageBinary, []byte("ls\r"))
+	}
+	if strings.Contains(term.Typed(), "early") {
+		t.Fatalf("input before approval reached the terminal: %q", term.Typed())
+	}
+	if resp := h.do(t, http.MethodPost, requests+"/"+req.ID+"/deny", "op", nil); resp.Status != http.StatusNotFound {
+		t.Fatalf("answering twice: want 404, got %d", resp.Status)
+	}
+	waitForAudit(t, h, func(e models.AuditEntry) bool {
+		return e.Event == "session.write_request.approve" && e.Result == models.AuditAllowed && e.UserID == "op"
+	})
+}
+
+func TestRevokingShareLinkClosesSharedStream(t *testing.T) {
+	h := newHarness(t)
+	term := &captureTerm{done: make(chan struct{})}
+	defer close(term.done)
+	handle, err := h.pluginSessions.Acquire(context.Background(), session.Key{ConnectionID: "c-op", ActorScope: "op"}, "op",
+		func(context.Context) (plugin.Session, error) { return &termSess{term: term}, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := handle.OpenChannel(context.Background(), plugin.ChannelRequest{Kind: plugin.StreamTerminal}); err != nil {
+		t.Fatal(err)
+	}
+	const links = "/api/connections/c-op/session/share-links"
+	resp := h.do(t, http.MethodPost, links, "op", strings.NewReader(`{"mode":"read"}`))
+	var link struct {
+		Link struct {
+			ID string `json:"id"`
+		} `json:"link"`
+		Token string `json:"token"`
+	}
+	_ = json.Unmarshal(resp.Body, &link)
+	if resp := h.do(t, http.MethodPost, "/api/sessions/join/"+link.Token, "op2", nil); resp.Status != http.StatusOK {
+		t.Fatalf("join: got %d", resp.Status)
+	}
+	shared, err := h.dialWSWithSubprotocol(t, "op2", "/api/connections/c-op/session/shared/op/stream", "binary")
+	if err != nil {
+		t.Fatalf("dial shared stream: %v", err)
+	}
+	defer shared.CloseNow()
+
+	if resp := h.do(t, http.MethodDelete, links+"/"+link.Link.ID, "op", nil); resp.Status != http.StatusOK {
+		t.Fatalf("revoke: got %d", resp.Status)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if _, _, err := shared.Read(ctx); websocket.CloseStatus(err) != websocket.StatusPolicyViolation {
+		t.Fatalf("stream after revoke: want policy-violation close, got %v", err)
+	}
+}
+
+func TestObserveSessionIsReadOnlyAndDisclosed(t *testing.T) {
+	h := newHarness(t)
+	if resp := h.do(t, http.MethodPost, "/api/connections/c-op/session?wait=true", "op", nil); resp.Status != http.StatusOK {
+		t.Fatalf("open session: %d (%s)", resp.Status, resp.Body)
+	}
+	key := session.Key{ConnectionID: "c-op", ActorScope: "op"}
+	handle, err := h.pluginSessions.Acquire(context.Background(), key, "op",
+		func(context.Context) (plugin.Session, error) { return nil, errors.New("must reuse the open session") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	observe := "/api/sessions/" + handle.Snapshot().ID + "/observe"
+	if _, err := h.dialWS(t, "viewer", observe); err == nil {
+		t.Fatal("viewer must not observe")
+	}
+	waitForAudit(t, h, func(row models.AuditEntry) bool {
+		return row.RouteID == "session.observe" && row.Result == models.AuditDenied && row.UserID == "viewer"
+	})
+	if _, err := h.dialWS(t, "admin", "/api/sessions/missing/observe"); err == nil {
+		t.Fatal("observing an unknown session must fail")
+	}
+
+	events, err := h.dialWS(t, "op", "/api/me/events")
+	if err != nil {
+		t.Fatalf("dial events: %v", err)
+	}
+	defer events.CloseNow()
+	observer, err := h.dialWS(t, "admin", observe)
+	if err != nil {
+		t.Fatalf("observe: %v", err)
+	}
+	defer observer.CloseNow()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var ev struct {
+		Type        string `json:"type"`
+		Observation struct {
+			ID         string `json:"id"`
+			ObserverID string `json:"observerId"`
+			Active     bool   `json:"active"`
+		} `json:"observation"`
+	}
+	if err := wsjson.Read(ctx, events, &ev); err != nil || ev.Type != "observation" || ev.Observation.ObserverID != "admin" || !ev.Observation.Active {
+		t.Fatalf("owner notice: %+v err=%v", ev, err)
+	}
+
+	_, _ = handle.MeterStream(discardStream{strings.NewReader("")}).Write([]byte("uptime\r\n"))
+	if _, p, err := observer.Read(ctx); err != nil || string(p) != "uptime\r\n" {
+		t.Fatalf("observed %q err=%v", p, err)
+	}
+	if snap, _ := h.pluginSessions.Status(key); snap.Streams != 0 {
+		t.Fatalf("observers must not count as streams: %+v", snap)
+	}
+
+	if resp := h.do(t, http.MethodDelete, "/api/connections/c-op/session", "op", nil); resp.Status != http.StatusOK {
+		t.Fatalf("close session: %d (%s)", resp.Status, resp.Body)
+	}
+	if _, _, err := observer.Read(ctx); websocket.CloseStatus(err) != websocket.StatusNormalClosure {
+		t.Fatalf("observer after close: %v", err)
+	}
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		row, err := h.store.SessionObservations.Get(context.Background(), ev.Observation.ID)
+		if err == nil && row.EndedAt != nil && row.Disclosed && row.OwnerID == "op" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("observation row: %+v err=%v", row, err)
+		}
+	}
+}
+
+func TestSessionScrollbackOwnerAndObserver(t *testing.T) {
+	h := newHarness(t)
+	if resp := h.do(t, http.MethodPost, "/api/connections/c-op/session?wait=true", "op", nil); resp.Status != http.StatusOK {
+		t.Fatalf("open session: %d (%s)", resp.Status, resp.Body)
+	}
+	key := session.Key{ConnectionID: "c-op", ActorScope: "op"}
+	handle, err := h.pluginSessions.Acquire(context.Background(), key, "op",
+		func(context.Context) (plugin.Session, error) { return nil, errors.New("must reuse the open session") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := "/api/sessions/" + handle.Snapshot().ID + "/scrollback"
+	if resp := h.do(t, http.MethodGet, base, "op", nil); resp.Status != http.StatusNotFound {
+		t.Fatalf("scrollback before any terminal output: %d (%s)", resp.Status, resp.Body)
+	}
+	stream := handle.CaptureScrollback(discardStream{strings.NewReader("")})
+	_, _ = stream.Write([]byte("$ cat /etc/hostname\r\nweb-1\r\n$ "))
+
+	var page struct {
+		Next  int `json:"next"`
+		Lines []struct {
+			Line int    `json:"line"`
+			Raw  string `json:"raw"`
+		} `json:"lines"`
+	}
+	resp := h.do(t, http.MethodGet, base+"?lines=2", "op", nil)
+	if resp.Status != http.StatusOK || json.Unmarshal(resp.Body, &page) != nil || len(page.Lines) != 2 || page.Lines[0].Raw != "web-1\r" {
+		t.Fatalf("owner page: %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodGet, base+"/search?q=HOSTNAME", "viewer", nil); resp.Status != http.StatusForbidden {
+		t.Fatalf("viewer search: %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodGet, "/api/sessions/missing/scrollback", "viewer", nil); resp.Status != http.StatusForbidden {
+		t.Fatalf("viewer probing an unknown session: %d (%s)", resp.Status, resp.Body)
+	}
+	var found struct {
+		Matches []struct {
+			Line int `json:"line"`
+		} `json:"matches"`
+	}
+	resp = h.do(t, http.MethodGet, base+"/search?q=HOSTNAME", "admin", nil)
+	if resp.Status != http.StatusOK || json.Unmarshal(resp.Body, &found) != nil || len(found.Matches) != 1 || found.Matches[0].Line != 0 {
+		t.Fatalf("admin search: %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodGet, base+"/search?q=(&regex=true", "admin", nil); resp.Status != http.StatusBadRequest {
+		t.Fatalf("bad regex: %d (%s)", resp.Status, resp.Body)
+	}
+	waitForAudit(t, h, func(row models.AuditEntry) bool {
+		return row.RouteID == "session.scrollback" && row.Result == models.AuditAllowed && row.UserID == "admin"
+	})
+
+	if resp := h.do(t, http.MethodDelete, "/api/connections/c-op/session", "op", nil); resp.Status != http.StatusOK {
+		t.Fatalf("close session: %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodGet, base, "op", nil); resp.Status != http.StatusNotFound {
+		t.Fatalf("scrollback after close: %d (%s)", resp.Status, resp.Body)
+	}
+}
+
+// clipboardSess is a fakeSess that syncs a clipboard.
+type clipboardSess struct {
+	fakeSess
+	pushed   atomic.Value
+	onRemote func(string)
+}
+
+func (c *clipboardSess) SetClipboard(_ context.Context, text string) error {
+	c.pushed.Store(text)
+	return nil
+}
+
+func (c *clipboardSess) OnClipboard(fn func(string)) { c.onRemote = fn }
+
+func TestSessionClipboardPolicyAndAudit(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+	_ = h.store.Grants.Create(ctx, &models.Grant{ID: "g1", ConnectionID: "c-op", SubjectID: "viewer", Access: models.AccessView})
+	open := func(user string) (*clipboardSess, string) {
+		t.Helper()
+		cs := &clipboardSess{}
+		handle, err := h.pluginSessions.Acquire(ctx, session.Key{ConnectionID: "c-op", ActorScope: user}, user,
+			func(context.Context) (plugin.Session, error) { return cs, nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cs, "/api/sessions/" + handle.Snapshot().ID + "/clipboard"
+	}
+	cs, path := open("op")
+
+	if resp := h.do(t, http.MethodPut, path, "op", strings.NewReader(`{"text":"s3cret"}`)); resp.Status != http.StatusNoContent || cs.pushed.Load() != "s3cret" {
+		t.Fatalf("push: %d (%s)", resp.Status, resp.Body)
+	}
+	big := `{"text":"` + strings.Repeat("x", session.DefaultClipboardBytes+1) + `"}`
+	if resp := h.do(t, http.MethodPut, path, "op", strings.NewReader(big)); resp.Status != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized push: %d (%s)", resp.Status, resp.Body)
+	}
+	cs.onRemote("from remote")
+	var clip struct {
+		Text string `json:"text"`
+	}
+	resp := h.do(t, http.MethodGet, path, "op", nil)
+	if resp.Status != http.StatusOK || json.Unmarshal(resp.Body, &clip) != nil || clip.Text != "from remote" {
+		t.Fatalf("pull: %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodGet, path, "admin", nil); resp.Status != http.StatusNotFound {
+		t.Fatalf("another user's clipboard: %d (%s)", resp.Status, resp.Body)
+	}
+	// Another user is refused before the body is read.
+	if resp := h.do(t, http.MethodPut, path, "admin", strings.NewReader(big)); resp.Status != http.StatusNotFound {
+		t.Fatalf("push to another user's clipboard: %d (%s)", resp.Status, resp.Body)
+	}
+	waitForAudit(t, h, func(row models.AuditEntry) bool {
+		return row.RouteID == "session.clipboard.push" && row.Result == models.AuditAllowed &&
+			row.Params["direction"] == "push" && row.Params["bytes"] == "6" && !strings.Contains(row.Error, "s3cret")
+	})
+
+	// A participant holding a view grant may read but not push.
+	_, viewerPath := open("viewer")
+	if resp := h.do(t, http.MethodGet, viewerPath, "viewer", nil); resp.Status != http.StatusOK {
+		t.Fatalf("viewer pull: %d (%s)", resp.Status, resp.Body)
+	}
+	if resp := h.do(t, http.MethodPut, viewerPath, "viewer", strings.NewReader(`{"text":"x"}`)); resp.Status != http.StatusForbidden {
+		t.Fatalf("viewer push: %d (%s)", resp.Status, resp.Body)
+	}
+
+	conn, _ := h.store.Connections.Get(ctx, "c-op")
+	conn.ClipboardDisabled = true
+	if err := h.store.Connections.Update(ctx, &conn); err != nil {
+		t.Fatal(err)
+	}
+	resp = h.do(t, http.MethodGet, path, "op", nil)
+	if resp.Status != http.StatusForbidden || !strings.Contains(string(resp.Body), "clipboard_disabled") {
+		t.Fatalf("pull with clipboard disabled: %d (%s)", resp.Status, resp.Body)
+	}
+
+	h.pluginSessions.Close(session.Key{ConnectionID: "c-op", ActorScope: "op"})
+	if resp := h.do(t, http.MethodGet, path, "op", nil); resp.Status != http.StatusNotFound {
+		t.Fatalf("pull after close: %d (%s)", resp.Status, resp.Body)
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/audit"
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	writeRequestEvent        = "session.write_request"
+	writeRequestApproveEvent = "session.write_request.approve"
+	writeRequestDenyEvent    = "session.write_request.deny"
+
+	maxWriteRequestReason = 500
+)
+
+type writeRequestDecision struct {
+	Reason string `json:"reason"`
+}
+
+// handleRequestWriteAccess lets a read-only participant of a shared session
+// ask its owner for write access.
+func (s *Server) handleRequestWriteAccess(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	connID := chi.URLParam(r, "id")
+	req, err := s.deps.SessionShares.RequestWrite(ctx, connID, user.ID)
+	result, params := models.AuditAllowed, map[string]string{}
+	switch {
+	case errors.Is(err, plugin.ErrForbidden):
+		result = models.AuditDenied
+	case err != nil:
+		result = models.AuditError
+	default:
+		params["request"] = req.ID
+	}
+	s.deps.Audit.Record(ctx, audit.Event{
+		User: user, Event: writeRequestEvent, ConnectionID: connID, RouteID: writeRequestEvent,
+		Risk: string(plugin.RiskWrite), Result: result, Params: params, Err: err,
+	})
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, req)
+}
+
+func (s *Server) handleApproveWriteRequest(w http.ResponseWriter, r *http.Request) {
+	s.decideWriteRequest(w, r, true, writeRequestApproveEvent)
+}
+
+func (s *Server) handleDenyWriteRequest(w http.ResponseWriter, r *http.Request) {
+	s.decideWriteRequest(w, r, false, writeRequestDenyEvent)
+}
+
+func (s *Server) decideWriteRequest(w http.ResponseWriter, r *http.Request, approve bool, event string) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	connID, id := chi.URLParam(r, "id"), chi.URLParam(r, "requestId")
+	var body writeRequestDecision
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	if len(body.Reason) > maxWriteRequestReason {
+		writeError(w, s.deps.Logger, fmt.Errorf("%w: reason must be at most %d characters", plugin.ErrInvalidInput, maxWriteRequestReason))
+		return
+	}
+	req, err := s.deps.SessionShares.DecideWrite(ctx, connID, id, user.ID, approve, body.Reason)
+	result, params := models.AuditAllowed, map[string]string{"request": id}
+	switch {
+	case errors.Is(err, plugin.ErrForbidden):
+		result = models.AuditDenied
+	case err != nil:
+		result = models.AuditError
+	default:
+		params["participant"] = req.UserID
+		if req.Reason != "" {
+			params["reason"] = req.Reason
+		}
+	}
+	s.deps.Audit.Record(ctx, audit.Event{
+		User: user, Event: event, ConnectionID: connID, RouteID: event,
+		Risk: string(plugin.RiskWrite), Result: result, Params: params, Err: err,
+	})
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, req)
+}
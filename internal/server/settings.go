@@ -0,0 +1,60 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const settingsUpdateEvent = "system.settings.update"
+
+const maxSettingsBody = 64 << 10
+
+type settingsDTO struct {
+	Settings []service.SettingView `json:"settings"`
+}
+
+func (s *Server) handleListSettings(w http.ResponseWriter, r *http.Request) {
+	views, err := s.deps.Settings.Views(r.Context())
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, settingsDTO{Settings: views})
+}
+
+// handleUpdateSettings writes a {key: value} map of registered settings. The
+// whole body is validated before anything is stored. Only the changed keys
+// are audited, never their values.
+func (s *Server) handleUpdateSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actor, _ := userFrom(ctx)
+	var req map[string]json.RawMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSettingsBody)).Decode(&req); err != nil || len(req) == 0 {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	keys := make([]string, 0, len(req))
+	for k := range req {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	params := map[string]string{"keys": strings.Join(keys, ",")}
+	if err := s.deps.Settings.Update(ctx, actor, req); err != nil {
+		result := models.AuditError
+		if errors.Is(err, plugin.ErrForbidden) {
+			result = models.AuditDenied
+		}
+		s.auditAdminEvent(ctx, actor, settingsUpdateEvent, result, params, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditAdminEvent(ctx, actor, settingsUpdateEvent, models.AuditAllowed, params, nil)
+	s.handleListSettings(w, r)
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/audit"
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	connGrantExtendEvent = "connection.grant.extend"
+
+	defaultExpiringDays = 7
+	maxExpiringDays     = 365
+)
+
+type expiringSharesDTO struct {
+	ConnectionID   string     `json:"connectionId"`
+	ConnectionName string     `json:"connectionName"`
+	Shares         []grantDTO `json:"shares"`
+}
+
+type extendSharesRequest struct {
+	GrantIDs  []string  `json:"grantIds"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// handleExpiringShares lists the caller's connection shares that lapse within
+// ?within=<n>d, grouped by connection.
+func (s *Server) handleExpiringShares(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	days, err := parseDays("within", r.URL.Query().Get("within"), defaultExpiringDays, maxExpiringDays)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	groups, err := s.deps.GrantExpiry.Expiring(ctx, user.ID, time.Duration(days)*24*time.Hour)
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	out := make([]expiringSharesDTO, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, expiringSharesDTO{
+			ConnectionID: g.Connection.ID, ConnectionName: g.Connection.Name, Shares: s.connectionGrantDTOs(ctx, g.Grants),
+		})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleExtendShares moves a batch of the caller's connection shares to a new
+// expiry, all or nothing, and audits once per connection.
+func (s *Server) handleExtendShares(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, _ := userFrom(ctx)
+	var req extendSharesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	grants, err := s.deps.GrantExpiry.Extend(ctx, user, req.GrantIDs, req.ExpiresAt)
+	if err != nil {
+		s.auditConnEvent(ctx, user, "", connGrantExtendEvent, plugin.RiskWrite, auditOutcome(err), err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	batchCtx, batch := audit.BeginBatch(ctx)
+	defer batch.Finish(batchCtx)
+	audited := map[string]bool{}
+	for _, g := range grants {
+		if !audited[g.ConnectionID] {
+			audited[g.ConnectionID] = true
+			s.auditConnEvent(batchCtx, user, g.ConnectionID, connGrantExtendEvent, plugin.RiskWrite, models.AuditAllowed, nil)
+		}
+	}
+	writeJSON(w, http.StatusOK, s.connectionGrantDTOs(ctx, grants))
+}
+
+// auditOutcome records a forbidden error as denied and any other as an error.
+func auditOutcome(err error) models.AuditResult {
+	if errors.Is(err, plugin.ErrForbidden) || errors.Is(err, models.ErrForbidden) {
+		return models.AuditDenied
+	}
+	return models.AuditError
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/coder/websocket"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+)
+
+// User event types on the /me/events stream.
+const (
+	userEventJob         = "job"
+	userEventPreferences = "preferences"
+	userEventWrite       = "writeRequest"
+	userEventObservation = "observation"
+	userEventShareExpiry = "shareExpiry"
+	userEventLaunch      = "sessionLaunch"
+	userEventLagged      = "lagged"
+)
+
+const (
+	userEventsStream = "user_events"
+	// maxUserEventBytes bounds one message on the stream. A larger event is
+	// sent without its payload and marked truncated, so the client refetches.
+	maxUserEventBytes = 64 << 10
+)
+
+// userEventDTO is one message on the caller's realtime stream; the field
+// named by Type is set.
+type userEventDTO struct {
+	Type         string                      `json:"type"`
+	Job          *jobDTO                     `json:"job,omitempty"`
+	Preferences  *service.UserPreferences    `json:"preferences,omitempty"`
+	WriteRequest *models.SessionWriteRequest `json:"writeRequest,omitempty"`
+	Observation  *service.ObservationNotice  `json:"observation,omitempty"`
+	ShareExpiry  *service.ShareExpiryNotice  `json:"shareExpiry,omitempty"`
+	Launch       *service.LaunchEvent        `json:"sessionLaunch,omitempty"`
+	Lagged       *userEventLagDTO            `json:"lagged,omitempty"`
+	// Truncated is set instead of the payload when it was too large to send.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// userEventLagDTO tells the client it missed updates of one type and should
+// refetch that state.
+type userEventLagDTO struct {
+	Of      string `json:"of"`
+	Dropped int64  `json:"dropped"`
+}
+
+// handleUserEvents streams the caller's own updates (job progress, preference
+// changes from other tabs, shared-session write requests, observers of the
+// caller's sessions, connection shares about to lapse, sessions connecting
+// in the background) over a WebSocket. A client that reads too slowly loses
+// the oldest queued updates and is sent a lagged event for each type that
+// lost some.
+func (s *Server) handleUserEvents(w http.ResponseWriter, r *http.Request) {
+	user, _ := userFrom(r.Context())
+	c, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return // Accept already wrote the response
+	}
+	var (
+		jobs   service.Feed[models.Job]
+		prefs  service.Feed[service.UserPreferences]
+		writes service.Feed[models.SessionWriteRequest]
+		obs    service.Feed[service.ObservationNotice]
+		shares service.Feed[service.ShareExpiryNotice]
+		starts service.Feed[service.LaunchEvent]
+	)
+	if s.deps.Jobs != nil {
+		var cancel func()
+		jobs, cancel = s.deps.Jobs.Subscribe(user.ID)
+		defer cancel()
+	}
+	if s.deps.Preferences != nil {
+		var cancel func()
+		prefs, cancel = s.deps.Preferences.Subscribe(user.ID)
+		defer cancel()
+	}
+	if s.deps.SessionShares != nil {
+		var cancel func()
+		writes, cancel = s.deps.SessionShares.SubscribeWrites(user.ID)
+		defer cancel()
+	}
+	if s.deps.Observations != nil {
+		var cancel func()
+		obs, cancel = s.deps.Observations.SubscribeNotices(user.ID)
+		defer cancel()
+	}
+	if s.deps.GrantExpiry != nil {
+		var cancel func()
+		shares, cancel = s.deps.GrantExpiry.SubscribeNotices(user.ID)
+		defer cancel()
+	}
+	if s.deps.Launches != nil {
+		var cancel func()
+		starts, cancel = s.deps.Launches.Subscribe(user.ID)
+		defer cancel()
+	}
+	ctx := c.CloseRead(r.Context())
+	for {
+		var ev userEventDTO
+		select {
+		case <-ctx.Done():
+			_ = c.Close(websocket.StatusNormalClosure, "")
+			return
+		case j := <-jobs.C:
+			d := s.toJobDTO(j)
+			ev = userEventDTO{Type: userEventJob, Job: &d}
+		case p := <-prefs.C:
+			ev = userEventDTO{Type: userEventPreferences, Preferences: &p}
+		case wr := <-writes.C:
+			ev = userEventDTO{Type: userEventWrite, WriteRequest: &wr}
+		case o := <-obs.C:
+			ev = userEventDTO{Type: userEventObservation, Observation: &o}
+		case n := <-shares.C:
+			ev = userEventDTO{Type: userEventShareExpiry, ShareExpiry: &n}
+		case l := <-starts.C:
+			ev = userEventDTO{Type: userEventLaunch, Launch: &l}
+		}
+		lags := []userEventLagDTO{
+			{Of: userEventJob, Dropped: jobs.Dropped()},
+			{Of: userEventPreferences, Dropped: prefs.Dropped()},
+			{Of: userEventWrite, Dropped: writes.Dropped()},
+			{Of: userEventObservation, Dropped: obs.Dropped()},
+			{Of: userEventShareExpiry, Dropped: shares.Dropped()},
+			{Of: userEventLaunch, Dropped: starts.Dropped()},
+		}
+		for _, lag := range lags {
+			if lag.Dropped == 0 {
+				continue
+			}
+			if s.deps.Metrics != nil {
+				s.deps.Metrics.AddEventsDropped(userEventsStream, lag.Dropped)
+			}
+			if err := s.writeUserEvent(ctx, c, userEventDTO{Type: userEventLagged, Lagged: &lag}); err != nil {
+				return
+			}
+		}
+		if err := s.writeUserEvent(ctx, c, ev); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) writeUserEvent(ctx context.Context, c *websocket.Conn, ev userEventDTO) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxUserEventBytes {
+		s.deps.Logger.Warn("user event truncated", "type", ev.Type, "bytes", len(data))
+		if s.deps.Metrics != nil {
+			s.deps.Metrics.IncEventTruncated(userEventsStream)
+		}
+		if data, err = json.Marshal(userEventDTO{Type: ev.Type, Truncated: true}); err != nil {
+			return err
+		}
+	}
+	return c.Write(ctx, websocket.MessageText, data)
+}
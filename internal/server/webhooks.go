@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	webhookCreateEvent = "webhook.create"
+	webhookUpdateEvent = "webhook.update"
+	webhookDeleteEvent = "webhook.delete"
+	webhookTestEvent   = "webhook.test"
+)
+
+type webhookCreateResponse struct {
+	Webhook models.WebhookSummary `json:"webhook"`
+	// Secret is set only when the server generated it; it is not shown again.
+	Secret string `json:"secret,omitempty"`
+}
+
+func (s *Server) handleAdminListWebhooks(w http.ResponseWriter, r *http.Request) {
+	list, err := s.deps.Webhooks.List(r.Context())
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	out := make([]models.WebhookSummary, 0, len(list))
+	for _, h := range list {
+		out = append(out, h.Summary())
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleAdminCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actor, _ := userFrom(ctx)
+	var req service.WebhookInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	hook, secret, err := s.deps.Webhooks.Create(ctx, req, actor.ID)
+	if err != nil {
+		s.auditAdminEvent(ctx, actor, webhookCreateEvent, models.AuditError, nil, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditAdminEvent(ctx, actor, webhookCreateEvent, models.AuditAllowed, map[string]string{"id": hook.ID, "url": hook.URL}, nil)
+	writeJSON(w, http.StatusCreated, webhookCreateResponse{Webhook: hook.Summary(), Secret: secret})
+}
+
+func (s *Server) handleAdminUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actor, _ := userFrom(ctx)
+	id := chi.URLParam(r, "id")
+	var req service.WebhookInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, s.deps.Logger, plugin.ErrInvalidInput)
+		return
+	}
+	hook, err := s.deps.Webhooks.Update(ctx, id, req)
+	if err != nil {
+		s.auditAdminEvent(ctx, actor, webhookUpdateEvent, models.AuditError, map[string]string{"id": id}, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditAdminEvent(ctx, actor, webhookUpdateEvent, models.AuditAllowed, map[string]string{"id": id, "url": hook.URL}, nil)
+	writeJSON(w, http.StatusOK, hook.Summary())
+}
+
+func (s *Server) handleAdminDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actor, _ := userFrom(ctx)
+	id := chi.URLParam(r, "id")
+	if err := s.deps.Webhooks.Delete(ctx, id); err != nil {
+		s.auditAdminEvent(ctx, actor, webhookDeleteEvent, models.AuditError, map[string]string{"id": id}, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditAdminEvent(ctx, actor, webhookDeleteEvent, models.AuditAllowed, map[string]string{"id": id}, nil)
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func (s *Server) handleAdminWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	list, err := s.deps.Webhooks.Deliveries(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleAdminTestWebhook sends a test event and reports the attempt; a failed
+// delivery is still a 200 whose body carries the status or error.
+func (s *Server) handleAdminTestWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actor, _ := userFrom(ctx)
+	id := chi.URLParam(r, "id")
+	d, err := s.deps.Webhooks.Test(ctx, id)
+	if err != nil {
+		s.auditAdminEvent(ctx, actor, webhookTestEvent, models.AuditError, map[string]string{"id": id}, err)
+		writeError(w, s.deps.Logger, err)
+		return
+	}
+	s.auditAdminEvent(ctx, actor, webhookTestEvent, models.AuditAllowed, map[string]string{"id": id}, nil)
+	writeJSON(w, http.StatusOK, d)
+}
@@ -0,0 +1,89 @@
+package server_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/service"
+)
+
+func TestWebhookLifecycleDeliveries(t *testing.T) {
+	h := newHarness(t)
+	events := make(chan string, 16)
+	rcv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(service.WebhookSignatureHeader) == service.SignWebhook([]byte("hook-secret"), body) {
+			events <- r.Header.Get(service.WebhookEventHeader)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(rcv.Close)
+	body := `{"name":"siem","url":"` + rcv.URL + `","secret":"hook-secret","events":["session.started","session.closed"]}`
+
+	if resp := h.do(t, http.MethodPost, "/api/admin/webhooks", "op", strings.NewReader(body)); resp.Status != http.StatusForbidden {
+		t.Fatalf("non-admin create: want 403, got %d", resp.Status)
+	}
+	resp := h.do(t, http.MethodPost, "/api/admin/webhooks", "admin", strings.NewReader(body))
+	if resp.Status != http.StatusCreated || strings.Contains(string(resp.Body), "hook-secret") {
+		t.Fatalf("create: got %d (%s)", resp.Status, resp.Body)
+	}
+	var created struct {
+		Webhook struct {
+			ID        string `json:"id"`
+			HasSecret bool   `json:"hasSecret"`
+		} `json:"webhook"`
+	}
+	_ = json.Unmarshal(resp.Body, &created)
+	if !created.Webhook.HasSecret {
+		t.Fatalf("created webhook: %s", resp.Body)
+	}
+
+	if resp := h.do(t, http.MethodGet, "/api/connections/c-op/x/tester.list", "op", nil); resp.Status != http.StatusOK {
+		t.Fatalf("open session: got %d (%s)", resp.Status, resp.Body)
+	}
+	h.pluginSessions.CloseConnection("c-op")
+	for _, want := range []string{service.WebhookSessionStarted, service.WebhookSessionClosed} {
+		select {
+		case got := <-events:
+			if got != want {
+				t.Fatalf("event = %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %s delivery", want)
+		}
+	}
+
+	id := created.Webhook.ID
+	resp = h.do(t, http.MethodPost, "/api/admin/webhooks/"+id+"/test", "admin", nil)
+	if resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `"statusCode":200`) {
+		t.Fatalf("test delivery: got %d (%s)", resp.Status, resp.Body)
+	}
+	// Lifecycle attempts are recorded asynchronously after the receiver answers.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp = h.do(t, http.MethodGet, "/api/admin/webhooks/"+id+"/deliveries", "admin", nil)
+		var history []struct {
+			Event string `json:"event"`
+		}
+		_ = json.Unmarshal(resp.Body, &history)
+		if resp.Status == http.StatusOK && len(history) == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("deliveries: got %d (%s)", resp.Status, resp.Body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if resp := h.do(t, http.MethodDelete, "/api/admin/webhooks/"+id, "admin", nil); resp.Status != http.StatusOK {
+		t.Fatalf("delete: got %d", resp.Status)
+	}
+	if resp := h.do(t, http.MethodPost, "/api/admin/webhooks/"+id+"/test", "admin", nil); resp.Status != http.StatusNotFound {
+		t.Fatalf("test after delete: want 404, got %d", resp.Status)
+	}
+}
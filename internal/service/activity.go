@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+// SessionOpenEvent is the audit event recorded when a pooled connection
+// session is established; activity reports count it as a session start.
+const SessionOpenEvent = "connection.session.open"
+
+const (
+	activityCacheTTL = 5 * time.Minute
+	activityTopN     = 10
+)
+
+// ActivityService serves usage summaries for dashboards. Results are cached
+// per window because the dashboard polls and every summary scans the audit
+// trail.
+type ActivityService struct {
+	store store.ActivityStore
+	now   func() time.Time
+
+	mu    sync.Mutex
+	cache map[time.Duration]cachedActivity
+}
+
+type cachedActivity struct {
+	at      time.Time
+	summary store.ActivitySummary
+}
+
+func NewActivityService(st store.ActivityStore) *ActivityService {
+	return &ActivityService{store: st, now: time.Now, cache: map[time.Duration]cachedActivity{}}
+}
+
+// Summary aggregates activity over the trailing window. The second result is
+// when the figures were computed.
+func (s *ActivityService) Summary(ctx context.Context, window time.Duration) (store.ActivitySummary, time.Time, error) {
+	now := s.now()
+	s.mu.Lock()
+	if c, ok := s.cache[window]; ok && now.Sub(c.at) < activityCacheTTL {
+		s.mu.Unlock()
+		return c.summary, c.at, nil
+	}
+	s.mu.Unlock()
+	ctx, cancel := WithTimeout(ctx, OpReport)
+	defer cancel()
+	summary, err := s.store.Summarize(ctx, store.ActivityQuery{
+		Since: now.Add(-window), SessionEvent: SessionOpenEvent, Top: activityTopN,
+	})
+	if err != nil {
+		return store.ActivitySummary{}, time.Time{}, timeoutError(ctx, err)
+	}
+	s.mu.Lock()
+	s.cache[window] = cachedActivity{at: now, summary: summary}
+	s.mu.Unlock()
+	return summary, now, nil
+}
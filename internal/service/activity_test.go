@@ -0,0 +1,35 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+func TestActivitySummaryIsCached(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	open := func(id string) {
+		_ = st.Audit.Append(ctx, &models.AuditEntry{
+			ID: id, Time: time.Now(), UserID: "u1", Event: service.SessionOpenEvent, ConnectionID: "c1", Result: models.AuditAllowed,
+		})
+	}
+	svc := service.NewActivityService(st.Activity)
+	open("a1")
+	first, at, err := svc.Summary(ctx, 24*time.Hour)
+	if err != nil || len(first.TopConnections) != 1 || first.TopConnections[0].Count != 1 {
+		t.Fatalf("summary: %+v err=%v", first, err)
+	}
+	open("a2")
+	cached, cachedAt, _ := svc.Summary(ctx, 24*time.Hour)
+	if cached.TopConnections[0].Count != 1 || !cachedAt.Equal(at) {
+		t.Fatalf("second read should be served from cache: %+v", cached)
+	}
+	if other, _, _ := svc.Summary(ctx, 48*time.Hour); other.TopConnections[0].Count != 2 {
+		t.Fatalf("a different window is computed separately: %+v", other)
+	}
+}
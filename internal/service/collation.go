@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// SettingCollationLocale is the BCP 47 locale connection, folder and
+// credential names are sorted by.
+const SettingCollationLocale = "system.collation_locale"
+
+// CollationService owns the name collation locale and keeps the stored sort
+// keys in step with it.
+type CollationService struct {
+	settings store.SystemSettingStore
+	keys     store.SortKeyStore
+	now      func() time.Time
+}
+
+func NewCollationService(settings store.SystemSettingStore, keys store.SortKeyStore) *CollationService {
+	return &CollationService{settings: settings, keys: keys, now: time.Now}
+}
+
+// Load applies the persisted locale and backfills keys that do not match it,
+// which also fills rows written before sort keys existed. An unset locale
+// keeps the root collation.
+func (s *CollationService) Load(ctx context.Context) (int, error) {
+	v, err := s.settings.Get(ctx, SettingCollationLocale)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+	case err != nil:
+		return 0, err
+	default:
+		if err := s.keys.SetLocale(v.Value); err != nil {
+			return 0, fmt.Errorf("decode %s: %w", SettingCollationLocale, err)
+		}
+	}
+	return s.Backfill(ctx)
+}
+
+func (s *CollationService) Locale() string { return s.keys.Locale() }
+
+// SetLocale validates, applies and persists locale, then rewrites every sort
+// key under it and returns how many changed.
+func (s *CollationService) SetLocale(ctx context.Context, actor models.User, locale string) (int, error) {
+	locale = strings.TrimSpace(locale)
+	if locale == "" {
+		locale = store.DefaultCollationLocale
+	}
+	if _, err := store.ParseCollationLocale(locale); err != nil {
+		return 0, fmt.Errorf("%w: %v", plugin.ErrInvalidInput, err)
+	}
+	prev := s.keys.Locale()
+	if err := s.keys.SetLocale(locale); err != nil {
+		return 0, err
+	}
+	if err := s.settings.Set(ctx, &models.SystemSetting{
+		Key: SettingCollationLocale, Value: locale, UpdatedBy: actor.ID, UpdatedAt: s.now(),
+	}); err != nil {
+		_ = s.keys.SetLocale(prev)
+		return 0, err
+	}
+	return s.Backfill(ctx)
+}
+
+// Backfill rewrites stale sort keys. It walks whole tables, so it runs
+// without operation timeouts.
+func (s *CollationService) Backfill(ctx context.Context) (int, error) {
+	return s.keys.Backfill(WithoutTimeouts(ctx))
+}
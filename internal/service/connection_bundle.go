@@ -0,0 +1,383 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// ConnectionBundleVersion is the bundle format ExportFolder writes and
+// ImportFolder accepts.
+const ConnectionBundleVersion = 1
+
+const (
+	maxBundleFolders     = 500
+	maxBundleConnections = 2000
+)
+
+// Import conflict strategies for a name already used in the destination.
+const (
+	ImportConflictRename = "rename"
+	ImportConflictSkip   = "skip"
+	ImportConflictFail   = "fail"
+)
+
+// ConnectionBundle is a portable copy of a folder tree and the connections in
+// it. It carries settings only: secrets, credential references, grants and
+// owners stay behind, so an importer re-enters secrets and picks their own
+// credentials.
+type ConnectionBundle struct {
+	Version     int                `json:"version"`
+	ExportedAt  time.Time          `json:"exportedAt"`
+	Folders     []BundleFolder     `json:"folders"`
+	Connections []BundleConnection `json:"connections"`
+	// Omitted counts connections in the tree the exporter does not own.
+	Omitted int `json:"omitted,omitempty"`
+}
+
+// BundleFolder is one folder; Ref is local to the bundle and ParentRef is
+// empty for the exported folder itself.
+type BundleFolder struct {
+	Ref       string `json:"ref"`
+	ParentRef string `json:"parentRef,omitempty"`
+	Name      string `json:"name"`
+	Color     string `json:"color,omitempty"`
+	SortOrder int    `json:"sortOrder"`
+}
+
+// BundleConnection is one connection's settings and its place in the tree.
+type BundleConnection struct {
+	FolderRef           string            `json:"folderRef"`
+	SortOrder           int               `json:"sortOrder"`
+	Name                string            `json:"name"`
+	Protocol            string            `json:"protocol"`
+	Transport           string            `json:"transport"`
+	Config              map[string]any    `json:"config"`
+	Recording           map[string]string `json:"recording,omitempty"`
+	AIMode              models.AIMode     `json:"aiMode,omitempty"`
+	AIAllowDestructive  bool              `json:"aiAllowDestructive,omitempty"`
+	AIAutoApprove       bool              `json:"aiAutoApprove,omitempty"`
+	AllowFileTransfer   bool              `json:"allowFileTransfer"`
+	AllowClipboard      bool              `json:"allowClipboard"`
+	RecordingFailClosed bool              `json:"recordingFailClosed,omitempty"`
+}
+
+// ExportFolder bundles rootID, the folders under it and the connections userID
+// owns and placed in them.
+func (s *ConnectionService) ExportFolder(ctx context.Context, folders store.ConnectionFolderStore, userID, rootID string) (ConnectionBundle, error) {
+	ctx, cancel := WithTimeout(ctx, OpRead)
+	defer cancel()
+	b, err := s.exportFolder(ctx, folders, userID, rootID)
+	return b, timeoutError(ctx, err)
+}
+
+func (s *ConnectionService) exportFolder(ctx context.Context, folders store.ConnectionFolderStore, userID, rootID string) (ConnectionBundle, error) {
+	all, err := folders.ListByUser(ctx, userID)
+	if err != nil {
+		return ConnectionBundle{}, err
+	}
+	if !folderExists(all, rootID) {
+		return ConnectionBundle{}, plugin.ErrNotFound
+	}
+	tree := FolderSubtree(all, rootID)
+	out := ConnectionBundle{Version: ConnectionBundleVersion, ExportedAt: time.Now().UTC(), Folders: []BundleFolder{}, Connections: []BundleConnection{}}
+	for _, f := range all {
+		if !tree[f.ID] {
+			continue
+		}
+		bf := BundleFolder{Ref: f.ID, ParentRef: f.ParentID, Name: f.Name, Color: f.Color, SortOrder: f.SortOrder}
+		if f.ID == rootID {
+			bf.ParentRef = ""
+		}
+		out.Folders = append(out.Folders, bf)
+	}
+	sort.SliceStable(out.Folders, func(i, j int) bool { return out.Folders[i].SortOrder < out.Folders[j].SortOrder })
+
+	var placements []models.ConnectionPlacement
+	if s.placements != nil {
+		if placements, err = s.placements.ListByUser(ctx, userID); err != nil {
+			return ConnectionBundle{}, err
+		}
+	}
+	sort.SliceStable(placements, func(i, j int) bool { return placements[i].SortOrder < placements[j].SortOrder })
+	for _, p := range placements {
+		if !tree[p.FolderID] {
+			continue
+		}
+		conn, err := s.conns.Get(ctx, p.ConnectionID)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return ConnectionBundle{}, err
+		}
+		if conn.OwnerID != userID {
+			out.Omitted++
+			continue
+		}
+		out.Connections = append(out.Connections, s.bundleConnection(conn, p))
+	}
+	return out, nil
+}
+
+func (s *ConnectionService) bundleConnection(conn models.Connection, p models.ConnectionPlacement) BundleConnection {
+	config := map[string]any{}
+	if m, ok := s.plugins.Manifest(conn.Protocol); ok {
+		conn, _ = migrateConnection(m, conn)
+		context := connectionSchemaContext(conn.Protocol, conn.Transport)
+		config = withoutCredentialRefs(m.Config, m.Config.VisibleValues(m.Config.ValuesWithDefaults(conn.Config), context))
+		for _, k := range secretKeys(m.Config) {
+			delete(config, k)
+		}
+	}
+	return BundleConnection{
+		FolderRef: p.FolderID, SortOrder: p.SortOrder,
+		Name: conn.Name, Protocol: conn.Protocol, Transport: conn.Transport,
+		Config: config, Recording: maps.Clone(conn.Recording),
+		AIMode: conn.AIMode, AIAllowDestructive: conn.AIAllowDestructive, AIAutoApprove: conn.AIAutoApprove,
+		AllowFileTransfer: !conn.FileTransferDisabled, AllowClipboard: !conn.ClipboardDisabled,
+		RecordingFailClosed: conn.RecordingFailClosed,
+	}
+}
+
+// withoutCredentialRefs copies values without credential_ref fields, including
+// those on the object items of array fields.
+func withoutCredentialRefs(schema plugin.Schema, values map[string]any) map[string]any {
+	out := maps.Clone(values)
+	for _, group := range schema.Groups {
+		for _, field := range group.Fields {
+			switch {
+			case field.Type == plugin.FieldCredentialRef:
+				delete(out, field.Key)
+			case field.Type == plugin.FieldArray && field.Item != nil && field.Item.Type == plugin.FieldObject:
+				items, ok := out[field.Key].([]any)
+				if !ok {
+					continue
+				}
+				copied := make([]any, len(items))
+				for i, item := range items {
+					obj, ok := item.(map[string]any)
+					if !ok {
+						copied[i] = item
+						continue
+					}
+					obj = maps.Clone(obj)
+					for _, sub := range field.Item.Fields {
+						if sub.Type == plugin.FieldCredentialRef {
+							delete(obj, sub.Key)
+						}
+					}
+					copied[i] = obj
+				}
+				out[field.Key] = copied
+			}
+		}
+	}
+	return out
+}
+
+// ConnectionImportInput recreates Bundle under ParentID ("" for the root)
+// with OnConflict deciding what a name already taken in the destination does.
+type ConnectionImportInput struct {
+	ParentID   string
+	OnConflict string
+	Bundle     ConnectionBundle
+	// Allowed vets each connection before it is created, e.g. protocol
+	// availability for the importer; nil allows all.
+	Allowed func(protocol string) error
+}
+
+// ConnectionImportReport lists the outcome of every bundle item.
+type ConnectionImportReport struct {
+	Folders     []ImportItem `json:"folders"`
+	Connections []ImportItem `json:"connections"`
+}
+
+// ImportItem is one folder or connection's outcome: created (possibly
+// renamed), skipped or failed with Error.
+type ImportItem struct {
+	Ref    string `json:"ref,omitempty"`
+	Name   string `json:"name"`
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status"`
+	// RenamedTo is the name used when the original was taken.
+	RenamedTo string `json:"renamedTo,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+const (
+	ImportCreated = "created"
+	ImportSkipped = "skipped"
+	ImportFailed  = "failed"
+)
+
+// ImportFolder creates the bundle's folders under in.ParentID and its
+// connections in them, owned by userID. Every connection is validated
+// against this instance's plugin schemas; one that fails is reported and the
+// rest carry on. A folder that cannot be created takes its subtree with it.
+func (s *ConnectionService) ImportFolder(ctx context.Context, folders store.ConnectionFolderStore, userID string, in ConnectionImportInput) (ConnectionImportReport, error) {
+	order, err := validateBundle(in)
+	if err != nil {
+		return ConnectionImportReport{}, err
+	}
+	existing, err := folders.ListByUser(ctx, userID)
+	if err != nil {
+		return ConnectionImportReport{}, err
+	}
+	if in.ParentID != "" && !folderExists(existing, in.ParentID) {
+		return ConnectionImportReport{}, fmt.Errorf("%w: unknown parent folder %q", plugin.ErrInvalidInput, in.ParentID)
+	}
+	siblings := map[string]bool{}
+	for _, f := range existing {
+		if f.ParentID == in.ParentID {
+			siblings[nameKey(f.Name)] = true
+		}
+	}
+
+	rep := ConnectionImportReport{Folders: []ImportItem{}, Connections: []ImportItem{}}
+	created := map[string]string{}
+	for _, bf := range order {
+		item := ImportItem{Ref: bf.Ref, Name: bf.Name}
+		parent := in.ParentID
+		if bf.ParentRef != "" {
+			id, ok := created[bf.ParentRef]
+			if !ok {
+				item.Status, item.Error = ImportSkipped, "parent folder was not imported"
+				rep.Folders = append(rep.Folders, item)
+				continue
+			}
+			parent = id
+		}
+		name := bf.Name
+		if bf.ParentRef == "" && siblings[nameKey(name)] {
+			switch in.OnConflict {
+			case ImportConflictSkip:
+				item.Status, item.Error = ImportSkipped, "a folder with this name already exists"
+				rep.Folders = append(rep.Folders, item)
+				continue
+			case ImportConflictFail:
+				item.Status, item.Error = ImportFailed, "a folder with this name already exists"
+				rep.Folders = append(rep.Folders, item)
+				continue
+			}
+			name = NextAvailableName(name, siblings)
+			item.RenamedTo = name
+		}
+		f, err := s.CreateFolder(ctx, folders, userID, ConnectionFolderInput{Name: name, Color: bf.Color, ParentID: parent})
+		if err != nil {
+			item.Status, item.Error = ImportFailed, err.Error()
+			rep.Folders = append(rep.Folders, item)
+			continue
+		}
+		if bf.ParentRef == "" {
+			siblings[nameKey(name)] = true
+		}
+		created[bf.Ref] = f.ID
+		item.ID, item.Status = f.ID, ImportCreated
+		rep.Folders = append(rep.Folders, item)
+	}
+
+	for _, bc := range in.Bundle.Connections {
+		rep.Connections = append(rep.Connections, s.importConnection(ctx, userID, created, in, bc))
+	}
+	return rep, nil
+}
+
+func (s *ConnectionService) importConnection(ctx context.Context, userID string, created map[string]string, in ConnectionImportInput, bc BundleConnection) ImportItem {
+	item := ImportItem{Name: bc.Name}
+	folderID, ok := created[bc.FolderRef]
+	if !ok {
+		item.Status, item.Error = ImportSkipped, "folder was not imported"
+		return item
+	}
+	if in.Allowed != nil {
+		if err := in.Allowed(bc.Protocol); err != nil {
+			item.Status, item.Error = ImportFailed, err.Error()
+			return item
+		}
+	}
+	fileTransfer, clipboard, failClosed := bc.AllowFileTransfer, bc.AllowClipboard, bc.RecordingFailClosed
+	input := ConnectionInput{
+		Name: bc.Name, Protocol: bc.Protocol, Transport: bc.Transport, Config: bc.Config, ActorID: userID,
+		Recording: bc.Recording, AIMode: bc.AIMode, AIAllowDestructive: bc.AIAllowDestructive, AIAutoApprove: bc.AIAutoApprove,
+		AllowFileTransfer: &fileTransfer, AllowClipboard: &clipboard, RecordingFailClosed: &failClosed,
+		FolderID: folderID, SortOrder: bc.SortOrder,
+	}
+	conn, err := s.Create(ctx, userID, input)
+	var conflict *NameConflictError
+	if errors.As(err, &conflict) {
+		switch in.OnConflict {
+		case ImportConflictSkip:
+			item.Status, item.Error = ImportSkipped, err.Error()
+			return item
+		case ImportConflictRename:
+			input.Name = conflict.Suggestion
+			item.RenamedTo = conflict.Suggestion
+			conn, err = s.Create(ctx, userID, input)
+		}
+	}
+	if err != nil {
+		item.Status, item.Error, item.RenamedTo = ImportFailed, err.Error(), ""
+		return item
+	}
+	item.ID, item.Status = conn.ID, ImportCreated
+	return item
+}
+
+// validateBundle checks the bundle's shape and returns its folders parents
+// first.
+func validateBundle(in ConnectionImportInput) ([]BundleFolder, error) {
+	b := in.Bundle
+	if b.Version != ConnectionBundleVersion {
+		return nil, fmt.Errorf("%w: unsupported bundle version %d", plugin.ErrInvalidInput, b.Version)
+	}
+	if !slices.Contains([]string{ImportConflictRename, ImportConflictSkip, ImportConflictFail}, in.OnConflict) {
+		return nil, fmt.Errorf("%w: onConflict must be rename, skip or fail", plugin.ErrInvalidInput)
+	}
+	if len(b.Folders) == 0 || len(b.Folders) > maxBundleFolders || len(b.Connections) > maxBundleConnections {
+		return nil, fmt.Errorf("%w: a bundle holds 1-%d folders and up to %d connections", plugin.ErrInvalidInput, maxBundleFolders, maxBundleConnections)
+	}
+	byRef := map[string]BundleFolder{}
+	for _, f := range b.Folders {
+		if f.Ref == "" {
+			return nil, fmt.Errorf("%w: every folder needs a ref", plugin.ErrInvalidInput)
+		}
+		if _, dup := byRef[f.Ref]; dup {
+			return nil, fmt.Errorf("%w: duplicate folder ref %q", plugin.ErrInvalidInput, f.Ref)
+		}
+		byRef[f.Ref] = f
+	}
+	var order []BundleFolder
+	placed := map[string]bool{}
+	for len(order) < len(b.Folders) {
+		progress := false
+		for _, f := range b.Folders {
+			if placed[f.Ref] || (f.ParentRef != "" && !placed[f.ParentRef]) {
+				continue
+			}
+			if _, ok := byRef[f.ParentRef]; f.ParentRef != "" && !ok {
+				continue
+			}
+			placed[f.Ref] = true
+			order = append(order, f)
+			progress = true
+		}
+		if !progress {
+			return nil, fmt.Errorf("%w: bundle folders reference a missing parent or form a cycle", plugin.ErrInvalidInput)
+		}
+	}
+	for _, c := range b.Connections {
+		if _, ok := byRef[c.FolderRef]; !ok {
+			return nil, fmt.Errorf("%w: connection %q references unknown folder %q", plugin.ErrInvalidInput, c.Name, c.FolderRef)
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+// ConnectionFolderRepair is one folder moved to its owner's root because its
+// nesting was invalid.
+type ConnectionFolderRepair struct {
+	FolderID string
+	UserID   string
+	ParentID string // the parent it was detached from
+	Reason   string
+}
+
+// RepairConnectionFolders moves folders whose parent is missing, belongs to
+// another user, or closes a cycle to their owner's root, and reports each move.
+// It runs at startup so rows written before nesting was enforced never break
+// the sidebar tree.
+func RepairConnectionFolders(ctx context.Context, folders store.ConnectionFolderStore) ([]ConnectionFolderRepair, error) {
+	all, err := folders.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.ConnectionFolder, len(all))
+	for i := range all {
+		byID[all[i].ID] = &all[i]
+	}
+	var repairs []ConnectionFolderRepair
+	detach := func(f *models.ConnectionFolder, reason string) {
+		repairs = append(repairs, ConnectionFolderRepair{FolderID: f.ID, UserID: f.UserID, ParentID: f.ParentID, Reason: reason})
+		f.ParentID = ""
+	}
+	for i := range all {
+		f := &all[i]
+		if f.ParentID == "" {
+			continue
+		}
+		parent, ok := byID[f.ParentID]
+		switch {
+		case !ok:
+			detach(f, "parent folder does not exist")
+		case parent.UserID != f.UserID:
+			detach(f, "parent folder belongs to another user")
+		}
+	}
+	for i := range all {
+		if cycle := folderCycleFrom(byID, all[i].ID); len(cycle) > 0 {
+			detach(byID[slices.Min(cycle)], "folder nesting formed a cycle")
+		}
+	}
+
+	now := time.Now()
+	for _, r := range repairs {
+		f := byID[r.FolderID]
+		f.SortOrder = nextFolderOrder(userFolders(all, f.UserID), "")
+		f.UpdatedAt = now
+		if err := folders.Update(ctx, f); err != nil {
+			return repairs, err
+		}
+	}
+	return repairs, nil
+}
+
+// folderCycleFrom walks up from id and returns the folders of the cycle it
+// runs into, if any.
+func folderCycleFrom(byID map[string]*models.ConnectionFolder, id string) []string {
+	var path []string
+	for id != "" {
+		if i := slices.Index(path, id); i >= 0 {
+			return path[i:]
+		}
+		path = append(path, id)
+		f, ok := byID[id]
+		if !ok {
+			return nil
+		}
+		id = f.ParentID
+	}
+	return nil
+}
+
+func userFolders(all []models.ConnectionFolder, userID string) []models.ConnectionFolder {
+	var out []models.ConnectionFolder
+	for _, f := range all {
+		if f.UserID == userID {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// FolderSubtree returns rootID and every folder nested under it.
+func FolderSubtree(folders []models.ConnectionFolder, rootID string) map[string]bool {
+	children := map[string][]string{}
+	for _, f := range folders {
+		children[f.ParentID] = append(children[f.ParentID], f.ID)
+	}
+	out := map[string]bool{}
+	queue := []string{rootID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if out[id] {
+			continue
+		}
+		out[id] = true
+		queue = append(queue, children[id]...)
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+func TestRepairConnectionFolders(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	for _, f := range []models.ConnectionFolder{
+		{ID: "ok-root", UserID: "u1"},
+		{ID: "ok-child", UserID: "u1", ParentID: "ok-root"},
+		{ID: "orphan", UserID: "u1", ParentID: "gone"},
+		{ID: "foreign", UserID: "u2", ParentID: "ok-root"},
+		{ID: "loop-a", UserID: "u1", ParentID: "loop-b"},
+		{ID: "loop-b", UserID: "u1", ParentID: "loop-a"},
+	} {
+		if err := st.ConnectionFolders.Create(ctx, &f); err != nil {
+			t.Fatalf("create %s: %v", f.ID, err)
+		}
+	}
+
+	repairs, err := service.RepairConnectionFolders(ctx, st.ConnectionFolders)
+	if err != nil {
+		t.Fatalf("repair: %v", err)
+	}
+	moved := map[string]string{}
+	for _, r := range repairs {
+		moved[r.FolderID] = r.Reason
+	}
+	if len(moved) != 3 || moved["orphan"] == "" || moved["foreign"] == "" || moved["loop-a"] == "" {
+		t.Fatalf("repairs: %+v", repairs)
+	}
+	for id, parent := range map[string]string{"ok-child": "ok-root", "orphan": "", "foreign": "", "loop-a": "", "loop-b": "loop-a"} {
+		if f, _ := st.ConnectionFolders.Get(ctx, id); f.ParentID != parent {
+			t.Errorf("%s: want parent %q, got %q", id, parent, f.ParentID)
+		}
+	}
+
+	if again, _ := service.RepairConnectionFolders(ctx, st.ConnectionFolders); len(again) != 0 {
+		t.Fatalf("second pass should find nothing: %+v", again)
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// ConfigMigrationReport is the outcome of rewriting stored connections to
+// their protocol's current config version.
+type ConfigMigrationReport struct {
+	// Updated counts connections rewritten under the current version.
+	Updated int `json:"updated"`
+	// Unmigrated are connections left as they were because a value could not
+	// be moved; they still launch with the renames that did apply.
+	Unmigrated []UnmigratedConnection `json:"unmigrated"`
+}
+
+type UnmigratedConnection struct {
+	ConnectionID string                  `json:"connectionId"`
+	Name         string                  `json:"name"`
+	Protocol     string                  `json:"protocol"`
+	FromVersion  string                  `json:"fromVersion"`
+	ToVersion    string                  `json:"toVersion"`
+	Issues       []plugin.MigrationIssue `json:"issues"`
+}
+
+// migrateConnection applies the manifest's config renames to a connection
+// saved under an older version, moving inline secrets along with plain values.
+func migrateConnection(m plugin.Manifest, conn models.Connection) (models.Connection, []plugin.MigrationIssue) {
+	if len(m.ConfigMigrations) == 0 || !configOutdated(m, conn) {
+		return conn, nil
+	}
+	config, issues := plugin.MigrateConfig(m.ConfigMigrations, conn.ConfigVersion, conn.Config)
+	if len(conn.Secrets) > 0 {
+		stored := make(map[string]any, len(conn.Secrets))
+		for k, v := range conn.Secrets {
+			stored[k] = v
+		}
+		moved, secretIssues := plugin.MigrateConfig(m.ConfigMigrations, conn.ConfigVersion, stored)
+		issues = append(issues, secretIssues...)
+		conn.Secrets = make(map[string][]byte, len(moved))
+		for k, v := range moved {
+			if ct, ok := v.([]byte); ok {
+				conn.Secrets[k] = ct
+			}
+		}
+	}
+	conn.Config = config
+	return conn, issues
+}
+
+func configOutdated(m plugin.Manifest, conn models.Connection) bool {
+	return conn.ConfigVersion == "" || plugin.CompareVersions(conn.ConfigVersion, m.Version) < 0
+}
+
+// MigrateConfigs rewrites every stored connection saved under an older config
+// version of its protocol. Connections with a value that cannot be moved are
+// reported and left unchanged.
+func (s *ConnectionService) MigrateConfigs(ctx context.Context) (ConfigMigrationReport, error) {
+	rep := ConfigMigrationReport{Unmigrated: []UnmigratedConnection{}}
+	conns, err := s.conns.List(ctx)
+	if err != nil {
+		return rep, err
+	}
+	for _, conn := range conns {
+		m, ok := s.plugins.Manifest(conn.Protocol)
+		if !ok || m.Version == "" || !configOutdated(m, conn) {
+			continue
+		}
+		migrated, issues := migrateConnection(m, conn)
+		if len(issues) > 0 {
+			rep.Unmigrated = append(rep.Unmigrated, UnmigratedConnection{
+				ConnectionID: conn.ID, Name: conn.Name, Protocol: conn.Protocol,
+				FromVersion: conn.ConfigVersion, ToVersion: m.Version, Issues: issues,
+			})
+			continue
+		}
+		migrated.ConfigVersion = m.Version
+		if err := s.conns.Update(ctx, &migrated); err != nil {
+			return rep, err
+		}
+		rep.Updated++
+	}
+	return rep, nil
+}
@@ -0,0 +1,90 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/pluginregistry"
+	"github.com/charlesng35/shellcn/internal/secrets"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/internal/transport"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+type renamedFieldsPlugin struct{}
+
+func (renamedFieldsPlugin) Manifest() plugin.Manifest {
+	return plugin.Manifest{
+		APIVersion: plugin.CurrentAPIVersion, Name: "renamed", Version: "2.0.0", Title: "Renamed",
+		Category: plugin.CategoryOther, Layout: plugin.LayoutTabs,
+		SupportedTransports: []plugin.Transport{plugin.TransportDirect},
+		Config: plugin.Schema{Groups: []plugin.Group{{Name: "Target", Fields: []plugin.Field{
+			{Key: "host", Label: "Host", Type: plugin.FieldText},
+			{Key: "tls", Label: "TLS", Type: plugin.FieldToggle},
+			{Key: "password", Label: "Password", Type: plugin.FieldPassword, Secret: true},
+		}}}},
+		ConfigMigrations: []plugin.ConfigMigration{{Version: "2.0.0", Renames: []plugin.FieldRename{
+			{From: "hostname", To: "host"},
+			{From: "use_tls", To: "tls", Transform: plugin.TransformStringToBool},
+			{From: "pass", To: "password"},
+		}}},
+		Tabs: []plugin.Panel{{Key: "main", Label: "Main", Type: plugin.PanelTable}},
+	}
+}
+
+func (renamedFieldsPlugin) Routes() []plugin.Route { return nil }
+func (renamedFieldsPlugin) Connect(context.Context, plugin.ConnectConfig) (plugin.Session, error) {
+	return nil, nil
+}
+
+func TestConnectionConfigMigration(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	key, _ := secrets.GenerateMasterKey()
+	vault, _ := secrets.NewVault(key)
+	reg := pluginregistry.New()
+	reg.MustRegister(renamedFieldsPlugin{})
+	ct, _ := vault.Encrypt(ctx, []byte("hunter2"))
+	for _, c := range []models.Connection{
+		{ID: "old", Name: "old", ConfigVersion: "1.4.0", Config: map[string]any{"hostname": "db1", "use_tls": "yes"}, Secrets: map[string][]byte{"pass": ct}},
+		{ID: "clash", Name: "clash", ConfigVersion: "1.4.0", Config: map[string]any{"hostname": "a", "host": "b"}},
+		{ID: "current", Name: "current", ConfigVersion: "2.0.0", Config: map[string]any{"host": "db2"}},
+	} {
+		c.Protocol, c.OwnerID, c.Transport = "renamed", "u1", string(plugin.TransportDirect)
+		if err := st.Connections.Create(ctx, &c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old, _ := st.Connections.Get(ctx, "old")
+
+	connector := service.NewConnector(reg, nil, vault, transport.NewRegistry())
+	cfg, _, err := connector.Build(ctx, models.User{ID: "u1"}, old)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if cfg.Config["host"] != "db1" || cfg.Config["tls"] != true || cfg.Config["password"] != "hunter2" {
+		t.Fatalf("launch config of an old connection: %v", cfg.Config)
+	}
+	svc := service.NewConnectionService(st.Connections, reg, nil, vault)
+	if d := svc.Detail(ctx, "u1", old); d.Config["host"] != "db1" || d.Secrets["password"] != "set" {
+		t.Fatalf("detail: %+v", d)
+	}
+
+	rep, err := svc.MigrateConfigs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Updated != 1 || len(rep.Unmigrated) != 1 || rep.Unmigrated[0].ConnectionID != "clash" ||
+		rep.Unmigrated[0].Issues[0].Field != "hostname" {
+		t.Fatalf("report: %+v", rep)
+	}
+	stored, _ := st.Connections.Get(ctx, "old")
+	if stored.ConfigVersion != "2.0.0" || stored.Config["host"] != "db1" || stored.Config["hostname"] != nil || len(stored.Secrets["password"]) == 0 {
+		t.Fatalf("rewritten connection: %+v", stored)
+	}
+	if again, _ := svc.MigrateConfigs(ctx); again.Updated != 0 || len(again.Unmigrated) != 1 {
+		t.Fatalf("second run: %+v", again)
+	}
+}
@@ -0,0 +1,319 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// ConnectionServiceOption configures a ConnectionService.
+type ConnectionServiceOption func(*ConnectionService)
+
+// WithConnectionPlacements scopes name uniqueness to the owner's sidebar
+// folders. Without it every connection an owner has counts as one folder.
+func WithConnectionPlacements(p store.ConnectionPlacementStore) ConnectionServiceOption {
+	return func(s *ConnectionService) { s.placements = p }
+}
+
+// WithConnectionNames records each connection's name claim in the store, so
+// replicas checking the same name cannot both write it. Without it the name
+// check alone guards against duplicates.
+func WithConnectionNames(n store.ConnectionNameStore) ConnectionServiceOption {
+	return func(s *ConnectionService) { s.names = n }
+}
+
+// NameConflictError reports a connection name already used in the owner's
+// folder, with the next free name as a suggestion.
+type NameConflictError struct {
+	Name       string
+	Suggestion string
+}
+
+func (e *NameConflictError) Error() string {
+	return fmt.Sprintf("%s: a connection named %q already exists in this folder; try %q",
+		plugin.ErrConflict, e.Name, e.Suggestion)
+}
+
+func (e *NameConflictError) Unwrap() error { return plugin.ErrConflict }
+
+// ConnectionRename records one rename made by DedupeNames.
+type ConnectionRename struct {
+	ConnectionID string
+	OwnerID      string
+	FolderID     string
+	From         string
+	To           string
+}
+
+func nameKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func nameHash(name string) string {
+	sum := sha256.Sum256([]byte(nameKey(name)))
+	return hex.EncodeToString(sum[:])
+}
+
+// NextAvailableName returns name, or name with the lowest " (n)" suffix from 2
+// up, whose case-insensitive form is not in taken.
+func NextAvailableName(name string, taken map[string]bool) string {
+	if !taken[nameKey(name)] {
+		return name
+	}
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)", name, n)
+		if !taken[nameKey(candidate)] {
+			return candidate
+		}
+	}
+}
+
+// ownerFolders maps each of the owner's placed connections to its folder.
+func (s *ConnectionService) ownerFolders(ctx context.Context, ownerID string) (map[string]string, error) {
+	out := map[string]string{}
+	if s.placements == nil {
+		return out, nil
+	}
+	placements, err := s.placements.ListByUser(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range placements {
+		out[p.ConnectionID] = p.FolderID
+	}
+	return out, nil
+}
+
+// checkName rejects name when another of the owner's connections in the
+// connection's folder already uses it, ignoring case. New connections land in
+// the root folder.
+func (s *ConnectionService) checkName(ctx context.Context, ownerID, connID, name string) error {
+	return s.checkNameIn(ctx, ownerID, connID, "", name)
+}
+
+// checkNameIn is checkName for a connection in folder; a placed connection's
+// stored folder wins.
+func (s *ConnectionService) checkNameIn(ctx context.Context, ownerID, connID, folder, name string) error {
+	_, taken, err := s.folderNames(ctx, ownerID, connID, folder)
+	if err != nil {
+		return err
+	}
+	if taken[nameKey(name)] {
+		return &NameConflictError{Name: name, Suggestion: NextAvailableName(name, taken)}
+	}
+	return nil
+}
+
+// folderNames resolves the connection's folder as checkNameIn does and
+// returns it with the names the owner's other connections use there.
+func (s *ConnectionService) folderNames(ctx context.Context, ownerID, connID, folder string) (string, map[string]bool, error) {
+	conns, err := s.conns.ListByOwner(ctx, ownerID)
+	if err != nil {
+		return "", nil, err
+	}
+	folders, err := s.ownerFolders(ctx, ownerID)
+	if err != nil {
+		return "", nil, err
+	}
+	if f, ok := folders[connID]; ok {
+		folder = f
+	}
+	taken := map[string]bool{}
+	for _, c := range conns {
+		if c.ID != connID && folders[c.ID] == folder {
+			taken[nameKey(c.Name)] = true
+		}
+	}
+	return folder, taken, nil
+}
+
+// reserveName checks name like checkNameIn and claims it for the connection,
+// returning the folder it was claimed in.
+func (s *ConnectionService) reserveName(ctx context.Context, ownerID, connID, folder, name string) (string, error) {
+	folder, taken, err := s.folderNames(ctx, ownerID, connID, folder)
+	if err != nil {
+		return "", err
+	}
+	if taken[nameKey(name)] {
+		return "", &NameConflictError{Name: name, Suggestion: NextAvailableName(name, taken)}
+	}
+	return folder, s.claimName(ctx, ownerID, connID, folder, name, taken)
+}
+
+// claimName records the claim. A conflict here means a writer elsewhere took
+// the name after the check; taken is what the check saw.
+func (s *ConnectionService) claimName(ctx context.Context, ownerID, connID, folder, name string, taken map[string]bool) error {
+	if s.names == nil {
+		return nil
+	}
+	err := s.names.Claim(ctx, &models.ConnectionName{ConnectionID: connID, OwnerID: ownerID, FolderID: folder, NameHash: nameHash(name)})
+	if errors.Is(err, models.ErrConflict) {
+		seen := maps.Clone(taken)
+		if seen == nil {
+			seen = map[string]bool{}
+		}
+		seen[nameKey(name)] = true
+		return &NameConflictError{Name: name, Suggestion: NextAvailableName(name, seen)}
+	}
+	return err
+}
+
+// releaseName drops the connection's claim.
+func (s *ConnectionService) releaseName(ctx context.Context, connID string) error {
+	if s.names == nil {
+		return nil
+	}
+	return s.names.Release(ctx, connID)
+}
+
+// undoClaim puts back prevName as the connection's claim, or drops the claim
+// when prevName is empty, after the write it guarded failed.
+func (s *ConnectionService) undoClaim(ctx context.Context, ownerID, connID, folder, prevName string) {
+	ctx = context.WithoutCancel(ctx)
+	if prevName == "" {
+		_ = s.releaseName(ctx, connID)
+		return
+	}
+	_ = s.claimName(ctx, ownerID, connID, folder, prevName, nil)
+}
+
+// ClaimMoves claims names for ownerID's connections in the folders moves
+// assigns them (connection ID to folder ID), before the placements are
+// written. Connections ownerID does not own keep their owner's folder for
+// naming and are skipped.
+func (s *ConnectionService) ClaimMoves(ctx context.Context, ownerID string, moves map[string]string) error {
+	conns, err := s.conns.ListByOwner(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	folders, err := s.ownerFolders(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	after := maps.Clone(folders)
+	maps.Copy(after, moves)
+	names := map[string]map[string]int{}
+	for _, c := range conns {
+		f := after[c.ID]
+		if names[f] == nil {
+			names[f] = map[string]int{}
+		}
+		names[f][nameKey(c.Name)]++
+	}
+	var moved []models.Connection
+	for _, c := range conns {
+		if f, ok := moves[c.ID]; ok && f != folders[c.ID] {
+			moved = append(moved, c)
+		}
+	}
+	sort.Slice(moved, func(i, j int) bool { return moved[i].ID < moved[j].ID })
+	for _, c := range moved {
+		f := after[c.ID]
+		taken := map[string]bool{}
+		for key, n := range names[f] {
+			taken[key] = n > 1 || key != nameKey(c.Name)
+		}
+		if taken[nameKey(c.Name)] {
+			return &NameConflictError{Name: c.Name, Suggestion: NextAvailableName(c.Name, taken)}
+		}
+		if err := s.claimName(ctx, ownerID, c.ID, f, c.Name, taken); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClaimFolderMove is ClaimMoves for every connection ownerID placed in
+// folderID moving to targetID.
+func (s *ConnectionService) ClaimFolderMove(ctx context.Context, ownerID, folderID, targetID string) error {
+	folders, err := s.ownerFolders(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	moves := map[string]string{}
+	for id, f := range folders {
+		if f == folderID {
+			moves[id] = targetID
+		}
+	}
+	return s.ClaimMoves(ctx, ownerID, moves)
+}
+
+// DedupeNames renames connections that share a case-insensitive name with an
+// older connection in the same owner folder, using the same suffixes as the
+// conflict suggestion, then rebuilds the name claims from the result. It is
+// safe to run on every start.
+func (s *ConnectionService) DedupeNames(ctx context.Context) ([]ConnectionRename, error) {
+	ctx = WithoutTimeouts(ctx)
+	all, err := s.conns.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byOwner := map[string][]models.Connection{}
+	for _, c := range all {
+		byOwner[c.OwnerID] = append(byOwner[c.OwnerID], c)
+	}
+	owners := make([]string, 0, len(byOwner))
+	for o := range byOwner {
+		owners = append(owners, o)
+	}
+	sort.Strings(owners)
+
+	var renames []ConnectionRename
+	claims := make([]models.ConnectionName, 0, len(all))
+	for _, owner := range owners {
+		folders, err := s.ownerFolders(ctx, owner)
+		if err != nil {
+			return renames, err
+		}
+		conns := byOwner[owner]
+		sort.SliceStable(conns, func(i, j int) bool {
+			if !conns[i].CreatedAt.Equal(conns[j].CreatedAt) {
+				return conns[i].CreatedAt.Before(conns[j].CreatedAt)
+			}
+			return conns[i].ID < conns[j].ID
+		})
+		// names holds every name in a folder so suffixes skip ones used by
+		// younger rows; kept holds the names already claimed by an older row.
+		names, kept := map[string]map[string]bool{}, map[string]map[string]bool{}
+		for _, c := range conns {
+			folder := folders[c.ID]
+			if names[folder] == nil {
+				names[folder], kept[folder] = map[string]bool{}, map[string]bool{}
+			}
+			names[folder][nameKey(c.Name)] = true
+		}
+		for _, c := range conns {
+			folder := folders[c.ID]
+			if !kept[folder][nameKey(c.Name)] {
+				kept[folder][nameKey(c.Name)] = true
+				claims = append(claims, models.ConnectionName{ConnectionID: c.ID, OwnerID: owner, FolderID: folder, NameHash: nameHash(c.Name)})
+				continue
+			}
+			from := c.Name
+			c.Name = NextAvailableName(c.Name, names[folder])
+			if err := s.conns.Update(ctx, &c); err != nil {
+				return renames, err
+			}
+			names[folder][nameKey(c.Name)] = true
+			kept[folder][nameKey(c.Name)] = true
+			claims = append(claims, models.ConnectionName{ConnectionID: c.ID, OwnerID: owner, FolderID: folder, NameHash: nameHash(c.Name)})
+			renames = append(renames, ConnectionRename{ConnectionID: c.ID, OwnerID: owner, FolderID: folder, From: from, To: c.Name})
+		}
+	}
+	if s.names != nil {
+		if err := s.names.Reset(ctx, claims); err != nil {
+			return renames, err
+		}
+	}
+	return renames, nil
+}
@@ -0,0 +1,110 @@
+package service_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+func TestNextAvailableName(t *testing.T) {
+	taken := map[string]bool{"prod-db": true, "prod-db (2)": true}
+	if got := service.NextAvailableName("Prod-DB", taken); got != "Prod-DB (3)" {
+		t.Fatalf("got %q", got)
+	}
+	if got := service.NextAvailableName("staging", taken); got != "staging" {
+		t.Fatalf("free name changed: %q", got)
+	}
+}
+
+func TestDedupeConnectionNames(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	base := time.Now()
+	for i, c := range []models.Connection{
+		{ID: "a", Name: "prod-db", OwnerID: "u1"},
+		{ID: "b", Name: "PROD-DB", OwnerID: "u1"},
+		{ID: "c", Name: "prod-db (2)", OwnerID: "u1"},
+		{ID: "d", Name: "prod-db", OwnerID: "u1"}, // in a folder: no clash
+		{ID: "e", Name: "prod-db", OwnerID: "u2"},
+	} {
+		c.CreatedAt = base.Add(time.Duration(i) * time.Second)
+		if err := st.Connections.Create(ctx, &c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = st.ConnectionPlacements.Set(ctx, &models.ConnectionPlacement{UserID: "u1", ConnectionID: "d", FolderID: "f1"})
+	svc := service.NewConnectionService(st.Connections, nil, nil, nil, service.WithConnectionPlacements(st.ConnectionPlacements))
+
+	renames, err := svc.DedupeNames(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(renames) != 1 || renames[0].ConnectionID != "b" || renames[0].To != "PROD-DB (3)" {
+		t.Fatalf("renames = %+v", renames)
+	}
+	if again, _ := svc.DedupeNames(ctx); len(again) != 0 {
+		t.Fatalf("second run should be a no-op: %+v", again)
+	}
+}
+
+func TestConnectionNameClaims(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	for _, c := range []models.Connection{
+		{ID: "root", Name: "db", OwnerID: "u1"},
+		{ID: "placed", Name: "db", OwnerID: "u1"},
+		{ID: "gone", Name: "db", OwnerID: "u1"},
+	} {
+		if err := st.Connections.Create(ctx, &c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = st.ConnectionPlacements.Set(ctx, &models.ConnectionPlacement{UserID: "u1", ConnectionID: "placed", FolderID: "f1"})
+	_ = st.ConnectionPlacements.Set(ctx, &models.ConnectionPlacement{UserID: "u1", ConnectionID: "gone", FolderID: "f2"})
+	svc := service.NewConnectionService(st.Connections, nil, nil, nil,
+		service.WithConnectionPlacements(st.ConnectionPlacements), service.WithConnectionNames(st.ConnectionNames))
+	if _, err := svc.DedupeNames(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another replica holds the name in f1 for a connection this one cannot
+	// see yet; the claim still refuses the move.
+	if err := svc.ClaimMoves(ctx, "u1", map[string]string{"root": "f1"}); err == nil {
+		t.Fatal("move onto a name taken in the folder should conflict")
+	}
+	if err := st.ConnectionNames.Claim(ctx, &models.ConnectionName{ConnectionID: "elsewhere", OwnerID: "u1", FolderID: "f3", NameHash: claimHash("db")}); err != nil {
+		t.Fatal(err)
+	}
+	var nameErr *service.NameConflictError
+	if err := svc.ClaimMoves(ctx, "u1", map[string]string{"root": "f3"}); !errors.As(err, &nameErr) || nameErr.Suggestion != "db (2)" {
+		t.Fatalf("claim held elsewhere: %v", err)
+	}
+
+	// A trashed connection comes back under its own name when only other
+	// folders use it, and with a suffix when its folder does.
+	if err := svc.Delete(ctx, "gone"); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := svc.Restore(ctx, models.Connection{ID: "gone", Name: "db", OwnerID: "u1"})
+	if err != nil || restored.Name != "db" {
+		t.Fatalf("restore into a free folder: %+v %v", restored, err)
+	}
+	_ = svc.Delete(ctx, "gone")
+	_ = st.ConnectionPlacements.Set(ctx, &models.ConnectionPlacement{UserID: "u1", ConnectionID: "gone", FolderID: "f1"})
+	restored, err = svc.Restore(ctx, models.Connection{ID: "gone", Name: "db", OwnerID: "u1"})
+	if err != nil || restored.Name != "db (restored)" {
+		t.Fatalf("restore next to a live name: %+v %v", restored, err)
+	}
+}
+
+func claimHash(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:])
+}
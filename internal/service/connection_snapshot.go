@@ -0,0 +1,54 @@
+package service
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// MetadataConnectionSnapshot is the session metadata key holding the
+// models.ConnectionSnapshot taken at launch.
+const MetadataConnectionSnapshot = "connection_snapshot"
+
+const (
+	maxSnapshotFields     = 64
+	maxSnapshotValueBytes = 1024
+)
+
+// Snapshot reduces a config Build materialised for conn to what may be kept:
+// secret fields and inline secrets are dropped, as are fields past
+// maxSnapshotFields or larger than maxSnapshotValueBytes encoded.
+func (c *Connector) Snapshot(conn models.Connection, cfg plugin.ConnectConfig) models.ConnectionSnapshot {
+	secret := map[string]bool{}
+	for k := range conn.Secrets {
+		secret[k] = true
+	}
+	if manifest, ok := c.plugins.Manifest(conn.Protocol); ok {
+		for _, k := range secretKeys(manifest.Config) {
+			secret[k] = true
+		}
+	}
+	out := models.ConnectionSnapshot{
+		Protocol: conn.Protocol, Transport: conn.Transport,
+		Config: map[string]any{}, Version: conn.UpdatedAt,
+	}
+	for _, k := range slices.Sorted(maps.Keys(cfg.Config)) {
+		if secret[k] {
+			continue
+		}
+		if len(out.Config) == maxSnapshotFields {
+			out.Truncated = true
+			break
+		}
+		b, err := json.Marshal(cfg.Config[k])
+		if err != nil || len(b) > maxSnapshotValueBytes {
+			out.Truncated = true
+			continue
+		}
+		out.Config[k] = cfg.Config[k]
+	}
+	return out
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// OwnedConnectionsError blocks deleting a user who still owns connections
+// until the caller names a new owner or confirms leaving them orphaned.
+type OwnedConnectionsError struct {
+	Count int
+}
+
+func (e *OwnedConnectionsError) Error() string {
+	return fmt.Sprintf("%s: user owns %d connection(s); reassign them or confirm orphaning", plugin.ErrConflict, e.Count)
+}
+
+func (e *OwnedConnectionsError) Unwrap() error { return plugin.ErrConflict }
+
+// ConnectionReassignment is the outcome of TransferAllOwnership.
+type ConnectionReassignment struct {
+	Moved []string
+	// Renamed lists moved connections whose name was taken in the new
+	// owner's folder and got a suffix.
+	Renamed []ConnectionRename
+	// Skipped lists connections left with the old owner, e.g. because they
+	// reference a credential the new owner cannot use.
+	Skipped []ConnectionTransferSkip
+}
+
+// ConnectionTransferSkip is one connection TransferAllOwnership left behind.
+type ConnectionTransferSkip struct {
+	ConnectionID string
+	Name         string
+	Reason       string
+}
+
+// TransferOwnership hands a connection to newOwnerID. Only the current owner
+// or the root admin may do so. Grants and everyone's folder placements stay
+// as they are; inline secrets live on the connection and move with it. Every
+// stored credential the connection references must be usable by the new
+// owner, since sessions resolve them as the owner.
+func (s *ConnectionService) TransferOwnership(ctx context.Context, viewer models.User, connectionID, newOwnerID string) (models.Connection, error) {
+	conn, err := s.conns.Get(ctx, connectionID)
+	if errors.Is(err, store.ErrNotFound) {
+		return models.Connection{}, plugin.ErrNotFound
+	}
+	if err != nil {
+		return models.Connection{}, err
+	}
+	if conn.OwnerID != viewer.ID && !viewer.Protected {
+		return models.Connection{}, fmt.Errorf("%w: only the owner or the root admin can transfer a connection", plugin.ErrForbidden)
+	}
+	if newOwnerID == "" {
+		return models.Connection{}, fmt.Errorf("%w: new owner is required", plugin.ErrInvalidInput)
+	}
+	if newOwnerID == conn.OwnerID {
+		return conn, nil
+	}
+	if err := s.checkTransferRefs(ctx, conn, newOwnerID, nil); err != nil {
+		return models.Connection{}, err
+	}
+	if _, err := s.reserveName(ctx, newOwnerID, conn.ID, "", conn.Name); err != nil {
+		return models.Connection{}, err
+	}
+	now := time.Now()
+	if err := s.conns.SetOwner(ctx, conn.ID, newOwnerID, now); err != nil {
+		_, _ = s.reserveName(context.WithoutCancel(ctx), conn.OwnerID, conn.ID, "", conn.Name)
+		return models.Connection{}, err
+	}
+	conn.OwnerID, conn.UpdatedAt = newOwnerID, now
+	return conn, nil
+}
+
+// TransferAllOwnership moves every live connection fromUserID owns to
+// toUserID. Unlike TransferOwnership it does not stop at a name clash, which
+// it resolves with a suffix, or at a credential the new owner cannot use,
+// which leaves that connection behind and reports it. Trashed connections
+// stay with the old owner until they are purged.
+func (s *ConnectionService) TransferAllOwnership(ctx context.Context, fromUserID, toUserID string) (ConnectionReassignment, error) {
+	out := ConnectionReassignment{Moved: []string{}, Renamed: []ConnectionRename{}, Skipped: []ConnectionTransferSkip{}}
+	if fromUserID == "" || toUserID == "" {
+		return out, fmt.Errorf("%w: both the current and the new owner are required", plugin.ErrInvalidInput)
+	}
+	if fromUserID == toUserID {
+		return out, fmt.Errorf("%w: the new owner must differ from the current owner", plugin.ErrInvalidInput)
+	}
+	list, err := s.conns.ListByOwner(ctx, fromUserID)
+	if err != nil {
+		return out, err
+	}
+	now := time.Now()
+	for _, c := range list {
+		if err := s.checkTransferRefs(ctx, c, toUserID, nil); err != nil {
+			out.Skipped = append(out.Skipped, ConnectionTransferSkip{ConnectionID: c.ID, Name: c.Name, Reason: err.Error()})
+			continue
+		}
+		from := c.Name
+		for {
+			var nameErr *NameConflictError
+			_, err := s.reserveName(ctx, toUserID, c.ID, "", c.Name)
+			if !errors.As(err, &nameErr) {
+				if err != nil {
+					return out, err
+				}
+				break
+			}
+			c.Name = nameErr.Suggestion
+		}
+		if c.Name != from {
+			if err := s.conns.Update(ctx, &c); err != nil {
+				return out, err
+			}
+			out.Renamed = append(out.Renamed, ConnectionRename{ConnectionID: c.ID, OwnerID: toUserID, From: from, To: c.Name})
+		}
+		if err := s.conns.SetOwner(ctx, c.ID, toUserID, now); err != nil {
+			return out, err
+		}
+		out.Moved = append(out.Moved, c.ID)
+	}
+	return out, nil
+}
+
+// TransferSkips lists the live connections fromUserID owns that
+// TransferAllOwnership to toUserID would leave behind, without moving any.
+// Credentials in inherited are taken to move to toUserID first.
+func (s *ConnectionService) TransferSkips(ctx context.Context, fromUserID, toUserID string, inherited map[string]bool) ([]ConnectionTransferSkip, error) {
+	list, err := s.conns.ListByOwner(ctx, fromUserID)
+	if err != nil {
+		return nil, err
+	}
+	var out []ConnectionTransferSkip
+	for _, c := range list {
+		if err := s.checkTransferRefs(ctx, c, toUserID, inherited); err != nil {
+			out = append(out, ConnectionTransferSkip{ConnectionID: c.ID, Name: c.Name, Reason: err.Error()})
+		}
+	}
+	return out, nil
+}
+
+// checkTransferRefs reports the first credential conn references that
+// ownerID could not use. A credential in inherited moves to ownerID as well,
+// so it is checked for the current owner, who holds it until then.
+// Connections of an unregistered protocol have no schema to find references
+// in and pass.
+func (s *ConnectionService) checkTransferRefs(ctx context.Context, conn models.Connection, ownerID string, inherited map[string]bool) error {
+	m, ok := s.plugins.Manifest(conn.Protocol)
+	if !ok {
+		return nil
+	}
+	for _, ref := range credentialRefs(m.Config, conn.Config) {
+		userID := ownerID
+		if inherited[ref.ID] {
+			userID = conn.OwnerID
+		}
+		if err := s.checkCredentialRef(ctx, userID, conn.Protocol, ref); err != nil {
+			return fmt.Errorf("new owner cannot use a credential the connection references: %w", err)
+		}
+	}
+	return nil
+}
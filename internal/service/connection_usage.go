@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+// UsageRecentDays is the trailing window of ConnectionStats.RecentSessions.
+const UsageRecentDays = 30
+
+// ConnectionStats summarizes how often a connection is used.
+type ConnectionStats struct {
+	ConnectionID   string     `json:"connectionId"`
+	LastUsedAt     *time.Time `json:"lastUsedAt"`
+	Sessions       int64      `json:"sessions"`
+	RecentSessions int64      `json:"recentSessions"`
+	RecentDays     int        `json:"recentDays"`
+}
+
+// StaleConnectionEntry is a stale connection with its owner's username and
+// the folder path the owner filed it under ("" for the root).
+type StaleConnectionEntry struct {
+	store.StaleConnection
+	OwnerUsername string `json:"ownerUsername"`
+	FolderPath    string `json:"folderPath"`
+}
+
+// ConnectionUsageService reports connection usage from session history.
+type ConnectionUsageService struct {
+	usage      store.ConnectionUsageStore
+	users      store.UserStore
+	folders    store.ConnectionFolderStore
+	placements store.ConnectionPlacementStore
+	now        func() time.Time
+}
+
+func NewConnectionUsageService(usage store.ConnectionUsageStore, users store.UserStore, folders store.ConnectionFolderStore, placements store.ConnectionPlacementStore) *ConnectionUsageService {
+	return &ConnectionUsageService{usage: usage, users: users, folders: folders, placements: placements, now: time.Now}
+}
+
+func (s *ConnectionUsageService) Stats(ctx context.Context, conn models.Connection) (ConnectionStats, error) {
+	u, err := s.usage.Usage(ctx, conn.ID, s.now().AddDate(0, 0, -UsageRecentDays))
+	if err != nil {
+		return ConnectionStats{}, err
+	}
+	return ConnectionStats{
+		ConnectionID: conn.ID, LastUsedAt: conn.LastUsedAt,
+		Sessions: u.Sessions, RecentSessions: u.RecentSessions, RecentDays: UsageRecentDays,
+	}, nil
+}
+
+// Stale lists connections with no session within unusedFor. Unless actor is
+// root, only connections the actor owns or was granted are considered.
+func (s *ConnectionUsageService) Stale(ctx context.Context, actor models.User, unusedFor time.Duration) ([]StaleConnectionEntry, error) {
+	ctx, cancel := WithTimeout(ctx, OpReport)
+	defer cancel()
+	f := store.StaleFilter{UnusedSince: s.now().Add(-unusedFor)}
+	if !actor.Protected {
+		f.VisibleTo = actor.ID
+	}
+	list, err := s.usage.Stale(ctx, f)
+	if err != nil {
+		return nil, timeoutError(ctx, err)
+	}
+	owners := map[string]string{}
+	paths := map[string]map[string]string{}
+	out := make([]StaleConnectionEntry, 0, len(list))
+	for _, c := range list {
+		if _, ok := owners[c.OwnerID]; !ok {
+			u, err := s.users.GetByID(ctx, c.OwnerID)
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				return nil, err
+			}
+			owners[c.OwnerID] = u.Username
+		}
+		if _, ok := paths[c.OwnerID]; !ok {
+			p, err := s.folderPaths(ctx, c.OwnerID)
+			if err != nil {
+				return nil, err
+			}
+			paths[c.OwnerID] = p
+		}
+		out = append(out, StaleConnectionEntry{
+			StaleConnection: c, OwnerUsername: owners[c.OwnerID], FolderPath: paths[c.OwnerID][c.ID],
+		})
+	}
+	return out, nil
+}
+
+// folderPaths maps each of the owner's placed connections to its folder path,
+// with names joined by "/".
+func (s *ConnectionUsageService) folderPaths(ctx context.Context, ownerID string) (map[string]string, error) {
+	folders, err := s.folders.ListByUser(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	placements, err := s.placements.ListByUser(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]models.ConnectionFolder, len(folders))
+	for _, f := range folders {
+		byID[f.ID] = f
+	}
+	out := make(map[string]string, len(placements))
+	for _, p := range placements {
+		var names []string
+		seen := map[string]bool{}
+		for id := p.FolderID; id != "" && !seen[id]; {
+			f, ok := byID[id]
+			if !ok {
+				break
+			}
+			seen[id] = true
+			names = append([]string{f.Name}, names...)
+			id = f.ParentID
+		}
+		out[p.ConnectionID] = strings.Join(names, "/")
+	}
+	return out, nil
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"maps"
 	"slices"
@@ -20,14 +21,20 @@ import (
 // ConnectionService owns connection config validation, secret encryption, and
 // write-only secret update semantics.
 type ConnectionService struct {
-	conns   store.ConnectionStore
-	plugins *pluginregistry.Registry
-	creds   *CredentialService
-	vault   secrets.SecretStore
+	conns      store.ConnectionStore
+	plugins    *pluginregistry.Registry
+	creds      *CredentialService
+	vault      secrets.SecretStore
+	placements store.ConnectionPlacementStore
+	names      store.ConnectionNameStore
 }
 
-func NewConnectionService(conns store.ConnectionStore, plugins *pluginregistry.Registry, creds *CredentialService, vault secrets.SecretStore) *ConnectionService {
-	return &ConnectionService{conns: conns, plugins: plugins, creds: creds, vault: vault}
+func NewConnectionService(conns store.ConnectionStore, plugins *pluginregistry.Registry, creds *CredentialService, vault secrets.SecretStore, opts ...ConnectionServiceOption) *ConnectionService {
+	s := &ConnectionService{conns: conns, plugins: plugins, creds: creds, vault: vault}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // ConnectionInput is a create/update request.
@@ -49,6 +56,17 @@ type ConnectionInput struct {
 	AIMode             models.AIMode
 	AIAllowDestructive bool
 	AIAutoApprove      bool
+	// AllowFileTransfer and AllowClipboard default to true on create; nil on
+	// update preserves the stored policy.
+	AllowFileTransfer *bool
+	AllowClipboard    *bool
+	// RecordingFailClosed ends auto-recorded streams whose recording fails;
+	// nil on update preserves the stored flag.
+	RecordingFailClosed *bool
+	// FolderID places a new connection in one of the owner's folders, at
+	// SortOrder, instead of the root list. Ignored on update.
+	FolderID  string
+	SortOrder int
 }
 
 // normalizeAIMode clears mutation options unless the mode is read_write.
@@ -63,6 +81,18 @@ func normalizeAIMode(mode models.AIMode, allowDestructive, autoApprove bool) (mo
 	}
 }
 
+func applyFeaturePolicy(conn *models.Connection, in ConnectionInput) {
+	if in.AllowFileTransfer != nil {
+		conn.FileTransferDisabled = !*in.AllowFileTransfer
+	}
+	if in.AllowClipboard != nil {
+		conn.ClipboardDisabled = !*in.AllowClipboard
+	}
+	if in.RecordingFailClosed != nil {
+		conn.RecordingFailClosed = *in.RecordingFailClosed
+	}
+}
+
 // ConnectionFolderInput is a sidebar folder create/update request.
 type ConnectionFolderInput struct {
 	Name     string
@@ -95,18 +125,21 @@ type ConnectionFolderOrderInput struct {
 
 // ConnectionDetail is the client edit/detail view; it never carries secret values.
 type ConnectionDetail struct {
-	ID                 string                        `json:"id"`
-	Name               string                        `json:"name"`
-	Protocol           string                        `json:"protocol"`
-	Transport          string                        `json:"transport"`
-	OwnerID            string                        `json:"ownerId"`
-	Config             map[string]any                `json:"config"`
-	Secrets            map[string]string             `json:"secrets"`
-	Credentials        map[string]CredentialRefState `json:"credentials,omitempty"`
-	Recording          map[string]string             `json:"recording"`
-	AIMode             models.AIMode                 `json:"aiMode"`
-	AIAllowDestructive bool                          `json:"aiAllowDestructive"`
-	AIAutoApprove      bool                          `json:"aiAutoApprove"`
+	ID                  string                        `json:"id"`
+	Name                string                        `json:"name"`
+	Protocol            string                        `json:"protocol"`
+	Transport           string                        `json:"transport"`
+	OwnerID             string                        `json:"ownerId"`
+	Config              map[string]any                `json:"config"`
+	Secrets             map[string]string             `json:"secrets"`
+	Credentials         map[string]CredentialRefState `json:"credentials,omitempty"`
+	Recording           map[string]string             `json:"recording"`
+	AIMode              models.AIMode                 `json:"aiMode"`
+	AIAllowDestructive  bool                          `json:"aiAllowDestructive"`
+	AIAutoApprove       bool                          `json:"aiAutoApprove"`
+	AllowFileTransfer   bool                          `json:"allowFileTransfer"`
+	AllowClipboard      bool                          `json:"allowClipboard"`
+	RecordingFailClosed bool                          `json:"recordingFailClosed"`
 }
 
 type CredentialRefState struct {
@@ -134,6 +167,13 @@ const defaultFolderColor = "slate"
 
 // Create validates, encrypts inline secrets, and persists a new connection.
 func (s *ConnectionService) Create(ctx context.Context, ownerID string, in ConnectionInput) (models.Connection, error) {
+	ctx, cancel := WithTimeout(ctx, OpWrite)
+	defer cancel()
+	conn, err := s.create(ctx, ownerID, in)
+	return conn, timeoutError(ctx, err)
+}
+
+func (s *ConnectionService) create(ctx context.Context, ownerID string, in ConnectionInput) (models.Connection, error) {
 	m, ok := s.plugins.Manifest(in.Protocol)
 	if !ok {
 		return models.Connection{}, fmt.Errorf("%w: unknown protocol %q", plugin.ErrInvalidInput, in.Protocol)
@@ -146,6 +186,9 @@ func (s *ConnectionService) Create(ctx context.Context, ownerID string, in Conne
 		return models.Connection{}, fmt.Errorf("%w: name is required", plugin.ErrInvalidInput)
 	}
 	context := connectionSchemaContext(in.Protocol, transport)
+	if err := checkConfigKeys(m.Config, in.Config); err != nil {
+		return models.Connection{}, err
+	}
 	configWithDefaults := m.Config.ValuesWithDefaults(in.Config)
 	if err := m.Config.ValidateValuesWithContext(configWithDefaults, nil, context); err != nil {
 		return models.Connection{}, err
@@ -181,6 +224,7 @@ func (s *ConnectionService) Create(ctx context.Context, ownerID string, in Conne
 		OwnerID:            ownerID,
 		Transport:          transport,
 		Config:             config,
+		ConfigVersion:      m.Version,
 		Secrets:            enc,
 		Recording:          recording,
 		AIMode:             aiMode,
@@ -189,15 +233,34 @@ func (s *ConnectionService) Create(ctx context.Context, ownerID string, in Conne
 		CreatedAt:          now,
 		UpdatedAt:          now,
 	}
+	applyFeaturePolicy(&conn, in)
+	if _, err := s.reserveName(ctx, ownerID, conn.ID, in.FolderID, conn.Name); err != nil {
+		return models.Connection{}, err
+	}
 	if err := s.conns.Create(ctx, &conn); err != nil {
+		s.undoClaim(ctx, ownerID, conn.ID, "", "")
 		return models.Connection{}, err
 	}
+	if in.FolderID != "" && s.placements != nil {
+		if err := s.placements.Set(ctx, &models.ConnectionPlacement{
+			UserID: ownerID, ConnectionID: conn.ID, FolderID: in.FolderID, SortOrder: in.SortOrder, UpdatedAt: now,
+		}); err != nil {
+			return models.Connection{}, err
+		}
+	}
 	return conn, nil
 }
 
 // Update re-validates and persists connection changes. Blank secrets keep the
 // stored ciphertext.
 func (s *ConnectionService) Update(ctx context.Context, existing models.Connection, in ConnectionInput) (models.Connection, error) {
+	ctx, cancel := WithTimeout(ctx, OpWrite)
+	defer cancel()
+	conn, err := s.update(ctx, existing, in)
+	return conn, timeoutError(ctx, err)
+}
+
+func (s *ConnectionService) update(ctx context.Context, existing models.Connection, in ConnectionInput) (models.Connection, error) {
 	m, ok := s.plugins.Manifest(existing.Protocol)
 	if !ok {
 		return models.Connection{}, fmt.Errorf("%w: unknown protocol %q", plugin.ErrInvalidInput, existing.Protocol)
@@ -210,11 +273,15 @@ func (s *ConnectionService) Update(ctx context.Context, existing models.Connecti
 		return models.Connection{}, fmt.Errorf("%w: name is required", plugin.ErrInvalidInput)
 	}
 
+	existing, _ = migrateConnection(m, existing)
 	context := connectionSchemaContext(existing.Protocol, transport)
 	mergedConfig, err := s.mergePreservedCredentialRefs(existing, m.Config, in)
 	if err != nil {
 		return models.Connection{}, err
 	}
+	if err := checkConfigKeys(m.Config, mergedConfig); err != nil {
+		return models.Connection{}, err
+	}
 	mergedConfig = m.Config.ValuesWithDefaults(mergedConfig)
 	// Validate against a view where retained secrets count as present.
 	validateView := map[string]any{}
@@ -258,23 +325,110 @@ func (s *ConnectionService) Update(ctx context.Context, existing models.Connecti
 		}
 	}
 
+	prevName, renamed := existing.Name, nameKey(existing.Name) != nameKey(in.Name)
 	existing.Name = in.Name
 	existing.Transport = transport
 	existing.Config = config
+	existing.ConfigVersion = m.Version
 	existing.Secrets = enc
 	existing.Recording = recording
 	existing.AIMode = aiMode
 	existing.AIAllowDestructive = aiAllowDestructive
 	existing.AIAutoApprove = aiAutoApprove
+	applyFeaturePolicy(&existing, in)
 	existing.UpdatedAt = time.Now()
+	var folder string
+	if renamed {
+		if folder, err = s.reserveName(ctx, existing.OwnerID, existing.ID, "", existing.Name); err != nil {
+			return models.Connection{}, err
+		}
+	}
 	if err := s.conns.Update(ctx, &existing); err != nil {
+		if renamed {
+			s.undoClaim(ctx, existing.OwnerID, existing.ID, folder, prevName)
+		}
 		return models.Connection{}, err
 	}
 	return existing, nil
 }
 
+// Delete moves a connection to the trash; PurgeTrash removes it for good.
 func (s *ConnectionService) Delete(ctx context.Context, id string) error {
-	return s.conns.Delete(ctx, id)
+	ctx, cancel := WithTimeout(ctx, OpWrite)
+	defer cancel()
+	if err := s.conns.Trash(ctx, id, time.Now()); err != nil {
+		return timeoutError(ctx, err)
+	}
+	return timeoutError(ctx, s.releaseName(ctx, id))
+}
+
+// Trash lists the owner's trashed connections, most recently deleted first.
+func (s *ConnectionService) Trash(ctx context.Context, ownerID string) ([]models.Connection, error) {
+	ctx, cancel := WithTimeout(ctx, OpRead)
+	defer cancel()
+	conns, err := s.conns.ListTrashed(ctx, store.TrashFilter{OwnerID: ownerID})
+	return conns, timeoutError(ctx, err)
+}
+
+// Restore brings a trashed connection back into its folder. When the owner
+// has since created a live connection with the same name there, the restored
+// one gets a suffix instead.
+func (s *ConnectionService) Restore(ctx context.Context, conn models.Connection) (models.Connection, error) {
+	ctx, cancel := WithTimeout(ctx, OpWrite)
+	defer cancel()
+	folder, taken, err := s.folderNames(ctx, conn.OwnerID, conn.ID, "")
+	if err != nil {
+		return models.Connection{}, timeoutError(ctx, err)
+	}
+	name := conn.Name
+	for i := 1; ; i++ {
+		if !taken[nameKey(name)] {
+			err := s.claimName(ctx, conn.OwnerID, conn.ID, folder, name, taken)
+			if err == nil {
+				break
+			}
+			var nameErr *NameConflictError
+			if !errors.As(err, &nameErr) {
+				return models.Connection{}, timeoutError(ctx, err)
+			}
+			taken[nameKey(name)] = true
+		}
+		name = restoredName(conn.Name, i)
+	}
+	if err := s.conns.Restore(ctx, conn.ID, name); err != nil {
+		s.undoClaim(ctx, conn.OwnerID, conn.ID, "", "")
+		return models.Connection{}, timeoutError(ctx, err)
+	}
+	restored, err := s.conns.Get(ctx, conn.ID)
+	return restored, timeoutError(ctx, err)
+}
+
+func restoredName(name string, n int) string {
+	if n == 1 {
+		return name + " (restored)"
+	}
+	return fmt.Sprintf("%s (restored %d)", name, n)
+}
+
+// PurgeTrash permanently deletes connections trashed before the cutoff and
+// returns their IDs so the caller can drop state keyed by them.
+func (s *ConnectionService) PurgeTrash(ctx context.Context, before time.Time) ([]string, error) {
+	ctx = WithoutTimeouts(ctx)
+	trashed, err := s.conns.ListTrashed(ctx, store.TrashFilter{TrashedBefore: before})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(trashed))
+	for _, c := range trashed {
+		if err := s.conns.Delete(ctx, c.ID); err != nil {
+			return ids, err
+		}
+		if err := s.releaseName(ctx, c.ID); err != nil {
+			return ids, err
+		}
+		ids = append(ids, c.ID)
+	}
+	return ids, nil
 }
 
 // CreateFolder creates one user-owned sidebar folder.
@@ -287,9 +441,11 @@ func (s *ConnectionService) CreateFolder(ctx context.Context, folders store.Conn
 	if err != nil {
 		return models.ConnectionFolder{}, err
 	}
+	ctx, cancel := WithTimeout(ctx, OpWrite)
+	defer cancel()
 	existing, err := folders.ListByUser(ctx, userID)
 	if err != nil {
-		return models.ConnectionFolder{}, err
+		return models.ConnectionFolder{}, timeoutError(ctx, err)
 	}
 	if in.ParentID != "" && !folderExists(existing, in.ParentID) {
 		return models.ConnectionFolder{}, fmt.Errorf("%w: unknown parent folder %q", plugin.ErrInvalidInput, in.ParentID)
@@ -300,7 +456,7 @@ func (s *ConnectionService) CreateFolder(ctx context.Context, folders store.Conn
 		ParentID: in.ParentID, SortOrder: nextFolderOrder(existing, in.ParentID), CreatedAt: now, UpdatedAt: now,
 	}
 	if err := folders.Create(ctx, &folder); err != nil {
-		return models.ConnectionFolder{}, err
+		return models.ConnectionFolder{}, timeoutError(ctx, err)
 	}
 	return folder, nil
 }
@@ -318,8 +474,10 @@ func (s *ConnectionService) UpdateFolder(ctx context.Context, folders store.Conn
 	existing.Name = name
 	existing.Color = color
 	existing.UpdatedAt = time.Now()
+	ctx, cancel := WithTimeout(ctx, OpWrite)
+	defer cancel()
 	if err := folders.Update(ctx, &existing); err != nil {
-		return models.ConnectionFolder{}, err
+		return models.ConnectionFolder{}, timeoutError(ctx, err)
 	}
 	return existing, nil
 }
@@ -330,20 +488,27 @@ func FolderDTO(f models.ConnectionFolder) ConnectionFolderDTO {
 }
 
 // SaveConnectionLayout validates and persists a user's connection placements.
-func SaveConnectionLayout(ctx context.Context, placements store.ConnectionPlacementStore, userID string, accessible map[string]bool, folders map[string]bool, in []ConnectionPlacementInput) error {
-	seen := map[string]bool{}
-	now := time.Now()
+// claim runs first with the folder each listed connection moves to, so name
+// clashes are refused before any placement changes.
+func SaveConnectionLayout(ctx context.Context, placements store.ConnectionPlacementStore, userID string, accessible map[string]bool, folders map[string]bool, in []ConnectionPlacementInput, claim func(moves map[string]string) error) error {
+	moves := make(map[string]string, len(in))
 	for _, item := range in {
 		if !accessible[item.ConnectionID] {
 			return fmt.Errorf("%w: connection %q is not accessible", plugin.ErrForbidden, item.ConnectionID)
 		}
-		if seen[item.ConnectionID] {
+		if _, ok := moves[item.ConnectionID]; ok {
 			return fmt.Errorf("%w: duplicate connection %q", plugin.ErrInvalidInput, item.ConnectionID)
 		}
-		seen[item.ConnectionID] = true
 		if item.FolderID != "" && !folders[item.FolderID] {
 			return fmt.Errorf("%w: unknown folder %q", plugin.ErrInvalidInput, item.FolderID)
 		}
+		moves[item.ConnectionID] = item.FolderID
+	}
+	if err := claim(moves); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, item := range in {
 		p := models.ConnectionPlacement{
 			UserID: userID, ConnectionID: item.ConnectionID, FolderID: item.FolderID,
 			SortOrder: item.SortOrder, UpdatedAt: now,
@@ -355,6 +520,65 @@ func SaveConnectionLayout(ctx context.Context, placements store.ConnectionPlacem
 	return nil
 }
 
+// AddConnectionFavorite pins a connection at the end of the user's favorites.
+// Pinning an existing favorite keeps its position.
+func AddConnectionFavorite(ctx context.Context, favorites store.ConnectionFavoriteStore, userID, connectionID string) error {
+	existing, err := favorites.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	next := 0
+	for _, f := range existing {
+		if f.ConnectionID == connectionID {
+			return nil
+		}
+		next = max(next, f.Position+1)
+	}
+	return favorites.Set(ctx, &models.ConnectionFavorite{
+		UserID: userID, ConnectionID: connectionID, Position: next, CreatedAt: time.Now(),
+	})
+}
+
+// ReorderConnectionFavorites moves the listed favorites to the front in the
+// given order; favorites not listed keep their relative order after them.
+func ReorderConnectionFavorites(ctx context.Context, favorites store.ConnectionFavoriteStore, userID string, order []string) error {
+	existing, err := favorites.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]models.ConnectionFavorite, len(existing))
+	for _, f := range existing {
+		byID[f.ConnectionID] = f
+	}
+	seen := map[string]bool{}
+	for _, id := range order {
+		if _, ok := byID[id]; !ok {
+			return fmt.Errorf("%w: connection %q is not a favorite", plugin.ErrInvalidInput, id)
+		}
+		if seen[id] {
+			return fmt.Errorf("%w: duplicate connection %q", plugin.ErrInvalidInput, id)
+		}
+		seen[id] = true
+	}
+	ids := slices.Clone(order)
+	for _, f := range existing {
+		if !seen[f.ConnectionID] {
+			ids = append(ids, f.ConnectionID)
+		}
+	}
+	for i, id := range ids {
+		f := byID[id]
+		if f.Position == i {
+			continue
+		}
+		f.Position = i
+		if err := favorites.Set(ctx, &f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // SaveConnectionFolderOrder validates and persists a user's folder ordering.
 func SaveConnectionFolderOrder(ctx context.Context, folderStore store.ConnectionFolderStore, userID string, in []ConnectionFolderOrderInput) error {
 	existing, err := folderStore.ListByUser(ctx, userID)
@@ -407,18 +631,21 @@ func SaveConnectionFolderOrder(ctx context.Context, folderStore store.Connection
 
 // ReferencesCredential reports whether any connection config uses credentialID.
 func (s *ConnectionService) ReferencesCredential(ctx context.Context, credentialID string) (bool, error) {
+	ctx, cancel := WithTimeout(ctx, OpRead)
+	defer cancel()
 	conns, err := s.conns.List(ctx)
 	if err != nil {
-		return false, err
+		return false, timeoutError(ctx, err)
 	}
 	for _, c := range conns {
 		if m, ok := s.plugins.Manifest(c.Protocol); ok {
+			c, _ = migrateConnection(m, c)
 			config := m.Config.VisibleValues(
 				m.Config.ValuesWithDefaults(c.Config),
 				connectionSchemaContext(c.Protocol, c.Transport),
 			)
-			for _, key := range credentialRefKeys(m.Config) {
-				if id, _ := config[key].(string); id == credentialID {
+			for _, ref := range credentialRefs(m.Config, config) {
+				if ref.ID == credentialID {
 					return true, nil
 				}
 			}
@@ -435,6 +662,7 @@ func (s *ConnectionService) ReferencesCredential(ctx context.Context, credential
 // field as "set" or "not set" without revealing any value.
 func (s *ConnectionService) Detail(ctx context.Context, userID string, conn models.Connection) ConnectionDetail {
 	m, _ := s.plugins.Manifest(conn.Protocol)
+	conn, _ = migrateConnection(m, conn)
 	state := map[string]string{}
 	context := connectionSchemaContext(conn.Protocol, conn.Transport)
 	configWithDefaults := m.Config.ValuesWithDefaults(conn.Config)
@@ -453,7 +681,9 @@ func (s *ConnectionService) Detail(ctx context.Context, userID string, conn mode
 		Transport: conn.Transport, OwnerID: conn.OwnerID,
 		Config: config, Secrets: state, Credentials: credentialStates, Recording: recording,
 		AIMode: conn.AIMode, AIAllowDestructive: conn.AIAllowDestructive,
-		AIAutoApprove: conn.AIAutoApprove,
+		AIAutoApprove:     conn.AIAutoApprove,
+		AllowFileTransfer: !conn.FileTransferDisabled, AllowClipboard: !conn.ClipboardDisabled,
+		RecordingFailClosed: conn.RecordingFailClosed,
 	}
 }
 
@@ -570,54 +800,43 @@ func nextFolderOrder(folders []models.ConnectionFolder, parentID string) int {
 // checkCredentialRefs ensures every referenced credential is usable by ownerID
 // and matches the field's selector constraints.
 func (s *ConnectionService) checkCredentialRefs(ctx context.Context, ownerID, protocol string, schema plugin.Schema, values map[string]any) error {
-	for _, group := range schema.Groups {
-		for _, field := range group.Fields {
-			if field.Type != plugin.FieldCredentialRef {
-				continue
-			}
-			id, _ := values[field.Key].(string)
-			if strings.TrimSpace(id) == "" {
-				continue
-			}
-			kinds := credentialSelectorKinds(field.Credential)
-			if field.Credential != nil && len(field.Credential.Protocols) > 0 && !slices.Contains(field.Credential.Protocols, protocol) {
-				return fmt.Errorf("%w: credential field %q is not valid for protocol %q", plugin.ErrInvalidInput, field.Key, protocol)
-			}
-			if err := s.creds.EnsureUsableFor(ctx, ownerID, id, kinds, protocol); err != nil {
-				return err
-			}
+	for _, ref := range credentialRefs(schema, values) {
+		if err := s.checkCredentialRef(ctx, ownerID, protocol, ref); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// checkCredentialRefsForUpdate checks refs as the acting user, except preserved
+// top-level fields and unchanged refs inside array items, which were already
+// authorized for the owner.
 func (s *ConnectionService) checkCredentialRefsForUpdate(ctx context.Context, actorID string, existing models.Connection, schema plugin.Schema, values map[string]any, preserve []string) error {
 	preserved := stringSet(preserve)
-	for _, group := range schema.Groups {
-		for _, field := range group.Fields {
-			if field.Type != plugin.FieldCredentialRef {
-				continue
-			}
-			id, _ := values[field.Key].(string)
-			if strings.TrimSpace(id) == "" {
-				continue
-			}
-			kinds := credentialSelectorKinds(field.Credential)
-			if field.Credential != nil && len(field.Credential.Protocols) > 0 && !slices.Contains(field.Credential.Protocols, existing.Protocol) {
-				return fmt.Errorf("%w: credential field %q is not valid for protocol %q", plugin.ErrInvalidInput, field.Key, existing.Protocol)
-			}
-			userID := actorID
-			if preserved[field.Key] {
-				userID = existing.OwnerID
-			}
-			if err := s.creds.EnsureUsableFor(ctx, userID, id, kinds, existing.Protocol); err != nil {
-				return err
-			}
+	previous := map[string]string{}
+	for _, ref := range credentialRefs(schema, existing.Config) {
+		previous[ref.Binding] = ref.ID
+	}
+	for _, ref := range credentialRefs(schema, values) {
+		userID := actorID
+		nested := ref.Binding != ref.Field.Key
+		if preserved[ref.Binding] || (nested && previous[ref.Binding] == ref.ID) {
+			userID = existing.OwnerID
+		}
+		if err := s.checkCredentialRef(ctx, userID, existing.Protocol, ref); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+func (s *ConnectionService) checkCredentialRef(ctx context.Context, userID, protocol string, ref credentialRef) error {
+	if ref.Field.Credential != nil && len(ref.Field.Credential.Protocols) > 0 && !slices.Contains(ref.Field.Credential.Protocols, protocol) {
+		return fmt.Errorf("%w: credential field %q is not valid for protocol %q", plugin.ErrInvalidInput, ref.Binding, protocol)
+	}
+	return s.creds.EnsureUsableFor(ctx, userID, ref.ID, credentialSelectorKinds(ref.Field.Credential), protocol)
+}
+
 func (s *ConnectionService) mergePreservedCredentialRefs(existing models.Connection, schema plugin.Schema, in ConnectionInput) (map[string]any, error) {
 	out := map[string]any{}
 	maps.Copy(out, in.Config)
@@ -663,6 +882,45 @@ func credentialRefKeys(schema plugin.Schema) []string {
 	return keys
 }
 
+// credentialRef is one credential id selected in connection config. Binding is
+// the key the resolved credential is exposed under to the plugin: the field key
+// at the top level, or plugin.ItemCredentialField for refs inside array items.
+type credentialRef struct {
+	Binding string
+	Field   plugin.Field
+	ID      string
+}
+
+// credentialRefs lists the non-empty credential_ref values in config, including
+// refs declared on the object items of array fields (e.g. per-hop credentials).
+func credentialRefs(schema plugin.Schema, values map[string]any) []credentialRef {
+	var refs []credentialRef
+	for _, group := range schema.Groups {
+		for _, field := range group.Fields {
+			switch {
+			case field.Type == plugin.FieldCredentialRef:
+				if id, _ := values[field.Key].(string); strings.TrimSpace(id) != "" {
+					refs = append(refs, credentialRef{Binding: field.Key, Field: field, ID: id})
+				}
+			case field.Type == plugin.FieldArray && field.Item != nil && field.Item.Type == plugin.FieldObject:
+				items, _ := values[field.Key].([]any)
+				for i, item := range items {
+					obj, _ := item.(map[string]any)
+					for _, sub := range field.Item.Fields {
+						if sub.Type != plugin.FieldCredentialRef {
+							continue
+						}
+						if id, _ := obj[sub.Key].(string); strings.TrimSpace(id) != "" {
+							refs = append(refs, credentialRef{Binding: plugin.ItemCredentialField(field.Key, i, sub.Key), Field: sub, ID: id})
+						}
+					}
+				}
+			}
+		}
+	}
+	return refs
+}
+
 func credentialSelectorKinds(selector *plugin.CredentialSelector) []string {
 	if selector == nil || selector.Kind == "" {
 		return nil
@@ -697,6 +955,29 @@ func connectionSchemaContext(protocol, transport string) map[string]any {
 }
 
 // secretKeys returns the keys of all Secret==true fields in a schema.
+// checkConfigKeys rejects every settings key the protocol's schema does not
+// declare, naming the declared key each one is probably a typo of.
+func checkConfigKeys(schema plugin.Schema, values map[string]any) error {
+	var known []string
+	for _, group := range schema.Groups {
+		for _, field := range group.Fields {
+			known = append(known, field.Key)
+		}
+	}
+	verr := &ValidationError{}
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		if slices.Contains(known, key) {
+			continue
+		}
+		fe := FieldError{Field: key, Message: fmt.Sprintf("unknown setting %q", key)}
+		if fe.Suggestion = closestKey(key, known); fe.Suggestion != "" {
+			fe.Message += fmt.Sprintf("; did you mean %q?", fe.Suggestion)
+		}
+		verr.Fields = append(verr.Fields, fe)
+	}
+	return verr.err()
+}
+
 func secretKeys(schema plugin.Schema) []string {
 	var keys []string
 	for _, group := range schema.Groups {
@@ -21,6 +21,7 @@ type Connector struct {
 	creds          *CredentialService
 	vault          secrets.SecretStore
 	tunnels        transport.TunnelRegistry
+	drivers        *DriverSettings
 	onSecretAccess func()
 }
 
@@ -33,6 +34,12 @@ func (c *Connector) SetSecretAccessHook(fn func()) {
 	c.onSecretAccess = fn
 }
 
+// SetDriverSettings makes Build pass each Configurable plugin its current
+// driver settings.
+func (c *Connector) SetDriverSettings(d *DriverSettings) {
+	c.drivers = d
+}
+
 // Plugin resolves the plugin singleton for a connection's protocol.
 func (c *Connector) Plugin(conn models.Connection) (plugin.Plugin, bool) {
 	return c.plugins.Get(conn.Protocol)
@@ -48,9 +55,15 @@ func (c *Connector) Build(ctx context.Context, user models.User, conn models.Con
 	cfg := map[string]any{}
 	manifest, hasManifest := c.plugins.Manifest(conn.Protocol)
 	if hasManifest {
+		conn, _ = migrateConnection(manifest, conn)
 		context := connectionSchemaContext(conn.Protocol, conn.Transport)
 		configWithDefaults := manifest.Config.ValuesWithDefaults(conn.Config)
 		maps.Copy(cfg, manifest.Config.VisibleValues(configWithDefaults, context))
+		if user.ID != conn.OwnerID {
+			for _, key := range manifest.Config.OwnerOnlyKeys(configWithDefaults) {
+				delete(cfg, key)
+			}
+		}
 	} else {
 		maps.Copy(cfg, conn.Config)
 	}
@@ -89,30 +102,23 @@ func (c *Connector) Build(ctx context.Context, user models.User, conn models.Con
 	// route wrapper has already authorized the acting user against the connection;
 	// credential records remain hidden unless separately shared.
 	if hasManifest {
-		for _, group := range manifest.Config.Groups {
-			for _, field := range group.Fields {
-				if field.Type != plugin.FieldCredentialRef {
-					continue
-				}
-				key := field.Key
-				if credID, _ := cfg[key].(string); credID != "" {
-					if err := c.creds.EnsureUsableFor(ctx, conn.OwnerID, credID, credentialSelectorKinds(field.Credential), conn.Protocol); err != nil {
-						return plugin.ConnectConfig{}, nil, fmt.Errorf("resolve credential: %w", err)
-					}
-					cred, values, err := c.creds.ResolveWithMetadata(ctx, conn.OwnerID, credID)
-					if err != nil {
-						return plugin.ConnectConfig{}, nil, fmt.Errorf("resolve credential: %w", err)
-					}
-					credentialBindings = append(credentialBindings, plugin.CredentialBinding{
-						Field: key,
-						Credential: plugin.ResolvedCredential{
-							ID:     cred.ID,
-							Kind:   plugin.CredentialKind(cred.Kind),
-							Values: values,
-						},
-					})
-				}
+		for _, ref := range credentialRefs(manifest.Config, cfg) {
+			if err := c.creds.EnsureUsableFor(ctx, conn.OwnerID, ref.ID, credentialSelectorKinds(ref.Field.Credential), conn.Protocol); err != nil {
+				return plugin.ConnectConfig{}, nil, fmt.Errorf("resolve credential: %w", err)
 			}
+			accessCtx := WithCredentialAccess(ctx, user.ID, conn.ID, CredentialPurposeSessionLaunch)
+			cred, values, err := c.creds.ResolveWithMetadata(accessCtx, conn.OwnerID, ref.ID)
+			if err != nil {
+				return plugin.ConnectConfig{}, nil, fmt.Errorf("resolve credential: %w", err)
+			}
+			credentialBindings = append(credentialBindings, plugin.CredentialBinding{
+				Field: ref.Binding,
+				Credential: plugin.ResolvedCredential{
+					ID:     cred.ID,
+					Kind:   plugin.CredentialKind(cred.Kind),
+					Values: values,
+				},
+			})
 		}
 	}
 
@@ -126,12 +132,19 @@ func (c *Connector) Build(ctx context.Context, user models.User, conn models.Con
 	if err != nil {
 		return plugin.ConnectConfig{}, nil, err
 	}
+	var settings map[string]any
+	if c.drivers != nil {
+		if settings, err = c.drivers.Values(ctx, conn.Protocol); err != nil {
+			return plugin.ConnectConfig{}, nil, fmt.Errorf("driver settings: %w", err)
+		}
+	}
 
 	return plugin.ConnectConfig{
 		ConnectionID: conn.ID,
 		UserID:       user.ID,
 		Transport:    plugin.Transport(conn.Transport),
 		Config:       cfg,
+		Settings:     settings,
 		Credentials:  plugin.NewResolvedCredentials(credentialBindings...),
 		Net:          net,
 	}, plg, nil
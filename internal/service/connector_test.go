@@ -2,6 +2,7 @@ package service_test
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
 
@@ -32,6 +33,16 @@ func (credentialRefPlugin) Manifest() plugin.Manifest {
 				Key: "api_credential", Label: "API Credential", Type: plugin.FieldCredentialRef,
 				Credential: &plugin.CredentialSelector{Kind: plugin.CredentialKindAPIToken},
 			},
+			{
+				Key: "hops", Label: "Hops", Type: plugin.FieldArray,
+				Item: &plugin.Field{Type: plugin.FieldObject, Fields: []plugin.Field{
+					{Key: "host", Label: "Host", Type: plugin.FieldText},
+					{
+						Key: "credential", Label: "Credential", Type: plugin.FieldCredentialRef,
+						Credential: &plugin.CredentialSelector{Kind: plugin.CredentialKindAPIToken},
+					},
+				}},
+			},
 		}}}},
 		Tabs: []plugin.Panel{{Key: "main", Label: "Main", Type: plugin.PanelTable}},
 	}
@@ -94,6 +105,46 @@ func TestConnectorResolvesCredentialRefFieldsFromSchema(t *testing.T) {
 	}
 }
 
+func TestConnectorResolvesAndAuthorizesCredentialRefsInArrayItems(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	key, _ := secrets.GenerateMasterKey()
+	vault, _ := secrets.NewVault(key)
+	reg := pluginregistry.New()
+	reg.MustRegister(credentialRefPlugin{})
+	creds := service.NewCredentialService(st.Credentials, st.CredentialGrants, vault, service.WithCredentialKindCatalog(reg))
+
+	own, _ := creds.Create(ctx, service.NewCredentialInput{
+		OwnerID: "u1", Name: "hop", Kind: "api_token",
+		Values: map[string]string{"subject": "svc", "token": "hop-token"},
+	})
+	foreign, _ := creds.Create(ctx, service.NewCredentialInput{
+		OwnerID: "u2", Name: "other", Kind: "api_token",
+		Values: map[string]string{"subject": "svc", "token": "foreign-token"},
+	})
+
+	connector := service.NewConnector(reg, creds, vault, transport.NewRegistry())
+	conn := models.Connection{
+		ID: "c1", Protocol: "http-api", Transport: string(plugin.TransportDirect), OwnerID: "u1",
+		Config: map[string]any{"hops": []any{
+			map[string]any{"host": "a.test"},
+			map[string]any{"host": "b.test", "credential": own.ID},
+		}},
+	}
+	cfg, _, err := connector.Build(ctx, models.User{ID: "u1"}, conn)
+	if err != nil {
+		t.Fatalf("build config: %v", err)
+	}
+	if got := cfg.CredentialValueFor(plugin.ItemCredentialField("hops", 1, "credential"), "token"); got != "hop-token" {
+		t.Fatalf("hop credential token = %q, want hop-token", got)
+	}
+
+	conn.Config = map[string]any{"hops": []any{map[string]any{"host": "a.test", "credential": foreign.ID}}}
+	if _, _, err := connector.Build(ctx, models.User{ID: "u1"}, conn); err == nil {
+		t.Fatal("hop credential the owner cannot use should block launch")
+	}
+}
+
 func TestConnectorResolvesSharedConnectionCredentialAsConnectionOwner(t *testing.T) {
 	ctx := context.Background()
 	st := store.NewMemory()
@@ -202,3 +253,148 @@ func TestConnectorTransportAllowlistExcludesSecrets(t *testing.T) {
 		t.Fatalf("declared host must stay allowed, got %v", err)
 	}
 }
+
+type ownerOnlyPlugin struct{}
+
+func (ownerOnlyPlugin) Manifest() plugin.Manifest {
+	return plugin.Manifest{
+		APIVersion:          plugin.CurrentAPIVersion,
+		Name:                "owner-only",
+		Version:             "0",
+		Title:               "Owner Only",
+		Category:            plugin.CategoryDevOps,
+		Layout:              plugin.LayoutTabs,
+		SupportedTransports: []plugin.Transport{plugin.TransportDirect},
+		Config: plugin.Schema{Groups: []plugin.Group{{Name: "Init", Fields: []plugin.Field{
+			{Key: "host", Label: "Host", Type: plugin.FieldText},
+			{Key: "init_command", Label: "Command", Type: plugin.FieldTextarea, OwnerOnly: true, SharedBy: "share_init"},
+			{Key: "share_init", Label: "Share", Type: plugin.FieldToggle},
+		}}}},
+		Tabs: []plugin.Panel{{Key: "main", Label: "Main", Type: plugin.PanelTable}},
+	}
+}
+
+func (ownerOnlyPlugin) Routes() []plugin.Route { return nil }
+func (ownerOnlyPlugin) Connect(context.Context, plugin.ConnectConfig) (plugin.Session, error) {
+	return nil, nil
+}
+
+func TestConnectorWithholdsOwnerOnlyFieldsFromOtherUsers(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	key, _ := secrets.GenerateMasterKey()
+	vault, _ := secrets.NewVault(key)
+	reg := pluginregistry.New()
+	reg.MustRegister(ownerOnlyPlugin{})
+	creds := service.NewCredentialService(st.Credentials, st.CredentialGrants, vault, service.WithCredentialKindCatalog(reg))
+	connector := service.NewConnector(reg, creds, vault, transport.NewRegistry())
+
+	conn := models.Connection{
+		ID: "c1", Protocol: "owner-only", Transport: string(plugin.TransportDirect), OwnerID: "owner",
+		Config: map[string]any{"host": "127.0.0.1", "init_command": "cd /srv"},
+	}
+	for _, tc := range []struct {
+		user   string
+		shared bool
+		want   bool
+	}{
+		{"owner", false, true},
+		{"viewer", false, false},
+		{"viewer", true, true},
+	} {
+		conn.Config["share_init"] = tc.shared
+		cfg, _, err := connector.Build(ctx, models.User{ID: tc.user}, conn)
+		if err != nil {
+			t.Fatalf("build config: %v", err)
+		}
+		if _, ok := cfg.Config["init_command"]; ok != tc.want {
+			t.Fatalf("user=%s shared=%v: init_command present=%v, want %v", tc.user, tc.shared, ok, tc.want)
+		}
+		if cfg.Config["host"] != "127.0.0.1" {
+			t.Fatalf("ordinary fields must pass through, got %#v", cfg.Config)
+		}
+	}
+}
+
+func TestConnectorSnapshotDropsSecretsAndBoundsSize(t *testing.T) {
+	reg := pluginregistry.New()
+	reg.MustRegister(credentialRefPlugin{})
+	connector := service.NewConnector(reg, nil, nil, transport.NewRegistry())
+	conn := models.Connection{
+		ID: "c1", Protocol: "http-api", Transport: string(plugin.TransportDirect),
+		Secrets: map[string][]byte{"token": []byte("ciphertext")},
+	}
+
+	snap := connector.Snapshot(conn, plugin.ConnectConfig{Config: map[string]any{
+		"api_credential": "cred-1", "token": "plain-secret", "blob": strings.Repeat("x", 2048),
+	}})
+	if snap.Protocol != "http-api" || snap.Config["api_credential"] != "cred-1" {
+		t.Fatalf("snapshot = %+v", snap)
+	}
+	if _, ok := snap.Config["token"]; ok {
+		t.Fatal("inline secret leaked into the snapshot")
+	}
+	if _, ok := snap.Config["blob"]; ok || !snap.Truncated {
+		t.Fatalf("oversized value kept or not flagged: truncated=%v", snap.Truncated)
+	}
+
+	wide := map[string]any{}
+	for i := range 100 {
+		wide[strings.Repeat("k", i+1)] = i
+	}
+	if snap := connector.Snapshot(conn, plugin.ConnectConfig{Config: wide}); len(snap.Config) != 64 || !snap.Truncated {
+		t.Fatalf("wide config: %d fields, truncated=%v", len(snap.Config), snap.Truncated)
+	}
+}
+
+type configurablePlugin struct{ credentialRefPlugin }
+
+func (configurablePlugin) Settings() plugin.Schema {
+	return plugin.Schema{Groups: []plugin.Group{{Name: "Defaults", Fields: []plugin.Field{{
+		Key: "mode", Label: "Mode", Type: plugin.FieldSelect, Default: "fast",
+		Options: []plugin.Option{{Label: "Fast", Value: "fast"}, {Label: "Safe", Value: "safe"}},
+	}}}}}
+}
+
+func TestConnectorPassesDriverSettingsAtBuild(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	key, _ := secrets.GenerateMasterKey()
+	vault, _ := secrets.NewVault(key)
+	reg := pluginregistry.New()
+	reg.MustRegister(configurablePlugin{})
+	settings := service.NewSettingsService(st.SystemSettings)
+	drivers := service.NewDriverSettings(reg, settings)
+	connector := service.NewConnector(reg, nil, vault, transport.NewRegistry())
+	connector.SetDriverSettings(drivers)
+	conn := models.Connection{ID: "c1", Protocol: "http-api", Transport: string(plugin.TransportDirect), OwnerID: "u1"}
+
+	running, _, err := connector.Build(ctx, models.User{ID: "u1"}, conn)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if running.Settings["mode"] != "fast" {
+		t.Fatalf("default settings = %v", running.Settings)
+	}
+	if _, err := drivers.Update(ctx, models.User{ID: "admin"}, "http-api", map[string]any{"mode": "reckless"}); !errors.Is(err, plugin.ErrInvalidInput) {
+		t.Fatalf("invalid option: %v", err)
+	}
+	if _, err := drivers.Update(ctx, models.User{ID: "admin"}, "http-api", map[string]any{"bogus": true}); !errors.Is(err, plugin.ErrInvalidInput) {
+		t.Fatalf("unknown key: %v", err)
+	}
+	view, err := drivers.Update(ctx, models.User{ID: "admin"}, "http-api", map[string]any{"mode": "safe"})
+	if err != nil || view.Values["mode"] != "safe" || view.UpdatedBy != "admin" || view.UpdatedAt == nil {
+		t.Fatalf("update = %+v, %v", view, err)
+	}
+
+	next, _, err := connector.Build(ctx, models.User{ID: "u1"}, conn)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if next.Settings["mode"] != "safe" || running.Settings["mode"] != "fast" {
+		t.Fatalf("settings after update: next=%v running=%v", next.Settings, running.Settings)
+	}
+	if _, err := drivers.Get(ctx, "missing"); !errors.Is(err, plugin.ErrNotFound) {
+		t.Fatalf("unknown driver: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+// defaultAccessLogQueue bounds the access-log rows waiting to be written.
+const defaultAccessLogQueue = 256
+
+// accessLogWriter appends access-log rows from its own goroutine, so a slow
+// or failing store never delays secret resolution; when the queue is full
+// the row is dropped and logged.
+type accessLogWriter struct {
+	rows   store.CredentialAccessLogStore
+	logger *slog.Logger
+	queue  chan *models.CredentialAccessLog
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newAccessLogWriter(rows store.CredentialAccessLogStore, logger *slog.Logger) *accessLogWriter {
+	w := &accessLogWriter{
+		rows: rows, logger: logger,
+		queue: make(chan *models.CredentialAccessLog, defaultAccessLogQueue),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *accessLogWriter) add(row *models.CredentialAccessLog) {
+	select {
+	case w.queue <- row:
+	default:
+		w.logger.Warn("credential access log queue full, entry dropped", "credential", row.CredentialID, "user", row.UserID)
+	}
+}
+
+// close writes the rows already queued and stops the writer.
+func (w *accessLogWriter) close() {
+	w.once.Do(func() { close(w.stop) })
+	<-w.done
+}
+
+func (w *accessLogWriter) run() {
+	defer close(w.done)
+	for {
+		select {
+		case row := <-w.queue:
+			w.write(row)
+		case <-w.stop:
+			for {
+				select {
+				case row := <-w.queue:
+					w.write(row)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (w *accessLogWriter) write(row *models.CredentialAccessLog) {
+	if err := w.rows.Append(context.Background(), row); err != nil {
+		w.logger.Warn("record credential access", "credential", row.CredentialID, "err", err)
+	}
+}
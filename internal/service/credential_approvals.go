@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// SettingApprovalExemptRoot, when "true", lets the protected root account
+// resolve approval-gated credentials without a second person.
+const SettingApprovalExemptRoot = "credentials.approval_exempt_root"
+
+const (
+	// DefaultApprovalTTL is how long a request waits for an approver.
+	DefaultApprovalTTL = time.Hour
+	// DefaultApprovalWindow is how long an approved request stays usable.
+	DefaultApprovalWindow = 15 * time.Minute
+)
+
+// ErrApprovalRequired is returned when a credential needs a second person's
+// approval before its secret can be resolved.
+var ErrApprovalRequired = errors.New("credential requires approval")
+
+// ApprovalRequiredError names the request the requester is waiting on.
+type ApprovalRequiredError struct {
+	ApprovalID string
+	ExpiresAt  time.Time
+}
+
+func (e *ApprovalRequiredError) Error() string {
+	return fmt.Sprintf("%s: request %s is waiting for another user to approve it before %s",
+		ErrApprovalRequired, e.ApprovalID, e.ExpiresAt.UTC().Format(time.RFC3339))
+}
+
+func (e *ApprovalRequiredError) Unwrap() error { return ErrApprovalRequired }
+
+// Credential approval lifecycle events.
+const (
+	ApprovalEventRequest = "credential.approval.request"
+	ApprovalEventApprove = "credential.approval.approve"
+	ApprovalEventUse     = "credential.approval.use"
+)
+
+// CredentialApprovalEvent is one step of an approval, for the audit trail.
+type CredentialApprovalEvent struct {
+	Event    string
+	ActorID  string
+	Approval models.CredentialApproval
+}
+
+// CredentialApprovals enforces two-person approval on credentials that
+// require it: a resolution by the requester needs an approval by someone
+// else, and each approval is spent by exactly one resolution.
+type CredentialApprovals struct {
+	approvals store.CredentialApprovalStore
+	creds     store.CredentialStore
+	grants    store.CredentialGrantStore
+	users     store.UserStore
+	settings  store.SystemSettingStore
+	ttl       time.Duration
+	window    time.Duration
+	now       func() time.Time
+
+	mu         sync.Mutex
+	exemptRoot bool
+	watchers   []func(CredentialApprovalEvent)
+}
+
+func NewCredentialApprovals(approvals store.CredentialApprovalStore, creds store.CredentialStore, grants store.CredentialGrantStore, users store.UserStore, settings store.SystemSettingStore) *CredentialApprovals {
+	return &CredentialApprovals{
+		approvals: approvals, creds: creds, grants: grants, users: users, settings: settings,
+		ttl: DefaultApprovalTTL, window: DefaultApprovalWindow, now: time.Now,
+	}
+}
+
+// Load restores the root exemption; unset means root needs approval too.
+func (a *CredentialApprovals) Load(ctx context.Context) error {
+	v, err := a.settings.Get(ctx, SettingApprovalExemptRoot)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	exempt, err := strconv.ParseBool(v.Value)
+	if err != nil {
+		return fmt.Errorf("decode %s: %w", SettingApprovalExemptRoot, err)
+	}
+	a.mu.Lock()
+	a.exemptRoot = exempt
+	a.mu.Unlock()
+	return nil
+}
+
+func (a *CredentialApprovals) ExemptRoot() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.exemptRoot
+}
+
+// SetExemptRoot persists and applies the root exemption.
+func (a *CredentialApprovals) SetExemptRoot(ctx context.Context, actor models.User, exempt bool) error {
+	if err := a.settings.Set(ctx, &models.SystemSetting{
+		Key: SettingApprovalExemptRoot, Value: strconv.FormatBool(exempt), UpdatedBy: actor.ID, UpdatedAt: a.now(),
+	}); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.exemptRoot = exempt
+	a.mu.Unlock()
+	return nil
+}
+
+// OnEvent registers fn for every request, approval and use. Watchers run on
+// the calling goroutine and must not block.
+func (a *CredentialApprovals) OnEvent(fn func(CredentialApprovalEvent)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.watchers = append(a.watchers, fn)
+}
+
+func (a *CredentialApprovals) emit(event, actorID string, approval models.CredentialApproval) {
+	a.mu.Lock()
+	watchers := slices.Clone(a.watchers)
+	a.mu.Unlock()
+	for _, fn := range watchers {
+		fn(CredentialApprovalEvent{Event: event, ActorID: actorID, Approval: approval})
+	}
+}
+
+// authorize lets requesterID resolve cred: it spends an open approval, or
+// files (or points at) a pending request and returns ApprovalRequiredError.
+func (a *CredentialApprovals) authorize(ctx context.Context, cred models.Credential, requesterID, connectionID string) error {
+	if !cred.RequiresApproval {
+		return nil
+	}
+	if a.ExemptRoot() {
+		u, err := a.users.GetByID(ctx, requesterID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+		if u.Protected {
+			return nil
+		}
+	}
+	now := a.now()
+	list, err := a.approvals.List(ctx, store.CredentialApprovalFilter{CredentialID: cred.ID, RequesterID: requesterID})
+	if err != nil {
+		return err
+	}
+	for _, r := range list {
+		if r.Status != models.ApprovalApproved || !now.Before(r.UseBy) {
+			continue
+		}
+		ok, err := a.approvals.Consume(ctx, r.ID, now)
+		if err != nil {
+			return err
+		}
+		if ok {
+			r.Status, r.UsedAt = models.ApprovalUsed, now
+			a.emit(ApprovalEventUse, requesterID, r)
+			return nil
+		}
+	}
+	for _, r := range list {
+		if r.Status == models.ApprovalPending && now.Before(r.ExpiresAt) {
+			return &ApprovalRequiredError{ApprovalID: r.ID, ExpiresAt: r.ExpiresAt}
+		}
+	}
+	r := models.CredentialApproval{
+		ID: uuid.NewString(), CredentialID: cred.ID, RequesterID: requesterID, ConnectionID: connectionID,
+		Status: models.ApprovalPending, CreatedAt: now, ExpiresAt: now.Add(a.ttl),
+	}
+	if err := a.approvals.Create(ctx, &r); err != nil {
+		return err
+	}
+	a.emit(ApprovalEventRequest, requesterID, r)
+	return &ApprovalRequiredError{ApprovalID: r.ID, ExpiresAt: r.ExpiresAt}
+}
+
+// canApprove reports whether actor may approve requests for cred: a user who
+// may edit it, never the requester. That is its owner, or a holder of a
+// manage grant on it; admin rights elsewhere do not count.
+func (a *CredentialApprovals) canApprove(ctx context.Context, actor models.User, cred models.Credential, r models.CredentialApproval) (bool, error) {
+	if actor.ID == r.RequesterID {
+		return false, nil
+	}
+	if cred.OwnerID == actor.ID {
+		return true, nil
+	}
+	grants, err := a.grants.ListByCredential(ctx, cred.ID)
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(grants, func(g models.CredentialGrant) bool {
+		return g.SubjectID == actor.ID && g.Access == models.AccessManage
+	}), nil
+}
+
+// approvable returns the IDs of the credentials actor may edit.
+func (a *CredentialApprovals) approvable(ctx context.Context, actorID string) ([]string, error) {
+	owned, err := a.creds.ListByOwner(ctx, actorID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(owned))
+	for _, c := range owned {
+		ids = append(ids, c.ID)
+	}
+	grants, err := a.grants.ListBySubject(ctx, actorID)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range grants {
+		if g.Access == models.AccessManage {
+			ids = append(ids, g.CredentialID)
+		}
+	}
+	return ids, nil
+}
+
+// Approve opens the requester's use window on a pending request.
+func (a *CredentialApprovals) Approve(ctx context.Context, actor models.User, id string) (models.CredentialApproval, error) {
+	ctx, cancel := WithTimeout(ctx, OpWrite)
+	defer cancel()
+	r, err := a.approvals.Get(ctx, id)
+	if err != nil {
+		return models.CredentialApproval{}, timeoutError(ctx, err)
+	}
+	if r.RequesterID == actor.ID {
+		return r, fmt.Errorf("%w: a request cannot be approved by its requester", plugin.ErrForbidden)
+	}
+	cred, err := a.creds.Get(ctx, r.CredentialID)
+	if err != nil {
+		return r, timeoutError(ctx, err)
+	}
+	allowed, err := a.canApprove(ctx, actor, cred, r)
+	if err != nil {
+		return r, timeoutError(ctx, err)
+	}
+	if !allowed {
+		return r, fmt.Errorf("%w: only a user who can edit the credential can approve", plugin.ErrForbidden)
+	}
+	now := a.now()
+	ok, err := a.approvals.Approve(ctx, r.ID, actor.ID, now, now.Add(a.window))
+	if err != nil {
+		return r, timeoutError(ctx, err)
+	}
+	if !ok {
+		return r, fmt.Errorf("%w: request is no longer pending", plugin.ErrConflict)
+	}
+	r.Status, r.ApproverID, r.ApprovedAt, r.UseBy = models.ApprovalApproved, actor.ID, now, now.Add(a.window)
+	a.emit(ApprovalEventApprove, actor.ID, r)
+	return r, nil
+}
+
+// List returns the actor's own requests and the pending ones they may
+// approve, newest first.
+func (a *CredentialApprovals) List(ctx context.Context, actor models.User) ([]models.CredentialApproval, error) {
+	ctx, cancel := WithTimeout(ctx, OpRead)
+	defer cancel()
+	out, err := a.approvals.List(ctx, store.CredentialApprovalFilter{RequesterID: actor.ID})
+	if err != nil {
+		return nil, timeoutError(ctx, err)
+	}
+	ids, err := a.approvable(ctx, actor.ID)
+	if err != nil || len(ids) == 0 {
+		return out, timeoutError(ctx, err)
+	}
+	pending, err := a.approvals.List(ctx, store.CredentialApprovalFilter{CredentialIDs: ids, Status: models.ApprovalPending})
+	if err != nil {
+		return nil, timeoutError(ctx, err)
+	}
+	now := a.now()
+	for _, r := range pending {
+		if r.RequesterID != actor.ID && now.Before(r.ExpiresAt) {
+			out = append(out, r)
+		}
+	}
+	slices.SortFunc(out, func(x, y models.CredentialApproval) int { return y.CreatedAt.Compare(x.CreatedAt) })
+	return out, nil
+}
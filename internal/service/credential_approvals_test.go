@@ -0,0 +1,107 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/secrets"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+func TestCredentialApprovalIsTwoPersonAndSingleUse(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	for _, u := range []models.User{
+		{ID: "root", Username: "root", Roles: []models.Role{models.RoleAdmin}, Protected: true},
+		{ID: "owner", Username: "owner", Roles: []models.Role{models.RoleOperator}},
+		{ID: "op", Username: "op", Roles: []models.Role{models.RoleOperator}},
+		{ID: "editor", Username: "editor", Roles: []models.Role{models.RoleOperator}},
+		{ID: "viewer", Username: "viewer", Roles: []models.Role{models.RoleViewer}},
+	} {
+		if err := st.Users.Create(ctx, &u, "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key, _ := secrets.GenerateMasterKey()
+	vault, _ := secrets.NewVault(key)
+	approvals := service.NewCredentialApprovals(st.CredentialApprovals, st.Credentials, st.CredentialGrants, st.Users, st.SystemSettings)
+	var events []string
+	approvals.OnEvent(func(e service.CredentialApprovalEvent) { events = append(events, e.Event) })
+	svc := service.NewCredentialService(st.Credentials, st.CredentialGrants, vault, service.WithCredentialApprovals(approvals))
+	cred, err := svc.Create(ctx, service.NewCredentialInput{
+		OwnerID: "owner", Name: "domain admin", Kind: "ssh_password", RequiresApproval: true,
+		Values: map[string]string{"username": "administrator", "password": "break-glass"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = st.CredentialGrants.Create(ctx, &models.CredentialGrant{ID: "g1", CredentialID: cred.ID, SubjectID: "op", Access: models.AccessView})
+	_ = st.CredentialGrants.Create(ctx, &models.CredentialGrant{ID: "g0", CredentialID: cred.ID, SubjectID: "editor", Access: models.AccessManage})
+
+	launch := service.WithCredentialAccess(ctx, "op", "conn-1", service.CredentialPurposeSessionLaunch)
+	var pending *service.ApprovalRequiredError
+	if _, _, err := svc.ResolveWithMetadata(launch, "op", cred.ID); !errors.As(err, &pending) {
+		t.Fatalf("first resolve should file a request: %v", err)
+	}
+	var again *service.ApprovalRequiredError
+	if _, _, err := svc.ResolveWithMetadata(launch, "op", cred.ID); !errors.As(err, &again) || again.ApprovalID != pending.ApprovalID {
+		t.Fatalf("a second attempt should point at the same request: %v", err)
+	}
+
+	// Admin rights do not make someone an approver; edit rights on the
+	// credential do.
+	for _, actor := range []models.User{{ID: "op"}, {ID: "viewer"}, {ID: "root", Roles: []models.Role{models.RoleAdmin}}} {
+		if _, err := approvals.Approve(ctx, actor, pending.ApprovalID); !errors.Is(err, plugin.ErrForbidden) {
+			t.Fatalf("%s approving: want ErrForbidden, got %v", actor.ID, err)
+		}
+	}
+	for _, actor := range []string{"owner", "editor"} {
+		if list, err := approvals.List(ctx, models.User{ID: actor}); err != nil || len(list) != 1 || list[0].ConnectionID != "conn-1" {
+			t.Fatalf("%s's pending list: %+v %v", actor, list, err)
+		}
+	}
+	if list, _ := approvals.List(ctx, models.User{ID: "root", Roles: []models.Role{models.RoleAdmin}}); len(list) != 0 {
+		t.Fatalf("admin's pending list: %+v", list)
+	}
+	if _, err := approvals.Approve(ctx, models.User{ID: "owner"}, pending.ApprovalID); err != nil {
+		t.Fatalf("owner approve: %v", err)
+	}
+	if _, err := approvals.Approve(ctx, models.User{ID: "owner"}, pending.ApprovalID); !errors.Is(err, plugin.ErrConflict) {
+		t.Fatalf("approvals are single-use: %v", err)
+	}
+
+	if _, values, err := svc.ResolveWithMetadata(launch, "op", cred.ID); err != nil || values["password"] != "break-glass" {
+		t.Fatalf("approved resolve: %v", err)
+	}
+	if _, _, err := svc.ResolveWithMetadata(launch, "op", cred.ID); !errors.Is(err, service.ErrApprovalRequired) {
+		t.Fatalf("a spent approval must not resolve again: %v", err)
+	}
+	want := []string{service.ApprovalEventRequest, service.ApprovalEventApprove, service.ApprovalEventUse, service.ApprovalEventRequest}
+	if len(events) != len(want) {
+		t.Fatalf("events = %v", events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("events = %v, want %v", events, want)
+		}
+	}
+
+	_ = st.CredentialGrants.Create(ctx, &models.CredentialGrant{ID: "g2", CredentialID: cred.ID, SubjectID: "root", Access: models.AccessView})
+	if _, _, err := svc.ResolveWithMetadata(ctx, "root", cred.ID); !errors.Is(err, service.ErrApprovalRequired) {
+		t.Fatalf("root needs approval unless exempted: %v", err)
+	}
+	if err := approvals.SetExemptRoot(ctx, models.User{ID: "root"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := svc.ResolveWithMetadata(ctx, "root", cred.ID); err != nil {
+		t.Fatalf("exempt root: %v", err)
+	}
+	reloaded := service.NewCredentialApprovals(st.CredentialApprovals, st.Credentials, st.CredentialGrants, st.Users, st.SystemSettings)
+	if err := reloaded.Load(ctx); err != nil || !reloaded.ExemptRoot() {
+		t.Fatalf("persisted exemption: %v %v", reloaded.ExemptRoot(), err)
+	}
+}
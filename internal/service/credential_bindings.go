@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// CredentialBindingStatus classifies a connection's credential reference as
+// its owner would meet it at launch.
+type CredentialBindingStatus string
+
+const (
+	BindingOK                CredentialBindingStatus = "ok"
+	BindingOwnerLostAccess   CredentialBindingStatus = "owner_lost_access"
+	BindingCredentialDeleted CredentialBindingStatus = "credential_deleted"
+	// BindingScopeMismatch is a credential whose kind or protocols no longer
+	// fit the field that references it.
+	BindingScopeMismatch CredentialBindingStatus = "scope_mismatch"
+)
+
+// CredentialBinding is one credential reference of one connection.
+type CredentialBinding struct {
+	ConnectionID   string                  `json:"connectionId"`
+	ConnectionName string                  `json:"connectionName"`
+	Protocol       string                  `json:"protocol"`
+	OwnerID        string                  `json:"ownerId"`
+	Field          string                  `json:"field"`
+	CredentialID   string                  `json:"credentialId"`
+	Status         CredentialBindingStatus `json:"status"`
+	Detail         string                  `json:"detail,omitempty"`
+}
+
+// CredentialBindingFilter narrows a binding report; empty fields match all.
+type CredentialBindingFilter struct {
+	Status   CredentialBindingStatus
+	OwnerID  string
+	Protocol string
+	Limit    int
+	Offset   int
+}
+
+// CredentialBindingReport is one page of bindings matching the filter, with
+// Total matches and the per-status counts across every binding.
+type CredentialBindingReport struct {
+	Items  []CredentialBinding             `json:"items"`
+	Total  int                             `json:"total"`
+	Counts map[CredentialBindingStatus]int `json:"counts"`
+}
+
+// ValidateCredentialBindings re-checks every connection's credential references
+// against the connection owner, the way launch resolves them, without
+// decrypting anything or writing any rows.
+func (s *ConnectionService) ValidateCredentialBindings(ctx context.Context, f CredentialBindingFilter) (CredentialBindingReport, error) {
+	ctx, cancel := WithTimeout(ctx, OpReport)
+	defer cancel()
+	conns, err := s.conns.List(ctx)
+	if err != nil {
+		return CredentialBindingReport{}, timeoutError(ctx, err)
+	}
+	slices.SortFunc(conns, func(a, b models.Connection) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	check := newBindingChecker(s.creds)
+	report := CredentialBindingReport{Items: []CredentialBinding{}, Counts: map[CredentialBindingStatus]int{}}
+	for _, c := range conns {
+		m, ok := s.plugins.Manifest(c.Protocol)
+		if !ok {
+			continue
+		}
+		c, _ = migrateConnection(m, c)
+		config := m.Config.VisibleValues(m.Config.ValuesWithDefaults(c.Config), connectionSchemaContext(c.Protocol, c.Transport))
+		for _, ref := range credentialRefs(m.Config, config) {
+			status, detail, err := check.status(ctx, c, ref)
+			if err != nil {
+				return CredentialBindingReport{}, timeoutError(ctx, err)
+			}
+			report.Counts[status]++
+			if (f.Status != "" && status != f.Status) || (f.OwnerID != "" && c.OwnerID != f.OwnerID) || (f.Protocol != "" && c.Protocol != f.Protocol) {
+				continue
+			}
+			report.Total++
+			if report.Total <= f.Offset || (f.Limit > 0 && len(report.Items) >= f.Limit) {
+				continue
+			}
+			report.Items = append(report.Items, CredentialBinding{
+				ConnectionID: c.ID, ConnectionName: c.Name, Protocol: c.Protocol, OwnerID: c.OwnerID,
+				Field: ref.Binding, CredentialID: ref.ID, Status: status, Detail: detail,
+			})
+		}
+	}
+	return report, nil
+}
+
+// bindingChecker loads each credential and owner grant once per report.
+type bindingChecker struct {
+	creds  *CredentialService
+	loaded map[string]*models.Credential
+	usable map[[2]string]bool
+}
+
+func newBindingChecker(creds *CredentialService) *bindingChecker {
+	return &bindingChecker{creds: creds, loaded: map[string]*models.Credential{}, usable: map[[2]string]bool{}}
+}
+
+func (b *bindingChecker) status(ctx context.Context, conn models.Connection, ref credentialRef) (CredentialBindingStatus, string, error) {
+	cred, ok := b.loaded[ref.ID]
+	if !ok {
+		got, err := b.creds.creds.Get(ctx, ref.ID)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+		case err != nil:
+			return "", "", err
+		default:
+			cred = &got
+		}
+		b.loaded[ref.ID] = cred
+	}
+	if cred == nil {
+		return BindingCredentialDeleted, "credential no longer exists", nil
+	}
+	if sel := ref.Field.Credential; sel != nil && len(sel.Protocols) > 0 && !slices.Contains(sel.Protocols, conn.Protocol) {
+		return BindingScopeMismatch, fmt.Sprintf("credential field is not valid for protocol %q", conn.Protocol), nil
+	}
+	if err := b.creds.checkScope(*cred, credentialSelectorKinds(ref.Field.Credential), conn.Protocol); err != nil {
+		return BindingScopeMismatch, strings.TrimPrefix(err.Error(), plugin.ErrInvalidInput.Error()+": "), nil
+	}
+	key := [2]string{cred.ID, conn.OwnerID}
+	usable, ok := b.usable[key]
+	if !ok {
+		var err error
+		if usable, err = b.creds.canUse(ctx, conn.OwnerID, *cred); err != nil {
+			return "", "", err
+		}
+		b.usable[key] = usable
+	}
+	if !usable {
+		return BindingOwnerLostAccess, "connection owner can no longer use this credential", nil
+	}
+	return BindingOK, "", nil
+}
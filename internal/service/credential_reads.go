@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// SettingCredentialReadQuota holds the CredentialReadQuota as JSON.
+const SettingCredentialReadQuota = "credentials.read_quota"
+
+// ErrCredentialReadsBlocked is returned while a user is locked out of secret
+// reads after tripping the read quota.
+var ErrCredentialReadsBlocked = errors.New("credential reads temporarily blocked")
+
+// CredentialReadBlockedError carries when a read lockout ends.
+type CredentialReadBlockedError struct {
+	Until time.Time
+}
+
+func (e *CredentialReadBlockedError) Error() string {
+	return fmt.Sprintf("%s: too many credential reads; try again after %s",
+		ErrCredentialReadsBlocked, e.Until.UTC().Format(time.RFC3339))
+}
+
+func (e *CredentialReadBlockedError) Unwrap() error { return ErrCredentialReadsBlocked }
+
+// CredentialReadQuota bounds secret reads in a sliding window. A zero limit
+// turns that check off; a zero block alerts without locking anyone out.
+type CredentialReadQuota struct {
+	UserLimit       int `json:"userLimit"`
+	CredentialLimit int `json:"credentialLimit"`
+	WindowSeconds   int `json:"windowSeconds"`
+	BlockSeconds    int `json:"blockSeconds"`
+}
+
+// DefaultCredentialReadQuota alerts on more than 20 reads by one user, or 50
+// of one credential, in five minutes.
+var DefaultCredentialReadQuota = CredentialReadQuota{UserLimit: 20, CredentialLimit: 50, WindowSeconds: 300}
+
+func (q CredentialReadQuota) validate() error {
+	if q.UserLimit < 0 || q.CredentialLimit < 0 || q.BlockSeconds < 0 {
+		return fmt.Errorf("%w: limits and block must not be negative", plugin.ErrInvalidInput)
+	}
+	if q.WindowSeconds < 1 || q.WindowSeconds > 86400 {
+		return fmt.Errorf("%w: windowSeconds must be between 1 and 86400", plugin.ErrInvalidInput)
+	}
+	return nil
+}
+
+// Credential read alert scopes.
+const (
+	CredentialReadScopeUser       = "user"
+	CredentialReadScopeCredential = "credential"
+)
+
+// CredentialReadAlert describes one quota breach.
+type CredentialReadAlert struct {
+	Scope        string
+	UserID       string
+	CredentialID string
+	Count        int
+	Limit        int
+	Window       time.Duration
+	// BlockedUntil is set when the breach locked UserID out.
+	BlockedUntil time.Time
+}
+
+// CredentialReadGuard counts secret reads per user and per credential and
+// raises an alert when a window goes over its limit. Counts live in memory,
+// so each instance enforces the quota on the reads it serves.
+type CredentialReadGuard struct {
+	settings store.SystemSettingStore
+	now      func() time.Time
+
+	mu       sync.Mutex
+	quota    CredentialReadQuota
+	reads    map[string][]time.Time
+	alerted  map[string]time.Time
+	blocked  map[string]time.Time
+	watchers []func(CredentialReadAlert)
+}
+
+func NewCredentialReadGuard(settings store.SystemSettingStore) *CredentialReadGuard {
+	return &CredentialReadGuard{
+		settings: settings, now: time.Now, quota: DefaultCredentialReadQuota,
+		reads: map[string][]time.Time{}, alerted: map[string]time.Time{}, blocked: map[string]time.Time{},
+	}
+}
+
+// Load restores the persisted quota; an unset quota keeps the defaults.
+func (g *CredentialReadGuard) Load(ctx context.Context) error {
+	v, err := g.settings.Get(ctx, SettingCredentialReadQuota)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var q CredentialReadQuota
+	if err := json.Unmarshal([]byte(v.Value), &q); err != nil {
+		return fmt.Errorf("decode %s: %w", SettingCredentialReadQuota, err)
+	}
+	if err := q.validate(); err != nil {
+		return err
+	}
+	g.mu.Lock()
+	g.quota = q
+	g.mu.Unlock()
+	return nil
+}
+
+func (g *CredentialReadGuard) Quota() CredentialReadQuota {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.quota
+}
+
+// SetQuota validates, persists and applies q.
+func (g *CredentialReadGuard) SetQuota(ctx context.Context, actor models.User, q CredentialReadQuota) error {
+	if err := q.validate(); err != nil {
+		return err
+	}
+	raw, _ := json.Marshal(q)
+	if err := g.settings.Set(ctx, &models.SystemSetting{
+		Key: SettingCredentialReadQuota, Value: string(raw), UpdatedBy: actor.ID, UpdatedAt: g.now(),
+	}); err != nil {
+		return err
+	}
+	g.mu.Lock()
+	g.quota = q
+	g.mu.Unlock()
+	return nil
+}
+
+// OnAlert registers fn to run for every breach. Watchers run on the reading
+// goroutine and must not block.
+func (g *CredentialReadGuard) OnAlert(fn func(CredentialReadAlert)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.watchers = append(g.watchers, fn)
+}
+
+// Check refuses a read while userID is locked out.
+func (g *CredentialReadGuard) Check(userID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	until, ok := g.blocked[userID]
+	if !ok {
+		return nil
+	}
+	if !g.now().Before(until) {
+		delete(g.blocked, userID)
+		return nil
+	}
+	return &CredentialReadBlockedError{Until: until}
+}
+
+// Record counts one successful read of credentialID by userID. Each window
+// over its limit alerts once until it drops back under.
+func (g *CredentialReadGuard) Record(userID, credentialID string) {
+	now := g.now()
+	g.mu.Lock()
+	q := g.quota
+	window := time.Duration(q.WindowSeconds) * time.Second
+	var alerts []CredentialReadAlert
+	for _, c := range []struct {
+		scope, key string
+		limit      int
+	}{
+		{CredentialReadScopeUser, "u:" + userID, q.UserLimit},
+		{CredentialReadScopeCredential, "c:" + credentialID, q.CredentialLimit},
+	} {
+		if c.limit == 0 {
+			continue
+		}
+		count := g.count(c.key, now, window)
+		if count <= c.limit {
+			delete(g.alerted, c.key)
+			continue
+		}
+		if _, done := g.alerted[c.key]; done {
+			continue
+		}
+		g.alerted[c.key] = now
+		a := CredentialReadAlert{
+			Scope: c.scope, UserID: userID, CredentialID: credentialID,
+			Count: count, Limit: c.limit, Window: window,
+		}
+		if q.BlockSeconds > 0 {
+			a.BlockedUntil = now.Add(time.Duration(q.BlockSeconds) * time.Second)
+			g.blocked[userID] = a.BlockedUntil
+		}
+		alerts = append(alerts, a)
+	}
+	watchers := slices.Clone(g.watchers)
+	g.mu.Unlock()
+	for _, a := range alerts {
+		for _, fn := range watchers {
+			fn(a)
+		}
+	}
+}
+
+// count appends a read at now to key and returns the reads inside window
+// (caller holds g.mu).
+func (g *CredentialReadGuard) count(key string, now time.Time, window time.Duration) int {
+	cutoff := now.Add(-window)
+	kept := g.reads[key][:0]
+	for _, t := range g.reads[key] {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	kept = append(kept, now)
+	g.reads[key] = kept
+	return len(kept)
+}
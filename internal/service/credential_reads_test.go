@@ -0,0 +1,58 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+func TestCredentialReadGuard(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	g := service.NewCredentialReadGuard(st.SystemSettings)
+	var alerts []service.CredentialReadAlert
+	g.OnAlert(func(a service.CredentialReadAlert) { alerts = append(alerts, a) })
+
+	if err := g.SetQuota(ctx, models.User{ID: "root"}, service.CredentialReadQuota{UserLimit: -1, WindowSeconds: 60}); !errors.Is(err, plugin.ErrInvalidInput) {
+		t.Fatalf("negative limit: %v", err)
+	}
+	if err := g.SetQuota(ctx, models.User{ID: "root"}, service.CredentialReadQuota{UserLimit: 3, CredentialLimit: 4, WindowSeconds: 300}); err != nil {
+		t.Fatal(err)
+	}
+	for range 6 {
+		if err := g.Check("u1"); err != nil {
+			t.Fatalf("alert-only quota blocked a read: %v", err)
+		}
+		g.Record("u1", "cred-1")
+	}
+	if len(alerts) != 2 || alerts[0].Scope != service.CredentialReadScopeUser || alerts[0].Count != 4 ||
+		alerts[1].Scope != service.CredentialReadScopeCredential || alerts[1].Count != 5 {
+		t.Fatalf("each breached window should alert once: %+v", alerts)
+	}
+
+	if err := g.SetQuota(ctx, models.User{ID: "root"}, service.CredentialReadQuota{UserLimit: 1, WindowSeconds: 300, BlockSeconds: 600}); err != nil {
+		t.Fatal(err)
+	}
+	g.Record("u2", "cred-2")
+	g.Record("u2", "cred-2")
+	var blocked *service.CredentialReadBlockedError
+	if err := g.Check("u2"); !errors.As(err, &blocked) || !errors.Is(err, service.ErrCredentialReadsBlocked) {
+		t.Fatalf("over-quota user should be blocked: %v", err)
+	}
+	if last := alerts[len(alerts)-1]; last.UserID != "u2" || !last.BlockedUntil.Equal(blocked.Until) {
+		t.Fatalf("block alert: %+v", last)
+	}
+	if err := g.Check("u3"); err != nil {
+		t.Fatalf("other users stay unblocked: %v", err)
+	}
+
+	reloaded := service.NewCredentialReadGuard(st.SystemSettings)
+	if err := reloaded.Load(ctx); err != nil || reloaded.Quota().BlockSeconds != 600 {
+		t.Fatalf("persisted quota: %+v %v", reloaded.Quota(), err)
+	}
+}
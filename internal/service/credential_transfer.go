@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// OwnedCredentialsError blocks deleting a user who still owns credentials
+// until the caller names a new owner or confirms leaving them orphaned.
+type OwnedCredentialsError struct {
+	Count int
+}
+
+func (e *OwnedCredentialsError) Error() string {
+	return fmt.Sprintf("%s: user owns %d credential(s); transfer them or confirm orphaning", plugin.ErrConflict, e.Count)
+}
+
+func (e *OwnedCredentialsError) Unwrap() error { return plugin.ErrConflict }
+
+// TransferOwnership hands a credential to newOwnerID. Only the current owner
+// or the root admin may do so. Grants the previous owner issued stay valid.
+func (s *CredentialService) TransferOwnership(ctx context.Context, viewer models.User, credentialID, newOwnerID string) (models.Credential, error) {
+	cred, err := s.creds.Get(ctx, credentialID)
+	if errors.Is(err, store.ErrNotFound) {
+		return models.Credential{}, plugin.ErrNotFound
+	}
+	if err != nil {
+		return models.Credential{}, err
+	}
+	if cred.OwnerID != viewer.ID && !viewer.Protected {
+		return models.Credential{}, fmt.Errorf("%w: only the owner or the root admin can transfer a credential", plugin.ErrForbidden)
+	}
+	if newOwnerID == "" {
+		return models.Credential{}, fmt.Errorf("%w: new owner is required", plugin.ErrInvalidInput)
+	}
+	if newOwnerID == cred.OwnerID {
+		return cred, nil
+	}
+	now := time.Now()
+	if err := s.creds.SetOwner(ctx, cred.ID, newOwnerID, now); err != nil {
+		return models.Credential{}, err
+	}
+	cred.OwnerID, cred.UpdatedAt = newOwnerID, now
+	return cred, nil
+}
+
+// TransferAllOwnership moves every credential owned by fromUserID to
+// toUserID and returns how many moved.
+func (s *CredentialService) TransferAllOwnership(ctx context.Context, fromUserID, toUserID string) (int, error) {
+	if fromUserID == "" || toUserID == "" {
+		return 0, fmt.Errorf("%w: both the current and the new owner are required", plugin.ErrInvalidInput)
+	}
+	if fromUserID == toUserID {
+		return 0, fmt.Errorf("%w: the new owner must differ from the current owner", plugin.ErrInvalidInput)
+	}
+	return moveCredentials(ctx, s.creds, fromUserID, toUserID)
+}
+
+// moveCredentials stops at the first failure; credentials already moved stay
+// with the new owner.
+func moveCredentials(ctx context.Context, creds store.CredentialStore, from, to string) (int, error) {
+	list, err := creds.ListByOwner(ctx, from)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	for i, c := range list {
+		if err := creds.SetOwner(ctx, c.ID, to, now); err != nil {
+			return i, err
+		}
+	}
+	return len(list), nil
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// Field changes reported by DiffVersions.
+const (
+	CredentialFieldAdded     = "added"
+	CredentialFieldRemoved   = "removed"
+	CredentialFieldChanged   = "changed"
+	CredentialFieldUnchanged = "unchanged"
+)
+
+const credentialHashPrefixLen = 8
+
+// CredentialVersionInfo describes one stored version without its values.
+type CredentialVersionInfo struct {
+	Version   int       `json:"version"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// CredentialDiff is a value-free comparison of two credential versions.
+type CredentialDiff struct {
+	CredentialID string                `json:"credentialId"`
+	From         int                   `json:"from"`
+	To           int                   `json:"to"`
+	Fields       []CredentialFieldDiff `json:"fields"`
+}
+
+// CredentialFieldDiff reports how one field moved between versions. The hashes
+// are keyed per diff: they show whether two values match within one response
+// and cannot be compared across responses or brute-forced offline.
+type CredentialFieldDiff struct {
+	Key      string `json:"key"`
+	Change   string `json:"change"`
+	Changed  bool   `json:"changed"`
+	FromHash string `json:"fromHash,omitempty"`
+	ToHash   string `json:"toHash,omitempty"`
+}
+
+// Versions lists a credential's stored versions, oldest first.
+func (s *CredentialService) Versions(ctx context.Context, credentialID string) ([]CredentialVersionInfo, error) {
+	if s.versions == nil {
+		return []CredentialVersionInfo{}, nil
+	}
+	ctx, cancel := WithTimeout(ctx, OpRead)
+	defer cancel()
+	list, err := s.versions.List(ctx, credentialID)
+	if err != nil {
+		return nil, timeoutError(ctx, err)
+	}
+	out := make([]CredentialVersionInfo, 0, len(list))
+	for _, v := range list {
+		out = append(out, CredentialVersionInfo{Version: v.Version, CreatedBy: v.CreatedBy, CreatedAt: v.CreatedAt})
+	}
+	return out, nil
+}
+
+// DiffVersions compares two versions of a credential field by field. Values
+// are decrypted only to compare them and never leave this method.
+func (s *CredentialService) DiffVersions(ctx context.Context, credentialID string, from, to int) (CredentialDiff, error) {
+	if s.versions == nil {
+		return CredentialDiff{}, plugin.ErrNotFound
+	}
+	ctx, cancel := WithTimeout(ctx, OpRead)
+	defer cancel()
+	list, err := s.versions.List(ctx, credentialID)
+	if err != nil {
+		return CredentialDiff{}, timeoutError(ctx, err)
+	}
+	fromValues, err := s.versionValues(ctx, list, from)
+	if err != nil {
+		return CredentialDiff{}, err
+	}
+	toValues, err := s.versionValues(ctx, list, to)
+	if err != nil {
+		return CredentialDiff{}, err
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return CredentialDiff{}, err
+	}
+	hash := func(v string) string {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(v))
+		return hex.EncodeToString(mac.Sum(nil))[:credentialHashPrefixLen]
+	}
+
+	keys := slices.Sorted(maps.Keys(fromValues))
+	for k := range toValues {
+		if _, ok := fromValues[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	diff := CredentialDiff{CredentialID: credentialID, From: from, To: to, Fields: make([]CredentialFieldDiff, 0, len(keys))}
+	for _, k := range keys {
+		before, hadBefore := fromValues[k]
+		after, hasAfter := toValues[k]
+		field := CredentialFieldDiff{Key: k}
+		switch {
+		case !hadBefore:
+			field.Change, field.ToHash = CredentialFieldAdded, hash(after)
+		case !hasAfter:
+			field.Change, field.FromHash = CredentialFieldRemoved, hash(before)
+		case before != after:
+			field.Change, field.FromHash, field.ToHash = CredentialFieldChanged, hash(before), hash(after)
+		default:
+			field.Change = CredentialFieldUnchanged
+			field.FromHash = hash(before)
+			field.ToHash = field.FromHash
+		}
+		field.Changed = field.Change != CredentialFieldUnchanged
+		diff.Fields = append(diff.Fields, field)
+	}
+	return diff, nil
+}
+
+// versionValues merges a version's public and decrypted secret values.
+func (s *CredentialService) versionValues(ctx context.Context, list []models.CredentialVersion, version int) (map[string]string, error) {
+	for _, v := range list {
+		if v.Version != version {
+			continue
+		}
+		values, err := s.decryptSecretValues(ctx, v.EncryptedValues)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(values, v.Values)
+		return values, nil
+	}
+	return nil, fmt.Errorf("%w: credential version %d", plugin.ErrNotFound, version)
+}
+
+// nextVersion returns the number the next update gets. A credential created
+// before versioning first has its current state saved as version 1.
+func (s *CredentialService) nextVersion(ctx context.Context, cred models.Credential) (int, error) {
+	if s.versions == nil {
+		return 0, nil
+	}
+	list, err := s.versions.List(ctx, cred.ID)
+	if err != nil {
+		return 0, err
+	}
+	if len(list) == 0 {
+		if err := s.recordVersion(ctx, cred, 1, ""); err != nil {
+			return 0, err
+		}
+		return 2, nil
+	}
+	return list[len(list)-1].Version + 1, nil
+}
+
+func (s *CredentialService) recordVersion(ctx context.Context, cred models.Credential, version int, actorID string) error {
+	if s.versions == nil {
+		return nil
+	}
+	return s.versions.Create(ctx, &models.CredentialVersion{
+		ID: uuid.NewString(), CredentialID: cred.ID, Version: version,
+		Values: maps.Clone(cred.Values), EncryptedValues: cred.EncryptedValues,
+		CreatedBy: actorID, CreatedAt: cred.UpdatedAt,
+	})
+}
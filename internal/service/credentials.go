@@ -3,9 +3,11 @@ package service
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"log/slog"
 	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,6 +26,13 @@ type CredentialService struct {
 	grants         store.CredentialGrantStore
 	vault          secrets.SecretStore
 	kinds          plugin.CredentialKindCatalog
+	accessLog      store.CredentialAccessLogStore
+	accessWriter   *accessLogWriter
+	logger         *slog.Logger
+	versions       store.CredentialVersionStore
+	usage          store.CredentialUsageStore
+	reads          *CredentialReadGuard
+	approvals      *CredentialApprovals
 	onSecretAccess func()
 }
 
@@ -39,19 +48,96 @@ func WithCredentialKindCatalog(kinds plugin.CredentialKindCatalog) CredentialSer
 	}
 }
 
+// WithCredentialAccessLog records every secret resolution in the per-credential
+// access log. Rows are written in the background; call Close to write the
+// rows still queued.
+func WithCredentialAccessLog(log store.CredentialAccessLogStore) CredentialServiceOption {
+	return func(s *CredentialService) {
+		s.accessLog = log
+	}
+}
+
+// WithCredentialLogger sets the logger for dropped and failed access-log
+// writes.
+func WithCredentialLogger(l *slog.Logger) CredentialServiceOption {
+	return func(s *CredentialService) { s.logger = l }
+}
+
+// WithCredentialVersions keeps a snapshot of the values on every create and
+// update so changes can be reviewed later.
+func WithCredentialVersions(versions store.CredentialVersionStore) CredentialServiceOption {
+	return func(s *CredentialService) {
+		s.versions = versions
+	}
+}
+
+// WithCredentialUsage tracks per-user favorites and last use for the picker.
+func WithCredentialUsage(usage store.CredentialUsageStore) CredentialServiceOption {
+	return func(s *CredentialService) {
+		s.usage = usage
+	}
+}
+
+// WithCredentialReadGuard rate-accounts secret reads by the acting user.
+func WithCredentialReadGuard(g *CredentialReadGuard) CredentialServiceOption {
+	return func(s *CredentialService) {
+		s.reads = g
+	}
+}
+
+// WithCredentialApprovals holds secret reads of approval-gated credentials
+// until a second person approves them.
+func WithCredentialApprovals(a *CredentialApprovals) CredentialServiceOption {
+	return func(s *CredentialService) {
+		s.approvals = a
+	}
+}
+
+// Credential access purposes recorded in the access log.
+const (
+	CredentialPurposeSessionLaunch = "session.launch"
+	CredentialPurposeResolve       = "resolve"
+)
+
+type credentialAccessKey struct{}
+
+type credentialAccess struct {
+	userID       string
+	connectionID string
+	purpose      string
+}
+
+// WithCredentialAccess tags secret resolutions made during ctx with the acting
+// user, connection, and purpose. Resolutions go through the connection owner,
+// so without it the log could not name who actually triggered the access.
+func WithCredentialAccess(ctx context.Context, userID, connectionID, purpose string) context.Context {
+	return context.WithValue(ctx, credentialAccessKey{}, credentialAccess{userID: userID, connectionID: connectionID, purpose: purpose})
+}
+
 func NewCredentialService(creds store.CredentialStore, grants store.CredentialGrantStore, vault secrets.SecretStore, opts ...CredentialServiceOption) *CredentialService {
 	svc := &CredentialService{
 		creds:  creds,
 		grants: grants,
 		vault:  vault,
 		kinds:  plugin.MustCredentialKindSet(plugin.BuiltInCredentialKinds()),
+		logger: slog.Default(),
 	}
 	for _, opt := range opts {
 		opt(svc)
 	}
+	if svc.accessLog != nil {
+		svc.accessWriter = newAccessLogWriter(svc.accessLog, svc.logger)
+	}
 	return svc
 }
 
+// Close writes the access-log rows still queued and stops the writer.
+func (s *CredentialService) Close() {
+	if s.accessWriter != nil {
+		s.accessWriter.close()
+	}
+}
+
 // SetSecretAccessHook registers a callback for successful secret decryptions.
 func (s *CredentialService) SetSecretAccessHook(fn func()) {
 	s.onSecretAccess = fn
@@ -59,14 +145,22 @@ func (s *CredentialService) SetSecretAccessHook(fn func()) {
 
 // NewCredentialInput describes a credential to create.
 type NewCredentialInput struct {
-	OwnerID string
-	Name    string
-	Kind    string
-	Values  map[string]string
+	OwnerID          string
+	Name             string
+	Kind             string
+	Values           map[string]string
+	RequiresApproval bool
 }
 
 // Create encrypts the secret material and persists the credential.
 func (s *CredentialService) Create(ctx context.Context, in NewCredentialInput) (models.Credential, error) {
+	ctx, cancel := WithTimeout(ctx, OpWrite)
+	defer cancel()
+	cred, err := s.create(ctx, in)
+	return cred, timeoutError(ctx, err)
+}
+
+func (s *CredentialService) create(ctx context.Context, in NewCredentialInput) (models.Credential, error) {
 	normalized, err := s.normalizeCredentialInput(in.Name, in.Kind, in.Values, nil)
 	if err != nil {
 		return models.Credential{}, err
@@ -77,35 +171,53 @@ func (s *CredentialService) Create(ctx context.Context, in NewCredentialInput) (
 	}
 	now := time.Now()
 	cred := models.Credential{
-		ID:              uuid.NewString(),
-		Name:            normalized.name,
-		Kind:            normalized.kind,
-		OwnerID:         in.OwnerID,
-		Values:          normalized.publicValues,
-		Protocols:       normalized.protocols,
-		EncryptedValues: enc,
-		CreatedAt:       now,
-		UpdatedAt:       now,
+		ID:               uuid.NewString(),
+		Name:             normalized.name,
+		Kind:             normalized.kind,
+		OwnerID:          in.OwnerID,
+		Values:           normalized.publicValues,
+		Protocols:        normalized.protocols,
+		EncryptedValues:  enc,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		RequiresApproval: in.RequiresApproval,
 	}
 	if err := s.creds.Create(ctx, &cred); err != nil {
 		return models.Credential{}, err
 	}
+	if err := s.recordVersion(ctx, cred, 1, in.OwnerID); err != nil {
+		return models.Credential{}, err
+	}
 	return cred, nil
 }
 
 // UpdateCredentialInput updates metadata and optionally rotates the secret.
 type UpdateCredentialInput struct {
-	Name   string
-	Kind   string
-	Values map[string]string
+	Name    string
+	Kind    string
+	Values  map[string]string
+	ActorID string
+	// RequiresApproval, when set, turns two-person approval on or off.
+	RequiresApproval *bool
 }
 
 // Update applies metadata changes and rotates the encrypted material when set.
 func (s *CredentialService) Update(ctx context.Context, id string, in UpdateCredentialInput) (models.Credential, error) {
+	ctx, cancel := WithTimeout(ctx, OpWrite)
+	defer cancel()
+	cred, err := s.update(ctx, id, in)
+	return cred, timeoutError(ctx, err)
+}
+
+func (s *CredentialService) update(ctx context.Context, id string, in UpdateCredentialInput) (models.Credential, error) {
 	cred, err := s.creds.Get(ctx, id)
 	if err != nil {
 		return models.Credential{}, err
 	}
+	next, err := s.nextVersion(ctx, cred)
+	if err != nil {
+		return models.Credential{}, err
+	}
 	existingSecrets, err := s.decryptSecretValues(ctx, cred.EncryptedValues)
 	if err != nil {
 		return models.Credential{}, err
@@ -123,10 +235,16 @@ func (s *CredentialService) Update(ctx context.Context, id string, in UpdateCred
 		return models.Credential{}, err
 	}
 	cred.EncryptedValues = enc
+	if in.RequiresApproval != nil {
+		cred.RequiresApproval = *in.RequiresApproval
+	}
 	cred.UpdatedAt = time.Now()
 	if err := s.creds.Update(ctx, &cred); err != nil {
 		return models.Credential{}, err
 	}
+	if err := s.recordVersion(ctx, cred, next, in.ActorID); err != nil {
+		return models.Credential{}, err
+	}
 	return cred, nil
 }
 
@@ -138,6 +256,23 @@ type normalizedCredentialInput struct {
 	protocols    []string
 }
 
+// credentialFieldValue types a submitted string the way the field's schema
+// expects it, so the shared schema validators apply. Unparseable input is left
+// as a string and fails the type check.
+func credentialFieldValue(field plugin.Field, value string) any {
+	switch field.Type {
+	case plugin.FieldNumber, plugin.FieldStepper, plugin.FieldSlider:
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	case plugin.FieldToggle:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
 func (s *CredentialService) normalizeCredentialInput(name, kind string, values map[string]string, existingSecrets map[string]string) (normalizedCredentialInput, error) {
 	out := normalizedCredentialInput{
 		name:         strings.TrimSpace(name),
@@ -159,36 +294,59 @@ func (s *CredentialService) normalizeCredentialInput(name, kind string, values m
 	for _, field := range info.Fields {
 		fields[field.Key] = field
 	}
+	verr := &ValidationError{}
+	var unknown []string
 	for key, value := range values {
 		if _, ok := fields[key]; !ok && strings.TrimSpace(value) != "" {
-			return normalizedCredentialInput{}, fmt.Errorf("%w: credential kind %q does not declare field %q", plugin.ErrInvalidInput, out.kind, key)
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		if info.AllowUnknownFields {
+			out.secretValues[key] = strings.TrimSpace(values[key])
+			continue
 		}
+		verr.add(key, fmt.Sprintf("credential kind %q does not declare field %q", out.kind, key))
 	}
 	for _, field := range info.Fields {
 		value := strings.TrimSpace(values[field.Key])
-		if field.Secret {
-			if value == "" && existingSecrets != nil {
-				value = existingSecrets[field.Key]
-			}
-			if field.Required && value == "" {
-				return normalizedCredentialInput{}, fmt.Errorf("%w: credential field %q is required", plugin.ErrInvalidInput, field.Key)
-			}
-			if value != "" {
-				out.secretValues[field.Key] = value
+		if field.Secret && value == "" && existingSecrets != nil {
+			value = existingSecrets[field.Key]
+		}
+		if value == "" {
+			if field.Required {
+				verr.add(field.Key, fmt.Sprintf("credential field %q is required", field.Key))
 			}
 			continue
 		}
-		if field.Required && value == "" {
-			return normalizedCredentialInput{}, fmt.Errorf("%w: credential field %q is required", plugin.ErrInvalidInput, field.Key)
+		if err := field.ValidateValue(credentialFieldValue(field, value)); err != nil {
+			verr.add(field.Key, strings.TrimPrefix(err.Error(), plugin.ErrInvalidInput.Error()+": "))
+			continue
 		}
-		if value != "" && field.Public {
+		if field.Secret {
+			out.secretValues[field.Key] = value
+		} else if field.Public {
 			out.publicValues[field.Key] = value
 		}
 	}
+	if err := verr.err(); err != nil {
+		return normalizedCredentialInput{}, err
+	}
 	out.protocols = append(out.protocols, info.CompatibleProtocols...)
 	return out, nil
 }
 
+// KindWarnings returns non-fatal notices about saving a credential of kind,
+// such as the kind being deprecated.
+func (s *CredentialService) KindWarnings(kind string) []string {
+	info, ok := s.kinds.CredentialKindLookup(plugin.CredentialKind(kind))
+	if !ok || !info.Deprecated(time.Now()) {
+		return nil
+	}
+	return []string{fmt.Sprintf("credential kind %q is deprecated since %s", kind, info.DeprecatedAfter.Format(time.DateOnly))}
+}
+
 func (s *CredentialService) encryptSecretValues(ctx context.Context, values map[string]string) ([]byte, error) {
 	raw, err := json.Marshal(values)
 	if err != nil {
@@ -218,7 +376,20 @@ func (s *CredentialService) decryptSecretValues(ctx context.Context, enc []byte)
 
 // Delete removes a credential after callers enforce reference checks.
 func (s *CredentialService) Delete(ctx context.Context, id string) error {
-	return s.creds.Delete(ctx, id)
+	ctx, cancel := WithTimeout(ctx, OpWrite)
+	defer cancel()
+	if err := s.creds.Delete(ctx, id); err != nil {
+		return timeoutError(ctx, err)
+	}
+	if s.usage != nil {
+		if err := s.usage.DeleteByCredential(ctx, id); err != nil {
+			return timeoutError(ctx, err)
+		}
+	}
+	if s.versions != nil {
+		return timeoutError(ctx, s.versions.DeleteByCredential(ctx, id))
+	}
+	return nil
 }
 
 // canUse reports whether userID owns the credential or holds a view-grant.
@@ -231,11 +402,13 @@ func (s *CredentialService) canUse(ctx context.Context, userID string, cred mode
 
 // EnsureUsable verifies owner/use access to a credential.
 func (s *CredentialService) EnsureUsable(ctx context.Context, userID, credentialID string) error {
+	ctx, cancel := WithTimeout(ctx, OpRead)
+	defer cancel()
 	cred, err := s.creds.Get(ctx, credentialID)
 	if err != nil {
-		return err
+		return timeoutError(ctx, err)
 	}
-	return s.ensureUsableCredential(ctx, userID, cred)
+	return timeoutError(ctx, s.ensureUsableCredential(ctx, userID, cred))
 }
 
 // SummaryIfUsable returns a non-secret summary only when userID has use access.
@@ -255,20 +428,30 @@ func (s *CredentialService) SummaryIfUsable(ctx context.Context, userID, credent
 // EnsureUsableFor verifies that userID may use credentialID and that the
 // credential matches the selector constraints for the connection protocol.
 func (s *CredentialService) EnsureUsableFor(ctx context.Context, userID, credentialID string, kinds []string, protocol string) error {
+	ctx, cancel := WithTimeout(ctx, OpRead)
+	defer cancel()
 	cred, err := s.creds.Get(ctx, credentialID)
 	if err != nil {
+		return timeoutError(ctx, err)
+	}
+	if err := s.checkScope(cred, kinds, protocol); err != nil {
 		return err
 	}
+	return timeoutError(ctx, s.ensureUsableCredential(ctx, userID, cred))
+}
+
+// checkScope verifies cred matches a credential field's kinds and protocol.
+func (s *CredentialService) checkScope(cred models.Credential, kinds []string, protocol string) error {
 	if len(kinds) > 0 && !slices.Contains(kinds, cred.Kind) {
-		return fmt.Errorf("%w: credential %q is kind %q", plugin.ErrInvalidInput, credentialID, cred.Kind)
+		return fmt.Errorf("%w: credential %q is kind %q", plugin.ErrInvalidInput, cred.ID, cred.Kind)
 	}
 	if protocol != "" && !s.kinds.CredentialKindSupportsProtocol(plugin.CredentialKind(cred.Kind), protocol) {
 		return fmt.Errorf("%w: credential kind %q is not compatible with protocol %q", plugin.ErrInvalidInput, cred.Kind, protocol)
 	}
 	if protocol != "" && len(cred.Protocols) > 0 && !slices.Contains(cred.Protocols, protocol) {
-		return fmt.Errorf("%w: credential %q is not valid for protocol %q", plugin.ErrInvalidInput, credentialID, protocol)
+		return fmt.Errorf("%w: credential %q is not valid for protocol %q", plugin.ErrInvalidInput, cred.ID, protocol)
 	}
-	return s.ensureUsableCredential(ctx, userID, cred)
+	return nil
 }
 
 func (s *CredentialService) ensureUsableCredential(ctx context.Context, userID string, cred models.Credential) error {
@@ -284,6 +467,13 @@ func (s *CredentialService) ensureUsableCredential(ctx context.Context, userID s
 
 // ResolveWithMetadata returns metadata plus decrypted material after use access.
 func (s *CredentialService) ResolveWithMetadata(ctx context.Context, userID, credentialID string) (models.Credential, map[string]string, error) {
+	ctx, cancel := WithTimeout(ctx, OpRead)
+	defer cancel()
+	cred, values, err := s.resolveWithMetadata(ctx, userID, credentialID)
+	return cred, values, timeoutError(ctx, err)
+}
+
+func (s *CredentialService) resolveWithMetadata(ctx context.Context, userID, credentialID string) (models.Credential, map[string]string, error) {
 	cred, err := s.creds.Get(ctx, credentialID)
 	if err != nil {
 		return models.Credential{}, nil, err
@@ -293,10 +483,26 @@ func (s *CredentialService) ResolveWithMetadata(ctx context.Context, userID, cre
 		return models.Credential{}, nil, err
 	}
 	if !ok {
+		s.logAccess(ctx, userID, credentialID, models.AuditDenied)
 		return models.Credential{}, nil, fmt.Errorf("credential %q: %w", credentialID, models.ErrForbidden)
 	}
+	reader := actingUser(ctx, userID)
+	if s.reads != nil {
+		if err := s.reads.Check(reader); err != nil {
+			s.logAccess(ctx, userID, credentialID, models.AuditDenied)
+			return models.Credential{}, nil, err
+		}
+	}
+	if s.approvals != nil {
+		access, _ := ctx.Value(credentialAccessKey{}).(credentialAccess)
+		if err := s.approvals.authorize(ctx, cred, reader, access.connectionID); err != nil {
+			s.logAccess(ctx, userID, credentialID, models.AuditDenied)
+			return models.Credential{}, nil, err
+		}
+	}
 	secrets, err := s.decryptSecretValues(ctx, cred.EncryptedValues)
 	if err != nil {
+		s.logAccess(ctx, userID, credentialID, models.AuditError)
 		return models.Credential{}, nil, err
 	}
 	values := make(map[string]string, len(cred.Values)+len(secrets))
@@ -306,59 +512,176 @@ func (s *CredentialService) ResolveWithMetadata(ctx context.Context, userID, cre
 	for k, v := range secrets {
 		values[k] = v
 	}
+	s.logAccess(ctx, userID, credentialID, models.AuditAllowed)
+	if s.usage != nil {
+		_ = s.usage.Touch(context.WithoutCancel(ctx), reader, credentialID, time.Now())
+	}
+	if s.reads != nil {
+		s.reads.Record(reader, credentialID)
+	}
 	if s.onSecretAccess != nil {
 		s.onSecretAccess()
 	}
 	return cred, values, nil
 }
 
-// ListUsable returns the non-secret summaries the user may select for a
-// credential_ref field, filtered by accepted kinds and an optional protocol.
-func (s *CredentialService) ListUsable(ctx context.Context, userID string, kinds []string, protocol string) ([]models.CredentialSummary, error) {
-	seen := map[string]bool{}
-	var out []models.CredentialSummary
+// actingUser is the user who triggered a resolution: the WithCredentialAccess
+// tag when present, otherwise userID.
+func actingUser(ctx context.Context, userID string) string {
+	if access, _ := ctx.Value(credentialAccessKey{}).(credentialAccess); access.userID != "" {
+		return access.userID
+	}
+	return userID
+}
 
-	consider := func(cred models.Credential) {
-		if seen[cred.ID] {
-			return
+// logAccess queues one access-log row. The log must never break or delay
+// secret resolution, so the row is written in the background.
+func (s *CredentialService) logAccess(ctx context.Context, userID, credentialID string, result models.AuditResult) {
+	if s.accessWriter == nil {
+		return
+	}
+	access, _ := ctx.Value(credentialAccessKey{}).(credentialAccess)
+	access.userID = actingUser(ctx, userID)
+	if access.purpose == "" {
+		access.purpose = CredentialPurposeResolve
+	}
+	s.accessWriter.add(&models.CredentialAccessLog{
+		ID:           uuid.NewString(),
+		Time:         time.Now(),
+		CredentialID: credentialID,
+		UserID:       access.userID,
+		ConnectionID: access.connectionID,
+		Purpose:      access.purpose,
+		Result:       result,
+	})
+}
+
+// CredentialSortRecent orders Personalize output by the user's own last use.
+const CredentialSortRecent = "recent"
+
+// SetFavorite pins or unpins a credential in userID's picker. Pinning needs
+// use access; unpinning does not, so a lost credential can always be cleared.
+func (s *CredentialService) SetFavorite(ctx context.Context, userID, credentialID string, favorite bool) error {
+	if s.usage == nil {
+		return fmt.Errorf("%w: credential favorites are not enabled", plugin.ErrNotSupported)
+	}
+	ctx, cancel := WithTimeout(ctx, OpWrite)
+	defer cancel()
+	if favorite {
+		cred, err := s.creds.Get(ctx, credentialID)
+		if err != nil {
+			return timeoutError(ctx, err)
 		}
-		seen[cred.ID] = true
-		if len(kinds) > 0 && !slices.Contains(kinds, cred.Kind) {
-			return
+		if err := s.ensureUsableCredential(ctx, userID, cred); err != nil {
+			return timeoutError(ctx, err)
 		}
-		if protocol != "" {
-			if !s.kinds.CredentialKindSupportsProtocol(plugin.CredentialKind(cred.Kind), protocol) {
-				return
-			}
-			// Empty Protocols means the credential works with any compatible protocol.
-			if len(cred.Protocols) > 0 && !slices.Contains(cred.Protocols, protocol) {
-				return
+	}
+	return timeoutError(ctx, s.usage.SetFavorite(ctx, userID, credentialID, favorite))
+}
+
+// Personalize fills each summary's IsFavorite and LastUsedByMeAt for userID.
+// With sortBy CredentialSortRecent the list is reordered most recently used
+// first; credentials userID never used keep their order after them.
+func (s *CredentialService) Personalize(ctx context.Context, userID string, list []models.CredentialSummary, sortBy string) error {
+	if sortBy != "" && sortBy != CredentialSortRecent {
+		return fmt.Errorf("%w: sort must be %q", plugin.ErrInvalidInput, CredentialSortRecent)
+	}
+	if s.usage == nil {
+		return nil
+	}
+	ctx, cancel := WithTimeout(ctx, OpRead)
+	defer cancel()
+	rows, err := s.usage.ListByUser(ctx, userID)
+	if err != nil {
+		return timeoutError(ctx, err)
+	}
+	byID := make(map[string]models.CredentialUsage, len(rows))
+	for _, u := range rows {
+		byID[u.CredentialID] = u
+	}
+	for i := range list {
+		u := byID[list[i].ID]
+		list[i].IsFavorite, list[i].LastUsedByMeAt = u.Favorite, u.LastUsedAt
+	}
+	if sortBy == CredentialSortRecent {
+		slices.SortStableFunc(list, func(a, b models.CredentialSummary) int {
+			switch {
+			case a.LastUsedByMeAt == nil && b.LastUsedByMeAt == nil:
+				return 0
+			case a.LastUsedByMeAt == nil:
+				return 1
+			case b.LastUsedByMeAt == nil:
+				return -1
 			}
-		}
-		out = append(out, cred.Summary())
+			return b.LastUsedByMeAt.Compare(*a.LastUsedByMeAt)
+		})
 	}
+	return nil
+}
 
-	owned, err := s.creds.ListByOwner(ctx, userID)
+// ListUsable returns the non-secret summaries the user may select for a
+// credential_ref field, filtered by accepted kinds and an optional protocol.
+func (s *CredentialService) ListUsable(ctx context.Context, userID string, kinds []string, protocol string) ([]models.CredentialSummary, error) {
+	ctx, cancel := WithTimeout(ctx, OpRead)
+	defer cancel()
+	out, err := s.listUsable(ctx, userID, kinds, protocol)
+	return out, timeoutError(ctx, err)
+}
+
+func (s *CredentialService) listUsable(ctx context.Context, userID string, kinds []string, protocol string) ([]models.CredentialSummary, error) {
+	if protocol != "" {
+		if kinds = s.kindsFor(kinds, protocol); len(kinds) == 0 {
+			return nil, nil
+		}
+	}
+	owned, err := s.creds.List(ctx, store.CredentialFilter{OwnerID: userID, Kinds: kinds, Protocol: protocol})
 	if err != nil {
 		return nil, err
 	}
-	for _, c := range owned {
-		consider(c)
-	}
-
 	granted, err := s.grants.ListBySubject(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
-	for _, g := range granted {
-		cred, err := s.creds.Get(ctx, g.CredentialID)
-		if errors.Is(err, store.ErrNotFound) {
-			continue
+	var shared []models.Credential
+	if len(granted) > 0 {
+		ids := make([]string, 0, len(granted))
+		for _, g := range granted {
+			ids = append(ids, g.CredentialID)
 		}
-		if err != nil {
+		if shared, err = s.creds.List(ctx, store.CredentialFilter{IDs: ids, Kinds: kinds, Protocol: protocol}); err != nil {
 			return nil, err
 		}
-		consider(cred)
+	}
+
+	seen := map[string]bool{}
+	var out []models.CredentialSummary
+	for _, cred := range append(owned, shared...) {
+		if seen[cred.ID] {
+			continue
+		}
+		seen[cred.ID] = true
+		sum := cred.Summary()
+		if info, ok := s.kinds.CredentialKindLookup(plugin.CredentialKind(cred.Kind)); ok {
+			sum.KindLabel = info.Label
+		}
+		out = append(out, sum)
 	}
 	return out, nil
 }
+
+// kindsFor narrows kinds, or every known kind when empty, to those usable
+// with protocol.
+func (s *CredentialService) kindsFor(kinds []string, protocol string) []string {
+	if len(kinds) == 0 {
+		for _, info := range s.kinds.CredentialKinds() {
+			kinds = append(kinds, string(info.Kind))
+		}
+	}
+	var out []string
+	for _, k := range kinds {
+		if s.kinds.CredentialKindSupportsProtocol(plugin.CredentialKind(k), protocol) {
+			out = append(out, k)
+		}
+	}
+	return out
+}
@@ -2,8 +2,11 @@ package service_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/charlesng35/shellcn/internal/models"
 	"github.com/charlesng35/shellcn/internal/pluginregistry"
@@ -76,7 +79,11 @@ func newCredentialService(t *testing.T) (*service.CredentialService, *store.Stor
 	st := store.NewMemory()
 	reg := pluginregistry.New()
 	reg.MustRegister(credentialCatalogPlugin{})
-	return service.NewCredentialService(st.Credentials, st.CredentialGrants, vault, service.WithCredentialKindCatalog(reg)), st
+	svc := service.NewCredentialService(st.Credentials, st.CredentialGrants, vault,
+		service.WithCredentialKindCatalog(reg), service.WithCredentialAccessLog(st.CredentialAccess),
+		service.WithCredentialVersions(st.CredentialVersions), service.WithCredentialUsage(st.CredentialUsage))
+	t.Cleanup(svc.Close)
+	return svc, st
 }
 
 func TestCredentialCreateEncryptsAtRest(t *testing.T) {
@@ -145,6 +152,84 @@ func TestCredentialResolveOwnerAndGrant(t *testing.T) {
 	}
 }
 
+func TestCredentialAccessLogMatchesSecretAccess(t *testing.T) {
+	ctx := context.Background()
+	svc, st := newCredentialService(t)
+	cred, _ := svc.Create(ctx, service.NewCredentialInput{
+		OwnerID: "owner", Name: "k", Kind: "ssh_password",
+		Values: map[string]string{"username": "ops", "password": "topsecret"},
+	})
+	secretAccesses := 0
+	svc.SetSecretAccessHook(func() { secretAccesses++ })
+
+	launch := service.WithCredentialAccess(ctx, "actor", "c1", service.CredentialPurposeSessionLaunch)
+	if _, _, err := svc.ResolveWithMetadata(launch, "owner", cred.ID); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	_, _, _ = svc.ResolveWithMetadata(ctx, "stranger", cred.ID)
+	svc.Close()
+
+	logs, err := st.CredentialAccess.List(ctx, store.CredentialAccessFilter{CredentialID: cred.ID})
+	if err != nil || len(logs) != 2 {
+		t.Fatalf("access log: %+v err=%v", logs, err)
+	}
+	denied, allowed := logs[0], logs[1]
+	if allowed.UserID != "actor" || allowed.ConnectionID != "c1" || allowed.Purpose != service.CredentialPurposeSessionLaunch || allowed.Result != models.AuditAllowed {
+		t.Errorf("allowed entry: %+v", allowed)
+	}
+	if denied.UserID != "stranger" || denied.Purpose != service.CredentialPurposeResolve || denied.Result != models.AuditDenied {
+		t.Errorf("denied entry: %+v", denied)
+	}
+	if secretAccesses != 1 {
+		t.Errorf("secret access hook calls = %d, want one per allowed entry", secretAccesses)
+	}
+}
+
+// blockedAccessLog holds every Append until release is closed.
+type blockedAccessLog struct {
+	store.CredentialAccessLogStore
+	release chan struct{}
+}
+
+func (b blockedAccessLog) Append(ctx context.Context, row *models.CredentialAccessLog) error {
+	<-b.release
+	return b.CredentialAccessLogStore.Append(ctx, row)
+}
+
+func TestCredentialAccessLogDoesNotDelayResolve(t *testing.T) {
+	ctx := context.Background()
+	key, _ := secrets.GenerateMasterKey()
+	vault, _ := secrets.NewVault(key)
+	st := store.NewMemory()
+	release := make(chan struct{})
+	svc := service.NewCredentialService(st.Credentials, st.CredentialGrants, vault,
+		service.WithCredentialAccessLog(blockedAccessLog{st.CredentialAccess, release}))
+	cred, _ := svc.Create(ctx, service.NewCredentialInput{
+		OwnerID: "owner", Name: "k", Kind: "ssh_password",
+		Values: map[string]string{"username": "ops", "password": "topsecret"},
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := svc.ResolveWithMetadata(ctx, "owner", cred.ID)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("resolve: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("resolve waited on the access log")
+	}
+
+	close(release)
+	svc.Close()
+	if n, _ := st.CredentialAccess.Count(ctx, store.CredentialAccessFilter{CredentialID: cred.ID}); n != 1 {
+		t.Fatalf("access log rows after close = %d, want 1", n)
+	}
+}
+
 func TestCredentialRotateAndResolve(t *testing.T) {
 	ctx := context.Background()
 	svc, st := newCredentialService(t)
@@ -248,6 +333,74 @@ func TestCredentialCreateValidatesKindAndSecret(t *testing.T) {
 	}
 }
 
+func TestCredentialValidationReportsEveryField(t *testing.T) {
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	kinds := plugin.MustCredentialKindSet([]plugin.CredentialKindInfo{
+		{
+			Kind: "strict", Label: "Strict",
+			Fields: []plugin.Field{
+				plugin.CredentialPublicField(plugin.Field{Key: "user", Label: "User", Type: plugin.FieldText, Required: true}),
+				plugin.CredentialPublicField(plugin.Field{Key: "port", Label: "Port", Type: plugin.FieldNumber}),
+				plugin.CredentialPublicField(plugin.Field{Key: "tls", Label: "TLS", Type: plugin.FieldToggle}),
+				plugin.CredentialPublicField(plugin.Field{Key: "region", Label: "Region", Type: plugin.FieldSelect,
+					Options: []plugin.Option{{Label: "EU", Value: "eu"}, {Label: "US", Value: "us"}}}),
+				plugin.CredentialSecretField(plugin.Field{Key: "token", Label: "Token", Type: plugin.FieldPassword, Required: true}),
+			},
+		},
+		{
+			Kind: "loose", Label: "Loose", AllowUnknownFields: true, DeprecatedAfter: &past,
+			Fields: []plugin.Field{
+				plugin.CredentialSecretField(plugin.Field{Key: "token", Label: "Token", Type: plugin.FieldPassword, Required: true}),
+			},
+		},
+	})
+	key, _ := secrets.GenerateMasterKey()
+	vault, _ := secrets.NewVault(key)
+	st := store.NewMemory()
+	svc := service.NewCredentialService(st.Credentials, st.CredentialGrants, vault, service.WithCredentialKindCatalog(kinds))
+
+	_, err := svc.Create(ctx, service.NewCredentialInput{OwnerID: "u", Name: "bad", Kind: "strict", Values: map[string]string{
+		"port": "twenty-two", "tls": "maybe", "region": "mars", "extra": "x",
+	}})
+	var verr *service.ValidationError
+	if !errors.As(err, &verr) || !errors.Is(err, plugin.ErrInvalidInput) {
+		t.Fatalf("want a ValidationError, got %v", err)
+	}
+	got := map[string]bool{}
+	for _, f := range verr.Fields {
+		got[f.Field] = true
+	}
+	for _, want := range []string{"extra", "user", "port", "tls", "region", "token"} {
+		if !got[want] {
+			t.Errorf("field %q not reported: %+v", want, verr.Fields)
+		}
+	}
+
+	cred, err := svc.Create(ctx, service.NewCredentialInput{OwnerID: "u", Name: "ok", Kind: "strict", Values: map[string]string{
+		"user": "deploy", "port": "22", "tls": "true", "region": "eu", "token": "t",
+	}})
+	if err != nil || cred.Values["port"] != "22" {
+		t.Fatalf("valid create: %+v %v", cred, err)
+	}
+	if w := svc.KindWarnings("strict"); len(w) != 0 {
+		t.Fatalf("strict kind is not deprecated: %v", w)
+	}
+
+	loose, err := svc.Create(ctx, service.NewCredentialInput{OwnerID: "u", Name: "loose", Kind: "loose", Values: map[string]string{
+		"token": "t", "tenant": "acme",
+	}})
+	if err != nil {
+		t.Fatalf("unknown field on a permissive kind: %v", err)
+	}
+	if _, values, err := svc.ResolveWithMetadata(ctx, "u", loose.ID); err != nil || values["tenant"] != "acme" {
+		t.Fatalf("unknown field should be kept: %v %v", values, err)
+	}
+	if w := svc.KindWarnings("loose"); len(w) != 1 || !strings.Contains(w[0], "deprecated") {
+		t.Fatalf("deprecation warning: %v", w)
+	}
+}
+
 func containsBytes(haystack []byte, needle string) bool {
 	return len(needle) > 0 && len(haystack) >= len(needle) && indexOfBytes(haystack, needle) >= 0
 }
@@ -260,3 +413,98 @@ func indexOfBytes(h []byte, n string) int {
 	}
 	return -1
 }
+
+func TestCredentialDiffVersionsRedactsValues(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newCredentialService(t)
+	cred, err := svc.Create(ctx, service.NewCredentialInput{
+		OwnerID: "owner", Name: "k", Kind: "ssh_password",
+		Values: map[string]string{"username": "ops", "password": "first-secret"},
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := svc.Update(ctx, cred.ID, service.UpdateCredentialInput{
+		Name: "k", Kind: "ssh_password", Values: map[string]string{"username": "ops", "password": "second-secret"}, ActorID: "owner",
+	}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	versions, _ := svc.Versions(ctx, cred.ID)
+	if len(versions) != 2 || versions[1].Version != 2 || versions[1].CreatedBy != "owner" {
+		t.Fatalf("versions = %+v", versions)
+	}
+
+	diff, err := svc.DiffVersions(ctx, cred.ID, 1, 2)
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	changes := map[string]service.CredentialFieldDiff{}
+	for _, f := range diff.Fields {
+		changes[f.Key] = f
+	}
+	if f := changes["password"]; !f.Changed || f.Change != service.CredentialFieldChanged || f.FromHash == f.ToHash || len(f.FromHash) != 8 {
+		t.Fatalf("password diff = %+v", f)
+	}
+	if f := changes["username"]; f.Changed || f.FromHash != f.ToHash {
+		t.Fatalf("username diff = %+v", f)
+	}
+	raw, _ := json.Marshal(diff)
+	for _, secret := range []string{"first-secret", "second-secret", "ops"} {
+		if strings.Contains(string(raw), secret) {
+			t.Fatalf("diff leaked %q: %s", secret, raw)
+		}
+	}
+
+	if _, err := svc.DiffVersions(ctx, cred.ID, 1, 9); !errors.Is(err, plugin.ErrNotFound) {
+		t.Fatalf("missing version err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCredentialFavoritesAndRecentOrder(t *testing.T) {
+	ctx := context.Background()
+	svc, st := newCredentialService(t)
+	var ids []string
+	for _, name := range []string{"a", "b", "c"} {
+		c, err := svc.Create(ctx, service.NewCredentialInput{OwnerID: "u", Name: name, Kind: "db_password", Values: map[string]string{"username": name, "password": "p"}})
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		ids = append(ids, c.ID)
+	}
+	if err := svc.SetFavorite(ctx, "u", ids[0], true); err != nil {
+		t.Fatalf("favorite: %v", err)
+	}
+	if err := svc.SetFavorite(ctx, "other", ids[0], true); !errors.Is(err, models.ErrForbidden) {
+		t.Fatalf("favorite without access: want forbidden, got %v", err)
+	}
+	for _, id := range []string{ids[1], ids[2]} {
+		if _, _, err := svc.ResolveWithMetadata(ctx, "u", id); err != nil {
+			t.Fatalf("resolve: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	list, _ := svc.ListUsable(ctx, "u", nil, "")
+	if err := svc.Personalize(ctx, "u", list, service.CredentialSortRecent); err != nil {
+		t.Fatalf("personalize: %v", err)
+	}
+	if len(list) != 3 || list[0].ID != ids[2] || list[1].ID != ids[1] || list[2].ID != ids[0] {
+		t.Fatalf("recent order: %+v", list)
+	}
+	if !list[2].IsFavorite || list[2].LastUsedByMeAt != nil || list[0].IsFavorite || list[0].LastUsedByMeAt == nil {
+		t.Fatalf("personal fields: %+v", list)
+	}
+	if err := svc.Personalize(ctx, "u", list, "popular"); !errors.Is(err, plugin.ErrInvalidInput) {
+		t.Fatalf("unknown sort: %v", err)
+	}
+
+	if err := svc.Delete(ctx, ids[0]); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	rows, _ := st.CredentialUsage.ListByUser(ctx, "u")
+	for _, r := range rows {
+		if r.CredentialID == ids[0] {
+			t.Fatalf("usage row survived credential delete: %+v", rows)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/pluginregistry"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// DriverSettingsKey is the system setting holding a driver's settings as one
+// JSON object.
+func DriverSettingsKey(driver string) string {
+	return "drivers." + driver + ".settings"
+}
+
+// DriverSettingsView is a driver's settings form with its current values.
+type DriverSettingsView struct {
+	Driver    string         `json:"driver"`
+	Schema    plugin.Schema  `json:"schema"`
+	Values    map[string]any `json:"values"`
+	UpdatedBy string         `json:"updatedBy,omitempty"`
+	UpdatedAt *time.Time     `json:"updatedAt,omitempty"`
+}
+
+// DriverSettings stores the instance-wide settings of plugins that implement
+// plugin.Configurable, validated against the schema each plugin declares.
+type DriverSettings struct {
+	plugins  *pluginregistry.Registry
+	settings *SettingsService
+}
+
+func NewDriverSettings(plugins *pluginregistry.Registry, settings *SettingsService) *DriverSettings {
+	return &DriverSettings{plugins: plugins, settings: settings}
+}
+
+func (d *DriverSettings) schema(driver string) (plugin.Schema, error) {
+	schema, ok := d.plugins.Settings(driver)
+	if !ok {
+		return plugin.Schema{}, fmt.Errorf("%w: driver %q has no settings", plugin.ErrNotFound, driver)
+	}
+	return schema, nil
+}
+
+// stored decodes the saved settings of a driver; none saved is an empty map.
+func (d *DriverSettings) stored(ctx context.Context, driver string) (map[string]any, models.SystemSetting, error) {
+	key := DriverSettingsKey(driver)
+	row, err := d.settings.Get(ctx, key)
+	if errors.Is(err, store.ErrNotFound) {
+		return map[string]any{}, models.SystemSetting{}, nil
+	}
+	if err != nil {
+		return nil, models.SystemSetting{}, err
+	}
+	values := map[string]any{}
+	if err := json.Unmarshal([]byte(row.Value), &values); err != nil {
+		return nil, models.SystemSetting{}, fmt.Errorf("decode %s: %w", key, err)
+	}
+	return values, row, nil
+}
+
+// Values returns a driver's settings with schema defaults filled in, or nil
+// when the driver declares none. Keys the schema no longer declares are
+// dropped.
+func (d *DriverSettings) Values(ctx context.Context, driver string) (map[string]any, error) {
+	schema, ok := d.plugins.Settings(driver)
+	if !ok {
+		return nil, nil
+	}
+	values, _, err := d.stored(ctx, driver)
+	if err != nil {
+		return nil, err
+	}
+	return schema.VisibleValues(schema.ValuesWithDefaults(values), nil), nil
+}
+
+func (d *DriverSettings) Get(ctx context.Context, driver string) (DriverSettingsView, error) {
+	schema, err := d.schema(driver)
+	if err != nil {
+		return DriverSettingsView{}, err
+	}
+	values, row, err := d.stored(ctx, driver)
+	if err != nil {
+		return DriverSettingsView{}, err
+	}
+	view := DriverSettingsView{
+		Driver: driver, Schema: schema,
+		Values:    schema.VisibleValues(schema.ValuesWithDefaults(values), nil),
+		UpdatedBy: row.UpdatedBy,
+	}
+	if !row.UpdatedAt.IsZero() {
+		at := row.UpdatedAt
+		view.UpdatedAt = &at
+	}
+	return view, nil
+}
+
+// Update replaces a driver's settings. Sessions pick them up when they next
+// open.
+func (d *DriverSettings) Update(ctx context.Context, actor models.User, driver string, values map[string]any) (DriverSettingsView, error) {
+	schema, err := d.schema(driver)
+	if err != nil {
+		return DriverSettingsView{}, err
+	}
+	if err := schema.ValidateValues(values, nil); err != nil {
+		return DriverSettingsView{}, err
+	}
+	raw, err := json.Marshal(values)
+	if err != nil {
+		return DriverSettingsView{}, err
+	}
+	if err := d.settings.Set(ctx, &models.SystemSetting{Key: DriverSettingsKey(driver), Value: string(raw), UpdatedBy: actor.ID}); err != nil {
+		return DriverSettingsView{}, err
+	}
+	return d.Get(ctx, driver)
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// FieldError is one rejected form field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	// Suggestion is a known field the rejected one is probably a typo of.
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// ValidationError collects every rejected field of one submission so a form
+// can mark them all at once. It unwraps to plugin.ErrInvalidInput.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Message)
+	}
+	return plugin.ErrInvalidInput.Error() + ": " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Unwrap() error { return plugin.ErrInvalidInput }
+
+func (e *ValidationError) add(field, msg string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: msg})
+}
+
+// err returns e when any field was rejected, otherwise nil.
+func (e *ValidationError) err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// closestKey returns the key in known that key is most likely a typo of, or
+// "" when none is close. Case, '_' and '-' are ignored, so "keepAlive"
+// matches "keep_alive" exactly.
+func closestKey(key string, known []string) string {
+	norm := func(s string) string {
+		return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(s))
+	}
+	want := norm(key)
+	best, bestDist := "", 3
+	for _, k := range known {
+		d := editDistance(want, norm(k))
+		if d < bestDist && d < max(len(want)/2, 1) {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b, by byte.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// SettingSharingMaxGrantDays caps how far ahead a connection share may
+// expire; 0 lifts the cap.
+const SettingSharingMaxGrantDays = "sharing.max_grant_days"
+
+// DefaultMaxGrantDays applies until an admin sets another cap.
+const DefaultMaxGrantDays = 365
+
+// ShareExpiryWarning is how long before a share lapses its grantee is warned.
+const ShareExpiryWarning = 15 * time.Minute
+
+// ExpiringShares is one connection's shares that lapse within a window.
+type ExpiringShares struct {
+	Connection models.Connection
+	Grants     []models.Grant
+}
+
+// ShareExpiryNotice tells a grantee that their share of a connection is about
+// to lapse, or has.
+type ShareExpiryNotice struct {
+	GrantID        string    `json:"grantId"`
+	ConnectionID   string    `json:"connectionId"`
+	ConnectionName string    `json:"connectionName"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+	Expired        bool      `json:"expired"`
+}
+
+// GrantExpiry reports and extends the expiry of connection shares, and tells
+// grantees when theirs run out.
+type GrantExpiry struct {
+	grants   store.GrantStore
+	conns    store.ConnectionStore
+	settings *SettingsService
+	now      func() time.Time
+	notices  userHub[ShareExpiryNotice]
+
+	mu sync.Mutex
+	// notifiedUntil is the end of the last window Notify covered.
+	notifiedUntil time.Time
+}
+
+func NewGrantExpiry(grants store.GrantStore, conns store.ConnectionStore, settings *SettingsService) *GrantExpiry {
+	return &GrantExpiry{grants: grants, conns: conns, settings: settings, now: time.Now}
+}
+
+// Validate checks a requested expiry lies in the future and within the
+// configured maximum grant lifetime.
+func (e *GrantExpiry) Validate(ctx context.Context, expiresAt time.Time) error {
+	now := e.now()
+	if !expiresAt.After(now) {
+		return fmt.Errorf("%w: expiry must be in the future", plugin.ErrInvalidInput)
+	}
+	days, err := e.settings.Int(ctx, SettingSharingMaxGrantDays)
+	if err != nil {
+		return err
+	}
+	if days > 0 && expiresAt.After(now.AddDate(0, 0, days)) {
+		return fmt.Errorf("%w: expiry may be at most %d days away", plugin.ErrInvalidInput, days)
+	}
+	return nil
+}
+
+// ExpiresIn turns a duration shortcut such as "1h", "1d" or "7d" into an
+// expiry that far from now, checked as Validate does.
+func (e *GrantExpiry) ExpiresIn(ctx context.Context, in string) (time.Time, error) {
+	d, err := parseShareDuration(in)
+	if err != nil {
+		return time.Time{}, err
+	}
+	at := e.now().Add(d)
+	if err := e.Validate(ctx, at); err != nil {
+		return time.Time{}, err
+	}
+	return at, nil
+}
+
+func parseShareDuration(in string) (time.Duration, error) {
+	unit := map[byte]time.Duration{'h': time.Hour, 'd': 24 * time.Hour}
+	in = strings.TrimSpace(in)
+	if len(in) >= 2 {
+		if u, ok := unit[in[len(in)-1]]; ok {
+			if n, err := strconv.Atoi(in[:len(in)-1]); err == nil && n > 0 {
+				return time.Duration(n) * u, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%w: duration must be a whole number of hours or days, such as 1h or 7d", plugin.ErrInvalidInput)
+}
+
+// Expiring lists the shares of ownerID's connections that lapse within the
+// window, grouped by connection in order of the soonest lapse.
+func (e *GrantExpiry) Expiring(ctx context.Context, ownerID string, within time.Duration) ([]ExpiringShares, error) {
+	ctx, cancel := WithTimeout(ctx, OpRead)
+	defer cancel()
+	conns, err := e.conns.ListByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, timeoutError(ctx, err)
+	}
+	byID := make(map[string]models.Connection, len(conns))
+	ids := make([]string, 0, len(conns))
+	for _, c := range conns {
+		byID[c.ID] = c
+		ids = append(ids, c.ID)
+	}
+	now := e.now()
+	grants, err := e.grants.ListExpiring(ctx, ids, now, now.Add(within))
+	if err != nil {
+		return nil, timeoutError(ctx, err)
+	}
+	out := []ExpiringShares{}
+	index := map[string]int{}
+	for _, g := range grants {
+		i, ok := index[g.ConnectionID]
+		if !ok {
+			i = len(out)
+			index[g.ConnectionID] = i
+			out = append(out, ExpiringShares{Connection: byID[g.ConnectionID]})
+		}
+		out[i].Grants = append(out[i].Grants, g)
+	}
+	return out, nil
+}
+
+// Extend moves every listed share to expiresAt. Each share must sit on a
+// connection actor owns; otherwise nothing changes. It returns the updated
+// shares.
+func (e *GrantExpiry) Extend(ctx context.Context, actor models.User, grantIDs []string, expiresAt time.Time) ([]models.Grant, error) {
+	if len(grantIDs) == 0 {
+		return nil, fmt.Errorf("%w: no shares to extend", plugin.ErrInvalidInput)
+	}
+	if err := e.Validate(ctx, expiresAt); err != nil {
+		return nil, err
+	}
+	ctx, cancel := WithTimeout(ctx, OpWrite)
+	defer cancel()
+	ids := slices.Compact(slices.Sorted(slices.Values(grantIDs)))
+	out := make([]models.Grant, 0, len(ids))
+	for _, id := range ids {
+		g, err := e.grants.GetByID(ctx, id)
+		if err != nil {
+			return nil, timeoutError(ctx, err)
+		}
+		conn, err := e.conns.Get(ctx, g.ConnectionID)
+		if err != nil {
+			return nil, timeoutError(ctx, err)
+		}
+		if conn.OwnerID != actor.ID {
+			return nil, fmt.Errorf("share %q: %w", id, plugin.ErrForbidden)
+		}
+		g.ExpiresAt = &expiresAt
+		out = append(out, g)
+	}
+	if err := e.grants.SetExpiry(ctx, ids, &expiresAt); err != nil {
+		return nil, timeoutError(ctx, err)
+	}
+	return out, nil
+}
+
+// SubscribeNotices streams expiry notices for shares granted to userID.
+func (e *GrantExpiry) SubscribeNotices(userID string) (Feed[ShareExpiryNotice], func()) {
+	return e.notices.subscribe(userID)
+}
+
+// Notify warns grantees whose shares lapse within ShareExpiryWarning and
+// tells those whose shares lapsed since the last call. Each share is
+// reported once per state while the process runs; after a restart, shares
+// that lapsed in between are not reported.
+func (e *GrantExpiry) Notify(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.now()
+	from, warnFrom := e.notifiedUntil, e.notifiedUntil.Add(ShareExpiryWarning)
+	if from.IsZero() {
+		from, warnFrom = now, now
+	}
+	ctx, cancel := WithTimeout(ctx, OpRead)
+	defer cancel()
+	warn, err := e.grants.ListLapsing(ctx, warnFrom, now.Add(ShareExpiryWarning))
+	if err != nil {
+		return timeoutError(ctx, err)
+	}
+	expired, err := e.grants.ListLapsing(ctx, from, now)
+	if err != nil {
+		return timeoutError(ctx, err)
+	}
+	names := map[string]string{}
+	publish := func(g models.Grant, lapsed bool) {
+		name, ok := names[g.ConnectionID]
+		if !ok {
+			if c, err := e.conns.Get(ctx, g.ConnectionID); err == nil {
+				name = c.Name
+			}
+			names[g.ConnectionID] = name
+		}
+		e.notices.publish(g.SubjectID, ShareExpiryNotice{
+			GrantID: g.ID, ConnectionID: g.ConnectionID, ConnectionName: name,
+			ExpiresAt: *g.ExpiresAt, Expired: lapsed,
+		})
+	}
+	for _, g := range expired {
+		publish(g, true)
+	}
+	for _, g := range warn {
+		publish(g, false)
+	}
+	e.notifiedUntil = now
+	return nil
+}
+
+func grantLifetimeSetting() SettingDef {
+	return SettingDef{
+		Key: SettingSharingMaxGrantDays, Type: SettingInt, Default: strconv.Itoa(DefaultMaxGrantDays),
+		Description: "Furthest a connection share may be set to expire, in days; 0 removes the cap.",
+		Validate: func(v string) error {
+			if n, err := strconv.Atoi(v); err != nil || n < 0 {
+				return fmt.Errorf("must be a whole number of at least 0")
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,90 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+func TestGrantExpiryNotifiesGrantee(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	settings := service.NewSettingsService(st.SystemSettings)
+	settings.Register(service.BuiltinSettings()...)
+	expiry := service.NewGrantExpiry(st.Grants, st.Connections, settings)
+	share := func(id string, expiresAt time.Time) {
+		t.Helper()
+		if err := st.Connections.Create(ctx, &models.Connection{ID: "c-" + id, Name: id + " host", OwnerID: "owner", Protocol: "ssh"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := st.Grants.Create(ctx, &models.Grant{ID: id, ConnectionID: "c-" + id, SubjectID: "guest", Access: models.AccessView, ExpiresAt: &expiresAt}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	feed, cancel := expiry.SubscribeNotices("guest")
+	defer cancel()
+	next := func() (service.ShareExpiryNotice, bool) {
+		select {
+		case n := <-feed.C:
+			return n, true
+		default:
+			return service.ShareExpiryNotice{}, false
+		}
+	}
+
+	share("soon", time.Now().Add(10*time.Minute))
+	share("later", time.Now().Add(time.Hour))
+	if err := expiry.Notify(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := next(); !ok || n.GrantID != "soon" || n.Expired || n.ConnectionName != "soon host" {
+		t.Fatalf("warning = %+v, %v", n, ok)
+	}
+	if n, ok := next(); ok {
+		t.Fatalf("unexpected notice %+v", n)
+	}
+
+	// A share that lapses between runs is reported once as expired, and the
+	// earlier warning is not repeated.
+	share("brief", time.Now().Add(20*time.Millisecond))
+	time.Sleep(50 * time.Millisecond)
+	if err := expiry.Notify(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := next(); !ok || n.GrantID != "brief" || !n.Expired {
+		t.Fatalf("expired = %+v, %v", n, ok)
+	}
+	if err := expiry.Notify(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := next(); ok {
+		t.Fatalf("unexpected notice %+v", n)
+	}
+}
+
+func TestGrantExpiryExpiresIn(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	settings := service.NewSettingsService(st.SystemSettings)
+	settings.Register(service.BuiltinSettings()...)
+	expiry := service.NewGrantExpiry(st.Grants, st.Connections, settings)
+
+	for in, want := range map[string]time.Duration{"1h": time.Hour, "1d": 24 * time.Hour, "7d": 7 * 24 * time.Hour} {
+		at, err := expiry.ExpiresIn(ctx, in)
+		if err != nil {
+			t.Fatalf("%s: %v", in, err)
+		}
+		if d := time.Until(at); d > want || d < want-time.Minute {
+			t.Fatalf("%s: expires in %v", in, d)
+		}
+	}
+	for _, in := range []string{"", "0d", "-1h", "1w", "d", "1.5h", "400d"} {
+		if _, err := expiry.ExpiresIn(ctx, in); err == nil {
+			t.Fatalf("%q accepted", in)
+		}
+	}
+}
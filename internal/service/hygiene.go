@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+const hygieneBatch = 500
+
+// HygieneReport is the outcome of one orphan sweep: rows removed per
+// category, and the error that stopped it early, if any.
+type HygieneReport struct {
+	RanAt   time.Time                      `json:"ranAt"`
+	Removed map[store.OrphanCategory]int64 `json:"removed"`
+	Error   string                         `json:"error,omitempty"`
+}
+
+// HygieneService sweeps grants whose connection, credential or user is gone.
+// Deletions clean up after themselves; the sweep is the backstop for rows a
+// failed cleanup or an older release left behind.
+type HygieneService struct {
+	orphans store.OrphanStore
+
+	mu   sync.Mutex
+	last *HygieneReport
+}
+
+func NewHygieneService(orphans store.OrphanStore) *HygieneService {
+	return &HygieneService{orphans: orphans}
+}
+
+// Run deletes every orphan in batches and keeps the report for Last.
+func (s *HygieneService) Run(ctx context.Context, now time.Time) (HygieneReport, error) {
+	ctx = WithoutTimeouts(ctx)
+	rep := HygieneReport{RanAt: now, Removed: map[store.OrphanCategory]int64{}}
+	var err error
+sweep:
+	for _, c := range store.OrphanCategories {
+		rep.Removed[c] = 0
+		for {
+			var n int64
+			if n, err = s.orphans.Purge(ctx, c, hygieneBatch); err != nil {
+				rep.Error = err.Error()
+				break sweep
+			}
+			rep.Removed[c] += n
+			if n < hygieneBatch {
+				break
+			}
+		}
+	}
+	s.mu.Lock()
+	s.last = &rep
+	s.mu.Unlock()
+	return rep, err
+}
+
+// Last returns the most recent sweep's report, or nil before the first.
+func (s *HygieneService) Last() *HygieneReport {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// Total is the number of rows the sweep removed.
+func (r HygieneReport) Total() int64 {
+	var n int64
+	for _, v := range r.Removed {
+		n += v
+	}
+	return n
+}
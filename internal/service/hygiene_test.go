@@ -0,0 +1,102 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+func TestHygieneSweepsOrphanedGrants(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	_ = st.Users.Create(ctx, &models.User{ID: "u1", Username: "alice"}, "")
+	_ = st.Connections.Create(ctx, &models.Connection{ID: "c1", Name: "db", OwnerID: "u1"})
+	_ = st.Grants.Create(ctx, &models.Grant{ID: "g1", ConnectionID: "c1", SubjectID: "u1"})
+	_ = st.Grants.Create(ctx, &models.Grant{ID: "g2", ConnectionID: "purged", SubjectID: "u1"})
+	_ = st.CredentialGrants.Create(ctx, &models.CredentialGrant{ID: "cg1", CredentialID: "deleted", SubjectID: "u1"})
+
+	h := service.NewHygieneService(st.Orphans)
+	if h.Last() != nil {
+		t.Fatal("no report before the first sweep")
+	}
+	now := time.Now()
+	rep, err := h.Run(ctx, now)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if rep.Removed[store.OrphanGrantConnection] != 1 || rep.Removed[store.OrphanCredentialGrantCredential] != 1 || rep.Total() != 2 {
+		t.Fatalf("report: %+v", rep)
+	}
+	if last := h.Last(); last == nil || !last.RanAt.Equal(now) {
+		t.Fatalf("last: %+v", last)
+	}
+	if _, err := st.Grants.Get(ctx, "c1", "u1"); err != nil {
+		t.Fatalf("live grant removed: %v", err)
+	}
+}
+
+func TestUserDeleteRemovesGrants(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	_ = st.Users.Create(ctx, &models.User{ID: "u1", Username: "alice"}, "")
+	_ = st.Grants.Create(ctx, &models.Grant{ID: "g1", ConnectionID: "c1", SubjectID: "u1"})
+	_ = st.CredentialGrants.Create(ctx, &models.CredentialGrant{ID: "cg1", CredentialID: "cr1", SubjectID: "u1"})
+
+	users := service.NewUserService(st.Users, service.WithUserGrants(st.Grants, st.CredentialGrants))
+	if err := users.Delete(ctx, "u1", service.UserDeleteOptions{}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if list, _ := st.Grants.ListBySubject(ctx, "u1"); len(list) != 0 {
+		t.Fatalf("connection grants left: %+v", list)
+	}
+	if list, _ := st.CredentialGrants.ListBySubject(ctx, "u1"); len(list) != 0 {
+		t.Fatalf("credential grants left: %+v", list)
+	}
+}
+
+func TestUserDeleteHandlesOwnedCredentials(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	for _, u := range []models.User{{ID: "u1", Username: "alice"}, {ID: "u2", Username: "bob"}, {ID: "u3", Username: "carol", Disabled: true}} {
+		_ = st.Users.Create(ctx, &u, "")
+	}
+	for _, id := range []string{"cr1", "cr2"} {
+		_ = st.Credentials.Create(ctx, &models.Credential{ID: id, Name: id, Kind: "password", OwnerID: "u1"})
+	}
+	_ = st.CredentialGrants.Create(ctx, &models.CredentialGrant{ID: "cg1", CredentialID: "cr1", SubjectID: "u2"})
+
+	users := service.NewUserService(st.Users, service.WithUserGrants(st.Grants, st.CredentialGrants), service.WithUserCredentials(st.Credentials))
+	var owned *service.OwnedCredentialsError
+	if err := users.Delete(ctx, "u1", service.UserDeleteOptions{}); !errors.As(err, &owned) || owned.Count != 2 || !errors.Is(err, plugin.ErrConflict) {
+		t.Fatalf("delete without target = %v, want owned-credentials conflict for 2", err)
+	}
+	if err := users.Delete(ctx, "u1", service.UserDeleteOptions{TransferCredentialsTo: "u3"}); !errors.Is(err, plugin.ErrInvalidInput) {
+		t.Fatalf("transfer to deactivated user = %v, want invalid input", err)
+	}
+	if _, err := users.Get(ctx, "u1"); err != nil {
+		t.Fatalf("user deleted despite refusal: %v", err)
+	}
+	if err := users.Delete(ctx, "u1", service.UserDeleteOptions{TransferCredentialsTo: "u2"}); err != nil {
+		t.Fatalf("delete with transfer: %v", err)
+	}
+	if list, _ := st.Credentials.ListByOwner(ctx, "u2"); len(list) != 2 {
+		t.Fatalf("credentials moved = %d, want 2", len(list))
+	}
+	if g, err := st.CredentialGrants.ListBySubject(ctx, "u2"); err != nil || len(g) != 1 || g[0].CredentialID != "cr1" {
+		t.Fatalf("grant by old owner = %+v, %v; want kept", g, err)
+	}
+
+	_ = st.Credentials.Create(ctx, &models.Credential{ID: "cr3", Name: "cr3", Kind: "password", OwnerID: "u2"})
+	if err := users.Delete(ctx, "u2", service.UserDeleteOptions{OrphanCredentials: true}); err != nil {
+		t.Fatalf("delete with orphaning: %v", err)
+	}
+	if list, _ := st.Credentials.ListByOwner(ctx, "u2"); len(list) != 3 {
+		t.Fatalf("orphaned credentials = %d, want 3 left in place", len(list))
+	}
+}
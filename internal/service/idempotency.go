@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+// DefaultIdempotencyTTL is how long a replayable response is kept.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// IdempotencyService stores the responses of requests sent with an
+// Idempotency-Key and serializes concurrent requests sharing one, so a retry
+// neither repeats the operation nor races the original attempt. The lock is
+// per process; a retry landing on another replica still finds the stored
+// response once the first attempt has finished.
+type IdempotencyService struct {
+	keys store.IdempotencyKeyStore
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func NewIdempotencyService(keys store.IdempotencyKeyStore, ttl time.Duration) *IdempotencyService {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	return &IdempotencyService{keys: keys, ttl: ttl, locks: map[string]*keyLock{}}
+}
+
+// Lock blocks until no other request holds id and returns its release.
+func (s *IdempotencyService) Lock(id string) func() {
+	s.mu.Lock()
+	l, ok := s.locks[id]
+	if !ok {
+		l = &keyLock{}
+		s.locks[id] = l
+	}
+	l.refs++
+	s.mu.Unlock()
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		s.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(s.locks, id)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Lookup returns the stored response for id, if one has not expired.
+func (s *IdempotencyService) Lookup(ctx context.Context, id string, now time.Time) (models.IdempotencyKey, bool, error) {
+	ctx, cancel := WithTimeout(ctx, OpRead)
+	defer cancel()
+	k, err := s.keys.Get(ctx, id, now)
+	if errors.Is(err, store.ErrNotFound) {
+		return k, false, nil
+	}
+	if err != nil {
+		return k, false, timeoutError(ctx, err)
+	}
+	return k, true, nil
+}
+
+// Remember stores k's response until the TTL passes.
+func (s *IdempotencyService) Remember(ctx context.Context, k models.IdempotencyKey, now time.Time) error {
+	ctx, cancel := WithTimeout(ctx, OpWrite)
+	defer cancel()
+	k.CreatedAt, k.ExpiresAt = now, now.Add(s.ttl)
+	return timeoutError(ctx, s.keys.Put(ctx, &k))
+}
+
+// Purge deletes the keys that expired by now.
+func (s *IdempotencyService) Purge(ctx context.Context, now time.Time) (int64, error) {
+	return s.keys.DeleteExpired(WithoutTimeouts(ctx), now)
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,11 +15,24 @@ import (
 	"github.com/charlesng35/shellcn/internal/store"
 )
 
-// DefaultInvitationTTL is how long an unaccepted invitation stays valid.
-const DefaultInvitationTTL = 72 * time.Hour
+// SettingInvitationExpiryDays is how many days a new or resent invitation
+// link stays valid.
+const SettingInvitationExpiryDays = "invitations.expiry_days"
 
-// ErrInvitationInvalid is returned for an unknown, expired, or consumed token.
-var ErrInvitationInvalid = errors.New("service: invalid or expired invitation")
+// DefaultInvitationExpiryDays applies until an admin sets another expiry.
+const DefaultInvitationExpiryDays = 7
+
+// maxInvitationExpiryDays bounds how long a leaked link can stay usable.
+const maxInvitationExpiryDays = 90
+
+var (
+	// ErrInvitationInvalid is returned for an unknown, revoked, or consumed
+	// token. It says nothing about the invited address.
+	ErrInvitationInvalid = errors.New("service: invalid invitation")
+	// ErrInvitationExpired is returned for a pending invitation whose link
+	// has lapsed.
+	ErrInvitationExpired = errors.New("invitation expired")
+)
 
 // Mailer sends invitation emails; satisfied by internal/email.Mailer.
 type Mailer interface {
@@ -29,15 +43,50 @@ type Mailer interface {
 // InvitationService issues account invitations, sends the link when email is
 // configured, and consumes a token to create the account on acceptance.
 type InvitationService struct {
-	invites store.InvitationStore
-	users   *UserService
-	mailer  Mailer
-	ttl     time.Duration
-	now     func() time.Time
+	invites  store.InvitationStore
+	users    *UserService
+	mailer   Mailer
+	settings *SettingsService
+	now      func() time.Time
+}
+
+// InvitationOption configures an InvitationService.
+type InvitationOption func(*InvitationService)
+
+// WithInvitationSettings reads the link expiry from system settings instead
+// of using DefaultInvitationExpiryDays.
+func WithInvitationSettings(settings *SettingsService) InvitationOption {
+	return func(s *InvitationService) { s.settings = settings }
+}
+
+func NewInvitationService(invites store.InvitationStore, users *UserService, mailer Mailer, opts ...InvitationOption) *InvitationService {
+	s := &InvitationService{invites: invites, users: users, mailer: mailer, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *InvitationService) ttl(ctx context.Context) (time.Duration, error) {
+	days := DefaultInvitationExpiryDays
+	if s.settings != nil {
+		n, err := s.settings.Int(ctx, SettingInvitationExpiryDays)
+		if err != nil {
+			return 0, err
+		}
+		days = n
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
 }
 
-func NewInvitationService(invites store.InvitationStore, users *UserService, mailer Mailer) *InvitationService {
-	return &InvitationService{invites: invites, users: users, mailer: mailer, ttl: DefaultInvitationTTL, now: time.Now}
+// newToken returns a raw invitation token and the hash stored for it.
+func newToken() (string, string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(tokenBytes)
+	return token, hashToken(token), nil
 }
 
 // EmailEnabled reports whether invitations are also delivered by email.
@@ -48,34 +97,67 @@ func (s *InvitationService) EmailEnabled() bool {
 // Create issues an invitation. It returns the stored record and the raw token,
 // which appears only here (in the acceptURL link) and as a stored hash.
 func (s *InvitationService) Create(ctx context.Context, email string, role models.Role, inviterID, acceptURL string) (models.Invitation, string, bool, error) {
-	tokenBytes := make([]byte, 32)
-	if _, err := rand.Read(tokenBytes); err != nil {
+	ttl, err := s.ttl(ctx)
+	if err != nil {
+		return models.Invitation{}, "", false, err
+	}
+	token, hash, err := newToken()
+	if err != nil {
 		return models.Invitation{}, "", false, err
 	}
-	token := base64.RawURLEncoding.EncodeToString(tokenBytes)
 
 	now := s.now()
 	inv := models.Invitation{
 		ID:        uuid.NewString(),
 		Email:     email,
 		Role:      role,
-		TokenHash: hashToken(token),
+		TokenHash: &hash,
 		Status:    models.InvitePending,
 		InvitedBy: inviterID,
 		CreatedAt: now,
-		ExpiresAt: now.Add(s.ttl),
+		ExpiresAt: now.Add(ttl),
 	}
 	if err := s.invites.Create(ctx, &inv); err != nil {
 		return models.Invitation{}, "", false, err
 	}
+	return inv, token, s.send(inv, token, acceptURL), nil
+}
 
-	emailSent := false
-	if s.mailer != nil && s.mailer.Enabled() {
-		body := fmt.Sprintf("You've been invited to ShellCN.\n\nAccept and set your password:\n%s%s\n\nThis link expires %s.",
-			acceptURL, token, inv.ExpiresAt.Format(time.RFC1123))
-		emailSent = s.mailer.Send(email, "You're invited to ShellCN", body) == nil
+// Resend issues a fresh link for a pending or expired invitation with a new
+// expiry. The previous link stops working at once.
+func (s *InvitationService) Resend(ctx context.Context, id, acceptURL string) (models.Invitation, string, bool, error) {
+	inv, err := s.invites.Get(ctx, id)
+	if err != nil {
+		return models.Invitation{}, "", false, err
+	}
+	if inv.Status != models.InvitePending && inv.Status != models.InviteExpired {
+		return models.Invitation{}, "", false, fmt.Errorf("%w: invitation is %s", models.ErrConflict, inv.Status)
+	}
+	ttl, err := s.ttl(ctx)
+	if err != nil {
+		return models.Invitation{}, "", false, err
+	}
+	token, hash, err := newToken()
+	if err != nil {
+		return models.Invitation{}, "", false, err
+	}
+	inv.TokenHash = &hash
+	inv.Status = models.InvitePending
+	inv.ExpiresAt = s.now().Add(ttl)
+	if err := s.invites.Update(ctx, &inv); err != nil {
+		return models.Invitation{}, "", false, err
 	}
-	return inv, token, emailSent, nil
+	return inv, token, s.send(inv, token, acceptURL), nil
+}
+
+// send emails the link when a mailer is configured and reports delivery.
+func (s *InvitationService) send(inv models.Invitation, token, acceptURL string) bool {
+	if s.mailer == nil || !s.mailer.Enabled() {
+		return false
+	}
+	body := fmt.Sprintf("You've been invited to ShellCN.\n\nAccept and set your password:\n%s%s\n\nThis link expires %s.",
+		acceptURL, token, inv.ExpiresAt.Format(time.RFC1123))
+	return s.mailer.Send(inv.Email, "You're invited to ShellCN", body) == nil
 }
 
 func (s *InvitationService) List(ctx context.Context) ([]models.InvitationSummary, error) {
@@ -90,23 +172,40 @@ func (s *InvitationService) List(ctx context.Context) ([]models.InvitationSummar
 	return out, nil
 }
 
+// Revoke invalidates an invitation's link. An accepted invitation cannot be
+// revoked; revoking twice is a no-op.
 func (s *InvitationService) Revoke(ctx context.Context, id string) error {
 	inv, err := s.invites.Get(ctx, id)
 	if err != nil {
 		return err
 	}
+	switch inv.Status {
+	case models.InviteAccepted:
+		return fmt.Errorf("%w: invitation was already accepted", models.ErrConflict)
+	case models.InviteRevoked:
+		return nil
+	}
 	inv.Status = models.InviteRevoked
+	inv.TokenHash = nil
 	return s.invites.Update(ctx, &inv)
 }
 
+// ExpireLapsed marks lapsed pending invitations expired and drops their token
+// hashes, so a leaked link cannot be matched even by the database.
+func (s *InvitationService) ExpireLapsed(ctx context.Context) (int, error) {
+	return s.invites.ExpirePending(ctx, s.now())
+}
+
 // Lookup validates a raw token and returns the pending invitation behind it.
+// Only a lapsed pending invitation is reported as expired; every other
+// failure is ErrInvitationInvalid.
 func (s *InvitationService) Lookup(ctx context.Context, token string) (models.Invitation, error) {
 	inv, err := s.invites.GetByTokenHash(ctx, hashToken(token))
-	if err != nil {
+	if err != nil || inv.Status != models.InvitePending {
 		return models.Invitation{}, ErrInvitationInvalid
 	}
-	if inv.Status != models.InvitePending || s.now().After(inv.ExpiresAt) {
-		return models.Invitation{}, ErrInvitationInvalid
+	if !s.now().Before(inv.ExpiresAt) {
+		return models.Invitation{}, ErrInvitationExpired
 	}
 	return inv, nil
 }
@@ -130,12 +229,25 @@ func (s *InvitationService) Accept(ctx context.Context, token, username, passwor
 	}
 	consumed, err := s.invites.Consume(ctx, inv.ID, now)
 	if err != nil {
-		_ = s.users.Delete(ctx, user.ID)
+		_ = s.users.Delete(ctx, user.ID, UserDeleteOptions{})
 		return models.User{}, err
 	}
 	if !consumed {
-		_ = s.users.Delete(ctx, user.ID)
+		_ = s.users.Delete(ctx, user.ID, UserDeleteOptions{})
 		return models.User{}, ErrInvitationInvalid
 	}
 	return user, nil
 }
+
+func invitationExpirySetting() SettingDef {
+	return SettingDef{
+		Key: SettingInvitationExpiryDays, Type: SettingInt, Default: strconv.Itoa(DefaultInvitationExpiryDays),
+		Description: "Days a new or resent invitation link stays valid.",
+		Validate: func(v string) error {
+			if n, err := strconv.Atoi(v); err != nil || n < 1 || n > maxInvitationExpiryDays {
+				return fmt.Errorf("must be between 1 and %d days", maxInvitationExpiryDays)
+			}
+			return nil
+		},
+	}
+}
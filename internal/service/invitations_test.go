@@ -2,8 +2,10 @@ package service_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/charlesng35/shellcn/internal/models"
 	"github.com/charlesng35/shellcn/internal/service"
@@ -117,6 +119,9 @@ func TestInvitationRevoke(t *testing.T) {
 	if _, err := inv.Lookup(ctx, token); !errors.Is(err, service.ErrInvitationInvalid) {
 		t.Errorf("revoked invite: want ErrInvitationInvalid, got %v", err)
 	}
+	if err := inv.Revoke(ctx, rec.ID); err != nil {
+		t.Errorf("second revoke should be a no-op: %v", err)
+	}
 }
 
 func TestInvitationMailerOptional(t *testing.T) {
@@ -137,3 +142,133 @@ func TestInvitationMailerOptional(t *testing.T) {
 		t.Error("token (for the copyable link) must be returned even without email")
 	}
 }
+
+// lapse backdates an invitation's expiry so it reads as expired.
+func lapse(t *testing.T, st *store.Store, id string) {
+	t.Helper()
+	ctx := context.Background()
+	rec, err := st.Invitations.Get(ctx, id)
+	if err != nil {
+		t.Fatalf("get invite: %v", err)
+	}
+	rec.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := st.Invitations.Update(ctx, &rec); err != nil {
+		t.Fatalf("backdate invite: %v", err)
+	}
+}
+
+func TestInvitationExpiry(t *testing.T) {
+	ctx := context.Background()
+	inv, st, _ := newInvitationService(false)
+
+	rec, token, _, err := inv.Create(ctx, "late@example.com", models.RoleViewer, "admin", "https://host/invite/")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if want := time.Duration(service.DefaultInvitationExpiryDays) * 24 * time.Hour; rec.ExpiresAt.Sub(rec.CreatedAt) != want {
+		t.Errorf("default expiry = %s, want %s", rec.ExpiresAt.Sub(rec.CreatedAt), want)
+	}
+	lapse(t, st, rec.ID)
+	if _, err := inv.Accept(ctx, token, "late", "s3cret-pw"); !errors.Is(err, service.ErrInvitationExpired) {
+		t.Fatalf("expired accept: want ErrInvitationExpired, got %v", err)
+	}
+	if _, err := st.Users.GetByUsername(ctx, "late"); err == nil {
+		t.Error("expired invite must not create an account")
+	}
+
+	n, err := inv.ExpireLapsed(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("expire lapsed: n=%d err=%v", n, err)
+	}
+	stored, _ := st.Invitations.Get(ctx, rec.ID)
+	if stored.Status != models.InviteExpired || stored.TokenHash != nil {
+		t.Errorf("swept invite: status=%s hash=%v", stored.Status, stored.TokenHash)
+	}
+	// Once the hash is purged the link is indistinguishable from a bogus one.
+	if _, err := inv.Lookup(ctx, token); !errors.Is(err, service.ErrInvitationInvalid) {
+		t.Errorf("purged invite: want ErrInvitationInvalid, got %v", err)
+	}
+}
+
+func TestInvitationExpiryFromSettings(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	settings := service.NewSettingsService(st.SystemSettings)
+	settings.Register(service.BuiltinSettings()...)
+	admin := models.User{ID: "admin"}
+	if err := settings.Update(ctx, admin, map[string]json.RawMessage{service.SettingInvitationExpiryDays: json.RawMessage("2")}); err != nil {
+		t.Fatalf("set expiry: %v", err)
+	}
+	if err := settings.Update(ctx, admin, map[string]json.RawMessage{service.SettingInvitationExpiryDays: json.RawMessage("365")}); err == nil {
+		t.Error("expiry beyond the maximum should be rejected")
+	}
+	inv := service.NewInvitationService(st.Invitations, service.NewUserService(st.Users), nil, service.WithInvitationSettings(settings))
+
+	rec, _, _, err := inv.Create(ctx, "short@example.com", models.RoleViewer, "admin", "https://host/invite/")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if got := rec.ExpiresAt.Sub(rec.CreatedAt); got != 48*time.Hour {
+		t.Errorf("expiry = %s, want 48h", got)
+	}
+}
+
+func TestInvitationResendInvalidatesPreviousToken(t *testing.T) {
+	ctx := context.Background()
+	inv, st, mailer := newInvitationService(true)
+
+	rec, first, _, err := inv.Create(ctx, "again@example.com", models.RoleViewer, "admin", "https://host/invite/")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	lapse(t, st, rec.ID)
+	if _, err := inv.ExpireLapsed(ctx); err != nil {
+		t.Fatalf("expire lapsed: %v", err)
+	}
+
+	resent, second, sent, err := inv.Resend(ctx, rec.ID, "https://host/invite/")
+	if err != nil {
+		t.Fatalf("resend: %v", err)
+	}
+	if !sent || mailer.sent != 2 {
+		t.Errorf("resend should email the new link: sent=%v count=%d", sent, mailer.sent)
+	}
+	if resent.Status != models.InvitePending || !resent.ExpiresAt.After(time.Now()) {
+		t.Errorf("resent invite: status=%s expires=%s", resent.Status, resent.ExpiresAt)
+	}
+	_, third, _, err := inv.Resend(ctx, rec.ID, "https://host/invite/")
+	if err != nil {
+		t.Fatalf("second resend: %v", err)
+	}
+	for _, old := range []string{first, second} {
+		if _, err := inv.Lookup(ctx, old); !errors.Is(err, service.ErrInvitationInvalid) {
+			t.Errorf("superseded token: want ErrInvitationInvalid, got %v", err)
+		}
+	}
+	if _, err := inv.Accept(ctx, third, "again", "s3cret-pw"); err != nil {
+		t.Fatalf("newest token should work: %v", err)
+	}
+	if _, _, _, err := inv.Resend(ctx, rec.ID, "https://host/invite/"); !errors.Is(err, models.ErrConflict) {
+		t.Errorf("resend accepted invite: want conflict, got %v", err)
+	}
+}
+
+func TestInvitationRevokeAccepted(t *testing.T) {
+	ctx := context.Background()
+	inv, st, _ := newInvitationService(false)
+
+	rec, token, _, err := inv.Create(ctx, "done@example.com", models.RoleViewer, "admin", "https://host/invite/")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := inv.Accept(ctx, token, "done", "s3cret-pw"); err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	if err := inv.Revoke(ctx, rec.ID); !errors.Is(err, models.ErrConflict) {
+		t.Errorf("revoke accepted invite: want conflict, got %v", err)
+	}
+	stored, _ := st.Invitations.Get(ctx, rec.ID)
+	if stored.Status != models.InviteAccepted || stored.Summary().AcceptedAt == nil {
+		t.Errorf("accepted invite summary: %+v", stored.Summary())
+	}
+}
@@ -0,0 +1,392 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/recording"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// DefaultJobLinkTTL is how long a job result download link stays valid.
+const DefaultJobLinkTTL = 15 * time.Minute
+
+const (
+	defaultJobWorkers     = 2
+	defaultJobQueue       = 64
+	defaultJobInlineLimit = 1 << 20
+	defaultJobRetention   = 24 * time.Hour
+
+	jobInterrupted = "interrupted by server shutdown"
+)
+
+// JobType is a registered kind of background job. Run streams the result to w
+// and reports progress as a percentage; it must stop when ctx is cancelled.
+type JobType struct {
+	ContentType string
+	FileName    string
+	Run         func(ctx context.Context, job models.Job, progress func(int), w io.Writer) error
+}
+
+// JobServiceOption configures a JobService.
+type JobServiceOption func(*JobService)
+
+// WithJobLogger sets the logger for job failures.
+func WithJobLogger(l *slog.Logger) JobServiceOption {
+	return func(s *JobService) { s.logger = l }
+}
+
+// WithJobInlineLimit sets the result size above which output is written to
+// blob storage instead of the job row.
+func WithJobInlineLimit(n int) JobServiceOption {
+	return func(s *JobService) { s.inlineLimit = n }
+}
+
+// WithJobRetention sets how long a finished job and its result are kept.
+func WithJobRetention(d time.Duration) JobServiceOption {
+	return func(s *JobService) { s.retention = d }
+}
+
+// JobService runs long-running work outside the request that asked for it.
+// Jobs are persisted, executed by a bounded worker pool, and observable by
+// polling or by subscribing to the requesting user's event stream.
+type JobService struct {
+	jobs        store.JobStore
+	blobs       recording.BlobStore
+	logger      *slog.Logger
+	now         func() time.Time
+	inlineLimit int
+	retention   time.Duration
+	types       map[string]JobType
+
+	queue  chan string
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	once   sync.Once
+
+	mu      sync.Mutex
+	running map[string]*runningJob
+
+	events userHub[models.Job]
+}
+
+type runningJob struct {
+	cancel   context.CancelFunc
+	canceled bool
+}
+
+// NewJobService builds the service; register job types, then call Start.
+// blobs may be nil, in which case results over the inline limit fail the job.
+func NewJobService(jobs store.JobStore, blobs recording.BlobStore, opts ...JobServiceOption) *JobService {
+	s := &JobService{
+		jobs: jobs, blobs: blobs,
+		logger:      slog.Default(),
+		now:         time.Now,
+		inlineLimit: defaultJobInlineLimit,
+		retention:   defaultJobRetention,
+		types:       map[string]JobType{},
+		queue:       make(chan string, defaultJobQueue),
+		running:     map[string]*runningJob{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+// Register adds a job type. It must be called before Start.
+func (s *JobService) Register(name string, t JobType) {
+	s.types[name] = t
+}
+
+// Start fails jobs left queued or running by a previous process, then starts
+// the workers; call Close to stop them.
+func (s *JobService) Start(ctx context.Context) error {
+	stale, err := s.jobs.ListByStatus(ctx, models.JobQueued, models.JobRunning)
+	if err != nil {
+		return err
+	}
+	for _, j := range stale {
+		s.finish(&j, models.JobFailed, jobInterrupted)
+	}
+	for range defaultJobWorkers {
+		s.wg.Add(1)
+		go s.worker()
+	}
+	return nil
+}
+
+// Close cancels running jobs and stops the workers. Cancelled jobs are marked
+// failed as interrupted; queued jobs are failed on the next Start.
+func (s *JobService) Close() {
+	s.once.Do(func() {
+		s.cancel()
+		s.wg.Wait()
+	})
+}
+
+// Submit persists a queued job of the given type and hands it to a worker.
+func (s *JobService) Submit(ctx context.Context, userID, typ string, params map[string]string) (models.Job, error) {
+	if _, ok := s.types[typ]; !ok {
+		return models.Job{}, fmt.Errorf("%w: unknown job type %q", plugin.ErrInvalidInput, typ)
+	}
+	now := s.now()
+	j := models.Job{ID: uuid.NewString(), Type: typ, Status: models.JobQueued, RequestedBy: userID, Params: params, CreatedAt: now, UpdatedAt: now}
+	if err := s.jobs.Create(ctx, &j); err != nil {
+		return models.Job{}, err
+	}
+	s.publish(j)
+	select {
+	case s.queue <- j.ID:
+	default:
+		s.finish(&j, models.JobFailed, "job queue is full")
+		return models.Job{}, fmt.Errorf("%w: too many jobs are queued; try again later", plugin.ErrUnavailable)
+	}
+	return j, nil
+}
+
+// Get returns a job owned by userID. Other users' jobs are reported as not
+// found so their existence is not revealed.
+func (s *JobService) Get(ctx context.Context, userID, id string) (models.Job, error) {
+	j, err := s.jobs.Get(ctx, id)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && j.RequestedBy != userID) {
+		return models.Job{}, fmt.Errorf("%w: job not found", plugin.ErrNotFound)
+	}
+	return j, err
+}
+
+// Cancel stops a queued or running job. A queued job is canceled at once; a
+// running one is signalled and reaches the canceled state when its handler
+// returns.
+func (s *JobService) Cancel(ctx context.Context, userID, id string) (models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if j.Status.Done() {
+		return models.Job{}, fmt.Errorf("%w: job has already finished", plugin.ErrConflict)
+	}
+	if r, ok := s.running[id]; ok {
+		r.canceled = true
+		r.cancel()
+		return j, nil
+	}
+	s.finish(&j, models.JobCanceled, "")
+	return j, nil
+}
+
+// OpenResult opens a succeeded job's output. Callers authorize the read.
+func (s *JobService) OpenResult(ctx context.Context, id string) (models.Job, io.ReadCloser, error) {
+	j, err := s.jobs.Get(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return models.Job{}, nil, fmt.Errorf("%w: job not found", plugin.ErrNotFound)
+	}
+	if err != nil {
+		return models.Job{}, nil, err
+	}
+	if j.Status != models.JobSucceeded {
+		return models.Job{}, nil, fmt.Errorf("%w: job has no result", plugin.ErrConflict)
+	}
+	if j.ResultKey == "" {
+		return j, io.NopCloser(bytes.NewReader(j.Result)), nil
+	}
+	if s.blobs == nil {
+		return models.Job{}, nil, fmt.Errorf("%w: job result storage is not configured", plugin.ErrUnavailable)
+	}
+	rc, err := s.blobs.Open(ctx, j.ResultKey)
+	if err != nil {
+		return models.Job{}, nil, err
+	}
+	return j, rc, nil
+}
+
+// Subscribe streams updates to userID's jobs until cancel is called. A slow
+// subscriber loses its oldest updates rather than blocking the workers.
+func (s *JobService) Subscribe(userID string) (Feed[models.Job], func()) {
+	return s.events.subscribe(userID)
+}
+
+// Purge deletes finished jobs past their retention along with their results.
+func (s *JobService) Purge(ctx context.Context, now time.Time) (int, error) {
+	expired, err := s.jobs.ListExpired(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, j := range expired {
+		if j.ResultKey != "" && s.blobs != nil {
+			if err := s.blobs.Delete(ctx, j.ResultKey); err != nil {
+				return n, err
+			}
+		}
+		if err := s.jobs.Delete(ctx, j.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (s *JobService) worker() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case id := <-s.queue:
+			s.run(id)
+		}
+	}
+}
+
+func (s *JobService) run(id string) {
+	bg := context.WithoutCancel(s.ctx)
+	s.mu.Lock()
+	j, err := s.jobs.Get(bg, id)
+	if err != nil || j.Status != models.JobQueued {
+		s.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	r := &runningJob{cancel: cancel}
+	s.running[id] = r
+	now := s.now()
+	j.Status, j.StartedAt, j.UpdatedAt = models.JobRunning, &now, now
+	err = s.jobs.Update(bg, &j)
+	s.mu.Unlock()
+	if err != nil {
+		s.logger.Warn("job start failed", "job", id, "error", err)
+	}
+	s.publish(j)
+
+	t := s.types[j.Type]
+	out := &jobOutput{s: s, ctx: bg, key: "jobs/" + j.ID}
+	progress := func(p int) {
+		p = min(max(p, 0), 99)
+		if p <= j.Progress {
+			return
+		}
+		j.Progress, j.UpdatedAt = p, s.now()
+		if err := s.jobs.Update(bg, &j); err != nil {
+			s.logger.Warn("job progress update failed", "job", id, "error", err)
+		}
+		s.publish(j)
+	}
+	runErr := t.Run(ctx, j, progress, out)
+	if closeErr := out.close(); runErr == nil {
+		runErr = closeErr
+	}
+
+	s.mu.Lock()
+	delete(s.running, id)
+	canceled := r.canceled
+	s.mu.Unlock()
+
+	switch {
+	case runErr == nil:
+		j.ContentType, j.FileName = t.ContentType, t.FileName
+		j.Result, j.ResultKey, j.ResultSize = out.inline(), out.blobKey(), out.size
+		j.Progress = 100
+		s.finish(&j, models.JobSucceeded, "")
+		return
+	case canceled:
+		s.finish(&j, models.JobCanceled, "")
+	case s.ctx.Err() != nil:
+		s.finish(&j, models.JobFailed, jobInterrupted)
+	default:
+		s.logger.Warn("job failed", "job", id, "type", j.Type, "error", runErr)
+		s.finish(&j, models.JobFailed, runErr.Error())
+	}
+	if key := out.blobKey(); key != "" {
+		_ = s.blobs.Delete(bg, key)
+	}
+}
+
+// finish records a terminal status and starts the retention clock.
+func (s *JobService) finish(j *models.Job, status models.JobStatus, msg string) {
+	now := s.now()
+	expires := now.Add(s.retention)
+	j.Status, j.Error, j.FinishedAt, j.ExpiresAt, j.UpdatedAt = status, msg, &now, &expires, now
+	if err := s.jobs.Update(context.WithoutCancel(s.ctx), j); err != nil {
+		s.logger.Warn("job update failed", "job", j.ID, "error", err)
+	}
+	s.publish(*j)
+}
+
+func (s *JobService) publish(j models.Job) {
+	j.Result = nil
+	s.events.publish(j.RequestedBy, j)
+}
+
+// jobOutput buffers a job's result in memory and moves it to blob storage
+// once it outgrows the inline limit.
+type jobOutput struct {
+	s    *JobService
+	ctx  context.Context
+	key  string
+	buf  bytes.Buffer
+	blob io.WriteCloser
+	size int64
+}
+
+func (o *jobOutput) Write(p []byte) (int, error) {
+	if o.blob == nil && o.buf.Len()+len(p) > o.s.inlineLimit {
+		if o.s.blobs == nil {
+			return 0, fmt.Errorf("%w: job result exceeds %d bytes and no result storage is configured", plugin.ErrUnavailable, o.s.inlineLimit)
+		}
+		w, err := o.s.blobs.Create(o.ctx, o.key)
+		if err != nil {
+			return 0, err
+		}
+		o.blob = w
+		if _, err := o.blob.Write(o.buf.Bytes()); err != nil {
+			return 0, err
+		}
+		o.buf.Reset()
+	}
+	var n int
+	var err error
+	if o.blob != nil {
+		n, err = o.blob.Write(p)
+	} else {
+		n, err = o.buf.Write(p)
+	}
+	o.size += int64(n)
+	return n, err
+}
+
+func (o *jobOutput) close() error {
+	if o.blob == nil {
+		return nil
+	}
+	return o.blob.Close()
+}
+
+func (o *jobOutput) inline() []byte {
+	if o.blob != nil {
+		return nil
+	}
+	return bytes.Clone(o.buf.Bytes())
+}
+
+func (o *jobOutput) blobKey() string {
+	if o.blob == nil {
+		return ""
+	}
+	return o.key
+}
@@ -0,0 +1,249 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/recording"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+func newJobSvc(t *testing.T, opts ...service.JobServiceOption) (*service.JobService, *store.Store, recording.BlobStore) {
+	t.Helper()
+	st := store.NewMemory()
+	bs, err := recording.NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("blob store: %v", err)
+	}
+	return service.NewJobService(st.Jobs, bs, opts...), st, bs
+}
+
+func waitJob(t *testing.T, js *service.JobService, userID, id string, want models.JobStatus) models.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		j, err := js.Get(context.Background(), userID, id)
+		if err != nil {
+			t.Fatalf("get job: %v", err)
+		}
+		if j.Status == want {
+			return j
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job status = %s (%s), want %s", j.Status, j.Error, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func readResult(t *testing.T, js *service.JobService, id string) string {
+	t.Helper()
+	_, rc, err := js.OpenResult(context.Background(), id)
+	if err != nil {
+		t.Fatalf("open result: %v", err)
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read result: %v", err)
+	}
+	return string(b)
+}
+
+func TestJobServiceRunsAndReportsProgress(t *testing.T) {
+	ctx := context.Background()
+	js, st, _ := newJobSvc(t, service.WithJobInlineLimit(16))
+	js.Register("small", service.JobType{ContentType: "text/plain", FileName: "small.txt",
+		Run: func(_ context.Context, j models.Job, progress func(int), w io.Writer) error {
+			progress(50)
+			_, err := io.WriteString(w, "hello "+j.Params["who"])
+			return err
+		}})
+	js.Register("large", service.JobType{ContentType: "text/plain",
+		Run: func(_ context.Context, _ models.Job, _ func(int), w io.Writer) error {
+			for i := range 10 {
+				if _, err := fmt.Fprintf(w, "line %d\n", i); err != nil {
+					return err
+				}
+			}
+			return nil
+		}})
+	js.Register("broken", service.JobType{
+		Run: func(context.Context, models.Job, func(int), io.Writer) error { return errors.New("boom") },
+	})
+	if err := js.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(js.Close)
+
+	events, cancel := js.Subscribe("op")
+	defer cancel()
+
+	if _, err := js.Submit(ctx, "op", "missing", nil); !errors.Is(err, plugin.ErrInvalidInput) {
+		t.Fatalf("unknown type err = %v, want invalid input", err)
+	}
+
+	small, err := js.Submit(ctx, "op", "small", map[string]string{"who": "op"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	done := waitJob(t, js, "op", small.ID, models.JobSucceeded)
+	if done.Progress != 100 || done.ResultKey != "" || done.ResultSize != 8 || done.ExpiresAt == nil {
+		t.Fatalf("small job = %+v", done)
+	}
+	if got := readResult(t, js, small.ID); got != "hello op" {
+		t.Fatalf("small result = %q", got)
+	}
+	if _, err := js.Get(ctx, "op2", small.ID); !errors.Is(err, plugin.ErrNotFound) {
+		t.Fatalf("non-owner get err = %v, want not found", err)
+	}
+
+	var seen []models.JobStatus
+	var sawProgress bool
+	for len(seen) == 0 || seen[len(seen)-1] != models.JobSucceeded {
+		select {
+		case j := <-events.C:
+			seen = append(seen, j.Status)
+			sawProgress = sawProgress || j.Progress == 50
+		case <-time.After(5 * time.Second):
+			t.Fatalf("events = %v, want through succeeded", seen)
+		}
+	}
+	if seen[0] != models.JobQueued || !sawProgress {
+		t.Fatalf("events = %v progress=%v", seen, sawProgress)
+	}
+
+	large, err := js.Submit(ctx, "op", "large", nil)
+	if err != nil {
+		t.Fatalf("submit large: %v", err)
+	}
+	done = waitJob(t, js, "op", large.ID, models.JobSucceeded)
+	if done.ResultKey == "" || len(done.Result) != 0 || done.ResultSize != 70 {
+		t.Fatalf("large job = %+v, want spilled to blob storage", done)
+	}
+	if got := readResult(t, js, large.ID); !strings.HasPrefix(got, "line 0\n") || len(got) != 70 {
+		t.Fatalf("large result = %q", got)
+	}
+
+	broken, err := js.Submit(ctx, "op", "broken", nil)
+	if err != nil {
+		t.Fatalf("submit broken: %v", err)
+	}
+	if j := waitJob(t, js, "op", broken.ID, models.JobFailed); j.Error != "boom" {
+		t.Fatalf("broken error = %q", j.Error)
+	}
+	if _, _, err := js.OpenResult(ctx, broken.ID); !errors.Is(err, plugin.ErrConflict) {
+		t.Fatalf("failed job result err = %v, want conflict", err)
+	}
+
+	n, err := js.Purge(ctx, time.Now().Add(48*time.Hour))
+	if err != nil || n != 3 {
+		t.Fatalf("purge = %d, %v; want 3", n, err)
+	}
+	if _, err := st.Jobs.Get(ctx, large.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("purged job err = %v", err)
+	}
+}
+
+func TestJobServiceCancel(t *testing.T) {
+	ctx := context.Background()
+	js, _, _ := newJobSvc(t)
+	started := make(chan struct{})
+	js.Register("wait", service.JobType{
+		Run: func(ctx context.Context, _ models.Job, _ func(int), _ io.Writer) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}})
+	if err := js.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(js.Close)
+
+	j, err := js.Submit(ctx, "op", "wait", nil)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	<-started
+	if _, err := js.Cancel(ctx, "op2", j.ID); !errors.Is(err, plugin.ErrNotFound) {
+		t.Fatalf("non-owner cancel err = %v, want not found", err)
+	}
+	if _, err := js.Cancel(ctx, "op", j.ID); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	waitJob(t, js, "op", j.ID, models.JobCanceled)
+	if _, err := js.Cancel(ctx, "op", j.ID); !errors.Is(err, plugin.ErrConflict) {
+		t.Fatalf("second cancel err = %v, want conflict", err)
+	}
+}
+
+func TestJobServiceStartFailsInterruptedJobs(t *testing.T) {
+	ctx := context.Background()
+	js, st, _ := newJobSvc(t)
+	stale := models.Job{ID: "stale", Type: "x", Status: models.JobRunning, RequestedBy: "op", CreatedAt: time.Now()}
+	if err := st.Jobs.Create(ctx, &stale); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	if err := js.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(js.Close)
+	got, err := js.Get(ctx, "op", "stale")
+	if err != nil || got.Status != models.JobFailed || got.Error == "" {
+		t.Fatalf("stale job = %+v, %v", got, err)
+	}
+}
+
+func TestAuditExportJob(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		e := models.AuditEntry{ID: fmt.Sprintf("a%d", i), Time: base.Add(time.Duration(i) * time.Hour), UserID: "op", Event: "x", Result: models.AuditAllowed}
+		if i == 4 {
+			e.UserID = "admin"
+		}
+		if err := st.Audit.Append(ctx, &e); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	job := models.Job{
+		CreatedAt: base.Add(24 * time.Hour),
+		Params:    service.AuditExportParams(store.AuditFilter{UserID: "op", Since: base.Add(time.Hour)}),
+	}
+	var buf bytes.Buffer
+	var last int
+	if err := service.AuditExportJob(st.Audit).Run(ctx, job, func(p int) { last = p }, &buf); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], `"id":"a3"`) || last != 100 {
+		t.Fatalf("export = %q (progress %d), want a3..a1", buf.String(), last)
+	}
+
+	if err := st.Audit.SetResource(ctx, "a2", "connection", "c1"); err != nil {
+		t.Fatalf("set resource: %v", err)
+	}
+	job.Params = service.AuditExportParams(store.AuditFilter{ResourceType: "connection", ResourceID: "c1"})
+	buf.Reset()
+	if err := service.AuditExportJob(st.Audit).Run(ctx, job, func(int) {}, &buf); err != nil {
+		t.Fatalf("run by resource: %v", err)
+	}
+	if out := strings.TrimSpace(buf.String()); strings.Count(out, "\n") != 0 || !strings.Contains(out, `"resourceId":"c1"`) {
+		t.Fatalf("export by resource = %q, want only a2", out)
+	}
+
+	job.Params = map[string]string{"since": "yesterday"}
+	if err := service.AuditExportJob(st.Audit).Run(ctx, job, func(int) {}, io.Discard); !errors.Is(err, plugin.ErrInvalidInput) {
+		t.Fatalf("bad since err = %v, want invalid input", err)
+	}
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// MetadataLaunchFailure is the session metadata key holding the category of
+// a failed connect, so the session status can show it.
+const MetadataLaunchFailure = "launch_failure"
+
+// LaunchError is a classified failure to open an upstream session. Error
+// keeps the driver's text for logs; Message is what the user is shown.
+type LaunchError struct {
+	Category plugin.FailureCategory
+	Err      error
+}
+
+func (e *LaunchError) Error() string { return e.Err.Error() }
+
+func (e *LaunchError) Unwrap() error { return e.Err }
+
+// Message tells the user what to check for the failure's category.
+func (e *LaunchError) Message() string {
+	switch e.Category {
+	case plugin.FailureAuth:
+		return "The server rejected the credentials. Check the username and the password or key for this connection."
+	case plugin.FailureUnreachable:
+		return "The host could not be reached. Check the host name and port, and that the server is running."
+	case plugin.FailureTimeout:
+		return "The server did not answer in time. Check the host and port, and any firewall in between."
+	case plugin.FailureHostKey:
+		return "The server's identity does not match the pinned host key or certificate. Verify the server before updating the connection."
+	case plugin.FailureProtocol:
+		return "The server did not speak the expected protocol. Check the port and the protocol settings."
+	case plugin.FailureQuota:
+		return "The server is refusing more connections. Close other sessions or try again later."
+	}
+	return e.Err.Error()
+}
+
+// ClassifyLaunchError wraps a plugin Connect error in a LaunchError when its
+// category can be told, and returns it unchanged otherwise.
+func ClassifyLaunchError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if c := launchFailureCategory(err); c != "" {
+		return &LaunchError{Category: c, Err: err}
+	}
+	return err
+}
+
+type launchPattern struct {
+	category  plugin.FailureCategory
+	fragments []string
+}
+
+// launchPatterns match the error text of drivers that return plain errors,
+// lowercased. Earlier entries win: an SSH host key failure also reads
+// "handshake failed", and a refused login may mention a timeout. They are
+// checked before the error's type, since a host key or login refusal arrives
+// wrapped in a network error on some drivers.
+var launchPatterns = []launchPattern{
+	{plugin.FailureHostKey, []string{
+		"host key mismatch", "host key fingerprint mismatch", "knownhosts: key mismatch",
+		"x509: certificate", "tls: failed to verify certificate",
+	}},
+	{plugin.FailureQuota, []string{
+		"too many connections", "too many clients", "max number of clients", "maxclients",
+		"connection limit", "quota exceeded", "rate limit", "error 1040", "sqlstate 53300",
+		"421 too many", "status_insufficient_resources",
+	}},
+	{plugin.FailureAuth, []string{
+		"unable to authenticate", "authentication failed", "password authentication failed",
+		"access denied for user", "wrongpass", "noauth", "invalid credentials", "result code 49",
+		"530 login", "login incorrect", "logon failure", "status_logon_failure", "logon is invalid",
+		"unauthorized", "invalidaccesskeyid", "signaturedoesnotmatch", "permission denied (publickey",
+		"sqlstate 28p01", "sqlstate 28000", "authentication failure", "no ticket",
+	}},
+}
+
+// launchFallbackPatterns are checked after the error's type.
+var launchFallbackPatterns = []launchPattern{
+	{plugin.FailureTimeout, []string{"i/o timeout", "deadline exceeded", "timed out", "timeout"}},
+	{plugin.FailureUnreachable, []string{
+		"connection refused", "no route to host", "network is unreachable", "no such host",
+		"host is down", "server misbehaving",
+	}},
+	{plugin.FailureProtocol, []string{
+		"first record does not look like a tls handshake", "wrong version number", "malformed http",
+		"unexpected packet", "protocol version", "ssh: handshake failed: eof", "unsupported protocol",
+		"invalid packet", "bad handshake", "unexpected response", "unknown rfb",
+	}},
+}
+
+func launchFailureCategory(err error) plugin.FailureCategory {
+	var ce *plugin.ConnectError
+	if errors.As(err, &ce) {
+		return ce.Category
+	}
+	msg := strings.ToLower(err.Error())
+	if c := matchLaunchPatterns(msg, launchPatterns); c != "" {
+		return c
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return plugin.FailureTimeout
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return plugin.FailureUnreachable
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return plugin.FailureUnreachable
+	}
+	if c := matchLaunchPatterns(msg, launchFallbackPatterns); c != "" {
+		return c
+	}
+	if errors.Is(err, plugin.ErrUnauthorized) {
+		return plugin.FailureAuth
+	}
+	return ""
+}
+
+func matchLaunchPatterns(msg string, patterns []launchPattern) plugin.FailureCategory {
+	for _, p := range patterns {
+		for _, f := range p.fragments {
+			if strings.Contains(msg, f) {
+				return p.category
+			}
+		}
+	}
+	return ""
+}
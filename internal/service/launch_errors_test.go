@@ -0,0 +1,131 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+func launchCategory(err error) plugin.FailureCategory {
+	var le *service.LaunchError
+	if errors.As(service.ClassifyLaunchError(err), &le) {
+		return le.Category
+	}
+	return ""
+}
+
+func TestClassifyLaunchErrorDriverText(t *testing.T) {
+	cases := map[string][]struct {
+		msg  string
+		want plugin.FailureCategory
+	}{
+		"ssh": {
+			{"ssh: handshake failed: ssh: unable to authenticate, attempted methods [none publickey], no supported methods remain", plugin.FailureAuth},
+			{"ssh: handshake failed: ssh: host key mismatch", plugin.FailureHostKey},
+			{"ssh: handshake failed: EOF", plugin.FailureProtocol},
+			{"dial tcp 10.0.0.5:22: connect: connection refused", plugin.FailureUnreachable},
+			{"dial tcp 10.0.0.5:22: i/o timeout", plugin.FailureTimeout},
+			{"dial tcp: lookup bastion.internal: no such host", plugin.FailureUnreachable},
+		},
+		"postgresql": {
+			{`failed to connect to host=db user=app database=app: server error (FATAL: password authentication failed for user "app" (SQLSTATE 28P01))`, plugin.FailureAuth},
+			{"failed to connect to host=db user=app database=app: server error (FATAL: sorry, too many clients already (SQLSTATE 53300))", plugin.FailureQuota},
+			{"failed to connect to host=db user=app database=app: dial error (dial tcp 10.0.0.7:5432: connect: no route to host)", plugin.FailureUnreachable},
+			{"failed to connect to host=db: tls error (x509: certificate signed by unknown authority)", plugin.FailureHostKey},
+		},
+		"mysql": {
+			{"Error 1045 (28000): Access denied for user 'app'@'10.0.0.2' (using password: YES)", plugin.FailureAuth},
+			{"Error 1040: Too many connections", plugin.FailureQuota},
+			{"dial tcp 10.0.0.8:3306: connect: connection refused", plugin.FailureUnreachable},
+		},
+		"redis": {
+			{"WRONGPASS invalid username-password pair or user is disabled.", plugin.FailureAuth},
+			{"NOAUTH Authentication required.", plugin.FailureAuth},
+			{"ERR max number of clients reached", plugin.FailureQuota},
+		},
+		"mongodb": {
+			{`connection() error occurred during connection handshake: auth error: sasl conversation error: unable to authenticate using mechanism "SCRAM-SHA-256": (AuthenticationFailed) Authentication failed.`, plugin.FailureAuth},
+			{"server selection error: context deadline exceeded, current topology: { Type: Unknown }", plugin.FailureTimeout},
+		},
+		"ldap": {
+			{`LDAP Result Code 49 "Invalid Credentials": 80090308: LdapErr: DSID-0C090447`, plugin.FailureAuth},
+			{`LDAP Result Code 200 "Network Error": dial tcp 10.0.0.3:636: connect: connection refused`, plugin.FailureUnreachable},
+		},
+		"ftp": {
+			{"530 Login incorrect.", plugin.FailureAuth},
+			{"421 Too many users, sorry.", plugin.FailureQuota},
+		},
+		"smb": {
+			{"response error: The attempted logon is invalid. This is either due to a bad username or authentication information.", plugin.FailureAuth},
+			{"STATUS_LOGON_FAILURE", plugin.FailureAuth},
+		},
+		"rdp": {
+			{"rdp: NLA authentication failed", plugin.FailureAuth},
+			{"rdp: dial tcp 10.0.0.4:3389: i/o timeout", plugin.FailureTimeout},
+		},
+		"vnc": {
+			{"vnc: security handshake failed: authentication failed", plugin.FailureAuth},
+			{"vnc: unknown RFB protocol version", plugin.FailureProtocol},
+		},
+		"kubernetes": {
+			{"Unauthorized", plugin.FailureAuth},
+			{`Get "https://k8s:6443/version": tls: failed to verify certificate: x509: certificate is valid for kubernetes, not k8s`, plugin.FailureHostKey},
+		},
+		"docker": {
+			{"Cannot connect to the Docker daemon at tcp://10.0.0.6:2376. Is the docker daemon running?: dial tcp 10.0.0.6:2376: connect: connection refused", plugin.FailureUnreachable},
+			{`Get "https://10.0.0.6:2376/_ping": http: server gave HTTP response to HTTPS client; tls: first record does not look like a TLS handshake`, plugin.FailureProtocol},
+		},
+		"s3": {
+			{"operation error S3: ListBuckets, https response error StatusCode: 403, api error InvalidAccessKeyId: The AWS Access Key Id you provided does not exist in our records.", plugin.FailureAuth},
+			{"operation error S3: ListBuckets, api error SignatureDoesNotMatch", plugin.FailureAuth},
+			{"operation error S3: ListBuckets, exceeded maximum number of attempts, 3, https response error StatusCode: 503, api error SlowDown: Please reduce your request rate limit", plugin.FailureQuota},
+		},
+		"webdav": {
+			{"PROPFIND /: 401 Unauthorized", plugin.FailureAuth},
+		},
+		"proxmox": {
+			{"proxmox: authentication failure", plugin.FailureAuth},
+			{"proxmox: 401 no ticket", plugin.FailureAuth},
+		},
+	}
+	for driver, list := range cases {
+		for _, tc := range list {
+			if got := launchCategory(errors.New(tc.msg)); got != tc.want {
+				t.Errorf("%s: %q classified %q, want %q", driver, tc.msg, got, tc.want)
+			}
+		}
+	}
+}
+
+func TestClassifyLaunchErrorTypes(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	for _, tc := range []struct {
+		err  error
+		want plugin.FailureCategory
+	}{
+		{refused, plugin.FailureUnreachable},
+		{fmt.Errorf("open session: %w", context.DeadlineExceeded), plugin.FailureTimeout},
+		{&net.DNSError{Err: "server misbehaving", Name: "db.internal"}, plugin.FailureUnreachable},
+		// A plugin's own classification wins over the text.
+		{plugin.ConnectFailed(plugin.FailureQuota, errors.New("connection refused")), plugin.FailureQuota},
+		{fmt.Errorf("%w: ssh host key fingerprint mismatch", plugin.ErrUnauthorized), plugin.FailureHostKey},
+		{plugin.ErrUnauthorized, plugin.FailureAuth},
+	} {
+		if got := launchCategory(tc.err); got != tc.want {
+			t.Errorf("%v classified %q, want %q", tc.err, got, tc.want)
+		}
+	}
+
+	// Anything else is returned as it was.
+	unknown := fmt.Errorf("%w: upstream busy", plugin.ErrUnavailable)
+	if got := service.ClassifyLaunchError(unknown); got != unknown {
+		t.Fatalf("unclassified error changed: %v", got)
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+// SettingReadOnly is the persisted read-only maintenance flag.
+const SettingReadOnly = "system.read_only"
+
+// MaintenanceService owns the read-only maintenance mode: while it is on the
+// HTTP layer refuses mutations and background writers pause. The flag is held
+// in memory so it can be consulted per request and flipped while the database
+// itself is refusing writes.
+type MaintenanceService struct {
+	settings store.SystemSettingStore
+
+	mu       sync.RWMutex
+	readOnly bool
+	watchers []func(readOnly bool)
+}
+
+func NewMaintenanceService(settings store.SystemSettingStore) *MaintenanceService {
+	return &MaintenanceService{settings: settings}
+}
+
+// Load restores the persisted flag at startup; an unset flag means read-write.
+func (s *MaintenanceService) Load(ctx context.Context) error {
+	v, err := s.settings.Get(ctx, SettingReadOnly)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	on, _ := strconv.ParseBool(v.Value)
+	s.apply(on)
+	return nil
+}
+
+// ReadOnly reports whether maintenance mode is on. Safe on a nil receiver.
+func (s *MaintenanceService) ReadOnly() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readOnly
+}
+
+// OnChange registers fn to run after every mode change.
+func (s *MaintenanceService) OnChange(fn func(readOnly bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watchers = append(s.watchers, fn)
+}
+
+// SetReadOnly switches the mode and persists it. Entering read-only takes
+// effect even when persisting fails, since the database may already be
+// refusing writes; leaving it requires a successful write so a database that
+// is still read-only keeps the mode on.
+func (s *MaintenanceService) SetReadOnly(ctx context.Context, actor models.User, on bool) error {
+	if on {
+		s.apply(true)
+		return s.persist(ctx, actor, true)
+	}
+	if err := s.persist(ctx, actor, false); err != nil {
+		return err
+	}
+	s.apply(false)
+	return nil
+}
+
+func (s *MaintenanceService) persist(ctx context.Context, actor models.User, on bool) error {
+	return s.settings.Set(ctx, &models.SystemSetting{
+		Key: SettingReadOnly, Value: strconv.FormatBool(on), UpdatedBy: actor.ID,
+	})
+}
+
+func (s *MaintenanceService) apply(on bool) {
+	s.mu.Lock()
+	changed := s.readOnly != on
+	s.readOnly = on
+	watchers := slices.Clone(s.watchers)
+	s.mu.Unlock()
+	if !changed {
+		return
+	}
+	for _, fn := range watchers {
+		fn(on)
+	}
+}
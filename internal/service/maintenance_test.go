@@ -0,0 +1,38 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+func TestMaintenanceModePersistsAndNotifies(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	m := service.NewMaintenanceService(st.SystemSettings)
+	var seen []bool
+	m.OnChange(func(on bool) { seen = append(seen, on) })
+
+	if err := m.SetReadOnly(ctx, models.User{ID: "admin"}, true); err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+	if !m.ReadOnly() || len(seen) != 1 || !seen[0] {
+		t.Fatalf("enable not applied: readOnly=%v seen=%v", m.ReadOnly(), seen)
+	}
+
+	// A fresh instance restores the persisted flag.
+	restarted := service.NewMaintenanceService(st.SystemSettings)
+	if err := restarted.Load(ctx); err != nil || !restarted.ReadOnly() {
+		t.Fatalf("load: readOnly=%v err=%v", restarted.ReadOnly(), err)
+	}
+
+	if err := m.SetReadOnly(ctx, models.User{ID: "admin"}, false); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	if m.ReadOnly() || len(seen) != 2 || seen[1] {
+		t.Fatalf("disable not applied: readOnly=%v seen=%v", m.ReadOnly(), seen)
+	}
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charlesng35/shellcn/internal/auth"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// SettingPasswordPolicy holds the JSON-encoded PasswordPolicy.
+const SettingPasswordPolicy = "auth.password_policy"
+
+const maxPolicyPasswordLength = 128
+
+// PasswordRule names one password requirement a password can fail.
+type PasswordRule string
+
+const (
+	PasswordRuleMinLength PasswordRule = "min_length"
+	PasswordRuleUpper     PasswordRule = "uppercase"
+	PasswordRuleLower     PasswordRule = "lowercase"
+	PasswordRuleDigit     PasswordRule = "digit"
+	PasswordRuleSymbol    PasswordRule = "symbol"
+	PasswordRuleIdentity  PasswordRule = "identity"
+	PasswordRuleBreached  PasswordRule = "breached"
+)
+
+// PasswordPolicy is what a new local password must satisfy. Existing
+// passwords are never re-checked.
+type PasswordPolicy struct {
+	MinLength        int  `json:"minLength"`
+	RequireUpper     bool `json:"requireUpper"`
+	RequireLower     bool `json:"requireLower"`
+	RequireDigit     bool `json:"requireDigit"`
+	RequireSymbol    bool `json:"requireSymbol"`
+	DisallowIdentity bool `json:"disallowIdentity"`
+	// CheckBreached rejects passwords in the configured breached-password
+	// filter; it has no effect when none is configured.
+	CheckBreached bool `json:"checkBreached"`
+	// ForceChangeOnStrengthen makes users whose password was set before this
+	// policy was saved change it at their next sign-in, as long as the policy
+	// is stricter than the default.
+	ForceChangeOnStrengthen bool `json:"forceChangeOnStrengthen"`
+}
+
+// DefaultPasswordPolicy is the length-only rule that applies when no policy
+// has been saved.
+var DefaultPasswordPolicy = PasswordPolicy{MinLength: MinPasswordLength}
+
+func (p PasswordPolicy) validate() error {
+	if p.MinLength < MinPasswordLength || p.MinLength > maxPolicyPasswordLength {
+		return fmt.Errorf("%w: minLength must be between %d and %d", plugin.ErrInvalidInput, MinPasswordLength, maxPolicyPasswordLength)
+	}
+	return nil
+}
+
+// stricterThan reports whether p rejects something old accepted.
+func (p PasswordPolicy) stricterThan(old PasswordPolicy) bool {
+	return p.MinLength > old.MinLength ||
+		p.RequireUpper && !old.RequireUpper || p.RequireLower && !old.RequireLower ||
+		p.RequireDigit && !old.RequireDigit || p.RequireSymbol && !old.RequireSymbol ||
+		p.DisallowIdentity && !old.DisallowIdentity || p.CheckBreached && !old.CheckBreached
+}
+
+// PasswordPolicyError lists every rule a rejected password failed.
+type PasswordPolicyError struct {
+	Rules    []PasswordRule
+	messages []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return plugin.ErrInvalidInput.Error() + ": " + strings.Join(e.messages, "; ")
+}
+
+func (e *PasswordPolicyError) Unwrap() error { return plugin.ErrInvalidInput }
+
+func (e *PasswordPolicyError) add(rule PasswordRule, msg string) {
+	e.Rules = append(e.Rules, rule)
+	e.messages = append(e.messages, msg)
+}
+
+type PasswordPolicyOption func(*PasswordPolicyService)
+
+// WithBreachedPasswords enables the breached-password rule.
+func WithBreachedPasswords(f *auth.BloomFilter) PasswordPolicyOption {
+	return func(s *PasswordPolicyService) { s.breached = f }
+}
+
+// PasswordPolicyService checks new local passwords against the policy saved
+// in system settings.
+type PasswordPolicyService struct {
+	settings store.SystemSettingStore
+	users    store.UserStore
+	breached *auth.BloomFilter
+
+	mu     sync.RWMutex
+	policy PasswordPolicy
+	// savedAt is when the applied policy was saved; zero for the default.
+	savedAt time.Time
+}
+
+func NewPasswordPolicyService(settings store.SystemSettingStore, users store.UserStore, opts ...PasswordPolicyOption) *PasswordPolicyService {
+	s := &PasswordPolicyService{settings: settings, users: users, policy: DefaultPasswordPolicy}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// Load applies the saved policy. A newly saved policy stricter than the one
+// it replaces that sets ForceChangeOnStrengthen flags every password set
+// before it was saved for a change at next sign-in. The first Load compares
+// against the default; repeating it on restart flags nobody new.
+func (s *PasswordPolicyService) Load(ctx context.Context) error {
+	v, err := s.settings.Get(ctx, SettingPasswordPolicy)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	next := DefaultPasswordPolicy
+	if err == nil {
+		if err := json.Unmarshal([]byte(v.Value), &next); err != nil {
+			return fmt.Errorf("decode %s: %w", SettingPasswordPolicy, err)
+		}
+		if err := next.validate(); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	prev, saved := s.policy, !v.UpdatedAt.Equal(s.savedAt)
+	s.policy, s.savedAt = next, v.UpdatedAt
+	s.mu.Unlock()
+	if next.ForceChangeOnStrengthen && saved && next.stricterThan(prev) && !v.UpdatedAt.IsZero() {
+		if _, err := s.users.RequirePasswordChange(ctx, v.UpdatedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *PasswordPolicyService) Policy() PasswordPolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.policy
+}
+
+// BreachedCheckAvailable reports whether a breached-password filter is loaded.
+func (s *PasswordPolicyService) BreachedCheckAvailable() bool { return s.breached != nil }
+
+// Check validates a new password for the account named username/email and
+// returns a PasswordPolicyError listing every failed rule.
+func (s *PasswordPolicyService) Check(password, username, email string) error {
+	p := s.Policy()
+	perr := &PasswordPolicyError{}
+	if strings.TrimSpace(password) == "" || utf8.RuneCountInString(password) < p.MinLength {
+		perr.add(PasswordRuleMinLength, fmt.Sprintf("password must be at least %d characters", p.MinLength))
+	}
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r), unicode.IsSymbol(r), unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		perr.add(PasswordRuleUpper, "password must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		perr.add(PasswordRuleLower, "password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		perr.add(PasswordRuleDigit, "password must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		perr.add(PasswordRuleSymbol, "password must contain a symbol")
+	}
+	if p.DisallowIdentity && containsIdentity(password, username, email) {
+		perr.add(PasswordRuleIdentity, "password must not contain your username or email")
+	}
+	if p.CheckBreached && s.breached != nil && s.breached.Contains(password) {
+		perr.add(PasswordRuleBreached, "password appears in a list of breached passwords")
+	}
+	if len(perr.Rules) > 0 {
+		return perr
+	}
+	return nil
+}
+
+// containsIdentity ignores identity parts shorter than three characters,
+// which would match too many passwords by accident.
+func containsIdentity(password, username, email string) bool {
+	pw := strings.ToLower(password)
+	local, _, _ := strings.Cut(email, "@")
+	for _, part := range []string{username, email, local} {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if utf8.RuneCountInString(part) >= 3 && strings.Contains(pw, part) {
+			return true
+		}
+	}
+	return false
+}
+
+func passwordPolicySetting() SettingDef {
+	def, _ := json.Marshal(DefaultPasswordPolicy)
+	return SettingDef{
+		Key: SettingPasswordPolicy, Type: SettingJSON, Default: string(def),
+		Description: "Rules new local passwords must meet; existing passwords are unaffected unless forceChangeOnStrengthen is set.",
+		Validate: func(v string) error {
+			var p PasswordPolicy
+			if err := json.Unmarshal([]byte(v), &p); err != nil {
+				return err
+			}
+			return p.validate()
+		},
+	}
+}
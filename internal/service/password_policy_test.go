@@ -0,0 +1,91 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/auth"
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	breached := auth.NewBloomFilter(10, 0.001)
+	breached.Add("Password1!")
+	p := service.NewPasswordPolicyService(st.SystemSettings, st.Users, service.WithBreachedPasswords(breached))
+	if err := p.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Check("Password1!", "alice", ""); err != nil {
+		t.Fatalf("default policy checks length only: %v", err)
+	}
+
+	_ = st.Users.Create(ctx, &models.User{ID: "u1", Username: "alice"}, "hash")
+	_ = st.SystemSettings.Set(ctx, &models.SystemSetting{
+		Key:       service.SettingPasswordPolicy,
+		Value:     `{"minLength":12,"requireUpper":true,"requireDigit":true,"requireSymbol":true,"disallowIdentity":true,"checkBreached":true,"forceChangeOnStrengthen":true}`,
+		UpdatedAt: time.Now(),
+	})
+	if err := p.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := st.Users.GetByID(ctx, "u1"); !u.MustChangePassword {
+		t.Fatal("password predating the stricter policy was not flagged")
+	}
+
+	// Reloading the same row, or saving a looser policy, flags nobody again.
+	_ = st.Users.SetPasswordHash(ctx, "u1", "hash2", time.Now())
+	if err := p.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	_ = st.SystemSettings.Set(ctx, &models.SystemSetting{
+		Key:       service.SettingPasswordPolicy,
+		Value:     `{"minLength":10,"requireUpper":true,"requireDigit":true,"requireSymbol":true,"disallowIdentity":true,"checkBreached":true,"forceChangeOnStrengthen":true}`,
+		UpdatedAt: time.Now().Add(time.Second),
+	})
+	if err := p.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := st.Users.GetByID(ctx, "u1"); u.MustChangePassword {
+		t.Fatal("loosening the policy must not flag passwords again")
+	}
+	_ = st.SystemSettings.Set(ctx, &models.SystemSetting{
+		Key:       service.SettingPasswordPolicy,
+		Value:     `{"minLength":12,"requireUpper":true,"requireDigit":true,"requireSymbol":true,"disallowIdentity":true,"checkBreached":true,"forceChangeOnStrengthen":true}`,
+		UpdatedAt: time.Now().Add(2 * time.Second),
+	})
+	if err := p.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := st.Users.GetByID(ctx, "u1"); !u.MustChangePassword {
+		t.Fatal("tightening the policy again should flag the password")
+	}
+
+	var perr *service.PasswordPolicyError
+	err := p.Check("alice-smith", "alice", "alice@example.com")
+	if !errors.As(err, &perr) || !errors.Is(err, plugin.ErrInvalidInput) {
+		t.Fatalf("weak password: %v", err)
+	}
+	want := []service.PasswordRule{service.PasswordRuleMinLength, service.PasswordRuleUpper, service.PasswordRuleDigit, service.PasswordRuleIdentity}
+	if !slices.Equal(perr.Rules, want) {
+		t.Fatalf("rules = %v, want %v", perr.Rules, want)
+	}
+	if err := p.Check("Password1!", "bob", ""); !errors.As(err, &perr) || !slices.Contains(perr.Rules, service.PasswordRuleBreached) {
+		t.Fatalf("breached password: %v", err)
+	}
+	if err := p.Check("Tr0ub4dor&3-staple", "alice", "alice@example.com"); err != nil {
+		t.Fatalf("compliant password: %v", err)
+	}
+
+	users := service.NewUserService(st.Users, service.WithPasswordPolicy(p))
+	if _, err := users.Create(ctx, service.NewUserInput{Username: "dave", Password: "short"}); !errors.As(err, &perr) {
+		t.Fatalf("create with weak password: %v", err)
+	}
+}
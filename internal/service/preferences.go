@@ -0,0 +1,232 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// MaxPreferencesBytes caps the encoded size of one user's preference document.
+const MaxPreferencesBytes = 32 << 10
+
+// uiPreferencesKey is the preference row holding the synced UI document.
+const uiPreferencesKey = "ui"
+
+// timeZonePreference is the preference naming the IANA zone exports are
+// written in.
+const timeZonePreference = "timezone"
+
+// ErrPreferencesChanged is returned when an update names a version of the
+// preferences that another tab or device has since replaced.
+var ErrPreferencesChanged = errors.New("preferences were changed elsewhere")
+
+var (
+	preferenceThemes   = []string{"system", "light", "dark"}
+	preferenceSFTPView = []string{"list", "grid"}
+)
+
+const (
+	minTerminalFontSize = 8
+	maxTerminalFontSize = 40
+	maxPreferenceString = 128
+)
+
+// UserPreferences is a user's UI settings document and the ETag of its
+// current version.
+type UserPreferences struct {
+	Values    map[string]json.RawMessage `json:"values"`
+	ETag      string                     `json:"etag"`
+	UpdatedAt time.Time                  `json:"updatedAt,omitzero"`
+}
+
+// PreferenceService stores the terminal and UI settings that follow a user
+// across browsers. Known keys are validated; unknown keys are kept as given so
+// newer clients can add settings without a server change.
+type PreferenceService struct {
+	prefs  store.PreferenceStore
+	now    func() time.Time
+	mu     sync.Mutex
+	events userHub[UserPreferences]
+}
+
+func NewPreferenceService(prefs store.PreferenceStore) *PreferenceService {
+	return &PreferenceService{prefs: prefs, now: time.Now}
+}
+
+func (s *PreferenceService) Get(ctx context.Context, userID string) (UserPreferences, error) {
+	p, err := s.prefs.Get(ctx, userID, uiPreferencesKey)
+	if errors.Is(err, store.ErrNotFound) {
+		return newUserPreferences(map[string]json.RawMessage{}, time.Time{})
+	}
+	if err != nil {
+		return UserPreferences{}, err
+	}
+	values := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(p.Value), &values); err != nil {
+		return UserPreferences{}, fmt.Errorf("decode preferences: %w", err)
+	}
+	return newUserPreferences(values, p.UpdatedAt)
+}
+
+// Update shallow-merges patch into the stored document: supplied keys replace
+// their values and a JSON null removes the key. A non-empty ifMatch must equal
+// the current ETag, otherwise ErrPreferencesChanged is returned.
+func (s *PreferenceService) Update(ctx context.Context, userID string, patch map[string]json.RawMessage, ifMatch string) (UserPreferences, error) {
+	if err := validatePreferences(patch); err != nil {
+		return UserPreferences{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, err := s.Get(ctx, userID)
+	if err != nil {
+		return UserPreferences{}, err
+	}
+	if ifMatch != "" && ifMatch != cur.ETag {
+		return UserPreferences{}, ErrPreferencesChanged
+	}
+	for k, v := range patch {
+		if isJSONNull(v) {
+			delete(cur.Values, k)
+		} else {
+			cur.Values[k] = v
+		}
+	}
+	doc, err := json.Marshal(cur.Values)
+	if err != nil {
+		return UserPreferences{}, err
+	}
+	if len(doc) > MaxPreferencesBytes {
+		return UserPreferences{}, fmt.Errorf("%w: preferences exceed %d bytes", plugin.ErrInvalidInput, MaxPreferencesBytes)
+	}
+	now := s.now()
+	if err := s.prefs.Set(ctx, &models.Preference{UserID: userID, Key: uiPreferencesKey, Value: string(doc), UpdatedAt: now}); err != nil {
+		return UserPreferences{}, err
+	}
+	out, err := newUserPreferences(cur.Values, now)
+	if err != nil {
+		return UserPreferences{}, err
+	}
+	s.events.publish(userID, out)
+	return out, nil
+}
+
+// TimeZone is the zone userID prefers timestamps in, UTC when none is set.
+func (s *PreferenceService) TimeZone(ctx context.Context, userID string) (*time.Location, error) {
+	p, err := s.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var name string
+	if raw, ok := p.Values[timeZonePreference]; !ok || json.Unmarshal(raw, &name) != nil {
+		return time.UTC, nil
+	}
+	loc, err := LoadTimeZone(name)
+	if err != nil {
+		// Checked on save; a zone this host cannot load falls back to UTC.
+		return time.UTC, nil
+	}
+	return loc, nil
+}
+
+// LoadTimeZone resolves an IANA time zone name such as "Europe/Berlin".
+// The empty name and "Local" are rejected, since both silently mean
+// something other than what a user would pick.
+func LoadTimeZone(name string) (*time.Location, error) {
+	if name != "" && name != "Local" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q is not a time zone; use an IANA name such as Europe/Berlin, America/New_York or UTC", plugin.ErrInvalidInput, name)
+}
+
+// Subscribe streams userID's saved preferences until cancel is called.
+func (s *PreferenceService) Subscribe(userID string) (Feed[UserPreferences], func()) {
+	return s.events.subscribe(userID)
+}
+
+// newUserPreferences compacts the values and derives the ETag from their
+// canonical encoding, which sorts keys.
+func newUserPreferences(values map[string]json.RawMessage, updatedAt time.Time) (UserPreferences, error) {
+	for k, v := range values {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, v); err != nil {
+			return UserPreferences{}, fmt.Errorf("%w: %s is not valid JSON", plugin.ErrInvalidInput, k)
+		}
+		values[k] = buf.Bytes()
+	}
+	doc, err := json.Marshal(values)
+	if err != nil {
+		return UserPreferences{}, err
+	}
+	sum := sha256.Sum256(doc)
+	return UserPreferences{Values: values, ETag: `"` + hex.EncodeToString(sum[:12]) + `"`, UpdatedAt: updatedAt}, nil
+}
+
+// validatePreferences checks the keys the web client understands. Null is
+// always accepted since it deletes the key.
+func validatePreferences(patch map[string]json.RawMessage) error {
+	verr := &ValidationError{}
+	for _, k := range slices.Sorted(maps.Keys(patch)) {
+		raw := patch[k]
+		if isJSONNull(raw) {
+			continue
+		}
+		if !json.Valid(raw) {
+			verr.add(k, k+" is not valid JSON")
+			continue
+		}
+		switch k {
+		case "theme":
+			validatePreferenceEnum(verr, k, raw, preferenceThemes)
+		case "sftpViewMode":
+			validatePreferenceEnum(verr, k, raw, preferenceSFTPView)
+		case "terminalFontSize":
+			var n float64
+			if json.Unmarshal(raw, &n) != nil || n < minTerminalFontSize || n > maxTerminalFontSize || n != float64(int(n)) {
+				verr.add(k, fmt.Sprintf("terminalFontSize must be a whole number from %d to %d", minTerminalFontSize, maxTerminalFontSize))
+			}
+		case "terminalColorScheme", "terminalFontFamily":
+			var v string
+			if json.Unmarshal(raw, &v) != nil || v == "" || len(v) > maxPreferenceString {
+				verr.add(k, fmt.Sprintf("%s must be a non-empty string of at most %d characters", k, maxPreferenceString))
+			}
+		case timeZonePreference:
+			var v string
+			if json.Unmarshal(raw, &v) != nil {
+				verr.add(k, "timezone must be a string")
+			} else if _, err := LoadTimeZone(v); err != nil {
+				verr.add(k, "timezone must be an IANA time zone name such as Europe/Berlin or UTC")
+			}
+		case "keybindings":
+			var m map[string]string
+			if json.Unmarshal(raw, &m) != nil {
+				verr.add(k, "keybindings must map actions to key strings")
+			}
+		}
+	}
+	return verr.err()
+}
+
+func validatePreferenceEnum(verr *ValidationError, key string, raw json.RawMessage, allowed []string) {
+	var v string
+	if json.Unmarshal(raw, &v) != nil || !slices.Contains(allowed, v) {
+		verr.add(key, fmt.Sprintf("%s must be one of %v", key, allowed))
+	}
+}
+
+func isJSONNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
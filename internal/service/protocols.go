@@ -3,23 +3,76 @@ package service
 import (
 	"context"
 	"fmt"
+	"maps"
+	"sync"
+	"time"
 
 	"github.com/charlesng35/shellcn/internal/models"
 	"github.com/charlesng35/shellcn/internal/store"
 	"github.com/charlesng35/shellcn/sdk/plugin"
 )
 
+const defaultProtocolCacheTTL = 30 * time.Second
+
 // ProtocolService manages the admin-configured availability of each protocol.
+// Availability is checked on every launch, so the decoded rows are cached in
+// memory until a Set on this replica or the TTL expires.
 type ProtocolService struct {
 	settings store.ProtocolSettingStore
+	ttl      time.Duration
+	observe  func(hit bool)
+
+	mu       sync.RWMutex
+	cached   map[string]models.ProtocolAvailability
+	loadedAt time.Time
+	gen      uint64 // bumped by invalidate so a load that raced a Set is dropped
+}
+
+// ProtocolServiceOption configures a ProtocolService.
+type ProtocolServiceOption func(*ProtocolService)
+
+// WithProtocolCacheTTL bounds how long cached availability is served, so a
+// change made through another replica is picked up.
+func WithProtocolCacheTTL(d time.Duration) ProtocolServiceOption {
+	return func(s *ProtocolService) {
+		if d > 0 {
+			s.ttl = d
+		}
+	}
 }
 
-func NewProtocolService(settings store.ProtocolSettingStore) *ProtocolService {
-	return &ProtocolService{settings: settings}
+// WithProtocolCacheObserver reports each cache lookup as a hit or a miss.
+func WithProtocolCacheObserver(fn func(hit bool)) ProtocolServiceOption {
+	return func(s *ProtocolService) { s.observe = fn }
+}
+
+func NewProtocolService(settings store.ProtocolSettingStore, opts ...ProtocolServiceOption) *ProtocolService {
+	s := &ProtocolService{settings: settings, ttl: defaultProtocolCacheTTL}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
 }
 
 // States returns stored availability keyed by protocol; absent ones default to enabled.
 func (s *ProtocolService) States(ctx context.Context) (map[string]models.ProtocolAvailability, error) {
+	states, err := s.states(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return maps.Clone(states), nil
+}
+
+// states returns the cached map, which callers must not modify.
+func (s *ProtocolService) states(ctx context.Context) (map[string]models.ProtocolAvailability, error) {
+	s.mu.RLock()
+	states, fresh, gen := s.cached, s.cached != nil && time.Since(s.loadedAt) < s.ttl, s.gen
+	s.mu.RUnlock()
+	s.observeLookup(fresh)
+	if fresh {
+		return states, nil
+	}
+
 	rows, err := s.settings.List(ctx)
 	if err != nil {
 		return nil, err
@@ -28,9 +81,27 @@ func (s *ProtocolService) States(ctx context.Context) (map[string]models.Protoco
 	for _, r := range rows {
 		out[r.Protocol] = r.Availability
 	}
+	s.mu.Lock()
+	if s.gen == gen {
+		s.cached, s.loadedAt = out, time.Now()
+	}
+	s.mu.Unlock()
 	return out, nil
 }
 
+func (s *ProtocolService) observeLookup(hit bool) {
+	if s.observe != nil {
+		s.observe(hit)
+	}
+}
+
+func (s *ProtocolService) invalidate() {
+	s.mu.Lock()
+	s.cached = nil
+	s.gen++
+	s.mu.Unlock()
+}
+
 // Set validates and persists a protocol's availability.
 func (s *ProtocolService) Set(ctx context.Context, protocol string, a models.ProtocolAvailability) error {
 	if protocol == "" {
@@ -39,12 +110,16 @@ func (s *ProtocolService) Set(ctx context.Context, protocol string, a models.Pro
 	if !a.Valid() {
 		return fmt.Errorf("%w: unknown availability %q", plugin.ErrInvalidInput, a)
 	}
-	return s.settings.Set(ctx, &models.ProtocolSetting{Protocol: protocol, Availability: a})
+	if err := s.settings.Set(ctx, &models.ProtocolSetting{Protocol: protocol, Availability: a}); err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
 }
 
 // Allowed reports whether the protocol is usable by a user with the given role.
 func (s *ProtocolService) Allowed(ctx context.Context, protocol string, isAdmin bool) (bool, error) {
-	states, err := s.States(ctx)
+	states, err := s.states(ctx)
 	if err != nil {
 		return false, err
 	}
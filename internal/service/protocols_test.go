@@ -3,6 +3,7 @@ package service_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/charlesng35/shellcn/internal/models"
 	"github.com/charlesng35/shellcn/internal/service"
@@ -77,3 +78,67 @@ func TestProtocolServiceSetRejectsUnknown(t *testing.T) {
 		t.Fatal("expected an error for an empty protocol")
 	}
 }
+
+func TestProtocolServiceCache(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	var hits, misses int
+	svc := service.NewProtocolService(st.ProtocolSettings,
+		service.WithProtocolCacheTTL(50*time.Millisecond),
+		service.WithProtocolCacheObserver(func(hit bool) {
+			if hit {
+				hits++
+			} else {
+				misses++
+			}
+		}))
+
+	for range 3 {
+		if ok, _ := svc.Allowed(ctx, "ssh", false); !ok {
+			t.Fatal("unset protocol should be allowed")
+		}
+	}
+	if hits != 2 || misses != 1 {
+		t.Fatalf("hits=%d misses=%d, want 2/1", hits, misses)
+	}
+
+	// A Set through the service is visible immediately.
+	if err := svc.Set(ctx, "ssh", models.ProtocolDisabled); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := svc.Allowed(ctx, "ssh", true); ok {
+		t.Fatal("Set should invalidate the cache")
+	}
+
+	// A write from another replica shows up once the TTL lapses.
+	_ = st.ProtocolSettings.Set(ctx, &models.ProtocolSetting{Protocol: "ssh", Availability: models.ProtocolEnabled})
+	if ok, _ := svc.Allowed(ctx, "ssh", false); ok {
+		t.Fatal("cached state should be served until the TTL")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if ok, _ := svc.Allowed(ctx, "ssh", false); !ok {
+		t.Fatal("expired cache should reload")
+	}
+
+	states, _ := svc.States(ctx)
+	states["ssh"] = models.ProtocolDisabled
+	if ok, _ := svc.Allowed(ctx, "ssh", false); !ok {
+		t.Fatal("States must return a copy")
+	}
+}
+
+func BenchmarkProtocolAllowedWarm(b *testing.B) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	for _, p := range []string{"ssh", "rdp", "vnc", "postgres", "kubernetes"} {
+		_ = st.ProtocolSettings.Set(ctx, &models.ProtocolSetting{Protocol: p, Availability: models.ProtocolAdminOnly})
+	}
+	svc := service.NewProtocolService(st.ProtocolSettings, service.WithProtocolCacheTTL(time.Hour))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if _, err := svc.Allowed(ctx, "ssh", true); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/recording"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// WithArchive moves finalized recordings from the primary blob store to
+// archive once they ended more than after ago. Archived recordings stay
+// playable and are purged from the archive at retention like any other.
+func WithArchive(archive recording.BlobStore, after time.Duration) RecordingServiceOption {
+	return func(s *RecordingService) { s.archive, s.archiveAfter = archive, after }
+}
+
+// Archive moves every finalized recording that ended before the archive age
+// as of now to the archive store. Each blob is copied, read back and checked
+// against the recording's checksum before the row points at the archive and
+// the primary copy is removed. Without an archive store it does nothing.
+func (s *RecordingService) Archive(ctx context.Context, now time.Time) (int, error) {
+	if s.archive == nil || s.archiveAfter <= 0 {
+		return 0, nil
+	}
+	ctx = WithoutTimeouts(ctx)
+	due, err := s.recs.List(ctx, store.RecordingFilter{
+		Status: string(models.RecordingFinalized), ArchivableBefore: now.Add(-s.archiveAfter),
+	})
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, r := range due {
+		if err := s.archiveOne(ctx, r); err != nil {
+			return n, fmt.Errorf("archive recording %s: %w", r.ID, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (s *RecordingService) archiveOne(ctx context.Context, r models.Recording) error {
+	src, err := s.blobs.Open(ctx, r.StorageKey)
+	if err != nil {
+		return err
+	}
+	sum, err := copyBlob(ctx, s.archive, r.StorageKey, src)
+	_ = src.Close()
+	if err == nil && r.Checksum != "" && sum != r.Checksum {
+		err = fmt.Errorf("source checksum %s does not match the recorded %s", sum, r.Checksum)
+	}
+	if err == nil {
+		err = verifyBlob(ctx, s.archive, r.StorageKey, sum)
+	}
+	if err != nil {
+		_ = s.archive.Delete(ctx, r.StorageKey)
+		return err
+	}
+	now := time.Now().UTC()
+	r.ArchivedAt = &now
+	if err := s.recs.Update(ctx, &r); err != nil {
+		return err
+	}
+	// The row already points at the archive, so a failure here only leaves
+	// an unreferenced primary copy behind.
+	return s.blobs.Delete(ctx, r.StorageKey)
+}
+
+func copyBlob(ctx context.Context, dst recording.BlobStore, key string, src io.Reader) (string, error) {
+	w, err := dst.Create(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(w, h), src)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return hex.EncodeToString(h.Sum(nil)), err
+}
+
+func verifyBlob(ctx context.Context, blobs recording.BlobStore, key, want string) error {
+	rc, err := blobs.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rc.Close() }()
+	h := sha256.New()
+	if _, err := io.Copy(h, rc); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("archived copy checksum %s does not match %s", got, want)
+	}
+	return nil
+}
+
+// recordingBlobs is the store holding r's recording blob.
+func (s *RecordingService) recordingBlobs(r models.Recording) (recording.BlobStore, error) {
+	if r.ArchivedAt == nil {
+		return s.blobs, nil
+	}
+	if s.archive == nil {
+		return nil, fmt.Errorf("%w: the recording is archived and no archive store is configured", plugin.ErrUnavailable)
+	}
+	return s.archive, nil
+}
+
+func (s *RecordingService) openRecording(ctx context.Context, r models.Recording) (io.ReadCloser, error) {
+	blobs, err := s.recordingBlobs(r)
+	if err != nil {
+		return nil, err
+	}
+	return blobs.Open(ctx, r.StorageKey)
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/recording"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// DefaultChapterGap is the idle gap that ends a chapter unless configured.
+const DefaultChapterGap = time.Minute
+
+// ChapterGap is the idle gap, in seconds, that separated r's chapters, or the
+// configured gap when r has not been chaptered yet.
+func (s *RecordingService) ChapterGap(r models.Recording) float64 {
+	if r.ChapterGapMS > 0 {
+		return float64(r.ChapterGapMS) / 1000
+	}
+	return s.chapterGap.Seconds()
+}
+
+// BuildChapters splits a finalized terminal recording into activity chapters
+// and stores them on the record. It is idempotent for the configured gap.
+func (s *RecordingService) BuildChapters(ctx context.Context, id string) (models.Recording, error) {
+	r, err := s.recs.Get(ctx, id)
+	if err != nil {
+		return models.Recording{}, err
+	}
+	if r.ChapterGapMS == s.chapterGap.Milliseconds() {
+		return r, nil
+	}
+	if r.Status != models.RecordingFinalized || r.StorageKey == "" {
+		return r, fmt.Errorf("%w: recording is not finalized", plugin.ErrConflict)
+	}
+	if r.Format != string(plugin.FormatAsciicastV2) {
+		return r, fmt.Errorf("%w: %s recordings have no events", plugin.ErrInvalidInput, r.Format)
+	}
+	rc, err := s.openRecording(ctx, r)
+	if err != nil {
+		return r, err
+	}
+	chapters, err := recording.ExtractChapters(rc, s.chapterGap)
+	_ = rc.Close()
+	if err != nil {
+		return r, err
+	}
+	r.Chapters, r.ChapterGapMS = chapters, s.chapterGap.Milliseconds()
+	if err := s.recs.Update(ctx, &r); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// BuildAllChapters is the maintenance pass: it chapters every finalized
+// terminal recording not yet chaptered with the configured gap and returns
+// how many it did.
+func (s *RecordingService) BuildAllChapters(ctx context.Context) (int, error) {
+	ctx = WithoutTimeouts(ctx)
+	recs, err := s.recs.List(ctx, store.RecordingFilter{
+		Status: string(models.RecordingFinalized), Format: string(plugin.FormatAsciicastV2),
+	})
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, r := range recs {
+		if r.ChapterGapMS == s.chapterGap.Milliseconds() {
+			continue
+		}
+		if ctx.Err() != nil {
+			return n, ctx.Err()
+		}
+		if _, err := s.BuildChapters(ctx, r.ID); err != nil {
+			return n, fmt.Errorf("recording %s: %w", r.ID, err)
+		}
+		n++
+	}
+	return n, nil
+}
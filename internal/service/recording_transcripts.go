@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/recording"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// ErrTranscriptSkipped is returned for recordings transcript extraction does
+// not cover: non-terminal formats and blobs over the size limit.
+var ErrTranscriptSkipped = errors.New("transcript extraction skipped")
+
+// maxSnippetsPerRecording caps the transcript matches returned per recording.
+const maxSnippetsPerRecording = 5
+
+// RecordingSearchResult is a recording matched by a list search, with the
+// transcript lines that matched.
+type RecordingSearchResult struct {
+	Recording models.Recording
+	Matches   []recording.TranscriptMatch
+}
+
+// ExtractTranscript stores the plain-text transcript of a finalized terminal
+// recording. It is idempotent: a recording that already has one is returned
+// unchanged.
+func (s *RecordingService) ExtractTranscript(ctx context.Context, id string) (models.Recording, error) {
+	r, err := s.recs.Get(ctx, id)
+	if err != nil {
+		return models.Recording{}, err
+	}
+	if r.TranscriptKey != "" {
+		return r, nil
+	}
+	if r.Status != models.RecordingFinalized || r.StorageKey == "" {
+		return r, fmt.Errorf("%w: recording is not finalized", plugin.ErrConflict)
+	}
+	if r.Format != string(plugin.FormatAsciicastV2) {
+		return r, fmt.Errorf("%w: %s recordings have no text", ErrTranscriptSkipped, r.Format)
+	}
+	if s.transcriptMaxBytes > 0 && r.Size > s.transcriptMaxBytes {
+		return r, fmt.Errorf("%w: recording is larger than %d bytes", ErrTranscriptSkipped, s.transcriptMaxBytes)
+	}
+	rc, err := s.openRecording(ctx, r)
+	if err != nil {
+		return r, err
+	}
+	t, err := recording.ExtractTranscript(rc)
+	_ = rc.Close()
+	if err != nil {
+		return r, err
+	}
+	key := recording.TranscriptKey(r.StorageKey)
+	if err := recording.WriteTranscript(ctx, s.blobs, key, t); err != nil {
+		return r, err
+	}
+	r.TranscriptKey = key
+	if err := s.recs.Update(ctx, &r); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// ExtractTranscripts is the maintenance pass: it extracts every finalized
+// terminal recording still missing a transcript and returns how many it did.
+func (s *RecordingService) ExtractTranscripts(ctx context.Context) (int, error) {
+	ctx = WithoutTimeouts(ctx)
+	recs, err := s.recs.List(ctx, store.RecordingFilter{
+		Status: string(models.RecordingFinalized), Format: string(plugin.FormatAsciicastV2),
+	})
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, r := range recs {
+		if r.TranscriptKey != "" {
+			continue
+		}
+		if ctx.Err() != nil {
+			return n, ctx.Err()
+		}
+		if _, err := s.ExtractTranscript(ctx, r.ID); err != nil {
+			if errors.Is(err, ErrTranscriptSkipped) {
+				continue
+			}
+			return n, fmt.Errorf("recording %s: %w", r.ID, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Transcript returns the stored transcript of a recording the actor may see.
+func (s *RecordingService) Transcript(ctx context.Context, actor models.User, id string) (recording.Transcript, error) {
+	r, err := s.Get(ctx, actor, id)
+	if err != nil {
+		return recording.Transcript{}, err
+	}
+	if r.TranscriptKey == "" {
+		return recording.Transcript{}, fmt.Errorf("%w: no transcript has been extracted for this recording", plugin.ErrNotFound)
+	}
+	return recording.ReadTranscript(ctx, s.blobs, r.TranscriptKey)
+}
+
+// Search lists the actor's recordings matching f.Search by connection name or
+// username, or by a line of their stored transcript. Transcript hits carry
+// the matching lines with their offsets.
+func (s *RecordingService) Search(ctx context.Context, actor models.User, f store.RecordingFilter) ([]RecordingSearchResult, error) {
+	ctx, cancel := WithTimeout(ctx, OpReport)
+	defer cancel()
+	q, limit := f.Search, f.Limit
+	f.UserID, f.Search, f.Limit = actor.ID, "", 0
+	recs, err := s.recs.List(ctx, f)
+	if err != nil {
+		return nil, timeoutError(ctx, err)
+	}
+	needle := strings.ToLower(q)
+	out := []RecordingSearchResult{}
+	for _, r := range recs {
+		res := RecordingSearchResult{Recording: r}
+		if r.TranscriptKey != "" {
+			t, err := recording.ReadTranscript(ctx, s.blobs, r.TranscriptKey)
+			if err != nil {
+				return nil, timeoutError(ctx, fmt.Errorf("recording %s transcript: %w", r.ID, err))
+			}
+			res.Matches = t.Search(q, maxSnippetsPerRecording)
+		}
+		nameHit := strings.Contains(strings.ToLower(r.ConnectionName), needle) ||
+			strings.Contains(strings.ToLower(r.Username), needle)
+		if !nameHit && len(res.Matches) == 0 {
+			continue
+		}
+		out = append(out, res)
+		if limit > 0 && len(out) == limit {
+			break
+		}
+	}
+	return out, nil
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"time"
 
@@ -16,17 +18,42 @@ import (
 // cleanup. Read scope is role-aware — admins see everything, others see their
 // own recordings only.
 type RecordingService struct {
-	recs  store.RecordingStore
-	blobs recording.BlobStore
+	recs               store.RecordingStore
+	blobs              recording.BlobStore
+	transcriptMaxBytes int64
+	chapterGap         time.Duration
+	// archive, when set, holds recording blobs older than archiveAfter.
+	archive      recording.BlobStore
+	archiveAfter time.Duration
 }
 
-func NewRecordingService(recs store.RecordingStore, blobs recording.BlobStore) *RecordingService {
-	return &RecordingService{recs: recs, blobs: blobs}
+// RecordingServiceOption configures a RecordingService.
+type RecordingServiceOption func(*RecordingService)
+
+// WithTranscriptMaxBytes skips transcript extraction for recordings larger
+// than n bytes; zero or less means no limit.
+func WithTranscriptMaxBytes(n int64) RecordingServiceOption {
+	return func(s *RecordingService) { s.transcriptMaxBytes = n }
+}
+
+// WithChapterGap sets the idle gap that separates recording chapters.
+func WithChapterGap(d time.Duration) RecordingServiceOption {
+	return func(s *RecordingService) { s.chapterGap = d }
+}
+
+func NewRecordingService(recs store.RecordingStore, blobs recording.BlobStore, opts ...RecordingServiceOption) *RecordingService {
+	s := &RecordingService{recs: recs, blobs: blobs, chapterGap: DefaultChapterGap}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // Create persists initial recording metadata.
 func (s *RecordingService) Create(ctx context.Context, r *models.Recording) error {
-	return s.recs.Create(ctx, r)
+	ctx, cancel := WithTimeout(ctx, OpWrite)
+	defer cancel()
+	return timeoutError(ctx, s.recs.Create(ctx, r))
 }
 
 // canView reports whether actor may see a recording: only its creator. A
@@ -38,15 +65,64 @@ func (s *RecordingService) canView(_ context.Context, actor models.User, r model
 
 // List returns the actor's own recordings; the query is always scoped to them.
 func (s *RecordingService) List(ctx context.Context, actor models.User, f store.RecordingFilter) ([]models.Recording, error) {
+	ctx, cancel := WithTimeout(ctx, OpRead)
+	defer cancel()
 	f.UserID = actor.ID
-	return s.recs.List(ctx, f)
+	recs, err := s.recs.List(ctx, f)
+	return recs, timeoutError(ctx, err)
+}
+
+// recordingExportBatch is how many recordings Each reads per query.
+const recordingExportBatch = 500
+
+// Each calls fn for every recording List would return for f, reading them a
+// page at a time so an export of a long history never holds it all. A zero
+// f.Until is fixed to the call time so rows added meanwhile do not shift the
+// pages. f.Limit still caps the total.
+func (s *RecordingService) Each(ctx context.Context, actor models.User, f store.RecordingFilter, fn func(models.Recording) error) error {
+	f.UserID = actor.ID
+	if f.Until.IsZero() {
+		f.Until = time.Now()
+	}
+	remaining := f.Limit
+	for offset := 0; ; {
+		f.Limit, f.Offset = recordingExportBatch, offset
+		if remaining > 0 {
+			f.Limit = min(f.Limit, remaining)
+		}
+		page, err := func() ([]models.Recording, error) {
+			ctx, cancel := WithTimeout(ctx, OpRead)
+			defer cancel()
+			page, err := s.recs.List(ctx, f)
+			return page, timeoutError(ctx, err)
+		}()
+		if err != nil {
+			return err
+		}
+		for _, r := range page {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+		offset += len(page)
+		if remaining > 0 {
+			if remaining -= len(page); remaining == 0 {
+				return nil
+			}
+		}
+		if len(page) < f.Limit {
+			return nil
+		}
+	}
 }
 
 // Get returns one recording if the actor may see it.
 func (s *RecordingService) Get(ctx context.Context, actor models.User, id string) (models.Recording, error) {
+	ctx, cancel := WithTimeout(ctx, OpRead)
+	defer cancel()
 	r, err := s.recs.Get(ctx, id)
 	if err != nil {
-		return models.Recording{}, err
+		return models.Recording{}, timeoutError(ctx, err)
 	}
 	if !s.canView(ctx, actor, r) {
 		return models.Recording{}, plugin.ErrForbidden
@@ -63,18 +139,43 @@ func (s *RecordingService) Content(ctx context.Context, actor models.User, id st
 	if r.Status != models.RecordingFinalized {
 		return nil, r, plugin.ErrUnavailable
 	}
-	rc, err := s.blobs.Open(ctx, r.StorageKey)
+	rc, err := s.openRecording(ctx, r)
 	if err != nil {
 		return nil, r, err
 	}
 	return rc, r, nil
 }
 
+// Annotate adds a post-hoc annotation to a finished recording the actor may see.
+// It lands in metadata only; the blob is immutable once finalized.
+func (s *RecordingService) Annotate(ctx context.Context, actor models.User, id string, offset float64, text string) (models.RecordingAnnotation, error) {
+	r, err := s.Get(ctx, actor, id)
+	if err != nil {
+		return models.RecordingAnnotation{}, err
+	}
+	if r.Status == models.RecordingActive {
+		return models.RecordingAnnotation{}, fmt.Errorf("%w: recording is still capturing", plugin.ErrConflict)
+	}
+	if offset < 0 || offset*1000 > float64(r.DurationMS) {
+		return models.RecordingAnnotation{}, fmt.Errorf("%w: offset is outside the recording", plugin.ErrInvalidInput)
+	}
+	note := models.RecordingAnnotation{Offset: offset, Text: text, UserID: actor.ID, At: time.Now()}
+	r.Annotations = append(r.Annotations, note)
+	ctx, cancel := WithTimeout(ctx, OpWrite)
+	defer cancel()
+	if err := s.recs.Update(ctx, &r); err != nil {
+		return models.RecordingAnnotation{}, timeoutError(ctx, err)
+	}
+	return note, nil
+}
+
 // Delete removes a recording's blob and metadata if the actor may manage it.
 func (s *RecordingService) Delete(ctx context.Context, actor models.User, id string) (models.Recording, error) {
+	ctx, cancel := WithTimeout(ctx, OpWrite)
+	defer cancel()
 	r, err := s.recs.Get(ctx, id)
 	if err != nil {
-		return models.Recording{}, err
+		return models.Recording{}, timeoutError(ctx, err)
 	}
 	if !s.canView(ctx, actor, r) {
 		return models.Recording{}, plugin.ErrForbidden
@@ -82,34 +183,184 @@ func (s *RecordingService) Delete(ctx context.Context, actor models.User, id str
 	if r.Status == models.RecordingActive {
 		return models.Recording{}, plugin.ErrConflict
 	}
-	if r.StorageKey != "" {
-		if err := s.blobs.Delete(ctx, r.StorageKey); err != nil {
-			return models.Recording{}, err
-		}
+	if r.Protected {
+		return models.Recording{}, &RecordingProtectedError{ProtectedBy: r.ProtectedBy, ProtectedAt: r.ProtectedAt}
+	}
+	if err := s.deleteBlobs(ctx, r); err != nil {
+		return models.Recording{}, timeoutError(ctx, err)
 	}
 	if err := s.recs.Delete(ctx, id); err != nil {
-		return models.Recording{}, err
+		return models.Recording{}, timeoutError(ctx, err)
 	}
 	return r, nil
 }
 
+// RecordingProtectedError refuses to delete a recording on legal hold.
+type RecordingProtectedError struct {
+	ProtectedBy string
+	ProtectedAt *time.Time
+}
+
+func (e *RecordingProtectedError) Error() string {
+	return fmt.Sprintf("%s: the recording is protected; an admin must unprotect it first", plugin.ErrConflict)
+}
+
+func (e *RecordingProtectedError) Unwrap() error { return plugin.ErrConflict }
+
+// SetProtected places a recording on legal hold or lifts it. A recording still
+// being captured cannot be protected: its row is rewritten at finalize.
+func (s *RecordingService) SetProtected(ctx context.Context, actorID, id string, protect bool) (models.Recording, error) {
+	ctx, cancel := WithTimeout(ctx, OpWrite)
+	defer cancel()
+	r, err := s.recs.Get(ctx, id)
+	if err != nil {
+		return models.Recording{}, timeoutError(ctx, err)
+	}
+	if protect && !protectable(r) {
+		return models.Recording{}, fmt.Errorf("%w: a recording still being captured cannot be protected", plugin.ErrConflict)
+	}
+	if r.Protected == protect {
+		return r, nil
+	}
+	setProtected(&r, actorID, protect)
+	if err := s.recs.Update(ctx, &r); err != nil {
+		return models.Recording{}, timeoutError(ctx, err)
+	}
+	return r, nil
+}
+
+// ProtectMatching places every finished recording matching f on legal hold
+// and returns how many it newly protected. f must be narrowed as for
+// BulkDelete.
+func (s *RecordingService) ProtectMatching(ctx context.Context, actorID string, f store.RecordingFilter) (int, error) {
+	if err := checkBulkFilter(f); err != nil {
+		return 0, err
+	}
+	f.Search, f.Sort, f.Limit = "", "", 0
+	ctx = WithoutTimeouts(ctx)
+	recs, err := s.recs.List(ctx, f)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, r := range recs {
+		if r.Protected || !protectable(r) {
+			continue
+		}
+		setProtected(&r, actorID, true)
+		if err := s.recs.Update(ctx, &r); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func protectable(r models.Recording) bool {
+	return r.Status != models.RecordingActive && r.Status != models.RecordingPending
+}
+
+func setProtected(r *models.Recording, actorID string, protect bool) {
+	r.Protected = protect
+	r.ProtectedBy, r.ProtectedAt = "", nil
+	if protect {
+		now := time.Now().UTC()
+		r.ProtectedBy, r.ProtectedAt = actorID, &now
+	}
+}
+
+// checkBulkFilter rejects a filter that would select every recording.
+func checkBulkFilter(f store.RecordingFilter) error {
+	if f.UserID == "" && f.ConnectionID == "" && f.Protocol == "" && f.Since.IsZero() && f.Until.IsZero() {
+		return fmt.Errorf("%w: narrow the selection by user, connection, protocol or time", plugin.ErrInvalidInput)
+	}
+	return nil
+}
+
+// recordingDeleteBatch is how many recordings BulkDelete removes between
+// cancellation checks.
+const recordingDeleteBatch = 100
+
+// RecordingBulkResult summarises a bulk delete, or for a dry run what it would
+// remove. Bytes counts the recordings deleted (or deletable).
+type RecordingBulkResult struct {
+	DryRun    bool                   `json:"dryRun"`
+	Matched   int                    `json:"matched"`
+	Deleted   int                    `json:"deleted"`
+	Bytes     int64                  `json:"bytes"`
+	Protected []string               `json:"protected"`
+	Active    []string               `json:"active"`
+	Failed    []RecordingBulkFailure `json:"failed"`
+}
+
+// RecordingBulkFailure is one recording BulkDelete could not remove.
+type RecordingBulkFailure struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// BulkDelete removes every recording matching f in batches, skipping and
+// reporting protected and still-active ones. A failed recording is reported
+// and the rest carry on; a blob already gone counts as deleted. f must narrow
+// the selection by at least one of user, connection, protocol or time.
+func (s *RecordingService) BulkDelete(ctx context.Context, f store.RecordingFilter, dryRun bool) (RecordingBulkResult, error) {
+	if err := checkBulkFilter(f); err != nil {
+		return RecordingBulkResult{}, err
+	}
+	f.Search, f.Sort, f.Limit = "", "", 0
+	ctx = WithoutTimeouts(ctx)
+	recs, err := s.recs.List(ctx, f)
+	if err != nil {
+		return RecordingBulkResult{}, err
+	}
+	out := RecordingBulkResult{DryRun: dryRun, Matched: len(recs), Protected: []string{}, Active: []string{}, Failed: []RecordingBulkFailure{}}
+	for i, r := range recs {
+		if i > 0 && i%recordingDeleteBatch == 0 {
+			if err := ctx.Err(); err != nil {
+				return out, err
+			}
+		}
+		switch {
+		case r.Protected:
+			out.Protected = append(out.Protected, r.ID)
+			continue
+		case r.Status == models.RecordingActive:
+			out.Active = append(out.Active, r.ID)
+			continue
+		}
+		if !dryRun {
+			err := s.deleteBlobs(ctx, r)
+			if err == nil {
+				err = s.recs.Delete(ctx, r.ID)
+			}
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				out.Failed = append(out.Failed, RecordingBulkFailure{ID: r.ID, Error: err.Error()})
+				continue
+			}
+		}
+		out.Deleted++
+		out.Bytes += r.Size
+	}
+	return out, nil
+}
+
 // Cleanup deletes the blobs of recordings expired as of now and marks their
 // metadata discarded. It is a no-op for already-discarded rows.
 func (s *RecordingService) Cleanup(ctx context.Context, now time.Time) (int, error) {
+	ctx = WithoutTimeouts(ctx)
 	expired, err := s.recs.List(ctx, store.RecordingFilter{ExpiredBefore: now})
 	if err != nil {
 		return 0, err
 	}
 	n := 0
 	for _, r := range expired {
-		if r.Status == models.RecordingDiscarded || r.Status == models.RecordingActive {
+		if r.Status == models.RecordingDiscarded || r.Status == models.RecordingActive || r.Protected {
 			continue
 		}
-		if r.StorageKey != "" {
-			if err := s.blobs.Delete(ctx, r.StorageKey); err != nil {
-				return n, err
-			}
+		if err := s.deleteBlobs(ctx, r); err != nil {
+			return n, err
 		}
+		r.TranscriptKey = ""
 		r.Status = models.RecordingDiscarded
 		r.Error = "expired"
 		if err := s.recs.Update(ctx, &r); err != nil {
@@ -119,3 +370,29 @@ func (s *RecordingService) Cleanup(ctx context.Context, now time.Time) (int, err
 	}
 	return n, nil
 }
+
+// StorageReport aggregates the stored recordings by user and by connection,
+// keeping the top largest groups of each. Reclaimable bytes are those Cleanup
+// would free at now.
+func (s *RecordingService) StorageReport(ctx context.Context, now time.Time, top int) (store.RecordingUsage, error) {
+	ctx, cancel := WithTimeout(ctx, OpReport)
+	defer cancel()
+	u, err := s.recs.Usage(ctx, store.RecordingUsageQuery{Now: now, Top: top})
+	return u, timeoutError(ctx, err)
+}
+
+func (s *RecordingService) deleteBlobs(ctx context.Context, r models.Recording) error {
+	if r.StorageKey != "" {
+		blobs, err := s.recordingBlobs(r)
+		if err != nil {
+			return err
+		}
+		if err := blobs.Delete(ctx, r.StorageKey); err != nil {
+			return err
+		}
+	}
+	if r.TranscriptKey == "" {
+		return nil
+	}
+	return s.blobs.Delete(ctx, r.TranscriptKey)
+}
@@ -3,6 +3,7 @@ package service_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"testing"
 	"time"
@@ -170,6 +171,10 @@ func TestRecordingRetentionCleanup(t *testing.T) {
 	mk("r-future", &future, models.RecordingFinalized)
 	mk("r-active", &past, models.RecordingActive)
 	mk("r-kept", nil, models.RecordingFinalized) // no expiry → retained
+	mk("r-held", &past, models.RecordingFinalized)
+	if _, err := svc.SetProtected(ctx, "admin", "r-held", true); err != nil {
+		t.Fatalf("protect: %v", err)
+	}
 
 	n, err := svc.Cleanup(ctx, now)
 	if err != nil {
@@ -196,9 +201,111 @@ func TestRecordingRetentionCleanup(t *testing.T) {
 	if _, err := bs.Open(ctx, recording.StorageKey("c-op", "r-future", plugin.FormatAsciicastV2)); err != nil {
 		t.Errorf("future blob wrongly deleted: %v", err)
 	}
+	if held, _ := st.Recordings.Get(ctx, "r-held"); held.Status != models.RecordingFinalized || !held.Protected {
+		t.Errorf("protected recording should not be cleaned: %+v", held)
+	}
 
 	// Re-running cleanup does nothing (already discarded).
 	if n, _ := svc.Cleanup(ctx, now); n != 0 {
 		t.Errorf("second cleanup: want 0, got %d", n)
 	}
 }
+
+func TestRecordingArchive(t *testing.T) {
+	st := store.NewMemory()
+	primary, _ := recording.NewLocalBlobStore(t.TempDir())
+	archive, _ := recording.NewLocalBlobStore(t.TempDir())
+	svc := service.NewRecordingService(st.Recordings, primary, service.WithArchive(archive, 30*24*time.Hour))
+	ctx := context.Background()
+	now := time.Now()
+
+	mk := func(id string, ended time.Time, checksum string) string {
+		key := recording.StorageKey("c-op", id, plugin.FormatAsciicastV2)
+		w, _ := primary.Create(ctx, key)
+		_, _ = w.Write([]byte("[2,80,24]\n"))
+		_ = w.Close()
+		_ = st.Recordings.Create(ctx, &models.Recording{
+			ID: id, UserID: "op", ConnectionID: "c-op", Protocol: "ssh", Class: "terminal",
+			Format: "asciicast_v2", Status: models.RecordingFinalized, StartedAt: ended, EndedAt: &ended,
+			StorageKey: key, Checksum: checksum, ExpiresAt: &ended,
+		})
+		return key
+	}
+	oldKey := mk("r-old", now.Add(-40*24*time.Hour), "")
+	mk("r-recent", now.Add(-time.Hour), "")
+
+	if n, err := svc.Archive(ctx, now); err != nil || n != 1 {
+		t.Fatalf("archive: n=%d err=%v", n, err)
+	}
+	if _, err := primary.Open(ctx, oldKey); err == nil {
+		t.Error("primary copy kept after archiving")
+	}
+	rc, rec, err := svc.Content(ctx, op, "r-old")
+	if err != nil || rec.ArchivedAt == nil {
+		t.Fatalf("content of archived recording: %+v %v", rec, err)
+	}
+	b, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(b) != "[2,80,24]\n" {
+		t.Fatalf("archived content = %q", b)
+	}
+	if n, _ := svc.Archive(ctx, now); n != 0 {
+		t.Fatalf("second archive moved %d", n)
+	}
+
+	// A blob that does not match its checksum stays where it is.
+	badKey := mk("r-corrupt", now.Add(-50*24*time.Hour), "deadbeef")
+	if _, err := svc.Archive(ctx, now); err == nil {
+		t.Fatal("archive of a corrupt blob succeeded")
+	}
+	if r, _ := st.Recordings.Get(ctx, "r-corrupt"); r.ArchivedAt != nil {
+		t.Fatal("corrupt recording marked archived")
+	}
+	if _, err := primary.Open(ctx, badKey); err != nil {
+		t.Fatalf("corrupt primary copy removed: %v", err)
+	}
+	if _, err := archive.Open(ctx, badKey); err == nil {
+		t.Fatal("corrupt archive copy left behind")
+	}
+
+	// Retention purges archived and primary blobs alike.
+	if n, err := svc.Cleanup(ctx, now); err != nil || n != 3 {
+		t.Fatalf("cleanup: n=%d err=%v", n, err)
+	}
+	if _, err := archive.Open(ctx, oldKey); err == nil {
+		t.Error("archived blob kept after retention cleanup")
+	}
+}
+
+func TestRecordingEachPagesThroughActorScope(t *testing.T) {
+	svc, st, _ := newRecordingSvc(t)
+	ctx := context.Background()
+	start := time.Now().Add(-time.Hour)
+	for i := range 1203 {
+		userID := "op"
+		if i%100 == 0 {
+			userID = "other"
+		}
+		_ = st.Recordings.Create(ctx, &models.Recording{
+			ID: fmt.Sprintf("r%04d", i), UserID: userID, StartedAt: start.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	seen := map[string]bool{}
+	err := svc.Each(ctx, op, store.RecordingFilter{}, func(r models.Recording) error {
+		if r.UserID != "op" || seen[r.ID] {
+			t.Fatalf("unexpected row %s for %s", r.ID, r.UserID)
+		}
+		seen[r.ID] = true
+		return nil
+	})
+	if err != nil || len(seen) != 1190 {
+		t.Fatalf("each: %d rows, err=%v; want 1190", len(seen), err)
+	}
+
+	n := 0
+	_ = svc.Each(ctx, op, store.RecordingFilter{Limit: 501}, func(models.Recording) error { n++; return nil })
+	if n != 501 {
+		t.Fatalf("limited each: %d rows, want 501", n)
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+const (
+	// SettingSessionsMaxPerUser caps how many upstream sessions one user may
+	// hold at once across all connections; 0 means no cap.
+	SettingSessionsMaxPerUser = "sessions.max_per_user"
+	// SettingSessionsMaxPerRole holds a JSON object of role to cap that
+	// replaces the global cap for users with that role.
+	SettingSessionsMaxPerRole = "sessions.max_per_role"
+	// SettingSessionsExemptRoot lifts the cap for the root admin.
+	SettingSessionsExemptRoot = "sessions.exempt_root"
+)
+
+// DefaultMaxSessionsPerUser applies until an admin sets another cap.
+const DefaultMaxSessionsPerUser = 50
+
+// SessionCaps resolves each user's concurrent session cap from system
+// settings.
+type SessionCaps struct {
+	settings *SettingsService
+	users    store.UserStore
+	logger   *slog.Logger
+}
+
+func NewSessionCaps(settings *SettingsService, users store.UserStore, logger *slog.Logger) *SessionCaps {
+	return &SessionCaps{settings: settings, users: users, logger: logger}
+}
+
+// Limit returns how many sessions user may hold at once, 0 for no cap. A role
+// override wins over the global cap; with several roles the largest applies.
+func (c *SessionCaps) Limit(ctx context.Context, user models.User) (int, error) {
+	if user.Protected {
+		exempt, err := c.settings.Bool(ctx, SettingSessionsExemptRoot)
+		if err != nil {
+			return 0, err
+		}
+		if exempt {
+			return 0, nil
+		}
+	}
+	var perRole map[models.Role]int
+	if err := c.settings.JSON(ctx, SettingSessionsMaxPerRole, &perRole); err != nil {
+		return 0, err
+	}
+	limit := 0
+	for _, r := range user.Roles {
+		n, ok := perRole[r]
+		switch {
+		case !ok:
+		case n == 0:
+			return 0, nil
+		case n > limit:
+			limit = n
+		}
+	}
+	if limit > 0 {
+		return limit, nil
+	}
+	return c.settings.Int(ctx, SettingSessionsMaxPerUser)
+}
+
+// UserLimit is the session manager's UserLimit hook. A cap that cannot be
+// resolved falls back to the default rather than refusing every session.
+func (c *SessionCaps) UserLimit(ctx context.Context, userID string) int {
+	user, err := c.users.GetByID(ctx, userID)
+	if err != nil {
+		c.logger.Warn("resolve session cap", "user", userID, "err", err)
+		return DefaultMaxSessionsPerUser
+	}
+	limit, err := c.Limit(ctx, user)
+	if err != nil {
+		c.logger.Warn("resolve session cap", "user", userID, "err", err)
+		return DefaultMaxSessionsPerUser
+	}
+	return limit
+}
+
+func sessionCapSettings() []SettingDef {
+	return []SettingDef{
+		{
+			Key: SettingSessionsMaxPerUser, Type: SettingInt, Default: strconv.Itoa(DefaultMaxSessionsPerUser),
+			Description: "Most upstream sessions one user may hold at once across all connections; 0 removes the cap.",
+			Validate: func(v string) error {
+				if n, err := strconv.Atoi(v); err != nil || n < 0 {
+					return fmt.Errorf("must be a whole number of at least 0")
+				}
+				return nil
+			},
+		},
+		{
+			Key: SettingSessionsMaxPerRole, Type: SettingJSON, Default: "{}",
+			Description: "Per-role session caps that replace the global cap, e.g. {\"viewer\": 5}; 0 removes the cap for that role.",
+			Validate: func(v string) error {
+				var m map[models.Role]int
+				if err := json.Unmarshal([]byte(v), &m); err != nil {
+					return err
+				}
+				for r, n := range m {
+					if n < 0 {
+						return fmt.Errorf("cap for role %q must be at least 0", r)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Key: SettingSessionsExemptRoot, Type: SettingBool, Default: "false", RootOnly: true,
+			Description: "Let the root admin open sessions beyond the per-user cap.",
+		},
+	}
+}
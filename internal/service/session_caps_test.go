@@ -0,0 +1,67 @@
+package service_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+func TestSessionCapsResolveRoleOverridesAndRootExemption(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	settings := service.NewSettingsService(st.SystemSettings)
+	settings.Register(service.BuiltinSettings()...)
+	caps := service.NewSessionCaps(settings, st.Users, slog.New(slog.DiscardHandler))
+	set := func(key, value string) {
+		t.Helper()
+		if err := settings.Set(ctx, &models.SystemSetting{Key: key, Value: value}); err != nil {
+			t.Fatalf("set %s: %v", key, err)
+		}
+	}
+	limit := func(u models.User) int {
+		t.Helper()
+		n, err := caps.Limit(ctx, u)
+		if err != nil {
+			t.Fatalf("limit: %v", err)
+		}
+		return n
+	}
+	viewer := models.User{ID: "v", Roles: []models.Role{models.RoleViewer}}
+	both := models.User{ID: "b", Roles: []models.Role{models.RoleViewer, models.RoleOperator}}
+	root := models.User{ID: "r", Roles: []models.Role{models.RoleAdmin}, Protected: true}
+
+	if got := limit(viewer); got != service.DefaultMaxSessionsPerUser {
+		t.Fatalf("default cap = %d", got)
+	}
+	set(service.SettingSessionsMaxPerUser, "10")
+	set(service.SettingSessionsMaxPerRole, `{"viewer":3,"operator":20}`)
+	if got := limit(viewer); got != 3 {
+		t.Fatalf("viewer cap = %d, want 3", got)
+	}
+	if got := limit(both); got != 20 {
+		t.Fatalf("largest role cap should apply, got %d", got)
+	}
+	if got := limit(root); got != 10 {
+		t.Fatalf("root without exemption = %d, want the global 10", got)
+	}
+	set(service.SettingSessionsExemptRoot, "true")
+	if got := limit(root); got != 0 {
+		t.Fatalf("exempt root = %d, want no cap", got)
+	}
+	set(service.SettingSessionsMaxPerRole, `{"operator":0}`)
+	if got := limit(both); got != 0 {
+		t.Fatalf("an uncapped role should lift the cap, got %d", got)
+	}
+
+	_ = st.Users.Create(ctx, &models.User{ID: "v", Username: "v", Roles: []models.Role{models.RoleViewer}}, "")
+	if got := caps.UserLimit(ctx, "v"); got != 10 {
+		t.Fatalf("UserLimit = %d, want 10", got)
+	}
+	if got := caps.UserLimit(ctx, "missing"); got != service.DefaultMaxSessionsPerUser {
+		t.Fatalf("unknown user = %d, want the default", got)
+	}
+}
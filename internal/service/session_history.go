@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/session"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+// DefaultSessionFlushInterval is the least time between two traffic writes
+// for one live session.
+const DefaultSessionFlushInterval = 30 * time.Second
+
+// SessionReasonServerRestart ends a session row whose server stopped without
+// closing it.
+const SessionReasonServerRestart = "server_restart"
+
+// sessionStaleFlushes is how many flush intervals an active row may go
+// unwritten before Reconcile treats its server as gone.
+const sessionStaleFlushes = 3
+
+type SessionHistoryOption func(*SessionHistory)
+
+// WithSessionFlushInterval sets how often a live session's byte counts may be
+// written.
+func WithSessionFlushInterval(d time.Duration) SessionHistoryOption {
+	return func(h *SessionHistory) {
+		if d > 0 {
+			h.interval = d
+		}
+	}
+}
+
+func WithSessionHistoryLogger(l *slog.Logger) SessionHistoryOption {
+	return func(h *SessionHistory) { h.logger = l }
+}
+
+// WithSessionHistoryObservations lets Reconcile end the observations of the
+// sessions it fails.
+func WithSessionHistoryObservations(obs store.SessionObservationStore) SessionHistoryOption {
+	return func(h *SessionHistory) { h.observations = obs }
+}
+
+// SessionHistory keeps a connection_sessions row for every upstream session.
+// The session manager's hooks only queue work and Flush does the writes, so a
+// live session costs one UPDATE per flush interval, which doubles as the
+// heartbeat Reconcile goes by.
+type SessionHistory struct {
+	rows         store.ConnectionSessionStore
+	observations store.SessionObservationStore
+	logger       *slog.Logger
+	interval     time.Duration
+	now          func() time.Time
+
+	// flushMu serializes Flush, which alone touches a row's saved state.
+	flushMu sync.Mutex
+	mu      sync.Mutex
+	live    map[string]*historyRow
+	ended   []endedSession
+}
+
+type historyRow struct {
+	opened            session.Snapshot
+	created           bool
+	savedIn, savedOut int64
+	savedAt           time.Time
+}
+
+type endedSession struct {
+	snap session.Snapshot
+	at   time.Time
+}
+
+func NewSessionHistory(rows store.ConnectionSessionStore, opts ...SessionHistoryOption) *SessionHistory {
+	h := &SessionHistory{
+		rows: rows, logger: slog.Default(), interval: DefaultSessionFlushInterval, now: time.Now,
+		live: map[string]*historyRow{},
+	}
+	for _, o := range opts {
+		o(h)
+	}
+	return h
+}
+
+// SessionStarted is the session manager's open hook.
+func (h *SessionHistory) SessionStarted(snap session.Snapshot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.live[snap.ID]; !ok {
+		h.live[snap.ID] = &historyRow{opened: snap}
+	}
+}
+
+// SessionClosed is the session manager's close hook.
+func (h *SessionHistory) SessionClosed(snap session.Snapshot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ended = append(h.ended, endedSession{snap: snap, at: h.now()})
+}
+
+// Flush creates rows for sessions opened since the last flush, writes the
+// byte counts of active sessions not written within the flush interval, even
+// when unchanged, and
+// records the final state of sessions that closed. Call it once more after
+// the session manager shuts down to keep the last counts.
+func (h *SessionHistory) Flush(ctx context.Context, active []session.Snapshot) {
+	h.flushMu.Lock()
+	defer h.flushMu.Unlock()
+	now := h.now()
+	h.mu.Lock()
+	ended := h.ended
+	h.ended = nil
+	rows := make(map[string]*historyRow, len(h.live))
+	for id, r := range h.live {
+		rows[id] = r
+	}
+	for _, e := range ended {
+		delete(h.live, e.snap.ID)
+	}
+	h.mu.Unlock()
+
+	for id, r := range rows {
+		if r.created {
+			continue
+		}
+		err := h.rows.Create(ctx, &models.ConnectionSession{
+			ID: id, ConnectionID: r.opened.Key.ConnectionID, UserID: r.opened.UserID,
+			Protocol: SnapshotProtocol(r.opened), Status: models.ConnectionSessionActive,
+			StartedAt: r.opened.CreatedAt, UpdatedAt: now,
+		})
+		if err != nil {
+			h.logger.Warn("record connection session", "session", id, "err", err)
+			continue
+		}
+		r.created, r.savedAt = true, now
+	}
+	for _, snap := range active {
+		r, ok := rows[snap.ID]
+		if !ok || !r.created || now.Sub(r.savedAt) < h.interval {
+			continue
+		}
+		if err := h.rows.UpdateTraffic(ctx, snap.ID, snap.BytesIn, snap.BytesOut, now); err != nil {
+			h.logger.Warn("update connection session traffic", "session", snap.ID, "err", err)
+			continue
+		}
+		r.savedIn, r.savedOut, r.savedAt = snap.BytesIn, snap.BytesOut, now
+	}
+	for _, e := range ended {
+		if r, ok := rows[e.snap.ID]; !ok || !r.created {
+			continue
+		}
+		status := models.ConnectionSessionClosed
+		if e.snap.State == session.StateError {
+			status = models.ConnectionSessionFailed
+		}
+		if err := h.rows.End(ctx, e.snap.ID, status, e.snap.Reason, e.snap.BytesIn, e.snap.BytesOut, e.at); err != nil {
+			h.logger.Warn("end connection session", "session", e.snap.ID, "err", err)
+		}
+	}
+}
+
+// Reconcile fails the active rows no server has written for a few flush
+// intervals, e.g. those left by a crash, and ends their observations. Rows of
+// this process's own live sessions are never touched. It returns how many
+// rows it failed.
+func (h *SessionHistory) Reconcile(ctx context.Context) (int, error) {
+	h.flushMu.Lock()
+	defer h.flushMu.Unlock()
+	now := h.now()
+	stale, err := h.rows.ListStale(ctx, now.Add(-sessionStaleFlushes*h.interval))
+	if err != nil {
+		return 0, err
+	}
+	h.mu.Lock()
+	live := make(map[string]bool, len(h.live))
+	for id := range h.live {
+		live[id] = true
+	}
+	h.mu.Unlock()
+	n := 0
+	for _, cs := range stale {
+		if live[cs.ID] {
+			continue
+		}
+		// The last write is the last time the session was known to be up.
+		err := h.rows.End(ctx, cs.ID, models.ConnectionSessionFailed, SessionReasonServerRestart, cs.BytesIn, cs.BytesOut, cs.UpdatedAt)
+		if err != nil {
+			return n, err
+		}
+		n++
+		if h.observations == nil {
+			continue
+		}
+		if _, err := h.observations.EndBySession(ctx, cs.ID, cs.UpdatedAt); err != nil {
+			h.logger.Warn("end observations of a stale session", "session", cs.ID, "err", err)
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,126 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/session"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+type countingSessionRows struct {
+	store.ConnectionSessionStore
+	updates int
+}
+
+func (s *countingSessionRows) UpdateTraffic(ctx context.Context, id string, in, out int64, at time.Time) error {
+	s.updates++
+	return s.ConnectionSessionStore.UpdateTraffic(ctx, id, in, out, at)
+}
+
+func TestSessionHistoryThrottlesTrafficWrites(t *testing.T) {
+	ctx := context.Background()
+	rows := &countingSessionRows{ConnectionSessionStore: store.NewMemory().ConnectionSessions}
+	h := service.NewSessionHistory(rows, service.WithSessionFlushInterval(time.Hour))
+	started := time.Now().Add(-time.Minute)
+	snap := session.Snapshot{
+		ID: "s1", Key: session.Key{ConnectionID: "c1", ActorScope: "u1"}, UserID: "u1",
+		State: session.StateConnected, CreatedAt: started,
+		Metadata: map[string]any{service.MetadataConnectionSnapshot: models.ConnectionSnapshot{Protocol: "ssh"}},
+	}
+	h.SessionStarted(snap)
+	h.Flush(ctx, []session.Snapshot{snap})
+	row, err := rows.Get(ctx, "s1")
+	if err != nil || row.Status != models.ConnectionSessionActive || row.Protocol != "ssh" || !row.StartedAt.Equal(started) {
+		t.Fatalf("row after open: %+v err=%v", row, err)
+	}
+
+	// A chatty session inside the flush interval writes nothing.
+	for i := range 100 {
+		snap.BytesIn, snap.BytesOut = int64(i), int64(i*100)
+		h.Flush(ctx, []session.Snapshot{snap})
+	}
+	if rows.updates != 0 {
+		t.Fatalf("traffic written %d times inside the flush interval", rows.updates)
+	}
+
+	snap.BytesIn, snap.BytesOut, snap.State = 120, 64000, session.StateClosed
+	h.SessionClosed(snap)
+	h.Flush(ctx, nil)
+	row, err = rows.Get(ctx, "s1")
+	if err != nil || row.Status != models.ConnectionSessionClosed || row.EndedAt == nil ||
+		row.BytesIn != 120 || row.BytesOut != 64000 {
+		t.Fatalf("row after close: %+v err=%v", row, err)
+	}
+}
+
+func TestSessionHistoryWritesTrafficEachInterval(t *testing.T) {
+	ctx := context.Background()
+	rows := &countingSessionRows{ConnectionSessionStore: store.NewMemory().ConnectionSessions}
+	h := service.NewSessionHistory(rows, service.WithSessionFlushInterval(time.Millisecond))
+	snap := session.Snapshot{ID: "s1", Key: session.Key{ConnectionID: "c1", ActorScope: "u1"}, State: session.StateConnected}
+	h.SessionStarted(snap)
+	h.Flush(ctx, nil)
+	created, _ := rows.Get(ctx, "s1")
+	time.Sleep(2 * time.Millisecond)
+	// Unchanged counts are still written, as the row's heartbeat.
+	h.Flush(ctx, []session.Snapshot{snap})
+	if row, _ := rows.Get(ctx, "s1"); rows.updates != 1 || !row.UpdatedAt.After(created.UpdatedAt) {
+		t.Fatalf("heartbeat: updates=%d row=%+v", rows.updates, row)
+	}
+	snap.BytesOut = 42
+	time.Sleep(2 * time.Millisecond)
+	h.Flush(ctx, []session.Snapshot{snap})
+	if row, _ := rows.Get(ctx, "s1"); rows.updates != 2 || row.BytesOut != 42 {
+		t.Fatalf("updates=%d row=%+v", rows.updates, row)
+	}
+}
+
+func TestSessionHistoryReconcileFailsStaleRows(t *testing.T) {
+	ctx := context.Background()
+	mem := store.NewMemory()
+	h := service.NewSessionHistory(mem.ConnectionSessions, service.WithSessionFlushInterval(time.Minute),
+		service.WithSessionHistoryObservations(mem.SessionObservations))
+	now := time.Now()
+	lastSeen := now.Add(-time.Hour)
+	for _, cs := range []models.ConnectionSession{
+		{ID: "orphan", UpdatedAt: lastSeen, BytesOut: 7},
+		{ID: "recent", UpdatedAt: now},
+	} {
+		cs.ConnectionID, cs.UserID, cs.Status, cs.StartedAt = "c1", "u1", models.ConnectionSessionActive, lastSeen
+		if err := mem.ConnectionSessions.Create(ctx, &cs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mem.SessionObservations.Create(ctx, &models.SessionObservation{ID: "o1", SessionID: "orphan", StartedAt: lastSeen}); err != nil {
+		t.Fatal(err)
+	}
+	// A live session of this process is never failed, however old its row.
+	live := session.Snapshot{ID: "live", Key: session.Key{ConnectionID: "c1", ActorScope: "u1"}, State: session.StateConnected}
+	h.SessionStarted(live)
+	h.Flush(ctx, nil)
+	if err := mem.ConnectionSessions.UpdateTraffic(ctx, "live", 0, 0, lastSeen); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := h.Reconcile(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("reconcile: n=%d err=%v", n, err)
+	}
+	row, _ := mem.ConnectionSessions.Get(ctx, "orphan")
+	if row.Status != models.ConnectionSessionFailed || row.Reason != service.SessionReasonServerRestart ||
+		row.EndedAt == nil || !row.EndedAt.Equal(lastSeen) || row.BytesOut != 7 {
+		t.Fatalf("orphan row: %+v", row)
+	}
+	if o, _ := mem.SessionObservations.Get(ctx, "o1"); o.EndedAt == nil {
+		t.Fatal("the orphan's observation should have ended")
+	}
+	for _, id := range []string{"recent", "live"} {
+		if row, _ := mem.ConnectionSessions.Get(ctx, id); row.Status != models.ConnectionSessionActive {
+			t.Fatalf("%s row: %+v", id, row)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/session"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// LaunchEvent reports a background session launch moving on: a new
+// stage, the session connecting, or the launch failing.
+type LaunchEvent struct {
+	SessionID    string `json:"sessionId"`
+	ConnectionID string `json:"connectionId"`
+	State        string `json:"state"`
+	Stage        string `json:"stage,omitempty"`
+	// Failure is the category of a failed launch and Reason its error.
+	Failure string    `json:"failure,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// SessionLaunches relays the session manager's launch hook to the launching
+// user's event stream.
+type SessionLaunches struct {
+	events userHub[LaunchEvent]
+}
+
+func NewSessionLaunches() *SessionLaunches { return &SessionLaunches{} }
+
+// Launched is the session manager's OnLaunch hook.
+func (l *SessionLaunches) Launched(snap session.Snapshot) {
+	l.events.publish(snap.UserID, LaunchEvent{
+		SessionID: snap.ID, ConnectionID: snap.Key.ConnectionID,
+		State: string(snap.State), Stage: string(snap.Stage),
+		Failure: LaunchFailure(snap), Reason: snap.Reason, At: time.Now(),
+	})
+}
+
+// Subscribe streams launch events for sessions userID opens.
+func (l *SessionLaunches) Subscribe(userID string) (Feed[LaunchEvent], func()) {
+	return l.events.subscribe(userID)
+}
+
+// LaunchFailure is the failure category of a failed session, or "" when it
+// did not fail or could not be classified. A launch the janitor gave up on
+// counts as a timeout.
+func LaunchFailure(snap session.Snapshot) string {
+	if snap.State != session.StateError {
+		return ""
+	}
+	if c, ok := snap.Metadata[MetadataLaunchFailure].(string); ok {
+		return c
+	}
+	if snap.Reason == session.ErrLaunchTimeout.Error() {
+		return string(plugin.FailureTimeout)
+	}
+	return ""
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/session"
+)
+
+// otherProtocol labels sessions whose protocol is not a registered plugin, so
+// label values stay bounded by the registry.
+const otherProtocol = "other"
+
+// SessionMetricsSink receives per-protocol session metrics; telemetry.Metrics
+// satisfies it.
+type SessionMetricsSink interface {
+	SessionOpened(protocol string)
+	SessionClosed(protocol, status string, d time.Duration)
+	SetSessionAges(ages map[string][]time.Duration)
+	AddSessionBytes(protocol string, in, out int64)
+}
+
+// SessionMetrics turns the session manager's open and close hooks into
+// per-protocol metrics. The protocol label is fixed when a session opens so
+// the live gauge balances even if its plugin is unregistered meanwhile.
+// Traffic is counted as the growth of each session's byte totals between
+// samples and at close.
+type SessionMetrics struct {
+	sink  SessionMetricsSink
+	known func(protocol string) bool
+	now   func() time.Time
+
+	mu   sync.Mutex
+	open map[session.Key]*meteredSession
+}
+
+type meteredSession struct {
+	id       string
+	protocol string
+	in, out  int64
+}
+
+// advance returns the traffic since the last call.
+func (s *meteredSession) advance(snap session.Snapshot) (int64, int64) {
+	in, out := snap.BytesIn-s.in, snap.BytesOut-s.out
+	s.in, s.out = snap.BytesIn, snap.BytesOut
+	return in, out
+}
+
+// NewSessionMetrics reports to sink; known says whether a protocol is a
+// registered plugin.
+func NewSessionMetrics(sink SessionMetricsSink, known func(protocol string) bool) *SessionMetrics {
+	return &SessionMetrics{sink: sink, known: known, now: time.Now, open: map[session.Key]*meteredSession{}}
+}
+
+// SessionStarted is the session manager's open hook.
+func (m *SessionMetrics) SessionStarted(snap session.Snapshot) {
+	protocol := m.label(snap)
+	m.mu.Lock()
+	_, dup := m.open[snap.Key]
+	if !dup {
+		m.open[snap.Key] = &meteredSession{id: snap.ID, protocol: protocol}
+	}
+	m.mu.Unlock()
+	if !dup {
+		m.sink.SessionOpened(protocol)
+	}
+}
+
+// SessionClosed is the session manager's close hook.
+func (m *SessionMetrics) SessionClosed(snap session.Snapshot) {
+	m.mu.Lock()
+	ms, ok := m.open[snap.Key]
+	delete(m.open, snap.Key)
+	var in, out int64
+	if ok {
+		in, out = ms.advance(snap)
+	}
+	m.mu.Unlock()
+	if !ok {
+		return
+	}
+	m.sink.AddSessionBytes(ms.protocol, in, out)
+	m.sink.SessionClosed(ms.protocol, string(snap.State), m.now().Sub(snap.CreatedAt))
+}
+
+// Sample publishes the age distribution of the given live sessions and the
+// traffic they relayed since the last sample.
+func (m *SessionMetrics) Sample(active []session.Snapshot) {
+	now := m.now()
+	ages := map[string][]time.Duration{}
+	type traffic struct{ in, out int64 }
+	bytes := map[string]traffic{}
+	m.mu.Lock()
+	for _, snap := range active {
+		protocol := m.label(snap)
+		ages[protocol] = append(ages[protocol], now.Sub(snap.CreatedAt))
+		if ms, ok := m.open[snap.Key]; ok && ms.id == snap.ID {
+			in, out := ms.advance(snap)
+			t := bytes[ms.protocol]
+			bytes[ms.protocol] = traffic{t.in + in, t.out + out}
+		}
+	}
+	m.mu.Unlock()
+	m.sink.SetSessionAges(ages)
+	for protocol, t := range bytes {
+		m.sink.AddSessionBytes(protocol, t.in, t.out)
+	}
+}
+
+func (m *SessionMetrics) label(snap session.Snapshot) string {
+	protocol := SnapshotProtocol(snap)
+	if protocol == "" || m.known == nil || !m.known(protocol) {
+		return otherProtocol
+	}
+	return protocol
+}
+
+// SnapshotProtocol is the protocol of the connection a session was launched
+// for, or "" when the launch recorded no connection snapshot.
+func SnapshotProtocol(snap session.Snapshot) string {
+	cs, _ := snap.Metadata[MetadataConnectionSnapshot].(models.ConnectionSnapshot)
+	return cs.Protocol
+}
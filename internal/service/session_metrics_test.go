@@ -0,0 +1,192 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/session"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+type metricsSink struct {
+	mu     sync.Mutex
+	active map[string]int
+	closed map[string]int
+	ages   map[string][]time.Duration
+	bytes  map[string]int64
+}
+
+func (s *metricsSink) SessionOpened(protocol string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active[protocol]++
+}
+
+func (s *metricsSink) SessionClosed(protocol, status string, _ time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active[protocol]--
+	s.closed[protocol+"/"+status]++
+}
+
+func (s *metricsSink) SetSessionAges(ages map[string][]time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ages = ages
+}
+
+func (s *metricsSink) AddSessionBytes(protocol string, in, out int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bytes == nil {
+		s.bytes = map[string]int64{}
+	}
+	s.bytes[protocol+"/in"] += in
+	s.bytes[protocol+"/out"] += out
+}
+
+func (s *metricsSink) gauge(protocol string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active[protocol]
+}
+
+type stubSession struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (s *stubSession) HealthCheck(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *stubSession) OpenChannel(context.Context, plugin.ChannelRequest) (plugin.Channel, error) {
+	return nil, errors.New("unsupported")
+}
+
+func (s *stubSession) Close() error { return nil }
+
+func TestSessionMetricsGaugeTracksLifecycle(t *testing.T) {
+	sink := &metricsSink{active: map[string]int{}, closed: map[string]int{}}
+	sm := service.NewSessionMetrics(sink, func(p string) bool { return p == "ssh" })
+	m := session.New(session.Options{
+		IdleTimeout: 50 * time.Millisecond, HealthInterval: 10 * time.Millisecond,
+		OnOpen: sm.SessionStarted, OnClose: sm.SessionClosed,
+	})
+	defer m.Shutdown()
+
+	acquire := func(conn, protocol string, sess plugin.Session) {
+		t.Helper()
+		_, err := m.Acquire(context.Background(), session.Key{ConnectionID: conn, ActorScope: "u1"}, "u1",
+			func(ctx context.Context) (plugin.Session, error) {
+				session.SetMetadata(ctx, service.MetadataConnectionSnapshot, models.ConnectionSnapshot{Protocol: protocol})
+				return sess, nil
+			})
+		if err != nil {
+			t.Fatalf("acquire %s: %v", conn, err)
+		}
+	}
+	acquire("a", "ssh", &stubSession{})
+	acquire("b", "ssh", &stubSession{})
+	acquire("c", "mystery", &stubSession{})
+	if sink.gauge("ssh") != 2 || sink.gauge("other") != 1 {
+		t.Fatalf("after open: %v", sink.active)
+	}
+
+	sm.Sample(m.Active())
+	sink.mu.Lock()
+	if len(sink.ages["ssh"]) != 2 || len(sink.ages["other"]) != 1 {
+		t.Fatalf("sampled ages: %v", sink.ages)
+	}
+	sink.mu.Unlock()
+
+	m.Close(session.Key{ConnectionID: "a", ActorScope: "u1"})
+	if sink.gauge("ssh") != 1 {
+		t.Fatalf("after close: %v", sink.active)
+	}
+
+	// The janitor reclaims the idle ones.
+	deadline := time.Now().Add(2 * time.Second)
+	for sink.gauge("ssh") != 0 || sink.gauge("other") != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("idle sweep left gauges at %v", sink.active)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if sink.closed["ssh/closed"] != 2 || sink.closed["other/closed"] != 1 {
+		t.Fatalf("durations by status: %v", sink.closed)
+	}
+}
+
+func TestSessionMetricsGaugeOnHealthFailure(t *testing.T) {
+	sink := &metricsSink{active: map[string]int{}, closed: map[string]int{}}
+	sm := service.NewSessionMetrics(sink, func(string) bool { return true })
+	m := session.New(session.Options{
+		HealthInterval: 10 * time.Millisecond,
+		OnOpen:         sm.SessionStarted, OnClose: sm.SessionClosed,
+	})
+	defer m.Shutdown()
+
+	sess := &stubSession{}
+	_, err := m.Acquire(context.Background(), session.Key{ConnectionID: "a", ActorScope: "u1"}, "u1",
+		func(ctx context.Context) (plugin.Session, error) {
+			session.SetMetadata(ctx, service.MetadataConnectionSnapshot, models.ConnectionSnapshot{Protocol: "rdp"})
+			return sess, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sink.gauge("rdp") != 1 {
+		t.Fatalf("after open: %v", sink.active)
+	}
+	sess.mu.Lock()
+	sess.err = errors.New("gone")
+	sess.mu.Unlock()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for sink.gauge("rdp") != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("failed health check left gauge at %v", sink.active)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if sink.closed["rdp/error"] != 1 {
+		t.Fatalf("durations by status: %v", sink.closed)
+	}
+}
+
+func TestSessionMetricsCountsTrafficOnce(t *testing.T) {
+	sink := &metricsSink{active: map[string]int{}, closed: map[string]int{}}
+	sm := service.NewSessionMetrics(sink, func(string) bool { return true })
+	snap := session.Snapshot{
+		ID: "s1", Key: session.Key{ConnectionID: "a", ActorScope: "u1"}, State: session.StateConnected,
+		Metadata: map[string]any{service.MetadataConnectionSnapshot: models.ConnectionSnapshot{Protocol: "ssh"}},
+	}
+	sm.SessionStarted(snap)
+	snap.BytesIn, snap.BytesOut = 10, 500
+	sm.Sample([]session.Snapshot{snap})
+	sm.Sample([]session.Snapshot{snap})
+	stale := snap
+	stale.ID = "old"
+	stale.BytesOut = 9000
+	sm.Sample([]session.Snapshot{stale})
+	snap.BytesIn, snap.BytesOut, snap.State = 15, 800, session.StateClosed
+	sm.SessionClosed(snap)
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if sink.bytes["ssh/in"] != 15 || sink.bytes["ssh/out"] != 800 {
+		t.Fatalf("traffic = %v, want 15 in and 800 out", sink.bytes)
+	}
+}
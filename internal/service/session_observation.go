@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/session"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+// SettingObservationDisclose makes observed sessions' owners get notified.
+const SettingObservationDisclose = "observation.disclose"
+
+// ObservationNotice tells a session's owner that someone started or stopped
+// observing it.
+type ObservationNotice struct {
+	ID           string    `json:"id"`
+	SessionID    string    `json:"sessionId"`
+	ConnectionID string    `json:"connectionId"`
+	ObserverID   string    `json:"observerId"`
+	Active       bool      `json:"active"`
+	At           time.Time `json:"at"`
+}
+
+// SessionObservationService logs read-only observers of live sessions and,
+// when the disclosure setting is on, tells the owner about them.
+type SessionObservationService struct {
+	rows     store.SessionObservationStore
+	settings *SettingsService
+	now      func() time.Time
+	notices  userHub[ObservationNotice]
+}
+
+func NewSessionObservationService(rows store.SessionObservationStore, settings *SettingsService) *SessionObservationService {
+	return &SessionObservationService{rows: rows, settings: settings, now: time.Now}
+}
+
+// Start records observerID attaching to the session snap describes.
+func (s *SessionObservationService) Start(ctx context.Context, observerID string, snap session.Snapshot) (models.SessionObservation, error) {
+	disclose := true
+	if s.settings != nil {
+		v, err := s.settings.Bool(ctx, SettingObservationDisclose)
+		if err != nil {
+			return models.SessionObservation{}, err
+		}
+		disclose = v
+	}
+	o := models.SessionObservation{
+		ID: uuid.NewString(), SessionID: snap.ID, ConnectionID: snap.Key.ConnectionID,
+		ObserverID: observerID, OwnerID: snap.UserID, Disclosed: disclose, StartedAt: s.now(),
+	}
+	if err := s.rows.Create(ctx, &o); err != nil {
+		return models.SessionObservation{}, err
+	}
+	s.notify(o, true, o.StartedAt)
+	return o, nil
+}
+
+// End records the observer detaching.
+func (s *SessionObservationService) End(ctx context.Context, o models.SessionObservation) error {
+	at := s.now()
+	if err := s.rows.End(ctx, o.ID, at); err != nil {
+		return err
+	}
+	s.notify(o, false, at)
+	return nil
+}
+
+// SubscribeNotices streams observation notices for sessions userID owns.
+func (s *SessionObservationService) SubscribeNotices(userID string) (Feed[ObservationNotice], func()) {
+	return s.notices.subscribe(userID)
+}
+
+func (s *SessionObservationService) notify(o models.SessionObservation, active bool, at time.Time) {
+	if !o.Disclosed || o.OwnerID == o.ObserverID {
+		return
+	}
+	s.notices.publish(o.OwnerID, ObservationNotice{
+		ID: o.ID, SessionID: o.SessionID, ConnectionID: o.ConnectionID, ObserverID: o.ObserverID, Active: active, At: at,
+	})
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/session"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	defaultShareLinkTTL = time.Hour
+	maxShareLinkTTL     = 24 * time.Hour
+	maxShareLinkUses    = 50
+
+	// DefaultWriteRequestTTL is how long a request for write access waits for
+	// an answer.
+	DefaultWriteRequestTTL = 2 * time.Minute
+)
+
+// SessionShareInput configures a new share link. Zero values mean a single use
+// and a one-hour expiry.
+type SessionShareInput struct {
+	Mode             models.ShareMode `json:"mode"`
+	MaxUses          int              `json:"maxUses,omitempty"`
+	ExpiresInSeconds int              `json:"expiresInSeconds,omitempty"`
+}
+
+// SessionShareService issues join links for a user's live session. Joining
+// makes the user a participant of that session and grants nothing on the
+// connection; the links, participants and write requests go when the
+// creator's session closes.
+type SessionShareService struct {
+	links        store.SessionShareLinkStore
+	participants store.SessionParticipantStore
+	requests     store.SessionWriteRequestStore
+	logger       *slog.Logger
+	now          func() time.Time
+	writeTTL     time.Duration
+
+	mu          sync.Mutex
+	expiries    map[string]*time.Timer
+	writeEvents userHub[models.SessionWriteRequest]
+	removals    userHub[models.SessionParticipant]
+}
+
+// SessionShareOption configures a SessionShareService.
+type SessionShareOption func(*SessionShareService)
+
+// WithWriteRequestTTL sets how long a request for write access stays pending.
+func WithWriteRequestTTL(d time.Duration) SessionShareOption {
+	return func(s *SessionShareService) {
+		if d > 0 {
+			s.writeTTL = d
+		}
+	}
+}
+
+func NewSessionShareService(links store.SessionShareLinkStore, participants store.SessionParticipantStore, requests store.SessionWriteRequestStore, logger *slog.Logger, opts ...SessionShareOption) *SessionShareService {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	s := &SessionShareService{
+		links: links, participants: participants, requests: requests, logger: logger, now: time.Now,
+		writeTTL: DefaultWriteRequestTTL, expiries: map[string]*time.Timer{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Create issues a link on connID for ownerID's session; creatorID is the owner
+// or the session's write holder. The raw token is returned only here; the
+// store keeps its hash.
+func (s *SessionShareService) Create(ctx context.Context, connID, ownerID, creatorID string, in SessionShareInput) (models.SessionShareLink, string, error) {
+	if !in.Mode.Valid() {
+		return models.SessionShareLink{}, "", fmt.Errorf("%w: mode must be %q or %q", plugin.ErrInvalidInput, models.ShareRead, models.ShareWrite)
+	}
+	uses := in.MaxUses
+	if uses == 0 {
+		uses = 1
+	}
+	if uses < 0 || uses > maxShareLinkUses {
+		return models.SessionShareLink{}, "", fmt.Errorf("%w: maxUses must be between 1 and %d", plugin.ErrInvalidInput, maxShareLinkUses)
+	}
+	ttl := time.Duration(in.ExpiresInSeconds) * time.Second
+	if ttl == 0 {
+		ttl = defaultShareLinkTTL
+	}
+	if ttl < 0 || ttl > maxShareLinkTTL {
+		return models.SessionShareLink{}, "", fmt.Errorf("%w: expiry must be within %s", plugin.ErrInvalidInput, maxShareLinkTTL)
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return models.SessionShareLink{}, "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(tokenBytes)
+	now := s.now()
+	link := models.SessionShareLink{
+		ID: uuid.NewString(), ConnectionID: connID, OwnerID: ownerID, CreatedBy: creatorID, TokenHash: hashToken(token),
+		Mode: in.Mode, MaxUses: uses, ExpiresAt: now.Add(ttl), CreatedAt: now,
+	}
+	if err := s.links.Create(ctx, &link); err != nil {
+		return models.SessionShareLink{}, "", err
+	}
+	return link, token, nil
+}
+
+// List returns the links issued on a connection, newest first.
+func (s *SessionShareService) List(ctx context.Context, connID string) ([]models.SessionShareLinkSummary, error) {
+	list, err := s.links.ListByConnection(ctx, connID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]models.SessionShareLinkSummary, 0, len(list))
+	for _, l := range list {
+		out = append(out, l.Summary())
+	}
+	return out, nil
+}
+
+// Revoke invalidates a link on connID and removes the participants who
+// joined through it.
+func (s *SessionShareService) Revoke(ctx context.Context, connID, id string) error {
+	link, err := s.links.Get(ctx, id)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && link.ConnectionID != connID) {
+		return plugin.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.links.Revoke(ctx, id, s.now()); err != nil {
+		return err
+	}
+	parts, err := s.participants.ListByConnection(ctx, connID)
+	if err != nil {
+		return err
+	}
+	if err := s.participants.DeleteByLink(ctx, id); err != nil {
+		return err
+	}
+	for _, p := range parts {
+		if p.ShareLinkID == id {
+			s.removals.publish(p.UserID, p)
+		}
+	}
+	return nil
+}
+
+// Removals subscribes to userID being removed from shared sessions, so their
+// open streams of those sessions can close.
+func (s *SessionShareService) Removals(userID string) (Feed[models.SessionParticipant], func()) {
+	return s.removals.subscribe(userID)
+}
+
+// Join redeems a token for userID, making them a participant of the link
+// owner's live session. A write link also hands them write. The owner and
+// users who already joined cannot join again. Once the token matches a link,
+// that link is returned along with any error.
+func (s *SessionShareService) Join(ctx context.Context, token, userID string) (models.SessionShareLink, models.SessionParticipant, error) {
+	invalid := fmt.Errorf("%w: share link is invalid or has expired", plugin.ErrNotFound)
+	link, err := s.links.GetByTokenHash(ctx, hashToken(token))
+	if errors.Is(err, store.ErrNotFound) {
+		return models.SessionShareLink{}, models.SessionParticipant{}, invalid
+	}
+	if err != nil {
+		return models.SessionShareLink{}, models.SessionParticipant{}, err
+	}
+	if !link.Active(s.now()) {
+		return link, models.SessionParticipant{}, invalid
+	}
+	ownerID := link.SessionOwner()
+	if ownerID == userID {
+		return link, models.SessionParticipant{}, fmt.Errorf("%w: you already own this session", plugin.ErrConflict)
+	}
+	if _, err := s.participants.Get(ctx, link.ConnectionID, ownerID, userID); err == nil {
+		return link, models.SessionParticipant{}, fmt.Errorf("%w: you already joined this session", plugin.ErrConflict)
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return link, models.SessionParticipant{}, err
+	}
+	ok, err := s.links.Consume(ctx, link.ID, s.now())
+	if err != nil {
+		return link, models.SessionParticipant{}, err
+	}
+	if !ok {
+		return link, models.SessionParticipant{}, invalid
+	}
+	p := models.SessionParticipant{
+		ID: uuid.NewString(), ConnectionID: link.ConnectionID, OwnerID: ownerID, UserID: userID,
+		ShareLinkID: link.ID, Mode: link.Mode, JoinedAt: s.now(),
+	}
+	if err := s.participants.Create(ctx, &p); err != nil {
+		return link, models.SessionParticipant{}, err
+	}
+	if link.Mode == models.ShareWrite {
+		if err := s.participants.SetWriteHolder(ctx, p.ConnectionID, p.OwnerID, userID); err != nil {
+			return link, models.SessionParticipant{}, err
+		}
+		p.WriteHolder = true
+	}
+	link.Uses++
+	return link, p, nil
+}
+
+// Participant returns userID's membership of ownerID's session on connID;
+// plugin.ErrNotFound means they have not joined it.
+func (s *SessionShareService) Participant(ctx context.Context, connID, ownerID, userID string) (models.SessionParticipant, error) {
+	p, err := s.participants.Get(ctx, connID, ownerID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return models.SessionParticipant{}, plugin.ErrNotFound
+	}
+	return p, err
+}
+
+// Participation returns the session on connID that userID joined most
+// recently; plugin.ErrNotFound means none.
+func (s *SessionShareService) Participation(ctx context.Context, connID, userID string) (models.SessionParticipant, error) {
+	list, err := s.participants.ListByConnection(ctx, connID)
+	if err != nil {
+		return models.SessionParticipant{}, err
+	}
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i].UserID == userID {
+			return list[i], nil
+		}
+	}
+	return models.SessionParticipant{}, plugin.ErrNotFound
+}
+
+// SessionClosed is the session manager's close hook: it revokes the links
+// into the closing session and removes its participants.
+func (s *SessionShareService) SessionClosed(snap session.Snapshot) {
+	ctx := context.Background()
+	connID, ownerID := snap.Key.ConnectionID, snap.Key.ActorScope
+	list, err := s.links.ListByConnection(ctx, connID)
+	if err != nil {
+		s.logger.Warn("list share links on session close", "connection", connID, "err", err)
+	}
+	for _, l := range list {
+		if l.SessionOwner() == ownerID && l.RevokedAt == nil {
+			if err := s.links.Revoke(ctx, l.ID, s.now()); err != nil {
+				s.logger.Warn("revoke share link on session close", "link", l.ID, "err", err)
+			}
+		}
+	}
+	s.expireWrites(ctx, connID, ownerID)
+	if err := s.requests.DeleteBySession(ctx, connID, ownerID); err != nil {
+		s.logger.Warn("remove write requests on close", "connection", connID, "err", err)
+	}
+	parts, err := s.participants.ListByConnection(ctx, connID)
+	if err != nil {
+		s.logger.Warn("list session participants on close", "connection", connID, "err", err)
+	}
+	if err := s.participants.DeleteBySession(ctx, connID, ownerID); err != nil {
+		s.logger.Warn("remove session participants on close", "connection", connID, "err", err)
+		return
+	}
+	for _, p := range parts {
+		if p.OwnerID == ownerID {
+			s.removals.publish(p.UserID, p)
+		}
+	}
+}
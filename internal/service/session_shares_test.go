@@ -0,0 +1,180 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/session"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+func TestSessionShareLinkLimits(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	svc := service.NewSessionShareService(st.SessionShareLinks, st.SessionParticipants, st.SessionWriteRequests, nil)
+
+	for _, in := range []service.SessionShareInput{
+		{Mode: "owner"},
+		{Mode: models.ShareRead, MaxUses: -1},
+		{Mode: models.ShareRead, ExpiresInSeconds: 7 * 24 * 3600},
+	} {
+		if _, _, err := svc.Create(ctx, "c1", "owner", "owner", in); !errors.Is(err, plugin.ErrInvalidInput) {
+			t.Errorf("create %+v: want invalid input, got %v", in, err)
+		}
+	}
+
+	_, token, err := svc.Create(ctx, "c1", "owner", "owner", service.SessionShareInput{Mode: models.ShareWrite, MaxUses: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, p, err := svc.Join(ctx, token, "u1"); err != nil || !p.WriteHolder || p.OwnerID != "owner" {
+		t.Fatalf("join: %+v %v", p, err)
+	}
+	if _, _, err := svc.Join(ctx, token, "owner"); !errors.Is(err, plugin.ErrConflict) {
+		t.Fatalf("owner joining their own session: %v", err)
+	}
+	if _, _, err := svc.Join(ctx, token, "u1"); !errors.Is(err, plugin.ErrConflict) {
+		t.Fatalf("second join by a participant: %v", err)
+	}
+	if _, _, err := svc.Join(ctx, "bogus", "u2"); !errors.Is(err, plugin.ErrNotFound) {
+		t.Fatalf("unknown token: %v", err)
+	}
+
+	// Another user's session closing leaves the owner's links alone.
+	svc.SessionClosed(session.Snapshot{Key: session.Key{ConnectionID: "c1", ActorScope: "u1"}})
+	if _, _, err := svc.Join(ctx, token, "u2"); err != nil {
+		t.Fatalf("join after unrelated close: %v", err)
+	}
+	svc.SessionClosed(session.Snapshot{Key: session.Key{ConnectionID: "c1", ActorScope: "owner"}})
+	if ps, _ := st.SessionParticipants.ListByConnection(ctx, "c1"); len(ps) != 0 {
+		t.Fatalf("participants should be removed on close: %+v", ps)
+	}
+	if grants, _ := st.Grants.ListByConnection(ctx, "c1"); len(grants) != 0 {
+		t.Fatalf("joining must not grant the connection: %+v", grants)
+	}
+}
+
+func TestSessionWriteRequests(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	svc := service.NewSessionShareService(st.SessionShareLinks, st.SessionParticipants, st.SessionWriteRequests, nil, service.WithWriteRequestTTL(50*time.Millisecond))
+
+	_, token, err := svc.Create(ctx, "c1", "owner", "owner", service.SessionShareInput{Mode: models.ShareRead, MaxUses: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []string{"u1", "u2"} {
+		if _, _, err := svc.Join(ctx, token, u); err != nil {
+			t.Fatalf("join %s: %v", u, err)
+		}
+	}
+	if _, err := svc.RequestWrite(ctx, "c1", "stranger"); !errors.Is(err, plugin.ErrForbidden) {
+		t.Fatalf("non-participant request: %v", err)
+	}
+	events, cancel := svc.SubscribeWrites("owner")
+	defer cancel()
+
+	req, err := svc.RequestWrite(ctx, "c1", "u1")
+	if err != nil || req.Status != models.WriteRequestPending || req.OwnerID != "owner" {
+		t.Fatalf("request: %+v %v", req, err)
+	}
+	if again, _ := svc.RequestWrite(ctx, "c1", "u1"); again.ID != req.ID {
+		t.Fatalf("second request should return the pending one: %+v", again)
+	}
+	if ev := <-events.C; ev.ID != req.ID || ev.Status != models.WriteRequestPending {
+		t.Fatalf("owner event: %+v", ev)
+	}
+	if got := svc.PendingWrites(ctx, "c1", "owner"); len(got) != 1 {
+		t.Fatalf("owner pending: %+v", got)
+	}
+	if got := svc.PendingWrites(ctx, "c1", "u2"); len(got) != 0 {
+		t.Fatalf("other participant should not see the request: %+v", got)
+	}
+	// Requests are stored, so another replica, or this one after a restart,
+	// sees and answers them.
+	replica := service.NewSessionShareService(st.SessionShareLinks, st.SessionParticipants, st.SessionWriteRequests, nil)
+	if got := replica.PendingWrites(ctx, "c1", "owner"); len(got) != 1 || got[0].ID != req.ID {
+		t.Fatalf("replica pending: %+v", got)
+	}
+	if _, err := svc.DecideWrite(ctx, "c1", req.ID, "u2", true, ""); !errors.Is(err, plugin.ErrForbidden) {
+		t.Fatalf("read participant answering: %v", err)
+	}
+
+	done, err := svc.DecideWrite(ctx, "c1", req.ID, "owner", true, "")
+	if err != nil || done.Status != models.WriteRequestApproved || done.DecidedBy != "owner" {
+		t.Fatalf("approve: %+v %v", done, err)
+	}
+	if p, _ := st.SessionParticipants.Get(ctx, "c1", "owner", "u1"); !p.WriteHolder {
+		t.Fatalf("approved participant should hold write: %+v", p)
+	}
+	if grants, _ := st.Grants.ListByConnection(ctx, "c1"); len(grants) != 0 {
+		t.Fatalf("approval must not grant the connection: %+v", grants)
+	}
+	if _, err := svc.DecideWrite(ctx, "c1", req.ID, "owner", false, ""); !errors.Is(err, plugin.ErrNotFound) {
+		t.Fatalf("answering twice: %v", err)
+	}
+	if _, err := svc.RequestWrite(ctx, "c1", "u1"); !errors.Is(err, plugin.ErrConflict) {
+		t.Fatalf("request with write access: %v", err)
+	}
+
+	// The write holder may answer too; unanswered requests expire.
+	req2, _ := svc.RequestWrite(ctx, "c1", "u2")
+	denied, err := svc.DecideWrite(ctx, "c1", req2.ID, "u1", false, "not now")
+	if err != nil || denied.Status != models.WriteRequestDenied || denied.Reason != "not now" {
+		t.Fatalf("deny: %+v %v", denied, err)
+	}
+	if p, _ := st.SessionParticipants.Get(ctx, "c1", "owner", "u2"); p.WriteHolder {
+		t.Fatalf("denied participant should not hold write: %+v", p)
+	}
+	req3, _ := svc.RequestWrite(ctx, "c1", "u2")
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case ev := <-events.C:
+			if ev.ID == req3.ID && ev.Status == models.WriteRequestExpired {
+				if got := svc.PendingWrites(ctx, "c1", "owner"); len(got) != 0 {
+					t.Fatalf("expired request still pending: %+v", got)
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatal("request did not expire")
+		}
+	}
+}
+
+func TestPendingWritesPerSession(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	svc := service.NewSessionShareService(st.SessionShareLinks, st.SessionParticipants, st.SessionWriteRequests, nil)
+
+	// Two owners' sessions on one connection, each with a pending request.
+	reqs := map[string]string{}
+	for owner, joiner := range map[string]string{"ownerA": "uA", "ownerB": "uB"} {
+		_, token, err := svc.Create(ctx, "c1", owner, owner, service.SessionShareInput{Mode: models.ShareRead})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := svc.Join(ctx, token, joiner); err != nil {
+			t.Fatalf("join %s: %v", joiner, err)
+		}
+		req, err := svc.RequestWrite(ctx, "c1", joiner)
+		if err != nil {
+			t.Fatalf("request %s: %v", joiner, err)
+		}
+		reqs[owner] = req.ID
+	}
+	for owner, id := range reqs {
+		if got := svc.PendingWrites(ctx, "c1", owner); len(got) != 1 || got[0].ID != id {
+			t.Errorf("%s pending: %+v, want only %s", owner, got, id)
+		}
+	}
+	if got := svc.PendingWrites(ctx, "c1", "uA"); len(got) != 1 || got[0].ID != reqs["ownerA"] {
+		t.Errorf("requester pending: %+v, want only their own", got)
+	}
+}
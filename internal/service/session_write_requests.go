@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// RequestWrite files userID's request to hold write in the session shared on
+// connID. A participant has at most one pending request; asking again
+// returns it.
+func (s *SessionShareService) RequestWrite(ctx context.Context, connID, userID string) (models.SessionWriteRequest, error) {
+	p, err := s.Participation(ctx, connID, userID)
+	if errors.Is(err, plugin.ErrNotFound) {
+		return models.SessionWriteRequest{}, fmt.Errorf("%w: only participants who joined a shared session can request write access", plugin.ErrForbidden)
+	}
+	if err != nil {
+		return models.SessionWriteRequest{}, err
+	}
+	if p.WriteHolder {
+		return models.SessionWriteRequest{}, fmt.Errorf("%w: you already hold write access", plugin.ErrConflict)
+	}
+	pending, err := s.requests.ListPending(ctx, connID, s.now())
+	if err != nil {
+		return models.SessionWriteRequest{}, err
+	}
+	for _, r := range pending {
+		if r.OwnerID == p.OwnerID && r.UserID == userID {
+			return r, nil
+		}
+	}
+	now := s.now()
+	req := models.SessionWriteRequest{
+		ID: uuid.NewString(), ConnectionID: connID, UserID: userID, OwnerID: p.OwnerID,
+		Status: models.WriteRequestPending, CreatedAt: now, ExpiresAt: now.Add(s.writeTTL),
+	}
+	if err := s.requests.Create(ctx, &req); err != nil {
+		return models.SessionWriteRequest{}, err
+	}
+	s.scheduleExpiry(req)
+	s.notifyWrite(ctx, req)
+	return req, nil
+}
+
+// DecideWrite answers a pending request. The session owner or its current
+// write holder may answer; approval makes the requester the write holder.
+func (s *SessionShareService) DecideWrite(ctx context.Context, connID, id, deciderID string, approve bool, reason string) (models.SessionWriteRequest, error) {
+	missing := fmt.Errorf("%w: write request is not pending", plugin.ErrNotFound)
+	req, err := s.requests.Get(ctx, id)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && (req.ConnectionID != connID || req.Status != models.WriteRequestPending)) {
+		return models.SessionWriteRequest{}, missing
+	}
+	if err != nil {
+		return models.SessionWriteRequest{}, err
+	}
+	if !s.now().Before(req.ExpiresAt) {
+		s.expireWrite(ctx, req)
+		return models.SessionWriteRequest{}, missing
+	}
+	allowed, err := s.canAnswerWrite(ctx, req, deciderID)
+	if err != nil {
+		return models.SessionWriteRequest{}, err
+	}
+	if !allowed {
+		return models.SessionWriteRequest{}, fmt.Errorf("%w: only the session owner or its write holder can answer", plugin.ErrForbidden)
+	}
+	req.Status = models.WriteRequestDenied
+	if approve {
+		req.Status = models.WriteRequestApproved
+	}
+	req.DecidedBy, req.Reason, req.DecidedAt = deciderID, reason, s.now()
+	ok, err := s.requests.Decide(ctx, id, req.Status, deciderID, reason, req.DecidedAt)
+	if err != nil {
+		return models.SessionWriteRequest{}, err
+	}
+	if !ok {
+		return models.SessionWriteRequest{}, missing
+	}
+	s.stopExpiry(id)
+	if approve {
+		if err := s.participants.SetWriteHolder(ctx, req.ConnectionID, req.OwnerID, req.UserID); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return models.SessionWriteRequest{}, fmt.Errorf("%w: the participant has left the session", plugin.ErrNotFound)
+			}
+			return models.SessionWriteRequest{}, err
+		}
+	}
+	s.notifyWrite(ctx, req)
+	return req, nil
+}
+
+// PendingWrites returns the pending requests on connID that userID should
+// see: their own and those they may answer. Sessions of several owners can
+// share a connection, so each request is checked on its own.
+func (s *SessionShareService) PendingWrites(ctx context.Context, connID, userID string) []models.SessionWriteRequest {
+	out, err := s.requests.ListPending(ctx, connID, s.now())
+	if err != nil {
+		s.logger.Warn("list write requests", "connection", connID, "err", err)
+		return nil
+	}
+	out = slices.DeleteFunc(out, func(r models.SessionWriteRequest) bool {
+		if r.UserID == userID {
+			return false
+		}
+		ok, err := s.canAnswerWrite(ctx, r, userID)
+		return err != nil || !ok
+	})
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// SubscribeWrites streams updates on the write requests userID filed or may
+// answer.
+func (s *SessionShareService) SubscribeWrites(userID string) (Feed[models.SessionWriteRequest], func()) {
+	return s.writeEvents.subscribe(userID)
+}
+
+func (s *SessionShareService) canAnswerWrite(ctx context.Context, req models.SessionWriteRequest, userID string) (bool, error) {
+	if userID == req.UserID {
+		return false, nil
+	}
+	if userID == req.OwnerID {
+		return true, nil
+	}
+	p, err := s.participants.Get(ctx, req.ConnectionID, req.OwnerID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return p.WriteHolder, nil
+}
+
+// scheduleExpiry tells the parties when req runs out unanswered. Reads skip
+// expired requests regardless, so one whose timer is lost to a restart still
+// ends on time.
+func (s *SessionShareService) scheduleExpiry(req models.SessionWriteRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expiries[req.ID] = time.AfterFunc(req.ExpiresAt.Sub(s.now()), func() {
+		s.expireWrite(context.Background(), req)
+	})
+}
+
+func (s *SessionShareService) stopExpiry(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.expiries[id]; ok {
+		t.Stop()
+		delete(s.expiries, id)
+	}
+}
+
+// expireWrite marks req expired unless it was answered first.
+func (s *SessionShareService) expireWrite(ctx context.Context, req models.SessionWriteRequest) {
+	s.stopExpiry(req.ID)
+	req.Status, req.DecidedAt = models.WriteRequestExpired, s.now()
+	ok, err := s.requests.Decide(ctx, req.ID, req.Status, "", "", req.DecidedAt)
+	if err != nil {
+		s.logger.Warn("expire write request", "request", req.ID, "err", err)
+		return
+	}
+	if ok {
+		s.notifyWrite(ctx, req)
+	}
+}
+
+// expireWrites ends the pending requests on ownerID's session on connID.
+func (s *SessionShareService) expireWrites(ctx context.Context, connID, ownerID string) {
+	pending, err := s.requests.ListPending(ctx, connID, s.now())
+	if err != nil {
+		s.logger.Warn("list write requests", "connection", connID, "err", err)
+		return
+	}
+	for _, r := range pending {
+		if r.OwnerID == ownerID {
+			s.expireWrite(ctx, r)
+		}
+	}
+}
+
+// notifyWrite tells the requester, the session owner and its write holder.
+func (s *SessionShareService) notifyWrite(ctx context.Context, req models.SessionWriteRequest) {
+	to := []string{req.UserID, req.OwnerID}
+	list, err := s.participants.ListByConnection(ctx, req.ConnectionID)
+	if err != nil {
+		s.logger.Warn("list write holders", "connection", req.ConnectionID, "err", err)
+	}
+	for _, p := range list {
+		if p.OwnerID == req.OwnerID && p.WriteHolder && !slices.Contains(to, p.UserID) {
+			to = append(to, p.UserID)
+		}
+	}
+	for _, id := range to {
+		s.writeEvents.publish(id, req)
+	}
+}
@@ -0,0 +1,443 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// SettingType is how a system setting's stored string is interpreted.
+type SettingType string
+
+const (
+	SettingString   SettingType = "string"
+	SettingInt      SettingType = "int"
+	SettingBool     SettingType = "bool"
+	SettingDuration SettingType = "duration"
+	SettingJSON     SettingType = "json"
+)
+
+// DefaultSettingsCacheTTL bounds how long a cached value is served before it
+// is reread, so a change made on another replica shows up.
+const DefaultSettingsCacheTTL = 30 * time.Second
+
+// SettingDef registers a key the admin settings API may read and write.
+type SettingDef struct {
+	Key         string
+	Type        SettingType
+	Default     string
+	Description string
+	// Secret values can be written but are never returned.
+	Secret bool
+	// RootOnly keys may only be changed by the root admin.
+	RootOnly bool
+	Validate func(value string) error
+}
+
+// SettingView is the admin view of one registered setting.
+type SettingView struct {
+	Key         string          `json:"key"`
+	Type        SettingType     `json:"type"`
+	Description string          `json:"description"`
+	Secret      bool            `json:"secret,omitempty"`
+	RootOnly    bool            `json:"rootOnly,omitempty"`
+	Default     json.RawMessage `json:"default,omitempty"`
+	Value       json.RawMessage `json:"value,omitempty"`
+	IsSet       bool            `json:"isSet"`
+	UpdatedBy   string          `json:"updatedBy,omitempty"`
+	UpdatedAt   *time.Time      `json:"updatedAt,omitempty"`
+}
+
+type cachedSetting struct {
+	setting models.SystemSetting
+	found   bool
+	at      time.Time
+}
+
+// SettingsServiceOption configures a SettingsService.
+type SettingsServiceOption func(*SettingsService)
+
+// WithSettingsCacheTTL sets how long values are cached; 0 disables caching.
+func WithSettingsCacheTTL(d time.Duration) SettingsServiceOption {
+	return func(s *SettingsService) { s.ttl = d }
+}
+
+// SettingsService fronts the system settings table with a read cache, typed
+// accessors, a registry of admin-editable keys and change callbacks. It
+// satisfies store.SystemSettingStore, so services that persist their own
+// settings through it share the cache and notify watchers.
+type SettingsService struct {
+	settings store.SystemSettingStore
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	defs     map[string]SettingDef
+	cache    map[string]cachedSetting
+	watchers map[string][]func(ctx context.Context, value string)
+}
+
+var _ store.SystemSettingStore = (*SettingsService)(nil)
+
+func NewSettingsService(settings store.SystemSettingStore, opts ...SettingsServiceOption) *SettingsService {
+	s := &SettingsService{
+		settings: settings, ttl: DefaultSettingsCacheTTL, now: time.Now,
+		defs: map[string]SettingDef{}, cache: map[string]cachedSetting{},
+		watchers: map[string][]func(context.Context, string){},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Register adds keys to the registry. It panics on a duplicate or a default
+// that fails its own validation, both programming errors.
+func (s *SettingsService) Register(defs ...SettingDef) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range defs {
+		if _, dup := s.defs[d.Key]; dup {
+			panic(fmt.Sprintf("setting %q registered twice", d.Key))
+		}
+		if d.Default != "" {
+			if err := d.check(d.Default); err != nil {
+				panic(fmt.Sprintf("setting %q: default: %v", d.Key, err))
+			}
+		}
+		s.defs[d.Key] = d
+	}
+}
+
+// OnChange registers fn to run after key is written. Watchers run on the
+// writing goroutine.
+func (s *SettingsService) OnChange(key string, fn func(ctx context.Context, value string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watchers[key] = append(s.watchers[key], fn)
+}
+
+// Get returns the stored setting, from the cache when it is fresh.
+func (s *SettingsService) Get(ctx context.Context, key string) (models.SystemSetting, error) {
+	s.mu.Lock()
+	c, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok && s.now().Sub(c.at) < s.ttl {
+		if !c.found {
+			return models.SystemSetting{}, store.ErrNotFound
+		}
+		return c.setting, nil
+	}
+	v, err := s.settings.Get(ctx, key)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return models.SystemSetting{}, err
+	}
+	s.mu.Lock()
+	s.cache[key] = cachedSetting{setting: v, found: err == nil, at: s.now()}
+	s.mu.Unlock()
+	return v, err
+}
+
+func (s *SettingsService) List(ctx context.Context) ([]models.SystemSetting, error) {
+	return s.settings.List(ctx)
+}
+
+// Set writes a setting through to the store, refreshes the cache and runs the
+// key's watchers.
+func (s *SettingsService) Set(ctx context.Context, v *models.SystemSetting) error {
+	if v.UpdatedAt.IsZero() {
+		v.UpdatedAt = s.now()
+	}
+	if err := s.settings.Set(ctx, v); err != nil {
+		s.mu.Lock()
+		delete(s.cache, v.Key)
+		s.mu.Unlock()
+		return err
+	}
+	s.mu.Lock()
+	s.cache[v.Key] = cachedSetting{setting: *v, found: true, at: s.now()}
+	watchers := slices.Clone(s.watchers[v.Key])
+	s.mu.Unlock()
+	for _, fn := range watchers {
+		fn(ctx, v.Value)
+	}
+	return nil
+}
+
+// Invalidate drops cached values so the next read goes to the store.
+func (s *SettingsService) Invalidate(keys ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(keys) == 0 {
+		clear(s.cache)
+		return
+	}
+	for _, k := range keys {
+		delete(s.cache, k)
+	}
+}
+
+// String returns key's value, or its registered default when unset.
+func (s *SettingsService) String(ctx context.Context, key string) (string, error) {
+	v, err := s.Get(ctx, key)
+	if errors.Is(err, store.ErrNotFound) {
+		s.mu.Lock()
+		def := s.defs[key]
+		s.mu.Unlock()
+		return def.Default, nil
+	}
+	return v.Value, err
+}
+
+func (s *SettingsService) Int(ctx context.Context, key string) (int, error) {
+	v, err := s.String(ctx, key)
+	if err != nil || v == "" {
+		return 0, err
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("decode %s: %w", key, err)
+	}
+	return n, nil
+}
+
+func (s *SettingsService) Bool(ctx context.Context, key string) (bool, error) {
+	v, err := s.String(ctx, key)
+	if err != nil || v == "" {
+		return false, err
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("decode %s: %w", key, err)
+	}
+	return b, nil
+}
+
+func (s *SettingsService) Duration(ctx context.Context, key string) (time.Duration, error) {
+	v, err := s.String(ctx, key)
+	if err != nil || v == "" {
+		return 0, err
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("decode %s: %w", key, err)
+	}
+	return d, nil
+}
+
+// JSON decodes key's value into dst; an unset key without a default leaves
+// dst untouched.
+func (s *SettingsService) JSON(ctx context.Context, key string, dst any) error {
+	v, err := s.String(ctx, key)
+	if err != nil || v == "" {
+		return err
+	}
+	if err := json.Unmarshal([]byte(v), dst); err != nil {
+		return fmt.Errorf("decode %s: %w", key, err)
+	}
+	return nil
+}
+
+// Views lists every registered setting by key. Secret values are left out.
+func (s *SettingsService) Views(ctx context.Context) ([]SettingView, error) {
+	s.mu.Lock()
+	defs := make([]SettingDef, 0, len(s.defs))
+	for _, d := range s.defs {
+		defs = append(defs, d)
+	}
+	s.mu.Unlock()
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Key < defs[j].Key })
+	out := make([]SettingView, 0, len(defs))
+	for _, d := range defs {
+		v, err := s.Get(ctx, d.Key)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return nil, err
+		}
+		view := SettingView{
+			Key: d.Key, Type: d.Type, Description: d.Description, Secret: d.Secret, RootOnly: d.RootOnly,
+			IsSet: err == nil,
+		}
+		if !d.Secret && d.Default != "" {
+			view.Default = d.render(d.Default)
+		}
+		if view.IsSet {
+			view.UpdatedBy = v.UpdatedBy
+			if !v.UpdatedAt.IsZero() {
+				at := v.UpdatedAt
+				view.UpdatedAt = &at
+			}
+			if !d.Secret {
+				view.Value = d.render(v.Value)
+			}
+		}
+		out = append(out, view)
+	}
+	return out, nil
+}
+
+// Update validates every value against the registry and then writes them.
+// Values are JSON: strings for string and duration keys, numbers for int,
+// booleans for bool and any document for json.
+func (s *SettingsService) Update(ctx context.Context, actor models.User, values map[string]json.RawMessage) error {
+	verr := &ValidationError{}
+	parsed := make(map[string]string, len(values))
+	s.mu.Lock()
+	for key, raw := range values {
+		d, ok := s.defs[key]
+		switch {
+		case !ok:
+			verr.add(key, "unknown setting")
+		case d.RootOnly && !actor.Protected:
+			s.mu.Unlock()
+			return fmt.Errorf("%w: only the root admin may change %s", plugin.ErrForbidden, key)
+		default:
+			v, err := d.parse(raw)
+			if err == nil {
+				err = d.check(v)
+			}
+			if err != nil {
+				verr.add(key, err.Error())
+				continue
+			}
+			parsed[key] = v
+		}
+	}
+	s.mu.Unlock()
+	if err := verr.err(); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(parsed))
+	for k := range parsed {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := s.Set(ctx, &models.SystemSetting{Key: k, Value: parsed[k], UpdatedBy: actor.ID, UpdatedAt: s.now()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parse turns an API value into the stored string form.
+func (d SettingDef) parse(raw json.RawMessage) (string, error) {
+	switch d.Type {
+	case SettingString, SettingDuration:
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return "", errors.New("must be a string")
+		}
+		return v, nil
+	case SettingInt:
+		var v int
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return "", errors.New("must be an integer")
+		}
+		return strconv.Itoa(v), nil
+	case SettingBool:
+		var v bool
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return "", errors.New("must be true or false")
+		}
+		return strconv.FormatBool(v), nil
+	case SettingJSON:
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, raw); err != nil || buf.String() == "null" {
+			return "", errors.New("must be a JSON document")
+		}
+		return buf.String(), nil
+	}
+	return "", fmt.Errorf("unsupported type %q", d.Type)
+}
+
+// check validates a stored-form value against the type and the key's own rule.
+func (d SettingDef) check(v string) error {
+	switch d.Type {
+	case SettingInt:
+		if _, err := strconv.Atoi(v); err != nil {
+			return errors.New("must be an integer")
+		}
+	case SettingBool:
+		if _, err := strconv.ParseBool(v); err != nil {
+			return errors.New("must be true or false")
+		}
+	case SettingDuration:
+		if _, err := time.ParseDuration(v); err != nil {
+			return errors.New("must be a duration such as 30s or 5m")
+		}
+	case SettingJSON:
+		if !json.Valid([]byte(v)) {
+			return errors.New("must be a JSON document")
+		}
+	}
+	if d.Validate != nil {
+		return d.Validate(v)
+	}
+	return nil
+}
+
+// render is the API form of a stored value.
+func (d SettingDef) render(v string) json.RawMessage {
+	switch d.Type {
+	case SettingInt, SettingBool, SettingJSON:
+		if json.Valid([]byte(v)) {
+			return json.RawMessage(v)
+		}
+	}
+	b, _ := json.Marshal(v)
+	return b
+}
+
+// BuiltinSettings are the admin-editable keys owned by core services.
+// Maintenance mode is left out: it has its own endpoint and must stay
+// switchable while the database refuses writes.
+func BuiltinSettings() []SettingDef {
+	quota, _ := json.Marshal(DefaultCredentialReadQuota)
+	return append([]SettingDef{
+		{
+			Key: SettingApprovalExemptRoot, Type: SettingBool, Default: "false", RootOnly: true,
+			Description: "Let the root admin use approval-gated credentials without a second approver.",
+		},
+		{
+			Key: SettingCredentialReadQuota, Type: SettingJSON, Default: string(quota), RootOnly: true,
+			Description: "Sliding-window limits on credential secret reads per user and per credential.",
+			Validate: func(v string) error {
+				var q CredentialReadQuota
+				if err := json.Unmarshal([]byte(v), &q); err != nil {
+					return err
+				}
+				return q.validate()
+			},
+		},
+		{
+			Key: SettingCollationLocale, Type: SettingString, Default: store.DefaultCollationLocale,
+			Description: "BCP 47 locale used to sort connection, folder and credential names.",
+			Validate: func(v string) error {
+				if strings.TrimSpace(v) == "" {
+					return errors.New("must not be empty")
+				}
+				_, err := store.ParseCollationLocale(v)
+				return err
+			},
+		},
+		passwordPolicySetting(),
+		grantLifetimeSetting(),
+		invitationExpirySetting(),
+		{
+			Key: SettingObservationDisclose, Type: SettingBool, Default: "true",
+			Description: "Notify a session's owner when someone observes it.",
+		},
+	}, sessionCapSettings()...)
+}
@@ -0,0 +1,109 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+func TestSettingsService(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	s := service.NewSettingsService(st.SystemSettings, service.WithSettingsCacheTTL(time.Hour))
+	s.Register(
+		service.SettingDef{Key: "t.limit", Type: service.SettingInt, Default: "10", Validate: func(v string) error {
+			if v == "0" {
+				return errors.New("must be positive")
+			}
+			return nil
+		}},
+		service.SettingDef{Key: "t.enabled", Type: service.SettingBool},
+		service.SettingDef{Key: "t.ttl", Type: service.SettingDuration, Default: "5m"},
+		service.SettingDef{Key: "t.token", Type: service.SettingString, Secret: true},
+		service.SettingDef{Key: "t.root", Type: service.SettingJSON, RootOnly: true},
+	)
+	admin, root := models.User{ID: "admin"}, models.User{ID: "root", Protected: true}
+
+	if n, err := s.Int(ctx, "t.limit"); err != nil || n != 10 {
+		t.Fatalf("default int = %d, %v", n, err)
+	}
+	if d, err := s.Duration(ctx, "t.ttl"); err != nil || d != 5*time.Minute {
+		t.Fatalf("default duration = %v, %v", d, err)
+	}
+
+	var seen []string
+	s.OnChange("t.limit", func(_ context.Context, v string) { seen = append(seen, v) })
+
+	update := func(actor models.User, body string) error {
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(body), &m); err != nil {
+			t.Fatal(err)
+		}
+		return s.Update(ctx, actor, m)
+	}
+	for _, bad := range []string{`{"t.limit":"ten"}`, `{"t.limit":0}`, `{"t.ttl":"soon"}`, `{"t.unknown":1}`, `{"t.limit":20,"t.enabled":"yes"}`} {
+		if err := update(admin, bad); !errors.Is(err, plugin.ErrInvalidInput) {
+			t.Fatalf("%s: want invalid input, got %v", bad, err)
+		}
+	}
+	if len(seen) != 0 {
+		t.Fatalf("rejected batch notified watchers: %v", seen)
+	}
+	if err := update(admin, `{"t.root":{"a":1}}`); !errors.Is(err, plugin.ErrForbidden) {
+		t.Fatalf("root-only as admin: %v", err)
+	}
+	if err := update(root, `{"t.root":{"a":1}}`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := update(admin, `{"t.limit":20,"t.enabled":true,"t.token":"s3cret"}`); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := s.Int(ctx, "t.limit"); n != 20 {
+		t.Fatalf("limit = %d", n)
+	}
+	if b, _ := s.Bool(ctx, "t.enabled"); !b {
+		t.Fatal("enabled not set")
+	}
+	if len(seen) != 1 || seen[0] != "20" {
+		t.Fatalf("watcher saw %v", seen)
+	}
+
+	// Writes behind the service's back are served from cache until invalidated.
+	_ = st.SystemSettings.Set(ctx, &models.SystemSetting{Key: "t.limit", Value: "30"})
+	if n, _ := s.Int(ctx, "t.limit"); n != 20 {
+		t.Fatalf("cached limit = %d", n)
+	}
+	s.Invalidate("t.limit")
+	if n, _ := s.Int(ctx, "t.limit"); n != 30 {
+		t.Fatalf("invalidated limit = %d", n)
+	}
+
+	views, err := s.Views(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range views {
+		switch v.Key {
+		case "t.token":
+			if !v.IsSet || v.Value != nil {
+				t.Fatalf("secret view = %+v", v)
+			}
+		case "t.root":
+			if string(v.Value) != `{"a":1}` || v.UpdatedBy != "root" {
+				t.Fatalf("json view = %+v", v)
+			}
+		case "t.ttl":
+			if v.IsSet || string(v.Default) != `"5m"` {
+				t.Fatalf("default view = %+v", v)
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTimeout is returned when a service operation outlives its timeout.
+var ErrTimeout = errors.New("operation timed out")
+
+// OperationClass buckets service operations by how long their store calls
+// may run.
+type OperationClass int
+
+const (
+	OpRead OperationClass = iota
+	OpWrite
+	OpReport
+)
+
+// Timeouts bounds each operation class; a zero duration leaves it unbounded.
+type Timeouts struct {
+	Read   time.Duration
+	Write  time.Duration
+	Report time.Duration
+}
+
+var DefaultTimeouts = Timeouts{Read: 5 * time.Second, Write: 10 * time.Second, Report: 30 * time.Second}
+
+func (t Timeouts) of(class OperationClass) time.Duration {
+	switch class {
+	case OpWrite:
+		return t.Write
+	case OpReport:
+		return t.Report
+	default:
+		return t.Read
+	}
+}
+
+type timeoutsKey struct{}
+
+// ContextWithTimeouts makes WithTimeout use t for operations run under ctx.
+func ContextWithTimeouts(ctx context.Context, t Timeouts) context.Context {
+	return context.WithValue(ctx, timeoutsKey{}, t)
+}
+
+// WithoutTimeouts opts ctx out of operation timeouts. Maintenance passes that
+// walk whole tables use it so they are bounded only by their caller.
+func WithoutTimeouts(ctx context.Context) context.Context {
+	return ContextWithTimeouts(ctx, Timeouts{})
+}
+
+// WithTimeout bounds ctx by the timeout of class, taken from the context or
+// DefaultTimeouts. Pair it with timeoutError on the way out.
+func WithTimeout(ctx context.Context, class OperationClass) (context.Context, context.CancelFunc) {
+	t, ok := ctx.Value(timeoutsKey{}).(Timeouts)
+	if !ok {
+		t = DefaultTimeouts
+	}
+	d := t.of(class)
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// timeoutError reports err as ErrTimeout when ctx ran out of time, whatever
+// error the driver surfaced for it.
+func timeoutError(ctx context.Context, err error) error {
+	if err == nil || errors.Is(err, ErrTimeout) || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrTimeout, err)
+}
@@ -0,0 +1,99 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+)
+
+// stall blocks like a query that never returns until ctx gives up.
+func stall(ctx context.Context) error {
+	<-ctx.Done()
+	return errors.New("query interrupted")
+}
+
+type stallRecordings struct{ store.RecordingStore }
+
+func (stallRecordings) List(ctx context.Context, _ store.RecordingFilter) ([]models.Recording, error) {
+	return nil, stall(ctx)
+}
+
+type stallConnections struct{ store.ConnectionStore }
+
+func (stallConnections) ListTrashed(ctx context.Context, _ store.TrashFilter) ([]models.Connection, error) {
+	return nil, stall(ctx)
+}
+
+type stallCredentials struct{ store.CredentialStore }
+
+func (stallCredentials) Get(ctx context.Context, _ string) (models.Credential, error) {
+	return models.Credential{}, stall(ctx)
+}
+
+type stallActivity struct{}
+
+func (stallActivity) Summarize(ctx context.Context, _ store.ActivityQuery) (store.ActivitySummary, error) {
+	return store.ActivitySummary{}, stall(ctx)
+}
+
+func TestServiceCallsHonourOperationTimeouts(t *testing.T) {
+	st := store.NewMemory()
+	recs := service.NewRecordingService(stallRecordings{st.Recordings}, nil)
+	conns := service.NewConnectionService(stallConnections{st.Connections}, nil, nil, nil)
+	creds := service.NewCredentialService(stallCredentials{st.Credentials}, st.CredentialGrants, nil)
+	activity := service.NewActivityService(stallActivity{})
+	calls := map[string]func(context.Context) error{
+		"recordings.List": func(ctx context.Context) error {
+			_, err := recs.List(ctx, op, store.RecordingFilter{})
+			return err
+		},
+		"connections.Trash": func(ctx context.Context) error {
+			_, err := conns.Trash(ctx, "op")
+			return err
+		},
+		"credentials.EnsureUsable": func(ctx context.Context) error {
+			return creds.EnsureUsable(ctx, "op", "cred")
+		},
+		"activity.Summary": func(ctx context.Context) error {
+			_, _, err := activity.Summary(ctx, time.Hour)
+			return err
+		},
+	}
+	short := service.Timeouts{Read: 20 * time.Millisecond, Write: 20 * time.Millisecond, Report: 20 * time.Millisecond}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			err := call(service.ContextWithTimeouts(context.Background(), short))
+			if !errors.Is(err, service.ErrTimeout) {
+				t.Fatalf("want ErrTimeout, got %v", err)
+			}
+			if time.Since(start) > time.Second {
+				t.Fatalf("call took %s", time.Since(start))
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if err := call(ctx); err == nil || errors.Is(err, service.ErrTimeout) {
+				t.Fatalf("a canceled caller is not a timeout: %v", err)
+			}
+		})
+	}
+}
+
+func TestWithoutTimeoutsLeavesContextUnbounded(t *testing.T) {
+	ctx, cancel := service.WithTimeout(service.WithoutTimeouts(context.Background()), service.OpReport)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Fatal("maintenance context should carry no deadline")
+	}
+	ctx, cancel = service.WithTimeout(context.Background(), service.OpWrite)
+	defer cancel()
+	if d, ok := ctx.Deadline(); !ok || time.Until(d) > service.DefaultTimeouts.Write {
+		t.Fatalf("default write deadline: %v %v", d, ok)
+	}
+}
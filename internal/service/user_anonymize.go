@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// AnonymizedDisplayName replaces the display name of an anonymized user.
+const AnonymizedDisplayName = "Deleted user"
+
+// AnonymizeReport counts the rows one Anonymize pass changed, by table.
+type AnonymizeReport struct {
+	UserID string `json:"userId"`
+	// Username is the tombstone that now stands in for the user's name.
+	Username string           `json:"username"`
+	Rows     map[string]int64 `json:"rows"`
+	// Done is false when a table hit the batch limit; another pass picks up
+	// where this one stopped.
+	Done bool `json:"done"`
+}
+
+// AnonymizedUsername is the tombstone written over userID's username.
+func AnonymizedUsername(userID string) string { return "deleted-" + userID }
+
+// Anonymize irreversibly removes a user's personal data. The user row stays,
+// disabled and renamed to a tombstone, so recordings and audit entries still
+// resolve; their copies of the username are rewritten, and the user's AI
+// conversations, credential access logs, favorites and preferences are
+// cleared. Each table changes at most limit rows per call, so a large history
+// is scrubbed over several calls without holding long transactions. The root
+// admin cannot be anonymized.
+func (s *UserService) Anonymize(ctx context.Context, userID string, limit int) (AnonymizeReport, error) {
+	if s.scrub == nil {
+		return AnonymizeReport{}, fmt.Errorf("%w: anonymization is not enabled", plugin.ErrNotSupported)
+	}
+	if limit <= 0 {
+		return AnonymizeReport{}, fmt.Errorf("%w: batch size must be positive", plugin.ErrInvalidInput)
+	}
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return AnonymizeReport{}, err
+	}
+	if user.Protected {
+		return AnonymizeReport{}, fmt.Errorf("%w: the root admin cannot be anonymized", plugin.ErrForbidden)
+	}
+	tombstone := AnonymizedUsername(userID)
+	report := AnonymizeReport{UserID: userID, Username: tombstone, Rows: map[string]int64{}, Done: true}
+	if user.Username != tombstone {
+		// The credentials go first: a failure after this point leaves an
+		// account nobody can sign in to, never a half-renamed live one.
+		// Clearing the password also ends the user's sessions.
+		if err := s.users.SetPasswordHash(ctx, userID, "", time.Now()); err != nil {
+			return AnonymizeReport{}, err
+		}
+		if err := s.users.SetTwoFactor(ctx, userID, nil, false, nil); err != nil {
+			return AnonymizeReport{}, err
+		}
+		if user, err = s.users.GetByID(ctx, userID); err != nil {
+			return AnonymizeReport{}, err
+		}
+		user.Username = tombstone
+		user.Email = ""
+		user.DisplayName = AnonymizedDisplayName
+		user.Disabled = true
+		user.UpdatedAt = time.Now()
+		if err := s.users.Update(ctx, &user); err != nil {
+			return AnonymizeReport{}, err
+		}
+		report.Rows["users"] = 1
+	}
+	for _, t := range store.AnonymizeTables {
+		n, err := s.scrub.Scrub(ctx, t, userID, tombstone, limit)
+		if err != nil {
+			return report, fmt.Errorf("anonymize %s: %w", t, err)
+		}
+		report.Rows[string(t)] = n
+		if n >= int64(limit) {
+			report.Done = false
+		}
+	}
+	return report, nil
+}
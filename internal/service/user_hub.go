@@ -0,0 +1,81 @@
+package service
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const userHubBuffer = 16
+
+// userHub fans updates out to one user's subscribers, e.g. their open tabs.
+// Publishing never waits on a subscriber: one whose queue is full loses its
+// oldest queued update, so it still sees the latest state once it catches
+// up. The zero value is ready to use.
+type userHub[T any] struct {
+	mu   sync.Mutex
+	subs map[string]map[*hubSub[T]]struct{}
+}
+
+type hubSub[T any] struct {
+	ch      chan T
+	dropped atomic.Int64
+}
+
+// Feed is one subscription to a user's updates.
+type Feed[T any] struct {
+	C   <-chan T
+	sub *hubSub[T]
+}
+
+// Dropped reports how many updates were discarded because the subscriber
+// fell behind since the last call, and resets the count.
+func (f Feed[T]) Dropped() int64 {
+	if f.sub == nil {
+		return 0
+	}
+	return f.sub.dropped.Swap(0)
+}
+
+func (h *userHub[T]) subscribe(userID string) (Feed[T], func()) {
+	sub := &hubSub[T]{ch: make(chan T, userHubBuffer)}
+	h.mu.Lock()
+	if h.subs == nil {
+		h.subs = map[string]map[*hubSub[T]]struct{}{}
+	}
+	if h.subs[userID] == nil {
+		h.subs[userID] = map[*hubSub[T]]struct{}{}
+	}
+	h.subs[userID][sub] = struct{}{}
+	h.mu.Unlock()
+	var once sync.Once
+	return Feed[T]{C: sub.ch, sub: sub}, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs[userID], sub)
+			if len(h.subs[userID]) == 0 {
+				delete(h.subs, userID)
+			}
+			h.mu.Unlock()
+		})
+	}
+}
+
+func (h *userHub[T]) publish(userID string, v T) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs[userID] {
+		select {
+		case sub.ch <- v:
+			continue
+		default:
+		}
+		// Publishers hold mu, so once one update is taken off the full
+		// queue the send below cannot fail.
+		select {
+		case <-sub.ch:
+			sub.dropped.Add(1)
+		default:
+		}
+		sub.ch <- v
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUserHubSlowSubscriberDoesNotHoldBackOthers(t *testing.T) {
+	var h userHub[int]
+	fast, cancelFast := h.subscribe("u")
+	defer cancelFast()
+	slow, cancelSlow := h.subscribe("u")
+	defer cancelSlow()
+	other, cancelOther := h.subscribe("u")
+	defer cancelOther()
+
+	const n = 2000
+	got := make(chan []int, 2)
+	for _, f := range []Feed[int]{fast, other} {
+		go func() {
+			var seen []int
+			for v := range f.C {
+				seen = append(seen, v)
+				if v == n-1 {
+					break
+				}
+			}
+			got <- seen
+		}()
+	}
+
+	start := time.Now()
+	for i := range n {
+		h.publish("u", i)
+		// Pace the publisher so the readers keep up; only slow, which never
+		// reads until the end, should fall behind.
+		if i%(userHubBuffer/2) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for range 2 {
+		select {
+		case seen := <-got:
+			if len(seen) != n || seen[0] != 0 || seen[n-1] != n-1 {
+				t.Fatalf("prompt subscriber saw %d updates (%v..%v), want all %d", len(seen), seen[0], seen[len(seen)-1], n)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("prompt subscribers still waiting %v after publishing started", time.Since(start))
+		}
+	}
+	if d := fast.Dropped(); d != 0 {
+		t.Fatalf("prompt subscriber dropped %d", d)
+	}
+
+	if d := slow.Dropped(); d != n-userHubBuffer {
+		t.Fatalf("slow subscriber dropped %d, want %d", d, n-userHubBuffer)
+	}
+	if d := slow.Dropped(); d != 0 {
+		t.Fatalf("Dropped should reset, got %d", d)
+	}
+	// Drop-oldest leaves the newest updates queued.
+	for want := n - userHubBuffer; want < n; want++ {
+		if v := <-slow.C; v != want {
+			t.Fatalf("slow subscriber got %d, want %d", v, want)
+		}
+	}
+}
@@ -32,11 +32,53 @@ func ValidatePassword(password string) error {
 // UserService manages platform accounts: it hashes passwords on write and never
 // returns hashes (the store clears them on read).
 type UserService struct {
-	users store.UserStore
+	users      store.UserStore
+	grants     store.GrantStore
+	credGrants store.CredentialGrantStore
+	creds      store.CredentialStore
+	conns      *ConnectionService
+	policy     *PasswordPolicyService
+	scrub      store.AnonymizeStore
 }
 
-func NewUserService(users store.UserStore) *UserService {
-	return &UserService{users: users}
+type UserServiceOption func(*UserService)
+
+// WithUserGrants makes Delete remove the connection and credential grants a
+// deleted user held, so a reused id can never inherit them.
+func WithUserGrants(grants store.GrantStore, credGrants store.CredentialGrantStore) UserServiceOption {
+	return func(s *UserService) { s.grants, s.credGrants = grants, credGrants }
+}
+
+// WithUserCredentials makes Delete account for the credentials a user owns:
+// they are transferred or, with explicit confirmation, left orphaned.
+func WithUserCredentials(creds store.CredentialStore) UserServiceOption {
+	return func(s *UserService) { s.creds = creds }
+}
+
+// WithUserConnections makes Delete account for the connections a user owns:
+// they are reassigned or, with explicit confirmation, left orphaned.
+func WithUserConnections(conns *ConnectionService) UserServiceOption {
+	return func(s *UserService) { s.conns = conns }
+}
+
+// WithPasswordPolicy checks new passwords against the configured policy
+// instead of the length-only baseline.
+func WithPasswordPolicy(p *PasswordPolicyService) UserServiceOption {
+	return func(s *UserService) { s.policy = p }
+}
+
+// WithUserAnonymizer enables Anonymize, which scrubs a user's personal data
+// from the tables that outlive the account.
+func WithUserAnonymizer(scrub store.AnonymizeStore) UserServiceOption {
+	return func(s *UserService) { s.scrub = scrub }
+}
+
+func NewUserService(users store.UserStore, opts ...UserServiceOption) *UserService {
+	s := &UserService{users: users}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
 }
 
 // NewUserInput describes an account to create (password in plaintext).
@@ -54,7 +96,8 @@ func (s *UserService) Create(ctx context.Context, in NewUserInput) (models.User,
 	if username == "" {
 		return models.User{}, fmt.Errorf("%w: username is required", plugin.ErrInvalidInput)
 	}
-	if err := ValidatePassword(in.Password); err != nil {
+	email := strings.TrimSpace(in.Email)
+	if err := s.checkPassword(in.Password, username, email); err != nil {
 		return models.User{}, err
 	}
 	hash, err := auth.HashPassword(in.Password)
@@ -63,14 +106,15 @@ func (s *UserService) Create(ctx context.Context, in NewUserInput) (models.User,
 	}
 	now := time.Now()
 	user := models.User{
-		ID:          uuid.NewString(),
-		Username:    username,
-		Email:       strings.TrimSpace(in.Email),
-		DisplayName: strings.TrimSpace(in.DisplayName),
-		Roles:       in.Roles,
-		Protected:   in.Protected,
-		CreatedAt:   now,
-		UpdatedAt:   now,
+		ID:                uuid.NewString(),
+		Username:          username,
+		Email:             email,
+		DisplayName:       strings.TrimSpace(in.DisplayName),
+		Roles:             in.Roles,
+		Protected:         in.Protected,
+		PasswordChangedAt: &now,
+		CreatedAt:         now,
+		UpdatedAt:         now,
 	}
 	if err := s.users.Create(ctx, &user, hash); err != nil {
 		return models.User{}, err
@@ -110,8 +154,136 @@ func (s *UserService) Update(ctx context.Context, id string, in UpdateUserInput)
 	return user, nil
 }
 
-func (s *UserService) Delete(ctx context.Context, id string) error {
-	return s.users.Delete(ctx, id)
+// UserDeleteOptions says what happens to the credentials and connections a
+// deleted user owns.
+type UserDeleteOptions struct {
+	// TransferCredentialsTo is the user who takes over the credentials.
+	TransferCredentialsTo string
+	// OrphanCredentials confirms deleting the user while their credentials
+	// keep an owner that no longer exists.
+	OrphanCredentials bool
+	// TransferConnectionsTo is the user who takes over the connections.
+	TransferConnectionsTo string
+	// OrphanConnections confirms deleting the user while their connections
+	// keep an owner that no longer exists.
+	OrphanConnections bool
+}
+
+// Delete removes a user and the grants they held. A user who owns credentials
+// or connections is only deleted with a transfer target or an explicit
+// orphaning confirmation for each; otherwise an OwnedCredentialsError or
+// OwnedConnectionsError reports the count. Transfer targets and connections
+// that could not follow are checked before anything moves. Credentials move
+// first, so connections that use them can follow them to the same new owner.
+func (s *UserService) Delete(ctx context.Context, id string, opts UserDeleteOptions) error {
+	var ownedCreds []models.Credential
+	if s.creds != nil {
+		var err error
+		if ownedCreds, err = s.creds.ListByOwner(ctx, id); err != nil {
+			return err
+		}
+		if len(ownedCreds) > 0 && opts.TransferCredentialsTo == "" && !opts.OrphanCredentials {
+			return &OwnedCredentialsError{Count: len(ownedCreds)}
+		}
+	}
+	var ownedConns []models.Connection
+	if s.conns != nil {
+		var err error
+		if ownedConns, err = s.conns.conns.ListByOwner(ctx, id); err != nil {
+			return err
+		}
+		if len(ownedConns) > 0 && opts.TransferConnectionsTo == "" && !opts.OrphanConnections {
+			return &OwnedConnectionsError{Count: len(ownedConns)}
+		}
+	}
+	moveCreds := len(ownedCreds) > 0 && opts.TransferCredentialsTo != ""
+	moveConns := len(ownedConns) > 0 && opts.TransferConnectionsTo != ""
+	if moveCreds {
+		if err := s.checkTransferTarget(ctx, id, opts.TransferCredentialsTo); err != nil {
+			return err
+		}
+	}
+	if moveConns {
+		if err := s.checkTransferTarget(ctx, id, opts.TransferConnectionsTo); err != nil {
+			return err
+		}
+		inherited := map[string]bool{}
+		if moveCreds && opts.TransferCredentialsTo == opts.TransferConnectionsTo {
+			for _, c := range ownedCreds {
+				inherited[c.ID] = true
+			}
+		}
+		skips, err := s.conns.TransferSkips(ctx, id, opts.TransferConnectionsTo, inherited)
+		if err != nil {
+			return err
+		}
+		if n := len(skips); n > 0 && !opts.OrphanConnections {
+			return fmt.Errorf("%w: %d connection(s) could not be reassigned: %s", plugin.ErrConflict, n, skips[0].Reason)
+		}
+	}
+	if moveCreds {
+		if _, err := moveCredentials(ctx, s.creds, id, opts.TransferCredentialsTo); err != nil {
+			return err
+		}
+	}
+	if moveConns {
+		moved, err := s.conns.TransferAllOwnership(ctx, id, opts.TransferConnectionsTo)
+		if err != nil {
+			return err
+		}
+		if n := len(moved.Skipped); n > 0 && !opts.OrphanConnections {
+			return fmt.Errorf("%w: %d connection(s) could not be reassigned: %s", plugin.ErrConflict, n, moved.Skipped[0].Reason)
+		}
+	}
+	if err := s.users.Delete(ctx, id); err != nil {
+		return err
+	}
+	return s.deleteGrants(ctx, id)
+}
+
+func (s *UserService) checkTransferTarget(ctx context.Context, fromID, toID string) error {
+	if toID == fromID {
+		return fmt.Errorf("%w: nothing can be transferred to the user being deleted", plugin.ErrInvalidInput)
+	}
+	to, err := s.Get(ctx, toID)
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("%w: transfer target does not exist", plugin.ErrInvalidInput)
+	}
+	if err != nil {
+		return err
+	}
+	if to.Disabled {
+		return fmt.Errorf("%w: transfer target is deactivated", plugin.ErrInvalidInput)
+	}
+	return nil
+}
+
+// deleteGrants stops at the first failure; the hygiene sweep removes whatever
+// is left.
+func (s *UserService) deleteGrants(ctx context.Context, userID string) error {
+	if s.grants != nil {
+		list, err := s.grants.ListBySubject(ctx, userID)
+		if err != nil {
+			return err
+		}
+		for _, g := range list {
+			if err := s.grants.Delete(ctx, g.ID); err != nil {
+				return err
+			}
+		}
+	}
+	if s.credGrants != nil {
+		list, err := s.credGrants.ListBySubject(ctx, userID)
+		if err != nil {
+			return err
+		}
+		for _, g := range list {
+			if err := s.credGrants.Delete(ctx, g.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // UpdateProfile changes only a user's own profile fields (display name + email).
@@ -132,7 +304,11 @@ func (s *UserService) UpdateProfile(ctx context.Context, id, email, displayName
 
 // ChangePassword verifies the current password before setting a new one.
 func (s *UserService) ChangePassword(ctx context.Context, id, current, next string) error {
-	if err := ValidatePassword(next); err != nil {
+	user, err := s.users.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.checkPassword(next, user.Username, user.Email); err != nil {
 		return err
 	}
 	hash, err := s.users.GetPasswordHash(ctx, id)
@@ -150,5 +326,12 @@ func (s *UserService) ChangePassword(ctx context.Context, id, current, next stri
 	if err != nil {
 		return err
 	}
-	return s.users.SetPasswordHash(ctx, id, newHash)
+	return s.users.SetPasswordHash(ctx, id, newHash, time.Now())
+}
+
+func (s *UserService) checkPassword(password, username, email string) error {
+	if s.policy != nil {
+		return s.policy.Check(password, username, email)
+	}
+	return ValidatePassword(password)
 }
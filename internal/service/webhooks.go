@@ -0,0 +1,458 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/secrets"
+	"github.com/charlesng35/shellcn/internal/session"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// Webhook event names.
+const (
+	WebhookSessionStarted      = "session.started"
+	WebhookSessionClosed       = "session.closed"
+	WebhookSessionResized      = "session.resized"
+	WebhookSessionReconnecting = "session.reconnecting"
+	WebhookRecordingFailed     = "session.recording_failed"
+	WebhookCredentialReadAlert = "credential.read_alert"
+	WebhookRecordingCompleted  = "recording.completed"
+	WebhookTest                = "webhook.test"
+)
+
+// WebhookEvents are the events a webhook may subscribe to.
+var WebhookEvents = []string{WebhookSessionStarted, WebhookSessionClosed, WebhookSessionResized, WebhookSessionReconnecting, WebhookRecordingFailed, WebhookRecordingCompleted, WebhookCredentialReadAlert}
+
+// Delivery request headers.
+const (
+	WebhookEventHeader     = "X-Shellcn-Event"
+	WebhookDeliveryHeader  = "X-Shellcn-Delivery"
+	WebhookSignatureHeader = "X-Shellcn-Signature"
+)
+
+const (
+	// WebhookHistoryLimit is how many delivery attempts are kept per webhook.
+	WebhookHistoryLimit = 50
+
+	defaultWebhookWorkers = 4
+	defaultWebhookQueue   = 256
+	defaultWebhookRetries = 3
+	defaultWebhookBackoff = time.Second
+	webhookTimeout        = 10 * time.Second
+)
+
+// WebhookInput is the admin-supplied webhook definition. An empty Secret on
+// update keeps the stored one; on create a secret is generated.
+type WebhookInput struct {
+	Name    string   `json:"name"`
+	URL     string   `json:"url"`
+	Secret  string   `json:"secret,omitempty"`
+	Events  []string `json:"events"`
+	Enabled *bool    `json:"enabled,omitempty"`
+}
+
+// WebhookServiceOption configures a WebhookService.
+type WebhookServiceOption func(*WebhookService)
+
+// WithWebhookClient overrides the HTTP client used for deliveries.
+func WithWebhookClient(c *http.Client) WebhookServiceOption {
+	return func(s *WebhookService) { s.client = c }
+}
+
+// WithWebhookLogger sets the logger for dropped and failed deliveries.
+func WithWebhookLogger(l *slog.Logger) WebhookServiceOption {
+	return func(s *WebhookService) { s.logger = l }
+}
+
+// WithWebhookRetry sets how many times a failed delivery is retried and the
+// first backoff delay, which doubles on each retry.
+func WithWebhookRetry(retries int, backoff time.Duration) WebhookServiceOption {
+	return func(s *WebhookService) { s.retries, s.backoff = retries, backoff }
+}
+
+// WebhookService manages webhook endpoints and delivers lifecycle events to
+// them. Notifications are queued and sent by a bounded worker pool, so a slow
+// or failing endpoint never blocks or fails the operation that raised them;
+// when the queue is full the event is dropped and logged.
+type WebhookService struct {
+	hooks      store.WebhookStore
+	deliveries store.WebhookDeliveryStore
+	vault      secrets.SecretStore
+	client     *http.Client
+	logger     *slog.Logger
+	retries    int
+	backoff    time.Duration
+	now        func() time.Time
+
+	queue  chan webhookEvent
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+type webhookEvent struct {
+	name string
+	at   time.Time
+	data any
+}
+
+// webhookPayload is the JSON body of every delivery.
+type webhookPayload struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	Timestamp time.Time `json:"timestamp"`
+	Data      any       `json:"data"`
+}
+
+// NewWebhookService starts the delivery workers; call Close to stop them.
+func NewWebhookService(hooks store.WebhookStore, deliveries store.WebhookDeliveryStore, vault secrets.SecretStore, opts ...WebhookServiceOption) *WebhookService {
+	s := &WebhookService{
+		hooks: hooks, deliveries: deliveries, vault: vault,
+		client:  &http.Client{Timeout: webhookTimeout},
+		logger:  slog.Default(),
+		retries: defaultWebhookRetries,
+		backoff: defaultWebhookBackoff,
+		now:     time.Now,
+		queue:   make(chan webhookEvent, defaultWebhookQueue),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	for range defaultWebhookWorkers {
+		s.wg.Add(1)
+		go s.worker()
+	}
+	return s
+}
+
+// Close stops the workers. Queued events that have not been sent are dropped.
+func (s *WebhookService) Close() {
+	s.once.Do(func() {
+		s.cancel()
+		s.wg.Wait()
+	})
+}
+
+func (s *WebhookService) List(ctx context.Context) ([]models.Webhook, error) {
+	return s.hooks.List(ctx)
+}
+
+func (s *WebhookService) Get(ctx context.Context, id string) (models.Webhook, error) {
+	w, err := s.hooks.Get(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return models.Webhook{}, plugin.ErrNotFound
+	}
+	return w, err
+}
+
+// Create validates and stores a webhook. The returned secret is non-empty
+// only when one was generated, and is not retrievable afterwards.
+func (s *WebhookService) Create(ctx context.Context, in WebhookInput, actorID string) (models.Webhook, string, error) {
+	if err := validateWebhook(in); err != nil {
+		return models.Webhook{}, "", err
+	}
+	secret, generated := in.Secret, ""
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return models.Webhook{}, "", err
+		}
+		secret = hex.EncodeToString(buf)
+		generated = secret
+	}
+	ct, err := s.vault.Encrypt(ctx, []byte(secret))
+	if err != nil {
+		return models.Webhook{}, "", err
+	}
+	now := s.now()
+	w := models.Webhook{
+		ID: uuid.NewString(), Name: strings.TrimSpace(in.Name), URL: in.URL,
+		Events: normalizeWebhookEvents(in.Events), Enabled: in.Enabled == nil || *in.Enabled,
+		SecretCiphertext: ct, CreatedBy: actorID, CreatedAt: now, UpdatedAt: now,
+	}
+	if err := s.hooks.Create(ctx, &w); err != nil {
+		return models.Webhook{}, "", err
+	}
+	return w, generated, nil
+}
+
+func (s *WebhookService) Update(ctx context.Context, id string, in WebhookInput) (models.Webhook, error) {
+	w, err := s.Get(ctx, id)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	if err := validateWebhook(in); err != nil {
+		return models.Webhook{}, err
+	}
+	w.Name, w.URL, w.Events = strings.TrimSpace(in.Name), in.URL, normalizeWebhookEvents(in.Events)
+	if in.Enabled != nil {
+		w.Enabled = *in.Enabled
+	}
+	if in.Secret != "" {
+		if w.SecretCiphertext, err = s.vault.Encrypt(ctx, []byte(in.Secret)); err != nil {
+			return models.Webhook{}, err
+		}
+	}
+	w.UpdatedAt = s.now()
+	if err := s.hooks.Update(ctx, &w); err != nil {
+		return models.Webhook{}, err
+	}
+	return w, nil
+}
+
+func (s *WebhookService) Delete(ctx context.Context, id string) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	if err := s.hooks.Delete(ctx, id); err != nil {
+		return err
+	}
+	return s.deliveries.DeleteByWebhook(ctx, id)
+}
+
+// Deliveries returns a webhook's recent attempts, newest first.
+func (s *WebhookService) Deliveries(ctx context.Context, id string) ([]models.WebhookDelivery, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.deliveries.List(ctx, id, WebhookHistoryLimit)
+}
+
+// Test sends one synchronous test event, without retries, regardless of the
+// webhook's event filter or enabled state.
+func (s *WebhookService) Test(ctx context.Context, id string) (models.WebhookDelivery, error) {
+	w, err := s.Get(ctx, id)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	ev := webhookEvent{name: WebhookTest, at: s.now(), data: map[string]string{"webhookId": w.ID}}
+	d, _ := s.attempt(ctx, w, ev, uuid.NewString(), 1, true)
+	return d, nil
+}
+
+// Notify queues an event for every enabled, subscribed webhook. It never blocks.
+func (s *WebhookService) Notify(event string, data any) {
+	select {
+	case <-s.ctx.Done():
+		return
+	default:
+	}
+	select {
+	case s.queue <- webhookEvent{name: event, at: s.now(), data: data}:
+	default:
+		s.logger.Warn("webhook queue full, event dropped", "event", event)
+	}
+}
+
+// SessionStarted is the session manager's open hook.
+func (s *WebhookService) SessionStarted(snap session.Snapshot) {
+	s.Notify(WebhookSessionStarted, map[string]any{
+		"connectionId": snap.Key.ConnectionID, "userId": snap.UserID, "startedAt": snap.CreatedAt,
+	})
+}
+
+// SessionClosed is the session manager's close hook.
+func (s *WebhookService) SessionClosed(snap session.Snapshot) {
+	data := map[string]any{
+		"connectionId": snap.Key.ConnectionID, "userId": snap.UserID,
+		"startedAt": snap.CreatedAt, "closedAt": s.now(), "state": snap.State,
+	}
+	if snap.Reason != "" {
+		data["reason"] = snap.Reason
+	}
+	s.Notify(WebhookSessionClosed, data)
+}
+
+// SessionResized is the session manager's resize hook.
+func (s *WebhookService) SessionResized(snap session.Snapshot) {
+	s.Notify(WebhookSessionResized, map[string]any{
+		"connectionId": snap.Key.ConnectionID, "userId": snap.UserID, "cols": snap.Cols, "rows": snap.Rows,
+	})
+}
+
+// SessionReconnecting is the session manager's reconnect hook.
+func (s *WebhookService) SessionReconnecting(snap session.Snapshot) {
+	s.Notify(WebhookSessionReconnecting, map[string]any{
+		"connectionId": snap.Key.ConnectionID, "userId": snap.UserID, "reason": snap.Reason,
+	})
+}
+
+// RecordingCompleted is the recording engine's finalize hook.
+func (s *WebhookService) RecordingCompleted(rec models.Recording) {
+	s.Notify(WebhookRecordingCompleted, map[string]any{
+		"recordingId": rec.ID, "connectionId": rec.ConnectionID, "userId": rec.UserID,
+		"protocol": rec.Protocol, "status": rec.Status, "durationMs": rec.DurationMS, "size": rec.Size,
+	})
+}
+
+// RecordingWriteFailed is the recording engine's write-failure hook.
+func (s *WebhookService) RecordingWriteFailed(rec models.Recording, err error) {
+	s.Notify(WebhookRecordingFailed, map[string]any{
+		"recordingId": rec.ID, "connectionId": rec.ConnectionID, "userId": rec.UserID,
+		"protocol": rec.Protocol, "reason": err.Error(),
+	})
+}
+
+// CredentialReadAlert is the credential read guard's alert hook.
+func (s *WebhookService) CredentialReadAlert(a CredentialReadAlert) {
+	data := map[string]any{
+		"scope": a.Scope, "userId": a.UserID, "credentialId": a.CredentialID,
+		"count": a.Count, "limit": a.Limit, "windowSeconds": int(a.Window.Seconds()),
+	}
+	if !a.BlockedUntil.IsZero() {
+		data["blockedUntil"] = a.BlockedUntil
+	}
+	s.Notify(WebhookCredentialReadAlert, data)
+}
+
+func (s *WebhookService) worker() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case ev := <-s.queue:
+			s.dispatch(ev)
+		}
+	}
+}
+
+func (s *WebhookService) dispatch(ev webhookEvent) {
+	hooks, err := s.hooks.List(s.ctx)
+	if err != nil {
+		s.logger.Warn("webhook dispatch: list webhooks", "event", ev.name, "err", err)
+		return
+	}
+	for _, w := range hooks {
+		if w.Enabled && w.Subscribed(ev.name) {
+			s.deliver(w, ev)
+		}
+	}
+}
+
+// deliver sends ev to w, retrying with exponential backoff. Every attempt
+// reuses the same delivery id so receivers can deduplicate.
+func (s *WebhookService) deliver(w models.Webhook, ev webhookEvent) {
+	id := uuid.NewString()
+	delay := s.backoff
+	for attempt := 1; ; attempt++ {
+		if _, ok := s.attempt(s.ctx, w, ev, id, attempt, false); ok || attempt > s.retries {
+			if !ok {
+				s.logger.Warn("webhook delivery failed", "webhook", w.ID, "event", ev.name, "attempts", attempt)
+			}
+			return
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-s.ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		delay *= 2
+	}
+}
+
+// attempt performs one signed POST and records it in the delivery history.
+func (s *WebhookService) attempt(ctx context.Context, w models.Webhook, ev webhookEvent, id string, n int, test bool) (models.WebhookDelivery, bool) {
+	d := models.WebhookDelivery{
+		ID: uuid.NewString(), WebhookID: w.ID, DeliveryID: id, Event: ev.name,
+		Attempt: n, Test: test, CreatedAt: s.now(),
+	}
+	status, err := s.post(ctx, w, ev, id)
+	d.StatusCode = status
+	d.DurationMS = s.now().Sub(d.CreatedAt).Milliseconds()
+	if err != nil {
+		d.Error = err.Error()
+	}
+	// History writes use a fresh context so a shutdown mid-delivery is still recorded.
+	hctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := s.deliveries.Create(hctx, &d); err != nil {
+		s.logger.Warn("record webhook delivery", "webhook", w.ID, "err", err)
+	} else if err := s.deliveries.Prune(hctx, w.ID, WebhookHistoryLimit); err != nil {
+		s.logger.Warn("prune webhook deliveries", "webhook", w.ID, "err", err)
+	}
+	return d, err == nil
+}
+
+func (s *WebhookService) post(ctx context.Context, w models.Webhook, ev webhookEvent, id string) (int, error) {
+	body, err := json.Marshal(webhookPayload{ID: id, Event: ev.name, Timestamp: ev.at.UTC(), Data: ev.data})
+	if err != nil {
+		return 0, err
+	}
+	secret, err := s.vault.Decrypt(ctx, w.SecretCiphertext)
+	if err != nil {
+		return 0, fmt.Errorf("decrypt secret: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, ev.name)
+	req.Header.Set(WebhookDeliveryHeader, id)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(secret, body))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhook returns the signature header value for body: "sha256=" followed
+// by the hex HMAC-SHA256 of the body under secret.
+func SignWebhook(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func validateWebhook(in WebhookInput) error {
+	if strings.TrimSpace(in.Name) == "" {
+		return fmt.Errorf("%w: name is required", plugin.ErrInvalidInput)
+	}
+	u, err := url.Parse(in.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http(s) URL", plugin.ErrInvalidInput)
+	}
+	for _, e := range in.Events {
+		if !slices.Contains(WebhookEvents, e) {
+			return fmt.Errorf("%w: unknown event %q", plugin.ErrInvalidInput, e)
+		}
+	}
+	return nil
+}
+
+func normalizeWebhookEvents(events []string) []string {
+	out := slices.Clone(events)
+	slices.Sort(out)
+	return slices.Compact(out)
+}
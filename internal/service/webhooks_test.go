@@ -0,0 +1,193 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/secrets"
+	"github.com/charlesng35/shellcn/internal/service"
+	"github.com/charlesng35/shellcn/internal/store"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+type webhookReceiver struct {
+	mu       sync.Mutex
+	statuses []int // served in order; the last one repeats
+	reqs     []*http.Request
+	bodies   [][]byte
+	got      chan struct{}
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) (*webhookReceiver, *httptest.Server) {
+	rcv := &webhookReceiver{statuses: statuses, got: make(chan struct{}, 64)}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rcv.mu.Lock()
+		status := rcv.statuses[min(len(rcv.reqs), len(rcv.statuses)-1)]
+		rcv.reqs = append(rcv.reqs, r)
+		rcv.bodies = append(rcv.bodies, body)
+		rcv.mu.Unlock()
+		w.WriteHeader(status)
+		rcv.got <- struct{}{}
+	}))
+	t.Cleanup(ts.Close)
+	return rcv, ts
+}
+
+func (r *webhookReceiver) wait(t *testing.T, n int) {
+	t.Helper()
+	for range n {
+		select {
+		case <-r.got:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %d deliveries", n)
+		}
+	}
+}
+
+func newWebhookService(t *testing.T) (*service.WebhookService, *store.Store) {
+	t.Helper()
+	st := store.NewMemory()
+	key, _ := secrets.GenerateMasterKey()
+	vault, _ := secrets.NewVault(key)
+	svc := service.NewWebhookService(st.Webhooks, st.WebhookDeliveries, vault,
+		service.WithWebhookRetry(2, time.Millisecond))
+	t.Cleanup(svc.Close)
+	return svc, st
+}
+
+func TestWebhookDeliverySignedAndRetried(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newWebhookService(t)
+	rcv, ts := newWebhookReceiver(t, http.StatusBadGateway, http.StatusOK)
+
+	hook, generated, err := svc.Create(ctx, service.WebhookInput{
+		Name: "siem", URL: ts.URL, Secret: "s3cret", Events: []string{service.WebhookSessionClosed},
+	}, "admin")
+	if err != nil || generated != "" {
+		t.Fatalf("create: generated=%q err=%v", generated, err)
+	}
+
+	svc.Notify(service.WebhookSessionStarted, map[string]string{"connectionId": "c1"})
+	svc.Notify(service.WebhookSessionClosed, map[string]string{"connectionId": "c1"})
+	rcv.wait(t, 2)
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	if len(rcv.reqs) != 2 {
+		t.Fatalf("want one failed attempt and one retry, got %d requests", len(rcv.reqs))
+	}
+	for i, r := range rcv.reqs {
+		if r.Header.Get(service.WebhookEventHeader) != service.WebhookSessionClosed {
+			t.Fatalf("unsubscribed event delivered: %s", r.Header.Get(service.WebhookEventHeader))
+		}
+		if got, want := r.Header.Get(service.WebhookSignatureHeader), service.SignWebhook([]byte("s3cret"), rcv.bodies[i]); got != want {
+			t.Fatalf("signature = %q, want %q", got, want)
+		}
+	}
+	if rcv.reqs[0].Header.Get(service.WebhookDeliveryHeader) != rcv.reqs[1].Header.Get(service.WebhookDeliveryHeader) {
+		t.Fatal("a retry should reuse the delivery id")
+	}
+	var payload struct {
+		Event string            `json:"event"`
+		Data  map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal(rcv.bodies[1], &payload); err != nil || payload.Data["connectionId"] != "c1" {
+		t.Fatalf("payload = %s (%v)", rcv.bodies[1], err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		history, _ := svc.Deliveries(ctx, hook.ID)
+		if len(history) == 2 {
+			if history[0].Attempt != 2 || history[0].StatusCode != http.StatusOK || history[1].StatusCode != http.StatusBadGateway || history[1].Error == "" {
+				t.Fatalf("history = %+v", history)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("history never reached 2 attempts: %+v", history)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWebhookNotifyNeverBlocks(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newWebhookService(t)
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { <-release }))
+	t.Cleanup(ts.Close)
+	t.Cleanup(func() { close(release) })
+	if _, _, err := svc.Create(ctx, service.WebhookInput{Name: "slow", URL: ts.URL}, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for range 1000 {
+			svc.Notify(service.WebhookSessionStarted, nil)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Notify blocked behind a hung endpoint")
+	}
+}
+
+func TestWebhookTestAndHistoryLimit(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newWebhookService(t)
+	rcv, ts := newWebhookReceiver(t, http.StatusNoContent)
+	hook, secret, err := svc.Create(ctx, service.WebhookInput{Name: "t", URL: ts.URL}, "admin")
+	if err != nil || len(secret) != 64 {
+		t.Fatalf("create should generate a secret: %q %v", secret, err)
+	}
+	for range service.WebhookHistoryLimit + 5 {
+		d, err := svc.Test(ctx, hook.ID)
+		if err != nil || d.StatusCode != http.StatusNoContent || !d.Test {
+			t.Fatalf("test delivery: %+v %v", d, err)
+		}
+	}
+	rcv.wait(t, service.WebhookHistoryLimit+5)
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	if got := rcv.reqs[0].Header.Get(service.WebhookSignatureHeader); got != service.SignWebhook([]byte(secret), rcv.bodies[0]) {
+		t.Fatal("test delivery should be signed with the generated secret")
+	}
+	history, err := svc.Deliveries(ctx, hook.ID)
+	if err != nil || len(history) != service.WebhookHistoryLimit {
+		t.Fatalf("history: %d entries, err=%v", len(history), err)
+	}
+
+	if err := svc.Delete(ctx, hook.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Deliveries(ctx, hook.ID); !errors.Is(err, plugin.ErrNotFound) {
+		t.Fatalf("deliveries after delete: %v", err)
+	}
+}
+
+func TestWebhookValidation(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newWebhookService(t)
+	for _, in := range []service.WebhookInput{
+		{URL: "https://example.com/hook"},
+		{Name: "x", URL: "ftp://example.com"},
+		{Name: "x", URL: "/relative"},
+		{Name: "x", URL: "https://example.com", Events: []string{"session.exploded"}},
+	} {
+		if _, _, err := svc.Create(ctx, in, "admin"); !errors.Is(err, plugin.ErrInvalidInput) {
+			t.Errorf("create %+v: want invalid input, got %v", in, err)
+		}
+	}
+}
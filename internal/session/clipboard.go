@@ -0,0 +1,138 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// DefaultClipboardBytes caps clipboard text when Options.ClipboardBytes is 0.
+const DefaultClipboardBytes = 256 << 10
+
+var (
+	// ErrNoClipboard is returned for a session whose plugin does not sync a
+	// clipboard.
+	ErrNoClipboard = errors.New("session: clipboard not supported")
+	// ErrClipboardTooLarge is returned for text over the clipboard limit.
+	ErrClipboardTooLarge = errors.New("session: clipboard text too large")
+)
+
+// Clipboard is the remote clipboard text a session's plugin last reported.
+// UpdatedAt is zero until the plugin reports any.
+type Clipboard struct {
+	Text      string
+	UpdatedAt time.Time
+}
+
+// clipboardHolder keeps the last remote clipboard text of one session. It
+// locks apart from the entry, since plugins report from their own goroutines,
+// some of them while the entry is still connecting.
+type clipboardHolder struct {
+	mu     sync.Mutex
+	clip   Clipboard
+	closed bool
+}
+
+func (h *clipboardHolder) set(text string, at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.closed {
+		h.clip = Clipboard{Text: text, UpdatedAt: at}
+	}
+}
+
+func (h *clipboardHolder) get() Clipboard {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.clip
+}
+
+// close drops the held text for good; reports arriving later are ignored.
+func (h *clipboardHolder) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clip, h.closed = Clipboard{}, true
+}
+
+// watchClipboard subscribes e to sess's clipboard when the plugin syncs one.
+// Text over the limit is not held, and clears what was.
+func (m *Manager) watchClipboard(e *entry, sess plugin.Session) {
+	cs, ok := sess.(plugin.ClipboardSupporter)
+	if !ok {
+		return
+	}
+	cs.OnClipboard(func(text string) {
+		if len(text) > m.opts.ClipboardBytes {
+			text = ""
+		}
+		e.clipboard.set(text, m.now())
+	})
+}
+
+// ClipboardLimit is the most bytes of clipboard text relayed either way.
+func (m *Manager) ClipboardLimit() int {
+	return m.opts.ClipboardBytes
+}
+
+// ClipboardSession returns the live session with the given ID, which need
+// not sync a clipboard, without counting the lookup as use. Callers
+// authorize against it before SetClipboard or Clipboard touch the session.
+func (m *Manager) ClipboardSession(id string) (Snapshot, error) {
+	e := m.entryByID(id)
+	if e == nil {
+		return Snapshot{}, ErrSessionNotFound
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed || e.sess == nil {
+		return Snapshot{}, ErrSessionNotFound
+	}
+	return e.snapshotLocked(""), nil
+}
+
+// clipboardOf returns the live session with the given ID and its plugin's
+// clipboard capability, counting the call as use of the session.
+func (m *Manager) clipboardOf(id string) (Snapshot, *entry, plugin.ClipboardSupporter, error) {
+	e := m.entryByID(id)
+	if e == nil {
+		return Snapshot{}, nil, nil, ErrSessionNotFound
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed || e.sess == nil {
+		return Snapshot{}, nil, nil, ErrSessionNotFound
+	}
+	snap := e.snapshotLocked("")
+	cs, ok := e.sess.(plugin.ClipboardSupporter)
+	if !ok {
+		return snap, nil, nil, ErrNoClipboard
+	}
+	e.lastUsed = m.now()
+	return snap, e, cs, nil
+}
+
+// SetClipboard replaces the remote clipboard of the live session with the
+// given ID. The text is handed to the plugin and not kept.
+func (m *Manager) SetClipboard(ctx context.Context, id, text string) (Snapshot, error) {
+	snap, _, cs, err := m.clipboardOf(id)
+	if err != nil {
+		return snap, err
+	}
+	if len(text) > m.opts.ClipboardBytes {
+		return snap, ErrClipboardTooLarge
+	}
+	return snap, cs.SetClipboard(ctx, text)
+}
+
+// Clipboard returns the remote clipboard text the live session with the
+// given ID last reported.
+func (m *Manager) Clipboard(id string) (Snapshot, Clipboard, error) {
+	snap, e, _, err := m.clipboardOf(id)
+	if err != nil {
+		return snap, Clipboard{}, err
+	}
+	return snap, e.clipboard.get(), nil
+}
@@ -2,6 +2,7 @@ package session
 
 import (
 	"context"
+	"fmt"
 	"sync"
 
 	"github.com/charlesng35/shellcn/sdk/plugin"
@@ -57,39 +58,112 @@ func (h *Handle) TrackStream() func() {
 	}
 }
 
+// MeterStream counts a browser stream's traffic toward the session's byte
+// totals: reads are browser→upstream input, writes upstream→browser output.
+// Writes are also relayed to the session's observers.
+func (h *Handle) MeterStream(client plugin.ClientStream) plugin.ClientStream {
+	return &meteredStream{ClientStream: client, e: h.e}
+}
+
+type meteredStream struct {
+	plugin.ClientStream
+	e *entry
+}
+
+func (s *meteredStream) Read(p []byte) (int, error) {
+	n, err := s.ClientStream.Read(p)
+	if n > 0 {
+		s.e.bytesIn.Add(int64(n))
+	}
+	return n, err
+}
+
+func (s *meteredStream) Write(p []byte) (int, error) {
+	n, err := s.ClientStream.Write(p)
+	if n > 0 {
+		s.e.bytesOut.Add(int64(n))
+		s.e.observers.publish(p[:n])
+	}
+	return n, err
+}
+
 // OpenChannel opens a tracked upstream stream, enforcing the per-session channel
 // cap. The returned channel decrements the counter exactly once on Close.
 func (h *Handle) OpenChannel(ctx context.Context, req plugin.ChannelRequest) (plugin.Channel, error) {
+	e := h.e
+	sess, err := h.reserveChannel()
+	if err != nil {
+		return nil, err
+	}
+	ch, err := sess.OpenChannel(ctx, req)
+	if err != nil {
+		h.releaseChannel()
+		return nil, err
+	}
+	var tracked plugin.Channel
+	tracked = wrapTrackedChannel(ch, func() {
+		e.mu.Lock()
+		if e.channels > 0 {
+			e.channels--
+		}
+		if r, ok := tracked.(*trackedResizableChannel); ok {
+			delete(e.terminals, r)
+		}
+		e.lastUsed = h.m.now()
+		e.mu.Unlock()
+	}, func(cols, rows int) { h.m.recordSize(e, cols, rows) })
+	if r, ok := tracked.(*trackedResizableChannel); ok {
+		e.mu.Lock()
+		if e.terminals == nil {
+			e.terminals = map[resizeChannel]struct{}{}
+		}
+		e.terminals[r] = struct{}{}
+		e.mu.Unlock()
+	}
+	return tracked, nil
+}
+
+// Exec runs a non-interactive command on the session. It holds a channel slot
+// while it runs, so it is capped like any other upstream stream.
+func (h *Handle) Exec(ctx context.Context, req plugin.ExecRequest) (int, error) {
+	sess, err := h.reserveChannel()
+	if err != nil {
+		return 0, err
+	}
+	defer h.releaseChannel()
+	ex, ok := sess.(plugin.Executor)
+	if !ok {
+		return 0, fmt.Errorf("%w: this session cannot run commands", plugin.ErrNotSupported)
+	}
+	return ex.Exec(ctx, req)
+}
+
+func (h *Handle) reserveChannel() (plugin.Session, error) {
 	e := h.e
 	e.mu.Lock()
+	defer e.mu.Unlock()
 	if e.closed || e.sess == nil {
-		e.mu.Unlock()
 		return nil, ErrSessionClosed
 	}
+	if e.reconnecting {
+		return nil, ErrSessionReconnecting
+	}
 	if e.channels >= h.m.opts.MaxChannelsPerSession {
-		e.mu.Unlock()
 		return nil, ErrChannelLimit
 	}
 	e.channels++
 	e.lastUsed = h.m.now()
-	sess := e.sess
-	e.mu.Unlock()
+	return e.sess, nil
+}
 
-	ch, err := sess.OpenChannel(ctx, req)
-	if err != nil {
-		e.mu.Lock()
+func (h *Handle) releaseChannel() {
+	e := h.e
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.channels > 0 {
 		e.channels--
-		e.mu.Unlock()
-		return nil, err
 	}
-	return wrapTrackedChannel(ch, func() {
-		e.mu.Lock()
-		if e.channels > 0 {
-			e.channels--
-		}
-		e.lastUsed = h.m.now()
-		e.mu.Unlock()
-	}), nil
+	e.lastUsed = h.m.now()
 }
 
 // Close closes this managed session through the registry so bookkeeping, leases,
@@ -120,7 +194,9 @@ type serverInitChannel interface {
 	ServerInit() []byte
 }
 
-func wrapTrackedChannel(ch plugin.Channel, release func()) plugin.Channel {
+// wrapTrackedChannel wraps ch so Close runs release once. Terminal resizes are
+// reported to resized; desktop resizes are pixel sizes and are not.
+func wrapTrackedChannel(ch plugin.Channel, release func(), resized func(cols, rows int)) plugin.Channel {
 	base := &trackedChannel{Channel: ch, release: release}
 	resizer, canResize := ch.(resizeChannel)
 	serverInit, hasServerInit := ch.(serverInitChannel)
@@ -128,7 +204,7 @@ func wrapTrackedChannel(ch plugin.Channel, release func()) plugin.Channel {
 	case canResize && hasServerInit:
 		return &trackedResizableDesktopChannel{trackedChannel: base, resizer: resizer, serverInit: serverInit}
 	case canResize:
-		return &trackedResizableChannel{trackedChannel: base, resizer: resizer}
+		return &trackedResizableChannel{trackedChannel: base, resizer: resizer, resized: resized}
 	case hasServerInit:
 		return &trackedDesktopChannel{trackedChannel: base, serverInit: serverInit}
 	default:
@@ -139,10 +215,17 @@ func wrapTrackedChannel(ch plugin.Channel, release func()) plugin.Channel {
 type trackedResizableChannel struct {
 	*trackedChannel
 	resizer resizeChannel
+	resized func(cols, rows int)
 }
 
 func (c *trackedResizableChannel) Resize(cols, rows int) error {
-	return c.resizer.Resize(cols, rows)
+	if err := c.resizer.Resize(cols, rows); err != nil {
+		return err
+	}
+	if c.resized != nil {
+		c.resized(cols, rows)
+	}
+	return nil
 }
 
 type trackedDesktopChannel struct {
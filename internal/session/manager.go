@@ -4,10 +4,17 @@ package session
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
+
+	"github.com/charlesng35/shellcn/internal/audit"
 	"github.com/charlesng35/shellcn/internal/livelease"
+	"github.com/charlesng35/shellcn/internal/models"
 	"github.com/charlesng35/shellcn/sdk/plugin"
 )
 
@@ -18,17 +25,55 @@ var (
 	ErrSessionLimit = errors.New("session: per-user limit reached")
 	// ErrChannelLimit is returned when a session is at its max channel count.
 	ErrChannelLimit = errors.New("session: per-session channel limit reached")
+	// ErrSessionReconnecting is returned when opening a channel while the
+	// session's transport is being re-established.
+	ErrSessionReconnecting = errors.New("session: reconnecting")
+	// ErrTransportLost is the close reason of a session whose transport did not
+	// come back within the reconnect grace window.
+	ErrTransportLost = errors.New("transport_lost")
+	// ErrMaxLifetime is the close reason of a session open longer than
+	// Options.MaxLifetime.
+	ErrMaxLifetime = errors.New("max_lifetime")
+	// ErrLaunchTimeout is the close reason of a background launch still
+	// pending after Options.LaunchTimeout.
+	ErrLaunchTimeout = errors.New("launch_timeout")
+	// ErrNoTerminal is returned for input to a session with no terminal open.
+	ErrNoTerminal = errors.New("session: no terminal open")
 )
 
+// LimitError is ErrSessionLimit with the cap that applied and how many
+// sessions the user already had open.
+type LimitError struct {
+	Limit  int
+	Active int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %d of %d sessions open", ErrSessionLimit, e.Active, e.Limit)
+}
+
+func (e *LimitError) Is(target error) bool { return target == ErrSessionLimit }
+
 // Key identifies one live session for an actor's scope on a connection.
 type Key struct {
 	ConnectionID string
 	ActorScope   string
 }
 
-// ConnectFunc lazily opens the upstream session on first use.
+// ConnectFunc lazily opens the upstream session on first use. It may record
+// metadata on the session being opened with SetMetadata.
 type ConnectFunc func(ctx context.Context) (plugin.Session, error)
 
+type metadataKey struct{}
+
+// SetMetadata attaches a value to the session a ConnectFunc is opening; it is
+// reported in the session's snapshots. Outside a ConnectFunc it does nothing.
+func SetMetadata(ctx context.Context, name string, v any) {
+	if md, ok := ctx.Value(metadataKey{}).(map[string]any); ok {
+		md[name] = v
+	}
+}
+
 // State is the lifecycle state of a registry entry.
 type State string
 
@@ -37,10 +82,18 @@ const (
 	StateConnected  State = "connected"
 	StateClosed     State = "closed"
 	StateError      State = "error"
+	// StateReconnecting is a session whose transport dropped; it stays
+	// registered while a plugin.Reconnectable session tries to come back.
+	StateReconnecting State = "reconnecting"
+	// StatePending is a session Start is opening in the background.
+	StatePending State = "pending"
 )
 
 // Snapshot is a point-in-time view of one live registry entry.
 type Snapshot struct {
+	// ID identifies this registry entry; a key that reconnects after its
+	// session closed gets a new ID.
+	ID              string
 	Key             Key
 	UserID          string
 	State           State
@@ -50,12 +103,31 @@ type Snapshot struct {
 	LastUsed        time.Time
 	CreatedAt       time.Time
 	LastHealthCheck time.Time
+	// Cols and Rows are the last terminal size applied to the session, zero
+	// until a terminal channel has been resized.
+	Cols int
+	Rows int
+	// Client is where the request that opened the session came from.
+	Client models.ClientInfo
+	// Stage is the last step a pending launch reported.
+	Stage plugin.ConnectStage
+	// Metadata is what the ConnectFunc recorded with SetMetadata; shared
+	// between snapshots and must not be modified.
+	Metadata map[string]any
+	// BytesIn counts what metered streams read from the browser, BytesOut
+	// what they wrote to it.
+	BytesIn  int64
+	BytesOut int64
 }
 
 // Options bound the registry. Zero values fall back to sensible defaults.
 type Options struct {
-	IdleTimeout           time.Duration
-	MaxSessionsPerUser    int
+	IdleTimeout        time.Duration
+	MaxSessionsPerUser int
+	// UserLimit, when set, replaces MaxSessionsPerUser with a cap looked up per
+	// user; 0 or less means no cap. It is called with the registry locked and
+	// should answer from a cache.
+	UserLimit             func(ctx context.Context, userID string) int
 	MaxChannelsPerSession int
 	HealthInterval        time.Duration
 	FailureRetention      time.Duration
@@ -63,6 +135,42 @@ type Options struct {
 	Instance              livelease.InstanceRef
 	LeaseTTL              time.Duration
 	RenewInterval         time.Duration
+	// OnOpen and OnClose observe upstream sessions connecting and going away.
+	// They run on the caller's goroutine and must not block.
+	OnOpen  func(Snapshot)
+	OnClose func(Snapshot)
+	// OnResize observes the session's terminal size changing, under the same
+	// rules as OnOpen.
+	OnResize func(Snapshot)
+	// ReconnectGrace is how long a plugin.Reconnectable session whose health
+	// check failed stays registered while it reconnects. Default 60s.
+	ReconnectGrace time.Duration
+	// OnReconnecting observes a session entering its reconnect window, under
+	// the same rules as OnOpen.
+	OnReconnecting func(Snapshot)
+	// ScrollbackBytes caps the terminal output each session keeps for
+	// scrollback search; 0 means DefaultScrollbackBytes and less than 0 keeps
+	// none.
+	ScrollbackBytes int
+	// CloseTimeout is how long closing an upstream session may block the
+	// caller; a plugin Close still running after it is left to finish in the
+	// background and reported to OnCloseStuck. Default 10s.
+	CloseTimeout time.Duration
+	// OnCloseStuck observes such a Close, under the same rules as OnOpen.
+	OnCloseStuck func(Snapshot)
+	// MaxLifetime closes sessions open longer than it, however busy they
+	// are; 0 means no limit.
+	MaxLifetime time.Duration
+	// LaunchTimeout bounds a background launch: its ConnectFunc's context
+	// ends then, and the janitor fails the session if the plugin ignores
+	// that. Default 2m.
+	LaunchTimeout time.Duration
+	// OnLaunch observes a background launch reaching a new stage, connecting
+	// or failing, under the same rules as OnOpen.
+	OnLaunch func(Snapshot)
+	// ClipboardBytes caps clipboard text relayed either way through a
+	// plugin.ClipboardSupporter session; 0 means DefaultClipboardBytes.
+	ClipboardBytes int
 }
 
 func (o Options) withDefaults() Options {
@@ -78,6 +186,9 @@ func (o Options) withDefaults() Options {
 	if o.HealthInterval <= 0 {
 		o.HealthInterval = 30 * time.Second
 	}
+	if o.ScrollbackBytes == 0 {
+		o.ScrollbackBytes = DefaultScrollbackBytes
+	}
 	if o.FailureRetention <= 0 {
 		o.FailureRetention = 2 * time.Minute
 	}
@@ -87,11 +198,24 @@ func (o Options) withDefaults() Options {
 	if o.RenewInterval <= 0 || o.RenewInterval >= o.LeaseTTL {
 		o.RenewInterval = o.LeaseTTL / 3
 	}
+	if o.ReconnectGrace <= 0 {
+		o.ReconnectGrace = time.Minute
+	}
+	if o.CloseTimeout <= 0 {
+		o.CloseTimeout = 10 * time.Second
+	}
+	if o.LaunchTimeout <= 0 {
+		o.LaunchTimeout = 2 * time.Minute
+	}
+	if o.ClipboardBytes <= 0 {
+		o.ClipboardBytes = DefaultClipboardBytes
+	}
 	return o
 }
 
 type entry struct {
 	mu              sync.Mutex
+	id              string
 	key             Key
 	userID          string
 	sess            plugin.Session
@@ -102,7 +226,24 @@ type entry struct {
 	lastHealthCheck time.Time
 	reason          string
 	closed          bool
+	reconnecting    bool
 	lease           livelease.Lease
+	cols, rows      int
+	terminals       map[resizeChannel]struct{}
+	client          models.ClientInfo
+	metadata        map[string]any
+	// bytesIn and bytesOut are updated without mu from stream relays.
+	bytesIn, bytesOut atomic.Int64
+	observers         observerSet
+	clipboard         clipboardHolder
+	// scrollback holds terminal output once a terminal stream is captured;
+	// nil before that and after the session closes.
+	scrollback *scrollback
+	// launching is closed when a background launch finishes; nil otherwise.
+	launching chan struct{}
+	stage     plugin.ConnectStage
+	// launchErr is why a background launch failed, for callers that waited.
+	launchErr error
 }
 
 type failure struct {
@@ -110,6 +251,13 @@ type failure struct {
 	expiresAt time.Time
 }
 
+// Reconnect attempts back off from reconnectBackoff, doubling up to
+// maxReconnectBackoff.
+const (
+	reconnectBackoff    = 500 * time.Millisecond
+	maxReconnectBackoff = 10 * time.Second
+)
+
 // Manager owns the session registry and lifecycle.
 type Manager struct {
 	mu       sync.Mutex
@@ -119,6 +267,8 @@ type Manager struct {
 	now      func() time.Time
 	stop     chan struct{}
 	wg       sync.WaitGroup
+	// stuckCloses counts plugin Close calls still running past CloseTimeout.
+	stuckCloses atomic.Int64
 }
 
 // New starts a manager and its background janitor.
@@ -136,41 +286,39 @@ func New(opts Options) *Manager {
 }
 
 // Acquire returns the live session for key, lazily connecting on first use.
+// A session Start is still opening is waited for rather than dialed again.
 func (m *Manager) Acquire(ctx context.Context, key Key, userID string, connect ConnectFunc) (*Handle, error) {
-	m.mu.Lock()
-	e, ok := m.sessions[key]
-	if !ok {
-		if n := m.countUser(userID); n >= m.opts.MaxSessionsPerUser {
-			m.mu.Unlock()
-			return nil, ErrSessionLimit
-		}
-		now := m.now()
-		var lease livelease.Lease
-		if m.opts.LeaseRegistry != nil {
-			var err error
-			lease, err = m.opts.LeaseRegistry.Claim(ctx, livelease.SessionLeaseKey(key.ConnectionID, key.ActorScope), m.opts.Instance, livelease.ClaimOptions{
-				Mode: livelease.ClaimExclusive,
-				TTL:  m.opts.LeaseTTL,
-			})
-			if err != nil {
-				m.mu.Unlock()
-				return nil, err
-			}
-		}
-		e = &entry{key: key, userID: userID, lastUsed: now, created: now, lease: lease}
-		m.sessions[key] = e
-		delete(m.failures, key)
+	e, err := m.register(ctx, key, userID)
+	if err != nil {
+		return nil, err
 	}
-	m.mu.Unlock()
 
 	e.mu.Lock()
+	for e.launching != nil {
+		done := e.launching
+		e.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		e.mu.Lock()
+	}
 	if e.closed {
+		err := e.launchErr
 		e.mu.Unlock()
-		return nil, ErrSessionClosed
+		if err == nil {
+			err = ErrSessionClosed
+		}
+		return nil, err
 	}
 	if e.sess == nil {
-		sess, err := connect(ctx)
+		md := map[string]any{}
+		sess, err := connect(context.WithValue(ctx, metadataKey{}, md))
 		now := m.now()
+		if len(md) > 0 {
+			e.metadata = md
+		}
 		if err != nil {
 			e.lastUsed = now
 			e.lastHealthCheck = now
@@ -187,7 +335,6 @@ func (m *Manager) Acquire(ctx context.Context, key Key, userID string, connect C
 			return nil, err
 		}
 		if err := sess.HealthCheck(ctx); err != nil {
-			_ = sess.Close()
 			e.lastUsed = now
 			e.lastHealthCheck = now
 			e.reason = err.Error()
@@ -196,6 +343,7 @@ func (m *Manager) Acquire(ctx context.Context, key Key, userID string, connect C
 			lease := e.lease
 			e.lease = nil
 			e.mu.Unlock()
+			m.closeUpstream(sess, snap)
 			m.removeAndRememberFailure(key, e, snap)
 			if lease != nil {
 				_ = lease.Release(context.Background())
@@ -205,12 +353,191 @@ func (m *Manager) Acquire(ctx context.Context, key Key, userID string, connect C
 		e.sess = sess
 		e.lastHealthCheck = now
 		e.reason = ""
+		m.watchClipboard(e, sess)
+		if m.opts.OnOpen != nil {
+			m.opts.OnOpen(e.snapshotLocked(StateConnected))
+		}
 	}
 	e.lastUsed = m.now()
 	e.mu.Unlock()
 	return &Handle{m: m, e: e}, nil
 }
 
+// register returns the entry for key, creating it when the user is under
+// their limit. A new entry counts toward the limit before it connects, so
+// launches in flight cannot overshoot it.
+func (m *Manager) register(ctx context.Context, key Key, userID string) (*entry, error) {
+	m.mu.Lock()
+	e, ok := m.sessions[key]
+	m.mu.Unlock()
+	if ok {
+		return e, nil
+	}
+	// The limit and the cluster-wide count come from the database; look them
+	// up before taking m.mu so a slow query cannot stall every session.
+	limit, cluster := m.userLimit(ctx, userID)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.sessions[key]; ok {
+		return e, nil
+	}
+	if err := m.checkUserLimit(userID, limit, cluster); err != nil {
+		return nil, err
+	}
+	now := m.now()
+	var lease livelease.Lease
+	if m.opts.LeaseRegistry != nil {
+		var err error
+		lease, err = m.opts.LeaseRegistry.Claim(ctx, livelease.SessionLeaseKey(key.ConnectionID, key.ActorScope), m.opts.Instance, livelease.ClaimOptions{
+			Mode: livelease.ClaimExclusive,
+			TTL:  m.opts.LeaseTTL,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	e = &entry{id: uuid.NewString(), key: key, userID: userID, lastUsed: now, created: now, lease: lease, client: audit.ClientFrom(ctx)}
+	m.sessions[key] = e
+	delete(m.failures, key)
+	return e, nil
+}
+
+// Start registers the session for key and opens it in the background,
+// returning a pending snapshot at once; a session already open or opening is
+// returned as it is. The launch outlives ctx but keeps its values. Stages the
+// ConnectFunc reports with plugin.ReportStage, and the outcome, go to
+// OnLaunch.
+func (m *Manager) Start(ctx context.Context, key Key, userID string, connect ConnectFunc) (Snapshot, error) {
+	e, err := m.register(ctx, key, userID)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return Snapshot{}, ErrSessionClosed
+	}
+	if e.sess == nil && e.launching == nil {
+		e.launching = make(chan struct{})
+		go m.launch(context.WithoutCancel(ctx), e, connect)
+	}
+	e.lastUsed = m.now()
+	return e.snapshotLocked(""), nil
+}
+
+// Await waits until a background launch of the session at key finishes or
+// ctx ends, then returns the session's status.
+func (m *Manager) Await(ctx context.Context, key Key) (Snapshot, bool) {
+	m.mu.Lock()
+	e, ok := m.sessions[key]
+	m.mu.Unlock()
+	if ok {
+		e.mu.Lock()
+		done := e.launching
+		e.mu.Unlock()
+		if done != nil {
+			select {
+			case <-done:
+			case <-ctx.Done():
+			}
+		}
+	}
+	return m.Status(key)
+}
+
+func (m *Manager) launch(ctx context.Context, e *entry, connect ConnectFunc) {
+	bounded, cancel := context.WithTimeout(ctx, m.opts.LaunchTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-m.stop:
+			cancel()
+		case <-bounded.Done():
+		}
+	}()
+	md := map[string]any{}
+	ctx = context.WithValue(bounded, metadataKey{}, md)
+	ctx = plugin.WithStageReporter(ctx, func(stage plugin.ConnectStage) { m.reportStage(e, stage) })
+	sess, err := connect(ctx)
+	if err == nil {
+		if err = sess.HealthCheck(ctx); err != nil {
+			_ = sess.Close()
+			sess = nil
+		}
+	}
+
+	e.mu.Lock()
+	if len(md) > 0 {
+		e.metadata = md
+	}
+	if e.closed {
+		// The janitor or a Close got there first.
+		snap := e.snapshotLocked(StateClosed)
+		e.mu.Unlock()
+		if sess != nil {
+			m.closeUpstream(sess, snap)
+		}
+		return
+	}
+	now := m.now()
+	e.lastUsed = now
+	e.lastHealthCheck = now
+	e.stage = ""
+	if err != nil {
+		e.reason = err.Error()
+		e.launchErr = err
+		snap := e.snapshotLocked(StateError)
+		e.closed = true
+		e.finishLaunchLocked()
+		lease := e.lease
+		e.lease = nil
+		e.mu.Unlock()
+		m.removeAndRememberFailure(e.key, e, snap)
+		if lease != nil {
+			_ = lease.Release(context.Background())
+		}
+		m.notifyLaunch(snap)
+		return
+	}
+	e.sess = sess
+	e.reason = ""
+	e.finishLaunchLocked()
+	m.watchClipboard(e, sess)
+	snap := e.snapshotLocked(StateConnected)
+	e.mu.Unlock()
+	if m.opts.OnOpen != nil {
+		m.opts.OnOpen(snap)
+	}
+	m.notifyLaunch(snap)
+}
+
+func (m *Manager) reportStage(e *entry, stage plugin.ConnectStage) {
+	e.mu.Lock()
+	if e.closed || e.launching == nil || e.stage == stage {
+		e.mu.Unlock()
+		return
+	}
+	e.stage = stage
+	snap := e.snapshotLocked("")
+	e.mu.Unlock()
+	m.notifyLaunch(snap)
+}
+
+func (m *Manager) notifyLaunch(snap Snapshot) {
+	if m.opts.OnLaunch != nil {
+		m.opts.OnLaunch(snap)
+	}
+}
+
+// finishLaunchLocked wakes callers waiting on a background launch (caller
+// holds e.mu).
+func (e *entry) finishLaunchLocked() {
+	if e.launching != nil {
+		close(e.launching)
+		e.launching = nil
+	}
+}
+
 // Status returns a snapshot for key without creating or connecting a session.
 func (m *Manager) Status(key Key) (Snapshot, bool) {
 	m.mu.Lock()
@@ -232,6 +559,89 @@ func (m *Manager) Status(key Key) (Snapshot, bool) {
 	return e.snapshot(), true
 }
 
+// Resize applies cols x rows to every open terminal channel of the live
+// session at key and records it as the session's size, so later viewers start
+// from the same geometry.
+func (m *Manager) Resize(key Key, cols, rows int) (Snapshot, error) {
+	m.mu.Lock()
+	e, ok := m.sessions[key]
+	m.mu.Unlock()
+	if !ok {
+		return Snapshot{}, ErrSessionClosed
+	}
+	e.mu.Lock()
+	if e.closed || e.sess == nil {
+		e.mu.Unlock()
+		return Snapshot{}, ErrSessionClosed
+	}
+	terminals := make([]resizeChannel, 0, len(e.terminals))
+	for ch := range e.terminals {
+		terminals = append(terminals, ch)
+	}
+	e.lastUsed = m.now()
+	e.mu.Unlock()
+
+	for _, ch := range terminals {
+		if err := ch.Resize(cols, rows); err != nil {
+			return Snapshot{}, err
+		}
+	}
+	m.recordSize(e, cols, rows)
+	return e.snapshot(), nil
+}
+
+// Input writes p to every open terminal channel of the live session at key,
+// as typing from a shared-session participant who holds write. It counts as
+// input to, and use of, the session.
+func (m *Manager) Input(key Key, p []byte) error {
+	m.mu.Lock()
+	e, ok := m.sessions[key]
+	m.mu.Unlock()
+	if !ok {
+		return ErrSessionClosed
+	}
+	e.mu.Lock()
+	if e.closed || e.sess == nil {
+		e.mu.Unlock()
+		return ErrSessionClosed
+	}
+	terminals := make([]io.Writer, 0, len(e.terminals))
+	for ch := range e.terminals {
+		if w, ok := ch.(io.Writer); ok {
+			terminals = append(terminals, w)
+		}
+	}
+	e.lastUsed = m.now()
+	e.mu.Unlock()
+
+	if len(terminals) == 0 {
+		return ErrNoTerminal
+	}
+	for _, w := range terminals {
+		if _, err := w.Write(p); err != nil {
+			return err
+		}
+	}
+	e.bytesIn.Add(int64(len(p)))
+	return nil
+}
+
+// recordSize stores a terminal size applied to e and reports a change to
+// OnResize.
+func (m *Manager) recordSize(e *entry, cols, rows int) {
+	e.mu.Lock()
+	if e.closed || (e.cols == cols && e.rows == rows) {
+		e.mu.Unlock()
+		return
+	}
+	e.cols, e.rows = cols, rows
+	snap := e.snapshotLocked("")
+	e.mu.Unlock()
+	if m.opts.OnResize != nil {
+		m.opts.OnResize(snap)
+	}
+}
+
 func (e *entry) snapshot() Snapshot {
 	e.mu.Lock()
 	defer e.mu.Unlock()
@@ -244,13 +654,19 @@ func (e *entry) snapshotLocked(force State) Snapshot {
 		state = force
 	} else if e.closed {
 		state = StateClosed
+	} else if e.reconnecting {
+		state = StateReconnecting
+	} else if e.launching != nil {
+		state = StatePending
 	} else if e.sess == nil {
 		state = StateConnecting
 	}
 	return Snapshot{
-		Key: e.key, UserID: e.userID, State: state, Reason: e.reason,
+		ID: e.id, Key: e.key, UserID: e.userID, State: state, Reason: e.reason,
 		Channels: e.channels, Streams: e.streams,
 		LastUsed: e.lastUsed, CreatedAt: e.created, LastHealthCheck: e.lastHealthCheck,
+		Cols: e.cols, Rows: e.rows, Client: e.client, Stage: e.stage, Metadata: e.metadata,
+		BytesIn: e.bytesIn.Load(), BytesOut: e.bytesOut.Load(),
 	}
 }
 
@@ -265,6 +681,37 @@ func (m *Manager) countUser(userID string) int {
 	return n
 }
 
+// userLimit resolves the user's session cap and, when the lease registry can
+// count sessions, how many they hold across all instances.
+func (m *Manager) userLimit(ctx context.Context, userID string) (limit, cluster int) {
+	limit = m.opts.MaxSessionsPerUser
+	if m.opts.UserLimit != nil {
+		limit = m.opts.UserLimit(ctx, userID)
+	}
+	if limit <= 0 {
+		return limit, 0
+	}
+	if counter, ok := m.opts.LeaseRegistry.(livelease.SessionCounter); ok {
+		if total, err := counter.CountSessions(ctx, userID); err == nil {
+			cluster = total
+		}
+	}
+	return limit, cluster
+}
+
+// checkUserLimit refuses another session for a user at limit, counting the
+// larger of their local sessions and the cluster count (caller holds m.mu).
+func (m *Manager) checkUserLimit(userID string, limit, cluster int) error {
+	if limit <= 0 {
+		return nil
+	}
+	n := max(m.countUser(userID), cluster)
+	if n >= limit {
+		return &LimitError{Limit: limit, Active: n}
+	}
+	return nil
+}
+
 func (m *Manager) removeAndRememberFailure(key Key, e *entry, snap Snapshot) {
 	m.mu.Lock()
 	if cur, ok := m.sessions[key]; ok && cur == e {
@@ -284,7 +731,7 @@ func (m *Manager) Close(key Key) {
 	delete(m.failures, key)
 	m.mu.Unlock()
 	if ok {
-		e.shutdown()
+		m.shutdown(e)
 	}
 }
 
@@ -307,7 +754,7 @@ func (m *Manager) CloseConnection(connectionID string) {
 	}
 	m.mu.Unlock()
 	for _, e := range entries {
-		e.shutdown()
+		m.shutdown(e)
 	}
 }
 
@@ -324,7 +771,7 @@ func (m *Manager) Shutdown() {
 	m.failures = make(map[Key]failure)
 	m.mu.Unlock()
 	for _, e := range entries {
-		e.shutdown()
+		m.shutdown(e)
 	}
 }
 
@@ -332,13 +779,16 @@ func (m *Manager) Shutdown() {
 type Stats struct {
 	Sessions int
 	Channels int
+	// StuckCloses is how many removed sessions' plugin Close calls are still
+	// running past CloseTimeout, each likely holding a connection or PTY.
+	StuckCloses int
 }
 
 // Stats returns the current session/channel counts.
 func (m *Manager) Stats() Stats {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	s := Stats{Sessions: len(m.sessions)}
+	s := Stats{Sessions: len(m.sessions), StuckCloses: int(m.stuckCloses.Load())}
 	for _, e := range m.sessions {
 		e.mu.Lock()
 		s.Channels += e.channels
@@ -347,31 +797,88 @@ func (m *Manager) Stats() Stats {
 	return s
 }
 
+// Active snapshots every session with a connected upstream.
+func (m *Manager) Active() []Snapshot {
+	m.mu.Lock()
+	entries := make([]*entry, 0, len(m.sessions))
+	for _, e := range m.sessions {
+		entries = append(entries, e)
+	}
+	m.mu.Unlock()
+	out := make([]Snapshot, 0, len(entries))
+	for _, e := range entries {
+		e.mu.Lock()
+		if e.sess != nil && !e.closed {
+			out = append(out, e.snapshotLocked(""))
+		}
+		e.mu.Unlock()
+	}
+	return out
+}
+
 // IdleTimeout returns the configured idle session timeout.
 func (m *Manager) IdleTimeout() time.Duration {
 	return m.opts.IdleTimeout
 }
 
-func (e *entry) shutdown() {
+func (m *Manager) shutdown(e *entry) {
 	e.mu.Lock()
 	if e.closed {
 		e.mu.Unlock()
 		return
 	}
 	e.closed = true
+	e.finishLaunchLocked()
+	snap := e.snapshotLocked(StateClosed)
 	sess := e.sess
 	lease := e.lease
 	e.sess = nil
 	e.lease = nil
+	e.scrollback = nil
 	e.mu.Unlock()
+	e.observers.close()
+	e.clipboard.close()
 	if sess != nil {
-		_ = sess.Close()
+		m.closeUpstream(sess, snap)
+		m.notifyClose(snap)
 	}
 	if lease != nil {
 		_ = lease.Release(context.Background())
 	}
 }
 
+// closeUpstream closes sess, waiting at most CloseTimeout so a hung plugin
+// cannot block the caller. A Close still running by then counts as stuck
+// until it returns.
+func (m *Manager) closeUpstream(sess plugin.Session, snap Snapshot) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = sess.Close()
+	}()
+	t := time.NewTimer(m.opts.CloseTimeout)
+	defer t.Stop()
+	select {
+	case <-done:
+		return
+	case <-t.C:
+	}
+	m.stuckCloses.Add(1)
+	if m.opts.OnCloseStuck != nil {
+		m.opts.OnCloseStuck(snap)
+	}
+	go func() {
+		<-done
+		m.stuckCloses.Add(-1)
+	}()
+}
+
+func (m *Manager) notifyClose(snap Snapshot) {
+	if m.opts.OnClose != nil {
+		m.opts.OnClose(snap)
+	}
+}
+
 func (m *Manager) janitor() {
 	defer m.wg.Done()
 	interval := m.opts.HealthInterval
@@ -408,19 +915,37 @@ func (m *Manager) sweep() {
 		idle := e.channels == 0 && e.streams == 0 && m.now().Sub(e.lastUsed) > m.opts.IdleTimeout
 		sess := e.sess
 		closed := e.closed
+		reconnecting := e.reconnecting
 		lease := e.lease
+		expired := m.opts.MaxLifetime > 0 && m.now().Sub(e.created) > m.opts.MaxLifetime
+		pending := e.launching != nil
 		e.mu.Unlock()
 
 		if closed {
 			continue
 		}
 		checkedAt := m.now()
+		if expired {
+			m.failEntry(e, ErrMaxLifetime, checkedAt)
+			continue
+		}
+		// A plugin that ignores its context would hold a pending launch, and
+		// its place under the user's limit, forever.
+		if pending && checkedAt.Sub(e.created) > m.opts.LaunchTimeout {
+			m.failEntry(e, ErrLaunchTimeout, checkedAt)
+			continue
+		}
 		if lease != nil {
 			if err := lease.Renew(ctx); err != nil {
 				m.failEntry(e, err, checkedAt)
 				continue
 			}
 		}
+		// A reconnecting session is neither idle nor due a health check: its
+		// reconnect loop decides whether it lives.
+		if reconnecting || pending {
+			continue
+		}
 		if idle {
 			m.Close(e.key)
 			continue
@@ -429,7 +954,9 @@ func (m *Manager) sweep() {
 			continue
 		}
 		if err := sess.HealthCheck(ctx); err != nil {
-			m.failEntry(e, err, checkedAt)
+			if !m.beginReconnect(e, sess, err) {
+				m.failEntry(e, err, checkedAt)
+			}
 			continue
 		}
 		e.mu.Lock()
@@ -460,17 +987,93 @@ func (m *Manager) failEntry(e *entry, err error, checkedAt time.Time) {
 	e.reason = err.Error()
 	snap := e.snapshotLocked(StateError)
 	e.closed = true
+	launching := e.launching != nil
+	if launching {
+		e.launchErr = err
+		e.finishLaunchLocked()
+	}
 	sess := e.sess
 	lease := e.lease
 	e.sess = nil
 	e.lease = nil
+	e.scrollback = nil
 	e.mu.Unlock()
+	e.observers.close()
+	e.clipboard.close()
 
 	m.removeAndRememberFailure(e.key, e, snap)
+	if launching {
+		m.notifyLaunch(snap)
+	}
 	if sess != nil {
-		_ = sess.Close()
+		m.closeUpstream(sess, snap)
+		m.notifyClose(snap)
 	}
 	if lease != nil {
 		_ = lease.Release(context.Background())
 	}
 }
+
+// beginReconnect keeps a failed Reconnectable session registered and starts
+// reconnecting it in the background. It reports false for sessions that
+// cannot reconnect, which the caller fails as before.
+func (m *Manager) beginReconnect(e *entry, sess plugin.Session, cause error) bool {
+	rc, ok := sess.(plugin.Reconnectable)
+	if !ok {
+		return false
+	}
+	e.mu.Lock()
+	if e.closed || e.sess != sess || e.reconnecting {
+		e.mu.Unlock()
+		return true
+	}
+	e.reconnecting = true
+	e.reason = cause.Error()
+	snap := e.snapshotLocked("")
+	e.mu.Unlock()
+	if m.opts.OnReconnecting != nil {
+		m.opts.OnReconnecting(snap)
+	}
+	m.wg.Add(1)
+	go m.reconnect(e, rc, m.now().Add(m.opts.ReconnectGrace))
+	return true
+}
+
+// reconnect retries rc with backoff until it succeeds, the grace window ends
+// (closing the session with ErrTransportLost), or the manager shuts down.
+func (m *Manager) reconnect(e *entry, rc plugin.Reconnectable, deadline time.Time) {
+	defer m.wg.Done()
+	ctx, cancel := context.WithTimeout(context.Background(), deadline.Sub(m.now()))
+	defer cancel()
+	go func() {
+		select {
+		case <-m.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	backoff := reconnectBackoff
+	for {
+		if err := rc.Reconnect(ctx); err == nil {
+			e.mu.Lock()
+			if !e.closed {
+				e.reconnecting = false
+				e.reason = ""
+				e.lastHealthCheck = m.now()
+			}
+			e.mu.Unlock()
+			return
+		}
+		select {
+		case <-time.After(backoff):
+			backoff = min(backoff*2, maxReconnectBackoff)
+		case <-ctx.Done():
+			select {
+			case <-m.stop:
+			default:
+				m.failEntry(e, ErrTransportLost, m.now())
+			}
+			return
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -64,6 +65,20 @@ func (c *fakeChannel) Kind() plugin.StreamKind     { return plugin.StreamTermina
 func (c *fakeChannel) Resize(int, int) error       { return nil }
 func (c *fakeChannel) ServerInit() []byte          { return []byte("rfb") }
 
+// termChannel is a resizable channel without a desktop handshake.
+type termChannel struct {
+	basicChannel
+	mu   sync.Mutex
+	size [2]int
+}
+
+func (c *termChannel) Resize(cols, rows int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size = [2]int{cols, rows}
+	return nil
+}
+
 type basicChannel struct{ closed atomic.Bool }
 
 func (c *basicChannel) Read([]byte) (int, error)    { return 0, io.EOF }
@@ -115,6 +130,38 @@ func TestAcquireLazyConnectAndReuse(t *testing.T) {
 	}
 }
 
+func TestLifecycleHooks(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	record := func(kind string) func(session.Snapshot) {
+		return func(s session.Snapshot) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, kind+":"+s.Key.ConnectionID+":"+string(s.State))
+		}
+	}
+	m := session.New(session.Options{OnOpen: record("open"), OnClose: record("close")})
+	defer m.Shutdown()
+	key := session.Key{ConnectionID: "c1", ActorScope: "u1"}
+
+	_, _ = m.Acquire(context.Background(), session.Key{ConnectionID: "bad", ActorScope: "u1"}, "u1",
+		func(context.Context) (plugin.Session, error) { return nil, errors.New("dial failed") })
+	for range 2 {
+		if _, err := m.Acquire(context.Background(), key, "u1", connector(&fakeSession{}, nil)); err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+	}
+	m.Close(key)
+	m.Close(key)
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"open:c1:connected", "close:c1:closed"}
+	if len(events) != len(want) || events[0] != want[0] || events[1] != want[1] {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+}
+
 func TestConnectErrorNotCached(t *testing.T) {
 	m := session.New(session.Options{})
 	defer m.Shutdown()
@@ -285,6 +332,53 @@ func TestTrackedChannelDoesNotInventOptionalCapabilities(t *testing.T) {
 	}
 }
 
+func TestResizeTracksSessionSize(t *testing.T) {
+	var mu sync.Mutex
+	var resized []session.Snapshot
+	m := session.New(session.Options{OnResize: func(s session.Snapshot) {
+		mu.Lock()
+		defer mu.Unlock()
+		resized = append(resized, s)
+	}})
+	defer m.Shutdown()
+	key := session.Key{ConnectionID: "c1", ActorScope: "u1"}
+	if _, err := m.Resize(key, 80, 24); !errors.Is(err, session.ErrSessionClosed) {
+		t.Fatalf("resize without a session: %v", err)
+	}
+	term := &termChannel{}
+	h, _ := m.Acquire(context.Background(), key, "u1", connector(&fakeSession{channel: term}, nil))
+	ch, err := h.OpenChannel(context.Background(), plugin.ChannelRequest{Kind: plugin.StreamTerminal})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	// An in-band resize from the terminal stream is recorded on the session.
+	if err := ch.(interface{ Resize(int, int) error }).Resize(100, 30); err != nil {
+		t.Fatalf("resize: %v", err)
+	}
+	if snap, _ := m.Status(key); snap.Cols != 100 || snap.Rows != 30 {
+		t.Fatalf("status size = %dx%d", snap.Cols, snap.Rows)
+	}
+	snap, err := m.Resize(key, 132, 43)
+	if err != nil || snap.Cols != 132 || snap.Rows != 43 {
+		t.Fatalf("resize: %+v %v", snap, err)
+	}
+	term.mu.Lock()
+	if term.size != [2]int{132, 43} {
+		t.Fatalf("channel size = %v", term.size)
+	}
+	term.mu.Unlock()
+	if _, err := m.Resize(key, 132, 43); err != nil {
+		t.Fatalf("repeat resize: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(resized) != 2 || resized[1].Cols != 132 || resized[1].Rows != 43 {
+		t.Fatalf("resize hook should fire once per change: %+v", resized)
+	}
+}
+
 func TestActiveStreamPreventsIdleReclaim(t *testing.T) {
 	m := session.New(session.Options{IdleTimeout: 10 * time.Millisecond, HealthInterval: 5 * time.Millisecond})
 	defer m.Shutdown()
@@ -307,7 +401,7 @@ func TestActiveStreamPreventsIdleReclaim(t *testing.T) {
 
 	release()
 	deadline := time.After(2 * time.Second)
-	for m.Stats().Sessions != 0 {
+	for m.Stats().Sessions != 0 || !fs.isClosed() {
 		select {
 		case <-deadline:
 			t.Fatal("idle session was not reclaimed after stream release")
@@ -319,6 +413,206 @@ func TestActiveStreamPreventsIdleReclaim(t *testing.T) {
 	}
 }
 
+type bufferStream struct {
+	in  io.Reader
+	out []byte
+}
+
+func (s *bufferStream) Read(p []byte) (int, error)  { return s.in.Read(p) }
+func (s *bufferStream) Write(p []byte) (int, error) { s.out = append(s.out, p...); return len(p), nil }
+func (s *bufferStream) Close() error                { return nil }
+func (s *bufferStream) Context() context.Context    { return context.Background() }
+
+func TestMeterStreamCountsBothDirections(t *testing.T) {
+	var closed session.Snapshot
+	m := session.New(session.Options{OnClose: func(s session.Snapshot) { closed = s }})
+	defer m.Shutdown()
+	key := session.Key{ConnectionID: "c1", ActorScope: "u1"}
+	h, err := m.Acquire(context.Background(), key, "u1", connector(&fakeSession{}, nil))
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	first := h.Snapshot().ID
+	stream := h.MeterStream(&bufferStream{in: strings.NewReader("ls -la\n")})
+	if _, err := io.ReadAll(stream); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		_, _ = stream.Write([]byte("0123456789"))
+	}
+	if snap, _ := m.Status(key); snap.BytesIn != 7 || snap.BytesOut != 30 {
+		t.Fatalf("status bytes in=%d out=%d, want 7/30", snap.BytesIn, snap.BytesOut)
+	}
+	m.Close(key)
+	if closed.ID != first || closed.BytesIn != 7 || closed.BytesOut != 30 {
+		t.Fatalf("close snapshot = %+v", closed)
+	}
+
+	h, err = m.Acquire(context.Background(), key, "u1", connector(&fakeSession{}, nil))
+	if err != nil {
+		t.Fatalf("reacquire: %v", err)
+	}
+	if snap := h.Snapshot(); snap.ID == "" || snap.ID == first || snap.BytesIn != 0 {
+		t.Fatalf("a new session must start a new count: %+v", snap)
+	}
+}
+
+func TestObserveRelaysOutputUntilClose(t *testing.T) {
+	m := session.New(session.Options{})
+	defer m.Shutdown()
+	key := session.Key{ConnectionID: "c1", ActorScope: "u1"}
+	h, err := m.Acquire(context.Background(), key, "u1", connector(&fakeSession{}, nil))
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, _, _, err := m.Observe("missing"); !errors.Is(err, session.ErrSessionNotFound) {
+		t.Fatalf("observe missing: %v", err)
+	}
+	snap, out, cancel, err := m.Observe(h.Snapshot().ID)
+	if err != nil || snap.UserID != "u1" {
+		t.Fatalf("observe: %+v %v", snap, err)
+	}
+	defer cancel()
+	stream := h.MeterStream(&bufferStream{in: strings.NewReader("")})
+	_, _ = stream.Write([]byte("prompt$ "))
+	if got := <-out; string(got) != "prompt$ " {
+		t.Fatalf("observed %q", got)
+	}
+	if s, _ := m.Status(key); s.Streams != 0 {
+		t.Fatalf("an observer must not count as a stream: %+v", s)
+	}
+	m.Close(key)
+	if _, ok := <-out; ok {
+		t.Fatal("closing the session must detach observers")
+	}
+}
+
+func TestScrollbackSearchAndEviction(t *testing.T) {
+	m := session.New(session.Options{ScrollbackBytes: 64})
+	defer m.Shutdown()
+	key := session.Key{ConnectionID: "c1", ActorScope: "u1"}
+	h, err := m.Acquire(context.Background(), key, "u1", connector(&fakeSession{}, nil))
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	id := h.Snapshot().ID
+	if _, _, err := m.Scrollback(id, 0, 10); !errors.Is(err, session.ErrNoScrollback) {
+		t.Fatalf("scrollback before capture: %v", err)
+	}
+	stream := h.CaptureScrollback(h.MeterStream(&bufferStream{in: strings.NewReader("")}))
+	_, _ = stream.Write([]byte("$ ls\r\n\x1b[01;34mDocuments\x1b[0m\r\n"))
+	_, _ = stream.Write([]byte("notes.txt\r\n$ "))
+
+	_, matches, err := m.SearchScrollback(id, session.ScrollbackQuery{Query: "documents", Context: 1})
+	if err != nil || len(matches) != 1 || matches[0].Line != 1 || len(matches[0].Context) != 3 {
+		t.Fatalf("search = %+v, %v", matches, err)
+	}
+	if raw := matches[0].Context[1].Raw; !strings.Contains(raw, "\x1b[01;34m") {
+		t.Fatalf("matched line must keep its escapes: %q", raw)
+	}
+	if _, matches, _ := m.SearchScrollback(id, session.ScrollbackQuery{Query: "01;34"}); len(matches) != 0 {
+		t.Fatalf("escape sequences must not match: %+v", matches)
+	}
+	if _, matches, err := m.SearchScrollback(id, session.ScrollbackQuery{Query: `^\w+\.txt$`, Regex: true}); err != nil || len(matches) != 1 || matches[0].Line != 2 {
+		t.Fatalf("regex search = %+v, %v", matches, err)
+	}
+	if _, _, err := m.SearchScrollback(id, session.ScrollbackQuery{Query: "(", Regex: true}); !errors.Is(err, plugin.ErrInvalidInput) {
+		t.Fatalf("bad regex: %v", err)
+	}
+
+	for range 10 {
+		_, _ = stream.Write([]byte("0123456789\n"))
+	}
+	_, page, err := m.Scrollback(id, 0, 100)
+	if err != nil || page.First == 0 || page.Lines[0].Line != page.First || page.Next != 13 {
+		t.Fatalf("page after eviction = %+v, %v", page, err)
+	}
+
+	m.Close(key)
+	if _, _, err := m.Scrollback(id, 0, 10); !errors.Is(err, session.ErrSessionNotFound) {
+		t.Fatalf("scrollback after close: %v", err)
+	}
+}
+
+// clipboardSession is a fakeSession that syncs a clipboard.
+type clipboardSession struct {
+	fakeSession
+	mu       sync.Mutex
+	pushed   string
+	onRemote func(string)
+}
+
+func (c *clipboardSession) SetClipboard(_ context.Context, text string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pushed = text
+	return nil
+}
+
+func (c *clipboardSession) OnClipboard(fn func(string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onRemote = fn
+}
+
+func (c *clipboardSession) remote(text string) {
+	c.mu.Lock()
+	fn := c.onRemote
+	c.mu.Unlock()
+	fn(text)
+}
+
+func TestClipboardRelayAndLimit(t *testing.T) {
+	m := session.New(session.Options{ClipboardBytes: 8})
+	defer m.Shutdown()
+	key := session.Key{ConnectionID: "c1", ActorScope: "u1"}
+	h, err := m.Acquire(context.Background(), key, "u1", connector(&fakeSession{}, nil))
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := m.SetClipboard(context.Background(), h.Snapshot().ID, "x"); !errors.Is(err, session.ErrNoClipboard) {
+		t.Fatalf("clipboard on a plain session: %v", err)
+	}
+	m.Close(key)
+
+	cs := &clipboardSession{}
+	h, err = m.Acquire(context.Background(), key, "u1", connector(cs, nil))
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	id := h.Snapshot().ID
+	if snap, err := m.SetClipboard(context.Background(), id, "hello"); err != nil || snap.UserID != "u1" || cs.pushed != "hello" {
+		t.Fatalf("push = %+v %q, %v", snap, cs.pushed, err)
+	}
+	if _, err := m.SetClipboard(context.Background(), id, "too long!"); !errors.Is(err, session.ErrClipboardTooLarge) {
+		t.Fatalf("oversized push: %v", err)
+	}
+	if _, clip, err := m.Clipboard(id); err != nil || clip.Text != "" || !clip.UpdatedAt.IsZero() {
+		t.Fatalf("clipboard before any report = %+v, %v", clip, err)
+	}
+	cs.remote("copied")
+	if _, clip, _ := m.Clipboard(id); clip.Text != "copied" || clip.UpdatedAt.IsZero() {
+		t.Fatalf("reported clipboard = %+v", clip)
+	}
+	cs.remote("far too long")
+	if _, clip, _ := m.Clipboard(id); clip.Text != "" {
+		t.Fatalf("an oversized report must clear the clipboard: %+v", clip)
+	}
+	cs.remote("copied")
+
+	if snap, err := m.ClipboardSession(id); err != nil || snap.UserID != "u1" {
+		t.Fatalf("lookup = %+v, %v", snap, err)
+	}
+
+	m.Close(key)
+	if _, _, err := m.Clipboard(id); !errors.Is(err, session.ErrSessionNotFound) {
+		t.Fatalf("clipboard after close: %v", err)
+	}
+	if _, err := m.ClipboardSession(id); !errors.Is(err, session.ErrSessionNotFound) {
+		t.Fatalf("lookup after close: %v", err)
+	}
+}
+
 func TestPerUserSessionLimit(t *testing.T) {
 	m := session.New(session.Options{MaxSessionsPerUser: 1})
 	defer m.Shutdown()
@@ -332,6 +626,60 @@ func TestPerUserSessionLimit(t *testing.T) {
 	}
 }
 
+func TestUserLimitHookAndCloseFreesHeadroom(t *testing.T) {
+	limits := map[string]int{"u1": 2, "root": 0}
+	m := session.New(session.Options{
+		MaxSessionsPerUser: 1,
+		UserLimit:          func(_ context.Context, userID string) int { return limits[userID] },
+	})
+	defer m.Shutdown()
+	acquire := func(conn, user string) error {
+		_, err := m.Acquire(context.Background(), session.Key{ConnectionID: conn, ActorScope: user}, user, connector(&fakeSession{}, nil))
+		return err
+	}
+	for _, c := range []string{"a", "b"} {
+		if err := acquire(c, "u1"); err != nil {
+			t.Fatalf("session %s: %v", c, err)
+		}
+	}
+	err := acquire("c", "u1")
+	var limitErr *session.LimitError
+	if !errors.As(err, &limitErr) || !errors.Is(err, session.ErrSessionLimit) || limitErr.Limit != 2 || limitErr.Active != 2 {
+		t.Fatalf("want LimitError{2, 2}, got %v", err)
+	}
+	m.Close(session.Key{ConnectionID: "a", ActorScope: "u1"})
+	if err := acquire("c", "u1"); err != nil {
+		t.Fatalf("closing a session must free headroom: %v", err)
+	}
+	for _, c := range []string{"a", "b", "c"} {
+		if err := acquire(c, "root"); err != nil {
+			t.Fatalf("uncapped user: %v", err)
+		}
+	}
+}
+
+func TestUserLimitLookupDoesNotHoldManagerLock(t *testing.T) {
+	var m *session.Manager
+	// A lookup that reads the manager, like a slow query, must not block it.
+	m = session.New(session.Options{
+		UserLimit: func(context.Context, string) int { _ = m.Stats(); return 1 },
+	})
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.Acquire(context.Background(), session.Key{ConnectionID: "a", ActorScope: "u1"}, "u1", connector(&fakeSession{}, nil))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+		m.Shutdown()
+	case <-time.After(2 * time.Second):
+		t.Fatal("limit lookup ran under the manager lock")
+	}
+}
+
 func TestIdleReclaim(t *testing.T) {
 	m := session.New(session.Options{IdleTimeout: 10 * time.Millisecond, HealthInterval: 5 * time.Millisecond})
 	defer m.Shutdown()
@@ -342,7 +690,7 @@ func TestIdleReclaim(t *testing.T) {
 	}
 
 	deadline := time.After(2 * time.Second)
-	for m.Stats().Sessions != 0 {
+	for m.Stats().Sessions != 0 || !fs.isClosed() {
 		select {
 		case <-deadline:
 			t.Fatal("idle session was not reclaimed")
@@ -365,7 +713,7 @@ func TestHealthCheckClosesDeadUpstream(t *testing.T) {
 	fs.setHealthErr(errors.New("upstream gone"))
 
 	deadline := time.After(2 * time.Second)
-	for m.Stats().Sessions != 0 {
+	for m.Stats().Sessions != 0 || !fs.isClosed() {
 		select {
 		case <-deadline:
 			t.Fatal("dead upstream was not reclaimed by health check")
@@ -384,6 +732,82 @@ func TestHealthCheckClosesDeadUpstream(t *testing.T) {
 	}
 }
 
+// hangingSession is a plugin whose Close blocks until release is closed.
+type hangingSession struct {
+	fakeSession
+	release chan struct{}
+}
+
+func (h *hangingSession) Close() error {
+	<-h.release
+	return h.fakeSession.Close()
+}
+
+func TestHungCloseDoesNotBlockRemoval(t *testing.T) {
+	stuck := make(chan session.Snapshot, 1)
+	m := session.New(session.Options{CloseTimeout: 20 * time.Millisecond, OnCloseStuck: func(s session.Snapshot) { stuck <- s }})
+	defer m.Shutdown()
+	hs := &hangingSession{release: make(chan struct{})}
+	key := session.Key{ConnectionID: "c1", ActorScope: "u1"}
+	if _, err := m.Acquire(context.Background(), key, "u1", connector(hs, nil)); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.Close(key)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close blocked on a hung plugin")
+	}
+	if snap := <-stuck; snap.Key != key {
+		t.Fatalf("stuck close reported for %+v", snap.Key)
+	}
+	if s := m.Stats(); s.Sessions != 0 || s.StuckCloses != 1 {
+		t.Fatalf("while hung: %+v", s)
+	}
+
+	close(hs.release)
+	deadline := time.After(2 * time.Second)
+	for m.Stats().StuckCloses != 0 {
+		select {
+		case <-deadline:
+			t.Fatal("stuck close was not cleared after Close returned")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	if !hs.isClosed() {
+		t.Fatal("plugin Close did not finish")
+	}
+}
+
+func TestMaxLifetimeClosesBusySession(t *testing.T) {
+	m := session.New(session.Options{HealthInterval: 5 * time.Millisecond, IdleTimeout: time.Hour, MaxLifetime: 20 * time.Millisecond})
+	defer m.Shutdown()
+	fs := &fakeSession{}
+	key := session.Key{ConnectionID: "c1", ActorScope: "u1"}
+	h, err := m.Acquire(context.Background(), key, "u1", connector(fs, nil))
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defer h.TrackStream()()
+
+	deadline := time.After(2 * time.Second)
+	for m.Stats().Sessions != 0 || !fs.isClosed() {
+		select {
+		case <-deadline:
+			t.Fatal("session past its max lifetime was not closed")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	if snap, ok := m.Status(key); !ok || snap.Reason != session.ErrMaxLifetime.Error() {
+		t.Fatalf("status after max lifetime: %+v %v", snap, ok)
+	}
+}
+
 func TestFailureStatusExpires(t *testing.T) {
 	m := session.New(session.Options{FailureRetention: 15 * time.Millisecond})
 	defer m.Shutdown()
@@ -400,6 +824,137 @@ func TestFailureStatusExpires(t *testing.T) {
 	}
 }
 
+// launchLog records what OnLaunch observed.
+type launchLog struct {
+	mu    sync.Mutex
+	snaps []session.Snapshot
+}
+
+func (l *launchLog) add(snap session.Snapshot) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.snaps = append(l.snaps, snap)
+}
+
+func (l *launchLog) states() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []string
+	for _, s := range l.snaps {
+		out = append(out, string(s.State)+":"+string(s.Stage))
+	}
+	return out
+}
+
+func TestStartLaunchesInBackground(t *testing.T) {
+	var launches launchLog
+	var opened atomic.Int32
+	m := session.New(session.Options{
+		MaxSessionsPerUser: 1,
+		OnLaunch:           launches.add,
+		OnOpen:             func(session.Snapshot) { opened.Add(1) },
+	})
+	defer m.Shutdown()
+	key := session.Key{ConnectionID: "c1", ActorScope: "u1"}
+	fs := &fakeSession{}
+	release := make(chan struct{})
+	var hits int32
+	connect := func(ctx context.Context) (plugin.Session, error) {
+		atomic.AddInt32(&hits, 1)
+		plugin.ReportStage(ctx, plugin.StageConnecting)
+		<-release
+		plugin.ReportStage(ctx, plugin.StageAuthenticating)
+		return fs, nil
+	}
+
+	snap, err := m.Start(context.Background(), key, "u1", connect)
+	if err != nil || snap.State != session.StatePending || snap.ID == "" {
+		t.Fatalf("start: %+v %v", snap, err)
+	}
+	// The pending launch holds the user's only slot.
+	if _, err := m.Start(context.Background(), session.Key{ConnectionID: "c2", ActorScope: "u1"}, "u1", connect); !errors.Is(err, session.ErrSessionLimit) {
+		t.Fatalf("second launch: want ErrSessionLimit, got %v", err)
+	}
+	// Starting again, or acquiring, joins the launch instead of dialing twice.
+	if again, err := m.Start(context.Background(), key, "u1", connect); err != nil || again.ID != snap.ID {
+		t.Fatalf("start again: %+v %v", again, err)
+	}
+	acquired := make(chan *session.Handle)
+	go func() {
+		h, _ := m.Acquire(context.Background(), key, "u1", connect)
+		acquired <- h
+	}()
+	close(release)
+	if h := <-acquired; h == nil || h.Session() != fs {
+		t.Fatal("acquire did not get the launched session")
+	}
+	if final, ok := m.Await(context.Background(), key); !ok || final.State != session.StateConnected {
+		t.Fatalf("await: %+v %v", final, ok)
+	}
+	if got, want := strings.Join(launches.states(), ","), "pending:connecting,pending:authenticating,connected:"; got != want {
+		t.Fatalf("launch events %s, want %s", got, want)
+	}
+	if atomic.LoadInt32(&hits) != 1 || opened.Load() != 1 {
+		t.Fatalf("connect ran %d times, OnOpen %d times", hits, opened.Load())
+	}
+}
+
+func TestStartFailureIsRemembered(t *testing.T) {
+	var launches launchLog
+	m := session.New(session.Options{OnLaunch: launches.add})
+	defer m.Shutdown()
+	key := session.Key{ConnectionID: "c1", ActorScope: "u1"}
+	if _, err := m.Start(context.Background(), key, "u1", func(ctx context.Context) (plugin.Session, error) {
+		session.SetMetadata(ctx, "why", "refused")
+		return nil, errors.New("dial failed")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	snap, ok := m.Await(context.Background(), key)
+	if !ok || snap.State != session.StateError || snap.Reason != "dial failed" || snap.Metadata["why"] != "refused" {
+		t.Fatalf("failed launch: %+v %v", snap, ok)
+	}
+	if got := launches.states(); len(got) != 1 || got[0] != "error:" {
+		t.Fatalf("launch events %v", got)
+	}
+	if m.Stats().Sessions != 0 {
+		t.Fatal("failed launch still registered")
+	}
+}
+
+func TestJanitorFailsStuckLaunch(t *testing.T) {
+	m := session.New(session.Options{HealthInterval: 5 * time.Millisecond, LaunchTimeout: 20 * time.Millisecond})
+	defer m.Shutdown()
+	key := session.Key{ConnectionID: "c1", ActorScope: "u1"}
+	fs := &fakeSession{}
+	release := make(chan struct{})
+	// The plugin ignores its context and only returns when released.
+	if _, err := m.Start(context.Background(), key, "u1", func(context.Context) (plugin.Session, error) {
+		<-release
+		return fs, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := m.Acquire(ctx, key, "u1", connector(fs, nil)); !errors.Is(err, session.ErrLaunchTimeout) {
+		t.Fatalf("acquire during stuck launch: want ErrLaunchTimeout, got %v", err)
+	}
+	if snap, ok := m.Status(key); !ok || snap.Reason != session.ErrLaunchTimeout.Error() {
+		t.Fatalf("status after launch timeout: %+v %v", snap, ok)
+	}
+	// A session that turns up after the deadline is closed, not kept.
+	close(release)
+	deadline := time.After(2 * time.Second)
+	for !fs.isClosed() {
+		select {
+		case <-deadline:
+			t.Fatal("late session was not closed")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
 func TestShutdownClosesAll(t *testing.T) {
 	m := session.New(session.Options{})
 	fs := &fakeSession{}
@@ -466,3 +1021,96 @@ func TestAcquireClaimsExclusiveLease(t *testing.T) {
 		t.Fatalf("second acquire: want ErrLeaseHeld, got %v", err)
 	}
 }
+
+// reconnectSession is a fakeSession whose transport can be re-established.
+type reconnectSession struct {
+	fakeSession
+	reconnectErr atomic.Pointer[error]
+	attempts     atomic.Int32
+}
+
+func (r *reconnectSession) Reconnect(context.Context) error {
+	r.attempts.Add(1)
+	if err := r.reconnectErr.Load(); err != nil {
+		return *err
+	}
+	r.setHealthErr(nil)
+	return nil
+}
+
+func waitState(t *testing.T, m *session.Manager, key session.Key, want session.State) session.Snapshot {
+	t.Helper()
+	deadline := time.After(2 * time.Second)
+	for {
+		if snap, ok := m.Status(key); ok && snap.State == want {
+			return snap
+		}
+		select {
+		case <-deadline:
+			snap, _ := m.Status(key)
+			t.Fatalf("session never reached %s: %+v", want, snap)
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func TestReconnectableSessionSurvivesTransportDrop(t *testing.T) {
+	var reconnecting atomic.Int32
+	m := session.New(session.Options{
+		HealthInterval: 5 * time.Millisecond, IdleTimeout: time.Hour, ReconnectGrace: time.Minute,
+		OnReconnecting: func(session.Snapshot) { reconnecting.Add(1) },
+	})
+	defer m.Shutdown()
+	rs := &reconnectSession{}
+	blocked := errors.New("network unreachable")
+	rs.reconnectErr.Store(&blocked)
+	key := session.Key{ConnectionID: "c1", ActorScope: "u1"}
+	h, err := m.Acquire(context.Background(), key, "u1", connector(rs, nil))
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	rs.setHealthErr(errors.New("broken pipe"))
+
+	snap := waitState(t, m, key, session.StateReconnecting)
+	if snap.Reason != "broken pipe" {
+		t.Fatalf("reconnecting status should carry the cause: %+v", snap)
+	}
+	if _, err := h.OpenChannel(context.Background(), plugin.ChannelRequest{}); !errors.Is(err, session.ErrSessionReconnecting) {
+		t.Fatalf("open channel while reconnecting: %v", err)
+	}
+
+	rs.reconnectErr.Store(nil)
+	waitState(t, m, key, session.StateConnected)
+	if rs.isClosed() || m.Stats().Sessions != 1 {
+		t.Fatal("a reconnected session must stay open")
+	}
+	if reconnecting.Load() != 1 {
+		t.Fatalf("OnReconnecting fired %d times, want 1", reconnecting.Load())
+	}
+	if _, err := h.OpenChannel(context.Background(), plugin.ChannelRequest{}); err != nil {
+		t.Fatalf("open channel after reconnect: %v", err)
+	}
+}
+
+func TestReconnectGiveUpClosesSession(t *testing.T) {
+	m := session.New(session.Options{
+		HealthInterval: 5 * time.Millisecond, IdleTimeout: time.Hour, ReconnectGrace: 50 * time.Millisecond,
+	})
+	defer m.Shutdown()
+	rs := &reconnectSession{}
+	down := errors.New("no route to host")
+	rs.reconnectErr.Store(&down)
+	key := session.Key{ConnectionID: "c1", ActorScope: "u1"}
+	if _, err := m.Acquire(context.Background(), key, "u1", connector(rs, nil)); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	rs.setHealthErr(errors.New("broken pipe"))
+
+	snap := waitState(t, m, key, session.StateError)
+	if snap.Reason != session.ErrTransportLost.Error() {
+		t.Fatalf("unexpected failure status: %+v", snap)
+	}
+	if !rs.isClosed() || rs.attempts.Load() == 0 {
+		t.Fatalf("session should be closed after %d reconnect attempts", rs.attempts.Load())
+	}
+}
@@ -0,0 +1,114 @@
+package session
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrSessionNotFound is returned when no live session has the requested ID.
+var ErrSessionNotFound = errors.New("session: not found")
+
+// observerBuffer is how many output writes an observer may fall behind
+// before writes are dropped for it.
+const observerBuffer = 256
+
+// observerSet branches a session's stream output to read-only observers. A
+// slow observer misses output rather than slowing the session.
+type observerSet struct {
+	n      atomic.Int32
+	mu     sync.Mutex
+	subs   map[chan []byte]struct{}
+	closed bool
+}
+
+func (o *observerSet) add() (chan []byte, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return nil, false
+	}
+	if o.subs == nil {
+		o.subs = map[chan []byte]struct{}{}
+	}
+	ch := make(chan []byte, observerBuffer)
+	o.subs[ch] = struct{}{}
+	o.n.Add(1)
+	return ch, true
+}
+
+func (o *observerSet) remove(ch chan []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.subs[ch]; ok {
+		delete(o.subs, ch)
+		o.n.Add(-1)
+		close(ch)
+	}
+}
+
+func (o *observerSet) publish(p []byte) {
+	if o.n.Load() == 0 {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.subs) == 0 {
+		return
+	}
+	frame := append([]byte(nil), p...)
+	for ch := range o.subs {
+		select {
+		case ch <- frame:
+		default:
+		}
+	}
+}
+
+// close detaches every observer; their channels are closed.
+func (o *observerSet) close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.closed = true
+	for ch := range o.subs {
+		delete(o.subs, ch)
+		close(ch)
+	}
+	o.n.Store(0)
+}
+
+// Observe attaches a read-only observer to the live session with the given
+// ID. The channel carries what the session's metered streams write to their
+// browsers and is closed when the session ends or cancel is called.
+// Observers do not count as streams, so they neither keep a session alive
+// nor count toward any limit.
+func (m *Manager) Observe(id string) (Snapshot, <-chan []byte, func(), error) {
+	e := m.entryByID(id)
+	if e == nil {
+		return Snapshot{}, nil, nil, ErrSessionNotFound
+	}
+	e.mu.Lock()
+	if e.closed || e.sess == nil {
+		e.mu.Unlock()
+		return Snapshot{}, nil, nil, ErrSessionNotFound
+	}
+	snap := e.snapshotLocked("")
+	e.mu.Unlock()
+	ch, ok := e.observers.add()
+	if !ok {
+		return Snapshot{}, nil, nil, ErrSessionNotFound
+	}
+	var once sync.Once
+	return snap, ch, func() { once.Do(func() { e.observers.remove(ch) }) }, nil
+}
+
+func (m *Manager) entryByID(id string) *entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.sessions {
+		if e.id == id {
+			return e
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package session
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PresenceEventType is the event name presence changes are broadcast under.
+const PresenceEventType = "session.presence"
+
+// DefaultPresenceRate is how many presence events per second one user may
+// broadcast on a connection.
+const DefaultPresenceRate = 4
+
+const presenceSubBuffer = 32
+
+// CursorPos is a terminal cell, zero-based.
+type CursorPos struct {
+	Row int `json:"row"`
+	Col int `json:"col"`
+}
+
+// PresenceState is what a participant shows the others in a shared session.
+type PresenceState struct {
+	Typing bool       `json:"typing"`
+	Cursor *CursorPos `json:"cursor,omitempty"`
+	// Scroll is the participant's scrollback offset in lines from the bottom.
+	Scroll *int `json:"scroll,omitempty"`
+}
+
+// Presence is one participant's current state.
+type Presence struct {
+	UserID string `json:"userId"`
+	PresenceState
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// PresenceEvent is broadcast to a connection's subscribers. Left is set, and
+// State is zero, when the participant went away.
+type PresenceEvent struct {
+	Type         string        `json:"type"`
+	ConnectionID string        `json:"connectionId"`
+	UserID       string        `json:"userId"`
+	State        PresenceState `json:"state"`
+	Left         bool          `json:"left,omitempty"`
+	At           time.Time     `json:"at"`
+}
+
+type presenceEntry struct {
+	Presence
+	lastSent time.Time
+	pending  bool
+	timer    *time.Timer
+}
+
+type presenceSub struct {
+	userID string
+	ch     chan PresenceEvent
+}
+
+// PresenceHub keeps the ephemeral typing, cursor and scroll state of each
+// connection's participants and fans changes out to subscribers. Each user's
+// broadcasts are throttled; an update inside the interval is held and the
+// latest one sent when the interval ends. Nothing is persisted.
+type PresenceHub struct {
+	interval time.Duration
+	now      func() time.Time
+
+	mu    sync.Mutex
+	conns map[string]map[string]*presenceEntry
+	subs  map[string]map[*presenceSub]struct{}
+}
+
+// NewPresenceHub allows each user perSecond broadcasts per connection;
+// perSecond <= 0 uses DefaultPresenceRate.
+func NewPresenceHub(perSecond int) *PresenceHub {
+	if perSecond <= 0 {
+		perSecond = DefaultPresenceRate
+	}
+	return &PresenceHub{
+		interval: time.Second / time.Duration(perSecond),
+		now:      time.Now,
+		conns:    map[string]map[string]*presenceEntry{},
+		subs:     map[string]map[*presenceSub]struct{}{},
+	}
+}
+
+// Update records userID's state on connID. It reports whether the change was
+// broadcast now; a throttled change is sent when the user's interval ends.
+func (h *PresenceHub) Update(connID, userID string, st PresenceState) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	users := h.conns[connID]
+	if users == nil {
+		users = map[string]*presenceEntry{}
+		h.conns[connID] = users
+	}
+	e := users[userID]
+	if e == nil {
+		e = &presenceEntry{Presence: Presence{UserID: userID}}
+		users[userID] = e
+	}
+	now := h.now()
+	e.PresenceState, e.UpdatedAt = st, now
+	if wait := e.lastSent.Add(h.interval).Sub(now); wait > 0 {
+		if !e.pending {
+			e.pending = true
+			e.timer = time.AfterFunc(wait, func() { h.flush(connID, userID, e) })
+		}
+		return false
+	}
+	h.sendLocked(connID, e)
+	return true
+}
+
+func (h *PresenceHub) flush(connID, userID string, e *presenceEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conns[connID][userID] != e || !e.pending {
+		return
+	}
+	h.sendLocked(connID, e)
+}
+
+func (h *PresenceHub) sendLocked(connID string, e *presenceEntry) {
+	e.pending, e.timer = false, nil
+	e.lastSent = h.now()
+	h.broadcastLocked(connID, PresenceEvent{
+		Type: PresenceEventType, ConnectionID: connID, UserID: e.UserID, State: e.PresenceState, At: e.UpdatedAt,
+	})
+}
+
+// broadcastLocked never blocks: a subscriber that has fallen a full buffer
+// behind misses events and catches up from the next one.
+func (h *PresenceHub) broadcastLocked(connID string, ev PresenceEvent) {
+	for sub := range h.subs[connID] {
+		select {
+		case sub.ch <- ev:
+		default:
+		}
+	}
+}
+
+// Leave clears userID's presence on connID and tells the others.
+func (h *PresenceHub) Leave(connID, userID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.leaveLocked(connID, userID)
+}
+
+func (h *PresenceHub) leaveLocked(connID, userID string) {
+	e, ok := h.conns[connID][userID]
+	if !ok {
+		return
+	}
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+	delete(h.conns[connID], userID)
+	if len(h.conns[connID]) == 0 {
+		delete(h.conns, connID)
+	}
+	h.broadcastLocked(connID, PresenceEvent{
+		Type: PresenceEventType, ConnectionID: connID, UserID: userID, Left: true, At: h.now(),
+	})
+}
+
+// SessionClosed is the session manager's close hook: the closing session's
+// user has left the connection.
+func (h *PresenceHub) SessionClosed(snap Snapshot) {
+	h.Leave(snap.Key.ConnectionID, snap.Key.ActorScope)
+}
+
+// Snapshot returns connID's current participants ordered by user id, so a
+// late joiner can render presence before the next event arrives.
+func (h *PresenceHub) Snapshot(connID string) []Presence {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]Presence, 0, len(h.conns[connID]))
+	for _, e := range h.conns[connID] {
+		out = append(out, e.Presence)
+	}
+	slices.SortFunc(out, func(a, b Presence) int { return strings.Compare(a.UserID, b.UserID) })
+	return out
+}
+
+// Subscribe streams connID's presence events to userID. Cancelling ends the
+// stream, and when it was userID's last one on connID they leave.
+func (h *PresenceHub) Subscribe(connID, userID string) (<-chan PresenceEvent, func()) {
+	sub := &presenceSub{userID: userID, ch: make(chan PresenceEvent, presenceSubBuffer)}
+	h.mu.Lock()
+	if h.subs[connID] == nil {
+		h.subs[connID] = map[*presenceSub]struct{}{}
+	}
+	h.subs[connID][sub] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			delete(h.subs[connID], sub)
+			if len(h.subs[connID]) == 0 {
+				delete(h.subs, connID)
+			}
+			for other := range h.subs[connID] {
+				if other.userID == userID {
+					return
+				}
+			}
+			h.leaveLocked(connID, userID)
+		})
+	}
+}
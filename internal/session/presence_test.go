@@ -0,0 +1,84 @@
+package session_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/charlesng35/shellcn/internal/session"
+)
+
+func nextPresence(t *testing.T, ch <-chan session.PresenceEvent) session.PresenceEvent {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("no presence event")
+		return session.PresenceEvent{}
+	}
+}
+
+func TestPresenceHubThrottlesAndSendsLatest(t *testing.T) {
+	hub := session.NewPresenceHub(10)
+	events, cancel := hub.Subscribe("c1", "viewer")
+	defer cancel()
+
+	if !hub.Update("c1", "alice", session.PresenceState{Typing: true}) {
+		t.Fatal("first update should broadcast immediately")
+	}
+	if ev := nextPresence(t, events); ev.Type != session.PresenceEventType || ev.UserID != "alice" || !ev.State.Typing {
+		t.Fatalf("first event = %+v", ev)
+	}
+
+	// A burst inside the interval is held back; only its last state is sent.
+	for i := range 20 {
+		row := i
+		if hub.Update("c1", "alice", session.PresenceState{Typing: true, Cursor: &session.CursorPos{Row: row}}) {
+			t.Fatalf("update %d inside the interval was broadcast", i)
+		}
+	}
+	hub.Update("c1", "alice", session.PresenceState{Typing: false})
+	if ev := nextPresence(t, events); ev.State.Typing || ev.State.Cursor != nil {
+		t.Fatalf("trailing event should carry the latest state, got %+v", ev.State)
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("burst produced an extra event %+v", ev)
+	case <-time.After(150 * time.Millisecond):
+	}
+
+	// Throttling is per user: bob is not held back by alice.
+	if !hub.Update("c1", "bob", session.PresenceState{Typing: true}) {
+		t.Fatal("another user's first update should broadcast")
+	}
+	nextPresence(t, events)
+
+	if got := hub.Snapshot("c1"); len(got) != 2 || got[0].UserID != "alice" || got[1].UserID != "bob" || !got[1].Typing {
+		t.Fatalf("snapshot = %+v", got)
+	}
+}
+
+func TestPresenceHubClearsParticipantsWhoLeave(t *testing.T) {
+	hub := session.NewPresenceHub(0)
+	events, cancel := hub.Subscribe("c1", "viewer")
+	defer cancel()
+	_, aliceCancel := hub.Subscribe("c1", "alice")
+	hub.Update("c1", "alice", session.PresenceState{Typing: true})
+	hub.Update("c1", "bob", session.PresenceState{Typing: true})
+	nextPresence(t, events)
+	nextPresence(t, events)
+
+	// Closing alice's stream makes her leave.
+	aliceCancel()
+	if ev := nextPresence(t, events); ev.UserID != "alice" || !ev.Left {
+		t.Fatalf("leave event = %+v", ev)
+	}
+	// Bob's session closing clears him too.
+	hub.SessionClosed(session.Snapshot{Key: session.Key{ConnectionID: "c1", ActorScope: "bob"}})
+	if ev := nextPresence(t, events); ev.UserID != "bob" || !ev.Left {
+		t.Fatalf("leave event = %+v", ev)
+	}
+	if got := hub.Snapshot("c1"); len(got) != 0 {
+		t.Fatalf("presence should be empty, got %+v", got)
+	}
+}
@@ -0,0 +1,281 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+// DefaultScrollbackBytes is how much terminal output a session keeps for
+// scrollback search when Options.ScrollbackBytes is zero.
+const DefaultScrollbackBytes = 2 << 20
+
+// Scrollback query bounds.
+const (
+	MaxScrollbackLines   = 1000
+	MaxScrollbackContext = 10
+	MaxScrollbackMatches = 200
+	maxScrollbackQuery   = 256
+)
+
+// ScrollbackLine is one line of terminal output. Line numbers count from the
+// session's first output line and stay valid as old lines are evicted; Raw
+// keeps the escape sequences for display.
+type ScrollbackLine struct {
+	Line int    `json:"line"`
+	Raw  string `json:"raw"`
+}
+
+// ScrollbackPage is a range of retained lines. First is the oldest line still
+// held and Next the line after the newest one, so a client that asked for an
+// evicted line can tell.
+type ScrollbackPage struct {
+	First int              `json:"first"`
+	Next  int              `json:"next"`
+	Lines []ScrollbackLine `json:"lines"`
+}
+
+// ScrollbackMatch is a line whose text, with escape sequences stripped,
+// matched a search, with the lines around it.
+type ScrollbackMatch struct {
+	Line    int              `json:"line"`
+	Context []ScrollbackLine `json:"context"`
+}
+
+// ScrollbackQuery is a scrollback search. Text matches case-insensitively;
+// Regex takes Query as a Go regular expression instead.
+type ScrollbackQuery struct {
+	Query   string
+	Regex   bool
+	Context int
+}
+
+// scrollback is a bounded ring of a session's terminal output lines. The
+// oldest whole lines are dropped once the held bytes pass limit.
+type scrollback struct {
+	mu      sync.Mutex
+	limit   int
+	lines   [][]byte
+	first   int
+	partial []byte
+	size    int
+}
+
+func newScrollback(limit int) *scrollback {
+	return &scrollback{limit: limit}
+}
+
+func (s *scrollback) write(p []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			s.partial = append(s.partial, p...)
+			s.size += len(p)
+			break
+		}
+		line := append(s.partial, p[:i]...)
+		s.size += i
+		s.lines = append(s.lines, line)
+		s.partial = nil
+		p = p[i+1:]
+	}
+	for s.size > s.limit && len(s.lines) > 0 {
+		s.size -= len(s.lines[0])
+		s.lines[0] = nil
+		s.lines = s.lines[1:]
+		s.first++
+	}
+	if over := len(s.partial) - s.limit; over > 0 {
+		s.partial = append([]byte(nil), s.partial[over:]...)
+		s.size -= over
+	}
+	// Reslicing off the front leaves the backing array growing; copy once
+	// the dead prefix dominates.
+	if cap(s.lines) > 2*len(s.lines)+64 {
+		s.lines = append([][]byte(nil), s.lines...)
+	}
+}
+
+// snapshotLocked returns the held lines, the unfinished last line included.
+func (s *scrollback) snapshotLocked() [][]byte {
+	out := s.lines
+	if len(s.partial) > 0 {
+		out = append(out[:len(out):len(out)], s.partial)
+	}
+	return out
+}
+
+func (s *scrollback) page(from, n int) ScrollbackPage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lines := s.snapshotLocked()
+	out := ScrollbackPage{First: s.first, Next: s.first + len(lines), Lines: []ScrollbackLine{}}
+	from = max(from, s.first)
+	for i := from - s.first; i < len(lines) && len(out.Lines) < n; i++ {
+		out.Lines = append(out.Lines, ScrollbackLine{Line: s.first + i, Raw: string(lines[i])})
+	}
+	return out
+}
+
+func (s *scrollback) search(q ScrollbackQuery) ([]ScrollbackMatch, error) {
+	match, err := scrollbackMatcher(q)
+	if err != nil {
+		return nil, err
+	}
+	radius := min(max(q.Context, 0), MaxScrollbackContext)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lines := s.snapshotLocked()
+	out := []ScrollbackMatch{}
+	for i, raw := range lines {
+		if !match(stripANSI(raw)) {
+			continue
+		}
+		m := ScrollbackMatch{Line: s.first + i}
+		for j := max(i-radius, 0); j <= min(i+radius, len(lines)-1); j++ {
+			m.Context = append(m.Context, ScrollbackLine{Line: s.first + j, Raw: string(lines[j])})
+		}
+		out = append(out, m)
+		if len(out) == MaxScrollbackMatches {
+			break
+		}
+	}
+	return out, nil
+}
+
+func scrollbackMatcher(q ScrollbackQuery) (func(string) bool, error) {
+	switch {
+	case q.Query == "":
+		return nil, fmt.Errorf("%w: q is required", plugin.ErrInvalidInput)
+	case len(q.Query) > maxScrollbackQuery:
+		return nil, fmt.Errorf("%w: q is longer than %d bytes", plugin.ErrInvalidInput, maxScrollbackQuery)
+	case q.Regex:
+		re, err := regexp.Compile(q.Query)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", plugin.ErrInvalidInput, err)
+		}
+		return re.MatchString, nil
+	}
+	needle := strings.ToLower(q.Query)
+	return func(text string) bool { return strings.Contains(strings.ToLower(text), needle) }, nil
+}
+
+// stripANSI drops CSI and OSC escape sequences and other control bytes, and
+// keeps only what follows the last carriage return, so matching sees the
+// text a terminal would show.
+func stripANSI(raw []byte) string {
+	if i := bytes.LastIndexByte(bytes.TrimRight(raw, "\r"), '\r'); i >= 0 {
+		raw = raw[i+1:]
+	}
+	var b strings.Builder
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c != 0x1b {
+			if c >= 0x20 && c != 0x7f || c == '\t' {
+				b.WriteByte(c)
+			}
+			continue
+		}
+		if i+1 >= len(raw) {
+			break
+		}
+		switch raw[i+1] {
+		case '[':
+			i += 2
+			for i < len(raw) && (raw[i] < 0x40 || raw[i] > 0x7e) {
+				i++
+			}
+		case ']':
+			i += 2
+			for i < len(raw) && raw[i] != 0x07 && !(raw[i] == 0x1b && i+1 < len(raw) && raw[i+1] == '\\') {
+				i++
+			}
+			if i < len(raw) && raw[i] == 0x1b {
+				i++
+			}
+		default:
+			i++
+		}
+	}
+	return b.String()
+}
+
+// ErrNoScrollback is returned for a live session that has kept no terminal
+// output: it has no terminal stream, or scrollback is off for it.
+var ErrNoScrollback = errors.New("session: no scrollback")
+
+// CaptureScrollback keeps what client's writes send to the browser in the
+// session's scrollback. Wrap terminal streams only; other streams carry
+// bytes no one would search.
+func (h *Handle) CaptureScrollback(client plugin.ClientStream) plugin.ClientStream {
+	if h.m.opts.ScrollbackBytes < 0 {
+		return client
+	}
+	e := h.e
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return client
+	}
+	if e.scrollback == nil {
+		e.scrollback = newScrollback(h.m.opts.ScrollbackBytes)
+	}
+	return &scrollbackStream{ClientStream: client, sb: e.scrollback}
+}
+
+type scrollbackStream struct {
+	plugin.ClientStream
+	sb *scrollback
+}
+
+func (s *scrollbackStream) Write(p []byte) (int, error) {
+	n, err := s.ClientStream.Write(p)
+	if n > 0 {
+		s.sb.write(p[:n])
+	}
+	return n, err
+}
+
+func (m *Manager) scrollbackOf(id string) (Snapshot, *scrollback, error) {
+	e := m.entryByID(id)
+	if e == nil {
+		return Snapshot{}, nil, ErrSessionNotFound
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed || e.sess == nil {
+		return Snapshot{}, nil, ErrSessionNotFound
+	}
+	if e.scrollback == nil {
+		return Snapshot{}, nil, ErrNoScrollback
+	}
+	return e.snapshotLocked(""), e.scrollback, nil
+}
+
+// Scrollback returns up to n retained lines of the live session's terminal
+// output starting at line from.
+func (m *Manager) Scrollback(id string, from, n int) (Snapshot, ScrollbackPage, error) {
+	snap, sb, err := m.scrollbackOf(id)
+	if err != nil {
+		return Snapshot{}, ScrollbackPage{}, err
+	}
+	return snap, sb.page(from, min(max(n, 1), MaxScrollbackLines)), nil
+}
+
+// SearchScrollback finds the retained lines of the live session's terminal
+// output that match q, oldest first.
+func (m *Manager) SearchScrollback(id string, q ScrollbackQuery) (Snapshot, []ScrollbackMatch, error) {
+	snap, sb, err := m.scrollbackOf(id)
+	if err != nil {
+		return Snapshot{}, nil, err
+	}
+	matches, err := sb.search(q)
+	return snap, matches, err
+}
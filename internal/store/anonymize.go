@@ -0,0 +1,224 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"gorm.io/gorm"
+
+	"github.com/charlesng35/shellcn/internal/models"
+)
+
+// AnonymizeTable names a table holding a user's personal data.
+type AnonymizeTable string
+
+const (
+	// AnonymizeRecordings rewrites the username stored on the user's
+	// recordings; ids, checksums and durations are kept.
+	AnonymizeRecordings AnonymizeTable = "recordings"
+	// AnonymizeAudit rewrites the username and clears the remote address of
+	// the user's audit entries, which otherwise stay as recorded.
+	AnonymizeAudit AnonymizeTable = "audit_entries"
+	// AnonymizeAIConversations clears the titles and summaries of the user's
+	// AI conversations.
+	AnonymizeAIConversations AnonymizeTable = "ai_conversations"
+	// AnonymizeAIMessages clears the content of every message in the user's
+	// AI conversations, keeping the messages themselves.
+	AnonymizeAIMessages AnonymizeTable = "ai_messages"
+	// AnonymizeCredentialAccess deletes the user's credential access logs.
+	AnonymizeCredentialAccess AnonymizeTable = "credential_access_logs"
+	// AnonymizeCredentialUsage deletes the user's credential favorites and
+	// last-use times.
+	AnonymizeCredentialUsage AnonymizeTable = "user_credential_usage"
+	// AnonymizePreferences deletes the user's saved preferences.
+	AnonymizePreferences AnonymizeTable = "preferences"
+)
+
+// AnonymizeTables lists every table in the order they are scrubbed.
+var AnonymizeTables = []AnonymizeTable{
+	AnonymizeRecordings, AnonymizeAudit, AnonymizeAIConversations, AnonymizeAIMessages,
+	AnonymizeCredentialAccess, AnonymizeCredentialUsage, AnonymizePreferences,
+}
+
+// AnonymizeStore scrubs one user's personal data from the tables that keep
+// rows after the user is gone. Rows that stay keep their ids and user ids so
+// audit trails still line up.
+type AnonymizeStore interface {
+	// Scrub rewrites or deletes up to limit of userID's rows in one table,
+	// writing tombstone where a username was, and returns how many changed;
+	// fewer than limit means the table is clean. Rows already scrubbed are
+	// not counted again.
+	Scrub(ctx context.Context, t AnonymizeTable, userID, tombstone string, limit int) (int64, error)
+}
+
+type gormAnonymizeStore struct{ db *gorm.DB }
+
+// Scrub picks a batch of ids first and updates by id, since MySQL refuses a
+// LIMIT inside IN and Postgres has no UPDATE ... LIMIT. The per-user
+// preference and credential usage rows are few and go in one statement.
+func (s *gormAnonymizeStore) Scrub(ctx context.Context, t AnonymizeTable, userID, tombstone string, limit int) (int64, error) {
+	db := s.db.WithContext(ctx)
+	var (
+		model   any
+		pending *gorm.DB
+		changes map[string]any
+	)
+	switch t {
+	case AnonymizeRecordings:
+		model = &models.Recording{}
+		pending = db.Model(model).Where("user_id = ? AND username <> ?", userID, tombstone)
+		changes = map[string]any{"username": tombstone}
+	case AnonymizeAudit:
+		model = &models.AuditEntry{}
+		pending = db.Model(model).Where("user_id = ? AND (username <> ? OR remote_addr <> '')", userID, tombstone)
+		changes = map[string]any{"username": tombstone, "remote_addr": ""}
+	case AnonymizeAIConversations:
+		model = &models.AIConversation{}
+		pending = db.Model(model).Where("owner_id = ? AND (title <> '' OR summary <> '')", userID)
+		changes = map[string]any{"title": "", "summary": ""}
+	case AnonymizeAIMessages:
+		model = &models.AIMessage{}
+		pending = db.Model(model).
+			Where("conversation_id IN (SELECT id FROM ai_conversations WHERE owner_id = ?)", userID).
+			Where("content <> '' OR reasoning <> '' OR (tool_calls IS NOT NULL AND tool_calls <> '[]')")
+		changes = map[string]any{"content": "", "reasoning": "", "tool_calls": nil}
+	case AnonymizeCredentialAccess:
+		model = &models.CredentialAccessLog{}
+		pending = db.Model(model).Where("user_id = ?", userID)
+	case AnonymizeCredentialUsage:
+		res := db.Where("user_id = ?", userID).Delete(&models.CredentialUsage{})
+		return res.RowsAffected, res.Error
+	case AnonymizePreferences:
+		res := db.Where("user_id = ?", userID).Delete(&models.Preference{})
+		return res.RowsAffected, res.Error
+	default:
+		return 0, fmt.Errorf("store: unknown anonymize table %q", t)
+	}
+	var ids []string
+	if err := pending.Order("id").Limit(limit).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if changes == nil {
+		res := db.Where("id IN ?", ids).Delete(model)
+		return res.RowsAffected, res.Error
+	}
+	res := db.Model(model).Where("id IN ?", ids).Updates(changes)
+	return res.RowsAffected, res.Error
+}
+
+type memAnonymizeStore struct {
+	recordings *memRecordingStore
+	audit      *memAuditStore
+	convs      *memAIConversationStore
+	messages   *memAIMessageStore
+	access     *memCredentialAccessLogStore
+	credUsage  *memCredentialUsageStore
+	prefs      *memPreferenceStore
+}
+
+func (s *memAnonymizeStore) Scrub(_ context.Context, t AnonymizeTable, userID, tombstone string, limit int) (int64, error) {
+	switch t {
+	case AnonymizeRecordings:
+		s.recordings.mu.Lock()
+		defer s.recordings.mu.Unlock()
+		return scrubMem(s.recordings.m, limit, func(r *models.Recording) bool {
+			if r.UserID != userID || r.Username == tombstone {
+				return false
+			}
+			r.Username = tombstone
+			return true
+		}), nil
+	case AnonymizeAudit:
+		s.audit.mu.Lock()
+		defer s.audit.mu.Unlock()
+		var n int64
+		for i := range s.audit.entries {
+			e := &s.audit.entries[i]
+			if n == int64(limit) {
+				break
+			}
+			if e.UserID == userID && (e.Username != tombstone || e.RemoteAddr != "") {
+				e.Username, e.RemoteAddr = tombstone, ""
+				n++
+			}
+		}
+		return n, nil
+	case AnonymizeAIConversations:
+		s.convs.mu.Lock()
+		defer s.convs.mu.Unlock()
+		return scrubMem(s.convs.m, limit, func(c *models.AIConversation) bool {
+			if c.OwnerID != userID || (c.Title == "" && c.Summary == "") {
+				return false
+			}
+			c.Title, c.Summary = "", ""
+			return true
+		}), nil
+	case AnonymizeAIMessages:
+		s.convs.mu.RLock()
+		defer s.convs.mu.RUnlock()
+		s.messages.mu.Lock()
+		defer s.messages.mu.Unlock()
+		var n int64
+		for _, id := range slices.Sorted(maps.Keys(s.messages.m)) {
+			if c, ok := s.convs.m[id]; !ok || c.OwnerID != userID {
+				continue
+			}
+			list := s.messages.m[id]
+			for i := range list {
+				m := &list[i]
+				if n == int64(limit) {
+					return n, nil
+				}
+				if m.Content != "" || m.Reasoning != "" || len(m.ToolCalls) > 0 {
+					m.Content, m.Reasoning, m.ToolCalls = "", "", nil
+					n++
+				}
+			}
+		}
+		return n, nil
+	case AnonymizeCredentialAccess:
+		s.access.mu.Lock()
+		defer s.access.mu.Unlock()
+		var n int64
+		kept := s.access.entries[:0]
+		for _, e := range s.access.entries {
+			if e.UserID == userID && n < int64(limit) {
+				n++
+				continue
+			}
+			kept = append(kept, e)
+		}
+		s.access.entries = kept
+		return n, nil
+	case AnonymizeCredentialUsage:
+		s.credUsage.mu.Lock()
+		defer s.credUsage.mu.Unlock()
+		return purgeMem(s.credUsage.m, func(u models.CredentialUsage) bool { return u.UserID == userID }, len(s.credUsage.m)), nil
+	case AnonymizePreferences:
+		s.prefs.mu.Lock()
+		defer s.prefs.mu.Unlock()
+		return purgeMem(s.prefs.m, func(p models.Preference) bool { return p.UserID == userID }, len(s.prefs.m)), nil
+	}
+	return 0, fmt.Errorf("store: unknown anonymize table %q", t)
+}
+
+// scrubMem applies scrub, in id order, to up to limit rows it changes.
+func scrubMem[T any](m map[string]T, limit int, scrub func(*T) bool) int64 {
+	var n int64
+	for _, id := range slices.Sorted(maps.Keys(m)) {
+		if n == int64(limit) {
+			break
+		}
+		v := m[id]
+		if scrub(&v) {
+			m[id] = v
+			n++
+		}
+	}
+	return n
+}
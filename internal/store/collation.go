@@ -0,0 +1,168 @@
+package store
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+
+	"github.com/charlesng35/shellcn/internal/models"
+)
+
+// DefaultCollationLocale is the root collation, neutral across languages.
+const DefaultCollationLocale = "und"
+
+const sortKeyBatch = 500
+
+// mysqlSortKeyLen keeps a key within an indexed MySQL varchar, at an even
+// length so no hex byte is split; names that agree for that long fall back
+// to raw name order. Other databases store the whole key.
+const mysqlSortKeyLen = mysqlStringSize - 1
+
+// SortKeyStore maintains the name_sort keys connections, folders and
+// credentials are listed by. Keys are collation keys for one locale, stored as
+// hex so byte order sorts the same on every database.
+type SortKeyStore interface {
+	Locale() string
+	// SetLocale switches the collation used for keys written from now on;
+	// Backfill brings existing rows in line.
+	SetLocale(locale string) error
+	// Backfill rewrites every stale key and returns how many rows changed.
+	Backfill(ctx context.Context) (int, error)
+}
+
+// ParseCollationLocale validates a BCP 47 locale for name collation.
+func ParseCollationLocale(locale string) (language.Tag, error) {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return language.Und, fmt.Errorf("invalid collation locale %q: %w", locale, err)
+	}
+	return tag, nil
+}
+
+// collation derives sort keys. A Collator is not safe for concurrent use, so
+// every key is built under mu. Keys are cut to maxLen when it is set.
+type collation struct {
+	mu     sync.Mutex
+	locale string
+	c      *collate.Collator
+	buf    collate.Buffer
+	maxLen int
+}
+
+func newCollation(maxLen int) *collation {
+	return &collation{locale: DefaultCollationLocale, c: collate.New(language.Und), maxLen: maxLen}
+}
+
+func (c *collation) Locale() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.locale
+}
+
+func (c *collation) SetLocale(locale string) error {
+	tag, err := ParseCollationLocale(locale)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.locale, c.c = locale, collate.New(tag)
+	return nil
+}
+
+func (c *collation) key(name string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.buf.Reset()
+	key := hex.EncodeToString(c.c.KeyFromString(&c.buf, name))
+	if c.maxLen > 0 && len(key) > c.maxLen {
+		key = key[:c.maxLen]
+	}
+	return key
+}
+
+// nameSortLess orders by collation key, then raw name for identical keys.
+func nameSortLess(aKey, aName, bKey, bName string) bool {
+	if aKey != bKey {
+		return aKey < bKey
+	}
+	return aName < bName
+}
+
+type gormSortKeyStore struct {
+	*collation
+	db *gorm.DB
+}
+
+func (s *gormSortKeyStore) Backfill(ctx context.Context) (int, error) {
+	n := 0
+	for _, model := range []any{&models.Connection{}, &models.ConnectionFolder{}, &models.Credential{}} {
+		last := ""
+		for {
+			var rows []struct{ ID, Name, NameSort string }
+			if err := s.db.WithContext(ctx).Model(model).Select("id", "name", "name_sort").
+				Where("id > ?", last).Order("id").Limit(sortKeyBatch).Find(&rows).Error; err != nil {
+				return n, err
+			}
+			for _, r := range rows {
+				key := s.key(r.Name)
+				if key == r.NameSort {
+					continue
+				}
+				if err := s.db.WithContext(ctx).Model(model).Where("id = ?", r.ID).UpdateColumn("name_sort", key).Error; err != nil {
+					return n, err
+				}
+				n++
+			}
+			if len(rows) < sortKeyBatch {
+				break
+			}
+			last = rows[len(rows)-1].ID
+		}
+	}
+	return n, nil
+}
+
+type memSortKeyStore struct {
+	*collation
+	conns   *memConnectionStore
+	folders *memConnectionFolderStore
+	creds   *memCredentialStore
+}
+
+func (s *memSortKeyStore) Backfill(context.Context) (int, error) {
+	n := 0
+	s.conns.mu.Lock()
+	for id, c := range s.conns.m {
+		if key := s.key(c.Name); key != c.NameSort {
+			c.NameSort = key
+			s.conns.m[id] = c
+			n++
+		}
+	}
+	s.conns.mu.Unlock()
+	s.folders.mu.Lock()
+	for id, f := range s.folders.m {
+		if key := s.key(f.Name); key != f.NameSort {
+			f.NameSort = key
+			s.folders.m[id] = f
+			n++
+		}
+	}
+	s.folders.mu.Unlock()
+	s.creds.mu.Lock()
+	for id, c := range s.creds.m {
+		if key := s.key(c.Name); key != c.NameSort {
+			c.NameSort = key
+			s.creds.m[id] = c
+			n++
+		}
+	}
+	s.creds.mu.Unlock()
+	return n, nil
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -21,11 +22,17 @@ import (
 func allModels() []any {
 	return []any{
 		&models.User{}, &models.Connection{}, &models.Credential{}, &models.Grant{},
-		&models.ConnectionFolder{}, &models.ConnectionPlacement{}, &models.CredentialGrant{},
+		&models.ConnectionFolder{}, &models.ConnectionPlacement{}, &models.ConnectionName{}, &models.ConnectionFavorite{},
+		&models.CredentialGrant{}, &models.CredentialUsage{},
 		&models.AuditEntry{}, &models.PluginStorageItem{}, &models.Preference{},
 		&models.AgentEnrollment{}, &models.PolicyRule{}, &models.Invitation{},
-		&models.Recording{}, &models.ProtocolSetting{}, &models.AIProviderConfig{},
+		&models.Recording{}, &models.ProtocolSetting{}, &models.SystemSetting{}, &models.AIProviderConfig{},
 		&models.AIConversation{}, &models.AIMessage{}, &models.LiveStateLease{},
+		&models.CredentialAccessLog{}, &models.CredentialVersion{}, &models.CredentialApproval{},
+		&models.Webhook{}, &models.WebhookDelivery{}, &models.SessionShareLink{},
+		&models.SessionParticipant{}, &models.SessionWriteRequest{},
+		&models.ConnectionSession{}, &models.SessionObservation{}, &models.IdempotencyKey{},
+		&models.Job{}, &models.SeedRecord{},
 	}
 }
 
@@ -46,6 +53,11 @@ type Config struct {
 	DSN string
 	// LogSQL enables GORM's SQL logger at info level.
 	LogSQL bool
+	// Instrumentation, when set, times every statement; nil registers nothing.
+	Instrumentation *QueryInstrumentation
+	// Resilience, when set, retries transient read failures and guards the
+	// database with a circuit breaker.
+	Resilience *Resilience
 }
 
 // Open connects using a pure-Go driver, runs AutoMigrate, and wires the repos.
@@ -66,14 +78,54 @@ func Open(cfg Config) (*Store, error) {
 	if err != nil {
 		return nil, fmt.Errorf("open %s: %w", cfg.Driver, err)
 	}
+	if cfg.Instrumentation != nil {
+		if err := db.Use(cfg.Instrumentation); err != nil {
+			return nil, fmt.Errorf("register query instrumentation: %w", err)
+		}
+	}
 
+	if cfg.Driver == DriverMySQL {
+		if err := sizeIndexedStrings(db, allModels()); err != nil {
+			return nil, err
+		}
+	}
 	if err := db.AutoMigrate(allModels()...); err != nil {
 		return nil, fmt.Errorf("auto-migrate: %w", err)
 	}
+	if cfg.Resilience != nil {
+		if err := cfg.Resilience.wrap(db); err != nil {
+			return nil, fmt.Errorf("wrap connection pool: %w", err)
+		}
+	}
 
 	return newGormStore(db), nil
 }
 
+// Probe connects without migrating and pings the database within ctx. The
+// returned store is for read-only checks against an existing schema.
+func Probe(ctx context.Context, cfg Config) (*Store, error) {
+	dialector, err := dialector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger:               gormLogger(logger.Silent),
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", cfg.Driver, err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		_ = sqlDB.Close()
+		return nil, fmt.Errorf("ping %s: %w", cfg.Driver, err)
+	}
+	return newGormStore(db), nil
+}
+
 func gormLogger(level logger.LogLevel) logger.Interface {
 	return gormLoggerWithWriter(log.New(os.Stdout, "\r\n", log.LstdFlags), level)
 }
@@ -98,7 +150,11 @@ func dialector(cfg Config) (gorm.Dialector, error) {
 	case DriverPostgres:
 		return postgres.Open(cfg.DSN), nil
 	case DriverMySQL:
-		return mysql.Open(cfg.DSN), nil
+		dsn, err := mysqlDSN(cfg.DSN)
+		if err != nil {
+			return nil, err
+		}
+		return mysql.Open(dsn), nil
 	default:
 		return nil, fmt.Errorf("unsupported driver %q", cfg.Driver)
 	}
@@ -106,26 +162,53 @@ func dialector(cfg Config) (gorm.Dialector, error) {
 
 // newGormStore wires the GORM-backed repositories.
 func newGormStore(db *gorm.DB) *Store {
+	keys := newCollation(0)
+	if db.Dialector.Name() == "mysql" {
+		keys = newCollation(mysqlSortKeyLen)
+	}
 	return &Store{
 		Users:                &gormUserStore{db: db},
-		Connections:          &gormConnectionStore{db: db},
-		ConnectionFolders:    &gormConnectionFolderStore{db: db},
+		Connections:          &gormConnectionStore{db: db, keys: keys},
+		ConnectionFolders:    &gormConnectionFolderStore{db: db, keys: keys},
 		ConnectionPlacements: &gormConnectionPlacementStore{db: db},
-		Credentials:          &gormCredentialStore{db: db},
+		ConnectionNames:      &gormConnectionNameStore{db: db},
+		ConnectionFavorites:  &gormConnectionFavoriteStore{db: db},
+		Credentials:          &gormCredentialStore{db: db, keys: keys},
+		CredentialVersions:   &gormCredentialVersionStore{db: db},
+		CredentialUsage:      &gormCredentialUsageStore{db: db},
 		Grants:               &gormGrantStore{db: db},
 		CredentialGrants:     &gormCredentialGrantStore{db: db},
 		Audit:                &gormAuditStore{db: db},
+		CredentialAccess:     &gormCredentialAccessLogStore{db: db},
+		CredentialApprovals:  &gormCredentialApprovalStore{db: db},
 		PluginStorage:        &gormPluginStorageStore{db: db},
 		Preferences:          &gormPreferenceStore{db: db},
 		Enrollments:          &gormEnrollmentStore{db: db},
 		Policies:             &gormPolicyStore{db: db},
 		Invitations:          &gormInvitationStore{db: db},
+		Webhooks:             &gormWebhookStore{db: db},
+		WebhookDeliveries:    &gormWebhookDeliveryStore{db: db},
+		SessionShareLinks:    &gormSessionShareLinkStore{db: db},
+		SessionParticipants:  &gormSessionParticipantStore{db: db},
+		SessionWriteRequests: &gormSessionWriteRequestStore{db: db},
+		ConnectionSessions:   &gormConnectionSessionStore{db: db},
+		SessionObservations:  &gormSessionObservationStore{db: db},
+		IdempotencyKeys:      &gormIdempotencyKeyStore{db: db},
+		Jobs:                 &gormJobStore{db: db},
+		SeedRecords:          &gormSeedRecordStore{db: db},
 		Recordings:           &gormRecordingStore{db: db},
 		ProtocolSettings:     &gormProtocolSettingStore{db: db},
+		SystemSettings:       &gormSystemSettingStore{db: db},
 		AIProviders:          &gormAIProviderStore{db: db},
 		AIConversations:      &gormAIConversationStore{db: db},
 		AIMessages:           &gormAIMessageStore{db: db},
 		LiveStateLeases:      &gormLiveStateLeaseStore{db: db},
+		Activity:             &gormActivityStore{db: db},
+		SortKeys:             &gormSortKeyStore{collation: keys, db: db},
+		Orphans:              &gormOrphanStore{db: db},
+		Anonymize:            &gormAnonymizeStore{db: db},
+		ConnectionUsage:      &gormConnectionUsageStore{db: db},
+		Schema:               &gormSchemaStore{db: db},
 		close: func() error {
 			sqlDB, err := db.DB()
 			if err != nil {
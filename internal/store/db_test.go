@@ -2,15 +2,25 @@ package store
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
 
 	"github.com/charlesng35/shellcn/internal/models"
+	"github.com/charlesng35/shellcn/internal/telemetry"
 )
 
 func TestGormLoggerSuppressesRecordNotFound(t *testing.T) {
@@ -34,3 +44,218 @@ func TestGormLoggerSuppressesRecordNotFound(t *testing.T) {
 		t.Fatalf("record-not-found log output = %q, want empty", got)
 	}
 }
+
+func TestQueryInstrumentationObservesSlowQuery(t *testing.T) {
+	metrics := telemetry.NewMetrics()
+	var logs bytes.Buffer
+	s, err := Open(Config{
+		Driver: DriverSQLite,
+		DSN:    filepath.Join(t.TempDir(), "test.db"),
+		Instrumentation: &QueryInstrumentation{
+			Observe:       metrics.ObserveDBQuery,
+			SlowThreshold: time.Nanosecond,
+			Logger:        slog.New(slog.NewTextHandler(&logs, nil)),
+		},
+	})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	secret := "s3cret-lookup-value"
+	if _, err := s.Users.GetByUsername(context.Background(), secret); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("lookup: %v", err)
+	}
+
+	families, err := metrics.Registry().Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	var observed uint64
+	for _, f := range families {
+		if f.GetName() != "shellcn_db_query_duration_seconds" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "table" && l.GetValue() == "users" {
+					observed += m.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	if observed == 0 {
+		t.Fatal("histogram did not observe the users query")
+	}
+	out := logs.String()
+	if !strings.Contains(out, "slow query") || !strings.Contains(out, "table=users") {
+		t.Fatalf("slow query not logged: %s", out)
+	}
+	if strings.Contains(out, secret) {
+		t.Fatalf("slow query log leaked a bound value: %s", out)
+	}
+}
+
+func TestMySQLSchemaFitsIndexes(t *testing.T) {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "u:p@tcp(127.0.0.1:1)/shellcn", SkipInitializeWithVersion: true}),
+		&gorm.Config{DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open mysql dialector: %v", err)
+	}
+	if err := sizeIndexedStrings(db, allModels()); err != nil {
+		t.Fatalf("size indexed strings: %v", err)
+	}
+	for _, m := range allModels() {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(m); err != nil {
+			t.Fatalf("parse %T: %v", m, err)
+		}
+		keys := map[string][]*schema.Field{"primary": stmt.Schema.PrimaryFields}
+		for _, idx := range stmt.Schema.ParseIndexes() {
+			for _, opt := range idx.Fields {
+				keys[idx.Name] = append(keys[idx.Name], opt.Field)
+			}
+		}
+		for name, fields := range keys {
+			chars := 0
+			for _, f := range fields {
+				typ := db.Dialector.DataTypeOf(f)
+				if strings.Contains(typ, "text") || strings.Contains(typ, "blob") {
+					t.Errorf("%s.%s: %s column in key %s cannot be indexed", stmt.Schema.Table, f.DBName, typ, name)
+				}
+				if f.DataType == schema.String {
+					chars += f.Size
+				}
+			}
+			if chars > mysqlKeyChars {
+				t.Errorf("%s key %s spans %d characters, over the InnoDB limit", stmt.Schema.Table, name, chars)
+			}
+		}
+	}
+
+	long := strings.Repeat("Ärger ", 100)
+	if key := newCollation(mysqlSortKeyLen).key(long); len(key) > mysqlStringSize {
+		t.Fatalf("sort key of %d characters does not fit its column", len(key))
+	}
+	// Only MySQL needs the cap; elsewhere long names that share a prefix
+	// still sort by collation.
+	if a, b := newCollation(0).key(long+"b"), newCollation(0).key(long+"a"); len(a) <= mysqlStringSize || a <= b {
+		t.Fatalf("uncapped keys: %d characters, ordered %v", len(a), a > b)
+	}
+	dsn, err := mysqlDSN("u:p@tcp(db:3306)/shellcn")
+	if err != nil || !strings.Contains(dsn, "parseTime=true") {
+		t.Fatalf("mysql dsn = %q, %v", dsn, err)
+	}
+}
+
+func TestSchemaPlanReportsPendingChangesAndDrift(t *testing.T) {
+	ctx := context.Background()
+	s, err := Open(Config{Driver: DriverSQLite, DSN: filepath.Join(t.TempDir(), "plan.db")})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	rep, err := s.Schema.Plan(ctx)
+	if err != nil || rep.Driver != "sqlite" || !rep.UpToDate() || len(rep.Drift) != 0 {
+		t.Fatalf("freshly migrated schema: %+v err=%v", rep, err)
+	}
+
+	db := s.Schema.(*gormSchemaStore).db
+	for _, stmt := range []string{
+		"DROP TABLE jobs",
+		"ALTER TABLE connection_sessions DROP COLUMN bytes_out",
+		"DROP INDEX idx_connection_sessions_user_id",
+		"ALTER TABLE users ADD COLUMN hotfix TEXT",
+		"CREATE TABLE manual_backup (id TEXT)",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	rep, err = s.Schema.Plan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SchemaChange{
+		{Kind: SchemaNewColumn, Table: "connection_sessions", Column: "bytes_out"},
+		{Kind: SchemaMissingIndex, Table: "connection_sessions", Index: "idx_connection_sessions_user_id"},
+		{Kind: SchemaNewTable, Table: "jobs"},
+	}
+	for _, c := range want {
+		if !slices.Contains(rep.Pending, c) {
+			t.Errorf("pending is missing %+v: %+v", c, rep.Pending)
+		}
+	}
+	if len(rep.Pending) != len(want) {
+		t.Errorf("pending = %+v", rep.Pending)
+	}
+	drift := []SchemaChange{
+		{Kind: SchemaExtraColumn, Table: "users", Column: "hotfix"},
+		{Kind: SchemaExtraTable, Table: "manual_backup"},
+	}
+	if !slices.Equal(rep.Drift, drift) {
+		t.Errorf("drift = %+v", rep.Drift)
+	}
+}
+
+func TestTypeFamilyMatchesDriverSpellings(t *testing.T) {
+	for _, pair := range [][2]string{
+		{"bigint", "int8"}, {"boolean", "bool"}, {"boolean", "TINYINT"}, {"timestamptz", "timestamp with time zone"},
+		{"datetime(3)", "DATETIME"}, {"varchar(191)", "longtext"}, {"decimal", "numeric"}, {"bytea", "BLOB"},
+	} {
+		if a, b := typeFamily(pair[0]), typeFamily(pair[1]); a == "" || a != b {
+			t.Errorf("%s (%q) and %s (%q) should match", pair[0], a, pair[1], b)
+		}
+	}
+	if typeFamily("text") == typeFamily("integer") {
+		t.Error("text and integer must differ")
+	}
+}
+
+func TestAuditListUnresourcedFindsMigratedRows(t *testing.T) {
+	ctx := context.Background()
+	s, err := Open(Config{Driver: DriverSQLite, DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	for _, id := range []string{"old", "new"} {
+		if err := s.Audit.Append(ctx, &models.AuditEntry{ID: id, Time: time.Now(), Event: "x", Result: models.AuditAllowed}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	// Rows written before the columns existed read back as NULL.
+	db := s.Audit.(*gormAuditStore).db
+	if err := db.Exec("UPDATE audit_entries SET resource_type = NULL, resource_id = NULL WHERE id = ?", "old").Error; err != nil {
+		t.Fatalf("null resource: %v", err)
+	}
+	pending, err := s.Audit.ListUnresourced(ctx, 10)
+	if err != nil || len(pending) != 1 || pending[0].ID != "old" {
+		t.Fatalf("unresourced: %+v err=%v", pending, err)
+	}
+	if err := s.Audit.SetResource(ctx, "old", "", ""); err != nil {
+		t.Fatalf("set resource: %v", err)
+	}
+	if pending, _ := s.Audit.ListUnresourced(ctx, 10); len(pending) != 0 {
+		t.Fatalf("still unresourced after set: %+v", pending)
+	}
+}
+
+func TestAuditAppendBatchSpansChunks(t *testing.T) {
+	ctx := context.Background()
+	s, err := Open(Config{Driver: DriverSQLite, DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	entries := make([]*models.AuditEntry, AuditBatchSize*2+1)
+	for i := range entries {
+		entries[i] = &models.AuditEntry{ID: fmt.Sprintf("e%04d", i), Time: time.Now(), Event: "user.disable", Result: models.AuditAllowed}
+	}
+	if err := s.Audit.AppendBatch(ctx, entries); err != nil {
+		t.Fatalf("append batch: %v", err)
+	}
+	if n, err := s.Audit.Count(ctx, AuditFilter{}); err != nil || n != int64(len(entries)) {
+		t.Fatalf("count = %d err=%v, want %d", n, err, len(entries))
+	}
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/charlesng35/shellcn/internal/models"
+)
+
+type gormIdempotencyKeyStore struct{ db *gorm.DB }
+
+func (s *gormIdempotencyKeyStore) Get(ctx context.Context, id string, now time.Time) (models.IdempotencyKey, error) {
+	var k models.IdempotencyKey
+	err := s.db.WithContext(ctx).First(&k, "id = ? AND expires_at > ?", id, now).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return k, ErrNotFound
+	}
+	return k, err
+}
+
+func (s *gormIdempotencyKeyStore) Put(ctx context.Context, k *models.IdempotencyKey) error {
+	return s.db.WithContext(ctx).Save(k).Error
+}
+
+func (s *gormIdempotencyKeyStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res := s.db.WithContext(ctx).Delete(&models.IdempotencyKey{}, "expires_at <= ?", now)
+	return res.RowsAffected, res.Error
+}
+
+type memIdempotencyKeyStore struct {
+	mu sync.Mutex
+	m  map[string]models.IdempotencyKey
+}
+
+func (s *memIdempotencyKeyStore) Get(_ context.Context, id string, now time.Time) (models.IdempotencyKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.m[id]
+	if !ok || !now.Before(k.ExpiresAt) {
+		return models.IdempotencyKey{}, ErrNotFound
+	}
+	return k, nil
+}
+
+func (s *memIdempotencyKeyStore) Put(_ context.Context, k *models.IdempotencyKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if k.CreatedAt.IsZero() {
+		k.CreatedAt = time.Now()
+	}
+	s.m[k.ID] = *k
+	return nil
+}
+
+func (s *memIdempotencyKeyStore) DeleteExpired(_ context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deleted int64
+	for id, k := range s.m {
+		if !now.Before(k.ExpiresAt) {
+			delete(s.m, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
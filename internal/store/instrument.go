@@ -0,0 +1,83 @@
+package store
+
+import (
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const queryStartKey = "shellcn:query_start"
+
+// QueryInstrumentation times every GORM statement. It is only registered when
+// set on Config, so a disabled store pays nothing for it.
+type QueryInstrumentation struct {
+	// Observe receives each statement's latency by operation and table.
+	Observe func(operation, table string, d time.Duration)
+	// SlowThreshold logs statements at or above it; 0 disables the slow log.
+	SlowThreshold time.Duration
+	Logger        *slog.Logger
+}
+
+func (q *QueryInstrumentation) Name() string { return "shellcn:query_instrumentation" }
+
+func (q *QueryInstrumentation) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("shellcn:before_create", q.start); err != nil {
+		return err
+	}
+	if err := cb.Create().After("gorm:create").Register("shellcn:after_create", q.finish("insert")); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("shellcn:before_query", q.start); err != nil {
+		return err
+	}
+	if err := cb.Query().After("gorm:query").Register("shellcn:after_query", q.finish("select")); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("shellcn:before_update", q.start); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("shellcn:after_update", q.finish("update")); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("shellcn:before_delete", q.start); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:delete").Register("shellcn:after_delete", q.finish("delete")); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("shellcn:before_row", q.start); err != nil {
+		return err
+	}
+	if err := cb.Row().After("gorm:row").Register("shellcn:after_row", q.finish("select")); err != nil {
+		return err
+	}
+	if err := cb.Raw().Before("gorm:raw").Register("shellcn:before_raw", q.start); err != nil {
+		return err
+	}
+	return cb.Raw().After("gorm:raw").Register("shellcn:after_raw", q.finish("raw"))
+}
+
+func (q *QueryInstrumentation) start(db *gorm.DB) {
+	db.InstanceSet(queryStartKey, time.Now())
+}
+
+func (q *QueryInstrumentation) finish(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(queryStartKey)
+		if !ok {
+			return
+		}
+		d := time.Since(v.(time.Time))
+		table := db.Statement.Table
+		if q.Observe != nil {
+			q.Observe(operation, table, d)
+		}
+		if q.SlowThreshold > 0 && d >= q.SlowThreshold && q.Logger != nil {
+			// The SQL template only: bound values can carry ciphertext or hashes.
+			q.Logger.Warn("slow query", "operation", operation, "table", table,
+				"duration", d, "sql", db.Statement.SQL.String())
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/charlesng35/shellcn/internal/models"
+)
+
+type gormJobStore struct{ db *gorm.DB }
+
+func (s *gormJobStore) Create(ctx context.Context, j *models.Job) error {
+	return s.db.WithContext(ctx).Create(j).Error
+}
+
+func (s *gormJobStore) Get(ctx context.Context, id string) (models.Job, error) {
+	var j models.Job
+	err := s.db.WithContext(ctx).First(&j, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return j, ErrNotFound
+	}
+	return j, err
+}
+
+func (s *gormJobStore) Update(ctx context.Context, j *models.Job) error {
+	res := s.db.WithContext(ctx).Model(&models.Job{}).Where("id = ?", j.ID).
+		Updates(map[string]any{
+			"status":       j.Status,
+			"progress":     j.Progress,
+			"error":        j.Error,
+			"content_type": j.ContentType,
+			"file_name":    j.FileName,
+			"result":       j.Result,
+			"result_key":   j.ResultKey,
+			"result_size":  j.ResultSize,
+			"started_at":   j.StartedAt,
+			"finished_at":  j.FinishedAt,
+			"expires_at":   j.ExpiresAt,
+			"updated_at":   time.Now(),
+		})
+	return rowsOrNotFound(res)
+}
+
+func (s *gormJobStore) ListByStatus(ctx context.Context, statuses ...models.JobStatus) ([]models.Job, error) {
+	var list []models.Job
+	err := s.db.WithContext(ctx).Where("status IN ?", statuses).Order("created_at").Find(&list).Error
+	return list, err
+}
+
+func (s *gormJobStore) ListExpired(ctx context.Context, now time.Time) ([]models.Job, error) {
+	var list []models.Job
+	err := s.db.WithContext(ctx).Where("expires_at IS NOT NULL AND expires_at <= ?", now).Find(&list).Error
+	return list, err
+}
+
+func (s *gormJobStore) Delete(ctx context.Context, id string) error {
+	return s.db.WithContext(ctx).Delete(&models.Job{}, "id = ?", id).Error
+}
+
+type memJobStore struct {
+	mu sync.Mutex
+	m  map[string]models.Job
+}
+
+func (s *memJobStore) Create(_ context.Context, j *models.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.m[j.ID]; ok {
+		return models.ErrConflict
+	}
+	now := time.Now()
+	if j.CreatedAt.IsZero() {
+		j.CreatedAt = now
+	}
+	j.UpdatedAt = now
+	s.m[j.ID] = cloneJob(*j)
+	return nil
+}
+
+func (s *memJobStore) Get(_ context.Context, id string) (models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.m[id]
+	if !ok {
+		return models.Job{}, ErrNotFound
+	}
+	return cloneJob(j), nil
+}
+
+func (s *memJobStore) Update(_ context.Context, j *models.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.m[j.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	prev.Status, prev.Progress, prev.Error = j.Status, j.Progress, j.Error
+	prev.ContentType, prev.FileName = j.ContentType, j.FileName
+	prev.Result, prev.ResultKey, prev.ResultSize = slices.Clone(j.Result), j.ResultKey, j.ResultSize
+	prev.StartedAt, prev.FinishedAt, prev.ExpiresAt = j.StartedAt, j.FinishedAt, j.ExpiresAt
+	prev.UpdatedAt = time.Now()
+	s.m[j.ID] = prev
+	return nil
+}
+
+func (s *memJobStore) ListByStatus(_ context.Context, statuses ...models.JobStatus) ([]models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []models.Job
+	for _, j := range s.m {
+		if slices.Contains(statuses, j.Status) {
+			out = append(out, cloneJob(j))
+		}
+	}
+	slices.SortFunc(out, func(a, b models.Job) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return out, nil
+}
+
+func (s *memJobStore) ListExpired(_ context.Context, now time.Time) ([]models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []models.Job
+	for _, j := range s.m {
+		if j.ExpiresAt != nil && !j.ExpiresAt.After(now) {
+			out = append(out, cloneJob(j))
+		}
+	}
+	return out, nil
+}
+
+func (s *memJobStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, id)
+	return nil
+}
+
+func cloneJob(j models.Job) models.Job {
+	j.Params = maps.Clone(j.Params)
+	j.Result = slices.Clone(j.Result)
+	return j
+}
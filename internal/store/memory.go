@@ -1,7 +1,9 @@
 package store
 
 import (
+	"cmp"
 	"context"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -12,27 +14,69 @@ import (
 
 // NewMemory returns a fully in-memory Store for unit tests — no DB, no gorm.
 func NewMemory() *Store {
-	return &Store{
+	keys := newCollation(0)
+	s := &Store{
 		Users:                &memUserStore{users: map[string]models.User{}, hashes: map[string]string{}},
-		Connections:          &memConnectionStore{m: map[string]models.Connection{}},
-		ConnectionFolders:    &memConnectionFolderStore{m: map[string]models.ConnectionFolder{}},
+		Connections:          &memConnectionStore{m: map[string]models.Connection{}, keys: keys},
+		ConnectionFolders:    &memConnectionFolderStore{m: map[string]models.ConnectionFolder{}, keys: keys},
 		ConnectionPlacements: &memConnectionPlacementStore{m: map[string]models.ConnectionPlacement{}},
-		Credentials:          &memCredentialStore{m: map[string]models.Credential{}},
+		ConnectionNames:      &memConnectionNameStore{m: map[string]models.ConnectionName{}},
+		ConnectionFavorites:  &memConnectionFavoriteStore{m: map[string]models.ConnectionFavorite{}},
+		Credentials:          &memCredentialStore{m: map[string]models.Credential{}, keys: keys},
+		CredentialVersions:   &memCredentialVersionStore{m: map[string][]models.CredentialVersion{}},
+		CredentialUsage:      &memCredentialUsageStore{m: map[string]models.CredentialUsage{}},
 		Grants:               &memGrantStore{m: map[string]models.Grant{}},
 		CredentialGrants:     &memCredentialGrantStore{m: map[string]models.CredentialGrant{}},
 		Audit:                &memAuditStore{},
+		CredentialAccess:     &memCredentialAccessLogStore{},
+		CredentialApprovals:  &memCredentialApprovalStore{m: map[string]models.CredentialApproval{}},
 		PluginStorage:        &memPluginStorageStore{m: map[pluginStorageKey]models.PluginStorageItem{}},
 		Preferences:          &memPreferenceStore{m: map[string]models.Preference{}},
 		Enrollments:          &memEnrollmentStore{m: map[string]models.AgentEnrollment{}},
 		Policies:             &memPolicyStore{m: map[string]models.PolicyRule{}},
 		Invitations:          &memInvitationStore{m: map[string]models.Invitation{}},
+		Webhooks:             &memWebhookStore{m: map[string]models.Webhook{}},
+		WebhookDeliveries:    &memWebhookDeliveryStore{m: map[string][]models.WebhookDelivery{}},
+		SessionShareLinks:    &memSessionShareLinkStore{m: map[string]models.SessionShareLink{}},
+		SessionParticipants:  &memSessionParticipantStore{m: map[string]models.SessionParticipant{}},
+		SessionWriteRequests: &memSessionWriteRequestStore{m: map[string]models.SessionWriteRequest{}},
+		ConnectionSessions:   &memConnectionSessionStore{m: map[string]models.ConnectionSession{}},
+		SessionObservations:  &memSessionObservationStore{m: map[string]models.SessionObservation{}},
+		IdempotencyKeys:      &memIdempotencyKeyStore{m: map[string]models.IdempotencyKey{}},
+		Jobs:                 &memJobStore{m: map[string]models.Job{}},
+		SeedRecords:          &memSeedRecordStore{m: map[string]models.SeedRecord{}},
 		Recordings:           &memRecordingStore{m: map[string]models.Recording{}},
 		ProtocolSettings:     &memProtocolSettingStore{m: map[string]models.ProtocolSetting{}},
+		SystemSettings:       &memSystemSettingStore{m: map[string]models.SystemSetting{}},
 		AIProviders:          &memAIProviderStore{m: map[string]models.AIProviderConfig{}},
 		AIConversations:      &memAIConversationStore{m: map[string]models.AIConversation{}},
 		AIMessages:           &memAIMessageStore{m: map[string][]models.AIMessage{}},
 		LiveStateLeases:      &memLiveStateLeaseStore{m: map[string]models.LiveStateLease{}},
 	}
+	s.Activity = &memActivityStore{
+		audit: s.Audit.(*memAuditStore), recordings: s.Recordings.(*memRecordingStore),
+		access: s.CredentialAccess.(*memCredentialAccessLogStore),
+	}
+	s.SortKeys = &memSortKeyStore{
+		collation: keys, conns: s.Connections.(*memConnectionStore),
+		folders: s.ConnectionFolders.(*memConnectionFolderStore), creds: s.Credentials.(*memCredentialStore),
+	}
+	s.Orphans = &memOrphanStore{
+		users: s.Users.(*memUserStore), conns: s.Connections.(*memConnectionStore), creds: s.Credentials.(*memCredentialStore),
+		grants: s.Grants.(*memGrantStore), credGrants: s.CredentialGrants.(*memCredentialGrantStore),
+	}
+	s.Anonymize = &memAnonymizeStore{
+		recordings: s.Recordings.(*memRecordingStore), audit: s.Audit.(*memAuditStore),
+		convs: s.AIConversations.(*memAIConversationStore), messages: s.AIMessages.(*memAIMessageStore),
+		access: s.CredentialAccess.(*memCredentialAccessLogStore), credUsage: s.CredentialUsage.(*memCredentialUsageStore),
+		prefs: s.Preferences.(*memPreferenceStore),
+	}
+	s.Schema = memSchemaStore{}
+	s.ConnectionUsage = &memConnectionUsageStore{
+		conns: s.Connections.(*memConnectionStore), sessions: s.ConnectionSessions.(*memConnectionSessionStore),
+		grants: s.Grants.(*memGrantStore),
+	}
+	return s
 }
 
 type memLiveStateLeaseStore struct {
@@ -108,6 +152,19 @@ func (s *memLiveStateLeaseStore) Release(_ context.Context, key, leaseID string)
 	return nil
 }
 
+func (s *memLiveStateLeaseStore) CountLive(_ context.Context, prefix, suffix string, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for key, lease := range s.m {
+		if now.Before(lease.ExpiresAt) && len(key) >= len(prefix)+len(suffix) &&
+			strings.HasPrefix(key, prefix) && strings.HasSuffix(key, suffix) {
+			n++
+		}
+	}
+	return n, nil
+}
+
 func (s *memLiveStateLeaseStore) DeleteExpired(_ context.Context, now time.Time) (int64, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -366,7 +423,7 @@ func (s *memUserStore) GetPasswordHash(_ context.Context, userID string) (string
 	return s.hashes[userID], nil
 }
 
-func (s *memUserStore) SetPasswordHash(_ context.Context, userID, hash string) error {
+func (s *memUserStore) SetPasswordHash(_ context.Context, userID, hash string, at time.Time) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if _, ok := s.users[userID]; !ok {
@@ -375,10 +432,26 @@ func (s *memUserStore) SetPasswordHash(_ context.Context, userID, hash string) e
 	s.hashes[userID] = hash
 	u := s.users[userID]
 	u.SessionVersion++
+	u.PasswordChangedAt, u.MustChangePassword = &at, false
 	s.users[userID] = u
 	return nil
 }
 
+func (s *memUserStore) RequirePasswordChange(_ context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id, u := range s.users {
+		if s.hashes[id] == "" || u.MustChangePassword || (u.PasswordChangedAt != nil && !u.PasswordChangedAt.Before(before)) {
+			continue
+		}
+		u.MustChangePassword = true
+		s.users[id] = u
+		n++
+	}
+	return n, nil
+}
+
 func (s *memUserStore) List(_ context.Context) ([]models.User, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -441,8 +514,9 @@ func (s *memUserStore) Count(_ context.Context) (int64, error) {
 }
 
 type memConnectionStore struct {
-	mu sync.RWMutex
-	m  map[string]models.Connection
+	mu   sync.RWMutex
+	m    map[string]models.Connection
+	keys *collation
 }
 
 func (s *memConnectionStore) Create(_ context.Context, c *models.Connection) error {
@@ -451,6 +525,7 @@ func (s *memConnectionStore) Create(_ context.Context, c *models.Connection) err
 	if _, ok := s.m[c.ID]; ok {
 		return models.ErrConflict
 	}
+	c.NameSort = s.keys.key(c.Name)
 	s.m[c.ID] = *c
 	return nil
 }
@@ -459,7 +534,7 @@ func (s *memConnectionStore) Get(_ context.Context, id string) (models.Connectio
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	c, ok := s.m[id]
-	if !ok {
+	if !ok || c.DeletedAt != nil {
 		return models.Connection{}, ErrNotFound
 	}
 	return c, nil
@@ -470,11 +545,11 @@ func (s *memConnectionStore) ListByOwner(_ context.Context, ownerID string) ([]m
 	defer s.mu.RUnlock()
 	var out []models.Connection
 	for _, c := range s.m {
-		if c.OwnerID == ownerID {
+		if c.OwnerID == ownerID && c.DeletedAt == nil {
 			out = append(out, c)
 		}
 	}
-	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	sort.Slice(out, func(i, j int) bool { return nameSortLess(out[i].NameSort, out[i].Name, out[j].NameSort, out[j].Name) })
 	return out, nil
 }
 
@@ -483,22 +558,51 @@ func (s *memConnectionStore) List(_ context.Context) ([]models.Connection, error
 	defer s.mu.RUnlock()
 	out := make([]models.Connection, 0, len(s.m))
 	for _, c := range s.m {
-		out = append(out, c)
+		if c.DeletedAt == nil {
+			out = append(out, c)
+		}
 	}
-	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	sort.Slice(out, func(i, j int) bool { return nameSortLess(out[i].NameSort, out[i].Name, out[j].NameSort, out[j].Name) })
 	return out, nil
 }
 
 func (s *memConnectionStore) Update(_ context.Context, c *models.Connection) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if _, ok := s.m[c.ID]; !ok {
+	cur, ok := s.m[c.ID]
+	if !ok || cur.DeletedAt != nil {
 		return ErrNotFound
 	}
+	c.NameSort = s.keys.key(c.Name)
+	c.LastUsedAt = cur.LastUsedAt
 	s.m[c.ID] = *c
 	return nil
 }
 
+func (s *memConnectionStore) MarkUsed(_ context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.m[id]
+	if !ok || c.DeletedAt != nil {
+		return ErrNotFound
+	}
+	c.LastUsedAt = &at
+	s.m[id] = c
+	return nil
+}
+
+func (s *memConnectionStore) SetOwner(_ context.Context, id, ownerID string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.m[id]
+	if !ok || c.DeletedAt != nil {
+		return ErrNotFound
+	}
+	c.OwnerID, c.UpdatedAt = ownerID, at
+	s.m[id] = c
+	return nil
+}
+
 func (s *memConnectionStore) Delete(_ context.Context, id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -506,9 +610,62 @@ func (s *memConnectionStore) Delete(_ context.Context, id string) error {
 	return nil
 }
 
+func (s *memConnectionStore) Trash(_ context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.m[id]
+	if !ok || c.DeletedAt != nil {
+		return ErrNotFound
+	}
+	c.DeletedAt = &at
+	s.m[id] = c
+	return nil
+}
+
+func (s *memConnectionStore) GetTrashed(_ context.Context, id string) (models.Connection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.m[id]
+	if !ok || c.DeletedAt == nil {
+		return models.Connection{}, ErrNotFound
+	}
+	return c, nil
+}
+
+func (s *memConnectionStore) ListTrashed(_ context.Context, f TrashFilter) ([]models.Connection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []models.Connection
+	for _, c := range s.m {
+		if c.DeletedAt == nil || (f.OwnerID != "" && c.OwnerID != f.OwnerID) {
+			continue
+		}
+		if !f.TrashedBefore.IsZero() && !c.DeletedAt.Before(f.TrashedBefore) {
+			continue
+		}
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].DeletedAt.After(*out[j].DeletedAt) })
+	return out, nil
+}
+
+func (s *memConnectionStore) Restore(_ context.Context, id, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.m[id]
+	if !ok || c.DeletedAt == nil {
+		return ErrNotFound
+	}
+	c.Name, c.NameSort = name, s.keys.key(name)
+	c.DeletedAt = nil
+	s.m[id] = c
+	return nil
+}
+
 type memConnectionFolderStore struct {
-	mu sync.RWMutex
-	m  map[string]models.ConnectionFolder
+	mu   sync.RWMutex
+	m    map[string]models.ConnectionFolder
+	keys *collation
 }
 
 func (s *memConnectionFolderStore) Create(_ context.Context, f *models.ConnectionFolder) error {
@@ -517,6 +674,7 @@ func (s *memConnectionFolderStore) Create(_ context.Context, f *models.Connectio
 	if _, ok := s.m[f.ID]; ok {
 		return models.ErrConflict
 	}
+	f.NameSort = s.keys.key(f.Name)
 	s.m[f.ID] = *f
 	return nil
 }
@@ -531,6 +689,25 @@ func (s *memConnectionFolderStore) Get(_ context.Context, id string) (models.Con
 	return f, nil
 }
 
+func (s *memConnectionFolderStore) List(_ context.Context) ([]models.ConnectionFolder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]models.ConnectionFolder, 0, len(s.m))
+	for _, f := range s.m {
+		out = append(out, f)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].UserID != out[j].UserID {
+			return out[i].UserID < out[j].UserID
+		}
+		if out[i].SortOrder == out[j].SortOrder {
+			return nameSortLess(out[i].NameSort, out[i].Name, out[j].NameSort, out[j].Name)
+		}
+		return out[i].SortOrder < out[j].SortOrder
+	})
+	return out, nil
+}
+
 func (s *memConnectionFolderStore) ListByUser(_ context.Context, userID string) ([]models.ConnectionFolder, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -542,7 +719,7 @@ func (s *memConnectionFolderStore) ListByUser(_ context.Context, userID string)
 	}
 	sort.Slice(out, func(i, j int) bool {
 		if out[i].SortOrder == out[j].SortOrder {
-			return out[i].Name < out[j].Name
+			return nameSortLess(out[i].NameSort, out[i].Name, out[j].NameSort, out[j].Name)
 		}
 		return out[i].SortOrder < out[j].SortOrder
 	})
@@ -555,6 +732,7 @@ func (s *memConnectionFolderStore) Update(_ context.Context, f *models.Connectio
 	if _, ok := s.m[f.ID]; !ok {
 		return ErrNotFound
 	}
+	f.NameSort = s.keys.key(f.Name)
 	s.m[f.ID] = *f
 	return nil
 }
@@ -629,9 +807,153 @@ func (s *memConnectionPlacementStore) MoveFolder(_ context.Context, userID, fold
 	return nil
 }
 
-type memCredentialStore struct {
+type memConnectionNameStore struct {
+	mu sync.Mutex
+	m  map[string]models.ConnectionName
+}
+
+func (s *memConnectionNameStore) Claim(_ context.Context, n *models.ConnectionName) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, held := range s.m {
+		if id != n.ConnectionID && held.OwnerID == n.OwnerID && held.FolderID == n.FolderID && held.NameHash == n.NameHash {
+			return models.ErrConflict
+		}
+	}
+	s.m[n.ConnectionID] = *n
+	return nil
+}
+
+func (s *memConnectionNameStore) Release(_ context.Context, connectionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, connectionID)
+	return nil
+}
+
+func (s *memConnectionNameStore) Reset(_ context.Context, list []models.ConnectionName) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := make(map[string]models.ConnectionName, len(list))
+	seen := map[models.ConnectionName]bool{}
+	for _, n := range list {
+		key := models.ConnectionName{OwnerID: n.OwnerID, FolderID: n.FolderID, NameHash: n.NameHash}
+		if seen[key] {
+			return models.ErrConflict
+		}
+		seen[key] = true
+		m[n.ConnectionID] = n
+	}
+	s.m = m
+	return nil
+}
+
+type memConnectionFavoriteStore struct {
+	mu sync.RWMutex
+	m  map[string]models.ConnectionFavorite
+}
+
+func (s *memConnectionFavoriteStore) ListByUser(_ context.Context, userID string) ([]models.ConnectionFavorite, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []models.ConnectionFavorite
+	for _, f := range s.m {
+		if f.UserID == userID {
+			out = append(out, f)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Position != out[j].Position {
+			return out[i].Position < out[j].Position
+		}
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out, nil
+}
+
+func (s *memConnectionFavoriteStore) Set(_ context.Context, f *models.ConnectionFavorite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := placementKey(f.UserID, f.ConnectionID)
+	if existing, ok := s.m[key]; ok {
+		existing.Position = f.Position
+		s.m[key] = existing
+		return nil
+	}
+	s.m[key] = *f
+	return nil
+}
+
+func (s *memConnectionFavoriteStore) Delete(_ context.Context, userID, connectionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, placementKey(userID, connectionID))
+	return nil
+}
+
+func (s *memConnectionFavoriteStore) DeleteByConnection(_ context.Context, connectionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, f := range s.m {
+		if f.ConnectionID == connectionID {
+			delete(s.m, key)
+		}
+	}
+	return nil
+}
+
+type memCredentialUsageStore struct {
 	mu sync.RWMutex
-	m  map[string]models.Credential
+	m  map[string]models.CredentialUsage
+}
+
+func (s *memCredentialUsageStore) ListByUser(_ context.Context, userID string) ([]models.CredentialUsage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []models.CredentialUsage
+	for _, u := range s.m {
+		if u.UserID == userID {
+			out = append(out, u)
+		}
+	}
+	return out, nil
+}
+
+func (s *memCredentialUsageStore) SetFavorite(_ context.Context, userID, credentialID string, favorite bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := placementKey(userID, credentialID)
+	u := s.m[key]
+	u.UserID, u.CredentialID, u.Favorite = userID, credentialID, favorite
+	s.m[key] = u
+	return nil
+}
+
+func (s *memCredentialUsageStore) Touch(_ context.Context, userID, credentialID string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := placementKey(userID, credentialID)
+	u := s.m[key]
+	u.UserID, u.CredentialID, u.LastUsedAt = userID, credentialID, &at
+	s.m[key] = u
+	return nil
+}
+
+func (s *memCredentialUsageStore) DeleteByCredential(_ context.Context, credentialID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, u := range s.m {
+		if u.CredentialID == credentialID {
+			delete(s.m, key)
+		}
+	}
+	return nil
+}
+
+type memCredentialStore struct {
+	mu   sync.RWMutex
+	m    map[string]models.Credential
+	keys *collation
 }
 
 func (s *memCredentialStore) Create(_ context.Context, c *models.Credential) error {
@@ -640,6 +962,7 @@ func (s *memCredentialStore) Create(_ context.Context, c *models.Credential) err
 	if _, ok := s.m[c.ID]; ok {
 		return models.ErrConflict
 	}
+	c.NameSort = s.keys.key(c.Name)
 	s.m[c.ID] = *c
 	return nil
 }
@@ -663,7 +986,25 @@ func (s *memCredentialStore) ListByOwner(_ context.Context, ownerID string) ([]m
 			out = append(out, c)
 		}
 	}
-	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	sort.Slice(out, func(i, j int) bool { return nameSortLess(out[i].NameSort, out[i].Name, out[j].NameSort, out[j].Name) })
+	return out, nil
+}
+
+func (s *memCredentialStore) List(_ context.Context, f CredentialFilter) ([]models.Credential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []models.Credential
+	for _, c := range s.m {
+		switch {
+		case f.OwnerID != "" && c.OwnerID != f.OwnerID,
+			f.IDs != nil && !slices.Contains(f.IDs, c.ID),
+			len(f.Kinds) > 0 && !slices.Contains(f.Kinds, c.Kind),
+			f.Protocol != "" && len(c.Protocols) > 0 && !slices.Contains(c.Protocols, f.Protocol):
+			continue
+		}
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return nameSortLess(out[i].NameSort, out[i].Name, out[j].NameSort, out[j].Name) })
 	return out, nil
 }
 
@@ -673,10 +1014,23 @@ func (s *memCredentialStore) Update(_ context.Context, c *models.Credential) err
 	if _, ok := s.m[c.ID]; !ok {
 		return ErrNotFound
 	}
+	c.NameSort = s.keys.key(c.Name)
 	s.m[c.ID] = *c
 	return nil
 }
 
+func (s *memCredentialStore) SetOwner(_ context.Context, id, ownerID string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.m[id]
+	if !ok {
+		return ErrNotFound
+	}
+	c.OwnerID, c.UpdatedAt = ownerID, at
+	s.m[id] = c
+	return nil
+}
+
 func (s *memCredentialStore) Delete(_ context.Context, id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -684,6 +1038,38 @@ func (s *memCredentialStore) Delete(_ context.Context, id string) error {
 	return nil
 }
 
+type memCredentialVersionStore struct {
+	mu sync.RWMutex
+	m  map[string][]models.CredentialVersion
+}
+
+func (s *memCredentialVersionStore) Create(_ context.Context, v *models.CredentialVersion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.m[v.CredentialID] {
+		if existing.Version == v.Version {
+			return models.ErrConflict
+		}
+	}
+	s.m[v.CredentialID] = append(s.m[v.CredentialID], *v)
+	return nil
+}
+
+func (s *memCredentialVersionStore) List(_ context.Context, credentialID string) ([]models.CredentialVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := append([]models.CredentialVersion(nil), s.m[credentialID]...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+func (s *memCredentialVersionStore) DeleteByCredential(_ context.Context, credentialID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, credentialID)
+	return nil
+}
+
 type memGrantStore struct {
 	mu sync.RWMutex
 	m  map[string]models.Grant
@@ -711,14 +1097,25 @@ func (s *memGrantStore) Delete(_ context.Context, id string) error {
 func (s *memGrantStore) Get(_ context.Context, connectionID, subjectID string) (models.Grant, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	now := time.Now()
 	for _, g := range s.m {
-		if g.ConnectionID == connectionID && g.SubjectID == subjectID {
+		if g.ConnectionID == connectionID && g.SubjectID == subjectID && g.Active(now) {
 			return g, nil
 		}
 	}
 	return models.Grant{}, ErrNotFound
 }
 
+func (s *memGrantStore) GetByID(_ context.Context, id string) (models.Grant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	g, ok := s.m[id]
+	if !ok {
+		return models.Grant{}, ErrNotFound
+	}
+	return g, nil
+}
+
 func (s *memGrantStore) ListByConnection(_ context.Context, connectionID string) ([]models.Grant, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -735,36 +1132,91 @@ func (s *memGrantStore) ListBySubject(_ context.Context, subjectID string) ([]mo
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	var out []models.Grant
+	now := time.Now()
 	for _, g := range s.m {
-		if g.SubjectID == subjectID {
+		if g.SubjectID == subjectID && g.Active(now) {
 			out = append(out, g)
 		}
 	}
 	return out, nil
 }
 
-type memCredentialGrantStore struct {
-	mu sync.RWMutex
-	m  map[string]models.CredentialGrant
+func (s *memGrantStore) ListExpiring(_ context.Context, connectionIDs []string, from, to time.Time) ([]models.Grant, error) {
+	return s.listLapsing(func(g models.Grant) bool { return slices.Contains(connectionIDs, g.ConnectionID) }, from, to), nil
 }
 
-func (s *memCredentialGrantStore) Create(_ context.Context, g *models.CredentialGrant) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	for _, existing := range s.m {
-		if existing.CredentialID == g.CredentialID && existing.SubjectID == g.SubjectID {
-			return models.ErrConflict
+func (s *memGrantStore) ListLapsing(_ context.Context, from, to time.Time) ([]models.Grant, error) {
+	return s.listLapsing(func(models.Grant) bool { return true }, from, to), nil
+}
+
+func (s *memGrantStore) listLapsing(keep func(models.Grant) bool, from, to time.Time) []models.Grant {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := []models.Grant{}
+	for _, g := range s.m {
+		if keep(g) && g.ExpiresAt != nil && g.ExpiresAt.After(from) && !g.ExpiresAt.After(to) {
+			out = append(out, g)
 		}
 	}
-	s.m[g.ID] = *g
-	return nil
+	slices.SortFunc(out, func(a, b models.Grant) int {
+		if c := a.ExpiresAt.Compare(*b.ExpiresAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return out
 }
 
-func (s *memCredentialGrantStore) Delete(_ context.Context, id string) error {
+func (s *memGrantStore) SetAccess(_ context.Context, id string, access models.Access) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	delete(s.m, id)
-	return nil
+	g, ok := s.m[id]
+	if !ok {
+		return ErrNotFound
+	}
+	g.Access = access
+	s.m[id] = g
+	return nil
+}
+
+func (s *memGrantStore) SetExpiry(_ context.Context, ids []string, expiresAt *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		if _, ok := s.m[id]; !ok {
+			return ErrNotFound
+		}
+	}
+	for _, id := range ids {
+		g := s.m[id]
+		g.ExpiresAt = expiresAt
+		s.m[id] = g
+	}
+	return nil
+}
+
+type memCredentialGrantStore struct {
+	mu sync.RWMutex
+	m  map[string]models.CredentialGrant
+}
+
+func (s *memCredentialGrantStore) Create(_ context.Context, g *models.CredentialGrant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.m {
+		if existing.CredentialID == g.CredentialID && existing.SubjectID == g.SubjectID {
+			return models.ErrConflict
+		}
+	}
+	s.m[g.ID] = *g
+	return nil
+}
+
+func (s *memCredentialGrantStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, id)
+	return nil
 }
 
 func (s *memCredentialGrantStore) Has(_ context.Context, credentialID, subjectID string) (bool, error) {
@@ -805,15 +1257,65 @@ func (s *memCredentialGrantStore) ListBySubject(_ context.Context, subjectID str
 type memAuditStore struct {
 	mu      sync.RWMutex
 	entries []models.AuditEntry
+	// seqs stands in for the unique index on Seq.
+	seqs map[int64]bool
+}
+
+func (s *memAuditStore) Append(ctx context.Context, e *models.AuditEntry) error {
+	return s.AppendBatch(ctx, []*models.AuditEntry{e})
 }
 
-func (s *memAuditStore) Append(_ context.Context, e *models.AuditEntry) error {
+func (s *memAuditStore) AppendBatch(_ context.Context, entries []*models.AuditEntry) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.entries = append(s.entries, *e)
+	for _, e := range entries {
+		if e.Seq != nil && s.seqs[*e.Seq] {
+			return models.ErrConflict
+		}
+	}
+	for _, e := range entries {
+		if e.Seq != nil {
+			if s.seqs == nil {
+				s.seqs = map[int64]bool{}
+			}
+			s.seqs[*e.Seq] = true
+		}
+		s.entries = append(s.entries, *e)
+	}
 	return nil
 }
 
+func (s *memAuditStore) ChainHead(context.Context) (models.AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var head *models.AuditEntry
+	for i := range s.entries {
+		if e := &s.entries[i]; e.Seq != nil && (head == nil || *e.Seq > *head.Seq) {
+			head = e
+		}
+	}
+	if head == nil {
+		return models.AuditEntry{}, ErrNotFound
+	}
+	return *head, nil
+}
+
+func (s *memAuditStore) ListChain(_ context.Context, since time.Time, afterSeq int64, limit int) ([]models.AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []models.AuditEntry
+	for _, e := range s.entries {
+		if e.Seq != nil && *e.Seq > afterSeq && !e.Time.Before(since) {
+			out = append(out, e)
+		}
+	}
+	slices.SortFunc(out, func(a, b models.AuditEntry) int { return cmp.Compare(*a.Seq, *b.Seq) })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
 func (s *memAuditStore) matches(e models.AuditEntry, f AuditFilter) bool {
 	if f.UserID != "" && e.UserID != f.UserID {
 		return false
@@ -821,6 +1323,18 @@ func (s *memAuditStore) matches(e models.AuditEntry, f AuditFilter) bool {
 	if f.ConnectionID != "" && e.ConnectionID != f.ConnectionID {
 		return false
 	}
+	if f.ResourceType != "" && e.ResourceType != f.ResourceType {
+		return false
+	}
+	if f.ResourceID != "" && e.ResourceID != f.ResourceID {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
 	return true
 }
 
@@ -858,6 +1372,24 @@ func (s *memAuditStore) Count(_ context.Context, f AuditFilter) (int64, error) {
 	return n, nil
 }
 
+// ListUnresourced always returns nothing: the memory store never holds entries
+// from before the resource columns existed.
+func (s *memAuditStore) ListUnresourced(context.Context, int) ([]models.AuditEntry, error) {
+	return nil, nil
+}
+
+func (s *memAuditStore) SetResource(_ context.Context, id, resourceType, resourceID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.entries {
+		if s.entries[i].ID == id {
+			s.entries[i].ResourceType, s.entries[i].ResourceID = resourceType, resourceID
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
 func (s *memAuditStore) DeleteBefore(_ context.Context, before time.Time) (int64, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -1152,6 +1684,105 @@ func (s *memProtocolSettingStore) Set(_ context.Context, p *models.ProtocolSetti
 	return nil
 }
 
+type memSystemSettingStore struct {
+	mu sync.RWMutex
+	m  map[string]models.SystemSetting
+}
+
+func (s *memSystemSettingStore) Get(_ context.Context, key string) (models.SystemSetting, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.m[key]
+	if !ok {
+		return models.SystemSetting{}, ErrNotFound
+	}
+	return v, nil
+}
+
+func (s *memSystemSettingStore) List(_ context.Context) ([]models.SystemSetting, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]models.SystemSetting, 0, len(s.m))
+	for _, v := range s.m {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+func (s *memSystemSettingStore) Set(_ context.Context, v *models.SystemSetting) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *v
+	cp.UpdatedAt = time.Now()
+	s.m[v.Key] = cp
+	return nil
+}
+
+type memCredentialApprovalStore struct {
+	mu sync.Mutex
+	m  map[string]models.CredentialApproval
+}
+
+func (s *memCredentialApprovalStore) Create(_ context.Context, a *models.CredentialApproval) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.m[a.ID]; ok {
+		return models.ErrConflict
+	}
+	s.m[a.ID] = *a
+	return nil
+}
+
+func (s *memCredentialApprovalStore) Get(_ context.Context, id string) (models.CredentialApproval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.m[id]
+	if !ok {
+		return models.CredentialApproval{}, ErrNotFound
+	}
+	return a, nil
+}
+
+func (s *memCredentialApprovalStore) List(_ context.Context, f CredentialApprovalFilter) ([]models.CredentialApproval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []models.CredentialApproval
+	for _, a := range s.m {
+		if (f.CredentialID != "" && a.CredentialID != f.CredentialID) || (f.RequesterID != "" && a.RequesterID != f.RequesterID) ||
+			(f.Status != "" && a.Status != f.Status) || (len(f.CredentialIDs) > 0 && !slices.Contains(f.CredentialIDs, a.CredentialID)) {
+			continue
+		}
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (s *memCredentialApprovalStore) Approve(_ context.Context, id, approverID string, at, useBy time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.m[id]
+	if !ok || a.Status != models.ApprovalPending || !at.Before(a.ExpiresAt) {
+		return false, nil
+	}
+	a.Status, a.ApproverID, a.ApprovedAt, a.UseBy = models.ApprovalApproved, approverID, at, useBy
+	s.m[id] = a
+	return true, nil
+}
+
+func (s *memCredentialApprovalStore) Consume(_ context.Context, id string, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.m[id]
+	if !ok || a.Status != models.ApprovalApproved || !now.Before(a.UseBy) {
+		return false, nil
+	}
+	a.Status, a.UsedAt = models.ApprovalUsed, now
+	s.m[id] = a
+	return true, nil
+}
+
 type memInvitationStore struct {
 	mu sync.RWMutex
 	m  map[string]models.Invitation
@@ -1161,7 +1792,7 @@ func (s *memInvitationStore) Create(_ context.Context, i *models.Invitation) err
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	for _, existing := range s.m {
-		if existing.TokenHash == i.TokenHash {
+		if existing.TokenHash != nil && i.TokenHash != nil && *existing.TokenHash == *i.TokenHash {
 			return models.ErrConflict
 		}
 	}
@@ -1183,7 +1814,7 @@ func (s *memInvitationStore) GetByTokenHash(_ context.Context, tokenHash string)
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	for _, i := range s.m {
-		if i.TokenHash == tokenHash {
+		if i.TokenHash != nil && *i.TokenHash == tokenHash {
 			return i, nil
 		}
 	}
@@ -1220,10 +1851,26 @@ func (s *memInvitationStore) Consume(_ context.Context, id string, acceptedAt ti
 	}
 	i.Status = models.InviteAccepted
 	i.AcceptedAt = acceptedAt
+	i.TokenHash = nil
 	s.m[id] = i
 	return true, nil
 }
 
+func (s *memInvitationStore) ExpirePending(_ context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id, i := range s.m {
+		if i.Status == models.InvitePending && !now.Before(i.ExpiresAt) {
+			i.Status = models.InviteExpired
+			i.TokenHash = nil
+			s.m[id] = i
+			n++
+		}
+	}
+	return n, nil
+}
+
 func (s *memInvitationStore) Delete(_ context.Context, id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -1231,6 +1878,438 @@ func (s *memInvitationStore) Delete(_ context.Context, id string) error {
 	return nil
 }
 
+type memWebhookStore struct {
+	mu sync.RWMutex
+	m  map[string]models.Webhook
+}
+
+func (s *memWebhookStore) Create(_ context.Context, w *models.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.m[w.ID]; ok {
+		return models.ErrConflict
+	}
+	s.m[w.ID] = *w
+	return nil
+}
+
+func (s *memWebhookStore) Get(_ context.Context, id string) (models.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	w, ok := s.m[id]
+	if !ok {
+		return models.Webhook{}, ErrNotFound
+	}
+	return w, nil
+}
+
+func (s *memWebhookStore) List(_ context.Context) ([]models.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]models.Webhook, 0, len(s.m))
+	for _, w := range s.m {
+		out = append(out, w)
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].CreatedAt.Before(out[b].CreatedAt) })
+	return out, nil
+}
+
+func (s *memWebhookStore) Update(_ context.Context, w *models.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.m[w.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	cur.Name, cur.URL, cur.Events, cur.Enabled = w.Name, w.URL, w.Events, w.Enabled
+	cur.SecretCiphertext, cur.UpdatedAt = w.SecretCiphertext, w.UpdatedAt
+	s.m[w.ID] = cur
+	return nil
+}
+
+func (s *memWebhookStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, id)
+	return nil
+}
+
+// memWebhookDeliveryStore keeps each webhook's attempts in insertion order.
+type memWebhookDeliveryStore struct {
+	mu sync.Mutex
+	m  map[string][]models.WebhookDelivery
+}
+
+func (s *memWebhookDeliveryStore) Create(_ context.Context, d *models.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[d.WebhookID] = append(s.m[d.WebhookID], *d)
+	return nil
+}
+
+func (s *memWebhookDeliveryStore) List(_ context.Context, webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all := s.m[webhookID]
+	out := make([]models.WebhookDelivery, 0, min(limit, len(all)))
+	for i := len(all) - 1; i >= 0 && len(out) < limit; i-- {
+		out = append(out, all[i])
+	}
+	return out, nil
+}
+
+func (s *memWebhookDeliveryStore) Prune(_ context.Context, webhookID string, keep int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if all := s.m[webhookID]; len(all) > keep {
+		s.m[webhookID] = append([]models.WebhookDelivery(nil), all[len(all)-keep:]...)
+	}
+	return nil
+}
+
+func (s *memWebhookDeliveryStore) DeleteByWebhook(_ context.Context, webhookID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, webhookID)
+	return nil
+}
+
+type memSessionShareLinkStore struct {
+	mu sync.RWMutex
+	m  map[string]models.SessionShareLink
+}
+
+func (s *memSessionShareLinkStore) Create(_ context.Context, l *models.SessionShareLink) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.m {
+		if existing.TokenHash == l.TokenHash {
+			return models.ErrConflict
+		}
+	}
+	s.m[l.ID] = *l
+	return nil
+}
+
+func (s *memSessionShareLinkStore) Get(_ context.Context, id string) (models.SessionShareLink, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	l, ok := s.m[id]
+	if !ok {
+		return models.SessionShareLink{}, ErrNotFound
+	}
+	return l, nil
+}
+
+func (s *memSessionShareLinkStore) GetByTokenHash(_ context.Context, tokenHash string) (models.SessionShareLink, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, l := range s.m {
+		if l.TokenHash == tokenHash {
+			return l, nil
+		}
+	}
+	return models.SessionShareLink{}, ErrNotFound
+}
+
+func (s *memSessionShareLinkStore) ListByConnection(_ context.Context, connectionID string) ([]models.SessionShareLink, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []models.SessionShareLink
+	for _, l := range s.m {
+		if l.ConnectionID == connectionID {
+			out = append(out, l)
+		}
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].CreatedAt.After(out[b].CreatedAt) })
+	return out, nil
+}
+
+func (s *memSessionShareLinkStore) Consume(_ context.Context, id string, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.m[id]
+	if !ok || !l.Active(now) {
+		return false, nil
+	}
+	l.Uses++
+	s.m[id] = l
+	return true, nil
+}
+
+func (s *memSessionShareLinkStore) Revoke(_ context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.m[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if l.RevokedAt == nil {
+		l.RevokedAt = &at
+		s.m[id] = l
+	}
+	return nil
+}
+
+type memSessionParticipantStore struct {
+	mu sync.RWMutex
+	m  map[string]models.SessionParticipant
+}
+
+func (s *memSessionParticipantStore) Create(_ context.Context, p *models.SessionParticipant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, cur := range s.m {
+		if cur.ConnectionID == p.ConnectionID && cur.OwnerID == p.OwnerID && cur.UserID == p.UserID {
+			return models.ErrConflict
+		}
+	}
+	s.m[p.ID] = *p
+	return nil
+}
+
+func (s *memSessionParticipantStore) Get(_ context.Context, connectionID, ownerID, userID string) (models.SessionParticipant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, p := range s.m {
+		if p.ConnectionID == connectionID && p.OwnerID == ownerID && p.UserID == userID {
+			return p, nil
+		}
+	}
+	return models.SessionParticipant{}, ErrNotFound
+}
+
+func (s *memSessionParticipantStore) ListByConnection(_ context.Context, connectionID string) ([]models.SessionParticipant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []models.SessionParticipant
+	for _, p := range s.m {
+		if p.ConnectionID == connectionID {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].JoinedAt.Before(out[b].JoinedAt) })
+	return out, nil
+}
+
+func (s *memSessionParticipantStore) SetWriteHolder(_ context.Context, connectionID, ownerID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := userID == ""
+	for _, p := range s.m {
+		if p.ConnectionID == connectionID && p.OwnerID == ownerID && p.UserID == userID {
+			found = true
+		}
+	}
+	if !found {
+		return ErrNotFound
+	}
+	for id, p := range s.m {
+		if p.ConnectionID == connectionID && p.OwnerID == ownerID {
+			p.WriteHolder = userID != "" && p.UserID == userID
+			s.m[id] = p
+		}
+	}
+	return nil
+}
+
+func (s *memSessionParticipantStore) DeleteBySession(_ context.Context, connectionID, ownerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, p := range s.m {
+		if p.ConnectionID == connectionID && p.OwnerID == ownerID {
+			delete(s.m, id)
+		}
+	}
+	return nil
+}
+
+func (s *memSessionParticipantStore) DeleteByLink(_ context.Context, linkID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, p := range s.m {
+		if p.ShareLinkID == linkID {
+			delete(s.m, id)
+		}
+	}
+	return nil
+}
+
+type memSessionWriteRequestStore struct {
+	mu sync.RWMutex
+	m  map[string]models.SessionWriteRequest
+}
+
+func (s *memSessionWriteRequestStore) Create(_ context.Context, r *models.SessionWriteRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.m[r.ID]; ok {
+		return models.ErrConflict
+	}
+	s.m[r.ID] = *r
+	return nil
+}
+
+func (s *memSessionWriteRequestStore) Get(_ context.Context, id string) (models.SessionWriteRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.m[id]
+	if !ok {
+		return models.SessionWriteRequest{}, ErrNotFound
+	}
+	return r, nil
+}
+
+func (s *memSessionWriteRequestStore) ListPending(_ context.Context, connectionID string, now time.Time) ([]models.SessionWriteRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []models.SessionWriteRequest
+	for _, r := range s.m {
+		if r.ConnectionID == connectionID && r.Status == models.WriteRequestPending && r.ExpiresAt.After(now) {
+			out = append(out, r)
+		}
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].CreatedAt.Before(out[b].CreatedAt) })
+	return out, nil
+}
+
+func (s *memSessionWriteRequestStore) Decide(_ context.Context, id string, status models.WriteRequestStatus, decidedBy, reason string, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.m[id]
+	if !ok || r.Status != models.WriteRequestPending {
+		return false, nil
+	}
+	r.Status, r.DecidedBy, r.Reason, r.DecidedAt = status, decidedBy, reason, at
+	s.m[id] = r
+	return true, nil
+}
+
+func (s *memSessionWriteRequestStore) DeleteBySession(_ context.Context, connectionID, ownerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, r := range s.m {
+		if r.ConnectionID == connectionID && r.OwnerID == ownerID {
+			delete(s.m, id)
+		}
+	}
+	return nil
+}
+
+type memConnectionSessionStore struct {
+	mu sync.RWMutex
+	m  map[string]models.ConnectionSession
+}
+
+func (s *memConnectionSessionStore) Create(_ context.Context, cs *models.ConnectionSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.m[cs.ID]; ok {
+		return models.ErrConflict
+	}
+	s.m[cs.ID] = *cs
+	return nil
+}
+
+func (s *memConnectionSessionStore) Get(_ context.Context, id string) (models.ConnectionSession, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cs, ok := s.m[id]
+	if !ok {
+		return models.ConnectionSession{}, ErrNotFound
+	}
+	return cs, nil
+}
+
+func (s *memConnectionSessionStore) UpdateTraffic(_ context.Context, id string, bytesIn, bytesOut int64, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cs, ok := s.m[id]
+	if !ok {
+		return ErrNotFound
+	}
+	cs.BytesIn, cs.BytesOut, cs.UpdatedAt = bytesIn, bytesOut, at
+	s.m[id] = cs
+	return nil
+}
+
+func (s *memConnectionSessionStore) End(_ context.Context, id string, status models.ConnectionSessionStatus, reason string, bytesIn, bytesOut int64, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cs, ok := s.m[id]
+	if !ok {
+		return ErrNotFound
+	}
+	cs.Status, cs.Reason, cs.EndedAt = status, reason, &at
+	cs.BytesIn, cs.BytesOut, cs.UpdatedAt = bytesIn, bytesOut, at
+	s.m[id] = cs
+	return nil
+}
+
+func (s *memConnectionSessionStore) ListStale(_ context.Context, before time.Time) ([]models.ConnectionSession, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := []models.ConnectionSession{}
+	for _, cs := range s.m {
+		if cs.Status == models.ConnectionSessionActive && cs.UpdatedAt.Before(before) {
+			out = append(out, cs)
+		}
+	}
+	slices.SortFunc(out, func(a, b models.ConnectionSession) int { return a.StartedAt.Compare(b.StartedAt) })
+	return out, nil
+}
+
+type memSessionObservationStore struct {
+	mu sync.RWMutex
+	m  map[string]models.SessionObservation
+}
+
+func (s *memSessionObservationStore) Create(_ context.Context, o *models.SessionObservation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.m[o.ID]; ok {
+		return models.ErrConflict
+	}
+	s.m[o.ID] = *o
+	return nil
+}
+
+func (s *memSessionObservationStore) Get(_ context.Context, id string) (models.SessionObservation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	o, ok := s.m[id]
+	if !ok {
+		return models.SessionObservation{}, ErrNotFound
+	}
+	return o, nil
+}
+
+func (s *memSessionObservationStore) End(_ context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.m[id]
+	if !ok {
+		return ErrNotFound
+	}
+	o.EndedAt = &at
+	s.m[id] = o
+	return nil
+}
+
+func (s *memSessionObservationStore) EndBySession(_ context.Context, sessionID string, at time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for id, o := range s.m {
+		if o.SessionID == sessionID && o.EndedAt == nil {
+			o.EndedAt = &at
+			s.m[id] = o
+			n++
+		}
+	}
+	return n, nil
+}
+
 type memEnrollmentStore struct {
 	mu sync.RWMutex
 	m  map[string]models.AgentEnrollment
@@ -1369,8 +2448,15 @@ func (s *memRecordingStore) Update(_ context.Context, r *models.Recording) error
 	prev.Size = r.Size
 	prev.Checksum = r.Checksum
 	prev.StorageKey = r.StorageKey
+	prev.TranscriptKey = r.TranscriptKey
 	prev.Error = r.Error
+	prev.Partial = r.Partial
 	prev.ExpiresAt = r.ExpiresAt
+	prev.Annotations = append([]models.RecordingAnnotation(nil), r.Annotations...)
+	prev.Chapters = append([]models.RecordingChapter(nil), r.Chapters...)
+	prev.ChapterGapMS = r.ChapterGapMS
+	prev.Protected, prev.ProtectedBy, prev.ProtectedAt = r.Protected, r.ProtectedBy, r.ProtectedAt
+	prev.ArchivedAt = r.ArchivedAt
 	prev.UpdatedAt = time.Now()
 	s.m[r.ID] = prev
 	return nil
@@ -1393,7 +2479,24 @@ func (s *memRecordingStore) List(_ context.Context, f RecordingFilter) ([]models
 		}
 		out = append(out, r)
 	}
-	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.After(out[j].StartedAt) })
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		switch {
+		case f.Sort == RecordingSortDuration && a.DurationMS != b.DurationMS:
+			return a.DurationMS > b.DurationMS
+		case f.Sort == RecordingSortConnection && a.ConnectionName != b.ConnectionName:
+			return a.ConnectionName < b.ConnectionName
+		case !a.StartedAt.Equal(b.StartedAt):
+			return a.StartedAt.After(b.StartedAt)
+		}
+		return a.ID < b.ID
+	})
+	if f.Offset > 0 {
+		if f.Offset >= len(out) {
+			return nil, nil
+		}
+		out = out[f.Offset:]
+	}
 	if f.Limit > 0 && len(out) > f.Limit {
 		out = out[:f.Limit]
 	}
@@ -1420,6 +2523,138 @@ func recordingMatches(r models.Recording, f RecordingFilter) bool {
 		return false
 	case !f.ExpiredBefore.IsZero() && (r.ExpiresAt == nil || r.ExpiresAt.After(f.ExpiredBefore)):
 		return false
+	case !f.ArchivableBefore.IsZero() && (r.ArchivedAt != nil || r.EndedAt == nil || r.EndedAt.After(f.ArchivableBefore)):
+		return false
+	case f.Search != "" && !containsFold(r.ConnectionName, f.Search) && !containsFold(r.Username, f.Search):
+		return false
 	}
 	return true
 }
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+type memCredentialAccessLogStore struct {
+	mu      sync.RWMutex
+	entries []models.CredentialAccessLog
+}
+
+func (s *memCredentialAccessLogStore) Append(_ context.Context, e *models.CredentialAccessLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, *e)
+	return nil
+}
+
+func (s *memCredentialAccessLogStore) List(_ context.Context, f CredentialAccessFilter) ([]models.CredentialAccessLog, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []models.CredentialAccessLog
+	skipped := 0
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		e := s.entries[i]
+		if f.CredentialID != "" && e.CredentialID != f.CredentialID {
+			continue
+		}
+		if f.Offset > 0 && skipped < f.Offset {
+			skipped++
+			continue
+		}
+		out = append(out, e)
+		if f.Limit > 0 && len(out) >= f.Limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+func (s *memCredentialAccessLogStore) Count(_ context.Context, f CredentialAccessFilter) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var n int64
+	for _, e := range s.entries {
+		if f.CredentialID == "" || e.CredentialID == f.CredentialID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *memCredentialAccessLogStore) DeleteBefore(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var kept []models.CredentialAccessLog
+	var removed int64
+	for _, e := range s.entries {
+		if e.Time.Before(before) {
+			removed++
+			continue
+		}
+		kept = append(kept, e)
+	}
+	s.entries = kept
+	return removed, nil
+}
+
+type memActivityStore struct {
+	audit      *memAuditStore
+	recordings *memRecordingStore
+	access     *memCredentialAccessLogStore
+}
+
+func (s *memActivityStore) Summarize(_ context.Context, q ActivityQuery) (ActivitySummary, error) {
+	var out ActivitySummary
+	days := map[string]int64{}
+	conns := map[string]int64{}
+	users := map[string]bool{}
+	s.audit.mu.RLock()
+	for _, e := range s.audit.entries {
+		if e.Time.Before(q.Since) || e.Result != models.AuditAllowed {
+			continue
+		}
+		if e.UserID != "" {
+			users[e.UserID] = true
+		}
+		if e.Event == q.SessionEvent {
+			days[e.Time.UTC().Format(time.DateOnly)]++
+			conns[e.ConnectionID]++
+		}
+	}
+	s.audit.mu.RUnlock()
+	out.ActiveUsers = int64(len(users))
+	for day, n := range days {
+		out.SessionsPerDay = append(out.SessionsPerDay, DayCount{Day: day, Count: n})
+	}
+	sort.Slice(out.SessionsPerDay, func(i, j int) bool { return out.SessionsPerDay[i].Day < out.SessionsPerDay[j].Day })
+	for id, n := range conns {
+		out.TopConnections = append(out.TopConnections, ConnectionCount{ConnectionID: id, Count: n})
+	}
+	sort.Slice(out.TopConnections, func(i, j int) bool {
+		a, b := out.TopConnections[i], out.TopConnections[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.ConnectionID < b.ConnectionID
+	})
+	if q.Top > 0 && len(out.TopConnections) > q.Top {
+		out.TopConnections = out.TopConnections[:q.Top]
+	}
+
+	s.recordings.mu.RLock()
+	for _, r := range s.recordings.m {
+		if !r.StartedAt.Before(q.Since) {
+			out.RecordingBytes += r.Size
+		}
+	}
+	s.recordings.mu.RUnlock()
+
+	s.access.mu.RLock()
+	for _, e := range s.access.entries {
+		if !e.Time.Before(q.Since) && e.Result == models.AuditAllowed {
+			out.CredentialRetrievals++
+		}
+	}
+	s.access.mu.RUnlock()
+	return out, nil
+}
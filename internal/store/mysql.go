@@ -0,0 +1,78 @@
+package store
+
+import (
+	"fmt"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// mysqlStringSize is the varchar length of an indexed string column; 191
+	// utf8mb4 characters is the longest that fits a pre-5.7 index prefix.
+	mysqlStringSize = 191
+	// mysqlKeyChars is the InnoDB key limit (3072 bytes) in utf8mb4 characters,
+	// shared by every string column of one index.
+	mysqlKeyChars = 768
+)
+
+// mysqlDSN makes the driver scan DATETIME columns into time.Time, which the
+// stores rely on and the driver does not do by default.
+func mysqlDSN(dsn string) (string, error) {
+	cfg, err := gomysql.ParseDSN(dsn)
+	if err != nil {
+		return "", fmt.Errorf("parse mysql dsn: %w", err)
+	}
+	cfg.ParseTime = true
+	return cfg.FormatDSN(), nil
+}
+
+// sizeIndexedStrings gives every unsized string column in a key a varchar
+// length. gorm maps such columns to longtext on MySQL, which cannot be indexed,
+// and a composite key must stay within the InnoDB key limit. The sizes are set
+// on the cached schemas, so AutoMigrate picks them up.
+func sizeIndexedStrings(db *gorm.DB, models []any) error {
+	for _, m := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(m); err != nil {
+			return fmt.Errorf("parse %T: %w", m, err)
+		}
+		keys := [][]*schema.Field{stmt.Schema.PrimaryFields}
+		for _, idx := range stmt.Schema.ParseIndexes() {
+			fields := make([]*schema.Field, 0, len(idx.Fields))
+			for _, opt := range idx.Fields {
+				fields = append(fields, opt.Field)
+			}
+			keys = append(keys, fields)
+		}
+		sizes := map[*schema.Field]int{}
+		for _, key := range keys {
+			var strs []*schema.Field
+			for _, f := range key {
+				if f.DataType == schema.String && f.Size == 0 {
+					strs = append(strs, f)
+				}
+			}
+			for _, f := range strs {
+				size := min(mysqlStringSize, mysqlKeyChars/len(strs))
+				if cur, ok := sizes[f]; !ok || size < cur {
+					sizes[f] = size
+				}
+			}
+		}
+		for f, size := range sizes {
+			f.Size = size
+		}
+	}
+	return nil
+}
+
+// likeEscape is the ESCAPE clause for patterns built by escapeSQLLike. MySQL
+// reads '\' as an unterminated literal, but backslash is its default escape.
+func likeEscape(db *gorm.DB) string {
+	if db.Dialector.Name() == "mysql" {
+		return ""
+	}
+	return ` ESCAPE '\'`
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"gorm.io/gorm"
+
+	"github.com/charlesng35/shellcn/internal/models"
+)
+
+// OrphanCategory names a kind of row whose referenced record can disappear.
+type OrphanCategory string
+
+const (
+	// OrphanGrantConnection is a connection grant whose connection was purged.
+	OrphanGrantConnection OrphanCategory = "grant_connection"
+	// OrphanGrantSubject is a connection grant whose user was deleted.
+	OrphanGrantSubject OrphanCategory = "grant_subject"
+	// OrphanCredentialGrantCredential is a credential grant whose credential
+	// was deleted.
+	OrphanCredentialGrantCredential OrphanCategory = "credential_grant_credential"
+	// OrphanCredentialGrantSubject is a credential grant whose user was deleted.
+	OrphanCredentialGrantSubject OrphanCategory = "credential_grant_subject"
+)
+
+// OrphanCategories lists every category in sweep order.
+var OrphanCategories = []OrphanCategory{
+	OrphanGrantConnection, OrphanGrantSubject,
+	OrphanCredentialGrantCredential, OrphanCredentialGrantSubject,
+}
+
+// OrphanStore removes rows left behind when the record they reference was
+// deleted. A trashed connection still exists, so its grants are kept.
+type OrphanStore interface {
+	// Purge deletes up to limit orphans of one category and returns how many
+	// went; fewer than limit means the category is clean.
+	Purge(ctx context.Context, c OrphanCategory, limit int) (int64, error)
+}
+
+type orphanRef struct {
+	model  any
+	table  string
+	column string
+	ref    string
+}
+
+var orphanRefs = map[OrphanCategory]orphanRef{
+	OrphanGrantConnection:           {&models.Grant{}, "grants", "connection_id", "connections"},
+	OrphanGrantSubject:              {&models.Grant{}, "grants", "subject_id", "users"},
+	OrphanCredentialGrantCredential: {&models.CredentialGrant{}, "credential_grants", "credential_id", "credentials"},
+	OrphanCredentialGrantSubject:    {&models.CredentialGrant{}, "credential_grants", "subject_id", "users"},
+}
+
+type gormOrphanStore struct{ db *gorm.DB }
+
+// Purge picks a batch of ids first and deletes by id, since MySQL refuses a
+// LIMIT inside IN and a DELETE that selects from its own table.
+func (s *gormOrphanStore) Purge(ctx context.Context, c OrphanCategory, limit int) (int64, error) {
+	r, ok := orphanRefs[c]
+	if !ok {
+		return 0, fmt.Errorf("store: unknown orphan category %q", c)
+	}
+	missing := fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s r WHERE r.id = %s.%s)", r.ref, r.table, r.column)
+	var ids []string
+	if err := s.db.WithContext(ctx).Model(r.model).Where(missing).Order("id").Limit(limit).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	res := s.db.WithContext(ctx).Where("id IN ?", ids).Where(missing).Delete(r.model)
+	return res.RowsAffected, res.Error
+}
+
+type memOrphanStore struct {
+	users      *memUserStore
+	conns      *memConnectionStore
+	creds      *memCredentialStore
+	grants     *memGrantStore
+	credGrants *memCredentialGrantStore
+}
+
+func (s *memOrphanStore) Purge(_ context.Context, c OrphanCategory, limit int) (int64, error) {
+	s.users.mu.RLock()
+	s.conns.mu.RLock()
+	s.creds.mu.RLock()
+	defer s.users.mu.RUnlock()
+	defer s.conns.mu.RUnlock()
+	defer s.creds.mu.RUnlock()
+	userGone := func(id string) bool { _, ok := s.users.users[id]; return !ok }
+	switch c {
+	case OrphanGrantConnection, OrphanGrantSubject:
+		gone := func(g models.Grant) bool { return userGone(g.SubjectID) }
+		if c == OrphanGrantConnection {
+			gone = func(g models.Grant) bool { _, ok := s.conns.m[g.ConnectionID]; return !ok }
+		}
+		s.grants.mu.Lock()
+		defer s.grants.mu.Unlock()
+		return purgeMem(s.grants.m, gone, limit), nil
+	case OrphanCredentialGrantCredential, OrphanCredentialGrantSubject:
+		gone := func(g models.CredentialGrant) bool { return userGone(g.SubjectID) }
+		if c == OrphanCredentialGrantCredential {
+			gone = func(g models.CredentialGrant) bool { _, ok := s.creds.m[g.CredentialID]; return !ok }
+		}
+		s.credGrants.mu.Lock()
+		defer s.credGrants.mu.Unlock()
+		return purgeMem(s.credGrants.m, gone, limit), nil
+	}
+	return 0, fmt.Errorf("store: unknown orphan category %q", c)
+}
+
+func purgeMem[T any](m map[string]T, gone func(T) bool, limit int) int64 {
+	var ids []string
+	for id, v := range m {
+		if gone(v) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	for _, id := range ids {
+		delete(m, id)
+	}
+	return int64(len(ids))
+}
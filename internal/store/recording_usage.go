@@ -0,0 +1,176 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/charlesng35/shellcn/internal/models"
+)
+
+// aggTime scans MIN/MAX over a timestamp column. SQLite drops the column type
+// on aggregates and returns text, which database/sql will not scan into a
+// time.Time.
+type aggTime struct{ time.Time }
+
+var aggTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+}
+
+func (t *aggTime) Scan(v any) error {
+	switch v := v.(type) {
+	case nil:
+		t.Time = time.Time{}
+		return nil
+	case time.Time:
+		t.Time = v
+		return nil
+	case []byte:
+		return t.parse(string(v))
+	case string:
+		return t.parse(v)
+	}
+	return fmt.Errorf("store: cannot scan %T into a time", v)
+}
+
+// Value lets gorm treat aggTime as a column rather than a relation.
+func (t aggTime) Value() (driver.Value, error) { return t.Time, nil }
+
+func (t *aggTime) parse(s string) error {
+	for _, layout := range aggTimeLayouts {
+		if parsed, err := time.Parse(layout, s); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("store: unrecognised time %q", s)
+}
+
+type usageRow struct {
+	ID               string
+	Name             string
+	Bytes            int64
+	Count            int64
+	Oldest           aggTime
+	Newest           aggTime
+	ReclaimableBytes int64
+	ReclaimableCount int64
+}
+
+func (r usageRow) group() RecordingUsageGroup {
+	return RecordingUsageGroup{
+		ID: r.ID, Name: r.Name, Bytes: r.Bytes, Count: r.Count,
+		Oldest: r.Oldest.Time, Newest: r.Newest.Time,
+		ReclaimableBytes: r.ReclaimableBytes, ReclaimableCount: r.ReclaimableCount,
+	}
+}
+
+// usageSelect sums size and retention-expired size; active and protected
+// recordings are never reclaimable because cleanup skips them.
+const usageSelect = "COALESCE(SUM(size), 0) AS bytes, COUNT(*) AS count, " +
+	"MIN(started_at) AS oldest, MAX(started_at) AS newest, " +
+	"COALESCE(SUM(CASE WHEN expires_at IS NOT NULL AND expires_at <= @now AND status <> @active AND NOT protected THEN size ELSE 0 END), 0) AS reclaimable_bytes, " +
+	"COALESCE(SUM(CASE WHEN expires_at IS NOT NULL AND expires_at <= @now AND status <> @active AND NOT protected THEN 1 ELSE 0 END), 0) AS reclaimable_count"
+
+func (s *gormRecordingStore) Usage(ctx context.Context, q RecordingUsageQuery) (RecordingUsage, error) {
+	args := map[string]any{"now": q.Now, "active": models.RecordingActive}
+	held := func() *gorm.DB {
+		return s.db.WithContext(ctx).Model(&models.Recording{}).Where("status <> ?", models.RecordingDiscarded)
+	}
+	var out RecordingUsage
+	var total usageRow
+	if err := held().Select(usageSelect, args).Scan(&total).Error; err != nil {
+		return out, err
+	}
+	out.Total = total.group()
+	by := func(idCol, nameCol string) ([]RecordingUsageGroup, error) {
+		var rows []usageRow
+		qq := held().Select(idCol+" AS id, MAX("+nameCol+") AS name, "+usageSelect, args).
+			Group(idCol).Order("bytes DESC, " + idCol)
+		if q.Top > 0 {
+			qq = qq.Limit(q.Top)
+		}
+		if err := qq.Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		groups := make([]RecordingUsageGroup, 0, len(rows))
+		for _, r := range rows {
+			groups = append(groups, r.group())
+		}
+		return groups, nil
+	}
+	var err error
+	if out.ByUser, err = by("user_id", "username"); err != nil {
+		return out, err
+	}
+	out.ByConnection, err = by("connection_id", "connection_name")
+	return out, err
+}
+
+func (s *memRecordingStore) Usage(_ context.Context, q RecordingUsageQuery) (RecordingUsage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out RecordingUsage
+	users := map[string]*RecordingUsageGroup{}
+	conns := map[string]*RecordingUsageGroup{}
+	add := func(g *RecordingUsageGroup, r models.Recording) {
+		g.Bytes += r.Size
+		g.Count++
+		if g.Oldest.IsZero() || r.StartedAt.Before(g.Oldest) {
+			g.Oldest = r.StartedAt
+		}
+		if r.StartedAt.After(g.Newest) {
+			g.Newest = r.StartedAt
+		}
+		if r.ExpiresAt != nil && !r.ExpiresAt.After(q.Now) && r.Status != models.RecordingActive && !r.Protected {
+			g.ReclaimableBytes += r.Size
+			g.ReclaimableCount++
+		}
+	}
+	group := func(m map[string]*RecordingUsageGroup, id, name string) *RecordingUsageGroup {
+		g, ok := m[id]
+		if !ok {
+			g = &RecordingUsageGroup{ID: id}
+			m[id] = g
+		}
+		g.Name = max(g.Name, name)
+		return g
+	}
+	for _, r := range s.m {
+		if r.Status == models.RecordingDiscarded {
+			continue
+		}
+		add(&out.Total, r)
+		add(group(users, r.UserID, r.Username), r)
+		add(group(conns, r.ConnectionID, r.ConnectionName), r)
+	}
+	out.ByUser = topUsage(users, q.Top)
+	out.ByConnection = topUsage(conns, q.Top)
+	return out, nil
+}
+
+func topUsage(m map[string]*RecordingUsageGroup, top int) []RecordingUsageGroup {
+	out := make([]RecordingUsageGroup, 0, len(m))
+	for _, g := range m {
+		out = append(out, *g)
+	}
+	slices.SortFunc(out, func(a, b RecordingUsageGroup) int {
+		if a.Bytes != b.Bytes {
+			return cmp.Compare(b.Bytes, a.Bytes)
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	if top > 0 && len(out) > top {
+		out = out[:top]
+	}
+	return out
+}
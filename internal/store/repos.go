@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"strings"
 	"sync"
@@ -78,14 +79,24 @@ func (s *gormUserStore) GetPasswordHash(ctx context.Context, userID string) (str
 	return u.PasswordHash, nil
 }
 
-func (s *gormUserStore) SetPasswordHash(ctx context.Context, userID, hash string) error {
+func (s *gormUserStore) SetPasswordHash(ctx context.Context, userID, hash string, at time.Time) error {
 	res := s.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Updates(map[string]any{
-		"password_hash":   hash,
-		"session_version": gorm.Expr("COALESCE(session_version, 0) + ?", 1),
+		"password_hash":        hash,
+		"password_changed_at":  at,
+		"must_change_password": false,
+		"session_version":      gorm.Expr("COALESCE(session_version, 0) + ?", 1),
 	})
 	return rowsOrNotFound(res)
 }
 
+func (s *gormUserStore) RequirePasswordChange(ctx context.Context, before time.Time) (int, error) {
+	res := s.db.WithContext(ctx).Model(&models.User{}).
+		Where("password_hash <> '' AND must_change_password = ?", false).
+		Where("password_changed_at IS NULL OR password_changed_at < ?", before).
+		Update("must_change_password", true)
+	return int(res.RowsAffected), res.Error
+}
+
 func (s *gormUserStore) List(ctx context.Context) ([]models.User, error) {
 	var users []models.User
 	if err := s.db.WithContext(ctx).
@@ -127,15 +138,19 @@ func (s *gormUserStore) Count(ctx context.Context) (int64, error) {
 	return n, err
 }
 
-type gormConnectionStore struct{ db *gorm.DB }
+type gormConnectionStore struct {
+	db   *gorm.DB
+	keys *collation
+}
 
 func (s *gormConnectionStore) Create(ctx context.Context, c *models.Connection) error {
+	c.NameSort = s.keys.key(c.Name)
 	return s.db.WithContext(ctx).Create(c).Error
 }
 
 func (s *gormConnectionStore) Get(ctx context.Context, id string) (models.Connection, error) {
 	var c models.Connection
-	if err := s.db.WithContext(ctx).First(&c, "id = ?", id).Error; err != nil {
+	if err := s.db.WithContext(ctx).First(&c, "id = ? AND deleted_at IS NULL", id).Error; err != nil {
 		return models.Connection{}, normNotFound(err)
 	}
 	return c, nil
@@ -143,7 +158,7 @@ func (s *gormConnectionStore) Get(ctx context.Context, id string) (models.Connec
 
 func (s *gormConnectionStore) ListByOwner(ctx context.Context, ownerID string) ([]models.Connection, error) {
 	var list []models.Connection
-	if err := s.db.WithContext(ctx).Where("owner_id = ?", ownerID).Order("name").Find(&list).Error; err != nil {
+	if err := s.db.WithContext(ctx).Where("owner_id = ? AND deleted_at IS NULL", ownerID).Order("name_sort, name").Find(&list).Error; err != nil {
 		return nil, err
 	}
 	return list, nil
@@ -151,15 +166,16 @@ func (s *gormConnectionStore) ListByOwner(ctx context.Context, ownerID string) (
 
 func (s *gormConnectionStore) List(ctx context.Context) ([]models.Connection, error) {
 	var list []models.Connection
-	if err := s.db.WithContext(ctx).Order("name").Find(&list).Error; err != nil {
+	if err := s.db.WithContext(ctx).Where("deleted_at IS NULL").Order("name_sort, name").Find(&list).Error; err != nil {
 		return nil, err
 	}
 	return list, nil
 }
 
 func (s *gormConnectionStore) Update(ctx context.Context, c *models.Connection) error {
-	res := s.db.WithContext(ctx).Model(&models.Connection{}).Where("id = ?", c.ID).
-		Select("name", "protocol", "transport", "shared", "config", "secrets", "recording", "retention_days", "ai_mode", "ai_allow_destructive", "ai_auto_approve").Updates(c)
+	c.NameSort = s.keys.key(c.Name)
+	res := s.db.WithContext(ctx).Model(&models.Connection{}).Where("id = ? AND deleted_at IS NULL", c.ID).
+		Select("name", "name_sort", "protocol", "transport", "shared", "config", "config_version", "secrets", "recording", "retention_days", "ai_mode", "ai_allow_destructive", "ai_auto_approve").Updates(c)
 	return rowsOrNotFound(res)
 }
 
@@ -167,9 +183,60 @@ func (s *gormConnectionStore) Delete(ctx context.Context, id string) error {
 	return s.db.WithContext(ctx).Delete(&models.Connection{}, "id = ?", id).Error
 }
 
-type gormConnectionFolderStore struct{ db *gorm.DB }
+func (s *gormConnectionStore) Trash(ctx context.Context, id string, at time.Time) error {
+	res := s.db.WithContext(ctx).Model(&models.Connection{}).Where("id = ? AND deleted_at IS NULL", id).
+		Update("deleted_at", at)
+	return rowsOrNotFound(res)
+}
+
+func (s *gormConnectionStore) GetTrashed(ctx context.Context, id string) (models.Connection, error) {
+	var c models.Connection
+	if err := s.db.WithContext(ctx).First(&c, "id = ? AND deleted_at IS NOT NULL", id).Error; err != nil {
+		return models.Connection{}, normNotFound(err)
+	}
+	return c, nil
+}
+
+func (s *gormConnectionStore) ListTrashed(ctx context.Context, f TrashFilter) ([]models.Connection, error) {
+	q := s.db.WithContext(ctx).Where("deleted_at IS NOT NULL")
+	if f.OwnerID != "" {
+		q = q.Where("owner_id = ?", f.OwnerID)
+	}
+	if !f.TrashedBefore.IsZero() {
+		q = q.Where("deleted_at < ?", f.TrashedBefore)
+	}
+	var list []models.Connection
+	if err := q.Order("deleted_at DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *gormConnectionStore) Restore(ctx context.Context, id, name string) error {
+	res := s.db.WithContext(ctx).Model(&models.Connection{}).Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]any{"name": name, "name_sort": s.keys.key(name), "deleted_at": nil})
+	return rowsOrNotFound(res)
+}
+
+func (s *gormConnectionStore) MarkUsed(ctx context.Context, id string, at time.Time) error {
+	res := s.db.WithContext(ctx).Model(&models.Connection{}).Where("id = ? AND deleted_at IS NULL", id).
+		UpdateColumn("last_used_at", at)
+	return rowsOrNotFound(res)
+}
+
+func (s *gormConnectionStore) SetOwner(ctx context.Context, id, ownerID string, at time.Time) error {
+	res := s.db.WithContext(ctx).Model(&models.Connection{}).Where("id = ? AND deleted_at IS NULL", id).
+		Updates(map[string]any{"owner_id": ownerID, "updated_at": at})
+	return rowsOrNotFound(res)
+}
+
+type gormConnectionFolderStore struct {
+	db   *gorm.DB
+	keys *collation
+}
 
 func (s *gormConnectionFolderStore) Create(ctx context.Context, f *models.ConnectionFolder) error {
+	f.NameSort = s.keys.key(f.Name)
 	return s.db.WithContext(ctx).Create(f).Error
 }
 
@@ -183,15 +250,24 @@ func (s *gormConnectionFolderStore) Get(ctx context.Context, id string) (models.
 
 func (s *gormConnectionFolderStore) ListByUser(ctx context.Context, userID string) ([]models.ConnectionFolder, error) {
 	var list []models.ConnectionFolder
-	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("sort_order, name").Find(&list).Error; err != nil {
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("sort_order, name_sort, name").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *gormConnectionFolderStore) List(ctx context.Context) ([]models.ConnectionFolder, error) {
+	var list []models.ConnectionFolder
+	if err := s.db.WithContext(ctx).Order("user_id, sort_order, name_sort, name").Find(&list).Error; err != nil {
 		return nil, err
 	}
 	return list, nil
 }
 
 func (s *gormConnectionFolderStore) Update(ctx context.Context, f *models.ConnectionFolder) error {
+	f.NameSort = s.keys.key(f.Name)
 	res := s.db.WithContext(ctx).Model(&models.ConnectionFolder{}).Where("id = ?", f.ID).
-		Select("parent_id", "name", "color", "sort_order", "updated_at").Updates(f)
+		Select("parent_id", "name", "name_sort", "color", "sort_order", "updated_at").Updates(f)
 	return rowsOrNotFound(res)
 }
 
@@ -234,9 +310,105 @@ func (s *gormConnectionPlacementStore) MoveFolder(ctx context.Context, userID, f
 		Updates(map[string]any{"folder_id": targetFolderID, "updated_at": time.Now()}).Error
 }
 
-type gormCredentialStore struct{ db *gorm.DB }
+type gormConnectionNameStore struct{ db *gorm.DB }
+
+func (s *gormConnectionNameStore) Claim(ctx context.Context, n *models.ConnectionName) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.ConnectionName{}, "connection_id = ?", n.ConnectionID).Error; err != nil {
+			return err
+		}
+		return tx.Create(n).Error
+	})
+	if err == nil {
+		return nil
+	}
+	// Drivers word unique violations differently; a holder that is now
+	// visible is what makes this a conflict.
+	var held int64
+	if s.db.WithContext(ctx).Model(&models.ConnectionName{}).
+		Where("owner_id = ? AND folder_id = ? AND name_hash = ? AND connection_id <> ?", n.OwnerID, n.FolderID, n.NameHash, n.ConnectionID).
+		Count(&held).Error == nil && held > 0 {
+		return models.ErrConflict
+	}
+	return err
+}
+
+func (s *gormConnectionNameStore) Release(ctx context.Context, connectionID string) error {
+	return s.db.WithContext(ctx).Delete(&models.ConnectionName{}, "connection_id = ?", connectionID).Error
+}
+
+func (s *gormConnectionNameStore) Reset(ctx context.Context, list []models.ConnectionName) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.ConnectionName{}).Error; err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(list, 100).Error
+	})
+}
+
+type gormConnectionFavoriteStore struct{ db *gorm.DB }
+
+func (s *gormConnectionFavoriteStore) ListByUser(ctx context.Context, userID string) ([]models.ConnectionFavorite, error) {
+	var list []models.ConnectionFavorite
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("position ASC, created_at ASC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *gormConnectionFavoriteStore) Set(ctx context.Context, f *models.ConnectionFavorite) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "connection_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"position"}),
+	}).Create(f).Error
+}
+
+func (s *gormConnectionFavoriteStore) Delete(ctx context.Context, userID, connectionID string) error {
+	return s.db.WithContext(ctx).Delete(&models.ConnectionFavorite{}, "user_id = ? AND connection_id = ?", userID, connectionID).Error
+}
+
+func (s *gormConnectionFavoriteStore) DeleteByConnection(ctx context.Context, connectionID string) error {
+	return s.db.WithContext(ctx).Delete(&models.ConnectionFavorite{}, "connection_id = ?", connectionID).Error
+}
+
+type gormCredentialUsageStore struct{ db *gorm.DB }
+
+func (s *gormCredentialUsageStore) ListByUser(ctx context.Context, userID string) ([]models.CredentialUsage, error) {
+	var list []models.CredentialUsage
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *gormCredentialUsageStore) SetFavorite(ctx context.Context, userID, credentialID string, favorite bool) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "credential_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"favorite"}),
+	}).Create(&models.CredentialUsage{UserID: userID, CredentialID: credentialID, Favorite: favorite}).Error
+}
+
+func (s *gormCredentialUsageStore) Touch(ctx context.Context, userID, credentialID string, at time.Time) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "credential_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_used_at"}),
+	}).Create(&models.CredentialUsage{UserID: userID, CredentialID: credentialID, LastUsedAt: &at}).Error
+}
+
+func (s *gormCredentialUsageStore) DeleteByCredential(ctx context.Context, credentialID string) error {
+	return s.db.WithContext(ctx).Delete(&models.CredentialUsage{}, "credential_id = ?", credentialID).Error
+}
+
+type gormCredentialStore struct {
+	db   *gorm.DB
+	keys *collation
+}
 
 func (s *gormCredentialStore) Create(ctx context.Context, c *models.Credential) error {
+	c.NameSort = s.keys.key(c.Name)
 	return s.db.WithContext(ctx).Create(c).Error
 }
 
@@ -250,15 +422,52 @@ func (s *gormCredentialStore) Get(ctx context.Context, id string) (models.Creden
 
 func (s *gormCredentialStore) ListByOwner(ctx context.Context, ownerID string) ([]models.Credential, error) {
 	var list []models.Credential
-	if err := s.db.WithContext(ctx).Where("owner_id = ?", ownerID).Order("name").Find(&list).Error; err != nil {
+	if err := s.db.WithContext(ctx).Where("owner_id = ?", ownerID).Order("name_sort, name").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *gormCredentialStore) List(ctx context.Context, f CredentialFilter) ([]models.Credential, error) {
+	q := s.db.WithContext(ctx).Model(&models.Credential{})
+	if f.OwnerID != "" {
+		q = q.Where("owner_id = ?", f.OwnerID)
+	}
+	if f.IDs != nil {
+		if len(f.IDs) == 0 {
+			return nil, nil
+		}
+		q = q.Where("id IN ?", f.IDs)
+	}
+	if len(f.Kinds) > 0 {
+		q = q.Where("kind IN ?", f.Kinds)
+	}
+	if f.Protocol != "" {
+		// Protocols is a JSON array of strings; match the quoted element.
+		quoted, err := json.Marshal(f.Protocol)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where("(protocols IS NULL OR protocols IN ('', 'null', '[]') OR protocols LIKE ?"+likeEscape(q)+")",
+			"%"+escapeSQLLike(string(quoted))+"%")
+	}
+	var list []models.Credential
+	if err := q.Order("name_sort, name").Find(&list).Error; err != nil {
 		return nil, err
 	}
 	return list, nil
 }
 
 func (s *gormCredentialStore) Update(ctx context.Context, c *models.Credential) error {
+	c.NameSort = s.keys.key(c.Name)
 	res := s.db.WithContext(ctx).Model(&models.Credential{}).Where("id = ?", c.ID).
-		Select("name", "kind", "username", "protocols", "encrypted_secret").Updates(c)
+		Select("name", "name_sort", "kind", "username", "protocols", "encrypted_secret", "requires_approval").Updates(c)
+	return rowsOrNotFound(res)
+}
+
+func (s *gormCredentialStore) SetOwner(ctx context.Context, id, ownerID string, at time.Time) error {
+	res := s.db.WithContext(ctx).Model(&models.Credential{}).Where("id = ?", id).
+		Updates(map[string]any{"owner_id": ownerID, "updated_at": at})
 	return rowsOrNotFound(res)
 }
 
@@ -266,6 +475,22 @@ func (s *gormCredentialStore) Delete(ctx context.Context, id string) error {
 	return s.db.WithContext(ctx).Delete(&models.Credential{}, "id = ?", id).Error
 }
 
+type gormCredentialVersionStore struct{ db *gorm.DB }
+
+func (s *gormCredentialVersionStore) Create(ctx context.Context, v *models.CredentialVersion) error {
+	return s.db.WithContext(ctx).Create(v).Error
+}
+
+func (s *gormCredentialVersionStore) List(ctx context.Context, credentialID string) ([]models.CredentialVersion, error) {
+	var list []models.CredentialVersion
+	err := s.db.WithContext(ctx).Where("credential_id = ?", credentialID).Order("version ASC").Find(&list).Error
+	return list, err
+}
+
+func (s *gormCredentialVersionStore) DeleteByCredential(ctx context.Context, credentialID string) error {
+	return s.db.WithContext(ctx).Delete(&models.CredentialVersion{}, "credential_id = ?", credentialID).Error
+}
+
 type gormGrantStore struct{ db *gorm.DB }
 
 func (s *gormGrantStore) Create(ctx context.Context, g *models.Grant) error {
@@ -278,7 +503,17 @@ func (s *gormGrantStore) Delete(ctx context.Context, id string) error {
 
 func (s *gormGrantStore) Get(ctx context.Context, connectionID, subjectID string) (models.Grant, error) {
 	var g models.Grant
-	if err := s.db.WithContext(ctx).First(&g, "connection_id = ? AND subject_id = ?", connectionID, subjectID).Error; err != nil {
+	err := s.db.WithContext(ctx).Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		First(&g, "connection_id = ? AND subject_id = ?", connectionID, subjectID).Error
+	if err != nil {
+		return models.Grant{}, normNotFound(err)
+	}
+	return g, nil
+}
+
+func (s *gormGrantStore) GetByID(ctx context.Context, id string) (models.Grant, error) {
+	var g models.Grant
+	if err := s.db.WithContext(ctx).First(&g, "id = ?", id).Error; err != nil {
 		return models.Grant{}, normNotFound(err)
 	}
 	return g, nil
@@ -294,12 +529,49 @@ func (s *gormGrantStore) ListByConnection(ctx context.Context, connectionID stri
 
 func (s *gormGrantStore) ListBySubject(ctx context.Context, subjectID string) ([]models.Grant, error) {
 	var list []models.Grant
-	if err := s.db.WithContext(ctx).Where("subject_id = ?", subjectID).Find(&list).Error; err != nil {
+	err := s.db.WithContext(ctx).Where("subject_id = ?", subjectID).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).Find(&list).Error
+	if err != nil {
 		return nil, err
 	}
 	return list, nil
 }
 
+func (s *gormGrantStore) ListExpiring(ctx context.Context, connectionIDs []string, from, to time.Time) ([]models.Grant, error) {
+	list := []models.Grant{}
+	if len(connectionIDs) == 0 {
+		return list, nil
+	}
+	err := s.db.WithContext(ctx).Where("connection_id IN ? AND expires_at > ? AND expires_at <= ?", connectionIDs, from, to).
+		Order("expires_at, id").Find(&list).Error
+	return list, err
+}
+
+func (s *gormGrantStore) ListLapsing(ctx context.Context, from, to time.Time) ([]models.Grant, error) {
+	list := []models.Grant{}
+	err := s.db.WithContext(ctx).Where("expires_at > ? AND expires_at <= ?", from, to).
+		Order("expires_at, id").Find(&list).Error
+	return list, err
+}
+
+func (s *gormGrantStore) SetAccess(ctx context.Context, id string, access models.Access) error {
+	res := s.db.WithContext(ctx).Model(&models.Grant{}).Where("id = ?", id).Update("access", access)
+	return rowsOrNotFound(res)
+}
+
+func (s *gormGrantStore) SetExpiry(ctx context.Context, ids []string, expiresAt *time.Time) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Grant{}).Where("id IN ?", ids).Update("expires_at", expiresAt)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != int64(len(ids)) {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
 type gormCredentialGrantStore struct{ db *gorm.DB }
 
 func (s *gormCredentialGrantStore) Create(ctx context.Context, g *models.CredentialGrant) error {
@@ -339,14 +611,15 @@ func (s *gormAuditStore) Append(ctx context.Context, e *models.AuditEntry) error
 	return s.db.WithContext(ctx).Create(e).Error
 }
 
-func (s *gormAuditStore) List(ctx context.Context, f AuditFilter) ([]models.AuditEntry, error) {
-	q := s.db.WithContext(ctx).Model(&models.AuditEntry{}).Order("time DESC")
-	if f.UserID != "" {
-		q = q.Where("user_id = ?", f.UserID)
-	}
-	if f.ConnectionID != "" {
-		q = q.Where("connection_id = ?", f.ConnectionID)
+func (s *gormAuditStore) AppendBatch(ctx context.Context, entries []*models.AuditEntry) error {
+	if len(entries) == 0 {
+		return nil
 	}
+	return s.db.WithContext(ctx).CreateInBatches(entries, AuditBatchSize).Error
+}
+
+func (s *gormAuditStore) List(ctx context.Context, f AuditFilter) ([]models.AuditEntry, error) {
+	q := auditWhere(s.db.WithContext(ctx).Model(&models.AuditEntry{}).Order("time DESC"), f)
 	if f.Limit > 0 {
 		q = q.Limit(f.Limit)
 	}
@@ -361,15 +634,45 @@ func (s *gormAuditStore) List(ctx context.Context, f AuditFilter) ([]models.Audi
 }
 
 func (s *gormAuditStore) Count(ctx context.Context, f AuditFilter) (int64, error) {
-	q := s.db.WithContext(ctx).Model(&models.AuditEntry{})
+	q := auditWhere(s.db.WithContext(ctx).Model(&models.AuditEntry{}), f)
+	var n int64
+	return n, q.Count(&n).Error
+}
+
+func auditWhere(q *gorm.DB, f AuditFilter) *gorm.DB {
 	if f.UserID != "" {
 		q = q.Where("user_id = ?", f.UserID)
 	}
 	if f.ConnectionID != "" {
 		q = q.Where("connection_id = ?", f.ConnectionID)
 	}
-	var n int64
-	return n, q.Count(&n).Error
+	if f.ResourceType != "" {
+		q = q.Where("resource_type = ?", f.ResourceType)
+	}
+	if f.ResourceID != "" {
+		q = q.Where("resource_id = ?", f.ResourceID)
+	}
+	if !f.Since.IsZero() {
+		q = q.Where("time >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		q = q.Where("time <= ?", f.Until)
+	}
+	return q
+}
+
+// ListUnresourced finds entries from before the resource columns were added;
+// the migration leaves those NULL, while new entries store "" at least.
+func (s *gormAuditStore) ListUnresourced(ctx context.Context, limit int) ([]models.AuditEntry, error) {
+	var out []models.AuditEntry
+	err := s.db.WithContext(ctx).Where("resource_type IS NULL").Order("time, id").Limit(limit).Find(&out).Error
+	return out, err
+}
+
+func (s *gormAuditStore) SetResource(ctx context.Context, id, resourceType, resourceID string) error {
+	res := s.db.WithContext(ctx).Model(&models.AuditEntry{}).Where("id = ?", id).
+		UpdateColumns(map[string]any{"resource_type": resourceType, "resource_id": resourceID})
+	return rowsOrNotFound(res)
 }
 
 func (s *gormAuditStore) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
@@ -377,6 +680,60 @@ func (s *gormAuditStore) DeleteBefore(ctx context.Context, before time.Time) (in
 	return res.RowsAffected, res.Error
 }
 
+func (s *gormAuditStore) ChainHead(ctx context.Context) (models.AuditEntry, error) {
+	var e models.AuditEntry
+	err := s.db.WithContext(ctx).Where("seq IS NOT NULL").Order("seq DESC").First(&e).Error
+	return e, normNotFound(err)
+}
+
+func (s *gormAuditStore) ListChain(ctx context.Context, since time.Time, afterSeq int64, limit int) ([]models.AuditEntry, error) {
+	q := s.db.WithContext(ctx).Where("seq > ?", afterSeq)
+	if !since.IsZero() {
+		q = q.Where("time >= ?", since)
+	}
+	var out []models.AuditEntry
+	err := q.Order("seq").Limit(limit).Find(&out).Error
+	return out, err
+}
+
+type gormCredentialAccessLogStore struct{ db *gorm.DB }
+
+func (s *gormCredentialAccessLogStore) Append(ctx context.Context, e *models.CredentialAccessLog) error {
+	return s.db.WithContext(ctx).Create(e).Error
+}
+
+func (s *gormCredentialAccessLogStore) List(ctx context.Context, f CredentialAccessFilter) ([]models.CredentialAccessLog, error) {
+	q := s.db.WithContext(ctx).Model(&models.CredentialAccessLog{}).Order("time DESC")
+	if f.CredentialID != "" {
+		q = q.Where("credential_id = ?", f.CredentialID)
+	}
+	if f.Limit > 0 {
+		q = q.Limit(f.Limit)
+	}
+	if f.Offset > 0 {
+		q = q.Offset(f.Offset)
+	}
+	var list []models.CredentialAccessLog
+	if err := q.Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *gormCredentialAccessLogStore) Count(ctx context.Context, f CredentialAccessFilter) (int64, error) {
+	q := s.db.WithContext(ctx).Model(&models.CredentialAccessLog{})
+	if f.CredentialID != "" {
+		q = q.Where("credential_id = ?", f.CredentialID)
+	}
+	var n int64
+	return n, q.Count(&n).Error
+}
+
+func (s *gormCredentialAccessLogStore) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	res := s.db.WithContext(ctx).Where("time < ?", before).Delete(&models.CredentialAccessLog{})
+	return res.RowsAffected, res.Error
+}
+
 type gormPluginStorageStore struct{ db *gorm.DB }
 
 type gormPolicyStore struct{ db *gorm.DB }
@@ -498,7 +855,7 @@ func applyPluginStorageFilter(q *gorm.DB, f PluginStorageFilter) *gorm.DB {
 		q = q.Where("item_key IN ?", f.Keys)
 	}
 	if f.KeyPrefix != "" {
-		q = q.Where("item_key LIKE ? ESCAPE '\\'", escapeSQLLikePrefix(f.KeyPrefix))
+		q = q.Where("item_key LIKE ?"+likeEscape(q), escapeSQLLikePrefix(f.KeyPrefix))
 	}
 	if f.ContentType != "" {
 		q = q.Where("content_type = ?", f.ContentType)
@@ -519,7 +876,11 @@ func applyPluginStorageFilter(q *gorm.DB, f PluginStorageFilter) *gorm.DB {
 }
 
 func escapeSQLLikePrefix(prefix string) string {
-	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+	return escapeSQLLike(prefix) + "%"
+}
+
+func escapeSQLLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
 }
 
 type gormPreferenceStore struct{ db *gorm.DB }
@@ -556,6 +917,85 @@ func (s *gormProtocolSettingStore) Set(ctx context.Context, p *models.ProtocolSe
 	return s.db.WithContext(ctx).Save(p).Error
 }
 
+type gormSystemSettingStore struct{ db *gorm.DB }
+
+func (s *gormSystemSettingStore) Get(ctx context.Context, key string) (models.SystemSetting, error) {
+	var v models.SystemSetting
+	// Clause-built so the reserved word "key" is quoted per dialect.
+	if err := s.db.WithContext(ctx).Where(clause.Eq{Column: clause.Column{Name: "key"}, Value: key}).First(&v).Error; err != nil {
+		return models.SystemSetting{}, normNotFound(err)
+	}
+	return v, nil
+}
+
+func (s *gormSystemSettingStore) List(ctx context.Context) ([]models.SystemSetting, error) {
+	var out []models.SystemSetting
+	if err := s.db.WithContext(ctx).Order(clause.OrderByColumn{Column: clause.Column{Name: "key"}}).Find(&out).Error; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *gormSystemSettingStore) Set(ctx context.Context, v *models.SystemSetting) error {
+	v.UpdatedAt = time.Now()
+	return s.db.WithContext(ctx).Save(v).Error
+}
+
+type gormCredentialApprovalStore struct{ db *gorm.DB }
+
+func (s *gormCredentialApprovalStore) Create(ctx context.Context, a *models.CredentialApproval) error {
+	return s.db.WithContext(ctx).Create(a).Error
+}
+
+func (s *gormCredentialApprovalStore) Get(ctx context.Context, id string) (models.CredentialApproval, error) {
+	var a models.CredentialApproval
+	if err := s.db.WithContext(ctx).First(&a, "id = ?", id).Error; err != nil {
+		return models.CredentialApproval{}, normNotFound(err)
+	}
+	return a, nil
+}
+
+func (s *gormCredentialApprovalStore) List(ctx context.Context, f CredentialApprovalFilter) ([]models.CredentialApproval, error) {
+	q := s.db.WithContext(ctx).Model(&models.CredentialApproval{}).Order("created_at DESC")
+	if f.CredentialID != "" {
+		q = q.Where("credential_id = ?", f.CredentialID)
+	}
+	if len(f.CredentialIDs) > 0 {
+		q = q.Where("credential_id IN ?", f.CredentialIDs)
+	}
+	if f.RequesterID != "" {
+		q = q.Where("requester_id = ?", f.RequesterID)
+	}
+	if f.Status != "" {
+		q = q.Where("status = ?", string(f.Status))
+	}
+	var list []models.CredentialApproval
+	if err := q.Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *gormCredentialApprovalStore) Approve(ctx context.Context, id, approverID string, at, useBy time.Time) (bool, error) {
+	res := s.db.WithContext(ctx).Model(&models.CredentialApproval{}).
+		Where("id = ? AND status = ? AND expires_at > ?", id, string(models.ApprovalPending), at).
+		Updates(map[string]any{"status": string(models.ApprovalApproved), "approver_id": approverID, "approved_at": at, "use_by": useBy})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (s *gormCredentialApprovalStore) Consume(ctx context.Context, id string, now time.Time) (bool, error) {
+	res := s.db.WithContext(ctx).Model(&models.CredentialApproval{}).
+		Where("id = ? AND status = ? AND use_by > ?", id, string(models.ApprovalApproved), now).
+		Updates(map[string]any{"status": string(models.ApprovalUsed), "used_at": now})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
 type gormInvitationStore struct{ db *gorm.DB }
 
 func (s *gormInvitationStore) Create(ctx context.Context, i *models.Invitation) error {
@@ -588,24 +1028,294 @@ func (s *gormInvitationStore) List(ctx context.Context) ([]models.Invitation, er
 
 func (s *gormInvitationStore) Update(ctx context.Context, i *models.Invitation) error {
 	res := s.db.WithContext(ctx).Model(&models.Invitation{}).Where("id = ?", i.ID).
-		Select("status", "accepted_at").Updates(i)
+		Select("status", "accepted_at", "token_hash", "expires_at").Updates(i)
 	return rowsOrNotFound(res)
 }
 
 func (s *gormInvitationStore) Consume(ctx context.Context, id string, acceptedAt time.Time) (bool, error) {
 	res := s.db.WithContext(ctx).Model(&models.Invitation{}).
 		Where("id = ? AND status = ? AND expires_at > ?", id, string(models.InvitePending), acceptedAt).
-		Updates(map[string]any{"status": string(models.InviteAccepted), "accepted_at": acceptedAt})
+		Updates(map[string]any{"status": string(models.InviteAccepted), "accepted_at": acceptedAt, "token_hash": nil})
 	if res.Error != nil {
 		return false, res.Error
 	}
 	return res.RowsAffected == 1, nil
 }
 
+func (s *gormInvitationStore) ExpirePending(ctx context.Context, now time.Time) (int, error) {
+	res := s.db.WithContext(ctx).Model(&models.Invitation{}).
+		Where("status = ? AND expires_at <= ?", string(models.InvitePending), now).
+		Updates(map[string]any{"status": string(models.InviteExpired), "token_hash": nil})
+	return int(res.RowsAffected), res.Error
+}
+
 func (s *gormInvitationStore) Delete(ctx context.Context, id string) error {
 	return s.db.WithContext(ctx).Delete(&models.Invitation{}, "id = ?", id).Error
 }
 
+type gormWebhookStore struct{ db *gorm.DB }
+
+func (s *gormWebhookStore) Create(ctx context.Context, w *models.Webhook) error {
+	return s.db.WithContext(ctx).Create(w).Error
+}
+
+func (s *gormWebhookStore) Get(ctx context.Context, id string) (models.Webhook, error) {
+	var w models.Webhook
+	if err := s.db.WithContext(ctx).First(&w, "id = ?", id).Error; err != nil {
+		return models.Webhook{}, normNotFound(err)
+	}
+	return w, nil
+}
+
+func (s *gormWebhookStore) List(ctx context.Context) ([]models.Webhook, error) {
+	var list []models.Webhook
+	if err := s.db.WithContext(ctx).Order("created_at").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *gormWebhookStore) Update(ctx context.Context, w *models.Webhook) error {
+	res := s.db.WithContext(ctx).Model(&models.Webhook{}).Where("id = ?", w.ID).
+		Select("name", "url", "events", "enabled", "secret_ciphertext", "updated_at").Updates(w)
+	return rowsOrNotFound(res)
+}
+
+func (s *gormWebhookStore) Delete(ctx context.Context, id string) error {
+	return s.db.WithContext(ctx).Delete(&models.Webhook{}, "id = ?", id).Error
+}
+
+type gormWebhookDeliveryStore struct{ db *gorm.DB }
+
+func (s *gormWebhookDeliveryStore) Create(ctx context.Context, d *models.WebhookDelivery) error {
+	return s.db.WithContext(ctx).Create(d).Error
+}
+
+func (s *gormWebhookDeliveryStore) List(ctx context.Context, webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	var list []models.WebhookDelivery
+	if err := s.db.WithContext(ctx).Where("webhook_id = ?", webhookID).
+		Order("created_at DESC").Order("id DESC").Limit(limit).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *gormWebhookDeliveryStore) Prune(ctx context.Context, webhookID string, keep int) error {
+	var cutoff []models.WebhookDelivery
+	if err := s.db.WithContext(ctx).Where("webhook_id = ?", webhookID).
+		Order("created_at DESC").Order("id DESC").Offset(keep).Limit(1).Find(&cutoff).Error; err != nil {
+		return err
+	}
+	if len(cutoff) == 0 {
+		return nil
+	}
+	c := cutoff[0]
+	return s.db.WithContext(ctx).Where("webhook_id = ? AND (created_at < ? OR (created_at = ? AND id <= ?))",
+		webhookID, c.CreatedAt, c.CreatedAt, c.ID).Delete(&models.WebhookDelivery{}).Error
+}
+
+func (s *gormWebhookDeliveryStore) DeleteByWebhook(ctx context.Context, webhookID string) error {
+	return s.db.WithContext(ctx).Delete(&models.WebhookDelivery{}, "webhook_id = ?", webhookID).Error
+}
+
+type gormSessionShareLinkStore struct{ db *gorm.DB }
+
+func (s *gormSessionShareLinkStore) Create(ctx context.Context, l *models.SessionShareLink) error {
+	return s.db.WithContext(ctx).Create(l).Error
+}
+
+func (s *gormSessionShareLinkStore) Get(ctx context.Context, id string) (models.SessionShareLink, error) {
+	var l models.SessionShareLink
+	if err := s.db.WithContext(ctx).First(&l, "id = ?", id).Error; err != nil {
+		return models.SessionShareLink{}, normNotFound(err)
+	}
+	return l, nil
+}
+
+func (s *gormSessionShareLinkStore) GetByTokenHash(ctx context.Context, tokenHash string) (models.SessionShareLink, error) {
+	var l models.SessionShareLink
+	if err := s.db.WithContext(ctx).First(&l, "token_hash = ?", tokenHash).Error; err != nil {
+		return models.SessionShareLink{}, normNotFound(err)
+	}
+	return l, nil
+}
+
+func (s *gormSessionShareLinkStore) ListByConnection(ctx context.Context, connectionID string) ([]models.SessionShareLink, error) {
+	var list []models.SessionShareLink
+	if err := s.db.WithContext(ctx).Where("connection_id = ?", connectionID).
+		Order("created_at DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *gormSessionShareLinkStore) Consume(ctx context.Context, id string, now time.Time) (bool, error) {
+	res := s.db.WithContext(ctx).Model(&models.SessionShareLink{}).
+		Where("id = ? AND revoked_at IS NULL AND expires_at > ? AND uses < max_uses", id, now).
+		Update("uses", gorm.Expr("uses + 1"))
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (s *gormSessionShareLinkStore) Revoke(ctx context.Context, id string, at time.Time) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Model(&models.SessionShareLink{}).
+		Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", at).Error
+}
+
+type gormSessionParticipantStore struct{ db *gorm.DB }
+
+func (s *gormSessionParticipantStore) Create(ctx context.Context, p *models.SessionParticipant) error {
+	return s.db.WithContext(ctx).Create(p).Error
+}
+
+func (s *gormSessionParticipantStore) Get(ctx context.Context, connectionID, ownerID, userID string) (models.SessionParticipant, error) {
+	var p models.SessionParticipant
+	err := s.db.WithContext(ctx).
+		First(&p, "connection_id = ? AND owner_id = ? AND user_id = ?", connectionID, ownerID, userID).Error
+	if err != nil {
+		return models.SessionParticipant{}, normNotFound(err)
+	}
+	return p, nil
+}
+
+func (s *gormSessionParticipantStore) ListByConnection(ctx context.Context, connectionID string) ([]models.SessionParticipant, error) {
+	var list []models.SessionParticipant
+	if err := s.db.WithContext(ctx).Where("connection_id = ?", connectionID).
+		Order("joined_at").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *gormSessionParticipantStore) SetWriteHolder(ctx context.Context, connectionID, ownerID, userID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.SessionParticipant{}).
+			Where("connection_id = ? AND owner_id = ?", connectionID, ownerID).Update("write_holder", false).Error
+		if err != nil || userID == "" {
+			return err
+		}
+		res := tx.Model(&models.SessionParticipant{}).
+			Where("connection_id = ? AND owner_id = ? AND user_id = ?", connectionID, ownerID, userID).
+			Update("write_holder", true)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (s *gormSessionParticipantStore) DeleteBySession(ctx context.Context, connectionID, ownerID string) error {
+	return s.db.WithContext(ctx).
+		Delete(&models.SessionParticipant{}, "connection_id = ? AND owner_id = ?", connectionID, ownerID).Error
+}
+
+func (s *gormSessionParticipantStore) DeleteByLink(ctx context.Context, linkID string) error {
+	return s.db.WithContext(ctx).Delete(&models.SessionParticipant{}, "share_link_id = ?", linkID).Error
+}
+
+type gormSessionWriteRequestStore struct{ db *gorm.DB }
+
+func (s *gormSessionWriteRequestStore) Create(ctx context.Context, r *models.SessionWriteRequest) error {
+	return s.db.WithContext(ctx).Create(r).Error
+}
+
+func (s *gormSessionWriteRequestStore) Get(ctx context.Context, id string) (models.SessionWriteRequest, error) {
+	var r models.SessionWriteRequest
+	if err := s.db.WithContext(ctx).First(&r, "id = ?", id).Error; err != nil {
+		return models.SessionWriteRequest{}, normNotFound(err)
+	}
+	return r, nil
+}
+
+func (s *gormSessionWriteRequestStore) ListPending(ctx context.Context, connectionID string, now time.Time) ([]models.SessionWriteRequest, error) {
+	var list []models.SessionWriteRequest
+	if err := s.db.WithContext(ctx).
+		Where("connection_id = ? AND status = ? AND expires_at > ?", connectionID, models.WriteRequestPending, now).
+		Order("created_at").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *gormSessionWriteRequestStore) Decide(ctx context.Context, id string, status models.WriteRequestStatus, decidedBy, reason string, at time.Time) (bool, error) {
+	res := s.db.WithContext(ctx).Model(&models.SessionWriteRequest{}).
+		Where("id = ? AND status = ?", id, models.WriteRequestPending).
+		Updates(map[string]any{"status": status, "decided_by": decidedBy, "reason": reason, "decided_at": at})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (s *gormSessionWriteRequestStore) DeleteBySession(ctx context.Context, connectionID, ownerID string) error {
+	return s.db.WithContext(ctx).
+		Delete(&models.SessionWriteRequest{}, "connection_id = ? AND owner_id = ?", connectionID, ownerID).Error
+}
+
+type gormConnectionSessionStore struct{ db *gorm.DB }
+
+func (s *gormConnectionSessionStore) Create(ctx context.Context, cs *models.ConnectionSession) error {
+	return s.db.WithContext(ctx).Create(cs).Error
+}
+
+func (s *gormConnectionSessionStore) Get(ctx context.Context, id string) (models.ConnectionSession, error) {
+	var cs models.ConnectionSession
+	if err := s.db.WithContext(ctx).First(&cs, "id = ?", id).Error; err != nil {
+		return models.ConnectionSession{}, normNotFound(err)
+	}
+	return cs, nil
+}
+
+func (s *gormConnectionSessionStore) UpdateTraffic(ctx context.Context, id string, bytesIn, bytesOut int64, at time.Time) error {
+	return rowsOrNotFound(s.db.WithContext(ctx).Model(&models.ConnectionSession{}).Where("id = ?", id).
+		Updates(map[string]any{"bytes_in": bytesIn, "bytes_out": bytesOut, "updated_at": at}))
+}
+
+func (s *gormConnectionSessionStore) End(ctx context.Context, id string, status models.ConnectionSessionStatus, reason string, bytesIn, bytesOut int64, at time.Time) error {
+	return rowsOrNotFound(s.db.WithContext(ctx).Model(&models.ConnectionSession{}).Where("id = ?", id).
+		Updates(map[string]any{
+			"status": status, "reason": reason, "ended_at": at,
+			"bytes_in": bytesIn, "bytes_out": bytesOut, "updated_at": at,
+		}))
+}
+
+func (s *gormConnectionSessionStore) ListStale(ctx context.Context, before time.Time) ([]models.ConnectionSession, error) {
+	var out []models.ConnectionSession
+	err := s.db.WithContext(ctx).Where("status = ? AND updated_at < ?", models.ConnectionSessionActive, before).
+		Order("started_at").Find(&out).Error
+	return out, err
+}
+
+type gormSessionObservationStore struct{ db *gorm.DB }
+
+func (s *gormSessionObservationStore) Create(ctx context.Context, o *models.SessionObservation) error {
+	return s.db.WithContext(ctx).Create(o).Error
+}
+
+func (s *gormSessionObservationStore) Get(ctx context.Context, id string) (models.SessionObservation, error) {
+	var o models.SessionObservation
+	if err := s.db.WithContext(ctx).First(&o, "id = ?", id).Error; err != nil {
+		return models.SessionObservation{}, normNotFound(err)
+	}
+	return o, nil
+}
+
+func (s *gormSessionObservationStore) End(ctx context.Context, id string, at time.Time) error {
+	return rowsOrNotFound(s.db.WithContext(ctx).Model(&models.SessionObservation{}).Where("id = ?", id).
+		Update("ended_at", at))
+}
+
+func (s *gormSessionObservationStore) EndBySession(ctx context.Context, sessionID string, at time.Time) (int64, error) {
+	res := s.db.WithContext(ctx).Model(&models.SessionObservation{}).
+		Where("session_id = ? AND ended_at IS NULL", sessionID).Update("ended_at", at)
+	return res.RowsAffected, res.Error
+}
+
 type gormRecordingStore struct{ db *gorm.DB }
 
 func (s *gormRecordingStore) Create(ctx context.Context, r *models.Recording) error {
@@ -621,19 +1331,37 @@ func (s *gormRecordingStore) Get(ctx context.Context, id string) (models.Recordi
 }
 
 func (s *gormRecordingStore) Update(ctx context.Context, r *models.Recording) error {
+	// Map updates bypass field serializers, so encode the JSON column here.
+	annotations, err := json.Marshal(r.Annotations)
+	if err != nil {
+		return err
+	}
+	chapters, err := json.Marshal(r.Chapters)
+	if err != nil {
+		return err
+	}
 	// A map (not a struct) guarantees every column is written — including the
 	// nullable *time.Time fields back to NULL — matching the memory store.
 	res := s.db.WithContext(ctx).Model(&models.Recording{}).Where("id = ?", r.ID).
 		Updates(map[string]any{
-			"status":      r.Status,
-			"title":       r.Title,
-			"ended_at":    r.EndedAt,
-			"duration_ms": r.DurationMS,
-			"size":        r.Size,
-			"checksum":    r.Checksum,
-			"storage_key": r.StorageKey,
-			"error":       r.Error,
-			"expires_at":  r.ExpiresAt,
+			"status":         r.Status,
+			"title":          r.Title,
+			"ended_at":       r.EndedAt,
+			"duration_ms":    r.DurationMS,
+			"size":           r.Size,
+			"checksum":       r.Checksum,
+			"storage_key":    r.StorageKey,
+			"transcript_key": r.TranscriptKey,
+			"error":          r.Error,
+			"partial":        r.Partial,
+			"expires_at":     r.ExpiresAt,
+			"annotations":    string(annotations),
+			"chapters":       string(chapters),
+			"chapter_gap_ms": r.ChapterGapMS,
+			"protected":      r.Protected,
+			"protected_by":   r.ProtectedBy,
+			"protected_at":   r.ProtectedAt,
+			"archived_at":    r.ArchivedAt,
 		})
 	return rowsOrNotFound(res)
 }
@@ -643,7 +1371,19 @@ func (s *gormRecordingStore) Delete(ctx context.Context, id string) error {
 }
 
 func (s *gormRecordingStore) List(ctx context.Context, f RecordingFilter) ([]models.Recording, error) {
-	q := s.db.WithContext(ctx).Model(&models.Recording{}).Order("started_at DESC")
+	q := s.db.WithContext(ctx).Model(&models.Recording{})
+	switch f.Sort {
+	case RecordingSortDuration:
+		q = q.Order("duration_ms DESC")
+	case RecordingSortConnection:
+		q = q.Order("connection_name ASC")
+	}
+	q = q.Order("started_at DESC").Order("id")
+	if f.Search != "" {
+		term := "%" + escapeSQLLike(strings.ToLower(f.Search)) + "%"
+		esc := likeEscape(s.db)
+		q = q.Where("(LOWER(connection_name) LIKE ?"+esc+" OR LOWER(username) LIKE ?"+esc+")", term, term)
+	}
 	if f.UserID != "" {
 		q = q.Where("user_id = ?", f.UserID)
 	}
@@ -671,9 +1411,15 @@ func (s *gormRecordingStore) List(ctx context.Context, f RecordingFilter) ([]mod
 	if !f.ExpiredBefore.IsZero() {
 		q = q.Where("expires_at IS NOT NULL AND expires_at <= ?", f.ExpiredBefore)
 	}
+	if !f.ArchivableBefore.IsZero() {
+		q = q.Where("archived_at IS NULL AND ended_at IS NOT NULL AND ended_at <= ?", f.ArchivableBefore)
+	}
 	if f.Limit > 0 {
 		q = q.Limit(f.Limit)
 	}
+	if f.Offset > 0 {
+		q = q.Offset(f.Offset)
+	}
 	var list []models.Recording
 	if err := q.Find(&list).Error; err != nil {
 		return nil, err
@@ -907,6 +1653,14 @@ func (s *gormLiveStateLeaseStore) Release(ctx context.Context, key, leaseID stri
 	return s.db.WithContext(ctx).Delete(&models.LiveStateLease{}, "lease_key = ? AND lease_id = ?", key, leaseID).Error
 }
 
+func (s *gormLiveStateLeaseStore) CountLive(ctx context.Context, prefix, suffix string, now time.Time) (int64, error) {
+	var n int64
+	err := s.db.WithContext(ctx).Model(&models.LiveStateLease{}).
+		Where("lease_key LIKE ?"+likeEscape(s.db)+" AND expires_at > ?", escapeSQLLike(prefix)+"%"+escapeSQLLike(suffix), now).
+		Count(&n).Error
+	return n, err
+}
+
 func (s *gormLiveStateLeaseStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
 	res := s.db.WithContext(ctx).Delete(&models.LiveStateLease{}, "expires_at <= ?", now)
 	return res.RowsAffected, res.Error
@@ -968,3 +1722,51 @@ func (s *gormEnrollmentStore) Consume(ctx context.Context, id string, now time.T
 	}
 	return res.RowsAffected == 1, nil
 }
+
+type gormActivityStore struct{ db *gorm.DB }
+
+func (s *gormActivityStore) Summarize(ctx context.Context, q ActivityQuery) (ActivitySummary, error) {
+	var out ActivitySummary
+	db := s.db.WithContext(ctx)
+	sessions := func() *gorm.DB {
+		return db.Model(&models.AuditEntry{}).
+			Where("time >= ? AND event = ? AND result = ?", q.Since, q.SessionEvent, models.AuditAllowed)
+	}
+	day := dayExpr(s.db.Dialector.Name(), "time")
+	if err := sessions().Select(day + " AS day, COUNT(*) AS count").
+		Group(day).Order("day").Scan(&out.SessionsPerDay).Error; err != nil {
+		return out, err
+	}
+	top := sessions().Select("connection_id, COUNT(*) AS count").
+		Group("connection_id").Order("count DESC, connection_id")
+	if q.Top > 0 {
+		top = top.Limit(q.Top)
+	}
+	if err := top.Scan(&out.TopConnections).Error; err != nil {
+		return out, err
+	}
+	if err := db.Model(&models.AuditEntry{}).
+		Where("time >= ? AND result = ? AND user_id <> ''", q.Since, models.AuditAllowed).
+		Distinct("user_id").Count(&out.ActiveUsers).Error; err != nil {
+		return out, err
+	}
+	if err := db.Model(&models.Recording{}).Where("started_at >= ?", q.Since).
+		Select("COALESCE(SUM(size), 0)").Scan(&out.RecordingBytes).Error; err != nil {
+		return out, err
+	}
+	err := db.Model(&models.CredentialAccessLog{}).
+		Where("time >= ? AND result = ?", q.Since, models.AuditAllowed).Count(&out.CredentialRetrievals).Error
+	return out, err
+}
+
+// dayExpr formats a timestamp column as a UTC YYYY-MM-DD string in SQL.
+func dayExpr(dialect, column string) string {
+	switch dialect {
+	case "postgres":
+		return "to_char(" + column + " AT TIME ZONE 'UTC', 'YYYY-MM-DD')"
+	case "mysql":
+		return "DATE_FORMAT(" + column + ", '%Y-%m-%d')"
+	default:
+		return "strftime('%Y-%m-%d', " + column + ")"
+	}
+}
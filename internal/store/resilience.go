@@ -0,0 +1,279 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// ErrUnavailable is returned without touching the database while the circuit
+// breaker is open.
+var ErrUnavailable = errors.New("database unavailable")
+
+const (
+	defaultReadRetries      = 3
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 10 * time.Second
+	retryBaseDelay          = 50 * time.Millisecond
+)
+
+// Resilience retries idempotent reads that fail transiently and trips a
+// circuit breaker after consecutive transient failures. Writes are never
+// retried: outside a transaction they run once, and statements inside a
+// transaction go straight to the driver.
+type Resilience struct {
+	// ReadRetries is how many times a failed SELECT is retried; 0 means 3.
+	ReadRetries int
+	// BreakerThreshold is how many consecutive transient failures open the
+	// breaker; 0 means 5.
+	BreakerThreshold int
+	// BreakerCooldown is how long the breaker stays open before letting one
+	// probe through; 0 means 10s.
+	BreakerCooldown time.Duration
+	// OnBreakerChange is called with true when the breaker opens and false
+	// when it closes again.
+	OnBreakerChange func(open bool)
+
+	mu        sync.Mutex
+	failures  int
+	open      bool
+	openUntil time.Time
+	now       func() time.Time
+	sleep     func(ctx context.Context, d time.Duration) error
+}
+
+// BreakerOpen reports whether database calls are currently being refused.
+func (r *Resilience) BreakerOpen() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.open
+}
+
+// Check is a health check that fails while the breaker is open.
+func (r *Resilience) Check(context.Context) error {
+	if r.BreakerOpen() {
+		return fmt.Errorf("%w: circuit breaker open", ErrUnavailable)
+	}
+	return nil
+}
+
+func (r *Resilience) wrap(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if r.now == nil {
+		r.now = time.Now
+	}
+	if r.sleep == nil {
+		r.sleep = sleepCtx
+	}
+	pool := &resilientPool{db: sqlDB, r: r}
+	db.ConnPool = pool
+	db.Statement.ConnPool = pool
+	return nil
+}
+
+// allow reports whether a call may proceed. Once the cooldown has passed an
+// open breaker lets a single probe through per cooldown.
+func (r *Resilience) allow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.open {
+		return true
+	}
+	now := r.now()
+	if now.Before(r.openUntil) {
+		return false
+	}
+	r.openUntil = now.Add(r.cooldown())
+	return true
+}
+
+// observe feeds a call's outcome to the breaker. Only transient errors count
+// as failures; any other result proves the database is reachable.
+func (r *Resilience) observe(err error) {
+	r.mu.Lock()
+	var changed bool
+	if err != nil && isTransient(err) {
+		r.failures++
+		if !r.open && r.failures >= r.threshold() {
+			r.open, changed = true, true
+		}
+		if r.open {
+			r.openUntil = r.now().Add(r.cooldown())
+		}
+	} else {
+		r.failures = 0
+		if r.open {
+			r.open, changed = false, true
+		}
+	}
+	open := r.open
+	r.mu.Unlock()
+	if changed && r.OnBreakerChange != nil {
+		r.OnBreakerChange(open)
+	}
+}
+
+func (r *Resilience) threshold() int {
+	if r.BreakerThreshold > 0 {
+		return r.BreakerThreshold
+	}
+	return defaultBreakerThreshold
+}
+
+func (r *Resilience) cooldown() time.Duration {
+	if r.BreakerCooldown > 0 {
+		return r.BreakerCooldown
+	}
+	return defaultBreakerCooldown
+}
+
+func (r *Resilience) retries() int {
+	if r.ReadRetries > 0 {
+		return r.ReadRetries
+	}
+	return defaultReadRetries
+}
+
+// backoff is exponential from retryBaseDelay with full jitter.
+func backoff(attempt int) time.Duration {
+	ceiling := retryBaseDelay << attempt
+	return ceiling/2 + rand.N(ceiling/2+1)
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// resilientPool is the gorm ConnPool outside transactions. BeginTx hands gorm
+// a plain *sql.Tx, so nothing run inside a transaction passes through here.
+type resilientPool struct {
+	db *sql.DB
+	r  *Resilience
+}
+
+func (p *resilientPool) GetDBConn() (*sql.DB, error) { return p.db, nil }
+
+func (p *resilientPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	if !p.r.allow() {
+		return nil, ErrUnavailable
+	}
+	tx, err := p.db.BeginTx(ctx, opts)
+	p.r.observe(err)
+	return tx, err
+}
+
+func (p *resilientPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	if !p.r.allow() {
+		return nil, ErrUnavailable
+	}
+	stmt, err := p.db.PrepareContext(ctx, query)
+	p.r.observe(err)
+	return stmt, err
+}
+
+func (p *resilientPool) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if !p.r.allow() {
+		return nil, ErrUnavailable
+	}
+	res, err := p.db.ExecContext(ctx, query, args...)
+	p.r.observe(err)
+	return res, err
+}
+
+func (p *resilientPool) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	retries := 0
+	if isRead(query) {
+		retries = p.r.retries()
+	}
+	for attempt := 0; ; attempt++ {
+		if !p.r.allow() {
+			return nil, ErrUnavailable
+		}
+		rows, err := p.db.QueryContext(ctx, query, args...)
+		p.r.observe(err)
+		if err == nil || attempt >= retries || !isTransient(err) {
+			return rows, err
+		}
+		if serr := p.r.sleep(ctx, backoff(attempt)); serr != nil {
+			return nil, err
+		}
+	}
+}
+
+// QueryRowContext cannot fail fast: *sql.Row carries its error privately.
+func (p *resilientPool) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	retries := 0
+	if isRead(query) {
+		retries = p.r.retries()
+	}
+	for attempt := 0; ; attempt++ {
+		row := p.db.QueryRowContext(ctx, query, args...)
+		err := row.Err()
+		p.r.observe(err)
+		if err == nil || attempt >= retries || !isTransient(err) {
+			return row
+		}
+		if p.r.sleep(ctx, backoff(attempt)) != nil {
+			return row
+		}
+	}
+}
+
+// isRead limits retries to plain SELECTs; INSERT ... RETURNING also arrives
+// through QueryContext and must run once.
+func isRead(query string) bool {
+	q := strings.TrimLeft(query, " \t\r\n(")
+	return len(q) >= 6 && strings.EqualFold(q[:6], "select")
+}
+
+// isTransient reports whether err is a connection loss or a conflict the
+// database asks the client to retry.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+	var netErr *net.OpError
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) {
+		code := state.SQLState()
+		// 40001 serialization failure, 40P01 deadlock, 08xxx connection
+		// exception, 57P0x server shutting down.
+		return code == "40001" || code == "40P01" || strings.HasPrefix(code, "08") || strings.HasPrefix(code, "57P0")
+	}
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == 1213 || myErr.Number == 1205 // deadlock, lock wait timeout
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") || strings.Contains(msg, "SQLITE_BUSY")
+}
@@ -0,0 +1,164 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// flakyDriver fails the next `fail` statements with a transient error.
+type flakyDriver struct {
+	fail  int
+	calls []string
+}
+
+func (d *flakyDriver) Open(string) (driver.Conn, error) { return &flakyConn{d: d}, nil }
+
+type flakyConn struct{ d *flakyDriver }
+
+func (c *flakyConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("unused") }
+func (c *flakyConn) Close() error                        { return nil }
+func (c *flakyConn) Begin() (driver.Tx, error)           { return nil, errors.New("unused") }
+
+func (c *flakyConn) step(query string) error {
+	c.d.calls = append(c.d.calls, query)
+	if c.d.fail > 0 {
+		c.d.fail--
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func (c *flakyConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if err := c.step(query); err != nil {
+		return nil, err
+	}
+	return emptyRows{}, nil
+}
+
+func (c *flakyConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if err := c.step(query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return []string{"x"} }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+var flakyDrivers int
+
+func flakyPool(t *testing.T, fail int) (*resilientPool, *flakyDriver) {
+	t.Helper()
+	d := &flakyDriver{fail: fail}
+	flakyDrivers++
+	name := fmt.Sprintf("flaky-%d", flakyDrivers)
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	r := &Resilience{now: time.Now, sleep: func(context.Context, time.Duration) error { return nil }}
+	return &resilientPool{db: db, r: r}, d
+}
+
+func TestResilientPoolRetriesOnlyReads(t *testing.T) {
+	ctx := context.Background()
+	p, d := flakyPool(t, 2)
+	rows, err := p.QueryContext(ctx, "SELECT 1")
+	if err != nil {
+		t.Fatalf("read should succeed after retries: %v", err)
+	}
+	_ = rows.Close()
+	if len(d.calls) != 3 {
+		t.Fatalf("want 3 attempts, got %d", len(d.calls))
+	}
+
+	p, d = flakyPool(t, 1)
+	if _, err := p.QueryContext(ctx, "INSERT INTO t VALUES (1) RETURNING id"); err == nil || len(d.calls) != 1 {
+		t.Fatalf("insert-returning must run once: err=%v calls=%d", err, len(d.calls))
+	}
+	p, d = flakyPool(t, 1)
+	if _, err := p.ExecContext(ctx, "UPDATE t SET x = 1"); err == nil || len(d.calls) != 1 {
+		t.Fatalf("exec must run once: err=%v calls=%d", err, len(d.calls))
+	}
+
+	p, d = flakyPool(t, 10)
+	if _, err := p.QueryContext(ctx, "SELECT 1"); err == nil || len(d.calls) != 1+defaultReadRetries {
+		t.Fatalf("retries should stop at %d: err=%v calls=%d", defaultReadRetries, err, len(d.calls))
+	}
+}
+
+func TestResilienceBreaker(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	var changes []bool
+	r := &Resilience{
+		BreakerThreshold: 2, BreakerCooldown: time.Minute,
+		OnBreakerChange: func(open bool) { changes = append(changes, open) },
+		now:             func() time.Time { return now },
+	}
+	transient := fmt.Errorf("dial: %w", syscall.ECONNREFUSED)
+
+	r.observe(transient)
+	r.observe(errors.New("constraint failed")) // reachable: resets the count
+	r.observe(transient)
+	if r.BreakerOpen() {
+		t.Fatal("a non-transient error should reset the failure count")
+	}
+	r.observe(transient)
+	if !r.BreakerOpen() || r.allow() || !errors.Is(r.Check(context.Background()), ErrUnavailable) {
+		t.Fatal("breaker should open after consecutive transient failures")
+	}
+
+	now = now.Add(time.Minute)
+	if !r.allow() || r.allow() {
+		t.Fatal("after the cooldown exactly one probe is let through")
+	}
+	r.observe(nil)
+	if r.BreakerOpen() || !r.allow() || r.Check(context.Background()) != nil {
+		t.Fatal("a successful probe should close the breaker")
+	}
+	if len(changes) != 2 || !changes[0] || changes[1] {
+		t.Fatalf("state changes: %v", changes)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{driver.ErrBadConn, true},
+		{fmt.Errorf("x: %w", syscall.ECONNREFUSED), true},
+		{&sqlStateErr{"40001"}, true},
+		{&sqlStateErr{"40P01"}, true},
+		{&sqlStateErr{"08006"}, true},
+		{&sqlStateErr{"23505"}, false},
+		{&mysql.MySQLError{Number: 1213}, true},
+		{&mysql.MySQLError{Number: 1062}, false},
+		{errors.New("database is locked (5) (SQLITE_BUSY)"), true},
+		{context.Canceled, false},
+		{ErrNotFound, false},
+	} {
+		if got := isTransient(tc.err); got != tc.want {
+			t.Errorf("isTransient(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+type sqlStateErr struct{ code string }
+
+func (e *sqlStateErr) Error() string    { return "sqlstate " + e.code }
+func (e *sqlStateErr) SQLState() string { return e.code }
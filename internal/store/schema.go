@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// SchemaChangeKind names one difference between the models and the live
+// database schema.
+type SchemaChangeKind string
+
+const (
+	SchemaNewTable     SchemaChangeKind = "new_table"
+	SchemaNewColumn    SchemaChangeKind = "new_column"
+	SchemaTypeMismatch SchemaChangeKind = "type_mismatch"
+	SchemaMissingIndex SchemaChangeKind = "missing_index"
+	// SchemaExtraTable and SchemaExtraColumn exist in the database but not in
+	// the models, e.g. after a manual hotfix. Migrations never drop them.
+	SchemaExtraTable  SchemaChangeKind = "extra_table"
+	SchemaExtraColumn SchemaChangeKind = "extra_column"
+)
+
+// SchemaChange is one difference found by a schema plan.
+type SchemaChange struct {
+	Kind   SchemaChangeKind `json:"kind"`
+	Table  string           `json:"table"`
+	Column string           `json:"column,omitempty"`
+	Index  string           `json:"index,omitempty"`
+	Detail string           `json:"detail,omitempty"`
+}
+
+// SchemaReport compares the models with the live schema. Pending changes are
+// what the next start would apply; Drift is reported only.
+type SchemaReport struct {
+	Driver  string         `json:"driver"`
+	Pending []SchemaChange `json:"pending"`
+	Drift   []SchemaChange `json:"drift"`
+}
+
+// UpToDate reports whether starting would change nothing.
+func (r SchemaReport) UpToDate() bool { return len(r.Pending) == 0 }
+
+// SchemaStore inspects the database schema without changing it.
+type SchemaStore interface {
+	Plan(ctx context.Context) (SchemaReport, error)
+}
+
+type gormSchemaStore struct{ db *gorm.DB }
+
+func (s *gormSchemaStore) Plan(ctx context.Context) (SchemaReport, error) {
+	db := s.db.WithContext(ctx)
+	m := db.Migrator()
+	rep := SchemaReport{Driver: db.Dialector.Name(), Pending: []SchemaChange{}, Drift: []SchemaChange{}}
+	tables, err := m.GetTables()
+	if err != nil {
+		return rep, fmt.Errorf("list tables: %w", err)
+	}
+	known := map[string]bool{}
+	for _, model := range allModels() {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return rep, fmt.Errorf("parse %T: %w", model, err)
+		}
+		table := stmt.Schema.Table
+		known[table] = true
+		if !slices.Contains(tables, table) {
+			rep.Pending = append(rep.Pending, SchemaChange{Kind: SchemaNewTable, Table: table})
+			continue
+		}
+		cols, err := m.ColumnTypes(model)
+		if err != nil {
+			return rep, fmt.Errorf("columns of %s: %w", table, err)
+		}
+		live := make(map[string]gorm.ColumnType, len(cols))
+		for _, c := range cols {
+			live[strings.ToLower(c.Name())] = c
+		}
+		fields := map[string]bool{}
+		for _, f := range stmt.Schema.Fields {
+			if f.DBName == "" || f.IgnoreMigration {
+				continue
+			}
+			fields[strings.ToLower(f.DBName)] = true
+			col, ok := live[strings.ToLower(f.DBName)]
+			if !ok {
+				rep.Pending = append(rep.Pending, SchemaChange{Kind: SchemaNewColumn, Table: table, Column: f.DBName})
+				continue
+			}
+			want, got := db.Dialector.DataTypeOf(f), col.DatabaseTypeName()
+			if wf, gf := typeFamily(want), typeFamily(got); wf != "" && gf != "" && wf != gf {
+				rep.Pending = append(rep.Pending, SchemaChange{
+					Kind: SchemaTypeMismatch, Table: table, Column: f.DBName,
+					Detail: fmt.Sprintf("database has %s, model wants %s", got, want),
+				})
+			}
+		}
+		for _, idx := range stmt.Schema.ParseIndexes() {
+			if !m.HasIndex(model, idx.Name) {
+				rep.Pending = append(rep.Pending, SchemaChange{Kind: SchemaMissingIndex, Table: table, Index: idx.Name})
+			}
+		}
+		for _, c := range cols {
+			if !fields[strings.ToLower(c.Name())] {
+				rep.Drift = append(rep.Drift, SchemaChange{Kind: SchemaExtraColumn, Table: table, Column: c.Name()})
+			}
+		}
+	}
+	for _, t := range tables {
+		if !known[t] && !strings.HasPrefix(t, "sqlite_") {
+			rep.Drift = append(rep.Drift, SchemaChange{Kind: SchemaExtraTable, Table: t})
+		}
+	}
+	return rep, nil
+}
+
+// typeFamily groups driver type names so that equivalent spellings, such as
+// bigint and int8, compare equal. MySQL stores booleans as tinyint. Unknown
+// types return "".
+func typeFamily(t string) string {
+	t = strings.ToLower(t)
+	switch {
+	case strings.Contains(t, "char"), strings.Contains(t, "text"), strings.Contains(t, "clob"):
+		return "string"
+	case strings.Contains(t, "bool"), strings.HasPrefix(t, "tinyint"):
+		return "bool"
+	case strings.Contains(t, "int"), strings.Contains(t, "serial"):
+		return "integer"
+	case strings.Contains(t, "time"), strings.Contains(t, "date"):
+		return "time"
+	case strings.Contains(t, "blob"), strings.Contains(t, "bytea"), strings.Contains(t, "binary"):
+		return "bytes"
+	case strings.Contains(t, "real"), strings.Contains(t, "float"), strings.Contains(t, "double"),
+		strings.Contains(t, "decimal"), strings.Contains(t, "numeric"):
+		return "number"
+	}
+	return ""
+}
+
+// memSchemaStore has no SQL schema, so there is never anything to apply.
+type memSchemaStore struct{}
+
+func (memSchemaStore) Plan(context.Context) (SchemaReport, error) {
+	return SchemaReport{Driver: "memory", Pending: []SchemaChange{}, Drift: []SchemaChange{}}, nil
+}
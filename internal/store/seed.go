@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/charlesng35/shellcn/internal/models"
+)
+
+type gormSeedRecordStore struct{ db *gorm.DB }
+
+func (s *gormSeedRecordStore) Get(ctx context.Context, kind, externalID string) (models.SeedRecord, error) {
+	var r models.SeedRecord
+	err := s.db.WithContext(ctx).First(&r, "kind = ? AND external_id = ?", kind, externalID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return r, ErrNotFound
+	}
+	return r, err
+}
+
+func (s *gormSeedRecordStore) Put(ctx context.Context, r *models.SeedRecord) error {
+	return s.db.WithContext(ctx).Save(r).Error
+}
+
+func (s *gormSeedRecordStore) List(ctx context.Context) ([]models.SeedRecord, error) {
+	var list []models.SeedRecord
+	if err := s.db.WithContext(ctx).Order("created_at ASC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *gormSeedRecordStore) Delete(ctx context.Context, kind, externalID string) error {
+	return s.db.WithContext(ctx).Delete(&models.SeedRecord{}, "kind = ? AND external_id = ?", kind, externalID).Error
+}
+
+type memSeedRecordStore struct {
+	mu sync.Mutex
+	m  map[string]models.SeedRecord
+}
+
+func seedRecordKey(kind, externalID string) string { return kind + "\x00" + externalID }
+
+func (s *memSeedRecordStore) Get(_ context.Context, kind, externalID string) (models.SeedRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.m[seedRecordKey(kind, externalID)]
+	if !ok {
+		return models.SeedRecord{}, ErrNotFound
+	}
+	return r, nil
+}
+
+func (s *memSeedRecordStore) Put(_ context.Context, r *models.SeedRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = now
+	}
+	r.UpdatedAt = now
+	s.m[seedRecordKey(r.Kind, r.ExternalID)] = *r
+	return nil
+}
+
+func (s *memSeedRecordStore) List(_ context.Context) ([]models.SeedRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]models.SeedRecord, 0, len(s.m))
+	for _, r := range s.m {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (s *memSeedRecordStore) Delete(_ context.Context, kind, externalID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, seedRecordKey(kind, externalID))
+	return nil
+}
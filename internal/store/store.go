@@ -21,7 +21,12 @@ type UserStore interface {
 	GetByUsername(ctx context.Context, username string) (models.User, error)
 	GetByEmail(ctx context.Context, email string) (models.User, error)
 	GetPasswordHash(ctx context.Context, userID string) (string, error)
-	SetPasswordHash(ctx context.Context, userID, hash string) error
+	// SetPasswordHash also records when the password changed and clears any
+	// pending forced change.
+	SetPasswordHash(ctx context.Context, userID, hash string, at time.Time) error
+	// RequirePasswordChange flags every user with a local password set before
+	// the cutoff (or at an unknown time) and returns how many were flagged.
+	RequirePasswordChange(ctx context.Context, before time.Time) (int, error)
 	List(ctx context.Context) ([]models.User, error)
 	Update(ctx context.Context, u *models.User) error
 	Delete(ctx context.Context, id string) error
@@ -42,6 +47,24 @@ type ConnectionStore interface {
 	List(ctx context.Context) ([]models.Connection, error)
 	Update(ctx context.Context, c *models.Connection) error
 	Delete(ctx context.Context, id string) error
+	// Trash soft-deletes a live connection; Get/List stop returning it.
+	Trash(ctx context.Context, id string, at time.Time) error
+	GetTrashed(ctx context.Context, id string) (models.Connection, error)
+	// ListTrashed returns trashed connections, optionally narrowed to one owner
+	// and to those trashed before a cutoff.
+	ListTrashed(ctx context.Context, f TrashFilter) ([]models.Connection, error)
+	// Restore brings a trashed connection back under the given name.
+	Restore(ctx context.Context, id, name string) error
+	// MarkUsed records that a session was opened on the connection.
+	MarkUsed(ctx context.Context, id string, at time.Time) error
+	// SetOwner moves a live connection to ownerID; Update never changes owners.
+	SetOwner(ctx context.Context, id, ownerID string, at time.Time) error
+}
+
+// TrashFilter narrows ListTrashed. Zero fields do not filter.
+type TrashFilter struct {
+	OwnerID       string
+	TrashedBefore time.Time
 }
 
 // ConnectionFolderStore persists per-user connection folders.
@@ -49,6 +72,8 @@ type ConnectionFolderStore interface {
 	Create(ctx context.Context, f *models.ConnectionFolder) error
 	Get(ctx context.Context, id string) (models.ConnectionFolder, error)
 	ListByUser(ctx context.Context, userID string) ([]models.ConnectionFolder, error)
+	// List returns every user's folders (startup integrity checks).
+	List(ctx context.Context) ([]models.ConnectionFolder, error)
 	Update(ctx context.Context, f *models.ConnectionFolder) error
 	Delete(ctx context.Context, id string) error
 }
@@ -63,22 +88,87 @@ type ConnectionPlacementStore interface {
 	MoveFolder(ctx context.Context, userID, folderID, targetFolderID string) error
 }
 
+// ConnectionNameStore holds the name each live connection claims in its
+// owner's folder.
+type ConnectionNameStore interface {
+	// Claim records n as the connection's only claim. It returns
+	// models.ErrConflict when another connection holds the same owner, folder
+	// and name.
+	Claim(ctx context.Context, n *models.ConnectionName) error
+	Release(ctx context.Context, connectionID string) error
+	// Reset replaces every claim with list in one step.
+	Reset(ctx context.Context, list []models.ConnectionName) error
+}
+
+// ConnectionFavoriteStore persists per-user pinned connections.
+type ConnectionFavoriteStore interface {
+	// ListByUser returns the user's favorites ordered by position.
+	ListByUser(ctx context.Context, userID string) ([]models.ConnectionFavorite, error)
+	Set(ctx context.Context, f *models.ConnectionFavorite) error
+	Delete(ctx context.Context, userID, connectionID string) error
+	DeleteByConnection(ctx context.Context, connectionID string) error
+}
+
+// CredentialUsageStore persists each user's credential favorites and last use.
+type CredentialUsageStore interface {
+	ListByUser(ctx context.Context, userID string) ([]models.CredentialUsage, error)
+	SetFavorite(ctx context.Context, userID, credentialID string, favorite bool) error
+	// Touch records that userID resolved the credential at the given time.
+	Touch(ctx context.Context, userID, credentialID string, at time.Time) error
+	DeleteByCredential(ctx context.Context, credentialID string) error
+}
+
 // CredentialStore persists reusable credentials (with ciphertext material).
 type CredentialStore interface {
 	Create(ctx context.Context, c *models.Credential) error
 	Get(ctx context.Context, id string) (models.Credential, error)
 	ListByOwner(ctx context.Context, ownerID string) ([]models.Credential, error)
+	// List returns the credentials matching f, ordered by name.
+	List(ctx context.Context, f CredentialFilter) ([]models.Credential, error)
 	Update(ctx context.Context, c *models.Credential) error
+	// SetOwner moves the credential to ownerID; Update never changes owners.
+	SetOwner(ctx context.Context, id, ownerID string, at time.Time) error
 	Delete(ctx context.Context, id string) error
 }
 
+// CredentialFilter narrows a credential listing; zero fields match everything.
+type CredentialFilter struct {
+	OwnerID string
+	// IDs, when non-nil, limits the listing to these credentials.
+	IDs   []string
+	Kinds []string
+	// Protocol keeps credentials whose Protocols list names it or is empty,
+	// since an empty list means any protocol the kind supports.
+	Protocol string
+}
+
+// CredentialVersionStore keeps the value history of credentials.
+type CredentialVersionStore interface {
+	Create(ctx context.Context, v *models.CredentialVersion) error
+	// List returns a credential's versions, oldest first.
+	List(ctx context.Context, credentialID string) ([]models.CredentialVersion, error)
+	DeleteByCredential(ctx context.Context, credentialID string) error
+}
+
 // GrantStore persists per-connection sharing grants.
+// Get and ListBySubject answer "what may this user reach" and skip lapsed
+// grants; ListByConnection and GetByID return them too.
 type GrantStore interface {
 	Create(ctx context.Context, g *models.Grant) error
 	Delete(ctx context.Context, id string) error
 	Get(ctx context.Context, connectionID, subjectID string) (models.Grant, error)
+	GetByID(ctx context.Context, id string) (models.Grant, error)
 	ListByConnection(ctx context.Context, connectionID string) ([]models.Grant, error)
 	ListBySubject(ctx context.Context, subjectID string) ([]models.Grant, error)
+	// ListExpiring returns the grants on the given connections that lapse
+	// after from and no later than to, soonest first.
+	ListExpiring(ctx context.Context, connectionIDs []string, from, to time.Time) ([]models.Grant, error)
+	// ListLapsing is ListExpiring across every connection.
+	ListLapsing(ctx context.Context, from, to time.Time) ([]models.Grant, error)
+	SetAccess(ctx context.Context, id string, access models.Access) error
+	// SetExpiry sets ExpiresAt on every listed grant, or on none when any is
+	// missing.
+	SetExpiry(ctx context.Context, ids []string, expiresAt *time.Time) error
 }
 
 // CredentialGrantStore persists credential view-grants (no secret readback).
@@ -91,12 +181,52 @@ type CredentialGrantStore interface {
 }
 
 // AuditStore is append-only: records are written and read, never updated/deleted.
+// The one exception is SetResource, which fills the resource columns of
+// entries written before they existed. Seq is unique, so two writers that
+// chain onto the same head cannot both insert.
 type AuditStore interface {
 	Append(ctx context.Context, e *models.AuditEntry) error
+	// AppendBatch appends entries with multi-row inserts of at most
+	// AuditBatchSize rows each.
+	AppendBatch(ctx context.Context, entries []*models.AuditEntry) error
 	List(ctx context.Context, f AuditFilter) ([]models.AuditEntry, error)
 	// Count returns the number of entries matching the filter (Limit/Offset ignored).
 	Count(ctx context.Context, f AuditFilter) (int64, error)
 	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+	// ListUnresourced returns up to limit entries whose resource columns have
+	// never been set.
+	ListUnresourced(ctx context.Context, limit int) ([]models.AuditEntry, error)
+	SetResource(ctx context.Context, id, resourceType, resourceID string) error
+	// ChainHead returns the chained entry with the highest Seq, or
+	// ErrNotFound when no entry is chained yet.
+	ChainHead(ctx context.Context) (models.AuditEntry, error)
+	// ListChain returns up to limit chained entries with Seq above afterSeq
+	// and Time at or after since (zero for no bound), in Seq order.
+	ListChain(ctx context.Context, since time.Time, afterSeq int64, limit int) ([]models.AuditEntry, error)
+}
+
+// CredentialAccessLogStore is the append-only per-credential access log.
+type CredentialAccessLogStore interface {
+	Append(ctx context.Context, e *models.CredentialAccessLog) error
+	List(ctx context.Context, f CredentialAccessFilter) ([]models.CredentialAccessLog, error)
+	// Count returns the number of entries matching the filter (Limit/Offset ignored).
+	Count(ctx context.Context, f CredentialAccessFilter) (int64, error)
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// CredentialApprovalStore persists two-person approval requests. Approve and
+// Consume are conditional updates so concurrent callers cannot both win.
+type CredentialApprovalStore interface {
+	Create(ctx context.Context, a *models.CredentialApproval) error
+	Get(ctx context.Context, id string) (models.CredentialApproval, error)
+	// List returns matching requests, newest first.
+	List(ctx context.Context, f CredentialApprovalFilter) ([]models.CredentialApproval, error)
+	// Approve moves a request that is pending and unexpired at at to approved,
+	// usable until useBy; it reports whether it did.
+	Approve(ctx context.Context, id, approverID string, at, useBy time.Time) (bool, error)
+	// Consume spends an approved request whose window is still open at now,
+	// once; it reports whether it did.
+	Consume(ctx context.Context, id string, now time.Time) (bool, error)
 }
 
 // RecordingStore persists session-recording metadata (the blobs live elsewhere).
@@ -106,6 +236,37 @@ type RecordingStore interface {
 	Update(ctx context.Context, r *models.Recording) error
 	Delete(ctx context.Context, id string) error
 	List(ctx context.Context, f RecordingFilter) ([]models.Recording, error)
+	// Usage aggregates the recordings that still hold blobs, grouped in the
+	// database.
+	Usage(ctx context.Context, q RecordingUsageQuery) (RecordingUsage, error)
+}
+
+// RecordingUsageQuery sets the clock retention is judged by and caps each
+// breakdown at the Top largest groups (0 = all).
+type RecordingUsageQuery struct {
+	Now time.Time
+	Top int
+}
+
+// RecordingUsage is recording storage in total and by user and connection,
+// largest first.
+type RecordingUsage struct {
+	Total        RecordingUsageGroup
+	ByUser       []RecordingUsageGroup
+	ByConnection []RecordingUsageGroup
+}
+
+// RecordingUsageGroup sums one group's recordings. Reclaimable counts those
+// whose retention has passed but that the cleanup job has not yet purged.
+type RecordingUsageGroup struct {
+	ID               string
+	Name             string
+	Bytes            int64
+	Count            int64
+	Oldest           time.Time
+	Newest           time.Time
+	ReclaimableBytes int64
+	ReclaimableCount int64
 }
 
 // RecordingFilter narrows a recording query. Zero-value fields are ignored.
@@ -121,17 +282,56 @@ type RecordingFilter struct {
 	// ExpiredBefore selects recordings whose ExpiresAt is set and at/before it
 	// (used by retention cleanup). Ignored when zero.
 	ExpiredBefore time.Time
-	Limit         int
+	// ArchivableBefore selects recordings still in primary storage that ended
+	// at/before it (used by archiving). Ignored when zero.
+	ArchivableBefore time.Time
+	// Search matches case-insensitively anywhere in the connection name or
+	// username.
+	Search string
+	// Sort is one of the RecordingSort values; empty means newest first. Ties
+	// fall back to id so Offset pages are stable.
+	Sort   string
+	Limit  int
+	Offset int
 }
 
+const (
+	RecordingSortDuration   = "duration"   // longest first
+	RecordingSortConnection = "connection" // connection name A-Z
+)
+
+// AuditBatchSize caps the rows in one AppendBatch insert.
+const AuditBatchSize = 500
+
 // AuditFilter narrows an audit query.
 type AuditFilter struct {
 	UserID       string
 	ConnectionID string
+	ResourceType string
+	ResourceID   string
+	// Since and Until bound Time inclusively; zero leaves that side open.
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+	Offset int
+}
+
+// CredentialAccessFilter narrows a credential access-log query.
+type CredentialAccessFilter struct {
+	CredentialID string
 	Limit        int
 	Offset       int
 }
 
+// CredentialApprovalFilter narrows approval requests; empty fields match all.
+type CredentialApprovalFilter struct {
+	CredentialID string
+	// CredentialIDs, when set, matches requests on any of them.
+	CredentialIDs []string
+	RequesterID   string
+	Status        models.CredentialApprovalStatus
+}
+
 // PluginStorageFilter narrows generic plugin storage access. Collection,
 // Plugin, and OwnerID are required for all operations. ConnectionID is optional
 // for user-scoped reads/lists/deletes across the current user's connection rows.
@@ -198,6 +398,124 @@ type InvitationStore interface {
 	List(ctx context.Context) ([]models.Invitation, error)
 	Update(ctx context.Context, i *models.Invitation) error
 	Consume(ctx context.Context, id string, acceptedAt time.Time) (bool, error)
+	// ExpirePending marks pending invitations that lapsed by now as expired
+	// and clears their token hashes.
+	ExpirePending(ctx context.Context, now time.Time) (int, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// WebhookStore persists admin-managed webhook endpoints.
+type WebhookStore interface {
+	Create(ctx context.Context, w *models.Webhook) error
+	Get(ctx context.Context, id string) (models.Webhook, error)
+	List(ctx context.Context) ([]models.Webhook, error)
+	Update(ctx context.Context, w *models.Webhook) error
+	Delete(ctx context.Context, id string) error
+}
+
+// WebhookDeliveryStore keeps the recent delivery attempts of each webhook.
+type WebhookDeliveryStore interface {
+	Create(ctx context.Context, d *models.WebhookDelivery) error
+	// List returns a webhook's newest limit attempts, newest first.
+	List(ctx context.Context, webhookID string, limit int) ([]models.WebhookDelivery, error)
+	// Prune keeps only a webhook's newest keep attempts.
+	Prune(ctx context.Context, webhookID string, keep int) error
+	DeleteByWebhook(ctx context.Context, webhookID string) error
+}
+
+// SessionShareLinkStore persists join links for live sessions.
+type SessionShareLinkStore interface {
+	Create(ctx context.Context, l *models.SessionShareLink) error
+	Get(ctx context.Context, id string) (models.SessionShareLink, error)
+	GetByTokenHash(ctx context.Context, tokenHash string) (models.SessionShareLink, error)
+	// ListByConnection returns a connection's links, newest first.
+	ListByConnection(ctx context.Context, connectionID string) ([]models.SessionShareLink, error)
+	// Consume counts one use if the link is unrevoked, unexpired at now, and
+	// under its use limit; it reports whether a use was taken.
+	Consume(ctx context.Context, id string, now time.Time) (bool, error)
+	Revoke(ctx context.Context, id string, at time.Time) error
+}
+
+// SessionParticipantStore persists who joined which live session. A session
+// is named by its connection and the user who owns it.
+type SessionParticipantStore interface {
+	Create(ctx context.Context, p *models.SessionParticipant) error
+	Get(ctx context.Context, connectionID, ownerID, userID string) (models.SessionParticipant, error)
+	// ListByConnection returns the participants of every session on a
+	// connection, oldest first.
+	ListByConnection(ctx context.Context, connectionID string) ([]models.SessionParticipant, error)
+	// SetWriteHolder makes userID the one participant of ownerID's session
+	// holding write; "" hands write back to the owner. ErrNotFound means
+	// userID is not a participant.
+	SetWriteHolder(ctx context.Context, connectionID, ownerID, userID string) error
+	DeleteBySession(ctx context.Context, connectionID, ownerID string) error
+	DeleteByLink(ctx context.Context, linkID string) error
+}
+
+// SessionWriteRequestStore persists participants' requests to hold write.
+type SessionWriteRequestStore interface {
+	Create(ctx context.Context, r *models.SessionWriteRequest) error
+	Get(ctx context.Context, id string) (models.SessionWriteRequest, error)
+	// ListPending returns a connection's requests still pending and
+	// unexpired at now, oldest first.
+	ListPending(ctx context.Context, connectionID string, now time.Time) ([]models.SessionWriteRequest, error)
+	// Decide records an answer only while the request is still pending, and
+	// reports whether it did; replicas racing to answer see one winner.
+	Decide(ctx context.Context, id string, status models.WriteRequestStatus, decidedBy, reason string, at time.Time) (bool, error)
+	DeleteBySession(ctx context.Context, connectionID, ownerID string) error
+}
+
+// ConnectionSessionStore persists the history of upstream sessions.
+type ConnectionSessionStore interface {
+	Create(ctx context.Context, cs *models.ConnectionSession) error
+	Get(ctx context.Context, id string) (models.ConnectionSession, error)
+	// UpdateTraffic replaces the session's byte counts.
+	UpdateTraffic(ctx context.Context, id string, bytesIn, bytesOut int64, at time.Time) error
+	// End records the session's final status and byte counts.
+	End(ctx context.Context, id string, status models.ConnectionSessionStatus, reason string, bytesIn, bytesOut int64, at time.Time) error
+	// ListStale returns the active sessions last written before before,
+	// oldest first.
+	ListStale(ctx context.Context, before time.Time) ([]models.ConnectionSession, error)
+}
+
+// SessionObservationStore logs read-only observers of live sessions.
+type SessionObservationStore interface {
+	Create(ctx context.Context, o *models.SessionObservation) error
+	Get(ctx context.Context, id string) (models.SessionObservation, error)
+	End(ctx context.Context, id string, at time.Time) error
+	// EndBySession ends sessionID's open observations.
+	EndBySession(ctx context.Context, sessionID string, at time.Time) (int64, error)
+}
+
+// IdempotencyKeyStore keeps the responses retried requests replay.
+type IdempotencyKeyStore interface {
+	// Get returns the key if it has not expired at now, else ErrNotFound.
+	Get(ctx context.Context, id string, now time.Time) (models.IdempotencyKey, error)
+	// Put creates or replaces the key.
+	Put(ctx context.Context, k *models.IdempotencyKey) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// SeedRecordStore maps fixture ids to the rows the seed command created.
+type SeedRecordStore interface {
+	Get(ctx context.Context, kind, externalID string) (models.SeedRecord, error)
+	// Put creates or replaces the mapping.
+	Put(ctx context.Context, r *models.SeedRecord) error
+	// List returns every mapping, oldest first.
+	List(ctx context.Context) ([]models.SeedRecord, error)
+	Delete(ctx context.Context, kind, externalID string) error
+}
+
+// JobStore persists background jobs.
+type JobStore interface {
+	Create(ctx context.Context, j *models.Job) error
+	Get(ctx context.Context, id string) (models.Job, error)
+	// Update writes the job's mutable state: status, progress and result.
+	Update(ctx context.Context, j *models.Job) error
+	// ListByStatus returns jobs in any of the statuses, oldest first.
+	ListByStatus(ctx context.Context, statuses ...models.JobStatus) ([]models.Job, error)
+	// ListExpired returns jobs whose ExpiresAt is at or before now.
+	ListExpired(ctx context.Context, now time.Time) ([]models.Job, error)
 	Delete(ctx context.Context, id string) error
 }
 
@@ -207,6 +525,13 @@ type ProtocolSettingStore interface {
 	Set(ctx context.Context, s *models.ProtocolSetting) error
 }
 
+// SystemSettingStore persists instance-wide admin settings by key.
+type SystemSettingStore interface {
+	Get(ctx context.Context, key string) (models.SystemSetting, error)
+	List(ctx context.Context) ([]models.SystemSetting, error)
+	Set(ctx context.Context, s *models.SystemSetting) error
+}
+
 // AIProviderStore persists user-scoped AI provider configs (ciphertext keys).
 type AIProviderStore interface {
 	Create(ctx context.Context, c *models.AIProviderConfig) error
@@ -243,30 +568,91 @@ type LiveStateLeaseStore interface {
 	Renew(ctx context.Context, key, leaseID string, expiresAt, now time.Time) (bool, error)
 	PreferInternalURL(ctx context.Context, key, leaseID, internalURL string, now time.Time) (bool, error)
 	Release(ctx context.Context, key, leaseID string) error
+	// CountLive counts unexpired leases whose key starts with prefix and ends
+	// with suffix.
+	CountLive(ctx context.Context, prefix, suffix string, now time.Time) (int64, error)
 	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
 }
 
+// ActivityStore aggregates usage across the audit trail, recordings, and the
+// credential access log. Every figure is grouped in the database.
+type ActivityStore interface {
+	Summarize(ctx context.Context, q ActivityQuery) (ActivitySummary, error)
+}
+
+// ActivityQuery selects the window and the audit event counted as a session start.
+type ActivityQuery struct {
+	Since        time.Time
+	SessionEvent string
+	// Top caps TopConnections.
+	Top int
+}
+
+// ActivitySummary is the result of ActivityStore.Summarize. Days are UTC
+// calendar dates (YYYY-MM-DD) in ascending order; days without sessions are
+// omitted.
+type ActivitySummary struct {
+	SessionsPerDay       []DayCount
+	TopConnections       []ConnectionCount
+	ActiveUsers          int64
+	RecordingBytes       int64
+	CredentialRetrievals int64
+}
+
+type DayCount struct {
+	Day   string
+	Count int64
+}
+
+type ConnectionCount struct {
+	ConnectionID string
+	Count        int64
+}
+
 // Store aggregates every repository plus lifecycle controls.
 type Store struct {
 	Users                UserStore
 	Connections          ConnectionStore
 	ConnectionFolders    ConnectionFolderStore
 	ConnectionPlacements ConnectionPlacementStore
+	ConnectionNames      ConnectionNameStore
+	ConnectionFavorites  ConnectionFavoriteStore
 	Credentials          CredentialStore
+	CredentialVersions   CredentialVersionStore
+	CredentialUsage      CredentialUsageStore
 	Grants               GrantStore
 	CredentialGrants     CredentialGrantStore
 	Audit                AuditStore
+	CredentialAccess     CredentialAccessLogStore
+	CredentialApprovals  CredentialApprovalStore
 	PluginStorage        PluginStorageStore
 	Preferences          PreferenceStore
 	Enrollments          EnrollmentStore
 	Policies             PolicyStore
 	Invitations          InvitationStore
+	Webhooks             WebhookStore
+	WebhookDeliveries    WebhookDeliveryStore
+	SessionShareLinks    SessionShareLinkStore
+	SessionParticipants  SessionParticipantStore
+	SessionWriteRequests SessionWriteRequestStore
+	ConnectionSessions   ConnectionSessionStore
+	SessionObservations  SessionObservationStore
+	IdempotencyKeys      IdempotencyKeyStore
+	Jobs                 JobStore
+	SeedRecords          SeedRecordStore
 	Recordings           RecordingStore
 	ProtocolSettings     ProtocolSettingStore
+	SystemSettings       SystemSettingStore
 	AIProviders          AIProviderStore
 	AIConversations      AIConversationStore
 	AIMessages           AIMessageStore
 	LiveStateLeases      LiveStateLeaseStore
+	Activity             ActivityStore
+	SortKeys             SortKeyStore
+	Orphans              OrphanStore
+	Anonymize            AnonymizeStore
+	ConnectionUsage      ConnectionUsageStore
+	Schema               SchemaStore
 
 	close func() error
 }
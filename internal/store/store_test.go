@@ -6,7 +6,10 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
 	"slices"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -33,6 +36,15 @@ func factories() []storeFactory {
 			t.Cleanup(func() { _ = s.Close() })
 			return s
 		}},
+		{name: "sqlite-resilient", open: func(t *testing.T) *store.Store {
+			dsn := filepath.Join(t.TempDir(), "test.db")
+			s, err := store.Open(store.Config{Driver: store.DriverSQLite, DSN: dsn, Resilience: &store.Resilience{}})
+			if err != nil {
+				t.Fatalf("open sqlite: %v", err)
+			}
+			t.Cleanup(func() { _ = s.Close() })
+			return s
+		}},
 	}
 	if dsn := os.Getenv("TEST_POSTGRES_DSN"); dsn != "" {
 		fs = append(fs, storeFactory{name: "postgres", open: func(t *testing.T) *store.Store {
@@ -64,12 +76,33 @@ func TestStoreSuite(t *testing.T) {
 			t.Run("connections", func(t *testing.T) { testConnections(t, f.open(t)) })
 			t.Run("credentials", func(t *testing.T) { testCredentials(t, f.open(t)) })
 			t.Run("grants", func(t *testing.T) { testGrants(t, f.open(t)) })
+			t.Run("orphans", func(t *testing.T) { testOrphans(t, f.open(t)) })
+			t.Run("anonymize", func(t *testing.T) { testAnonymize(t, f.open(t)) })
 			t.Run("credentialReference", func(t *testing.T) { testCredentialReference(t, f.open(t)) })
 			t.Run("audit", func(t *testing.T) { testAudit(t, f.open(t)) })
+			t.Run("auditChain", func(t *testing.T) { testAuditChain(t, f.open(t)) })
+			t.Run("credentialAccess", func(t *testing.T) { testCredentialAccess(t, f.open(t)) })
+			t.Run("credentialVersions", func(t *testing.T) { testCredentialVersions(t, f.open(t)) })
+			t.Run("webhooks", func(t *testing.T) { testWebhooks(t, f.open(t)) })
+			t.Run("sessionShareLinks", func(t *testing.T) { testSessionShareLinks(t, f.open(t)) })
+			t.Run("sessionParticipants", func(t *testing.T) { testSessionParticipants(t, f.open(t)) })
+			t.Run("sessionWriteRequests", func(t *testing.T) { testSessionWriteRequests(t, f.open(t)) })
+			t.Run("connectionSessions", func(t *testing.T) { testConnectionSessions(t, f.open(t)) })
+			t.Run("sessionObservations", func(t *testing.T) { testSessionObservations(t, f.open(t)) })
+			t.Run("connectionUsage", func(t *testing.T) { testConnectionUsage(t, f.open(t)) })
+			t.Run("idempotencyKeys", func(t *testing.T) { testIdempotencyKeys(t, f.open(t)) })
+			t.Run("jobs", func(t *testing.T) { testJobs(t, f.open(t)) })
+			t.Run("credentialApprovals", func(t *testing.T) { testCredentialApprovals(t, f.open(t)) })
 			t.Run("policies", func(t *testing.T) { testPolicies(t, f.open(t)) })
 			t.Run("recordings", func(t *testing.T) { testRecordings(t, f.open(t)) })
+			t.Run("connectionFavorites", func(t *testing.T) { testConnectionFavorites(t, f.open(t)) })
+			t.Run("connectionNames", func(t *testing.T) { testConnectionNames(t, f.open(t)) })
+			t.Run("credentialUsage", func(t *testing.T) { testCredentialUsage(t, f.open(t)) })
+			t.Run("activity", func(t *testing.T) { testActivity(t, f.open(t)) })
+			t.Run("systemSettings", func(t *testing.T) { testSystemSettings(t, f.open(t)) })
 			t.Run("pluginStorage", func(t *testing.T) { testPluginStorage(t, f.open(t)) })
 			t.Run("liveStateLeases", func(t *testing.T) { testLiveStateLeases(t, f.open(t)) })
+			t.Run("sortKeys", func(t *testing.T) { testSortKeys(t, f.open(t)) })
 		})
 	}
 }
@@ -107,7 +140,7 @@ func testUsers(t *testing.T, s *store.Store) {
 		t.Errorf("update not persisted: %+v", reloaded)
 	}
 
-	if err := s.Users.SetPasswordHash(ctx, "u1", "hash2"); err != nil {
+	if err := s.Users.SetPasswordHash(ctx, "u1", "hash2", time.Now()); err != nil {
 		t.Fatalf("set password: %v", err)
 	}
 	if h, _ := s.Users.GetPasswordHash(ctx, "u1"); h != "hash2" {
@@ -117,6 +150,28 @@ func testUsers(t *testing.T, s *store.Store) {
 	if reloaded.SessionVersion != 1 {
 		t.Errorf("session version after password rotation: want 1, got %d", reloaded.SessionVersion)
 	}
+	if reloaded.PasswordChangedAt == nil || reloaded.MustChangePassword {
+		t.Errorf("password change not recorded: %+v", reloaded)
+	}
+	_ = s.Users.Create(ctx, &models.User{ID: "sso", Username: "sso"}, "")
+	if n, err := s.Users.RequirePasswordChange(ctx, reloaded.PasswordChangedAt.Add(-time.Minute)); err != nil || n != 0 {
+		t.Errorf("require change before last rotation: n=%d err=%v", n, err)
+	}
+	if n, err := s.Users.RequirePasswordChange(ctx, time.Now().Add(time.Minute)); err != nil || n != 1 {
+		t.Errorf("require change: want 1 local user flagged, got n=%d err=%v", n, err)
+	}
+	if reloaded, _ = s.Users.GetByID(ctx, "u1"); !reloaded.MustChangePassword {
+		t.Error("forced password change not persisted")
+	}
+	if err := s.Users.SetPasswordHash(ctx, "u1", "hash3", time.Now()); err != nil {
+		t.Fatalf("set password: %v", err)
+	}
+	if reloaded, _ = s.Users.GetByID(ctx, "u1"); reloaded.MustChangePassword {
+		t.Error("setting a password did not clear the forced change")
+	}
+	if err := s.Users.Delete(ctx, "sso"); err != nil {
+		t.Fatalf("delete sso: %v", err)
+	}
 
 	// Two-factor state round-trips: encrypted secret bytes, the enabled flag, and
 	// the JSON-serialized recovery code hashes.
@@ -235,6 +290,18 @@ func testLiveStateLeases(t *testing.T, s *store.Store) {
 	if err != nil || !ok {
 		t.Fatalf("renew active lease: ok=%v err=%v", ok, err)
 	}
+	for i, key := range []string{"session:c1:u_1", "session:c2:u_1", "session:c3:u_10", "session:c4:uX1"} {
+		l := &models.LiveStateLease{Key: key, InstanceID: "instance-a", LeaseID: "s" + strconv.Itoa(i), ExpiresAt: now.Add(time.Minute)}
+		if _, err := s.LiveStateLeases.Claim(ctx, l, false, now); err != nil {
+			t.Fatalf("claim %s: %v", key, err)
+		}
+	}
+	if n, err := s.LiveStateLeases.CountLive(ctx, "session:", ":u_1", now); err != nil || n != 2 {
+		t.Fatalf("count live sessions = %d err=%v, want 2", n, err)
+	}
+	if n, err := s.LiveStateLeases.CountLive(ctx, "session:", ":u_1", now.Add(2*time.Minute)); err != nil || n != 0 {
+		t.Fatalf("count after expiry = %d err=%v, want 0", n, err)
+	}
 	if ok, err := s.LiveStateLeases.Renew(ctx, "agent:c1", "lease-a", now.Add(2*time.Minute), now); err != nil || ok {
 		t.Fatalf("renew old lease should fail: ok=%v err=%v", ok, err)
 	}
@@ -299,6 +366,9 @@ func testConnections(t *testing.T, s *store.Store) {
 	}) {
 		t.Fatalf("folders not listed: %+v", folders)
 	}
+	if all, _ := s.ConnectionFolders.List(ctx); len(all) != 2 || all[0].ID != "f2" {
+		t.Fatalf("all folders not listed in order: %+v", all)
+	}
 	placements, _ := s.ConnectionPlacements.ListByUser(ctx, "u1")
 	if len(placements) != 1 || placements[0].FolderID != "f1" || placements[0].SortOrder != 3 {
 		t.Fatalf("placement not listed: %+v", placements)
@@ -323,6 +393,38 @@ func testConnections(t *testing.T, s *store.Store) {
 		t.Fatalf("move folder did not update placement: %+v", placements)
 	}
 
+	trashedAt := time.Now().UTC().Truncate(time.Second)
+	if err := s.Connections.Trash(ctx, "c1", trashedAt); err != nil {
+		t.Fatalf("trash: %v", err)
+	}
+	if _, err := s.Connections.Get(ctx, "c1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("get trashed: want ErrNotFound, got %v", err)
+	}
+	if list, _ := s.Connections.List(ctx); len(list) != 0 {
+		t.Errorf("list should hide trashed: %+v", list)
+	}
+	if err := s.Connections.Update(ctx, &got); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("update trashed: want ErrNotFound, got %v", err)
+	}
+	if trashed, err := s.Connections.GetTrashed(ctx, "c1"); err != nil || trashed.DeletedAt == nil {
+		t.Fatalf("get trashed: %+v err=%v", trashed, err)
+	}
+	if list, _ := s.Connections.ListTrashed(ctx, store.TrashFilter{OwnerID: "u1"}); len(list) != 1 {
+		t.Errorf("list trashed by owner: want 1, got %d", len(list))
+	}
+	if list, _ := s.Connections.ListTrashed(ctx, store.TrashFilter{TrashedBefore: trashedAt}); len(list) != 0 {
+		t.Errorf("list trashed before cutoff: want 0, got %d", len(list))
+	}
+	if err := s.Connections.Restore(ctx, "c1", "prod-web (restored)"); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if restored, err := s.Connections.Get(ctx, "c1"); err != nil || restored.Name != "prod-web (restored)" || restored.DeletedAt != nil {
+		t.Fatalf("restored: %+v err=%v", restored, err)
+	}
+	if err := s.Connections.Restore(ctx, "c1", "x"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("restore live: want ErrNotFound, got %v", err)
+	}
+
 	if err := s.Connections.Delete(ctx, "c1"); err != nil {
 		t.Fatalf("delete: %v", err)
 	}
@@ -640,6 +742,47 @@ func testCredentials(t *testing.T, s *store.Store) {
 	if len(list) != 1 {
 		t.Errorf("list: want 1, got %d", len(list))
 	}
+	if err := s.Credentials.SetOwner(ctx, "cr1", "u2", time.Now()); err != nil {
+		t.Fatalf("set owner: %v", err)
+	}
+	if moved, _ := s.Credentials.ListByOwner(ctx, "u2"); len(moved) != 1 || moved[0].EncryptedValues == nil {
+		t.Errorf("after set owner: %+v", moved)
+	}
+	if err := s.Credentials.SetOwner(ctx, "missing", "u2", time.Now()); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("set owner missing: %v", err)
+	}
+
+	for _, c := range []*models.Credential{
+		{ID: "cr2", Name: "any", Kind: "ssh_password", OwnerID: "u2"},
+		{ID: "cr3", Name: "pg", Kind: "ssh_password", OwnerID: "u2", Protocols: []string{"postgres"}},
+	} {
+		if err := s.Credentials.Create(ctx, c); err != nil {
+			t.Fatalf("create %s: %v", c.ID, err)
+		}
+	}
+	ids := func(f store.CredentialFilter) string {
+		list, err := s.Credentials.List(ctx, f)
+		if err != nil {
+			t.Fatalf("list %+v: %v", f, err)
+		}
+		var out []string
+		for _, c := range list {
+			out = append(out, c.ID)
+		}
+		return strings.Join(out, ",")
+	}
+	if got := ids(store.CredentialFilter{OwnerID: "u2", Protocol: "ssh"}); got != "cr2,cr1" {
+		t.Errorf("ssh filter: %s", got)
+	}
+	if got := ids(store.CredentialFilter{OwnerID: "u2", Kinds: []string{"ssh_password"}, Protocol: "postgres"}); got != "cr2,cr3" {
+		t.Errorf("kind and protocol filter: %s", got)
+	}
+	if got := ids(store.CredentialFilter{IDs: []string{"cr1", "cr3"}}); got != "cr1,cr3" {
+		t.Errorf("id filter: %s", got)
+	}
+	if got := ids(store.CredentialFilter{IDs: []string{}}); got != "" {
+		t.Errorf("empty id filter: %s", got)
+	}
 	if err := s.Credentials.Delete(ctx, "cr1"); err != nil {
 		t.Fatalf("delete: %v", err)
 	}
@@ -665,9 +808,207 @@ func testGrants(t *testing.T, s *store.Store) {
 	if bySub, _ := s.Grants.ListBySubject(ctx, "u2"); len(bySub) != 1 {
 		t.Errorf("by subject: want 1, got %d", len(bySub))
 	}
+	if err := s.Grants.SetAccess(ctx, "g1", models.AccessManage); err != nil {
+		t.Fatalf("set access: %v", err)
+	}
+	if got, _ := s.Grants.Get(ctx, "c1", "u2"); got.Access != models.AccessManage {
+		t.Errorf("after set access: %+v", got)
+	}
+	if err := s.Grants.SetAccess(ctx, "missing", models.AccessManage); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("set access missing: %v", err)
+	}
 	if err := s.Grants.Delete(ctx, "g1"); err != nil {
 		t.Fatalf("delete: %v", err)
 	}
+
+	now := time.Now()
+	lapsed, soon, later := now.Add(-time.Hour), now.Add(time.Hour), now.Add(48*time.Hour)
+	for _, g := range []models.Grant{
+		{ID: "e1", ConnectionID: "c1", SubjectID: "u3", ExpiresAt: &lapsed},
+		{ID: "e2", ConnectionID: "c1", SubjectID: "u4", ExpiresAt: &later},
+		{ID: "e3", ConnectionID: "c2", SubjectID: "u3", ExpiresAt: &soon},
+		{ID: "e4", ConnectionID: "c3", SubjectID: "u3", ExpiresAt: &soon},
+	} {
+		if err := s.Grants.Create(ctx, &g); err != nil {
+			t.Fatalf("create %s: %v", g.ID, err)
+		}
+	}
+	if _, err := s.Grants.Get(ctx, "c1", "u3"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("lapsed grant must not confer access: %v", err)
+	}
+	if got, err := s.Grants.GetByID(ctx, "e1"); err != nil || got.ExpiresAt == nil {
+		t.Errorf("get lapsed by id: %+v err=%v", got, err)
+	}
+	if bySub, _ := s.Grants.ListBySubject(ctx, "u3"); len(bySub) != 2 {
+		t.Errorf("by subject skips lapsed: %+v", bySub)
+	}
+	if byConn, _ := s.Grants.ListByConnection(ctx, "c1"); len(byConn) != 2 {
+		t.Errorf("by connection keeps lapsed: %+v", byConn)
+	}
+	expiring, err := s.Grants.ListExpiring(ctx, []string{"c1", "c2"}, now, now.Add(72*time.Hour))
+	if err != nil || len(expiring) != 2 || expiring[0].ID != "e3" || expiring[1].ID != "e2" {
+		t.Fatalf("expiring: %+v err=%v", expiring, err)
+	}
+	lapsing, err := s.Grants.ListLapsing(ctx, now.Add(-2*time.Hour), now.Add(2*time.Hour))
+	if err != nil || len(lapsing) != 3 || lapsing[0].ID != "e1" || lapsing[1].ID != "e3" || lapsing[2].ID != "e4" {
+		t.Fatalf("lapsing: %+v err=%v", lapsing, err)
+	}
+	if err := s.Grants.SetExpiry(ctx, []string{"e1", "missing"}, &later); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("set expiry with missing id: %v", err)
+	}
+	if _, err := s.Grants.Get(ctx, "c1", "u3"); err == nil {
+		t.Fatal("failed batch must not extend any grant")
+	}
+	if err := s.Grants.SetExpiry(ctx, []string{"e1", "e3"}, &later); err != nil {
+		t.Fatalf("set expiry: %v", err)
+	}
+	if _, err := s.Grants.Get(ctx, "c1", "u3"); err != nil {
+		t.Errorf("extended grant: %v", err)
+	}
+}
+
+func testOrphans(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	if err := s.Users.Create(ctx, &models.User{ID: "u1", Username: "alice"}, "h"); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := s.Connections.Create(ctx, &models.Connection{ID: "c1", Name: "kept", Protocol: "ssh", OwnerID: "u1", Transport: "direct"}); err != nil {
+		t.Fatalf("create connection: %v", err)
+	}
+	if err := s.Connections.Create(ctx, &models.Connection{ID: "c2", Name: "trashed", Protocol: "ssh", OwnerID: "u1", Transport: "direct"}); err != nil {
+		t.Fatalf("create connection: %v", err)
+	}
+	if err := s.Connections.Trash(ctx, "c2", time.Now()); err != nil {
+		t.Fatalf("trash: %v", err)
+	}
+	if err := s.Credentials.Create(ctx, &models.Credential{ID: "cr1", Name: "kept", Kind: "ssh_password", OwnerID: "u1"}); err != nil {
+		t.Fatalf("create credential: %v", err)
+	}
+	for _, g := range []models.Grant{
+		{ID: "g1", ConnectionID: "c1", SubjectID: "u1"},
+		{ID: "g2", ConnectionID: "c2", SubjectID: "u1"},
+		{ID: "g3", ConnectionID: "gone-1", SubjectID: "u1"},
+		{ID: "g4", ConnectionID: "gone-2", SubjectID: "u1"},
+		{ID: "g5", ConnectionID: "c1", SubjectID: "gone-user"},
+	} {
+		if err := s.Grants.Create(ctx, &g); err != nil {
+			t.Fatalf("create grant %s: %v", g.ID, err)
+		}
+	}
+	for _, g := range []models.CredentialGrant{
+		{ID: "cg1", CredentialID: "cr1", SubjectID: "u1"},
+		{ID: "cg2", CredentialID: "gone", SubjectID: "u1"},
+		{ID: "cg3", CredentialID: "cr1", SubjectID: "gone-user"},
+	} {
+		if err := s.CredentialGrants.Create(ctx, &g); err != nil {
+			t.Fatalf("create credential grant %s: %v", g.ID, err)
+		}
+	}
+
+	// Batches stop short once a category is clean.
+	if n, err := s.Orphans.Purge(ctx, store.OrphanGrantConnection, 1); err != nil || n != 1 {
+		t.Fatalf("first batch: n=%d err=%v", n, err)
+	}
+	if n, _ := s.Orphans.Purge(ctx, store.OrphanGrantConnection, 1); n != 1 {
+		t.Fatalf("second batch: want 1, got %d", n)
+	}
+	if n, _ := s.Orphans.Purge(ctx, store.OrphanGrantConnection, 1); n != 0 {
+		t.Fatalf("clean category: want 0, got %d", n)
+	}
+	for _, c := range []store.OrphanCategory{store.OrphanGrantSubject, store.OrphanCredentialGrantCredential, store.OrphanCredentialGrantSubject} {
+		if n, err := s.Orphans.Purge(ctx, c, 10); err != nil || n != 1 {
+			t.Fatalf("%s: n=%d err=%v", c, n, err)
+		}
+	}
+	if grants, _ := s.Grants.ListBySubject(ctx, "u1"); len(grants) != 2 {
+		t.Fatalf("grants on live and trashed connections must stay: %+v", grants)
+	}
+	if grants, _ := s.CredentialGrants.ListByCredential(ctx, "cr1"); len(grants) != 1 || grants[0].ID != "cg1" {
+		t.Fatalf("credential grants: %+v", grants)
+	}
+}
+
+func testAnonymize(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	const tomb = "deleted-u1"
+	now := time.Now().UTC().Truncate(time.Second)
+	for i, uid := range []string{"u1", "u1", "u1", "u2"} {
+		id := "r" + strconv.Itoa(i)
+		if err := s.Recordings.Create(ctx, &models.Recording{ID: id, UserID: uid, Username: "alice", Checksum: "sum-" + id, DurationMS: 1000, StartedAt: now}); err != nil {
+			t.Fatalf("create recording: %v", err)
+		}
+	}
+	_ = s.Audit.Append(ctx, &models.AuditEntry{ID: "a1", Time: now, UserID: "u1", Username: "alice", Event: "login", RemoteAddr: "10.0.0.1"})
+	_ = s.Audit.Append(ctx, &models.AuditEntry{ID: "a2", Time: now, UserID: "u2", Username: "bob", Event: "login", RemoteAddr: "10.0.0.2"})
+	_ = s.AIConversations.Create(ctx, &models.AIConversation{ID: "conv1", OwnerID: "u1", Title: "alice's db", Summary: "secret"})
+	_ = s.AIConversations.Create(ctx, &models.AIConversation{ID: "conv2", OwnerID: "u2", Title: "bob's db"})
+	for i, conv := range []string{"conv1", "conv1", "conv2"} {
+		m := models.AIMessage{ID: "m" + strconv.Itoa(i), ConversationID: conv, Seq: i, Role: "user", Content: "my email is alice@example.com"}
+		if i == 1 {
+			m.Content, m.ToolCalls = "", []models.AIToolCallRecord{{ID: "t1", Name: "exec", Input: "whoami"}}
+		}
+		if err := s.AIMessages.Append(ctx, &m); err != nil {
+			t.Fatalf("append message: %v", err)
+		}
+	}
+	_ = s.CredentialAccess.Append(ctx, &models.CredentialAccessLog{ID: "l1", Time: now, CredentialID: "cr1", UserID: "u1"})
+	_ = s.CredentialAccess.Append(ctx, &models.CredentialAccessLog{ID: "l2", Time: now, CredentialID: "cr1", UserID: "u2"})
+	_ = s.CredentialUsage.Touch(ctx, "u1", "cr1", now)
+	_ = s.Preferences.Set(ctx, &models.Preference{UserID: "u1", Key: "ui", Value: "{}", UpdatedAt: now})
+
+	// Batches stop short once a table is clean, and scrubbed rows are not
+	// counted again.
+	if n, err := s.Anonymize.Scrub(ctx, store.AnonymizeRecordings, "u1", tomb, 2); err != nil || n != 2 {
+		t.Fatalf("first recordings batch: n=%d err=%v", n, err)
+	}
+	if n, _ := s.Anonymize.Scrub(ctx, store.AnonymizeRecordings, "u1", tomb, 2); n != 1 {
+		t.Fatalf("second recordings batch: want 1, got %d", n)
+	}
+	if n, _ := s.Anonymize.Scrub(ctx, store.AnonymizeRecordings, "u1", tomb, 2); n != 0 {
+		t.Fatalf("clean recordings: want 0, got %d", n)
+	}
+	want := map[store.AnonymizeTable]int64{
+		store.AnonymizeAudit: 1, store.AnonymizeAIConversations: 1, store.AnonymizeAIMessages: 2,
+		store.AnonymizeCredentialAccess: 1, store.AnonymizeCredentialUsage: 1, store.AnonymizePreferences: 1,
+	}
+	for table, n := range want {
+		if got, err := s.Anonymize.Scrub(ctx, table, "u1", tomb, 10); err != nil || got != n {
+			t.Fatalf("%s: n=%d err=%v, want %d", table, got, err, n)
+		}
+		if got, err := s.Anonymize.Scrub(ctx, table, "u1", tomb, 10); err != nil || got != 0 {
+			t.Fatalf("%s again: n=%d err=%v", table, got, err)
+		}
+	}
+
+	r, _ := s.Recordings.Get(ctx, "r0")
+	if r.Username != tomb || r.UserID != "u1" || r.Checksum != "sum-r0" || r.DurationMS != 1000 {
+		t.Fatalf("scrubbed recording: %+v", r)
+	}
+	if r, _ := s.Recordings.Get(ctx, "r3"); r.Username != "alice" {
+		t.Fatalf("another user's recording changed: %+v", r)
+	}
+	entries, _ := s.Audit.List(ctx, store.AuditFilter{})
+	for _, e := range entries {
+		if e.UserID == "u1" && (e.Username != tomb || e.RemoteAddr != "") || e.UserID == "u2" && e.Username != "bob" {
+			t.Fatalf("audit entry after scrub: %+v", e)
+		}
+	}
+	if c, _ := s.AIConversations.Get(ctx, "conv1"); c.Title != "" || c.Summary != "" {
+		t.Fatalf("conversation not scrubbed: %+v", c)
+	}
+	msgs, _ := s.AIMessages.List(ctx, "conv1")
+	if len(msgs) != 2 || msgs[0].Content != "" || len(msgs[1].ToolCalls) != 0 {
+		t.Fatalf("messages after scrub: %+v", msgs)
+	}
+	if msgs, _ := s.AIMessages.List(ctx, "conv2"); len(msgs) != 1 || msgs[0].Content == "" {
+		t.Fatalf("another user's messages changed: %+v", msgs)
+	}
+	if n, _ := s.CredentialAccess.Count(ctx, store.CredentialAccessFilter{CredentialID: "cr1"}); n != 1 {
+		t.Fatalf("access logs left: %d", n)
+	}
+	if _, err := s.Preferences.Get(ctx, "u1", "ui"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("preference left: %v", err)
+	}
 }
 
 // testCredentialReference proves a connection can point at a reusable credential
@@ -731,6 +1072,26 @@ func testAudit(t *testing.T, s *store.Store) {
 	if len(limited) != 2 {
 		t.Errorf("limit: want 2, got %d", len(limited))
 	}
+	window := store.AuditFilter{Since: now.Add(500 * time.Millisecond), Until: now.Add(1500 * time.Millisecond)}
+	if ranged, _ := s.Audit.List(ctx, window); len(ranged) != 1 || ranged[0].ID != "a1" {
+		t.Errorf("time range: %+v", ranged)
+	}
+	if n, _ := s.Audit.Count(ctx, window); n != 1 {
+		t.Errorf("time range count: want 1, got %d", n)
+	}
+	if err := s.Audit.SetResource(ctx, "a1", "recording", "r1"); err != nil {
+		t.Fatalf("set resource: %v", err)
+	}
+	if err := s.Audit.SetResource(ctx, "missing", "recording", "r1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("set resource missing: %v", err)
+	}
+	byResource := store.AuditFilter{ResourceType: "recording", ResourceID: "r1"}
+	if got, _ := s.Audit.List(ctx, byResource); len(got) != 1 || got[0].ID != "a1" {
+		t.Errorf("resource filter: %+v", got)
+	}
+	if n, _ := s.Audit.Count(ctx, store.AuditFilter{ResourceType: "recording", ResourceID: "r2"}); n != 0 {
+		t.Errorf("resource filter other id: want 0, got %d", n)
+	}
 	removed, err := s.Audit.DeleteBefore(ctx, now.Add(1500*time.Millisecond))
 	if err != nil {
 		t.Fatalf("delete before: %v", err)
@@ -744,6 +1105,549 @@ func testAudit(t *testing.T, s *store.Store) {
 	}
 }
 
+func testAuditChain(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	if _, err := s.Audit.ChainHead(ctx); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("empty head: %v", err)
+	}
+	now := time.Now()
+	seq := func(n int64) *int64 { return &n }
+	// Entries from before the chain have no Seq and are never listed.
+	if err := s.Audit.Append(ctx, &models.AuditEntry{ID: "old", Time: now, Event: "login"}); err != nil {
+		t.Fatal(err)
+	}
+	err := s.Audit.AppendBatch(ctx, []*models.AuditEntry{
+		{ID: "c1", Time: now.Add(2 * time.Second), Event: "login", Seq: seq(1), Hash: "h1"},
+		{ID: "c2", Time: now, Event: "login", Seq: seq(2), PrevHash: "h1", Hash: "h2"},
+		{ID: "c3", Time: now.Add(time.Second), Event: "login", Seq: seq(3), PrevHash: "h2", Hash: "h3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head, err := s.Audit.ChainHead(ctx); err != nil || head.ID != "c3" || head.Hash != "h3" {
+		t.Fatalf("head = %+v, %v", head, err)
+	}
+	if err := s.Audit.Append(ctx, &models.AuditEntry{ID: "dup", Time: now, Event: "login", Seq: seq(3)}); err == nil {
+		t.Fatal("a second entry took seq 3")
+	}
+	ids := func(list []models.AuditEntry) string {
+		var out []string
+		for _, e := range list {
+			out = append(out, e.ID)
+		}
+		return strings.Join(out, ",")
+	}
+	if got, _ := s.Audit.ListChain(ctx, time.Time{}, 0, 10); ids(got) != "c1,c2,c3" || got[1].PrevHash != "h1" {
+		t.Fatalf("chain = %+v", got)
+	}
+	if got, _ := s.Audit.ListChain(ctx, time.Time{}, 1, 1); ids(got) != "c2" {
+		t.Fatalf("after 1, limit 1 = %s", ids(got))
+	}
+	if got, _ := s.Audit.ListChain(ctx, now.Add(500*time.Millisecond), 0, 10); ids(got) != "c1,c3" {
+		t.Fatalf("since = %s", ids(got))
+	}
+}
+
+func testWebhooks(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	w := &models.Webhook{ID: "w1", Name: "siem", URL: "https://example.com", Events: []string{"session.started"},
+		Enabled: true, SecretCiphertext: []byte{1}, CreatedAt: time.Now()}
+	if err := s.Webhooks.Create(ctx, w); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	w.Enabled, w.Events = false, []string{"session.closed"}
+	if err := s.Webhooks.Update(ctx, w); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	got, err := s.Webhooks.Get(ctx, "w1")
+	if err != nil || got.Enabled || !slices.Equal(got.Events, []string{"session.closed"}) || len(got.SecretCiphertext) != 1 {
+		t.Fatalf("get: %+v err=%v", got, err)
+	}
+	if err := s.Webhooks.Update(ctx, &models.Webhook{ID: "missing"}); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("update missing: %v", err)
+	}
+
+	base := time.Now()
+	for i := range 5 {
+		d := &models.WebhookDelivery{ID: "d" + string(rune('0'+i)), WebhookID: "w1", Attempt: i + 1, CreatedAt: base.Add(time.Duration(i) * time.Second)}
+		if err := s.WebhookDeliveries.Create(ctx, d); err != nil {
+			t.Fatalf("create delivery: %v", err)
+		}
+	}
+	if err := s.WebhookDeliveries.Prune(ctx, "w1", 3); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	list, err := s.WebhookDeliveries.List(ctx, "w1", 10)
+	if err != nil || len(list) != 3 || list[0].Attempt != 5 || list[2].Attempt != 3 {
+		t.Fatalf("list after prune: %+v err=%v", list, err)
+	}
+	if list, _ := s.WebhookDeliveries.List(ctx, "w1", 1); len(list) != 1 || list[0].Attempt != 5 {
+		t.Fatalf("limited list: %+v", list)
+	}
+	if err := s.WebhookDeliveries.DeleteByWebhook(ctx, "w1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Webhooks.Delete(ctx, "w1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Webhooks.Get(ctx, "w1"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("get after delete: %v", err)
+	}
+	if list, _ := s.WebhookDeliveries.List(ctx, "w1", 10); len(list) != 0 {
+		t.Fatalf("deliveries left: %+v", list)
+	}
+}
+
+func testIdempotencyKeys(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	k := &models.IdempotencyKey{
+		ID: "k1", UserID: "u1", RequestHash: "h1", Status: 201,
+		ContentType: "application/json", Body: []byte(`{"id":"c1"}`), ExpiresAt: now.Add(time.Hour),
+	}
+	if err := s.IdempotencyKeys.Put(ctx, k); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	got, err := s.IdempotencyKeys.Get(ctx, "k1", now)
+	if err != nil || got.Status != 201 || string(got.Body) != `{"id":"c1"}` || got.RequestHash != "h1" {
+		t.Fatalf("get: %+v %v", got, err)
+	}
+	if _, err := s.IdempotencyKeys.Get(ctx, "k1", now.Add(2*time.Hour)); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expired key: want ErrNotFound, got %v", err)
+	}
+
+	k.RequestHash, k.ExpiresAt = "h2", now.Add(2*time.Hour)
+	if err := s.IdempotencyKeys.Put(ctx, k); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if got, _ := s.IdempotencyKeys.Get(ctx, "k1", now); got.RequestHash != "h2" {
+		t.Fatalf("replace kept %q", got.RequestHash)
+	}
+	if err := s.IdempotencyKeys.Put(ctx, &models.IdempotencyKey{ID: "k2", UserID: "u1", ExpiresAt: now}); err != nil {
+		t.Fatalf("put k2: %v", err)
+	}
+	if n, err := s.IdempotencyKeys.DeleteExpired(ctx, now.Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("delete expired: n=%d err=%v", n, err)
+	}
+	if _, err := s.IdempotencyKeys.Get(ctx, "k1", now); err != nil {
+		t.Fatalf("live key was purged: %v", err)
+	}
+}
+
+func testJobs(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	j := &models.Job{ID: "j1", Type: "audit_export", Status: models.JobQueued, RequestedBy: "u1", Params: map[string]string{"user": "u2"}}
+	if err := s.Jobs.Create(ctx, j); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := s.Jobs.Create(ctx, &models.Job{ID: "j2", Type: "audit_export", Status: models.JobRunning}); err != nil {
+		t.Fatalf("create j2: %v", err)
+	}
+	got, err := s.Jobs.Get(ctx, "j1")
+	if err != nil || got.Type != "audit_export" || got.Params["user"] != "u2" {
+		t.Fatalf("get: %+v %v", got, err)
+	}
+
+	j.Status, j.Progress, j.Result, j.FinishedAt, j.ExpiresAt = models.JobSucceeded, 100, []byte("ok"), &now, &now
+	if err := s.Jobs.Update(ctx, j); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got, _ := s.Jobs.Get(ctx, "j1"); got.Status != models.JobSucceeded || got.Progress != 100 || string(got.Result) != "ok" {
+		t.Fatalf("update round-trip: %+v", got)
+	}
+	if err := s.Jobs.Update(ctx, &models.Job{ID: "missing"}); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("update missing: want ErrNotFound, got %v", err)
+	}
+
+	live, _ := s.Jobs.ListByStatus(ctx, models.JobQueued, models.JobRunning)
+	if len(live) != 1 || live[0].ID != "j2" {
+		t.Fatalf("list by status: %+v", live)
+	}
+	expired, _ := s.Jobs.ListExpired(ctx, now)
+	if len(expired) != 1 || expired[0].ID != "j1" {
+		t.Fatalf("list expired: %+v", expired)
+	}
+	if err := s.Jobs.Delete(ctx, "j1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := s.Jobs.Get(ctx, "j1"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("get deleted: %v", err)
+	}
+}
+
+func testSessionShareLinks(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+	l := &models.SessionShareLink{ID: "l1", ConnectionID: "c1", CreatedBy: "u1", TokenHash: "h1",
+		Mode: models.ShareRead, MaxUses: 2, ExpiresAt: now.Add(time.Hour), CreatedAt: now}
+	if err := s.SessionShareLinks.Create(ctx, l); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if got, err := s.SessionShareLinks.GetByTokenHash(ctx, "h1"); err != nil || got.ID != "l1" {
+		t.Fatalf("by token: %+v err=%v", got, err)
+	}
+	for i, want := range []bool{true, true, false} {
+		if ok, err := s.SessionShareLinks.Consume(ctx, "l1", now); err != nil || ok != want {
+			t.Fatalf("consume %d: ok=%v err=%v", i, ok, err)
+		}
+	}
+	if ok, _ := s.SessionShareLinks.Consume(ctx, "l1", now.Add(2*time.Hour)); ok {
+		t.Fatal("an expired link must not be consumed")
+	}
+
+	l2 := &models.SessionShareLink{ID: "l2", ConnectionID: "c1", CreatedBy: "u1", TokenHash: "h2",
+		Mode: models.ShareWrite, MaxUses: 5, ExpiresAt: now.Add(time.Hour), CreatedAt: now.Add(time.Second)}
+	if err := s.SessionShareLinks.Create(ctx, l2); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SessionShareLinks.Revoke(ctx, "l2", now); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if ok, _ := s.SessionShareLinks.Consume(ctx, "l2", now); ok {
+		t.Fatal("a revoked link must not be consumed")
+	}
+	if err := s.SessionShareLinks.Revoke(ctx, "missing", now); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("revoke missing: %v", err)
+	}
+	list, err := s.SessionShareLinks.ListByConnection(ctx, "c1")
+	if err != nil || len(list) != 2 || list[0].ID != "l2" || list[0].RevokedAt == nil || list[1].Uses != 2 {
+		t.Fatalf("list: %+v err=%v", list, err)
+	}
+}
+
+func testSessionParticipants(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+	for i, u := range []string{"u2", "u3"} {
+		p := &models.SessionParticipant{ID: "p" + u, ConnectionID: "c1", OwnerID: "u1", UserID: u,
+			ShareLinkID: "l1", Mode: models.ShareRead, JoinedAt: now.Add(time.Duration(i) * time.Second)}
+		if err := s.SessionParticipants.Create(ctx, p); err != nil {
+			t.Fatalf("create %s: %v", u, err)
+		}
+	}
+	dup := &models.SessionParticipant{ID: "dup", ConnectionID: "c1", OwnerID: "u1", UserID: "u2", JoinedAt: now}
+	if err := s.SessionParticipants.Create(ctx, dup); err == nil {
+		t.Fatal("joining the same session twice must fail")
+	}
+	if err := s.SessionParticipants.SetWriteHolder(ctx, "c1", "u1", "u2"); err != nil {
+		t.Fatalf("hold u2: %v", err)
+	}
+	if err := s.SessionParticipants.SetWriteHolder(ctx, "c1", "u1", "u3"); err != nil {
+		t.Fatalf("hold u3: %v", err)
+	}
+	if err := s.SessionParticipants.SetWriteHolder(ctx, "c1", "u1", "stranger"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("hold for a non-participant: %v", err)
+	}
+	list, err := s.SessionParticipants.ListByConnection(ctx, "c1")
+	if err != nil || len(list) != 2 || list[0].UserID != "u2" || list[0].WriteHolder || !list[1].WriteHolder {
+		t.Fatalf("list: %+v err=%v", list, err)
+	}
+	if err := s.SessionParticipants.SetWriteHolder(ctx, "c1", "u1", ""); err != nil {
+		t.Fatalf("hand write back: %v", err)
+	}
+	if p, _ := s.SessionParticipants.Get(ctx, "c1", "u1", "u3"); p.WriteHolder {
+		t.Fatalf("write should be back with the owner: %+v", p)
+	}
+
+	if err := s.SessionParticipants.DeleteByLink(ctx, "l1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SessionParticipants.Get(ctx, "c1", "u1", "u2"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("after link delete: %v", err)
+	}
+	_ = s.SessionParticipants.Create(ctx, &models.SessionParticipant{ID: "p4", ConnectionID: "c1", OwnerID: "u1", UserID: "u4", JoinedAt: now})
+	_ = s.SessionParticipants.Create(ctx, &models.SessionParticipant{ID: "p5", ConnectionID: "c1", OwnerID: "u9", UserID: "u4", JoinedAt: now})
+	if err := s.SessionParticipants.DeleteBySession(ctx, "c1", "u1"); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := s.SessionParticipants.ListByConnection(ctx, "c1"); len(list) != 1 || list[0].OwnerID != "u9" {
+		t.Fatalf("another owner's session should stay: %+v", list)
+	}
+}
+
+func testSessionWriteRequests(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+	for i, id := range []string{"r1", "r2", "r3"} {
+		r := &models.SessionWriteRequest{ID: id, ConnectionID: "c1", OwnerID: "u1", UserID: "u2",
+			Status: models.WriteRequestPending, CreatedAt: now.Add(time.Duration(i) * time.Second), ExpiresAt: now.Add(time.Minute)}
+		if id == "r3" {
+			r.ExpiresAt = now.Add(-time.Second)
+		}
+		if err := s.SessionWriteRequests.Create(ctx, r); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	list, err := s.SessionWriteRequests.ListPending(ctx, "c1", now)
+	if err != nil || len(list) != 2 || list[0].ID != "r1" {
+		t.Fatalf("pending should skip expired requests: %+v err=%v", list, err)
+	}
+	if ok, err := s.SessionWriteRequests.Decide(ctx, "r1", models.WriteRequestApproved, "u1", "", now); err != nil || !ok {
+		t.Fatalf("decide: ok=%v err=%v", ok, err)
+	}
+	if ok, _ := s.SessionWriteRequests.Decide(ctx, "r1", models.WriteRequestDenied, "u1", "", now); ok {
+		t.Fatal("a decided request must not be answered again")
+	}
+	if r, err := s.SessionWriteRequests.Get(ctx, "r1"); err != nil || r.Status != models.WriteRequestApproved || r.DecidedBy != "u1" {
+		t.Fatalf("get: %+v err=%v", r, err)
+	}
+	if _, err := s.SessionWriteRequests.Get(ctx, "missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("get missing: %v", err)
+	}
+	if err := s.SessionWriteRequests.DeleteBySession(ctx, "c1", "u1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SessionWriteRequests.Get(ctx, "r2"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("after session delete: %v", err)
+	}
+}
+
+func testConnectionSessions(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	cs := &models.ConnectionSession{ID: "s1", ConnectionID: "c1", UserID: "u1", Protocol: "ssh",
+		Status: models.ConnectionSessionActive, StartedAt: now, UpdatedAt: now}
+	if err := s.ConnectionSessions.Create(ctx, cs); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := s.ConnectionSessions.UpdateTraffic(ctx, "s1", 10, 200, now.Add(time.Second)); err != nil {
+		t.Fatalf("update traffic: %v", err)
+	}
+	if got, err := s.ConnectionSessions.Get(ctx, "s1"); err != nil || got.BytesIn != 10 || got.BytesOut != 200 || got.EndedAt != nil {
+		t.Fatalf("after update: %+v err=%v", got, err)
+	}
+	if err := s.ConnectionSessions.End(ctx, "s1", models.ConnectionSessionClosed, "", 12, 300, now.Add(time.Minute)); err != nil {
+		t.Fatalf("end: %v", err)
+	}
+	got, err := s.ConnectionSessions.Get(ctx, "s1")
+	if err != nil || got.Status != models.ConnectionSessionClosed || got.BytesIn != 12 || got.BytesOut != 300 ||
+		got.EndedAt == nil || !got.EndedAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("after end: %+v err=%v", got, err)
+	}
+	if err := s.ConnectionSessions.UpdateTraffic(ctx, "missing", 1, 1, now); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("update missing: %v", err)
+	}
+
+	for _, cs := range []models.ConnectionSession{
+		{ID: "stale", StartedAt: now.Add(-time.Hour), UpdatedAt: now.Add(-10 * time.Minute)},
+		{ID: "older", StartedAt: now.Add(-2 * time.Hour), UpdatedAt: now.Add(-20 * time.Minute)},
+		{ID: "fresh", StartedAt: now.Add(-time.Hour), UpdatedAt: now},
+	} {
+		cs.ConnectionID, cs.UserID, cs.Status = "c1", "u1", models.ConnectionSessionActive
+		if err := s.ConnectionSessions.Create(ctx, &cs); err != nil {
+			t.Fatalf("create %s: %v", cs.ID, err)
+		}
+	}
+	stale, err := s.ConnectionSessions.ListStale(ctx, now.Add(-5*time.Minute))
+	if err != nil || len(stale) != 2 || stale[0].ID != "older" || stale[1].ID != "stale" {
+		t.Fatalf("list stale: %+v err=%v", stale, err)
+	}
+}
+
+func testConnectionUsage(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	old := now.AddDate(0, 0, -200)
+	cutoff := now.AddDate(0, 0, -90)
+	for _, c := range []models.Connection{
+		{ID: "busy", Name: "busy", OwnerID: "u1", CreatedAt: old},
+		{ID: "idle", Name: "idle", OwnerID: "u1", CreatedAt: old},
+		{ID: "never", Name: "never", OwnerID: "u2", CreatedAt: old},
+		{ID: "fresh", Name: "fresh", OwnerID: "u1", CreatedAt: now},
+		{ID: "marked", Name: "marked", OwnerID: "u2", CreatedAt: old},
+	} {
+		if err := s.Connections.Create(ctx, &c); err != nil {
+			t.Fatalf("create %s: %v", c.ID, err)
+		}
+	}
+	for i, cs := range []models.ConnectionSession{
+		{ConnectionID: "busy", StartedAt: now.AddDate(0, 0, -1)},
+		{ConnectionID: "busy", StartedAt: now.AddDate(0, 0, -100)},
+		{ConnectionID: "idle", StartedAt: now.AddDate(0, 0, -120)},
+	} {
+		cs.ID, cs.UserID, cs.Status, cs.UpdatedAt = "s"+strconv.Itoa(i), "u1", models.ConnectionSessionClosed, cs.StartedAt
+		if err := s.ConnectionSessions.Create(ctx, &cs); err != nil {
+			t.Fatalf("create session: %v", err)
+		}
+	}
+	if err := s.Connections.MarkUsed(ctx, "marked", now); err != nil {
+		t.Fatalf("mark used: %v", err)
+	}
+	if got, err := s.Connections.Get(ctx, "marked"); err != nil || got.LastUsedAt == nil || !got.LastUsedAt.Equal(now) {
+		t.Fatalf("last used: %+v err=%v", got.LastUsedAt, err)
+	}
+	if err := s.Connections.MarkUsed(ctx, "missing", now); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("mark missing: %v", err)
+	}
+
+	u, err := s.ConnectionUsage.Usage(ctx, "busy", now.AddDate(0, 0, -30))
+	if err != nil || u.Sessions != 2 || u.RecentSessions != 1 {
+		t.Fatalf("usage busy: %+v err=%v", u, err)
+	}
+	if u, err := s.ConnectionUsage.Usage(ctx, "never", now.AddDate(0, 0, -30)); err != nil || u.Sessions != 0 || u.RecentSessions != 0 {
+		t.Fatalf("usage never: %+v err=%v", u, err)
+	}
+
+	ids := func(f store.StaleFilter) []string {
+		t.Helper()
+		list, err := s.ConnectionUsage.Stale(ctx, f)
+		if err != nil {
+			t.Fatalf("stale: %v", err)
+		}
+		out := []string{}
+		for _, c := range list {
+			out = append(out, c.ID)
+		}
+		return out
+	}
+	if got := ids(store.StaleFilter{UnusedSince: cutoff}); !slices.Equal(got, []string{"idle", "never"}) {
+		t.Fatalf("stale = %v", got)
+	}
+	if got := ids(store.StaleFilter{UnusedSince: cutoff, VisibleTo: "u1"}); !slices.Equal(got, []string{"idle"}) {
+		t.Fatalf("stale for u1 = %v", got)
+	}
+	if err := s.Grants.Create(ctx, &models.Grant{ID: "g1", ConnectionID: "never", SubjectID: "u1", Access: models.AccessView}); err != nil {
+		t.Fatalf("grant: %v", err)
+	}
+	if got := ids(store.StaleFilter{UnusedSince: cutoff, VisibleTo: "u1"}); !slices.Equal(got, []string{"idle", "never"}) {
+		t.Fatalf("stale for u1 with grant = %v", got)
+	}
+}
+
+func testSessionObservations(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	o := &models.SessionObservation{ID: "o1", SessionID: "s1", ConnectionID: "c1", ObserverID: "u2", OwnerID: "u1",
+		Disclosed: true, StartedAt: now}
+	if err := s.SessionObservations.Create(ctx, o); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := s.SessionObservations.End(ctx, "o1", now.Add(time.Minute)); err != nil {
+		t.Fatalf("end: %v", err)
+	}
+	got, err := s.SessionObservations.Get(ctx, "o1")
+	if err != nil || got.ObserverID != "u2" || !got.Disclosed || got.EndedAt == nil || !got.EndedAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("after end: %+v err=%v", got, err)
+	}
+	if err := s.SessionObservations.End(ctx, "missing", now); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("end missing: %v", err)
+	}
+
+	for _, id := range []string{"o2", "o3"} {
+		if err := s.SessionObservations.Create(ctx, &models.SessionObservation{ID: id, SessionID: "s1", ObserverID: "u3", StartedAt: now}); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	if n, err := s.SessionObservations.EndBySession(ctx, "s1", now.Add(time.Hour)); err != nil || n != 2 {
+		t.Fatalf("end by session: n=%d err=%v", n, err)
+	}
+	if got, _ := s.SessionObservations.Get(ctx, "o1"); !got.EndedAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("an ended observation should keep its end: %v", got.EndedAt)
+	}
+}
+
+func testCredentialApprovals(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+	for i, id := range []string{"a1", "a2"} {
+		if err := s.CredentialApprovals.Create(ctx, &models.CredentialApproval{
+			ID: id, CredentialID: "cred1", RequesterID: "u1", Status: models.ApprovalPending,
+			CreatedAt: now.Add(time.Duration(i) * time.Second), ExpiresAt: now.Add(time.Hour),
+		}); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	if ok, err := s.CredentialApprovals.Approve(ctx, "a1", "u2", now.Add(2*time.Hour), now.Add(3*time.Hour)); err != nil || ok {
+		t.Fatalf("approve after expiry: ok=%v err=%v", ok, err)
+	}
+	for i, want := range []bool{true, false} {
+		if ok, err := s.CredentialApprovals.Approve(ctx, "a1", "u2", now, now.Add(15*time.Minute)); err != nil || ok != want {
+			t.Fatalf("approve %d: ok=%v err=%v", i, ok, err)
+		}
+	}
+	if ok, _ := s.CredentialApprovals.Consume(ctx, "a1", now.Add(time.Hour)); ok {
+		t.Fatal("a closed use window must not be consumed")
+	}
+	for i, want := range []bool{true, false} {
+		if ok, err := s.CredentialApprovals.Consume(ctx, "a1", now.Add(time.Minute)); err != nil || ok != want {
+			t.Fatalf("consume %d: ok=%v err=%v", i, ok, err)
+		}
+	}
+	if ok, _ := s.CredentialApprovals.Consume(ctx, "a2", now); ok {
+		t.Fatal("a pending request must not be consumed")
+	}
+	got, err := s.CredentialApprovals.Get(ctx, "a1")
+	if err != nil || got.Status != models.ApprovalUsed || got.ApproverID != "u2" || got.UsedAt.IsZero() {
+		t.Fatalf("get: %+v err=%v", got, err)
+	}
+	list, err := s.CredentialApprovals.List(ctx, store.CredentialApprovalFilter{CredentialID: "cred1", RequesterID: "u1"})
+	if err != nil || len(list) != 2 || list[0].ID != "a2" {
+		t.Fatalf("list: %+v err=%v", list, err)
+	}
+	if list, _ := s.CredentialApprovals.List(ctx, store.CredentialApprovalFilter{Status: models.ApprovalPending}); len(list) != 1 || list[0].ID != "a2" {
+		t.Fatalf("pending: %+v", list)
+	}
+	if _, err := s.CredentialApprovals.Get(ctx, "missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("get missing: %v", err)
+	}
+}
+
+func testCredentialVersions(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	for _, v := range []int{2, 1} {
+		if err := s.CredentialVersions.Create(ctx, &models.CredentialVersion{
+			ID: "v" + string(rune('0'+v)), CredentialID: "cred1", Version: v,
+			Values: map[string]string{"username": "ops"}, EncryptedValues: []byte{byte(v)}, CreatedAt: time.Now(),
+		}); err != nil {
+			t.Fatalf("create v%d: %v", v, err)
+		}
+	}
+	if err := s.CredentialVersions.Create(ctx, &models.CredentialVersion{ID: "dup", CredentialID: "cred1", Version: 1}); err == nil {
+		t.Fatal("duplicate version number should be rejected")
+	}
+	list, err := s.CredentialVersions.List(ctx, "cred1")
+	if err != nil || len(list) != 2 || list[0].Version != 1 || list[1].Version != 2 || list[0].Values["username"] != "ops" {
+		t.Fatalf("list: %+v err=%v", list, err)
+	}
+	if err := s.CredentialVersions.DeleteByCredential(ctx, "cred1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if list, _ := s.CredentialVersions.List(ctx, "cred1"); len(list) != 0 {
+		t.Fatalf("versions left after delete: %+v", list)
+	}
+}
+
+func testCredentialAccess(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+	for i, credID := range []string{"k1", "k1", "k2"} {
+		e := &models.CredentialAccessLog{
+			ID: "l" + string(rune('0'+i)), Time: now.Add(time.Duration(i) * time.Second),
+			CredentialID: credID, UserID: "u1", ConnectionID: "c1", Purpose: "session.launch",
+			Result: models.AuditAllowed,
+		}
+		if err := s.CredentialAccess.Append(ctx, e); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	list, err := s.CredentialAccess.List(ctx, store.CredentialAccessFilter{CredentialID: "k1"})
+	if err != nil || len(list) != 2 || list[0].ID != "l1" {
+		t.Fatalf("list: %+v err=%v", list, err)
+	}
+	page, _ := s.CredentialAccess.List(ctx, store.CredentialAccessFilter{CredentialID: "k1", Limit: 1, Offset: 1})
+	if len(page) != 1 || page[0].ID != "l0" {
+		t.Errorf("page: %+v", page)
+	}
+	if n, _ := s.CredentialAccess.Count(ctx, store.CredentialAccessFilter{CredentialID: "k1"}); n != 2 {
+		t.Errorf("count: want 2, got %d", n)
+	}
+	removed, err := s.CredentialAccess.DeleteBefore(ctx, now.Add(1500*time.Millisecond))
+	if err != nil || removed != 2 {
+		t.Errorf("delete before: removed %d err=%v", removed, err)
+	}
+}
+
 func testRecordings(t *testing.T, s *store.Store) {
 	ctx := context.Background()
 	now := time.Now().Truncate(time.Second)
@@ -769,14 +1673,19 @@ func testRecordings(t *testing.T, s *store.Store) {
 	got.EndedAt = &ended
 	got.Size = 4096
 	got.Checksum = "abc123"
+	got.TranscriptKey = "c1/rec1.cast.transcript.json.gz"
 	got.ExpiresAt = &past
+	got.Annotations = []models.RecordingAnnotation{{Offset: 1.5, Text: "deploy starts", UserID: "u1", Live: true}}
 	if err := s.Recordings.Update(ctx, &got); err != nil {
 		t.Fatalf("update: %v", err)
 	}
 	reloaded, _ := s.Recordings.Get(ctx, "rec1")
-	if reloaded.Status != models.RecordingFinalized || reloaded.Size != 4096 || reloaded.Checksum != "abc123" {
+	if reloaded.Status != models.RecordingFinalized || reloaded.Size != 4096 || reloaded.Checksum != "abc123" || reloaded.TranscriptKey == "" {
 		t.Fatalf("update not persisted: %+v", reloaded)
 	}
+	if len(reloaded.Annotations) != 1 || reloaded.Annotations[0].Text != "deploy starts" || reloaded.Annotations[0].Offset != 1.5 {
+		t.Fatalf("annotations not persisted: %+v", reloaded.Annotations)
+	}
 
 	// A second, non-expired recording for another user/connection.
 	if err := s.Recordings.Create(ctx, &models.Recording{
@@ -801,6 +1710,82 @@ func testRecordings(t *testing.T, s *store.Store) {
 		t.Fatalf("expired filter: want [rec1], got %+v", expired)
 	}
 
+	if err := s.Recordings.Create(ctx, &models.Recording{
+		ID: "rec3", UserID: "u2", Username: "bob", ConnectionID: "c3", ConnectionName: "billing_100%",
+		Protocol: "ssh", Class: "terminal", Format: "asciicast_v2", Status: models.RecordingFinalized,
+		StartedAt: now.Add(-time.Minute), DurationMS: 9000, StorageKey: "c3/rec3.cast",
+	}); err != nil {
+		t.Fatalf("create rec3: %v", err)
+	}
+	for _, tc := range []struct {
+		search string
+		want   string
+	}{
+		{"BILLING", "rec3"},
+		{"ALI", "rec1"},
+		{"_100%", "rec3"},
+		{"g%1", ""},
+		{"%", "rec3"},
+	} {
+		found, err := s.Recordings.List(ctx, store.RecordingFilter{Search: tc.search})
+		if err != nil {
+			t.Fatalf("search %q: %v", tc.search, err)
+		}
+		var ids []string
+		for _, r := range found {
+			ids = append(ids, r.ID)
+		}
+		if strings.Join(ids, ",") != tc.want {
+			t.Fatalf("search %q = %v, want %q", tc.search, ids, tc.want)
+		}
+	}
+	if found, _ := s.Recordings.List(ctx, store.RecordingFilter{Search: "bob", Since: now}); len(found) != 0 {
+		t.Fatalf("search must combine with the time range: %+v", found)
+	}
+	byDuration, _ := s.Recordings.List(ctx, store.RecordingFilter{Sort: store.RecordingSortDuration})
+	if len(byDuration) != 3 || byDuration[0].ID != "rec3" {
+		t.Fatalf("sort by duration: %+v", byDuration)
+	}
+	byName, _ := s.Recordings.List(ctx, store.RecordingFilter{Sort: store.RecordingSortConnection})
+	if len(byName) != 3 || byName[0].ID != "rec2" || byName[1].ID != "rec3" || byName[2].ID != "rec1" {
+		t.Fatalf("sort by connection name: %+v", byName)
+	}
+
+	// Usage skips discarded rows; rec1's retention has passed.
+	if err := s.Recordings.Create(ctx, &models.Recording{
+		ID: "rec4", UserID: "u1", ConnectionID: "c1", Status: models.RecordingDiscarded, StartedAt: past, Size: 9999,
+	}); err != nil {
+		t.Fatalf("create rec4: %v", err)
+	}
+	usage, err := s.Recordings.Usage(ctx, store.RecordingUsageQuery{Now: now})
+	if err != nil {
+		t.Fatalf("usage: %v", err)
+	}
+	if tot := usage.Total; tot.Bytes != 4096 || tot.Count != 3 || tot.ReclaimableBytes != 4096 || tot.ReclaimableCount != 1 ||
+		!tot.Oldest.Equal(now.Add(-time.Minute)) || !tot.Newest.Equal(now.Add(time.Minute)) {
+		t.Fatalf("usage total: %+v", tot)
+	}
+	if len(usage.ByUser) != 2 || usage.ByUser[0].ID != "u1" || usage.ByUser[1].Name != "bob" || usage.ByUser[1].Count != 2 {
+		t.Fatalf("usage by user: %+v", usage.ByUser)
+	}
+	top, _ := s.Recordings.Usage(ctx, store.RecordingUsageQuery{Now: now, Top: 1})
+	if len(top.ByConnection) != 1 || top.ByConnection[0].ID != "c1" || top.ByConnection[0].Name != "prod" || top.ByConnection[0].ReclaimableBytes != 4096 {
+		t.Fatalf("usage by connection: %+v", top.ByConnection)
+	}
+
+	// A protected recording is held, so no longer reclaimable.
+	held, _ := s.Recordings.Get(ctx, "rec1")
+	held.Protected, held.ProtectedBy, held.ProtectedAt = true, "admin", &now
+	if err := s.Recordings.Update(ctx, &held); err != nil {
+		t.Fatalf("protect: %v", err)
+	}
+	if got, _ := s.Recordings.Get(ctx, "rec1"); !got.Protected || got.ProtectedBy != "admin" || got.ProtectedAt == nil {
+		t.Fatalf("protection round-trip: %+v", got)
+	}
+	if usage, _ := s.Recordings.Usage(ctx, store.RecordingUsageQuery{Now: now}); usage.Total.ReclaimableBytes != 0 {
+		t.Fatalf("protected recording counted reclaimable: %+v", usage.Total)
+	}
+
 	if err := s.Recordings.Delete(ctx, "rec1"); err != nil {
 		t.Fatalf("delete: %v", err)
 	}
@@ -832,3 +1817,224 @@ func testPolicies(t *testing.T, s *store.Store) {
 		t.Fatalf("delete did not remove policy: %+v", list)
 	}
 }
+
+func testActivity(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	day1 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	old := day1.Add(-60 * 24 * time.Hour)
+	entries := []models.AuditEntry{
+		{ID: "a1", Time: day1, UserID: "u1", Event: "open", ConnectionID: "c1", Result: models.AuditAllowed},
+		{ID: "a2", Time: day1.Add(time.Hour), UserID: "u2", Event: "open", ConnectionID: "c2", Result: models.AuditAllowed},
+		{ID: "a3", Time: day2, UserID: "u1", Event: "open", ConnectionID: "c1", Result: models.AuditAllowed},
+		{ID: "a4", Time: day2, UserID: "u3", Event: "open", ConnectionID: "c3", Result: models.AuditDenied},
+		{ID: "a5", Time: day2, UserID: "u4", Event: "other", Result: models.AuditAllowed},
+		{ID: "a6", Time: old, UserID: "u5", Event: "open", ConnectionID: "c1", Result: models.AuditAllowed},
+	}
+	for i := range entries {
+		if err := s.Audit.Append(ctx, &entries[i]); err != nil {
+			t.Fatalf("append audit: %v", err)
+		}
+	}
+	_ = s.Recordings.Create(ctx, &models.Recording{ID: "r1", StartedAt: day1, Size: 100})
+	_ = s.Recordings.Create(ctx, &models.Recording{ID: "r2", StartedAt: day2, Size: 50})
+	_ = s.Recordings.Create(ctx, &models.Recording{ID: "r3", StartedAt: old, Size: 1000})
+	_ = s.CredentialAccess.Append(ctx, &models.CredentialAccessLog{ID: "l1", Time: day1, CredentialID: "k1", Result: models.AuditAllowed})
+	_ = s.CredentialAccess.Append(ctx, &models.CredentialAccessLog{ID: "l2", Time: day1, CredentialID: "k1", Result: models.AuditDenied})
+
+	got, err := s.Activity.Summarize(ctx, store.ActivityQuery{Since: day1.Add(-time.Hour), SessionEvent: "open", Top: 1})
+	if err != nil {
+		t.Fatalf("summarize: %v", err)
+	}
+	wantDays := []store.DayCount{{Day: "2026-03-01", Count: 2}, {Day: "2026-03-02", Count: 1}}
+	if !reflect.DeepEqual(got.SessionsPerDay, wantDays) {
+		t.Errorf("sessions per day: %+v", got.SessionsPerDay)
+	}
+	if len(got.TopConnections) != 1 || got.TopConnections[0] != (store.ConnectionCount{ConnectionID: "c1", Count: 2}) {
+		t.Errorf("top connections: %+v", got.TopConnections)
+	}
+	if got.ActiveUsers != 3 || got.RecordingBytes != 150 || got.CredentialRetrievals != 1 {
+		t.Errorf("totals: %+v", got)
+	}
+}
+
+func testConnectionFavorites(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+	for i, id := range []string{"c1", "c2", "c3"} {
+		if err := s.ConnectionFavorites.Set(ctx, &models.ConnectionFavorite{UserID: "u1", ConnectionID: id, Position: i, CreatedAt: now}); err != nil {
+			t.Fatalf("set %s: %v", id, err)
+		}
+	}
+	_ = s.ConnectionFavorites.Set(ctx, &models.ConnectionFavorite{UserID: "u2", ConnectionID: "c2", CreatedAt: now})
+	if err := s.ConnectionFavorites.Set(ctx, &models.ConnectionFavorite{UserID: "u1", ConnectionID: "c1", Position: 5}); err != nil {
+		t.Fatalf("reposition: %v", err)
+	}
+	got, _ := s.ConnectionFavorites.ListByUser(ctx, "u1")
+	if len(got) != 3 || got[0].ConnectionID != "c2" || got[2].ConnectionID != "c1" {
+		t.Fatalf("list should follow position: %+v", got)
+	}
+	if err := s.ConnectionFavorites.DeleteByConnection(ctx, "c2"); err != nil {
+		t.Fatalf("delete by connection: %v", err)
+	}
+	if err := s.ConnectionFavorites.Delete(ctx, "u1", "c3"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	got, _ = s.ConnectionFavorites.ListByUser(ctx, "u1")
+	other, _ := s.ConnectionFavorites.ListByUser(ctx, "u2")
+	if len(got) != 1 || got[0].ConnectionID != "c1" || len(other) != 0 {
+		t.Fatalf("after deletes: u1=%+v u2=%+v", got, other)
+	}
+}
+
+func testConnectionNames(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	claim := func(connID, folder, hash string) error {
+		return s.ConnectionNames.Claim(ctx, &models.ConnectionName{ConnectionID: connID, OwnerID: "u1", FolderID: folder, NameHash: hash})
+	}
+	if err := claim("c1", "", "h1"); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if err := claim("c2", "", "h1"); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("same owner, folder and name: want conflict, got %v", err)
+	}
+	if err := claim("c2", "f1", "h1"); err != nil {
+		t.Fatalf("another folder: %v", err)
+	}
+	if err := s.ConnectionNames.Claim(ctx, &models.ConnectionName{ConnectionID: "c3", OwnerID: "u2", NameHash: "h1"}); err != nil {
+		t.Fatalf("another owner: %v", err)
+	}
+	// A new claim replaces the connection's old one.
+	if err := claim("c1", "", "h2"); err != nil {
+		t.Fatalf("reclaim: %v", err)
+	}
+	if err := claim("c2", "", "h1"); err != nil {
+		t.Fatalf("released name: %v", err)
+	}
+	if err := s.ConnectionNames.Release(ctx, "c1"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if err := claim("c4", "", "h2"); err != nil {
+		t.Fatalf("claim after release: %v", err)
+	}
+	if err := s.ConnectionNames.Reset(ctx, []models.ConnectionName{{ConnectionID: "c5", OwnerID: "u1", NameHash: "h1"}}); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if err := claim("c2", "", "h1"); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("reset should keep only its list: %v", err)
+	}
+	if err := claim("c6", "", "h2"); err != nil {
+		t.Fatalf("reset should drop earlier claims: %v", err)
+	}
+}
+
+func testCredentialUsage(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	at := time.Now().UTC().Truncate(time.Second)
+	if err := s.CredentialUsage.SetFavorite(ctx, "u1", "k1", true); err != nil {
+		t.Fatalf("favorite: %v", err)
+	}
+	if err := s.CredentialUsage.Touch(ctx, "u1", "k1", at); err != nil {
+		t.Fatalf("touch favorite: %v", err)
+	}
+	if err := s.CredentialUsage.Touch(ctx, "u1", "k2", at); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+	_ = s.CredentialUsage.Touch(ctx, "u2", "k2", at)
+	if err := s.CredentialUsage.SetFavorite(ctx, "u1", "k2", false); err != nil {
+		t.Fatalf("unfavorite: %v", err)
+	}
+	got, _ := s.CredentialUsage.ListByUser(ctx, "u1")
+	byID := map[string]models.CredentialUsage{}
+	for _, u := range got {
+		byID[u.CredentialID] = u
+	}
+	if k1 := byID["k1"]; len(got) != 2 || !k1.Favorite || k1.LastUsedAt == nil || !k1.LastUsedAt.Equal(at) {
+		t.Fatalf("touch must keep the favorite flag: %+v", got)
+	}
+	if k2 := byID["k2"]; k2.Favorite || k2.LastUsedAt == nil {
+		t.Fatalf("unfavorite must keep last use: %+v", k2)
+	}
+	if err := s.CredentialUsage.DeleteByCredential(ctx, "k2"); err != nil {
+		t.Fatalf("delete by credential: %v", err)
+	}
+	got, _ = s.CredentialUsage.ListByUser(ctx, "u1")
+	other, _ := s.CredentialUsage.ListByUser(ctx, "u2")
+	if len(got) != 1 || got[0].CredentialID != "k1" || len(other) != 0 {
+		t.Fatalf("after delete: u1=%+v u2=%+v", got, other)
+	}
+}
+
+func testSystemSettings(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	if _, err := s.SystemSettings.Get(ctx, "system.read_only"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("missing setting: want ErrNotFound, got %v", err)
+	}
+	if err := s.SystemSettings.Set(ctx, &models.SystemSetting{Key: "system.read_only", Value: "true", UpdatedBy: "u1"}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if err := s.SystemSettings.Set(ctx, &models.SystemSetting{Key: "system.read_only", Value: "false", UpdatedBy: "u2"}); err != nil {
+		t.Fatalf("overwrite: %v", err)
+	}
+	got, err := s.SystemSettings.Get(ctx, "system.read_only")
+	if err != nil || got.Value != "false" || got.UpdatedBy != "u2" || got.UpdatedAt.IsZero() {
+		t.Fatalf("get: %+v err=%v", got, err)
+	}
+	if err := s.SystemSettings.Set(ctx, &models.SystemSetting{Key: "a.first", Value: "1"}); err != nil {
+		t.Fatalf("set second: %v", err)
+	}
+	if all, _ := s.SystemSettings.List(ctx); len(all) != 2 || all[0].Key != "a.first" {
+		t.Fatalf("list: %+v", all)
+	}
+}
+
+func testSortKeys(t *testing.T, s *store.Store) {
+	ctx := context.Background()
+	for i, name := range []string{"Zebra", "Ähre", "banana"} {
+		id := strconv.Itoa(i)
+		if err := s.Connections.Create(ctx, &models.Connection{ID: "c" + id, Name: name, OwnerID: "o"}); err != nil {
+			t.Fatalf("create connection: %v", err)
+		}
+		if err := s.Credentials.Create(ctx, &models.Credential{ID: "k" + id, Name: name, Kind: "db_password", OwnerID: "o"}); err != nil {
+			t.Fatalf("create credential: %v", err)
+		}
+	}
+	names := func() (conns, creds []string) {
+		cl, err := s.Connections.ListByOwner(ctx, "o")
+		if err != nil {
+			t.Fatalf("list connections: %v", err)
+		}
+		for _, c := range cl {
+			conns = append(conns, c.Name)
+		}
+		kl, err := s.Credentials.ListByOwner(ctx, "o")
+		if err != nil {
+			t.Fatalf("list credentials: %v", err)
+		}
+		for _, c := range kl {
+			creds = append(creds, c.Name)
+		}
+		return conns, creds
+	}
+	want := []string{"Ähre", "banana", "Zebra"}
+	if conns, creds := names(); !slices.Equal(conns, want) || !slices.Equal(creds, want) {
+		t.Fatalf("root collation: connections %v, credentials %v, want %v", conns, creds, want)
+	}
+
+	if err := s.SortKeys.SetLocale("sv"); err != nil {
+		t.Fatalf("set locale: %v", err)
+	}
+	if n, err := s.SortKeys.Backfill(ctx); err != nil || n == 0 {
+		t.Fatalf("backfill: %d, %v", n, err)
+	}
+	want = []string{"banana", "Zebra", "Ähre"}
+	if conns, creds := names(); !slices.Equal(conns, want) || !slices.Equal(creds, want) {
+		t.Fatalf("swedish collation: connections %v, credentials %v, want %v", conns, creds, want)
+	}
+	if n, _ := s.SortKeys.Backfill(ctx); n != 0 {
+		t.Fatalf("second backfill rewrote %d keys", n)
+	}
+	if err := s.SortKeys.SetLocale("not a locale!"); err == nil {
+		t.Fatal("invalid locale accepted")
+	}
+}
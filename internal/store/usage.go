@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/charlesng35/shellcn/internal/models"
+)
+
+// ConnectionUsage counts the recorded sessions of one connection.
+type ConnectionUsage struct {
+	Sessions       int64 `json:"sessions"`
+	RecentSessions int64 `json:"recentSessions"`
+}
+
+// StaleFilter selects live connections with no session since UnusedSince.
+// Connections created after the cutoff are not stale yet. A non-empty
+// VisibleTo keeps only connections that user owns or has been granted.
+type StaleFilter struct {
+	UnusedSince time.Time
+	VisibleTo   string
+}
+
+type StaleConnection struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Protocol   string     `json:"protocol"`
+	OwnerID    string     `json:"ownerId"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
+
+// ConnectionUsageStore aggregates connection_sessions per connection.
+type ConnectionUsageStore interface {
+	// Usage counts every session of a connection and those started since the
+	// given time.
+	Usage(ctx context.Context, connectionID string, since time.Time) (ConnectionUsage, error)
+	// Stale lists matching connections ordered by name.
+	Stale(ctx context.Context, f StaleFilter) ([]StaleConnection, error)
+}
+
+type gormConnectionUsageStore struct{ db *gorm.DB }
+
+func (s *gormConnectionUsageStore) Usage(ctx context.Context, connectionID string, since time.Time) (ConnectionUsage, error) {
+	var u ConnectionUsage
+	err := s.db.WithContext(ctx).Model(&models.ConnectionSession{}).
+		Select("COUNT(*) AS sessions, COALESCE(SUM(CASE WHEN started_at >= ? THEN 1 ELSE 0 END), 0) AS recent_sessions", since).
+		Where("connection_id = ?", connectionID).Scan(&u).Error
+	return u, err
+}
+
+func (s *gormConnectionUsageStore) Stale(ctx context.Context, f StaleFilter) ([]StaleConnection, error) {
+	q := s.db.WithContext(ctx).Model(&models.Connection{}).
+		Select("id, name, protocol, owner_id, created_at, last_used_at").
+		Where("deleted_at IS NULL AND created_at < ?", f.UnusedSince).
+		Where("last_used_at IS NULL OR last_used_at < ?", f.UnusedSince).
+		Where("NOT EXISTS (SELECT 1 FROM connection_sessions cs WHERE cs.connection_id = connections.id AND cs.started_at >= ?)", f.UnusedSince)
+	if f.VisibleTo != "" {
+		q = q.Where("owner_id = ? OR id IN (SELECT connection_id FROM grants WHERE subject_id = ? AND (expires_at IS NULL OR expires_at > ?))",
+			f.VisibleTo, f.VisibleTo, time.Now())
+	}
+	out := []StaleConnection{}
+	if err := q.Order("name_sort, name, id").Scan(&out).Error; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+type memConnectionUsageStore struct {
+	conns    *memConnectionStore
+	sessions *memConnectionSessionStore
+	grants   *memGrantStore
+}
+
+func (s *memConnectionUsageStore) Usage(_ context.Context, connectionID string, since time.Time) (ConnectionUsage, error) {
+	s.sessions.mu.RLock()
+	defer s.sessions.mu.RUnlock()
+	var u ConnectionUsage
+	for _, cs := range s.sessions.m {
+		if cs.ConnectionID != connectionID {
+			continue
+		}
+		u.Sessions++
+		if !cs.StartedAt.Before(since) {
+			u.RecentSessions++
+		}
+	}
+	return u, nil
+}
+
+func (s *memConnectionUsageStore) Stale(_ context.Context, f StaleFilter) ([]StaleConnection, error) {
+	used := map[string]bool{}
+	s.sessions.mu.RLock()
+	for _, cs := range s.sessions.m {
+		if !cs.StartedAt.Before(f.UnusedSince) {
+			used[cs.ConnectionID] = true
+		}
+	}
+	s.sessions.mu.RUnlock()
+	granted := map[string]bool{}
+	if f.VisibleTo != "" {
+		s.grants.mu.RLock()
+		now := time.Now()
+		for _, g := range s.grants.m {
+			if g.SubjectID == f.VisibleTo && g.Active(now) {
+				granted[g.ConnectionID] = true
+			}
+		}
+		s.grants.mu.RUnlock()
+	}
+	s.conns.mu.RLock()
+	var list []models.Connection
+	for _, c := range s.conns.m {
+		switch {
+		case c.DeletedAt != nil, !c.CreatedAt.Before(f.UnusedSince), used[c.ID],
+			c.LastUsedAt != nil && !c.LastUsedAt.Before(f.UnusedSince),
+			f.VisibleTo != "" && c.OwnerID != f.VisibleTo && !granted[c.ID]:
+			continue
+		}
+		list = append(list, c)
+	}
+	s.conns.mu.RUnlock()
+	slices.SortFunc(list, func(a, b models.Connection) int {
+		if c := strings.Compare(a.NameSort, b.NameSort); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	out := make([]StaleConnection, 0, len(list))
+	for _, c := range list {
+		out = append(out, StaleConnection{
+			ID: c.ID, Name: c.Name, Protocol: c.Protocol, OwnerID: c.OwnerID,
+			CreatedAt: c.CreatedAt, LastUsedAt: c.LastUsedAt,
+		})
+	}
+	return out, nil
+}
@@ -12,26 +12,47 @@ import (
 // Check reports whether a subsystem is healthy.
 type Check func(ctx context.Context) error
 
+// Report is a Check that also names the state it found, shown in place of
+// "ok" or alongside the error.
+type Report func(ctx context.Context) (string, error)
+
 // Health aggregates named liveness/readiness checks behind a status endpoint.
 type Health struct {
 	mu     sync.RWMutex
-	checks map[string]Check
+	checks map[string]Report
+	mode   func() string
 }
 
 // NewHealth returns an empty health registry.
 func NewHealth() *Health {
-	return &Health{checks: make(map[string]Check)}
+	return &Health{checks: make(map[string]Report)}
 }
 
 // Register adds (or replaces) a named check.
 func (h *Health) Register(name string, c Check) {
+	h.RegisterReport(name, func(ctx context.Context) (string, error) {
+		return "ok", c(ctx)
+	})
+}
+
+// RegisterReport adds (or replaces) a named check that reports its state.
+func (h *Health) RegisterReport(name string, r Report) {
 	h.mu.Lock()
-	h.checks[name] = c
+	h.checks[name] = r
+	h.mu.Unlock()
+}
+
+// SetMode reports an operating mode (e.g. read-only maintenance) alongside the
+// checks. The mode is informational and never fails the health status.
+func (h *Health) SetMode(mode func() string) {
+	h.mu.Lock()
+	h.mode = mode
 	h.mu.Unlock()
 }
 
 type healthResponse struct {
 	Status string            `json:"status"`
+	Mode   string            `json:"mode,omitempty"`
 	Checks map[string]string `json:"checks,omitempty"`
 }
 
@@ -43,18 +64,27 @@ func (h *Health) Handler() http.HandlerFunc {
 		defer cancel()
 
 		h.mu.RLock()
-		checks := make(map[string]Check, len(h.checks))
+		checks := make(map[string]Report, len(h.checks))
 		maps.Copy(checks, h.checks)
+		mode := h.mode
 		h.mu.RUnlock()
 
 		resp := healthResponse{Status: "ok", Checks: map[string]string{}}
+		if mode != nil {
+			resp.Mode = mode()
+		}
 		healthy := true
 		for name, c := range checks {
-			if err := c(ctx); err != nil {
+			state, err := c(ctx)
+			switch {
+			case err == nil:
+				resp.Checks[name] = state
+			case state == "" || state == "ok":
 				healthy = false
 				resp.Checks[name] = err.Error()
-			} else {
-				resp.Checks[name] = "ok"
+			default:
+				healthy = false
+				resp.Checks[name] = state + ": " + err.Error()
 			}
 		}
 
@@ -103,12 +103,32 @@ func newRequestID() string {
 	return hex.EncodeToString(b)
 }
 
+const maxRequestIDLen = 128
+
+// validRequestID accepts an incoming id only when it is a short token, since it
+// ends up verbatim in logs, audit rows and error responses.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 // RequestIDMiddleware ensures every request carries a correlation id, echoes it
-// on the response, and stores it in the context for structured logging.
+// on the response, and stores it in the context for structured logging and
+// audit. A well-formed incoming id is kept; anything else is replaced.
 func RequestIDMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		id := r.Header.Get(RequestIDHeader)
-		if id == "" {
+		if !validRequestID(id) {
 			id = newRequestID()
 		}
 		w.Header().Set(RequestIDHeader, id)
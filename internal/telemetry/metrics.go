@@ -4,6 +4,7 @@ package telemetry
 
 import (
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -16,6 +17,7 @@ type Metrics struct {
 
 	sessionsOpen    prometheus.Gauge
 	channelsOpen    prometheus.Gauge
+	stuckCloses     prometheus.Gauge
 	wsConnections   prometheus.Gauge
 	actionLatency   *prometheus.HistogramVec
 	authzFailures   prometheus.Counter
@@ -23,8 +25,34 @@ type Metrics struct {
 	recordingsOpen  prometheus.Gauge
 	recordingBytes  prometheus.Counter
 	recordingFailed prometheus.Counter
+	recordingWrites prometheus.Counter
+	dbQueryLatency  *prometheus.HistogramVec
+	dbCircuitOpen   prometheus.Gauge
+	cacheLookups    *prometheus.CounterVec
+	credReadAlerts  *prometheus.CounterVec
+	// Recording storage, refreshed by the maintenance job.
+	recStoredBytes prometheus.Gauge
+	recStoredCount prometheus.Gauge
+	recReclaimable prometheus.Gauge
+	// Sessions by protocol: live count, lifetime at close and the sampled age
+	// of those still open.
+	sessionsActive  *prometheus.GaugeVec
+	sessionDuration *prometheus.HistogramVec
+	sessionAges     *ageCollector
+	sessionBytes    *prometheus.CounterVec
+	eventsDropped   *prometheus.CounterVec
+	eventsTruncated *prometheus.CounterVec
+	reconciled      prometheus.Counter
+	launchFailures  *prometheus.CounterVec
+	// Clipboard transfers by protocol and direction, push being browser to
+	// upstream.
+	clipboardTransfers *prometheus.CounterVec
+	clipboardBytes     *prometheus.CounterVec
 }
 
+// sessionBuckets span a quick command to a day-long session, in seconds.
+var sessionBuckets = []float64{10, 60, 300, 900, 1800, 3600, 7200, 14400, 28800, 86400}
+
 // NewMetrics registers the collectors on a fresh registry.
 func NewMetrics() *Metrics {
 	m := &Metrics{
@@ -32,6 +60,10 @@ func NewMetrics() *Metrics {
 		sessionsOpen:  prometheus.NewGauge(prometheus.GaugeOpts{Name: "shellcn_sessions_open", Help: "Open upstream sessions."}),
 		channelsOpen:  prometheus.NewGauge(prometheus.GaugeOpts{Name: "shellcn_channels_open", Help: "Open tracked channels."}),
 		wsConnections: prometheus.NewGauge(prometheus.GaugeOpts{Name: "shellcn_ws_connections", Help: "Active WebSocket connections."}),
+		stuckCloses: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "shellcn_session_closes_stuck",
+			Help: "Closed upstream sessions whose plugin Close has not returned within the close timeout.",
+		}),
 		actionLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
 			Name:    "shellcn_action_duration_seconds",
 			Help:    "Route handler latency.",
@@ -42,11 +74,76 @@ func NewMetrics() *Metrics {
 		recordingsOpen:  prometheus.NewGauge(prometheus.GaugeOpts{Name: "shellcn_recordings_active", Help: "Active session recordings."}),
 		recordingBytes:  prometheus.NewCounter(prometheus.CounterOpts{Name: "shellcn_recording_bytes_total", Help: "Bytes written to recordings."}),
 		recordingFailed: prometheus.NewCounter(prometheus.CounterOpts{Name: "shellcn_recording_failures_total", Help: "Recordings that failed to capture."}),
+		recordingWrites: prometheus.NewCounter(prometheus.CounterOpts{Name: "shellcn_recording_write_errors_total", Help: "Live recordings stopped by a blob write error."}),
+		dbQueryLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "shellcn_db_query_duration_seconds",
+			Help:    "Control-plane database statement latency.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation", "table"}),
+		dbCircuitOpen: prometheus.NewGauge(prometheus.GaugeOpts{Name: "shellcn_db_circuit_open", Help: "1 while the database circuit breaker is open."}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shellcn_cache_lookups_total",
+			Help: "In-memory cache lookups by cache and hit or miss.",
+		}, []string{"cache", "result"}),
+		credReadAlerts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shellcn_credential_read_alerts_total",
+			Help: "Credential read quota breaches by scope (user or credential).",
+		}, []string{"scope"}),
+		recStoredBytes: prometheus.NewGauge(prometheus.GaugeOpts{Name: "shellcn_recording_storage_bytes", Help: "Bytes held by stored recordings."}),
+		recStoredCount: prometheus.NewGauge(prometheus.GaugeOpts{Name: "shellcn_recording_storage_recordings", Help: "Stored recordings."}),
+		recReclaimable: prometheus.NewGauge(prometheus.GaugeOpts{Name: "shellcn_recording_reclaimable_bytes", Help: "Bytes of recordings past retention awaiting cleanup."}),
+		sessionsActive: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "shellcn_sessions_active",
+			Help: "Connected upstream sessions by protocol.",
+		}, []string{"protocol"}),
+		sessionDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "shellcn_session_duration_seconds",
+			Help:    "Upstream session lifetime at close by protocol and close state.",
+			Buckets: sessionBuckets,
+		}, []string{"protocol", "status"}),
+		sessionAges: &ageCollector{desc: prometheus.NewDesc(
+			"shellcn_session_age_seconds",
+			"Age of the upstream sessions open at the last sample, by protocol.",
+			[]string{"protocol"}, nil,
+		)},
+		sessionBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shellcn_session_bytes_total",
+			Help: "Bytes relayed through session streams by protocol and direction (in is browser to upstream).",
+		}, []string{"protocol", "direction"}),
+		eventsDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shellcn_realtime_events_dropped_total",
+			Help: "Realtime events discarded because a subscriber fell behind, by stream.",
+		}, []string{"stream"}),
+		eventsTruncated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shellcn_realtime_events_truncated_total",
+			Help: "Realtime events sent without their payload because it exceeded the message size limit, by stream.",
+		}, []string{"stream"}),
+		reconciled: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "shellcn_sessions_reconciled_total",
+			Help: "Session history rows left active by a stopped server and marked failed.",
+		}),
+		launchFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shellcn_session_launch_failures_total",
+			Help: "Upstream sessions that failed to open, by protocol and failure category.",
+		}, []string{"protocol", "category"}),
+		clipboardTransfers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shellcn_session_clipboard_transfers_total",
+			Help: "Session clipboard transfers by protocol and direction (push is browser to upstream).",
+		}, []string{"protocol", "direction"}),
+		clipboardBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "shellcn_session_clipboard_bytes_total",
+			Help: "Clipboard text relayed through sessions by protocol and direction.",
+		}, []string{"protocol", "direction"}),
 	}
 	m.reg.MustRegister(
-		m.sessionsOpen, m.channelsOpen, m.wsConnections,
+		m.sessionsOpen, m.channelsOpen, m.wsConnections, m.stuckCloses,
 		m.actionLatency, m.authzFailures, m.secretAccess,
-		m.recordingsOpen, m.recordingBytes, m.recordingFailed,
+		m.recordingsOpen, m.recordingBytes, m.recordingFailed, m.recordingWrites,
+		m.dbQueryLatency, m.dbCircuitOpen, m.cacheLookups, m.credReadAlerts,
+		m.recStoredBytes, m.recStoredCount, m.recReclaimable,
+		m.sessionsActive, m.sessionDuration, m.sessionAges, m.sessionBytes,
+		m.eventsDropped, m.eventsTruncated, m.reconciled, m.launchFailures,
+		m.clipboardTransfers, m.clipboardBytes,
 	)
 	return m
 }
@@ -63,6 +160,9 @@ func (m *Metrics) Registry() *prometheus.Registry { return m.reg }
 func (m *Metrics) SetSessions(n int) { m.sessionsOpen.Set(float64(n)) }
 func (m *Metrics) SetChannels(n int) { m.channelsOpen.Set(float64(n)) }
 
+// SetStuckCloses reflects how many upstream closes are hung in a plugin.
+func (m *Metrics) SetStuckCloses(n int) { m.stuckCloses.Set(float64(n)) }
+
 // WSOpened / WSClosed track active WebSocket connections.
 func (m *Metrics) WSOpened() { m.wsConnections.Inc() }
 func (m *Metrics) WSClosed() { m.wsConnections.Dec() }
@@ -87,3 +187,121 @@ func (m *Metrics) AddRecordingBytes(n int) { m.recordingBytes.Add(float64(n)) }
 
 // RecordingFailed counts a recording that failed to capture.
 func (m *Metrics) RecordingFailed() { m.recordingFailed.Inc() }
+
+// RecordingWriteFailed counts a live recording stopped by a write error.
+func (m *Metrics) RecordingWriteFailed() { m.recordingWrites.Inc() }
+
+// SetRecordingStorage publishes the recording storage totals.
+func (m *Metrics) SetRecordingStorage(bytes, count, reclaimable int64) {
+	m.recStoredBytes.Set(float64(bytes))
+	m.recStoredCount.Set(float64(count))
+	m.recReclaimable.Set(float64(reclaimable))
+}
+
+// ObserveDBQuery records a database statement's latency by operation + table.
+func (m *Metrics) ObserveDBQuery(operation, table string, d time.Duration) {
+	m.dbQueryLatency.WithLabelValues(operation, table).Observe(d.Seconds())
+}
+
+// SetDBCircuitOpen reflects the database circuit breaker state.
+func (m *Metrics) SetDBCircuitOpen(open bool) {
+	if open {
+		m.dbCircuitOpen.Set(1)
+		return
+	}
+	m.dbCircuitOpen.Set(0)
+}
+
+// ObserveCacheLookup counts a lookup in the named in-memory cache.
+func (m *Metrics) ObserveCacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cacheLookups.WithLabelValues(cache, result).Inc()
+}
+
+// IncCredentialReadAlert counts a credential read quota breach.
+func (m *Metrics) IncCredentialReadAlert(scope string) {
+	m.credReadAlerts.WithLabelValues(scope).Inc()
+}
+
+// SessionOpened / SessionClosed track connected sessions by protocol; a close
+// also records the session's lifetime under its close state.
+func (m *Metrics) SessionOpened(protocol string) { m.sessionsActive.WithLabelValues(protocol).Inc() }
+func (m *Metrics) SessionClosed(protocol, status string, d time.Duration) {
+	m.sessionsActive.WithLabelValues(protocol).Dec()
+	m.sessionDuration.WithLabelValues(protocol, status).Observe(d.Seconds())
+}
+
+// AddSessionBytes counts relayed session traffic.
+func (m *Metrics) AddSessionBytes(protocol string, in, out int64) {
+	if in > 0 {
+		m.sessionBytes.WithLabelValues(protocol, "in").Add(float64(in))
+	}
+	if out > 0 {
+		m.sessionBytes.WithLabelValues(protocol, "out").Add(float64(out))
+	}
+}
+
+// SetSessionAges replaces the sampled age distribution of open sessions.
+func (m *Metrics) SetSessionAges(ages map[string][]time.Duration) { m.sessionAges.set(ages) }
+
+// IncLaunchFailure counts a session that failed to open.
+func (m *Metrics) IncLaunchFailure(protocol, category string) {
+	m.launchFailures.WithLabelValues(protocol, category).Inc()
+}
+
+// ObserveClipboard counts one clipboard transfer of n bytes.
+func (m *Metrics) ObserveClipboard(protocol, direction string, n int) {
+	m.clipboardTransfers.WithLabelValues(protocol, direction).Inc()
+	m.clipboardBytes.WithLabelValues(protocol, direction).Add(float64(n))
+}
+
+// AddSessionsReconciled counts session rows failed by reconciliation.
+func (m *Metrics) AddSessionsReconciled(n int) { m.reconciled.Add(float64(n)) }
+
+// AddEventsDropped counts realtime events a slow subscriber lost on stream.
+func (m *Metrics) AddEventsDropped(stream string, n int64) {
+	m.eventsDropped.WithLabelValues(stream).Add(float64(n))
+}
+
+// IncEventTruncated counts a realtime event whose payload was left out for
+// being too large.
+func (m *Metrics) IncEventTruncated(stream string) { m.eventsTruncated.WithLabelValues(stream).Inc() }
+
+// ageCollector exports the last sample as one histogram per protocol. It is
+// rebuilt on every sample rather than accumulated, so a session is counted
+// once however long it lives.
+type ageCollector struct {
+	desc *prometheus.Desc
+	mu   sync.Mutex
+	ages map[string][]time.Duration
+}
+
+func (c *ageCollector) set(ages map[string][]time.Duration) {
+	c.mu.Lock()
+	c.ages = ages
+	c.mu.Unlock()
+}
+
+func (c *ageCollector) Describe(ch chan<- *prometheus.Desc) { ch <- c.desc }
+
+func (c *ageCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for protocol, ages := range c.ages {
+		var sum float64
+		buckets := make(map[float64]uint64, len(sessionBuckets))
+		for _, age := range ages {
+			s := age.Seconds()
+			sum += s
+			for _, b := range sessionBuckets {
+				if s <= b {
+					buckets[b]++
+				}
+			}
+		}
+		ch <- prometheus.MustNewConstHistogram(c.desc, uint64(len(ages)), sum, buckets, protocol)
+	}
+}
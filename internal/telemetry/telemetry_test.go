@@ -21,6 +21,16 @@ func TestMetricsExposed(t *testing.T) {
 	m.ObserveAction("write", "allowed", 12*time.Millisecond)
 	m.IncAuthzFailure()
 	m.IncSecretAccess()
+	m.ObserveDBQuery("select", "users", 3*time.Millisecond)
+	m.ObserveCacheLookup("protocols", true)
+	m.ObserveCacheLookup("protocols", false)
+	m.IncCredentialReadAlert("user")
+	m.SetRecordingStorage(4096, 2, 1024)
+	m.SessionOpened("ssh")
+	m.SessionOpened("ssh")
+	m.SessionClosed("ssh", "closed", 90*time.Second)
+	m.SetSessionAges(map[string][]time.Duration{"rdp": {30 * time.Second, 2 * time.Hour}})
+	m.AddSessionBytes("ssh", 12, 3000)
 
 	rec := httptest.NewRecorder()
 	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
@@ -32,6 +42,18 @@ func TestMetricsExposed(t *testing.T) {
 		"shellcn_authz_failures_total 1",
 		"shellcn_secret_access_total 1",
 		"shellcn_action_duration_seconds",
+		`shellcn_db_query_duration_seconds_count{operation="select",table="users"} 1`,
+		`shellcn_cache_lookups_total{cache="protocols",result="hit"} 1`,
+		`shellcn_session_bytes_total{direction="out",protocol="ssh"} 3000`,
+		`shellcn_cache_lookups_total{cache="protocols",result="miss"} 1`,
+		`shellcn_credential_read_alerts_total{scope="user"} 1`,
+		"shellcn_recording_storage_bytes 4096",
+		"shellcn_recording_storage_recordings 2",
+		"shellcn_recording_reclaimable_bytes 1024",
+		`shellcn_sessions_active{protocol="ssh"} 1`,
+		`shellcn_session_duration_seconds_bucket{protocol="ssh",status="closed",le="300"} 1`,
+		`shellcn_session_age_seconds_bucket{protocol="rdp",le="60"} 1`,
+		`shellcn_session_age_seconds_count{protocol="rdp"} 2`,
 	} {
 		if !strings.Contains(body, want) {
 			t.Errorf("metrics output missing %q", want)
@@ -52,6 +74,13 @@ func TestHealthHandler(t *testing.T) {
 		t.Errorf("body: %s", rec.Body.String())
 	}
 
+	h.SetMode(func() string { return "read_only" })
+	rec = httptest.NewRecorder()
+	h.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"mode":"read_only"`) {
+		t.Errorf("mode: want 200 with read_only, got %d %s", rec.Code, rec.Body.String())
+	}
+
 	h.Register("broken", func(context.Context) error { return errors.New("down") })
 	rec = httptest.NewRecorder()
 	h.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
@@ -61,6 +90,20 @@ func TestHealthHandler(t *testing.T) {
 	if !strings.Contains(rec.Body.String(), "down") {
 		t.Errorf("degraded body should name the failing check: %s", rec.Body.String())
 	}
+
+	h.Register("broken", func(context.Context) error { return nil })
+	h.RegisterReport("vault", func(context.Context) (string, error) { return "crypto_ok", nil })
+	rec = httptest.NewRecorder()
+	h.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"vault":"crypto_ok"`) {
+		t.Errorf("report: want 200 with its state, got %d %s", rec.Code, rec.Body.String())
+	}
+	h.RegisterReport("vault", func(context.Context) (string, error) { return "key_mismatch", errors.New("bad tag") })
+	rec = httptest.NewRecorder()
+	h.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"vault":"key_mismatch: bad tag"`) {
+		t.Errorf("failed report: want 503 with state and error, got %d %s", rec.Code, rec.Body.String())
+	}
 }
 
 func TestRequestIDMiddleware(t *testing.T) {
@@ -86,6 +129,17 @@ func TestRequestIDMiddleware(t *testing.T) {
 	if seen != "abc123" {
 		t.Errorf("incoming request id not preserved: %q", seen)
 	}
+
+	// A malformed incoming id is replaced rather than echoed into logs.
+	for _, bad := range []string{"a b\nforged=1", strings.Repeat("x", 200)} {
+		req = httptest.NewRequest(http.MethodGet, "/x", nil)
+		req.Header.Set(telemetry.RequestIDHeader, bad)
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if seen == bad || seen == "" || rec.Header().Get(telemetry.RequestIDHeader) != seen {
+			t.Errorf("malformed request id %q: got %q", bad, seen)
+		}
+	}
 }
 
 func TestLoggerWrites(_ *testing.T) {
@@ -80,6 +80,13 @@ func newTargetAllowlist(config map[string]any) targetAllowlist {
 		unix:    map[string]bool{},
 		ports:   map[string]bool{},
 	}
+	t.addConfig(config)
+	return t
+}
+
+// addConfig declares every host/port in config, recursing into array items and
+// objects so targets such as jump-host chains are dialable too.
+func (t *targetAllowlist) addConfig(config map[string]any) {
 	rangeStarts := map[string]int{}
 	rangeEnds := map[string]int{}
 	for key, value := range config {
@@ -94,6 +101,18 @@ func newTargetAllowlist(config map[string]any) targetAllowlist {
 			}
 			continue
 		}
+		switch v := value.(type) {
+		case map[string]any:
+			t.addConfig(v)
+			continue
+		case []any:
+			for _, item := range v {
+				if obj, ok := item.(map[string]any); ok {
+					t.addConfig(obj)
+				}
+			}
+			continue
+		}
 		s, ok := value.(string)
 		if !ok || strings.TrimSpace(s) == "" {
 			continue
@@ -105,7 +124,6 @@ func newTargetAllowlist(config map[string]any) targetAllowlist {
 			t.addPortRange(start, end)
 		}
 	}
-	return t
 }
 
 func (t targetAllowlist) addString(raw string) {
@@ -87,6 +87,34 @@ func TestDirectForConnectionOnlyDialsDeclaredTarget(t *testing.T) {
 	}
 }
 
+func TestDirectForConnectionAllowsNestedHops(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer func() { _ = ln.Close() }()
+	go func() {
+		c, err := ln.Accept()
+		if err == nil {
+			_ = c.Close()
+		}
+	}()
+	host, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatalf("split addr: %v", err)
+	}
+	d := transport.NewDirectForConnection(models.Connection{Config: map[string]any{
+		"host":       "10.0.0.5",
+		"port":       22,
+		"jump_hosts": []any{map[string]any{"host": host, "port": port}},
+	}})
+	c, err := d.DialContext(context.Background(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("first hop dial: %v", err)
+	}
+	_ = c.Close()
+}
+
 func TestDirectForConnectionAllowsLoopbackAliases(t *testing.T) {
 	ln, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
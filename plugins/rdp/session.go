@@ -13,7 +13,9 @@ import (
 )
 
 // Session holds the per-connection RDP dial parameters. grdp opens its own TCP
-// connection, so RDP supports direct transport only.
+// connection, so RDP supports direct transport only. grdp has no clipboard
+// virtual channel either, so Session does not implement
+// plugin.ClipboardSupporter.
 type Session struct {
 	addr     string
 	user     string
@@ -79,16 +79,21 @@ func filesTab() plugin.Panel {
 		Config: plugin.FileBrowserConfig{
 			PathParam: "path",
 			Routes: plugin.FileBrowserRoutes{
-				Read:     "sftp.sftp.read",
-				Download: "sftp.sftp.download",
-				Write:    "sftp.sftp.write",
-				Mkdir:    "sftp.sftp.mkdir",
-				Rename:   "sftp.sftp.rename",
-				Delete:   "sftp.sftp.delete",
-				Move:     "sftp.sftp.move",
-				Copy:     "sftp.sftp.copy",
-				Chmod:    "sftp.sftp.chmod",
-				Archive:  "sftp.sftp.archive",
+				Read:           "sftp.sftp.read",
+				Download:       "sftp.sftp.download",
+				Write:          "sftp.sftp.write",
+				Mkdir:          "sftp.sftp.mkdir",
+				Rename:         "sftp.sftp.rename",
+				Delete:         "sftp.sftp.delete",
+				Move:           "sftp.sftp.move",
+				MoveBatch:      "sftp.sftp.move_batch",
+				Copy:           "sftp.sftp.copy",
+				Chmod:          "sftp.sftp.chmod",
+				Archive:        "sftp.sftp.archive",
+				Bookmarks:      "sftp.sftp.bookmark.list",
+				BookmarkCreate: "sftp.sftp.bookmark.create",
+				BookmarkUpdate: "sftp.sftp.bookmark.update",
+				BookmarkDelete: "sftp.sftp.bookmark.delete",
 			},
 			Upload: plugin.FileUploadConfig{
 				RouteID:   "sftp.sftp.upload",
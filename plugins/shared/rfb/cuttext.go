@@ -0,0 +1,83 @@
+package rfb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RFB server→client message types a client that never requests framebuffer
+// updates can still receive.
+const (
+	msgSetColourMapEntries = 1
+	msgBell                = 2
+	msgServerCutText       = 3
+)
+
+// maxCutText bounds the server cut text held in memory; longer text is
+// skipped.
+const maxCutText = 1 << 20
+
+// WriteClientCutText sends text as a ClientCutText message. RFB cut text is
+// Latin-1, so characters outside it become '?'.
+func WriteClientCutText(w io.Writer, text string) error {
+	latin := make([]byte, 0, len(text))
+	for _, r := range text {
+		if r > 0xff {
+			r = '?'
+		}
+		latin = append(latin, byte(r))
+	}
+	msg := make([]byte, 8, 8+len(latin))
+	msg[0] = msgClientCutText
+	binary.BigEndian.PutUint32(msg[4:], uint32(len(latin)))
+	_, err := w.Write(append(msg, latin...))
+	return err
+}
+
+// ReadServerCutText reads server messages until a ServerCutText arrives and
+// returns its text as UTF-8. It is meant for a connection that never asked
+// for framebuffer updates, and fails on any message it cannot size.
+func ReadServerCutText(r io.Reader) (string, error) {
+	typ := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(r, typ); err != nil {
+			return "", err
+		}
+		switch typ[0] {
+		case msgBell:
+		case msgSetColourMapEntries:
+			head := make([]byte, 5) // 1 padding + 2 first colour + 2 count
+			if _, err := io.ReadFull(r, head); err != nil {
+				return "", err
+			}
+			if _, err := io.CopyN(io.Discard, r, 6*int64(binary.BigEndian.Uint16(head[3:]))); err != nil {
+				return "", err
+			}
+		case msgServerCutText:
+			head := make([]byte, 7) // 3 padding + 4 length
+			if _, err := io.ReadFull(r, head); err != nil {
+				return "", err
+			}
+			n := int64(binary.BigEndian.Uint32(head[3:]))
+			if n > maxCutText {
+				if _, err := io.CopyN(io.Discard, r, n); err != nil {
+					return "", err
+				}
+				continue
+			}
+			latin := make([]byte, n)
+			if _, err := io.ReadFull(r, latin); err != nil {
+				return "", err
+			}
+			var b strings.Builder
+			for _, c := range latin {
+				b.WriteRune(rune(c))
+			}
+			return b.String(), nil
+		default:
+			return "", fmt.Errorf("unexpected server message type %d", typ[0])
+		}
+	}
+}
@@ -220,3 +220,18 @@ func writeInt32(w io.Writer, v int32) {
 	binary.BigEndian.PutUint32(b[:], uint32(v))
 	_, _ = w.Write(b[:])
 }
+
+func TestReadServerCutTextSkipsOtherMessages(t *testing.T) {
+	var in bytes.Buffer
+	in.Write([]byte{msgSetColourMapEntries, 0, 0, 0, 0, 1, 1, 2, 3, 4, 5, 6})
+	in.Write([]byte{msgBell})
+	in.Write([]byte{msgServerCutText, 0, 0, 0, 0, 0, 0, 2, 'o', 'k'})
+	in.Write([]byte{0})
+	text, err := ReadServerCutText(&in)
+	if err != nil || text != "ok" {
+		t.Fatalf("cut text = %q, %v", text, err)
+	}
+	if _, err := ReadServerCutText(&in); err == nil {
+		t.Fatal("a framebuffer update cannot be sized and must fail")
+	}
+}
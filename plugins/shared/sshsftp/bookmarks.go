@@ -0,0 +1,224 @@
+package sshsftp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	bookmarkStorageCollection = "sftp_bookmarks"
+	maxBookmarks              = 50
+)
+
+// bookmark is a saved remote directory. Bookmarks live in connection-scoped
+// plugin storage, which core keys by user too, so they outlive any one session.
+type bookmark struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type bookmarkRequest struct {
+	Name string `json:"name" validate:"required"`
+	Path string `json:"path" validate:"required"`
+}
+
+type bookmarkStore struct {
+	storage plugin.Storage
+}
+
+func newBookmarkStore(storage plugin.Storage) *bookmarkStore {
+	if storage == nil {
+		return nil
+	}
+	return &bookmarkStore{storage: storage}
+}
+
+func (s *bookmarkStore) List(ctx context.Context) ([]bookmark, error) {
+	rows, err := s.storage.List(ctx, bookmarkStorageScope(), nil)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]bookmark, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, bookmarkFromStorageItem(row))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return strings.ToLower(out[i].Name) < strings.ToLower(out[j].Name)
+	})
+	return out, nil
+}
+
+func (s *bookmarkStore) Get(ctx context.Context, id string) (bookmark, error) {
+	item, err := s.storage.Get(ctx, bookmarkStorageScope(), id)
+	if err != nil {
+		return bookmark{}, err
+	}
+	return bookmarkFromStorageItem(item), nil
+}
+
+func (s *bookmarkStore) Create(ctx context.Context, b *bookmark) error {
+	existing, err := s.storage.List(ctx, bookmarkStorageScope(), nil)
+	if err != nil {
+		return err
+	}
+	if len(existing) >= maxBookmarks {
+		return fmt.Errorf("%w: at most %d bookmarks per connection", plugin.ErrInvalidInput, maxBookmarks)
+	}
+	return s.put(ctx, b)
+}
+
+func (s *bookmarkStore) Update(ctx context.Context, b *bookmark) error {
+	return s.put(ctx, b)
+}
+
+func (s *bookmarkStore) Delete(ctx context.Context, id string) error {
+	return s.storage.Delete(ctx, bookmarkStorageScope(), id)
+}
+
+func (s *bookmarkStore) put(ctx context.Context, b *bookmark) error {
+	item, err := bookmarkToStorageItem(*b)
+	if err != nil {
+		return err
+	}
+	stored, err := s.storage.Put(ctx, bookmarkStorageCollection, item)
+	if err != nil {
+		return err
+	}
+	*b = bookmarkFromStorageItem(stored)
+	return nil
+}
+
+func bookmarkStorageScope() plugin.StorageScope {
+	return plugin.ConnectionStorage(bookmarkStorageCollection)
+}
+
+type bookmarkValue struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+func bookmarkToStorageItem(b bookmark) (plugin.StorageItem, error) {
+	body, err := json.Marshal(bookmarkValue{Name: b.Name, Path: b.Path})
+	if err != nil {
+		return plugin.StorageItem{}, err
+	}
+	return plugin.StorageItem{
+		Key:         b.ID,
+		Value:       body,
+		ContentType: "application/vnd.shellcn.sftp-bookmark+json",
+		Metadata:    map[string]string{"name": b.Name},
+		CreatedAt:   b.CreatedAt,
+		UpdatedAt:   b.UpdatedAt,
+	}, nil
+}
+
+func bookmarkFromStorageItem(item plugin.StorageItem) bookmark {
+	var value bookmarkValue
+	_ = json.Unmarshal(item.Value, &value)
+	return bookmark{
+		ID:        item.Key,
+		Name:      value.Name,
+		Path:      value.Path,
+		CreatedAt: item.CreatedAt,
+		UpdatedAt: item.UpdatedAt,
+	}
+}
+
+func bookmarkSchema() *plugin.Schema {
+	return &plugin.Schema{Groups: []plugin.Group{{Name: "Bookmark", Fields: []plugin.Field{
+		{Key: "name", Label: "Name", Type: plugin.FieldText, Required: true, Placeholder: "Release logs"},
+		{Key: "path", Label: "Path", Type: plugin.FieldText, Required: true, Placeholder: "/var/www/releases/current/logs"},
+	}}}}
+}
+
+// bindBookmark validates a request through the same path sanitizer the file
+// routes use, so a bookmark always names a path those routes would accept.
+func bindBookmark(rc *plugin.RequestContext) (bookmarkRequest, error) {
+	var req bookmarkRequest
+	if err := rc.Bind(&req); err != nil {
+		return bookmarkRequest{}, err
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return bookmarkRequest{}, fmt.Errorf("%w: bookmark name is required", plugin.ErrInvalidInput)
+	}
+	if strings.HasPrefix(strings.TrimSpace(req.Path), "~") {
+		return bookmarkRequest{}, fmt.Errorf("%w: bookmark path must be absolute", plugin.ErrInvalidInput)
+	}
+	clean, err := cleanRemotePath(req.Path)
+	if err != nil {
+		return bookmarkRequest{}, err
+	}
+	req.Path = clean
+	return req, nil
+}
+
+func bookmarkList(rc *plugin.RequestContext) (any, error) {
+	bookmarks := newBookmarkStore(rc.Storage)
+	if bookmarks == nil {
+		return nil, plugin.ErrNotSupported
+	}
+	return bookmarks.List(rc.Ctx)
+}
+
+func bookmarkCreate(rc *plugin.RequestContext) (any, error) {
+	bookmarks := newBookmarkStore(rc.Storage)
+	if bookmarks == nil {
+		return nil, plugin.ErrNotSupported
+	}
+	req, err := bindBookmark(rc)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	b := bookmark{ID: uuid.NewString(), Name: req.Name, Path: req.Path, CreatedAt: now, UpdatedAt: now}
+	if err := bookmarks.Create(rc.Ctx, &b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func bookmarkUpdate(rc *plugin.RequestContext) (any, error) {
+	bookmarks := newBookmarkStore(rc.Storage)
+	if bookmarks == nil {
+		return nil, plugin.ErrNotSupported
+	}
+	b, err := bookmarks.Get(rc.Ctx, rc.Param("id"))
+	if err != nil {
+		return nil, err
+	}
+	req, err := bindBookmark(rc)
+	if err != nil {
+		return nil, err
+	}
+	b.Name, b.Path = req.Name, req.Path
+	if err := bookmarks.Update(rc.Ctx, &b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func bookmarkDelete(rc *plugin.RequestContext) (any, error) {
+	bookmarks := newBookmarkStore(rc.Storage)
+	if bookmarks == nil {
+		return nil, plugin.ErrNotSupported
+	}
+	if _, err := bookmarks.Get(rc.Ctx, rc.Param("id")); err != nil {
+		return nil, err
+	}
+	if err := bookmarks.Delete(rc.Ctx, rc.Param("id")); err != nil {
+		return nil, err
+	}
+	return map[string]bool{"ok": true}, nil
+}
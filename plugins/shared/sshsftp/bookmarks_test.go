@@ -0,0 +1,61 @@
+package sshsftp
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+func bookmarkRequestContext(storage plugin.Storage, params map[string]string, body string) *plugin.RequestContext {
+	rc := plugin.NewRequestContext(context.Background(), plugin.User{ID: "u1"}, nil, params, nil, []byte(body))
+	rc.Storage = storage
+	return rc
+}
+
+func TestBookmarkCreateSanitizesPath(t *testing.T) {
+	storage := newTestPluginStorage()
+
+	got, err := bookmarkCreate(bookmarkRequestContext(storage, nil, `{"name":" Logs ","path":"var/www/../www/releases//current/logs/"}`))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	b := got.(bookmark)
+	if b.Name != "Logs" || b.Path != "/var/www/releases/current/logs" {
+		t.Fatalf("bookmark not normalized: %+v", b)
+	}
+	if _, err := bookmarkCreate(bookmarkRequestContext(storage, nil, `{"name":"home","path":"~/src"}`)); !errors.Is(err, plugin.ErrInvalidInput) {
+		t.Fatalf("home-relative path: want ErrInvalidInput, got %v", err)
+	}
+
+	updated, err := bookmarkUpdate(bookmarkRequestContext(storage, map[string]string{"id": b.ID}, `{"name":"Logs","path":"/srv/logs"}`))
+	if err != nil || updated.(bookmark).Path != "/srv/logs" {
+		t.Fatalf("update: %+v err=%v", updated, err)
+	}
+	if _, err := bookmarkDelete(bookmarkRequestContext(storage, map[string]string{"id": b.ID}, "")); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := bookmarkDelete(bookmarkRequestContext(storage, map[string]string{"id": b.ID}, "")); !errors.Is(err, plugin.ErrNotFound) {
+		t.Fatalf("delete missing: want ErrNotFound, got %v", err)
+	}
+}
+
+func TestBookmarkStoreCapsPerConnection(t *testing.T) {
+	bookmarks := newBookmarkStore(newTestPluginStorage())
+	ctx := context.Background()
+	for i := range maxBookmarks {
+		b := &bookmark{ID: strconv.Itoa(i), Name: "b" + strconv.Itoa(i), Path: "/"}
+		if err := bookmarks.Create(ctx, b); err != nil {
+			t.Fatalf("create %d: %v", i, err)
+		}
+	}
+	if err := bookmarks.Create(ctx, &bookmark{ID: "over", Name: "over", Path: "/"}); !errors.Is(err, plugin.ErrInvalidInput) {
+		t.Fatalf("over cap: want ErrInvalidInput, got %v", err)
+	}
+	rows, _ := bookmarks.List(ctx)
+	if len(rows) != maxBookmarks {
+		t.Fatalf("list: want %d, got %d", maxBookmarks, len(rows))
+	}
+}
@@ -3,6 +3,7 @@ package sshsftp
 import (
 	"archive/zip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -19,6 +20,10 @@ import (
 const (
 	archiveMaxEntries = 50000
 	archiveMaxBytes   = int64(2) << 30
+	chmodMaxDepth     = 32
+	moveBatchMax      = 100
+	// moveRenameTries bounds the " (n)" suffixes tried for on_conflict=rename.
+	moveRenameTries = 1000
 )
 
 type pathsRequest struct {
@@ -30,9 +35,67 @@ type fileOperationRequest struct {
 	Destination string   `json:"destination"`
 }
 
+type movePair struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+type moveBatchRequest struct {
+	Moves     []movePair `json:"moves"`
+	Overwrite bool       `json:"overwrite"`
+	// OnConflict "rename" moves onto the first free "name (n)" instead of
+	// skipping an existing target.
+	OnConflict string `json:"onConflict"`
+}
+
+const (
+	moveSuccess       = "success"
+	moveSkippedExists = "skipped_exists"
+	moveError         = "error"
+)
+
+type moveItemResult struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type moveBatchResult struct {
+	OK      bool             `json:"ok"`
+	Moved   int              `json:"moved"`
+	Skipped int              `json:"skipped"`
+	Failed  int              `json:"failed"`
+	Items   []moveItemResult `json:"items"`
+}
+
 type chmodRequest struct {
+	Paths     []string `json:"paths"`
+	Mode      string   `json:"mode"`
+	Recursive bool     `json:"recursive"`
+	MaxDepth  int      `json:"maxDepth"`
+}
+
+type chownRequest struct {
 	Paths []string `json:"paths"`
-	Mode  string   `json:"mode"`
+	UID   *int     `json:"uid"`
+	GID   *int     `json:"gid"`
+}
+
+type symlinkRequest struct {
+	Target string `json:"target"`
+	Link   string `json:"link"`
+}
+
+type entryError struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+type chmodResult struct {
+	OK      bool         `json:"ok"`
+	Changed int          `json:"changed"`
+	Errors  []entryError `json:"errors"`
 }
 
 func pathsSchema(groupName string) *plugin.Schema {
@@ -56,6 +119,24 @@ func fileOperationSchema(groupName string) *plugin.Schema {
 	}}}}
 }
 
+func moveBatchSchema() *plugin.Schema {
+	return &plugin.Schema{Groups: []plugin.Group{{Name: "Move", Fields: []plugin.Field{
+		{
+			Key: "moves", Label: "Moves", Type: plugin.FieldArray, Required: true,
+			ItemLabel: "Move", AddLabel: "Add move", MinItems: 1, MaxItems: moveBatchMax,
+			Item: &plugin.Field{Type: plugin.FieldObject, Fields: []plugin.Field{
+				{Key: "source", Label: "Source", Type: plugin.FieldText, Required: true, Placeholder: "/path/to/item"},
+				{Key: "target", Label: "Target", Type: plugin.FieldText, Required: true, Placeholder: "/new/path/to/item"},
+			}},
+		},
+		{Key: "overwrite", Label: "Replace existing targets", Type: plugin.FieldToggle},
+		{
+			Key: "onConflict", Label: "When the target exists", Type: plugin.FieldSelect,
+			Options: []plugin.Option{{Label: "Skip the item", Value: "skip"}, {Label: "Keep both, add a (1) suffix", Value: "rename"}},
+		},
+	}}}}
+}
+
 func chmodSchema() *plugin.Schema {
 	return &plugin.Schema{Groups: []plugin.Group{{Name: "Permissions", Fields: []plugin.Field{
 		{
@@ -74,6 +155,27 @@ func chmodSchema() *plugin.Schema {
 			},
 			Validators: []plugin.Validator{{Type: plugin.ValidatorRegex, Value: `^0?[0-7]{3,4}$`, Message: "Enter a 3 or 4 digit octal mode, e.g. 0644."}},
 		},
+		{Key: "recursive", Label: "Apply to folder contents", Type: plugin.FieldToggle},
+		{Key: "maxDepth", Label: "Maximum depth", Type: plugin.FieldNumber, Placeholder: strconv.Itoa(chmodMaxDepth), Help: "How many folder levels below the selection to change."},
+	}}}}
+}
+
+func chownSchema() *plugin.Schema {
+	return &plugin.Schema{Groups: []plugin.Group{{Name: "Ownership", Fields: []plugin.Field{
+		{
+			Key: "paths", Label: "Selection", Type: plugin.FieldArray, Required: true,
+			ItemLabel: "Path", AddLabel: "Add path", MinItems: 1,
+			Item: &plugin.Field{Type: plugin.FieldText, Required: true, Placeholder: "/path/to/item"},
+		},
+		{Key: "uid", Label: "Owner UID", Type: plugin.FieldNumber, Placeholder: "1000", Help: "Leave empty to keep the current owner."},
+		{Key: "gid", Label: "Group GID", Type: plugin.FieldNumber, Placeholder: "1000", Help: "Leave empty to keep the current group."},
+	}}}}
+}
+
+func symlinkSchema() *plugin.Schema {
+	return &plugin.Schema{Groups: []plugin.Group{{Name: "Symlink", Fields: []plugin.Field{
+		{Key: "target", Label: "Target", Type: plugin.FieldText, Required: true, Placeholder: "/path/to/target", Help: "Relative targets resolve from the link's folder."},
+		{Key: "link", Label: "Link path", Type: plugin.FieldText, Required: true, Placeholder: "/path/to/link"},
 	}}}}
 }
 
@@ -128,6 +230,138 @@ func moveEntries(rc *plugin.RequestContext) (any, error) {
 	return map[string]bool{"ok": true}, nil
 }
 
+// moveBatch moves each source to its own target in order. Every path is
+// validated before anything moves; after that an item that fails is reported
+// and the rest still run, and earlier moves are never undone.
+func moveBatch(rc *plugin.RequestContext) (any, error) {
+	fsc, err := fsSession(rc)
+	if err != nil {
+		return nil, err
+	}
+	var req moveBatchRequest
+	if err := rc.Bind(&req); err != nil {
+		return nil, err
+	}
+	moves, err := resolveMoveBatch(req)
+	if err != nil {
+		return nil, err
+	}
+	res := moveBatchResult{Items: make([]moveItemResult, 0, len(moves))}
+	for _, m := range moves {
+		item := moveItemResult{Source: m.Source, Target: m.Target}
+		if err := rc.Ctx.Err(); err != nil {
+			item.Status, item.Error = moveError, err.Error()
+		} else {
+			item = moveOne(fsc, m, req)
+		}
+		switch item.Status {
+		case moveSuccess:
+			res.Moved++
+		case moveSkippedExists:
+			res.Skipped++
+		default:
+			res.Failed++
+		}
+		res.Items = append(res.Items, item)
+	}
+	res.OK = res.Failed == 0
+	return res, nil
+}
+
+func resolveMoveBatch(req moveBatchRequest) ([]movePair, error) {
+	switch {
+	case len(req.Moves) == 0:
+		return nil, fmt.Errorf("%w: no moves provided", plugin.ErrInvalidInput)
+	case len(req.Moves) > moveBatchMax:
+		return nil, fmt.Errorf("%w: at most %d moves per batch", plugin.ErrInvalidInput, moveBatchMax)
+	case req.OnConflict != "" && req.OnConflict != "skip" && req.OnConflict != "rename":
+		return nil, fmt.Errorf("%w: onConflict must be skip or rename", plugin.ErrInvalidInput)
+	case req.Overwrite && req.OnConflict == "rename":
+		return nil, fmt.Errorf("%w: overwrite and onConflict=rename are exclusive", plugin.ErrInvalidInput)
+	}
+	out := make([]movePair, 0, len(req.Moves))
+	for _, m := range req.Moves {
+		paths, err := resolveBulkPaths([]string{m.Source, m.Target})
+		if err != nil {
+			return nil, err
+		}
+		src, dst := paths[0], paths[1]
+		if src == dst || strings.HasPrefix(dst, src+"/") {
+			return nil, fmt.Errorf("%w: cannot move %s into itself", plugin.ErrInvalidInput, src)
+		}
+		out = append(out, movePair{Source: src, Target: dst})
+	}
+	return out, nil
+}
+
+func moveOne(fsc *sftp.Client, m movePair, req moveBatchRequest) moveItemResult {
+	item := moveItemResult{Source: m.Source, Target: m.Target}
+	fail := func(err error) moveItemResult {
+		item.Status, item.Error = moveError, err.Error()
+		return item
+	}
+	if _, err := fsc.Lstat(m.Source); err != nil {
+		return fail(mapFileError(err))
+	}
+	exists, err := remoteExists(fsc, m.Target)
+	if err != nil {
+		return fail(err)
+	}
+	switch {
+	case exists && req.Overwrite:
+		if err := fsc.PosixRename(m.Source, m.Target); err != nil {
+			return fail(mapAttrError("overwriting rename", err))
+		}
+		item.Status = moveSuccess
+		return item
+	case exists && req.OnConflict == "rename":
+		if item.Target, err = freeTarget(fsc, m.Target); err != nil {
+			return fail(err)
+		}
+	case exists:
+		item.Status = moveSkippedExists
+		return item
+	}
+	if err := fsc.Rename(m.Source, item.Target); err != nil {
+		return fail(mapFileError(err))
+	}
+	item.Status = moveSuccess
+	return item
+}
+
+func remoteExists(fsc *sftp.Client, p string) (bool, error) {
+	_, err := fsc.Lstat(p)
+	switch {
+	case err == nil:
+		return true, nil
+	case os.IsNotExist(err):
+		return false, nil
+	default:
+		return false, mapFileError(err)
+	}
+}
+
+// freeTarget returns the first "stem (n).ext" beside p that does not exist.
+func freeTarget(fsc *sftp.Client, p string) (string, error) {
+	dir, name := path.Dir(p), path.Base(p)
+	ext := path.Ext(name)
+	if ext == name {
+		ext = ""
+	}
+	stem := strings.TrimSuffix(name, ext)
+	for n := 1; n <= moveRenameTries; n++ {
+		candidate := joinRemote(dir, fmt.Sprintf("%s (%d)%s", stem, n, ext))
+		exists, err := remoteExists(fsc, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%w: no free name for %s", plugin.ErrConflict, p)
+}
+
 func copyEntries(rc *plugin.RequestContext) (any, error) {
 	fsc, err := fsSession(rc)
 	if err != nil {
@@ -221,14 +455,181 @@ func chmod(rc *plugin.RequestContext) (any, error) {
 	if err != nil {
 		return nil, err
 	}
+	if req.MaxDepth < 0 || req.MaxDepth > chmodMaxDepth {
+		return nil, fmt.Errorf("%w: maxDepth must be between 0 and %d", plugin.ErrInvalidInput, chmodMaxDepth)
+	}
+	res := chmodResult{OK: true, Errors: []entryError{}}
+	if !req.Recursive {
+		for _, p := range paths {
+			if err := fsc.Chmod(p, mode); err != nil {
+				return nil, mapFileError(err)
+			}
+			res.Changed++
+		}
+		return res, nil
+	}
+	depth := req.MaxDepth
+	if depth == 0 {
+		depth = chmodMaxDepth
+	}
+	w := &chmodWalker{ctx: rc.Ctx, fs: fsc, mode: mode, maxDepth: depth, res: &res}
+	for _, p := range paths {
+		if err := w.walk(p, 0); err != nil {
+			return nil, err
+		}
+	}
+	res.OK = len(res.Errors) == 0
+	return res, nil
+}
+
+// chmodWalker applies one mode down a tree. It does not follow symlinks, and
+// an entry that fails is reported rather than ending the walk.
+type chmodWalker struct {
+	ctx      context.Context
+	fs       *sftp.Client
+	mode     fs.FileMode
+	maxDepth int
+	entries  int
+	res      *chmodResult
+}
+
+func (w *chmodWalker) walk(p string, depth int) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	w.entries++
+	if w.entries > archiveMaxEntries {
+		return fmt.Errorf("%w: selection exceeds %d entries", plugin.ErrInvalidInput, archiveMaxEntries)
+	}
+	info, err := w.fs.Lstat(p)
+	if err != nil {
+		w.fail(p, err)
+		return nil
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return nil
+	}
+	if err := w.fs.Chmod(p, w.mode); err != nil {
+		w.fail(p, err)
+	} else {
+		w.res.Changed++
+	}
+	if !info.IsDir() || depth >= w.maxDepth {
+		return nil
+	}
+	children, err := w.fs.ReadDir(p)
+	if err != nil {
+		w.fail(p, err)
+		return nil
+	}
+	for _, child := range children {
+		if err := w.walk(joinRemote(p, child.Name()), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *chmodWalker) fail(p string, err error) {
+	w.res.Errors = append(w.res.Errors, entryError{Path: p, Error: mapFileError(err).Error()})
+}
+
+func chown(rc *plugin.RequestContext) (any, error) {
+	fsc, err := fsSession(rc)
+	if err != nil {
+		return nil, err
+	}
+	var req chownRequest
+	if err := rc.Bind(&req); err != nil {
+		return nil, err
+	}
+	if req.UID == nil && req.GID == nil {
+		return nil, fmt.Errorf("%w: uid or gid is required", plugin.ErrInvalidInput)
+	}
+	if (req.UID != nil && *req.UID < 0) || (req.GID != nil && *req.GID < 0) {
+		return nil, fmt.Errorf("%w: uid and gid must not be negative", plugin.ErrInvalidInput)
+	}
+	paths, err := resolveBulkPaths(req.Paths)
+	if err != nil {
+		return nil, err
+	}
 	for _, p := range paths {
-		if err := fsc.Chmod(p, mode); err != nil {
-			return nil, mapFileError(err)
+		uid, gid, err := ownerOf(fsc, p, req)
+		if err != nil {
+			return nil, err
+		}
+		if err := fsc.Chown(p, uid, gid); err != nil {
+			return nil, mapAttrError("chown", err)
 		}
 	}
 	return map[string]bool{"ok": true}, nil
 }
 
+// ownerOf fills whichever of uid/gid the request left out from p's current
+// owner, since SFTP sets both at once.
+func ownerOf(fsc *sftp.Client, p string, req chownRequest) (int, int, error) {
+	if req.UID != nil && req.GID != nil {
+		return *req.UID, *req.GID, nil
+	}
+	info, err := fsc.Lstat(p)
+	if err != nil {
+		return 0, 0, mapFileError(err)
+	}
+	st, ok := info.Sys().(*sftp.FileStat)
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: server does not report file ownership", plugin.ErrNotSupported)
+	}
+	uid, gid := int(st.UID), int(st.GID)
+	if req.UID != nil {
+		uid = *req.UID
+	}
+	if req.GID != nil {
+		gid = *req.GID
+	}
+	return uid, gid, nil
+}
+
+func symlink(rc *plugin.RequestContext) (any, error) {
+	fsc, err := fsSession(rc)
+	if err != nil {
+		return nil, err
+	}
+	var req symlinkRequest
+	if err := rc.Bind(&req); err != nil {
+		return nil, err
+	}
+	target := strings.TrimSpace(req.Target)
+	if target == "" || strings.ContainsRune(target, 0) {
+		return nil, fmt.Errorf("%w: target is required", plugin.ErrInvalidInput)
+	}
+	link, err := resolveRemotePath(fsc, req.Link)
+	if err != nil {
+		return nil, err
+	}
+	if link == "/" {
+		return nil, fmt.Errorf("%w: refusing to operate on root", plugin.ErrInvalidInput)
+	}
+	if err := fsc.Symlink(target, link); err != nil {
+		return nil, mapAttrError("symlink", err)
+	}
+	info, err := fsc.Lstat(link)
+	if err != nil {
+		return nil, mapFileError(err)
+	}
+	entry := fileEntry(link, info)
+	entry.Symlink = target
+	return entry, nil
+}
+
+// mapAttrError is mapFileError for operations SFTP servers may not implement.
+func mapAttrError(op string, err error) error {
+	var status *sftp.StatusError
+	if errors.As(err, &status) && status.FxCode() == sftp.ErrSSHFxOpUnsupported {
+		return fmt.Errorf("%w: the server does not support %s", plugin.ErrNotSupported, op)
+	}
+	return mapFileError(err)
+}
+
 func archive(rc *plugin.RequestContext) (any, error) {
 	fsc, err := fsSession(rc)
 	if err != nil {
@@ -11,7 +11,7 @@ func TestBulkRoutesDeclareActionableInputSchemas(t *testing.T) {
 	for _, route := range Routes("test", "test", false) {
 		routes[route.ID] = route
 	}
-	for _, id := range []string{"test.sftp.move", "test.sftp.copy", "test.sftp.chmod", "test.sftp.archive"} {
+	for _, id := range []string{"test.sftp.move", "test.sftp.copy", "test.sftp.chmod", "test.sftp.chown", "test.sftp.symlink", "test.sftp.archive"} {
 		if routes[id].Input == nil {
 			t.Fatalf("%s missing input schema", id)
 		}
@@ -0,0 +1,211 @@
+package sshsftp
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+func fileRequestContext(t *testing.T, body string) *plugin.RequestContext {
+	t.Helper()
+	srv := newSSHServer(t)
+	t.Cleanup(srv.Close)
+	sess, err := Connect(context.Background(), plugin.ConnectConfig{Config: srv.config(), Net: pluginNet{}})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = sess.Close() })
+	return plugin.NewRequestContext(context.Background(), plugin.User{ID: "u1"}, sess, nil, nil, []byte(body))
+}
+
+func TestRecursiveChmodIsBoundedAndSkipsFailures(t *testing.T) {
+	root := t.TempDir()
+	deep := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(deep, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{filepath.Join(root, "top.txt"), filepath.Join(root, "a", "mid.txt"), filepath.Join(deep, "low.txt")} {
+		if err := os.WriteFile(f, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("/etc", filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := chmod(fileRequestContext(t, `{"paths":["`+root+`","`+root+`/missing"],"mode":"0750","recursive":true,"maxDepth":2}`))
+	if err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	res := got.(chmodResult)
+	// root, top.txt, a, a/mid.txt and a/b; a/b/low.txt is past the depth cap
+	// and the symlink is never followed.
+	if res.Changed != 5 || res.OK || len(res.Errors) != 1 || res.Errors[0].Path != root+"/missing" {
+		t.Fatalf("result = %+v", res)
+	}
+	if info, _ := os.Stat(filepath.Join(root, "a", "mid.txt")); info.Mode().Perm() != 0o750 {
+		t.Fatalf("mid.txt mode = %o", info.Mode().Perm())
+	}
+	if info, _ := os.Stat(filepath.Join(deep, "low.txt")); info.Mode().Perm() != 0o644 {
+		t.Fatalf("low.txt beyond maxDepth changed to %o", info.Mode().Perm())
+	}
+
+	if _, err := chmod(fileRequestContext(t, `{"paths":["`+root+`"],"mode":"0750","recursive":true,"maxDepth":99}`)); !errors.Is(err, plugin.ErrInvalidInput) {
+		t.Fatalf("maxDepth over cap: want ErrInvalidInput, got %v", err)
+	}
+}
+
+func TestSymlinkCreatesLinkAndProtectsRoot(t *testing.T) {
+	root := t.TempDir()
+	link, target := root+"/current", root+"/releases/v2"
+	got, err := symlink(fileRequestContext(t, `{"target":"`+target+`","link":"`+link+`"}`))
+	if err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	if entry := got.(FileEntry); entry.Path != link || entry.Symlink != target {
+		t.Fatalf("entry = %+v", entry)
+	}
+	if got, err := os.Readlink(link); err != nil || got != target {
+		t.Fatalf("readlink = %q, %v", got, err)
+	}
+	if _, err := symlink(fileRequestContext(t, `{"target":"/etc","link":"/"}`)); !errors.Is(err, plugin.ErrInvalidInput) {
+		t.Fatalf("link at root: want ErrInvalidInput, got %v", err)
+	}
+}
+
+func TestChownRequiresAnOwner(t *testing.T) {
+	root := t.TempDir()
+	if _, err := chown(fileRequestContext(t, `{"paths":["`+root+`"]}`)); !errors.Is(err, plugin.ErrInvalidInput) {
+		t.Fatalf("no uid/gid: want ErrInvalidInput, got %v", err)
+	}
+	gid := os.Getgid()
+	if _, err := chown(fileRequestContext(t, `{"paths":["`+root+`"],"gid":`+strconv.Itoa(gid)+`}`)); err != nil {
+		t.Fatalf("chown to own group: %v", err)
+	}
+}
+
+func TestMoveBatchReportsEachItem(t *testing.T) {
+	root := t.TempDir()
+	dst := filepath.Join(root, "dst")
+	if err := os.Mkdir(dst, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"a.txt", "b.txt", "c.txt", "d.txt", "dst/b.txt"} {
+		if err := os.WriteFile(filepath.Join(root, f), []byte(f), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	move := func(body string) moveBatchResult {
+		t.Helper()
+		got, err := moveBatch(fileRequestContext(t, body))
+		if err != nil {
+			t.Fatalf("moveBatch: %v", err)
+		}
+		return got.(moveBatchResult)
+	}
+
+	res := move(`{"moves":[` +
+		`{"source":"` + root + `/a.txt","target":"` + dst + `/a.txt"},` +
+		`{"source":"` + root + `/b.txt","target":"` + dst + `/b.txt"},` +
+		`{"source":"` + root + `/missing","target":"` + dst + `/missing"}]}`)
+	if res.OK || res.Moved != 1 || res.Skipped != 1 || res.Failed != 1 {
+		t.Fatalf("result = %+v", res)
+	}
+	if s := []string{res.Items[0].Status, res.Items[1].Status, res.Items[2].Status}; s[0] != moveSuccess || s[1] != moveSkippedExists || s[2] != moveError {
+		t.Fatalf("statuses = %v", s)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "a.txt")); err != nil {
+		t.Fatalf("earlier move was not kept: %v", err)
+	}
+
+	res = move(`{"onConflict":"rename","moves":[{"source":"` + root + `/c.txt","target":"` + dst + `/b.txt"}]}`)
+	if !res.OK || res.Items[0].Target != dst+"/b (1).txt" {
+		t.Fatalf("rename result = %+v", res)
+	}
+	res = move(`{"overwrite":true,"moves":[{"source":"` + root + `/d.txt","target":"` + dst + `/b.txt"}]}`)
+	if !res.OK {
+		t.Fatalf("overwrite result = %+v", res)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dst, "b.txt")); string(b) != "d.txt" {
+		t.Fatalf("overwritten content = %q", b)
+	}
+
+	for name, body := range map[string]string{
+		"root":      `{"moves":[{"source":"/","target":"` + dst + `/root"}]}`,
+		"into self": `{"moves":[{"source":"` + dst + `","target":"` + dst + `/inner"}]}`,
+		"exclusive": `{"overwrite":true,"onConflict":"rename","moves":[{"source":"` + dst + `/a.txt","target":"` + root + `/a.txt"}]}`,
+		"too many":  `{"moves":[` + strings.Repeat(`{"source":"/a","target":"/b"},`, moveBatchMax) + `{"source":"/a","target":"/b"}]}`,
+	} {
+		if _, err := moveBatch(fileRequestContext(t, body)); !errors.Is(err, plugin.ErrInvalidInput) {
+			t.Fatalf("%s: want ErrInvalidInput, got %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dst, "a.txt")); err != nil {
+		t.Fatalf("rejected batch moved a file: %v", err)
+	}
+}
+
+func TestListPagesByNameCursor(t *testing.T) {
+	root := t.TempDir()
+	for _, d := range []string{"zdir", "Adir"} {
+		if err := os.Mkdir(filepath.Join(root, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{"b.txt", "D.txt", "f.txt"} {
+		if err := os.WriteFile(filepath.Join(root, f), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("f.txt", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	base := fileRequestContext(t, "")
+	page := func(cursor string) FilePage {
+		t.Helper()
+		q := url.Values{"limit": {"2"}}
+		if cursor != "" {
+			q.Set("cursor", cursor)
+		}
+		rc := plugin.NewRequestContext(context.Background(), plugin.User{ID: "u1"}, base.Session, map[string]string{"path": root}, q, nil)
+		out, err := list(rc)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		return out.(FilePage)
+	}
+	names := func(p FilePage) []string {
+		var out []string
+		for _, e := range p.Items {
+			out = append(out, e.Name)
+		}
+		return out
+	}
+
+	first := page("")
+	if got := names(first); strings.Join(got, ",") != "Adir,zdir" || first.NextCursor == "" || *first.Total != 6 {
+		t.Fatalf("first page = %v next=%q total=%d", got, first.NextCursor, *first.Total)
+	}
+	second := page(first.NextCursor)
+	if got := names(second); strings.Join(got, ",") != "b.txt,D.txt" {
+		t.Fatalf("second page = %v", got)
+	}
+	// An entry created ahead of the cursor must not shift the next page.
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	third := page(second.NextCursor)
+	if got := names(third); strings.Join(got, ",") != "f.txt,link" || third.NextCursor != "" {
+		t.Fatalf("third page = %v next=%q", got, third.NextCursor)
+	}
+	if third.Items[1].Symlink != "f.txt" {
+		t.Fatalf("symlink target = %q", third.Items[1].Symlink)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -22,8 +23,27 @@ const (
 
 	CredentialPasswordField   = "credential_password_id"
 	CredentialPrivateKeyField = "credential_private_key_id"
+
+	// JumpHostsField is the ordered bastion chain dialed before the target.
+	// Each item carries host, port, optional user/host_key, and optional
+	// credential refs under the same keys as the target's stored credentials.
+	JumpHostsField = "jump_hosts"
+
+	// InitEnvField and InitCommandField configure what each new shell runs
+	// before the user takes over. They reach the plugin only for the
+	// connection owner unless AllowInitField is set.
+	InitEnvField     = "init_env"
+	InitCommandField = "init_command"
+	AllowInitField   = "allow_init_commands"
+
+	// CiphersSetting and KeyExchangesSetting are driver settings that narrow
+	// the algorithms offered in every handshake; empty keeps the defaults.
+	CiphersSetting      = "ciphers"
+	KeyExchangesSetting = "key_exchanges"
 )
 
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 type connectOptions struct {
 	Host        string
 	Port        int
@@ -34,6 +54,25 @@ type connectOptions struct {
 	Passphrase  string
 	HostKey     string
 	HostKeyMode string
+	Hops        []hopOptions
+	Init        initOptions
+	Algorithms  ssh.Config
+}
+
+// initOptions is the shell preamble typed into each new terminal.
+type initOptions struct {
+	Env     map[string]string
+	Command string
+}
+
+// hopOptions is one jump host. A hop without its own credential reuses the
+// target's authentication.
+type hopOptions struct {
+	Host    string
+	Port    int
+	User    string
+	Auth    []ssh.AuthMethod
+	HostKey string
 }
 
 // Connect opens one SSH client for either the SSH or SFTP plugin.
@@ -46,28 +85,119 @@ func Connect(ctx context.Context, cfg plugin.ConnectConfig) (plugin.Session, err
 	if err != nil {
 		return nil, err
 	}
-	hostKeyCallback, err := hostKeyCallback(opts.HostKey)
+	verifyHostKey, err := hostKeyCallback(opts.HostKey)
 	if err != nil {
 		return nil, err
 	}
 
-	addr := net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port))
-	conn, err := cfg.Net.DialContext(ctx, "tcp", addr)
-	if err != nil {
-		return nil, fmt.Errorf("%w: dial ssh target: %v", plugin.ErrUnavailable, err)
+	dial := func(addr string) (net.Conn, error) { return cfg.Net.DialContext(ctx, "tcp", addr) }
+	var hops []*ssh.Client
+	closeHops := func() {
+		for i := len(hops) - 1; i >= 0; i-- {
+			_ = hops[i].Close()
+		}
+	}
+	for i, hop := range opts.Hops {
+		hostKey, err := hostKeyCallback(hop.HostKey)
+		if err != nil {
+			closeHops()
+			return nil, err
+		}
+		addr := net.JoinHostPort(hop.Host, strconv.Itoa(hop.Port))
+		client, err := dialSSH(ctx, dial, addr, &ssh.ClientConfig{
+			Config: opts.Algorithms, User: hop.User, Auth: hop.Auth, HostKeyCallback: hostKey, Timeout: 15 * time.Second,
+		}, fmt.Sprintf("jump host %d", i+1))
+		if err != nil {
+			closeHops()
+			return nil, err
+		}
+		hops = append(hops, client)
+		dial = func(addr string) (net.Conn, error) { return client.DialContext(ctx, "tcp", addr) }
 	}
-	sshCfg := &ssh.ClientConfig{
+
+	addr := net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port))
+	client, err := dialSSH(ctx, dial, addr, &ssh.ClientConfig{
+		Config:          opts.Algorithms,
 		User:            opts.User,
 		Auth:            auth,
-		HostKeyCallback: hostKeyCallback,
+		HostKeyCallback: verifyHostKey,
 		Timeout:         15 * time.Second,
+	}, "ssh target")
+	if err != nil {
+		closeHops()
+		return nil, err
+	}
+	sess := NewSession(client)
+	sess.hops = hops
+	sess.init = opts.Init
+	return sess, nil
+}
+
+// AlgorithmSettings is the driver settings form for the SSH algorithm
+// allowlists, offering every algorithm the client supports.
+func AlgorithmSettings() plugin.Schema {
+	supported := ssh.SupportedAlgorithms()
+	return plugin.Schema{Groups: []plugin.Group{{Name: "Algorithms", Fields: []plugin.Field{
+		{
+			Key: CiphersSetting, Label: "Allowed ciphers", Type: plugin.FieldMultiSelect, Options: algorithmOptions(supported.Ciphers),
+			Help: "Ciphers offered to every SSH server, in preference order. Leave empty for the client defaults.",
+		},
+		{
+			Key: KeyExchangesSetting, Label: "Allowed key exchanges", Type: plugin.FieldMultiSelect, Options: algorithmOptions(supported.KeyExchanges),
+			Help: "Key exchange algorithms offered to every SSH server, in preference order. Leave empty for the client defaults.",
+		},
+	}}}}
+}
+
+func algorithmOptions(names []string) []plugin.Option {
+	out := make([]plugin.Option, len(names))
+	for i, n := range names {
+		out[i] = plugin.Option{Label: n, Value: n}
+	}
+	return out
+}
+
+// settingList reads a multiselect driver setting; nil when unset or empty.
+func settingList(settings map[string]any, key string) []string {
+	var out []string
+	switch v := settings[key].(type) {
+	case []string:
+		out = append(out, v...)
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// dialSSH opens an SSH client over a connection from dial, which is either the
+// connection transport or the previous hop's client.
+func dialSSH(ctx context.Context, dial func(string) (net.Conn, error), addr string, cfg *ssh.ClientConfig, what string) (*ssh.Client, error) {
+	plugin.ReportStage(ctx, plugin.StageConnecting)
+	conn, err := dial(addr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: dial %s: %v", plugin.ErrUnavailable, what, err)
+	}
+	plugin.ReportStage(ctx, plugin.StageNegotiating)
+	// The host key is checked once key exchange is done; user
+	// authentication follows.
+	verify := cfg.HostKeyCallback
+	cfg.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if err := verify(hostname, remote, key); err != nil {
+			return err
+		}
+		plugin.ReportStage(ctx, plugin.StageAuthenticating)
+		return nil
 	}
-	cc, chans, reqs, err := ssh.NewClientConn(conn, addr, sshCfg)
+	cc, chans, reqs, err := ssh.NewClientConn(conn, addr, cfg)
 	if err != nil {
 		_ = conn.Close()
-		return nil, fmt.Errorf("%w: ssh handshake failed: %v", plugin.ErrUnauthorized, err)
+		return nil, fmt.Errorf("%w: %s handshake failed: %v", plugin.ErrUnauthorized, what, err)
 	}
-	return NewSession(ssh.NewClient(cc, chans, reqs)), nil
+	return ssh.NewClient(cc, chans, reqs), nil
 }
 
 func parseConnectOptions(cfg plugin.ConnectConfig) (connectOptions, error) {
@@ -89,6 +219,10 @@ func parseConnectOptions(cfg plugin.ConnectConfig) (connectOptions, error) {
 		Passphrase:  cfg.String("passphrase"),
 		HostKey:     strings.TrimSpace(cfg.String("host_key")),
 		HostKeyMode: strings.TrimSpace(cfg.String("host_key_verification")),
+		Algorithms: ssh.Config{
+			Ciphers:      settingList(cfg.Settings, CiphersSetting),
+			KeyExchanges: settingList(cfg.Settings, KeyExchangesSetting),
+		},
 	}
 	if opts.Auth == "" {
 		opts.Auth = "password"
@@ -149,9 +283,99 @@ func parseConnectOptions(cfg plugin.ConnectConfig) (connectOptions, error) {
 	if opts.User == "" {
 		return connectOptions{}, fmt.Errorf("%w: user is required", plugin.ErrInvalidInput)
 	}
+	hops, err := parseJumpHosts(cfg, opts)
+	if err != nil {
+		return connectOptions{}, err
+	}
+	opts.Hops = hops
+	if opts.Init, err = parseInit(cfg); err != nil {
+		return connectOptions{}, err
+	}
 	return opts, nil
 }
 
+func parseInit(cfg plugin.ConnectConfig) (initOptions, error) {
+	init := initOptions{Command: strings.TrimSpace(cfg.String(InitCommandField))}
+	values, _ := cfg.Config[InitEnvField].(map[string]any)
+	for name, value := range values {
+		if !envNamePattern.MatchString(name) {
+			return initOptions{}, fmt.Errorf("%w: invalid environment variable name %q", plugin.ErrInvalidInput, name)
+		}
+		if init.Env == nil {
+			init.Env = map[string]string{}
+		}
+		init.Env[name] = fmt.Sprint(value)
+	}
+	return init, nil
+}
+
+// parseJumpHosts reads the bastion chain. A hop without a stored credential
+// is offered the target's credentials, so it must have its host key pinned;
+// an unverified hop could otherwise collect them.
+func parseJumpHosts(cfg plugin.ConnectConfig, target connectOptions) ([]hopOptions, error) {
+	items, _ := cfg.Config[JumpHostsField].([]any)
+	if len(items) == 0 {
+		return nil, nil
+	}
+	hops := make([]hopOptions, 0, len(items))
+	for i, item := range items {
+		values, _ := item.(map[string]any)
+		hopCfg := plugin.ConnectConfig{Config: values}
+		hop := hopOptions{
+			Host:    strings.TrimSpace(hopCfg.String("host")),
+			User:    strings.TrimSpace(hopCfg.String("user")),
+			HostKey: strings.TrimSpace(hopCfg.String("host_key")),
+		}
+		if hop.Host == "" {
+			return nil, fmt.Errorf("%w: jump host %d: host is required", plugin.ErrInvalidInput, i+1)
+		}
+		hop.Port, _ = hopCfg.Int("port")
+		if hop.Port == 0 {
+			hop.Port = defaultPort
+		}
+		if hop.Port < 1 || hop.Port > 65535 {
+			return nil, fmt.Errorf("%w: jump host %d: port must be between 1 and 65535", plugin.ErrInvalidInput, i+1)
+		}
+		if cred, ok := cfg.CredentialFor(plugin.ItemCredentialField(JumpHostsField, i, CredentialPrivateKeyField)); ok {
+			key, err := cred.RequiredValue("private_key")
+			if err != nil {
+				return nil, err
+			}
+			if hop.Auth, err = privateKeyAuth(key, cred.Value("passphrase")); err != nil {
+				return nil, err
+			}
+			hop.User = firstNonEmpty(hop.User, strings.TrimSpace(cred.Value("username")))
+		} else if cred, ok := cfg.CredentialFor(plugin.ItemCredentialField(JumpHostsField, i, CredentialPasswordField)); ok {
+			password, err := cred.RequiredValue("password")
+			if err != nil {
+				return nil, err
+			}
+			hop.Auth = []ssh.AuthMethod{ssh.Password(password)}
+			hop.User = firstNonEmpty(hop.User, strings.TrimSpace(cred.Value("username")))
+		} else if hop.HostKey == "" {
+			return nil, fmt.Errorf("%w: jump host %d: choose a stored credential for it or pin its host key", plugin.ErrInvalidInput, i+1)
+		} else {
+			auth, err := authMethods(target)
+			if err != nil {
+				return nil, err
+			}
+			hop.Auth = auth
+		}
+		hop.User = firstNonEmpty(hop.User, target.User)
+		hops = append(hops, hop)
+	}
+	return hops, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
 func hostKeyCallback(raw string) (ssh.HostKeyCallback, error) {
 	if raw == "" {
 		return ssh.InsecureIgnoreHostKey(), nil
@@ -159,13 +383,19 @@ func hostKeyCallback(raw string) (ssh.HostKeyCallback, error) {
 	if strings.HasPrefix(raw, "SHA256:") {
 		return func(_ string, _ net.Addr, key ssh.PublicKey) error {
 			if subtle.ConstantTimeCompare([]byte(ssh.FingerprintSHA256(key)), []byte(raw)) != 1 {
-				return fmt.Errorf("%w: ssh host key fingerprint mismatch", plugin.ErrUnauthorized)
+				return plugin.ConnectFailed(plugin.FailureHostKey, fmt.Errorf("%w: ssh host key fingerprint mismatch", plugin.ErrUnauthorized))
 			}
 			return nil
 		}, nil
 	}
 	if _, _, pubKey, _, _, err := ssh.ParseKnownHosts([]byte(raw)); err == nil {
-		return ssh.FixedHostKey(pubKey), nil
+		fixed := ssh.FixedHostKey(pubKey)
+		return func(host string, remote net.Addr, key ssh.PublicKey) error {
+			if err := fixed(host, remote, key); err != nil {
+				return plugin.ConnectFailed(plugin.FailureHostKey, err)
+			}
+			return nil
+		}, nil
 	} else if !errors.Is(err, io.EOF) {
 		if pubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(raw)); err == nil {
 			return ssh.FixedHostKey(pubKey), nil
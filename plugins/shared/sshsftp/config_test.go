@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -55,6 +56,85 @@ func TestConnectVerifiesPinnedHostKey(t *testing.T) {
 	}
 }
 
+func TestConnectThroughJumpHost(t *testing.T) {
+	bastion := newSSHServer(t)
+	defer bastion.Close()
+	target := newSSHServer(t)
+	defer target.Close()
+
+	cfg := target.config()
+	bastionPort, _ := strconv.Atoi(bastion.Port)
+	cfg[JumpHostsField] = []any{map[string]any{
+		"host": bastion.Host, "port": bastionPort, "host_key": ssh.FingerprintSHA256(bastion.PublicKey),
+	}}
+	sess, err := Connect(context.Background(), plugin.ConnectConfig{Config: cfg, Net: pluginNet{}})
+	if err != nil {
+		t.Fatalf("Connect via jump host: %v", err)
+	}
+	defer func() { _ = sess.Close() }()
+	if err := sess.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("HealthCheck: %v", err)
+	}
+	if n := bastion.forwarded.Load(); n != 1 {
+		t.Fatalf("bastion forwarded %d channels, want 1", n)
+	}
+
+	cfg[JumpHostsField] = []any{map[string]any{
+		"host": bastion.Host, "port": bastionPort, "host_key": "SHA256:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+	}}
+	if _, err := Connect(context.Background(), plugin.ConnectConfig{Config: cfg, Net: pluginNet{}}); !errors.Is(err, plugin.ErrUnauthorized) {
+		t.Fatalf("mismatched jump host key error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestParseJumpHostsUsesHopCredential(t *testing.T) {
+	opts, err := parseConnectOptions(plugin.ConnectConfig{
+		Config: map[string]any{
+			"host": "target.test", "user": "root", "auth": "password", "password": "pw",
+			JumpHostsField: []any{
+				map[string]any{"host": "bastion.test", "host_key": "SHA256:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"},
+				map[string]any{"host": "inner.test", "port": float64(2222), CredentialPasswordField: "cred-1"},
+			},
+		},
+		Credentials: plugin.NewResolvedCredentials(plugin.CredentialBinding{
+			Field: plugin.ItemCredentialField(JumpHostsField, 1, CredentialPasswordField),
+			Credential: plugin.ResolvedCredential{
+				ID: "cred-1", Kind: CredentialKindSSHPassword,
+				Values: map[string]string{"username": "jump", "password": "hop-pw"},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(opts.Hops) != 2 {
+		t.Fatalf("hops = %+v, want 2", opts.Hops)
+	}
+	if h := opts.Hops[0]; h.Host != "bastion.test" || h.Port != 22 || h.User != "root" {
+		t.Errorf("first hop should inherit target user and default port: %+v", h)
+	}
+	if h := opts.Hops[1]; h.Host != "inner.test" || h.Port != 2222 || h.User != "jump" {
+		t.Errorf("second hop should use its credential: %+v", h)
+	}
+
+	_, err = parseConnectOptions(plugin.ConnectConfig{Config: map[string]any{
+		"host": "target.test", "user": "root", "auth": "password", "password": "pw",
+		JumpHostsField: []any{map[string]any{"port": 22}},
+	}})
+	if !errors.Is(err, plugin.ErrInvalidInput) {
+		t.Fatalf("hop without host error = %v, want ErrInvalidInput", err)
+	}
+
+	// The target's credentials are never offered to an unverified hop.
+	_, err = parseConnectOptions(plugin.ConnectConfig{Config: map[string]any{
+		"host": "target.test", "user": "root", "auth": "password", "password": "pw",
+		JumpHostsField: []any{map[string]any{"host": "bastion.test"}},
+	}})
+	if !errors.Is(err, plugin.ErrInvalidInput) {
+		t.Fatalf("unpinned hop without credential error = %v, want ErrInvalidInput", err)
+	}
+}
+
 func TestParseConnectOptionsHostKeyVerification(t *testing.T) {
 	opts, err := parseConnectOptions(plugin.ConnectConfig{Config: map[string]any{
 		"host":                  "example.test",
@@ -98,6 +178,27 @@ func TestParseConnectOptionsHostKeyVerification(t *testing.T) {
 	}
 }
 
+func TestParseInitValidatesEnvNames(t *testing.T) {
+	base := func(env map[string]any) plugin.ConnectConfig {
+		return plugin.ConnectConfig{Config: map[string]any{
+			"host": "example.test", "user": "root", "password": "pw", "host_key_verification": "insecure",
+			InitEnvField: env, InitCommandField: "  cd /srv  ",
+		}}
+	}
+	opts, err := parseConnectOptions(base(map[string]any{"APP_ENV": "prod", "_x1": 2}))
+	if err != nil {
+		t.Fatalf("parse init: %v", err)
+	}
+	if opts.Init.Env["APP_ENV"] != "prod" || opts.Init.Env["_x1"] != "2" || opts.Init.Command != "cd /srv" {
+		t.Fatalf("init options = %+v", opts.Init)
+	}
+	for _, name := range []string{"1ABC", "A-B", "PATH;rm", "A B", ""} {
+		if _, err := parseConnectOptions(base(map[string]any{name: "x"})); !errors.Is(err, plugin.ErrInvalidInput) {
+			t.Fatalf("env name %q err = %v, want ErrInvalidInput", name, err)
+		}
+	}
+}
+
 func TestHostKeyCallbackParsesOpenSSHKeys(t *testing.T) {
 	srv := newSSHServer(t)
 	defer srv.Close()
@@ -1,6 +1,7 @@
 package sshsftp
 
 import (
+	"cmp"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -8,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -87,9 +89,16 @@ func Routes(prefix, protocol string, includeShell bool) []plugin.Route {
 		{ID: prefix + ".sftp.rename", Method: plugin.MethodPatch, Path: "/sftp/rename/{path}", Permission: protocol + ".files.write", Risk: plugin.RiskWrite, AuditEvent: protocol + ".sftp.rename", Input: nameSchema("Name"), Handle: renameEntry},
 		{ID: prefix + ".sftp.delete", Method: plugin.MethodDelete, Path: "/sftp/delete/{path}", Permission: protocol + ".files.write", Risk: plugin.RiskDestructive, AuditEvent: protocol + ".sftp.delete", Handle: deleteEntry},
 		{ID: prefix + ".sftp.move", Method: plugin.MethodPost, Path: "/sftp/move", Permission: protocol + ".files.write", Risk: plugin.RiskWrite, AuditEvent: protocol + ".sftp.move", Input: fileOperationSchema("Move"), Handle: moveEntries},
+		{ID: prefix + ".sftp.move_batch", Method: plugin.MethodPost, Path: "/sftp/move-batch", Permission: protocol + ".files.write", Risk: plugin.RiskWrite, AuditEvent: protocol + ".sftp.move_batch", Input: moveBatchSchema(), Handle: moveBatch},
 		{ID: prefix + ".sftp.copy", Method: plugin.MethodPost, Path: "/sftp/copy", Permission: protocol + ".files.write", Risk: plugin.RiskWrite, AuditEvent: protocol + ".sftp.copy", Input: fileOperationSchema("Copy"), Handle: copyEntries},
 		{ID: prefix + ".sftp.chmod", Method: plugin.MethodPost, Path: "/sftp/chmod", Permission: protocol + ".files.write", Risk: plugin.RiskWrite, AuditEvent: protocol + ".sftp.chmod", Input: chmodSchema(), Handle: chmod},
+		{ID: prefix + ".sftp.chown", Method: plugin.MethodPost, Path: "/sftp/chown", Permission: protocol + ".files.write", Risk: plugin.RiskWrite, AuditEvent: protocol + ".sftp.chown", Input: chownSchema(), Handle: chown},
+		{ID: prefix + ".sftp.symlink", Method: plugin.MethodPost, Path: "/sftp/symlink", Permission: protocol + ".files.write", Risk: plugin.RiskWrite, AuditEvent: protocol + ".sftp.symlink", Input: symlinkSchema(), Handle: symlink},
 		{ID: prefix + ".sftp.archive", Method: plugin.MethodPost, Path: "/sftp/archive", Permission: protocol + ".files.read", Risk: plugin.RiskSafe, AuditEvent: protocol + ".sftp.archive", Input: pathsSchema("Archive"), Handle: archive},
+		{ID: prefix + ".sftp.bookmark.list", Method: plugin.MethodGet, Path: "/sftp/bookmarks", Permission: protocol + ".files.read", Risk: plugin.RiskSafe, AuditEvent: protocol + ".sftp.bookmark.list", Handle: bookmarkList},
+		{ID: prefix + ".sftp.bookmark.create", Method: plugin.MethodPost, Path: "/sftp/bookmarks", Permission: protocol + ".files.read", Risk: plugin.RiskWrite, AuditEvent: protocol + ".sftp.bookmark.create", Input: bookmarkSchema(), Handle: bookmarkCreate},
+		{ID: prefix + ".sftp.bookmark.update", Method: plugin.MethodPut, Path: "/sftp/bookmarks/{id}", Permission: protocol + ".files.read", Risk: plugin.RiskWrite, AuditEvent: protocol + ".sftp.bookmark.update", Input: bookmarkSchema(), Handle: bookmarkUpdate},
+		{ID: prefix + ".sftp.bookmark.delete", Method: plugin.MethodDelete, Path: "/sftp/bookmarks/{id}", Permission: protocol + ".files.read", Risk: plugin.RiskWrite, AuditEvent: protocol + ".sftp.bookmark.delete", Handle: bookmarkDelete},
 	}
 	if includeShell {
 		routes = append([]plugin.Route{{
@@ -188,6 +197,9 @@ func fsSession(rc *plugin.RequestContext) (*sftp.Client, error) {
 	return s.Filesystem()
 }
 
+// list pages a directory. The raw listing is sorted and only the page
+// is turned into FileEntry rows, so a directory with hundreds of thousands of
+// entries costs one read and a sort rather than a readlink per entry.
 func list(rc *plugin.RequestContext) (any, error) {
 	fs, err := fsSession(rc)
 	if err != nil {
@@ -197,12 +209,18 @@ func list(rc *plugin.RequestContext) (any, error) {
 	if err != nil {
 		return nil, err
 	}
+	req, err := rc.Page()
+	if err != nil {
+		return nil, err
+	}
 	infos, err := fs.ReadDirContext(rc.Ctx, p)
 	if err != nil {
 		return nil, mapFileError(err)
 	}
-	entries := make([]FileEntry, 0, len(infos))
-	for _, info := range infos {
+	slices.SortFunc(infos, func(a, b os.FileInfo) int { return compareListed(a.IsDir(), a.Name(), b.IsDir(), b.Name()) })
+	page, next := pageInfos(infos, req)
+	entries := make([]FileEntry, 0, len(page))
+	for _, info := range page {
 		entryPath := joinRemote(p, info.Name())
 		entry := fileEntry(entryPath, info)
 		if info.Mode()&os.ModeSymlink != 0 {
@@ -212,17 +230,8 @@ func list(rc *plugin.RequestContext) (any, error) {
 		}
 		entries = append(entries, entry)
 	}
-	sort.Slice(entries, func(i, j int) bool {
-		if entries[i].IsDir != entries[j].IsDir {
-			return entries[i].IsDir
-		}
-		return strings.ToLower(entries[i].Name) < strings.ToLower(entries[j].Name)
-	})
-	req, err := rc.Page()
-	if err != nil {
-		return nil, err
-	}
-	return pageEntries(p, entries, req), nil
+	total := len(infos)
+	return FilePage{Items: entries, NextCursor: next, Total: &total, Path: p}, nil
 }
 
 func stat(rc *plugin.RequestContext) (any, error) {
@@ -554,30 +563,62 @@ func fileEntry(p string, info os.FileInfo) FileEntry {
 	}
 }
 
-func pageEntries(currentPath string, entries []FileEntry, req plugin.PageRequest) FilePage {
-	offset := 0
-	if req.Cursor != "" {
-		if raw, err := base64.RawURLEncoding.DecodeString(req.Cursor); err == nil {
-			offset, _ = strconv.Atoi(string(raw))
+// compareListed orders directories first, then names case-insensitively,
+// with the exact name breaking ties so every entry has one position.
+func compareListed(aDir bool, aName string, bDir bool, bName string) int {
+	if aDir != bDir {
+		if aDir {
+			return -1
 		}
+		return 1
 	}
-	if offset < 0 || offset > len(entries) {
-		offset = 0
+	return cmp.Or(strings.Compare(strings.ToLower(aName), strings.ToLower(bName)), strings.Compare(aName, bName))
+}
+
+// pageInfos slices one page from sorted infos. The cursor names the last
+// entry returned rather than its offset, so a page stays put while entries
+// are added or removed between requests.
+func pageInfos(infos []os.FileInfo, req plugin.PageRequest) ([]os.FileInfo, string) {
+	start := 0
+	if dir, name, ok := decodeListCursor(req.Cursor); ok {
+		start = sort.Search(len(infos), func(i int) bool {
+			return compareListed(infos[i].IsDir(), infos[i].Name(), dir, name) > 0
+		})
+	} else {
+		start = min(max(cursorOffset(req.Cursor), 0), len(infos))
 	}
 	limit := req.Limit
 	if limit <= 0 {
 		limit = plugin.DefaultPageLimit
 	}
-	end := offset + limit
-	if end > len(entries) {
-		end = len(entries)
-	}
+	end := min(start+limit, len(infos))
 	next := ""
-	if end < len(entries) {
-		next = base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(end)))
+	if end < len(infos) {
+		next = encodeListCursor(infos[end-1].IsDir(), infos[end-1].Name())
+	}
+	return infos[start:end], next
+}
+
+func encodeListCursor(dir bool, name string) string {
+	kind := "f:"
+	if dir {
+		kind = "d:"
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(kind + name))
+}
+
+func decodeListCursor(cursor string) (dir bool, name string, ok bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(raw) < 2 {
+		return false, "", false
+	}
+	switch string(raw[:2]) {
+	case "d:":
+		return true, string(raw[2:]), true
+	case "f:":
+		return false, string(raw[2:]), true
 	}
-	total := len(entries)
-	return FilePage{Items: entries[offset:end], NextCursor: next, Total: &total, Path: currentPath}
+	return false, "", false
 }
 
 func pageSnippets(rows []storedSnippet, req plugin.PageRequest) plugin.Page[snippet] {
@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
 
 	"github.com/pkg/sftp"
@@ -16,8 +19,12 @@ import (
 // Session holds all mutable per-connection SSH state.
 type Session struct {
 	client *ssh.Client
-	mu     sync.Mutex
-	sftp   *sftp.Client
+	// hops are the jump-host clients the target is tunneled through, in dial
+	// order; they close after the target.
+	hops []*ssh.Client
+	init initOptions
+	mu   sync.Mutex
+	sftp *sftp.Client
 }
 
 // NewSession wraps an authenticated SSH client.
@@ -82,9 +89,54 @@ func (s *Session) Close() error {
 	if cerr := s.client.Close(); cerr != nil && err == nil {
 		err = cerr
 	}
+	for i := len(s.hops) - 1; i >= 0; i-- {
+		_ = s.hops[i].Close()
+	}
 	return err
 }
 
+// Exec runs req.Command without a pty. The environment is exported ahead of
+// the command because most servers refuse setenv requests.
+func (s *Session) Exec(ctx context.Context, req plugin.ExecRequest) (int, error) {
+	var b strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(req.Env)) {
+		if !envNamePattern.MatchString(name) {
+			return 0, fmt.Errorf("%w: invalid environment variable name %q", plugin.ErrInvalidInput, name)
+		}
+		fmt.Fprintf(&b, "export %s=%s; ", name, shellQuote(req.Env[name]))
+	}
+	b.WriteString(req.Command)
+
+	sshSess, err := s.client.NewSession()
+	if err != nil {
+		return 0, fmt.Errorf("%w: open exec session: %v", plugin.ErrUnavailable, err)
+	}
+	defer func() { _ = sshSess.Close() }()
+	sshSess.Stdout, sshSess.Stderr = req.Stdout, req.Stderr
+	if err := sshSess.Start(b.String()); err != nil {
+		return 0, fmt.Errorf("%w: start command: %v", plugin.ErrUnavailable, err)
+	}
+	wait := make(chan error, 1)
+	go func() { wait <- sshSess.Wait() }()
+	select {
+	case <-ctx.Done():
+		_ = sshSess.Signal(ssh.SIGKILL)
+		_ = sshSess.Close()
+		<-wait
+		return 0, ctx.Err()
+	case err := <-wait:
+		var exitErr *ssh.ExitError
+		switch {
+		case err == nil:
+			return 0, nil
+		case errors.As(err, &exitErr):
+			return exitErr.ExitStatus(), nil
+		default:
+			return 0, fmt.Errorf("%w: command ended without an exit status: %v", plugin.ErrUnavailable, err)
+		}
+	}
+}
+
 func (s *Session) openTerminal(ctx context.Context, params map[string]string) (plugin.Channel, error) {
 	sshSess, err := s.client.NewSession()
 	if err != nil {
@@ -129,9 +181,33 @@ func (s *Session) openTerminal(ctx context.Context, params map[string]string) (p
 		done:    make(chan struct{}),
 	}
 	go ch.copyOutput(stdout, stderr)
+	if script := s.init.script(); script != "" {
+		if _, err := io.WriteString(stdin, script); err != nil {
+			_ = ch.Close()
+			return nil, fmt.Errorf("%w: run init commands: %v", plugin.ErrUnavailable, err)
+		}
+	}
 	return ch, nil
 }
 
+// script types the exports and commands into the shell, so their echo and
+// output travel the same stream as everything the user does next.
+func (o initOptions) script() string {
+	var b strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(o.Env)) {
+		fmt.Fprintf(&b, "export %s=%s\n", name, shellQuote(o.Env[name]))
+	}
+	if o.Command != "" {
+		b.WriteString(o.Command)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func shellQuote(v string) string {
+	return "'" + strings.ReplaceAll(v, "'", `'\''`) + "'"
+}
+
 func terminalSize(params map[string]string) (int, int) {
 	cols := intParam(params, "cols", 80)
 	rows := intParam(params, "rows", 24)
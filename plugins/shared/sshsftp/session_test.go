@@ -2,6 +2,9 @@ package sshsftp
 
 import (
 	"context"
+	"errors"
+	"io"
+	"strings"
 	"sync"
 	"testing"
 
@@ -56,6 +59,34 @@ func TestOpenTerminalAndSFTPLazilyShareClient(t *testing.T) {
 	}
 }
 
+func TestOpenTerminalRunsInitBeforeHandoff(t *testing.T) {
+	srv := newSSHServer(t)
+	defer srv.Close()
+	cfg := srv.config()
+	cfg[InitEnvField] = map[string]any{"GREETING": "it's", "APP": "web"}
+	cfg[InitCommandField] = "cd /srv"
+
+	sess, err := Connect(context.Background(), plugin.ConnectConfig{Config: cfg, Net: pluginNet{}})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer func() { _ = sess.Close() }()
+	ch, err := sess.(*Session).OpenChannel(context.Background(), plugin.ChannelRequest{Kind: plugin.StreamTerminal})
+	if err != nil {
+		t.Fatalf("open terminal: %v", err)
+	}
+	defer func() { _ = ch.Close() }()
+
+	want := "ready\nexport APP='web'\nexport GREETING='it'\\''s'\ncd /srv\n"
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(ch, got); err != nil {
+		t.Fatalf("read terminal: %v", err)
+	}
+	if string(got) != want {
+		t.Fatalf("terminal stream = %q, want %q", got, want)
+	}
+}
+
 func TestFilesystemIsLazyAndReused(t *testing.T) {
 	srv := newSSHServer(t)
 	defer srv.Close()
@@ -105,3 +136,51 @@ func TestResolveRemotePathUsesSFTPHome(t *testing.T) {
 		t.Fatalf("home path = %q, want /", got)
 	}
 }
+
+func TestExecExportsEnvAndReportsExitStatus(t *testing.T) {
+	srv := newSSHServer(t)
+	defer srv.Close()
+
+	sess, err := Connect(context.Background(), plugin.ConnectConfig{Config: srv.config(), Net: pluginNet{}})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer func() { _ = sess.Close() }()
+	var stdout strings.Builder
+	code, err := sess.(*Session).Exec(context.Background(), plugin.ExecRequest{
+		Command: "uptime", Env: map[string]string{"MODE": "ci's"}, Stdout: &stdout, Stderr: io.Discard,
+	})
+	if err != nil || code != 0 {
+		t.Fatalf("Exec = %d, %v", code, err)
+	}
+	if want := "ran: export MODE='ci'\\''s'; uptime\n"; stdout.String() != want {
+		t.Fatalf("stdout = %q, want %q", stdout.String(), want)
+	}
+	if _, err := sess.(*Session).Exec(context.Background(), plugin.ExecRequest{
+		Command: "true", Env: map[string]string{"BAD-NAME": "x"}, Stdout: io.Discard, Stderr: io.Discard,
+	}); !errors.Is(err, plugin.ErrInvalidInput) {
+		t.Fatalf("invalid env name: %v", err)
+	}
+}
+
+func TestConnectOffersOnlyAllowedAlgorithms(t *testing.T) {
+	srv := newSSHServer(t)
+	defer srv.Close()
+
+	sess, err := Connect(context.Background(), plugin.ConnectConfig{
+		Config: srv.config(), Net: pluginNet{},
+		Settings: map[string]any{CiphersSetting: []any{"aes256-ctr"}, KeyExchangesSetting: []any{"curve25519-sha256"}},
+	})
+	if err != nil {
+		t.Fatalf("Connect with allowed algorithms: %v", err)
+	}
+	_ = sess.Close()
+
+	_, err = Connect(context.Background(), plugin.ConnectConfig{
+		Config: srv.config(), Net: pluginNet{},
+		Settings: map[string]any{CiphersSetting: []any{"3des-cbc"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "handshake failed") {
+		t.Fatalf("Connect with a cipher the server refuses = %v, want handshake failure", err)
+	}
+}
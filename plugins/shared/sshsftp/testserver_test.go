@@ -8,6 +8,7 @@ import (
 	"net"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/pkg/sftp"
@@ -23,6 +24,8 @@ type sshTestServer struct {
 	serverConfig *ssh.ServerConfig
 	done         chan struct{}
 	once         sync.Once
+	// forwarded counts direct-tcpip channels, i.e. uses as a jump host.
+	forwarded atomic.Int32
 }
 
 func newSSHServer(t *testing.T) *sshTestServer {
@@ -86,6 +89,10 @@ func (s *sshTestServer) handleConn(conn net.Conn) {
 		_ = sc.Close()
 	}()
 	for ch := range chans {
+		if ch.ChannelType() == "direct-tcpip" {
+			go s.handleForward(ch)
+			continue
+		}
 		if ch.ChannelType() != "session" {
 			_ = ch.Reject(ssh.UnknownChannelType, "session only")
 			continue
@@ -98,6 +105,37 @@ func (s *sshTestServer) handleConn(conn net.Conn) {
 	}
 }
 
+func (s *sshTestServer) handleForward(newCh ssh.NewChannel) {
+	var payload struct {
+		Host     string
+		Port     uint32
+		OrigHost string
+		OrigPort uint32
+	}
+	if err := ssh.Unmarshal(newCh.ExtraData(), &payload); err != nil {
+		_ = newCh.Reject(ssh.ConnectionFailed, "bad payload")
+		return
+	}
+	target, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+	if err != nil {
+		_ = newCh.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	ch, reqs, err := newCh.Accept()
+	if err != nil {
+		_ = target.Close()
+		return
+	}
+	s.forwarded.Add(1)
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		_, _ = io.Copy(target, ch)
+		_ = target.Close()
+	}()
+	_, _ = io.Copy(ch, target)
+	_ = ch.Close()
+}
+
 func (s *sshTestServer) handleSession(ch ssh.Channel, reqs <-chan *ssh.Request) {
 	defer func() { _ = ch.Close() }()
 	for req := range reqs {
@@ -23,7 +23,7 @@ func (p *Plugin) Manifest() plugin.Manifest {
 		Icon:                plugin.Icon{Type: plugin.IconLucide, Value: "terminal"},
 		Category:            plugin.CategoryShell,
 		Config:              configSchema("ssh"),
-		Capabilities:        []plugin.Capability{"terminal", "filesystem"},
+		Capabilities:        []plugin.Capability{"terminal", "filesystem", plugin.CapabilityExec},
 		SupportedTransports: []plugin.Transport{plugin.TransportDirect},
 		Layout:              plugin.LayoutTabs,
 		Tabs: []plugin.Panel{
@@ -131,6 +131,11 @@ func (p *Plugin) Routes() []plugin.Route {
 	return sshsftp.Routes("ssh", "ssh", true)
 }
 
+// Settings lets an admin narrow the SSH algorithms offered to every server.
+func (p *Plugin) Settings() plugin.Schema {
+	return sshsftp.AlgorithmSettings()
+}
+
 func (p *Plugin) Connect(ctx context.Context, cfg plugin.ConnectConfig) (plugin.Session, error) {
 	return sshsftp.Connect(ctx, cfg)
 }
@@ -165,15 +170,48 @@ func configSchema(protocol string) plugin.Schema {
 			{Key: "private_key", Label: "Private key", Type: plugin.FieldTextarea, Required: true, Secret: true, Help: "PEM-encoded private key.", VisibleWhen: &plugin.Condition{AllOf: []plugin.Rule{{Field: "auth", Op: plugin.OpEq, Value: "private_key"}}}},
 			{Key: "passphrase", Label: "Key passphrase", Type: plugin.FieldPassword, Secret: true, VisibleWhen: &plugin.Condition{AllOf: []plugin.Rule{{Field: "auth", Op: plugin.OpEq, Value: "private_key"}}}},
 		}},
+		{Name: "Jump hosts", Fields: []plugin.Field{jumpHostsField(protocol)}},
 		{Name: "Terminal", Fields: []plugin.Field{
 			{Key: "terminal_layout", Label: "Terminal layout", Type: plugin.FieldSelect, Required: true, Default: "single", Options: []plugin.Option{
 				{Label: "Single terminal", Value: "single"},
 				{Label: "Terminal grid", Value: "grid"},
 			}, Help: "Use a single terminal by default. Enable grid only when you need multiple concurrent terminal sessions."},
 		}},
+		{Name: "Session init", Fields: []plugin.Field{
+			{Key: sshsftp.InitEnvField, Label: "Environment", Type: plugin.FieldMap, KeyLabel: "Variable", KeyPlaceholder: "APP_ENV", AddLabel: "Add variable",
+				Item: &plugin.Field{Type: plugin.FieldText}, OwnerOnly: true, SharedBy: sshsftp.AllowInitField,
+				Help: "Exported in each new shell. Names may contain letters, digits and underscores and must not start with a digit."},
+			{Key: sshsftp.InitCommandField, Label: "Init commands", Type: plugin.FieldTextarea, Placeholder: "cd /srv/app", OwnerOnly: true, SharedBy: sshsftp.AllowInitField,
+				Help: "Run in each new shell before you take over; the output appears in the terminal and its recording."},
+			{Key: sshsftp.AllowInitField, Label: "Run init for shared users", Type: plugin.FieldToggle, Default: false,
+				Help: "By default only the connection owner's sessions run the init. Enable to run it for everyone this connection is shared with."},
+		}},
 	}}
 }
 
+// jumpHostsField declares the ordered bastion chain. A hop without a stored
+// credential authenticates with the target's credentials, and only when its
+// host key is pinned.
+func jumpHostsField(protocol string) plugin.Field {
+	return plugin.Field{
+		Key: sshsftp.JumpHostsField, Label: "Via bastion", Type: plugin.FieldArray,
+		ItemLabel: "Jump host", AddLabel: "Add jump host", MaxItems: 8,
+		Help: "Hops are dialed in order; the last one connects to the target host.",
+		Item: &plugin.Field{Type: plugin.FieldObject, Fields: []plugin.Field{
+			{Key: "host", Label: "Host", Type: plugin.FieldText, Required: true, Placeholder: "bastion.example.com"},
+			{Key: "port", Label: "Port", Type: plugin.FieldNumber, Default: 22, Validators: []plugin.Validator{{Type: plugin.ValidatorMin, Value: 1}, {Type: plugin.ValidatorMax, Value: 65535}}},
+			{Key: "user", Label: "Username", Type: plugin.FieldText, Help: "Defaults to the stored credential's or target's username."},
+			{Key: "host_key", Label: "Pinned host key", Type: plugin.FieldTextarea, Placeholder: "SHA256:...", Help: "Required when the hop has no stored credential of its own."},
+			{Key: sshsftp.CredentialPrivateKeyField, Label: "Stored SSH private key", Type: plugin.FieldCredentialRef, Credential: &plugin.CredentialSelector{
+				Kind: sshsftp.CredentialKindSSHPrivateKey, Protocols: []string{protocol},
+			}},
+			{Key: sshsftp.CredentialPasswordField, Label: "Stored SSH password", Type: plugin.FieldCredentialRef, Credential: &plugin.CredentialSelector{
+				Kind: sshsftp.CredentialKindSSHPassword, Protocols: []string{protocol},
+			}},
+		}},
+	}
+}
+
 func filesTab(prefix string) plugin.Panel {
 	return plugin.Panel{
 		Key: "files", Label: "Files", Icon: plugin.Icon{Type: plugin.IconLucide, Value: "folder"},
@@ -182,16 +220,21 @@ func filesTab(prefix string) plugin.Panel {
 		Config: plugin.FileBrowserConfig{
 			PathParam: "path",
 			Routes: plugin.FileBrowserRoutes{
-				Read:     prefix + ".sftp.read",
-				Download: prefix + ".sftp.download",
-				Write:    prefix + ".sftp.write",
-				Mkdir:    prefix + ".sftp.mkdir",
-				Rename:   prefix + ".sftp.rename",
-				Delete:   prefix + ".sftp.delete",
-				Move:     prefix + ".sftp.move",
-				Copy:     prefix + ".sftp.copy",
-				Chmod:    prefix + ".sftp.chmod",
-				Archive:  prefix + ".sftp.archive",
+				Read:           prefix + ".sftp.read",
+				Download:       prefix + ".sftp.download",
+				Write:          prefix + ".sftp.write",
+				Mkdir:          prefix + ".sftp.mkdir",
+				Rename:         prefix + ".sftp.rename",
+				Delete:         prefix + ".sftp.delete",
+				Move:           prefix + ".sftp.move",
+				MoveBatch:      prefix + ".sftp.move_batch",
+				Copy:           prefix + ".sftp.copy",
+				Chmod:          prefix + ".sftp.chmod",
+				Archive:        prefix + ".sftp.archive",
+				Bookmarks:      prefix + ".sftp.bookmark.list",
+				BookmarkCreate: prefix + ".sftp.bookmark.create",
+				BookmarkUpdate: prefix + ".sftp.bookmark.update",
+				BookmarkDelete: prefix + ".sftp.bookmark.delete",
 			},
 			Upload: plugin.FileUploadConfig{
 				RouteID:   prefix + ".sftp.upload",
@@ -121,3 +121,20 @@ func requireField(t *testing.T, schema plugin.Schema, key string) plugin.Field {
 	t.Fatalf("missing field %q", key)
 	return plugin.Field{}
 }
+
+func TestSettingsDeclareAlgorithmAllowlists(t *testing.T) {
+	var p plugin.Plugin = ssh.New()
+	c, ok := p.(plugin.Configurable)
+	if !ok {
+		t.Fatal("ssh plugin does not implement plugin.Configurable")
+	}
+	keys := map[string]bool{}
+	for _, g := range c.Settings().Groups {
+		for _, f := range g.Fields {
+			keys[f.Key] = len(f.Options) > 0
+		}
+	}
+	if !keys["ciphers"] || !keys["key_exchanges"] {
+		t.Fatalf("settings fields = %v, want ciphers and key_exchanges with options", keys)
+	}
+}
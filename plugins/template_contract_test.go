@@ -0,0 +1,52 @@
+package plugins
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/charlesng35/shellcn/internal/pluginregistry"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+func TestAllPluginTemplatesRoundTrip(t *testing.T) {
+	reg := pluginregistry.New()
+	Register(reg)
+	for _, p := range allTestPlugins(t) {
+		name := p.Manifest().Name
+		t.Run(name, func(t *testing.T) {
+			tmpl, ok := reg.Template(name)
+			if !ok {
+				t.Fatalf("no template for %q", name)
+			}
+			if tmpl.Protocol != name || tmpl.Hash == "" {
+				t.Fatalf("template = %+v", tmpl)
+			}
+			b, err := json.Marshal(tmpl)
+			if err != nil {
+				t.Fatalf("template does not marshal: %v", err)
+			}
+			var back plugin.ConfigTemplate
+			if err := json.Unmarshal(b, &back); err != nil {
+				t.Fatalf("template does not unmarshal: %v", err)
+			}
+			again, err := json.Marshal(back)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, again) {
+				t.Fatalf("template changed across a round trip:\n%s\n%s", b, again)
+			}
+			if got := plugin.SchemaHash(back.Schema); got != tmpl.Hash {
+				t.Fatalf("decoded schema hash = %s, want %s", got, tmpl.Hash)
+			}
+			for _, g := range back.Schema.Groups {
+				for _, f := range g.Fields {
+					if back.Bindings[f.Key] != f.Binding() {
+						t.Fatalf("field %q binding = %q, want %q", f.Key, back.Bindings[f.Key], f.Binding())
+					}
+				}
+			}
+		})
+	}
+}
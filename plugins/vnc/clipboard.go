@@ -0,0 +1,133 @@
+package vnc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/charlesng35/shellcn/plugins/shared/rfb"
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+const (
+	// clipboardRetry is how long the clipboard link waits before redialing
+	// a server that dropped it.
+	clipboardRetry = 5 * time.Second
+	// clipboardTimeout bounds dialing the link, and a push when the caller
+	// sets no deadline.
+	clipboardTimeout = 10 * time.Second
+)
+
+// clipboardLink is a second, shared RFB client kept open for cut text. The
+// desktop stream is spliced raw to noVNC, so cut text can be neither injected
+// into it nor picked out of it; this client never asks for framebuffer
+// updates, so the server only sends it cut text, bells and colour maps.
+type clipboardLink struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	stop   context.CancelFunc
+	closed bool
+}
+
+// SetClipboard replaces the server's cut text.
+func (s *Session) SetClipboard(ctx context.Context, text string) error {
+	conn, err := s.clipboardConn(ctx)
+	if err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(clipboardTimeout)
+	}
+	s.clip.mu.Lock()
+	defer s.clip.mu.Unlock()
+	_ = conn.SetWriteDeadline(deadline)
+	if err := rfb.WriteClientCutText(conn, text); err != nil {
+		s.dropClipboardLocked(conn)
+		return fmt.Errorf("%w: write vnc clipboard: %v", plugin.ErrUnavailable, err)
+	}
+	return nil
+}
+
+// OnClipboard relays the server's cut text to fn until the session closes.
+func (s *Session) OnClipboard(fn func(text string)) {
+	s.clip.mu.Lock()
+	defer s.clip.mu.Unlock()
+	if s.clip.closed || s.clip.stop != nil {
+		return
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	s.clip.stop = stop
+	go s.watchClipboard(ctx, fn)
+}
+
+func (s *Session) watchClipboard(ctx context.Context, fn func(string)) {
+	for ctx.Err() == nil {
+		conn, err := s.clipboardConn(ctx)
+		for err == nil {
+			var text string
+			if text, err = rfb.ReadServerCutText(conn); err == nil {
+				fn(text)
+			}
+		}
+		if conn != nil {
+			s.clip.mu.Lock()
+			s.dropClipboardLocked(conn)
+			s.clip.mu.Unlock()
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(clipboardRetry):
+		}
+	}
+}
+
+// clipboardConn returns the open clipboard link, dialing it if needed.
+func (s *Session) clipboardConn(ctx context.Context) (net.Conn, error) {
+	s.clip.mu.Lock()
+	defer s.clip.mu.Unlock()
+	if s.clip.closed {
+		return nil, fmt.Errorf("%w: vnc session closed", plugin.ErrUnavailable)
+	}
+	if s.clip.conn != nil {
+		return s.clip.conn, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, clipboardTimeout)
+	defer cancel()
+	conn, err := s.net.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: dial vnc target: %v", plugin.ErrUnavailable, err)
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+	if _, err := rfb.DialVNC(conn, s.password); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%w: %v", plugin.ErrUnavailable, err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	s.clip.conn = conn
+	return conn, nil
+}
+
+// dropClipboardLocked closes conn if it is still the open link (caller
+// holds s.clip.mu).
+func (s *Session) dropClipboardLocked(conn net.Conn) {
+	_ = conn.Close()
+	if s.clip.conn == conn {
+		s.clip.conn = nil
+	}
+}
+
+func (s *Session) closeClipboard() {
+	s.clip.mu.Lock()
+	defer s.clip.mu.Unlock()
+	s.clip.closed = true
+	if s.clip.stop != nil {
+		s.clip.stop()
+	}
+	if s.clip.conn != nil {
+		s.dropClipboardLocked(s.clip.conn)
+	}
+}
@@ -9,12 +9,13 @@ import (
 	"github.com/charlesng35/shellcn/sdk/plugin"
 )
 
-// Session holds the per-connection VNC dial parameters. VNC keeps no persistent
-// upstream socket; each desktop channel dials fresh and authenticates.
+// Session holds the per-connection VNC dial parameters. Each desktop channel
+// dials fresh and authenticates; only the clipboard keeps a link open.
 type Session struct {
 	net      plugin.NetTransport
 	addr     string
 	password string
+	clip     clipboardLink
 }
 
 func (s *Session) HealthCheck(ctx context.Context) error {
@@ -45,7 +46,10 @@ func (s *Session) OpenChannel(ctx context.Context, req plugin.ChannelRequest) (p
 	return &desktopChannel{conn: conn, serverInit: serverInit}, nil
 }
 
-func (s *Session) Close() error { return nil }
+func (s *Session) Close() error {
+	s.closeClipboard()
+	return nil
+}
 
 // desktopChannel carries the authenticated upstream RFB byte stream.
 type desktopChannel struct {
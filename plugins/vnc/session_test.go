@@ -48,29 +48,91 @@ func serveVNCNoAuth(t *testing.T, conn net.Conn, closed chan<- struct{}) {
 	t.Helper()
 	defer close(closed)
 	defer func() { _ = conn.Close() }()
-	if _, err := conn.Write([]byte("RFB 003.008\n")); err != nil {
+	if acceptVNCNoAuth(conn) != nil {
 		return
 	}
+	_, _ = io.Copy(io.Discard, conn)
+}
+
+// acceptVNCNoAuth plays the server side of the handshake up to ServerInit.
+func acceptVNCNoAuth(conn net.Conn) error {
+	if _, err := conn.Write([]byte("RFB 003.008\n")); err != nil {
+		return err
+	}
 	if _, err := io.ReadFull(conn, make([]byte, 12)); err != nil {
-		return
+		return err
 	}
 	if _, err := conn.Write([]byte{1, 1}); err != nil {
-		return
+		return err
 	}
 	if _, err := io.ReadFull(conn, make([]byte, 1)); err != nil {
-		return
+		return err
 	}
 	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
-		return
+		return err
 	}
 	if _, err := io.ReadFull(conn, make([]byte, 1)); err != nil {
-		return
+		return err
 	}
 	init := make([]byte, 24)
 	binary.BigEndian.PutUint16(init[0:], 800)
 	binary.BigEndian.PutUint16(init[2:], 600)
-	_, _ = conn.Write(init)
-	_, _ = io.Copy(io.Discard, conn)
+	_, err := conn.Write(init)
+	return err
+}
+
+func TestClipboardRelaysCutTextOverSideLink(t *testing.T) {
+	srv, cli := net.Pipe()
+	dials := 0
+	s := &Session{
+		net: fakeNetTransport{dial: func(context.Context, string, string) (net.Conn, error) {
+			dials++
+			return cli, nil
+		}},
+		addr: "127.0.0.1:5900",
+	}
+	var _ plugin.ClipboardSupporter = s
+	got := make(chan string, 1)
+	s.OnClipboard(func(text string) { got <- text })
+	if err := acceptVNCNoAuth(srv); err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+
+	// Server cut text is Latin-1; a bell before it is skipped.
+	if _, err := srv.Write([]byte{2, 3, 0, 0, 0, 0, 0, 0, 4, 'c', 'a', 'f', 0xe9}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case text := <-got:
+		if text != "café" {
+			t.Fatalf("remote clipboard = %q", text)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("server cut text was not relayed")
+	}
+
+	pushed := make(chan []byte, 1)
+	go func() {
+		msg := make([]byte, 8+3)
+		_, _ = io.ReadFull(srv, msg)
+		pushed <- msg
+	}()
+	if err := s.SetClipboard(context.Background(), "hé✓"); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if msg := <-pushed; msg[0] != 6 || binary.BigEndian.Uint32(msg[4:]) != 3 || string(msg[8:]) != "h\xe9?" {
+		t.Fatalf("client cut text = %v", msg)
+	}
+	if dials != 1 {
+		t.Fatalf("push should reuse the open link, dialed %d times", dials)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetClipboard(context.Background(), "x"); !errors.Is(err, plugin.ErrUnavailable) {
+		t.Fatalf("push after close: %v", err)
+	}
 }
 
 var _ plugin.NetTransport = fakeNetTransport{}
@@ -0,0 +1,192 @@
+package plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ValueTransform converts a renamed config field's stored value.
+type ValueTransform string
+
+const (
+	// TransformStringToBool parses "true"/"false", "1"/"0", "yes"/"no" and
+	// "on"/"off".
+	TransformStringToBool ValueTransform = "string_to_bool"
+	// TransformStringToNumber parses a decimal string.
+	TransformStringToNumber ValueTransform = "string_to_number"
+	// TransformToString formats a scalar as a string.
+	TransformToString ValueTransform = "to_string"
+)
+
+var validTransforms = map[ValueTransform]bool{
+	"": true, TransformStringToBool: true, TransformStringToNumber: true, TransformToString: true,
+}
+
+// FieldRename moves a top-level config value from an old key to a new one.
+type FieldRename struct {
+	From      string         `json:"from"`
+	To        string         `json:"to"`
+	Transform ValueTransform `json:"transform,omitempty"`
+}
+
+// ConfigMigration lists the renames a manifest version introduced. Connections
+// saved under an older version have them applied when their config is read.
+type ConfigMigration struct {
+	// Version is the first manifest version that uses the new keys.
+	Version string        `json:"version"`
+	Renames []FieldRename `json:"renames"`
+}
+
+// MigrationIssue is a stored value MigrateConfig could not move.
+type MigrationIssue struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// MigrateConfig applies, in version order, every migration newer than the
+// version config was saved under; an empty from applies them all. A value
+// whose new key is already set, or that its transform rejects, stays under
+// its old key and is reported.
+func MigrateConfig(migrations []ConfigMigration, from string, config map[string]any) (map[string]any, []MigrationIssue) {
+	out := make(map[string]any, len(config))
+	for k, v := range config {
+		out[k] = v
+	}
+	var issues []MigrationIssue
+	for _, mig := range migrations {
+		if from != "" && CompareVersions(mig.Version, from) <= 0 {
+			continue
+		}
+		for _, r := range mig.Renames {
+			v, ok := out[r.From]
+			if !ok {
+				continue
+			}
+			if _, taken := out[r.To]; taken {
+				issues = append(issues, MigrationIssue{Field: r.From, Reason: fmt.Sprintf("%q is already set", r.To)})
+				continue
+			}
+			nv, err := r.Transform.apply(v)
+			if err != nil {
+				issues = append(issues, MigrationIssue{Field: r.From, Reason: err.Error()})
+				continue
+			}
+			delete(out, r.From)
+			out[r.To] = nv
+		}
+	}
+	return out, issues
+}
+
+func (t ValueTransform) apply(v any) (any, error) {
+	switch t {
+	case TransformStringToBool:
+		switch x := v.(type) {
+		case bool:
+			return x, nil
+		case string:
+			switch strings.ToLower(strings.TrimSpace(x)) {
+			case "true", "1", "yes", "on":
+				return true, nil
+			case "false", "0", "no", "off", "":
+				return false, nil
+			}
+		}
+		return nil, fmt.Errorf("cannot convert %v to a boolean", v)
+	case TransformStringToNumber:
+		switch x := v.(type) {
+		case float64, int, int64:
+			return x, nil
+		case string:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(x), 64); err == nil {
+				return f, nil
+			}
+		}
+		return nil, fmt.Errorf("cannot convert %v to a number", v)
+	case TransformToString:
+		switch x := v.(type) {
+		case string:
+			return x, nil
+		case bool, float64, int, int64:
+			return fmt.Sprint(x), nil
+		}
+		return nil, fmt.Errorf("cannot convert %v to a string", v)
+	}
+	return v, nil
+}
+
+// CompareVersions orders dotted numeric versions such as "1.10.0", ignoring a
+// leading "v" and any pre-release or build suffix. Missing parts count as 0.
+func CompareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := range max(len(pa), len(pb)) {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(v string) []int {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	var parts []int
+	for _, p := range strings.Split(v, ".") {
+		n, _ := strconv.Atoi(p)
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+// validateConfigMigrations checks that renames target declared top-level
+// fields and are ordered no later than the manifest's own version.
+func validateConfigMigrations(m Manifest, add func(string, ...any)) {
+	fields := map[string]Field{}
+	for _, group := range m.Config.Groups {
+		for _, f := range group.Fields {
+			fields[f.Key] = f
+		}
+	}
+	prev := ""
+	for _, mig := range m.ConfigMigrations {
+		if mig.Version == "" {
+			add("config migration is missing its Version")
+			continue
+		}
+		if m.Version != "" && CompareVersions(mig.Version, m.Version) > 0 {
+			add("config migration %s is newer than manifest version %s", mig.Version, m.Version)
+		}
+		if prev != "" && CompareVersions(mig.Version, prev) <= 0 {
+			add("config migrations must be in ascending version order; %s follows %s", mig.Version, prev)
+		}
+		prev = mig.Version
+		for _, r := range mig.Renames {
+			if r.From == "" || r.To == "" || r.From == r.To {
+				add("config migration %s: rename %q → %q needs two different keys", mig.Version, r.From, r.To)
+				continue
+			}
+			if !validTransforms[r.Transform] {
+				add("config migration %s: unknown transform %q", mig.Version, r.Transform)
+			}
+			to, ok := fields[r.To]
+			if !ok {
+				add("config migration %s: rename target %q is not a top-level config field", mig.Version, r.To)
+			} else if to.Secret && r.Transform != "" {
+				add("config migration %s: secret field %q cannot take a transform", mig.Version, r.To)
+			}
+		}
+	}
+}
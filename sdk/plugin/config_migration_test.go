@@ -0,0 +1,83 @@
+package plugin_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charlesng35/shellcn/sdk/plugin"
+)
+
+var hostMigrations = []plugin.ConfigMigration{
+	{Version: "1.2.0", Renames: []plugin.FieldRename{{From: "hostname", To: "host"}}},
+	{Version: "1.10.0", Renames: []plugin.FieldRename{
+		{From: "legacy_tls", To: "tls", Transform: plugin.TransformStringToBool},
+		{From: "timeout", To: "timeout_seconds", Transform: plugin.TransformStringToNumber},
+	}},
+}
+
+func TestMigrateConfigAppliesNewerVersionsOnly(t *testing.T) {
+	stored := map[string]any{"hostname": "db1", "legacy_tls": "yes", "timeout": "30"}
+	got, issues := plugin.MigrateConfig(hostMigrations, "1.2.0", stored)
+	if len(issues) != 0 || got["hostname"] != "db1" || got["tls"] != true || got["timeout_seconds"] != 30.0 {
+		t.Fatalf("from 1.2.0: %v issues=%v", got, issues)
+	}
+	if _, ok := stored["tls"]; ok {
+		t.Fatal("the stored config must not be modified")
+	}
+	got, issues = plugin.MigrateConfig(hostMigrations, "", stored)
+	if len(issues) != 0 || got["host"] != "db1" || got["hostname"] != nil {
+		t.Fatalf("unversioned: %v issues=%v", got, issues)
+	}
+}
+
+func TestMigrateConfigReportsWhatItCannotMove(t *testing.T) {
+	got, issues := plugin.MigrateConfig(hostMigrations, "1.0", map[string]any{
+		"hostname": "old", "host": "new", "legacy_tls": "maybe",
+	})
+	if len(issues) != 2 || got["host"] != "new" || got["hostname"] != "old" || got["legacy_tls"] != "maybe" {
+		t.Fatalf("got %v issues=%v", got, issues)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.10.0", "1.9.9", 1}, {"v1.2", "1.2.0", 0}, {"1.2.0-rc1", "1.2.0", 0}, {"0.9", "1.0", -1},
+	} {
+		if got := plugin.CompareVersions(tc.a, tc.b); got != tc.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestValidateConfigMigrations(t *testing.T) {
+	noop := func(_ *plugin.RequestContext) (any, error) { return nil, nil }
+	m := plugin.Manifest{
+		APIVersion: plugin.CurrentAPIVersion, Name: "x", Title: "X", Version: "1.3.0",
+		Category: plugin.CategoryOther, Layout: plugin.LayoutTabs,
+		SupportedTransports: []plugin.Transport{plugin.TransportDirect},
+		Config: plugin.Schema{Groups: []plugin.Group{{Name: "G", Fields: []plugin.Field{
+			{Key: "host", Label: "Host", Type: plugin.FieldText},
+			{Key: "password", Label: "Password", Type: plugin.FieldPassword, Secret: true},
+		}}}},
+		ConfigMigrations: []plugin.ConfigMigration{
+			{Version: "1.2.0", Renames: []plugin.FieldRename{{From: "hostname", To: "host"}, {From: "pass", To: "password"}}},
+		},
+	}
+	routes := []plugin.Route{{ID: "x.list", Method: plugin.MethodGet, Permission: "x.read", Risk: plugin.RiskSafe, Handle: noop}}
+	if err := plugin.Validate(m, routes); err != nil {
+		t.Fatalf("valid migrations rejected: %v", err)
+	}
+	m.ConfigMigrations = []plugin.ConfigMigration{
+		{Version: "2.0.0", Renames: []plugin.FieldRename{{From: "a", To: "missing"}}},
+		{Version: "1.0.0", Renames: []plugin.FieldRename{{From: "pw", To: "password", Transform: plugin.TransformToString}}},
+	}
+	err := plugin.Validate(m, routes)
+	for _, want := range []string{"newer than manifest", "ascending", "not a top-level", "cannot take a transform"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("want %q in %v", want, err)
+		}
+	}
+}
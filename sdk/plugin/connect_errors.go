@@ -0,0 +1,33 @@
+package plugin
+
+// FailureCategory says why Connect could not open a session, in terms a user
+// can act on.
+type FailureCategory string
+
+const (
+	FailureAuth        FailureCategory = "auth_failed"
+	FailureUnreachable FailureCategory = "host_unreachable"
+	FailureTimeout     FailureCategory = "timeout"
+	// FailureHostKey covers any server identity check: a pinned SSH host key
+	// or a TLS certificate that does not verify.
+	FailureHostKey  FailureCategory = "host_key_mismatch"
+	FailureProtocol FailureCategory = "protocol_error"
+	// FailureQuota is the server refusing more connections or sessions.
+	FailureQuota FailureCategory = "quota"
+)
+
+// ConnectError is a Connect failure the plugin has classified. The core
+// falls back to matching the error text when a plugin returns a plain error.
+type ConnectError struct {
+	Category FailureCategory
+	Err      error
+}
+
+func (e *ConnectError) Error() string { return e.Err.Error() }
+
+func (e *ConnectError) Unwrap() error { return e.Err }
+
+// ConnectFailed wraps err with the category it falls under.
+func ConnectFailed(category FailureCategory, err error) error {
+	return &ConnectError{Category: category, Err: err}
+}
@@ -0,0 +1,32 @@
+package plugin
+
+import "context"
+
+// ConnectStage is a step of opening an upstream session, shown to the user
+// while a launch is pending.
+type ConnectStage string
+
+const (
+	// StageResolving covers looking up the connection's settings and
+	// credentials before anything is dialed.
+	StageResolving      ConnectStage = "resolving"
+	StageConnecting     ConnectStage = "connecting"
+	StageNegotiating    ConnectStage = "negotiating"
+	StageAuthenticating ConnectStage = "authenticating"
+)
+
+type stageReporterKey struct{}
+
+// WithStageReporter returns a context on which ReportStage calls report.
+func WithStageReporter(ctx context.Context, report func(ConnectStage)) context.Context {
+	return context.WithValue(ctx, stageReporterKey{}, report)
+}
+
+// ReportStage tells the core that Connect reached stage. Plugins call it from
+// Connect when they can tell the steps apart; without a reporter on ctx it
+// does nothing.
+func ReportStage(ctx context.Context, stage ConnectStage) {
+	if report, ok := ctx.Value(stageReporterKey{}).(func(ConnectStage)); ok {
+		report(stage)
+	}
+}
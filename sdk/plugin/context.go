@@ -377,6 +377,24 @@ func (s Schema) VisibleSecretKeys(values map[string]any, context map[string]any)
 	return keys
 }
 
+// OwnerOnlyKeys lists top-level owner-only fields that values do not share
+// with other users.
+func (s Schema) OwnerOnlyKeys(values map[string]any) []string {
+	var keys []string
+	for _, group := range s.Groups {
+		for _, field := range group.Fields {
+			if !field.OwnerOnly {
+				continue
+			}
+			if shared, _ := values[field.SharedBy].(bool); field.SharedBy != "" && shared {
+				continue
+			}
+			keys = append(keys, field.Key)
+		}
+	}
+	return keys
+}
+
 func mergedConditionValues(values map[string]any, context map[string]any) map[string]any {
 	if len(context) == 0 {
 		return values
@@ -408,6 +426,12 @@ func (rc *RequestContext) inputValues() (map[string]any, error) {
 	return values, nil
 }
 
+// ValidateValue checks one non-empty value against the field's type, options,
+// and validators.
+func (f Field) ValidateValue(value any) error {
+	return validateFieldValue(f, value)
+}
+
 func validateFieldValue(field Field, value any) error {
 	switch field.Type {
 	case FieldText, FieldEmail, FieldURL, FieldTel, FieldPassword, FieldTextarea, FieldDuration, FieldCredentialRef, FieldRadio:
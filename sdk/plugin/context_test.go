@@ -77,6 +77,21 @@ func TestSchemaValuesWithDefaultsPreservesExplicitValues(t *testing.T) {
 	}
 }
 
+func TestSchemaOwnerOnlyKeysHonorsSharedBy(t *testing.T) {
+	schema := plugin.Schema{Groups: []plugin.Group{{Name: "Init", Fields: []plugin.Field{
+		{Key: "init_command", Label: "Command", Type: plugin.FieldTextarea, OwnerOnly: true, SharedBy: "share"},
+		{Key: "secret_note", Label: "Note", Type: plugin.FieldText, OwnerOnly: true},
+		{Key: "share", Label: "Share", Type: plugin.FieldToggle},
+	}}}}
+
+	if got := schema.OwnerOnlyKeys(map[string]any{}); strings.Join(got, ",") != "init_command,secret_note" {
+		t.Fatalf("unshared keys = %v", got)
+	}
+	if got := schema.OwnerOnlyKeys(map[string]any{"share": true}); strings.Join(got, ",") != "secret_note" {
+		t.Fatalf("shared keys = %v", got)
+	}
+}
+
 func TestValidateSchemaRejectsMissingVisibleRequiredField(t *testing.T) {
 	rc := plugin.NewRequestContext(context.Background(), testUser(), nil, nil, nil, []byte(`{"name":"alpha","advanced":true}`))
 	if err := rc.ValidateSchema(testSchema()); err == nil || !strings.Contains(err.Error(), "token") {
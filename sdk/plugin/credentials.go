@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 )
 
 // CredentialKindInfo is the public metadata for a reusable credential kind.
@@ -13,6 +14,17 @@ type CredentialKindInfo struct {
 	Label               string         `json:"label"`
 	Fields              []Field        `json:"fields"`
 	CompatibleProtocols []string       `json:"compatibleProtocols,omitempty"`
+	// AllowUnknownFields accepts values for keys the kind does not declare;
+	// they are stored with the secret material. By default they are rejected.
+	AllowUnknownFields bool `json:"allowUnknownFields,omitempty"`
+	// DeprecatedAfter marks a kind being phased out; saves after it still
+	// succeed but carry a warning.
+	DeprecatedAfter *time.Time `json:"deprecatedAfter,omitempty"`
+}
+
+// Deprecated reports whether the kind's deprecation date has passed at now.
+func (c CredentialKindInfo) Deprecated(now time.Time) bool {
+	return c.DeprecatedAfter != nil && now.After(*c.DeprecatedAfter)
 }
 
 // CredentialPublicField marks a credential field as non-secret metadata that
@@ -364,7 +376,7 @@ func validateCredentialKindInfo(info CredentialKindInfo) error {
 
 func credentialFieldTypeAllowed(t FieldType) bool {
 	switch t {
-	case FieldText, FieldPassword, FieldTextarea:
+	case FieldText, FieldPassword, FieldTextarea, FieldNumber, FieldToggle, FieldSelect:
 		return true
 	default:
 		return false
@@ -372,8 +384,11 @@ func credentialFieldTypeAllowed(t FieldType) bool {
 }
 
 func validateCredentialFieldSubset(kind CredentialKind, field Field) error {
+	if field.Type == FieldSelect && len(field.Options) == 0 {
+		return fmt.Errorf("credential kind %q field %q needs Options", kind, field.Key)
+	}
 	if field.Default != nil ||
-		len(field.Options) > 0 ||
+		(len(field.Options) > 0 && field.Type != FieldSelect) ||
 		field.OptionsSource != nil ||
 		field.Credential != nil ||
 		field.VisibleWhen != nil ||
@@ -10,6 +10,12 @@ const (
 	CredentialRefField = "credential_id"
 )
 
+// ItemCredentialField names the binding for a credential_ref field nested in
+// an array item, e.g. ItemCredentialField("jump_hosts", 0, "credential_id").
+func ItemCredentialField(arrayKey string, index int, field string) string {
+	return fmt.Sprintf("%s.%d.%s", arrayKey, index, field)
+}
+
 // ResolvedCredential is decrypted credential material resolved by the core for
 // one credential_ref config field.
 type ResolvedCredential struct {
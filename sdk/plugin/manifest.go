@@ -22,6 +22,10 @@ type Icon struct {
 // Capability is a declarative feature tag, not behavior dispatch.
 type Capability string
 
+// CapabilityExec marks a plugin whose sessions run non-interactive commands
+// through Executor.
+const CapabilityExec Capability = "exec"
+
 // Layout selects how the connection workspace is arranged.
 type Layout string
 
@@ -102,8 +106,11 @@ type Manifest struct {
 	Icon        Icon
 	Category    Category
 
-	Config       Schema
-	Capabilities []Capability
+	Config Schema
+	// ConfigMigrations rename config fields across versions, oldest first, so
+	// connections saved under an earlier Version keep their values.
+	ConfigMigrations []ConfigMigration
+	Capabilities     []Capability
 	// CredentialKinds declares reusable credential kinds owned by this plugin.
 	// Shared cross-protocol kinds may still come from the core catalog.
 	CredentialKinds []CredentialKindInfo
@@ -133,6 +140,11 @@ func (m Manifest) SupportsTransport(t Transport) bool {
 	return slices.Contains(m.SupportedTransports, t)
 }
 
+// HasCapability reports whether the manifest declares c.
+func (m Manifest) HasCapability(c Capability) bool {
+	return slices.Contains(m.Capabilities, c)
+}
+
 // StreamByRoute returns the declared stream served by a WS route, if any.
 func (m Manifest) StreamByRoute(routeID string) (Stream, bool) {
 	for _, s := range m.Streams {
@@ -428,9 +428,14 @@ func fileBrowserRoutes() PanelConfigProperty {
 			prop("rename", stringProp()),
 			prop("delete", stringProp()),
 			prop("move", stringProp()),
+			prop("moveBatch", stringProp()),
 			prop("copy", stringProp()),
 			prop("chmod", stringProp()),
 			prop("archive", stringProp()),
+			prop("bookmarks", stringProp()),
+			prop("bookmarkCreate", stringProp()),
+			prop("bookmarkUpdate", stringProp()),
+			prop("bookmarkDelete", stringProp()),
 		),
 	}
 }
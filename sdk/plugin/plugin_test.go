@@ -358,6 +358,20 @@ func TestValidateRejectsBadManifests(t *testing.T) {
 				Credential: &plugin.CredentialSelector{Kind: "custom_password"},
 			})
 		}},
+		{"credential kind select needs options", "needs Options", func(m *plugin.Manifest, _ *[]plugin.Route) {
+			m.Config = plugin.Schema{Groups: []plugin.Group{{Name: "Auth"}}}
+			m.CredentialKinds = []plugin.CredentialKindInfo{{
+				Kind: "custom_region", Label: "Custom region",
+				Fields: []plugin.Field{plugin.CredentialPublicField(plugin.Field{Key: "region", Label: "Region", Type: plugin.FieldSelect})},
+			}}
+		}},
+		{"nested secret config field", "secret fields must be top-level", func(m *plugin.Manifest, _ *[]plugin.Route) {
+			m.Config = plugin.Schema{Groups: []plugin.Group{{Name: "Auth", Fields: []plugin.Field{{
+				Key: "tunnel", Label: "Tunnel", Type: plugin.FieldObject, Fields: []plugin.Field{
+					{Key: "password", Label: "Password", Type: plugin.FieldPassword, Secret: true},
+				},
+			}}}}}
+		}},
 		{"credential kind declared but unused", "declared but not used", func(m *plugin.Manifest, _ *[]plugin.Route) {
 			m.CredentialKinds = []plugin.CredentialKindInfo{{
 				Kind: "custom_password", Label: "Custom password",
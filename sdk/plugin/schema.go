@@ -134,6 +134,10 @@ type Field struct {
 	Validators    []Validator         `json:"validators,omitempty"`
 	// Step is the increment for number/slider inputs.
 	Step any `json:"step,omitempty"`
+	// OwnerOnly values reach the plugin only when the connection owner opens
+	// it, unless the boolean field named by SharedBy is true.
+	OwnerOnly bool   `json:"ownerOnly,omitempty"`
+	SharedBy  string `json:"sharedBy,omitempty"`
 
 	// Composite fields: Fields holds object fields; Item describes array items.
 	Fields    []Field `json:"fields,omitempty"`
@@ -22,9 +22,12 @@ type ConnectConfig struct {
 	ActorScope   string
 	Transport    Transport
 	Config       map[string]any
-	Credentials  ResolvedCredentials
-	Net          NetTransport
-	Storage      Storage
+	// Settings holds the admin's driver settings, with defaults filled in,
+	// for plugins that implement Configurable; nil otherwise.
+	Settings    map[string]any
+	Credentials ResolvedCredentials
+	Net         NetTransport
+	Storage     Storage
 }
 
 // String returns a typed config value, or "" if absent/not a string.
@@ -76,6 +79,40 @@ type Session interface {
 	Close() error
 }
 
+// Reconnectable is an optional Session capability. After a transient transport
+// drop the host calls Reconnect until it succeeds or the grace window ends; it
+// re-establishes the upstream in place so the session and its state carry on.
+type Reconnectable interface {
+	Reconnect(ctx context.Context) error
+}
+
+// ClipboardSupporter is an optional Session capability for protocols that
+// sync a clipboard, such as VNC. The host relays text both ways and
+// keeps none of it once the session closes.
+type ClipboardSupporter interface {
+	// SetClipboard replaces the remote clipboard with text from the browser.
+	SetClipboard(ctx context.Context, text string) error
+	// OnClipboard registers fn to receive the remote clipboard's text each
+	// time it changes. The host calls it once, after the session connects;
+	// fn may be called from any goroutine.
+	OnClipboard(fn func(text string))
+}
+
+// ExecRequest runs one command without a terminal.
+type ExecRequest struct {
+	Command string
+	Env     map[string]string
+	Stdout  io.Writer
+	Stderr  io.Writer
+}
+
+// Executor is an optional Session capability for plugins that declare
+// CapabilityExec. Exec returns the command's exit status; an error means the
+// command could not run to completion.
+type Executor interface {
+	Exec(ctx context.Context, req ExecRequest) (exitCode int, err error)
+}
+
 // Plugin is a stateless, compiled-in protocol implementation.
 type Plugin interface {
 	Manifest() Manifest
@@ -83,6 +120,13 @@ type Plugin interface {
 	Connect(ctx context.Context, cfg ConnectConfig) (Session, error)
 }
 
+// Configurable is an optional Plugin capability for instance-wide settings an
+// admin edits once for every connection of the protocol. Values are read when
+// a session opens, so a change never reaches sessions already running.
+type Configurable interface {
+	Settings() Schema
+}
+
 // HTTPProxy is an optional Session capability for browser-accessible upstreams.
 type HTTPProxy interface {
 	ServeHTTPProxy(w http.ResponseWriter, r *http.Request)
@@ -0,0 +1,74 @@
+package plugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// FieldBinding says where the gateway keeps a submitted config value.
+type FieldBinding string
+
+const (
+	// BindingConfig values are stored as plaintext connection config.
+	BindingConfig FieldBinding = "config"
+	// BindingSecret values are encrypted and never returned to the browser.
+	BindingSecret FieldBinding = "secret"
+	// BindingCredential values are the id of a reusable credential.
+	BindingCredential FieldBinding = "credential"
+)
+
+// ConfigTemplate is the resolved connection form for one protocol: the config
+// schema plus its computed defaults and per-field storage bindings. Hash
+// changes whenever the schema does, so clients can cache on it.
+type ConfigTemplate struct {
+	Protocol string                  `json:"protocol"`
+	Version  string                  `json:"version"`
+	Hash     string                  `json:"hash"`
+	Schema   Schema                  `json:"schema"`
+	Defaults map[string]any          `json:"defaults"`
+	Bindings map[string]FieldBinding `json:"bindings"`
+	// Migrations are the manifest's config field renames, oldest first.
+	Migrations []ConfigMigration `json:"migrations,omitempty"`
+}
+
+// BuildConfigTemplate resolves a manifest's connection form.
+func BuildConfigTemplate(m Manifest) ConfigTemplate {
+	bindings := map[string]FieldBinding{}
+	for _, group := range m.Config.Groups {
+		for _, f := range group.Fields {
+			bindings[f.Key] = f.Binding()
+		}
+	}
+	return ConfigTemplate{
+		Protocol:   m.Name,
+		Version:    m.Version,
+		Hash:       SchemaHash(m.Config),
+		Schema:     m.Config,
+		Defaults:   m.Config.Defaults(),
+		Bindings:   bindings,
+		Migrations: m.ConfigMigrations,
+	}
+}
+
+// Binding reports where a top-level config field's value is kept.
+func (f Field) Binding() FieldBinding {
+	switch {
+	case f.Type == FieldCredentialRef:
+		return BindingCredential
+	case f.Secret:
+		return BindingSecret
+	default:
+		return BindingConfig
+	}
+}
+
+// SchemaHash is a stable digest of the schema's JSON form.
+func SchemaHash(s Schema) string {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
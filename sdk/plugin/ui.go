@@ -217,6 +217,13 @@ type FileBrowserRoutes struct {
 	Copy     string `json:"copy,omitempty"`
 	Chmod    string `json:"chmod,omitempty"`
 	Archive  string `json:"archive,omitempty"`
+	// MoveBatch moves many {source, target} pairs in one request.
+	MoveBatch string `json:"moveBatch,omitempty"`
+	// Bookmark routes back a saved-directory sidebar; Bookmarks lists them.
+	Bookmarks      string `json:"bookmarks,omitempty"`
+	BookmarkCreate string `json:"bookmarkCreate,omitempty"`
+	BookmarkUpdate string `json:"bookmarkUpdate,omitempty"`
+	BookmarkDelete string `json:"bookmarkDelete,omitempty"`
 }
 
 // FileUploadConfig configures browser-to-backend uploads for a file browser.
@@ -110,6 +110,8 @@ func ValidateWithCredentialKinds(m Manifest, routes []Route, existing Credential
 	}
 
 	validateSchemaShape("config", m.Config, add)
+	validateConfigSecrets(m.Config, add)
+	validateConfigMigrations(m, add)
 	for _, rt := range routes {
 		if rt.Input != nil {
 			validateSchemaShape("route "+rt.ID+" input", *rt.Input, add)
@@ -144,6 +146,25 @@ func validateCredentialSelectors(schema Schema, catalog CredentialKindCatalog, a
 	return used
 }
 
+// validateConfigSecrets rejects secret fields nested in composite config
+// fields. Only top-level secrets are split out and encrypted; a nested one
+// would be stored in the plaintext config.
+func validateConfigSecrets(schema Schema, add func(string, ...any)) {
+	for _, group := range schema.Groups {
+		for _, top := range group.Fields {
+			var nested []Field
+			if top.Item != nil {
+				nested = append(nested, *top.Item)
+			}
+			walkFields(append(nested, top.Fields...), func(f Field) {
+				if f.Secret {
+					add("config field %q nests secret field %q; secret fields must be top-level", top.Key, f.Key)
+				}
+			})
+		}
+	}
+}
+
 // validateSchemaShape checks composite (object/array) field wiring.
 func validateSchemaShape(ctx string, schema Schema, add func(string, ...any)) {
 	var check func(prefix string, fields []Field)
@@ -753,9 +774,14 @@ func checkPanelConfigRoutes(
 		checkWriteRouteID(ctx+" routes.rename", c.Routes.Rename)
 		checkWriteRouteID(ctx+" routes.delete", c.Routes.Delete)
 		checkWriteRouteID(ctx+" routes.move", c.Routes.Move)
+		checkWriteRouteID(ctx+" routes.moveBatch", c.Routes.MoveBatch)
 		checkWriteRouteID(ctx+" routes.copy", c.Routes.Copy)
 		checkWriteRouteID(ctx+" routes.chmod", c.Routes.Chmod)
 		checkRouteID(ctx+" routes.archive", c.Routes.Archive)
+		checkRouteID(ctx+" routes.bookmarks", c.Routes.Bookmarks)
+		checkWriteRouteID(ctx+" routes.bookmarkCreate", c.Routes.BookmarkCreate)
+		checkWriteRouteID(ctx+" routes.bookmarkUpdate", c.Routes.BookmarkUpdate)
+		checkWriteRouteID(ctx+" routes.bookmarkDelete", c.Routes.BookmarkDelete)
 		for i, ctrl := range c.Controls {
 			if ctrl.OptionsSource != nil {
 				checkReadSource(fmt.Sprintf("%s control[%d] optionsSource", ctx, i), *ctrl.OptionsSource)
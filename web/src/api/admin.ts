@@ -53,8 +53,86 @@ export const adminUsersApi = {
   },
 };
 
+export interface ReadOnlyState {
+  readOnly: boolean;
+  persisted: boolean;
+}
+
+// A zero limit turns that check off; blockSeconds 0 alerts without a lockout.
+export interface CredentialReadQuota {
+  userLimit: number;
+  credentialLimit: number;
+  windowSeconds: number;
+  blockSeconds: number;
+}
+
 export const adminSettingsApi = {
   emailStatus: () => api.get<{ enabled: boolean }>("/admin/email"),
+  readOnly: () => api.get<ReadOnlyState>("/admin/read-only"),
+  setReadOnly: (readOnly: boolean) =>
+    api.post<ReadOnlyState>("/admin/read-only", { readOnly }),
+  credentialReadQuota: () =>
+    api.get<CredentialReadQuota>("/admin/credential-read-quota"),
+  setCredentialReadQuota: (quota: CredentialReadQuota) =>
+    api.put<CredentialReadQuota>("/admin/credential-read-quota", quota),
+};
+
+export interface PermissionTraceStep {
+  rule: string;
+  matched: boolean;
+  detail?: string;
+}
+
+export interface PermissionExplanation {
+  userId: string;
+  resourceType: "connection" | "credential";
+  resourceId: string;
+  permission: string;
+  risk: string;
+  allowed: boolean;
+  reason?: string;
+  trace: PermissionTraceStep[];
+}
+
+export interface PermissionExplainQuery {
+  userId: string;
+  resourceType: "connection" | "credential";
+  resourceId: string;
+  permission?: string;
+  risk?: string;
+}
+
+// adminPermissionsApi replays access decisions for support (root only).
+export const adminPermissionsApi = {
+  explain: (q: PermissionExplainQuery) => {
+    const sp = new URLSearchParams({
+      user_id: q.userId,
+      resource_type: q.resourceType,
+      resource_id: q.resourceId,
+    });
+    if (q.permission) sp.set("permission", q.permission);
+    if (q.risk) sp.set("risk", q.risk);
+    return api.get<PermissionExplanation>(
+      `/admin/permissions/explain?${sp.toString()}`,
+    );
+  },
+};
+
+export interface ActivityReport {
+  range: string;
+  since: string;
+  generatedAt: string;
+  sessionsPerDay: { day: string; sessions: number }[];
+  topConnections: { connectionId: string; name?: string; sessions: number }[];
+  activeUsers: number;
+  recordingBytes: number;
+  credentialRetrievals: number;
+}
+
+// adminActivityApi feeds the usage dashboard; the server caches for 5 minutes.
+export const adminActivityApi = {
+  get: (range = "30d") =>
+    api.get<ActivityReport>(`/admin/activity?range=${encodeURIComponent(range)}`),
 };
 
 // adminProtocolsApi manages per-protocol availability (built-in and external).
@@ -75,3 +153,63 @@ export const adminMarketApi = {
   uninstall: (name: string) =>
     api.del<{ name: string; uninstalled: boolean }>(`/admin/market/${name}`),
 };
+
+export type WebhookEvent =
+  | "session.started"
+  | "session.closed"
+  | "session.resized"
+  | "session.reconnecting"
+  | "session.recording_failed"
+  | "recording.completed"
+  | "credential.read_alert";
+
+export interface WebhookSummary {
+  id: string;
+  name: string;
+  url: string;
+  events: WebhookEvent[];
+  enabled: boolean;
+  hasSecret: boolean;
+  createdBy?: string;
+  createdAt: string;
+  updatedAt: string;
+}
+
+// An empty secret keeps the stored one on update and is generated on create.
+export interface WebhookInput {
+  name: string;
+  url: string;
+  secret?: string;
+  events: WebhookEvent[];
+  enabled?: boolean;
+}
+
+export interface WebhookDelivery {
+  id: string;
+  webhookId: string;
+  deliveryId: string;
+  event: string;
+  attempt: number;
+  statusCode?: number;
+  error?: string;
+  durationMs: number;
+  test?: boolean;
+  createdAt: string;
+}
+
+// adminWebhooksApi manages lifecycle event webhooks and their delivery history.
+export const adminWebhooksApi = {
+  list: () => api.get<WebhookSummary[]>("/admin/webhooks"),
+  create: (body: WebhookInput) =>
+    api.post<{ webhook: WebhookSummary; secret?: string }>(
+      "/admin/webhooks",
+      body,
+    ),
+  update: (id: string, body: WebhookInput) =>
+    api.put<WebhookSummary>(`/admin/webhooks/${id}`, body),
+  remove: (id: string) => api.del(`/admin/webhooks/${id}`),
+  deliveries: (id: string) =>
+    api.get<WebhookDelivery[]>(`/admin/webhooks/${id}/deliveries`),
+  test: (id: string) =>
+    api.post<WebhookDelivery>(`/admin/webhooks/${id}/test`),
+};
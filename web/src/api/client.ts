@@ -1,8 +1,21 @@
 export const API_BASE = "/api";
 
+export interface ApiFieldError {
+  field: string;
+  message: string;
+}
+
 export class ApiError extends Error {
   readonly status: number;
   readonly authRequired: boolean;
+  // code is the server's stable reason (e.g. "name_taken"); suggestion is a
+  // replacement value it proposes, such as a free connection name.
+  code?: string;
+  suggestion?: string;
+  // fields lists every rejected form field so a form can mark each one.
+  fields?: ApiFieldError[];
+  // requestId is the correlation id to quote when reporting the failure.
+  requestId?: string;
 
   constructor(status: number, message: string, authRequired = false) {
     super(message);
@@ -39,13 +52,25 @@ export function apiErrorFromResponse(
   authRequired = false,
 ): ApiError {
   let message = statusText;
+  let parsed: {
+    error?: string;
+    code?: string;
+    suggestion?: string;
+    fields?: ApiFieldError[];
+    requestId?: string;
+  } = {};
   try {
-    const parsed = JSON.parse(body) as { error?: string };
+    parsed = JSON.parse(body) as typeof parsed;
     if (parsed.error) message = parsed.error;
   } catch {
     if (body) message = body;
   }
-  return new ApiError(status, message, authRequired);
+  const err = new ApiError(status, message, authRequired);
+  err.code = parsed.code;
+  err.suggestion = parsed.suggestion;
+  err.fields = parsed.fields;
+  err.requestId = parsed.requestId;
+  return err;
 }
 
 async function responseError(res: Response): Promise<ApiError> {
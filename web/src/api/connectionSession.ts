@@ -3,7 +3,9 @@ import { api } from "./client";
 export const ConnectionSessionState = {
   Idle: "idle",
   Connecting: "connecting",
+  Pending: "pending",
   Connected: "connected",
+  Reconnecting: "reconnecting",
   Closed: "closed",
   Error: "error",
 } as const;
@@ -11,23 +13,125 @@ export type ConnectionSessionState =
   (typeof ConnectionSessionState)[keyof typeof ConnectionSessionState];
 
 export interface ConnectionSession {
+  id?: string;
   state: ConnectionSessionState;
   reason?: string;
+  failure?: string;
+  stage?: string;
   channels: number;
   streams: number;
+  bytesIn?: number;
+  bytesOut?: number;
   lastSeen?: string;
   lastHealthCheck?: string;
   idleExpiresIn?: number;
+  cols?: number;
+  rows?: number;
+  capabilities?: ConnectionCapabilities;
 }
 
+export interface ConnectionCapabilities {
+  fileTransfer: boolean;
+  clipboard: boolean;
+}
+
+// wait=true keeps the request open until a new session has connected or
+// failed; without it the server answers at once with a pending session.
 export function keepaliveConnectionSession(
   connectionId: string,
 ): Promise<ConnectionSession> {
   return api.post<ConnectionSession>(
-    `/connections/${encodeURIComponent(connectionId)}/session`,
+    `/connections/${encodeURIComponent(connectionId)}/session?wait=true`,
+  );
+}
+
+export function resizeConnectionSession(
+  connectionId: string,
+  cols: number,
+  rows: number,
+): Promise<ConnectionSession> {
+  return api.post<ConnectionSession>(
+    `/connections/${encodeURIComponent(connectionId)}/session/resize`,
+    { cols, rows },
   );
 }
 
 export function closeConnectionSession(connectionId: string): Promise<unknown> {
   return api.del(`/connections/${encodeURIComponent(connectionId)}/session`);
 }
+
+export interface FolderLaunchResult {
+  connectionId: string;
+  name: string;
+  session?: ConnectionSession;
+  status: number;
+  error?: string;
+}
+
+export interface FolderLaunch {
+  batchId: string;
+  results: FolderLaunchResult[];
+}
+
+export function launchConnectionFolder(
+  folderId: string,
+  concurrency?: number,
+): Promise<FolderLaunch> {
+  return api.post<FolderLaunch>(
+    `/connection-folders/${encodeURIComponent(folderId)}/launch`,
+    { concurrency },
+  );
+}
+
+export type SessionShareMode = "read" | "write";
+
+export interface SessionShareLink {
+  id: string;
+  connectionId: string;
+  ownerId: string;
+  createdBy: string;
+  mode: SessionShareMode;
+  maxUses: number;
+  uses: number;
+  status: "active" | "used" | "expired" | "revoked";
+  expiresAt: string;
+  createdAt: string;
+}
+
+export interface SessionShareLinkRequest {
+  mode: SessionShareMode;
+  maxUses?: number;
+  expiresInSeconds?: number;
+}
+
+function shareLinksPath(connectionId: string): string {
+  return `/connections/${encodeURIComponent(connectionId)}/session/share-links`;
+}
+
+export interface SessionJoin {
+  connectionId: string;
+  ownerId: string;
+  mode: SessionShareMode;
+  writeHolder: boolean;
+  cols?: number;
+  rows?: number;
+}
+
+export const sessionSharesApi = {
+  list: (connectionId: string) =>
+    api.get<SessionShareLink[]>(shareLinksPath(connectionId)),
+  // The token is returned only once; build the join link from it.
+  create: (connectionId: string, body: SessionShareLinkRequest) =>
+    api.post<{ link: SessionShareLink; token: string }>(
+      shareLinksPath(connectionId),
+      body,
+    ),
+  revoke: (connectionId: string, linkId: string) =>
+    api.del(`${shareLinksPath(connectionId)}/${encodeURIComponent(linkId)}`),
+  // cols/rows carry the shared terminal's size so the joiner starts in sync.
+  join: (token: string) =>
+    api.post<SessionJoin>(`/sessions/join/${encodeURIComponent(token)}`),
+  // Joiners watch, and type while holding write, over this stream.
+  sharedStreamPath: (connectionId: string, ownerId: string) =>
+    `/connections/${encodeURIComponent(connectionId)}/session/shared/${encodeURIComponent(ownerId)}/stream`,
+};
@@ -15,6 +15,9 @@ export interface ConnectionCreate {
   aiMode?: string;
   aiAllowDestructive?: boolean;
   aiAutoApprove?: boolean;
+  allowFileTransfer?: boolean;
+  allowClipboard?: boolean;
+  recordingFailClosed?: boolean;
 }
 
 export interface ConnectionUpdate {
@@ -26,6 +29,9 @@ export interface ConnectionUpdate {
   aiMode?: string;
   aiAllowDestructive?: boolean;
   aiAutoApprove?: boolean;
+  allowFileTransfer?: boolean;
+  allowClipboard?: boolean;
+  recordingFailClosed?: boolean;
 }
 
 export interface LayoutItem {
@@ -50,6 +56,16 @@ export const connectionsApi = {
   remove: (id: string) => api.del(`/connections/${id}`),
   saveLayout: (items: LayoutItem[], folders: LayoutFolderItem[]) =>
     api.put("/connections/layout", { items, folders }),
+  favorites: () =>
+    api.get<ConnectionSummary[]>("/connections?favorites_only=true"),
+  inFolder: (folderId: string, recursive = false) =>
+    api.get<ConnectionSummary[]>(
+      `/connections?folder=${encodeURIComponent(folderId)}${recursive ? "&recursive=true" : ""}`,
+    ),
+  favorite: (id: string) => api.put<void>(`/connections/${id}/favorite`),
+  unfavorite: (id: string) => api.del(`/connections/${id}/favorite`),
+  reorderFavorites: (connectionIds: string[]) =>
+    api.patch<void>("/me/favorites/order", { connectionIds }),
 };
 
 export interface FolderCreate {
@@ -1,7 +1,9 @@
 import { api } from "./client";
 import type {
+  CredentialDiff,
   CredentialKindInfo,
   CredentialSummary,
+  CredentialVersionInfo,
 } from "../types/projection";
 
 export interface CredentialFilters {
@@ -31,5 +33,11 @@ export const credentialsApi = {
   update: (id: string, body: CredentialPayload) =>
     api.put<CredentialSummary>(`/credentials/${id}`, body),
   remove: (id: string) => api.del(`/credentials/${id}`),
+  versions: (id: string) =>
+    api.get<CredentialVersionInfo[]>(`/credentials/${id}/versions`),
+  diffVersions: (id: string, from: number, to: number) =>
+    api.get<CredentialDiff>(
+      `/credentials/${id}/versions/diff?from=${from}&to=${to}`,
+    ),
   kinds: () => api.get<CredentialKindInfo[]>("/credential-kinds"),
 };
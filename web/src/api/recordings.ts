@@ -1,8 +1,10 @@
 import { api, API_BASE, ApiError, getCsrfToken } from "./client";
 import type {
+  RecordingAnnotation,
   RecordingFilters,
   RecordingFormat,
   RecordingSummary,
+  RecordingTranscript,
 } from "../types/projection";
 
 function query(f: RecordingFilters): string {
@@ -61,6 +63,19 @@ export const recordingsApi = {
     api.get<RecordingSummary[]>(`/connections/${id}/recordings${query(f)}`),
   get: (id: string) => api.get<RecordingSummary>(`/recordings/${id}`),
   remove: (id: string) => api.del(`/recordings/${id}`),
+  transcript: (id: string) =>
+    api.get<RecordingTranscript>(`/recordings/${id}/transcript`),
+  annotations: (id: string) =>
+    api.get<RecordingAnnotation[]>(`/recordings/${id}/annotations`),
+  annotate: (id: string, text: string, offset?: number) =>
+    api.post<RecordingAnnotation>(`/recordings/${id}/annotations`, {
+      text,
+      offset,
+    }),
+  pause: (id: string) =>
+    api.post<{ paused: boolean }>(`/recordings/${id}/pause`),
+  resume: (id: string) =>
+    api.post<{ paused: boolean }>(`/recordings/${id}/resume`),
   contentUrl: (id: string, options: { download?: boolean } = {}) => {
     const sp = new URLSearchParams();
     if (options.download) sp.set("download", "1");
@@ -38,6 +38,7 @@ export const useConnectionSessionsStore = defineStore(
       switch (session.state) {
         case "connected":
         case "connecting":
+        case "reconnecting":
           ws.setConnected(connectionId, true);
           if (started) startHeartbeat();
           return true;
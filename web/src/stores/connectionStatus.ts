@@ -47,6 +47,7 @@ export const useConnectionStatusStore = defineStore("connectionStatus", () => {
         connected(id);
         return;
       case ConnectionSessionState.Connecting:
+      case ConnectionSessionState.Pending:
         connecting(id);
         return;
       case ConnectionSessionState.Error:
@@ -218,6 +218,8 @@ function mergeConnectionSummary(
     aiAllowDestructive:
       updated.aiAllowDestructive ?? input.aiAllowDestructive ?? false,
     aiAutoApprove: updated.aiAutoApprove ?? input.aiAutoApprove ?? false,
+    allowFileTransfer: updated.allowFileTransfer ?? input.allowFileTransfer,
+    allowClipboard: updated.allowClipboard ?? input.allowClipboard,
   };
 }
 
@@ -33,6 +33,7 @@ export interface ConnectionSummary {
   config?: Record<string, unknown>;
   online?: boolean;
   status?: ConnectionStatus;
+  protocolBlocked?: boolean;
   canManage?: boolean;
   canShare?: boolean;
   access?: ConnectionAccess;
@@ -44,8 +45,12 @@ export interface ConnectionSummary {
   aiMode?: string;
   aiAllowDestructive?: boolean;
   aiAutoApprove?: boolean;
+  allowFileTransfer?: boolean;
+  allowClipboard?: boolean;
   folderId?: string;
   sortOrder?: number;
+  isFavorite?: boolean;
+  favoritePosition?: number;
 }
 
 export const FolderColor = {
@@ -85,6 +90,29 @@ export interface CredentialSummary {
   values?: Record<string, string>;
   protocols?: string[];
   updatedAt?: string;
+  // warnings accompany a save that succeeded, e.g. a deprecated kind.
+  warnings?: string[];
+}
+
+export interface CredentialVersionInfo {
+  version: number;
+  createdBy?: string;
+  createdAt: string;
+}
+
+export interface CredentialFieldDiff {
+  key: string;
+  change: "added" | "removed" | "changed" | "unchanged";
+  changed: boolean;
+  fromHash?: string;
+  toHash?: string;
+}
+
+export interface CredentialDiff {
+  credentialId: string;
+  from: number;
+  to: number;
+  fields: CredentialFieldDiff[];
 }
 
 export interface ConnectionDetail {
@@ -100,6 +128,9 @@ export interface ConnectionDetail {
   aiMode?: string;
   aiAllowDestructive?: boolean;
   aiAutoApprove?: boolean;
+  allowFileTransfer?: boolean;
+  allowClipboard?: boolean;
+  recordingFailClosed?: boolean;
 }
 
 export interface CredentialRefState {
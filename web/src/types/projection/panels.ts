@@ -141,9 +141,14 @@ export interface FileBrowserRoutes {
   rename?: string;
   delete?: string;
   move?: string;
+  moveBatch?: string;
   copy?: string;
   chmod?: string;
   archive?: string;
+  bookmarks?: string;
+  bookmarkCreate?: string;
+  bookmarkUpdate?: string;
+  bookmarkDelete?: string;
 }
 
 export interface FileUploadConfig {
@@ -47,12 +47,34 @@ export interface RecordingSummary {
   class: RecordingClass;
   format: RecordingFormat;
   authoritative: boolean;
+  inputCaptured: boolean;
   status: RecordingStatus;
+  partial?: boolean; // capture stopped early on a write error
+  error?: string;
   title?: string;
   startedAt: string;
   endedAt?: string;
   durationMs: number;
   size: number;
+  hasTranscript: boolean;
+  matches?: TranscriptMatch[]; // transcript lines that matched ?q=
+}
+
+export interface TranscriptMatch {
+  offset: number; // seconds from recording start
+  snippet: string;
+}
+
+export interface RecordingTranscript {
+  lines: { offset: number; text: string }[];
+}
+
+export interface RecordingAnnotation {
+  offset: number; // seconds from recording start
+  text: string;
+  userId: string;
+  live: boolean;
+  at: string;
 }
 
 export interface RecordingFilters {
@@ -61,4 +83,8 @@ export interface RecordingFilters {
   protocol?: string;
   class?: RecordingClass;
   status?: RecordingStatus;
+  q?: string;
+  since?: string;
+  until?: string;
+  sort?: "duration" | "connection";
 }
@@ -37,6 +37,8 @@ export interface CredentialKindInfo {
   label: string;
   fields: Field[];
   compatibleProtocols?: string[];
+  allowUnknownFields?: boolean;
+  deprecatedAfter?: string;
 }
 
 export interface CredentialSelector {
@@ -103,6 +105,8 @@ export interface Field {
   visibleWhen?: Condition;
   validators?: Validator[];
   step?: number;
+  ownerOnly?: boolean;
+  sharedBy?: string;
   fields?: Field[];
   item?: Field;
   minItems?: number;
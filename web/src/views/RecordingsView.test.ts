@@ -34,10 +34,12 @@ const rows: RecordingSummary[] = [
     class: "terminal",
     format: "asciicast_v2",
     authoritative: true,
+    inputCaptured: false,
     status: "finalized",
     startedAt: new Date().toISOString(),
     durationMs: 5000,
     size: 2048,
+    hasTranscript: false,
   },
   {
     id: "r2",
@@ -48,10 +50,12 @@ const rows: RecordingSummary[] = [
     class: "desktop",
     format: "webm_canvas",
     authoritative: false,
+    inputCaptured: false,
     status: "active",
     startedAt: new Date().toISOString(),
     durationMs: 0,
     size: 0,
+    hasTranscript: false,
   },
 ];
 